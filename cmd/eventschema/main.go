@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"flowforge/pkg/events"
+)
+
+var output = flag.String("o", "docs/events/schema.json", "JSON Schema 输出路径")
+
+// main 由事件类型生成系统事件的 JSON Schema 文件
+func main() {
+	flag.Parse()
+
+	data, err := events.SchemaJSON()
+	if err != nil {
+		log.Fatalf("生成事件 JSON Schema 失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		log.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		log.Fatalf("写入 %s 失败: %v", *output, err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/client"
+)
+
+const usage = `用法: flowforge [-server URL] [-token TOKEN] <命令> [-yes] [参数]
+
+破坏性操作需要确认：先显示将被删除的数据，输入资源名称后执行；-yes 跳过询问，仍然完成两步确认。
+
+命令:
+  delete-project <项目ID>          删除项目及其流水线与运行
+  delete-user <用户ID>             删除用户（管理员）
+  delete-environments <项目ID>     删除项目的全部环境变量
+  cancel-all <流水线ID> [说明]     取消流水线全部未结束的运行
+  prune-retention                  按保留策略立即清理（管理员）
+
+服务地址与令牌默认取自 FLOWFORGE_URL 与 FLOWFORGE_TOKEN。
+`
+
+// main FlowForge 命令行，执行需要确认的破坏性操作
+func main() {
+	server := flag.String("server", os.Getenv("FLOWFORGE_URL"), "服务地址")
+	token := flag.String("token", os.Getenv("FLOWFORGE_TOKEN"), "API 令牌")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := client.New(*server, *token, client.WithTimeout(5*time.Minute), client.WithUserAgent("flowforge-cli/"+client.Version))
+	if err != nil {
+		fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, c, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+// run 执行子命令
+func run(ctx context.Context, c *client.Client, command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	yes := fs.Bool("yes", false, "不询问，直接确认")
+	fs.Parse(args)
+
+	confirm := prompt
+	if *yes {
+		confirm = client.AutoConfirm
+	}
+
+	switch command {
+	case "delete-project":
+		id, err := idArg(fs, "项目ID")
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteProject(ctx, id, confirm); err != nil {
+			return err
+		}
+		fmt.Printf("已删除项目 %d\n", id)
+	case "delete-user":
+		id, err := idArg(fs, "用户ID")
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteUser(ctx, id, confirm); err != nil {
+			return err
+		}
+		fmt.Printf("已删除用户 %d\n", id)
+	case "delete-environments":
+		id, err := idArg(fs, "项目ID")
+		if err != nil {
+			return err
+		}
+		deleted, err := c.DeleteEnvironments(ctx, id, confirm)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已删除 %d 个环境变量\n", deleted)
+	case "cancel-all":
+		id, err := idArg(fs, "流水线ID")
+		if err != nil {
+			return err
+		}
+		cancelled, err := c.CancelAllRuns(ctx, id, strings.Join(fs.Args()[1:], " "), confirm)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已取消 %d 个运行\n", cancelled)
+	case "prune-retention":
+		totals, err := c.PruneRetention(ctx, confirm)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已清理 %d 次运行、%d 个日志、%d 个制品\n", totals.Runs.Count, totals.Logs.Count, totals.Artifacts.Count)
+	default:
+		return fmt.Errorf("未知命令: %s", command)
+	}
+	return nil
+}
+
+// idArg 第一个位置参数为资源 ID
+func idArg(fs *flag.FlagSet, name string) (uint, error) {
+	if fs.NArg() == 0 {
+		return 0, fmt.Errorf("缺少%s", name)
+	}
+	id, err := strconv.ParseUint(fs.Arg(0), 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("无效的%s: %s", name, fs.Arg(0))
+	}
+	return uint(id), nil
+}
+
+// prompt 显示将被删除的数据，要求输入资源名称；输入为空时放弃操作
+func prompt(challenge *client.Challenge) (string, error) {
+	fmt.Fprintln(os.Stderr, challenge.Summary.Description)
+	keys := make([]string, 0, len(challenge.Summary.Counts))
+	for key := range challenge.Summary.Counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(os.Stderr, "  %-22s %d\n", key, challenge.Summary.Counts[key])
+	}
+	fmt.Fprintf(os.Stderr, "输入 %s 确认: ", challenge.ResourceName)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", client.ErrConfirmationDeclined
+	}
+	name := strings.TrimRight(line, "\r\n")
+	if name == "" {
+		return "", client.ErrConfirmationDeclined
+	}
+	return name, nil
+}
+
+// fatal 输出错误并退出
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "flowforge:", err)
+	os.Exit(1)
+}
@@ -2,19 +2,41 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"flowforge/pkg/anomaly"
 	"flowforge/pkg/api"
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/backup"
+	"flowforge/pkg/cache"
+	"flowforge/pkg/changefeed"
+	"flowforge/pkg/cloudcred"
+	"flowforge/pkg/compliance"
 	"flowforge/pkg/config"
 	"flowforge/pkg/database"
 	"flowforge/pkg/deploy"
+	"flowforge/pkg/events"
+	"flowforge/pkg/export"
 	"flowforge/pkg/git"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/ipallow"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/logarchive"
+	"flowforge/pkg/logsearch"
+	"flowforge/pkg/notify"
 	"flowforge/pkg/pipeline"
+	"flowforge/pkg/retention"
+	"flowforge/pkg/retry"
 	"flowforge/pkg/scheduler"
 	"flowforge/pkg/scripts"
+	"flowforge/pkg/service"
 	"flowforge/pkg/ssh"
+	"flowforge/pkg/support"
 	
 	"github.com/gin-gonic/gin"
 )
@@ -23,6 +45,12 @@ var (
 	configPath = flag.String("config", "config.yaml", "配置文件路径")
 	version    = flag.Bool("version", false, "显示版本信息")
 	help       = flag.Bool("help", false, "显示帮助信息")
+	pidFile    = flag.String("pid-file", "", "PID文件路径")
+	runMode    = flag.String("mode", "server", "运行模式：server 启动API服务器，worker 只作为执行器领取并执行排队的运行")
+	bundlePath = flag.String("support-bundle", "", "离线生成诊断包到指定路径后退出（API不可用时使用）")
+	convertTZ  = flag.String("convert-local-times", "", "将旧版本按该时区（原服务器时区，如 Asia/Shanghai）写入 MySQL 的时间转换为UTC后退出，升级后首次启动前执行一次")
+	migrateWS  = flag.Bool("migrate-workspaces", false, "开启多租户隔离后，将共享的工作区与依赖缓存移动到各项目目录并创建项目系统用户后退出")
+	validate   = flag.Bool("validate", false, "校验配置文件与数据库连接后退出，并报告是否有实例正持有数据库迁移锁")
 )
 
 const (
@@ -46,36 +74,132 @@ func main() {
 		return
 	}
 
-	// 初始化应用
-	if err := initApp(); err != nil {
+	// 离线生成诊断包
+	if *bundlePath != "" {
+		if err := generateSupportBundle(*bundlePath); err != nil {
+			log.Fatalf("生成诊断包失败: %v", err)
+		}
+		log.Printf("诊断包已生成: %s", *bundlePath)
+		return
+	}
+
+	// 校验配置与数据库
+	if *validate {
+		if err := validateSetup(); err != nil {
+			log.Fatalf("校验失败: %v", err)
+		}
+		return
+	}
+
+	// 离线转换旧数据中的本地时间
+	if *convertTZ != "" {
+		if err := convertLocalTimes(*convertTZ); err != nil {
+			log.Fatalf("转换本地时间失败: %v", err)
+		}
+		return
+	}
+
+	// 离线迁移工作区到项目目录
+	if *migrateWS {
+		if err := migrateWorkspaces(); err != nil {
+			log.Fatalf("迁移工作区失败: %v", err)
+		}
+		return
+	}
+
+	// 初始化应用（作为Windows服务运行时，服务停止请求走同样的优雅关闭流程）
+	run := initApp
+	switch *runMode {
+	case "server":
+	case "worker":
+		run = initWorker
+	default:
+		log.Fatalf("不支持的运行模式: %s", *runMode)
+	}
+	if err := service.Run(AppName, run); err != nil {
 		log.Fatalf("应用初始化失败: %v", err)
 	}
 
 	log.Printf("%s v%s 启动成功", AppName, AppVersion)
 }
 
-// initApp 初始化应用
-func initApp() error {
+// initApp 初始化应用，stop 关闭时优雅关闭服务器
+func initApp(stop <-chan struct{}) error {
 	// 1. 加载配置
 	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
 		return err
 	}
 
+	// 写入PID文件
+	if *pidFile != "" {
+		if err := service.WritePIDFile(*pidFile); err != nil {
+			return err
+		}
+		defer service.RemovePIDFile(*pidFile)
+	}
+
+	// 消息目录不完整时提示，缺失的消息回退到默认语言
+	for locale, keys := range i18n.MissingKeys() {
+		log.Printf("语言 %s 缺少 %d 条消息: %s", locale, len(keys), strings.Join(keys, ", "))
+	}
+
+	// 初始化出站HTTP客户端（代理、CA证书、超时）与基础设施请求的重试、熔断
+	if err := httpclient.Init(&cfg.Network); err != nil {
+		return err
+	}
+	retry.Init(&cfg.Network.Retry)
+	cache.Init(&cfg.Cache)
+
+	// 注册部署步骤扮演云平台角色的临时凭证实现
+	cloudcred.Init(&cfg.Cloud)
+
+	// 按配置开启多租户隔离，运行环境不满足时告警并关闭
+	isolation.Init(&cfg.Isolation)
+
+	// Webhook来源限制中 @github、@gitlab 地址段的刷新地址
+	ipallow.Init(&cfg.Security.ProviderRanges)
+
+	// 列表导出 CSV、NDJSON 的最大行数
+	export.Init(&cfg.Server.Export)
+
+	// 流水线配置检查中升级为阻止保存的规则
+	if err := pipeline.InitLint(cfg.Deploy.LintBlockingRules); err != nil {
+		return err
+	}
+
 	// 2. 初始化数据库
 	if err := database.InitDatabase(cfg); err != nil {
 		return err
 	}
 
-	// 3. 自动迁移数据库表结构
-	if err := database.AutoMigrate(); err != nil {
+	// 项目、流水线、运行、部署与通知的变更写入变更日志，供 /changes 接口订阅
+	if err := changefeed.Register(database.DB, &cfg.Server.Changes); err != nil {
+		return err
+	}
+
+	// 3. 自动迁移数据库表结构并初始化种子数据，多个实例同时启动时由迁移锁保证依次执行
+	if err := database.WithMigrationLock(func() error {
+		if err := database.AutoMigrate(); err != nil {
+			return err
+		}
+		if err := database.SeedData(); err != nil {
+			return err
+		}
+		// 运行日志全文检索的索引与表结构一起创建
+		return logsearch.Init(&cfg.Deploy.LogSearch)
+	}); err != nil {
 		return err
 	}
+	defer logsearch.Stop()
 
-	// 4. 初始化种子数据
-	if err := database.SeedData(); err != nil {
+	// 初始化系统事件投递，未配置接收端时不记录事件
+	eventDispatcher, err := events.Init(&cfg.Events)
+	if err != nil {
 		return err
 	}
+	eventDispatcher.Start()
+	defer eventDispatcher.Stop()
 
 	// 5. 创建必要的目录
 	if err := createDirectories(cfg); err != nil {
@@ -87,7 +211,36 @@ func initApp() error {
 	gitManager := git.NewManager(cfg)
 	sshManager := ssh.NewManager(cfg)
 	deployManager := deploy.NewDeployManager(cfg)
+	// 外部步骤类型需在引擎执行流水线前注册
+	if err := pipeline.LoadStepPlugins(cfg.Deploy.StepPluginDir); err != nil {
+		return err
+	}
 	pipelineEngine := pipeline.NewEngine(cfg, scriptManager, gitManager)
+	notifyManager := notify.NewManager(cfg)
+	pipelineEngine.SetNotifier(notifyManager)
+	driftChecker := deploy.NewDriftChecker(cfg, notifyManager)
+	pipelineEngine.SetDriftChecker(driftChecker)
+	preflighter := deploy.NewPreflighter(cfg)
+	pipelineEngine.SetPreflighter(preflighter)
+	// 重启前等待外部回调的运行继续等待，需在失联运行检查启动前恢复
+	pipelineEngine.ResumeExternalWaits()
+	freezeManager := deploy.NewFreezeManager(cfg, notifyManager)
+	artifactStore, err := artifact.NewStore(cfg)
+	if err != nil {
+		return err
+	}
+	pipelineEngine.SetArtifactStore(artifactStore)
+	logArchive, err := logarchive.NewStore(cfg, notifyManager)
+	if err != nil {
+		return err
+	}
+	pipelineEngine.SetLogArchive(logArchive)
+	backupManager, err := backup.NewManager(cfg, notifyManager)
+	if err != nil {
+		return err
+	}
+	failureMonitor := anomaly.NewMonitor(cfg, notifyManager)
+	defer failureMonitor.Stop()
 
 	// 7. 启动部署管理器
 	if err := deployManager.Start(); err != nil {
@@ -99,9 +252,56 @@ func initApp() error {
 	if err := scheduler.Start(); err != nil {
 		return err
 	}
+	scheduler.SetArtifactStore(artifactStore)
+	scheduler.SetRetentionPolicy(retention.FromConfig(cfg))
+	scheduler.SetLogArchive(logArchive)
+	if err := scheduler.AddCleanupJob(); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("notify_digest", cfg.Notify.DigestCron, notifyManager.SendDigests); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("drift_check", cfg.Deploy.DriftCheckCron, driftChecker.CheckAll); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("engine_watchdog", "0 * * * * *", pipelineEngine.CollectLeakedJobs); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("stale_run_watchdog", "30 * * * * *", pipelineEngine.FailStaleRuns); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("worker_fallback", "*/10 * * * * *", pipelineEngine.ClaimOrphanedRuns); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("freeze_expiry", "15 * * * * *", freezeManager.LiftExpired); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("event_outbox_prune", "0 30 3 * * *", eventDispatcher.Prune); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("database_backup", cfg.Backup.Cron, backupManager.RunScheduled); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("failure_spike_check", "45 * * * * *", failureMonitor.Check); err != nil {
+		return err
+	}
+	if err := scheduler.AddJob("provider_ranges_refresh", cfg.Security.ProviderRanges.Cron, ipallow.RefreshAll); err != nil {
+		return err
+	}
+	// 启动时刷新一次，失败时继续使用上次保存的地址段
+	go ipallow.RefreshAll()
+	scheduler.SetPrewarmer(pipelineEngine)
+	if err := scheduler.AddPrewarmJob(); err != nil {
+		return err
+	}
 
 	// 9. 创建并启动API服务器
-	server := api.NewServer(cfg, pipelineEngine, scriptManager, gitManager, sshManager, deployManager)
+	bundleGenerator := support.NewGenerator(cfg, support.Sources{
+		Engine:    pipelineEngine,
+		Scheduler: scheduler,
+		Deploy:    deployManager,
+	}, support.NewBuildInfo(AppName, AppVersion))
+	server := api.NewServer(cfg, pipelineEngine, scriptManager, gitManager, sshManager, deployManager, artifactStore, bundleGenerator, driftChecker, freezeManager, preflighter, compliance.NewManager(cfg), eventDispatcher, backupManager)
 	
 	// 设置静态文件服务
 	server.Static("/static", "./web/dist")
@@ -113,7 +313,95 @@ func initApp() error {
 	})
 
 	// 启动服务器（带优雅关闭）
-	return server.Run()
+	return server.RunUntil(stop)
+}
+
+// generateSupportBundle 离线生成诊断包：数据库可连接时包含数据库统计，运行时组件状态不可用
+func generateSupportBundle(path string) error {
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(cfg); err != nil {
+		log.Printf("连接数据库失败，诊断包将不包含数据库信息: %v", err)
+	}
+
+	generator := support.NewGenerator(cfg, support.Sources{}, support.NewBuildInfo(AppName, AppVersion))
+	return generator.GenerateFile(path, func(step string, progress int) {
+		log.Printf("[%3d%%] %s", progress, step)
+	})
+}
+
+// validateSetup 校验配置文件并连接数据库，报告迁移锁的持有者，便于多实例部署时确认启动是否在等待迁移
+func validateSetup() error {
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("配置文件有效: %s", *configPath)
+
+	if err := database.InitDatabase(cfg); err != nil {
+		return err
+	}
+	defer database.CloseDatabase()
+
+	holder, err := database.MigrationLockHolder()
+	if err != nil {
+		return fmt.Errorf("查询数据库迁移锁失败: %w", err)
+	}
+	if holder == "" {
+		log.Println("数据库迁移锁空闲")
+	} else {
+		log.Printf("数据库迁移锁由 %s 持有，正在执行迁移", holder)
+	}
+	return nil
+}
+
+// convertLocalTimes 将旧版本按服务器本地时间写入的时间转换为UTC
+func convertLocalTimes(zone string) error {
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if err := database.InitDatabase(cfg); err != nil {
+		return err
+	}
+
+	total, err := database.ConvertLocalTimes(zone)
+	if err != nil {
+		return err
+	}
+	log.Printf("已将 %d 行数据中的时间从 %s 转换为UTC", total, zone)
+	return nil
+}
+
+// migrateWorkspaces 将开启隔离前的工作区与依赖缓存移动到各项目目录
+func migrateWorkspaces() error {
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	isolation.Init(&cfg.Isolation)
+	if err := database.InitDatabase(cfg); err != nil {
+		return err
+	}
+	// 项目上记录系统用户的字段可能尚未创建
+	if err := database.WithMigrationLock(database.AutoMigrate); err != nil {
+		return err
+	}
+
+	result, err := isolation.Migrate(database.GetDB(), cfg.App.DataPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("已为 %d 个项目准备目录，移动 %d 个工作区与缓存目录", result.Projects, result.Moved)
+	for _, dir := range result.Skipped {
+		log.Printf("目标已存在，未移动: %s", dir)
+	}
+	return nil
 }
 
 // createDirectories 创建必要的目录
@@ -160,6 +448,12 @@ func showHelp() {
 	log.Println()
 	log.Println("Examples:")
 	log.Printf("  %s -config=config.yaml", os.Args[0])
+	log.Printf("  %s -config=config.yaml -pid-file=/run/flowforge.pid", os.Args[0])
+	log.Printf("  %s -config=config.yaml -mode=worker", os.Args[0])
+	log.Printf("  %s -config=config.yaml -support-bundle=support.zip", os.Args[0])
+	log.Printf("  %s -config=config.yaml -convert-local-times=Asia/Shanghai", os.Args[0])
+	log.Printf("  %s -config=config.yaml -migrate-workspaces", os.Args[0])
+	log.Printf("  %s -config=config.yaml -validate", os.Args[0])
 	log.Printf("  %s -version", os.Args[0])
 	log.Printf("  %s -help", os.Args[0])
 }
\ No newline at end of file
@@ -0,0 +1,6 @@
+//go:build example_steps
+
+package main
+
+// 使用 -tags example_steps 构建时注册示例步骤类型 cmdb_lookup
+import _ "flowforge/examples/cmdbstep"
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/cache"
+	"flowforge/pkg/changefeed"
+	"flowforge/pkg/cloudcred"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/events"
+	"flowforge/pkg/git"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/logarchive"
+	"flowforge/pkg/logsearch"
+	"flowforge/pkg/notify"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/retry"
+	"flowforge/pkg/scripts"
+	"flowforge/pkg/service"
+)
+
+// initWorker 以执行器模式运行：只连接数据库并启动流水线引擎，从数据库领取交给执行器的运行，不启动API服务器、调度器与部署管理器。
+// 数据库表结构由API实例迁移；制品与日志归档需使用各实例共享的存储，API实例才能读取执行器产出的制品
+func initWorker(stop <-chan struct{}) error {
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	// 写入PID文件
+	if *pidFile != "" {
+		if err := service.WritePIDFile(*pidFile); err != nil {
+			return err
+		}
+		defer service.RemovePIDFile(*pidFile)
+	}
+
+	if err := httpclient.Init(&cfg.Network); err != nil {
+		return err
+	}
+	retry.Init(&cfg.Network.Retry)
+	cache.Init(&cfg.Cache)
+	cloudcred.Init(&cfg.Cloud)
+	isolation.Init(&cfg.Isolation)
+
+	if err := database.InitDatabase(cfg); err != nil {
+		return err
+	}
+	if err := changefeed.Register(database.DB, &cfg.Server.Changes); err != nil {
+		return err
+	}
+
+	// 本实例执行的运行的日志同样建立全文索引
+	if err := logsearch.Init(&cfg.Deploy.LogSearch); err != nil {
+		return err
+	}
+	defer logsearch.Stop()
+
+	// 运行事件写入发件箱，由本实例投递
+	eventDispatcher, err := events.Init(&cfg.Events)
+	if err != nil {
+		return err
+	}
+	eventDispatcher.Start()
+	defer eventDispatcher.Stop()
+
+	if err := createDirectories(cfg); err != nil {
+		return err
+	}
+
+	// 流水线引擎及其执行步骤需要的组件
+	if err := pipeline.LoadStepPlugins(cfg.Deploy.StepPluginDir); err != nil {
+		return err
+	}
+	pipelineEngine := pipeline.NewEngine(cfg, scripts.NewManager(cfg), git.NewManager(cfg))
+	notifyManager := notify.NewManager(cfg)
+	pipelineEngine.SetNotifier(notifyManager)
+	pipelineEngine.SetDriftChecker(deploy.NewDriftChecker(cfg, notifyManager))
+	pipelineEngine.SetPreflighter(deploy.NewPreflighter(cfg))
+	artifactStore, err := artifact.NewStore(cfg)
+	if err != nil {
+		return err
+	}
+	pipelineEngine.SetArtifactStore(artifactStore)
+	logArchive, err := logarchive.NewStore(cfg, notifyManager)
+	if err != nil {
+		return err
+	}
+	pipelineEngine.SetLogArchive(logArchive)
+	defer pipelineEngine.Shutdown()
+
+	// 收到中断信号或服务停止请求后排空：执行完已领取的运行再退出
+	drain := make(chan struct{})
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		select {
+		case <-quit:
+			log.Println("收到关闭信号，执行器开始排空...")
+		case <-stop:
+			log.Println("收到服务停止请求，执行器开始排空...")
+		}
+		close(drain)
+	}()
+
+	return pipelineEngine.RunWorker(AppVersion, drain)
+}
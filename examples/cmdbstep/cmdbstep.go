@@ -0,0 +1,130 @@
+// Package cmdbstep 自定义步骤类型示例：从 CMDB 查询配置项，将指定字段作为步骤输出传给后续步骤。
+//
+// 通过构建标签编译进服务端：
+//
+//	go build -tags example_steps ./cmd/server
+//
+// 或构建为插件，放入 deploy.step_plugin_dir 目录：
+//
+//	go build -buildmode=plugin -o cmdb_lookup.so ./examples/cmdbstep/plugin
+package cmdbstep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/pipeline"
+)
+
+// lookupTimeout 单次 CMDB 查询的超时时间
+const lookupTimeout = 30 * time.Second
+
+// Executor cmdb_lookup 步骤：按 ci 查询配置项，fields 中的字段以 CMDB_<FIELD> 为键输出
+//
+//	{"name": "查询目标主机", "type": "cmdb_lookup", "config": {
+//	    "url": "https://cmdb.example.com", "ci": "order-service",
+//	    "fields": ["host", "owner"], "token_env": "CMDB_TOKEN"}}
+type Executor struct{}
+
+func init() {
+	pipeline.RegisterStepType(Executor{})
+}
+
+// Name 步骤类型名称
+func (Executor) Name() string {
+	return "cmdb_lookup"
+}
+
+// Schema 步骤配置结构
+func (Executor) Schema() pipeline.StepSchema {
+	return pipeline.StepSchema{
+		Fields: map[string]pipeline.SchemaField{
+			"url":       {Type: pipeline.FieldString, Required: true, Description: "CMDB 地址"},
+			"ci":        {Type: pipeline.FieldString, Required: true, Description: "配置项名称"},
+			"fields":    {Type: pipeline.FieldArray, Description: "输出的字段，为空时输出全部字符串字段"},
+			"token_env": {Type: pipeline.FieldString, Description: "保存访问令牌的服务端环境变量名"},
+		},
+	}
+}
+
+// ValidateConfig 校验 CMDB 地址与输出字段
+func (Executor) ValidateConfig(config map[string]interface{}) []string {
+	var problems []string
+	if raw, _ := config["url"].(string); raw != "" {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			problems = append(problems, fmt.Sprintf("CMDB 地址无效: %s", raw))
+		}
+	}
+	fields, _ := config["fields"].([]interface{})
+	for _, field := range fields {
+		if name, ok := field.(string); !ok || name == "" {
+			problems = append(problems, "fields 只能包含非空字符串")
+			break
+		}
+	}
+	return problems
+}
+
+// Execute 查询配置项并输出字段
+func (Executor) Execute(ctx context.Context, jobCtx *pipeline.JobContext, config map[string]interface{}) (map[string]string, error) {
+	base, _ := config["url"].(string)
+	ci, _ := config["ci"].(string)
+	endpoint := strings.TrimRight(base, "/") + "/api/ci/" + url.PathEscape(ci)
+
+	// 出站请求按项目的出站例外检查
+	ctx = httpclient.WithProject(ctx, jobCtx.Project.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建 CMDB 请求失败: %w", err)
+	}
+	if tokenEnv, _ := config["token_env"].(string); tokenEnv != "" {
+		req.Header.Set("Authorization", "Bearer "+os.Getenv(tokenEnv))
+	}
+
+	jobCtx.Log("查询 CMDB 配置项 %s", ci)
+	resp, err := httpclient.New(lookupTimeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("查询 CMDB 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询 CMDB 失败: HTTP %d", resp.StatusCode)
+	}
+
+	var item map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+		return nil, fmt.Errorf("解析 CMDB 响应失败: %w", err)
+	}
+
+	outputs := make(map[string]string)
+	fields, _ := config["fields"].([]interface{})
+	if len(fields) == 0 {
+		for name, value := range item {
+			if text, ok := value.(string); ok {
+				outputs[outputKey(name)] = text
+			}
+		}
+		return outputs, nil
+	}
+	for _, field := range fields {
+		name, _ := field.(string)
+		value, ok := item[name]
+		if !ok {
+			return nil, fmt.Errorf("CMDB 配置项 %s 没有字段 %s", ci, name)
+		}
+		outputs[outputKey(name)] = fmt.Sprint(value)
+	}
+	return outputs, nil
+}
+
+// outputKey 字段对应的输出键
+func outputKey(field string) string {
+	return "CMDB_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(field))
+}
@@ -0,0 +1,6 @@
+// 将 cmdb_lookup 步骤类型构建为 Go 插件，服务端启动时从 deploy.step_plugin_dir 加载
+package main
+
+import _ "flowforge/examples/cmdbstep"
+
+func main() {}
@@ -0,0 +1,50 @@
+package authctx
+
+import (
+	"errors"
+
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextKey 当前用户在gin上下文中的存储键，仅本包读写
+const contextKey = "flowforge.authctx.user"
+
+// ErrUnauthenticated 上下文中没有已认证的用户
+var ErrUnauthenticated = errors.New("未认证")
+
+// User 已认证用户的身份信息
+type User struct {
+	ID       uint
+	Username string
+	Role     string
+
+	// 通过API令牌认证时为令牌ID，否则为0
+	APITokenID uint
+}
+
+// IsAdmin 是否为管理员
+func (u *User) IsAdmin() bool {
+	return u.Role == models.RoleAdmin
+}
+
+// SetCurrentUser 将已认证用户写入上下文，由认证中间件调用
+func SetCurrentUser(c *gin.Context, user User) {
+	c.Set(contextKey, &user)
+}
+
+// CurrentUser 获取当前已认证用户，未认证时返回 ErrUnauthenticated
+func CurrentUser(c *gin.Context) (*User, error) {
+	value, exists := c.Get(contextKey)
+	if !exists {
+		return nil, ErrUnauthenticated
+	}
+
+	user, ok := value.(*User)
+	if !ok || user == nil || user.ID == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	return user, nil
+}
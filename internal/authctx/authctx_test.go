@@ -0,0 +1,59 @@
+package authctx
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCurrentUser(t *testing.T) {
+	var nilUser *User
+	tests := []struct {
+		name  string
+		value interface{} // 写入上下文的值，nil 表示不写入
+		want  uint
+	}{
+		{"未认证", nil, 0},
+		{"旧的用户ID键值", uint(7), 0},
+		{"值类型的用户", User{ID: 7}, 0},
+		{"空指针", nilUser, 0},
+		{"用户ID为0", &User{Username: "ghost"}, 0},
+		{"已认证", &User{ID: 7, Username: "alice"}, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.value != nil {
+				c.Set(contextKey, tt.value)
+			}
+			user, err := CurrentUser(c)
+			if tt.want == 0 {
+				if !errors.Is(err, ErrUnauthenticated) || user != nil {
+					t.Fatalf("应返回 ErrUnauthenticated，实际为 %v, %v", user, err)
+				}
+				return
+			}
+			if err != nil || user.ID != tt.want {
+				t.Fatalf("应返回用户 %d，实际为 %v, %v", tt.want, user, err)
+			}
+		})
+	}
+}
+
+// TestSetCurrentUser 写入的是副本，调用方之后修改不影响上下文中的身份
+func TestSetCurrentUser(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	user := User{ID: 3, Role: "user"}
+	SetCurrentUser(c, user)
+	user.Role = "admin"
+
+	current, err := CurrentUser(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.IsAdmin() {
+		t.Error("上下文中的身份不应随调用方的变量改变")
+	}
+}
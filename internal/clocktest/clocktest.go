@@ -0,0 +1,170 @@
+// Package clocktest 测试用的可控时钟：时间只在调用 Advance 或 Set 时前进，
+// 到期的 After 与 Ticker 按到期时间的顺序触发，测试定时任务、令牌过期与冻结到期时无需等待
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"flowforge/pkg/clock"
+)
+
+// Fake 可控时钟，实现 clock.Clock，可并发使用
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*timer
+	changed chan struct{} // 等待中的定时器数量变化时关闭，供 BlockUntil 使用
+}
+
+// timer 一个等待中的 After 或 Ticker；period 为 0 表示 After，触发一次后移除
+type timer struct {
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// New 创建当前时间为 now 的可控时钟
+func New(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+var _ clock.Clock = (*Fake)(nil)
+
+// Now 当前时间
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After 时钟推进 d 后收到当时时间的通道，d 不大于 0 时立即收到
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+	f.add(&timer{at: f.now.Add(d), c: c})
+	return c
+}
+
+// NewTicker 时钟每推进 d 触发一次的定时器，d 需大于 0
+func (f *Fake) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("clocktest: NewTicker 的间隔需大于 0")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &timer{at: f.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	f.add(t)
+	return &fakeTicker{clock: f, timer: t}
+}
+
+// Advance 时钟推进 d，期间到期的定时器按到期时间依次触发，触发时 Now 为该定时器的到期时间
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set 将时钟推进到 t，早于当前时间时不后退，只触发已到期的定时器
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.timers) > 0 && !f.timers[0].at.After(t) {
+		next := f.timers[0]
+		if next.at.After(f.now) {
+			f.now = next.at
+		}
+		// 与 time.Ticker 相同，接收方未读取上次触发时丢弃本次
+		select {
+		case next.c <- f.now:
+		default:
+		}
+		f.timers = f.timers[1:]
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+			f.insert(next)
+		} else {
+			f.notify()
+		}
+	}
+	if t.After(f.now) {
+		f.now = t
+	}
+}
+
+// Waiters 等待中的 After 与 Ticker 数量
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// BlockUntil 等待至少有 n 个等待中的 After 或 Ticker，用于在推进时钟前确认被测的后台循环已开始等待
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		if len(f.timers) >= n {
+			f.mu.Unlock()
+			return
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		<-changed
+	}
+}
+
+// add 加入等待中的定时器，调用方持有锁
+func (f *Fake) add(t *timer) {
+	f.insert(t)
+	f.notify()
+}
+
+// insert 按到期时间插入，到期时间相同时先加入的先触发，调用方持有锁
+func (f *Fake) insert(t *timer) {
+	i := sort.Search(len(f.timers), func(i int) bool { return f.timers[i].at.After(t.at) })
+	f.timers = append(f.timers, nil)
+	copy(f.timers[i+1:], f.timers[i:])
+	f.timers[i] = t
+}
+
+// remove 移除等待中的定时器，调用方持有锁
+func (f *Fake) remove(t *timer) {
+	for i, candidate := range f.timers {
+		if candidate == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			f.notify()
+			return
+		}
+	}
+}
+
+// notify 唤醒 BlockUntil，调用方持有锁
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// fakeTicker 可控时钟的周期定时器
+type fakeTicker struct {
+	clock *Fake
+	timer *timer
+}
+
+// C 触发通道
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.timer.c
+}
+
+// Stop 停止触发
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.remove(t.timer)
+}
@@ -0,0 +1,119 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// received 非阻塞读取通道，未触发时返回 false
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestAfter(t *testing.T) {
+	f := New(epoch)
+	c := f.After(time.Minute)
+
+	f.Advance(59 * time.Second)
+	if _, ok := received(c); ok {
+		t.Fatal("未到期的 After 不应触发")
+	}
+	f.Advance(2 * time.Second)
+	got, ok := received(c)
+	if !ok {
+		t.Fatal("到期的 After 应触发")
+	}
+	// 触发时的时间为到期时间，Advance 结束后为推进到的时间
+	if want := epoch.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("触发时间 %v，应为 %v", got, want)
+	}
+	if want := epoch.Add(61 * time.Second); !f.Now().Equal(want) {
+		t.Errorf("Now %v，应为 %v", f.Now(), want)
+	}
+	if f.Waiters() != 0 {
+		t.Errorf("触发后的 After 应移除，剩余 %d 个", f.Waiters())
+	}
+
+	if _, ok := received(f.After(0)); !ok {
+		t.Error("d 不大于 0 的 After 应立即触发")
+	}
+}
+
+func TestTickerDropsMissedTicks(t *testing.T) {
+	f := New(epoch)
+	ticker := f.NewTicker(10 * time.Second)
+
+	// 一次推进跨过多个周期，未读取的触发被丢弃，只收到第一次
+	f.Advance(35 * time.Second)
+	got, ok := received(ticker.C())
+	if !ok || !got.Equal(epoch.Add(10*time.Second)) {
+		t.Fatalf("应收到第一次触发，实际为 %v %v", got, ok)
+	}
+	if _, ok := received(ticker.C()); ok {
+		t.Fatal("未读取的多余触发应丢弃")
+	}
+
+	f.Advance(5 * time.Second)
+	if got, ok := received(ticker.C()); !ok || !got.Equal(epoch.Add(40*time.Second)) {
+		t.Fatalf("下一次触发应在 40s，实际为 %v %v", got, ok)
+	}
+
+	ticker.Stop()
+	f.Advance(time.Minute)
+	if _, ok := received(ticker.C()); ok {
+		t.Fatal("停止后不应再触发")
+	}
+	if f.Waiters() != 0 {
+		t.Errorf("停止后应移除，剩余 %d 个", f.Waiters())
+	}
+}
+
+func TestSetFiresInDeadlineOrder(t *testing.T) {
+	f := New(epoch)
+	late := f.After(3 * time.Hour)
+	early := f.After(time.Hour)
+	tie := f.After(time.Hour)
+
+	f.Set(epoch.Add(4 * time.Hour))
+	for name, c := range map[string]<-chan time.Time{"early": early, "tie": tie} {
+		if got, ok := received(c); !ok || !got.Equal(epoch.Add(time.Hour)) {
+			t.Errorf("%s 应在 1h 触发，实际为 %v %v", name, got, ok)
+		}
+	}
+	if got, ok := received(late); !ok || !got.Equal(epoch.Add(3*time.Hour)) {
+		t.Errorf("late 应在 3h 触发，实际为 %v %v", got, ok)
+	}
+
+	// 不后退
+	f.Set(epoch)
+	if !f.Now().Equal(epoch.Add(4 * time.Hour)) {
+		t.Errorf("Set 到更早的时间不应后退，Now 为 %v", f.Now())
+	}
+}
+
+func TestBlockUntil(t *testing.T) {
+	f := New(epoch)
+	done := make(chan time.Time)
+	go func() {
+		done <- <-f.After(time.Hour)
+	}()
+
+	// 等后台协程开始等待后再推进，否则推进可能发生在 After 之前
+	f.BlockUntil(1)
+	f.Advance(time.Hour)
+	select {
+	case got := <-done:
+		if !got.Equal(epoch.Add(time.Hour)) {
+			t.Errorf("触发时间 %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("推进后后台等待未被唤醒")
+	}
+}
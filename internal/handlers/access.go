@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/cache"
+	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 流水线与运行记录上的操作，不同操作要求的项目关系不同
+const (
+	actionView    = "view"    // 查看流水线、运行记录与日志：项目所有者或任意角色的成员
+	actionTrigger = "trigger" // 运行、重跑与取消：项目所有者或 maintainer 成员
+	actionEdit    = "edit"    // 修改与删除流水线：只有项目所有者
+)
+
+// projectAccess 非管理员执行 action 需要满足的项目条件，查询需已关联 projects 表。
+// 处理器的权限查询与权限排查接口使用同一条件，排查结果不会与实际校验不一致
+func projectAccess(userID uint, action string) clause.Expr {
+	switch action {
+	case actionEdit:
+		return gorm.Expr("projects.user_id = ?", userID)
+	case actionTrigger:
+		return gorm.Expr("(projects.user_id = ? OR projects.id IN (?))", userID, memberProjects(userID, models.ProjectRoleMaintainer))
+	}
+	return gorm.Expr("(projects.user_id = ? OR projects.id IN (?))", userID, memberProjects(userID, ""))
+}
+
+// projectPermitted 当前用户能否对项目执行 action，条件与 projectAccess 相同，按缓存的项目成员关系判断
+func projectPermitted(current *authctx.User, projectID uint, action string) bool {
+	if current.IsAdmin() {
+		return true
+	}
+	membership, err := membershipCache.Get(context.Background(), projectID)
+	if err != nil {
+		return false
+	}
+	return membership.permits(current.ID, action)
+}
+
+// projectMembership 项目的所有者与各成员的角色
+type projectMembership struct {
+	ownerID uint
+	roles   map[uint]string
+}
+
+// membershipCache 按项目缓存所有者与成员；成员变更与项目删除时失效，遗漏失效时最迟在缓存时间后生效
+var membershipCache = cache.New("project_membership", func(ctx context.Context, projectID uint) (*projectMembership, error) {
+	var project models.Project
+	if err := database.DB.WithContext(ctx).Select("id", "user_id").First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+	var members []models.ProjectMember
+	if err := database.DB.WithContext(ctx).Where("project_id = ?", projectID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	membership := &projectMembership{ownerID: project.UserID, roles: make(map[uint]string, len(members))}
+	for _, member := range members {
+		membership.roles[member.UserID] = member.Role
+	}
+	return membership, nil
+})
+
+// permits 用户能否执行 action：修改只有所有者，运行需要所有者或 maintainer 成员，查看需要所有者或任意角色的成员
+func (m *projectMembership) permits(userID uint, action string) bool {
+	if userID == m.ownerID {
+		return true
+	}
+	role, member := m.roles[userID]
+	switch action {
+	case actionEdit:
+		return false
+	case actionTrigger:
+		return role == models.ProjectRoleMaintainer
+	}
+	return member
+}
+
+// accessCheck 一项权限或前置条件检查的结果
+type accessCheck struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Blocking bool   `json:"blocking"` // 未通过时是否拒绝操作；不拒绝的检查只说明操作的实际效果，如排队或部署步骤被冻结
+	Detail   string `json:"detail"`
+	status   int    // 未通过时处理器返回的状态码
+}
+
+// firstFailure 第一项未通过且会拒绝操作的检查，全部通过时返回 nil
+func firstFailure(checks []accessCheck) *accessCheck {
+	for i := range checks {
+		if checks[i].Blocking && !checks[i].Passed {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+// archivedCheck 归档项目拒绝运行与修改流水线
+func archivedCheck(project *models.Project) accessCheck {
+	check := accessCheck{Name: "archived_project", Passed: !project.IsArchived(), Blocking: true, status: http.StatusConflict, Detail: "项目未归档"}
+	if !check.Passed {
+		check.Detail = models.ErrProjectArchived.Error()
+	}
+	return check
+}
+
+// manualTriggerChecks 手动运行流水线的前置条件，debugEnv 为是否开启环境变量调试
+func manualTriggerChecks(project *models.Project, current *authctx.User, debugEnv bool) []accessCheck {
+	checks := []accessCheck{archivedCheck(project)}
+
+	// 源码包项目通过 /projects/:id/runs/upload-source 上传源码包触发运行
+	source := accessCheck{Name: "source_type", Passed: !project.UsesArchiveSource(), Blocking: true, status: http.StatusBadRequest, Detail: "项目使用 git 仓库"}
+	if !source.Passed {
+		source.Detail = "源码包项目需要上传源码包触发运行"
+	} else if project.UsesRegistrySource() {
+		source.Detail = "registry 项目，运行时指定部署的镜像或 tarball 制品"
+	}
+	checks = append(checks, source)
+
+	// 调试环境变量会记录非密钥变量的值，只有项目所有者可以开启
+	if debugEnv {
+		debug := accessCheck{Name: "debug_env", Passed: project.UserID == current.ID, Blocking: true, status: http.StatusForbidden, Detail: "你是项目所有者"}
+		if !debug.Passed {
+			debug.Detail = "只有项目所有者可以开启环境变量调试"
+		}
+		checks = append(checks, debug)
+	}
+	return checks
+}
+
+// rerunChecks 仅重跑失败步骤的前置条件
+func (h *PipelineHandler) rerunChecks(project *models.Project, run *models.PipelineRun) []accessCheck {
+	rerun := accessCheck{Name: "rerun_failed", Passed: h.engine.CanRerunFailed(run), Blocking: true, status: http.StatusConflict, Detail: "原运行失败且工作区仍保留"}
+	if !rerun.Passed {
+		rerun.Detail = "原运行未失败或工作区已过期，无法仅重跑失败步骤"
+	}
+	return []accessCheck{archivedCheck(project), rerun}
+}
+
+// runEffectChecks 不拒绝运行、但影响运行实际效果的状态：部署冻结与项目并发上限
+func runEffectChecks(project *models.Project) []accessCheck {
+	var checks []accessCheck
+
+	freeze := accessCheck{Name: "deploy_freeze", Passed: true, Detail: "没有对项目生效的部署冻结"}
+	if freezes, err := deploy.ProjectFreezes(project.ID, ""); err != nil {
+		freeze.Passed = false
+		freeze.Detail = err.Error() + "，部署步骤将被拒绝"
+	} else if len(freezes) > 0 {
+		freeze.Passed = false
+		freeze.Detail = fmt.Sprintf("部署冻结生效中（%s），部署步骤将被拒绝", freezes[0].Reason)
+	}
+	checks = append(checks, freeze)
+
+	if project.MaxConcurrentRuns > 0 {
+		var running int64
+		database.DB.Model(&models.PipelineRun{}).
+			Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Where("pipelines.project_id = ? AND pipeline_runs.status = ?", project.ID, models.RunStatusRunning).
+			Count(&running)
+		concurrency := accessCheck{Name: "concurrency", Passed: running < int64(project.MaxConcurrentRuns),
+			Detail: fmt.Sprintf("项目并发运行上限 %d，当前运行中 %d", project.MaxConcurrentRuns, running)}
+		if !concurrency.Passed {
+			concurrency.Detail += "，新运行将排队等待"
+		}
+		checks = append(checks, concurrency)
+	}
+	return checks
+}
+
+// accessRelation 当前用户与项目的关系
+type accessRelation struct {
+	Admin      bool   `json:"admin"`
+	Owner      bool   `json:"owner"`
+	MemberRole string `json:"member_role,omitempty"`
+}
+
+// actionResult 一项操作的排查结果
+type actionResult struct {
+	Allowed bool          `json:"allowed"`
+	Checks  []accessCheck `json:"checks"`
+}
+
+// AccessDebugHandler 权限排查处理器
+type AccessDebugHandler struct {
+	pipelines *PipelineHandler
+}
+
+// NewAccessDebugHandler 创建权限排查处理器
+func NewAccessDebugHandler(engine *pipeline.Engine) *AccessDebugHandler {
+	return &AccessDebugHandler{pipelines: NewPipelineHandler(engine)}
+}
+
+// Explain 说明当前用户对资源各项操作的权限与前置条件。resource 为 pipeline、pipeline_run 或 project，id 为资源ID，
+// debug_env=true 时同时检查能否开启环境变量调试。检查与处理器使用相同的条件；
+// 对没有查看权限的流水线与运行记录只返回资源不存在，与处理器的返回一致
+func (h *AccessDebugHandler) Explain(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id := c.Query("id")
+	if id == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	var project models.Project
+	actions := make(map[string]*actionResult)
+	switch resource := c.Query("resource"); resource {
+	case "pipeline":
+		var pipeline models.Pipeline
+		if err := database.DB.Preload("Project").First(&pipeline, id).Error; err != nil || !projectPermitted(current, pipeline.ProjectID, actionView) {
+			utils.ErrorResponse(c, http.StatusNotFound, "资源不存在")
+			return
+		}
+		project = pipeline.Project
+		actions["view"] = explainAction(current, &project, actionView)
+		actions["run"] = explainAction(current, &project, actionTrigger,
+			append(manualTriggerChecks(&project, current, c.Query("debug_env") == "true"), runEffectChecks(&project)...)...)
+		actions["edit"] = explainAction(current, &project, actionEdit, archivedCheck(&project))
+		actions["delete"] = explainAction(current, &project, actionEdit)
+	case "pipeline_run":
+		var run models.PipelineRun
+		if err := database.DB.Preload("Pipeline.Project").First(&run, id).Error; err != nil || !projectPermitted(current, run.Pipeline.ProjectID, actionView) {
+			utils.ErrorResponse(c, http.StatusNotFound, "资源不存在")
+			return
+		}
+		project = run.Pipeline.Project
+		actions["view"] = explainAction(current, &project, actionView)
+		actions["cancel"] = explainAction(current, &project, actionTrigger)
+		actions["rerun_failed"] = explainAction(current, &project, actionTrigger,
+			append(h.pipelines.rerunChecks(&project, &run), runEffectChecks(&project)...)...)
+		actions["debug_session"] = explainAction(current, &project, actionView, debugSessionChecks(h.pipelines.engine, &project, &run, current)...)
+	case "project":
+		// 项目对所有登录用户可见，修改项目设置需要项目所有者或管理员
+		if err := projectLookup(database.DB, id).First(&project).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "资源不存在")
+			return
+		}
+		actions["view"] = &actionResult{Allowed: true, Checks: []accessCheck{{Name: "permission", Passed: true, Blocking: true, Detail: "项目对所有登录用户可见"}}}
+		actions["manage"] = explainAction(current, &project, actionEdit)
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "resource 只能是 pipeline、pipeline_run 或 project")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"resource":     c.Query("resource"),
+		"id":           id,
+		"project_id":   project.ID,
+		"project_name": project.Name,
+		"relation":     relationTo(current, &project),
+		"actions":      actions,
+	})
+}
+
+// explainAction 按项目条件与其余前置条件说明一项操作，前置条件全部通过且有权限时允许
+func explainAction(current *authctx.User, project *models.Project, action string, checks ...accessCheck) *actionResult {
+	all := append([]accessCheck{permissionCheck(current, project, action)}, checks...)
+	return &actionResult{Allowed: firstFailure(all) == nil, Checks: all}
+}
+
+// permissionCheck 项目条件的检查结果，是否通过由 projectAccess 决定，说明按用户与项目的关系生成
+func permissionCheck(current *authctx.User, project *models.Project, action string) accessCheck {
+	check := accessCheck{Name: "permission", Passed: projectPermitted(current, project.ID, action), Blocking: true, status: http.StatusNotFound}
+	relation := relationTo(current, project)
+
+	switch {
+	case check.Passed && relation.Admin:
+		check.Detail = "你是管理员"
+	case check.Passed && relation.Owner:
+		check.Detail = "你是项目所有者"
+	case check.Passed:
+		check.Detail = fmt.Sprintf("你是项目的 %s 成员", relation.MemberRole)
+	case action == actionEdit:
+		check.Detail = "只有项目所有者或管理员可以执行此操作"
+	case relation.MemberRole != "":
+		check.Detail = fmt.Sprintf("需要项目所有者或 maintainer 成员，你是项目的 %s 成员", relation.MemberRole)
+	default:
+		check.Detail = "需要项目所有者或项目成员，可以申请加入项目"
+	}
+	return check
+}
+
+// relationTo 当前用户与项目的关系
+func relationTo(current *authctx.User, project *models.Project) accessRelation {
+	relation := accessRelation{Admin: current.IsAdmin(), Owner: project.UserID == current.ID}
+	if member, err := findMember(project.ID, current.ID); err == nil {
+		relation.MemberRole = member.Role
+	}
+	return relation
+}
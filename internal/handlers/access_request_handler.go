@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AccessRequestHandler 加入项目申请与项目成员处理器
+type AccessRequestHandler struct {
+	notifier *notify.Manager
+}
+
+// NewAccessRequestHandler 创建加入项目申请处理器
+func NewAccessRequestHandler() *AccessRequestHandler {
+	return &AccessRequestHandler{
+		notifier: notify.NewManager(config.GetConfig()),
+	}
+}
+
+// Create 申请加入项目，任何登录用户都可以申请。已有待处理的申请时合并到其中，不重复通知；
+// 申请人邮箱域名匹配项目的自动批准规则时直接加入项目
+func (h *AccessRequestHandler) Create(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var project models.Project
+	if err := projectLookup(database.DB, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+
+	var req models.CreateAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.ProjectRoleViewer
+	}
+	if !models.IsValidProjectRole(req.Role) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "项目角色无效")
+		return
+	}
+
+	if project.UserID == current.ID {
+		utils.ErrorResponse(c, http.StatusConflict, "已是项目成员")
+		return
+	}
+	if member, err := findMember(project.ID, current.ID); err == nil &&
+		models.ProjectRoleRank(member.Role) >= models.ProjectRoleRank(req.Role) {
+		utils.ErrorResponse(c, http.StatusConflict, "已是项目成员")
+		return
+	}
+
+	var requester models.User
+	if err := database.DB.First(&requester, current.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	expireAccessRequests()
+	expiresAt := time.Now().AddDate(0, 0, config.GetConfig().Security.AccessRequestExpireDays)
+
+	// 合并重复申请：更新留言与角色并延长有效期
+	var request models.ProjectAccessRequest
+	err := database.DB.Where("project_id = ? AND user_id = ? AND status = ?", project.ID, current.ID, models.AccessRequestPending).
+		First(&request).Error
+	if err == nil {
+		request.Role = req.Role
+		request.Message = req.Message
+		request.Requests++
+		request.ExpiresAt = expiresAt
+		if err := database.DB.Save(&request).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "保存加入申请失败")
+			return
+		}
+		utils.SuccessResponse(c, request)
+		return
+	}
+
+	request = models.ProjectAccessRequest{
+		ProjectID: project.ID,
+		UserID:    current.ID,
+		Role:      req.Role,
+		Message:   req.Message,
+		Status:    models.AccessRequestPending,
+		Requests:  1,
+		ExpiresAt: expiresAt,
+	}
+	if err := database.DB.Create(&request).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存加入申请失败")
+		return
+	}
+
+	if role, ok := autoApproveRole(&project, &requester, req.Role); ok {
+		request.Role = role
+		if err := h.grant(&request, nil, "自动批准：邮箱域名匹配项目规则"); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "保存加入申请失败")
+			return
+		}
+		recordAudit(c, "approve_access_request", "project", project.ID,
+			fmt.Sprintf("按邮箱域名规则自动批准 %s 加入项目 %s（角色: %s）", requester.Username, project.Name, role))
+		h.notifyRequester(c, &request, &project, &requester)
+		utils.SuccessResponse(c, request)
+		return
+	}
+
+	recordAudit(c, "create_access_request", "project", project.ID,
+		fmt.Sprintf("%s 申请加入项目 %s（角色: %s）", requester.Username, project.Name, req.Role))
+	h.notifyApprovers(c, &request, &project, &requester)
+
+	utils.SuccessResponse(c, request)
+}
+
+// List 项目的加入申请（所有者、maintainer 成员与管理员），status 默认 pending，all 表示全部
+func (h *AccessRequestHandler) List(c *gin.Context) {
+	project, ok := loadApprovableProject(c)
+	if !ok {
+		return
+	}
+
+	expireAccessRequests()
+	query := database.DB.Preload("User").Where("project_id = ?", project.ID)
+	if status := c.DefaultQuery("status", models.AccessRequestPending); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []models.ProjectAccessRequest
+	if err := query.Order("id DESC").Find(&requests).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取加入申请失败")
+		return
+	}
+
+	utils.SuccessResponse(c, requests)
+}
+
+// Mine 当前用户提交的加入申请
+func (h *AccessRequestHandler) Mine(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	expireAccessRequests()
+	var requests []models.ProjectAccessRequest
+	if err := database.DB.Preload("Project", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "name", "slug", "user_id")
+	}).Where("user_id = ?", current.ID).Order("id DESC").Find(&requests).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取加入申请失败")
+		return
+	}
+
+	utils.SuccessResponse(c, requests)
+}
+
+// Approve 批准加入申请并授予成员资格，可以调整授予的角色
+func (h *AccessRequestHandler) Approve(c *gin.Context) {
+	h.decide(c, models.AccessRequestApproved)
+}
+
+// Deny 拒绝加入申请
+func (h *AccessRequestHandler) Deny(c *gin.Context) {
+	h.decide(c, models.AccessRequestDenied)
+}
+
+// decide 处理待处理的加入申请，记录审计日志并通知申请人
+func (h *AccessRequestHandler) decide(c *gin.Context, status string) {
+	project, ok := loadApprovableProject(c)
+	if !ok {
+		return
+	}
+	current, _ := currentUser(c)
+
+	var req models.DecideAccessRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+	}
+
+	expireAccessRequests()
+	var request models.ProjectAccessRequest
+	if err := database.DB.Preload("User").Where("project_id = ?", project.ID).
+		First(&request, c.Param("requestId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "加入申请不存在")
+		return
+	}
+	if request.Status != models.AccessRequestPending {
+		utils.ErrorResponse(c, http.StatusConflict, "加入申请已处理或已过期")
+		return
+	}
+
+	if status == models.AccessRequestApproved {
+		if req.Role != "" {
+			if !models.IsValidProjectRole(req.Role) {
+				utils.ErrorResponse(c, http.StatusBadRequest, "项目角色无效")
+				return
+			}
+			request.Role = req.Role
+		}
+		// maintainer 成员只能授予不高于自己的角色
+		if !current.IsAdmin() && current.ID != project.UserID {
+			if member, err := findMember(project.ID, current.ID); err != nil ||
+				models.ProjectRoleRank(request.Role) > models.ProjectRoleRank(member.Role) {
+				utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+				return
+			}
+		}
+		if err := h.grant(&request, &current.ID, req.Note); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "处理加入申请失败")
+			return
+		}
+		recordAudit(c, "approve_access_request", "project", project.ID,
+			fmt.Sprintf("批准 %s 加入项目 %s（角色: %s）", requesterName(&request), project.Name, request.Role))
+	} else {
+		now := time.Now()
+		request.Status = models.AccessRequestDenied
+		request.DecidedByID = &current.ID
+		request.DecidedAt = &now
+		request.DecisionNote = req.Note
+		if err := database.DB.Model(&request).Updates(map[string]interface{}{
+			"status":        request.Status,
+			"decided_by_id": request.DecidedByID,
+			"decided_at":    request.DecidedAt,
+			"decision_note": request.DecisionNote,
+		}).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "处理加入申请失败")
+			return
+		}
+		recordAudit(c, "deny_access_request", "project", project.ID,
+			fmt.Sprintf("拒绝 %s 加入项目 %s", requesterName(&request), project.Name))
+	}
+
+	h.notifyRequester(c, &request, project, request.User)
+	utils.SuccessResponse(c, request)
+}
+
+// grant 批准申请并授予成员资格；已是成员时按申请调整角色。decidedBy 为空表示自动批准
+func (h *AccessRequestHandler) grant(request *models.ProjectAccessRequest, decidedBy *uint, note string) error {
+	now := time.Now()
+	defer membershipCache.Invalidate(request.ProjectID)
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var member models.ProjectMember
+		err := tx.Where("project_id = ? AND user_id = ?", request.ProjectID, request.UserID).First(&member).Error
+		switch {
+		case err == nil:
+			if err := tx.Model(&member).Updates(map[string]interface{}{
+				"role":              request.Role,
+				"granted_by_id":     decidedBy,
+				"access_request_id": request.ID,
+			}).Error; err != nil {
+				return fmt.Errorf("更新项目成员失败: %w", err)
+			}
+		case err == gorm.ErrRecordNotFound:
+			member = models.ProjectMember{
+				ProjectID:       request.ProjectID,
+				UserID:          request.UserID,
+				Role:            request.Role,
+				GrantedByID:     decidedBy,
+				AccessRequestID: &request.ID,
+			}
+			if err := tx.Create(&member).Error; err != nil {
+				return fmt.Errorf("添加项目成员失败: %w", err)
+			}
+		default:
+			return fmt.Errorf("查询项目成员失败: %w", err)
+		}
+
+		request.Status = models.AccessRequestApproved
+		request.AutoApproved = decidedBy == nil
+		request.DecidedByID = decidedBy
+		request.DecidedAt = &now
+		request.DecisionNote = note
+		return tx.Model(request).Updates(map[string]interface{}{
+			"role":          request.Role,
+			"status":        request.Status,
+			"auto_approved": request.AutoApproved,
+			"decided_by_id": request.DecidedByID,
+			"decided_at":    request.DecidedAt,
+			"decision_note": request.DecisionNote,
+		}).Error
+	})
+}
+
+// GetMembers 项目成员列表，所有者、成员与管理员可以查看
+func (h *AccessRequestHandler) GetMembers(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var project models.Project
+	if err := projectLookup(database.DB, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+	if !current.IsAdmin() && current.ID != project.UserID {
+		if _, err := findMember(project.ID, current.ID); err != nil {
+			utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+			return
+		}
+	}
+
+	var members []models.ProjectMember
+	if err := database.DB.Preload("User").Where("project_id = ?", project.ID).Order("id").Find(&members).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目成员失败")
+		return
+	}
+
+	utils.SuccessResponse(c, members)
+}
+
+// RemoveMember 移除项目成员（所有者或管理员）
+func (h *AccessRequestHandler) RemoveMember(c *gin.Context) {
+	project, ok := loadOwnedProjectByID(c)
+	if !ok {
+		return
+	}
+
+	var member models.ProjectMember
+	if err := database.DB.Preload("User").Where("project_id = ? AND user_id = ?", project.ID, c.Param("userId")).
+		First(&member).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目成员不存在")
+		return
+	}
+	if err := database.DB.Delete(&member).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "移除项目成员失败")
+		return
+	}
+	membershipCache.Invalidate(project.ID)
+
+	username := ""
+	if member.User != nil {
+		username = member.User.Username
+	}
+	recordAudit(c, "remove_project_member", "project", project.ID,
+		fmt.Sprintf("从项目 %s 移除成员 %s（角色: %s）", project.Name, username, member.Role))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// UpdateAutoApprove 设置加入申请的自动批准规则（所有者或管理员）：邮箱域名匹配的申请自动批准，授予的角色不超过 role
+func (h *AccessRequestHandler) UpdateAutoApprove(c *gin.Context) {
+	project, ok := loadOwnedProjectByID(c)
+	if !ok {
+		return
+	}
+
+	var req models.AccessAutoApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.ProjectRoleViewer
+	}
+	if !models.IsValidProjectRole(req.Role) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "项目角色无效")
+		return
+	}
+
+	before := projectSettingsText(project)
+	domains := strings.Join(normalizeDomains(req.Domains), ",")
+	if err := database.DB.Model(project).Updates(map[string]interface{}{
+		"access_auto_approve_domains": domains,
+		"access_auto_approve_role":    req.Role,
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存自动批准规则失败")
+		return
+	}
+	project.AccessAutoApproveDomains = domains
+	project.AccessAutoApproveRole = req.Role
+
+	recordAuditChange(c, "update_access_auto_approve", "project", project.ID,
+		fmt.Sprintf("设置项目 %s 加入申请的自动批准规则: 域名 %s，角色 %s", project.Name, domains, req.Role),
+		before, projectSettingsText(project))
+
+	utils.SuccessResponse(c, gin.H{
+		"domains": normalizeDomains(strings.Split(domains, ",")),
+		"role":    project.AccessAutoApproveRole,
+	})
+}
+
+// notifyApprovers 通知项目所有者与 maintainer 成员有新的加入申请
+func (h *AccessRequestHandler) notifyApprovers(c *gin.Context, request *models.ProjectAccessRequest, project *models.Project, requester *models.User) {
+	userIDs := []uint{project.UserID}
+	var maintainers []uint
+	database.DB.Model(&models.ProjectMember{}).Where("project_id = ? AND role = ?", project.ID, models.ProjectRoleMaintainer).
+		Pluck("user_id", &maintainers)
+	userIDs = append(userIDs, maintainers...)
+
+	var approvers []models.User
+	if err := database.DB.Where("id IN ?", userIDs).Find(&approvers).Error; err != nil {
+		log.Printf("查询项目 %d 的申请审批人失败: %v", project.ID, err)
+		return
+	}
+
+	content := fmt.Sprintf("%s（%s）申请以 %s 角色加入项目 %s。", requester.Username, requester.Email, request.Role, project.Name)
+	if request.Message != "" {
+		content += "\n\n留言: " + request.Message
+	}
+	content += fmt.Sprintf("\n\n申请在 %s 前有效。", utils.FormatTime(request.ExpiresAt))
+	msg := notify.Message{
+		Title:    fmt.Sprintf("%s 申请加入项目 %s", requester.Username, project.Name),
+		Content:  content,
+		Link:     fmt.Sprintf("%s/projects/%d/access-requests", siteURL(c), project.ID),
+		Level:    models.NotifyLevelNormal,
+		Category: models.NotifyCategoryAccessRequest,
+	}
+	for i := range approvers {
+		if err := h.notifier.Deliver(&approvers[i], msg); err != nil {
+			log.Printf("向用户 %d 投递加入申请通知失败: %v", approvers[i].ID, err)
+		}
+	}
+}
+
+// notifyRequester 通知申请人申请的处理结果
+func (h *AccessRequestHandler) notifyRequester(c *gin.Context, request *models.ProjectAccessRequest, project *models.Project, requester *models.User) {
+	if requester == nil {
+		return
+	}
+
+	var title, content string
+	if request.Status == models.AccessRequestApproved {
+		title = fmt.Sprintf("已加入项目 %s", project.Name)
+		content = fmt.Sprintf("你加入项目 %s 的申请已批准，角色: %s。", project.Name, request.Role)
+	} else {
+		title = fmt.Sprintf("加入项目 %s 的申请被拒绝", project.Name)
+		content = fmt.Sprintf("你加入项目 %s 的申请被拒绝。", project.Name)
+	}
+	if request.DecisionNote != "" {
+		content += "\n\n说明: " + request.DecisionNote
+	}
+
+	if err := h.notifier.Deliver(requester, notify.Message{
+		Title:    title,
+		Content:  content,
+		Link:     fmt.Sprintf("%s/projects/%d", siteURL(c), project.ID),
+		Level:    models.NotifyLevelNormal,
+		Category: models.NotifyCategoryAccessRequest,
+	}); err != nil {
+		log.Printf("向用户 %d 投递加入申请结果通知失败: %v", requester.ID, err)
+	}
+}
+
+// requesterName 申请人的用户名，用户已删除时为用户ID
+func requesterName(request *models.ProjectAccessRequest) string {
+	if request.User != nil {
+		return request.User.Username
+	}
+	return fmt.Sprintf("用户 %d", request.UserID)
+}
+
+// autoApproveRole 申请人邮箱域名匹配项目的自动批准规则时授予的角色，不超过规则允许的角色
+func autoApproveRole(project *models.Project, requester *models.User, requested string) (string, bool) {
+	if project.AccessAutoApproveDomains == "" || requester.Email == "" {
+		return "", false
+	}
+	policy := registrationPolicy{AllowedDomains: normalizeDomains(strings.Split(project.AccessAutoApproveDomains, ","))}
+	if !policy.AllowsEmail(requester.Email) {
+		return "", false
+	}
+
+	role := requested
+	if models.ProjectRoleRank(role) > models.ProjectRoleRank(project.AccessAutoApproveRole) {
+		role = project.AccessAutoApproveRole
+	}
+	return role, models.IsValidProjectRole(role)
+}
+
+// expireAccessRequests 将超过有效期的待处理申请标记为过期
+func expireAccessRequests() {
+	if err := database.DB.Model(&models.ProjectAccessRequest{}).
+		Where("status = ? AND expires_at < ?", models.AccessRequestPending, time.Now()).
+		Update("status", models.AccessRequestExpired).Error; err != nil {
+		log.Printf("标记过期的加入申请失败: %v", err)
+	}
+}
+
+// findMember 查询用户在项目中的成员资格
+func findMember(projectID, userID uint) (*models.ProjectMember, error) {
+	var member models.ProjectMember
+	if err := database.DB.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// memberProjects 用户作为成员可以访问的项目ID子查询，指定 role 时只包括至少为该角色的成员资格
+func memberProjects(userID uint, role string) *gorm.DB {
+	query := database.DB.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", userID)
+	if role == models.ProjectRoleMaintainer {
+		query = query.Where("role = ?", models.ProjectRoleMaintainer)
+	}
+	return query
+}
+
+// loadApprovableProject 加载当前用户可以处理加入申请的项目：所有者、maintainer 成员或管理员
+func loadApprovableProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	if err := projectLookup(database.DB, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+	if !current.IsAdmin() && current.ID != project.UserID {
+		if member, err := findMember(project.ID, current.ID); err != nil || member.Role != models.ProjectRoleMaintainer {
+			utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+			return nil, false
+		}
+	}
+
+	return &project, true
+}
+
+// loadOwnedProjectByID 加载当前用户为所有者或管理员的项目
+func loadOwnedProjectByID(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	if err := projectLookup(database.DB, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+	if !current.IsAdmin() && current.ID != project.UserID {
+		utils.ErrorResponse(c, http.StatusForbidden, "只有项目所有者或管理员可以执行此操作")
+		return nil, false
+	}
+
+	return &project, true
+}
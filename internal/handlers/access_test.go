@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/cache"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 权限矩阵中的用户：管理员、项目所有者、maintainer 成员、viewer 成员与无关用户
+var (
+	adminUser      = authctx.User{ID: 1, Username: "admin", Role: models.RoleAdmin}
+	ownerUser      = authctx.User{ID: 2, Username: "owner", Role: models.RoleUser}
+	maintainerUser = authctx.User{ID: 3, Username: "maintainer", Role: models.RoleUser}
+	viewerUser     = authctx.User{ID: 4, Username: "viewer", Role: models.RoleUser}
+	outsiderUser   = authctx.User{ID: 5, Username: "outsider", Role: models.RoleUser}
+)
+
+// permissionMatrix 各角色对流水线的查看、运行与修改权限
+var permissionMatrix = []struct {
+	user                authctx.User
+	view, trigger, edit bool
+}{
+	{adminUser, true, true, true},
+	{ownerUser, true, true, true},
+	{maintainerUser, true, true, false},
+	{viewerUser, true, false, false},
+	{outsiderUser, false, false, false},
+}
+
+// setupAccessTest 内存数据库中的项目、流水线与成员，返回流水线
+func setupAccessTest(t *testing.T) *models.Pipeline {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	project := &models.Project{Name: "web", RepoURL: "https://git.example/web.git", UserID: ownerUser.ID}
+	if err := database.DB.Create(project).Error; err != nil {
+		t.Fatal(err)
+	}
+	pipeline := &models.Pipeline{Name: "build", ProjectID: project.ID}
+	if err := database.DB.Create(pipeline).Error; err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: maintainerUser.ID, Role: models.ProjectRoleMaintainer})
+	database.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: viewerUser.ID, Role: models.ProjectRoleViewer})
+
+	// 成员关系缓存按项目ID缓存，各测试的数据库相互独立
+	membershipCache.Invalidate(project.ID)
+	t.Cleanup(func() { membershipCache.Invalidate(project.ID) })
+	pipeline.Project = *project
+	return pipeline
+}
+
+// queryPermitted 处理器的权限查询能否查到流水线：管理员不加条件，其他用户按 projectAccess 筛选
+func queryPermitted(t *testing.T, user *authctx.User, pipelineID uint, action string) bool {
+	t.Helper()
+	query := database.DB.Model(&models.Pipeline{})
+	if !user.IsAdmin() {
+		query = query.Joins("JOIN projects ON pipelines.project_id = projects.id").Where(projectAccess(user.ID, action))
+	}
+	var count int64
+	if err := query.Where("pipelines.id = ?", pipelineID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+// explainPipeline 以 user 身份请求权限排查接口
+func explainPipeline(t *testing.T, user authctx.User, pipelineID uint) (int, map[string]*actionResult) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/debug/access?resource=pipeline&id=%d", pipelineID), nil)
+	authctx.SetCurrentUser(c, user)
+	NewAccessDebugHandler(nil).Explain(c)
+
+	var body struct {
+		Data struct {
+			Actions map[string]*actionResult `json:"actions"`
+		} `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, body.Data.Actions
+}
+
+// TestPermissionMatrix 处理器的查询条件、按缓存的判断与排查接口对每个角色与操作的结论一致
+func TestPermissionMatrix(t *testing.T) {
+	pipeline := setupAccessTest(t)
+	actions := []struct {
+		action  string
+		explain string // 排查接口中对应的操作
+	}{
+		{actionView, "view"},
+		{actionTrigger, "run"},
+		{actionEdit, "delete"},
+	}
+
+	for _, row := range permissionMatrix {
+		user := row.user
+		want := map[string]bool{actionView: row.view, actionTrigger: row.trigger, actionEdit: row.edit}
+		t.Run(user.Username, func(t *testing.T) {
+			status, explained := explainPipeline(t, user, pipeline.ID)
+			if !row.view {
+				// 没有查看权限时只返回资源不存在
+				if status != http.StatusNotFound {
+					t.Fatalf("没有查看权限时排查接口应返回 404，实际为 %d", status)
+				}
+			} else if status != http.StatusOK {
+				t.Fatalf("排查接口返回 %d", status)
+			}
+
+			for _, a := range actions {
+				if got := queryPermitted(t, &user, pipeline.ID, a.action); got != want[a.action] {
+					t.Errorf("%s 查询条件 = %v，应为 %v", a.action, got, want[a.action])
+				}
+				if got := projectPermitted(&user, pipeline.ProjectID, a.action); got != want[a.action] {
+					t.Errorf("%s projectPermitted = %v，应为 %v", a.action, got, want[a.action])
+				}
+				if got := permissionCheck(&user, &pipeline.Project, a.action).Passed; got != want[a.action] {
+					t.Errorf("%s permissionCheck = %v，应为 %v", a.action, got, want[a.action])
+				}
+				if explained == nil {
+					continue
+				}
+				result := explained[a.explain]
+				if result == nil || len(result.Checks) == 0 || result.Checks[0].Name != "permission" {
+					t.Fatalf("排查接口缺少 %s 的权限检查: %+v", a.explain, result)
+				}
+				if result.Checks[0].Passed != want[a.action] {
+					t.Errorf("排查接口 %s 权限检查 = %v，应为 %v（%s）", a.explain, result.Checks[0].Passed, want[a.action], result.Checks[0].Detail)
+				}
+			}
+		})
+	}
+}
+
+// TestPermissionMatrixAfterMembershipChange 成员角色变更并使缓存失效后，查询条件与缓存判断同时生效
+func TestPermissionMatrixAfterMembershipChange(t *testing.T) {
+	pipeline := setupAccessTest(t)
+	if projectPermitted(&viewerUser, pipeline.ProjectID, actionTrigger) {
+		t.Fatal("viewer 成员不能运行流水线")
+	}
+
+	database.DB.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", pipeline.ProjectID, viewerUser.ID).
+		Update("role", models.ProjectRoleMaintainer)
+	membershipCache.Invalidate(pipeline.ProjectID)
+
+	if !queryPermitted(t, &viewerUser, pipeline.ID, actionTrigger) || !projectPermitted(&viewerUser, pipeline.ProjectID, actionTrigger) {
+		t.Error("升级为 maintainer 后应可以运行流水线")
+	}
+	if queryPermitted(t, &viewerUser, pipeline.ID, actionEdit) || projectPermitted(&viewerUser, pipeline.ProjectID, actionEdit) {
+		t.Error("maintainer 成员不能修改流水线")
+	}
+}
+
+// TestMembershipRemovalWithinCacheTTL 移除成员时遗漏了缓存失效，成员最迟在缓存时间后失去权限
+func TestMembershipRemovalWithinCacheTTL(t *testing.T) {
+	pipeline := setupAccessTest(t)
+	cache.Init(&config.CacheConfig{TTL: 1})
+	t.Cleanup(func() { cache.Init(&config.CacheConfig{TTL: 30}) })
+	if !projectPermitted(&maintainerUser, pipeline.ProjectID, actionTrigger) {
+		t.Fatal("maintainer 成员应可以运行流水线")
+	}
+
+	database.DB.Where("project_id = ? AND user_id = ?", pipeline.ProjectID, maintainerUser.ID).Delete(&models.ProjectMember{})
+	if queryPermitted(t, &maintainerUser, pipeline.ID, actionTrigger) {
+		t.Error("移除成员后查询条件应立即生效")
+	}
+	removedAt := time.Now()
+	for projectPermitted(&maintainerUser, pipeline.ProjectID, actionTrigger) {
+		if time.Since(removedAt) > cache.TTL()+time.Second {
+			t.Fatalf("移除成员后超过缓存时间 %v 仍可以运行流水线", cache.TTL())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if projectPermitted(&maintainerUser, pipeline.ProjectID, actionView) {
+		t.Error("移除的成员不能再查看流水线")
+	}
+}
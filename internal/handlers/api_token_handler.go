@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/auth"
+	"flowforge/pkg/database"
+	"flowforge/pkg/ipallow"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenHandler API令牌处理器
+type APITokenHandler struct{}
+
+// NewAPITokenHandler 创建API令牌处理器
+func NewAPITokenHandler() *APITokenHandler {
+	return &APITokenHandler{}
+}
+
+// GetAPITokens 获取API令牌列表（管理员），不含令牌明文
+func (h *APITokenHandler) GetAPITokens(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var tokens []models.APIToken
+	if err := database.DB.Order("id DESC").Find(&tokens).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取API令牌失败")
+		return
+	}
+
+	utils.SuccessResponse(c, tokens)
+}
+
+// CreateAPIToken 创建API令牌（管理员），令牌明文只在本次响应中返回
+func (h *APITokenHandler) CreateAPIToken(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+	// 令牌不能再签发令牌，避免泄露的令牌自我延续
+	if current.APITokenID != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "API令牌不能创建新的令牌")
+		return
+	}
+
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = models.APITokenScopeAdmin
+	}
+	if req.Scope != models.APITokenScopeAdmin || req.ExpiresInDays < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	allowedIPs, err := ipallow.NormalizeCIDRs(req.AllowedIPs)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plain, hash := auth.GenerateAPIToken()
+	token := models.APIToken{
+		Name:      strings.TrimSpace(req.Name),
+		TokenHash: hash,
+		Prefix:    plain[:len(auth.APITokenPrefix)+4],
+		Scope:     req.Scope,
+		UserID:    current.ID,
+
+		AllowedIPs: allowedIPs,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&token).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建API令牌失败")
+		return
+	}
+
+	recordAudit(c, "create_api_token", "api_token", token.ID, fmt.Sprintf("创建API令牌 %s（范围: %s）", token.Name, token.Scope))
+
+	utils.SuccessResponse(c, gin.H{
+		"token":     plain,
+		"api_token": token,
+	})
+}
+
+// UpdateAPIToken 修改API令牌的来源限制（管理员），令牌本身不能修改自己的限制
+func (h *APITokenHandler) UpdateAPIToken(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+	if current.APITokenID != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "API令牌不能修改令牌的来源限制")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的令牌ID")
+		return
+	}
+	var req models.UpdateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	allowedIPs, err := ipallow.NormalizeCIDRs(req.AllowedIPs)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var token models.APIToken
+	if err := database.DB.First(&token, id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "API令牌不存在")
+		return
+	}
+	before := token.AllowedIPs
+	if err := database.DB.Model(&token).Update("allowed_ips", allowedIPs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存API令牌来源限制失败")
+		return
+	}
+	token.AllowedIPs = allowedIPs
+	recordAuditChange(c, "update_api_token", "api_token", token.ID,
+		fmt.Sprintf("修改API令牌 %s 的来源限制", token.Name), before, allowedIPs)
+
+	utils.SuccessResponse(c, token)
+}
+
+// RevokeAPIToken 撤销API令牌（管理员）
+func (h *APITokenHandler) RevokeAPIToken(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的令牌ID")
+		return
+	}
+
+	var token models.APIToken
+	if err := database.DB.First(&token, id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "API令牌不存在")
+		return
+	}
+
+	if token.RevokedAt == nil {
+		now := time.Now()
+		token.RevokedAt = &now
+		if err := database.DB.Model(&token).Update("revoked_at", &now).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "撤销API令牌失败")
+			return
+		}
+		recordAudit(c, "revoke_api_token", "api_token", token.ID, fmt.Sprintf("撤销API令牌 %s", token.Name))
+	}
+
+	utils.SuccessResponse(c, token)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArtifactHandler 制品处理器
+type ArtifactHandler struct {
+	store *artifact.Store
+}
+
+// NewArtifactHandler 创建制品处理器
+func NewArtifactHandler(store *artifact.Store) *ArtifactHandler {
+	return &ArtifactHandler{
+		store: store,
+	}
+}
+
+// UploadArtifact 上传制品，可通过 Digest 请求头或 sha256 表单字段声明摘要以校验传输完整性
+func (h *ArtifactHandler) UploadArtifact(c *gin.Context) {
+	run, ok := h.loadRun(c)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "获取上传文件失败")
+		return
+	}
+
+	expected := c.PostForm("sha256")
+	if header := c.GetHeader("Digest"); header != "" {
+		expected, err = artifact.ParseDigestHeader(header)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "读取上传文件失败")
+		return
+	}
+	defer src.Close()
+
+	name := c.DefaultPostForm("name", file.Filename)
+	saved, err := h.store.Put(c.Request.Context(), run.ID, name, src, expected)
+	if errors.Is(err, artifact.ErrDigestMismatch) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存制品失败")
+		return
+	}
+
+	utils.SuccessResponse(c, saved)
+}
+
+// GetArtifacts 获取运行的制品列表
+func (h *ArtifactHandler) GetArtifacts(c *gin.Context) {
+	run, ok := h.loadRun(c)
+	if !ok {
+		return
+	}
+
+	var artifacts []models.Artifact
+	database.DB.Where("pipeline_run_id = ?", run.ID).Order("id ASC").Find(&artifacts)
+
+	// 附带取用这些制品的运行，便于追溯制品被提升到了哪里
+	ids := make([]uint, len(artifacts))
+	for i := range artifacts {
+		ids[i] = artifacts[i].ID
+	}
+	var consumers []models.ArtifactConsumption
+	if len(ids) > 0 {
+		database.DB.Where("consumed_artifact_id IN ?", ids).Order("id").Find(&consumers)
+	}
+	for i := range consumers {
+		for j := range artifacts {
+			if artifacts[j].ID == consumers[i].ConsumedArtifactID {
+				artifacts[j].Consumers = append(artifacts[j].Consumers, consumers[i])
+			}
+		}
+	}
+
+	utils.SuccessResponse(c, artifacts)
+}
+
+// HeadArtifact 返回制品大小与摘要，不传输内容
+func (h *ArtifactHandler) HeadArtifact(c *gin.Context) {
+	a, ok := h.loadArtifact(c)
+	if !ok {
+		return
+	}
+
+	setArtifactHeaders(c, a)
+	c.Status(http.StatusOK)
+}
+
+// DownloadArtifact 下载制品，发送前校验存储数据完整性，并通过 ETag/Digest 头供客户端校验
+func (h *ArtifactHandler) DownloadArtifact(c *gin.Context) {
+	a, ok := h.loadArtifact(c)
+	if !ok {
+		return
+	}
+
+	etag := `"` + a.Digest + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	file, err := h.store.Open(a)
+	if errors.Is(err, artifact.ErrBlobCorrupted) {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "制品数据不存在")
+		return
+	}
+	defer file.Close()
+
+	setArtifactHeaders(c, a)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.Name))
+	c.DataFromReader(http.StatusOK, a.Size, "application/octet-stream", file, nil)
+}
+
+// setArtifactHeaders 设置制品的大小与摘要响应头
+func setArtifactHeaders(c *gin.Context, a *models.Artifact) {
+	c.Header("Content-Length", strconv.FormatInt(a.Size, 10))
+	c.Header("ETag", `"`+a.Digest+`"`)
+	c.Header("Digest", artifact.DigestHeader(a.Digest))
+	c.Header("X-Checksum-Sha256", a.Digest)
+}
+
+// loadRun 加载流水线运行并校验当前用户为项目所有者或管理员
+func (h *ArtifactHandler) loadRun(c *gin.Context) (*models.PipelineRun, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var run models.PipelineRun
+	query := database.DB.Where("pipeline_runs.pipeline_id = ?", c.Param("id"))
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where("projects.user_id = ?", current.ID)
+	}
+
+	if err := query.First(&run, c.Param("runId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return nil, false
+	}
+
+	return &run, true
+}
+
+// loadArtifact 加载属于当前运行的制品
+func (h *ArtifactHandler) loadArtifact(c *gin.Context) (*models.Artifact, bool) {
+	run, ok := h.loadRun(c)
+	if !ok {
+		return nil, false
+	}
+
+	var a models.Artifact
+	if err := database.DB.Where("pipeline_run_id = ?", run.ID).First(&a, c.Param("artifactId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "制品不存在")
+		return nil, false
+	}
+
+	return &a, true
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArtifactShareHandler 跨项目制品授权处理器：同一项目的流水线之间可直接通过 artifact_from 使用制品，
+// 其他项目需由制品所属项目的所有者或管理员授权
+type ArtifactShareHandler struct{}
+
+// NewArtifactShareHandler 创建跨项目制品授权处理器
+func NewArtifactShareHandler() *ArtifactShareHandler {
+	return &ArtifactShareHandler{}
+}
+
+// GetShares 获取可以使用本项目制品的其他项目
+func (h *ArtifactShareHandler) GetShares(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var shares []models.ArtifactShare
+	if err := database.DB.Where("project_id = ?", project.ID).Order("id").Find(&shares).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+
+	utils.SuccessResponse(c, shares)
+}
+
+// CreateShare 授权其他项目的流水线使用本项目的制品，已授权时直接返回
+func (h *ArtifactShareHandler) CreateShare(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var req models.ArtifactShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	var consumer models.Project
+	if req.ConsumerProjectID == project.ID || database.DB.First(&consumer, req.ConsumerProjectID).Error != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "被授权的项目无效")
+		return
+	}
+
+	share := models.ArtifactShare{ProjectID: project.ID, ConsumerProjectID: consumer.ID, CreatedByID: &current.ID}
+	if err := database.DB.Where(models.ArtifactShare{ProjectID: project.ID, ConsumerProjectID: consumer.ID}).
+		FirstOrCreate(&share).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存制品授权失败")
+		return
+	}
+
+	recordAudit(c, "create_artifact_share", "project", project.ID,
+		fmt.Sprintf("授权项目 %s 使用项目 %s 的制品", consumer.Name, project.Name))
+
+	utils.SuccessResponse(c, share)
+}
+
+// DeleteShare 取消授权，已记录的制品来源不受影响
+func (h *ArtifactShareHandler) DeleteShare(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var share models.ArtifactShare
+	if err := database.DB.Where("project_id = ?", project.ID).First(&share, c.Param("share_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "制品授权不存在")
+		return
+	}
+	if err := database.DB.Delete(&share).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除制品授权失败")
+		return
+	}
+
+	recordAudit(c, "delete_artifact_share", "project", project.ID,
+		fmt.Sprintf("取消项目 %d 使用项目 %s 制品的授权", share.ConsumerProjectID, project.Name))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// loadProject 加载项目并校验当前用户为项目所有者或管理员
+func (h *ArtifactShareHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/database"
+	"flowforge/pkg/events"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordAudit 记录当前用户的操作审计日志，失败只记录日志不影响请求
+func recordAudit(c *gin.Context, action, resourceType string, resourceID uint, description string) {
+	recordAuditChange(c, action, resourceType, resourceID, description, "", "")
+}
+
+// recordAuditChange 记录带变更前后内容的审计日志，before、after 需已脱敏，通过审计接口查看差异
+func recordAuditChange(c *gin.Context, action, resourceType string, resourceID uint, description, before, after string) {
+	auditLog := models.AuditLog{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Description:  description,
+		IP:           c.ClientIP(),
+		UserAgent:    c.GetHeader("User-Agent"),
+		Before:       before,
+		After:        after,
+	}
+	if current, err := authctx.CurrentUser(c); err == nil {
+		auditLog.UserID = &current.ID
+	}
+
+	if err := createAuditLog(c, database.DB, &auditLog); err != nil {
+		log.Printf("记录审计日志失败: %v", err)
+	}
+}
+
+// createAuditLog 保存审计日志，并在同一事务中写入对应的系统事件
+func createAuditLog(c *gin.Context, db *gorm.DB, auditLog *models.AuditLog) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(auditLog).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuditEvent(auditLog, auditActor(c, auditLog), c.GetString("requestId")))
+	})
+}
+
+// auditActor 审计事件的操作者，未登录的操作（如注册）为审计日志记录的用户
+func auditActor(c *gin.Context, auditLog *models.AuditLog) events.Actor {
+	if current, err := authctx.CurrentUser(c); err == nil {
+		return events.Actor{Kind: events.ActorUser, ID: current.ID, Username: current.Username, APITokenID: current.APITokenID}
+	}
+	if auditLog.UserID != nil {
+		return events.UserActor(*auditLog.UserID)
+	}
+	return events.Actor{Kind: events.ActorSystem}
+}
+
+// maskForProject 使用项目已知的密钥值脱敏文本，读取失败时只按密钥赋值脱敏
+func maskForProject(projectID uint, text string) string {
+	secrets, err := redact.ProjectSecrets(projectID)
+	if err != nil {
+		log.Printf("读取项目 %d 的密钥失败: %v", projectID, err)
+	}
+	return redact.MaskConsistent(text, secrets)
+}
+
+// projectSettingsText 项目设置的文本形式，用于审计日志中的变更对比
+func projectSettingsText(project *models.Project) string {
+	sshKeyID := ""
+	if project.SSHKeyID != nil {
+		sshKeyID = fmt.Sprintf("%d", *project.SSHKeyID)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\n", project.Name)
+	fmt.Fprintf(&b, "slug: %s\n", project.Slug)
+	fmt.Fprintf(&b, "description: %s\n", project.Description)
+	fmt.Fprintf(&b, "repo_url: %s\n", project.RepoURL)
+	fmt.Fprintf(&b, "branch: %s\n", project.Branch)
+	fmt.Fprintf(&b, "build_path: %s\n", project.BuildPath)
+	fmt.Fprintf(&b, "ssh_key_id: %s\n", sshKeyID)
+	fmt.Fprintf(&b, "size_hint_mb: %d\n", project.SizeHintMB)
+	fmt.Fprintf(&b, "default_shell: %s\n", project.DefaultShell)
+	fmt.Fprintf(&b, "max_concurrent_runs: %d\n", project.MaxConcurrentRuns)
+	fmt.Fprintf(&b, "mutex_groups: %s\n", project.MutexGroups)
+	fmt.Fprintf(&b, "allowed_run_labels: %s\n", project.AllowedRunLabels)
+	fmt.Fprintf(&b, "log_soft_quota_mb: %d\n", project.LogSoftQuotaMB)
+	fmt.Fprintf(&b, "log_hard_cap_mb: %d\n", project.LogHardCapMB)
+	fmt.Fprintf(&b, "failure_spike_factor: %g\n", project.FailureSpikeFactor)
+	fmt.Fprintf(&b, "failure_spike_min_runs: %d\n", project.FailureSpikeMinRuns)
+	fmt.Fprintf(&b, "failure_spike_disabled: %t\n", project.FailureSpikeDisabled)
+	fmt.Fprintf(&b, "access_auto_approve_domains: %s\n", project.AccessAutoApproveDomains)
+	fmt.Fprintf(&b, "access_auto_approve_role: %s\n", project.AccessAutoApproveRole)
+	return maskForProject(project.ID, b.String())
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/diff"
+	"flowforge/pkg/export"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct{}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+// GetAuditLogs 获取审计日志列表（管理员），可按操作、资源、用户与日期 from、to 筛选；
+// ?format=csv|ndjson 或对应的 Accept 头时不分页导出，不含变更前后的内容
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := database.DB.Model(&models.AuditLog{})
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if resourceType := c.Query("resource_type"); resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+	if resourceID := c.Query("resource_id"); resourceID != "" {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	dates, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	query = dates.apply(query, "audit_logs.created_at")
+
+	if format := exportFormat(c); format != "" {
+		spec := exportSpec{format: format, resource: "audit-logs", dates: dates, header: export.AuditColumns}
+		writeExport[export.AuditRow](c, query, spec, func(db *gorm.DB) *gorm.DB {
+			return db.Select(tableColumns("audit_logs", export.AuditColumns)).Order("audit_logs.id DESC")
+		}, nil)
+		return
+	}
+
+	var total int64
+	var logs []models.AuditLog
+	query.Count(&total)
+	if err := query.Order("id DESC").Scopes(database.Paginate(page, pageSize)).Find(&logs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	for i := range logs {
+		logs[i].HasDiff = logs[i].Before != "" || logs[i].After != ""
+	}
+
+	utils.SuccessResponse(c, models.PaginationResponse{
+		Data:       logs,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+// GetAuditLogDiff 获取审计日志记录的变更前后差异（管理员），内容在记录时已脱敏
+func (h *AuditHandler) GetAuditLogDiff(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var auditLog models.AuditLog
+	if err := database.DB.First(&auditLog, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "审计日志不存在")
+		return
+	}
+	auditLog.HasDiff = auditLog.Before != "" || auditLog.After != ""
+	if !auditLog.HasDiff {
+		utils.ErrorResponse(c, http.StatusNotFound, "审计日志没有变更内容")
+		return
+	}
+
+	opts := diff.Options{IgnoreWhitespace: c.Query("ignore_whitespace") == "true"}
+	name := fmt.Sprintf("%s/%d", auditLog.ResourceType, auditLog.ResourceID)
+	utils.SuccessResponse(c, gin.H{
+		"audit_log": auditLog,
+		"diff":      diff.Unified(name+" (before)", name+" (after)", auditLog.Before, auditLog.After, opts),
+	})
+}
@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"flowforge/internal/middleware"
 	"flowforge/pkg/auth"
 	"flowforge/pkg/config"
 	"flowforge/pkg/database"
 	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
 	"flowforge/pkg/utils"
 
 	"github.com/gin-gonic/gin"
@@ -49,6 +55,10 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 检查用户状态
+	if user.Status == models.StatusUnverified {
+		utils.ErrorResponse(c, http.StatusForbidden, "邮箱尚未验证")
+		return
+	}
 	if user.Status != models.StatusActive {
 		utils.ErrorResponse(c, http.StatusForbidden, "用户账户已被禁用")
 		return
@@ -63,7 +73,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		roleID = 1
 	}
 	
-		expirationTime := time.Now().Add(time.Duration(cfg.JWT.ExpireTime) * time.Hour)
+		expirationTime := auth.Now().Add(time.Duration(cfg.JWT.ExpireTime) * time.Hour)
 	token, err := auth.GenerateToken(user.ID, user.Username, roleID, cfg.JWT.Secret, expirationTime)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "生成令牌失败")
@@ -82,14 +92,52 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	utils.SuccessResponse(c, response)
 }
 
-// Register 用户注册
+// Register 用户注册，按生效的注册策略校验：closed 拒绝注册，invite 需携带有效的邀请，
+// domain 要求邮箱属于允许的域名，开启邮箱验证时验证后才能登录。携带邀请时按邀请指定的角色创建用户
 func (h *AuthHandler) Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
 		return
 	}
 
+	policy, err := currentRegistrationPolicy(c.Request.Context())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "读取注册策略失败")
+		return
+	}
+
+	user := models.User{
+		Username: strings.TrimSpace(req.Username),
+		Email:    strings.TrimSpace(req.Email),
+		Role:     models.RoleUser,
+		Status:   models.StatusActive,
+	}
+
+	// 邀请可用于 invite 与 domain 策略，兑换邀请时不再校验邮箱域名
+	var invite *models.Invite
+	switch policy.Policy {
+	case models.RegistrationClosed:
+		utils.ErrorResponse(c, http.StatusForbidden, "注册已关闭")
+		return
+	case models.RegistrationInvite, models.RegistrationDomain:
+		if req.InviteToken != "" {
+			if invite, err = findInvite(req.InviteToken, user.Email); err != nil {
+				utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+				return
+			}
+			user.Role = invite.Role
+		} else if policy.Policy == models.RegistrationInvite {
+			utils.ErrorResponse(c, http.StatusForbidden, "注册需要邀请")
+			return
+		} else if !policy.AllowsEmail(user.Email) {
+			utils.ErrorResponse(c, http.StatusForbidden, "邮箱域名不允许注册")
+			return
+		} else if policy.VerifyEmail {
+			user.Status = models.StatusUnverified
+		}
+	}
+
 	// 检查用户名是否已存在
 	var existingUser models.User
 	if err := database.DB.Where("username = ?", user.Username).First(&existingUser).Error; err == nil {
@@ -104,31 +152,117 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "密码加密失败")
 		return
 	}
 	user.Password = string(hashedPassword)
 
-	// 设置默认值
-	if user.Role == "" {
-		user.Role = models.RoleUser
+	// 创建用户，同时兑换邀请或生成邮箱验证令牌；邀请被并发兑换时整体回滚
+	var verifyToken string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if invite != nil {
+			result := tx.Model(invite).Where("used_at IS NULL AND revoked_at IS NULL").
+				Updates(map[string]interface{}{"used_at": time.Now(), "used_by_id": user.ID})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrInviteInvalid
+			}
+		}
+		if user.Status == models.StatusUnverified {
+			plain, hash := auth.GenerateVerificationToken()
+			verification := models.EmailVerification{
+				TokenHash: hash,
+				UserID:    user.ID,
+				ExpiresAt: time.Now().Add(emailVerificationTTL),
+			}
+			if err := tx.Create(&verification).Error; err != nil {
+				return err
+			}
+			verifyToken = plain
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInviteInvalid) {
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		return
 	}
-	user.Status = models.StatusActive
-
-	// 创建用户
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "创建用户失败")
 		return
 	}
 
+	// 记录审计日志，未登录的操作以新用户为操作者
+	auditLog := models.AuditLog{
+		UserID:       &user.ID,
+		Action:       "register",
+		ResourceType: "user",
+		ResourceID:   user.ID,
+		Description:  fmt.Sprintf("用户 %s 注册（注册策略: %s）", user.Username, policy.Policy),
+		IP:           c.ClientIP(),
+		UserAgent:    c.GetHeader("User-Agent"),
+	}
+	if invite != nil {
+		auditLog.Action = "redeem_invite"
+		auditLog.ResourceType = "invite"
+		auditLog.ResourceID = invite.ID
+		auditLog.Description = fmt.Sprintf("用户 %s 使用注册邀请 %s 注册（角色: %s）", user.Username, invite.Prefix, user.Role)
+	}
+	if err := createAuditLog(c, database.DB, &auditLog); err != nil {
+		log.Printf("记录审计日志失败: %v", err)
+	}
+
+	if verifyToken != "" {
+		body := fmt.Sprintf("请在 %s 内打开以下地址验证邮箱，验证后即可登录 FlowForge：\n\n%s/verify-email?token=%s",
+			emailVerificationTTL, siteURL(c), verifyToken)
+		if err := notify.NewManager(config.GetConfig()).SendEmail(user.Email, "验证 FlowForge 注册邮箱", body); err != nil {
+			log.Printf("发送用户 %d 的验证邮件失败: %v", user.ID, err)
+		}
+	}
+
 	// 清除密码字段
 	user.Password = ""
 
 	utils.SuccessResponse(c, user)
 }
 
+// VerifyEmail 验证注册邮箱，验证后账户可以登录
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	var verification models.EmailVerification
+	if err := database.DB.Where("token_hash = ? AND used_at IS NULL", auth.HashAPIToken(req.Token)).
+		First(&verification).Error; err != nil || time.Now().After(verification.ExpiresAt) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "验证链接无效或已过期")
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&verification).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ? AND status = ?", verification.UserID, models.StatusUnverified).
+			Update("status", models.StatusActive).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "验证邮箱失败")
+		return
+	}
+	middleware.InvalidateUser(verification.UserID)
+
+	utils.SuccessResponse(c, gin.H{"message": "邮箱已验证"})
+}
+
 // RefreshToken 刷新令牌
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	// 从请求头获取当前令牌
@@ -161,6 +295,10 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// 检查用户状态
+	if user.Status == models.StatusUnverified {
+		utils.ErrorResponse(c, http.StatusForbidden, "邮箱尚未验证")
+		return
+	}
 	if user.Status != models.StatusActive {
 		utils.ErrorResponse(c, http.StatusForbidden, "用户账户已被禁用")
 		return
@@ -172,7 +310,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		roleID = 1
 	}
 	
-	expirationTime := time.Now().Add(time.Duration(cfg.JWT.ExpireTime) * time.Hour)
+	expirationTime := auth.Now().Add(time.Duration(cfg.JWT.ExpireTime) * time.Hour)
 	newToken, err := auth.GenerateToken(user.ID, user.Username, roleID, cfg.JWT.Secret, expirationTime)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "生成令牌失败")
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"flowforge/pkg/backup"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupHandler 数据库备份处理器，只有管理员可以访问
+type BackupHandler struct {
+	manager *backup.Manager
+}
+
+// NewBackupHandler 创建数据库备份处理器
+func NewBackupHandler(manager *backup.Manager) *BackupHandler {
+	return &BackupHandler{
+		manager: manager,
+	}
+}
+
+// backupItem 备份记录及其下载地址，只有成功的备份可以下载
+type backupItem struct {
+	models.Backup
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// CreateBackup 立即执行一次数据库备份，备份在后台执行，返回进行中的备份记录
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	currentID, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	record, err := h.manager.Start(currentID)
+	if errors.Is(err, backup.ErrBackupRunning) {
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "启动数据库备份失败")
+		return
+	}
+
+	recordAudit(c, "create_backup", "backup", record.ID, fmt.Sprintf("手动执行数据库备份 #%d", record.ID))
+
+	c.JSON(http.StatusAccepted, gin.H{"data": record})
+}
+
+// GetBackups 数据库备份记录，按时间倒序
+func (h *BackupHandler) GetBackups(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	var backups []models.Backup
+	if err := database.DB.Order("id DESC").Find(&backups).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取数据库备份失败")
+		return
+	}
+
+	items := make([]backupItem, len(backups))
+	for i := range backups {
+		items[i] = backupItem{Backup: backups[i]}
+		if backups[i].Status == models.BackupStatusSuccess {
+			items[i].DownloadURL = fmt.Sprintf("%s/api/v1/admin/backups/%d/download", siteURL(c), backups[i].ID)
+		}
+	}
+
+	utils.SuccessResponse(c, items)
+}
+
+// DownloadBackup 下载解密后的备份（gzip 压缩的数据库文件或 SQL），下载前校验备份文件的校验和
+func (h *BackupHandler) DownloadBackup(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的备份ID")
+		return
+	}
+	var record models.Backup
+	if err := database.DB.First(&record, id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "备份不存在")
+		return
+	}
+
+	reader, err := h.manager.Open(&record)
+	switch {
+	case errors.Is(err, backup.ErrBackupUnavailable):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "读取备份失败: "+err.Error())
+		return
+	}
+	defer reader.Close()
+
+	recordAudit(c, "download_backup", "backup", record.ID, fmt.Sprintf("下载数据库备份 #%d", record.ID))
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", backup.DownloadName(&record)))
+	c.Header("Content-Type", "application/gzip")
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("下载数据库备份 #%d 中断: %v", record.ID, err)
+	}
+}
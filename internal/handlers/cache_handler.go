@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"flowforge/pkg/cache"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheHandler 进程内缓存处理器
+type CacheHandler struct{}
+
+// NewCacheHandler 创建缓存处理器
+func NewCacheHandler() *CacheHandler {
+	return &CacheHandler{}
+}
+
+// GetCaches 查看各缓存的条目数与命中率，统计只包括本实例
+func (h *CacheHandler) GetCaches(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"disabled": cache.Disabled(),
+		"ttl_sec":  int(cache.TTL().Seconds()),
+		"caches":   cache.All(),
+	})
+}
+
+// FlushCaches 清空本实例的所有缓存，直接修改数据库后不必等待缓存过期
+func (h *CacheHandler) FlushCaches(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	cache.Flush()
+	recordAudit(c, "flush_caches", "cache", 0, "清空进程内缓存")
+
+	utils.SuccessResponse(c, nil)
+}
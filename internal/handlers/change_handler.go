@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowforge/internal/authctx"
+	"flowforge/internal/middleware"
+	"flowforge/pkg/changefeed"
+	"flowforge/pkg/config"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ChangeHandler 变更订阅处理器，界面轮询该接口后只重新获取有变更的资源
+type ChangeHandler struct {
+	config *config.ChangesConfig
+}
+
+// NewChangeHandler 创建变更订阅处理器
+func NewChangeHandler(cfg *config.ChangesConfig) *ChangeHandler {
+	return &ChangeHandler{config: cfg}
+}
+
+// changeVisibility 当前用户可见的变更：自己的通知，以及可以查看的项目中的项目、流水线、运行与部署；
+// 项目条件与各列表接口使用同一 projectAccess
+func changeVisibility(current *authctx.User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if current.IsAdmin() {
+			return db.Where("change_journals.user_id = ? OR change_journals.project_id <> 0", current.ID)
+		}
+		return db.Joins("LEFT JOIN projects ON projects.id = change_journals.project_id").
+			Where("change_journals.user_id = ? OR (change_journals.project_id <> 0 AND ?)", current.ID, projectAccess(current.ID, actionView))
+	}
+}
+
+// List 返回 since 之后的变更。since 为空时只返回当前位置的游标；wait 为长轮询的最长等待秒数，
+// 没有新的变更时等待至有变更写入或超时，不超过配置的上限。reset 为 true 时需重新获取全部列表
+func (h *ChangeHandler) List(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	wait, _ := strconv.Atoi(c.Query("wait"))
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > h.config.MaxWaitSeconds {
+		wait = h.config.MaxWaitSeconds
+	}
+	timeout := time.Duration(wait) * time.Second
+	// 等待期间不写入响应，不超过流式路由的空闲时间
+	if idle := middleware.StreamIdleTimeout(c); idle > 0 && timeout > idle/2 {
+		timeout = idle / 2
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-middleware.StreamDraining(c):
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	page, err := changefeed.Poll(ctx, c.Query("since"), changeVisibility(current), timeout)
+	if err != nil {
+		if errors.Is(err, changefeed.ErrInvalidCursor) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("获取变更失败: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取变更失败")
+		return
+	}
+	utils.SuccessResponse(c, page)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/changefeed"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// changeFixture web 项目由 owner 所有、viewer 为成员；secret 项目由 outsider 所有，viewer 不可见
+type changeFixture struct {
+	web, secret                 *models.Project
+	webPipeline, secretPipeline *models.Pipeline
+}
+
+func setupChangeTest(t *testing.T) *changeFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := changefeed.Register(database.DB, &config.ChangesConfig{RetentionHours: 24}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	database.InvalidateSettings()
+	t.Cleanup(database.InvalidateSettings)
+
+	f := &changeFixture{
+		web:    &models.Project{Name: "web", Slug: "web", UserID: ownerUser.ID},
+		secret: &models.Project{Name: "secret", Slug: "secret", UserID: outsiderUser.ID},
+	}
+	for _, project := range []*models.Project{f.web, f.secret} {
+		if err := database.DB.Create(project).Error; err != nil {
+			t.Fatal(err)
+		}
+		membershipCache.Invalidate(project.ID)
+		t.Cleanup(func() { membershipCache.Invalidate(project.ID) })
+	}
+	database.DB.Create(&models.ProjectMember{ProjectID: f.web.ID, UserID: viewerUser.ID, Role: models.ProjectRoleViewer})
+	f.webPipeline = &models.Pipeline{Name: "build", ProjectID: f.web.ID}
+	f.secretPipeline = &models.Pipeline{Name: "build", ProjectID: f.secret.ID}
+	database.DB.Create(f.webPipeline)
+	database.DB.Create(f.secretPipeline)
+
+	// 准备数据的变更视为早已写入，游标直接越过，只返回测试中的变更
+	database.DB.Model(&models.ChangeJournal{}).Where("1 = 1").Update("created_at", time.Now().Add(-time.Minute))
+	return f
+}
+
+// changesPage 变更接口返回的内容
+type changesPage struct {
+	Changes []changefeed.Change `json:"changes"`
+	Cursor  string              `json:"cursor"`
+	Reset   bool                `json:"reset"`
+}
+
+// pollChanges 以 user 身份请求变更接口，maxWait 为配置的长轮询上限（秒）
+func pollChanges(t *testing.T, user authctx.User, since string, wait, maxWait int) *changesPage {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	query := url.Values{"since": {since}, "wait": {fmt.Sprint(wait)}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/changes?"+query.Encode(), nil)
+	authctx.SetCurrentUser(c, user)
+	NewChangeHandler(&config.ChangesConfig{MaxWaitSeconds: maxWait}).List(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("变更接口返回 %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data changesPage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return &body.Data
+}
+
+// keys 变更的资源类型与ID
+func keys(changes []changefeed.Change) map[string]string {
+	keys := make(map[string]string, len(changes))
+	for _, change := range changes {
+		keys[fmt.Sprintf("%s %d", change.ResourceType, change.ID)] = change.Kind
+	}
+	return keys
+}
+
+// TestChangeFeedVisibility 不可见的项目中的项目、流水线、运行与部署变更，以及其他用户的通知，不出现在调用者的变更中；
+// 管理员可见全部项目的变更，但不包括其他用户的通知
+func TestChangeFeedVisibility(t *testing.T) {
+	f := setupChangeTest(t)
+	cursors := map[string]string{}
+	for _, user := range []authctx.User{viewerUser, outsiderUser, adminUser} {
+		cursors[user.Username] = pollChanges(t, user, "", 0, 0).Cursor
+	}
+
+	// secret 项目中的变更
+	database.DB.Model(f.secret).Update("description", "内部项目")
+	secretPipeline := &models.Pipeline{Name: "release", ProjectID: f.secret.ID}
+	database.DB.Create(secretPipeline)
+	secretRun := &models.PipelineRun{PipelineID: f.secretPipeline.ID, UserID: outsiderUser.ID, RunNumber: 1, Status: models.RunStatusSuccess, TriggerType: models.TriggerManual}
+	database.DB.Create(secretRun)
+	secretDeployment := &models.Deployment{ProjectID: f.secret.ID, UserID: outsiderUser.ID, Version: "v1", Status: "success"}
+	database.DB.Create(secretDeployment)
+	database.DB.Delete(secretDeployment)
+	outsiderNotice := &models.Notification{Title: "运行完成", UserID: outsiderUser.ID}
+	database.DB.Create(outsiderNotice)
+
+	// web 项目中的变更
+	database.DB.Model(f.webPipeline).Update("description", "构建")
+	webRun := &models.PipelineRun{PipelineID: f.webPipeline.ID, UserID: ownerUser.ID, RunNumber: 1, Status: models.RunStatusSuccess, TriggerType: models.TriggerManual}
+	database.DB.Create(webRun)
+	viewerNotice := &models.Notification{Title: "运行完成", UserID: viewerUser.ID}
+	database.DB.Create(viewerNotice)
+
+	web := map[string]string{
+		fmt.Sprintf("pipeline %d", f.webPipeline.ID): changefeed.KindUpdated,
+		fmt.Sprintf("pipeline_run %d", webRun.ID):    changefeed.KindCreated,
+	}
+	secret := map[string]string{
+		fmt.Sprintf("project %d", f.secret.ID):            changefeed.KindUpdated,
+		fmt.Sprintf("pipeline %d", secretPipeline.ID):     changefeed.KindCreated,
+		fmt.Sprintf("pipeline_run %d", secretRun.ID):      changefeed.KindCreated,
+		fmt.Sprintf("deployment %d", secretDeployment.ID): changefeed.KindDeleted,
+	}
+	merge := func(sets ...map[string]string) map[string]string {
+		merged := map[string]string{}
+		for _, set := range sets {
+			for key, kind := range set {
+				merged[key] = kind
+			}
+		}
+		return merged
+	}
+	tests := []struct {
+		user authctx.User
+		want map[string]string
+	}{
+		{viewerUser, merge(web, map[string]string{fmt.Sprintf("notification %d", viewerNotice.ID): changefeed.KindCreated})},
+		{outsiderUser, merge(secret, map[string]string{fmt.Sprintf("notification %d", outsiderNotice.ID): changefeed.KindCreated})},
+		{adminUser, merge(web, secret)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user.Username, func(t *testing.T) {
+			got := keys(pollChanges(t, tt.user, cursors[tt.user.Username], 0, 0).Changes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("变更为 %v，应为 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestChangeFeedLongPoll 长轮询期间不可见的变更不结束等待，可见的变更写入后立即返回；没有新的变更时等待到配置的上限后返回，
+// 刚写入的变更可能再次返回
+func TestChangeFeedLongPoll(t *testing.T) {
+	f := setupChangeTest(t)
+	cursor := pollChanges(t, viewerUser, "", 0, 0).Cursor
+
+	done := make(chan *changesPage, 1)
+	go func() { done <- pollChanges(t, viewerUser, cursor, 10, 10) }()
+
+	time.Sleep(200 * time.Millisecond)
+	database.DB.Create(&models.Pipeline{Name: "release", ProjectID: f.secret.ID})
+	select {
+	case page := <-done:
+		t.Fatalf("不可见的变更结束了长轮询: %v", keys(page.Changes))
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	pipeline := &models.Pipeline{Name: "release", ProjectID: f.web.ID}
+	landed := time.Now()
+	database.DB.Create(pipeline)
+	select {
+	case page := <-done:
+		if elapsed := time.Since(landed); elapsed > 500*time.Millisecond {
+			t.Errorf("可见的变更写入 %v 后长轮询才返回", elapsed)
+		}
+		want := map[string]string{fmt.Sprintf("pipeline %d", pipeline.ID): changefeed.KindCreated}
+		if got := keys(page.Changes); !reflect.DeepEqual(got, want) {
+			t.Errorf("长轮询返回 %v，应为 %v", got, want)
+		}
+		cursor = page.Cursor
+	case <-time.After(5 * time.Second):
+		t.Fatal("可见的变更写入后长轮询没有返回")
+	}
+
+	startedAt := time.Now()
+	page := pollChanges(t, viewerUser, cursor, 30, 1)
+	if elapsed := time.Since(startedAt); elapsed < time.Second || elapsed > 3*time.Second {
+		t.Errorf("没有变更时等待了 %v，应按上限等待约 1 秒", elapsed)
+	}
+	for key := range keys(page.Changes) {
+		if key != fmt.Sprintf("pipeline %d", pipeline.ID) {
+			t.Errorf("没有新的变更时返回了 %s", key)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/models"
+	"flowforge/pkg/retry"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CircuitBreakerHandler 基础设施请求熔断器处理器
+type CircuitBreakerHandler struct{}
+
+// NewCircuitBreakerHandler 创建熔断器处理器
+func NewCircuitBreakerHandler() *CircuitBreakerHandler {
+	return &CircuitBreakerHandler{}
+}
+
+// GetBreakers 查看各目标主机的熔断状态、连续失败次数与熔断次数
+func (h *CircuitBreakerHandler) GetBreakers(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, retry.Breakers())
+}
+
+// ResetBreakers 上游恢复后手动关闭熔断，不必等待冷却结束
+func (h *CircuitBreakerHandler) ResetBreakers(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	var req models.CircuitBreakerResetRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+	}
+
+	count := retry.Reset(req.Destination)
+	if req.Destination != "" && count == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "熔断目标不存在")
+		return
+	}
+
+	description := "重置全部熔断器"
+	if req.Destination != "" {
+		description = fmt.Sprintf("重置 %s 的熔断器", req.Destination)
+	}
+	recordAudit(c, "reset_circuit_breakers", "circuit_breaker", 0, description)
+
+	utils.SuccessResponse(c, gin.H{"reset": count})
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/internal/middleware"
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/auth"
+	"flowforge/pkg/client"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/git"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/retry"
+	"flowforge/pkg/scripts"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contractFixture 运行真实处理器的服务与以管理员 API 令牌、viewer 与 outsider 的 JWT 访问它的客户端
+type contractFixture struct {
+	server                  *httptest.Server
+	store                   *artifact.Store
+	admin, viewer, outsider *client.Client
+	build, slow             *models.Pipeline
+}
+
+// contractRoutes 按 pkg/api setupRoutes 注册客户端调用的流水线、运行、日志、进度与制品接口，
+// 认证使用同一个 middleware.Auth。项目与部署接口的处理器在本仓库中尚未实现，不在此注册
+func contractRoutes(cfg *config.Config, engine *pipeline.Engine, store *artifact.Store) *gin.Engine {
+	r := gin.New()
+	protected := r.Group("/api/v1")
+	protected.Use(middleware.Auth(cfg))
+
+	pipelineGroup := protected.Group("/pipelines")
+	pipelineHandler := NewPipelineHandler(engine)
+	pipelineGroup.GET("", pipelineHandler.GetPipelines)
+	pipelineGroup.GET("/:id", pipelineHandler.GetPipeline)
+	pipelineGroup.POST("/:id/run", pipelineHandler.RunPipeline)
+	pipelineGroup.GET("/:id/runs", pipelineHandler.GetPipelineRuns)
+	pipelineGroup.GET("/:id/runs/:runId", pipelineHandler.GetPipelineRun)
+	pipelineGroup.POST("/:id/runs/:runId/cancel", pipelineHandler.CancelPipelineRun)
+	pipelineGroup.GET("/:id/runs/:runId/logs", pipelineHandler.GetPipelineRunLogs)
+	pipelineGroup.GET("/:id/runs/:runId/progress", pipelineHandler.StreamRunProgress)
+
+	artifactHandler := NewArtifactHandler(store)
+	pipelineGroup.GET("/:id/runs/:runId/artifacts", artifactHandler.GetArtifacts)
+	pipelineGroup.GET("/:id/runs/:runId/artifacts/:artifactId", artifactHandler.DownloadArtifact)
+	return r
+}
+
+func setupContractTest(t *testing.T) *contractFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	cfg := &config.Config{
+		App: config.ApplicationConfig{DataPath: filepath.Join(dir, "data")},
+		Database: config.DatabaseConfig{
+			Type:         "sqlite",
+			Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+			MaxIdleConns: 1,
+			MaxOpenConns: 1,
+			LogLevel:     "silent",
+		},
+		Deploy: config.DeployConfig{
+			WorkspaceDir:         filepath.Join(dir, "workspace"),
+			Timeout:              60,
+			MaxConcurrent:        4,
+			MaxScriptExecutions:  4,
+			RetainWorkspaceHours: 1,
+		},
+		Storage: config.StorageConfig{Local: config.LocalConfig{Path: filepath.Join(dir, "storage")}},
+		JWT:     config.JWTConfig{Secret: "contract-secret"},
+	}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	for _, user := range []*models.User{
+		{ID: adminUser.ID, Username: adminUser.Username, Email: "admin@example.com", Role: models.RoleAdmin},
+		{ID: ownerUser.ID, Username: ownerUser.Username, Email: "owner@example.com", Role: models.RoleUser},
+		{ID: viewerUser.ID, Username: viewerUser.Username, Email: "viewer@example.com", Role: models.RoleUser},
+		{ID: outsiderUser.ID, Username: outsiderUser.Username, Email: "outsider@example.com", Role: models.RoleUser},
+	} {
+		user.Password, user.Status = "x", models.StatusActive
+		if err := database.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+		middleware.InvalidateUser(user.ID)
+	}
+	project := &models.Project{Name: "web", Slug: "web", RepoURL: "https://git.example/web.git", Branch: "main", UserID: ownerUser.ID}
+	if err := database.DB.Create(project).Error; err != nil {
+		t.Fatal(err)
+	}
+	membershipCache.Invalidate(project.ID)
+	t.Cleanup(func() { membershipCache.Invalidate(project.ID) })
+	database.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: viewerUser.ID, Role: models.ProjectRoleViewer})
+
+	f := &contractFixture{
+		build: &models.Pipeline{Name: "build", ProjectID: project.ID, Trigger: models.TriggerManual,
+			Config: "stages:\n  - name: build\n    steps:\n      - name: compile\n        type: script\n        config:\n          script: \"echo compiling; echo done\"\n"},
+		slow: &models.Pipeline{Name: "slow", ProjectID: project.ID, Trigger: models.TriggerManual,
+			Config: "stages:\n  - name: build\n    steps:\n      - name: wait\n        type: script\n        config:\n          script: \"sleep 30\"\n"},
+	}
+	for _, p := range []*models.Pipeline{f.build, f.slow} {
+		if err := database.DB.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := pipeline.NewEngine(cfg, scripts.NewManager(cfg), git.NewManager(cfg))
+	t.Cleanup(engine.Shutdown)
+	// 没有 git_clone 步骤时脚本在已存在的项目工作区中执行
+	if err := os.MkdirAll(isolation.WorkspaceDir(cfg.App.DataPath, project.ID), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := artifact.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.store = store
+	f.server = httptest.NewServer(contractRoutes(cfg, engine, store))
+	t.Cleanup(f.server.Close)
+
+	token, hash := auth.GenerateAPIToken()
+	if err := database.DB.Create(&models.APIToken{Name: "contract", TokenHash: hash, Prefix: token[:8], Scope: models.APITokenScopeAdmin, UserID: adminUser.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.admin = newContractClient(t, f.server.URL, token)
+	f.viewer = newContractClient(t, f.server.URL, userJWT(t, cfg, viewerUser.ID, viewerUser.Username))
+	f.outsider = newContractClient(t, f.server.URL, userJWT(t, cfg, outsiderUser.ID, outsiderUser.Username))
+	return f
+}
+
+// contractRetry 不重试，错误响应直接返回
+var contractRetry = retry.Policy{Attempts: 1}
+
+// newContractClient 以 token 访问测试服务的客户端
+func newContractClient(t *testing.T, baseURL, token string) *client.Client {
+	t.Helper()
+	c, err := client.New(baseURL, token, client.WithTimeout(10*time.Second), client.WithRetry(contractRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// userJWT 普通用户的登录令牌
+func userJWT(t *testing.T, cfg *config.Config, userID uint, username string) string {
+	t.Helper()
+	token, err := auth.GenerateToken(userID, username, 2, cfg.JWT.Secret, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// TestClientContract 客户端对真实处理器触发运行、等待结束、读取日志与进度、下载制品，请求与响应的格式与服务端一致
+func TestClientContract(t *testing.T) {
+	f := setupContractTest(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pipelines, err := f.admin.ListPipelines(ctx, client.ListOptions{PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pipelines.Total != 2 || len(pipelines.Items) != 2 {
+		t.Errorf("流水线列表为 %+v，应有 2 条", pipelines)
+	}
+	got, err := f.admin.GetPipeline(ctx, f.build.ID)
+	if err != nil || got.Name != "build" || got.ProjectID != f.build.ProjectID {
+		t.Fatalf("流水线为 %+v（%v）", got, err)
+	}
+
+	run, err := f.admin.TriggerRun(ctx, f.build.ID, models.RunPipelineRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID == 0 || run.PipelineID != f.build.ID {
+		t.Fatalf("触发返回的运行为 %+v", run)
+	}
+	var lines []string
+	finished, err := f.admin.FollowLogs(ctx, f.build.ID, run.ID, 50*time.Millisecond, func(page []string) error {
+		lines = append(lines, page...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status != models.RunStatusSuccess {
+		t.Fatalf("运行状态为 %s: %s", finished.Status, finished.ErrorMsg)
+	}
+	if logs := strings.Join(lines, "\n"); !strings.Contains(logs, "compiling") || !strings.Contains(logs, "done") {
+		t.Errorf("读取的日志为:\n%s", logs)
+	}
+	page, err := f.admin.GetLogs(ctx, f.build.ID, run.ID, 0, 1)
+	if err != nil || len(page.Logs) != 1 || page.Total != len(lines) {
+		t.Errorf("日志分页为 %+v（%v），应返回 1 行，共 %d 行", page, err, len(lines))
+	}
+
+	runs, err := f.admin.ListRuns(ctx, f.build.ID, client.RunListOptions{})
+	if err != nil || runs.Total != 1 || len(runs.Items) != 1 || runs.Items[0].ID != run.ID {
+		t.Errorf("运行记录为 %+v（%v）", runs, err)
+	}
+
+	var events []string
+	if err := f.viewer.StreamProgress(ctx, f.build.ID, run.ID, func(event client.ProgressEvent) error {
+		events = append(events, event.Type+" "+event.Status)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0] != client.ProgressEventFinished+" "+models.RunStatusSuccess {
+		t.Errorf("进度事件为 %q，应只有 finished success", events)
+	}
+
+	stored, err := f.store.Put(ctx, run.ID, "app.tar.gz", strings.NewReader("release payload"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := f.admin.ListArtifacts(ctx, f.build.ID, run.ID)
+	if err != nil || len(artifacts) != 1 || artifacts[0].ID != stored.ID || artifacts[0].Name != "app.tar.gz" {
+		t.Errorf("制品列表为 %+v（%v）", artifacts, err)
+	}
+	var buf bytes.Buffer
+	if n, err := f.admin.DownloadArtifact(ctx, f.build.ID, run.ID, stored.ID, &buf); err != nil || n != int64(buf.Len()) || buf.String() != "release payload" {
+		t.Errorf("下载 %d 字节 %q（%v）", n, buf.String(), err)
+	}
+}
+
+// TestClientContractCancel 客户端取消执行中的运行，等待到的最终状态为 cancelled
+func TestClientContractCancel(t *testing.T) {
+	f := setupContractTest(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	run, err := f.admin.TriggerRun(ctx, f.slow.ID, models.RunPipelineRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		current, err := f.admin.GetRun(ctx, f.slow.ID, run.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Status == models.RunStatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("运行未开始执行，状态为 %s", current.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := f.admin.CancelRun(ctx, f.slow.ID, run.ID, "发布窗口已关闭"); err != nil {
+		t.Fatal(err)
+	}
+	finished, err := f.admin.WaitForRun(ctx, f.slow.ID, run.ID, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finished.Status != models.RunStatusCancelled {
+		t.Errorf("取消后运行状态为 %s，应为 cancelled", finished.Status)
+	}
+}
+
+// TestClientContractErrors 服务端的错误响应转换为对应的分类错误与错误码
+func TestClientContractErrors(t *testing.T) {
+	f := setupContractTest(t)
+	ctx := context.Background()
+
+	_, err := f.admin.GetPipeline(ctx, 999)
+	if !errors.Is(err, client.ErrNotFound) || !client.HasCode(err, "pipeline_not_found") {
+		t.Errorf("不存在的流水线返回 %v，应为 ErrNotFound 与 pipeline_not_found", err)
+	}
+	// viewer 可以查看但不能触发，outsider 看不到项目
+	if _, err := f.viewer.GetPipeline(ctx, f.build.ID); err != nil {
+		t.Errorf("viewer 查看流水线返回 %v", err)
+	}
+	if _, err := f.viewer.TriggerRun(ctx, f.build.ID, models.RunPipelineRequest{}); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("viewer 触发运行返回 %v，应为 ErrNotFound", err)
+	}
+	if _, err := f.outsider.GetRun(ctx, f.build.ID, 1); !errors.Is(err, client.ErrNotFound) || !client.HasCode(err, "run_not_found") {
+		t.Errorf("outsider 查看运行返回 %v，应为 ErrNotFound 与 run_not_found", err)
+	}
+
+	invalid := newContractClient(t, f.server.URL, auth.APITokenPrefix+"revoked")
+	if _, err := invalid.ListPipelines(ctx, client.ListOptions{}); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("无效的令牌返回 %v，应为 ErrUnauthorized", err)
+	}
+	if _, err := f.admin.GetLogs(ctx, f.build.ID, 999, 0, 0); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("不存在的运行的日志返回 %v，应为 ErrNotFound", err)
+	}
+}
+
+// TestContractRoutesMatchServer 测试注册的每个接口在 pkg/api setupRoutes 中以相同的方法、路径与处理器注册，
+// 服务端改动路由时测试随之失败，避免契约测试覆盖的路由与实际路由不一致
+func TestContractRoutesMatchServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	source, err := os.ReadFile(filepath.Join("..", "..", "pkg", "api", "server.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := strings.ReplaceAll(string(source), "\r\n", "\n")
+
+	// 处理器名称形如 flowforge/internal/handlers.(*PipelineHandler).GetPipelineRuns-fm
+	handlerName := regexp.MustCompile(`\.\(\*(\w+)\)\.(\w+)-fm$`)
+	for _, route := range contractRoutes(&config.Config{}, nil, nil).Routes() {
+		m := handlerName.FindStringSubmatch(route.Handler)
+		if m == nil {
+			t.Fatalf("无法解析处理器 %s", route.Handler)
+		}
+		group := strings.TrimPrefix(route.Path, "/api/v1/pipelines")
+		verb := route.Method[:1] + strings.ToLower(route.Method[1:])
+		handler := strings.ToLower(m[1][:1]) + m[1][1:] + "." + m[2]
+		pattern := fmt.Sprintf(`(pipelineGroup\.%s\(|pipelineGroup, http\.Method%s, )"%s", %s\)`,
+			route.Method, verb, regexp.QuoteMeta(group), regexp.QuoteMeta(handler))
+		if !regexp.MustCompile(pattern).MatchString(server) {
+			t.Errorf("setupRoutes 中没有 %s %s → %s", route.Method, route.Path, handler)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"flowforge/pkg/cloudcred"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCloudCredentials 获取项目部署扮演的云平台角色，项目所有者与管理员可以查看
+func (h *ProjectHandler) GetCloudCredentials(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	var credentials []models.CloudCredential
+	if err := h.db.Where("project_id = ?", project.ID).Order("environment").Find(&credentials).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	utils.SuccessResponse(c, credentials)
+}
+
+// CreateCloudCredential 为项目的部署环境配置扮演的角色。服务器的基础凭证可以扮演的角色对所有项目相同，
+// 因此只有管理员可以配置；角色的信任策略可以用会话标签 flowforge:project-id 限制项目
+func (h *ProjectHandler) CreateCloudCredential(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	credential := models.CloudCredential{ProjectID: project.ID}
+	if !bindCloudCredential(c, &credential) {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.CloudCredential{}).Where("project_id = ? AND environment = ?", project.ID, credential.Environment).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "该环境已配置云平台角色")
+		return
+	}
+	if err := h.db.Create(&credential).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存云平台角色失败")
+		return
+	}
+
+	recordAudit(c, "create_cloud_credential", "project", project.ID,
+		fmt.Sprintf("为项目 %s 的环境 %s 配置云平台角色 %s", project.Name, environmentLabel(credential.Environment), credential.RoleARN))
+
+	utils.SuccessResponse(c, credential)
+}
+
+// UpdateCloudCredential 修改项目部署扮演的角色，只有管理员可以修改
+func (h *ProjectHandler) UpdateCloudCredential(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	var credential models.CloudCredential
+	if err := h.db.Where("project_id = ?", project.ID).First(&credential, c.Param("credential_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "云平台角色不存在")
+		return
+	}
+	before := credential
+	if !bindCloudCredential(c, &credential) {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.CloudCredential{}).
+		Where("project_id = ? AND environment = ? AND id <> ?", project.ID, credential.Environment, credential.ID).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "该环境已配置云平台角色")
+		return
+	}
+	if err := h.db.Save(&credential).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存云平台角色失败")
+		return
+	}
+
+	recordAuditChange(c, "update_cloud_credential", "project", project.ID,
+		fmt.Sprintf("修改项目 %s 的环境 %s 的云平台角色", project.Name, environmentLabel(credential.Environment)),
+		before.RoleARN, credential.RoleARN)
+
+	utils.SuccessResponse(c, credential)
+}
+
+// DeleteCloudCredential 删除项目部署扮演的角色，只有管理员可以删除
+func (h *ProjectHandler) DeleteCloudCredential(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	var credential models.CloudCredential
+	if err := h.db.Where("project_id = ?", project.ID).First(&credential, c.Param("credential_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "云平台角色不存在")
+		return
+	}
+	if err := h.db.Delete(&credential).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除云平台角色失败")
+		return
+	}
+
+	recordAudit(c, "delete_cloud_credential", "project", project.ID,
+		fmt.Sprintf("删除项目 %s 的环境 %s 的云平台角色 %s", project.Name, environmentLabel(credential.Environment), credential.RoleARN))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// bindCloudCredential 读取请求并按云平台校验角色配置
+func bindCloudCredential(c *gin.Context, credential *models.CloudCredential) bool {
+	var req models.CloudCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return false
+	}
+
+	credential.Environment = strings.TrimSpace(req.Environment)
+	credential.Provider = req.Provider
+	credential.RoleARN = strings.TrimSpace(req.RoleARN)
+	credential.Region = strings.TrimSpace(req.Region)
+	credential.ExternalID = req.ExternalID
+	credential.DurationSeconds = req.DurationSeconds
+
+	provider, err := cloudcred.Get(credential.Provider)
+	if err == nil {
+		err = provider.Validate(credential)
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "云平台角色无效: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// environmentLabel 审计描述中的环境名，未指定环境的配置用于所有没有单独配置的环境
+func environmentLabel(environment string) string {
+	if environment == "" {
+		return "（默认）"
+	}
+	return environment
+}
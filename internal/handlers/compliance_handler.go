@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"flowforge/pkg/compliance"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ComplianceHandler 用户数据导出与删除处理器
+type ComplianceHandler struct {
+	manager *compliance.Manager
+}
+
+// NewComplianceHandler 创建用户数据导出与删除处理器
+func NewComplianceHandler(manager *compliance.Manager) *ComplianceHandler {
+	return &ComplianceHandler{
+		manager: manager,
+	}
+}
+
+// ExportUser 导出用户的全部数据：首次请求返回确认令牌，携带令牌再次请求后异步生成导出包
+func (h *ComplianceHandler) ExportUser(c *gin.Context) {
+	h.handle(c, compliance.KindExport)
+}
+
+// DeleteUserData 删除用户的个人数据：首次请求返回确认令牌，携带令牌再次请求后异步执行
+func (h *ComplianceHandler) DeleteUserData(c *gin.Context) {
+	h.handle(c, compliance.KindDelete)
+}
+
+// GetJob 查询导出或删除任务的进度
+func (h *ComplianceHandler) GetJob(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	job, ok := h.manager.Get(c.Param("id"))
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "任务不存在")
+		return
+	}
+
+	utils.SuccessResponse(c, job)
+}
+
+// DownloadExport 下载已生成的导出包
+func (h *ComplianceHandler) DownloadExport(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	path, ok := h.manager.FilePath(c.Param("id"))
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "导出文件不存在或尚未生成完成")
+		return
+	}
+
+	recordAudit(c, "download_user_export", "compliance_job", 0, "下载用户数据导出包 "+c.Param("id"))
+
+	c.FileAttachment(path, filepath.Base(path))
+}
+
+// handle 两步确认：未携带令牌时生成确认令牌，携带令牌时启动任务
+func (h *ComplianceHandler) handle(c *gin.Context, kind string) {
+	currentID, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Unscoped().First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+		return
+	}
+	if kind == compliance.KindDelete && user.ID == currentID {
+		utils.ErrorResponse(c, http.StatusBadRequest, "不能删除自己的数据")
+		return
+	}
+
+	var req models.ComplianceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+	}
+
+	action, description := "export_user_data", fmt.Sprintf("导出用户 %s 的数据", user.Username)
+	if kind == compliance.KindDelete {
+		action, description = "delete_user_data", fmt.Sprintf("删除用户 %s 的个人数据", user.Username)
+	}
+
+	if req.ConfirmationToken == "" {
+		confirmation := h.manager.RequestConfirmation(kind, user.ID, currentID)
+		recordAudit(c, "request_"+action, "user", user.ID, "申请"+description)
+		utils.SuccessResponse(c, confirmation)
+		return
+	}
+
+	job, err := h.manager.Start(kind, user.ID, currentID, req.ConfirmationToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, compliance.ErrConfirmationInvalid):
+			utils.ErrorResponse(c, http.StatusForbidden, "确认令牌无效或已过期")
+		case errors.Is(err, compliance.ErrJobRunning):
+			utils.ErrorResponse(c, http.StatusConflict, "该用户已有进行中的任务")
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "启动任务失败")
+		}
+		return
+	}
+
+	recordAudit(c, action, "user", user.ID, description+"，任务 "+job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{"data": job})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mutexGroupPattern 互斥组名称格式
+var mutexGroupPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// maxProjectConcurrentRuns 项目并发运行数上限的最大值
+const maxProjectConcurrentRuns = 100
+
+// ConcurrencyHandler 项目并发策略处理器
+type ConcurrencyHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewConcurrencyHandler 创建项目并发策略处理器
+func NewConcurrencyHandler(engine *pipeline.Engine) *ConcurrencyHandler {
+	return &ConcurrencyHandler{
+		engine: engine,
+	}
+}
+
+// GetPolicy 获取项目并发策略及各互斥组中的流水线
+func (h *ConcurrencyHandler) GetPolicy(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	members, err := mutexGroupMembers(project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"max_concurrent_runs": project.MaxConcurrentRuns,
+		"mutex_groups":        project.MutexGroupList(),
+		"members":             members,
+	})
+}
+
+// UpdatePolicy 更新项目并发策略，对之后出队的运行生效；仍被流水线使用的互斥组不能删除
+func (h *ConcurrencyHandler) UpdatePolicy(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var req models.ConcurrencyPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if req.MaxConcurrentRuns < 0 || req.MaxConcurrentRuns > maxProjectConcurrentRuns {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("无效的并发策略: 并发运行数上限应在 0 到 %d 之间", maxProjectConcurrentRuns))
+		return
+	}
+
+	groups := make([]string, 0, len(req.MutexGroups))
+	seen := make(map[string]bool, len(req.MutexGroups))
+	for _, group := range req.MutexGroups {
+		group = strings.TrimSpace(group)
+		if !mutexGroupPattern.MatchString(group) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "互斥组名称无效: "+group)
+			return
+		}
+		if !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+
+	members, err := mutexGroupMembers(project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	for group, pipelines := range members {
+		if !seen[group] {
+			utils.ErrorResponse(c, http.StatusConflict, fmt.Sprintf("互斥组仍被流水线使用: %s（%s）", group, strings.Join(pipelines, ", ")))
+			return
+		}
+	}
+
+	before := projectSettingsText(project)
+	if err := database.DB.Model(project).Updates(map[string]interface{}{
+		"max_concurrent_runs": req.MaxConcurrentRuns,
+		"mutex_groups":        strings.Join(groups, ","),
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存并发策略失败")
+		return
+	}
+
+	project.MaxConcurrentRuns = req.MaxConcurrentRuns
+	project.MutexGroups = strings.Join(groups, ",")
+
+	// 放宽限制后排队的运行可能已可以执行
+	h.engine.ApplyConcurrencyPolicy()
+
+	recordAuditChange(c, "update_concurrency_policy", "project", project.ID,
+		fmt.Sprintf("更新项目 %s 的并发策略: 并发运行数上限 %d，互斥组 [%s]", project.Name, req.MaxConcurrentRuns, strings.Join(groups, ", ")),
+		before, projectSettingsText(project))
+
+	utils.SuccessResponse(c, gin.H{
+		"max_concurrent_runs": req.MaxConcurrentRuns,
+		"mutex_groups":        groups,
+		"members":             members,
+	})
+}
+
+// loadProject 加载当前用户可访问的项目
+func (h *ConcurrencyHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// mutexGroupMembers 项目中各互斥组包含的流水线名称
+func mutexGroupMembers(projectID uint) (map[string][]string, error) {
+	var pipelines []models.Pipeline
+	if err := database.DB.Select("id", "name", "mutex_group").
+		Where("project_id = ? AND mutex_group <> ''", projectID).Order("id").Find(&pipelines).Error; err != nil {
+		return nil, err
+	}
+
+	members := make(map[string][]string)
+	for _, p := range pipelines {
+		members[p.MutexGroup] = append(members[p.MutexGroup], p.Name)
+	}
+	return members, nil
+}
+
+// validateMutexGroup 校验流水线加入的互斥组已在项目中定义
+func validateMutexGroup(c *gin.Context, project *models.Project, group string) bool {
+	if group == "" || project.HasMutexGroup(group) {
+		return true
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, "项目未定义该互斥组: "+group)
+	return false
+}
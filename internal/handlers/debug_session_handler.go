@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowforge/internal/authctx"
+	"flowforge/internal/middleware"
+	"flowforge/pkg/database"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// DebugSessionHandler 失败运行的调试终端处理器，连接数与其他 WebSocket 连接一起限制
+type DebugSessionHandler struct {
+	engine *pipeline.Engine
+	ws     *WebSocketHandler
+}
+
+// NewDebugSessionHandler 创建调试终端处理器
+func NewDebugSessionHandler(engine *pipeline.Engine, ws *WebSocketHandler) *DebugSessionHandler {
+	return &DebugSessionHandler{engine: engine, ws: ws}
+}
+
+// debugControl 客户端以文本消息发送的控制消息；键盘输入以二进制消息发送
+type debugControl struct {
+	Type string `json:"type"` // resize
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
+
+// debugSessionChecks 打开调试终端的前置条件：只有项目所有者可以打开，要求开启多租户隔离，
+// 运行在调试时限内且没有其他打开的终端
+func debugSessionChecks(engine *pipeline.Engine, project *models.Project, run *models.PipelineRun, current *authctx.User) []accessCheck {
+	owner := accessCheck{Name: "debug_owner", Passed: project.UserID == current.ID, Blocking: true, status: http.StatusForbidden, Detail: "你是项目所有者"}
+	if !owner.Passed {
+		owner.Detail = "只有项目所有者可以打开调试终端"
+	}
+
+	sandbox := accessCheck{Name: "debug_sandbox", Passed: isolation.Enabled(), Blocking: true, status: http.StatusForbidden, Detail: "多租户隔离已开启，终端以项目系统用户运行"}
+	if !sandbox.Passed {
+		sandbox.Detail = pipeline.ErrDebugSandboxDisabled.Error()
+	}
+
+	available := accessCheck{Name: "debug_available", Passed: engine.DebugAvailable(run), Blocking: true, status: http.StatusConflict}
+	if available.Passed {
+		available.Detail = fmt.Sprintf("调试终端可用至 %s", run.DebugUntil.Format("2006-01-02 15:04:05"))
+	} else {
+		available.Detail = pipeline.ErrDebugUnavailable.Error()
+	}
+
+	single := accessCheck{Name: "debug_single_session", Passed: !engine.DebugSessionOpen(run.ID), Blocking: true, status: http.StatusConflict, Detail: "没有打开的调试终端"}
+	if !single.Passed {
+		single.Detail = pipeline.ErrDebugSessionActive.Error()
+	}
+	return []accessCheck{owner, sandbox, available, single}
+}
+
+// HandleDebugSession 打开失败运行的调试终端，cols、rows 为初始的终端大小。
+// 服务端以二进制消息发送终端输出，客户端以二进制消息发送键盘输入、以文本消息发送 {"type":"resize"} 调整大小；
+// 到达调试时限时服务端结束终端并关闭连接，连接断开时终端随之结束
+func (h *DebugSessionHandler) HandleDebugSession(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline.Project").First(&run, c.Param("run_id")).Error; err != nil || !projectPermitted(current, run.Pipeline.ProjectID, actionView) {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+	project := run.Pipeline.Project
+	if failed := firstFailure(debugSessionChecks(h.engine, &project, &run, current)); failed != nil {
+		utils.ErrorResponse(c, failed.status, failed.Detail)
+		return
+	}
+
+	if status, msg := h.ws.acquire(current.ID); status != 0 {
+		utils.ErrorResponse(c, status, msg)
+		return
+	}
+	defer h.ws.release(current.ID)
+
+	cols, _ := strconv.ParseUint(c.Query("cols"), 10, 16)
+	rows, _ := strconv.ParseUint(c.Query("rows"), 10, 16)
+	session, err := h.engine.StartDebugSession(&run, &project, current.Username, uint16(cols), uint16(rows))
+	if err != nil {
+		switch {
+		case errors.Is(err, pipeline.ErrDebugSandboxDisabled):
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		case errors.Is(err, pipeline.ErrDebugUnavailable), errors.Is(err, pipeline.ErrDebugSessionActive):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			log.Printf("打开流水线运行 %d 的调试终端失败: %v", run.ID, err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "打开调试终端失败")
+		}
+		return
+	}
+	defer session.Close()
+
+	recordAudit(c, "start_debug_session", "pipeline_run", run.ID,
+		fmt.Sprintf("打开流水线 %s 运行 #%d 的调试终端，最迟 %s 结束", run.Pipeline.Name, run.ID, session.Deadline.Format("2006-01-02 15:04:05")))
+
+	conn, err := h.ws.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if h.ws.config.MaxMessageKB > 0 {
+		conn.SetReadLimit(int64(h.ws.config.MaxMessageKB) << 10)
+	}
+	h.serve(c, conn, session)
+}
+
+// serve 转发终端输出与键盘输入，直到终端结束或连接断开
+func (h *DebugSessionHandler) serve(c *gin.Context, conn *websocket.Conn, session *pipeline.DebugSession) {
+	idle := middleware.StreamIdleTimeout(c)
+	if idle <= 0 {
+		idle = defaultStreamIdle
+	}
+	extend := func() error {
+		return conn.SetReadDeadline(time.Now().Add(idle))
+	}
+	extend()
+	conn.SetPongHandler(func(string) error { return extend() })
+
+	// 输出只在该协程中发送，ping 与关闭帧通过可以并发调用的 WriteControl 发送
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := session.Read(buf)
+			if n > 0 {
+				conn.SetWriteDeadline(time.Now().Add(idle))
+				if werr := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					session.Close()
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// 连接断开时结束终端
+	go func() {
+		defer session.Close()
+		for {
+			kind, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			extend()
+			if kind == websocket.BinaryMessage {
+				if _, err := session.Write(data); err != nil {
+					return
+				}
+				continue
+			}
+			var control debugControl
+			if json.Unmarshal(data, &control) == nil && control.Type == "resize" {
+				session.Resize(control.Cols, control.Rows)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(idle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-session.Done():
+			select {
+			case <-outputDone:
+			case <-time.After(time.Second):
+			}
+			reason := "调试终端已结束"
+			if !time.Now().Before(session.Deadline) {
+				reason = "调试时限已到"
+			}
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason), time.Now().Add(time.Second))
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(idle/2)); err != nil {
+				return
+			}
+		case <-middleware.StreamDraining(c):
+			session.Close()
+			<-session.Done()
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseServiceRestart, "服务器重启"), time.Now().Add(time.Second))
+			return
+		}
+	}
+}
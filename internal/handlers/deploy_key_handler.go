@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/git"
+	"flowforge/pkg/models"
+	"flowforge/pkg/ssh"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DeployKeyHandler 项目部署密钥处理器
+type DeployKeyHandler struct {
+	sshManager *ssh.Manager
+	gitManager *git.Manager
+}
+
+// NewDeployKeyHandler 创建部署密钥处理器
+func NewDeployKeyHandler(sshManager *ssh.Manager, gitManager *git.Manager) *DeployKeyHandler {
+	return &DeployKeyHandler{
+		sshManager: sshManager,
+		gitManager: gitManager,
+	}
+}
+
+// GetDeployKey 获取项目部署密钥（仅公钥信息）
+func (h *DeployKeyHandler) GetDeployKey(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	result := gin.H{"active": nil, "pending": nil}
+	if project.DeployKeyID != nil {
+		var key models.SSHKey
+		if err := database.DB.First(&key, *project.DeployKeyID).Error; err == nil {
+			result["active"] = key
+		}
+	}
+	if project.PendingDeployKeyID != nil {
+		var key models.SSHKey
+		if err := database.DB.First(&key, *project.PendingDeployKeyID).Error; err == nil {
+			result["pending"] = key
+		}
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+// CreateDeployKey 为项目生成部署密钥
+func (h *DeployKeyHandler) CreateDeployKey(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	if project.DeployKeyID != nil {
+		utils.ErrorResponse(c, http.StatusConflict, "项目已存在部署密钥，请使用轮换接口")
+		return
+	}
+
+	key, warning, err := h.generateKey(c, project, models.SSHKeyStatusActive)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := database.DB.Model(project).Update("deploy_key_id", key.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "关联部署密钥失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"deploy_key": key,
+		"registered": key.ProviderKeyID != "",
+		"warning":    warning,
+	})
+}
+
+// RotateDeployKey 轮换部署密钥：生成新密钥，旧密钥保留到确认为止
+func (h *DeployKeyHandler) RotateDeployKey(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	if project.DeployKeyID == nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "项目尚未创建部署密钥")
+		return
+	}
+
+	// 重复轮换时撤销上一个未确认的密钥
+	if project.PendingDeployKeyID != nil {
+		h.revokeKey(c, project, *project.PendingDeployKeyID)
+	}
+
+	key, warning, err := h.generateKey(c, project, models.SSHKeyStatusPending)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := database.DB.Model(project).Update("pending_deploy_key_id", key.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "关联部署密钥失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"deploy_key": key,
+		"registered": key.ProviderKeyID != "",
+		"warning":    warning,
+	})
+}
+
+// ConfirmDeployKey 确认轮换：新密钥生效，旧密钥撤销
+func (h *DeployKeyHandler) ConfirmDeployKey(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	if project.PendingDeployKeyID == nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "没有待确认的部署密钥")
+		return
+	}
+
+	// 复制旧密钥ID：更新项目时 GORM 会写入原有的指针，直接保留指针会撤销刚确认的新密钥
+	var oldKeyID *uint
+	if project.DeployKeyID != nil {
+		id := *project.DeployKeyID
+		oldKeyID = &id
+	}
+	newKeyID := *project.PendingDeployKeyID
+
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SSHKey{}).Where("id = ?", newKeyID).
+			Update("status", models.SSHKeyStatusActive).Error; err != nil {
+			return err
+		}
+		return tx.Model(project).Updates(map[string]interface{}{
+			"deploy_key_id":         newKeyID,
+			"pending_deploy_key_id": nil,
+		}).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "确认部署密钥失败")
+		return
+	}
+
+	if oldKeyID != nil {
+		h.revokeKey(c, project, *oldKeyID)
+	}
+
+	utils.SuccessResponse(c, gin.H{"deploy_key_id": newKeyID})
+}
+
+// RevokeDeployKey 撤销项目部署密钥（包括待确认的密钥）
+func (h *DeployKeyHandler) RevokeDeployKey(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	if project.DeployKeyID == nil && project.PendingDeployKeyID == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目没有部署密钥")
+		return
+	}
+
+	for _, keyID := range []*uint{project.DeployKeyID, project.PendingDeployKeyID} {
+		if keyID != nil {
+			h.revokeKey(c, project, *keyID)
+		}
+	}
+
+	if err := database.DB.Model(project).Updates(map[string]interface{}{
+		"deploy_key_id":         nil,
+		"pending_deploy_key_id": nil,
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "撤销部署密钥失败")
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// loadProject 加载项目并校验当前用户为项目所有者或管理员
+func (h *DeployKeyHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// generateKey 生成并保存一个部署密钥，配置了平台令牌时自动注册
+func (h *DeployKeyHandler) generateKey(c *gin.Context, project *models.Project, status string) (*models.SSHKey, string, error) {
+	cfg := config.GetConfig()
+	comment := fmt.Sprintf("flowforge-project-%d-%d", project.ID, time.Now().Unix())
+
+	privateKey, publicKey, fingerprint, err := h.sshManager.GetClient().GenerateEd25519KeyPair(comment)
+	if err != nil {
+		return nil, "", fmt.Errorf("生成部署密钥失败: %w", err)
+	}
+
+	encrypted, err := utils.EncryptString(privateKey, cfg.Security.EncryptionKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("加密部署密钥失败: %w", err)
+	}
+
+	projectID := project.ID
+	key := models.SSHKey{
+		Name:        comment,
+		PublicKey:   publicKey,
+		PrivateKey:  encrypted,
+		Encrypted:   true,
+		Purpose:     models.SSHKeyPurposeDeployKey,
+		KeyType:     "ed25519",
+		Fingerprint: fingerprint,
+		ProjectID:   &projectID,
+		UserID:      project.UserID,
+		Status:      status,
+	}
+
+	// 自动注册失败不影响密钥生成，用户仍可手动添加公钥
+	var warning string
+	gitClient := h.gitManager.GetClient()
+	if gitClient.CanRegisterDeployKey(project.RepoURL) {
+		providerKeyID, err := gitClient.RegisterDeployKey(c.Request.Context(), project.RepoURL, comment, publicKey)
+		if err != nil {
+			warning = err.Error()
+		} else {
+			key.ProviderKeyID = providerKeyID
+		}
+	}
+
+	if err := database.DB.Create(&key).Error; err != nil {
+		return nil, "", fmt.Errorf("保存部署密钥失败: %w", err)
+	}
+
+	return &key, warning, nil
+}
+
+// revokeKey 撤销单个部署密钥，并尽量从托管平台移除
+func (h *DeployKeyHandler) revokeKey(c *gin.Context, project *models.Project, keyID uint) {
+	var key models.SSHKey
+	if err := database.DB.First(&key, keyID).Error; err != nil {
+		return
+	}
+
+	if key.ProviderKeyID != "" {
+		if err := h.gitManager.GetClient().RemoveDeployKey(c.Request.Context(), project.RepoURL, key.ProviderKeyID); err != nil {
+			log.Printf("从托管平台移除部署密钥 %d 失败: %v", key.ID, err)
+		}
+	}
+
+	now := time.Now()
+	database.DB.Model(&key).Updates(map[string]interface{}{
+		"status":     models.SSHKeyStatusRevoked,
+		"revoked_at": &now,
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/git"
+	"flowforge/pkg/models"
+	"flowforge/pkg/ssh"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deployKeyFlow 以项目所有者身份调用部署密钥接口
+type deployKeyFlow struct {
+	t         *testing.T
+	handler   *DeployKeyHandler
+	projectID uint
+	userKey   *models.SSHKey
+}
+
+func newDeployKeyFlow(t *testing.T) *deployKeyFlow {
+	t.Helper()
+	pipeline := setupAccessTest(t)
+	cfg := &config.Config{}
+	cfg.Security.EncryptionKey = "test-encryption-key"
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	// 项目同时配置了所有者的SSH密钥，部署密钥不可用时回退到该密钥
+	userKey := &models.SSHKey{Name: "owner", PrivateKey: "private", UserID: ownerUser.ID}
+	if err := database.DB.Create(userKey).Error; err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Model(&models.Project{}).Where("id = ?", pipeline.ProjectID).Update("ssh_key_id", userKey.ID)
+
+	return &deployKeyFlow{
+		t:         t,
+		handler:   NewDeployKeyHandler(ssh.NewManager(cfg), git.NewManager(cfg)),
+		projectID: pipeline.ProjectID,
+		userKey:   userKey,
+	}
+}
+
+// call 调用接口，返回状态码
+func (f *deployKeyFlow) call(handler gin.HandlerFunc) int {
+	f.t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/projects/%d/deploy-key", f.projectID), nil)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(f.projectID)}}
+	authctx.SetCurrentUser(c, ownerUser)
+	handler(c)
+	return w.Code
+}
+
+// project 重新加载项目及其密钥，与引擎克隆代码前的加载方式一致
+func (f *deployKeyFlow) project() *models.Project {
+	f.t.Helper()
+	var project models.Project
+	if err := models.WithPrivateKey(database.DB).Preload("DeployKey").Preload("SSHKey").First(&project, f.projectID).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	return &project
+}
+
+// gitKey Git操作选中的密钥ID
+func (f *deployKeyFlow) gitKey() uint {
+	f.t.Helper()
+	project := f.project()
+	key := git.SelectKey(project, project.SSHKey)
+	if key == nil {
+		return 0
+	}
+	return key.ID
+}
+
+func keyStatus(t *testing.T, id uint) string {
+	t.Helper()
+	var key models.SSHKey
+	if err := database.DB.First(&key, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return key.Status
+}
+
+// TestDeployKeyRotation 轮换的新密钥在确认后才用于Git操作，旧密钥在确认时撤销
+func TestDeployKeyRotation(t *testing.T) {
+	f := newDeployKeyFlow(t)
+	if got := f.gitKey(); got != f.userKey.ID {
+		t.Fatalf("没有部署密钥时应使用SSH密钥，实际为 %d", got)
+	}
+
+	if code := f.call(f.handler.CreateDeployKey); code != http.StatusOK {
+		t.Fatalf("创建部署密钥返回 %d", code)
+	}
+	first := *f.project().DeployKeyID
+	if got := f.gitKey(); got != first {
+		t.Fatalf("部署密钥应优先于SSH密钥，实际为 %d", got)
+	}
+	if code := f.call(f.handler.CreateDeployKey); code != http.StatusConflict {
+		t.Errorf("已有部署密钥时再次创建应返回 409，实际为 %d", code)
+	}
+
+	if code := f.call(f.handler.RotateDeployKey); code != http.StatusOK {
+		t.Fatalf("轮换返回 %d", code)
+	}
+	pending := *f.project().PendingDeployKeyID
+	if got := f.gitKey(); got != first {
+		t.Errorf("确认前应继续使用旧密钥 %d，实际为 %d", first, got)
+	}
+	if status := keyStatus(t, pending); status != models.SSHKeyStatusPending {
+		t.Errorf("新密钥状态 %s，应为 pending", status)
+	}
+
+	// 重复轮换撤销上一个未确认的密钥
+	if code := f.call(f.handler.RotateDeployKey); code != http.StatusOK {
+		t.Fatalf("再次轮换返回 %d", code)
+	}
+	second := *f.project().PendingDeployKeyID
+	if status := keyStatus(t, pending); status != models.SSHKeyStatusRevoked {
+		t.Errorf("被取代的待确认密钥状态 %s，应为 revoked", status)
+	}
+
+	if code := f.call(f.handler.ConfirmDeployKey); code != http.StatusOK {
+		t.Fatalf("确认返回 %d", code)
+	}
+	project := f.project()
+	if project.DeployKeyID == nil || *project.DeployKeyID != second || project.PendingDeployKeyID != nil {
+		t.Fatalf("确认后生效的密钥应为 %d，实际为 %v（待确认 %v）", second, project.DeployKeyID, project.PendingDeployKeyID)
+	}
+	if got := f.gitKey(); got != second {
+		t.Errorf("确认后应使用新密钥 %d，实际为 %d", second, got)
+	}
+	if status := keyStatus(t, first); status != models.SSHKeyStatusRevoked {
+		t.Errorf("旧密钥状态 %s，应为 revoked", status)
+	}
+	if code := f.call(f.handler.ConfirmDeployKey); code != http.StatusBadRequest {
+		t.Errorf("没有待确认的密钥时确认应返回 400，实际为 %d", code)
+	}
+}
+
+// TestDeployKeyRevoke 撤销部署密钥（包括轮换中的新密钥）后Git操作回退到项目的SSH密钥
+func TestDeployKeyRevoke(t *testing.T) {
+	f := newDeployKeyFlow(t)
+	f.call(f.handler.CreateDeployKey)
+	f.call(f.handler.RotateDeployKey)
+	project := f.project()
+	active, pending := *project.DeployKeyID, *project.PendingDeployKeyID
+
+	if code := f.call(f.handler.RevokeDeployKey); code != http.StatusOK {
+		t.Fatalf("撤销返回 %d", code)
+	}
+	project = f.project()
+	if project.DeployKeyID != nil || project.PendingDeployKeyID != nil {
+		t.Fatalf("撤销后项目不应再关联部署密钥: %v, %v", project.DeployKeyID, project.PendingDeployKeyID)
+	}
+	for _, id := range []uint{active, pending} {
+		if status := keyStatus(t, id); status != models.SSHKeyStatusRevoked {
+			t.Errorf("密钥 %d 状态 %s，应为 revoked", id, status)
+		}
+	}
+	if got := f.gitKey(); got != f.userKey.ID {
+		t.Errorf("撤销后应回退到SSH密钥 %d，实际为 %d", f.userKey.ID, got)
+	}
+	if code := f.call(f.handler.RevokeDeployKey); code != http.StatusNotFound {
+		t.Errorf("没有部署密钥时撤销应返回 404，实际为 %d", code)
+	}
+}
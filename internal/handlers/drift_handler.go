@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DriftHandler 部署目标漂移处理器
+type DriftHandler struct {
+	checker *deploy.DriftChecker
+}
+
+// NewDriftHandler 创建部署目标漂移处理器
+func NewDriftHandler(checker *deploy.DriftChecker) *DriftHandler {
+	return &DriftHandler{
+		checker: checker,
+	}
+}
+
+// driftTarget 部署目标的漂移状态
+type driftTarget struct {
+	models.DeploymentManifest
+	Report *deploy.DriftReport `json:"report,omitempty"`
+}
+
+// GetDrift 获取项目各部署目标最近一次检查的漂移状态
+func (h *DriftHandler) GetDrift(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	manifests, err := deploy.LatestManifests(project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询部署清单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"status":  deploy.ProjectDriftStatus(project.ID),
+		"targets": toDriftTargets(manifests),
+	})
+}
+
+// CheckDrift 立即检查项目各部署目标，只读取远程文件哈希，不修改目标
+func (h *DriftHandler) CheckDrift(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	manifests, err := h.checker.CheckProject(c.Request.Context(), project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "检查部署漂移失败")
+		return
+	}
+
+	recordAudit(c, "check_drift", "project", project.ID, fmt.Sprintf("检查项目 %s 的部署漂移", project.Name))
+
+	utils.SuccessResponse(c, gin.H{
+		"status":  deploy.ProjectDriftStatus(project.ID),
+		"targets": toDriftTargets(manifests),
+	})
+}
+
+// loadProject 加载项目并校验当前用户为项目所有者或管理员
+func (h *DriftHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// toDriftTargets 附带解析后的检查结果
+func toDriftTargets(manifests []models.DeploymentManifest) []driftTarget {
+	targets := make([]driftTarget, 0, len(manifests))
+	for _, m := range manifests {
+		target := driftTarget{DeploymentManifest: m}
+		if m.DriftDetail != "" {
+			var report deploy.DriftReport
+			if json.Unmarshal([]byte(m.DriftDetail), &report) == nil {
+				target.Report = &report
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
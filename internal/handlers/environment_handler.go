@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/interlock"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEnvironments 获取项目环境变量，密钥变量不返回值
+func (h *ProjectHandler) GetEnvironments(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	var envs []models.Environment
+	if err := h.db.Where("project_id = ?", project.ID).Find(&envs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取环境变量失败")
+		return
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Key < envs[j].Key })
+	for i := range envs {
+		if envs[i].IsSecret {
+			envs[i].Value = redact.Placeholder
+		}
+	}
+
+	utils.SuccessResponse(c, envs)
+}
+
+// CreateEnvironment 创建项目环境变量，同一项目内变量名唯一
+func (h *ProjectHandler) CreateEnvironment(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	var req models.EnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if !h.validEnvKey(c, project.ID, req.Key, 0) {
+		return
+	}
+
+	env := models.Environment{
+		Key:         req.Key,
+		Value:       req.Value,
+		Description: req.Description,
+		IsSecret:    req.IsSecret,
+		ProjectID:   project.ID,
+	}
+	if err := h.db.Create(&env).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建环境变量失败")
+		return
+	}
+
+	recordAudit(c, "create_environment", "project", project.ID,
+		fmt.Sprintf("创建项目 %s 的环境变量 %s", project.Name, env.Key))
+
+	if env.IsSecret {
+		env.Value = redact.Placeholder
+	}
+	utils.SuccessResponse(c, env)
+}
+
+// UpdateEnvironment 更新项目环境变量，密钥变量的 value 为空时保持原值
+func (h *ProjectHandler) UpdateEnvironment(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	var env models.Environment
+	if err := h.db.Where("project_id = ?", project.ID).First(&env, c.Param("env_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "环境变量不存在")
+		return
+	}
+
+	var req models.EnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if !h.validEnvKey(c, project.ID, req.Key, env.ID) {
+		return
+	}
+
+	previous := env.Key
+	env.Key = req.Key
+	env.Description = req.Description
+	if req.Value != "" || !req.IsSecret || !env.IsSecret {
+		env.Value = req.Value
+	}
+	env.IsSecret = req.IsSecret
+	if err := h.db.Save(&env).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新环境变量失败")
+		return
+	}
+
+	description := fmt.Sprintf("更新项目 %s 的环境变量 %s", project.Name, env.Key)
+	if previous != env.Key {
+		description = fmt.Sprintf("更新项目 %s 的环境变量 %s，原名称 %s", project.Name, env.Key, previous)
+	}
+	recordAudit(c, "update_environment", "project", project.ID, description)
+
+	if env.IsSecret {
+		env.Value = redact.Placeholder
+	}
+	utils.SuccessResponse(c, env)
+}
+
+// DeleteEnvironment 删除项目环境变量
+func (h *ProjectHandler) DeleteEnvironment(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	var env models.Environment
+	if err := h.db.Where("project_id = ?", project.ID).First(&env, c.Param("env_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "环境变量不存在")
+		return
+	}
+	if err := h.db.Delete(&env).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除环境变量失败")
+		return
+	}
+
+	recordAudit(c, "delete_environment", "project", project.ID,
+		fmt.Sprintf("删除项目 %s 的环境变量 %s", project.Name, env.Key))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// DeleteEnvironments 删除项目的全部环境变量，已删除的变量不保留。需要两步确认，回传的名称为项目名称
+func (h *ProjectHandler) DeleteEnvironments(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	if !confirmDestructive(c, interlock.OperationDeleteEnvironments, "project", project.ID, project.Name, func() (interlock.Summary, error) {
+		return environmentDeletionSummary(h.db, project)
+	}) {
+		return
+	}
+
+	result := h.db.Unscoped().Where("project_id = ?", project.ID).Delete(&models.Environment{})
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除环境变量失败")
+		return
+	}
+
+	recordAudit(c, interlock.OperationDeleteEnvironments, "project", project.ID,
+		fmt.Sprintf("删除项目 %s 的全部环境变量（%d 个）", project.Name, result.RowsAffected))
+
+	utils.SuccessResponse(c, gin.H{"deleted": result.RowsAffected})
+}
+
+// validEnvKey 校验变量名合法且未被项目内的其他变量使用，excludeID 为正在更新的变量自身
+func (h *ProjectHandler) validEnvKey(c *gin.Context, projectID uint, key string, excludeID uint) bool {
+	if !models.IsValidEnvName(key) {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("%s: %q", models.ErrInvalidEnvName.Error(), key))
+		return false
+	}
+
+	var count int64
+	h.db.Model(&models.Environment{}).Where(&models.Environment{ProjectID: projectID, Key: key}).
+		Where("id <> ?", excludeID).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "环境变量已存在")
+		return false
+	}
+	return true
+}
+
+// validateEnvReferences 检查保存的配置引用的环境变量。开启 strict_env 时存在未定义的引用即拒绝保存，
+// 错误响应中按步骤列出；否则返回检查结果，随保存结果提示
+func validateEnvReferences(c *gin.Context, project *models.Project, req *models.CreatePipelineRequest) ([]models.StepEnvFindings, bool) {
+	config := storedConfig(req)
+	if config == nil {
+		return nil, true
+	}
+
+	var ignore []string
+	if req.EnvIgnore != "" {
+		ignore = strings.Split(req.EnvIgnore, ",")
+	}
+	findings := pipeline.CheckEnvReferences(project, config, ignore)
+	if len(findings) == 0 || !req.StrictEnv {
+		return findings, true
+	}
+
+	message := "流水线配置引用了未定义的环境变量"
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":        i18n.Translate(i18n.FromContext(c), message),
+		"code":         i18n.Code(message),
+		"env_findings": findings,
+	})
+	return nil, false
+}
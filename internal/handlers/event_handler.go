@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/events"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventHandler 系统事件接收端处理器
+type EventHandler struct {
+	dispatcher *events.Dispatcher
+}
+
+// NewEventHandler 创建系统事件接收端处理器
+func NewEventHandler(dispatcher *events.Dispatcher) *EventHandler {
+	return &EventHandler{
+		dispatcher: dispatcher,
+	}
+}
+
+// GetSinks 查看各事件接收端的投递进度、积压事件数与重放状态
+func (h *EventHandler) GetSinks(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	statuses, err := h.dispatcher.Status()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+
+	utils.SuccessResponse(c, statuses)
+}
+
+// Replay 接收端故障恢复后重新投递一段时间内的事件，后台执行，通过 GetSinks 查看进度
+func (h *EventHandler) Replay(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	var req models.EventReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if !req.From.Before(req.To) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "重放的开始时间应早于结束时间")
+		return
+	}
+
+	total, err := h.dispatcher.Replay(req.Sink, req.From, req.To)
+	switch {
+	case errors.Is(err, events.ErrSinkNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "事件接收端不存在")
+		return
+	case errors.Is(err, events.ErrReplayRunning):
+		utils.ErrorResponse(c, http.StatusConflict, "事件接收端已有正在进行的重放")
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "重放事件失败")
+		return
+	}
+
+	recordAudit(c, "replay_events", "event_sink", 0,
+		fmt.Sprintf("重放事件到接收端 %s: %s 至 %s，共 %d 条", req.Sink, req.From.Format("2006-01-02 15:04:05"), req.To.Format("2006-01-02 15:04:05"), total))
+
+	c.JSON(http.StatusAccepted, gin.H{"data": gin.H{"sink": req.Sink, "total": total}})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/export"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportFormat 列表接口请求的导出格式（?format=csv|ndjson 或 Accept 头），为空时返回 JSON 列表
+func exportFormat(c *gin.Context) string {
+	return export.Negotiate(c.Query("format"), c.GetHeader("Accept"))
+}
+
+// dateRange 列表按创建日期筛选的范围：from、to 为 YYYY-MM-DD（服务器时区），to 当天包含在内
+type dateRange struct {
+	from *time.Time
+	to   *time.Time
+}
+
+// parseDateRange 解析 from、to 查询参数，格式错误时返回 400
+func parseDateRange(c *gin.Context) (dateRange, bool) {
+	var dates dateRange
+	for _, item := range []struct {
+		param  string
+		target **time.Time
+	}{{"from", &dates.from}, {"to", &dates.to}} {
+		value := c.Query(item.param)
+		if value == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "日期格式无效，应为 YYYY-MM-DD")
+			return dates, false
+		}
+		*item.target = &day
+	}
+	return dates, true
+}
+
+// apply 按 column 筛选日期范围
+func (d dateRange) apply(query *gorm.DB, column string) *gorm.DB {
+	if d.from != nil {
+		query = query.Where(column+" >= ?", *d.from)
+	}
+	if d.to != nil {
+		query = query.Where(column+" < ?", d.to.AddDate(0, 0, 1))
+	}
+	return query
+}
+
+// exportSpec 一次列表导出：格式、文件名中的资源名与日期范围、CSV 表头
+type exportSpec struct {
+	format   string
+	resource string
+	dates    dateRange
+	header   []string
+}
+
+// tableColumns 以表名限定的导出列，用于 Select
+func tableColumns(table string, columns []string) []string {
+	qualified := make([]string, len(columns))
+	for i, column := range columns {
+		qualified[i] = table + "." + column
+	}
+	return qualified
+}
+
+// writeExport 逐行导出与 JSON 列表相同筛选条件的记录：不分页，用 Rows 逐行读取，不在内存中保留结果集；
+// 记录数超过导出上限时返回 400。rows 在计数后为查询加上导出的列与排序，prepare 在写出前处理每一行，如脱敏
+func writeExport[T any, P interface {
+	*T
+	export.Row
+}](c *gin.Context, query *gorm.DB, spec exportSpec, rows func(*gorm.DB) *gorm.DB, prepare func(P)) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	if err := export.CheckRows(total); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursor, err := query.Scopes(rows).Rows()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	defer cursor.Close()
+
+	filename := export.Filename(spec.resource, spec.dates.from, spec.dates.to, spec.format)
+	c.Header("Content-Type", export.ContentType(spec.format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	writer, err := export.NewWriter(c.Writer, spec.format, spec.header, c.Writer)
+	if err != nil {
+		return
+	}
+	for cursor.Next() {
+		row := P(new(T))
+		if err := database.DB.ScanRows(cursor, row); err != nil {
+			log.Printf("导出 %s 时读取记录失败: %v", spec.resource, err)
+			return
+		}
+		if prepare != nil {
+			prepare(row)
+		}
+		// 写出失败时客户端已断开
+		if err := writer.Write(row); err != nil {
+			return
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("导出 %s 失败: %v", spec.resource, err)
+	}
+	writer.Flush()
+}
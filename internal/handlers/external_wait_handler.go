@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExternalWaitHandler external_wait 步骤的回调与管理处理器
+type ExternalWaitHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewExternalWaitHandler 创建外部等待处理器
+func NewExternalWaitHandler(engine *pipeline.Engine) *ExternalWaitHandler {
+	return &ExternalWaitHandler{
+		engine: engine,
+	}
+}
+
+// Callback 接收外部系统的回调（通过签名校验，无需JWT验证）；重复回调返回已记录的结果，不会再次改变步骤状态
+func (h *ExternalWaitHandler) Callback(c *gin.Context) {
+	wait, err := h.engine.ExternalWaitByToken(c.Param("token"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "外部等待不存在")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "读取请求体失败")
+		return
+	}
+	if err := pipeline.VerifyCallback(wait, c.GetHeader(pipeline.CallbackSignatureHeader), body); err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "回调签名校验失败")
+		return
+	}
+
+	var req models.ExternalCallbackRequest
+	if err := json.Unmarshal(body, &req); err != nil || (req.Status != "success" && req.Status != "failure") {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	result, applied, err := h.engine.ResolveExternalWait(wait.ID, req, "callback")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新外部等待失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"status":    result.Status,
+		"duplicate": !applied,
+	})
+}
+
+// GetExternalWaits 列出仍在等待外部回调的步骤（管理员）
+func (h *ExternalWaitHandler) GetExternalWaits(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	waits, err := h.engine.ExternalWaits()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询外部等待失败")
+		return
+	}
+
+	utils.SuccessResponse(c, waits)
+}
+
+// CompleteExternalWait 手动完成或失败卡住的外部等待（管理员）
+func (h *ExternalWaitHandler) CompleteExternalWait(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var req models.ExternalCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误: "+err.Error())
+		return
+	}
+
+	var wait models.ExternalWait
+	if err := database.DB.First(&wait, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "外部等待不存在")
+		return
+	}
+
+	result, applied, err := h.engine.ResolveExternalWait(wait.ID, req, current.Username)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新外部等待失败")
+		return
+	}
+	if !applied {
+		utils.ErrorResponse(c, http.StatusConflict, "外部等待已结束")
+		return
+	}
+
+	recordAudit(c, "complete_external_wait", "pipeline_run", wait.PipelineRunID,
+		fmt.Sprintf("手动将运行 #%d 的外部等待 %s 标记为 %s", wait.PipelineRunID, wait.Description, req.Status))
+
+	utils.SuccessResponse(c, result)
+}
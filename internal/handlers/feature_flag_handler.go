@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/cache"
+	"flowforge/pkg/database"
+	"flowforge/pkg/flags"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeatureFlagHandler 功能开关处理器
+type FeatureFlagHandler struct{}
+
+// NewFeatureFlagHandler 创建功能开关处理器
+func NewFeatureFlagHandler() *FeatureFlagHandler {
+	return &FeatureFlagHandler{}
+}
+
+// GetFeatureFlags 列出所有功能开关的默认值、覆盖值与推广情况（管理员）
+func (h *FeatureFlagHandler) GetFeatureFlags(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	statuses, err := flags.Statuses(c.Request.Context())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询功能开关失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"flags":         statuses,
+		"cache_ttl_sec": int(cache.TTL().Seconds()),
+	})
+}
+
+// SetFeatureFlag 设置功能开关的全局、项目或用户覆盖值，enabled 为 null 时删除覆盖值（管理员）
+func (h *FeatureFlagHandler) SetFeatureFlag(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var req models.SetFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	name := c.Param("name")
+	if req.ProjectID != 0 && req.UserID != 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "不能同时设置项目与用户覆盖")
+		return
+	}
+	scope, targetID := "全局", req.ProjectID
+	switch {
+	case req.UserID != 0:
+		var user models.User
+		if err := database.DB.First(&user, req.UserID).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+			return
+		}
+		scope, targetID = "用户 "+user.Username, req.UserID
+	case req.ProjectID != 0:
+		var project models.Project
+		if err := database.DB.First(&project, req.ProjectID).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+			return
+		}
+		scope = "项目 " + project.Name
+	}
+
+	var err error
+	if req.UserID != 0 {
+		err = flags.SetUser(name, req.UserID, req.Enabled, &current.ID)
+	} else {
+		err = flags.Set(name, req.ProjectID, req.Enabled, &current.ID)
+	}
+	if err != nil {
+		if errors.Is(err, flags.ErrUnknownFlag) {
+			utils.ErrorResponse(c, http.StatusNotFound, "未定义的功能开关: "+name)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存功能开关失败")
+		return
+	}
+
+	value := "恢复默认"
+	if req.Enabled != nil {
+		value = fmt.Sprintf("%t", *req.Enabled)
+	}
+	recordAudit(c, "set_feature_flag", "feature_flag", targetID,
+		fmt.Sprintf("设置功能开关 %s（%s）: %s", name, scope, value))
+
+	utils.SuccessResponse(c, gin.H{
+		"name":       name,
+		"project_id": req.ProjectID,
+		"user_id":    req.UserID,
+		"enabled":    flags.EnabledFor(c.Request.Context(), name, req.ProjectID, req.UserID),
+	})
+}
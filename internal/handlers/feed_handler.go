@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"flowforge/pkg/auth"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/feed"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxFeedEntries 订阅中的条目数上限，只保留最近结束的运行
+const maxFeedEntries = 50
+
+// feedRunStatuses 订阅包含的运行状态，只有已结束的运行
+var feedRunStatuses = []string{models.RunStatusSuccess, models.RunStatusFailed, models.RunStatusCancelled, models.RunStatusSkipped}
+
+// FeedHandler 运行结果 Atom 订阅处理器。阅读器无法携带认证头，订阅通过查询参数中的订阅令牌认证，
+// 令牌只能读取订阅，可见范围与接口相同：管理员可订阅所有项目，其他用户只能订阅自己的项目
+type FeedHandler struct{}
+
+// NewFeedHandler 创建运行结果订阅处理器
+func NewFeedHandler() *FeedHandler {
+	return &FeedHandler{}
+}
+
+// GetFeedToken 获取当前用户的订阅令牌，不含令牌明文；没有有效令牌时返回 null
+func (h *FeedHandler) GetFeedToken(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var tokens []models.FeedToken
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL", current.ID).Limit(1).Find(&tokens).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取订阅令牌失败")
+		return
+	}
+	if len(tokens) == 0 {
+		utils.SuccessResponse(c, nil)
+		return
+	}
+
+	utils.SuccessResponse(c, tokens[0])
+}
+
+// CreateFeedToken 生成订阅令牌，已有的令牌同时撤销；令牌明文只在本次响应中返回
+func (h *FeedHandler) CreateFeedToken(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if current.APITokenID != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "API令牌不能创建新的令牌")
+		return
+	}
+
+	plain, hash := auth.GenerateFeedToken()
+	token := models.FeedToken{
+		TokenHash: hash,
+		Prefix:    plain[:len(auth.FeedTokenPrefix)+4],
+		UserID:    current.ID,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeFeedTokens(tx, current.ID); err != nil {
+			return err
+		}
+		return tx.Create(&token).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建订阅令牌失败")
+		return
+	}
+
+	recordAudit(c, "create_feed_token", "feed_token", token.ID, fmt.Sprintf("创建订阅令牌 %s", token.Prefix))
+
+	utils.SuccessResponse(c, gin.H{
+		"token":      plain,
+		"feed_token": token,
+	})
+}
+
+// RevokeFeedToken 撤销当前用户的订阅令牌，使用该令牌的订阅地址随即失效
+func (h *FeedHandler) RevokeFeedToken(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := revokeFeedTokens(database.DB, current.ID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "撤销订阅令牌失败")
+		return
+	}
+
+	recordAudit(c, "revoke_feed_token", "feed_token", 0, "撤销订阅令牌")
+
+	utils.SuccessResponse(c, gin.H{"message": "订阅令牌已撤销"})
+}
+
+// ProjectRuns 项目所有流水线已结束运行的 Atom 订阅，可用 status 过滤（如 status=failed）
+func (h *FeedHandler) ProjectRuns(c *gin.Context) {
+	user, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	statuses, ok := feedStatuses(c)
+	if !ok {
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, c.Param("id")).Error; err != nil ||
+		(user.Role != models.RoleAdmin && project.UserID != user.ID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+
+	query := database.DB.Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Where("pipelines.project_id = ? AND pipelines.deleted_at IS NULL", project.ID)
+	runs, ok := feedRuns(c, query, statuses)
+	if !ok {
+		return
+	}
+
+	base := siteURL(c)
+	h.write(c, feed.New(
+		fmt.Sprintf("%s/projects/%d/runs", base, project.ID),
+		fmt.Sprintf("项目 %s 的运行结果", project.Name),
+		"FlowForge",
+		fmt.Sprintf("%s/api/v1/projects/%d/runs.atom", base, project.ID),
+		fmt.Sprintf("%s/projects/%d", base, project.ID),
+		feedUpdated(runs, project.UpdatedAt),
+		runEntries(base, project.ID, runs),
+	))
+}
+
+// PipelineRuns 流水线已结束运行的 Atom 订阅，可用 status 过滤（如 status=failed）
+func (h *FeedHandler) PipelineRuns(c *gin.Context) {
+	user, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	statuses, ok := feedStatuses(c)
+	if !ok {
+		return
+	}
+
+	var pipeline models.Pipeline
+	if err := database.DB.Preload("Project").First(&pipeline, c.Param("id")).Error; err != nil ||
+		(user.Role != models.RoleAdmin && pipeline.Project.UserID != user.ID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线不存在")
+		return
+	}
+
+	runs, ok := feedRuns(c, database.DB.Where("pipeline_runs.pipeline_id = ?", pipeline.ID), statuses)
+	if !ok {
+		return
+	}
+
+	base := siteURL(c)
+	h.write(c, feed.New(
+		fmt.Sprintf("%s/pipelines/%d/runs", base, pipeline.ID),
+		fmt.Sprintf("流水线 %s 的运行结果", pipeline.Name),
+		"FlowForge",
+		fmt.Sprintf("%s/api/v1/pipelines/%d/runs.atom", base, pipeline.ID),
+		fmt.Sprintf("%s/pipelines/%d", base, pipeline.ID),
+		feedUpdated(runs, pipeline.UpdatedAt),
+		runEntries(base, pipeline.ProjectID, runs),
+	))
+}
+
+// authenticate 校验查询参数中的订阅令牌，返回令牌所属的启用用户
+func (h *FeedHandler) authenticate(c *gin.Context) (*models.User, bool) {
+	plain := c.Query("token")
+	var token models.FeedToken
+	if !strings.HasPrefix(plain, auth.FeedTokenPrefix) ||
+		database.DB.Where("token_hash = ? AND revoked_at IS NULL", auth.HashAPIToken(plain)).First(&token).Error != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "无效的订阅令牌")
+		return nil, false
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, token.UserID).Error; err != nil || user.Status != models.StatusActive {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "无效的订阅令牌")
+		return nil, false
+	}
+
+	now := time.Now()
+	database.DB.Model(&token).Updates(map[string]interface{}{
+		"last_used_at": &now,
+		"last_used_ip": c.ClientIP(),
+	})
+	return &user, true
+}
+
+// write 输出 Atom 订阅
+func (h *FeedHandler) write(c *gin.Context, f *feed.Feed) {
+	body, err := f.Marshal()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成订阅失败")
+		return
+	}
+	c.Data(http.StatusOK, feed.ContentType, body)
+}
+
+// revokeFeedTokens 撤销用户所有有效的订阅令牌
+func revokeFeedTokens(db *gorm.DB, userID uint) error {
+	return db.Model(&models.FeedToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// feedStatuses 解析 status 查询参数（逗号分隔），为空时包含所有已结束状态
+func feedStatuses(c *gin.Context) ([]string, bool) {
+	if c.Query("status") == "" {
+		return feedRunStatuses, true
+	}
+	var statuses []string
+	for _, status := range strings.Split(c.Query("status"), ",") {
+		status = strings.TrimSpace(status)
+		if !slices.Contains(feedRunStatuses, status) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "订阅只支持已结束的运行状态")
+			return nil, false
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, true
+}
+
+// feedRuns 查询最近结束的运行，按结束时间倒序，最多 maxFeedEntries 条
+func feedRuns(c *gin.Context, query *gorm.DB, statuses []string) ([]models.PipelineRun, bool) {
+	var runs []models.PipelineRun
+	if err := query.Preload("Pipeline").
+		Where("pipeline_runs.status IN ? AND pipeline_runs.end_time IS NOT NULL", statuses).
+		Order("pipeline_runs.end_time DESC, pipeline_runs.id DESC").
+		Limit(maxFeedEntries).Find(&runs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取运行记录失败")
+		return nil, false
+	}
+	return runs, true
+}
+
+// feedUpdated 订阅的更新时间：最近结束的运行的结束时间，没有运行时为 fallback
+func feedUpdated(runs []models.PipelineRun, fallback time.Time) time.Time {
+	if len(runs) > 0 {
+		return *runs[0].EndTime
+	}
+	return fallback
+}
+
+// runEntries 运行对应的订阅条目，标题与摘要按项目的脱敏规则脱敏
+func runEntries(base string, projectID uint, runs []models.PipelineRun) []feed.Entry {
+	redactor := redact.ForProject(projectID)
+	entries := make([]feed.Entry, 0, len(runs))
+	for _, run := range runs {
+		link := fmt.Sprintf("%s/pipelines/%d/runs/%d", base, run.PipelineID, run.ID)
+		published := run.CreatedAt
+		if run.StartTime != nil {
+			published = *run.StartTime
+		}
+
+		commit := run.CommitSHA
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if commit == "" {
+			commit = "-"
+		}
+		duration := (time.Duration(run.DurationMs) * time.Millisecond).Round(time.Second)
+
+		entries = append(entries, feed.Entry{
+			ID:        link,
+			Title:     redactor.Redact(fmt.Sprintf("流水线 %s 运行 #%d %s", run.Pipeline.Name, run.RunNumber, run.Status)),
+			Updated:   utils.FormatTime(*run.EndTime),
+			Published: utils.FormatTime(published),
+			Links:     []feed.Link{{Href: link, Rel: "alternate", Type: "text/html"}},
+			Summary: redactor.Redact(fmt.Sprintf("状态: %s，耗时: %s，提交: %s，触发方式: %s",
+				run.Status, duration, commit, run.TriggerType)),
+			Categories: []feed.Category{{Term: run.Status}},
+		})
+	}
+	return entries
+}
+
+// siteURL 订阅中链接使用的站点地址，未配置 notify.base_url 时按请求推断
+func siteURL(c *gin.Context) string {
+	if base := strings.TrimRight(config.GetConfig().Notify.BaseURL, "/"); base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/auth"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/feed"
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// atomFeed 按 RFC 4287 解析订阅，字段带命名空间，命名空间不是 Atom 的元素不会被解析
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	IDs     []string    `xml:"http://www.w3.org/2005/Atom id"`
+	Titles  []string    `xml:"http://www.w3.org/2005/Atom title"`
+	Updated []string    `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors []atomName  `xml:"http://www.w3.org/2005/Atom author"`
+	Links   []feed.Link `xml:"http://www.w3.org/2005/Atom link"`
+	Entries []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomName struct {
+	Name string `xml:"http://www.w3.org/2005/Atom name"`
+}
+
+type atomEntry struct {
+	IDs       []string    `xml:"http://www.w3.org/2005/Atom id"`
+	Titles    []string    `xml:"http://www.w3.org/2005/Atom title"`
+	Updated   []string    `xml:"http://www.w3.org/2005/Atom updated"`
+	Published []string    `xml:"http://www.w3.org/2005/Atom published"`
+	Summary   []string    `xml:"http://www.w3.org/2005/Atom summary"`
+	Links     []feed.Link `xml:"http://www.w3.org/2005/Atom link"`
+}
+
+// validateAtom 检查 RFC 4287 要求的元素：feed 与 entry 各有且只有一个 id（绝对 IRI）、title、updated（RFC 3339），
+// entry 没有作者时 feed 必须有 author，有 rel=self 的订阅地址，每个条目有 rel=alternate 的链接且 id 不重复
+func validateAtom(t *testing.T, body []byte) *atomFeed {
+	t.Helper()
+	var doc atomFeed
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("不是 Atom 文档: %v\n%s", err, body)
+	}
+
+	single := func(where, name string, values []string) string {
+		t.Helper()
+		if len(values) != 1 || strings.TrimSpace(values[0]) == "" {
+			t.Errorf("%s 应有且只有一个非空的 %s，实际为 %q", where, name, values)
+			return ""
+		}
+		return values[0]
+	}
+	checkID := func(where, id string) {
+		t.Helper()
+		if u, err := url.Parse(id); err != nil || !u.IsAbs() {
+			t.Errorf("%s 的 id %q 不是绝对 IRI", where, id)
+		}
+	}
+	checkTime := func(where, name, value string) {
+		t.Helper()
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			t.Errorf("%s 的 %s %q 不是 RFC 3339 时间", where, name, value)
+		}
+	}
+	hasLink := func(links []feed.Link, rel string) bool {
+		for _, link := range links {
+			if (link.Rel == rel || link.Rel == "" && rel == "alternate") && link.Href != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	checkID("feed", single("feed", "id", doc.IDs))
+	single("feed", "title", doc.Titles)
+	checkTime("feed", "updated", single("feed", "updated", doc.Updated))
+	if len(doc.Authors) == 0 || doc.Authors[0].Name == "" {
+		t.Error("条目没有作者时 feed 必须有带 name 的 author")
+	}
+	if !hasLink(doc.Links, "self") {
+		t.Error("feed 缺少 rel=self 的订阅地址")
+	}
+
+	seen := map[string]bool{}
+	for i, entry := range doc.Entries {
+		where := fmt.Sprintf("第 %d 个条目", i+1)
+		id := single(where, "id", entry.IDs)
+		checkID(where, id)
+		if seen[id] {
+			t.Errorf("%s 的 id %s 重复", where, id)
+		}
+		seen[id] = true
+		single(where, "title", entry.Titles)
+		checkTime(where, "updated", single(where, "updated", entry.Updated))
+		if len(entry.Published) > 1 {
+			t.Errorf("%s 有 %d 个 published", where, len(entry.Published))
+		}
+		if len(entry.Summary) > 1 {
+			t.Errorf("%s 有 %d 个 summary", where, len(entry.Summary))
+		}
+		if !hasLink(entry.Links, "alternate") {
+			t.Errorf("%s 缺少 rel=alternate 的链接", where)
+		}
+	}
+	return &doc
+}
+
+// setupFeedTest 项目所有者的订阅令牌与 maxFeedEntries+5 次已结束的运行，另有一次正在执行的运行
+func setupFeedTest(t *testing.T) (*models.Pipeline, string) {
+	t.Helper()
+	pipeline := setupAccessTest(t)
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Notify: config.NotifyConfig{BaseURL: "https://ci.example/"}}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	owner := &models.User{ID: ownerUser.ID, Username: ownerUser.Username, Email: "owner@example.com", Password: "x"}
+	if err := database.DB.Create(owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	plain, hash := auth.GenerateFeedToken()
+	database.DB.Create(&models.FeedToken{TokenHash: hash, Prefix: plain[:8], UserID: owner.ID})
+
+	base := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	statuses := []string{models.RunStatusSuccess, models.RunStatusFailed, models.RunStatusCancelled}
+	for i := 1; i <= maxFeedEntries+5; i++ {
+		start := base.Add(time.Duration(i) * time.Hour)
+		end := start.Add(90 * time.Second)
+		database.DB.Create(&models.PipelineRun{
+			PipelineID:  pipeline.ID,
+			RunNumber:   i,
+			Status:      statuses[i%len(statuses)],
+			StartTime:   &start,
+			EndTime:     &end,
+			DurationMs:  90000,
+			TriggerType: "webhook",
+			CommitSHA:   fmt.Sprintf("%040d", i),
+		})
+	}
+	start := base.Add(100 * time.Hour)
+	database.DB.Create(&models.PipelineRun{PipelineID: pipeline.ID, RunNumber: maxFeedEntries + 6, Status: models.RunStatusRunning, StartTime: &start})
+	return pipeline, plain
+}
+
+func getFeed(handler gin.HandlerFunc, path, id, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, path+"?"+query, nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	handler(c)
+	return w
+}
+
+// TestRunFeedsAreValidAtom 项目与流水线订阅都是合法的 Atom：只包含已结束的运行，按结束时间倒序，
+// 最多 maxFeedEntries 条，订阅的 updated 为最近结束的运行的结束时间
+func TestRunFeedsAreValidAtom(t *testing.T) {
+	pipeline, token := setupFeedTest(t)
+	h := NewFeedHandler()
+
+	feeds := []struct {
+		name    string
+		handler gin.HandlerFunc
+		path    string
+		id      uint
+	}{
+		{"项目订阅", h.ProjectRuns, "/api/v1/projects/%d/runs.atom", pipeline.ProjectID},
+		{"流水线订阅", h.PipelineRuns, "/api/v1/pipelines/%d/runs.atom", pipeline.ID},
+	}
+	for _, tt := range feeds {
+		t.Run(tt.name, func(t *testing.T) {
+			path := fmt.Sprintf(tt.path, tt.id)
+			w := getFeed(tt.handler, path, fmt.Sprint(tt.id), "token="+token)
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != feed.ContentType {
+				t.Fatalf("订阅返回 %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+			}
+			body := w.Body.Bytes()
+			if !strings.HasPrefix(string(body), xml.Header) {
+				t.Error("订阅应以 XML 声明开头")
+			}
+			doc := validateAtom(t, body)
+
+			if len(doc.Entries) != maxFeedEntries {
+				t.Fatalf("订阅有 %d 个条目，应为最近的 %d 个", len(doc.Entries), maxFeedEntries)
+			}
+			for i, entry := range doc.Entries {
+				run := maxFeedEntries + 5 - i
+				if !strings.Contains(entry.Titles[0], fmt.Sprintf("#%d ", run)) {
+					t.Errorf("第 %d 个条目为 %q，应为运行 #%d", i+1, entry.Titles[0], run)
+				}
+				if len(entry.Summary) != 1 || !strings.Contains(entry.Summary[0], "耗时: 1m30s") || !strings.Contains(entry.Summary[0], "webhook") {
+					t.Errorf("第 %d 个条目的摘要 %q 应包含耗时、提交与触发方式", i+1, entry.Summary)
+				}
+				if !strings.HasPrefix(entry.IDs[0], "https://ci.example/pipelines/") {
+					t.Errorf("第 %d 个条目链接 %s 应指向运行页面", i+1, entry.IDs[0])
+				}
+			}
+			if doc.Updated[0] != doc.Entries[0].Updated[0] {
+				t.Errorf("订阅的 updated 为 %s，应为最近结束的运行 %s", doc.Updated[0], doc.Entries[0].Updated[0])
+			}
+			self := false
+			for _, link := range doc.Links {
+				self = self || link.Rel == "self" && link.Href == "https://ci.example"+path
+			}
+			if !self {
+				t.Errorf("订阅的 self 链接为 %+v，应为 %s", doc.Links, path)
+			}
+		})
+	}
+}
+
+// TestRunFeedEmptyAndUnauthorized 没有运行的订阅仍是合法的 Atom；令牌无效或撤销后返回 401，其他用户的项目返回 404
+func TestRunFeedEmptyAndUnauthorized(t *testing.T) {
+	pipeline, token := setupFeedTest(t)
+	h := NewFeedHandler()
+	id := fmt.Sprint(pipeline.ProjectID)
+	path := "/api/v1/projects/" + id + "/runs.atom"
+
+	database.DB.Where("1 = 1").Delete(&models.PipelineRun{})
+	w := getFeed(h.ProjectRuns, path, id, "token="+token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("订阅返回 %d", w.Code)
+	}
+	if doc := validateAtom(t, w.Body.Bytes()); len(doc.Entries) != 0 {
+		t.Errorf("没有运行时订阅有 %d 个条目", len(doc.Entries))
+	}
+
+	outsider := &models.User{ID: outsiderUser.ID, Username: outsiderUser.Username, Email: "outsider@example.com", Password: "x"}
+	database.DB.Create(outsider)
+	plain, hash := auth.GenerateFeedToken()
+	database.DB.Create(&models.FeedToken{TokenHash: hash, Prefix: plain[:8], UserID: outsider.ID})
+	if w := getFeed(h.ProjectRuns, path, id, "token="+plain); w.Code != http.StatusNotFound {
+		t.Errorf("订阅其他用户的项目返回 %d，应为 404", w.Code)
+	}
+
+	for _, query := range []string{"", "token=fffd_invalid", "token=" + url.QueryEscape(token[:len(token)-1])} {
+		if w := getFeed(h.ProjectRuns, path, id, query); w.Code != http.StatusUnauthorized {
+			t.Errorf("查询参数 %q 返回 %d，应为 401", query, w.Code)
+		}
+	}
+	revokeFeedTokens(database.DB, ownerUser.ID)
+	if w := getFeed(h.ProjectRuns, path, id, "token="+token); w.Code != http.StatusUnauthorized {
+		t.Errorf("撤销后的令牌返回 %d，应为 401", w.Code)
+	}
+}
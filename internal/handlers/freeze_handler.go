@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLiftedFreezes 冻结列表中最多返回的已解除冻结数
+const maxLiftedFreezes = 20
+
+// FreezeHandler 部署冻结处理器
+type FreezeHandler struct {
+	manager *deploy.FreezeManager
+}
+
+// NewFreezeHandler 创建部署冻结处理器
+func NewFreezeHandler(manager *deploy.FreezeManager) *FreezeHandler {
+	return &FreezeHandler{
+		manager: manager,
+	}
+}
+
+// GetFreezes 获取生效中的部署冻结及最近解除的冻结（管理员）
+func (h *FreezeHandler) GetFreezes(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	active, err := deploy.ActiveFreezes()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询部署冻结失败")
+		return
+	}
+
+	var lifted []models.DeployFreeze
+	database.DB.Where("lifted_at IS NOT NULL").Order("lifted_at DESC").Limit(maxLiftedFreezes).Find(&lifted)
+
+	utils.SuccessResponse(c, gin.H{
+		"frozen": len(active) > 0,
+		"active": active,
+		"lifted": lifted,
+	})
+}
+
+// CreateFreeze 冻结部署（管理员，可使用API令牌调用）
+func (h *FreezeHandler) CreateFreeze(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var req models.CreateFreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	var tokenID *uint
+	if current.APITokenID != 0 {
+		tokenID = &current.APITokenID
+	}
+
+	freeze, err := h.manager.Create(&req, current.ID, tokenID)
+	switch {
+	case errors.Is(err, deploy.ErrFreezeScopeInvalid):
+		utils.ErrorResponse(c, http.StatusBadRequest, "冻结范围无效")
+		return
+	case errors.Is(err, deploy.ErrFreezeExpiryInvalid):
+		utils.ErrorResponse(c, http.StatusBadRequest, "冻结到期时间无效")
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建部署冻结失败")
+		return
+	}
+
+	recordAudit(c, "create_freeze", "deploy_freeze", freeze.ID, fmt.Sprintf("冻结部署（%s）: %s", freeze.Scope, freeze.Reason))
+
+	utils.SuccessResponse(c, freeze)
+}
+
+// LiftFreeze 解除指定ID的部署冻结（管理员，可使用API令牌调用）
+func (h *FreezeHandler) LiftFreeze(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的冻结ID")
+		return
+	}
+
+	// 解除原因可选，请求体为空时忽略
+	var req models.LiftFreezeRequest
+	c.ShouldBindJSON(&req)
+
+	freeze, err := h.manager.Lift(uint(id), current.ID, req.Reason)
+	switch {
+	case errors.Is(err, deploy.ErrFreezeNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "部署冻结不存在")
+		return
+	case errors.Is(err, deploy.ErrFreezeLifted):
+		utils.ErrorResponse(c, http.StatusConflict, "部署冻结已解除")
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "解除部署冻结失败")
+		return
+	}
+
+	recordAudit(c, "lift_freeze", "deploy_freeze", freeze.ID, fmt.Sprintf("解除部署冻结 #%d: %s", freeze.ID, freeze.LiftReason))
+
+	utils.SuccessResponse(c, freeze)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser 获取当前已认证用户，未认证时直接返回401
+func currentUser(c *gin.Context) (*authctx.User, bool) {
+	current, err := authctx.CurrentUser(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+	return current, true
+}
+
+// requireAdmin 校验当前用户为管理员，返回用户ID；未认证时返回401，不是管理员时返回403
+func requireAdmin(c *gin.Context) (uint, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return 0, false
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return 0, false
+	}
+	return current.ID, true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/config"
+	"flowforge/pkg/git"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withoutIdentity 不要求身份或先校验其他条件的处理器方法，缺少身份时不返回 401
+var withoutIdentity = map[string]string{
+	"AuthHandler.Login":                     "公开接口",
+	"AuthHandler.Register":                  "公开接口",
+	"AuthHandler.VerifyEmail":               "公开接口",
+	"ExternalWaitHandler.Callback":          "以回调令牌认证",
+	"WebhookHandler.Receive":                "以 Webhook 签名认证",
+	"LintHandler.GetRules":                  "静态内容",
+	"PipelineHandler.GetStepTypes":          "静态内容",
+	"WebSocketHandler.HandleDeploymentLogs": "由 WebSocketAuth 认证，先校验升级请求",
+	"WebSocketHandler.HandlePipelineLogs":   "由 WebSocketAuth 认证，先校验升级请求",
+	"PipelineHandler.CreatePipeline":        "先校验请求体",
+	"ProjectHandler.Create":                 "先校验请求体",
+	"ProjectHandler.List":                   "由认证中间件保护，不读取身份",
+	"ProjectHandler.Get":                    "由认证中间件保护，不读取身份",
+	"ProjectHandler.GetBySlug":              "由认证中间件保护，不读取身份",
+	"ProjectHandler.GetReadme":              "由认证中间件保护，不读取身份",
+	"ProjectHandler.Stats":                  "由认证中间件保护，不读取身份",
+	"ProjectHandler.Update":                 "由认证中间件保护，不读取身份",
+}
+
+// identityHandlers 所有处理器，依赖只在通过身份校验后使用，零值即可
+func identityHandlers(cfg *config.Config) []interface{} {
+	return []interface{}{
+		&AccessDebugHandler{}, &AccessRequestHandler{}, &APITokenHandler{}, &ArtifactHandler{}, &ArtifactShareHandler{},
+		&AuditHandler{}, &AuthHandler{}, &BackupHandler{}, &CacheHandler{}, &ChangeHandler{}, &CircuitBreakerHandler{},
+		&ComplianceHandler{}, &ConcurrencyHandler{}, &DebugSessionHandler{}, &DeployKeyHandler{}, &DriftHandler{},
+		&EventHandler{}, &ExternalWaitHandler{}, &FeatureFlagHandler{}, &FeedHandler{}, &FreezeHandler{}, &LintHandler{},
+		&LogIngestHandler{}, &LogSearchHandler{}, &LogStorageHandler{}, &NotificationHandler{}, &OutboundHandler{},
+		&PipelineHandler{}, NewProjectHandler(git.NewManager(cfg)), &ProviderRangeHandler{}, &QueueHandler{},
+		&RedactionHandler{}, &RegistrationHandler{}, &RetentionHandler{}, &RunLabelHandler{}, &SourceArchiveHandler{},
+		&SupportHandler{}, &TargetHandler{}, &WebhookHandler{}, &WebSocketHandler{}, &WorkerHandler{}, &WorkspaceHandler{},
+	}
+}
+
+// TestHandlersRequireIdentity 上下文中没有身份或身份无效时，处理器不 panic，读取身份的处理器返回 401
+func TestHandlersRequireIdentity(t *testing.T) {
+	setupAccessTest(t)
+	cfg := &config.Config{}
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	identities := []struct {
+		name string
+		set  func(c *gin.Context)
+	}{
+		{"缺少身份", func(*gin.Context) {}},
+		{"身份无效", func(c *gin.Context) { authctx.SetCurrentUser(c, authctx.User{Username: "ghost"}) }},
+	}
+	ginHandler := reflect.TypeOf((func(*gin.Context))(nil))
+	for _, identity := range identities {
+		t.Run(identity.name, func(t *testing.T) {
+			checked := 0
+			for _, handler := range identityHandlers(cfg) {
+				value := reflect.ValueOf(handler)
+				for i := 0; i < value.NumMethod(); i++ {
+					method := value.Method(i)
+					if method.Type() != ginHandler {
+						continue
+					}
+					name := fmt.Sprintf("%s.%s", value.Elem().Type().Name(), value.Type().Method(i).Name)
+					code, panicked := callWithoutIdentity(method, identity.set)
+					switch {
+					case panicked != nil:
+						t.Errorf("%s panic: %v", name, panicked)
+					case withoutIdentity[name] == "" && code != http.StatusUnauthorized:
+						t.Errorf("%s 返回 %d，应为 401", name, code)
+					}
+					checked++
+				}
+			}
+			if checked < 100 {
+				t.Fatalf("只检查了 %d 个处理器方法", checked)
+			}
+		})
+	}
+}
+
+// callWithoutIdentity 以 set 设置的身份调用处理器方法，返回状态码与 panic 的值
+func callWithoutIdentity(method reflect.Value, set func(c *gin.Context)) (code int, panicked interface{}) {
+	defer func() { panicked = recover() }()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "1"}, {Key: "runId", Value: "1"}}
+	set(c)
+	method.Call([]reflect.Value{reflect.ValueOf(c)})
+	return w.Code, nil
+}
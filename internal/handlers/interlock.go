@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"flowforge/pkg/clock"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/interlock"
+	"flowforge/pkg/models"
+	"flowforge/pkg/retention"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 破坏性操作确认时回传的请求头：首次请求返回的令牌与要删除的资源名称
+const (
+	confirmationTokenHeader = "X-Confirmation-Token"
+	confirmationNameHeader  = "X-Confirmation-Name"
+)
+
+// destructiveConfirmations 删除项目、用户等操作的确认令牌
+var destructiveConfirmations = interlock.NewStore(clock.Real, interlock.DefaultTTL)
+
+// confirmDestructive 破坏性操作的两步确认，返回 true 表示已确认、可以执行。未携带确认令牌时签发令牌并返回 428，
+// 响应中包含 summarize 统计的将被删除的数据；携带令牌时校验令牌与回传的资源名称，令牌只能使用一次。
+// 签发令牌与确认失败都记录审计日志，确认后的操作由调用方记录
+func confirmDestructive(c *gin.Context, operation, resourceType string, resourceID uint, name string, summarize func() (interlock.Summary, error)) bool {
+	current, ok := currentUser(c)
+	if !ok {
+		return false
+	}
+
+	token := c.GetHeader(confirmationTokenHeader)
+	if token == "" {
+		summary, err := summarize()
+		if err != nil {
+			log.Printf("统计 %s %d 的数据失败: %v", resourceType, resourceID, err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "统计将被删除的数据失败")
+			return false
+		}
+		challenge := destructiveConfirmations.Issue(operation, resourceID, name, current.ID, summary)
+		recordAudit(c, "request_"+operation, resourceType, resourceID, "申请确认："+summary.Description)
+
+		message := "该操作需要确认"
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"error":        i18n.Translate(i18n.FromContext(c), message),
+			"code":         i18n.Code(message),
+			"confirmation": challenge,
+		})
+		return false
+	}
+
+	err := destructiveConfirmations.Confirm(operation, resourceID, name, current.ID, token, c.GetHeader(confirmationNameHeader))
+	if err == nil {
+		return true
+	}
+	recordAudit(c, "reject_"+operation, resourceType, resourceID, fmt.Sprintf("确认失败（%s）: %v", name, err))
+	if errors.Is(err, interlock.ErrNameMismatch) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	return false
+}
+
+// projectDeletionSummary 删除项目将删除的流水线、运行、日志与制品，运行数据按清理任务的统计口径计算
+func projectDeletionSummary(db *gorm.DB, project *models.Project) (interlock.Summary, error) {
+	var pipelines int64
+	if err := db.Model(&models.Pipeline{}).Where("project_id = ?", project.ID).Count(&pipelines).Error; err != nil {
+		return interlock.Summary{}, fmt.Errorf("统计流水线失败: %w", err)
+	}
+	totals, err := retention.Footprint(func(q *gorm.DB) *gorm.DB {
+		return q.Where("pipelines.project_id = ?", project.ID)
+	})
+	if err != nil {
+		return interlock.Summary{}, err
+	}
+	return interlock.Summary{
+		Description: fmt.Sprintf("删除项目 %s：%d 条流水线、%d 次运行、%d 个制品", project.Name, pipelines, totals.Runs.Count, totals.Artifacts.Count),
+		Counts:      footprintCounts(pipelines, totals),
+	}, nil
+}
+
+// userDeletionSummary 删除用户影响的数据：用户的 API 令牌失效，拥有的项目及其流水线与运行不随用户删除
+func userDeletionSummary(db *gorm.DB, user *models.User) (interlock.Summary, error) {
+	var projects, pipelines, tokens int64
+	if err := db.Model(&models.Project{}).Where("user_id = ?", user.ID).Count(&projects).Error; err != nil {
+		return interlock.Summary{}, fmt.Errorf("统计项目失败: %w", err)
+	}
+	if err := db.Model(&models.Pipeline{}).Joins("JOIN projects ON projects.id = pipelines.project_id").
+		Where("projects.user_id = ? AND projects.deleted_at IS NULL", user.ID).Count(&pipelines).Error; err != nil {
+		return interlock.Summary{}, fmt.Errorf("统计流水线失败: %w", err)
+	}
+	if err := db.Model(&models.APIToken{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&tokens).Error; err != nil {
+		return interlock.Summary{}, fmt.Errorf("统计 API 令牌失败: %w", err)
+	}
+	totals, err := retention.Footprint(func(q *gorm.DB) *gorm.DB {
+		return q.Joins("JOIN projects ON projects.id = pipelines.project_id").
+			Where("projects.user_id = ? AND projects.deleted_at IS NULL", user.ID)
+	})
+	if err != nil {
+		return interlock.Summary{}, err
+	}
+	counts := footprintCounts(pipelines, totals)
+	counts["projects"] = projects
+	counts["api_tokens"] = tokens
+	return interlock.Summary{
+		Description: fmt.Sprintf("删除用户 %s：%d 个 API 令牌失效；拥有的 %d 个项目（%d 条流水线、%d 次运行）不随用户删除",
+			user.Username, tokens, projects, pipelines, totals.Runs.Count),
+		Counts: counts,
+	}, nil
+}
+
+// environmentDeletionSummary 删除项目全部环境变量的数量，密钥变量单独统计
+func environmentDeletionSummary(db *gorm.DB, project *models.Project) (interlock.Summary, error) {
+	var total, secrets int64
+	if err := db.Model(&models.Environment{}).Where("project_id = ?", project.ID).Count(&total).Error; err != nil {
+		return interlock.Summary{}, fmt.Errorf("统计环境变量失败: %w", err)
+	}
+	if err := db.Model(&models.Environment{}).Where("project_id = ? AND is_secret = ?", project.ID, true).Count(&secrets).Error; err != nil {
+		return interlock.Summary{}, fmt.Errorf("统计环境变量失败: %w", err)
+	}
+	return interlock.Summary{
+		Description: fmt.Sprintf("删除项目 %s 的全部环境变量：%d 个，其中密钥 %d 个", project.Name, total, secrets),
+		Counts:      map[string]int64{"environments": total, "secrets": secrets},
+	}, nil
+}
+
+// cancelAllSummary 取消流水线全部未结束运行的数量
+func cancelAllSummary(name string, pending, running int64) interlock.Summary {
+	return interlock.Summary{
+		Description: fmt.Sprintf("取消流水线 %s 的全部运行：%d 个排队中、%d 个运行中", name, pending, running),
+		Counts:      map[string]int64{"pending": pending, "running": running},
+	}
+}
+
+// retentionPruneSummary 按保留策略立即清理将删除的数据，与模拟使用相同的计算
+func retentionPruneSummary(policy retention.Policy, now time.Time) (interlock.Summary, error) {
+	summary, err := retention.Simulate(policy, now)
+	if err != nil {
+		return interlock.Summary{}, err
+	}
+	totals := &summary.Totals
+	counts := footprintCounts(0, totals)
+	delete(counts, "pipelines")
+	return interlock.Summary{
+		Description: fmt.Sprintf("按保留策略清理：%d 次运行、%d 个日志、%d 个制品", totals.Runs.Count, totals.Logs.Count, totals.Artifacts.Count),
+		Counts:      counts,
+	}, nil
+}
+
+// footprintCounts 确认信息中的数据量
+func footprintCounts(pipelines int64, totals *retention.Totals) map[string]int64 {
+	return map[string]int64{
+		"pipelines":            pipelines,
+		"runs":                 totals.Runs.Count,
+		"logs":                 totals.Logs.Count,
+		"log_bytes":            totals.Logs.Bytes,
+		"artifacts":            totals.Artifacts.Count,
+		"artifact_bytes":       totals.Artifacts.Bytes,
+		"workspaces":           totals.Workspaces.Count,
+		"source_archives":      totals.SourceArchives.Count,
+		"source_archive_bytes": totals.SourceArchives.Bytes,
+	}
+}
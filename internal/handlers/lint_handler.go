@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LintHandler 流水线配置建议性检查处理器
+type LintHandler struct{}
+
+// NewLintHandler 创建流水线配置建议性检查处理器
+func NewLintHandler() *LintHandler {
+	return &LintHandler{}
+}
+
+// GetRules 列出建议性检查规则及其说明，blocking 表示本实例将该规则配置为阻止保存
+func (h *LintHandler) GetRules(c *gin.Context) {
+	utils.SuccessResponse(c, pipeline.LintRules())
+}
+
+// lintPipelineConfig 对保存的配置运行建议性检查，结果随保存或试运行的响应返回，不阻止保存；
+// 存在被配置为阻止保存且未被注释忽略的结果时拒绝保存，错误响应中列出全部结果
+func lintPipelineConfig(c *gin.Context, req *models.CreatePipelineRequest) ([]models.LintWarning, bool) {
+	config := storedConfig(req)
+	if config == nil {
+		return nil, true
+	}
+
+	warnings := pipeline.LintConfig(req.Config, config)
+	if len(pipeline.LintBlocking(warnings)) == 0 {
+		return warnings, true
+	}
+
+	message := "流水线配置未通过检查"
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":    i18n.Translate(i18n.FromContext(c), message),
+		"code":     i18n.Code(message),
+		"warnings": warnings,
+	})
+	return nil, false
+}
+
+// recordLintSuppressions 保存的配置中有被注释忽略的检查结果时记录审计日志，列出忽略的规则
+func recordLintSuppressions(c *gin.Context, pipelineID uint, warnings []models.LintWarning) {
+	counts := make(map[string]int)
+	for _, warning := range warnings {
+		if warning.Suppressed {
+			counts[warning.Rule]++
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+
+	rules := make([]string, 0, len(counts))
+	for rule, n := range counts {
+		rules = append(rules, fmt.Sprintf("%s×%d", rule, n))
+	}
+	sort.Strings(rules)
+	recordAudit(c, "suppress_lint", "pipeline", pipelineID, "流水线配置忽略了检查规则: "+strings.Join(rules, ", "))
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogIngestHandler 远程执行器批量上报运行日志的处理器
+type LogIngestHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewLogIngestHandler 创建日志上报处理器
+func NewLogIngestHandler(engine *pipeline.Engine) *LogIngestHandler {
+	return &LogIngestHandler{
+		engine: engine,
+	}
+}
+
+// Ingest 接收一批 NDJSON 日志（每行一个 {"seq":1,"line":"..."}，可用 gzip 压缩），通过运行的上报令牌认证，
+// 返回已连续写入的最大序号，执行器从其后重传缺失的行
+func (h *LogIngestHandler) Ingest(c *gin.Context) {
+	runID, err := strconv.ParseUint(c.Param("runId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的运行ID")
+		return
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "缺少日志上报令牌")
+		return
+	}
+
+	// 压缩后与解压后的大小分别限制，避免压缩炸弹
+	maxBytes := int64(-1)
+	if kb := config.GetConfig().Deploy.LogIngestMaxBatchKB; kb > 0 {
+		maxBytes = int64(kb) << 10
+	}
+	var body io.Reader = c.Request.Body
+	if maxBytes > 0 {
+		body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+	}
+	if strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "解压日志失败")
+			return
+		}
+		defer reader.Close()
+		body = reader
+	}
+	if maxBytes > 0 {
+		body = io.LimitReader(body, maxBytes+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "日志批次超过大小限制")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "读取日志失败")
+		return
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "日志批次超过大小限制")
+		return
+	}
+
+	var lines []pipeline.LogIngestLine
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), len(data)+1)
+	for scanner.Scan() {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var line pipeline.LogIngestLine
+		if err := json.Unmarshal(raw, &line); err != nil || line.Seq == 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "日志格式错误")
+			return
+		}
+		lines = append(lines, line)
+	}
+
+	acked, err := h.engine.IngestLogs(uint(runID), token, lines, int64(len(data)))
+	switch {
+	case errors.Is(err, pipeline.ErrIngestUnauthorized):
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	case errors.Is(err, pipeline.ErrIngestRateLimited):
+		c.Header("Retry-After", "1")
+		body := utils.ErrorBody(i18n.FromContext(c), err.Error())
+		body["acked"] = acked
+		c.JSON(http.StatusTooManyRequests, body)
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "写入日志失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"acked": acked})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/logsearch"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LogSearchHandler 运行日志全文检索处理器
+type LogSearchHandler struct{}
+
+// NewLogSearchHandler 创建运行日志全文检索处理器
+func NewLogSearchHandler() *LogSearchHandler {
+	return &LogSearchHandler{}
+}
+
+// Search 检索运行日志中提到 q 的运行，可按 project_id 筛选，返回匹配行的摘要与写入时间；只返回当前用户可以查看的项目中的运行
+func (h *LogSearchHandler) Search(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var projectID uint
+	if value := c.Query("project_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+			return
+		}
+		projectID = uint(id)
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	results, err := logsearch.Search(logsearch.Query{
+		Text:      strings.TrimSpace(c.Query("q")),
+		ProjectID: projectID,
+		Limit:     limit,
+		Scope: func(db *gorm.DB) *gorm.DB {
+			if current.IsAdmin() {
+				return db
+			}
+			return db.Where(projectAccess(current.ID, actionView))
+		},
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, logsearch.ErrDisabled):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, logsearch.ErrEmptyQuery):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			log.Printf("检索运行日志失败: %v", err)
+			utils.ErrorResponse(c, http.StatusInternalServerError, "检索运行日志失败")
+		}
+		return
+	}
+	utils.SuccessResponse(c, results)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/logsearch"
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchLogs 以 user 身份请求日志检索接口，返回状态码与提到检索内容的项目
+func searchLogs(t *testing.T, user authctx.User, query url.Values) (int, []string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/search/logs?"+query.Encode(), nil)
+	authctx.SetCurrentUser(c, user)
+	NewLogSearchHandler().Search(c)
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+	var body struct {
+		Data []logsearch.Result `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	var projects []string
+	for _, result := range body.Data {
+		projects = append(projects, result.ProjectName)
+	}
+	return w.Code, projects
+}
+
+// TestLogSearchVisibility 只返回调用者可以查看的项目中的运行；未开启检索时返回 404，没有检索词时返回 400
+func TestLogSearchVisibility(t *testing.T) {
+	f := setupChangeTest(t)
+	if code, _ := searchLogs(t, adminUser, url.Values{"q": {"ERESOLVE"}}); code != http.StatusNotFound {
+		t.Errorf("未开启检索时返回 %d，应为 404", code)
+	}
+
+	search := &config.LogSearchConfig{Enabled: true, MaxKBPerRun: 1024, QueueBatches: 10}
+	if err := logsearch.Init(search); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(logsearch.Stop)
+	for _, pipeline := range []*models.Pipeline{f.webPipeline, f.secretPipeline} {
+		run := &models.PipelineRun{PipelineID: pipeline.ID, UserID: ownerUser.ID, RunNumber: 1, Status: models.RunStatusFailed, TriggerType: models.TriggerManual}
+		database.DB.Create(run)
+		logsearch.Enqueue(run.ID, "npm ERR! code ERESOLVE\n")
+	}
+	// 停止时为队列中的日志建立索引
+	logsearch.Stop()
+	if err := logsearch.Init(search); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		user  authctx.User
+		query url.Values
+		code  int
+		want  []string
+	}{
+		{"管理员", adminUser, url.Values{"q": {"ERESOLVE"}}, http.StatusOK, []string{"secret", "web"}},
+		{"成员", viewerUser, url.Values{"q": {"ERESOLVE"}}, http.StatusOK, []string{"web"}},
+		{"其他项目的所有者", outsiderUser, url.Values{"q": {"ERESOLVE"}}, http.StatusOK, []string{"secret"}},
+		{"不可见的项目", viewerUser, url.Values{"q": {"ERESOLVE"}, "project_id": {fmt.Sprint(f.secret.ID)}}, http.StatusOK, nil},
+		{"按项目筛选", adminUser, url.Values{"q": {"ERESOLVE"}, "project_id": {fmt.Sprint(f.web.ID)}}, http.StatusOK, []string{"web"}},
+		{"没有检索词", viewerUser, url.Values{"q": {" "}}, http.StatusBadRequest, nil},
+		{"无效的项目ID", viewerUser, url.Values{"q": {"ERESOLVE"}, "project_id": {"web"}}, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := searchLogs(t, tt.user, tt.query)
+			if code != tt.code || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("返回 %d %q，应为 %d %q", code, got, tt.code, tt.want)
+			}
+		})
+	}
+}
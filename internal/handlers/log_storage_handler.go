@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogStorageHandler 项目运行日志存储占用与配额处理器
+type LogStorageHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewLogStorageHandler 创建日志存储处理器
+func NewLogStorageHandler(engine *pipeline.Engine) *LogStorageHandler {
+	return &LogStorageHandler{
+		engine: engine,
+	}
+}
+
+// GetUsage 获取项目的运行日志存储占用、项目设置的配额与生效的配额
+func (h *LogStorageHandler) GetUsage(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	h.respond(c, project)
+}
+
+// UpdateQuota 设置项目的运行日志软配额与硬上限（管理员），0 表示使用全局配置，-1 表示不限制
+func (h *LogStorageHandler) UpdateQuota(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	current, _ := currentUser(c)
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var req models.LogQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if req.SoftQuotaMB < -1 || req.HardCapMB < -1 ||
+		(req.SoftQuotaMB > 0 && req.HardCapMB > 0 && req.SoftQuotaMB > req.HardCapMB) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "日志存储配额无效")
+		return
+	}
+
+	before := projectSettingsText(project)
+	if err := database.DB.Model(project).Updates(map[string]interface{}{
+		"log_soft_quota_mb": req.SoftQuotaMB,
+		"log_hard_cap_mb":   req.HardCapMB,
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存日志存储配额失败")
+		return
+	}
+	project.LogSoftQuotaMB = req.SoftQuotaMB
+	project.LogHardCapMB = req.HardCapMB
+
+	recordAuditChange(c, "update_log_quota", "project", project.ID,
+		fmt.Sprintf("设置项目 %s 的日志存储配额: 软配额 %d MB，硬上限 %d MB", project.Name, req.SoftQuotaMB, req.HardCapMB),
+		before, projectSettingsText(project))
+
+	h.respond(c, project)
+}
+
+// respond 返回项目的日志存储占用，未配置日志归档存储时只返回项目设置
+func (h *LogStorageHandler) respond(c *gin.Context, project *models.Project) {
+	result := gin.H{
+		"log_soft_quota_mb":   project.LogSoftQuotaMB,
+		"log_hard_cap_mb":     project.LogHardCapMB,
+		"log_quota_warned_at": project.LogQuotaWarnedAt,
+	}
+	if store := h.engine.LogArchive(); store != nil {
+		usage, err := store.Usage(project)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "获取日志存储占用失败")
+			return
+		}
+		result["usage"] = usage
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+// loadProject 加载当前用户可访问的项目
+func (h *LogStorageHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
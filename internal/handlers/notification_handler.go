@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler 通知与关注处理器
+type NotificationHandler struct{}
+
+// NewNotificationHandler 创建通知处理器
+func NewNotificationHandler() *NotificationHandler {
+	return &NotificationHandler{}
+}
+
+// GetNotifications 获取当前用户的站内通知，可按 category 过滤（如 performance_regression）
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND channel = ?", current.ID, models.NotifyChannelInApp)
+	if c.Query("unread") == "true" {
+		query = query.Where("is_read = ?", false)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var total, unread int64
+	query.Count(&total)
+	database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND channel = ? AND is_read = ?", current.ID, models.NotifyChannelInApp, false).
+		Count(&unread)
+
+	var notifications []models.Notification
+	query.Order("created_at DESC").Scopes(database.Paginate(page, pageSize)).Find(&notifications)
+
+	utils.SuccessResponse(c, gin.H{
+		"unread_count": unread,
+		"notifications": models.PaginationResponse{
+			Data:       notifications,
+			Total:      total,
+			Page:       page,
+			PageSize:   pageSize,
+			TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// MarkRead 标记通知为已读
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", c.Param("id"), current.ID).
+		Updates(map[string]interface{}{"is_read": true, "read_at": &now})
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "标记已读失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "通知不存在")
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// MarkAllRead 标记所有通知为已读
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND is_read = ?", current.ID, false).
+		Updates(map[string]interface{}{"is_read": true, "read_at": &now}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "标记已读失败")
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// GetPreferences 获取通知偏好
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, current.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	var watches []models.RunWatch
+	database.DB.Where("user_id = ?", current.ID).Find(&watches)
+
+	utils.SuccessResponse(c, gin.H{
+		"channel": user.NotifyChannel,
+		"digest":  user.NotifyDigest,
+		"watches": watches,
+	})
+}
+
+// UpdatePreferences 更新通知偏好
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Channel *string `json:"channel"`
+		Digest  *bool   `json:"digest"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Channel != nil {
+		switch *req.Channel {
+		case models.NotifyChannelEmail, models.NotifyChannelInApp, models.NotifyChannelNone:
+			updates["notify_channel"] = *req.Channel
+		default:
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的通知渠道")
+			return
+		}
+	}
+	if req.Digest != nil {
+		updates["notify_digest"] = *req.Digest
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(&models.User{}).Where("id = ?", current.ID).Updates(updates).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "更新通知偏好失败")
+			return
+		}
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// WatchPipeline 关注流水线的所有后续运行
+func (h *NotificationHandler) WatchPipeline(c *gin.Context) {
+	h.watch(c, false)
+}
+
+// UnwatchPipeline 取消关注流水线
+func (h *NotificationHandler) UnwatchPipeline(c *gin.Context) {
+	h.unwatch(c, false)
+}
+
+// WatchRun 关注单次流水线运行
+func (h *NotificationHandler) WatchRun(c *gin.Context) {
+	h.watch(c, true)
+}
+
+// UnwatchRun 取消关注单次流水线运行
+func (h *NotificationHandler) UnwatchRun(c *gin.Context) {
+	h.unwatch(c, true)
+}
+
+// watch 创建关注订阅，仅允许关注有权查看的流水线
+func (h *NotificationHandler) watch(c *gin.Context, runLevel bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, current.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户不存在")
+		return
+	}
+
+	var pipeline models.Pipeline
+	if err := database.DB.First(&pipeline, c.Param("id")).Error; err != nil || !notify.CanViewPipeline(&user, &pipeline) {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线不存在")
+		return
+	}
+
+	watch := models.RunWatch{
+		UserID:     user.ID,
+		PipelineID: pipeline.ID,
+	}
+	query := database.DB.Where("user_id = ? AND pipeline_id = ?", user.ID, pipeline.ID)
+
+	if runLevel {
+		var run models.PipelineRun
+		if err := database.DB.Where("pipeline_id = ?", pipeline.ID).First(&run, c.Param("runId")).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+			return
+		}
+		watch.PipelineRunID = &run.ID
+		query = query.Where("pipeline_run_id = ?", run.ID)
+	} else {
+		query = query.Where("pipeline_run_id IS NULL")
+	}
+
+	if err := query.FirstOrCreate(&watch).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "关注失败")
+		return
+	}
+
+	utils.SuccessResponse(c, watch)
+}
+
+// unwatch 删除关注订阅
+func (h *NotificationHandler) unwatch(c *gin.Context, runLevel bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Where("user_id = ? AND pipeline_id = ?", current.ID, c.Param("id"))
+	if runLevel {
+		query = query.Where("pipeline_run_id = ?", c.Param("runId"))
+	} else {
+		query = query.Where("pipeline_run_id IS NULL")
+	}
+
+	if err := query.Delete(&models.RunWatch{}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "取消关注失败")
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
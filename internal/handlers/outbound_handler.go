@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OutboundHandler 项目出站例外处理器
+type OutboundHandler struct{}
+
+// NewOutboundHandler 创建项目出站例外处理器
+func NewOutboundHandler() *OutboundHandler {
+	return &OutboundHandler{}
+}
+
+// GetExceptions 获取项目的出站例外，项目所有者与管理员可以查看
+func (h *OutboundHandler) GetExceptions(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var exceptions []models.OutboundException
+	if err := database.DB.Where("project_id = ?", project.ID).Order("id").Find(&exceptions).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询出站例外失败")
+		return
+	}
+
+	utils.SuccessResponse(c, exceptions)
+}
+
+// CreateException 为项目添加出站例外，只有管理员可以添加
+func (h *OutboundHandler) CreateException(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var req models.OutboundExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	target, err := httpclient.ParseExceptionTarget(req.Target)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "出站例外无效: "+err.Error())
+		return
+	}
+
+	exception := models.OutboundException{
+		ProjectID:   project.ID,
+		Target:      target,
+		Reason:      req.Reason,
+		CreatedByID: &current.ID,
+	}
+	if err := database.DB.Create(&exception).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存出站例外失败")
+		return
+	}
+	httpclient.InvalidateExceptions(project.ID)
+
+	recordAudit(c, "create_outbound_exception", "project", project.ID,
+		fmt.Sprintf("为项目 %s 添加出站例外 %s：%s", project.Name, exception.Target, exception.Reason))
+
+	utils.SuccessResponse(c, exception)
+}
+
+// DeleteException 删除项目的出站例外，只有管理员可以删除
+func (h *OutboundHandler) DeleteException(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var exception models.OutboundException
+	if err := database.DB.Where("project_id = ?", project.ID).First(&exception, c.Param("exception_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "出站例外不存在")
+		return
+	}
+	if err := database.DB.Delete(&exception).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除出站例外失败")
+		return
+	}
+	httpclient.InvalidateExceptions(project.ID)
+
+	recordAudit(c, "delete_outbound_exception", "project", project.ID,
+		fmt.Sprintf("删除项目 %s 的出站例外 %s", project.Name, exception.Target))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// loadProject 加载项目并校验当前用户为项目所有者或管理员
+func (h *OutboundHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// validateOutboundTargets 校验流水线配置中用户填写的出站地址符合出站策略
+func validateOutboundTargets(c *gin.Context, project *models.Project, req *models.CreatePipelineRequest) bool {
+	config := storedConfig(req)
+	if config == nil {
+		return true
+	}
+
+	problems := pipeline.ValidateExternalRequests(project, config)
+	if len(problems) == 0 {
+		return true
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, "流水线配置无效: "+strings.Join(problems, "；"))
+	return false
+}
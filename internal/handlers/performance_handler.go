@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"flowforge/pkg/baseline"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// durationPoint 耗时趋势中一次运行的步骤耗时
+type durationPoint struct {
+	RunID      uint  `json:"run_id"`
+	RunNumber  int   `json:"run_number"`
+	DurationMs int64 `json:"duration_ms"`
+	Regression bool  `json:"regression"` // 运行结束时被标注为性能回退
+	Excluded   bool  `json:"excluded"`   // 仅重跑失败步骤或触及资源限制，不计入基线
+}
+
+// stepInsight 一个步骤的耗时基线与最近运行的实际耗时
+type stepInsight struct {
+	Name     string            `json:"name"`
+	Baseline baseline.Baseline `json:"baseline"`
+	Points   []durationPoint   `json:"points"`
+}
+
+// GetPerformanceThresholds 获取流水线的性能回退检查阈值，以及合并全局配置后生效的参数
+func (h *PipelineHandler) GetPerformanceThresholds(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"thresholds": performanceThresholds(pipeline),
+		"effective":  baseline.For(pipeline, &config.GetConfig().Deploy),
+	})
+}
+
+// UpdatePerformanceThresholds 设置流水线的性能回退检查阈值，0 表示使用全局配置
+func (h *PipelineHandler) UpdatePerformanceThresholds(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	var req models.PerformanceThresholdsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if (req.Multiplier != 0 && req.Multiplier <= 1) || req.BaselineRuns < 0 || req.BaselineRuns > maxInsightRuns || req.MinStepSeconds < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "性能回退阈值无效")
+		return
+	}
+
+	before := performanceThresholds(pipeline)
+	if err := database.DB.Model(pipeline).Updates(map[string]interface{}{
+		"perf_multiplier":       req.Multiplier,
+		"perf_baseline_runs":    req.BaselineRuns,
+		"perf_min_step_seconds": req.MinStepSeconds,
+		"perf_check_disabled":   req.Disabled,
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新流水线失败")
+		return
+	}
+	pipeline.PerfMultiplier = req.Multiplier
+	pipeline.PerfBaselineRuns = req.BaselineRuns
+	pipeline.PerfMinStepSeconds = req.MinStepSeconds
+	pipeline.PerfCheckDisabled = req.Disabled
+
+	recordAuditChange(c, "update_performance_thresholds", "pipeline", pipeline.ID,
+		fmt.Sprintf("设置流水线 %s 的性能回退阈值", pipeline.Name),
+		thresholdsText(before), thresholdsText(performanceThresholds(pipeline)))
+
+	utils.SuccessResponse(c, gin.H{
+		"thresholds": performanceThresholds(pipeline),
+		"effective":  baseline.For(pipeline, &config.GetConfig().Deploy),
+	})
+}
+
+// GetDurationInsights 流水线默认分支上最近成功运行的步骤耗时，附带每个步骤当前的基线与正常波动范围，
+// runs 指定运行数，默认为基线使用的运行数
+func (h *PipelineHandler) GetDurationInsights(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+	settings := baseline.For(pipeline, &config.GetConfig().Deploy)
+
+	runs, _ := strconv.Atoi(c.DefaultQuery("runs", strconv.Itoa(settings.Runs)))
+	if runs <= 0 || runs > maxInsightRuns {
+		runs = maxInsightRuns
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, pipeline.ProjectID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+
+	samples, err := baseline.Samples(pipeline.ID, project.Branch, settings.Runs, 0)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	baselines := baseline.Build(samples, settings.Multiplier)
+
+	var recent []models.PipelineRun
+	if err := database.DB.Where("pipeline_id = ? AND branch = ? AND status = ?", pipeline.ID, project.Branch, models.RunStatusSuccess).
+		Order("id DESC").Limit(runs).Find(&recent).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	runIDs := make([]uint, len(recent))
+	runsByID := make(map[uint]*models.PipelineRun, len(recent))
+	for i := range recent {
+		runIDs[i] = recent[i].ID
+		runsByID[recent[i].ID] = &recent[i]
+	}
+
+	var steps []models.PipelineStep
+	var annotations []models.RunAnnotation
+	if len(runIDs) > 0 {
+		if err := database.DB.Where("pipeline_run_id IN ? AND status = ?", runIDs, models.StepStatusSuccess).
+			Order("pipeline_run_id, step_order").Find(&steps).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+			return
+		}
+		if err := database.DB.Where("pipeline_run_id IN ? AND kind = ?", runIDs, models.AnnotationPerformanceRegression).
+			Find(&annotations).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+			return
+		}
+	}
+	flagged := make(map[uint]bool, len(annotations))
+	for _, annotation := range annotations {
+		if annotation.PipelineStepID != nil {
+			flagged[*annotation.PipelineStepID] = true
+		}
+	}
+
+	// 步骤按首次出现的顺序排列，点按运行先后排列
+	insights := []*stepInsight{}
+	byName := make(map[string]*stepInsight)
+	for _, step := range steps {
+		insight, ok := byName[step.Name]
+		if !ok {
+			insight = &stepInsight{Name: step.Name, Baseline: baselines[step.Name], Points: []durationPoint{}}
+			byName[step.Name] = insight
+			insights = append(insights, insight)
+		}
+		run := runsByID[step.PipelineRunID]
+		insight.Points = append(insight.Points, durationPoint{
+			RunID:      run.ID,
+			RunNumber:  run.RunNumber,
+			DurationMs: step.DurationMs,
+			Regression: flagged[step.ID],
+			Excluded:   run.RerunOfID != nil || run.ResourceLimited,
+		})
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"pipeline_id": pipeline.ID,
+		"branch":      project.Branch,
+		"settings":    settings,
+		"steps":       insights,
+	})
+}
+
+// performanceThresholds 流水线自身设置的性能回退阈值
+func performanceThresholds(pipeline *models.Pipeline) models.PerformanceThresholdsRequest {
+	return models.PerformanceThresholdsRequest{
+		Multiplier:     pipeline.PerfMultiplier,
+		BaselineRuns:   pipeline.PerfBaselineRuns,
+		MinStepSeconds: pipeline.PerfMinStepSeconds,
+		Disabled:       pipeline.PerfCheckDisabled,
+	}
+}
+
+// thresholdsText 性能回退阈值的文本形式，用于审计日志中的变更对比
+func thresholdsText(t models.PerformanceThresholdsRequest) string {
+	return fmt.Sprintf("perf_multiplier: %g\nperf_baseline_runs: %d\nperf_min_step_seconds: %d\nperf_check_disabled: %t\n",
+		t.Multiplier, t.BaselineRuns, t.MinStepSeconds, t.Disabled)
+}
@@ -1,17 +1,37 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	"flowforge/pkg/database"
+	"flowforge/pkg/export"
+	"flowforge/pkg/interlock"
+	"flowforge/pkg/logarchive"
 	"flowforge/pkg/models"
 	"flowforge/pkg/pipeline"
+	"flowforge/pkg/policy"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/runlabel"
+	"flowforge/pkg/scheduler"
+	"flowforge/pkg/testreport"
 	"flowforge/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// maxPrewarmMinutes 定时流水线预热最多提前的分钟数
+const maxPrewarmMinutes = 720
+
+// maxInsightRuns 测试稳定性与步骤耗时趋势统计最多回看的运行数
+const maxInsightRuns = 100
+
 // PipelineHandler 流水线处理器
 type PipelineHandler struct{
 	engine *pipeline.Engine
@@ -26,7 +46,10 @@ func NewPipelineHandler(engine *pipeline.Engine) *PipelineHandler {
 
 // GetPipelines 获取流水线列表
 func (h *PipelineHandler) GetPipelines(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
@@ -35,10 +58,10 @@ func (h *PipelineHandler) GetPipelines(c *gin.Context) {
 
 	query := database.DB.Model(&models.Pipeline{}).Preload("Project")
 	
-	// 非管理员只能查看自己的流水线
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
+	// 非管理员只能查看自己的及作为成员加入的项目的流水线
+	if !current.IsAdmin() {
 		query = query.Joins("JOIN projects ON pipelines.project_id = projects.id").
-			Where("projects.user_id = ?", userID)
+			Where(projectAccess(current.ID, actionView))
 	}
 
 	query.Count(&total)
@@ -60,15 +83,56 @@ func (h *PipelineHandler) CreatePipeline(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
 		return
 	}
+	if !normalizePipelineRequest(c, &req) {
+		return
+	}
 
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	// 检查项目是否存在且属于当前用户
 	var project models.Project
-	if err := database.DB.Where("id = ? AND user_id = ?", req.ProjectID, userID).First(&project).Error; err != nil {
+	if err := database.DB.Where("id = ? AND user_id = ?", req.ProjectID, current.ID).First(&project).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
 		return
 	}
+	if project.IsArchived() {
+		utils.ErrorResponse(c, http.StatusConflict, models.ErrProjectArchived.Error())
+		return
+	}
+	if !validateMutexGroup(c, &project, req.MutexGroup) {
+		return
+	}
+	if !validatePipelineConfig(c, &req) {
+		return
+	}
+	if !validateSourceSteps(c, &project, &req) {
+		return
+	}
+	if !validateSharedReferences(c, &req) {
+		return
+	}
+	if !h.validateShells(c, &project, &req) {
+		return
+	}
+	if !validateOutboundTargets(c, &project, &req) {
+		return
+	}
+	envWarnings, ok := validateEnvReferences(c, &project, &req)
+	if !ok {
+		return
+	}
+	lintWarnings, ok := lintPipelineConfig(c, &req)
+	if !ok {
+		return
+	}
+	// 试运行只校验，不保存
+	if c.Query("dry_run") == "true" {
+		utils.SuccessResponse(c, gin.H{"dry_run": true, "env_warnings": envWarnings, "warnings": lintWarnings})
+		return
+	}
 
 	pipeline := models.Pipeline{
 		Name:        req.Name,
@@ -78,28 +142,62 @@ func (h *PipelineHandler) CreatePipeline(c *gin.Context) {
 		CronExpr:    req.CronExpr,
 		ProjectID:   req.ProjectID,
 		Status:      models.PipelineStatusActive,
+
+		ConfigSource: req.ConfigSource,
+		ConfigPath:   req.ConfigPath,
+		AllowForkPRs: req.AllowForkPRs,
+
+		PrewarmMinutes: req.PrewarmMinutes,
+		PrewarmCaches:  req.PrewarmCaches,
+
+		MutexGroup: req.MutexGroup,
+
+		SkipIfUnchanged:   req.SkipIfUnchanged,
+		SkipCompareInputs: req.SkipCompareInputs,
+		SkipPaths:         req.SkipPaths,
+
+		StrictEnv: req.StrictEnv,
+		EnvIgnore: req.EnvIgnore,
+
+		DebugOnFailure: req.DebugOnFailure,
+
+		ProcessNice:    req.ProcessNice,
+		ProcessIOClass: req.ProcessIOClass,
+		ProcessIOLevel: req.ProcessIOLevel,
 	}
 
-	if err := database.DB.Create(&pipeline).Error; err != nil {
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&pipeline).Error; err != nil {
+			return err
+		}
+		_, err := createRevision(tx, &pipeline, &current.ID)
+		return err
+	}); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "创建流水线失败")
 		return
 	}
 
+	recordLintSuppressions(c, pipeline.ID, lintWarnings)
+	pipeline.EnvWarnings = envWarnings
+	pipeline.LintWarnings = lintWarnings
 	utils.SuccessResponse(c, pipeline)
 }
 
 // GetPipeline 获取流水线详情
 func (h *PipelineHandler) GetPipeline(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var pipeline models.Pipeline
 	query := database.DB.Preload("Project").Preload("PipelineRuns")
 
-	// 非管理员只能查看自己的流水线
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
+	// 非管理员只能查看自己的及作为成员加入的项目的流水线
+	if !current.IsAdmin() {
 		query = query.Joins("JOIN projects ON pipelines.project_id = projects.id").
-			Where("projects.user_id = ?", userID)
+			Where(projectAccess(current.ID, actionView))
 	}
 
 	if err := query.First(&pipeline, id).Error; err != nil {
@@ -107,58 +205,157 @@ func (h *PipelineHandler) GetPipeline(c *gin.Context) {
 		return
 	}
 
+	// 附带生效的策略，标明继承自项目策略的部分
+	if projectPolicy, err := policy.Load(database.DB, pipeline.ProjectID); err == nil {
+		pipeline.Policy = policy.For(projectPolicy, &pipeline)
+	}
+
 	utils.SuccessResponse(c, pipeline)
 }
 
 // UpdatePipeline 更新流水线
 func (h *PipelineHandler) UpdatePipeline(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var pipeline models.Pipeline
 	query := database.DB.Joins("JOIN projects ON pipelines.project_id = projects.id")
 
 	// 非管理员只能更新自己的流水线
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
-		query = query.Where("projects.user_id = ?", userID)
+	if !current.IsAdmin() {
+		query = query.Where(projectAccess(current.ID, actionEdit))
 	}
 
 	if err := query.First(&pipeline, id).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "流水线不存在")
 		return
 	}
+	if isProjectArchived(pipeline.ProjectID) {
+		utils.ErrorResponse(c, http.StatusConflict, models.ErrProjectArchived.Error())
+		return
+	}
 
 	var req models.CreatePipelineRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
 		return
 	}
+	if !normalizePipelineRequest(c, &req) {
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, pipeline.ProjectID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+	if !validateMutexGroup(c, &project, req.MutexGroup) {
+		return
+	}
+	if !validatePipelineConfig(c, &req) {
+		return
+	}
+	if !validateSourceSteps(c, &project, &req) {
+		return
+	}
+	if !validateSharedReferences(c, &req) {
+		return
+	}
+	if !h.validateShells(c, &project, &req) {
+		return
+	}
+	if !validateOutboundTargets(c, &project, &req) {
+		return
+	}
+	envWarnings, ok := validateEnvReferences(c, &project, &req)
+	if !ok {
+		return
+	}
+	lintWarnings, ok := lintPipelineConfig(c, &req)
+	if !ok {
+		return
+	}
+	// 试运行只校验，不保存
+	if c.Query("dry_run") == "true" {
+		utils.SuccessResponse(c, gin.H{"dry_run": true, "env_warnings": envWarnings, "warnings": lintWarnings})
+		return
+	}
+
+	// 功能上线前创建的流水线没有版本，先将修改前的内容保存为第一个版本
+	previous, err := findRevision(pipeline.ID, "latest")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var created *models.PipelineRevision
+		created, err = createRevision(database.DB, &pipeline, nil)
+		if created != nil {
+			previous = *created
+		}
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新流水线失败")
+		return
+	}
 
 	pipeline.Name = req.Name
 	pipeline.Description = req.Description
 	pipeline.Config = req.Config
 	pipeline.Trigger = req.Trigger
 	pipeline.CronExpr = req.CronExpr
-
-	if err := database.DB.Save(&pipeline).Error; err != nil {
+	pipeline.ConfigSource = req.ConfigSource
+	pipeline.ConfigPath = req.ConfigPath
+	pipeline.AllowForkPRs = req.AllowForkPRs
+	pipeline.PrewarmMinutes = req.PrewarmMinutes
+	pipeline.PrewarmCaches = req.PrewarmCaches
+	pipeline.MutexGroup = req.MutexGroup
+	pipeline.SkipIfUnchanged = req.SkipIfUnchanged
+	pipeline.SkipCompareInputs = req.SkipCompareInputs
+	pipeline.SkipPaths = req.SkipPaths
+	pipeline.StrictEnv = req.StrictEnv
+	pipeline.EnvIgnore = req.EnvIgnore
+	pipeline.DebugOnFailure = req.DebugOnFailure
+	pipeline.ProcessNice = req.ProcessNice
+	pipeline.ProcessIOClass = req.ProcessIOClass
+	pipeline.ProcessIOLevel = req.ProcessIOLevel
+
+	var revision *models.PipelineRevision
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&pipeline).Error; err != nil {
+			return err
+		}
+		revision, err = createRevision(tx, &pipeline, &current.ID)
+		return err
+	}); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "更新流水线失败")
 		return
 	}
 
+	recordAuditChange(c, "update_pipeline", "pipeline", pipeline.ID,
+		fmt.Sprintf("更新流水线 %s（版本 %d → %d）", pipeline.Name, previous.Revision, revision.Revision),
+		maskForProject(pipeline.ProjectID, revisionText(&previous)),
+		maskForProject(pipeline.ProjectID, revisionText(revision)))
+
+	recordLintSuppressions(c, pipeline.ID, lintWarnings)
+	pipeline.EnvWarnings = envWarnings
+	pipeline.LintWarnings = lintWarnings
 	utils.SuccessResponse(c, pipeline)
 }
 
 // DeletePipeline 删除流水线
 func (h *PipelineHandler) DeletePipeline(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var pipeline models.Pipeline
 	query := database.DB.Joins("JOIN projects ON pipelines.project_id = projects.id")
 
 	// 非管理员只能删除自己的流水线
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
-		query = query.Where("projects.user_id = ?", userID)
+	if !current.IsAdmin() {
+		query = query.Where(projectAccess(current.ID, actionEdit))
 	}
 
 	if err := query.First(&pipeline, id).Error; err != nil {
@@ -177,16 +374,29 @@ func (h *PipelineHandler) DeletePipeline(c *gin.Context) {
 // RunPipeline 运行流水线
 func (h *PipelineHandler) RunPipeline(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	// 请求体可以为空
+	var req models.RunPipelineRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+	}
+	opts := pipeline.RunOptions{DebugEnv: req.DebugEnv, KeepWorkspace: req.KeepWorkspace, Force: req.Force, Artifact: req.Artifact}
 
 	// 检查流水线是否存在且有权限
 	var pipeline models.Pipeline
 	query := database.DB.Preload("Project")
 
-	// 非管理员只能运行自己的流水线
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
+	// 非管理员只能运行自己的及作为 maintainer 加入的项目的流水线
+	if !current.IsAdmin() {
 		query = query.Joins("JOIN projects ON pipelines.project_id = projects.id").
-			Where("projects.user_id = ?", userID)
+			Where(projectAccess(current.ID, actionTrigger))
 	}
 
 	if err := query.First(&pipeline, id).Error; err != nil {
@@ -194,8 +404,31 @@ func (h *PipelineHandler) RunPipeline(c *gin.Context) {
 		return
 	}
 
+	// 归档项目、源码包项目与环境变量调试的前置条件，权限排查接口使用同样的检查
+	if failed := firstFailure(manualTriggerChecks(&pipeline.Project, current, opts.DebugEnv)); failed != nil {
+		utils.ErrorResponse(c, failed.status, failed.Detail)
+		return
+	}
+	labels, err := runlabel.Check(&pipeline.Project, req.Labels)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Labels = labels
+
 	// 运行流水线
-	pipelineRun, err := h.engine.RunPipeline(pipeline.ID, models.TriggerTypeManual, userID.(uint))
+	pipelineRun, err := h.engine.RunPipelineWithOptions(pipeline.ID, models.TriggerTypeManual, current.ID, opts)
+	if errors.Is(err, models.ErrProjectArchived) {
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	}
+	if configErrorResponse(c, err) {
+		return
+	}
+	if invalidArtifact(err) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "启动流水线失败: "+err.Error())
 		return
@@ -204,10 +437,21 @@ func (h *PipelineHandler) RunPipeline(c *gin.Context) {
 	utils.SuccessResponse(c, pipelineRun)
 }
 
-// GetPipelineRuns 获取流水线运行记录
+// invalidArtifact registry 项目的运行缺少制品或制品引用无效
+func invalidArtifact(err error) bool {
+	return errors.Is(err, pipeline.ErrArtifactRequired) || errors.Is(err, pipeline.ErrInvalidArtifact)
+}
+
+// GetPipelineRuns 获取流水线运行记录，按创建时间倒序、同一时间按ID倒序。
+// 支持两种分页：page/page_size 偏移分页；cursor/limit 游标分页，首页只传 limit，
+// 之后传上一页返回的 next_cursor，next_cursor 为空表示没有更多记录。label 按标签过滤，逗号分隔时需要带有全部标签，
+// from、to 按创建日期过滤。?format=csv|ndjson 或对应的 Accept 头时按同样的条件不分页导出
 func (h *PipelineHandler) GetPipelineRuns(c *gin.Context) {
 	pipelineID := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
@@ -215,8 +459,8 @@ func (h *PipelineHandler) GetPipelineRuns(c *gin.Context) {
 	var pipeline models.Pipeline
 	query := database.DB.Joins("JOIN projects ON pipelines.project_id = projects.id")
 
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
-		query = query.Where("projects.user_id = ?", userID)
+	if !current.IsAdmin() {
+		query = query.Where(projectAccess(current.ID, actionView))
 	}
 
 	if err := query.First(&pipeline, pipelineID).Error; err != nil {
@@ -229,8 +473,55 @@ func (h *PipelineHandler) GetPipelineRuns(c *gin.Context) {
 	var total int64
 
 	runQuery := database.DB.Model(&models.PipelineRun{}).Where("pipeline_id = ?", pipelineID)
+	runQuery = runlabel.Filter(runQuery, "pipeline_runs.id", runlabel.ParseQuery(c.Query("label")))
+	if reason := c.Query("cancellation_reason"); reason != "" {
+		runQuery = runQuery.Where("cancellation_reason = ?", reason)
+	}
+	dates, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	runQuery = dates.apply(runQuery, "pipeline_runs.created_at")
+
+	if format := exportFormat(c); format != "" {
+		redactor := redact.ForProject(pipeline.ProjectID)
+		spec := exportSpec{format: format, resource: "runs-" + pipeline.Name, dates: dates, header: export.RunColumns}
+		writeExport(c, runQuery, spec, func(db *gorm.DB) *gorm.DB {
+			return db.Select(tableColumns("pipeline_runs", export.RunColumns)).Order("pipeline_runs.created_at DESC").Order("pipeline_runs.id DESC")
+		}, func(row *export.RunRow) {
+			row.ErrorMsg = redactor.Redact(row.ErrorMsg)
+		})
+		return
+	}
 	runQuery.Count(&total)
-	runQuery.Order("created_at DESC").Scopes(database.Paginate(page, pageSize)).Find(&runs)
+	runQuery = runQuery.Preload("Labels", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+
+	// 游标分页：运行持续创建时翻页也不会重复或遗漏
+	if c.Query("cursor") != "" || c.Query("limit") != "" {
+		cursor, err := database.DecodeCursor(c.Query("cursor"))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的分页游标")
+			return
+		}
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		limit = database.CursorLimit(limit)
+		runQuery.Scopes(database.CursorPaginate(cursor, limit)).Find(&runs)
+
+		response := models.PaginationResponse{Total: total, PageSize: limit}
+		if len(runs) > limit {
+			runs = runs[:limit]
+			last := runs[limit-1]
+			response.NextCursor = database.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		response.Data = runs
+		utils.SuccessResponse(c, response)
+		return
+	}
+
+	// 偏移分页保留向后兼容，运行创建期间翻页可能出现重复
+	runQuery.Order("created_at DESC").Order("id DESC").Scopes(database.Paginate(page, pageSize)).Find(&runs)
 
 	utils.SuccessResponse(c, models.PaginationResponse{
 		Data:       runs,
@@ -244,16 +535,21 @@ func (h *PipelineHandler) GetPipelineRuns(c *gin.Context) {
 // GetPipelineRun 获取流水线运行详情
 func (h *PipelineHandler) GetPipelineRun(c *gin.Context) {
 	runID := c.Param("runId")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var pipelineRun models.PipelineRun
-	query := database.DB.Preload("Pipeline.Project")
+	query := database.DB.Preload("Pipeline.Project").Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_order ASC")
+	})
 
-	// 非管理员只能查看自己的流水线运行
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
+	// 非管理员只能查看自己的及作为成员加入的项目的流水线运行
+	if !current.IsAdmin() {
 		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
 			Joins("JOIN projects ON pipelines.project_id = projects.id").
-			Where("projects.user_id = ?", userID)
+			Where(projectAccess(current.ID, actionView))
 	}
 
 	if err := query.First(&pipelineRun, runID).Error; err != nil {
@@ -261,22 +557,279 @@ func (h *PipelineHandler) GetPipelineRun(c *gin.Context) {
 		return
 	}
 
+	database.DB.Where("pipeline_run_id = ?", pipelineRun.ID).Order("id").Find(&pipelineRun.Labels)
+	pipelineRun.RerunAvailable = h.engine.CanRerunFailed(&pipelineRun)
+	pipelineRun.DebugAvailable = h.engine.DebugAvailable(&pipelineRun)
+
+	// 附带各步骤的测试结果及运行汇总
+	var results []models.TestResult
+	database.DB.Preload("Failures").Where("pipeline_run_id = ?", pipelineRun.ID).Find(&results)
+	for i := range results {
+		for j := range pipelineRun.Steps {
+			if pipelineRun.Steps[j].ID == results[i].PipelineStepID {
+				pipelineRun.Steps[j].TestResult = &results[i]
+			}
+		}
+	}
+	pipelineRun.TestSummary = models.SummarizeTests(results)
+
+	// 附带 external_wait 步骤等待的内容与开始等待的时间
+	var waits []models.ExternalWait
+	database.DB.Where("pipeline_run_id = ?", pipelineRun.ID).Find(&waits)
+	for i := range waits {
+		for j := range pipelineRun.Steps {
+			if pipelineRun.Steps[j].ID == waits[i].PipelineStepID {
+				pipelineRun.Steps[j].ExternalWait = &waits[i]
+			}
+		}
+	}
+
+	// 附带 artifact_from 取用的制品来源，以及本次运行的制品被哪些运行取用
+	database.DB.Preload("ConsumedArtifact").Where("pipeline_run_id = ?", pipelineRun.ID).Order("id").Find(&pipelineRun.ConsumedArtifacts)
+	database.DB.Where("source_run_id = ?", pipelineRun.ID).Order("id").Find(&pipelineRun.ArtifactConsumers)
+
+	// 附带运行标注，如步骤耗时超过基线
+	database.DB.Where("pipeline_run_id = ?", pipelineRun.ID).Order("id").Find(&pipelineRun.Annotations)
+
+	// 运行中附带进度与预计完成时间
+	if snapshot, ok := h.engine.RunProgress(pipelineRun.ID); ok {
+		pipelineRun.Progress = snapshot
+	}
+
 	utils.SuccessResponse(c, pipelineRun)
 }
 
+// GetTestInsights 统计最近若干次有测试报告的运行中各失败用例的稳定性，label 只统计带有这些标签的运行
+func (h *PipelineHandler) GetTestInsights(c *gin.Context) {
+	pipelineID := c.Param("id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("runs", "30"))
+	if limit <= 0 || limit > maxInsightRuns {
+		limit = maxInsightRuns
+	}
+
+	// 检查流水线权限
+	var pipeline models.Pipeline
+	query := database.DB.Joins("JOIN projects ON pipelines.project_id = projects.id")
+	if !current.IsAdmin() {
+		query = query.Where(projectAccess(current.ID, actionView))
+	}
+	if err := query.First(&pipeline, pipelineID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线不存在")
+		return
+	}
+
+	var runIDs []uint
+	results := runlabel.Filter(database.DB.Model(&models.TestResult{}), "pipeline_run_id", runlabel.ParseQuery(c.Query("label")))
+	results.Where("pipeline_id = ?", pipeline.ID).
+		Distinct("pipeline_run_id").Order("pipeline_run_id DESC").Limit(limit).Pluck("pipeline_run_id", &runIDs)
+
+	var failures []models.TestCaseFailure
+	if len(runIDs) > 0 {
+		database.DB.Select("name", "pipeline_run_id").Where("pipeline_run_id IN ?", runIDs).Find(&failures)
+	}
+
+	// 按运行先后顺序（旧到新）整理每次运行的失败用例
+	runs := make([]testreport.RunFailures, len(runIDs))
+	index := make(map[uint]int, len(runIDs))
+	for i, id := range runIDs {
+		pos := len(runIDs) - 1 - i
+		runs[pos] = testreport.RunFailures{RunID: id, Failed: make(map[string]bool)}
+		index[id] = pos
+	}
+	for _, f := range failures {
+		runs[index[f.PipelineRunID]].Failed[f.Name] = true
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"runs":  len(runs),
+		"tests": testreport.Flakiness(runs),
+	})
+}
+
+// GetRunStats 按状态统计流水线的运行数。skipped 运行单独计数，不计入成功率，其中没有变化而跳过的运行另计节省的执行小时数；label 只统计带有这些标签的运行
+func (h *PipelineHandler) GetRunStats(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	runs := runlabel.Filter(database.DB.Model(&models.PipelineRun{}), "id", runlabel.ParseQuery(c.Query("label")))
+	if err := runs.Where("pipeline_id = ?", pipeline.ID).
+		Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+
+	var total int64
+	byStatus := make(map[string]int64)
+	for _, row := range rows {
+		byStatus[row.Status] = row.Count
+		if row.Status != models.RunStatusSkipped {
+			total += row.Count
+		}
+	}
+
+	// 成功率只统计已执行并结束的运行
+	finished := byStatus[models.RunStatusSuccess] + byStatus[models.RunStatusFailed] + byStatus[models.RunStatusCancelled]
+	successRate := 0.0
+	if finished > 0 {
+		successRate = float64(byStatus[models.RunStatusSuccess]) / float64(finished)
+	}
+
+	// 没有变化而跳过的运行按对应的上次成功运行的耗时计算节省的执行时间
+	var unchanged struct {
+		Count   int64
+		SavedMs int64
+	}
+	saved := runlabel.Filter(database.DB.Model(&models.PipelineRun{}), "id", runlabel.ParseQuery(c.Query("label")))
+	if err := saved.Where("pipeline_id = ? AND unchanged_from_id IS NOT NULL", pipeline.ID).
+		Select("COUNT(*) AS count, COALESCE(SUM(saved_ms), 0) AS saved_ms").Scan(&unchanged).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+
+	// 取消与超时中止的运行按取消原因统计，原因为固定取值
+	var reasonRows []struct {
+		CancellationReason string
+		Count              int64
+	}
+	reasons := runlabel.Filter(database.DB.Model(&models.PipelineRun{}), "id", runlabel.ParseQuery(c.Query("label")))
+	if err := reasons.Where("pipeline_id = ? AND cancellation_reason <> ''", pipeline.ID).
+		Select("cancellation_reason, COUNT(*) AS count").Group("cancellation_reason").Scan(&reasonRows).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	byCancelReason := make(map[string]int64, len(reasonRows))
+	for _, row := range reasonRows {
+		byCancelReason[row.CancellationReason] = row.Count
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"total":             total,
+		"skipped":           byStatus[models.RunStatusSkipped],
+		"skipped_unchanged": unchanged.Count,
+		"saved_hours":       float64(unchanged.SavedMs) / float64(time.Hour/time.Millisecond),
+		"by_status":         byStatus,
+		"by_cancel_reason":  byCancelReason,
+		"success_rate":      successRate,
+	})
+}
+
+// RerunFailedSteps 仅重跑失败步骤
+func (h *PipelineHandler) RerunFailedSteps(c *gin.Context) {
+	pipelineID := c.Param("id")
+	runID := c.Param("runId")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	// 检查权限
+	var pipelineRun models.PipelineRun
+	query := database.DB.Preload("Pipeline.Project").Where("pipeline_runs.pipeline_id = ?", pipelineID)
+
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionTrigger))
+	}
+
+	if err := query.First(&pipelineRun, runID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+
+	if failed := firstFailure(h.rerunChecks(&pipelineRun.Pipeline.Project, &pipelineRun)); failed != nil {
+		utils.ErrorResponse(c, failed.status, failed.Detail)
+		return
+	}
+
+	newRun, err := h.engine.RerunFailed(pipelineRun.ID, current.ID)
+	if errors.Is(err, models.ErrProjectArchived) {
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	}
+	if configErrorResponse(c, err) {
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "重跑失败步骤失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, newRun)
+}
+
+// RetryPipelineRun 重新运行：按原运行的提交重新执行全部步骤。
+// ?original_inputs=true 时使用原运行的流水线版本与共享资源版本，否则使用当前的配置与共享资源的最新版本
+func (h *PipelineHandler) RetryPipelineRun(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	// 检查权限
+	var pipelineRun models.PipelineRun
+	query := database.DB.Preload("Pipeline.Project").Where("pipeline_runs.pipeline_id = ?", c.Param("id"))
+
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionTrigger))
+	}
+
+	if err := query.First(&pipelineRun, c.Param("runId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+	if failed := firstFailure([]accessCheck{archivedCheck(&pipelineRun.Pipeline.Project)}); failed != nil {
+		utils.ErrorResponse(c, failed.status, failed.Detail)
+		return
+	}
+
+	newRun, err := h.engine.RetryRun(pipelineRun.ID, current.ID, c.Query("original_inputs") == "true")
+	switch {
+	case errors.Is(err, models.ErrProjectArchived), errors.Is(err, pipeline.ErrInputsUnavailable),
+		errors.Is(err, pipeline.ErrRetrySkipped), errors.Is(err, pipeline.ErrSourceArchiveRequired):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	case configErrorResponse(c, err):
+		return
+	case invalidArtifact(err):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "重新运行失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, newRun)
+}
+
 // CancelPipelineRun 取消流水线运行
 func (h *PipelineHandler) CancelPipelineRun(c *gin.Context) {
 	runID := c.Param("runId")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	// 检查权限
 	var pipelineRun models.PipelineRun
 	query := database.DB.Preload("Pipeline.Project")
 
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
+	if !current.IsAdmin() {
 		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
 			Joins("JOIN projects ON pipelines.project_id = projects.id").
-			Where("projects.user_id = ?", userID)
+			Where(projectAccess(current.ID, actionTrigger))
 	}
 
 	if err := query.First(&pipelineRun, runID).Error; err != nil {
@@ -284,9 +837,18 @@ func (h *PipelineHandler) CancelPipelineRun(c *gin.Context) {
 		return
 	}
 
+	// 请求体可以为空，reason 记录为取消说明
+	var req models.CancelRunRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+	}
+
 	// 取消运行
 	runIDUint, _ := strconv.ParseUint(runID, 10, 32)
-	if err := h.engine.CancelPipelineRun(uint(runIDUint)); err != nil {
+	if err := h.engine.CancelPipelineRun(uint(runIDUint), pipeline.UserCancellation(current.ID, req.Reason)); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "取消流水线运行失败: "+err.Error())
 		return
 	}
@@ -294,19 +856,86 @@ func (h *PipelineHandler) CancelPipelineRun(c *gin.Context) {
 	utils.SuccessResponse(c, nil)
 }
 
-// GetPipelineRunLogs 获取流水线运行日志
+// CancelAllRuns 取消流水线全部排队中与运行中的运行。需要两步确认，回传的名称为流水线名称；没有未结束的运行时直接返回
+func (h *PipelineHandler) CancelAllRuns(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var target models.Pipeline
+	query := database.DB.Model(&models.Pipeline{})
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionTrigger))
+	}
+	if err := query.First(&target, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线不存在")
+		return
+	}
+
+	var runs []models.PipelineRun
+	if err := database.DB.Where("pipeline_id = ? AND status IN ?", target.ID,
+		[]string{models.RunStatusPending, models.RunStatusRunning}).Order("id").Find(&runs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取流水线运行记录失败")
+		return
+	}
+	if len(runs) == 0 {
+		utils.SuccessResponse(c, gin.H{"cancelled": 0})
+		return
+	}
+
+	var pending, running int64
+	for _, run := range runs {
+		if run.Status == models.RunStatusPending {
+			pending++
+		} else {
+			running++
+		}
+	}
+	if !confirmDestructive(c, interlock.OperationCancelAllRuns, "pipeline", target.ID, target.Name, func() (interlock.Summary, error) {
+		return cancelAllSummary(target.Name, pending, running), nil
+	}) {
+		return
+	}
+
+	// 逐个取消，确认期间已结束的运行取消失败，不计入
+	var req models.CancelRunRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+	}
+	cancelled := 0
+	for _, run := range runs {
+		if err := h.engine.CancelPipelineRun(run.ID, pipeline.UserCancellation(current.ID, req.Reason)); err == nil {
+			cancelled++
+		}
+	}
+
+	recordAudit(c, interlock.OperationCancelAllRuns, "pipeline", target.ID,
+		fmt.Sprintf("取消流水线 %s 的全部运行（%d 个）", target.Name, cancelled))
+
+	utils.SuccessResponse(c, gin.H{"cancelled": cancelled})
+}
+
+// GetPipelineRunLogs 获取流水线运行日志，offset 与 limit 指定从第几行起读取多少行，未指定 limit 时读取到末尾
 func (h *PipelineHandler) GetPipelineRunLogs(c *gin.Context) {
 	runID := c.Param("runId")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	// 检查权限
 	var pipelineRun models.PipelineRun
 	query := database.DB.Preload("Pipeline.Project")
 
-	if role, exists := c.Get("role"); !exists || role != models.RoleAdmin {
+	if !current.IsAdmin() {
 		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
 			Joins("JOIN projects ON pipelines.project_id = projects.id").
-			Where("projects.user_id = ?", userID)
+			Where(projectAccess(current.ID, actionView))
 	}
 
 	if err := query.First(&pipelineRun, runID).Error; err != nil {
@@ -314,15 +943,323 @@ func (h *PipelineHandler) GetPipelineRunLogs(c *gin.Context) {
 		return
 	}
 
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if offset < 0 || limit < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
 	// 获取日志
-	runIDUint, _ := strconv.ParseUint(runID, 10, 32)
-	logs, err := h.engine.GetJobLogs(uint(runIDUint))
+	logs, total, err := h.engine.GetJobLogPage(pipelineRun.ID, offset, limit)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "获取日志失败: "+err.Error())
 		return
 	}
 
 	utils.SuccessResponse(c, map[string]interface{}{
-		"logs": logs,
+		"logs":     logs,
+		"offset":   offset,
+		"total":    total,
+		"archived": pipelineRun.LogArchived,
+	})
+}
+
+// GetInMemoryJobs 列出引擎内存中的任务（管理员）
+func (h *PipelineHandler) GetInMemoryJobs(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"jobs":         h.engine.ListJobs(),
+		"log_writer":   h.engine.LogWriterStats(),
+		"log_ingest":   h.engine.LogIngestStats(),
+		"queue":        h.engine.QueueStats(),
+		"deploy_locks": h.engine.DeployLocks().Stats(),
+		"scripts":      h.engine.ScriptStats(),
+	})
+}
+
+// GetDiskUsage 获取工作区磁盘使用情况与各项目的运行日志存储占用（管理员）
+func (h *PipelineHandler) GetDiskUsage(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	usage, err := h.engine.WorkspaceDiskUsage()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取磁盘使用情况失败")
+		return
+	}
+
+	result := gin.H{
+		"disk":                usage,
+		"safety_margin":       h.engine.DiskSafetyMargin(),
+		"below_safety_margin": usage.Free < h.engine.DiskSafetyMargin(),
+	}
+	if store := h.engine.LogArchive(); store != nil {
+		projects, err := store.UsageByProject()
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "获取日志存储占用失败")
+			return
+		}
+		result["logs"] = gin.H{
+			"total":    logarchive.Total(projects),
+			"projects": projects,
+		}
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+// scheduleEntry 定时流水线及其预热状态
+type scheduleEntry struct {
+	PipelineID     uint       `json:"pipeline_id"`
+	PipelineName   string     `json:"pipeline_name"`
+	ProjectID      uint       `json:"project_id"`
+	ProjectName    string     `json:"project_name"`
+	CronExpr       string     `json:"cron_expr"`
+	NextRun        *time.Time `json:"next_run"`
+	PrewarmMinutes int        `json:"prewarm_minutes"`
+	PrewarmFrom    *time.Time `json:"prewarm_from,omitempty"` // 下次预热的开始时间
+	PrewarmStatus  string     `json:"prewarm_status"`
+	PrewarmMessage string     `json:"prewarm_message"`
+	PrewarmAt      *time.Time `json:"prewarm_at"`
+}
+
+// GetSchedules 获取定时流水线及预热状态（管理员）
+func (h *PipelineHandler) GetSchedules(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var pipelines []models.Pipeline
+	if err := database.DB.Preload("Project").Where("cron_expr <> ''").
+		Where(&models.Pipeline{Trigger: models.TriggerSchedule}).Order("id ASC").Find(&pipelines).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取定时流水线失败")
+		return
+	}
+
+	now := time.Now()
+	entries := make([]scheduleEntry, 0, len(pipelines))
+	for _, p := range pipelines {
+		entry := scheduleEntry{
+			PipelineID:     p.ID,
+			PipelineName:   p.Name,
+			ProjectID:      p.ProjectID,
+			ProjectName:    p.Project.Name,
+			CronExpr:       p.CronExpr,
+			PrewarmMinutes: p.PrewarmMinutes,
+			PrewarmStatus:  p.PrewarmStatus,
+			PrewarmMessage: p.PrewarmMessage,
+			PrewarmAt:      p.PrewarmAt,
+		}
+		if next, err := scheduler.NextRun(p.CronExpr, now); err == nil {
+			entry.NextRun = &next
+			if p.PrewarmMinutes > 0 {
+				from := next.Add(-time.Duration(p.PrewarmMinutes) * time.Minute)
+				entry.PrewarmFrom = &from
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"schedules": entries,
+		"prewarm":   h.engine.PrewarmStats(),
+	})
+}
+
+// GetResolvedConfig 获取运行开始时的配置快照（已脱敏）
+func (h *PipelineHandler) GetResolvedConfig(c *gin.Context) {
+	runID := c.Param("runId")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var pipelineRun models.PipelineRun
+	query := database.DB.Where("pipeline_runs.pipeline_id = ?", c.Param("id"))
+
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionView))
+	}
+
+	if err := query.First(&pipelineRun, runID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+
+	snapshot, err := pipeline.DecodeResolvedConfig(&pipelineRun)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, snapshot)
+}
+
+// GetRunInputs 获取运行引用的输入（流水线配置或仓库配置文件，以及共享的模板、环境变量组与脚本）及实际使用的版本，
+// url 为查看该版本内容的接口
+func (h *PipelineHandler) GetRunInputs(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var pipelineRun models.PipelineRun
+	query := database.DB.Preload("Pipeline").Where("pipeline_runs.pipeline_id = ?", c.Param("id"))
+
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionView))
+	}
+
+	if err := query.First(&pipelineRun, c.Param("runId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+
+	inputs := pipeline.RunInputs(&pipelineRun)
+	if inputs == nil {
+		inputs = []models.RunInput{}
+	}
+	utils.SuccessResponse(c, gin.H{
+		"run_id":            pipelineRun.ID,
+		"pipeline_revision": pipelineRun.PipelineRevision,
+		"inputs":            inputs,
+	})
+}
+
+// GetPipelineStep 获取流水线步骤详情，附带步骤实际收到的环境变量（名称与来源层，运行开启调试时含脱敏后的值）
+func (h *PipelineHandler) GetPipelineStep(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var step models.PipelineStep
+	query := database.DB.Joins("JOIN pipeline_runs ON pipeline_steps.pipeline_run_id = pipeline_runs.id").
+		Where("pipeline_steps.pipeline_run_id = ? AND pipeline_runs.pipeline_id = ?", c.Param("runId"), c.Param("id"))
+
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionView))
+	}
+
+	if err := query.First(&step, c.Param("stepId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线步骤不存在")
+		return
+	}
+
+	env, err := pipeline.DecodeStepEnv(&step)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"step": step,
+		"env":  env,
 	})
 }
+
+// isProjectArchived 流水线所属项目是否已归档
+func isProjectArchived(projectID uint) bool {
+	var count int64
+	database.DB.Model(&models.Project{}).Where("id = ? AND status = ?", projectID, models.ProjectStatusArchived).Count(&count)
+	return count > 0
+}
+
+// normalizePipelineRequest 校验并补全配置来源（stored 模式必须提供配置）与预热设置
+func normalizePipelineRequest(c *gin.Context, req *models.CreatePipelineRequest) bool {
+	if !models.IsValidConfigSource(req.ConfigSource) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "不支持的配置来源")
+		return false
+	}
+	if req.ConfigSource == "" {
+		req.ConfigSource = models.ConfigSourceStored
+	}
+
+	if req.ConfigSource == models.ConfigSourceStored && req.Config == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "流水线配置不能为空")
+		return false
+	}
+	if req.ConfigPath == "" {
+		req.ConfigPath = ".flowforge.yml"
+	}
+
+	if req.PrewarmMinutes < 0 || req.PrewarmMinutes > maxPrewarmMinutes {
+		utils.ErrorResponse(c, http.StatusBadRequest, "预热提前时间无效")
+		return false
+	}
+	if req.CronExpr != "" {
+		if _, err := scheduler.NextRun(req.CronExpr, time.Now()); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "定时表达式无效")
+			return false
+		}
+	}
+
+	// 路径规则与 Webhook 路径过滤的语法相同，逐段按 path.Match 校验
+	var paths []string
+	for _, pattern := range strings.Split(req.SkipPaths, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				utils.ErrorResponse(c, http.StatusBadRequest, "跳过检查的路径规则无效")
+				return false
+			}
+		}
+		paths = append(paths, pattern)
+	}
+	req.SkipPaths = strings.Join(paths, ",")
+
+	var ignore []string
+	for _, name := range strings.Split(req.EnvIgnore, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !models.IsValidEnvName(strings.TrimSuffix(name, "*")) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "忽略的环境变量名无效")
+			return false
+		}
+		ignore = append(ignore, name)
+	}
+	req.EnvIgnore = strings.Join(ignore, ",")
+
+	if req.ProcessNice != nil && (*req.ProcessNice < -20 || *req.ProcessNice > 19) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "nice 值需在 -20 到 19 之间")
+		return false
+	}
+	if !models.IsValidIOClass(req.ProcessIOClass) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "不支持的 IO 调度类")
+		return false
+	}
+	if req.ProcessIOLevel != nil && (*req.ProcessIOLevel < 0 || *req.ProcessIOLevel > 7) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "IO 优先级需在 0 到 7 之间")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/policy"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProjectPolicy 获取项目默认策略
+func (h *ProjectHandler) GetProjectPolicy(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	projectPolicy, err := policy.Load(h.db, project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目策略失败")
+		return
+	}
+	utils.SuccessResponse(c, policy.View(projectPolicy))
+}
+
+// UpdateProjectPolicy 设置项目默认策略，对继承的流水线之后的运行立即生效；强制标记与已强制的部分只有管理员可以修改
+func (h *ProjectHandler) UpdateProjectPolicy(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.ProjectPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if problems := policy.Validate(req.NotifyRules, req.Approvals, req.RunTimeoutMinutes); len(problems) > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "策略无效: "+strings.Join(problems, "；"))
+		return
+	}
+
+	projectPolicy, err := policy.Load(h.db, project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目策略失败")
+		return
+	}
+	before := policy.View(projectPolicy)
+
+	if req.NotifyRules == nil {
+		req.NotifyRules = []models.PolicyNotifyRule{}
+	}
+	if req.Approvals == nil {
+		req.Approvals = []models.PolicyApproval{}
+	}
+	if !current.IsAdmin() {
+		enforcedChanged := req.NotifyEnforced != projectPolicy.NotifyEnforced ||
+			req.ApprovalsEnforced != projectPolicy.ApprovalsEnforced ||
+			req.TimeoutEnforced != projectPolicy.TimeoutEnforced
+		weakened := (projectPolicy.NotifyEnforced && policy.Encode(req.NotifyRules) != policy.Encode(before.NotifyRules)) ||
+			(projectPolicy.ApprovalsEnforced && policy.Encode(req.Approvals) != policy.Encode(before.Approvals)) ||
+			(projectPolicy.TimeoutEnforced && req.RunTimeoutMinutes != projectPolicy.RunTimeoutMinutes)
+		if enforcedChanged || weakened {
+			utils.ErrorResponse(c, http.StatusForbidden, "只有管理员可以修改强制的项目策略")
+			return
+		}
+	}
+
+	projectPolicy.NotifyRules = policy.Encode(req.NotifyRules)
+	projectPolicy.Approvals = policy.Encode(req.Approvals)
+	projectPolicy.RunTimeoutMinutes = req.RunTimeoutMinutes
+	projectPolicy.NotifyEnforced = req.NotifyEnforced
+	projectPolicy.ApprovalsEnforced = req.ApprovalsEnforced
+	projectPolicy.TimeoutEnforced = req.TimeoutEnforced
+	if err := h.db.Save(projectPolicy).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存项目策略失败")
+		return
+	}
+
+	view := policy.View(projectPolicy)
+	recordAuditChange(c, "update_project_policy", "project", project.ID,
+		fmt.Sprintf("设置项目 %s 的默认策略", project.Name), policy.Encode(before), policy.Encode(view))
+
+	utils.SuccessResponse(c, view)
+}
+
+// GetPipelinePolicy 获取流水线对项目策略的覆盖，以及合并项目策略后生效的策略
+func (h *PipelineHandler) GetPipelinePolicy(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	projectPolicy, err := policy.Load(database.DB, pipeline.ProjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目策略失败")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"overrides": pipelinePolicyOverrides(pipeline),
+		"effective": policy.For(projectPolicy, pipeline),
+	})
+}
+
+// UpdatePipelinePolicy 设置流水线对项目策略的覆盖，不能削弱项目强制的策略
+func (h *PipelineHandler) UpdatePipelinePolicy(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	var req models.PipelinePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	var rules []models.PolicyNotifyRule
+	var approvals []models.PolicyApproval
+	if req.NotifyRules != nil {
+		rules = *req.NotifyRules
+	}
+	if req.Approvals != nil {
+		approvals = *req.Approvals
+	}
+	if problems := policy.Validate(rules, approvals, req.RunTimeoutMinutes); len(problems) > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "策略无效: "+strings.Join(problems, "；"))
+		return
+	}
+
+	projectPolicy, err := policy.Load(database.DB, pipeline.ProjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目策略失败")
+		return
+	}
+	if problems := policy.Weakens(projectPolicy, &req); len(problems) > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "策略无效: "+strings.Join(problems, "；"))
+		return
+	}
+
+	before := pipelinePolicyOverrides(pipeline)
+	updates := map[string]interface{}{
+		"policy_notify_rules": "",
+		"policy_approvals":    "",
+		"run_timeout_minutes": req.RunTimeoutMinutes,
+	}
+	if req.NotifyRules != nil {
+		updates["policy_notify_rules"] = policy.Encode(rules)
+	}
+	if req.Approvals != nil {
+		updates["policy_approvals"] = policy.Encode(approvals)
+	}
+	if err := database.DB.Model(pipeline).Updates(updates).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新流水线失败")
+		return
+	}
+	pipeline.PolicyNotifyRules = updates["policy_notify_rules"].(string)
+	pipeline.PolicyApprovals = updates["policy_approvals"].(string)
+	pipeline.RunTimeoutMinutes = req.RunTimeoutMinutes
+
+	overrides := pipelinePolicyOverrides(pipeline)
+	recordAuditChange(c, "update_pipeline_policy", "pipeline", pipeline.ID,
+		fmt.Sprintf("设置流水线 %s 的策略覆盖", pipeline.Name), policy.Encode(before), policy.Encode(overrides))
+
+	utils.SuccessResponse(c, gin.H{
+		"overrides": overrides,
+		"effective": policy.For(projectPolicy, pipeline),
+	})
+}
+
+// pipelinePolicyOverrides 流水线保存的策略覆盖，未覆盖的项为 null
+func pipelinePolicyOverrides(pipeline *models.Pipeline) models.PipelinePolicyRequest {
+	overrides := models.PipelinePolicyRequest{RunTimeoutMinutes: pipeline.RunTimeoutMinutes}
+	if pipeline.PolicyNotifyRules != "" {
+		rules := policy.NotifyRules(pipeline.PolicyNotifyRules)
+		if rules == nil {
+			rules = []models.PolicyNotifyRule{}
+		}
+		overrides.NotifyRules = &rules
+	}
+	if pipeline.PolicyApprovals != "" {
+		approvals := policy.Approvals(pipeline.PolicyApprovals)
+		if approvals == nil {
+			approvals = []models.PolicyApproval{}
+		}
+		overrides.Approvals = &approvals
+	}
+	return overrides
+}
@@ -1,53 +1,100 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"flowforge/pkg/anomaly"
+	"flowforge/pkg/config"
 	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/export"
+	"flowforge/pkg/git"
+	"flowforge/pkg/interlock"
 	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/scripts"
+	"flowforge/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // ProjectHandler 项目处理器
 type ProjectHandler struct {
-	db *gorm.DB
+	db         *gorm.DB
+	gitManager *git.Manager
 }
 
 // NewProjectHandler 创建项目处理器
-func NewProjectHandler() *ProjectHandler {
+func NewProjectHandler(gitManager *git.Manager) *ProjectHandler {
 	return &ProjectHandler{
-		db: database.DB,
+		db:         database.DB,
+		gitManager: gitManager,
 	}
 }
 
 // List 获取项目列表
 func (h *ProjectHandler) List(c *gin.Context) {
 	var projects []models.Project
-	result := h.db.Find(&projects)
+	query := h.db
+	// 默认不显示已归档项目
+	if c.Query("include_archived") != "true" {
+		query = query.Where("status <> ?", models.ProjectStatusArchived)
+	}
+	result := query.Find(&projects)
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取项目列表失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目列表失败")
 		return
 	}
+	alerts, _ := anomaly.ActiveAlerts()
+	spikes := spikesByProject(alerts)
+	for i := range projects {
+		projects[i].Health = projects[i].RepoHealth(config.GetConfig().Git.RepoNotFoundRuns)
+		for j := range spikes[projects[i].ID] {
+			projects[i].Health.AddFailureSpike(&spikes[projects[i].ID][j], config.GetConfig().Deploy.FailureSpike.ShortMinutes)
+		}
+	}
 
 	c.JSON(http.StatusOK, projects)
 }
 
-// Get 获取单个项目
+// Get 获取单个项目，:id 可以是项目ID或slug
 func (h *ProjectHandler) Get(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的项目ID"})
-		return
-	}
+	h.showProject(c, projectLookup(h.db, c.Param("id")))
+}
+
+// GetBySlug 按slug获取单个项目
+func (h *ProjectHandler) GetBySlug(c *gin.Context) {
+	h.showProject(c, h.db.Where("slug = ?", c.Param("slug")))
+}
 
+// showProject 返回查询条件匹配的项目详情
+func (h *ProjectHandler) showProject(c *gin.Context, query *gorm.DB) {
 	var project models.Project
-	result := h.db.Preload("SSHKey").Preload("Pipelines").Preload("Schedules").First(&project, id)
+	result := query.Preload("SSHKey").Preload("DeployKey").Preload("Pipelines").Preload("Schedules").First(&project)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
 		return
 	}
+	project.DriftStatus = deploy.ProjectDriftStatus(project.ID)
+	project.Health = project.RepoHealth(config.GetConfig().Git.RepoNotFoundRuns)
+	if alerts, err := anomaly.ActiveAlerts(project.ID); err == nil {
+		project.FailureSpikes = alerts
+		for i := range alerts {
+			project.Health.AddFailureSpike(&alerts[i], config.GetConfig().Deploy.FailureSpike.ShortMinutes)
+		}
+	}
+	if freezes, err := deploy.ActiveFreezes(); err == nil {
+		for _, freeze := range freezes {
+			if freeze.Scope == models.FreezeScopeEnvironment || freeze.Covers(project.ID, "") {
+				project.ActiveFreezes = append(project.ActiveFreezes, freeze)
+			}
+		}
+	}
 
 	c.JSON(http.StatusOK, project)
 }
@@ -56,64 +103,134 @@ func (h *ProjectHandler) Get(c *gin.Context) {
 type CreateProjectRequest struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
-	GitURL      string `json:"git_url" binding:"required"`
+	SourceType  string `json:"source_type"` // git（默认）、archive 或 registry，archive 与 registry 项目不需要 git_url
+	GitURL      string `json:"git_url"`
 	GitBranch   string `json:"git_branch"`
 	GitUsername string `json:"git_username"`
 	GitPassword string `json:"git_password"`
 	SSHKeyID    *uint  `json:"ssh_key_id"`
 	WorkDir     string `json:"work_dir"`
+	SizeHintMB  int    `json:"size_hint_mb"`
+	// ImageRepository registry 项目部署的镜像仓库，如 harbor.example.com/library/app，为空时不限制
+	ImageRepository string `json:"image_repository"`
 }
 
 // Create 创建项目
 func (h *ProjectHandler) Create(c *gin.Context) {
 	var req CreateProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的请求参数")
 		return
 	}
+	if req.SourceType == "" {
+		req.SourceType = models.ProjectSourceGit
+	}
+	switch {
+	case req.SourceType != models.ProjectSourceGit && req.SourceType != models.ProjectSourceArchive && req.SourceType != models.ProjectSourceRegistry:
+		utils.ErrorResponse(c, http.StatusBadRequest, "不支持的源码来源")
+		return
+	case req.SourceType == models.ProjectSourceGit && req.GitURL == "":
+		utils.ErrorResponse(c, http.StatusBadRequest, "git 项目必须提供仓库地址")
+		return
+	case req.SourceType == models.ProjectSourceArchive:
+		// 源码包项目不关联仓库，每次运行上传源码包
+		req.GitURL = ""
+	case req.SourceType == models.ProjectSourceRegistry:
+		// registry 项目不关联仓库，每次运行指定部署的制品
+		req.GitURL = ""
+		req.ImageRepository = strings.TrimSuffix(strings.TrimSpace(req.ImageRepository), "/")
+		if err := pipeline.ValidateImageRepository(req.ImageRepository); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.SourceType != models.ProjectSourceRegistry {
+		req.ImageRepository = ""
+	}
 
 	// 如果提供了SSH密钥ID，检查它是否存在
+	var sshKey *models.SSHKey
 	if req.SSHKeyID != nil {
-		var sshKey models.SSHKey
-		result := h.db.First(&sshKey, *req.SSHKeyID)
+		sshKey = &models.SSHKey{}
+		result := models.WithPrivateKey(h.db).First(sshKey, *req.SSHKeyID)
 		if result.Error != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "SSH密钥不存在"})
+			utils.ErrorResponse(c, http.StatusBadRequest, "SSH密钥不存在")
 			return
 		}
 	}
 
 	// 获取用户ID
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if h.projectNameTaken(current.ID, req.Name, 0) {
+		utils.ErrorResponse(c, http.StatusConflict, "项目名称已存在")
+		return
+	}
+	slug, err := database.UniqueProjectSlug(req.Name, 0)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建项目失败")
+		return
+	}
 	
 	// 创建项目
 	project := models.Project{
 		Name:        req.Name,
+		Slug:        slug,
 		Description: req.Description,
 		RepoURL:     req.GitURL,
 		Branch:      req.GitBranch,
 		BuildPath:   req.WorkDir,
 		SSHKeyID:    req.SSHKeyID,
-		UserID:      userID.(uint),
+		SizeHintMB:  req.SizeHintMB,
+		UserID:      current.ID,
 		Status:      models.ProjectStatusActive,
+		SourceType:  req.SourceType,
+
+		ImageRepository: req.ImageRepository,
 	}
 
-	// 设置默认分支（如果未提供）
-	if project.Branch == "" {
-		project.Branch = "main"
+	// 未指定分支时检测远程仓库的默认分支，检测失败才回退到 main
+	branchDetected := false
+	branchWarning := ""
+	if project.Branch == "" && !project.UsesArchiveSource() && !project.UsesRegistrySource() {
+		branch, err := h.gitManager.GetClient().DetectDefaultBranch(c.Request.Context(), &project, sshKey)
+		if err != nil {
+			project.Branch = "main"
+			branchWarning = fmt.Sprintf("检测默认分支失败，已使用 main: %v", err)
+		} else {
+			project.Branch = branch
+			branchDetected = true
+		}
 	}
 
 	if result := h.db.Create(&project); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建项目失败"})
+		// 并发创建同名项目时由唯一索引拒绝
+		if h.projectNameTaken(current.ID, req.Name, 0) {
+			utils.ErrorResponse(c, http.StatusConflict, "项目名称已存在")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建项目失败")
 		return
 	}
 
 	// 审计日志功能暂时移除，因为AuditLog模型不存在
 	// TODO: 实现审计日志功能
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message":    "项目创建成功",
-		"project_id": project.ID,
-	})
+	response := gin.H{
+		"message":         "项目创建成功",
+		"project_id":      project.ID,
+		"slug":            project.Slug,
+		"branch":          project.Branch,
+		"branch_detected": branchDetected,
+	}
+	if branchWarning != "" {
+		response["warning"] = branchWarning
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 // UpdateProjectRequest 更新项目请求
@@ -126,49 +243,96 @@ type UpdateProjectRequest struct {
 	GitPassword string `json:"git_password"`
 	SSHKeyID    *uint  `json:"ssh_key_id"`
 	WorkDir     string `json:"work_dir"`
+	SizeHintMB  *int   `json:"size_hint_mb"`
+	// DefaultShell 脚本步骤未指定 shell 时使用的解释器，为空字符串时恢复为执行主机默认
+	DefaultShell *string `json:"default_shell"`
+	// RegenerateSlug 按当前名称重新生成slug；默认改名不影响slug，旧slug失效后原链接将无法访问
+	RegenerateSlug bool `json:"regenerate_slug"`
+	// ImageRepository registry 项目部署的镜像仓库，为空字符串时不限制
+	ImageRepository *string `json:"image_repository"`
+	// 失败率突增告警：倍数与最少运行数为 0 时使用全局配置
+	FailureSpikeFactor   *float64 `json:"failure_spike_factor"`
+	FailureSpikeMinRuns  *int     `json:"failure_spike_min_runs"`
+	FailureSpikeDisabled *bool    `json:"failure_spike_disabled"`
 }
 
 // Update 更新项目
 func (h *ProjectHandler) Update(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的项目ID"})
-		return
-	}
-
 	var req UpdateProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的请求参数")
 		return
 	}
 
 	// 查找项目
 	var project models.Project
-	result := h.db.First(&project, id)
+	result := projectLookup(h.db, c.Param("id")).First(&project)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+
+	// 已归档项目只读
+	if project.IsArchived() {
+		utils.ErrorResponse(c, http.StatusConflict, "项目已归档")
 		return
 	}
+	before := projectSettingsText(&project)
 
 	// 如果提供了SSH密钥ID，检查它是否存在
 	if req.SSHKeyID != nil {
 		var sshKey models.SSHKey
 		result := h.db.First(&sshKey, *req.SSHKeyID)
 		if result.Error != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "SSH密钥不存在"})
+			utils.ErrorResponse(c, http.StatusBadRequest, "SSH密钥不存在")
 			return
 		}
 	}
 
 	// 更新字段
-	if req.Name != "" {
+	if req.Name != "" && req.Name != project.Name {
+		if h.projectNameTaken(project.UserID, req.Name, project.ID) {
+			utils.ErrorResponse(c, http.StatusConflict, "项目名称已存在")
+			return
+		}
 		project.Name = req.Name
 	}
+	if req.RegenerateSlug {
+		slug, err := database.UniqueProjectSlug(project.Name, project.ID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "更新项目失败")
+			return
+		}
+		project.Slug = slug
+	}
 	if req.Description != "" {
 		project.Description = req.Description
 	}
-	if req.GitURL != "" {
+	if req.GitURL != "" && project.UsesArchiveSource() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "源码包项目不使用仓库地址")
+		return
+	}
+	if req.GitURL != "" && project.UsesRegistrySource() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "registry 项目不使用仓库地址")
+		return
+	}
+	if req.ImageRepository != nil {
+		repository := strings.TrimSuffix(strings.TrimSpace(*req.ImageRepository), "/")
+		if !project.UsesRegistrySource() {
+			utils.ErrorResponse(c, http.StatusBadRequest, "只有 registry 项目可以配置镜像仓库")
+			return
+		}
+		if err := pipeline.ValidateImageRepository(repository); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		project.ImageRepository = repository
+	}
+	if req.GitURL != "" && req.GitURL != project.RepoURL {
+		// 换用新地址后之前的仓库状态不再适用
 		project.RepoURL = req.GitURL
+		project.RepoMovedTo = ""
+		project.RepoNotFoundRuns = 0
 	}
 	if req.GitBranch != "" {
 		project.Branch = req.GitBranch
@@ -179,47 +343,553 @@ func (h *ProjectHandler) Update(c *gin.Context) {
 	if req.WorkDir != "" {
 		project.BuildPath = req.WorkDir
 	}
+	if req.SizeHintMB != nil {
+		project.SizeHintMB = *req.SizeHintMB
+	}
+	if req.DefaultShell != nil {
+		if *req.DefaultShell != "" && !scripts.IsShell(*req.DefaultShell) {
+			utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("%v: %s", scripts.ErrUnknownShell, *req.DefaultShell))
+			return
+		}
+		project.DefaultShell = *req.DefaultShell
+	}
+	if req.FailureSpikeFactor != nil {
+		if *req.FailureSpikeFactor != 0 && *req.FailureSpikeFactor <= 1 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "失败率告警倍数需大于 1，0 表示使用全局配置")
+			return
+		}
+		project.FailureSpikeFactor = *req.FailureSpikeFactor
+	}
+	if req.FailureSpikeMinRuns != nil {
+		if *req.FailureSpikeMinRuns < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "失败率告警最少运行数不能为负数")
+			return
+		}
+		project.FailureSpikeMinRuns = *req.FailureSpikeMinRuns
+	}
+	if req.FailureSpikeDisabled != nil {
+		project.FailureSpikeDisabled = *req.FailureSpikeDisabled
+	}
 
 	// 保存更新
 	if result := h.db.Save(&project); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新项目失败"})
+		if h.projectNameTaken(project.UserID, project.Name, project.ID) {
+			utils.ErrorResponse(c, http.StatusConflict, "项目名称已存在")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新项目失败")
 		return
 	}
 
-	// 审计日志功能暂时移除，因为AuditLog模型不存在
-	// TODO: 实现审计日志功能
+	recordAuditChange(c, "update_project", "project", project.ID, "更新项目 "+project.Name,
+		before, projectSettingsText(&project))
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "项目更新成功",
+		"slug":    project.Slug,
 	})
 }
 
-// Delete 删除项目
+// Delete 删除项目。需要两步确认：首次请求返回 428 与确认令牌及将被删除的数据，
+// 再次请求在请求头中回传令牌与项目名称后执行；已删除的项目再次删除返回 404
 func (h *ProjectHandler) Delete(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的项目ID"})
-		return
-	}
-
 	// 查找项目
 	var project models.Project
-	result := h.db.First(&project, id)
+	result := projectLookup(h.db, c.Param("id")).First(&project)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+	if !confirmDestructive(c, interlock.OperationDeleteProject, "project", project.ID, project.Name, func() (interlock.Summary, error) {
+		return projectDeletionSummary(h.db, &project)
+	}) {
+		return
+	}
+
+	// 软删除的记录仍受名称唯一索引约束，先改名释放名称；slug 保持占用，避免旧链接指向新项目
+	if err := h.db.Model(&project).Update("name", fmt.Sprintf("%s (已删除 #%d)", project.Name, project.ID)).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除项目失败")
 		return
 	}
 
 	// 删除项目（软删除）
 	if result := h.db.Delete(&project); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除项目失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除项目失败")
 		return
 	}
+	membershipCache.Invalidate(project.ID)
 
-	// 审计日志功能暂时移除，因为AuditLog模型不存在
-	// TODO: 实现审计日志功能
+	recordAudit(c, interlock.OperationDeleteProject, "project", project.ID, "删除项目 "+project.Name)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "项目删除成功",
 	})
+}
+
+// Stats 获取项目数量统计，默认不计入已归档项目
+func (h *ProjectHandler) Stats(c *gin.Context) {
+	query := h.db.Model(&models.Project{})
+	if c.Query("include_archived") != "true" {
+		query = query.Where("status <> ?", models.ProjectStatusArchived)
+	}
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := query.Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目统计失败")
+		return
+	}
+
+	var total int64
+	byStatus := make(map[string]int64)
+	for _, row := range rows {
+		byStatus[row.Status] = row.Count
+		total += row.Count
+	}
+
+	// 生效中的部署冻结需要在概览中醒目展示
+	freezes, err := deploy.ActiveFreezes()
+	if err != nil {
+		freezes = []models.DeployFreeze{}
+	}
+
+	// 健康状态异常的项目数，按 warning、error 统计
+	byHealth := map[string]int64{models.HealthWarning: 0, models.HealthError: 0}
+	var unhealthy []models.Project
+	healthQuery := h.db.Select("id", "repo_moved_to", "repo_not_found_runs").
+		Where("repo_moved_to <> '' OR repo_not_found_runs > 0")
+	if c.Query("include_archived") != "true" {
+		healthQuery = healthQuery.Where("status <> ?", models.ProjectStatusArchived)
+	}
+	alerts, err := anomaly.ActiveAlerts()
+	if err != nil {
+		alerts = []models.FailureSpikeAlert{}
+	}
+	spikes := spikesByProject(alerts)
+	if err := healthQuery.Find(&unhealthy).Error; err == nil {
+		for i := range unhealthy {
+			health := unhealthy[i].RepoHealth(config.GetConfig().Git.RepoNotFoundRuns)
+			if len(spikes[unhealthy[i].ID]) > 0 {
+				// 失败率告警中的项目按 error 计入，下面不再重复统计
+				health.Status = models.HealthError
+				delete(spikes, unhealthy[i].ID)
+			}
+			if health.Status != models.HealthOK {
+				byHealth[health.Status]++
+			}
+		}
+	}
+	if len(spikes) > 0 {
+		ids := make([]uint, 0, len(spikes))
+		for id := range spikes {
+			ids = append(ids, id)
+		}
+		// 只统计仍在范围内（未删除，且按需排除已归档）的项目
+		countQuery := h.db.Model(&models.Project{}).Where("id IN ?", ids)
+		if c.Query("include_archived") != "true" {
+			countQuery = countQuery.Where("status <> ?", models.ProjectStatusArchived)
+		}
+		var alerting int64
+		if err := countQuery.Count(&alerting).Error; err == nil {
+			byHealth[models.HealthError] += alerting
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":          total,
+		"by_status":      byStatus,
+		"by_health":      byHealth,
+		"deploy_frozen":  len(freezes) > 0,
+		"active_freezes": freezes,
+		"failure_spikes": alerts,
+	})
+}
+
+// spikesByProject 按项目分组失败率突增告警
+func spikesByProject(alerts []models.FailureSpikeAlert) map[uint][]models.FailureSpikeAlert {
+	byProject := make(map[uint][]models.FailureSpikeAlert)
+	for _, alert := range alerts {
+		byProject[alert.ProjectID] = append(byProject[alert.ProjectID], alert)
+	}
+	return byProject
+}
+
+// Archive 归档项目：停用定时任务和Webhook，拒绝新的运行和部署，历史记录保持可读
+func (h *ProjectHandler) Archive(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	if project.IsArchived() {
+		utils.ErrorResponse(c, http.StatusConflict, "项目已归档")
+		return
+	}
+
+	now := time.Now()
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(project).Updates(map[string]interface{}{
+			"status":                models.ProjectStatusArchived,
+			"status_before_archive": project.Status,
+			"archived_at":           &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// 暂停定时触发的流水线
+		if err := tx.Model(&models.Pipeline{}).
+			Where(&models.Pipeline{ProjectID: project.ID, Trigger: models.TriggerSchedule, Status: models.PipelineStatusActive}).
+			Updates(map[string]interface{}{
+				"status":            models.PipelineStatusInactive,
+				"paused_by_archive": true,
+			}).Error; err != nil {
+			return err
+		}
+
+		// 停用Webhook
+		return tx.Model(&models.Webhook{}).
+			Where("project_id = ? AND status = ?", project.ID, models.StatusActive).
+			Updates(map[string]interface{}{
+				"status":            models.StatusInactive,
+				"paused_by_archive": true,
+			}).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "归档项目失败")
+		return
+	}
+
+	recordAudit(c, "archive_project", "project", project.ID, "归档项目 "+project.Name)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "项目已归档",
+	})
+}
+
+// UnarchiveProjectRequest 取消归档请求
+type UnarchiveProjectRequest struct {
+	// 需要恢复定时任务的流水线ID，未列出的保持暂停
+	ResumePipelineIDs []uint `json:"resume_pipeline_ids"`
+}
+
+// Unarchive 取消归档：恢复Webhook，定时任务仅恢复明确确认的流水线
+func (h *ProjectHandler) Unarchive(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	if !project.IsArchived() {
+		utils.ErrorResponse(c, http.StatusConflict, "项目未归档")
+		return
+	}
+
+	var req UnarchiveProjectRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的请求参数")
+			return
+		}
+	}
+
+	status := project.StatusBeforeArchive
+	if status == "" || status == models.ProjectStatusArchived {
+		status = models.ProjectStatusActive
+	}
+
+	var pausedIDs []uint
+	h.db.Model(&models.Pipeline{}).Where("project_id = ? AND paused_by_archive = ?", project.ID, true).Pluck("id", &pausedIDs)
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(project).Updates(map[string]interface{}{
+			"status":                status,
+			"status_before_archive": "",
+			"archived_at":           nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if len(req.ResumePipelineIDs) > 0 {
+			if err := tx.Model(&models.Pipeline{}).
+				Where("project_id = ? AND paused_by_archive = ? AND id IN ?", project.ID, true, req.ResumePipelineIDs).
+				Updates(map[string]interface{}{
+					"status":            models.PipelineStatusActive,
+					"paused_by_archive": false,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Webhook{}).
+			Where("project_id = ? AND paused_by_archive = ?", project.ID, true).
+			Updates(map[string]interface{}{
+				"status":            models.StatusActive,
+				"paused_by_archive": false,
+			}).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "取消归档失败")
+		return
+	}
+
+	// 未确认恢复的流水线保持暂停，返回给调用方以便后续处理
+	resumed := make(map[uint]bool, len(req.ResumePipelineIDs))
+	for _, id := range req.ResumePipelineIDs {
+		resumed[id] = true
+	}
+	stillPaused := make([]uint, 0)
+	for _, id := range pausedIDs {
+		if !resumed[id] {
+			stillPaused = append(stillPaused, id)
+		}
+	}
+
+	recordAudit(c, "unarchive_project", "project", project.ID,
+		fmt.Sprintf("取消归档项目 %s，恢复流水线 %v", project.Name, req.ResumePipelineIDs))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "项目已取消归档",
+		"paused_pipelines": stillPaused,
+	})
+}
+
+// RefreshDefaultBranch 重新检测远程仓库的默认分支并更新项目配置
+func (h *ProjectHandler) RefreshDefaultBranch(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	if project.IsArchived() {
+		utils.ErrorResponse(c, http.StatusConflict, "项目已归档")
+		return
+	}
+
+	// 加载认证所需的密钥
+	if err := models.WithPrivateKey(h.db).Preload("SSHKey").Preload("DeployKey").First(project, project.ID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "加载项目失败")
+		return
+	}
+
+	branches, err := h.gitManager.GetClient().ListRemoteBranches(c.Request.Context(), project, project.SSHKey)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadGateway, err.Error())
+		return
+	}
+	if branches.Default == "" {
+		utils.ErrorResponse(c, http.StatusBadGateway, "远程仓库未返回默认分支")
+		return
+	}
+
+	previous := project.Branch
+	if previous != branches.Default {
+		if err := h.db.Model(project).Update("branch", branches.Default).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "更新默认分支失败")
+			return
+		}
+		recordAudit(c, "refresh_project_branch", "project", project.ID,
+			fmt.Sprintf("默认分支由 %s 更新为 %s", previous, branches.Default))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"previous_branch": previous,
+		"branch":          branches.Default,
+		"changed":         previous != branches.Default,
+		"branches":        branches.Branches,
+	})
+}
+
+// AcceptRepoMove 接受远程报告的仓库迁移地址，将项目的仓库地址更新为迁移后的地址
+func (h *ProjectHandler) AcceptRepoMove(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+	if project.IsArchived() {
+		utils.ErrorResponse(c, http.StatusConflict, "项目已归档")
+		return
+	}
+	if project.RepoMovedTo == "" {
+		utils.ErrorResponse(c, http.StatusConflict, "远程未报告仓库迁移")
+		return
+	}
+
+	before := projectSettingsText(project)
+	previous, movedTo := project.RepoURL, project.RepoMovedTo
+	if err := h.db.Model(project).Updates(map[string]interface{}{
+		"repo_url":            movedTo,
+		"repo_moved_to":       "",
+		"repo_not_found_runs": 0,
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新仓库地址失败")
+		return
+	}
+	project.RepoURL = movedTo
+
+	recordAuditChange(c, "accept_repo_move", "project", project.ID,
+		fmt.Sprintf("接受仓库迁移，仓库地址由 %s 更新为 %s", previous, movedTo),
+		before, projectSettingsText(project))
+
+	c.JSON(http.StatusOK, gin.H{
+		"previous_repo_url": previous,
+		"repo_url":          movedTo,
+	})
+}
+
+// GetDeployments 获取项目部署记录，排序与分页方式同流水线运行列表：
+// 按创建时间倒序、同一时间按ID倒序；page/page_size 偏移分页或 cursor/limit 游标分页。
+// from、to 按创建日期过滤，?format=csv|ndjson 或对应的 Accept 头时不分页导出
+func (h *ProjectHandler) GetDeployments(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+	dates, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var deployments []models.Deployment
+	var total int64
+	query := h.db.Model(&models.Deployment{}).Where("project_id = ?", project.ID)
+	query = dates.apply(query, "deployments.created_at")
+
+	if format := exportFormat(c); format != "" {
+		redactor := redact.ForProject(project.ID)
+		spec := exportSpec{format: format, resource: "deployments-" + project.Name, dates: dates, header: export.DeploymentColumns}
+		writeExport(c, query, spec, func(db *gorm.DB) *gorm.DB {
+			return db.Select(tableColumns("deployments", export.DeploymentColumns)).Order("deployments.created_at DESC").Order("deployments.id DESC")
+		}, func(row *export.DeploymentRow) {
+			row.ErrorMsg = redactor.Redact(row.ErrorMsg)
+		})
+		return
+	}
+	query.Count(&total)
+
+	if c.Query("cursor") != "" || c.Query("limit") != "" {
+		cursor, err := database.DecodeCursor(c.Query("cursor"))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的分页游标")
+			return
+		}
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		limit = database.CursorLimit(limit)
+		if err := query.Scopes(database.CursorPaginate(cursor, limit)).Find(&deployments).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "获取部署记录失败")
+			return
+		}
+
+		response := models.PaginationResponse{Total: total, PageSize: limit}
+		if len(deployments) > limit {
+			deployments = deployments[:limit]
+			last := deployments[limit-1]
+			response.NextCursor = database.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		response.Data = deployments
+		utils.SuccessResponse(c, response)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err := query.Order("created_at DESC").Order("id DESC").Scopes(database.Paginate(page, pageSize)).Find(&deployments).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取部署记录失败")
+		return
+	}
+
+	utils.SuccessResponse(c, models.PaginationResponse{
+		Data:       deployments,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+// GetReleases 获取项目发布记录，按时间倒序分页，可按 environment 与创建日期 from、to 筛选；
+// ?format=csv|ndjson 或对应的 Accept 头时不分页导出
+func (h *ProjectHandler) GetReleases(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+	dates, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var releases []models.Release
+	var total int64
+	query := h.db.Model(&models.Release{}).Where("project_id = ?", project.ID)
+	if environment := c.Query("environment"); environment != "" {
+		query = query.Where("environment = ?", environment)
+	}
+	query = dates.apply(query, "releases.created_at")
+
+	if format := exportFormat(c); format != "" {
+		spec := exportSpec{format: format, resource: "releases-" + project.Name, dates: dates, header: export.ReleaseColumns}
+		writeExport[export.ReleaseRow](c, query, spec, func(db *gorm.DB) *gorm.DB {
+			columns := tableColumns("releases", export.ReleaseColumns)
+			for i, column := range export.ReleaseColumns {
+				if column == "deployed_by" {
+					columns[i] = "users.username AS deployed_by"
+				}
+			}
+			return db.Select(columns).Joins("LEFT JOIN users ON users.id = releases.deployed_by_id").Order("releases.id DESC")
+		}, nil)
+		return
+	}
+	query.Count(&total)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err := query.Preload("DeployedBy").Order("id DESC").Scopes(database.Paginate(page, pageSize)).Find(&releases).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取发布记录失败")
+		return
+	}
+
+	utils.SuccessResponse(c, models.PaginationResponse{
+		Data:       releases,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+// loadOwnedProject 加载项目（:id 可以是项目ID或slug）并校验当前用户为项目所有者或管理员
+func (h *ProjectHandler) loadOwnedProject(c *gin.Context) (*models.Project, bool) {
+	var project models.Project
+	if result := projectLookup(h.db, c.Param("id")).First(&project); result.Error != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+	if !current.IsAdmin() && current.ID != project.UserID {
+		utils.ErrorResponse(c, http.StatusForbidden, "只有项目所有者或管理员可以执行此操作")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// projectLookup 按项目ID或slug查询项目；slug 不会是纯数字，纯数字一律按ID查询
+func projectLookup(db *gorm.DB, key string) *gorm.DB {
+	if id, err := strconv.ParseUint(key, 10, 32); err == nil {
+		return db.Where("id = ?", id)
+	}
+	return db.Where("slug = ?", key)
+}
+
+// projectNameTaken 同一所有者下是否已有同名项目，excludeID 为正在改名的项目自身
+func (h *ProjectHandler) projectNameTaken(userID uint, name string, excludeID uint) bool {
+	var count int64
+	h.db.Model(&models.Project{}).Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID).Count(&count)
+	return count > 0
 }
\ No newline at end of file
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"flowforge/pkg/ipallow"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProviderRangeHandler 托管平台Webhook来源地址段处理器
+type ProviderRangeHandler struct{}
+
+// NewProviderRangeHandler 创建托管平台地址段处理器
+func NewProviderRangeHandler() *ProviderRangeHandler {
+	return &ProviderRangeHandler{}
+}
+
+// GetRanges 查看 @github、@gitlab 当前使用的地址段与最近一次刷新的结果
+func (h *ProviderRangeHandler) GetRanges(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, ipallow.Statuses(c.Request.Context()))
+}
+
+// RefreshRanges 立即从托管平台刷新地址段，不必等待定时任务
+func (h *ProviderRangeHandler) RefreshRanges(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ipallow.RefreshAll()
+	recordAudit(c, "refresh_provider_ranges", "provider_range", 0, "刷新托管平台的Webhook来源地址段")
+
+	utils.SuccessResponse(c, ipallow.Statuses(c.Request.Context()))
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueueHandler 运行等待队列与部署锁等待队列处理器
+type QueueHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewQueueHandler 创建队列处理器
+func NewQueueHandler(engine *pipeline.Engine) *QueueHandler {
+	return &QueueHandler{
+		engine: engine,
+	}
+}
+
+// GetQueue 列出等待执行的运行与等待部署锁的运行；管理员查看全部，其他用户只能看到自己项目的条目
+func (h *QueueHandler) GetQueue(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	runs := h.engine.QueuedRuns()
+	waits := h.engine.DeployLocks().Waits()
+	if !current.IsAdmin() {
+		owned := ownedProjectIDs(current.ID)
+
+		visibleRuns := make([]pipeline.QueuedRun, 0, len(runs))
+		for _, run := range runs {
+			if owned[run.ProjectID] {
+				visibleRuns = append(visibleRuns, run)
+			}
+		}
+		runs = visibleRuns
+
+		visibleWaits := make([]deploy.LockWait, 0, len(waits))
+		for _, wait := range waits {
+			if owned[wait.ProjectID] {
+				visibleWaits = append(visibleWaits, wait)
+			}
+		}
+		waits = visibleWaits
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"runs":         runs,
+		"deploy_waits": waits,
+		"stats": gin.H{
+			"runs":   h.engine.QueueStats(),
+			"deploy": h.engine.DeployLocks().Stats(),
+		},
+	})
+}
+
+// PromoteRun 将等待中的运行移到队首（管理员）
+func (h *QueueHandler) PromoteRun(c *gin.Context) {
+	current, run, ok := h.loadQueuedRun(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	if err := h.engine.PromoteQueued(run.RunID); err != nil {
+		if errors.Is(err, pipeline.ErrRunNotQueued) {
+			utils.ErrorResponse(c, http.StatusNotFound, "流水线运行不在等待队列中")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "调整等待队列失败")
+		return
+	}
+
+	recordAudit(c, "promote_queued_run", "pipeline_run", run.RunID,
+		fmt.Sprintf("将流水线 %s 的运行 #%d 从第 %d 位提升到队首", run.PipelineName, run.RunID, run.Position))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// DropRun 将运行移出等待队列，等同于取消排队中的运行
+func (h *QueueHandler) DropRun(c *gin.Context) {
+	current, run, ok := h.loadQueuedRun(c)
+	if !ok {
+		return
+	}
+
+	if err := h.engine.CancelPipelineRun(run.RunID, pipeline.UserCancellation(current.ID, "移出等待队列")); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "取消流水线运行失败: "+err.Error())
+		return
+	}
+
+	recordAudit(c, "drop_queued_run", "pipeline_run", run.RunID,
+		fmt.Sprintf("将流水线 %s 的运行 #%d 移出等待队列", run.PipelineName, run.RunID))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// PromoteDeployWait 将等待部署锁的运行移到该目标等待队列的队首（管理员）
+func (h *QueueHandler) PromoteDeployWait(c *gin.Context) {
+	current, wait, ok := h.loadDeployWait(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	if err := h.engine.DeployLocks().Promote(wait.ID); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "部署锁等待项不存在")
+		return
+	}
+
+	recordAudit(c, "promote_deploy_wait", "pipeline_run", wait.RunID,
+		fmt.Sprintf("将运行 #%d 在部署目标 %s 的等待队列中从第 %d 位提升到队首", wait.RunID, wait.Target, wait.Position))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// DropDeployWait 取消等待部署锁的运行
+func (h *QueueHandler) DropDeployWait(c *gin.Context) {
+	current, wait, ok := h.loadDeployWait(c)
+	if !ok {
+		return
+	}
+
+	detail := fmt.Sprintf("取消等待部署锁（%s）", wait.Target)
+	if err := h.engine.CancelPipelineRun(wait.RunID, pipeline.UserCancellation(current.ID, detail)); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "取消流水线运行失败: "+err.Error())
+		return
+	}
+
+	recordAudit(c, "drop_deploy_wait", "pipeline_run", wait.RunID,
+		fmt.Sprintf("取消在部署目标 %s 等待部署锁的运行 #%d", wait.Target, wait.RunID))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// loadQueuedRun 加载等待中的运行；不在队列中或当前用户无权访问其项目时返回404
+func (h *QueueHandler) loadQueuedRun(c *gin.Context) (*authctx.User, pipeline.QueuedRun, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, pipeline.QueuedRun{}, false
+	}
+
+	runID, err := strconv.ParseUint(c.Param("runId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行不在等待队列中")
+		return nil, pipeline.QueuedRun{}, false
+	}
+	run, found := h.engine.QueuedRun(uint(runID))
+	if !found || !canAccessProject(current, run.ProjectID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行不在等待队列中")
+		return nil, pipeline.QueuedRun{}, false
+	}
+
+	return current, run, true
+}
+
+// loadDeployWait 加载部署锁等待项；不存在或当前用户无权访问其项目时返回404
+func (h *QueueHandler) loadDeployWait(c *gin.Context) (*authctx.User, deploy.LockWait, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, deploy.LockWait{}, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("waitId"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "部署锁等待项不存在")
+		return nil, deploy.LockWait{}, false
+	}
+	wait, found := h.engine.DeployLocks().Wait(id)
+	if !found || !canAccessProject(current, wait.ProjectID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "部署锁等待项不存在")
+		return nil, deploy.LockWait{}, false
+	}
+
+	return current, wait, true
+}
+
+// canAccessProject 当前用户是否为项目所有者或管理员
+func canAccessProject(user *authctx.User, projectID uint) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	var count int64
+	database.DB.Model(&models.Project{}).Where("id = ? AND user_id = ?", projectID, user.ID).Count(&count)
+	return count > 0
+}
+
+// ownedProjectIDs 用户拥有的项目ID
+func ownedProjectIDs(userID uint) map[uint]bool {
+	var ids []uint
+	database.DB.Model(&models.Project{}).Where("user_id = ?", userID).Pluck("id", &ids)
+
+	owned := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		owned[id] = true
+	}
+	return owned
+}
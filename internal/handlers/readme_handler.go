@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/git"
+	"flowforge/pkg/markdown"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// readmeCacheSize README渲染缓存的最大条目数，超过后整体清空
+const readmeCacheSize = 256
+
+// readmeCache 按项目、仓库地址与提交缓存渲染结果；同一提交的README内容不会变化
+var readmeCache = struct {
+	sync.Mutex
+	entries map[string]*ReadmeResponse
+}{entries: make(map[string]*ReadmeResponse)}
+
+// ReadmeCommit README所在的提交信息
+type ReadmeCommit struct {
+	Hash        string    `json:"hash"`
+	Author      string    `json:"author"`
+	Message     string    `json:"message"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// ReadmeResponse 项目README与仓库概要
+type ReadmeResponse struct {
+	Path       string       `json:"path"`
+	HTML       string       `json:"html"`
+	Language   string       `json:"language"`   // 根据标志文件识别的语言，无法识别时为空
+	BuildType  string       `json:"build_type"` // 构建步骤 type: auto 将使用的构建类型
+	Source     string       `json:"source"`     // workspace（共享工作区）或 provider（托管平台API）
+	LastCommit ReadmeCommit `json:"last_commit"`
+}
+
+// GetReadme 获取项目README渲染后的HTML及语言、构建类型与最近提交信息；
+// 优先读取共享工作区的 HEAD，工作区尚未克隆时通过托管平台API读取
+func (h *ProjectHandler) GetReadme(c *gin.Context) {
+	var project models.Project
+	if err := projectLookup(h.db, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+
+	client := h.gitManager.GetClient()
+	source := "workspace"
+	workDir := h.gitManager.WorkspaceDir(project.ID)
+	var summary *git.RepoSummary
+	var err error
+	if _, statErr := os.Stat(filepath.Join(workDir, ".git")); statErr == nil {
+		summary, err = client.ReadRepoSummary(workDir)
+	} else {
+		source = "provider"
+		summary, err = client.FetchRepoSummary(c.Request.Context(), project.RepoURL, project.Branch)
+	}
+	if errors.Is(err, git.ErrReadmeNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "README不存在")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	key := fmt.Sprintf("%d:%s:%s", project.ID, project.RepoURL, summary.Commit)
+	readmeCache.Lock()
+	cached, ok := readmeCache.entries[key]
+	readmeCache.Unlock()
+	if ok {
+		response := *cached
+		response.Source = source
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	language, buildType := git.DetectProjectType(summary.RootFiles)
+	response := &ReadmeResponse{
+		Path:      summary.ReadmePath,
+		HTML:      markdown.Render(summary.Readme, markdown.Options{ResolveLink: readmeLinkResolver(project.RepoURL, summary.Commit, summary.ReadmePath)}),
+		Language:  language,
+		BuildType: buildType,
+		Source:    source,
+		LastCommit: ReadmeCommit{
+			Hash:        summary.Commit,
+			Author:      summary.Author,
+			Message:     strings.SplitN(summary.Message, "\n", 2)[0],
+			CommittedAt: summary.CommittedAt,
+		},
+	}
+
+	readmeCache.Lock()
+	if len(readmeCache.entries) >= readmeCacheSize {
+		readmeCache.entries = make(map[string]*ReadmeResponse)
+	}
+	readmeCache.entries[key] = response
+	readmeCache.Unlock()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// readmeLinkResolver 将README中的相对链接转换为托管平台上该提交的文件地址，
+// 图片使用原始内容地址；仓库不在已知平台时返回 nil（丢弃相对链接）
+func readmeLinkResolver(repoURL, commit, readmePath string) func(string, bool) string {
+	ref, err := git.ParseRepoURL(repoURL)
+	if err != nil || ref.Provider == "" {
+		return nil
+	}
+
+	dir := path.Dir(readmePath)
+	return func(target string, image bool) string {
+		fragment := ""
+		if idx := strings.Index(target, "#"); idx >= 0 {
+			target, fragment = target[:idx], target[idx:]
+		}
+		if idx := strings.Index(target, "?"); idx >= 0 {
+			target = target[:idx]
+		}
+		target, err := url.PathUnescape(target)
+		if err != nil {
+			return ""
+		}
+
+		// 以 / 开头的地址相对仓库根目录；跳出根目录的部分被截断
+		filePath := path.Join("/", dir, target)
+		if strings.HasPrefix(target, "/") {
+			filePath = path.Clean(target)
+		}
+
+		fileURL := ref.FileURL(commit, filePath, image)
+		if fileURL == "" || image {
+			return fileURL
+		}
+		return fileURL + fragment
+	}
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RedactionHandler 日志脱敏规则处理器
+type RedactionHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewRedactionHandler 创建日志脱敏规则处理器
+func NewRedactionHandler(engine *pipeline.Engine) *RedactionHandler {
+	return &RedactionHandler{
+		engine: engine,
+	}
+}
+
+// GetProjectRules 获取项目的脱敏规则
+func (h *RedactionHandler) GetProjectRules(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var rules []models.RedactionRule
+	if err := database.DB.Where("project_id = ?", project.ID).Order("id").Find(&rules).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询脱敏规则失败")
+		return
+	}
+
+	utils.SuccessResponse(c, rules)
+}
+
+// CreateProjectRule 创建项目的脱敏规则，对之后产生的日志行生效
+func (h *RedactionHandler) CreateProjectRule(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	h.createRule(c, &project.ID)
+}
+
+// UpdateProjectRule 更新项目的脱敏规则
+func (h *RedactionHandler) UpdateProjectRule(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	h.updateRule(c, database.DB.Where("project_id = ?", project.ID))
+}
+
+// DeleteProjectRule 删除项目的脱敏规则
+func (h *RedactionHandler) DeleteProjectRule(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	h.deleteRule(c, database.DB.Where("project_id = ?", project.ID))
+}
+
+// GetGlobalRules 获取对所有项目生效的全局脱敏规则（管理员）
+func (h *RedactionHandler) GetGlobalRules(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	var rules []models.RedactionRule
+	if err := database.DB.Where("project_id IS NULL").Order("id").Find(&rules).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询脱敏规则失败")
+		return
+	}
+
+	utils.SuccessResponse(c, rules)
+}
+
+// CreateGlobalRule 创建全局脱敏规则（管理员）
+func (h *RedactionHandler) CreateGlobalRule(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	h.createRule(c, nil)
+}
+
+// UpdateGlobalRule 更新全局脱敏规则（管理员）
+func (h *RedactionHandler) UpdateGlobalRule(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	h.updateRule(c, database.DB.Where("project_id IS NULL"))
+}
+
+// DeleteGlobalRule 删除全局脱敏规则（管理员）
+func (h *RedactionHandler) DeleteGlobalRule(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	h.deleteRule(c, database.DB.Where("project_id IS NULL"))
+}
+
+// RedactRun 按当前规则重新处理已结束运行的日志与错误信息（管理员），用于规则创建前已写入的日志
+func (h *RedactionHandler) RedactRun(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline").First(&run, c.Param("runId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+	// 运行中的日志仍在批量写入，等待结束后再处理
+	_, running := h.engine.GetRunningJobs()[run.ID]
+	if _, queued := h.engine.QueuedRun(run.ID); running || queued {
+		utils.ErrorResponse(c, http.StatusConflict, "运行中的流水线不能重新脱敏")
+		return
+	}
+
+	redactor := redact.ForProject(run.Pipeline.ProjectID)
+	var changed int
+	// 已压缩归档的日志在归档中改写
+	if run.LogArchived {
+		store := h.engine.LogArchive()
+		if store == nil {
+			utils.ErrorResponse(c, http.StatusConflict, "日志已压缩归档，未配置日志归档存储")
+			return
+		}
+		rewritten, err := store.Rewrite(run.ID, redactor.Redact)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "重新脱敏运行日志失败")
+			return
+		}
+		if rewritten {
+			changed++
+		}
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{}
+		if logs := redactor.Redact(run.LogOutput); logs != run.LogOutput {
+			updates["log_output"] = logs
+			updates["log_size"] = len(logs)
+		}
+		if msg := redactor.Redact(run.ErrorMsg); msg != run.ErrorMsg {
+			updates["error_msg"] = msg
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&run).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+
+		var steps []models.PipelineStep
+		if err := tx.Where("pipeline_run_id = ? AND (error_msg <> '' OR log_output <> '')", run.ID).Find(&steps).Error; err != nil {
+			return err
+		}
+		for _, step := range steps {
+			stepUpdates := map[string]interface{}{}
+			if msg := redactor.Redact(step.ErrorMsg); msg != step.ErrorMsg {
+				stepUpdates["error_msg"] = msg
+			}
+			if logs := redactor.Redact(step.LogOutput); logs != step.LogOutput {
+				stepUpdates["log_output"] = logs
+			}
+			if len(stepUpdates) > 0 {
+				if err := tx.Model(&step).UpdateColumns(stepUpdates).Error; err != nil {
+					return err
+				}
+				changed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "重新脱敏运行日志失败")
+		return
+	}
+
+	recordAudit(c, "redact_run", "pipeline_run", run.ID, fmt.Sprintf("按当前脱敏规则重新处理运行 #%d 的日志", run.ID))
+
+	utils.SuccessResponse(c, gin.H{
+		"run_id":  run.ID,
+		"changed": changed > 0,
+	})
+}
+
+// createRule 校验并保存脱敏规则，projectID 为空时为全局规则
+func (h *RedactionHandler) createRule(c *gin.Context, projectID *uint) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.RedactionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if err := redact.Validate(req.Pattern); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "脱敏规则无效: "+err.Error())
+		return
+	}
+
+	rule := models.RedactionRule{
+		Name:        req.Name,
+		Pattern:     req.Pattern,
+		Replacement: req.Replacement,
+		ProjectID:   projectID,
+		CreatedByID: current.ID,
+	}
+	if err := database.DB.Create(&rule).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存脱敏规则失败")
+		return
+	}
+	invalidateRule(&rule)
+
+	recordAudit(c, "create_redaction_rule", "redaction_rule", rule.ID, fmt.Sprintf("创建%s脱敏规则 %s", ruleScope(&rule), rule.Name))
+
+	utils.SuccessResponse(c, rule)
+}
+
+// updateRule 校验并更新 scope 范围内的脱敏规则
+func (h *RedactionHandler) updateRule(c *gin.Context, scope *gorm.DB) {
+	var rule models.RedactionRule
+	if err := scope.First(&rule, c.Param("rule_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "脱敏规则不存在")
+		return
+	}
+
+	var req models.RedactionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if err := redact.Validate(req.Pattern); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "脱敏规则无效: "+err.Error())
+		return
+	}
+
+	rule.Name = req.Name
+	rule.Pattern = req.Pattern
+	rule.Replacement = req.Replacement
+	if err := database.DB.Model(&rule).Select("name", "pattern", "replacement").Updates(&rule).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存脱敏规则失败")
+		return
+	}
+	invalidateRule(&rule)
+
+	recordAudit(c, "update_redaction_rule", "redaction_rule", rule.ID, fmt.Sprintf("更新%s脱敏规则 %s", ruleScope(&rule), rule.Name))
+
+	utils.SuccessResponse(c, rule)
+}
+
+// deleteRule 删除 scope 范围内的脱敏规则
+func (h *RedactionHandler) deleteRule(c *gin.Context, scope *gorm.DB) {
+	var rule models.RedactionRule
+	if err := scope.First(&rule, c.Param("rule_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "脱敏规则不存在")
+		return
+	}
+
+	if err := database.DB.Delete(&rule).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除脱敏规则失败")
+		return
+	}
+	invalidateRule(&rule)
+
+	recordAudit(c, "delete_redaction_rule", "redaction_rule", rule.ID, fmt.Sprintf("删除%s脱敏规则 %s", ruleScope(&rule), rule.Name))
+
+	utils.SuccessResponse(c, nil)
+}
+
+// loadProject 加载项目并校验当前用户为项目所有者或管理员
+func (h *RedactionHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// invalidateRule 使受规则影响的脱敏器缓存失效，全局规则影响所有项目
+func invalidateRule(rule *models.RedactionRule) {
+	if rule.ProjectID == nil {
+		redact.Invalidate(0)
+		return
+	}
+	redact.Invalidate(*rule.ProjectID)
+}
+
+// ruleScope 规则范围的描述，用于审计日志
+func ruleScope(rule *models.RedactionRule) string {
+	if rule.ProjectID == nil {
+		return "全局"
+	}
+	return fmt.Sprintf("项目 %d 的", *rule.ProjectID)
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/auth"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// emailVerificationTTL 注册邮箱验证链接的有效期
+const emailVerificationTTL = 24 * time.Hour
+
+var (
+	// ErrInviteInvalid 邀请不存在、已撤销、已使用或已过期
+	ErrInviteInvalid = errors.New("邀请无效或已过期")
+	// ErrInviteEmailMismatch 邀请指定了邮箱，注册邮箱与之不符
+	ErrInviteEmailMismatch = errors.New("邀请不适用于该邮箱")
+)
+
+// registrationPolicy 生效的注册策略，source 为 config 时由配置文件指定，管理接口不能修改
+type registrationPolicy struct {
+	Policy         string   `json:"policy"`
+	AllowedDomains []string `json:"allowed_domains"`
+	VerifyEmail    bool     `json:"verify_email"`
+	Source         string   `json:"source"` // config, system
+}
+
+// RegistrationHandler 注册策略与注册邀请处理器（管理员）
+type RegistrationHandler struct{}
+
+// NewRegistrationHandler 创建注册策略处理器
+func NewRegistrationHandler() *RegistrationHandler {
+	return &RegistrationHandler{}
+}
+
+// GetPolicy 获取生效的注册策略
+func (h *RegistrationHandler) GetPolicy(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	policy, err := currentRegistrationPolicy(c.Request.Context())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "读取注册策略失败")
+		return
+	}
+
+	utils.SuccessResponse(c, policy)
+}
+
+// UpdatePolicy 设置注册策略，配置文件指定了策略时不能修改
+func (h *RegistrationHandler) UpdatePolicy(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	if config.GetConfig().Security.Registration.Policy != "" {
+		utils.ErrorResponse(c, http.StatusConflict, "注册策略由配置文件指定")
+		return
+	}
+
+	var req models.RegistrationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	switch req.Policy {
+	case models.RegistrationOpen, models.RegistrationClosed, models.RegistrationInvite, models.RegistrationDomain:
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "不支持的注册策略")
+		return
+	}
+	domains := normalizeDomains(req.AllowedDomains)
+	if req.Policy == models.RegistrationDomain && len(domains) == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "需配置允许注册的邮箱域名")
+		return
+	}
+
+	before, _ := currentRegistrationPolicy(c.Request.Context())
+	settings := map[string]string{
+		models.ConfigRegistrationPolicy:  req.Policy,
+		models.ConfigRegistrationDomains: strings.Join(domains, ","),
+		models.ConfigRegistrationVerify:  strconv.FormatBool(req.VerifyEmail),
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for key, value := range settings {
+			setting := models.SystemConfig{Key: key, Category: "security"}
+			if err := tx.Where(models.SystemConfig{Key: key}).Assign(models.SystemConfig{Value: value}).
+				FirstOrCreate(&setting).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	database.InvalidateSettings()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存注册策略失败")
+		return
+	}
+
+	policy, _ := currentRegistrationPolicy(c.Request.Context())
+	beforeText := ""
+	if before != nil {
+		beforeText = before.String()
+	}
+	recordAuditChange(c, "update_registration_policy", "system_config", 0,
+		fmt.Sprintf("设置注册策略为 %s", policy.Policy), beforeText, policy.String())
+
+	utils.SuccessResponse(c, policy)
+}
+
+// GetInvites 获取注册邀请列表，不含令牌明文
+func (h *RegistrationHandler) GetInvites(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	var invites []models.Invite
+	if err := database.DB.Order("id DESC").Find(&invites).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取注册邀请失败")
+		return
+	}
+
+	utils.SuccessResponse(c, invites)
+}
+
+// CreateInvite 生成一次性注册邀请；指定邮箱时发送邀请邮件，令牌明文也只在本次响应中返回，可手动转交
+func (h *RegistrationHandler) CreateInvite(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var req models.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if (req.Role != models.RoleUser && req.Role != models.RoleAdmin) || req.ExpiresInHours < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = config.GetConfig().Security.Registration.InviteExpireHours
+	}
+
+	plain, hash := auth.GenerateInviteToken()
+	expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+	invite := models.Invite{
+		TokenHash:   hash,
+		Prefix:      plain[:len(auth.InviteTokenPrefix)+4],
+		Email:       strings.TrimSpace(req.Email),
+		Role:        req.Role,
+		ExpiresAt:   &expiresAt,
+		CreatedByID: current.ID,
+	}
+	if err := database.DB.Create(&invite).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建注册邀请失败")
+		return
+	}
+
+	recordAudit(c, "create_invite", "invite", invite.ID, fmt.Sprintf("创建注册邀请 %s（角色: %s，邮箱: %s）", invite.Prefix, invite.Role, invite.Email))
+
+	emailed := false
+	if invite.Email != "" {
+		body := fmt.Sprintf("%s 邀请你注册 FlowForge。\n\n邀请令牌: %s\n注册地址: %s/register?invite=%s\n\n邀请在 %s 前有效，只能使用一次。",
+			current.Username, plain, siteURL(c), plain, utils.FormatTime(expiresAt))
+		if err := notify.NewManager(config.GetConfig()).SendEmail(invite.Email, "FlowForge 注册邀请", body); err != nil {
+			log.Printf("发送注册邀请 %d 邮件失败: %v", invite.ID, err)
+		} else {
+			emailed = true
+		}
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"token":   plain,
+		"invite":  invite,
+		"emailed": emailed,
+	})
+}
+
+// RevokeInvite 撤销尚未兑换的注册邀请
+func (h *RegistrationHandler) RevokeInvite(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	var invite models.Invite
+	if err := database.DB.First(&invite, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "注册邀请不存在")
+		return
+	}
+	if invite.UsedAt != nil {
+		utils.ErrorResponse(c, http.StatusConflict, "注册邀请已被使用")
+		return
+	}
+
+	if invite.RevokedAt == nil {
+		now := time.Now()
+		invite.RevokedAt = &now
+		if err := database.DB.Model(&invite).Update("revoked_at", &now).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "撤销注册邀请失败")
+			return
+		}
+		recordAudit(c, "revoke_invite", "invite", invite.ID, fmt.Sprintf("撤销注册邀请 %s", invite.Prefix))
+	}
+
+	utils.SuccessResponse(c, invite)
+}
+
+// currentRegistrationPolicy 读取生效的注册策略：配置文件指定时以配置为准，否则读取系统配置；
+// 都没有设置时为升级前的行为，即开放注册
+func currentRegistrationPolicy(ctx context.Context) (*registrationPolicy, error) {
+	cfg := config.GetConfig().Security.Registration
+	if cfg.Policy != "" {
+		return &registrationPolicy{
+			Policy:         cfg.Policy,
+			AllowedDomains: normalizeDomains(cfg.AllowedDomains),
+			VerifyEmail:    cfg.VerifyEmail,
+			Source:         "config",
+		}, nil
+	}
+
+	policy := &registrationPolicy{Policy: models.RegistrationOpen, Source: "system"}
+	for _, key := range []string{models.ConfigRegistrationPolicy, models.ConfigRegistrationDomains, models.ConfigRegistrationVerify} {
+		value, ok, err := database.Setting(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("读取注册策略失败: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		switch key {
+		case models.ConfigRegistrationPolicy:
+			policy.Policy = value
+		case models.ConfigRegistrationDomains:
+			policy.AllowedDomains = normalizeDomains(strings.Split(value, ","))
+		case models.ConfigRegistrationVerify:
+			policy.VerifyEmail, _ = strconv.ParseBool(value)
+		}
+	}
+	return policy, nil
+}
+
+// findInvite 查找可兑换的邀请，邀请指定了邮箱时需与注册邮箱一致
+func findInvite(token, email string) (*models.Invite, error) {
+	var invite models.Invite
+	if !strings.HasPrefix(token, auth.InviteTokenPrefix) ||
+		database.DB.Where("token_hash = ? AND used_at IS NULL AND revoked_at IS NULL", auth.HashAPIToken(token)).First(&invite).Error != nil {
+		return nil, ErrInviteInvalid
+	}
+	if invite.ExpiresAt != nil && time.Now().After(*invite.ExpiresAt) {
+		return nil, ErrInviteInvalid
+	}
+	if invite.Email != "" && !strings.EqualFold(invite.Email, email) {
+		return nil, ErrInviteEmailMismatch
+	}
+	return &invite, nil
+}
+
+// String 注册策略的文本形式，用于审计日志中的变更对比
+func (p *registrationPolicy) String() string {
+	return fmt.Sprintf("policy: %s\nallowed_domains: %s\nverify_email: %t\n", p.Policy, strings.Join(p.AllowedDomains, ","), p.VerifyEmail)
+}
+
+// AllowsEmail 邮箱域名是否在允许注册的范围内
+func (p *registrationPolicy) AllowsEmail(email string) bool {
+	_, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok {
+		return false
+	}
+	for _, allowed := range p.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDomains 规范化邮箱域名列表：去除空白与前导 @，转为小写并去重
+func normalizeDomains(domains []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" && !seen[domain] {
+			seen[domain] = true
+			result = append(result, domain)
+		}
+	}
+	return result
+}
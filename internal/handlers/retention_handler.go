@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/config"
+	"flowforge/pkg/interlock"
+	"flowforge/pkg/logarchive"
+	"flowforge/pkg/retention"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler 运行数据保留策略处理器
+type RetentionHandler struct {
+	config *config.Config
+	store  *artifact.Store
+	logs   *logarchive.Store
+}
+
+// retentionConfirmName 立即清理时回传的确认名称
+const retentionConfirmName = "retention"
+
+// NewRetentionHandler 创建保留策略处理器，store 与 logs 用于立即清理时释放制品与日志归档
+func NewRetentionHandler(cfg *config.Config, store *artifact.Store, logs *logarchive.Store) *RetentionHandler {
+	return &RetentionHandler{
+		config: cfg,
+		store:  store,
+		logs:   logs,
+	}
+}
+
+// Simulate 按候选保留策略计算每日清理任务会删除的运行、日志、制品与工作区，不做任何修改。
+// 请求中未指定的项使用当前配置；format=csv 时导出 CSV
+func (h *RetentionHandler) Simulate(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	policy := retention.FromConfig(h.config)
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&policy); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summary, err := retention.Simulate(policy, time.Now())
+	if err != nil {
+		log.Printf("模拟保留策略失败: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "模拟保留策略失败")
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "retention-simulation.csv"))
+		if err := summary.WriteCSV(c.Writer); err != nil {
+			log.Printf("导出保留策略模拟结果失败: %v", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, summary)
+}
+
+// Prune 按当前配置的保留策略立即清理，与每日清理任务相同。需要两步确认，回传的名称为 retention
+func (h *RetentionHandler) Prune(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	policy := retention.FromConfig(h.config)
+	if err := policy.Validate(); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !confirmDestructive(c, interlock.OperationPruneRetention, "retention", 0, retentionConfirmName, func() (interlock.Summary, error) {
+		return retentionPruneSummary(policy, time.Now())
+	}) {
+		return
+	}
+
+	summary, err := retention.Cleanup(policy, h.store, h.logs, time.Now())
+	if err != nil {
+		log.Printf("按保留策略清理失败: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "按保留策略清理失败")
+		return
+	}
+
+	recordAudit(c, interlock.OperationPruneRetention, "retention", 0,
+		fmt.Sprintf("按保留策略清理：%d 次运行、%d 个制品", summary.Totals.Runs.Count, summary.Totals.Artifacts.Count))
+
+	utils.SuccessResponse(c, summary)
+}
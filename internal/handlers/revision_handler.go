@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/diff"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetRevisions 获取流水线的版本列表（不含配置内容）
+func (h *PipelineHandler) GetRevisions(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	var revisions []models.PipelineRevision
+	if err := database.DB.Where("pipeline_id = ?", pipeline.ID).Order("revision DESC").Find(&revisions).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+
+	utils.SuccessResponse(c, revisions)
+}
+
+// GetRevision 获取流水线某个版本的内容（配置已脱敏），:rev 为版本号或 latest
+func (h *PipelineHandler) GetRevision(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	revision, err := findRevision(pipeline.ID, c.Param("rev"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线版本不存在")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"revision": revision,
+		"config":   maskForProject(pipeline.ProjectID, revision.Config),
+	})
+}
+
+// GetRevisionDiff 对比流水线的两个版本，返回统一格式差异（已脱敏）。
+// :rev 为版本号或 latest；against 指定对比的旧版本，默认为前一个版本；ignore_whitespace=true 时忽略空白变化
+func (h *PipelineHandler) GetRevisionDiff(c *gin.Context) {
+	pipeline, ok := h.loadReadablePipeline(c)
+	if !ok {
+		return
+	}
+
+	to, err := findRevision(pipeline.ID, c.Param("rev"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线版本不存在")
+		return
+	}
+
+	var from models.PipelineRevision
+	against := c.Query("against")
+	if against == "" {
+		if to.Revision == 1 {
+			utils.ErrorResponse(c, http.StatusNotFound, "第 1 个版本没有前一个版本，请指定 against")
+			return
+		}
+		against = strconv.Itoa(to.Revision - 1)
+	}
+	if from, err = findRevision(pipeline.ID, against); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线版本不存在")
+		return
+	}
+
+	opts := diff.Options{IgnoreWhitespace: c.Query("ignore_whitespace") == "true"}
+	if value := c.Query("context"); value != "" {
+		lines, err := strconv.Atoi(value)
+		if err != nil || lines < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+			return
+		}
+		opts.Context = lines
+	}
+
+	secrets, err := redact.ProjectSecrets(pipeline.ProjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	result := diff.Unified(
+		fmt.Sprintf("revision %d", from.Revision),
+		fmt.Sprintf("revision %d", to.Revision),
+		redact.MaskConsistent(revisionText(&from), secrets),
+		redact.MaskConsistent(revisionText(&to), secrets), opts)
+
+	utils.SuccessResponse(c, gin.H{
+		"pipeline_id": pipeline.ID,
+		"from":        from,
+		"to":          to,
+		"diff":        result,
+	})
+}
+
+// loadReadablePipeline 加载当前用户可查看的流水线
+func (h *PipelineHandler) loadReadablePipeline(c *gin.Context) (*models.Pipeline, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var pipeline models.Pipeline
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where("projects.user_id = ?", current.ID)
+	}
+
+	if err := query.First(&pipeline, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线不存在")
+		return nil, false
+	}
+	return &pipeline, true
+}
+
+// findRevision 按版本号查找流水线版本，latest 表示最新版本
+func findRevision(pipelineID uint, rev string) (models.PipelineRevision, error) {
+	var revision models.PipelineRevision
+	query := database.DB.Where("pipeline_id = ?", pipelineID)
+	if rev == "latest" {
+		err := query.Order("revision DESC").First(&revision).Error
+		return revision, err
+	}
+
+	number, err := strconv.Atoi(rev)
+	if err != nil {
+		return revision, gorm.ErrRecordNotFound
+	}
+	err = query.Where("revision = ?", number).First(&revision).Error
+	return revision, err
+}
+
+// createRevision 保存流水线当前内容为新版本，版本号在已有最大版本号上加一
+func createRevision(tx *gorm.DB, pipeline *models.Pipeline, userID *uint) (*models.PipelineRevision, error) {
+	var latest int
+	if err := tx.Model(&models.PipelineRevision{}).Where("pipeline_id = ?", pipeline.ID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&latest).Error; err != nil {
+		return nil, fmt.Errorf("查询流水线版本失败: %w", err)
+	}
+
+	revision := models.PipelineRevision{
+		PipelineID:   pipeline.ID,
+		Revision:     latest + 1,
+		Name:         pipeline.Name,
+		Description:  pipeline.Description,
+		Config:       pipeline.Config,
+		Trigger:      pipeline.Trigger,
+		CronExpr:     pipeline.CronExpr,
+		ConfigSource: pipeline.ConfigSource,
+		ConfigPath:   pipeline.ConfigPath,
+		MutexGroup:   pipeline.MutexGroup,
+		CreatedByID:  userID,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, fmt.Errorf("保存流水线版本失败: %w", err)
+	}
+	return &revision, nil
+}
+
+// revisionText 版本的文本形式：设置项在前、配置在后，对比前需脱敏
+func revisionText(revision *models.PipelineRevision) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\n", revision.Name)
+	fmt.Fprintf(&b, "description: %s\n", revision.Description)
+	fmt.Fprintf(&b, "trigger: %s\n", revision.Trigger)
+	fmt.Fprintf(&b, "cron_expr: %s\n", revision.CronExpr)
+	fmt.Fprintf(&b, "config_source: %s\n", revision.ConfigSource)
+	fmt.Fprintf(&b, "config_path: %s\n", revision.ConfigPath)
+	fmt.Fprintf(&b, "mutex_group: %s\n", revision.MutexGroup)
+	b.WriteString("config:\n")
+	b.WriteString(revision.Config)
+	return b.String()
+}
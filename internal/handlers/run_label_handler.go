@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/runlabel"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RunLabelHandler 运行标签处理器
+type RunLabelHandler struct{}
+
+// NewRunLabelHandler 创建运行标签处理器
+func NewRunLabelHandler() *RunLabelHandler {
+	return &RunLabelHandler{}
+}
+
+// GetPolicy 获取项目的运行标签策略：允许的标签与按标签保留规则
+func (h *RunLabelHandler) GetPolicy(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	retention, err := runlabel.Retention(project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"allowed_labels": project.AllowedRunLabelList(),
+		"retention":      retention,
+	})
+}
+
+// UpdatePolicy 更新项目的运行标签策略。允许的标签为空时不限制标签；
+// 保留规则 keep_days 为 0 表示永久保留，对已有的运行同样生效
+func (h *RunLabelHandler) UpdatePolicy(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var req models.RunLabelPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	allowed, err := runlabel.Normalize(req.AllowedLabels)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的标签策略: "+err.Error())
+		return
+	}
+	policy := models.Project{AllowedRunLabels: strings.Join(allowed, ",")}
+
+	seen := make(map[string]bool, len(req.Retention))
+	rules := make([]string, 0, len(req.Retention))
+	for i := range req.Retention {
+		rule := &req.Retention[i]
+		labels, err := runlabel.Check(&policy, []string{rule.Label})
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的标签策略: "+err.Error())
+			return
+		}
+		rule.Label = labels[0]
+		if seen[rule.Label] {
+			utils.ErrorResponse(c, http.StatusBadRequest, "标签的保留规则重复: "+rule.Label)
+			return
+		}
+		seen[rule.Label] = true
+		if rule.KeepDays < 0 || rule.KeepDays > runlabel.MaxKeepDays {
+			utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("无效的标签策略: 保留天数应在 0 到 %d 之间", runlabel.MaxKeepDays))
+			return
+		}
+		rules = append(rules, retentionText(rule))
+	}
+
+	before := projectSettingsText(project)
+	if err := runlabel.SetPolicy(database.DB, project, allowed, req.Retention); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存标签策略失败")
+		return
+	}
+	project.AllowedRunLabels = policy.AllowedRunLabels
+
+	recordAuditChange(c, "update_run_label_policy", "project", project.ID,
+		fmt.Sprintf("更新项目 %s 的运行标签策略: 允许的标签 [%s]，保留规则 [%s]", project.Name, strings.Join(allowed, ", "), strings.Join(rules, ", ")),
+		before, projectSettingsText(project))
+
+	utils.SuccessResponse(c, gin.H{
+		"allowed_labels": allowed,
+		"retention":      req.Retention,
+	})
+}
+
+// AddLabels 为运行添加标签，只有项目所有者与管理员可以添加
+func (h *RunLabelHandler) AddLabels(c *gin.Context) {
+	run, project, ok := h.loadRun(c)
+	if !ok {
+		return
+	}
+	current, _ := currentUser(c)
+
+	var req models.RunLabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	labels, err := runlabel.Check(project, req.Labels)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := runlabel.Add(database.DB, run.ID, labels, &current.ID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存运行标签失败")
+		return
+	}
+
+	recordAudit(c, "add_run_labels", "pipeline_run", run.ID, fmt.Sprintf("为运行 #%d 添加标签 %s", run.ID, strings.Join(labels, ", ")))
+
+	h.respondLabels(c, run.ID)
+}
+
+// RemoveLabel 删除运行的标签
+func (h *RunLabelHandler) RemoveLabel(c *gin.Context) {
+	run, _, ok := h.loadRun(c)
+	if !ok {
+		return
+	}
+
+	label := c.Param("label")
+	result := database.DB.Where("pipeline_run_id = ? AND label = ?", run.ID, label).Delete(&models.RunLabel{})
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除运行标签失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "运行标签不存在")
+		return
+	}
+
+	recordAudit(c, "remove_run_label", "pipeline_run", run.ID, fmt.Sprintf("删除运行 #%d 的标签 %s", run.ID, label))
+
+	h.respondLabels(c, run.ID)
+}
+
+// respondLabels 返回运行当前的标签
+func (h *RunLabelHandler) respondLabels(c *gin.Context, runID uint) {
+	var labels []models.RunLabel
+	if err := database.DB.Where("pipeline_run_id = ?", runID).Order("id").Find(&labels).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	utils.SuccessResponse(c, labels)
+}
+
+// loadProject 加载当前用户可访问的项目
+func (h *RunLabelHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// loadRun 加载流水线的运行及其项目，校验当前用户为项目所有者或管理员
+func (h *RunLabelHandler) loadRun(c *gin.Context) (*models.PipelineRun, *models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline.Project").
+		Where("pipeline_id = ?", c.Param("id")).First(&run, c.Param("runId")).Error; err != nil ||
+		(!current.IsAdmin() && run.Pipeline.Project.UserID != current.ID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return nil, nil, false
+	}
+
+	return &run, &run.Pipeline.Project, true
+}
+
+// retentionText 保留规则的描述，用于审计日志
+func retentionText(rule *models.RunLabelRetention) string {
+	if rule.KeepDays == 0 {
+		return rule.Label + " 永久保留"
+	}
+	return fmt.Sprintf("%s 保留 %d 天", rule.Label, rule.KeepDays)
+}
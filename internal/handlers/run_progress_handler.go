@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"flowforge/internal/middleware"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// progressInterval 推送运行进度的间隔，进度在内存中按当前时间计算，推送不读写数据库中的运行记录
+const progressInterval = 2 * time.Second
+
+// StreamRunProgress 以 SSE 推送运行中的进度与预计完成时间：运行执行中每隔 progressInterval 推送 progress 事件，
+// 运行结束时推送带最终状态的 finished 事件后关闭；排队中或在其他实例执行的运行推送 waiting 事件
+func (h *PipelineHandler) StreamRunProgress(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var pipelineRun models.PipelineRun
+	query := database.DB.Select("pipeline_runs.id", "pipeline_runs.status")
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionView))
+	}
+	if err := query.First(&pipelineRun, c.Param("runId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		if snapshot, ok := h.engine.RunProgress(pipelineRun.ID); ok {
+			c.SSEvent("progress", snapshot)
+		} else {
+			// 不在本实例执行时按数据库中的状态判断运行是否已结束
+			var status string
+			database.DB.Model(&models.PipelineRun{}).Select("status").Where("id = ?", pipelineRun.ID).Scan(&status)
+			if status != models.RunStatusPending && status != models.RunStatusRunning {
+				c.SSEvent("finished", gin.H{"status": status})
+				c.Writer.Flush()
+				return
+			}
+			c.SSEvent("waiting", gin.H{"status": status})
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-middleware.StreamDraining(c):
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxResourceRuns 共享资源使用记录中最多返回的运行数
+const maxResourceRuns = 100
+
+// SharedResourceHandler 共享脚本、环境变量组与流水线模板处理器：所有用户可以查看，管理员可以创建、修改与删除旧版本
+type SharedResourceHandler struct{}
+
+// NewSharedResourceHandler 创建共享资源处理器
+func NewSharedResourceHandler() *SharedResourceHandler {
+	return &SharedResourceHandler{}
+}
+
+// resourceRun 使用共享资源某个版本的运行
+type resourceRun struct {
+	RunID      uint   `json:"run_id"`
+	PipelineID uint   `json:"pipeline_id"`
+	RunNumber  int    `json:"run_number"`
+	Status     string `json:"status"`
+	Version    int    `json:"version"`
+}
+
+// GetResources 获取共享资源列表，?kind= 按类型筛选
+func (h *SharedResourceHandler) GetResources(c *gin.Context) {
+	query := database.DB.Order("kind, name")
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var resources []models.SharedResource
+	if err := query.Find(&resources).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	utils.SuccessResponse(c, resources)
+}
+
+// CreateResource 创建共享资源，内容保存为版本 1（管理员）
+func (h *SharedResourceHandler) CreateResource(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+
+	var req models.CreateSharedResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	resource, err := pipeline.CreateSharedResource(req.Kind, strings.TrimSpace(req.Name), req.Description, req.Content, &current.ID)
+	if sharedResourceError(c, err) {
+		return
+	}
+
+	recordAudit(c, "create_shared_resource", "shared_resource", resource.ID, fmt.Sprintf("创建共享资源 %s/%s", resource.Kind, resource.Name))
+	utils.SuccessResponse(c, resource)
+}
+
+// GetResource 获取共享资源及其全部版本（不含内容），版本按版本号倒序
+func (h *SharedResourceHandler) GetResource(c *gin.Context) {
+	resource, ok := h.loadResource(c)
+	if !ok {
+		return
+	}
+
+	var versions []models.SharedResourceVersion
+	database.DB.Omit("content").Where("resource_id = ?", resource.ID).Order("version DESC").Find(&versions)
+	utils.SuccessResponse(c, gin.H{
+		"resource": resource,
+		"versions": versions,
+	})
+}
+
+// UpdateResource 修改共享资源，内容有变化时生成新版本并返回，之后开始的运行使用新版本（管理员）
+func (h *SharedResourceHandler) UpdateResource(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+	resource, ok := h.loadResource(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateSharedResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	version, err := pipeline.UpdateSharedResource(resource.ID, req.Description, req.Content, &current.ID)
+	if sharedResourceError(c, err) {
+		return
+	}
+
+	if version.Version != resource.LatestVersion {
+		recordAudit(c, "update_shared_resource", "shared_resource", resource.ID,
+			fmt.Sprintf("修改共享资源 %s/%s，版本 %d", resource.Kind, resource.Name, version.Version))
+	}
+	utils.SuccessResponse(c, version)
+}
+
+// GetVersion 获取共享资源某个版本的内容
+func (h *SharedResourceHandler) GetVersion(c *gin.Context) {
+	resource, ok := h.loadResource(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的版本号")
+		return
+	}
+
+	var version models.SharedResourceVersion
+	if err := database.DB.Where("resource_id = ? AND version = ?", resource.ID, number).First(&version).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "共享资源版本不存在")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"resource": resource,
+		"version":  version,
+	})
+}
+
+// DeleteVersion 删除共享资源的旧版本；最新版本与仍被保留的运行引用的版本不能删除（管理员）
+func (h *SharedResourceHandler) DeleteVersion(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !current.IsAdmin() {
+		utils.ErrorResponse(c, http.StatusForbidden, "权限不足")
+		return
+	}
+	resource, ok := h.loadResource(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的版本号")
+		return
+	}
+
+	if sharedResourceError(c, pipeline.DeleteSharedVersion(resource.ID, number)) {
+		return
+	}
+
+	recordAudit(c, "delete_shared_resource_version", "shared_resource", resource.ID,
+		fmt.Sprintf("删除共享资源 %s/%s 的版本 %d", resource.Kind, resource.Name, number))
+	utils.SuccessResponse(c, gin.H{"message": "共享资源版本已删除"})
+}
+
+// GetResourceRuns 获取使用了共享资源的运行，按运行ID倒序，只返回当前用户可以查看的项目中的运行；
+// ?version= 只返回使用该版本的运行
+func (h *SharedResourceHandler) GetResourceRuns(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	resource, ok := h.loadResource(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Table("run_resource_versions").
+		Select("pipeline_runs.id AS run_id, pipeline_runs.pipeline_id, pipeline_runs.run_number, pipeline_runs.status, run_resource_versions.version").
+		Joins("JOIN pipeline_runs ON pipeline_runs.id = run_resource_versions.pipeline_run_id").
+		Where("run_resource_versions.resource_id = ? AND pipeline_runs.deleted_at IS NULL", resource.ID)
+	if value := c.Query("version"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的版本号")
+			return
+		}
+		query = query.Where("run_resource_versions.version = ?", number)
+	}
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionView))
+	}
+
+	runs := []resourceRun{}
+	if err := query.Order("pipeline_runs.id DESC").Limit(maxResourceRuns).Scan(&runs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "数据库查询失败")
+		return
+	}
+	utils.SuccessResponse(c, runs)
+}
+
+// loadResource 读取路径中的共享资源，不存在时写入 404
+func (h *SharedResourceHandler) loadResource(c *gin.Context) (*models.SharedResource, bool) {
+	var resource models.SharedResource
+	if err := database.DB.First(&resource, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "共享资源不存在")
+		return nil, false
+	}
+	return &resource, true
+}
+
+// sharedResourceError 按共享资源的错误写入响应，err 为 nil 时返回 false
+func sharedResourceError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, pipeline.ErrSharedResourceNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, pipeline.ErrInvalidSharedResource):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pipeline.ErrSharedResourceExists), errors.Is(err, pipeline.ErrVersionInUse), errors.Is(err, pipeline.ErrLatestVersion):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存共享资源失败")
+	}
+	return true
+}
+
+// validateSharedReferences 校验保存的配置引用的共享模板、环境变量组与脚本存在
+func validateSharedReferences(c *gin.Context, req *models.CreatePipelineRequest) bool {
+	config := storedConfig(req)
+	if config == nil {
+		return true
+	}
+	problems := pipeline.ValidateSharedReferences(config)
+	if len(problems) == 0 {
+		return true
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, "流水线配置无效: "+strings.Join(problems, "；"))
+	return false
+}
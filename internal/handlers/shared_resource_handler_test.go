@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sharedRequest 以 user 身份调用共享资源处理器，params 为路径参数
+func sharedRequest(t *testing.T, user authctx.User, method, target string, body interface{}, params gin.Params, handle func(*gin.Context)) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, &payload)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	authctx.SetCurrentUser(c, user)
+	handle(c)
+	return w
+}
+
+// TestSharedResourceVersions 管理员创建与修改共享资源，只有管理员可以修改；被保留的运行引用的版本不能删除，
+// 使用记录只返回调用者可以查看的项目中的运行
+func TestSharedResourceVersions(t *testing.T) {
+	f := setupChangeTest(t)
+	h := NewSharedResourceHandler()
+	create := models.CreateSharedResourceRequest{Kind: models.SharedResourceScript, Name: "build-sh", Content: "make build"}
+
+	if w := sharedRequest(t, ownerUser, http.MethodPost, "/api/v1/shared-resources", create, nil, h.CreateResource); w.Code != http.StatusForbidden {
+		t.Errorf("非管理员创建返回 %d，应为 403", w.Code)
+	}
+	if w := sharedRequest(t, adminUser, http.MethodPost, "/api/v1/shared-resources", create, nil, h.CreateResource); w.Code != http.StatusOK {
+		t.Fatalf("创建返回 %d: %s", w.Code, w.Body.String())
+	}
+	if w := sharedRequest(t, adminUser, http.MethodPost, "/api/v1/shared-resources", create, nil, h.CreateResource); w.Code != http.StatusConflict {
+		t.Errorf("重复创建返回 %d，应为 409", w.Code)
+	}
+	invalid := models.CreateSharedResourceRequest{Kind: models.SharedResourceEnvGroup, Name: "common", Content: "PORT=8080"}
+	if w := sharedRequest(t, adminUser, http.MethodPost, "/api/v1/shared-resources", invalid, nil, h.CreateResource); w.Code != http.StatusBadRequest {
+		t.Errorf("创建无效的环境变量组返回 %d，应为 400", w.Code)
+	}
+
+	var resource models.SharedResource
+	database.DB.Where("name = ?", "build-sh").First(&resource)
+	id := gin.Params{{Key: "id", Value: fmt.Sprint(resource.ID)}}
+	update := models.UpdateSharedResourceRequest{Content: "make test"}
+	if w := sharedRequest(t, viewerUser, http.MethodPut, "/", update, id, h.UpdateResource); w.Code != http.StatusForbidden {
+		t.Errorf("非管理员修改返回 %d，应为 403", w.Code)
+	}
+	w := sharedRequest(t, adminUser, http.MethodPut, "/", update, id, h.UpdateResource)
+	var updated struct {
+		Data models.SharedResourceVersion `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil || updated.Data.Version != 2 {
+		t.Fatalf("修改返回 %d %s，应生成版本 2", w.Code, w.Body.String())
+	}
+
+	// 两个项目各有一个使用版本 1 的运行
+	for _, pipeline := range []*models.Pipeline{f.webPipeline, f.secretPipeline} {
+		run := &models.PipelineRun{PipelineID: pipeline.ID, UserID: ownerUser.ID, RunNumber: 1, Status: models.RunStatusSuccess, TriggerType: models.TriggerManual}
+		database.DB.Create(run)
+		database.DB.Create(&models.RunResourceVersion{PipelineRunID: run.ID, ResourceID: resource.ID, Version: 1, Kind: resource.Kind, Name: resource.Name})
+	}
+	runProjects := func(user authctx.User) []uint {
+		w := sharedRequest(t, user, http.MethodGet, "/?version=1", nil, id, h.GetResourceRuns)
+		var body struct {
+			Data []resourceRun `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		var pipelines []uint
+		for _, run := range body.Data {
+			pipelines = append(pipelines, run.PipelineID)
+		}
+		return pipelines
+	}
+	if got := runProjects(adminUser); !reflect.DeepEqual(got, []uint{f.secretPipeline.ID, f.webPipeline.ID}) {
+		t.Errorf("管理员看到使用版本 1 的流水线 %v，应为两个项目的流水线", got)
+	}
+	if got := runProjects(viewerUser); !reflect.DeepEqual(got, []uint{f.webPipeline.ID}) {
+		t.Errorf("成员看到使用版本 1 的流水线 %v，应只有可以查看的项目", got)
+	}
+
+	version := func(number string) gin.Params {
+		return append(gin.Params{{Key: "version", Value: number}}, id...)
+	}
+	if w := sharedRequest(t, adminUser, http.MethodDelete, "/", nil, version("1"), h.DeleteVersion); w.Code != http.StatusConflict {
+		t.Errorf("删除被运行引用的版本返回 %d，应为 409", w.Code)
+	}
+	if w := sharedRequest(t, adminUser, http.MethodDelete, "/", nil, version("2"), h.DeleteVersion); w.Code != http.StatusConflict {
+		t.Errorf("删除最新版本返回 %d，应为 409", w.Code)
+	}
+	database.DB.Where("1 = 1").Delete(&models.PipelineRun{})
+	if w := sharedRequest(t, adminUser, http.MethodDelete, "/", nil, version("1"), h.DeleteVersion); w.Code != http.StatusOK {
+		t.Errorf("运行被清理后删除版本返回 %d: %s", w.Code, w.Body.String())
+	}
+	if w := sharedRequest(t, viewerUser, http.MethodGet, "/", nil, version("1"), h.GetVersion); w.Code != http.StatusNotFound {
+		t.Errorf("获取已删除的版本返回 %d，应为 404", w.Code)
+	}
+	if w := sharedRequest(t, viewerUser, http.MethodGet, "/", nil, version("2"), h.GetVersion); w.Code != http.StatusOK {
+		t.Errorf("获取版本 2 返回 %d，应为 200", w.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/archive"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/runlabel"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SourceArchiveHandler 源码包项目的上传运行处理器
+type SourceArchiveHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewSourceArchiveHandler 创建源码包上传处理器
+func NewSourceArchiveHandler(engine *pipeline.Engine) *SourceArchiveHandler {
+	return &SourceArchiveHandler{engine: engine}
+}
+
+// UploadSource 上传 zip / tar / tar.gz 源码包并触发运行。请求为 multipart，文件字段名为 file，
+// 可选字段 pipeline_id 与 labels（逗号分隔的运行标签）必须在 file 之前；未指定流水线时使用项目唯一的启用流水线
+func (h *SourceArchiveHandler) UploadSource(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+	if project.IsArchived() {
+		utils.ErrorResponse(c, http.StatusConflict, models.ErrProjectArchived.Error())
+		return
+	}
+	if !project.UsesArchiveSource() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "项目不是源码包项目")
+		return
+	}
+
+	// 逐个读取 multipart 字段，源码包直接写入存储而不在内存或临时目录中缓存整个请求
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+
+	var pipelineID uint
+	var labels []string
+	var stored *pipeline.SourceArchive
+	filename := ""
+	for stored == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "读取上传内容失败")
+			return
+		}
+
+		switch part.FormName() {
+		case "pipeline_id":
+			value, _ := io.ReadAll(io.LimitReader(part, 32))
+			id, err := strconv.ParseUint(strings.TrimSpace(string(value)), 10, 32)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+				return
+			}
+			pipelineID = uint(id)
+		case "labels":
+			value, _ := io.ReadAll(io.LimitReader(part, 4096))
+			labels, err = runlabel.Check(&project, runlabel.ParseQuery(string(value)))
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+				return
+			}
+		case "file":
+			filename = part.FileName()
+			stored, err = h.engine.StoreSourceArchive(project.ID, filename, part)
+			if err != nil {
+				switch {
+				case errors.Is(err, pipeline.ErrSourceArchiveTooLarge):
+					utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, err.Error())
+				case errors.Is(err, archive.ErrUnsupportedFormat):
+					utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+				default:
+					utils.ErrorResponse(c, http.StatusInternalServerError, "保存源码包失败")
+				}
+				return
+			}
+		}
+		part.Close()
+	}
+	if stored == nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "缺少源码包文件")
+		return
+	}
+
+	target, ok := h.findPipeline(c, &project, pipelineID)
+	if !ok {
+		os.Remove(stored.Path)
+		return
+	}
+
+	pipelineRun, err := h.engine.RunPipelineWithOptions(target.ID, models.TriggerTypeManual, current.ID, pipeline.RunOptions{SourceArchive: stored, Labels: labels})
+	if err != nil {
+		os.Remove(stored.Path)
+		if errors.Is(err, models.ErrProjectArchived) {
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+		if configErrorResponse(c, err) {
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "启动流水线失败: "+err.Error())
+		return
+	}
+
+	recordAudit(c, "upload_source", "pipeline_run", pipelineRun.ID,
+		fmt.Sprintf("上传源码包 %s（%d 字节，sha256 %s）触发流水线 %s", filename, stored.Size, stored.SHA256, target.Name))
+
+	utils.SuccessResponse(c, gin.H{
+		"run":    pipelineRun,
+		"source": stored,
+	})
+}
+
+// findPipeline 查找上传运行的流水线：指定了 pipeline_id 时必须属于该项目，否则项目只能有一条启用的流水线
+func (h *SourceArchiveHandler) findPipeline(c *gin.Context, project *models.Project, pipelineID uint) (*models.Pipeline, bool) {
+	var candidates []models.Pipeline
+	query := database.DB.Where("project_id = ?", project.ID)
+	if pipelineID != 0 {
+		query = query.Where("id = ?", pipelineID)
+	} else {
+		query = query.Where("status = ?", models.PipelineStatusActive)
+	}
+	if err := query.Limit(2).Find(&candidates).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询流水线失败")
+		return nil, false
+	}
+
+	switch {
+	case len(candidates) == 0:
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线不存在")
+		return nil, false
+	case len(candidates) > 1:
+		utils.ErrorResponse(c, http.StatusBadRequest, "项目有多条流水线，请指定 pipeline_id")
+		return nil, false
+	}
+	return &candidates[0], true
+}
+
+// validateSourceSteps 校验流水线配置与项目的源码来源匹配，源码包项目拒绝 git_clone 步骤与仓库配置来源
+func validateSourceSteps(c *gin.Context, project *models.Project, req *models.CreatePipelineRequest) bool {
+	problems := pipeline.ValidateSourceSteps(project, req.ConfigSource, storedConfig(req))
+	if len(problems) == 0 {
+		return true
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, "流水线配置无效: "+strings.Join(problems, "；"))
+	return false
+}
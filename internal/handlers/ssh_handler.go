@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"flowforge/pkg/database"
+	"flowforge/pkg/i18n"
 	"flowforge/pkg/models"
 	"flowforge/pkg/ssh"
 	"flowforge/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // SSHHandler SSH处理器
@@ -26,14 +30,18 @@ func NewSSHHandler(sshManager *ssh.Manager) *SSHHandler {
 
 // GetSSHKeys 获取SSH密钥列表
 func (h *SSHHandler) GetSSHKeys(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
 	var sshKeys []models.SSHKey
 	var total int64
 
-	query := database.DB.Model(&models.SSHKey{}).Where("user_id = ?", userID)
+	// 项目部署密钥在项目下管理，不出现在用户密钥列表中
+	query := database.DB.Model(&models.SSHKey{}).Where("user_id = ? AND purpose <> ?", current.ID, models.SSHKeyPurposeDeployKey)
 	query.Count(&total)
 	query.Scopes(database.Paginate(page, pageSize)).Find(&sshKeys)
 
@@ -54,7 +62,10 @@ func (h *SSHHandler) CreateSSHKey(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	// 生成SSH密钥对
 	publicKey, privateKey, err := ssh.GenerateKeyPair()
@@ -70,9 +81,14 @@ func (h *SSHHandler) CreateSSHKey(c *gin.Context) {
 		Host:       req.Host,
 		Port:       req.Port,
 		Username:   req.Username,
-		UserID:     userID.(uint),
+		UserID:     current.ID,
 		Status:     models.StatusActive,
 	}
+	sshKey.BastionConfig = req.BastionConfig
+	if !validBastionKey(current.ID, req.BastionConfig) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "跳板机使用的SSH密钥无效")
+		return
+	}
 
 	if sshKey.Port == 0 {
 		sshKey.Port = 22
@@ -95,10 +111,13 @@ func (h *SSHHandler) CreateSSHKey(c *gin.Context) {
 // GetSSHKey 获取SSH密钥详情
 func (h *SSHHandler) GetSSHKey(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var sshKey models.SSHKey
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&sshKey).Error; err != nil {
+	if err := database.DB.Where("id = ? AND user_id = ?", id, current.ID).First(&sshKey).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "SSH密钥不存在", "")
 		return
 	}
@@ -112,10 +131,13 @@ func (h *SSHHandler) GetSSHKey(c *gin.Context) {
 // UpdateSSHKey 更新SSH密钥
 func (h *SSHHandler) UpdateSSHKey(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var sshKey models.SSHKey
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&sshKey).Error; err != nil {
+	if err := database.DB.Where("id = ? AND user_id = ?", id, current.ID).First(&sshKey).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "SSH密钥不存在", "")
 		return
 	}
@@ -130,8 +152,15 @@ func (h *SSHHandler) UpdateSSHKey(c *gin.Context) {
 	sshKey.Host = req.Host
 	sshKey.Port = req.Port
 	sshKey.Username = req.Username
+	sshKey.BastionConfig = req.BastionConfig
+	if !validBastionKey(current.ID, req.BastionConfig) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "跳板机使用的SSH密钥无效")
+		return
+	}
 
-	if err := database.DB.Save(&sshKey).Error; err != nil {
+	// 只更新可编辑字段，查询结果中的私钥已被清空，不能整行保存
+	if err := database.DB.Model(&sshKey).Select("name", "host", "port", "username",
+		"bastion_host", "bastion_port", "bastion_username", "bastion_key_id").Updates(&sshKey).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "更新SSH密钥失败", err.Error())
 		return
 	}
@@ -145,15 +174,29 @@ func (h *SSHHandler) UpdateSSHKey(c *gin.Context) {
 // DeleteSSHKey 删除SSH密钥
 func (h *SSHHandler) DeleteSSHKey(c *gin.Context) {
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var sshKey models.SSHKey
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&sshKey).Error; err != nil {
+	if err := database.DB.Where("id = ? AND user_id = ?", id, current.ID).First(&sshKey).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "SSH密钥不存在", "")
 		return
 	}
 
-	if err := database.DB.Delete(&sshKey).Error; err != nil {
+	// 仍被引用的密钥不允许删除，避免项目留下悬空的密钥ID
+	refs, err := database.DeleteSSHKey(&sshKey)
+	if errors.Is(err, database.ErrSSHKeyInUse) {
+		message := database.ErrSSHKeyInUse.Error()
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      i18n.Translate(i18n.FromContext(c), message),
+			"code":       i18n.Code(message),
+			"references": refs,
+		})
+		return
+	}
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "删除SSH密钥失败", err.Error())
 		return
 	}
@@ -161,29 +204,142 @@ func (h *SSHHandler) DeleteSSHKey(c *gin.Context) {
 	utils.SuccessResponse(c, "删除SSH密钥成功", nil)
 }
 
-// TestSSHConnection 测试SSH连接
+// TestSSHConnection 测试SSH连接，配置了跳板机时经跳板机连接目标主机，错误中区分失败的是哪一段连接
 func (h *SSHHandler) TestSSHConnection(c *gin.Context) {
-	id := c.Param("id")
-	userID, _ := c.Get("user_id")
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
 
 	var sshKey models.SSHKey
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&sshKey).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "SSH密钥不存在", "")
+	if err := models.WithPrivateKey(database.DB).Where("id = ? AND user_id = ?", c.Param("id"), current.ID).First(&sshKey).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "SSH密钥不存在")
+		return
+	}
+
+	if err := h.sshManager.GetClient().TestConnection(&sshKey, sshKey.Host, sshKey.Port, sshKey.Username); err != nil {
+		// 失败的连接：bastion 为跳板机，bastion_to_target 为从跳板机到目标主机，target 为直接连接的目标主机
+		hop := "target"
+		switch {
+		case errors.Is(err, ssh.ErrBastionConnect):
+			hop = "bastion"
+		case errors.Is(err, ssh.ErrBastionTarget):
+			hop = "bastion_to_target"
+		}
+		message := "SSH连接测试失败"
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  i18n.Translate(i18n.FromContext(c), message) + ": " + err.Error(),
+			"code":   i18n.Code(message),
+			"failed": hop,
+		})
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "SSH连接测试成功", "via_bastion": sshKey.BastionConfig.Enabled()})
+}
+
+// GetSSHKeyReferences 获取引用SSH密钥的资源列表
+func (h *SSHHandler) GetSSHKeyReferences(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var sshKey models.SSHKey
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), current.ID).First(&sshKey).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "SSH密钥不存在")
+		return
+	}
+
+	refs, err := database.SSHKeyReferences(database.DB, sshKey.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询密钥引用失败")
+		return
+	}
+
+	utils.SuccessResponse(c, refs)
+}
+
+// ReplaceSSHKey 将所有引用从当前密钥原子地切换到另一个密钥，可选随后删除旧密钥
+func (h *SSHHandler) ReplaceSSHKey(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ReplacementID uint `json:"replacement_id" binding:"required"`
+		DeleteOld     bool `json:"delete_old"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
 		return
 	}
 
-	// 测试SSH连接
-	config := ssh.SSHConfig{
-		Host:       sshKey.Host,
-		Port:       sshKey.Port,
-		Username:   sshKey.Username,
-		PrivateKey: sshKey.PrivateKey,
+	var oldKey, newKey models.SSHKey
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), current.ID).First(&oldKey).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "SSH密钥不存在")
+		return
+	}
+	if err := database.DB.Where("id = ? AND user_id = ?", req.ReplacementID, current.ID).First(&newKey).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "替换用的SSH密钥不存在")
+		return
+	}
+	if oldKey.ID == newKey.ID {
+		utils.ErrorResponse(c, http.StatusBadRequest, "不能替换为同一个密钥")
+		return
+	}
+	if newKey.IsDeployKey() || newKey.Status != models.SSHKeyStatusActive {
+		utils.ErrorResponse(c, http.StatusBadRequest, "替换用的密钥必须是可用的通用密钥")
+		return
+	}
+	if oldKey.IsDeployKey() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "部署密钥请通过项目部署密钥轮换替换")
+		return
 	}
 
-	if err := ssh.TestConnection(config); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "SSH连接测试失败", err.Error())
+	var replaced int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Project{}).Where("ssh_key_id = ?", oldKey.ID).Update("ssh_key_id", newKey.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		replaced = result.RowsAffected
+
+		// 作为跳板机密钥的引用一并切换
+		result = tx.Model(&models.SSHKey{}).Where("bastion_key_id = ?", oldKey.ID).Update("bastion_key_id", newKey.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		replaced += result.RowsAffected
+
+		if req.DeleteOld {
+			return tx.Delete(&oldKey).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "替换SSH密钥失败")
 		return
 	}
 
-	utils.SuccessResponse(c, "SSH连接测试成功", nil)
+	recordAudit(c, "replace_ssh_key", "ssh_key", oldKey.ID,
+		fmt.Sprintf("将 %d 处引用从密钥 %s 切换到 %s", replaced, oldKey.Name, newKey.Name))
+
+	utils.SuccessResponse(c, gin.H{
+		"replaced":    replaced,
+		"old_deleted": req.DeleteOld,
+	})
+}
+
+// validBastionKey 跳板机密钥为空，或为当前用户可用于远程操作的密钥
+func validBastionKey(userID uint, bastion models.BastionConfig) bool {
+	if bastion.BastionKeyID == nil {
+		return true
+	}
+	var key models.SSHKey
+	if err := database.DB.Where("id = ? AND user_id = ?", *bastion.BastionKeyID, userID).First(&key).Error; err != nil {
+		return false
+	}
+	return !key.IsDeployKey() && key.Status == models.SSHKeyStatusActive
 }
\ No newline at end of file
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStepTypes 列出已注册的步骤类型及其配置结构，包括通过构建标签或插件注册的类型
+func (h *PipelineHandler) GetStepTypes(c *gin.Context) {
+	utils.SuccessResponse(c, gin.H{
+		"step_types": pipeline.StepTypes(),
+	})
+}
+
+// storedConfig 解析请求中保存的流水线配置，配置来源不是 stored 或无法解析时返回 nil；
+// 无法解析的配置已由 validatePipelineConfig 拒绝
+func storedConfig(req *models.CreatePipelineRequest) *models.PipelineConfig {
+	if req.ConfigSource != models.ConfigSourceStored {
+		return nil
+	}
+	config, err := pipeline.ParseConfig(req.Config)
+	if err != nil {
+		return nil
+	}
+	return config
+}
+
+// validatePipelineConfig 解析并校验保存的流水线配置（YAML 或 JSON）：格式、阶段与步骤的名称、步骤类型已注册且配置符合执行器的要求，
+// 未通过时拒绝保存，错误响应中按阶段与步骤列出全部问题
+func validatePipelineConfig(c *gin.Context, req *models.CreatePipelineRequest) bool {
+	if req.ConfigSource != models.ConfigSourceStored {
+		return true
+	}
+	_, err := pipeline.LoadConfig(req.Config)
+	return !configErrorResponse(c, err)
+}
+
+// configErrorResponse err 为配置无效时返回 400，problems 中为全部问题，并返回 true
+func configErrorResponse(c *gin.Context, err error) bool {
+	var configErr *pipeline.ConfigError
+	if !errors.As(err, &configErr) {
+		return false
+	}
+	message := configErr.Error()
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":    i18n.Translate(i18n.FromContext(c), message),
+		"code":     i18n.Code(message),
+		"problems": configErr.Problems,
+	})
+	return true
+}
+
+// validateShells 校验保存的流水线配置中脚本步骤要求的解释器在执行主机上可用
+func (h *PipelineHandler) validateShells(c *gin.Context, project *models.Project, req *models.CreatePipelineRequest) bool {
+	config := storedConfig(req)
+	if config == nil {
+		return true
+	}
+
+	problems := h.engine.ValidateShells(project, config)
+	if len(problems) == 0 {
+		return true
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, "流水线配置无效: "+strings.Join(problems, "；"))
+	return false
+}
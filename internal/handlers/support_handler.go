@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"flowforge/pkg/support"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SupportHandler 诊断包处理器
+type SupportHandler struct {
+	generator *support.Generator
+}
+
+// NewSupportHandler 创建诊断包处理器
+func NewSupportHandler(generator *support.Generator) *SupportHandler {
+	return &SupportHandler{
+		generator: generator,
+	}
+}
+
+// CreateBundle 异步生成诊断包，通过 GetBundle 查询进度
+func (h *SupportHandler) CreateBundle(c *gin.Context) {
+	current, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.generator.Start(current)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成诊断包失败")
+		return
+	}
+
+	recordAudit(c, "generate_support_bundle", "support_bundle", 0, "生成诊断包 "+job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{"data": job})
+}
+
+// GetBundle 查询诊断包生成进度
+func (h *SupportHandler) GetBundle(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	job, ok := h.generator.Get(c.Param("id"))
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "诊断包不存在")
+		return
+	}
+
+	utils.SuccessResponse(c, job)
+}
+
+// DownloadBundle 下载已生成的诊断包
+func (h *SupportHandler) DownloadBundle(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	path, ok := h.generator.FilePath(c.Param("id"))
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "诊断包不存在或尚未生成完成")
+		return
+	}
+
+	c.FileAttachment(path, filepath.Base(path))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TargetHandler 部署目标处理器
+type TargetHandler struct {
+	preflighter *deploy.Preflighter
+}
+
+// NewTargetHandler 创建部署目标处理器
+func NewTargetHandler(preflighter *deploy.Preflighter) *TargetHandler {
+	return &TargetHandler{
+		preflighter: preflighter,
+	}
+}
+
+// Preflight 立即对部署目标执行部署前检查，只读取不修改目标；
+// :target_id 为漂移接口返回的目标清单ID，磁盘要求按该目标最近一次部署的文件总大小计算
+func (h *TargetHandler) Preflight(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var manifest models.DeploymentManifest
+	if err := database.DB.Where("id = ? AND project_id = ?", c.Param("target_id"), project.ID).First(&manifest).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "部署目标不存在")
+		return
+	}
+
+	var sshKey models.SSHKey
+	if err := models.WithPrivateKey(database.DB).First(&sshKey, manifest.SSHKeyID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "部署使用的SSH密钥不存在")
+		return
+	}
+
+	target := &deploy.DriftTarget{
+		Host:        manifest.Host,
+		Port:        manifest.Port,
+		Username:    manifest.Username,
+		RemoteDir:   manifest.RemoteDir,
+		ServiceUnit: manifest.ServiceUnit,
+		SSHKey:      &sshKey,
+	}
+	opts := deploy.PreflightOptions{
+		RequiredBytes: manifest.TotalSize,
+		Sudo:          c.Query("require_sudo") == "true",
+		ProjectID:     project.ID,
+	}
+	if manifest.ServiceUnit != "" {
+		opts.Binaries = append(opts.Binaries, "systemctl")
+	}
+
+	report := h.preflighter.Run(c.Request.Context(), target, opts)
+
+	recordAudit(c, "preflight_target", "project", project.ID,
+		fmt.Sprintf("对部署目标 %s 执行部署前检查: %s", manifest.Target, report.Summary()))
+
+	utils.SuccessResponse(c, report)
+}
+
+// loadProject 加载项目并校验当前用户为项目所有者或管理员
+func (h *TargetHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
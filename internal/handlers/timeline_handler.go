@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/timeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTimeline 项目动态：运行、部署与审计日志中的配置变更合并为一条按时间倒序的时间线，以 cursor/limit 游标分页。
+// type 按条目类型过滤（逗号分隔），from、to 为时间范围（RFC3339 或日期，只有日期的 to 包含当天），q 按摘要中的文本过滤
+func (h *ProjectHandler) GetTimeline(c *gin.Context) {
+	project, ok := h.loadOwnedProject(c)
+	if !ok {
+		return
+	}
+
+	query := timeline.Query{ProjectID: project.ID, Search: c.Query("q")}
+	for _, typ := range strings.Split(c.Query("type"), ",") {
+		if typ = strings.TrimSpace(typ); typ == "" {
+			continue
+		}
+		if !timeline.IsValidType(typ) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的动态类型")
+			return
+		}
+		query.Types = append(query.Types, typ)
+	}
+
+	var err error
+	if query.From, err = parseTimelineTime(c.Query("from"), false); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的时间范围")
+		return
+	}
+	if query.To, err = parseTimelineTime(c.Query("to"), true); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的时间范围")
+		return
+	}
+
+	if query.Cursor, err = timeline.DecodeCursor(c.Query("cursor")); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的分页游标")
+		return
+	}
+	query.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	page, err := timeline.Load(h.db, query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目动态失败")
+		return
+	}
+	utils.SuccessResponse(c, page)
+}
+
+// parseTimelineTime 解析时间范围的一端，为空时返回零值；只有日期时 end 为 true 返回次日零点，使范围包含当天
+func parseTimelineTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/flags"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/runlabel"
+	"flowforge/pkg/utils"
+	"flowforge/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// triggerEvent Webhook 事件中决定触发哪些流水线的内容：真实投递从请求体解析，触发模拟按请求参数构造，
+// 两者经过相同的检查
+type triggerEvent struct {
+	Event    string
+	Ref      string
+	Files    []string
+	HasFiles bool // 投递包含提交列表；没有时（如 PR 事件）路径过滤总是通过
+	Fork     bool // 来自 fork 仓库的 PR/MR
+	Commit   string
+}
+
+// parseTriggerEvent 从投递的请求体解析事件
+func parseTriggerEvent(event string, body []byte) triggerEvent {
+	files, ok := webhook.ChangedFiles(body)
+	return triggerEvent{
+		Event:    event,
+		Ref:      webhook.EventRef(body),
+		Files:    files,
+		HasFiles: ok,
+		Fork:     webhook.IsForkPullRequest(body),
+		Commit:   webhook.HeadCommit(body),
+	}
+}
+
+// webhookEventChecks Webhook 是否处理事件：已启用且订阅了事件类型，未通过时投递被忽略
+func webhookEventChecks(hook *models.Webhook, event string) []accessCheck {
+	status := accessCheck{Name: "webhook_status", Passed: hook.Status == models.StatusActive, Blocking: true, Detail: "Webhook 已启用"}
+	if !status.Passed {
+		status.Detail = "Webhook 未启用，投递将被忽略"
+	}
+	subscribed := accessCheck{Name: "event", Passed: webhook.AcceptsEvent(hook, event), Blocking: true,
+		Detail: fmt.Sprintf("Webhook 订阅了 %s 事件", event)}
+	if !subscribed.Passed {
+		subscribed.Detail = fmt.Sprintf("Webhook 只订阅 %s，%s 事件将被忽略", hook.Events, event)
+	}
+	return []accessCheck{status, subscribed}
+}
+
+// pathFilterCheck Webhook 的路径过滤，未通过时 Detail 为记录到跳过运行中的原因
+func pathFilterCheck(ctx context.Context, hook *models.Webhook, ev triggerEvent) accessCheck {
+	check := accessCheck{Name: "path_filter", Passed: true, Blocking: true, Detail: "Webhook 未配置路径过滤"}
+	if !flags.Enabled(ctx, flags.WebhookPathFilters, hook.ProjectID) {
+		check.Detail = "项目未开启 Webhook 路径过滤"
+		return check
+	}
+	filters := webhook.PathFilters(hook)
+	if len(filters) == 0 {
+		return check
+	}
+	check.Passed, check.Detail = webhook.FilterFiles(hook, ev.Files, ev.HasFiles)
+	switch {
+	case check.Passed && !ev.HasFiles:
+		check.Detail = "事件没有变更文件列表，路径过滤不生效"
+	case check.Passed:
+		check.Detail = "变更文件匹配路径过滤: " + strings.Join(filters, ", ")
+	}
+	return check
+}
+
+// webhookPipelineChecks 流水线是否由 Webhook 触发：触发方式为 webhook 且已启用，未通过时不参与本次投递
+func webhookPipelineChecks(p *models.Pipeline) []accessCheck {
+	trigger := accessCheck{Name: "trigger_type", Passed: p.Trigger == models.TriggerWebhook, Blocking: true, Detail: "流水线由 Webhook 触发"}
+	if !trigger.Passed {
+		trigger.Detail = fmt.Sprintf("流水线的触发方式为 %s，不由 Webhook 触发", p.Trigger)
+	}
+	status := accessCheck{Name: "pipeline_status", Passed: p.Status == models.PipelineStatusActive, Blocking: true, Detail: "流水线已启用"}
+	if !status.Passed {
+		status.Detail = fmt.Sprintf("流水线状态为 %s", p.Status)
+	}
+	return []accessCheck{trigger, status}
+}
+
+// forkCheck 仓库配置来源会执行PR中的配置文件，默认拒绝来自fork的PR
+func forkCheck(p *models.Pipeline, ev triggerEvent) accessCheck {
+	check := accessCheck{Name: "fork_pull_request", Passed: !(ev.Fork && p.UsesRepoConfig() && !p.AllowForkPRs), Blocking: true}
+	switch {
+	case !check.Passed:
+		check.Detail = "流水线使用仓库中的配置文件，拒绝来自 fork 的 PR"
+	case ev.Fork:
+		check.Detail = "来自 fork 的 PR，流水线允许运行"
+	default:
+		check.Detail = "不是来自 fork 的 PR"
+	}
+	return check
+}
+
+// runnableCheck 创建运行前引擎的校验（项目未归档、源码来源、保存的配置有效），与实际触发使用同一函数
+func runnableCheck(p *models.Pipeline) accessCheck {
+	check := accessCheck{Name: "runnable", Passed: true, Blocking: true, Detail: "流水线可以运行"}
+	if err := pipeline.CheckRunnable(p, pipeline.RunOptions{}); err != nil {
+		check.Passed = false
+		check.Detail = err.Error()
+	}
+	return check
+}
+
+// simulateTriggerRequest 模拟触发的假设事件
+type simulateTriggerRequest struct {
+	Provider     string   `json:"provider"` // github（默认）、gitea 或 gitlab，决定事件类型的名称
+	Event        string   `json:"event"`    // 事件类型，为空时按 tag 为推送或标签推送事件
+	Ref          string   `json:"ref" binding:"required"`
+	Tag          bool     `json:"tag"`           // ref 不是完整 ref 时按标签补全为 refs/tags/
+	ChangedPaths []string `json:"changed_paths"` // 为空表示事件没有变更文件列表
+	Fork         bool     `json:"fork"`          // PR/MR 来自 fork 仓库
+	Commit       string   `json:"commit"`
+	Actor        string   `json:"actor"`
+}
+
+// event 按平台补全事件类型与 ref
+func (r *simulateTriggerRequest) event() (triggerEvent, error) {
+	ev := triggerEvent{
+		Event:    r.Event,
+		Ref:      strings.TrimSpace(r.Ref),
+		Files:    r.ChangedPaths,
+		HasFiles: len(r.ChangedPaths) > 0,
+		Fork:     r.Fork,
+		Commit:   r.Commit,
+	}
+	switch r.Provider {
+	case "", "github", "gitea":
+		if ev.Event == "" {
+			ev.Event = "push"
+		}
+	case "gitlab":
+		// GitLab 的标签推送为 Tag Push Hook，与实际投递一样统一为 tag_push
+		if ev.Event == "" && r.Tag {
+			ev.Event = "tag_push"
+		} else if ev.Event == "" {
+			ev.Event = "push"
+		}
+	default:
+		return ev, fmt.Errorf("provider 只能是 github、gitea 或 gitlab")
+	}
+	if !strings.HasPrefix(ev.Ref, "refs/") {
+		if r.Tag {
+			ev.Ref = "refs/tags/" + ev.Ref
+		} else {
+			ev.Ref = "refs/heads/" + ev.Ref
+		}
+	}
+	return ev, nil
+}
+
+// webhookSimulation 一个 Webhook 对假设事件的处理结果
+type webhookSimulation struct {
+	WebhookID  uint                 `json:"webhook_id"`
+	Name       string               `json:"name"`
+	Accepted   bool                 `json:"accepted"` // 是否处理该事件
+	Checks     []accessCheck        `json:"checks"`
+	LabelRules []labelRuleMatch     `json:"label_rules"`
+	Labels     []string             `json:"labels"`
+	Pipelines  []pipelineSimulation `json:"pipelines"`
+}
+
+// labelRuleMatch 标签规则是否匹配事件的 ref
+type labelRuleMatch struct {
+	Ref     string `json:"ref"`
+	Label   string `json:"label"`
+	Matched bool   `json:"matched"`
+}
+
+// pipelineSimulation 一条流水线对假设事件的处理结果
+type pipelineSimulation struct {
+	PipelineID uint          `json:"pipeline_id"`
+	Name       string        `json:"name"`
+	Triggered  bool          `json:"triggered"`
+	Outcome    string        `json:"outcome"`              // run、skipped_run（记录跳过的运行）或 none
+	BlockedBy  string        `json:"blocked_by,omitempty"` // 第一项未通过的检查
+	Checks     []accessCheck `json:"checks"`
+	RunParams  gin.H         `json:"run_params,omitempty"`
+}
+
+// SimulateTrigger 模拟一次假设的推送：按与接收投递相同的检查说明项目的每个 Webhook 与流水线是否会触发、
+// 由哪条规则允许或阻止，以及匹配的标签规则与运行参数。不登记投递、不创建运行，不做投递去重
+func (h *WebhookHandler) SimulateTrigger(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var req simulateTriggerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	ev, err := req.event()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var hooks []models.Webhook
+	database.DB.Where("project_id = ?", project.ID).Order("id").Find(&hooks)
+	var pipelines []models.Pipeline
+	database.DB.Where("project_id = ?", project.ID).Order("id").Find(&pipelines)
+
+	effects := runEffectChecks(project)
+	results := make([]webhookSimulation, 0, len(hooks))
+	for i := range hooks {
+		hook := &hooks[i]
+		hook.Project = *project
+		result := webhookSimulation{WebhookID: hook.ID, Name: hook.Name, LabelRules: []labelRuleMatch{}, Labels: []string{}, Pipelines: []pipelineSimulation{}}
+
+		result.Checks = webhookEventChecks(hook, ev.Event)
+		if hook.RegistryType != "" {
+			result.Checks = append(result.Checks, accessCheck{Name: "registry", Passed: false, Blocking: true,
+				Detail: "镜像仓库 Webhook 按推送的镜像触发，不处理 git 事件"})
+		}
+		result.Accepted = firstFailure(result.Checks) == nil
+
+		rules, _ := webhook.ParseLabelRules(hook.LabelRules)
+		for _, rule := range rules {
+			result.LabelRules = append(result.LabelRules, labelRuleMatch{Ref: rule.Ref, Label: rule.Label, Matched: webhook.MatchPath(rule.Ref, ev.Ref)})
+		}
+		labels, err := runlabel.Check(project, webhook.MatchRefLabels(hook, ev.Ref))
+		if err == nil && labels != nil {
+			result.Labels = labels
+		}
+
+		paths := pathFilterCheck(c.Request.Context(), hook, ev)
+		for j := range pipelines {
+			p := &pipelines[j]
+			p.Project = *project
+			sim := pipelineSimulation{PipelineID: p.ID, Name: p.Name, Outcome: "none"}
+			sim.Checks = append(append([]accessCheck{}, result.Checks...), webhookPipelineChecks(p)...)
+			sim.Checks = append(sim.Checks, forkCheck(p, ev), paths, runnableCheck(p))
+			sim.Checks = append(sim.Checks, effects...)
+
+			if failed := firstFailure(sim.Checks); failed != nil {
+				sim.BlockedBy = failed.Name
+				// 路径过滤与 fork 拒绝在开启记录跳过时创建 skipped 运行
+				if hook.RecordSkipped && (failed.Name == "path_filter" || failed.Name == "fork_pull_request") {
+					sim.Outcome = "skipped_run"
+				}
+			} else {
+				sim.Triggered = true
+				sim.Outcome = "run"
+				sim.RunParams = gin.H{
+					"trigger_type": models.TriggerWebhook,
+					"trigger_by":   project.UserID,
+					"branch":       project.Branch,
+					"commit_sha":   ev.Commit,
+					"labels":       result.Labels,
+				}
+			}
+			result.Pipelines = append(result.Pipelines, sim)
+		}
+		results = append(results, result)
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"event": gin.H{
+			"type":          ev.Event,
+			"ref":           ev.Ref,
+			"changed_paths": ev.Files,
+			"fork":          ev.Fork,
+			"actor":         req.Actor,
+		},
+		// Webhook 触发的运行以项目所有者的身份执行，推送者不影响检查结果
+		"run_as":   project.UserID,
+		"webhooks": results,
+	})
+}
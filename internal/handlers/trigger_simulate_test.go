@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/flags"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubRunner 记录投递触发的运行，不执行：创建运行前按引擎相同的条件校验，跳过的运行只记录流水线
+type stubRunner struct {
+	nextID  uint
+	runs    map[uint]pipeline.RunOptions
+	skipped []uint
+}
+
+func (s *stubRunner) RunPipelineWithOptions(pipelineID uint, triggerType string, triggerBy uint, opts pipeline.RunOptions) (*models.PipelineRun, error) {
+	var p models.Pipeline
+	if err := database.DB.Preload("Project").First(&p, pipelineID).Error; err != nil {
+		return nil, err
+	}
+	if err := pipeline.CheckRunnable(&p, opts); err != nil {
+		return nil, err
+	}
+	s.nextID++
+	s.runs[pipelineID] = opts
+	return &models.PipelineRun{ID: s.nextID, PipelineID: pipelineID, TriggerType: triggerType, UserID: triggerBy}, nil
+}
+
+func (s *stubRunner) RecordSkippedRun(pipelineID uint, triggerType string, triggerBy uint, commitSHA, reason string) (*models.PipelineRun, error) {
+	s.nextID++
+	s.skipped = append(s.skipped, pipelineID)
+	return &models.PipelineRun{ID: s.nextID, PipelineID: pipelineID, Status: models.RunStatusSkipped}, nil
+}
+
+// validPipelineConfig 通过校验的保存配置
+const validPipelineConfig = `stages:
+  - name: build
+    steps:
+      - name: test
+        type: script
+        config:
+          script: go test ./...
+`
+
+// setupTriggerTest 项目的 Webhook 与各种状态的流水线
+func setupTriggerTest(t *testing.T) (*models.Project, []models.Webhook) {
+	t.Helper()
+	setupAccessTest(t)
+	flags.Invalidate()
+	t.Cleanup(flags.Invalidate)
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Deploy: config.DeployConfig{WebhookDedupWindow: -1}}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	var project models.Project
+	if err := database.DB.First(&project).Error; err != nil {
+		t.Fatal(err)
+	}
+	pipelines := []models.Pipeline{
+		{Name: "webhook", Trigger: models.TriggerWebhook, Config: validPipelineConfig},
+		{Name: "manual", Trigger: models.TriggerManual, Config: validPipelineConfig},
+		{Name: "inactive", Trigger: models.TriggerWebhook, Status: models.PipelineStatusInactive, Config: validPipelineConfig},
+		{Name: "repo-config", Trigger: models.TriggerWebhook, ConfigSource: models.ConfigSourceRepo},
+		{Name: "invalid-config", Trigger: models.TriggerWebhook, Config: "stages: []"},
+	}
+	for i := range pipelines {
+		pipelines[i].ProjectID = project.ID
+		if err := database.DB.Create(&pipelines[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hooks := []models.Webhook{
+		{Name: "filtered", Events: "push,pull_request", PathFilters: "src/**", RecordSkipped: true, LabelRules: "refs/tags/**=release"},
+		{Name: "all-events", Events: "*"},
+		{Name: "disabled", Events: "*", Status: "inactive"},
+	}
+	for i := range hooks {
+		hooks[i].ProjectID = project.ID
+		hooks[i].URL = "/api/v1/webhooks/receive"
+		if err := database.DB.Create(&hooks[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &project, hooks
+}
+
+// triggerCase 同一事件的真实投递与模拟请求
+type triggerCase struct {
+	name     string
+	event    string
+	body     string
+	simulate simulateTriggerRequest
+}
+
+var triggerCases = []triggerCase{
+	{
+		name:     "推送匹配路径过滤",
+		event:    "push",
+		body:     `{"ref":"refs/heads/main","after":"aaa111","head_commit":{"id":"aaa111"},"commits":[{"modified":["src/main.go"]}]}`,
+		simulate: simulateTriggerRequest{Ref: "main", ChangedPaths: []string{"src/main.go"}, Commit: "aaa111"},
+	},
+	{
+		name:     "推送不匹配路径过滤",
+		event:    "push",
+		body:     `{"ref":"refs/heads/main","after":"bbb222","head_commit":{"id":"bbb222"},"commits":[{"added":["docs/readme.md"]}]}`,
+		simulate: simulateTriggerRequest{Ref: "main", ChangedPaths: []string{"docs/readme.md"}, Commit: "bbb222"},
+	},
+	{
+		name:     "标签推送",
+		event:    "push",
+		body:     `{"ref":"refs/tags/v1.2.0","after":"ccc333","head_commit":{"id":"ccc333"},"commits":[{"modified":["src/version.go"]}]}`,
+		simulate: simulateTriggerRequest{Ref: "v1.2.0", Tag: true, ChangedPaths: []string{"src/version.go"}, Commit: "ccc333"},
+	},
+	{
+		name:  "来自 fork 的 PR",
+		event: "pull_request",
+		body: `{"action":"opened","pull_request":{"head":{"ref":"feature","sha":"ddd444","repo":{"full_name":"someone/web"}},` +
+			`"base":{"ref":"main","repo":{"full_name":"team/web"}}}}`,
+		simulate: simulateTriggerRequest{Event: "pull_request", Ref: "feature", Fork: true, Commit: "ddd444"},
+	},
+	{
+		name:     "未订阅的事件",
+		event:    "issues",
+		body:     `{"action":"opened"}`,
+		simulate: simulateTriggerRequest{Event: "issues", Ref: "main"},
+	},
+}
+
+// receive 以 handler 接收真实投递，返回触发的运行与记录的跳过运行，以及是否处理了事件
+func receive(t *testing.T, hook *models.Webhook, event, body string) (*stubRunner, bool) {
+	t.Helper()
+	runner := &stubRunner{runs: make(map[uint]pipeline.RunOptions)}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/webhooks/%d/receive", hook.ID), strings.NewReader(body))
+	c.Request.Header.Set("X-GitHub-Event", event)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(hook.ID)}}
+	(&WebhookHandler{engine: runner}).Receive(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("接收投递返回 %d: %s", w.Code, w.Body.String())
+	}
+	// 忽略的事件只返回 triggered
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	_, handled := resp.Data["run_ids"]
+	return runner, handled
+}
+
+// simulate 以项目所有者请求触发模拟，按 Webhook ID 返回结果
+func simulate(t *testing.T, project *models.Project, req simulateTriggerRequest) map[uint]webhookSimulation {
+	t.Helper()
+	data, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/projects/%d/triggers/simulate", project.ID), bytes.NewReader(data))
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(project.ID)}}
+	authctx.SetCurrentUser(c, ownerUser)
+	(&WebhookHandler{}).SimulateTrigger(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("触发模拟返回 %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Data struct {
+			Webhooks []webhookSimulation `json:"webhooks"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[uint]webhookSimulation, len(body.Data.Webhooks))
+	for _, result := range body.Data.Webhooks {
+		results[result.WebhookID] = result
+	}
+	return results
+}
+
+// TestSimulateMatchesReceive 同一事件经过触发模拟与真实投递，触发的流水线、记录跳过的流水线与运行参数一致
+func TestSimulateMatchesReceive(t *testing.T) {
+	project, hooks := setupTriggerTest(t)
+
+	var ran, skipped int
+	for _, tc := range triggerCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulated := simulate(t, project, tc.simulate)
+			for i := range hooks {
+				hook := &hooks[i]
+				runner, handled := receive(t, hook, tc.event, tc.body)
+				result, ok := simulated[hook.ID]
+				if !ok {
+					t.Fatalf("模拟结果缺少 Webhook %s", hook.Name)
+				}
+
+				var simRuns, simSkipped []uint
+				for _, p := range result.Pipelines {
+					switch p.Outcome {
+					case "run":
+						simRuns = append(simRuns, p.PipelineID)
+						opts := runner.runs[p.PipelineID]
+						if commit := p.RunParams["commit_sha"]; commit != opts.CommitSHA {
+							t.Errorf("%s/%s 模拟的提交 %v，实际为 %q", hook.Name, p.Name, commit, opts.CommitSHA)
+						}
+						if labels := fmt.Sprint(p.RunParams["labels"]); labels != fmt.Sprint(opts.Labels) && !(labels == "[]" && len(opts.Labels) == 0) {
+							t.Errorf("%s/%s 模拟的标签 %v，实际为 %v", hook.Name, p.Name, labels, opts.Labels)
+						}
+					case "skipped_run":
+						simSkipped = append(simSkipped, p.PipelineID)
+					}
+				}
+
+				ran += len(runner.runs)
+				skipped += len(runner.skipped)
+				var runs []uint
+				for id := range runner.runs {
+					runs = append(runs, id)
+				}
+				if !sameIDs(simRuns, runs) {
+					t.Errorf("%s: 模拟触发 %v，实际触发 %v", hook.Name, simRuns, runs)
+				}
+				if !sameIDs(simSkipped, runner.skipped) {
+					t.Errorf("%s: 模拟记录跳过 %v，实际记录跳过 %v", hook.Name, simSkipped, runner.skipped)
+				}
+				if result.Accepted != handled {
+					t.Errorf("%s: 模拟的 accepted=%v，实际处理事件 %v", hook.Name, result.Accepted, handled)
+				}
+			}
+		})
+	}
+	// 用例需要同时覆盖触发与记录跳过，否则一致性没有意义
+	if ran == 0 || skipped == 0 {
+		t.Fatalf("用例应触发运行并记录跳过的运行，实际触发 %d、跳过 %d", ran, skipped)
+	}
+}
+
+// TestSimulateBlockedBy 模拟结果指出阻止每条流水线的检查
+func TestSimulateBlockedBy(t *testing.T) {
+	project, hooks := setupTriggerTest(t)
+	results := simulate(t, project, triggerCases[3].simulate)
+
+	want := map[string]string{
+		"build":          "trigger_type",
+		"webhook":        "",
+		"manual":         "trigger_type",
+		"inactive":       "pipeline_status",
+		"repo-config":    "fork_pull_request",
+		"invalid-config": "runnable",
+	}
+	for _, p := range results[hooks[0].ID].Pipelines {
+		if p.BlockedBy != want[p.Name] {
+			t.Errorf("流水线 %s 被 %q 阻止，应为 %q", p.Name, p.BlockedBy, want[p.Name])
+		}
+	}
+	for _, p := range results[hooks[2].ID].Pipelines {
+		if p.BlockedBy != "webhook_status" {
+			t.Errorf("未启用的 Webhook 不应触发 %s，被 %q 阻止", p.Name, p.BlockedBy)
+		}
+	}
+}
+
+// sameIDs 两组流水线ID是否相同，不计顺序
+func sameIDs(a, b []uint) bool {
+	a, b = append([]uint{}, a...), append([]uint{}, b...)
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
+	"flowforge/internal/middleware"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/interlock"
 	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -27,7 +32,7 @@ func (h *UserHandler) List(c *gin.Context) {
 	var users []models.User
 	result := h.db.Preload("Role").Find(&users)
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户列表失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取用户列表失败")
 		return
 	}
 
@@ -38,14 +43,14 @@ func (h *UserHandler) List(c *gin.Context) {
 func (h *UserHandler) Get(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
 		return
 	}
 
 	var user models.User
 	result := h.db.Preload("Role").First(&user, id)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
 		return
 	}
 
@@ -66,7 +71,7 @@ type CreateUserRequest struct {
 func (h *UserHandler) Create(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的请求参数")
 		return
 	}
 
@@ -74,14 +79,14 @@ func (h *UserHandler) Create(c *gin.Context) {
 	var count int64
 	h.db.Model(&models.User{}).Where("username = ?", req.Username).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "用户名已存在"})
+		utils.ErrorResponse(c, http.StatusConflict, "用户名已存在")
 		return
 	}
 
 	// 检查邮箱是否已存在
 	h.db.Model(&models.User{}).Where("email = ?", req.Email).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "邮箱已存在"})
+		utils.ErrorResponse(c, http.StatusConflict, "邮箱已存在")
 		return
 	}
 
@@ -89,14 +94,14 @@ func (h *UserHandler) Create(c *gin.Context) {
 	var role models.Role
 	result := h.db.First(&role, req.RoleID)
 	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "角色不存在")
 		return
 	}
 
 	// 加密密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "密码加密失败")
 		return
 	}
 
@@ -111,19 +116,23 @@ func (h *UserHandler) Create(c *gin.Context) {
 	}
 
 	if result := h.db.Create(&user); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建用户失败")
 		return
 	}
 
 	// 记录审计日志
 	auditLog := models.AuditLog{
-		UserID:      &user.ID,
-		Action:      "create_user",
-		Description: "创建用户",
-		IP:          c.ClientIP(),
-		UserAgent:   c.GetHeader("User-Agent"),
+		UserID:       &user.ID,
+		Action:       "create_user",
+		ResourceType: "user",
+		ResourceID:   user.ID,
+		Description:  "创建用户",
+		IP:           c.ClientIP(),
+		UserAgent:    c.GetHeader("User-Agent"),
+	}
+	if err := createAuditLog(c, h.db, &auditLog); err != nil {
+		log.Printf("记录审计日志失败: %v", err)
 	}
-	h.db.Create(&auditLog)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "用户创建成功",
@@ -144,13 +153,13 @@ type UpdateUserRequest struct {
 func (h *UserHandler) Update(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
 		return
 	}
 
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的请求参数")
 		return
 	}
 
@@ -158,7 +167,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 	var user models.User
 	result := h.db.First(&user, id)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
 		return
 	}
 
@@ -167,7 +176,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 		var count int64
 		h.db.Model(&models.User{}).Where("email = ? AND id != ?", req.Email, id).Count(&count)
 		if count > 0 {
-			c.JSON(http.StatusConflict, gin.H{"error": "邮箱已被其他用户使用"})
+			utils.ErrorResponse(c, http.StatusConflict, "邮箱已被其他用户使用")
 			return
 		}
 		user.Email = req.Email
@@ -182,7 +191,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 		var role models.Role
 		result := h.db.First(&role, *req.RoleID)
 		if result.Error != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在"})
+			utils.ErrorResponse(c, http.StatusBadRequest, "角色不存在")
 			return
 		}
 		user.RoleID = *req.RoleID
@@ -195,7 +204,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 	if req.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+			utils.ErrorResponse(c, http.StatusInternalServerError, "密码加密失败")
 			return
 		}
 		user.Password = string(hashedPassword)
@@ -203,30 +212,35 @@ func (h *UserHandler) Update(c *gin.Context) {
 
 	// 保存更新
 	if result := h.db.Save(&user); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新用户失败")
 		return
 	}
+	middleware.InvalidateUser(user.ID)
 
 	// 记录审计日志
 	auditLog := models.AuditLog{
-		UserID:      &user.ID,
-		Action:      "update_user",
-		Description: "更新用户",
-		IP:          c.ClientIP(),
-		UserAgent:   c.GetHeader("User-Agent"),
+		UserID:       &user.ID,
+		Action:       "update_user",
+		ResourceType: "user",
+		ResourceID:   user.ID,
+		Description:  "更新用户",
+		IP:           c.ClientIP(),
+		UserAgent:    c.GetHeader("User-Agent"),
+	}
+	if err := createAuditLog(c, h.db, &auditLog); err != nil {
+		log.Printf("记录审计日志失败: %v", err)
 	}
-	h.db.Create(&auditLog)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "用户更新成功",
 	})
 }
 
-// Delete 删除用户
+// Delete 删除用户。需要两步确认：首次请求返回 428 与确认令牌，再次请求在请求头中回传令牌与用户名后执行
 func (h *UserHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
 		return
 	}
 
@@ -234,25 +248,35 @@ func (h *UserHandler) Delete(c *gin.Context) {
 	var user models.User
 	result := h.db.First(&user, id)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+		return
+	}
+	if !confirmDestructive(c, interlock.OperationDeleteUser, "user", user.ID, user.Username, func() (interlock.Summary, error) {
+		return userDeletionSummary(h.db, &user)
+	}) {
 		return
 	}
 
 	// 删除用户（软删除）
 	if result := h.db.Delete(&user); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除用户失败")
 		return
 	}
+	middleware.InvalidateUser(user.ID)
 
 	// 记录审计日志
 	auditLog := models.AuditLog{
-		UserID:      &user.ID,
-		Action:      "delete_user",
-		Description: "删除用户",
-		IP:          c.ClientIP(),
-		UserAgent:   c.GetHeader("User-Agent"),
+		UserID:       &user.ID,
+		Action:       "delete_user",
+		ResourceType: "user",
+		ResourceID:   user.ID,
+		Description:  "删除用户",
+		IP:           c.ClientIP(),
+		UserAgent:    c.GetHeader("User-Agent"),
+	}
+	if err := createAuditLog(c, h.db, &auditLog); err != nil {
+		log.Printf("记录审计日志失败: %v", err)
 	}
-	h.db.Create(&auditLog)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "用户删除成功",
@@ -261,16 +285,15 @@ func (h *UserHandler) Delete(c *gin.Context) {
 
 // GetCurrentUser 获取当前用户信息
 func (h *UserHandler) GetCurrentUser(c *gin.Context) {
-	userID, exists := c.Get("userId")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+	current, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
 	var user models.User
-	result := h.db.Preload("Role").First(&user, userID)
+	result := h.db.Preload("Role").First(&user, current.ID)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
 		return
 	}
 
@@ -282,36 +305,36 @@ type UpdateCurrentUserRequest struct {
 	Email    string `json:"email" binding:"omitempty,email"`
 	FullName string `json:"full_name"`
 	Password string `json:"password"`
+	Locale   string `json:"locale"`
 }
 
 // UpdateCurrentUser 更新当前用户信息
 func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
-	userID, exists := c.Get("userId")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+	current, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
 	var req UpdateCurrentUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的请求参数")
 		return
 	}
 
 	// 查找用户
 	var user models.User
-	result := h.db.First(&user, userID)
+	result := h.db.First(&user, current.ID)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
 		return
 	}
 
 	// 检查邮箱是否已被其他用户使用
 	if req.Email != "" && req.Email != user.Email {
 		var count int64
-		h.db.Model(&models.User{}).Where("email = ? AND id != ?", req.Email, userID).Count(&count)
+		h.db.Model(&models.User{}).Where("email = ? AND id != ?", req.Email, current.ID).Count(&count)
 		if count > 0 {
-			c.JSON(http.StatusConflict, gin.H{"error": "邮箱已被其他用户使用"})
+			utils.ErrorResponse(c, http.StatusConflict, "邮箱已被其他用户使用")
 			return
 		}
 		user.Email = req.Email
@@ -322,11 +345,21 @@ func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
 		user.FullName = req.FullName
 	}
 
+	// 更新语言偏好
+	if req.Locale != "" {
+		locale := i18n.Normalize(req.Locale)
+		if locale == "" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "不支持的语言")
+			return
+		}
+		user.Locale = locale
+	}
+
 	// 更新密码（如果提供）
 	if req.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+			utils.ErrorResponse(c, http.StatusInternalServerError, "密码加密失败")
 			return
 		}
 		user.Password = string(hashedPassword)
@@ -334,19 +367,24 @@ func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
 
 	// 保存更新
 	if result := h.db.Save(&user); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新用户失败")
 		return
 	}
+	middleware.InvalidateUser(user.ID)
 
 	// 记录审计日志
 	auditLog := models.AuditLog{
-		UserID:      &user.ID,
-		Action:      "update_profile",
-		Description: "更新个人资料",
-		IP:          c.ClientIP(),
-		UserAgent:   c.GetHeader("User-Agent"),
+		UserID:       &user.ID,
+		Action:       "update_profile",
+		ResourceType: "user",
+		ResourceID:   user.ID,
+		Description:  "更新个人资料",
+		IP:           c.ClientIP(),
+		UserAgent:    c.GetHeader("User-Agent"),
+	}
+	if err := createAuditLog(c, h.db, &auditLog); err != nil {
+		log.Printf("记录审计日志失败: %v", err)
 	}
-	h.db.Create(&auditLog)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "个人资料更新成功",
@@ -0,0 +1,603 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/ipallow"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/runlabel"
+	"flowforge/pkg/utils"
+	"flowforge/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler Webhook处理器
+type WebhookHandler struct {
+	engine webhookRunner
+}
+
+// webhookRunner 投递触发运行使用的引擎方法，测试中替换为不执行运行的实现
+type webhookRunner interface {
+	RunPipelineWithOptions(pipelineID uint, triggerType string, triggerBy uint, opts pipeline.RunOptions) (*models.PipelineRun, error)
+	RecordSkippedRun(pipelineID uint, triggerType string, triggerBy uint, commitSHA, reason string) (*models.PipelineRun, error)
+}
+
+// NewWebhookHandler 创建Webhook处理器
+func NewWebhookHandler(engine *pipeline.Engine) *WebhookHandler {
+	return &WebhookHandler{
+		engine: engine,
+	}
+}
+
+// GetWebhooks 获取项目的Webhook列表
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var webhooks []models.Webhook
+	database.DB.Where("project_id = ?", project.ID).Find(&webhooks)
+
+	utils.SuccessResponse(c, webhooks)
+}
+
+// CreateWebhook 创建Webhook，密钥仅在创建时返回一次
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name          string `json:"name" binding:"required"`
+		Events        string `json:"events"`
+		PathFilters   string `json:"path_filters"`
+		RecordSkipped bool   `json:"record_skipped"`
+		LabelRules    string `json:"label_rules"`
+
+		AllowedIPs       string `json:"allowed_ips"`
+		RequireSignature bool   `json:"require_signature"`
+
+		// registry 项目的 Webhook 对接的镜像仓库类型：harbor 或 docker_registry
+		RegistryType string `json:"registry_type"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if !validateRegistryType(c, project, req.RegistryType, req.RequireSignature) {
+		return
+	}
+	if !validateLabelRules(c, project, req.LabelRules) {
+		return
+	}
+	allowedIPs, err := ipallow.Normalize(req.AllowedIPs)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hook := models.Webhook{
+		Name:      req.Name,
+		Secret:    utils.GenerateRandomString(32),
+		Events:    req.Events,
+		Status:    models.StatusActive,
+		ProjectID: project.ID,
+
+		PathFilters:   req.PathFilters,
+		RecordSkipped: req.RecordSkipped,
+		LabelRules:    req.LabelRules,
+
+		AllowedIPs:       allowedIPs,
+		RequireSignature: req.RequireSignature,
+		RegistryType:     req.RegistryType,
+	}
+	if hook.Events == "" {
+		hook.Events = "push"
+	}
+
+	if err := database.DB.Create(&hook).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建Webhook失败")
+		return
+	}
+
+	// 回填接收地址
+	hook.URL = fmt.Sprintf("/api/v1/webhooks/%d/receive", hook.ID)
+	database.DB.Model(&hook).Update("url", hook.URL)
+
+	utils.SuccessResponse(c, gin.H{
+		"webhook": hook,
+		"secret":  hook.Secret,
+	})
+}
+
+// DeleteWebhook 删除Webhook
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(hook).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除Webhook失败")
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// RotateSecret 轮换Webhook密钥，旧密钥在重叠期内仍然有效
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		OverlapSeconds *int `json:"overlap_seconds"`
+	}
+	c.ShouldBindJSON(&req)
+
+	overlap := config.GetConfig().Deploy.WebhookSecretOverlap
+	if req.OverlapSeconds != nil {
+		overlap = *req.OverlapSeconds
+	}
+	if overlap < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "重叠时间不能为负数")
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"secret":                     utils.GenerateRandomString(32),
+		"previous_secret":            "",
+		"previous_secret_expires_at": nil,
+		"secret_rotated_at":          &now,
+	}
+	var expiresAt *time.Time
+	if overlap > 0 {
+		t := now.Add(time.Duration(overlap) * time.Second)
+		expiresAt = &t
+		updates["previous_secret"] = hook.Secret
+		updates["previous_secret_expires_at"] = expiresAt
+	}
+
+	if err := database.DB.Model(hook).Updates(updates).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "轮换密钥失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"secret":                     updates["secret"],
+		"previous_secret_expires_at": expiresAt,
+		"secret_rotated_at":          now,
+	})
+}
+
+// UpdateAccess 修改Webhook的来源限制与签名要求，记录审计日志
+func (h *WebhookHandler) UpdateAccess(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req models.WebhookAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	if hook.RegistryType != "" && req.RequireSignature {
+		utils.ErrorResponse(c, http.StatusBadRequest, errRegistrySignature.Error())
+		return
+	}
+	allowedIPs, err := ipallow.Normalize(req.AllowedIPs)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before := webhookAccessText(hook)
+	if err := database.DB.Model(hook).Updates(map[string]interface{}{
+		"allowed_ips":       allowedIPs,
+		"require_signature": req.RequireSignature,
+	}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存Webhook来源限制失败")
+		return
+	}
+	hook.AllowedIPs, hook.RequireSignature = allowedIPs, req.RequireSignature
+	recordAuditChange(c, "update_webhook_access", "webhook", hook.ID,
+		fmt.Sprintf("修改Webhook %s 的来源限制", hook.Name), before, webhookAccessText(hook))
+
+	utils.SuccessResponse(c, hook)
+}
+
+// errRegistrySignature 镜像仓库不对 Webhook 请求签名，只能校验令牌
+var errRegistrySignature = errors.New("镜像仓库 Webhook 不支持签名，使用请求头中的令牌校验")
+
+// validateRegistryType 校验 Webhook 的镜像仓库类型与项目的源码来源匹配：registry 项目只接收镜像仓库的推送事件，
+// 其他项目只接收 git 托管平台的事件
+func validateRegistryType(c *gin.Context, project *models.Project, registryType string, requireSignature bool) bool {
+	switch {
+	case !project.UsesRegistrySource() && registryType != "":
+		utils.ErrorResponse(c, http.StatusBadRequest, "只有 registry 项目可以接收镜像仓库的 Webhook")
+		return false
+	case project.UsesRegistrySource() && registryType != models.RegistryTypeHarbor && registryType != models.RegistryTypeDocker:
+		utils.ErrorResponse(c, http.StatusBadRequest, "registry 项目的 Webhook 需要指定镜像仓库类型（harbor 或 docker_registry）")
+		return false
+	case registryType != "" && requireSignature:
+		utils.ErrorResponse(c, http.StatusBadRequest, errRegistrySignature.Error())
+		return false
+	}
+	return true
+}
+
+// webhookAccessText Webhook来源限制的文本形式，用于审计日志中的变更对比
+func webhookAccessText(hook *models.Webhook) string {
+	return fmt.Sprintf("allowed_ips=%s\nrequire_signature=%t", hook.AllowedIPs, hook.RequireSignature)
+}
+
+// GetDeliveries 获取Webhook投递记录
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	hook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	database.DB.Where("webhook_id = ?", hook.ID).Order("id DESC").Limit(100).Find(&deliveries)
+
+	utils.SuccessResponse(c, deliveries)
+}
+
+// Receive 接收托管平台推送的Webhook事件
+func (h *WebhookHandler) Receive(c *gin.Context) {
+	var hook models.Webhook
+	if err := database.DB.Preload("Project").First(&hook, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Webhook不存在")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 5<<20))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "读取请求体失败")
+		return
+	}
+
+	delivery := models.WebhookDelivery{
+		Event:      webhook.EventType(c.Request.Header),
+		DeliveryID: webhook.DeliveryID(c.Request.Header),
+		RemoteIP:   c.ClientIP(),
+		WebhookID:  hook.ID,
+	}
+	if hook.RegistryType != "" {
+		delivery.Event = webhook.RegistryEventType(hook.RegistryType, body)
+	}
+
+	// 来源地址按信任代理配置解析，不信任的来源伪造的 X-Forwarded-For 不会被采信
+	if !ipallow.Allowed(c.Request.Context(), hook.AllowedIPs, delivery.RemoteIP) {
+		delivery.Status = models.DeliveryStatusRejected
+		delivery.Message = fmt.Sprintf("来源地址 %s 不在允许范围内", delivery.RemoteIP)
+		database.DB.Create(&delivery)
+		log.Printf("Webhook %d 拒绝来自 %s 的投递：不在允许的来源范围内", hook.ID, delivery.RemoteIP)
+		utils.ErrorResponse(c, http.StatusForbidden, "Webhook来源地址不在允许范围内")
+		return
+	}
+
+	matched, err := webhook.VerifyRequest(&hook, c.Request.Header, body, time.Now())
+	if err != nil {
+		delivery.Status = models.DeliveryStatusRejected
+		delivery.Message = err.Error()
+		database.DB.Create(&delivery)
+		status := http.StatusUnauthorized
+		if errors.Is(err, webhook.ErrSignatureRequired) {
+			status = http.StatusForbidden
+		}
+		utils.ErrorResponse(c, status, err.Error())
+		return
+	}
+	delivery.MatchedSecret = matched
+
+	if firstFailure(webhookEventChecks(&hook, delivery.Event)) != nil {
+		delivery.Status = models.DeliveryStatusAccepted
+		delivery.Message = "事件已忽略"
+		database.DB.Create(&delivery)
+		utils.SuccessResponse(c, gin.H{"triggered": 0})
+		return
+	}
+	if hook.RegistryType != "" {
+		h.receiveRegistryPush(c, &hook, &delivery, body)
+		return
+	}
+
+	// 去重窗口内的相同投递（平台重投、代理重复转发）不再重复触发运行
+	fingerprint, duplicate := claimFingerprint(hook.ID, webhook.Fingerprint(c.Request.Header, body))
+	if duplicate {
+		delivery.Status = models.DeliveryStatusDuplicate
+		delivery.Message = duplicateMessage(fingerprint.RunIDs)
+		database.DB.Create(&delivery)
+		utils.SuccessResponse(c, gin.H{
+			"triggered": 0,
+			"duplicate": true,
+			"message":   delivery.Message,
+		})
+		return
+	}
+
+	var pipelines []models.Pipeline
+	database.DB.Where("project_id = ?", hook.ProjectID).Order("id").Find(&pipelines)
+
+	// 以下判断与触发模拟（SimulateTrigger）使用相同的检查
+	ev := parseTriggerEvent(delivery.Event, body)
+	commit := ev.Commit
+	paths := pathFilterCheck(c.Request.Context(), &hook, ev)
+	passed, skipReason := paths.Passed, ""
+	if !passed {
+		skipReason = paths.Detail
+	}
+
+	// 标签规则在创建时已校验，项目允许的标签之后变更时去掉不再允许的标签
+	labels, err := runlabel.Check(&hook.Project, webhook.MatchRefLabels(&hook, ev.Ref))
+	if err != nil {
+		log.Printf("Webhook %d 的标签规则无效，触发的运行不带标签: %v", hook.ID, err)
+		labels = nil
+	}
+
+	var runIDs, skippedIDs []uint
+	refused := 0
+	for _, p := range pipelines {
+		if firstFailure(webhookPipelineChecks(&p)) != nil {
+			continue
+		}
+		// 仓库配置来源会执行PR中的配置文件，默认拒绝来自fork的PR
+		if !forkCheck(&p, ev).Passed {
+			log.Printf("Webhook %d 拒绝为fork的PR运行流水线 %d", hook.ID, p.ID)
+			refused++
+			skippedIDs = h.recordSkipped(&hook, p.ID, commit, "fork pull request refused", skippedIDs)
+			continue
+		}
+		if !passed {
+			skippedIDs = h.recordSkipped(&hook, p.ID, commit, skipReason, skippedIDs)
+			continue
+		}
+
+		run, err := h.engine.RunPipelineWithOptions(p.ID, models.TriggerWebhook, hook.Project.UserID, pipeline.RunOptions{
+			CommitSHA: commit,
+			Labels:    labels,
+		})
+		if err != nil {
+			log.Printf("Webhook %d 触发流水线 %d 失败: %v", hook.ID, p.ID, err)
+			continue
+		}
+		runIDs = append(runIDs, run.ID)
+	}
+
+	if fingerprint != nil && len(runIDs) > 0 {
+		database.DB.Model(fingerprint).Update("run_ids", joinRunIDs(runIDs))
+	}
+
+	now := time.Now()
+	database.DB.Model(&hook).Update("last_trigger", &now)
+
+	delivery.Status = models.DeliveryStatusAccepted
+	delivery.Message = fmt.Sprintf("触发 %d 条流水线", len(runIDs))
+	if refused > 0 {
+		delivery.Message += fmt.Sprintf("，拒绝 %d 条（来自fork的PR）", refused)
+	}
+	if !passed {
+		delivery.Message += "，" + skipReason
+	}
+	database.DB.Create(&delivery)
+
+	utils.SuccessResponse(c, gin.H{
+		"triggered":       len(runIDs),
+		"refused":         refused,
+		"run_ids":         runIDs,
+		"skipped_run_ids": skippedIDs,
+	})
+}
+
+// receiveRegistryPush 处理镜像仓库的推送事件：以推送的镜像触发项目的 Webhook 流水线，只部署不构建。
+// 一次投递推送了多个镜像时只部署最后一个属于项目镜像仓库的镜像
+func (h *WebhookHandler) receiveRegistryPush(c *gin.Context, hook *models.Webhook, delivery *models.WebhookDelivery, body []byte) {
+	pushes, err := webhook.RegistryPushes(hook.RegistryType, body)
+	if err != nil {
+		delivery.Status = models.DeliveryStatusRejected
+		delivery.Message = err.Error()
+		database.DB.Create(delivery)
+		utils.ErrorResponse(c, http.StatusBadRequest, webhook.ErrRegistryPayload.Error())
+		return
+	}
+
+	var push *webhook.RegistryPush
+	want := strings.TrimSuffix(hook.Project.ImageRepository, "/")
+	for i := range pushes {
+		if want == "" || pushes[i].Repository == want {
+			push = &pushes[i]
+		}
+	}
+	if push == nil {
+		delivery.Status = models.DeliveryStatusAccepted
+		delivery.Message = "没有推送项目镜像仓库中的镜像，事件已忽略"
+		database.DB.Create(delivery)
+		utils.SuccessResponse(c, gin.H{"triggered": 0})
+		return
+	}
+
+	fingerprint, duplicate := claimFingerprint(hook.ID, push.Fingerprint())
+	if duplicate {
+		delivery.Status = models.DeliveryStatusDuplicate
+		delivery.Message = duplicateMessage(fingerprint.RunIDs)
+		database.DB.Create(delivery)
+		utils.SuccessResponse(c, gin.H{
+			"triggered": 0,
+			"duplicate": true,
+			"message":   delivery.Message,
+		})
+		return
+	}
+
+	var pipelines []models.Pipeline
+	database.DB.Where(&models.Pipeline{
+		ProjectID: hook.ProjectID,
+		Trigger:   models.TriggerWebhook,
+		Status:    models.PipelineStatusActive,
+	}).Find(&pipelines)
+
+	var runIDs []uint
+	for _, p := range pipelines {
+		run, err := h.engine.RunPipelineWithOptions(p.ID, models.TriggerWebhook, hook.Project.UserID, pipeline.RunOptions{
+			Artifact: &models.ArtifactReference{Image: push.Reference()},
+		})
+		if err != nil {
+			log.Printf("Webhook %d 以镜像 %s 触发流水线 %d 失败: %v", hook.ID, push.Reference(), p.ID, err)
+			continue
+		}
+		runIDs = append(runIDs, run.ID)
+	}
+
+	if fingerprint != nil && len(runIDs) > 0 {
+		database.DB.Model(fingerprint).Update("run_ids", joinRunIDs(runIDs))
+	}
+
+	now := time.Now()
+	database.DB.Model(hook).Update("last_trigger", &now)
+
+	delivery.Status = models.DeliveryStatusAccepted
+	delivery.Message = fmt.Sprintf("镜像 %s 触发 %d 条流水线", push.Reference(), len(runIDs))
+	database.DB.Create(delivery)
+
+	utils.SuccessResponse(c, gin.H{
+		"triggered": len(runIDs),
+		"run_ids":   runIDs,
+		"image":     push.Reference(),
+	})
+}
+
+// validateLabelRules 校验 Webhook 的运行标签规则，规则中的标签需要是项目允许的标签
+func validateLabelRules(c *gin.Context, project *models.Project, rules string) bool {
+	parsed, err := webhook.ParseLabelRules(rules)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "标签规则无效: "+err.Error())
+		return false
+	}
+	labels := make([]string, 0, len(parsed))
+	for _, rule := range parsed {
+		labels = append(labels, rule.Label)
+	}
+	if _, err := runlabel.Check(project, labels); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "标签规则无效: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// recordSkipped Webhook 开启了记录跳过时，为被过滤的流水线创建 skipped 运行
+func (h *WebhookHandler) recordSkipped(hook *models.Webhook, pipelineID uint, commit, reason string, runIDs []uint) []uint {
+	if !hook.RecordSkipped {
+		return runIDs
+	}
+	run, err := h.engine.RecordSkippedRun(pipelineID, models.TriggerWebhook, hook.Project.UserID, commit, reason)
+	if err != nil {
+		log.Printf("Webhook %d 记录流水线 %d 的跳过运行失败: %v", hook.ID, pipelineID, err)
+		return runIDs
+	}
+	return append(runIDs, run.ID)
+}
+
+// claimFingerprint 登记投递指纹，指纹在去重窗口内已被登记时返回已有记录与 true；
+// 指纹为空或去重被关闭时返回 nil
+func claimFingerprint(webhookID uint, fingerprint string) (*models.WebhookFingerprint, bool) {
+	window := config.GetConfig().Deploy.WebhookDedupWindow
+	if fingerprint == "" || window < 0 {
+		return nil, false
+	}
+
+	now := time.Now()
+	// 已过期但尚未被定时清理的指纹不再生效
+	database.DB.Where("webhook_id = ? AND fingerprint = ? AND expires_at < ?", webhookID, fingerprint, now).
+		Delete(&models.WebhookFingerprint{})
+
+	record := models.WebhookFingerprint{
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(time.Duration(window) * time.Second),
+		WebhookID:   webhookID,
+	}
+	// 依赖唯一索引保证并发投递（包括多实例部署）只有一个登记成功
+	createErr := database.DB.Create(&record).Error
+	if createErr == nil {
+		return &record, false
+	}
+
+	var existing models.WebhookFingerprint
+	if err := database.DB.Where("webhook_id = ? AND fingerprint = ?", webhookID, fingerprint).First(&existing).Error; err != nil {
+		log.Printf("登记Webhook %d 投递指纹失败: %v", webhookID, createErr)
+		return nil, false
+	}
+	return &existing, true
+}
+
+// duplicateMessage 重复投递的提示信息
+func duplicateMessage(runIDs string) string {
+	if runIDs == "" {
+		return "重复投递，首次投递未触发运行或仍在处理"
+	}
+	return fmt.Sprintf("重复投递，运行 #%s 已触发", strings.ReplaceAll(runIDs, ",", ", #"))
+}
+
+// joinRunIDs 将运行ID拼接为逗号分隔的字符串
+func joinRunIDs(runIDs []uint) string {
+	parts := make([]string, len(runIDs))
+	for i, id := range runIDs {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// loadProject 加载项目并校验当前用户为项目所有者或管理员
+func (h *WebhookHandler) loadProject(c *gin.Context) (*models.Project, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var project models.Project
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Where("user_id = ?", current.ID)
+	}
+
+	if err := projectLookup(query, c.Param("id")).First(&project).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return nil, false
+	}
+
+	return &project, true
+}
+
+// loadWebhook 加载属于当前项目的Webhook
+func (h *WebhookHandler) loadWebhook(c *gin.Context) (*models.Webhook, bool) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return nil, false
+	}
+
+	var hook models.Webhook
+	if err := database.DB.Where("project_id = ?", project.ID).First(&hook, c.Param("webhook_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Webhook不存在")
+		return nil, false
+	}
+
+	return &hook, true
+}
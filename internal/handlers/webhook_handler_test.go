@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sign 以 secret 计算请求体的 HMAC 签名
+func sign(newHash func() hash.Hash, secret, body string) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestWebhookSourceRestrictions 来源地址按信任代理配置解析后与来源限制比较：不信任的来源伪造 X-Forwarded-For 被拒绝，
+// 投递记录保存实际的来源地址；要求签名时明文令牌与 SHA1 签名返回 403，签名错误返回 401
+func TestWebhookSourceRestrictions(t *testing.T) {
+	pipeline := setupAccessTest(t)
+	hook := &models.Webhook{Name: "github", URL: "/webhooks", Secret: "s3cret", ProjectID: pipeline.ProjectID,
+		AllowedIPs: "203.0.113.0/24", RequireSignature: true}
+	if err := database.DB.Create(hook).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	r.POST("/api/v1/webhooks/:id", NewWebhookHandler(nil).Receive)
+
+	const body = `{"ref": "refs/heads/main"}`
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		status  int
+		ip      string
+		message string
+	}{
+		{"伪造转发地址", "198.51.100.7", map[string]string{"X-Forwarded-For": "203.0.113.5", "X-Hub-Signature-256": "sha256=" + sign(sha256.New, "s3cret", body)},
+			http.StatusForbidden, "198.51.100.7", "不在允许范围内"},
+		{"代理转发的其他地址", "10.0.0.1", map[string]string{"X-Forwarded-For": "198.51.100.7"}, http.StatusForbidden, "198.51.100.7", "不在允许范围内"},
+		{"明文令牌", "10.0.0.1", map[string]string{"X-Forwarded-For": "203.0.113.5", "X-Gitlab-Token": "s3cret"},
+			http.StatusForbidden, "203.0.113.5", webhook.ErrSignatureRequired.Error()},
+		{"SHA1 签名", "203.0.113.5", map[string]string{"X-Hub-Signature": "sha1=" + sign(sha1.New, "s3cret", body)},
+			http.StatusForbidden, "203.0.113.5", webhook.ErrSignatureRequired.Error()},
+		{"签名错误", "203.0.113.5", map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, "wrong", body)},
+			http.StatusUnauthorized, "203.0.113.5", webhook.ErrSignatureMismatch.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/webhooks/%d", hook.ID), strings.NewReader(body))
+			req.RemoteAddr = tt.remote + ":40000"
+			req.Header.Set("X-GitHub-Event", "push")
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("返回 %d，应为 %d: %s", w.Code, tt.status, w.Body.String())
+			}
+
+			var delivery models.WebhookDelivery
+			if err := database.DB.Where("webhook_id = ?", hook.ID).Order("id DESC").First(&delivery).Error; err != nil {
+				t.Fatal(err)
+			}
+			if delivery.Status != models.DeliveryStatusRejected || delivery.RemoteIP != tt.ip || !strings.Contains(delivery.Message, tt.message) {
+				t.Errorf("投递记录为 %s（来源 %s）: %s，应为来自 %s 的拒绝记录并包含 %q", delivery.Status, delivery.RemoteIP, delivery.Message, tt.ip, tt.message)
+			}
+		})
+	}
+}
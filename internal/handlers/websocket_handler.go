@@ -3,64 +3,124 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"flowforge/internal/middleware"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
 	"flowforge/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+// defaultStreamIdle 未配置空闲超时时 WebSocket 连接的心跳超时
+const defaultStreamIdle = 60 * time.Second
+
 // WebSocketHandler WebSocket处理器
 type WebSocketHandler struct {
 	upgrader websocket.Upgrader
+	config   *config.WebSocketConfig
+	tickets  *middleware.WebSocketTickets
+
+	// 当前的连接数，按用户与全部用户限制
+	mu      sync.Mutex
+	total   int
+	perUser map[uint]int
 }
 
 // NewWebSocketHandler 创建WebSocket处理器
-func NewWebSocketHandler() *WebSocketHandler {
-	return &WebSocketHandler{
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
-		},
+func NewWebSocketHandler(cfg *config.WebSocketConfig, tickets *middleware.WebSocketTickets) *WebSocketHandler {
+	h := &WebSocketHandler{
+		config:  cfg,
+		tickets: tickets,
+		perUser: make(map[uint]int),
+	}
+	h.upgrader = websocket.Upgrader{
+		CheckOrigin:  h.checkOrigin,
+		Subprotocols: []string{middleware.WebSocketProtocol},
+	}
+	return h
+}
+
+// IssueTicket 签发一次性连接票据，浏览器以 ?ticket= 连接 WebSocket 路由，票据使用一次或过期后失效
+func (h *WebSocketHandler) IssueTicket(c *gin.Context) {
+	current, ok := currentUser(c)
+	if !ok {
+		return
 	}
+
+	ticket, expiresAt := h.tickets.Issue(*current)
+	utils.SuccessResponse(c, gin.H{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	})
 }
 
 // HandleDeploymentLogs 处理部署日志WebSocket连接
 func (h *WebSocketHandler) HandleDeploymentLogs(c *gin.Context) {
 	deploymentID := c.Param("deployment_id")
 	if deploymentID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "缺少部署ID", "")
+		utils.ErrorResponse(c, http.StatusBadRequest, "缺少部署ID")
 		return
 	}
-
-	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		log.Printf("WebSocket升级失败: %v", err)
+	current, ok := currentUser(c)
+	if !ok {
 		return
 	}
-	defer conn.Close()
 
-	for {
-		err := conn.WriteMessage(websocket.TextMessage, []byte("部署日志实时推送"))
-		if err != nil {
-			break
-		}
-		
-		_, _, err = conn.ReadMessage()
-		if err != nil {
-			break
-		}
+	var deployment models.Deployment
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN projects ON deployments.project_id = projects.id").
+			Where(projectAccess(current.ID, actionView))
+	}
+	if err := query.First(&deployment, deploymentID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "部署记录不存在")
+		return
 	}
+
+	h.upgrade(c, current.ID, "部署日志实时推送")
 }
 
 // HandlePipelineLogs 处理流水线日志WebSocket连接
 func (h *WebSocketHandler) HandlePipelineLogs(c *gin.Context) {
 	runID := c.Param("run_id")
 	if runID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "缺少运行ID", "")
+		utils.ErrorResponse(c, http.StatusBadRequest, "缺少运行ID")
+		return
+	}
+	current, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var run models.PipelineRun
+	query := database.DB
+	if !current.IsAdmin() {
+		query = query.Joins("JOIN pipelines ON pipeline_runs.pipeline_id = pipelines.id").
+			Joins("JOIN projects ON pipelines.project_id = projects.id").
+			Where(projectAccess(current.ID, actionView))
+	}
+	if err := query.First(&run, runID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return
+	}
+
+	h.upgrade(c, current.ID, "流水线日志实时推送")
+}
+
+// upgrade 在连接数限制内升级连接并推送消息，连接断开后释放名额
+func (h *WebSocketHandler) upgrade(c *gin.Context, userID uint, message string) {
+	if status, msg := h.acquire(userID); status != 0 {
+		utils.ErrorResponse(c, status, msg)
 		return
 	}
+	defer h.release(userID)
 
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -69,15 +129,106 @@ func (h *WebSocketHandler) HandlePipelineLogs(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	for {
-		err := conn.WriteMessage(websocket.TextMessage, []byte("流水线日志实时推送"))
-		if err != nil {
-			break
+	if h.config.MaxMessageKB > 0 {
+		conn.SetReadLimit(int64(h.config.MaxMessageKB) << 10)
+	}
+	h.serve(c, conn, message)
+}
+
+// acquire 占用一个连接名额，超过限制时返回响应状态与错误信息
+func (h *WebSocketHandler) acquire(userID uint) (int, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.config.MaxConnections > 0 && h.total >= h.config.MaxConnections {
+		return http.StatusServiceUnavailable, "WebSocket 连接数已达服务器上限"
+	}
+	if h.config.MaxPerUser > 0 && h.perUser[userID] >= h.config.MaxPerUser {
+		return http.StatusTooManyRequests, "WebSocket 连接数已达上限"
+	}
+	h.total++
+	h.perUser[userID]++
+	return 0, ""
+}
+
+// release 释放连接名额
+func (h *WebSocketHandler) release(userID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.total--
+	h.perUser[userID]--
+	if h.perUser[userID] <= 0 {
+		delete(h.perUser, userID)
+	}
+}
+
+// checkOrigin 校验浏览器页面的来源：配置了 allowed_origins 时只允许其中的来源，否则只允许同源；
+// 没有 Origin 请求头的非浏览器客户端不受限制
+func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(h.config.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range h.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
 		}
-		
-		_, _, err = conn.ReadMessage()
-		if err != nil {
-			break
+	}
+	return false
+}
+
+// serve 推送消息直到连接断开。定期发送 ping，超过空闲时间没有收到消息或 pong 时断开；
+// 服务器关闭时发送“服务器重启”的关闭帧，客户端可以稍后重连
+func (h *WebSocketHandler) serve(c *gin.Context, conn *websocket.Conn, message string) {
+	idle := middleware.StreamIdleTimeout(c)
+	if idle <= 0 {
+		idle = defaultStreamIdle
+	}
+	extend := func() error {
+		return conn.SetReadDeadline(time.Now().Add(idle))
+	}
+	extend()
+	conn.SetPongHandler(func(string) error { return extend() })
+
+	// 读写在同一个协程中，ping 与关闭帧通过可以并发调用的 WriteControl 发送
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			conn.SetWriteDeadline(time.Now().Add(idle))
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				return
+			}
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			extend()
+		}
+	}()
+
+	ticker := time.NewTicker(idle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(idle/2)); err != nil {
+				return
+			}
+		case <-middleware.StreamDraining(c):
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseServiceRestart, "服务器重启"), time.Now().Add(time.Second))
+			select {
+			case <-closed:
+			case <-time.After(time.Second):
+			}
+			return
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/internal/authctx"
+	"flowforge/internal/middleware"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// wsServer 按服务器的方式注册连接票据与流水线日志路由；签发票据的接口以 X-Test-User 指定的用户认证
+type wsServer struct {
+	url   string
+	runID uint
+}
+
+func setupWebSocketTest(t *testing.T, cfg config.WebSocketConfig, ttl time.Duration) *wsServer {
+	t.Helper()
+	pipeline := setupAccessTest(t)
+	run := &models.PipelineRun{PipelineID: pipeline.ID, RunNumber: 1, Status: models.RunStatusRunning}
+	if err := database.DB.Create(run).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	users := map[string]authctx.User{"viewer": viewerUser, "outsider": outsiderUser}
+	tickets := middleware.NewWebSocketTickets(ttl)
+	h := NewWebSocketHandler(&cfg, tickets)
+	r := gin.New()
+	r.POST("/api/v1/ws/ticket", func(c *gin.Context) {
+		authctx.SetCurrentUser(c, users[c.GetHeader("X-Test-User")])
+	}, h.IssueTicket)
+	r.GET("/api/v1/ws/pipeline/:run_id", middleware.WebSocketAuth(&config.Config{}, tickets), h.HandlePipelineLogs)
+
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	return &wsServer{url: ts.URL, runID: run.ID}
+}
+
+// issue 以 user 身份签发连接票据
+func (s *wsServer) issue(t *testing.T, user string) (string, time.Time) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, s.url+"/api/v1/ws/ticket", nil)
+	req.Header.Set("X-Test-User", user)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data struct {
+			Ticket    string    `json:"ticket"`
+			ExpiresAt time.Time `json:"expires_at"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("签发票据返回 %d: %v", resp.StatusCode, err)
+	}
+	return body.Data.Ticket, body.Data.ExpiresAt
+}
+
+// dial 以票据连接运行日志，origin 为空时不发送 Origin 请求头；连接失败时返回升级响应的状态码
+func (s *wsServer) dial(t *testing.T, ticket, origin string) (*websocket.Conn, int) {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	wsURL := fmt.Sprintf("ws%s/api/v1/ws/pipeline/%d?ticket=%s", strings.TrimPrefix(s.url, "http"), s.runID, url.QueryEscape(ticket))
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		if resp == nil {
+			t.Fatalf("连接失败: %v", err)
+		}
+		return nil, resp.StatusCode
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, resp.StatusCode
+}
+
+// TestWebSocketTicket 签发的票据带前缀与过期时间，兑换一次即可连接；同一票据再次使用、
+// 票据过期或不存在时在升级前返回 401
+func TestWebSocketTicket(t *testing.T) {
+	s := setupWebSocketTest(t, config.WebSocketConfig{}, time.Minute)
+
+	ticket, expiresAt := s.issue(t, "viewer")
+	if !strings.HasPrefix(ticket, middleware.WebSocketTicketPrefix) {
+		t.Errorf("票据 %q 应以 %s 开头", ticket, middleware.WebSocketTicketPrefix)
+	}
+	if until := time.Until(expiresAt); until <= 0 || until > time.Minute {
+		t.Errorf("票据在 %v 后过期，应在有效期 1 分钟内", until)
+	}
+
+	conn, status := s.dial(t, ticket, "")
+	if conn == nil {
+		t.Fatalf("使用票据连接返回 %d", status)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, message, err := conn.ReadMessage(); err != nil || string(message) != "流水线日志实时推送" {
+		t.Errorf("连接后收到 %q: %v", message, err)
+	}
+
+	if _, status := s.dial(t, ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("再次使用同一票据返回 %d，应为 401", status)
+	}
+	if _, status := s.dial(t, middleware.WebSocketTicketPrefix+"unknown", ""); status != http.StatusUnauthorized {
+		t.Errorf("不存在的票据返回 %d，应为 401", status)
+	}
+
+	// 票据属于签发时的用户，连接时按该用户校验运行的查看权限
+	outsider, _ := s.issue(t, "outsider")
+	if _, status := s.dial(t, outsider, ""); status != http.StatusNotFound {
+		t.Errorf("没有项目权限的用户连接返回 %d，应为 404", status)
+	}
+}
+
+// TestWebSocketTicketExpiry 过期的票据不能连接，也不能在过期后再次尝试
+func TestWebSocketTicketExpiry(t *testing.T) {
+	s := setupWebSocketTest(t, config.WebSocketConfig{}, 50*time.Millisecond)
+
+	ticket, _ := s.issue(t, "viewer")
+	time.Sleep(100 * time.Millisecond)
+	if _, status := s.dial(t, ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("过期的票据返回 %d，应为 401", status)
+	}
+
+	fresh, _ := s.issue(t, "viewer")
+	if conn, status := s.dial(t, fresh, ""); conn == nil {
+		t.Errorf("有效期内的票据连接返回 %d", status)
+	}
+}
+
+// TestWebSocketOrigin 配置了 allowed_origins 时只接受其中的页面来源，未配置时只接受同源；
+// 没有 Origin 请求头的非浏览器客户端不受限制
+func TestWebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		ok      bool
+	}{
+		{"允许的来源", []string{"https://ci.example.com/"}, "https://ci.example.com", true},
+		{"来源大小写不同", []string{"https://ci.example.com"}, "https://CI.example.com", true},
+		{"不在允许列表中", []string{"https://ci.example.com"}, "https://evil.example.com", false},
+		{"协议不同", []string{"https://ci.example.com"}, "http://ci.example.com", false},
+		{"不限制来源", []string{"*"}, "https://evil.example.com", true},
+		{"未配置时同源", nil, "same-origin", true},
+		{"未配置时其他来源", nil, "https://evil.example.com", false},
+		{"非浏览器客户端", []string{"https://ci.example.com"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := setupWebSocketTest(t, config.WebSocketConfig{AllowedOrigins: tt.allowed}, time.Minute)
+			origin := tt.origin
+			if origin == "same-origin" {
+				origin = s.url
+			}
+			ticket, _ := s.issue(t, "viewer")
+			conn, status := s.dial(t, ticket, origin)
+			if tt.ok && conn == nil {
+				t.Errorf("来源 %q 连接返回 %d，应允许", origin, status)
+			}
+			if !tt.ok && status != http.StatusForbidden {
+				t.Errorf("来源 %q 连接返回 %d，应为 403", origin, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkerHandler 独立执行器处理器
+type WorkerHandler struct {
+	engine *pipeline.Engine
+}
+
+// NewWorkerHandler 创建执行器处理器
+func NewWorkerHandler(engine *pipeline.Engine) *WorkerHandler {
+	return &WorkerHandler{
+		engine: engine,
+	}
+}
+
+// GetWorkers 查看已注册的执行器、心跳与名额，以及等待执行器领取的运行数
+func (h *WorkerHandler) GetWorkers(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	fleet, err := h.engine.Workers()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取执行器状态失败")
+		return
+	}
+
+	utils.SuccessResponse(c, fleet)
+}
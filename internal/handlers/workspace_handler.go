@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/utils"
+	"flowforge/pkg/workspace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHandler 已结束运行保留的工作区的只读浏览处理器，权限与运行日志相同
+type WorkspaceHandler struct{}
+
+// NewWorkspaceHandler 创建工作区浏览处理器
+func NewWorkspaceHandler() *WorkspaceHandler {
+	return &WorkspaceHandler{}
+}
+
+// ListDirectory 列出工作区目录，path 为相对工作区根目录的路径，条目按 page、page_size 分页
+func (h *WorkspaceHandler) ListDirectory(c *gin.Context) {
+	run, ok := h.loadRun(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "200"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > workspace.MaxPageSize {
+		pageSize = workspace.MaxPageSize
+	}
+
+	listing, err := workspace.List(run.WorkspacePath, c.Query("path"), page, pageSize)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, models.PaginationResponse{
+		Data:       listing,
+		Total:      int64(listing.Total),
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (listing.Total + pageSize - 1) / pageSize,
+	})
+}
+
+// GetFile 读取工作区中的单个文件：文本文件按项目的密钥与脱敏规则脱敏后以纯文本返回，
+// 其他文件按嗅探到的类型作为附件下载；超过配置的大小上限时返回 413
+func (h *WorkspaceHandler) GetFile(c *gin.Context) {
+	run, ok := h.loadRun(c)
+	if !ok {
+		return
+	}
+
+	maxBytes := int64(config.GetConfig().Deploy.WorkspaceFileMaxMB) << 20
+	file, info, err := workspace.Open(run.WorkspacePath, c.Query("path"), maxBytes)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "读取文件失败")
+		return
+	}
+	head = head[:n]
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+
+	contentType := http.DetectContentType(head)
+	if isText(contentType, head) {
+		rest, err := io.ReadAll(file)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "读取文件失败")
+			return
+		}
+		masked := redact.ForProject(run.Pipeline.ProjectID).Redact(string(head) + string(rest))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(masked))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+	c.Header("Content-Length", strconv.FormatInt(info.Size(), 10))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	c.Writer.Write(head)
+	io.Copy(c.Writer, file)
+}
+
+// loadRun 加载运行并校验权限：管理员或项目所有者。工作区未保留或已清理时返回 410
+func (h *WorkspaceHandler) loadRun(c *gin.Context) (*models.PipelineRun, bool) {
+	current, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline.Project").
+		Where("pipeline_id = ?", c.Param("id")).First(&run, c.Param("runId")).Error; err != nil ||
+		(!current.IsAdmin() && run.Pipeline.Project.UserID != current.ID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "流水线运行记录不存在")
+		return nil, false
+	}
+
+	if run.EndTime == nil {
+		utils.ErrorResponse(c, http.StatusConflict, "运行尚未结束")
+		return nil, false
+	}
+	expired := run.WorkspaceExpiresAt != nil && time.Now().After(*run.WorkspaceExpiresAt)
+	if run.WorkspacePath == "" || expired || !utils.IsDirExists(run.WorkspacePath) {
+		utils.ErrorResponse(c, http.StatusGone, "运行的工作区未保留或已清理")
+		return nil, false
+	}
+
+	return &run, true
+}
+
+// respondError 工作区浏览错误对应的响应
+func (h *WorkspaceHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, workspace.ErrUnsafePath):
+		utils.ErrorResponse(c, http.StatusBadRequest, "工作区路径无效")
+	case errors.Is(err, workspace.ErrNotFound), os.IsNotExist(err):
+		utils.ErrorResponse(c, http.StatusNotFound, "工作区中不存在该路径")
+	case errors.Is(err, workspace.ErrNotDirectory):
+		utils.ErrorResponse(c, http.StatusBadRequest, "路径不是目录")
+	case errors.Is(err, workspace.ErrIsDirectory):
+		utils.ErrorResponse(c, http.StatusBadRequest, "路径是目录")
+	case errors.Is(err, workspace.ErrTooLarge):
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("文件超过可查看的大小上限: %d MB", config.GetConfig().Deploy.WorkspaceFileMaxMB))
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "读取工作区失败")
+	}
+}
+
+// isText 按嗅探到的类型与内容判断是否为文本文件
+func isText(contentType string, head []byte) bool {
+	if strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "json") || strings.Contains(contentType, "xml") {
+		return true
+	}
+	if contentType != "application/octet-stream" {
+		return false
+	}
+	// 截断可能落在多字节字符中间，去掉末尾不完整的字符后再检查
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head) && !strings.ContainsRune(string(head), 0)
+}
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"flowforge/internal/authctx"
 	"flowforge/pkg/auth"
 	"flowforge/pkg/config"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,9 +20,7 @@ func Auth(cfg *config.Config) gin.HandlerFunc {
 		// 从请求头获取Token
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "未提供认证信息",
-			})
+			utils.ErrorResponse(c, http.StatusUnauthorized, "未提供认证信息")
 			c.Abort()
 			return
 		}
@@ -25,29 +28,56 @@ func Auth(cfg *config.Config) gin.HandlerFunc {
 		// 检查Token格式
 		parts := strings.SplitN(authHeader, " ", 2)
 		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "认证格式无效",
-			})
+			utils.ErrorResponse(c, http.StatusUnauthorized, "认证格式无效")
 			c.Abort()
 			return
 		}
 
-		// 验证Token
-		token := parts[1]
-		claims, err := auth.ValidateToken(token, cfg.JWT.Secret)
+		user, err := authenticateToken(c, cfg, parts[1])
+		if errors.Is(err, errAPITokenIPDenied) {
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+			c.Abort()
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "无效的认证令牌",
-			})
+			utils.ErrorResponse(c, http.StatusUnauthorized, "无效的认证令牌")
 			c.Abort()
 			return
 		}
-
-		// 将用户信息存储到上下文
-		c.Set("userId", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("roleId", claims.RoleID)
+		setUser(c, user)
 
 		c.Next()
 	}
+}
+
+// authenticateToken 校验JWT或API令牌并返回对应的用户
+func authenticateToken(c *gin.Context, cfg *config.Config, token string) (*authctx.User, error) {
+	// API令牌供自动化系统调用，以创建令牌的管理员身份操作
+	if auth.IsAPIToken(token) {
+		return authenticateAPIToken(c, token)
+	}
+
+	// 验证Token
+	claims, err := auth.ValidateToken(token, cfg.JWT.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.RoleUser
+	if claims.RoleID == 1 {
+		role = models.RoleAdmin
+	}
+	return &authctx.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+		Role:     role,
+	}, nil
+}
+
+// setUser 将用户信息存储到上下文，用户设置的语言偏好优先于请求头
+func setUser(c *gin.Context, user *authctx.User) {
+	authctx.SetCurrentUser(c, *user)
+	if locale := userLocale(c.Request.Context(), user.ID); locale != "" {
+		i18n.SetLocale(c, locale)
+	}
 }
\ No newline at end of file
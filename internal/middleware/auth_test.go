@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flowforge/internal/clocktest"
+	"flowforge/pkg/auth"
+	"flowforge/pkg/cache"
+	"flowforge/pkg/clock"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testJWTSecret = "test-secret"
+
+// setupAuthTest 内存数据库中的启用管理员与受 Auth 保护的路由，令牌有效期按可控时钟判断
+func setupAuthTest(t *testing.T) (*gin.Engine, *models.User, *clocktest.Fake) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{
+			Type:         "sqlite",
+			Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+			MaxIdleConns: 1,
+			MaxOpenConns: 1,
+			LogLevel:     "silent",
+		},
+		JWT: config.JWTConfig{Secret: testJWTSecret},
+	}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := clocktest.New(time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC))
+	auth.SetClock(fake)
+	t.Cleanup(func() { auth.SetClock(clock.Real) })
+
+	admin := &models.User{Username: "alice", Email: "alice@example.com", Password: "x", Role: models.RoleAdmin, Status: models.StatusActive}
+	if err := database.DB.Create(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 用户认证信息的缓存按用户ID，各测试的数据库相互独立
+	InvalidateUser(admin.ID)
+	t.Cleanup(func() { InvalidateUser(admin.ID) })
+
+	r := gin.New()
+	r.GET("/api/v1/me", Auth(cfg), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r, admin, fake
+}
+
+// createAPIToken 为 user 创建 ttl 后过期的API令牌
+func createAPIToken(t *testing.T, user *models.User, ttl time.Duration) (string, *models.APIToken) {
+	t.Helper()
+	plain, hash := auth.GenerateAPIToken()
+	expiresAt := auth.Now().Add(ttl)
+	token := &models.APIToken{Name: "ci", TokenHash: hash, Prefix: plain[:8], Scope: models.APITokenScopeAdmin, ExpiresAt: &expiresAt, UserID: user.ID}
+	if err := database.DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	return plain, token
+}
+
+func request(r *gin.Engine, token string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// TestTokenExpiredOrRevoked JWT 与API令牌过期后的下一个请求返回 401；API令牌撤销后立即失效，
+// 令牌本身不缓存，不受用户认证信息缓存时间的影响
+func TestTokenExpiredOrRevoked(t *testing.T) {
+	r, admin, fake := setupAuthTest(t)
+
+	jwt, err := auth.GenerateToken(admin.ID, admin.Username, 1, testJWTSecret, auth.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiToken, _ := createAPIToken(t, admin, 2*time.Hour)
+	revoked, record := createAPIToken(t, admin, 24*time.Hour)
+
+	for name, token := range map[string]string{"JWT": jwt, "API令牌": apiToken, "待撤销的API令牌": revoked} {
+		if code := request(r, token); code != http.StatusNoContent {
+			t.Fatalf("有效的%s返回 %d", name, code)
+		}
+	}
+
+	now := auth.Now()
+	database.DB.Model(record).Update("revoked_at", &now)
+	if code := request(r, revoked); code != http.StatusUnauthorized {
+		t.Errorf("撤销后的API令牌返回 %d，应为 401", code)
+	}
+
+	fake.Advance(time.Hour + time.Second)
+	if code := request(r, jwt); code != http.StatusUnauthorized {
+		t.Errorf("过期的 JWT 返回 %d，应为 401", code)
+	}
+	if code := request(r, apiToken); code != http.StatusNoContent {
+		t.Errorf("未过期的API令牌返回 %d", code)
+	}
+	fake.Advance(time.Hour)
+	if code := request(r, apiToken); code != http.StatusUnauthorized {
+		t.Errorf("过期的API令牌返回 %d，应为 401", code)
+	}
+}
+
+// TestRevocationWithinCacheTTL 管理员被降级或停用后，即使修改处遗漏了 InvalidateUser，
+// 其API令牌最迟在缓存时间后被拒绝；调用 InvalidateUser 时立即生效
+func TestRevocationWithinCacheTTL(t *testing.T) {
+	r, admin, _ := setupAuthTest(t)
+	cache.Init(&config.CacheConfig{TTL: 1})
+	t.Cleanup(func() { cache.Init(&config.CacheConfig{TTL: 30}) })
+	token, _ := createAPIToken(t, admin, 24*time.Hour)
+
+	if code := request(r, token); code != http.StatusNoContent {
+		t.Fatalf("有效的API令牌返回 %d", code)
+	}
+
+	database.DB.Model(admin).Update("role", models.RoleUser)
+	revokedAt := time.Now()
+	for request(r, token) != http.StatusUnauthorized {
+		if time.Since(revokedAt) > cache.TTL()+time.Second {
+			t.Fatalf("降级后超过缓存时间 %v 仍接受API令牌", cache.TTL())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	database.DB.Model(admin).Update("role", models.RoleAdmin)
+	InvalidateUser(admin.ID)
+	if code := request(r, token); code != http.StatusNoContent {
+		t.Errorf("恢复管理员并使缓存失效后返回 %d", code)
+	}
+
+	database.DB.Model(admin).Update("status", models.StatusInactive)
+	InvalidateUser(admin.ID)
+	if code := request(r, token); code != http.StatusUnauthorized {
+		t.Errorf("停用并使缓存失效后返回 %d，应立即为 401", code)
+	}
+}
+
+// TestAPITokenAllowedIPs 绑定了来源地址的API令牌只能从允许的地址使用，客户端地址按信任代理配置解析：
+// 不信任的来源伪造 X-Forwarded-For 或 X-Real-IP 无法绕过限制，拒绝时返回 403 并在审计日志中记录来源地址
+func TestAPITokenAllowedIPs(t *testing.T) {
+	r, admin, _ := setupAuthTest(t)
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	token, record := createAPIToken(t, admin, 24*time.Hour)
+	database.DB.Model(record).Update("allowed_ips", "203.0.113.0/24")
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    int
+		denied  string // 审计日志中记录的来源地址
+	}{
+		{"允许的地址直连", "203.0.113.5", nil, http.StatusNoContent, ""},
+		{"其他地址直连", "198.51.100.7", nil, http.StatusForbidden, "198.51.100.7"},
+		{"不信任的来源伪造 X-Forwarded-For", "198.51.100.7", map[string]string{"X-Forwarded-For": "203.0.113.5"}, http.StatusForbidden, "198.51.100.7"},
+		{"不信任的来源伪造 X-Real-IP", "198.51.100.7", map[string]string{"X-Real-IP": "203.0.113.5"}, http.StatusForbidden, "198.51.100.7"},
+		{"信任的代理转发允许的地址", "10.0.0.1", map[string]string{"X-Forwarded-For": "203.0.113.5"}, http.StatusNoContent, ""},
+		{"信任的代理转发其他地址", "10.0.0.1", map[string]string{"X-Forwarded-For": "198.51.100.7"}, http.StatusForbidden, "198.51.100.7"},
+		{"客户端在代理前伪造地址", "10.0.0.1", map[string]string{"X-Forwarded-For": "203.0.113.5, 198.51.100.7"}, http.StatusForbidden, "198.51.100.7"},
+		{"信任的代理未转发地址", "10.0.0.1", nil, http.StatusForbidden, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			req.RemoteAddr = tt.remote + ":40000"
+			req.Header.Set("Authorization", "Bearer "+token)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			var before int64
+			database.DB.Model(&models.AuditLog{}).Where("action = ?", "api_token_ip_denied").Count(&before)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("返回 %d，应为 %d", w.Code, tt.want)
+			}
+			var denied []models.AuditLog
+			database.DB.Where("action = ?", "api_token_ip_denied").Order("id").Offset(int(before)).Find(&denied)
+			switch {
+			case tt.denied == "" && len(denied) != 0:
+				t.Errorf("允许的请求记录了 %d 条拒绝日志", len(denied))
+			case tt.denied != "" && (len(denied) != 1 || denied[0].IP != tt.denied || denied[0].ResourceID != record.ID):
+				t.Errorf("拒绝日志为 %+v，应记录一条来源为 %s 的日志", denied, tt.denied)
+			}
+		})
+	}
+
+	var used models.APIToken
+	database.DB.First(&used, record.ID)
+	if used.LastUsedIP != "203.0.113.5" {
+		t.Errorf("令牌最近使用地址为 %q，被拒绝的请求不应更新", used.LastUsedIP)
+	}
+}
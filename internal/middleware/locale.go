@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Locale 语言协商中间件，按 Accept-Language 选择响应语言，默认 zh-CN
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		i18n.SetLocale(c, i18n.Negotiate(c.GetHeader("Accept-Language")))
+		c.Next()
+	}
+}
+
+// userLocale 获取用户设置的语言偏好，未设置时返回空字符串
+func userLocale(ctx context.Context, userID uint) string {
+	if database.DB == nil {
+		return ""
+	}
+
+	info, err := userAuthCache.Get(ctx, userID)
+	if err != nil {
+		return ""
+	}
+	return i18n.Normalize(info.Locale)
+}
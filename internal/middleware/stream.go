@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamDrainingKey = "streamDraining"
+	streamIdleKey     = "streamIdle"
+)
+
+// StreamTracker 跟踪流式连接（WebSocket、日志与文件下载等）。
+// 流式连接不受处理超时限制，连续 idle 时间没有读写时断开；服务器关闭时先等待连接自然结束，
+// 超过排空时间后通知处理函数关闭连接
+type StreamTracker struct {
+	idle      time.Duration
+	active    int64
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+// NewStreamTracker 创建流式连接跟踪器，idle 为 0 时不做空闲检查
+func NewStreamTracker(idle time.Duration) *StreamTracker {
+	return &StreamTracker{
+		idle:     idle,
+		draining: make(chan struct{}),
+	}
+}
+
+// Track 流式路由的中间件：登记连接，并把整个请求的读写超时换成按次延长的空闲超时
+func (t *StreamTracker) Track() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		atomic.AddInt64(&t.active, 1)
+		defer atomic.AddInt64(&t.active, -1)
+
+		c.Set(streamDrainingKey, (<-chan struct{})(t.draining))
+		c.Set(streamIdleKey, t.idle)
+
+		if t.idle > 0 {
+			controller := http.NewResponseController(c.Writer)
+			controller.SetWriteDeadline(time.Now().Add(t.idle))
+			c.Writer = &idleWriter{ResponseWriter: c.Writer, controller: controller, idle: t.idle}
+
+			// 没有请求体时连接由 net/http 在后台读取以发现客户端断开，不能设置读超时
+			if c.Request.Body != nil && c.Request.Body != http.NoBody {
+				controller.SetReadDeadline(time.Now().Add(t.idle))
+				c.Request.Body = &idleReader{ReadCloser: c.Request.Body, controller: controller, idle: t.idle}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// Active 当前的流式连接数
+func (t *StreamTracker) Active() int {
+	return int(atomic.LoadInt64(&t.active))
+}
+
+// Drain 等待流式连接结束；ctx 结束时仍未结束的连接收到关闭通知，再最多等待 grace。
+// 返回被通知关闭的连接数
+func (t *StreamTracker) Drain(ctx context.Context, grace time.Duration) int {
+	if t.wait(ctx) {
+		return 0
+	}
+
+	remaining := t.Active()
+	t.drainOnce.Do(func() { close(t.draining) })
+
+	graceCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	t.wait(graceCtx)
+	return remaining
+}
+
+// wait 等待全部流式连接结束，ctx 先结束时返回 false
+func (t *StreamTracker) wait(ctx context.Context) bool {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for t.Active() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// StreamDraining 服务器开始关闭流式连接时关闭的通道，处理函数收到后应通知客户端并结束；
+// 不是流式路由时返回 nil
+func StreamDraining(c *gin.Context) <-chan struct{} {
+	if value, ok := c.Get(streamDrainingKey); ok {
+		return value.(<-chan struct{})
+	}
+	return nil
+}
+
+// StreamIdleTimeout 流式连接的空闲超时，不是流式路由或未启用时返回 0
+func StreamIdleTimeout(c *gin.Context) time.Duration {
+	if value, ok := c.Get(streamIdleKey); ok {
+		return value.(time.Duration)
+	}
+	return 0
+}
+
+// idleWriter 每次写入前延长写超时
+type idleWriter struct {
+	gin.ResponseWriter
+	controller *http.ResponseController
+	idle       time.Duration
+}
+
+// Write 实现 http.ResponseWriter
+func (w *idleWriter) Write(data []byte) (int, error) {
+	w.controller.SetWriteDeadline(time.Now().Add(w.idle))
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString 实现 gin.ResponseWriter
+func (w *idleWriter) WriteString(s string) (int, error) {
+	w.controller.SetWriteDeadline(time.Now().Add(w.idle))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idleReader 每次读取请求体前延长读超时
+type idleReader struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	idle       time.Duration
+}
+
+// Read 实现 io.Reader
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.controller.SetReadDeadline(time.Now().Add(r.idle))
+	return r.ReadCloser.Read(p)
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout 普通接口的处理超时：处理函数在 timeout 内没有完成时返回 504，之后的输出被丢弃。
+// exempt 对流式路由返回 true，这些路由不受处理超时限制，由 StreamTracker 的空闲检查约束
+func Timeout(timeout time.Duration, exempt func(fullPath string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 || exempt(c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// 超时响应在处理函数的协程之外写出，提前取出语言
+		locale := i18n.FromContext(c)
+		original := c.Writer
+		writer := newTimeoutWriter(original)
+		c.Writer = writer
+
+		done := make(chan struct{})
+		var panicked interface{}
+		go func() {
+			defer close(done)
+			defer func() { panicked = recover() }()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+			writer.commit()
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				writer.timeout(locale)
+			}
+			// 等待处理函数结束后再归还 gin.Context，处理函数可以通过请求的 ctx 得知已超时
+			<-done
+		}
+		c.Writer = original
+
+		if panicked != nil {
+			panic(panicked)
+		}
+	}
+}
+
+// timeoutWriter 缓冲处理函数的响应，处理完成后一次写出；超时后写入返回 http.ErrHandlerTimeout
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{ResponseWriter: w, header: w.Header().Clone()}
+}
+
+// Header 实现 http.ResponseWriter
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader 实现 http.ResponseWriter
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 && !w.timedOut {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow 实现 gin.ResponseWriter
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Write 实现 http.ResponseWriter
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+// WriteString 实现 gin.ResponseWriter
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Status 实现 gin.ResponseWriter
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size 实现 gin.ResponseWriter
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+// Written 实现 gin.ResponseWriter
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status != 0
+}
+
+// Flush 响应在处理完成后才写出，缓冲期间忽略
+func (w *timeoutWriter) Flush() {}
+
+// Hijack 缓冲的响应不能接管连接，需要接管连接的路由应当注册为流式路由
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("受处理超时限制的路由不支持接管连接")
+}
+
+// commit 把缓冲的响应写到原始连接上
+func (w *timeoutWriter) commit() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dst := w.ResponseWriter.Header()
+	for key := range dst {
+		delete(dst, key)
+	}
+	for key, values := range w.header {
+		dst[key] = values
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// timeout 丢弃缓冲的响应，写出 504
+func (w *timeoutWriter) timeout(locale string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.timedOut = true
+	body, _ := json.Marshal(utils.ErrorBody(locale, "请求处理超时"))
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handlerTimeout 测试中普通接口的处理超时
+const handlerTimeout = 100 * time.Millisecond
+
+// newStreamServer 按服务器的方式注册路由：普通接口受处理超时限制，/stream 开头的为流式路由，
+// 由 tracker 登记并按空闲时间断开；与服务器一样不设置 WriteTimeout
+func newStreamServer(t *testing.T, tracker *StreamTracker, routes func(r *gin.Engine, stream func(path string, handler gin.HandlerFunc))) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	streamRoutes := map[string]bool{}
+	r.Use(Timeout(handlerTimeout, func(fullPath string) bool { return streamRoutes[fullPath] }))
+	routes(r, func(path string, handler gin.HandlerFunc) {
+		streamRoutes[path] = true
+		r.GET(path, tracker.Track(), handler)
+	})
+
+	ts := httptest.NewUnstartedServer(r)
+	ts.Config.ReadHeaderTimeout = time.Second
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestTimeoutSlowHandler 普通接口超过处理超时返回 504 与标准错误格式，处理函数通过请求的 ctx 得知已超时，
+// 之后的写入被丢弃；按时完成的接口原样返回状态码、响应头与响应体
+func TestTimeoutSlowHandler(t *testing.T) {
+	cancelled := make(chan error, 1)
+	ts := newStreamServer(t, NewStreamTracker(0), func(r *gin.Engine, _ func(string, gin.HandlerFunc)) {
+		r.GET("/slow", func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			cancelled <- c.Request.Context().Err()
+			c.JSON(http.StatusOK, gin.H{"data": "late"})
+		})
+		r.GET("/fast", func(c *gin.Context) {
+			c.Header("X-Result", "fast")
+			c.JSON(http.StatusCreated, gin.H{"data": "ok"})
+		})
+	})
+
+	started := time.Now()
+	resp, err := http.Get(ts.URL + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusGatewayTimeout || body["code"] != "request_timeout" || body["error"] == "" {
+		t.Fatalf("慢接口应返回 504 与 request_timeout 错误，实际为 %d %v", resp.StatusCode, body)
+	}
+	if elapsed := time.Since(started); elapsed > handlerTimeout+time.Second {
+		t.Errorf("超时响应用了 %v，应在处理超时 %v 后立即返回", elapsed, handlerTimeout)
+	}
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("处理函数的 ctx 应以 DeadlineExceeded 结束，实际为 %v", err)
+	}
+
+	resp, err = http.Get(ts.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("X-Result") != "fast" || !strings.Contains(string(data), `"ok"`) {
+		t.Errorf("按时完成的接口应原样返回，实际为 %d %v %s", resp.StatusCode, resp.Header, data)
+	}
+}
+
+// TestTimeoutExemptsStreamRoutes 流式路由持续输出时不受处理超时限制，时长远超处理超时与空闲超时
+func TestTimeoutExemptsStreamRoutes(t *testing.T) {
+	const events = 10
+	tracker := NewStreamTracker(handlerTimeout)
+	ts := newStreamServer(t, tracker, func(_ *gin.Engine, stream func(string, gin.HandlerFunc)) {
+		stream("/stream/events", func(c *gin.Context) {
+			c.Header("Content-Type", "text/event-stream")
+			for i := 1; i <= events; i++ {
+				fmt.Fprintf(c.Writer, "data: %d\n\n", i)
+				c.Writer.Flush()
+				time.Sleep(handlerTimeout / 2)
+			}
+		})
+	})
+
+	started := time.Now()
+	resp, err := http.Get(ts.URL + "/stream/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("流式路由返回 %d", resp.StatusCode)
+	}
+	received := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			received++
+		}
+	}
+	if received != events {
+		t.Errorf("收到 %d 个事件，应为 %d 个", received, events)
+	}
+	if elapsed := time.Since(started); elapsed < 2*handlerTimeout {
+		t.Errorf("流式响应只持续了 %v，测试没有超过处理超时", elapsed)
+	}
+}
+
+// TestStreamTrackerDrain 关闭时先等待流式连接自然结束，超过排空时间后通知处理函数，客户端收到关闭事件后连接结束
+func TestStreamTrackerDrain(t *testing.T) {
+	tracker := NewStreamTracker(0)
+	ts := newStreamServer(t, tracker, func(_ *gin.Engine, stream func(string, gin.HandlerFunc)) {
+		stream("/stream/logs", func(c *gin.Context) {
+			c.Header("Content-Type", "text/event-stream")
+			fmt.Fprint(c.Writer, "data: ready\n\n")
+			c.Writer.Flush()
+			select {
+			case <-StreamDraining(c):
+				fmt.Fprint(c.Writer, "event: close\ndata: server restarting\n\n")
+			case <-c.Request.Context().Done():
+			}
+		})
+	})
+
+	if closed := tracker.Drain(context.Background(), time.Second); closed != 0 {
+		t.Fatalf("没有流式连接时不应通知关闭，实际通知了 %d 个", closed)
+	}
+
+	resp, err := http.Get(ts.URL + "/stream/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != "data: ready\n" {
+		t.Fatalf("首个事件为 %q", line)
+	}
+	if tracker.Active() != 1 {
+		t.Fatalf("流式连接数为 %d，应为 1", tracker.Active())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if closed := tracker.Drain(ctx, time.Second); closed != 1 {
+		t.Errorf("超过排空时间后应通知 1 个连接关闭，实际为 %d", closed)
+	}
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("连接应正常结束: %v", err)
+	}
+	if !strings.Contains(string(rest), "event: close\ndata: server restarting") {
+		t.Errorf("客户端应收到关闭事件，实际为 %q", rest)
+	}
+	if tracker.Active() != 0 {
+		t.Errorf("排空后仍有 %d 个流式连接", tracker.Active())
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"context"
+
+	"flowforge/pkg/cache"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// userAuthInfo 认证每个请求时需要的用户信息
+type userAuthInfo struct {
+	Username string
+	Role     string
+	Status   string
+	Locale   string
+}
+
+// userAuthCache 按用户缓存认证信息；角色、状态或语言偏好修改后需调用 InvalidateUser，
+// 遗漏时修改最迟在缓存时间后生效
+var userAuthCache = cache.New("user_auth", func(ctx context.Context, userID uint) (*userAuthInfo, error) {
+	var user models.User
+	if err := database.DB.WithContext(ctx).Select("id", "username", "role", "status", "locale").
+		First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &userAuthInfo{Username: user.Username, Role: user.Role, Status: user.Status, Locale: user.Locale}, nil
+})
+
+// InvalidateUser 用户信息修改或删除后使其认证信息的缓存失效
+func InvalidateUser(userIDs ...uint) {
+	userAuthCache.Invalidate(userIDs...)
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/internal/authctx"
+	"flowforge/pkg/config"
+	"flowforge/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// WebSocketTicketPrefix 连接票据的前缀
+	WebSocketTicketPrefix = "ffwt_"
+	// WebSocketProtocol 连接使用的子协议，通过子协议传递令牌的客户端需要同时声明
+	WebSocketProtocol = "flowforge"
+	// webSocketBearerPrefix 通过子协议传递令牌时的前缀，如 bearer.<JWT>
+	webSocketBearerPrefix = "bearer."
+)
+
+// wsTicket 一次性连接票据代表的用户
+type wsTicket struct {
+	user      authctx.User
+	expiresAt time.Time
+}
+
+// WebSocketTickets 一次性 WebSocket 连接票据。票据只保存在内存中，兑换一次后失效，
+// 避免长期有效的令牌出现在地址与访问日志中
+type WebSocketTickets struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	tickets map[string]wsTicket
+}
+
+// NewWebSocketTickets 创建连接票据存储，ttl 为票据的有效期
+func NewWebSocketTickets(ttl time.Duration) *WebSocketTickets {
+	return &WebSocketTickets{
+		ttl:     ttl,
+		tickets: make(map[string]wsTicket),
+	}
+}
+
+// Issue 为用户签发连接票据，同时清理已过期的票据
+func (t *WebSocketTickets) Issue(user authctx.User) (string, time.Time) {
+	now := time.Now()
+	ticket := WebSocketTicketPrefix + utils.GenerateRandomString(32)
+	expiresAt := now.Add(t.ttl)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, entry := range t.tickets {
+		if now.After(entry.expiresAt) {
+			delete(t.tickets, key)
+		}
+	}
+	t.tickets[ticket] = wsTicket{user: user, expiresAt: expiresAt}
+	return ticket, expiresAt
+}
+
+// Redeem 兑换票据，票据不存在、已过期或已使用时返回 false
+func (t *WebSocketTickets) Redeem(ticket string) (authctx.User, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.tickets[ticket]
+	if !ok {
+		return authctx.User{}, false
+	}
+	delete(t.tickets, ticket)
+	if time.Now().After(entry.expiresAt) {
+		return authctx.User{}, false
+	}
+	return entry.user, true
+}
+
+// WebSocketAuth WebSocket 路由的认证中间件，在升级前按顺序接受：查询参数 ticket 中的一次性票据、
+// Sec-WebSocket-Protocol 中 bearer.<令牌> 形式的子协议（需同时声明 flowforge 子协议），以及非浏览器客户端的 Authorization 请求头
+func WebSocketAuth(cfg *config.Config, tickets *WebSocketTickets) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ticket := c.Query("ticket"); ticket != "" {
+			user, ok := tickets.Redeem(ticket)
+			if !ok {
+				utils.ErrorResponse(c, http.StatusUnauthorized, "连接票据无效或已过期")
+				c.Abort()
+				return
+			}
+			setUser(c, &user)
+			c.Next()
+			return
+		}
+
+		token := subprotocolToken(c.Request)
+		if token == "" {
+			parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+			if len(parts) == 2 && parts[0] == "Bearer" {
+				token = parts[1]
+			}
+		}
+		if token == "" {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "未提供认证信息")
+			c.Abort()
+			return
+		}
+
+		user, err := authenticateToken(c, cfg, token)
+		if errors.Is(err, errAPITokenIPDenied) {
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+			c.Abort()
+			return
+		}
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "无效的认证令牌")
+			c.Abort()
+			return
+		}
+		setUser(c, user)
+
+		c.Next()
+	}
+}
+
+// subprotocolToken 从客户端声明的子协议中取出令牌
+func subprotocolToken(r *http.Request) string {
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, webSocketBearerPrefix) {
+				return strings.TrimPrefix(protocol, webSocketBearerPrefix)
+			}
+		}
+	}
+	return ""
+}
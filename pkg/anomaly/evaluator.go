@@ -0,0 +1,357 @@
+// Package anomaly 按结束的运行检测项目与流水线的失败率突增。
+//
+// Evaluator 只在内存中保存长窗口内结束的运行并计算告警状态，不访问数据库，可以直接用构造的运行序列验证；
+// Monitor 在持有租约的实例上定时读取新结束的运行交给 Evaluator，保存告警并发送事件与通知。
+package anomaly
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// FailureKindStep 没有失败分类的失败运行，即步骤执行失败
+const FailureKindStep = "step"
+
+// Outcome 一个结束的运行，只统计成功与失败的运行
+type Outcome struct {
+	RunID       uint
+	ProjectID   uint
+	PipelineID  uint
+	Pipeline    string // 流水线名称
+	Failed      bool
+	FailureKind string
+	FinishedAt  time.Time
+}
+
+// Thresholds 一个项目的告警参数
+type Thresholds struct {
+	Disabled bool
+	Factor   float64       // 短窗口失败率超过基线的倍数
+	MinRate  float64       // 短窗口失败率的下限
+	MinRuns  int           // 短窗口与基线各自至少需要的运行数
+	Short    time.Duration // 短窗口
+	Long     time.Duration // 长窗口，基线为其中除短窗口外的部分
+	Cooldown time.Duration // 同一项目或流水线两次告警的最小间隔
+}
+
+// Transition 告警状态的变化：Resolved 为 false 时为新告警，Alert 为评估器持有的告警，调用方保存后可设置其 ID
+type Transition struct {
+	Alert    *models.FailureSpikeAlert
+	Resolved bool
+}
+
+// key 告警对象：PipelineID 为 0 时为项目
+type key struct {
+	project  uint
+	pipeline uint
+}
+
+// state 告警对象的状态
+type state struct {
+	active     *models.FailureSpikeAlert
+	lastRaised time.Time
+}
+
+// Evaluator 失败率突增评估器，不是并发安全的
+type Evaluator struct {
+	thresholds func(projectID uint) Thresholds
+	outcomes   map[uint][]Outcome // 按项目
+	seen       map[uint]bool      // 已记录的运行ID
+	states     map[key]*state
+}
+
+// NewEvaluator 创建评估器，thresholds 返回项目的告警参数
+func NewEvaluator(thresholds func(projectID uint) Thresholds) *Evaluator {
+	return &Evaluator{
+		thresholds: thresholds,
+		outcomes:   make(map[uint][]Outcome),
+		seen:       make(map[uint]bool),
+		states:     make(map[key]*state),
+	}
+}
+
+// Observe 记录一个结束的运行，同一运行只记录一次
+func (e *Evaluator) Observe(outcome Outcome) {
+	if e.seen[outcome.RunID] {
+		return
+	}
+	e.seen[outcome.RunID] = true
+	e.outcomes[outcome.ProjectID] = append(e.outcomes[outcome.ProjectID], outcome)
+}
+
+// Restore 恢复已保存的告警：告警中的告警不再重复发送，冷却期内的告警用于计算下次可以告警的时间
+func (e *Evaluator) Restore(alert *models.FailureSpikeAlert) {
+	k := key{project: alert.ProjectID}
+	if alert.PipelineID != nil {
+		k.pipeline = *alert.PipelineID
+	}
+	s := e.state(k)
+	if alert.TriggeredAt.After(s.lastRaised) {
+		s.lastRaised = alert.TriggeredAt
+	}
+	if alert.ResolvedAt == nil {
+		s.active = alert
+	}
+}
+
+// Active 告警中的告警，项目告警在前
+func (e *Evaluator) Active() []*models.FailureSpikeAlert {
+	var alerts []*models.FailureSpikeAlert
+	for _, s := range e.states {
+		if s.active != nil {
+			alerts = append(alerts, s.active)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].ProjectID != alerts[j].ProjectID {
+			return alerts[i].ProjectID < alerts[j].ProjectID
+		}
+		return alerts[i].PipelineID == nil && alerts[j].PipelineID != nil
+	})
+	return alerts
+}
+
+// window 一段时间内的运行统计
+type window struct {
+	runs     int
+	failures int
+	kinds    map[string]int
+}
+
+func (w *window) add(outcome Outcome) {
+	w.runs++
+	if !outcome.Failed {
+		return
+	}
+	w.failures++
+	kind := outcome.FailureKind
+	if kind == "" {
+		kind = FailureKindStep
+	}
+	if w.kinds == nil {
+		w.kinds = make(map[string]int)
+	}
+	w.kinds[kind]++
+}
+
+func (w *window) rate() float64 {
+	if w.runs == 0 {
+		return 0
+	}
+	return float64(w.failures) / float64(w.runs)
+}
+
+// dominantKind 失败最多的分类，数量相同时按名称排序取第一个
+func (w *window) dominantKind() string {
+	best, count := "", 0
+	for kind, n := range w.kinds {
+		if n > count || (n == count && kind < best) {
+			best, count = kind, n
+		}
+	}
+	return best
+}
+
+// windows 项目或流水线的短窗口与基线统计
+type windows struct {
+	short    window
+	baseline window
+	name     string
+}
+
+// Evaluate 按 now 计算各项目与流水线的告警状态，返回新告警与恢复。
+// 短窗口与基线都至少有 MinRuns 个运行，且短窗口失败率不低于 MinRate、超过基线的 Factor 倍时告警，冷却期内不再告警；
+// 告警中的对象在短窗口至少有 MinRuns 个运行且失败率回到告警时的基线以下，或长窗口内没有运行时恢复。
+// 项目告警中时其中的流水线不单独告警
+func (e *Evaluator) Evaluate(now time.Time) []Transition {
+	var transitions []Transition
+	for projectID := range e.projects() {
+		thresholds := e.thresholds(projectID)
+		project, pipelines := e.collect(projectID, thresholds, now)
+
+		if thresholds.Disabled {
+			for k, s := range e.states {
+				if k.project == projectID && s.active != nil {
+					transitions = append(transitions, e.resolve(s, now))
+				}
+			}
+			continue
+		}
+
+		if t, ok := e.step(key{project: projectID}, project, pipelines, thresholds, now); ok {
+			transitions = append(transitions, t)
+		}
+		projectAlerting := e.alerting(key{project: projectID})
+
+		ids := make([]uint, 0, len(pipelines))
+		for id := range pipelines {
+			ids = append(ids, id)
+		}
+		for k, s := range e.states {
+			if k.project == projectID && k.pipeline != 0 && s.active != nil && pipelines[k.pipeline] == nil {
+				ids = append(ids, k.pipeline)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			k := key{project: projectID, pipeline: id}
+			stats := pipelines[id]
+			if stats == nil {
+				stats = &windows{}
+			}
+			if projectAlerting && !e.alerting(k) {
+				continue
+			}
+			if t, ok := e.step(k, stats, nil, thresholds, now); ok {
+				transitions = append(transitions, t)
+			}
+		}
+
+		// 冷却期已过且没有告警的对象不再需要状态
+		for k, s := range e.states {
+			if k.project == projectID && s.active == nil && now.Sub(s.lastRaised) >= thresholds.Cooldown {
+				delete(e.states, k)
+			}
+		}
+	}
+	return transitions
+}
+
+// alerting 对象是否告警中
+func (e *Evaluator) alerting(k key) bool {
+	s := e.states[k]
+	return s != nil && s.active != nil
+}
+
+// projects 有运行记录或有告警状态的项目
+func (e *Evaluator) projects() map[uint]bool {
+	projects := make(map[uint]bool, len(e.outcomes))
+	for projectID := range e.outcomes {
+		projects[projectID] = true
+	}
+	for k, s := range e.states {
+		if s.active != nil {
+			projects[k.project] = true
+		}
+	}
+	return projects
+}
+
+// collect 丢弃长窗口之前的运行，统计项目与各流水线的短窗口与基线
+func (e *Evaluator) collect(projectID uint, thresholds Thresholds, now time.Time) (*windows, map[uint]*windows) {
+	longStart := now.Add(-thresholds.Long)
+	shortStart := now.Add(-thresholds.Short)
+
+	project := &windows{}
+	pipelines := make(map[uint]*windows)
+	kept := e.outcomes[projectID][:0]
+	for _, outcome := range e.outcomes[projectID] {
+		if !outcome.FinishedAt.After(longStart) {
+			delete(e.seen, outcome.RunID)
+			continue
+		}
+		kept = append(kept, outcome)
+
+		pipeline := pipelines[outcome.PipelineID]
+		if pipeline == nil {
+			pipeline = &windows{}
+			pipelines[outcome.PipelineID] = pipeline
+		}
+		pipeline.name = outcome.Pipeline
+		if outcome.FinishedAt.After(shortStart) {
+			project.short.add(outcome)
+			pipeline.short.add(outcome)
+		} else {
+			project.baseline.add(outcome)
+			pipeline.baseline.add(outcome)
+		}
+	}
+	if len(kept) == 0 {
+		delete(e.outcomes, projectID)
+	} else {
+		e.outcomes[projectID] = kept
+	}
+	return project, pipelines
+}
+
+// step 按统计更新一个对象的告警状态；pipelines 不为 nil 时为项目，告警中列出短窗口内有失败的流水线
+func (e *Evaluator) step(k key, stats *windows, pipelines map[uint]*windows, thresholds Thresholds, now time.Time) (Transition, bool) {
+	s := e.states[k]
+	rate := stats.short.rate()
+
+	if s != nil && s.active != nil {
+		empty := stats.short.runs == 0 && stats.baseline.runs == 0
+		recovered := stats.short.runs >= thresholds.MinRuns && rate <= s.active.BaselineRate
+		if empty || recovered {
+			return e.resolve(s, now), true
+		}
+		return Transition{}, false
+	}
+
+	if stats.short.runs < thresholds.MinRuns || stats.baseline.runs < thresholds.MinRuns {
+		return Transition{}, false
+	}
+	baseline := stats.baseline.rate()
+	if rate < thresholds.MinRate || rate <= baseline*thresholds.Factor {
+		return Transition{}, false
+	}
+	if s != nil && now.Sub(s.lastRaised) < thresholds.Cooldown {
+		return Transition{}, false
+	}
+
+	alert := &models.FailureSpikeAlert{
+		ProjectID:    k.project,
+		Runs:         stats.short.runs,
+		Failures:     stats.short.failures,
+		Rate:         rate,
+		BaselineRate: baseline,
+		FailureKind:  stats.short.dominantKind(),
+		TriggeredAt:  now,
+	}
+	affected := []models.SpikePipeline{}
+	if k.pipeline != 0 {
+		id := k.pipeline
+		alert.PipelineID = &id
+		affected = append(affected, models.SpikePipeline{PipelineID: id, Name: stats.name, Runs: stats.short.runs, Failures: stats.short.failures})
+	}
+	for id, pipeline := range pipelines {
+		if pipeline.short.failures > 0 {
+			affected = append(affected, models.SpikePipeline{PipelineID: id, Name: pipeline.name, Runs: pipeline.short.runs, Failures: pipeline.short.failures})
+		}
+	}
+	sort.Slice(affected, func(i, j int) bool {
+		if affected[i].Failures != affected[j].Failures {
+			return affected[i].Failures > affected[j].Failures
+		}
+		return affected[i].PipelineID < affected[j].PipelineID
+	})
+	data, _ := json.Marshal(affected)
+	alert.Pipelines = string(data)
+
+	s = e.state(k)
+	s.active = alert
+	s.lastRaised = now
+	return Transition{Alert: alert}, true
+}
+
+// resolve 结束对象的告警
+func (e *Evaluator) resolve(s *state, now time.Time) Transition {
+	alert := s.active
+	resolvedAt := now
+	alert.ResolvedAt = &resolvedAt
+	s.active = nil
+	return Transition{Alert: alert, Resolved: true}
+}
+
+// state 对象的状态，不存在时创建
+func (e *Evaluator) state(k key) *state {
+	s := e.states[k]
+	if s == nil {
+		s = &state{}
+		e.states[k] = s
+	}
+	return s
+}
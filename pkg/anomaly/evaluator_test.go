@@ -0,0 +1,255 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+var epoch = time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+
+// testThresholds 短窗口 1 小时、长窗口 24 小时，至少 5 个运行，失败率不低于 50% 且超过基线 3 倍时告警，冷却 6 小时
+var testThresholds = Thresholds{
+	Factor:   3,
+	MinRate:  0.5,
+	MinRuns:  5,
+	Short:    time.Hour,
+	Long:     24 * time.Hour,
+	Cooldown: 6 * time.Hour,
+}
+
+// feeder 构造运行序列，运行ID递增
+type feeder struct {
+	e      *Evaluator
+	nextID uint
+}
+
+func newFeeder(thresholds Thresholds) *feeder {
+	return &feeder{e: NewEvaluator(func(uint) Thresholds { return thresholds })}
+}
+
+// runs 流水线在 [from, to) 内均匀结束 n 个运行，前 failed 个失败，失败分类为 kind
+func (f *feeder) runs(pipeline uint, name string, from, to time.Time, n, failed int, kind string) {
+	step := to.Sub(from) / time.Duration(n)
+	for i := 0; i < n; i++ {
+		f.nextID++
+		outcome := Outcome{
+			RunID:      f.nextID,
+			ProjectID:  1,
+			PipelineID: pipeline,
+			Pipeline:   name,
+			Failed:     i < failed,
+			FinishedAt: from.Add(time.Duration(i) * step),
+		}
+		if outcome.Failed {
+			outcome.FailureKind = kind
+		}
+		f.e.Observe(outcome)
+	}
+}
+
+// baseline 两条流水线在短窗口之前的 20 小时内各有 20 个运行，build 失败 1 个
+func (f *feeder) baseline(now time.Time) {
+	f.runs(1, "build", now.Add(-22*time.Hour), now.Add(-2*time.Hour), 20, 1, "")
+	f.runs(2, "deploy", now.Add(-22*time.Hour), now.Add(-2*time.Hour), 20, 0, "")
+}
+
+// spike 短窗口内两条流水线各 5 个运行，build 全部凭证失败、deploy 失败 3 个
+func (f *feeder) spike(now time.Time) {
+	f.runs(1, "build", now.Add(-50*time.Minute), now.Add(-time.Minute), 5, 5, "credentials")
+	f.runs(2, "deploy", now.Add(-50*time.Minute), now.Add(-time.Minute), 5, 3, "timeout")
+}
+
+func TestEvaluatorTriggers(t *testing.T) {
+	f := newFeeder(testThresholds)
+	f.baseline(epoch)
+	if got := f.e.Evaluate(epoch); len(got) != 0 {
+		t.Fatalf("基线正常时不应告警，实际为 %+v", got)
+	}
+
+	now := epoch.Add(time.Hour)
+	f.spike(now)
+	got := f.e.Evaluate(now)
+	if len(got) != 1 || got[0].Resolved {
+		t.Fatalf("项目应产生一个告警，流水线不单独告警，实际为 %+v", got)
+	}
+	alert := got[0].Alert
+	if alert.ProjectID != 1 || alert.PipelineID != nil {
+		t.Fatalf("应为项目告警: %+v", alert)
+	}
+	if alert.Runs != 10 || alert.Failures != 8 || alert.Rate != 0.8 {
+		t.Errorf("短窗口统计 %d/%d（%.2f），应为 8/10", alert.Failures, alert.Runs, alert.Rate)
+	}
+	if alert.BaselineRate != 1.0/40 {
+		t.Errorf("基线失败率 %.3f，应为 1/40", alert.BaselineRate)
+	}
+	if alert.FailureKind != "credentials" {
+		t.Errorf("主要失败分类 %q，应为 credentials", alert.FailureKind)
+	}
+	if !alert.TriggeredAt.Equal(now) {
+		t.Errorf("告警时间 %v，应为 %v", alert.TriggeredAt, now)
+	}
+
+	affected := alert.AffectedPipelines()
+	want := []models.SpikePipeline{{PipelineID: 1, Name: "build", Runs: 5, Failures: 5}, {PipelineID: 2, Name: "deploy", Runs: 5, Failures: 3}}
+	if len(affected) != len(want) {
+		t.Fatalf("受影响的流水线 %+v，应为 %+v", affected, want)
+	}
+	for i := range want {
+		if affected[i] != want[i] {
+			t.Errorf("受影响的流水线 %d 为 %+v，应为 %+v", i, affected[i], want[i])
+		}
+	}
+	if active := f.e.Active(); len(active) != 1 || active[0] != alert {
+		t.Errorf("Active 应返回告警中的项目告警，实际为 %+v", active)
+	}
+}
+
+func TestEvaluatorRequiresSamples(t *testing.T) {
+	f := newFeeder(testThresholds)
+	f.baseline(epoch)
+	// 短窗口只有 4 个运行，即使全部失败也不告警
+	f.runs(1, "build", epoch.Add(-30*time.Minute), epoch, 4, 4, "")
+	if got := f.e.Evaluate(epoch); len(got) != 0 {
+		t.Fatalf("短窗口运行数不足时不应告警，实际为 %+v", got)
+	}
+
+	// 没有基线时不告警
+	f = newFeeder(testThresholds)
+	f.spike(epoch)
+	if got := f.e.Evaluate(epoch); len(got) != 0 {
+		t.Fatalf("没有基线时不应告警，实际为 %+v", got)
+	}
+}
+
+func TestEvaluatorPipelineAlert(t *testing.T) {
+	f := newFeeder(testThresholds)
+	f.runs(1, "build", epoch.Add(-22*time.Hour), epoch.Add(-2*time.Hour), 10, 0, "")
+	f.runs(2, "deploy", epoch.Add(-22*time.Hour), epoch.Add(-2*time.Hour), 30, 0, "")
+	// 项目短窗口失败率 5/25 低于下限，只有 build 告警
+	f.runs(1, "build", epoch.Add(-50*time.Minute), epoch, 5, 5, "")
+	f.runs(2, "deploy", epoch.Add(-50*time.Minute), epoch, 20, 0, "")
+
+	got := f.e.Evaluate(epoch)
+	if len(got) != 1 || got[0].Alert.PipelineID == nil || *got[0].Alert.PipelineID != 1 {
+		t.Fatalf("应只有 build 流水线告警，实际为 %+v", got)
+	}
+	if kind := got[0].Alert.FailureKind; kind != FailureKindStep {
+		t.Errorf("没有失败分类的失败应归为 %s，实际为 %q", FailureKindStep, kind)
+	}
+}
+
+func TestEvaluatorDedupe(t *testing.T) {
+	f := newFeeder(testThresholds)
+	now := epoch.Add(time.Hour)
+	f.baseline(epoch)
+	f.spike(now)
+	if got := f.e.Evaluate(now); len(got) != 1 {
+		t.Fatalf("应告警，实际为 %+v", got)
+	}
+
+	// 同一运行重复到达只记录一次；持续失败期间不重复告警
+	f.e.Observe(Outcome{RunID: 1, ProjectID: 1, PipelineID: 1, Failed: true, FinishedAt: now})
+	for i := 1; i <= 3; i++ {
+		at := now.Add(time.Duration(i) * 10 * time.Minute)
+		f.runs(1, "build", at.Add(-5*time.Minute), at, 2, 2, "credentials")
+		if got := f.e.Evaluate(at); len(got) != 0 {
+			t.Fatalf("告警中的项目不应重复告警，第 %d 次评估为 %+v", i, got)
+		}
+	}
+}
+
+func TestEvaluatorRecovery(t *testing.T) {
+	f := newFeeder(testThresholds)
+	now := epoch.Add(time.Hour)
+	f.baseline(epoch)
+	f.spike(now)
+	raised := f.e.Evaluate(now)
+	if len(raised) != 1 {
+		t.Fatalf("应告警，实际为 %+v", raised)
+	}
+
+	// 短窗口运行数不足时保持告警
+	later := now.Add(2 * time.Hour)
+	f.runs(1, "build", later.Add(-30*time.Minute), later, 3, 0, "")
+	if got := f.e.Evaluate(later); len(got) != 0 {
+		t.Fatalf("短窗口运行数不足时不应恢复，实际为 %+v", got)
+	}
+
+	// 失败率回到基线以下时恢复
+	f.runs(2, "deploy", later.Add(-20*time.Minute), later, 3, 0, "")
+	got := f.e.Evaluate(later)
+	if len(got) != 1 || !got[0].Resolved || got[0].Alert != raised[0].Alert {
+		t.Fatalf("应恢复原告警，实际为 %+v", got)
+	}
+	if resolved := got[0].Alert.ResolvedAt; resolved == nil || !resolved.Equal(later) {
+		t.Errorf("恢复时间 %v，应为 %v", resolved, later)
+	}
+	if active := f.e.Active(); len(active) != 0 {
+		t.Errorf("恢复后不应有告警中的告警，实际为 %+v", active)
+	}
+
+	// 冷却期内项目再次突增不告警，冷却后告警；流水线的冷却分别计算
+	f.spike(later.Add(time.Hour))
+	if alert := projectAlert(f.e.Evaluate(later.Add(time.Hour))); alert != nil {
+		t.Fatalf("冷却期内项目不应再次告警，实际为 %+v", alert)
+	}
+	again := now.Add(testThresholds.Cooldown + time.Hour)
+	f.runs(1, "build", again.Add(-4*time.Hour), again.Add(-2*time.Hour), 20, 0, "")
+	f.spike(again)
+	if alert := projectAlert(f.e.Evaluate(again)); alert == nil || !alert.TriggeredAt.Equal(again) {
+		t.Fatalf("冷却后项目应再次告警，实际为 %+v", alert)
+	}
+}
+
+// projectAlert 新的项目告警，没有时返回 nil
+func projectAlert(transitions []Transition) *models.FailureSpikeAlert {
+	for _, t := range transitions {
+		if !t.Resolved && t.Alert.PipelineID == nil {
+			return t.Alert
+		}
+	}
+	return nil
+}
+
+func TestEvaluatorRecoversWhenIdle(t *testing.T) {
+	f := newFeeder(testThresholds)
+	now := epoch.Add(time.Hour)
+	f.baseline(epoch)
+	f.spike(now)
+	f.e.Evaluate(now)
+
+	// 长窗口内没有运行时恢复
+	idle := now.Add(testThresholds.Long)
+	got := f.e.Evaluate(idle)
+	if len(got) != 1 || !got[0].Resolved {
+		t.Fatalf("长窗口内没有运行时应恢复，实际为 %+v", got)
+	}
+}
+
+func TestEvaluatorRestore(t *testing.T) {
+	f := newFeeder(testThresholds)
+	now := epoch.Add(time.Hour)
+	saved := &models.FailureSpikeAlert{ID: 9, ProjectID: 1, BaselineRate: 0.025, TriggeredAt: now.Add(-30 * time.Minute)}
+	f.e.Restore(saved)
+	f.baseline(epoch)
+	f.spike(now)
+
+	// 恢复的告警中的告警不重复发送
+	if got := f.e.Evaluate(now); len(got) != 0 {
+		t.Fatalf("已保存的告警不应重复发送，实际为 %+v", got)
+	}
+	if active := f.e.Active(); len(active) != 1 || active[0].ID != 9 {
+		t.Fatalf("Active 应返回恢复的告警，实际为 %+v", active)
+	}
+
+	// 关闭检测时结束告警中的告警
+	disabled := testThresholds
+	disabled.Disabled = true
+	f.e.thresholds = func(uint) Thresholds { return disabled }
+	got := f.e.Evaluate(now.Add(time.Minute))
+	if len(got) != 1 || !got[0].Resolved || got[0].Alert.ID != 9 {
+		t.Fatalf("关闭检测时应结束告警，实际为 %+v", got)
+	}
+}
@@ -0,0 +1,260 @@
+package anomaly
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/events"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+)
+
+const (
+	// leaseName 检测任务的租约，只有持有租约的实例检测，避免多个实例重复告警
+	leaseName = "failure_spike"
+	// leaseTTL 租约有效期，检测任务每分钟执行一次并续期
+	leaseTTL = 3 * time.Minute
+	// settleDelay 每次多读取的时间：运行的结束时间早于其提交时间，晚提交的运行不会被游标越过
+	settleDelay = time.Minute
+)
+
+// Monitor 失败率突增检测任务：持有租约时读取新结束的运行交给 Evaluator，保存告警、发送事件并通知项目所有者与关注者。
+// 失去租约后丢弃内存中的状态，重新获得时从数据库恢复告警与长窗口内的运行
+type Monitor struct {
+	config   *config.Config
+	notifier *notify.Manager
+
+	mu        sync.Mutex
+	evaluator *Evaluator // 未持有租约时为 nil
+	since     time.Time  // 已读取到的运行结束时间
+	projects  map[uint]models.Project
+}
+
+// NewMonitor 创建失败率突增检测任务，notifier 为 nil 时只保存告警与发送事件
+func NewMonitor(cfg *config.Config, notifier *notify.Manager) *Monitor {
+	return &Monitor{
+		config:   cfg,
+		notifier: notifier,
+	}
+}
+
+// Check 执行一次检测，由调度器每分钟调用
+func (m *Monitor) Check() {
+	settings := &m.config.Deploy.FailureSpike
+	if settings.Disabled {
+		return
+	}
+
+	leader, err := database.AcquireLease(leaseName, leaseTTL)
+	if err != nil {
+		log.Printf("获取失败率检测租约失败: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !leader {
+		m.evaluator = nil
+		return
+	}
+
+	now := time.Now()
+	if err := m.loadProjects(); err != nil {
+		log.Printf("失败率检测读取项目设置失败: %v", err)
+		return
+	}
+	if m.evaluator == nil {
+		evaluator := NewEvaluator(m.thresholds)
+		if err := m.restore(evaluator, now); err != nil {
+			log.Printf("失败率检测恢复告警失败: %v", err)
+			return
+		}
+		m.evaluator = evaluator
+		m.since = now.Add(-time.Duration(settings.LongHours) * time.Hour)
+	}
+	if err := m.observe(now); err != nil {
+		log.Printf("失败率检测读取运行失败: %v", err)
+		return
+	}
+
+	for _, transition := range m.evaluator.Evaluate(now) {
+		m.apply(transition)
+	}
+}
+
+// Stop 释放租约，其他实例不必等待过期即可接管
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.evaluator == nil {
+		return
+	}
+	m.evaluator = nil
+	if err := database.ReleaseLease(leaseName); err != nil {
+		log.Printf("释放失败率检测租约失败: %v", err)
+	}
+}
+
+// thresholds 项目生效的告警参数，项目未设置的使用全局配置；已归档或已删除的项目不检测
+func (m *Monitor) thresholds(projectID uint) Thresholds {
+	settings := &m.config.Deploy.FailureSpike
+	thresholds := Thresholds{
+		Factor:   settings.Factor,
+		MinRate:  settings.MinRate,
+		MinRuns:  settings.MinRuns,
+		Short:    time.Duration(settings.ShortMinutes) * time.Minute,
+		Long:     time.Duration(settings.LongHours) * time.Hour,
+		Cooldown: time.Duration(settings.CooldownMinutes) * time.Minute,
+	}
+	project, ok := m.projects[projectID]
+	if !ok || project.IsArchived() || project.FailureSpikeDisabled {
+		thresholds.Disabled = true
+		return thresholds
+	}
+	if project.FailureSpikeFactor > 0 {
+		thresholds.Factor = project.FailureSpikeFactor
+	}
+	if project.FailureSpikeMinRuns > 0 {
+		thresholds.MinRuns = project.FailureSpikeMinRuns
+	}
+	return thresholds
+}
+
+// loadProjects 读取各项目的告警设置
+func (m *Monitor) loadProjects() error {
+	var projects []models.Project
+	if err := database.DB.Select("id", "name", "user_id", "status", "failure_spike_factor", "failure_spike_min_runs", "failure_spike_disabled").
+		Find(&projects).Error; err != nil {
+		return err
+	}
+	m.projects = make(map[uint]models.Project, len(projects))
+	for _, project := range projects {
+		m.projects[project.ID] = project
+	}
+	return nil
+}
+
+// restore 恢复告警中与冷却期内的告警
+func (m *Monitor) restore(evaluator *Evaluator, now time.Time) error {
+	cooldown := time.Duration(m.config.Deploy.FailureSpike.CooldownMinutes) * time.Minute
+	var alerts []models.FailureSpikeAlert
+	if err := database.DB.Where("resolved_at IS NULL OR triggered_at > ?", now.Add(-cooldown)).
+		Order("triggered_at").Find(&alerts).Error; err != nil {
+		return err
+	}
+	for i := range alerts {
+		evaluator.Restore(&alerts[i])
+	}
+	return nil
+}
+
+// observe 读取上次之后结束的成功与失败运行
+func (m *Monitor) observe(now time.Time) error {
+	var rows []struct {
+		ID          uint
+		PipelineID  uint
+		ProjectID   uint
+		Name        string
+		Status      string
+		FailureKind string
+		EndTime     time.Time
+	}
+	if err := database.DB.Table("pipeline_runs").
+		Select("pipeline_runs.id, pipeline_runs.pipeline_id, pipelines.project_id, pipelines.name, pipeline_runs.status, pipeline_runs.failure_kind, pipeline_runs.end_time").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Where("pipeline_runs.deleted_at IS NULL AND pipeline_runs.end_time > ? AND pipeline_runs.status IN ?",
+			m.since.Add(-settleDelay), []string{models.RunStatusSuccess, models.RunStatusFailed}).
+		Order("pipeline_runs.end_time").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		m.evaluator.Observe(Outcome{
+			RunID:       row.ID,
+			ProjectID:   row.ProjectID,
+			PipelineID:  row.PipelineID,
+			Pipeline:    row.Name,
+			Failed:      row.Status == models.RunStatusFailed,
+			FailureKind: row.FailureKind,
+			FinishedAt:  row.EndTime,
+		})
+		if row.EndTime.After(m.since) {
+			m.since = row.EndTime
+		}
+	}
+	if m.since.After(now) {
+		m.since = now
+	}
+	return nil
+}
+
+// apply 保存告警状态的变化，发送事件并通知
+func (m *Monitor) apply(transition Transition) {
+	alert := transition.Alert
+	if transition.Resolved {
+		if alert.ID != 0 {
+			if err := database.DB.Model(alert).Update("resolved_at", alert.ResolvedAt).Error; err != nil {
+				log.Printf("保存项目 %d 的失败率告警恢复失败: %v", alert.ProjectID, err)
+			}
+		}
+	} else if err := database.DB.Create(alert).Error; err != nil {
+		log.Printf("保存项目 %d 的失败率告警失败: %v", alert.ProjectID, err)
+	}
+
+	if events.Enabled() {
+		m.publish(alert)
+	}
+	if m.notifier != nil {
+		project := m.projects[alert.ProjectID]
+		go m.notifier.NotifyFailureSpike(alert, &project, m.config.Deploy.FailureSpike.ShortMinutes)
+	}
+}
+
+// publish 发送 project.failure_spike 或 project.failure_spike_resolved 事件
+func (m *Monitor) publish(alert *models.FailureSpikeAlert) {
+	event := events.Event{
+		Type:     events.TypeFailureSpike,
+		Resource: events.Resource{Type: "project", ID: strconv.FormatUint(uint64(alert.ProjectID), 10), ProjectID: alert.ProjectID},
+		Outcome:  events.OutcomeFailure,
+		Data: map[string]interface{}{
+			"alert_id":       alert.ID,
+			"runs":           alert.Runs,
+			"failures":       alert.Failures,
+			"rate":           alert.Rate,
+			"baseline_rate":  alert.BaselineRate,
+			"failure_kind":   alert.FailureKind,
+			"pipelines":      alert.AffectedPipelines(),
+			"window_minutes": m.config.Deploy.FailureSpike.ShortMinutes,
+			"triggered_at":   alert.TriggeredAt.UTC(),
+		},
+	}
+	if alert.PipelineID != nil {
+		event.Resource.PipelineID = *alert.PipelineID
+	}
+	if alert.ResolvedAt != nil {
+		event.Type = events.TypeFailureSpikeResolved
+		event.Outcome = events.OutcomeSuccess
+		event.Data["resolved_at"] = alert.ResolvedAt.UTC()
+	}
+	if err := events.Publish(database.DB, event); err != nil {
+		log.Printf("记录项目 %d 的失败率告警事件失败: %v", alert.ProjectID, err)
+	}
+}
+
+// ActiveAlerts 告警中的失败率告警，projectIDs 为空时返回全部项目的告警
+func ActiveAlerts(projectIDs ...uint) ([]models.FailureSpikeAlert, error) {
+	query := database.DB.Where("resolved_at IS NULL")
+	if len(projectIDs) > 0 {
+		query = query.Where("project_id IN ?", projectIDs)
+	}
+	var alerts []models.FailureSpikeAlert
+	if err := query.Order("triggered_at DESC").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("查询失败率告警失败: %w", err)
+	}
+	return alerts, nil
+}
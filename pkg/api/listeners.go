@@ -0,0 +1,118 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// defaultSocketMode unix 套接字文件的默认权限：所有者与同组用户（如 nginx）可连接
+const defaultSocketMode = 0660
+
+// serverListener 一个已打开的监听
+type serverListener struct {
+	net.Listener
+	network    string
+	socketPath string // unix 监听的套接字文件，关闭后删除
+}
+
+// describe 监听的描述，用于日志
+func (l *serverListener) describe() string {
+	if l.network == "unix" {
+		return "unix:" + l.socketPath
+	}
+	return fmt.Sprintf("%s %s", l.network, l.Addr())
+}
+
+// openListeners 按配置打开全部监听，任一失败时关闭已打开的监听并返回错误
+func openListeners(targets []config.ListenerConfig) ([]*serverListener, error) {
+	var listeners []*serverListener
+	for _, target := range targets {
+		listener, err := openListener(target)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// openListener 打开单个监听，unix 监听先清理遗留的套接字文件并设置权限
+func openListener(target config.ListenerConfig) (*serverListener, error) {
+	if target.Network != "unix" {
+		listener, err := net.Listen(target.Network, target.Address)
+		if err != nil {
+			return nil, fmt.Errorf("监听 %s %s 失败: %w", target.Network, target.Address, err)
+		}
+		return &serverListener{Listener: listener, network: target.Network}, nil
+	}
+
+	mode := os.FileMode(defaultSocketMode)
+	if target.SocketMode != "" {
+		m, err := strconv.ParseUint(target.SocketMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的套接字文件权限: %s", target.SocketMode)
+		}
+		mode = os.FileMode(m)
+	}
+
+	if err := removeStaleSocket(target.SocketPath); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", target.SocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("监听套接字 %s 失败: %w", target.SocketPath, err)
+	}
+	if err := os.Chmod(target.SocketPath, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("设置套接字 %s 权限失败: %w", target.SocketPath, err)
+	}
+	return &serverListener{Listener: listener, network: "unix", socketPath: target.SocketPath}, nil
+}
+
+// removeStaleSocket 删除上次未正常退出遗留的套接字文件；路径被普通文件占用或套接字仍有进程在监听时拒绝启动
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("检查套接字路径 %s 失败: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("套接字路径 %s 已存在且不是套接字", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("套接字 %s 正在被其他进程使用", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("删除遗留的套接字 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// closeListeners 关闭监听并删除套接字文件
+func closeListeners(listeners []*serverListener) {
+	for _, listener := range listeners {
+		listener.Close()
+		listener.cleanup()
+	}
+}
+
+// cleanup 删除 unix 监听的套接字文件（关闭监听时通常已删除，这里兜底）
+func (l *serverListener) cleanup() {
+	if l.socketPath == "" {
+		return
+	}
+	if err := os.Remove(l.socketPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("删除套接字 %s 失败: %v", l.socketPath, err)
+	}
+}
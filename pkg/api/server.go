@@ -7,18 +7,26 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"syscall"
 	"time"
 
 	"flowforge/internal/handlers"
 	"flowforge/internal/middleware"
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/backup"
+	"flowforge/pkg/compliance"
 	"flowforge/pkg/config"
 	"flowforge/pkg/database"
 	"flowforge/pkg/deploy"
+	"flowforge/pkg/events"
 	"flowforge/pkg/git"
 	"flowforge/pkg/pipeline"
 	"flowforge/pkg/scripts"
+	"flowforge/pkg/service"
 	"flowforge/pkg/ssh"
+	"flowforge/pkg/support"
+	"flowforge/pkg/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -34,15 +42,31 @@ type Server struct {
 	gitManager     *git.Manager
 	sshManager     *ssh.Manager
 	deployManager  *deploy.DeployManager
+	artifactStore  *artifact.Store
+	supportBundle  *support.Generator
+	driftChecker   *deploy.DriftChecker
+	freezeManager  *deploy.FreezeManager
+	preflighter    *deploy.Preflighter
+	complianceJobs *compliance.Manager
+	events         *events.Dispatcher
+	backups        *backup.Manager
+
+	// 流式路由（WebSocket、下载等）不受处理超时限制，关闭时等待其排空
+	streams      *middleware.StreamTracker
+	streamRoutes map[string]bool
 }
 
 // NewServer 创建新的API服务器
-func NewServer(cfg *config.Config, pipelineEngine *pipeline.Engine, scriptManager *scripts.Manager, gitManager *git.Manager, sshManager *ssh.Manager, deployManager *deploy.DeployManager) *Server {
+func NewServer(cfg *config.Config, pipelineEngine *pipeline.Engine, scriptManager *scripts.Manager, gitManager *git.Manager, sshManager *ssh.Manager, deployManager *deploy.DeployManager, artifactStore *artifact.Store, supportBundle *support.Generator, driftChecker *deploy.DriftChecker, freezeManager *deploy.FreezeManager, preflighter *deploy.Preflighter, complianceJobs *compliance.Manager, eventDispatcher *events.Dispatcher, backups *backup.Manager) *Server {
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
 	// 创建Gin路由器
 	router := gin.New()
+	// 只信任配置的代理转发的客户端地址，Webhook与API令牌的来源限制依赖该地址
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Printf("设置信任代理失败: %v", err)
+	}
 
 	// 创建HTTP服务器
 	httpServer := &http.Server{
@@ -51,6 +75,8 @@ func NewServer(cfg *config.Config, pipelineEngine *pipeline.Engine, scriptManage
 		ReadTimeout:    time.Duration(cfg.Server.ReadTimeout) * time.Second,
 		WriteTimeout:   time.Duration(cfg.Server.WriteTimeout) * time.Second,
 		MaxHeaderBytes: cfg.Server.MaxHeaderMB << 20, // MB to bytes
+
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout) * time.Second,
 	}
 
 	return &Server{
@@ -62,6 +88,16 @@ func NewServer(cfg *config.Config, pipelineEngine *pipeline.Engine, scriptManage
 		gitManager:     gitManager,
 		sshManager:     sshManager,
 		deployManager:  deployManager,
+		artifactStore:  artifactStore,
+		supportBundle:  supportBundle,
+		driftChecker:   driftChecker,
+		freezeManager:  freezeManager,
+		preflighter:    preflighter,
+		complianceJobs: complianceJobs,
+		events:         eventDispatcher,
+		backups:        backups,
+		streams:        middleware.NewStreamTracker(time.Duration(cfg.Server.StreamIdleTimeout) * time.Second),
+		streamRoutes:   make(map[string]bool),
 	}
 }
 
@@ -93,14 +129,32 @@ func (s *Server) setupMiddleware() {
 	// 请求ID中间件
 	s.router.Use(middleware.RequestID())
 
+	// 语言协商中间件
+	s.router.Use(middleware.Locale())
+
 	// 安全头中间件
 	s.router.Use(middleware.Security())
+
+	// 处理超时中间件，流式路由除外
+	s.router.Use(middleware.Timeout(time.Duration(s.config.Server.HandlerTimeout)*time.Second, s.isStreamRoute))
+}
+
+// streamRoute 注册流式路由：不受处理超时限制，按空闲时间断开，关闭时等待其排空
+func (s *Server) streamRoute(group *gin.RouterGroup, method, relativePath string, handler gin.HandlerFunc) {
+	s.streamRoutes[path.Join(group.BasePath(), relativePath)] = true
+	group.Handle(method, relativePath, s.streams.Track(), handler)
+}
+
+// isStreamRoute 判断路由是否为流式路由
+func (s *Server) isStreamRoute(fullPath string) bool {
+	return s.streamRoutes[fullPath]
 }
 
 // setupRoutes 设置路由
 func (s *Server) setupRoutes() {
 	// 健康检查
 	s.router.GET("/health", s.healthCheck)
+	s.router.GET("/ready", s.readinessCheck)
 	s.router.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "pong"})
 	})
@@ -114,12 +168,28 @@ func (s *Server) setupRoutes() {
 		authHandler := handlers.NewAuthHandler()
 		authGroup.POST("/login", authHandler.Login)
 		authGroup.POST("/register", authHandler.Register)
+		authGroup.POST("/verify-email", authHandler.VerifyEmail)
 		authGroup.POST("/refresh", authHandler.RefreshToken)
 	}
 
+	// Webhook接收路由（通过签名校验，无需JWT验证）
+	v1.POST("/webhooks/:id/receive", handlers.NewWebhookHandler(s.pipelineEngine).Receive)
+
+	// 外部系统回调 external_wait 步骤（通过签名校验，无需JWT验证）
+	externalWaitHandler := handlers.NewExternalWaitHandler(s.pipelineEngine)
+	v1.POST("/callbacks/:token", externalWaitHandler.Callback)
+
+	// 远程执行器批量上报运行日志（通过运行的上报令牌认证，无需JWT验证）
+	v1.POST("/internal/runs/:runId/logs", handlers.NewLogIngestHandler(s.pipelineEngine).Ingest)
+
+	// 运行结果订阅（阅读器无法携带认证头，通过查询参数中的订阅令牌认证）
+	feedHandler := handlers.NewFeedHandler()
+	v1.GET("/projects/:id/runs.atom", feedHandler.ProjectRuns)
+	v1.GET("/pipelines/:id/runs.atom", feedHandler.PipelineRuns)
+
 	// 需要JWT验证的路由
 	protected := v1.Group("")
-	protected.Use(middleware.JWTAuth())
+	protected.Use(middleware.Auth(s.config))
 
 	// 用户管理路由
 	userGroup := protected.Group("/users")
@@ -132,29 +202,137 @@ func (s *Server) setupRoutes() {
 		userGroup.GET("/profile", userHandler.GetProfile)
 		userGroup.PUT("/profile", userHandler.UpdateProfile)
 		userGroup.PUT("/password", userHandler.ChangePassword)
+
+		// 当前用户的订阅令牌
+		userGroup.GET("/feed-token", feedHandler.GetFeedToken)
+		userGroup.POST("/feed-token", feedHandler.CreateFeedToken)
+		userGroup.DELETE("/feed-token", feedHandler.RevokeFeedToken)
 	}
 
 	// 项目管理路由
 	projectGroup := protected.Group("/projects")
 	{
-		projectHandler := handlers.NewProjectHandler()
+		projectHandler := handlers.NewProjectHandler(s.gitManager)
 		projectGroup.GET("", projectHandler.GetProjects)
+		projectGroup.GET("/stats", projectHandler.Stats)
+		projectGroup.GET("/by-slug/:slug", projectHandler.GetBySlug)
 		projectGroup.POST("", projectHandler.CreateProject)
 		projectGroup.GET("/:id", projectHandler.GetProject)
 		projectGroup.PUT("/:id", projectHandler.UpdateProject)
 		projectGroup.DELETE("/:id", projectHandler.DeleteProject)
+		projectGroup.POST("/:id/archive", projectHandler.Archive)
+		projectGroup.POST("/:id/unarchive", projectHandler.Unarchive)
+		projectGroup.POST("/:id/refresh-default-branch", projectHandler.RefreshDefaultBranch)
+		projectGroup.POST("/:id/accept-repo-move", projectHandler.AcceptRepoMove)
+		projectGroup.GET("/:id/readme", projectHandler.GetReadme)
+
+		// 项目动态：运行、部署与配置变更的时间线
+		projectGroup.GET("/:id/timeline", projectHandler.GetTimeline)
+
+		// 项目默认策略，未覆盖的流水线继承
+		projectGroup.GET("/:id/policy", projectHandler.GetProjectPolicy)
+		projectGroup.PUT("/:id/policy", projectHandler.UpdateProjectPolicy)
 		
 		// 项目部署相关
 		projectGroup.POST("/:id/deploy", projectHandler.DeployProject)
-		projectGroup.GET("/:id/deployments", projectHandler.GetDeployments)
+		// 部署与发布记录，可导出 CSV、NDJSON，导出不受处理超时限制
+		s.streamRoute(projectGroup, http.MethodGet, "/:id/deployments", projectHandler.GetDeployments)
+		s.streamRoute(projectGroup, http.MethodGet, "/:id/releases", projectHandler.GetReleases)
 		projectGroup.GET("/:id/deployments/:deployment_id", projectHandler.GetDeployment)
 		projectGroup.DELETE("/:id/deployments/:deployment_id", projectHandler.DeleteDeployment)
+
+		// 部署目标漂移
+		driftHandler := handlers.NewDriftHandler(s.driftChecker)
+		projectGroup.GET("/:id/drift", driftHandler.GetDrift)
+		projectGroup.POST("/:id/drift/check", driftHandler.CheckDrift)
+
+		// 部署目标部署前检查
+		targetHandler := handlers.NewTargetHandler(s.preflighter)
+		projectGroup.POST("/:id/targets/:target_id/preflight", targetHandler.Preflight)
+
+		// 项目日志脱敏规则
+		redactionHandler := handlers.NewRedactionHandler(s.pipelineEngine)
+		projectGroup.GET("/:id/redaction-rules", redactionHandler.GetProjectRules)
+		projectGroup.POST("/:id/redaction-rules", redactionHandler.CreateProjectRule)
+		projectGroup.PUT("/:id/redaction-rules/:rule_id", redactionHandler.UpdateProjectRule)
+		projectGroup.DELETE("/:id/redaction-rules/:rule_id", redactionHandler.DeleteProjectRule)
+
+		// 项目出站例外，只有管理员可以添加与删除
+		outboundHandler := handlers.NewOutboundHandler()
+		projectGroup.GET("/:id/outbound-exceptions", outboundHandler.GetExceptions)
+		projectGroup.POST("/:id/outbound-exceptions", outboundHandler.CreateException)
+		projectGroup.DELETE("/:id/outbound-exceptions/:exception_id", outboundHandler.DeleteException)
+
+		// 项目部署扮演的云平台角色，只有管理员可以配置
+		projectGroup.GET("/:id/cloud-credentials", projectHandler.GetCloudCredentials)
+		projectGroup.POST("/:id/cloud-credentials", projectHandler.CreateCloudCredential)
+		projectGroup.PUT("/:id/cloud-credentials/:credential_id", projectHandler.UpdateCloudCredential)
+		projectGroup.DELETE("/:id/cloud-credentials/:credential_id", projectHandler.DeleteCloudCredential)
+
+		// 项目并发策略：并发运行数上限与互斥组
+		concurrencyHandler := handlers.NewConcurrencyHandler(s.pipelineEngine)
+		projectGroup.GET("/:id/concurrency", concurrencyHandler.GetPolicy)
+		projectGroup.PUT("/:id/concurrency", concurrencyHandler.UpdatePolicy)
+
+		// 项目运行日志存储占用与配额，只有管理员可以修改配额
+		logStorageHandler := handlers.NewLogStorageHandler(s.pipelineEngine)
+		projectGroup.GET("/:id/log-storage", logStorageHandler.GetUsage)
+		projectGroup.PUT("/:id/log-storage", logStorageHandler.UpdateQuota)
+
+		// 加入项目申请与项目成员：任何登录用户可以申请，所有者与 maintainer 成员审批，所有者设置自动批准规则
+		accessRequestHandler := handlers.NewAccessRequestHandler()
+		projectGroup.POST("/:id/access-requests", accessRequestHandler.Create)
+		projectGroup.GET("/:id/access-requests", accessRequestHandler.List)
+		projectGroup.POST("/:id/access-requests/:requestId/approve", accessRequestHandler.Approve)
+		projectGroup.POST("/:id/access-requests/:requestId/deny", accessRequestHandler.Deny)
+		projectGroup.PUT("/:id/access-rules", accessRequestHandler.UpdateAutoApprove)
+		projectGroup.GET("/:id/members", accessRequestHandler.GetMembers)
+		projectGroup.DELETE("/:id/members/:userId", accessRequestHandler.RemoveMember)
+		protected.GET("/access-requests/mine", accessRequestHandler.Mine)
+
+		// 权限排查：说明当前用户对流水线、运行记录或项目的各项操作为何允许或被拒绝
+		accessDebugHandler := handlers.NewAccessDebugHandler(s.pipelineEngine)
+		protected.GET("/debug/access", accessDebugHandler.Explain)
+
+		// 运行标签策略：允许的标签与按标签保留规则
+		runLabelHandler := handlers.NewRunLabelHandler()
+		projectGroup.GET("/:id/run-labels", runLabelHandler.GetPolicy)
+		projectGroup.PUT("/:id/run-labels", runLabelHandler.UpdatePolicy)
+
+		// 跨项目制品授权：允许其他项目的流水线通过 artifact_from 使用本项目的制品
+		artifactShareHandler := handlers.NewArtifactShareHandler()
+		projectGroup.GET("/:id/artifact-shares", artifactShareHandler.GetShares)
+		projectGroup.POST("/:id/artifact-shares", artifactShareHandler.CreateShare)
+		projectGroup.DELETE("/:id/artifact-shares/:share_id", artifactShareHandler.DeleteShare)
+
+		// 源码包项目：上传源码包触发运行
+		sourceArchiveHandler := handlers.NewSourceArchiveHandler(s.pipelineEngine)
+		s.streamRoute(projectGroup, http.MethodPost, "/:id/runs/upload-source", sourceArchiveHandler.UploadSource)
 		
 		// 项目环境变量
 		projectGroup.GET("/:id/environments", projectHandler.GetEnvironments)
 		projectGroup.POST("/:id/environments", projectHandler.CreateEnvironment)
 		projectGroup.PUT("/:id/environments/:env_id", projectHandler.UpdateEnvironment)
 		projectGroup.DELETE("/:id/environments/:env_id", projectHandler.DeleteEnvironment)
+		projectGroup.DELETE("/:id/environments", projectHandler.DeleteEnvironments)
+
+		// 项目部署密钥
+		deployKeyHandler := handlers.NewDeployKeyHandler(s.sshManager, s.gitManager)
+		projectGroup.GET("/:id/deploy-key", deployKeyHandler.GetDeployKey)
+		projectGroup.POST("/:id/deploy-key", deployKeyHandler.CreateDeployKey)
+		projectGroup.POST("/:id/deploy-key/rotate", deployKeyHandler.RotateDeployKey)
+		projectGroup.POST("/:id/deploy-key/confirm", deployKeyHandler.ConfirmDeployKey)
+		projectGroup.DELETE("/:id/deploy-key", deployKeyHandler.RevokeDeployKey)
+
+		// 项目Webhook
+		webhookHandler := handlers.NewWebhookHandler(s.pipelineEngine)
+		projectGroup.GET("/:id/webhooks", webhookHandler.GetWebhooks)
+		projectGroup.POST("/:id/webhooks", webhookHandler.CreateWebhook)
+		projectGroup.DELETE("/:id/webhooks/:webhook_id", webhookHandler.DeleteWebhook)
+		projectGroup.POST("/:id/webhooks/:webhook_id/rotate-secret", webhookHandler.RotateSecret)
+		projectGroup.GET("/:id/webhooks/:webhook_id/deliveries", webhookHandler.GetDeliveries)
+		projectGroup.PUT("/:id/webhooks/:webhook_id/access", webhookHandler.UpdateAccess)
+		projectGroup.POST("/:id/triggers/simulate", webhookHandler.SimulateTrigger)
 	}
 
 	// SSH密钥管理路由
@@ -167,6 +345,8 @@ func (s *Server) setupRoutes() {
 		sshGroup.PUT("/:id", sshHandler.UpdateSSHKey)
 		sshGroup.DELETE("/:id", sshHandler.DeleteSSHKey)
 		sshGroup.POST("/:id/test", sshHandler.TestSSHConnection)
+		sshGroup.GET("/:id/references", sshHandler.GetSSHKeyReferences)
+		sshGroup.POST("/:id/replace", sshHandler.ReplaceSSHKey)
 	}
 
 	// 流水线管理路由
@@ -178,13 +358,76 @@ func (s *Server) setupRoutes() {
 		pipelineGroup.GET("/:id", pipelineHandler.GetPipeline)
 		pipelineGroup.PUT("/:id", pipelineHandler.UpdatePipeline)
 		pipelineGroup.DELETE("/:id", pipelineHandler.DeletePipeline)
+		pipelineGroup.GET("/:id/revisions", pipelineHandler.GetRevisions)
+		pipelineGroup.GET("/:id/revisions/:rev", pipelineHandler.GetRevision)
+		pipelineGroup.GET("/:id/revisions/:rev/diff", pipelineHandler.GetRevisionDiff)
+
+		// 已注册的步骤类型，包括通过构建标签或插件注册的类型
+		protected.GET("/step-types", pipelineHandler.GetStepTypes)
 		
 		// 流水线执行
 		pipelineGroup.POST("/:id/run", pipelineHandler.RunPipeline)
-		pipelineGroup.GET("/:id/runs", pipelineHandler.GetPipelineRuns)
+		// 运行记录，可导出 CSV、NDJSON
+		s.streamRoute(pipelineGroup, http.MethodGet, "/:id/runs", pipelineHandler.GetPipelineRuns)
 		pipelineGroup.GET("/:id/runs/:runId", pipelineHandler.GetPipelineRun)
 		pipelineGroup.POST("/:id/runs/:runId/cancel", pipelineHandler.CancelPipelineRun)
-		pipelineGroup.GET("/:id/runs/:runId/logs", pipelineHandler.GetPipelineRunLogs)
+		pipelineGroup.POST("/:id/runs/cancel-all", pipelineHandler.CancelAllRuns)
+		s.streamRoute(pipelineGroup, http.MethodGet, "/:id/runs/:runId/logs", pipelineHandler.GetPipelineRunLogs)
+		s.streamRoute(pipelineGroup, http.MethodGet, "/:id/runs/:runId/progress", pipelineHandler.StreamRunProgress)
+		pipelineGroup.GET("/:id/runs/:runId/resolved-config", pipelineHandler.GetResolvedConfig)
+		pipelineGroup.GET("/:id/runs/:runId/inputs", pipelineHandler.GetRunInputs)
+		pipelineGroup.GET("/:id/runs/:runId/steps/:stepId", pipelineHandler.GetPipelineStep)
+		pipelineGroup.POST("/:id/runs/:runId/rerun-failed", pipelineHandler.RerunFailedSteps)
+		pipelineGroup.POST("/:id/runs/:runId/retry", pipelineHandler.RetryPipelineRun)
+
+		// 已结束运行保留的工作区只读浏览
+		workspaceHandler := handlers.NewWorkspaceHandler()
+		pipelineGroup.GET("/:id/runs/:runId/workspace", workspaceHandler.ListDirectory)
+		s.streamRoute(pipelineGroup, http.MethodGet, "/:id/runs/:runId/workspace/file", workspaceHandler.GetFile)
+
+		pipelineGroup.GET("/:id/test-insights", pipelineHandler.GetTestInsights)
+		pipelineGroup.GET("/:id/run-stats", pipelineHandler.GetRunStats)
+
+		// 步骤耗时基线与性能回退阈值
+		pipelineGroup.GET("/:id/duration-insights", pipelineHandler.GetDurationInsights)
+		pipelineGroup.GET("/:id/performance-thresholds", pipelineHandler.GetPerformanceThresholds)
+		pipelineGroup.PUT("/:id/performance-thresholds", pipelineHandler.UpdatePerformanceThresholds)
+
+		// 流水线对项目默认策略的覆盖
+		pipelineGroup.GET("/:id/policy", pipelineHandler.GetPipelinePolicy)
+		pipelineGroup.PUT("/:id/policy", pipelineHandler.UpdatePipelinePolicy)
+
+		// 运行标签，项目所有者与管理员可以添加与删除
+		runLabelHandler := handlers.NewRunLabelHandler()
+		pipelineGroup.POST("/:id/runs/:runId/labels", runLabelHandler.AddLabels)
+		pipelineGroup.DELETE("/:id/runs/:runId/labels/:label", runLabelHandler.RemoveLabel)
+
+		// 运行制品
+		artifactHandler := handlers.NewArtifactHandler(s.artifactStore)
+		pipelineGroup.GET("/:id/runs/:runId/artifacts", artifactHandler.GetArtifacts)
+		s.streamRoute(pipelineGroup, http.MethodPost, "/:id/runs/:runId/artifacts", artifactHandler.UploadArtifact)
+		s.streamRoute(pipelineGroup, http.MethodGet, "/:id/runs/:runId/artifacts/:artifactId", artifactHandler.DownloadArtifact)
+		pipelineGroup.HEAD("/:id/runs/:runId/artifacts/:artifactId", artifactHandler.HeadArtifact)
+
+		// 运行关注
+		notificationHandler := handlers.NewNotificationHandler()
+		pipelineGroup.POST("/:id/watch", notificationHandler.WatchPipeline)
+		pipelineGroup.DELETE("/:id/watch", notificationHandler.UnwatchPipeline)
+		pipelineGroup.POST("/:id/runs/:runId/watch", notificationHandler.WatchRun)
+		pipelineGroup.DELETE("/:id/runs/:runId/watch", notificationHandler.UnwatchRun)
+	}
+
+	// 共享脚本、环境变量组与流水线模板路由
+	sharedGroup := protected.Group("/shared-resources")
+	{
+		sharedHandler := handlers.NewSharedResourceHandler()
+		sharedGroup.GET("", sharedHandler.GetResources)
+		sharedGroup.POST("", sharedHandler.CreateResource)
+		sharedGroup.GET("/:id", sharedHandler.GetResource)
+		sharedGroup.PUT("/:id", sharedHandler.UpdateResource)
+		sharedGroup.GET("/:id/runs", sharedHandler.GetResourceRuns)
+		sharedGroup.GET("/:id/versions/:version", sharedHandler.GetVersion)
+		sharedGroup.DELETE("/:id/versions/:version", sharedHandler.DeleteVersion)
 	}
 
 	// 文件上传路由
@@ -195,12 +438,148 @@ func (s *Server) setupRoutes() {
 		uploadGroup.POST("/file", uploadHandler.UploadFile)
 	}
 
-	// WebSocket路由（实时日志）
-	wsGroup := protected.Group("/ws")
+	// 系统管理路由
+	adminGroup := protected.Group("/admin")
+	{
+		pipelineHandler := handlers.NewPipelineHandler(s.pipelineEngine)
+		adminGroup.GET("/jobs", pipelineHandler.GetInMemoryJobs)
+		adminGroup.GET("/disk-usage", pipelineHandler.GetDiskUsage)
+		adminGroup.GET("/schedules", pipelineHandler.GetSchedules)
+
+		// 运行等待队列与部署锁等待队列
+		queueHandler := handlers.NewQueueHandler(s.pipelineEngine)
+		adminGroup.GET("/queue", queueHandler.GetQueue)
+		adminGroup.POST("/queue/:runId/promote", queueHandler.PromoteRun)
+		adminGroup.DELETE("/queue/:runId", queueHandler.DropRun)
+		adminGroup.POST("/deploy-queue/:waitId/promote", queueHandler.PromoteDeployWait)
+		adminGroup.DELETE("/deploy-queue/:waitId", queueHandler.DropDeployWait)
+
+		// 独立执行器的心跳、名额与等待领取的运行
+		workerHandler := handlers.NewWorkerHandler(s.pipelineEngine)
+		adminGroup.GET("/workers", workerHandler.GetWorkers)
+
+		supportHandler := handlers.NewSupportHandler(s.supportBundle)
+		adminGroup.POST("/support-bundle", supportHandler.CreateBundle)
+		adminGroup.GET("/support-bundle/:id", supportHandler.GetBundle)
+		s.streamRoute(adminGroup, http.MethodGet, "/support-bundle/:id/download", supportHandler.DownloadBundle)
+
+		complianceHandler := handlers.NewComplianceHandler(s.complianceJobs)
+		adminGroup.POST("/users/:id/data-export", complianceHandler.ExportUser)
+		adminGroup.POST("/users/:id/data-deletion", complianceHandler.DeleteUserData)
+		adminGroup.GET("/compliance-jobs/:id", complianceHandler.GetJob)
+		s.streamRoute(adminGroup, http.MethodGet, "/compliance-jobs/:id/download", complianceHandler.DownloadExport)
+
+		// 数据库备份：手动备份与下载备份都记录审计日志
+		backupHandler := handlers.NewBackupHandler(s.backups)
+		adminGroup.POST("/backup", backupHandler.CreateBackup)
+		adminGroup.GET("/backups", backupHandler.GetBackups)
+		s.streamRoute(adminGroup, http.MethodGet, "/backups/:id/download", backupHandler.DownloadBackup)
+
+		// 部署冻结，供故障处理机器人通过API令牌调用
+		freezeHandler := handlers.NewFreezeHandler(s.freezeManager)
+		adminGroup.GET("/freeze", freezeHandler.GetFreezes)
+		adminGroup.POST("/freeze", freezeHandler.CreateFreeze)
+		adminGroup.DELETE("/freeze/:id", freezeHandler.LiftFreeze)
+
+		apiTokenHandler := handlers.NewAPITokenHandler()
+		adminGroup.GET("/api-tokens", apiTokenHandler.GetAPITokens)
+		adminGroup.POST("/api-tokens", apiTokenHandler.CreateAPIToken)
+		adminGroup.PUT("/api-tokens/:id", apiTokenHandler.UpdateAPIToken)
+		adminGroup.DELETE("/api-tokens/:id", apiTokenHandler.RevokeAPIToken)
+
+		// 注册策略与注册邀请
+		registrationHandler := handlers.NewRegistrationHandler()
+		adminGroup.GET("/registration", registrationHandler.GetPolicy)
+		adminGroup.PUT("/registration", registrationHandler.UpdatePolicy)
+		adminGroup.GET("/invites", registrationHandler.GetInvites)
+		adminGroup.POST("/invites", registrationHandler.CreateInvite)
+		adminGroup.DELETE("/invites/:id", registrationHandler.RevokeInvite)
+
+		// 全局日志脱敏规则与已有运行日志的重新脱敏
+		redactionHandler := handlers.NewRedactionHandler(s.pipelineEngine)
+		adminGroup.GET("/redaction-rules", redactionHandler.GetGlobalRules)
+		adminGroup.POST("/redaction-rules", redactionHandler.CreateGlobalRule)
+		adminGroup.PUT("/redaction-rules/:rule_id", redactionHandler.UpdateGlobalRule)
+		adminGroup.DELETE("/redaction-rules/:rule_id", redactionHandler.DeleteGlobalRule)
+		adminGroup.POST("/pipeline-runs/:runId/redact", redactionHandler.RedactRun)
+
+		// 手动完成卡住的外部等待
+		adminGroup.GET("/external-waits", externalWaitHandler.GetExternalWaits)
+		adminGroup.POST("/external-waits/:id/complete", externalWaitHandler.CompleteExternalWait)
+
+		// 功能开关
+		featureFlagHandler := handlers.NewFeatureFlagHandler()
+		adminGroup.GET("/feature-flags", featureFlagHandler.GetFeatureFlags)
+		adminGroup.PUT("/feature-flags/:name", featureFlagHandler.SetFeatureFlag)
+
+		// 审计日志及变更前后差异
+		auditHandler := handlers.NewAuditHandler()
+		// 审计日志，可导出 CSV、NDJSON
+		s.streamRoute(adminGroup, http.MethodGet, "/audit-logs", auditHandler.GetAuditLogs)
+		adminGroup.GET("/audit-logs/:id/diff", auditHandler.GetAuditLogDiff)
+
+		// 系统事件接收端的投递状态与重放
+		eventHandler := handlers.NewEventHandler(s.events)
+		adminGroup.GET("/event-sinks", eventHandler.GetSinks)
+		adminGroup.POST("/event-sinks/replay", eventHandler.Replay)
+
+		// 基础设施请求的熔断状态与重置
+		circuitBreakerHandler := handlers.NewCircuitBreakerHandler()
+		adminGroup.GET("/circuit-breakers", circuitBreakerHandler.GetBreakers)
+		adminGroup.POST("/circuit-breakers/reset", circuitBreakerHandler.ResetBreakers)
+
+		// 保留策略模拟与立即清理：扫描全部运行，不受处理超时限制
+		retentionHandler := handlers.NewRetentionHandler(s.config, s.artifactStore, s.pipelineEngine.LogArchive())
+		s.streamRoute(adminGroup, http.MethodPost, "/retention/simulate", retentionHandler.Simulate)
+		s.streamRoute(adminGroup, http.MethodPost, "/retention/prune", retentionHandler.Prune)
+
+		// 托管平台Webhook来源地址段的状态与刷新
+		providerRangeHandler := handlers.NewProviderRangeHandler()
+		adminGroup.GET("/provider-ranges", providerRangeHandler.GetRanges)
+		adminGroup.POST("/provider-ranges/refresh", providerRangeHandler.RefreshRanges)
+
+		// 进程内缓存的命中统计与清空
+		cacheHandler := handlers.NewCacheHandler()
+		adminGroup.GET("/caches", cacheHandler.GetCaches)
+		adminGroup.POST("/caches/flush", cacheHandler.FlushCaches)
+	}
+
+	// 站内通知路由
+	notificationGroup := protected.Group("/notifications")
+	{
+		notificationHandler := handlers.NewNotificationHandler()
+		notificationGroup.GET("", notificationHandler.GetNotifications)
+		notificationGroup.POST("/:id/read", notificationHandler.MarkRead)
+		notificationGroup.POST("/read-all", notificationHandler.MarkAllRead)
+		notificationGroup.GET("/preferences", notificationHandler.GetPreferences)
+		notificationGroup.PUT("/preferences", notificationHandler.UpdatePreferences)
+	}
+
+	// 变更订阅：界面轮询一个接口，只重新获取有变更的资源，支持长轮询
+	changeHandler := handlers.NewChangeHandler(&s.config.Server.Changes)
+	s.streamRoute(protected, http.MethodGet, "/changes", changeHandler.List)
+
+	// 流水线配置建议性检查的规则说明，界面据此链接检查结果
+	lintHandler := handlers.NewLintHandler()
+	protected.GET("/lint/rules", lintHandler.GetRules)
+
+	// 运行日志全文检索（deploy.log_search 开启时可用）
+	logSearchHandler := handlers.NewLogSearchHandler()
+	protected.GET("/search/logs", logSearchHandler.Search)
+
+	// WebSocket路由（实时日志）：浏览器无法设置认证头，先通过 /ws/ticket 换取一次性票据，
+	// 连接时在升级前校验票据、子协议中的令牌或认证头
+	wsTickets := middleware.NewWebSocketTickets(time.Duration(s.config.Server.WebSocket.TicketTTL) * time.Second)
+	wsHandler := handlers.NewWebSocketHandler(&s.config.Server.WebSocket, wsTickets)
+	debugSessionHandler := handlers.NewDebugSessionHandler(s.pipelineEngine, wsHandler)
+	protected.POST("/ws/ticket", wsHandler.IssueTicket)
+	wsGroup := v1.Group("/ws")
+	wsGroup.Use(middleware.WebSocketAuth(s.config, wsTickets))
 	{
-		wsHandler := handlers.NewWebSocketHandler()
-		wsGroup.GET("/logs/:deployment_id", wsHandler.HandleDeploymentLogs)
-		wsGroup.GET("/pipeline/:run_id", wsHandler.HandlePipelineLogs)
+		s.streamRoute(wsGroup, http.MethodGet, "/logs/:deployment_id", wsHandler.HandleDeploymentLogs)
+		s.streamRoute(wsGroup, http.MethodGet, "/pipeline/:run_id", wsHandler.HandlePipelineLogs)
+		// 失败运行的调试终端，只有项目所有者可以打开
+		s.streamRoute(wsGroup, http.MethodGet, "/pipeline/:run_id/debug", debugSessionHandler.HandleDebugSession)
 	}
 }
 
@@ -225,12 +604,55 @@ func (s *Server) healthCheck(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "healthy",
-		"timestamp": time.Now().Unix(),
+		"timestamp": utils.FormatTime(time.Now()),
 		"version":   "1.0.0",
 		"database":  dbStats,
 	})
 }
 
+// readinessCheck 就绪检查处理器：数据库可用、引擎正常且工作区磁盘空间不低于安全余量；
+// 同时报告启动时探测到的本机解释器，不影响就绪状态
+func (s *Server) readinessCheck(c *gin.Context) {
+	usage, err := s.Ready()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "not_ready",
+			"error":  err.Error(),
+			"disk":   usage,
+			"shells": s.scriptManager.Shells(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ready",
+		"timestamp": utils.FormatTime(time.Now()),
+		"disk":      usage,
+		"shells":    s.scriptManager.Shells(),
+	})
+}
+
+// Ready 执行就绪检查，就绪接口与 systemd 看门狗共用
+func (s *Server) Ready() (*utils.DiskUsage, error) {
+	if err := database.HealthCheck(); err != nil {
+		return nil, fmt.Errorf("database connection failed: %w", err)
+	}
+
+	if err := s.pipelineEngine.HealthCheck(); err != nil {
+		return nil, fmt.Errorf("pipeline engine unhealthy: %w", err)
+	}
+
+	usage, err := s.pipelineEngine.WorkspaceDiskUsage()
+	if err != nil {
+		return nil, fmt.Errorf("disk check failed: %w", err)
+	}
+	if usage.Free < s.pipelineEngine.DiskSafetyMargin() {
+		return usage, fmt.Errorf("insufficient disk space")
+	}
+
+	return usage, nil
+}
+
 // Start 启动服务器
 func (s *Server) Start() error {
 	// 设置中间件
@@ -240,27 +662,76 @@ func (s *Server) Start() error {
 	s.setupRoutes()
 
 	// 启动服务器
-	log.Printf("服务器启动在 %s", s.httpServer.Addr)
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+	defer closeListeners(listeners)
 
-	// 如果启用了TLS
-	if s.config.Server.TLS.Enabled {
-		if s.config.Server.TLS.CertFile == "" || s.config.Server.TLS.KeyFile == "" {
-			return fmt.Errorf("TLS已启用但证书文件未配置")
-		}
-		return s.httpServer.ListenAndServeTLS(s.config.Server.TLS.CertFile, s.config.Server.TLS.KeyFile)
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener *serverListener) {
+			errs <- s.serve(listener)
+		}(listener)
+	}
+	return <-errs
+}
+
+// listen 打开配置的全部监听，TLS 已启用时先确认证书已配置
+func (s *Server) listen() ([]*serverListener, error) {
+	if s.config.Server.TLS.Enabled && (s.config.Server.TLS.CertFile == "" || s.config.Server.TLS.KeyFile == "") {
+		return nil, fmt.Errorf("TLS已启用但证书文件未配置")
 	}
+	return openListeners(s.config.Server.ListenTargets())
+}
+
+// serve 在监听上提供服务直到服务器关闭；TLS 只用于 TCP 监听，unix 套接字由本机反向代理访问
+func (s *Server) serve(listener *serverListener) error {
+	log.Printf("服务器启动在 %s", listener.describe())
 
-	return s.httpServer.ListenAndServe()
+	var err error
+	if s.config.Server.TLS.Enabled && listener.network != "unix" {
+		err = s.httpServer.ServeTLS(listener, s.config.Server.TLS.CertFile, s.config.Server.TLS.KeyFile)
+	} else {
+		err = s.httpServer.Serve(listener)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
 
 // Stop 停止服务器
 func (s *Server) Stop(ctx context.Context) error {
 	log.Println("正在关闭服务器...")
-	return s.httpServer.Shutdown(ctx)
+	return s.shutdown(ctx)
+}
+
+// shutdown 停止接受新连接并等待进行中的请求；流式连接在 ctx 结束前没有自然结束时收到关闭通知，
+// 仍未结束的连接被强制关闭
+func (s *Server) shutdown(ctx context.Context) error {
+	drained := make(chan int, 1)
+	go func() {
+		drained <- s.streams.Drain(ctx, 5*time.Second)
+	}()
+
+	err := s.httpServer.Shutdown(ctx)
+	if closed := <-drained; closed > 0 {
+		log.Printf("已通知 %d 个流式连接关闭", closed)
+	}
+	if err != nil {
+		s.httpServer.Close()
+	}
+	return err
 }
 
 // Run 运行服务器（带优雅关闭）
 func (s *Server) Run() error {
+	return s.RunUntil(nil)
+}
+
+// RunUntil 运行服务器，收到中断信号或 stop 关闭时优雅关闭（stop 用于Windows服务停止请求）
+func (s *Server) RunUntil(stop <-chan struct{}) error {
 	// 设置中间件和路由
 	s.setupMiddleware()
 	s.setupRoutes()
@@ -269,35 +740,47 @@ func (s *Server) Run() error {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	// 先打开全部监听，确认监听成功后再通知就绪；所有监听共用同一路由，健康检查在每个监听上都可访问
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+	defer closeListeners(listeners)
+
 	// 在goroutine中启动服务器
-	go func() {
-		log.Printf("服务器启动在 %s", s.httpServer.Addr)
-		
-		var err error
-		if s.config.Server.TLS.Enabled {
-			if s.config.Server.TLS.CertFile == "" || s.config.Server.TLS.KeyFile == "" {
-				log.Fatal("TLS已启用但证书文件未配置")
+	for _, listener := range listeners {
+		go func(listener *serverListener) {
+			if err := s.serve(listener); err != nil {
+				log.Fatalf("服务器启动失败: %v", err)
 			}
-			err = s.httpServer.ListenAndServeTLS(s.config.Server.TLS.CertFile, s.config.Server.TLS.KeyFile)
-		} else {
-			err = s.httpServer.ListenAndServe()
-		}
-
-		if err != nil && err != http.ErrServerClosed {
-			log.Fatalf("服务器启动失败: %v", err)
-		}
-	}()
+		}(listener)
+	}
 
-	// 等待中断信号
-	<-quit
-	log.Println("收到关闭信号...")
+	// 通知 systemd 服务已就绪并启动看门狗心跳
+	if _, err := service.Notify(service.NotifyReady); err != nil {
+		log.Printf("发送 systemd 就绪通知失败: %v", err)
+	}
+	watchdogDone := make(chan struct{})
+	go s.watchdog(watchdogDone)
+
+	// 等待中断信号或服务停止请求
+	select {
+	case <-quit:
+		log.Println("收到关闭信号...")
+	case <-stop:
+		log.Println("收到服务停止请求...")
+	}
+	close(watchdogDone)
+	service.Notify(service.NotifyStopping)
 
 	// 创建一个超时上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.Server.DrainTimeout)*time.Second)
 	defer cancel()
 
-	// 优雅关闭服务器
-	if err := s.httpServer.Shutdown(ctx); err != nil {
+	// 优雅关闭服务器，并写入引擎缓冲中的日志
+	err = s.shutdown(ctx)
+	s.pipelineEngine.Shutdown()
+	if err != nil {
 		log.Printf("服务器强制关闭: %v", err)
 		return err
 	}
@@ -306,6 +789,32 @@ func (s *Server) Run() error {
 	return nil
 }
 
+// watchdog 按 systemd 看门狗间隔的一半发送心跳，就绪检查失败时停止心跳以便 systemd 重启实例
+func (s *Server) watchdog(done <-chan struct{}) {
+	interval := service.WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if _, err := s.Ready(); err != nil {
+				log.Printf("就绪检查失败，跳过看门狗心跳: %v", err)
+				continue
+			}
+			if _, err := service.Notify(service.NotifyWatchdog); err != nil {
+				log.Printf("发送看门狗心跳失败: %v", err)
+			}
+		}
+	}
+}
+
 // GetRouter 获取Gin路由器（用于测试）
 func (s *Server) GetRouter() *gin.Engine {
 	return s.router
@@ -0,0 +1,271 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 支持的源码包格式
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+// ErrUnsafePath 条目路径试图写到解压目录之外（zip-slip）
+var ErrUnsafePath = errors.New("源码包包含不安全的路径")
+
+// ErrTooLarge 解压后的文件数或总大小超过限制（解压炸弹）
+var ErrTooLarge = errors.New("源码包解压后超过限制")
+
+// ErrUnsupportedFormat 无法识别的源码包格式
+var ErrUnsupportedFormat = errors.New("不支持的源码包格式，仅支持 zip、tar 与 tar.gz")
+
+// Limits 解压限制，0 表示不限制该项
+type Limits struct {
+	MaxFiles      int   // 最多解压的文件数
+	MaxTotalBytes int64 // 解压后的总大小上限
+	MaxRatio      int64 // 解压后大小与源码包大小之比的上限
+}
+
+// Stats 解压结果
+type Stats struct {
+	Files      int   `json:"files"`
+	TotalBytes int64 `json:"total_bytes"`
+}
+
+// DetectFormat 按文件头识别源码包格式，无法从文件头判断时按文件名后缀识别
+func DetectFormat(name string, header []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return FormatTar, nil
+	}
+
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Extract 将源码包解压到 dest。拒绝绝对路径、含 .. 的路径与符号/硬链接，
+// 超过文件数、总大小或压缩比限制时停止并返回 ErrTooLarge；出错时 dest 中可能留有部分文件
+func Extract(src, format, dest string, limits Limits) (*Stats, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("读取源码包失败: %w", err)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("创建解压目录失败: %w", err)
+	}
+
+	x := &extractor{dest: dest, limits: limits, archiveSize: info.Size()}
+	switch format {
+	case FormatZip:
+		err = x.zip(src)
+	case FormatTar, FormatTarGz:
+		err = x.tar(src, format == FormatTarGz)
+	default:
+		err = ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &x.stats, nil
+}
+
+// extractor 一次解压的状态
+type extractor struct {
+	dest        string
+	limits      Limits
+	archiveSize int64
+	stats       Stats
+}
+
+// zip 解压 zip 包
+func (x *extractor) zip(src string) error {
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("打开 zip 源码包失败: %w", err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		mode := file.Mode()
+		if mode&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: 不允许符号链接 %s", ErrUnsafePath, file.Name)
+		}
+		target, err := x.target(file.Name)
+		if err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("创建目录失败: %w", err)
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			return fmt.Errorf("%w: 不支持的条目类型 %s", ErrUnsafePath, file.Name)
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", file.Name, err)
+		}
+		err = x.writeFile(target, rc, mode.Perm())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tar 解压 tar 或 tar.gz 包
+func (x *extractor) tar(src string, gzipped bool) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("打开源码包失败: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("解压 gzip 失败: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 tar 条目失败: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			target, err := x.target(header.Name)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("创建目录失败: %w", err)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			target, err := x.target(header.Name)
+			if err != nil {
+				return err
+			}
+			if err := x.writeFile(target, tr, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+			// pax 扩展头只携带元数据
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("%w: 不允许链接 %s", ErrUnsafePath, header.Name)
+		default:
+			return fmt.Errorf("%w: 不支持的条目类型 %s", ErrUnsafePath, header.Name)
+		}
+	}
+}
+
+// target 条目在解压目录中的路径，不在解压目录内时返回 ErrUnsafePath
+func (x *extractor) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+		}
+	}
+
+	target := filepath.Join(x.dest, filepath.FromSlash(name))
+	rel, err := filepath.Rel(x.dest, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return target, nil
+}
+
+// writeFile 写入一个文件，按实际写入的字节数检查限制，不信任条目头中声明的大小
+func (x *extractor) writeFile(target string, r io.Reader, perm os.FileMode) error {
+	x.stats.Files++
+	if x.limits.MaxFiles > 0 && x.stats.Files > x.limits.MaxFiles {
+		return fmt.Errorf("%w: 文件数超过 %d", ErrTooLarge, x.limits.MaxFiles)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if perm == 0 {
+		perm = 0644
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm|0600)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer out.Close()
+
+	remaining := x.remaining()
+	written, err := io.Copy(out, io.LimitReader(r, remaining+1))
+	x.stats.TotalBytes += written
+	if err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", target, err)
+	}
+	if written > remaining {
+		return fmt.Errorf("%w: 解压后大小超过 %d 字节", ErrTooLarge, x.maxBytes())
+	}
+	return nil
+}
+
+// maxBytes 解压后总大小的上限：总大小限制与压缩比限制中较小者
+func (x *extractor) maxBytes() int64 {
+	max := x.limits.MaxTotalBytes
+	if x.limits.MaxRatio > 0 {
+		byRatio := x.archiveSize * x.limits.MaxRatio
+		if max <= 0 || byRatio < max {
+			max = byRatio
+		}
+	}
+	return max
+}
+
+// remaining 还可以写入的字节数
+func (x *extractor) remaining() int64 {
+	max := x.maxBytes()
+	if max <= 0 {
+		return 1<<63 - 2
+	}
+	if left := max - x.stats.TotalBytes; left > 0 {
+		return left
+	}
+	return 0
+}
@@ -0,0 +1,265 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// entry 构造源码包的一个条目
+type entry struct {
+	name     string
+	body     string
+	size     int // body 为空时写入 size 个零字节
+	symlink  string
+	hardlink string
+	dir      bool
+}
+
+func (e entry) data() []byte {
+	if e.body != "" {
+		return []byte(e.body)
+	}
+	return make([]byte, e.size)
+}
+
+// writeTar 在 dir 下生成 tar 或 tar.gz 源码包
+func writeTar(t *testing.T, dir string, gzipped bool, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var gz *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	}
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644}
+		switch {
+		case e.symlink != "":
+			header.Typeflag, header.Linkname = tar.TypeSymlink, e.symlink
+		case e.hardlink != "":
+			header.Typeflag, header.Linkname = tar.TypeLink, e.hardlink
+		case e.dir:
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		default:
+			header.Typeflag, header.Size = tar.TypeReg, int64(len(e.data()))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write(e.data()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	name := "src.tar"
+	if gzipped {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		name = "src.tar.gz"
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeZip 在 dir 下生成 zip 源码包
+func writeZip(t *testing.T, dir string, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.data()
+		switch {
+		case e.symlink != "":
+			header.SetMode(os.ModeSymlink | 0777)
+			body = []byte(e.symlink)
+		case e.dir:
+			header.Name = strings.TrimSuffix(e.name, "/") + "/"
+			header.SetMode(os.ModeDir | 0755)
+			body = nil
+		default:
+			header.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "src.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// build 按格式生成源码包
+func build(t *testing.T, dir, format string, entries ...entry) string {
+	t.Helper()
+	switch format {
+	case FormatZip:
+		return writeZip(t, dir, entries...)
+	case FormatTar:
+		return writeTar(t, dir, false, entries...)
+	default:
+		return writeTar(t, dir, true, entries...)
+	}
+}
+
+var formats = []string{FormatZip, FormatTar, FormatTarGz}
+
+func TestExtractRejectsUnsafePaths(t *testing.T) {
+	cases := []struct {
+		name    string
+		entries []entry
+	}{
+		{"parent", []entry{{name: "../evil", body: "x"}}},
+		{"nested parent", []entry{{name: "src/../../evil", body: "x"}}},
+		{"backslash parent", []entry{{name: "..\\evil", body: "x"}}},
+		{"absolute", []entry{{name: "/tmp/evil", body: "x"}}},
+		{"parent dir entry", []entry{{name: "../evildir", dir: true}}},
+		{"symlink out", []entry{{name: "link", symlink: "/etc"}, {name: "link/passwd", body: "x"}}},
+		{"symlink relative", []entry{{name: "link", symlink: "../../"}, {name: "link/evil", body: "x"}}},
+	}
+
+	for _, format := range formats {
+		for _, tc := range cases {
+			t.Run(format+"/"+tc.name, func(t *testing.T) {
+				root := t.TempDir()
+				src := build(t, root, format, tc.entries...)
+				dest := filepath.Join(root, "out", "dest")
+
+				_, err := Extract(src, format, dest, Limits{})
+				if !errors.Is(err, ErrUnsafePath) {
+					t.Fatalf("应返回 ErrUnsafePath，实际为 %v", err)
+				}
+				for _, escaped := range []string{filepath.Join(root, "evil"), filepath.Join(root, "out", "evil"), filepath.Join(root, "out", "evildir")} {
+					if _, err := os.Lstat(escaped); err == nil {
+						t.Fatalf("条目被写到解压目录之外: %s", escaped)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestExtractRejectsHardLinks(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		root := t.TempDir()
+		src := writeTar(t, root, gzipped, entry{name: "passwd", hardlink: "/etc/passwd"})
+		format := FormatTar
+		if gzipped {
+			format = FormatTarGz
+		}
+		if _, err := Extract(src, format, filepath.Join(root, "dest"), Limits{}); !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("%s: 硬链接应返回 ErrUnsafePath，实际为 %v", format, err)
+		}
+	}
+}
+
+func TestExtractLimitsExpansionRatio(t *testing.T) {
+	// 8 MiB 的零字节压缩后只有几 KiB
+	for _, format := range []string{FormatZip, FormatTarGz} {
+		root := t.TempDir()
+		src := build(t, root, format, entry{name: "zeros", size: 8 << 20})
+		_, err := Extract(src, format, filepath.Join(root, "dest"), Limits{MaxRatio: 100})
+		if !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("%s: 超过压缩比应返回 ErrTooLarge，实际为 %v", format, err)
+		}
+		info, statErr := os.Stat(filepath.Join(root, "dest", "zeros"))
+		if statErr == nil && info.Size() > 8<<20/2 {
+			t.Fatalf("%s: 超过限制后仍写入了 %d 字节", format, info.Size())
+		}
+	}
+}
+
+func TestExtractLimitsTotalBytes(t *testing.T) {
+	for _, format := range formats {
+		root := t.TempDir()
+		src := build(t, root, format, entry{name: "a", size: 600}, entry{name: "b", size: 600})
+		if _, err := Extract(src, format, filepath.Join(root, "dest"), Limits{MaxTotalBytes: 1000}); !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("%s: 超过总大小应返回 ErrTooLarge，实际为 %v", format, err)
+		}
+	}
+}
+
+func TestExtractLimitsEntryCount(t *testing.T) {
+	entries := make([]entry, 0, 500)
+	for i := 0; i < 500; i++ {
+		entries = append(entries, entry{name: "many/f" + strconv.Itoa(i), body: "x"})
+	}
+	for _, format := range formats {
+		root := t.TempDir()
+		src := build(t, root, format, entries...)
+		_, err := Extract(src, format, filepath.Join(root, "dest"), Limits{MaxFiles: 100})
+		if !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("%s: 超过文件数应返回 ErrTooLarge，实际为 %v", format, err)
+		}
+		written, _ := filepath.Glob(filepath.Join(root, "dest", "many", "*"))
+		if len(written) > 100 {
+			t.Fatalf("%s: 超过限制后仍写入了 %d 个文件", format, len(written))
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	for _, format := range formats {
+		root := t.TempDir()
+		src := build(t, root, format,
+			entry{name: "src", dir: true},
+			entry{name: "src/main.go", body: "package main\n"},
+			entry{name: "./README.md", body: "# readme\n"},
+		)
+		dest := filepath.Join(root, "dest")
+		stats, err := Extract(src, format, dest, Limits{MaxFiles: 10, MaxTotalBytes: 1 << 20, MaxRatio: 100})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if stats.Files != 2 || stats.TotalBytes != int64(len("package main\n")+len("# readme\n")) {
+			t.Fatalf("%s: 统计错误 %+v", format, stats)
+		}
+		if data, err := os.ReadFile(filepath.Join(dest, "src", "main.go")); err != nil || string(data) != "package main\n" {
+			t.Fatalf("%s: 解压内容错误: %q %v", format, data, err)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	root := t.TempDir()
+	for _, format := range formats {
+		src := build(t, root, format, entry{name: "a", body: "x"})
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 文件头优先于文件名
+		if got, err := DetectFormat("upload.bin", data); err != nil || got != format {
+			t.Errorf("%s: DetectFormat = %q, %v", format, got, err)
+		}
+	}
+	if _, err := DetectFormat("notes.txt", []byte("hello")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("无法识别的格式应返回 ErrUnsupportedFormat，实际为 %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/runlabel"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDigestMismatch 上传内容与客户端声明的摘要不一致
+	ErrDigestMismatch = errors.New("制品摘要校验失败")
+	// ErrBlobCorrupted 存储中的制品数据与记录的摘要不一致
+	ErrBlobCorrupted = errors.New("制品数据已损坏")
+	// ErrArtifactInUse 制品仍被带有永久保留标签的运行使用
+	ErrArtifactInUse = errors.New("制品仍被永久保留的运行使用")
+)
+
+// Store 按内容寻址（sha256）存储制品，相同内容只保存一份
+type Store struct {
+	root string
+}
+
+// NewStore 创建制品存储，目前仅支持本地存储
+func NewStore(cfg *config.Config) (*Store, error) {
+	if cfg.Storage.Type != "" && cfg.Storage.Type != "local" {
+		return nil, fmt.Errorf("制品存储暂不支持存储类型: %s", cfg.Storage.Type)
+	}
+
+	root := filepath.Join(cfg.Storage.Local.Path, "artifacts")
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0755); err != nil {
+		return nil, fmt.Errorf("创建制品存储目录失败: %w", err)
+	}
+
+	return &Store{root: root}, nil
+}
+
+// Put 流式写入制品并计算sha256，expectedDigest 非空时校验一致；已存在相同内容时复用并增加引用计数。
+// ctx 取消时中止写入，不保留部分写入的数据
+func (s *Store) Put(ctx context.Context, runID uint, name string, r io.Reader, expectedDigest string) (*models.Artifact, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hasher := sha256.New()
+	size, err := io.Copy(tmp, io.TeeReader(contextReader{ctx: ctx, r: r}, hasher))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("写入制品失败: %w", err)
+	}
+
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	if expectedDigest != "" && expectedDigest != digest {
+		return nil, ErrDigestMismatch
+	}
+
+	artifact := &models.Artifact{
+		Name:          name,
+		Size:          size,
+		Digest:        digest,
+		PipelineRunID: runID,
+	}
+
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blob models.ArtifactBlob
+		err := tx.Where("digest = ?", digest).First(&blob).Error
+		switch {
+		case err == nil:
+			if err := tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count + 1")).Error; err != nil {
+				return err
+			}
+			// 数据文件丢失时用本次上传补齐
+			if _, statErr := os.Stat(s.blobPath(digest)); statErr != nil {
+				if err := s.moveBlob(tmpPath, digest); err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := s.moveBlob(tmpPath, digest); err != nil {
+				return err
+			}
+			blob = models.ArtifactBlob{Digest: digest, Size: size, RefCount: 1}
+			if err := tx.Create(&blob).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		artifact.BlobID = blob.ID
+		return tx.Create(artifact).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存制品失败: %w", err)
+	}
+
+	return artifact, nil
+}
+
+// contextReader 每次读取前检查 ctx，取消后读取返回 ctx 的错误
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Open 打开制品数据，读取前校验内容摘要，数据被篡改或损坏时返回 ErrBlobCorrupted
+func (s *Store) Open(artifact *models.Artifact) (*os.File, error) {
+	file, err := os.Open(s.blobPath(artifact.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("打开制品失败: %w", err)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("读取制品失败: %w", err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != artifact.Digest {
+		file.Close()
+		log.Printf("制品 %d 的数据 %s 校验失败", artifact.ID, artifact.Digest)
+		return nil, ErrBlobCorrupted
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("读取制品失败: %w", err)
+	}
+
+	return file, nil
+}
+
+// CopyTo 将制品复制到 dest，复制时再次计算sha256，与记录的摘要不一致时不保留文件
+func (s *Store) CopyTo(artifact *models.Artifact, dest string) error {
+	src, err := s.Open(artifact)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".artifact-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(tmp, io.TeeReader(src, hasher))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("复制制品失败: %w", err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != artifact.Digest {
+		return ErrBlobCorrupted
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("保存制品失败: %w", err)
+	}
+	return nil
+}
+
+// Protected 被带有永久保留标签的运行使用的制品ID子查询，这些制品不能删除
+func Protected() *gorm.DB {
+	return database.DB.Model(&models.ArtifactConsumption{}).Select("consumed_artifact_id").
+		Where("pipeline_run_id IN (?)", runlabel.Pinned())
+}
+
+// Release 删除制品记录并减少数据引用计数，数据不再被引用时一并删除；仍被永久保留的运行使用时返回 ErrArtifactInUse
+func (s *Store) Release(artifact *models.Artifact) error {
+	var orphan string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var consumers int64
+		if err := tx.Model(&models.ArtifactConsumption{}).
+			Where("consumed_artifact_id = ? AND pipeline_run_id IN (?)", artifact.ID, runlabel.Pinned()).Count(&consumers).Error; err != nil {
+			return err
+		}
+		if consumers > 0 {
+			return ErrArtifactInUse
+		}
+		if err := tx.Delete(artifact).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ArtifactBlob{}).Where("id = ?", artifact.BlobID).
+			Update("ref_count", gorm.Expr("ref_count - 1")).Error; err != nil {
+			return err
+		}
+
+		var blob models.ArtifactBlob
+		if err := tx.First(&blob, artifact.BlobID).Error; err != nil {
+			return err
+		}
+		if blob.RefCount <= 0 {
+			orphan = blob.Digest
+			return tx.Delete(&blob).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("释放制品失败: %w", err)
+	}
+
+	if orphan != "" {
+		if err := os.Remove(s.blobPath(orphan)); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除制品数据 %s 失败: %v", orphan, err)
+		}
+	}
+
+	return nil
+}
+
+// PruneRunsBefore 清理在截止时间前结束的运行的制品，keep 为需要保留的运行ID子查询，为 nil 时不排除
+func (s *Store) PruneRunsBefore(cutoff time.Time, keep *gorm.DB) (int, error) {
+	query := database.DB.Joins("JOIN pipeline_runs ON pipeline_runs.id = artifacts.pipeline_run_id").
+		Where("pipeline_runs.end_time < ?", cutoff)
+	if keep != nil {
+		query = query.Where("artifacts.pipeline_run_id NOT IN (?)", keep)
+	}
+	return s.prune(query)
+}
+
+// PruneRun 清理一次运行的全部制品
+func (s *Store) PruneRun(runID uint) (int, error) {
+	return s.prune(database.DB.Where("pipeline_run_id = ?", runID))
+}
+
+// prune 释放查询到的制品，返回成功释放的数量；仍被永久保留的运行使用的制品保留
+func (s *Store) prune(query *gorm.DB) (int, error) {
+	var artifacts []models.Artifact
+	if err := query.Where("artifacts.id NOT IN (?)", Protected()).Find(&artifacts).Error; err != nil {
+		return 0, fmt.Errorf("查询过期制品失败: %w", err)
+	}
+
+	pruned := 0
+	for i := range artifacts {
+		if err := s.Release(&artifacts[i]); err != nil {
+			log.Printf("清理制品 %d 失败: %v", artifacts[i].ID, err)
+			continue
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// DigestHeader 生成 RFC 3230 的 Digest 响应头值
+func DigestHeader(digest string) string {
+	raw, err := hex.DecodeString(digest)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(raw)
+}
+
+// ParseDigestHeader 解析客户端声明的 Digest 请求头（sha-256=base64），返回十六进制摘要
+func ParseDigestHeader(value string) (string, error) {
+	const prefix = "sha-256="
+	if len(value) <= len(prefix) || value[:len(prefix)] != prefix {
+		return "", fmt.Errorf("仅支持 sha-256 摘要")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(value[len(prefix):])
+	if err != nil || len(raw) != sha256.Size {
+		return "", fmt.Errorf("无效的摘要: %s", value)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// blobPath 数据文件路径，按摘要前两位分目录
+func (s *Store) blobPath(digest string) string {
+	return filepath.Join(s.root, "blobs", digest[:2], digest)
+}
+
+// moveBlob 将临时文件移动到数据文件位置
+func (s *Store) moveBlob(tmpPath, digest string) error {
+	target := s.blobPath(digest)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("创建制品目录失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		return fmt.Errorf("保存制品数据失败: %w", err)
+	}
+	return nil
+}
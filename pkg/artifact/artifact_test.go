@@ -0,0 +1,174 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// setupStore 内存数据库与临时目录中的制品存储
+func setupStore(t *testing.T) *Store {
+	t.Helper()
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{
+			Type:         "sqlite",
+			Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+			MaxIdleConns: 1,
+			MaxOpenConns: 1,
+			LogLevel:     "silent",
+		},
+		Storage: config.StorageConfig{Local: config.LocalConfig{Path: t.TempDir()}},
+	}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func put(t *testing.T, store *Store, runID uint, content, digest string) (*models.Artifact, error) {
+	t.Helper()
+	return store.Put(context.Background(), runID, "app.tar.gz", strings.NewReader(content), digest)
+}
+
+func loadBlob(t *testing.T, id uint) *models.ArtifactBlob {
+	t.Helper()
+	var blob models.ArtifactBlob
+	if err := database.DB.First(&blob, id).Error; err != nil {
+		return nil
+	}
+	return &blob
+}
+
+// TestPutDeduplicates 重复上传相同内容共享一份数据，引用计数归零时才删除数据文件
+func TestPutDeduplicates(t *testing.T) {
+	store := setupStore(t)
+	const content = "release build"
+
+	staging, err := put(t, store, 1, content, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod, err := put(t, store, 2, content, sha256Hex(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staging.BlobID != prod.BlobID || staging.Digest != sha256Hex(content) {
+		t.Fatalf("相同内容应共享数据，实际为 %d 与 %d（摘要 %s）", staging.BlobID, prod.BlobID, staging.Digest)
+	}
+	if blob := loadBlob(t, staging.BlobID); blob.RefCount != 2 {
+		t.Errorf("引用计数 %d，应为 2", blob.RefCount)
+	}
+
+	if err := store.Release(staging); err != nil {
+		t.Fatal(err)
+	}
+	if blob := loadBlob(t, prod.BlobID); blob == nil || blob.RefCount != 1 {
+		t.Fatalf("仍被引用的数据应保留，引用计数为 1，实际为 %+v", blob)
+	}
+	if _, err := os.Stat(store.blobPath(prod.Digest)); err != nil {
+		t.Fatalf("仍被引用的数据文件应保留: %v", err)
+	}
+
+	if err := store.Release(prod); err != nil {
+		t.Fatal(err)
+	}
+	if blob := loadBlob(t, prod.BlobID); blob != nil {
+		t.Errorf("不再被引用的数据记录应删除，实际为 %+v", blob)
+	}
+	if _, err := os.Stat(store.blobPath(prod.Digest)); !os.IsNotExist(err) {
+		t.Errorf("不再被引用的数据文件应删除，实际为 %v", err)
+	}
+}
+
+// TestPutRejectsTamperedUpload 上传内容与声明的摘要不一致时拒绝，不保存记录、数据与临时文件
+func TestPutRejectsTamperedUpload(t *testing.T) {
+	store := setupStore(t)
+
+	if _, err := put(t, store, 1, "tampered build", sha256Hex("release build")); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("应返回 ErrDigestMismatch，实际为 %v", err)
+	}
+	var artifacts, blobs int64
+	database.DB.Model(&models.Artifact{}).Count(&artifacts)
+	database.DB.Model(&models.ArtifactBlob{}).Count(&blobs)
+	if artifacts != 0 || blobs != 0 {
+		t.Errorf("被拒绝的上传不应保存记录，实际有 %d 个制品、%d 份数据", artifacts, blobs)
+	}
+	if leftover, _ := os.ReadDir(filepath.Join(store.root, "tmp")); len(leftover) != 0 {
+		t.Errorf("不应留下临时文件，实际有 %d 个", len(leftover))
+	}
+	if _, err := os.Stat(filepath.Join(store.root, "blobs")); !os.IsNotExist(err) {
+		t.Errorf("被拒绝的上传不应写入数据目录，实际为 %v", err)
+	}
+}
+
+// TestOpenDetectsTamperedBlob 存储中的数据被改动后下载与复制都返回 ErrBlobCorrupted，复制不留下文件
+func TestOpenDetectsTamperedBlob(t *testing.T) {
+	store := setupStore(t)
+	artifact, err := put(t, store, 1, "release build", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := store.Open(artifact)
+	if err != nil {
+		t.Fatalf("未改动的数据应能打开: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "release build" {
+		t.Fatalf("读取内容为 %q", data)
+	}
+
+	if err := os.WriteFile(store.blobPath(artifact.Digest), []byte("release bui1d"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(artifact); !errors.Is(err, ErrBlobCorrupted) {
+		t.Errorf("篡改后打开应返回 ErrBlobCorrupted，实际为 %v", err)
+	}
+	dest := filepath.Join(t.TempDir(), "out", "app.tar.gz")
+	if err := store.CopyTo(artifact, dest); !errors.Is(err, ErrBlobCorrupted) {
+		t.Errorf("篡改后复制应返回 ErrBlobCorrupted，实际为 %v", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("校验失败的复制不应留下文件，实际为 %v", err)
+	}
+}
+
+func TestDigestHeader(t *testing.T) {
+	digest := sha256Hex("release build")
+	header := DigestHeader(digest)
+	parsed, err := ParseDigestHeader(header)
+	if err != nil || parsed != digest {
+		t.Fatalf("Digest 头 %q 解析为 %q, %v，应为 %s", header, parsed, err, digest)
+	}
+
+	for _, value := range []string{"", "md5=abc", "sha-256=", "sha-256=not-base64!", "sha-256=YWJj"} {
+		if _, err := ParseDigestHeader(value); err == nil {
+			t.Errorf("无效的 Digest 头 %q 应返回错误", value)
+		}
+	}
+}
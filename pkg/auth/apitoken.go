@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"flowforge/pkg/utils"
+)
+
+// APITokenPrefix API令牌前缀，用于与JWT区分
+const APITokenPrefix = "ffat_"
+
+// GenerateAPIToken 生成API令牌，返回明文与用于存储的哈希；明文只在创建时返回一次
+func GenerateAPIToken() (string, string) {
+	token := APITokenPrefix + utils.GenerateRandomString(40)
+	return token, HashAPIToken(token)
+}
+
+// HashAPIToken 计算API令牌的存储哈希
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// FeedTokenPrefix 订阅令牌前缀
+const FeedTokenPrefix = "fffd_"
+
+// GenerateFeedToken 生成订阅令牌，返回明文与用于存储的哈希，哈希方式与API令牌相同
+func GenerateFeedToken() (string, string) {
+	token := FeedTokenPrefix + utils.GenerateRandomString(40)
+	return token, HashAPIToken(token)
+}
+
+// InviteTokenPrefix 注册邀请令牌前缀
+const InviteTokenPrefix = "ffiv_"
+
+// GenerateInviteToken 生成注册邀请令牌，返回明文与用于存储的哈希
+func GenerateInviteToken() (string, string) {
+	token := InviteTokenPrefix + utils.GenerateRandomString(40)
+	return token, HashAPIToken(token)
+}
+
+// GenerateVerificationToken 生成邮箱验证令牌，返回明文与用于存储的哈希
+func GenerateVerificationToken() (string, string) {
+	token := utils.GenerateRandomString(40)
+	return token, HashAPIToken(token)
+}
+
+// IsAPIToken 是否为API令牌（而非JWT）
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
@@ -2,11 +2,33 @@ package auth
 
 import (
 	"errors"
+	"sync"
 	"time"
 
+	"flowforge/pkg/clock"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	clockMu    sync.RWMutex
+	tokenClock clock.Clock = clock.Real
+)
+
+// SetClock 设置令牌签发与校验的时间来源，默认为系统时间；测试中传入可控时钟验证令牌过期
+func SetClock(c clock.Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	tokenClock = c
+}
+
+// Now 令牌签发与校验使用的当前时间，JWT 与 API 令牌的有效期均按该时间判断
+func Now() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return tokenClock.Now()
+}
+
 // Claims JWT声明
 type Claims struct {
 	UserID   uint   `json:"user_id"`
@@ -17,14 +39,15 @@ type Claims struct {
 
 // GenerateToken 生成JWT令牌
 func GenerateToken(userID uint, username string, roleID uint, secret string, expirationTime time.Time) (string, error) {
+	now := Now()
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
 		RoleID:   roleID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "vibe",
 		},
 	}
@@ -33,13 +56,13 @@ func GenerateToken(userID uint, username string, roleID uint, secret string, exp
 	return token.SignedString([]byte(secret))
 }
 
-// ValidateToken 验证JWT令牌
+// ValidateToken 验证JWT令牌，过期与生效时间按 Now 判断
 func ValidateToken(tokenString string, secret string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secret), nil
-	})
+	}, jwt.WithTimeFunc(Now))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"flowforge/internal/clocktest"
+	"flowforge/pkg/clock"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+// useFakeClock 令牌签发与校验改用可控时钟，测试结束后恢复系统时间
+func useFakeClock(t *testing.T) *clocktest.Fake {
+	t.Helper()
+	fake := clocktest.New(time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC))
+	SetClock(fake)
+	t.Cleanup(func() { SetClock(clock.Real) })
+	return fake
+}
+
+func TestTokenExpiry(t *testing.T) {
+	fake := useFakeClock(t)
+	token, err := GenerateToken(7, "alice", 2, testSecret, Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ValidateToken(token, testSecret)
+	if err != nil {
+		t.Fatalf("刚签发的令牌应有效: %v", err)
+	}
+	if claims.UserID != 7 || claims.Username != "alice" || claims.RoleID != 2 {
+		t.Fatalf("声明错误: %+v", claims)
+	}
+	if !claims.IssuedAt.Time.Equal(fake.Now()) {
+		t.Errorf("签发时间应取自时钟: %v", claims.IssuedAt.Time)
+	}
+
+	// 过期前一秒仍有效，到期后无效
+	fake.Advance(24*time.Hour - time.Second)
+	if _, err := ValidateToken(token, testSecret); err != nil {
+		t.Fatalf("过期前的令牌应有效: %v", err)
+	}
+	fake.Advance(time.Second)
+	if _, err := ValidateToken(token, testSecret); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("到期的令牌应返回 ErrTokenExpired，实际为 %v", err)
+	}
+}
+
+func TestTokenNotBefore(t *testing.T) {
+	fake := useFakeClock(t)
+	issuedAt := fake.Now()
+	token, err := GenerateToken(7, "alice", 2, testSecret, issuedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 校验方的时钟早于签发时间时令牌尚未生效
+	SetClock(clocktest.New(issuedAt.Add(-time.Minute)))
+	if _, err := ValidateToken(token, testSecret); !errors.Is(err, jwt.ErrTokenNotValidYet) {
+		t.Fatalf("生效前的令牌应返回 ErrTokenNotValidYet，实际为 %v", err)
+	}
+}
+
+func TestTokenWrongSecret(t *testing.T) {
+	useFakeClock(t)
+	token, err := GenerateToken(7, "alice", 2, testSecret, Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(token, "other-secret"); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Fatalf("密钥错误应返回 ErrTokenSignatureInvalid，实际为 %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+	"flowforge/pkg/utils"
+)
+
+var (
+	// ErrBackupRunning 已有进行中的备份
+	ErrBackupRunning = errors.New("已有进行中的备份")
+	// ErrBackupUnavailable 备份未成功或备份文件已被清理
+	ErrBackupUnavailable = errors.New("备份文件不存在")
+	// ErrChecksumMismatch 备份文件与记录的校验和不一致
+	ErrChecksumMismatch = errors.New("备份文件校验和不一致")
+)
+
+// Manager 数据库备份：定时或手动导出数据库，压缩并使用静态数据加密密钥加密后保存在存储目录，
+// 生成后进行恢复校验，只保留最近成功的 Keep 个备份；备份失败时通知管理员
+type Manager struct {
+	config   *config.Config
+	dir      string
+	notifier *notify.Manager
+
+	mu      sync.Mutex
+	running bool
+}
+
+// NewManager 创建备份管理器，目前仅支持本地存储
+func NewManager(cfg *config.Config, notifier *notify.Manager) (*Manager, error) {
+	if cfg.Storage.Type != "" && cfg.Storage.Type != "local" {
+		return nil, fmt.Errorf("数据库备份暂不支持存储类型: %s", cfg.Storage.Type)
+	}
+
+	dir := filepath.Join(cfg.Storage.Local.Path, "backups")
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+
+	return &Manager{config: cfg, dir: dir, notifier: notifier}, nil
+}
+
+// RunScheduled 定时备份，关闭定时备份或已有进行中的备份时跳过
+func (m *Manager) RunScheduled() {
+	if m.config.Backup.Disabled {
+		return
+	}
+	record, err := m.begin(models.BackupTriggerSchedule, nil)
+	if errors.Is(err, ErrBackupRunning) {
+		log.Printf("跳过定时数据库备份: %v", err)
+		return
+	}
+	if err != nil {
+		log.Printf("定时数据库备份失败: %v", err)
+		return
+	}
+	m.run(record)
+}
+
+// Start 手动备份：创建备份记录后在后台执行，返回创建的记录
+func (m *Manager) Start(userID uint) (*models.Backup, error) {
+	record, err := m.begin(models.BackupTriggerManual, &userID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := *record
+	go m.run(record)
+	return &snapshot, nil
+}
+
+// Open 打开成功备份解密后的 gzip 数据，先校验文件的校验和
+func (m *Manager) Open(record *models.Backup) (io.ReadCloser, error) {
+	if record.Status != models.BackupStatusSuccess || record.FileName == "" {
+		return nil, ErrBackupUnavailable
+	}
+	path := filepath.Join(m.dir, record.FileName)
+	if err := checkChecksum(path, record.Checksum); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ErrBackupUnavailable
+	}
+	reader, err := utils.NewDecryptReader(file, m.config.Security.EncryptionKey)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+// DownloadName 下载时的文件名，解密后为 gzip 压缩的导出文件
+func DownloadName(record *models.Backup) string {
+	return record.FileName[:len(record.FileName)-len(filepath.Ext(record.FileName))]
+}
+
+// begin 创建进行中的备份记录，同一时间只执行一个备份
+func (m *Manager) begin(trigger string, userID *uint) (*models.Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return nil, ErrBackupRunning
+	}
+
+	record := &models.Backup{
+		Status:        models.BackupStatusRunning,
+		Trigger:       trigger,
+		TriggeredByID: userID,
+		DatabaseType:  m.config.Database.Type,
+	}
+	if err := database.DB.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建备份记录失败: %w", err)
+	}
+	m.running = true
+	return record, nil
+}
+
+// run 导出、加密并校验备份，保存结果后清理超出保留个数的备份
+func (m *Manager) run(record *models.Backup) {
+	defer func() {
+		m.mu.Lock()
+		m.running = false
+		m.mu.Unlock()
+	}()
+
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.config.Backup.Timeout)*time.Second)
+	defer cancel()
+
+	err := m.write(ctx, record)
+	if err == nil {
+		record.VerifyDetail, err = m.verify(ctx, record)
+		if err != nil {
+			record.VerifyDetail = err.Error()
+			err = fmt.Errorf("恢复校验失败: %w", err)
+		}
+		record.Verified = err == nil
+	}
+
+	now := time.Now()
+	record.FinishedAt = &now
+	record.DurationMs = now.Sub(started).Milliseconds()
+	record.Status = models.BackupStatusSuccess
+	if err != nil {
+		record.Status = models.BackupStatusFailed
+		record.Error = err.Error()
+	}
+	if saveErr := database.DB.Save(record).Error; saveErr != nil {
+		log.Printf("保存数据库备份 #%d 的结果失败: %v", record.ID, saveErr)
+	}
+
+	if err != nil {
+		log.Printf("数据库备份 #%d 失败: %v", record.ID, err)
+		m.notifyFailure(record)
+		return
+	}
+	log.Printf("数据库备份 #%d 完成: %s，%d 字节，耗时 %d ms", record.ID, record.FileName, record.Size, record.DurationMs)
+	m.prune()
+}
+
+// write 导出数据库并依次压缩、加密写入备份文件，先写入临时文件，完成后再重命名
+func (m *Manager) write(ctx context.Context, record *models.Backup) error {
+	tmp, err := os.CreateTemp(filepath.Join(m.dir, "tmp"), "backup-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	encrypted, err := utils.NewEncryptWriter(io.MultiWriter(tmp, hasher), m.config.Security.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	compressed := gzip.NewWriter(encrypted)
+
+	ext, err := m.dump(ctx, compressed)
+	if err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("压缩备份失败: %w", err)
+	}
+	if err := encrypted.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入备份文件失败: %w", err)
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("读取备份文件失败: %w", err)
+	}
+	name := fmt.Sprintf("flowforge-%s-%d%s.gz.enc", record.CreatedAt.UTC().Format("20060102-150405"), record.ID, ext)
+	if err := os.Rename(tmp.Name(), filepath.Join(m.dir, name)); err != nil {
+		return fmt.Errorf("保存备份文件失败: %w", err)
+	}
+
+	record.FileName = name
+	record.Size = info.Size()
+	record.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	return nil
+}
+
+// prune 只保留最近成功的 Keep 个备份，删除更早的备份文件与记录，以及其间失败的备份记录
+func (m *Manager) prune() {
+	keep := m.config.Backup.Keep
+	if keep <= 0 {
+		return
+	}
+
+	var kept []models.Backup
+	if err := database.DB.Where("status = ?", models.BackupStatusSuccess).
+		Order("id DESC").Limit(keep).Find(&kept).Error; err != nil || len(kept) < keep {
+		return
+	}
+	oldest := kept[len(kept)-1].ID
+
+	var expired []models.Backup
+	if err := database.DB.Where("id < ? AND status <> ?", oldest, models.BackupStatusRunning).Find(&expired).Error; err != nil {
+		log.Printf("查询过期的数据库备份失败: %v", err)
+		return
+	}
+	for i := range expired {
+		if expired[i].FileName != "" {
+			if err := os.Remove(filepath.Join(m.dir, expired[i].FileName)); err != nil && !os.IsNotExist(err) {
+				log.Printf("删除数据库备份文件 %s 失败: %v", expired[i].FileName, err)
+				continue
+			}
+		}
+		database.DB.Delete(&expired[i])
+	}
+}
+
+// notifyFailure 通知所有管理员备份失败
+func (m *Manager) notifyFailure(record *models.Backup) {
+	if m.notifier == nil {
+		return
+	}
+
+	var admins []models.User
+	if err := database.DB.Where("role = ?", models.RoleAdmin).Find(&admins).Error; err != nil {
+		log.Printf("查询数据库备份通知对象失败: %v", err)
+		return
+	}
+
+	msg := notify.Message{
+		Title:    fmt.Sprintf("数据库备份 #%d 失败", record.ID),
+		Content:  fmt.Sprintf("%s数据库备份失败: %s", triggerText(record.Trigger), record.Error),
+		Link:     fmt.Sprintf("%s/admin/backups", m.config.Notify.BaseURL),
+		Level:    models.NotifyLevelUrgent,
+		Category: models.NotifyCategoryBackup,
+	}
+	for i := range admins {
+		if err := m.notifier.Deliver(&admins[i], msg); err != nil {
+			log.Printf("向用户 %d 投递数据库备份通知失败: %v", admins[i].ID, err)
+		}
+	}
+}
+
+func triggerText(trigger string) string {
+	if trigger == models.BackupTriggerManual {
+		return "手动"
+	}
+	return "定时"
+}
+
+// checkChecksum 校验备份文件的 sha256 与记录一致
+func checkChecksum(path, checksum string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return ErrBackupUnavailable
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return fmt.Errorf("读取备份文件失败: %w", err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != checksum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// 导出工具在导出完整结束时写在末尾的标记，没有该标记说明导出被中断
+const (
+	mysqldumpTrailer = "-- Dump completed"
+	pgDumpTrailer    = "-- PostgreSQL database dump complete"
+)
+
+// sqliteStepPages 在线备份每次复制的页数，分步复制期间其他连接仍可写入
+const sqliteStepPages = 1024
+
+// stderrLimit 导出工具错误输出的保留字节数
+const stderrLimit = 4096
+
+// dump 将数据库导出写入 w，返回备份文件的扩展名：sqlite 为数据库文件副本，mysql 与 postgres 为 SQL
+func (m *Manager) dump(ctx context.Context, w io.Writer) (string, error) {
+	cfg := m.config.Database
+	switch cfg.Type {
+	case "sqlite":
+		return ".db", m.dumpSQLite(ctx, w)
+	case "mysql":
+		cmd := exec.CommandContext(ctx, m.config.Backup.MysqldumpPath,
+			"--single-transaction", "--routines", "--triggers", "--hex-blob",
+			"-h", cfg.Host, "-P", strconv.Itoa(cfg.Port), "-u", cfg.Username, cfg.Name)
+		// 密码通过环境变量传递，不出现在进程参数中
+		cmd.Env = append(os.Environ(), "MYSQL_PWD="+cfg.Password)
+		return ".sql", runDump(cmd, w)
+	case "postgres":
+		cmd := exec.CommandContext(ctx, m.config.Backup.PgDumpPath,
+			"--no-owner", "--no-privileges",
+			"-h", cfg.Host, "-p", strconv.Itoa(cfg.Port), "-U", cfg.Username, "-d", cfg.Name)
+		cmd.Env = append(os.Environ(), "PGPASSWORD="+cfg.Password)
+		return ".sql", runDump(cmd, w)
+	}
+	return "", fmt.Errorf("不支持备份的数据库类型: %s", cfg.Type)
+}
+
+// runDump 执行导出工具，标准输出写入 w，失败时返回错误输出
+func runDump(cmd *exec.Cmd, w io.Writer) error {
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > stderrLimit {
+			message = message[:stderrLimit]
+		}
+		return fmt.Errorf("执行 %s 失败: %w: %s", filepath.Base(cmd.Path), err, message)
+	}
+	return nil
+}
+
+// dumpSQLite 使用 sqlite 在线备份接口将当前数据库复制到临时文件，再写入 w
+func (m *Manager) dumpSQLite(ctx context.Context, w io.Writer) error {
+	tmp, err := os.CreateTemp(filepath.Join(m.dir, "tmp"), "sqlite-*.db")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := sqliteBackup(ctx, tmp.Name()); err != nil {
+		return err
+	}
+
+	file, err := os.Open(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("读取数据库副本失败: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("写入备份失败: %w", err)
+	}
+	return nil
+}
+
+// sqliteBackup 通过在线备份接口将当前连接的数据库复制到 dest
+func sqliteBackup(ctx context.Context, dest string) error {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return fmt.Errorf("获取数据库实例失败: %w", err)
+	}
+	src, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer src.Close()
+
+	destDB, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("打开数据库副本失败: %w", err)
+	}
+	defer destDB.Close()
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("打开数据库副本失败: %w", err)
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destRaw any) error {
+		return src.Raw(func(srcRaw any) error {
+			destSQLite, ok := destRaw.(*sqlite3.SQLiteConn)
+			srcSQLite, srcOK := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok || !srcOK {
+				return errors.New("数据库连接不是 sqlite 连接")
+			}
+
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("开始在线备份失败: %w", err)
+			}
+			for {
+				done, err := backup.Step(sqliteStepPages)
+				if err != nil {
+					backup.Finish()
+					return fmt.Errorf("在线备份失败: %w", err)
+				}
+				if done {
+					break
+				}
+				if err := ctx.Err(); err != nil {
+					backup.Finish()
+					return fmt.Errorf("在线备份超时: %w", err)
+				}
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("完成在线备份失败: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// verify 恢复校验：校验备份文件的校验和并完整解密、解压。sqlite 备份恢复到临时数据库后检查完整性与数据表；
+// mysql 与 postgres 恢复到临时库需要额外的数据库权限，只检查导出内容以导出工具的完成标记结尾。返回校验结果的说明
+func (m *Manager) verify(ctx context.Context, record *models.Backup) (string, error) {
+	path := filepath.Join(m.dir, record.FileName)
+	if err := checkChecksum(path, record.Checksum); err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("读取备份文件失败: %w", err)
+	}
+	defer file.Close()
+	decrypted, err := utils.NewDecryptReader(file, m.config.Security.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+	decompressed, err := gzip.NewReader(decrypted)
+	if err != nil {
+		return "", fmt.Errorf("解压备份失败: %w", err)
+	}
+	defer decompressed.Close()
+
+	if record.DatabaseType == "sqlite" {
+		return m.verifySQLite(ctx, decompressed)
+	}
+
+	trailer := pgDumpTrailer
+	if record.DatabaseType == "mysql" {
+		trailer = mysqldumpTrailer
+	}
+	tail := &tailBuffer{limit: 256}
+	size, err := io.Copy(tail, decompressed)
+	if err != nil {
+		return "", fmt.Errorf("解压备份失败: %w", err)
+	}
+	if !bytes.Contains(tail.data, []byte(trailer)) {
+		return "", errors.New("导出内容缺少完成标记，导出可能被中断")
+	}
+	return fmt.Sprintf("解密与解压完整，导出内容 %d 字节，包含导出工具的完成标记；未恢复到临时库", size), nil
+}
+
+// verifySQLite 将备份恢复为临时数据库，检查完整性并统计数据表与用户数
+func (m *Manager) verifySQLite(ctx context.Context, r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Join(m.dir, "tmp"), "restore-*.db")
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("恢复数据库失败: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+tmp.Name()+"?mode=ro")
+	if err != nil {
+		return "", fmt.Errorf("打开恢复的数据库失败: %w", err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return "", fmt.Errorf("检查恢复的数据库失败: %w", err)
+	}
+	if result != "ok" {
+		return "", fmt.Errorf("恢复的数据库完整性检查未通过: %s", result)
+	}
+	var tables, users int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		return "", fmt.Errorf("检查恢复的数据库失败: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&users); err != nil {
+		return "", fmt.Errorf("恢复的数据库缺少用户表: %w", err)
+	}
+	return fmt.Sprintf("已恢复到临时 sqlite 数据库，完整性检查通过，共 %d 张表、%d 个用户", tables, users), nil
+}
+
+// tailBuffer 只保留最后 limit 字节的 Writer
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.data = append(t.data, p...)
+	if len(t.data) > t.limit {
+		t.data = append(t.data[:0], t.data[len(t.data)-t.limit:]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,156 @@
+package baseline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// madScale MAD 换算为标准差的系数（正态分布下），正常波动范围为中位数上下 bandWidth 倍换算后的 MAD
+const (
+	madScale  = 1.4826
+	bandWidth = 3
+)
+
+// Settings 流水线步骤耗时性能回退检查的参数
+type Settings struct {
+	Multiplier float64 `json:"multiplier"`    // 超过基线中位数的倍数视为回退
+	Runs       int     `json:"baseline_runs"` // 基线使用的最近成功运行数
+	MinSamples int     `json:"min_samples"`   // 基线至少需要的运行数
+	MinStepMs  int64   `json:"min_step_ms"`   // 耗时低于该值的步骤不检查
+	Disabled   bool    `json:"disabled"`
+}
+
+// For 流水线生效的检查参数，流水线未设置（为 0）的参数使用全局配置
+func For(pipeline *models.Pipeline, cfg *config.DeployConfig) Settings {
+	settings := Settings{
+		Multiplier: cfg.PerfRegressionMultiplier,
+		Runs:       cfg.PerfBaselineRuns,
+		MinSamples: cfg.PerfMinSamples,
+		MinStepMs:  int64(cfg.PerfMinStepSeconds) * 1000,
+		Disabled:   pipeline.PerfCheckDisabled,
+	}
+	if pipeline.PerfMultiplier > 0 {
+		settings.Multiplier = pipeline.PerfMultiplier
+	}
+	if pipeline.PerfBaselineRuns > 0 {
+		settings.Runs = pipeline.PerfBaselineRuns
+	}
+	if pipeline.PerfMinStepSeconds > 0 {
+		settings.MinStepMs = int64(pipeline.PerfMinStepSeconds) * 1000
+	}
+	if settings.MinSamples > settings.Runs {
+		settings.MinSamples = settings.Runs
+	}
+	return settings
+}
+
+// Baseline 一个步骤的耗时基线（毫秒）
+type Baseline struct {
+	Samples     int   `json:"samples"`
+	MedianMs    int64 `json:"median_ms"`
+	MADMs       int64 `json:"mad_ms"`       // 中位数绝对偏差
+	LowerMs     int64 `json:"lower_ms"`     // 正常波动范围下界
+	UpperMs     int64 `json:"upper_ms"`     // 正常波动范围上界
+	ThresholdMs int64 `json:"threshold_ms"` // 超过时视为性能回退：中位数乘以倍数
+}
+
+// Compute 按耗时样本计算基线
+func Compute(durations []int64, multiplier float64) Baseline {
+	if len(durations) == 0 {
+		return Baseline{}
+	}
+	median := Median(durations)
+	deviations := make([]int64, len(durations))
+	for i, d := range durations {
+		deviations[i] = int64(math.Abs(float64(d) - median))
+	}
+	mad := Median(deviations)
+	spread := bandWidth * madScale * mad
+
+	return Baseline{
+		Samples:     len(durations),
+		MedianMs:    int64(math.Round(median)),
+		MADMs:       int64(math.Round(mad)),
+		LowerMs:     int64(math.Max(0, math.Round(median-spread))),
+		UpperMs:     int64(math.Round(median + spread)),
+		ThresholdMs: int64(math.Round(median * multiplier)),
+	}
+}
+
+// Exceeds 步骤耗时是否超过基线阈值；样本不足或耗时过短的步骤不判断
+func (b Baseline) Exceeds(durationMs int64, settings Settings) bool {
+	if settings.Disabled || b.Samples == 0 || b.Samples < settings.MinSamples {
+		return false
+	}
+	return durationMs >= settings.MinStepMs && durationMs > b.ThresholdMs
+}
+
+// Median 中位数，样本为偶数个时取中间两个的平均值
+func Median(values []int64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return float64(sorted[mid-1]+sorted[mid]) / 2
+	}
+	return float64(sorted[mid])
+}
+
+// Sample 一次运行中一个步骤的耗时
+type Sample struct {
+	RunID      uint   `json:"run_id"`
+	RunNumber  int    `json:"run_number"`
+	StepID     uint   `json:"step_id"`
+	StepName   string `json:"-"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Samples 最近 runs 次可作为基线的运行的步骤耗时，按运行先后（旧到新）排列。
+// 只使用 branch 分支上成功的运行，排除仅重跑失败步骤的运行、触及资源限制的运行与 excludeRunID；
+// 复用的步骤没有实际执行，只计入成功执行的步骤
+func Samples(pipelineID uint, branch string, runs int, excludeRunID uint) ([]Sample, error) {
+	var runIDs []uint
+	if err := database.DB.Model(&models.PipelineRun{}).
+		Where("pipeline_id = ? AND branch = ? AND status = ?", pipelineID, branch, models.RunStatusSuccess).
+		Where("rerun_of_id IS NULL AND resource_limited = ? AND id <> ?", false, excludeRunID).
+		Order("id DESC").Limit(runs).Pluck("id", &runIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询基线运行失败: %w", err)
+	}
+	if len(runIDs) == 0 {
+		return nil, nil
+	}
+
+	var samples []Sample
+	if err := database.DB.Table("pipeline_steps").
+		Select("pipeline_steps.pipeline_run_id AS run_id, pipeline_runs.run_number, pipeline_steps.id AS step_id, "+
+			"pipeline_steps.name AS step_name, pipeline_steps.duration_ms").
+		Joins("JOIN pipeline_runs ON pipeline_runs.id = pipeline_steps.pipeline_run_id").
+		Where("pipeline_steps.pipeline_run_id IN ? AND pipeline_steps.status = ? AND pipeline_steps.deleted_at IS NULL",
+			runIDs, models.StepStatusSuccess).
+		Order("pipeline_steps.pipeline_run_id, pipeline_steps.step_order").
+		Scan(&samples).Error; err != nil {
+		return nil, fmt.Errorf("查询基线步骤耗时失败: %w", err)
+	}
+	return samples, nil
+}
+
+// Build 按步骤名称计算基线
+func Build(samples []Sample, multiplier float64) map[string]Baseline {
+	durations := make(map[string][]int64)
+	for _, s := range samples {
+		durations[s.StepName] = append(durations[s.StepName], s.DurationMs)
+	}
+	baselines := make(map[string]Baseline, len(durations))
+	for name, values := range durations {
+		baselines[name] = Compute(values, multiplier)
+	}
+	return baselines
+}
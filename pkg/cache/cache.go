@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// sweepThreshold 条目数达到该值时写入前清理过期条目
+const sweepThreshold = 1024
+
+var (
+	mu       sync.RWMutex
+	ttl      = 30 * time.Second
+	disabled bool
+	registry []stater
+)
+
+// Init 按配置设置缓存时间与开关
+func Init(cfg *config.CacheConfig) {
+	mu.Lock()
+	disabled = cfg.Disabled
+	if cfg.TTL > 0 {
+		ttl = time.Duration(cfg.TTL) * time.Second
+	}
+	mu.Unlock()
+
+	Flush()
+}
+
+// TTL 缓存时间，也是遗漏失效时修改最迟生效的时间
+func TTL() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return ttl
+}
+
+// Disabled 是否关闭了缓存
+func Disabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return disabled
+}
+
+// Stats 缓存的条目数与命中统计
+type Stats struct {
+	Name    string  `json:"name"`
+	Entries int     `json:"entries"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// stater 已注册缓存的统计与清空，不依赖键值类型
+type stater interface {
+	stats() Stats
+	flush()
+}
+
+// All 所有已注册缓存的统计，按名称排序
+func All() []Stats {
+	caches := registered()
+	stats := make([]Stats, 0, len(caches))
+	for _, c := range caches {
+		stats = append(stats, c.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Flush 清空所有缓存
+func Flush() {
+	for _, c := range registered() {
+		c.flush()
+	}
+}
+
+// registered 已注册缓存的副本；各缓存写入时持有自身的锁读取 TTL，遍历时不能持有全局锁
+func registered() []stater {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]stater(nil), registry...)
+}
+
+// Loader 缓存未命中时读取数据
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Cache 带 TTL 与显式失效的类型化缓存，同一个键的并发未命中只调用一次 Loader
+type Cache[K comparable, V any] struct {
+	name string
+	load Loader[K, V]
+
+	mu      sync.Mutex
+	entries map[K]entry[V]
+	calls   map[K]*call[V]
+
+	hits, misses int64
+}
+
+// entry 缓存的值与过期时间
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// call 进行中的加载，等待的调用方共享结果
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// New 创建并注册缓存，name 用于统计
+func New[K comparable, V any](name string, load Loader[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		name:    name,
+		load:    load,
+		entries: make(map[K]entry[V]),
+		calls:   make(map[K]*call[V]),
+	}
+
+	mu.Lock()
+	registry = append(registry, c)
+	mu.Unlock()
+	return c
+}
+
+// Get 读取缓存的值，未命中或已过期时加载；加载失败的结果不缓存
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	if Disabled() {
+		atomic.AddInt64(&c.misses, 1)
+		return c.load(ctx, key)
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expiresAt) {
+		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
+		return e.value, nil
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	if pending, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-pending.done:
+			return pending.value, pending.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+	pending := &call[V]{done: make(chan struct{})}
+	c.calls[key] = pending
+	c.mu.Unlock()
+
+	pending.value, pending.err = c.load(ctx, key)
+
+	c.mu.Lock()
+	// 加载期间键被失效时，结果只返回给已经在等待的调用方，不写入缓存
+	if c.calls[key] == pending {
+		delete(c.calls, key)
+		if pending.err == nil {
+			c.store(key, pending.value)
+		}
+	}
+	c.mu.Unlock()
+	close(pending.done)
+
+	return pending.value, pending.err
+}
+
+// store 写入条目，调用方持有锁
+func (c *Cache[K, V]) store(key K, value V) {
+	now := time.Now()
+	if len(c.entries) >= sweepThreshold {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = entry[V]{value: value, expiresAt: now.Add(TTL())}
+}
+
+// Invalidate 使指定的键失效，进行中的加载结果也不再写入缓存
+func (c *Cache[K, V]) Invalidate(keys ...K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+		delete(c.calls, key)
+	}
+}
+
+// InvalidateAll 使所有键失效
+func (c *Cache[K, V]) InvalidateAll() {
+	c.flush()
+}
+
+func (c *Cache[K, V]) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[K]entry[V])
+	c.calls = make(map[K]*call[V])
+}
+
+func (c *Cache[K, V]) stats() Stats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	stats := Stats{
+		Name:    c.name,
+		Entries: entries,
+		Hits:    atomic.LoadInt64(&c.hits),
+		Misses:  atomic.LoadInt64(&c.misses),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
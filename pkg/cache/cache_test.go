@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// useTTL 设置缓存时间与开关，测试结束后恢复
+func useTTL(t *testing.T, d time.Duration, off bool) {
+	t.Helper()
+	mu.Lock()
+	savedTTL, savedDisabled := ttl, disabled
+	ttl, disabled = d, off
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		ttl, disabled = savedTTL, savedDisabled
+		mu.Unlock()
+	})
+}
+
+// source 模拟数据库：记录读取次数，值可以在缓存之外修改
+type source struct {
+	mu    sync.Mutex
+	value map[string]string
+	loads int64
+}
+
+func (s *source) set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value[key] = value
+}
+
+func (s *source) load(_ context.Context, key string) (string, error) {
+	atomic.AddInt64(&s.loads, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.value[key]
+	if !ok {
+		return "", errors.New("记录不存在")
+	}
+	return value, nil
+}
+
+func newSource(t *testing.T, name string) (*Cache[string, string], *source) {
+	t.Helper()
+	src := &source{value: map[string]string{"alice": "admin"}}
+	return New(t.Name()+"/"+name, src.load), src
+}
+
+func get(t *testing.T, c *Cache[string, string], key string) string {
+	t.Helper()
+	value, err := c.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// TestMissedInvalidationWithinTTL 修改后遗漏失效时，缓存时间内仍返回旧值，超过缓存时间后读取到新值；
+// 调用失效后立即读取到新值
+func TestMissedInvalidationWithinTTL(t *testing.T) {
+	useTTL(t, 100*time.Millisecond, false)
+	c, src := newSource(t, "roles")
+
+	if got := get(t, c, "alice"); got != "admin" {
+		t.Fatalf("读取到 %q", got)
+	}
+	src.set("alice", "user")
+	if got := get(t, c, "alice"); got != "admin" {
+		t.Errorf("缓存时间内读取到 %q，应为缓存的旧值", got)
+	}
+
+	deadline := time.Now().Add(TTL())
+	for get(t, c, "alice") != "user" {
+		if time.Now().After(deadline.Add(50 * time.Millisecond)) {
+			t.Fatalf("遗漏失效后超过缓存时间 %v 仍返回旧值", TTL())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	src.set("alice", "viewer")
+	c.Invalidate("alice")
+	if got := get(t, c, "alice"); got != "viewer" {
+		t.Errorf("失效后读取到 %q，应立即读取到新值", got)
+	}
+}
+
+// TestGetCachesAndCounts 命中时不再读取，统计命中与未命中次数；读取失败的结果不缓存
+func TestGetCachesAndCounts(t *testing.T) {
+	useTTL(t, time.Minute, false)
+	c, src := newSource(t, "counts")
+
+	for i := 0; i < 4; i++ {
+		get(t, c, "alice")
+	}
+	if src.loads != 1 {
+		t.Errorf("读取了 %d 次，应只在第一次未命中时读取", src.loads)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(context.Background(), "bob"); err == nil {
+			t.Fatal("不存在的记录应返回错误")
+		}
+	}
+	src.set("bob", "user")
+	if got := get(t, c, "bob"); got != "user" {
+		t.Errorf("读取失败后写入的记录读取到 %q，失败的结果不应缓存", got)
+	}
+
+	stats := c.stats()
+	if stats.Entries != 2 || stats.Hits != 3 || stats.Misses != 4 || stats.HitRate != 3.0/7 {
+		t.Errorf("统计为 %+v，应为 2 个条目、命中 3 次、未命中 4 次", stats)
+	}
+	found := false
+	for _, s := range All() {
+		found = found || s.Name == stats.Name
+	}
+	if !found {
+		t.Errorf("All 中没有缓存 %s", stats.Name)
+	}
+
+	Flush()
+	if stats := c.stats(); stats.Entries != 0 {
+		t.Errorf("清空后仍有 %d 个条目", stats.Entries)
+	}
+}
+
+// TestConcurrentMissesLoadOnce 同一个键的并发未命中只读取一次，等待的调用方共享结果
+func TestConcurrentMissesLoadOnce(t *testing.T) {
+	useTTL(t, time.Minute, false)
+	release := make(chan struct{})
+	var loads int64
+	c := New(t.Name(), func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt64(&loads, 1)
+		<-release
+		return "admin", nil
+	})
+
+	var wg sync.WaitGroup
+	results := make([]string, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.Get(context.Background(), "alice")
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("并发未命中读取了 %d 次", loads)
+	}
+	for i, got := range results {
+		if got != "admin" {
+			t.Errorf("第 %d 个调用方读取到 %q", i+1, got)
+		}
+	}
+}
+
+// TestInvalidateDuringLoad 读取期间键被失效时，读取到的旧值不写入缓存，下一次读取重新加载
+func TestInvalidateDuringLoad(t *testing.T) {
+	useTTL(t, time.Minute, false)
+	started, release := make(chan struct{}), make(chan struct{})
+	var value atomic.Value
+	value.Store("admin")
+	c := New(t.Name(), func(ctx context.Context, key string) (string, error) {
+		v := value.Load().(string)
+		if v == "admin" {
+			close(started)
+			<-release
+		}
+		return v, nil
+	})
+
+	done := make(chan string)
+	go func() {
+		v, _ := c.Get(context.Background(), "alice")
+		done <- v
+	}()
+	<-started
+	value.Store("user")
+	c.Invalidate("alice")
+	close(release)
+
+	if got := <-done; got != "admin" {
+		t.Errorf("进行中的读取返回 %q", got)
+	}
+	if got := get(t, c, "alice"); got != "user" {
+		t.Errorf("失效后读取到 %q，进行中读取的旧值不应写入缓存", got)
+	}
+}
+
+// TestDisabled 关闭缓存后每次都读取；Init 设置缓存时间并清空已有条目
+func TestDisabled(t *testing.T) {
+	useTTL(t, time.Minute, false)
+	c, src := newSource(t, "disabled")
+	get(t, c, "alice")
+
+	Init(&config.CacheConfig{Disabled: true, TTL: 5})
+	if TTL() != 5*time.Second || !Disabled() {
+		t.Errorf("缓存时间为 %v、关闭为 %v", TTL(), Disabled())
+	}
+	if stats := c.stats(); stats.Entries != 0 {
+		t.Errorf("Init 后仍有 %d 个条目", stats.Entries)
+	}
+	src.set("alice", "user")
+	for i := 0; i < 3; i++ {
+		if got := get(t, c, "alice"); got != "user" {
+			t.Errorf("关闭缓存后读取到 %q", got)
+		}
+	}
+	if src.loads != 4 {
+		t.Errorf("关闭缓存后读取了 %d 次，应每次都读取", src.loads)
+	}
+}
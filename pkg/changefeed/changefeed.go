@@ -0,0 +1,283 @@
+package changefeed
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// 变更日志记录的资源类型，与各列表接口对应
+const (
+	ResourceProject      = "project"
+	ResourcePipeline     = "pipeline"
+	ResourcePipelineRun  = "pipeline_run"
+	ResourceDeployment   = "deployment"
+	ResourceNotification = "notification"
+)
+
+// 变更类型
+const (
+	KindCreated = "created"
+	KindUpdated = "updated"
+	KindDeleted = "deleted"
+)
+
+const (
+	// pageSize 一次返回的最多变更日志条数，超过时 has_more 为 true
+	pageSize = 500
+	// settleDelay 写入超过该时长的变更才让游标越过：并发事务可能晚于日志ID更大的变更提交，
+	// 期间的变更下次查询会再次返回，避免游标越过尚未提交的变更
+	settleDelay = 2 * time.Second
+	// recheckInterval 长轮询没有收到本实例的写入通知时重新查询的间隔，发现其他实例写入与刚提交的事务
+	recheckInterval = time.Second
+)
+
+// ErrInvalidCursor 游标不是本接口返回的值
+var ErrInvalidCursor = errors.New("无效的变更游标")
+
+// Change 一项变更，同一资源在一次返回中只出现最后一次
+type Change struct {
+	ResourceType string    `json:"resource_type"`
+	ID           uint      `json:"id"`
+	Kind         string    `json:"kind"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Page 一次查询的结果
+type Page struct {
+	Changes []Change `json:"changes"`
+	Cursor  string   `json:"cursor"`   // 下次查询的 since
+	HasMore bool     `json:"has_more"` // 超过单次返回的上限，应立即再次查询
+	Reset   bool     `json:"reset"`    // since 早于已清理的变更日志，需要重新获取全部列表
+
+	fresh bool // 是否包含 since 之后首次返回的变更
+}
+
+// cursor 变更订阅的位置：settled 及之前的变更已全部返回；seen 为已返回的最大日志ID，
+// 两者之间的变更写入不久，下次查询会再次返回，但不视为新的变更
+type cursor struct {
+	settled uint
+	seen    uint
+}
+
+// encode 游标的外部形式，不透明，客户端原样传回
+func (c cursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("v1:%d:%d", c.settled, c.seen)))
+}
+
+// decodeCursor 解析客户端传回的游标
+func decodeCursor(value string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	var c cursor
+	if n, err := fmt.Sscanf(string(data), "v1:%d:%d", &c.settled, &c.seen); err != nil || n != 2 || c.seen < c.settled {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+var (
+	signalMu sync.Mutex
+	signal   = make(chan struct{})
+)
+
+// changed 下次写入变更日志时关闭的通道，查询前获取，不会错过查询期间的写入
+func changed() <-chan struct{} {
+	signalMu.Lock()
+	defer signalMu.Unlock()
+	return signal
+}
+
+// notify 唤醒等待中的长轮询
+func notify() {
+	signalMu.Lock()
+	defer signalMu.Unlock()
+	close(signal)
+	signal = make(chan struct{})
+}
+
+// Poll 查询 since 之后 visible 范围内的变更；没有新的变更时最多等待 wait，期间有变更写入时立即返回。
+// since 为空时不返回变更，只返回当前位置的游标，界面获取全部列表后从该位置开始订阅
+func Poll(ctx context.Context, since string, visible func(*gorm.DB) *gorm.DB, wait time.Duration) (*Page, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		written := changed()
+		page, err := list(since, visible)
+		if err != nil || since == "" || page.fresh || page.Reset || page.HasMore {
+			return page, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return page, nil
+		}
+		if remaining > recheckInterval {
+			remaining = recheckInterval
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return page, nil
+		case <-written:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// list 查询一次变更
+func list(since string, visible func(*gorm.DB) *gorm.DB) (*Page, error) {
+	settledID, err := settledThrough(time.Now().Add(-settleDelay))
+	if err != nil {
+		return nil, err
+	}
+	if since == "" {
+		return head(settledID)
+	}
+	from, err := decodeCursor(since)
+	if err != nil {
+		return nil, err
+	}
+	if from.settled < prunedThrough() {
+		page, err := head(settledID)
+		if page != nil {
+			page.Reset = true
+		}
+		return page, err
+	}
+
+	var entries []models.ChangeJournal
+	if err := database.DB.Model(&models.ChangeJournal{}).Select("change_journals.*").Scopes(visible).
+		Where("change_journals.id > ?", from.settled).
+		Order("change_journals.id").Limit(pageSize + 1).
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("查询变更日志失败: %w", err)
+	}
+
+	page := &Page{Changes: []Change{}}
+	next := from
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		page.HasMore = true
+		// 之后还有未返回的可见变更，游标只越过已返回的部分
+		if last := entries[len(entries)-1].ID; last < settledID {
+			settledID = last
+		}
+	}
+	if settledID > next.settled {
+		next.settled = settledID
+	}
+	for _, entry := range entries {
+		if entry.ID > from.seen {
+			page.fresh = true
+		}
+		if entry.ID > next.seen {
+			next.seen = entry.ID
+		}
+	}
+	if next.seen < next.settled {
+		next.seen = next.settled
+	}
+
+	page.Changes = compact(entries)
+	page.Cursor = next.encode()
+	return page, nil
+}
+
+// head 当前位置的游标
+func head(settledID uint) (*Page, error) {
+	var latest uint
+	if err := database.DB.Model(&models.ChangeJournal{}).Select("COALESCE(MAX(id), 0)").Scan(&latest).Error; err != nil {
+		return nil, fmt.Errorf("查询变更日志失败: %w", err)
+	}
+	if latest < settledID {
+		latest = settledID
+	}
+	return &Page{Changes: []Change{}, Cursor: cursor{settled: settledID, seen: latest}.encode()}, nil
+}
+
+// settledThrough 写入早于 before 的最后一条变更日志的ID
+func settledThrough(before time.Time) (uint, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.ChangeJournal{}).Where("created_at < ?", before).
+		Order("created_at DESC, id DESC").Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("查询变更日志失败: %w", err)
+	}
+	if len(ids) == 0 {
+		return prunedThrough(), nil
+	}
+	return ids[0], nil
+}
+
+// compact 同一资源只保留最后一次变更，按最后一次变更的顺序返回
+func compact(entries []models.ChangeJournal) []Change {
+	seen := make(map[string]bool, len(entries))
+	changes := make([]Change, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		key := entry.ResourceType + ":" + strconv.FormatUint(uint64(entry.ResourceID), 10)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		changes = append(changes, Change{ResourceType: entry.ResourceType, ID: entry.ResourceID, Kind: entry.Kind, UpdatedAt: entry.CreatedAt})
+	}
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+	return changes
+}
+
+// prunedThrough 已清理到的变更日志ID，从未清理时为 0
+func prunedThrough() uint {
+	value, ok, err := database.Setting(context.Background(), models.ConfigChangesPrunedThrough)
+	if err != nil || !ok {
+		return 0
+	}
+	id, _ := strconv.ParseUint(value, 10, 64)
+	return uint(id)
+}
+
+// Prune 删除在 now 时已超过保留时间的变更日志，由每日清理任务调用。先记录清理到的位置，
+// 早于该位置的游标查询时返回 reset，界面重新获取全部列表
+func Prune(now time.Time) (int64, error) {
+	mu.Lock()
+	cutoff := now.Add(-retention)
+	mu.Unlock()
+
+	var last uint
+	if err := database.DB.Model(&models.ChangeJournal{}).Where("created_at < ?", cutoff).
+		Select("COALESCE(MAX(id), 0)").Scan(&last).Error; err != nil {
+		return 0, fmt.Errorf("查询变更日志失败: %w", err)
+	}
+	if last == 0 || last <= prunedThrough() {
+		return 0, nil
+	}
+
+	key := models.ConfigChangesPrunedThrough
+	setting := models.SystemConfig{Key: key, Category: "system", Description: "变更日志已清理到的日志ID"}
+	err := database.DB.Where(models.SystemConfig{Key: key}).
+		Assign(models.SystemConfig{Value: strconv.FormatUint(uint64(last), 10)}).FirstOrCreate(&setting).Error
+	database.InvalidateSettings()
+	if err != nil {
+		return 0, fmt.Errorf("保存变更日志清理位置失败: %w", err)
+	}
+
+	result := database.DB.Where("id <= ?", last).Delete(&models.ChangeJournal{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理变更日志失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
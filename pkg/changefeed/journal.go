@@ -0,0 +1,248 @@
+package changefeed
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pendingKey 更新与删除前查询到的受影响资源，执行成功后写入变更日志
+const pendingKey = "changefeed:pending"
+
+// journalBatch 一次写入变更日志的最大条数
+const journalBatch = 500
+
+// table 记录变更的表：资源类型，以及所属项目与用户所在的列
+type table struct {
+	resource    string
+	project     string          // 所属项目ID所在的列，为空时不属于项目
+	user        string          // 所属用户ID所在的列，为空时不属于单个用户
+	viaPipeline bool            // project 为流水线ID，所属项目需查询流水线
+	quiet       map[string]bool // 只修改这些列时不记录，如运行日志的追加与心跳
+}
+
+// tables 界面列表展示的资源；其他表的变更不记录
+var tables = map[string]table{
+	"projects":  {resource: ResourceProject, project: "id"},
+	"pipelines": {resource: ResourcePipeline, project: "project_id"},
+	"pipeline_runs": {resource: ResourcePipelineRun, project: "pipeline_id", viaPipeline: true,
+		quiet: map[string]bool{"log_output": true, "log_size": true, "last_heartbeat_at": true, "updated_at": true}},
+	"deployments":   {resource: ResourceDeployment, project: "project_id"},
+	"notifications": {resource: ResourceNotification, user: "user_id"},
+}
+
+// target 受影响的一个资源
+type target struct {
+	ID        uint
+	ProjectID uint
+	UserID    uint
+}
+
+var (
+	mu        sync.Mutex
+	retention = 24 * time.Hour
+)
+
+// Register 在数据库连接上注册记录变更的回调并设置变更日志的保留时间，连接数据库后调用一次。
+// 变更日志与变更在同一事务中写入，事务回滚时一起撤销
+func Register(db *gorm.DB, cfg *config.ChangesConfig) error {
+	mu.Lock()
+	retention = time.Duration(cfg.RetentionHours) * time.Hour
+	mu.Unlock()
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("changefeed:after_create", afterCreate); err != nil {
+		return fmt.Errorf("注册变更日志回调失败: %w", err)
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("changefeed:before_update", beforeWrite); err != nil {
+		return fmt.Errorf("注册变更日志回调失败: %w", err)
+	}
+	if err := callbacks.Update().After("gorm:update").Register("changefeed:after_update", afterWrite(KindUpdated)); err != nil {
+		return fmt.Errorf("注册变更日志回调失败: %w", err)
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("changefeed:before_delete", beforeWrite); err != nil {
+		return fmt.Errorf("注册变更日志回调失败: %w", err)
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("changefeed:after_delete", afterWrite(KindDeleted)); err != nil {
+		return fmt.Errorf("注册变更日志回调失败: %w", err)
+	}
+	return nil
+}
+
+// afterCreate 创建成功后按主键查询所属项目与用户并记录
+func afterCreate(db *gorm.DB) {
+	t, ok := tables[db.Statement.Table]
+	if !ok || db.Error != nil || db.Statement.DryRun || db.Statement.RowsAffected == 0 {
+		return
+	}
+	ids := primaryKeys(db.Statement)
+	if len(ids) == 0 {
+		return
+	}
+	targets, err := lookup(db, t, ids, nil)
+	if err != nil {
+		log.Printf("查询新建的 %s 失败，变更日志未记录: %v", t.resource, err)
+		return
+	}
+	record(db, t, KindCreated, targets)
+}
+
+// beforeWrite 更新与删除前按同样的条件查询受影响的资源，执行后的条件可能已不再匹配
+func beforeWrite(db *gorm.DB) {
+	t, ok := tables[db.Statement.Table]
+	if !ok || db.Error != nil || db.Statement.DryRun || t.onlyQuiet(db.Statement) {
+		return
+	}
+
+	var where *clause.Where
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok && len(w.Exprs) > 0 {
+			where = &w
+		}
+	}
+	ids := primaryKeys(db.Statement)
+	if len(ids) == 0 && where == nil {
+		return
+	}
+
+	targets, err := lookup(db, t, ids, where)
+	if err != nil {
+		log.Printf("查询受影响的 %s 失败，变更日志未记录: %v", t.resource, err)
+		return
+	}
+	db.InstanceSet(pendingKey, targets)
+}
+
+// afterWrite 更新或删除成功后记录 beforeWrite 查询到的资源
+func afterWrite(kind string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		t, ok := tables[db.Statement.Table]
+		if !ok || db.Error != nil || db.Statement.RowsAffected == 0 {
+			return
+		}
+		if value, ok := db.InstanceGet(pendingKey); ok {
+			record(db, t, kind, value.([]target))
+		}
+	}
+}
+
+// onlyQuiet 以列名为键的更新是否只修改不需要记录的列
+func (t table) onlyQuiet(stmt *gorm.Statement) bool {
+	values, ok := stmt.Dest.(map[string]interface{})
+	if !ok || len(t.quiet) == 0 || len(values) == 0 {
+		return false
+	}
+	for column := range values {
+		if !t.quiet[column] {
+			return false
+		}
+	}
+	return true
+}
+
+// primaryKeys 语句的模型或创建的记录中非零的主键
+func primaryKeys(stmt *gorm.Statement) []interface{} {
+	if stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+
+	var ids []interface{}
+	collect := func(value reflect.Value) {
+		value = reflect.Indirect(value)
+		if value.Kind() != reflect.Struct || value.Type() != stmt.Schema.ModelType {
+			return
+		}
+		if id, zero := field.ValueOf(stmt.Context, value); !zero {
+			ids = append(ids, id)
+		}
+	}
+
+	value := reflect.Indirect(stmt.ReflectValue)
+	switch value.Kind() {
+	case reflect.Struct:
+		collect(value)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			collect(value.Index(i))
+		}
+	}
+	return ids
+}
+
+// lookup 在语句所在的事务中查询资源及其所属项目与用户，ids 与 where 同时给出时都需满足
+func lookup(db *gorm.DB, t table, ids []interface{}, where *clause.Where) ([]target, error) {
+	columns := "id"
+	if t.project != "" {
+		columns += ", " + t.project + " AS project_id"
+	}
+	if t.user != "" {
+		columns += ", " + t.user + " AS user_id"
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true}).Table(db.Statement.Table).Select(columns)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if where != nil {
+		query = query.Clauses(*where)
+	}
+	var targets []target
+	if err := query.Scan(&targets).Error; err != nil {
+		return nil, err
+	}
+	if !t.viaPipeline || len(targets) == 0 {
+		return targets, nil
+	}
+
+	pipelineIDs := make([]uint, 0, len(targets))
+	for _, target := range targets {
+		pipelineIDs = append(pipelineIDs, target.ProjectID)
+	}
+	var pipelines []struct {
+		ID        uint
+		ProjectID uint
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Table("pipelines").Select("id, project_id").
+		Where("id IN ?", pipelineIDs).Scan(&pipelines).Error; err != nil {
+		return nil, err
+	}
+	projects := make(map[uint]uint, len(pipelines))
+	for _, pipeline := range pipelines {
+		projects[pipeline.ID] = pipeline.ProjectID
+	}
+	for i := range targets {
+		targets[i].ProjectID = projects[targets[i].ProjectID]
+	}
+	return targets, nil
+}
+
+// record 在语句所在的事务中写入变更日志并唤醒等待中的长轮询；写入失败只记录日志，不影响变更本身
+func record(db *gorm.DB, t table, kind string, targets []target) {
+	if len(targets) == 0 {
+		return
+	}
+	entries := make([]models.ChangeJournal, 0, len(targets))
+	for _, target := range targets {
+		entries = append(entries, models.ChangeJournal{
+			ResourceType: t.resource,
+			ResourceID:   target.ID,
+			Kind:         kind,
+			ProjectID:    target.ProjectID,
+			UserID:       target.UserID,
+		})
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).CreateInBatches(&entries, journalBatch).Error; err != nil {
+		log.Printf("写入变更日志失败: %v", err)
+		return
+	}
+	notify()
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"flowforge/pkg/models"
+)
+
+// ListArtifacts 获取运行产出的制品
+func (c *Client) ListArtifacts(ctx context.Context, pipelineID, runID uint) ([]models.Artifact, error) {
+	var artifacts []models.Artifact
+	err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/pipelines/%d/runs/%d/artifacts", pipelineID, runID)}, &artifacts)
+	return artifacts, err
+}
+
+// DownloadArtifact 下载制品并写入 w，返回写入的字节数。下载完成后按 X-Checksum-Sha256 校验内容，
+// 校验失败时返回错误，此时 w 中已写入的内容不可用
+func (c *Client) DownloadArtifact(ctx context.Context, pipelineID, runID, artifactID uint, w io.Writer) (int64, error) {
+	resp, err := c.stream(ctx, &request{method: http.MethodGet, path: idPath("/pipelines/%d/runs/%d/artifacts/%d", pipelineID, runID, artifactID)})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("下载制品失败: %w", err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return n, fmt.Errorf("制品下载不完整: 收到 %d 字节，应为 %d 字节", n, resp.ContentLength)
+	}
+	if expected := resp.Header.Get("X-Checksum-Sha256"); expected != "" {
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+			return n, fmt.Errorf("制品校验失败: sha256 为 %s，应为 %s", actual, expected)
+		}
+	}
+	return n, nil
+}
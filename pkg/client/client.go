@@ -0,0 +1,257 @@
+// Package client FlowForge API 的 Go 客户端。
+//
+// 方法与 /api/v1 下的接口一一对应，返回 models 中的结构；接口返回的错误转换为 *Error，
+// 可用 errors.Is 与 ErrNotFound 等比较，或按 Code（错误码目录中的键）区分具体原因。
+// 查询类请求在连接失败、超时、限流与服务端错误时按 pkg/retry 的策略重试，
+// 触发运行、取消与部署等请求不重试，避免重复执行。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/retry"
+)
+
+// Version 客户端版本，随 User-Agent 发送；接口不兼容的变更时提升主版本
+const Version = "1.0.0"
+
+// apiPrefix 接口路径前缀
+const apiPrefix = "/api/v1"
+
+// Client FlowForge API 客户端，可在多个 goroutine 中共享
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	retry      retry.Policy
+	userAgent  string
+	locale     string
+}
+
+// Option 客户端选项
+type Option func(*Client)
+
+// WithHTTPClient 使用指定的 HTTP 客户端，默认使用 httpclient.Default()
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTimeout 使用共享传输层并设置请求超时；日志、进度与制品下载等流式请求不受该超时限制，由 ctx 控制
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient = httpclient.New(timeout)
+	}
+}
+
+// WithRetry 设置查询类请求的重试策略，默认使用 retry.Default()；Attempts 为 1 时不重试
+func WithRetry(policy retry.Policy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithUserAgent 在 User-Agent 中标识调用方，如 "release-bot/2.1"
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent + " " + c.userAgent
+	}
+}
+
+// WithLocale 设置错误信息的语言（Accept-Language），如 en；默认由服务端决定
+func WithLocale(locale string) Option {
+	return func(c *Client) {
+		c.locale = locale
+	}
+}
+
+// New 创建客户端，baseURL 为服务地址（如 https://flowforge.example.com），token 为 API 令牌
+func New(baseURL, token string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的服务地址: %s", baseURL)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("API令牌不能为空")
+	}
+
+	c := &Client{
+		baseURL:   strings.TrimSuffix(u.String(), apiPrefix),
+		token:     token,
+		retry:     retry.Default(),
+		userAgent: "flowforge-go-client/" + Version,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = httpclient.Default()
+	}
+	return c, nil
+}
+
+// request 一次接口调用
+type request struct {
+	method string
+	path   string // /api/v1 之后的路径
+	query  url.Values
+	body   interface{}
+	header http.Header
+}
+
+// newRequest 创建带认证与语言请求头的 HTTP 请求
+func (c *Client) newRequest(ctx context.Context, r *request, data []byte) (*http.Request, error) {
+	endpoint := c.baseURL + apiPrefix + r.path
+	if len(r.query) > 0 {
+		endpoint += "?" + r.query.Encode()
+	}
+
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.locale != "" {
+		req.Header.Set("Accept-Language", c.locale)
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// do 发送请求并将响应中的 data 解析到 out，out 为 nil 时丢弃响应体。
+// 只有 GET 请求按重试策略重试，其他请求只经过熔断
+func (c *Client) do(ctx context.Context, r *request, out interface{}) error {
+	var data []byte
+	if r.body != nil {
+		var err error
+		if data, err = json.Marshal(r.body); err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+	}
+
+	policy := c.retry
+	if r.method != http.MethodGet {
+		policy.Attempts = 1
+	}
+
+	return retry.Do(ctx, retry.Host(c.baseURL), policy, func() error {
+		req, err := c.newRequest(ctx, r, data)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return c.transportError(ctx, err)
+		}
+		defer resp.Body.Close()
+
+		if err := checkResponse(resp); err != nil {
+			return err
+		}
+		if out == nil {
+			return nil
+		}
+		if err := decodeData(resp.Body, out); err != nil {
+			return retry.Permanent(err)
+		}
+		return nil
+	})
+}
+
+// stream 发送流式请求，成功时返回状态码为 2xx 的响应，调用方负责关闭响应体。
+// 流式请求不受客户端超时限制；建立连接前的失败按 GET 的重试策略重试
+func (c *Client) stream(ctx context.Context, r *request) (*http.Response, error) {
+	hc := *c.httpClient
+	hc.Timeout = 0
+
+	var resp *http.Response
+	err := retry.Do(ctx, retry.Host(c.baseURL), c.retry, func() error {
+		req, err := c.newRequest(ctx, r, nil)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		res, err := hc.Do(req)
+		if err != nil {
+			return c.transportError(ctx, err)
+		}
+		if err := checkResponse(res); err != nil {
+			res.Body.Close()
+			return err
+		}
+		resp = res
+		return nil
+	})
+	return resp, err
+}
+
+// transportError 请求未得到响应：ctx 已取消时不再重试
+func (c *Client) transportError(ctx context.Context, err error) error {
+	err = fmt.Errorf("请求 FlowForge 失败: %w", err)
+	if ctx.Err() != nil {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// checkResponse 将非 2xx 响应转换为 *Error，超时、限流与服务端错误可以重试
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err := parseError(resp)
+	if !retry.Retryable(resp.StatusCode) {
+		return retry.Permanent(err)
+	}
+	return err
+}
+
+// decodeData 解析成功响应：接口统一返回 {"data": ...}，少数早期接口直接返回对象
+func decodeData(body io.Reader, out interface{}) error {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+
+	var envelope map[string]json.RawMessage
+	if json.Unmarshal(raw, &envelope) == nil {
+		if data, ok := envelope["data"]; ok {
+			raw = data
+		}
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
+
+// idPath 拼接资源路径
+func idPath(format string, ids ...uint) string {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return fmt.Sprintf(format, args...)
+}
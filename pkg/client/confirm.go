@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// Challenge 删除项目等破坏性操作首次请求返回的确认信息，令牌只能使用一次
+type Challenge struct {
+	Token        string    `json:"confirmation_token"`
+	Operation    string    `json:"operation"`
+	ResourceID   uint      `json:"resource_id"`
+	ResourceName string    `json:"resource_name"`
+	Summary      Summary   `json:"summary"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Summary 操作将删除或影响的数据，Counts 的键如 pipelines、runs、artifacts
+type Summary struct {
+	Description string           `json:"description"`
+	Counts      map[string]int64 `json:"counts"`
+}
+
+// ConfirmFunc 收到确认信息后决定是否继续，返回回传的资源名称；名称与资源不一致时服务端拒绝执行。
+// 返回错误时放弃操作，令牌在有效期后失效
+type ConfirmFunc func(*Challenge) (string, error)
+
+// AutoConfirm 不询问直接回传服务端给出的资源名称，即命令行的 --yes；仍然完成两步确认，签发与执行都有审计记录
+func AutoConfirm(challenge *Challenge) (string, error) {
+	return challenge.ResourceName, nil
+}
+
+// ErrConfirmationDeclined 调用方放弃了需要确认的操作
+var ErrConfirmationDeclined = errors.New("已放弃操作")
+
+// 破坏性操作确认时回传的请求头
+const (
+	confirmationTokenHeader = "X-Confirmation-Token"
+	confirmationNameHeader  = "X-Confirmation-Name"
+)
+
+// confirmed 发送需要两步确认的请求：服务端返回 428 时由 confirm 决定回传的名称，携带令牌再次请求。
+// 资源已删除时第一次请求即返回 ErrNotFound
+func (c *Client) confirmed(ctx context.Context, r *request, confirm ConfirmFunc, out interface{}) error {
+	err := c.do(ctx, r, out)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Confirmation == nil {
+		return err
+	}
+	name, err := confirm(apiErr.Confirmation)
+	if err != nil {
+		return err
+	}
+
+	again := *r
+	again.header = r.header.Clone()
+	if again.header == nil {
+		again.header = http.Header{}
+	}
+	again.header.Set(confirmationTokenHeader, apiErr.Confirmation.Token)
+	again.header.Set(confirmationNameHeader, name)
+	return c.do(ctx, &again, out)
+}
+
+// DeleteProject 删除项目及其流水线与运行，需要确认
+func (c *Client) DeleteProject(ctx context.Context, projectID uint, confirm ConfirmFunc) error {
+	return c.confirmed(ctx, &request{method: http.MethodDelete, path: idPath("/projects/%d", projectID)}, confirm, nil)
+}
+
+// DeleteUser 删除用户，用户的 API 令牌失效，需要管理员权限与确认
+func (c *Client) DeleteUser(ctx context.Context, userID uint, confirm ConfirmFunc) error {
+	return c.confirmed(ctx, &request{method: http.MethodDelete, path: idPath("/users/%d", userID)}, confirm, nil)
+}
+
+// DeleteEnvironments 删除项目的全部环境变量，返回删除的数量；回传的名称为项目名称
+func (c *Client) DeleteEnvironments(ctx context.Context, projectID uint, confirm ConfirmFunc) (int64, error) {
+	var result struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := c.confirmed(ctx, &request{method: http.MethodDelete, path: idPath("/projects/%d/environments", projectID)}, confirm, &result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+// CancelAllRuns 取消流水线全部排队中与运行中的运行，返回取消的数量；没有未结束的运行时不需要确认。
+// 回传的名称为流水线名称
+func (c *Client) CancelAllRuns(ctx context.Context, pipelineID uint, reason string, confirm ConfirmFunc) (int, error) {
+	var result struct {
+		Cancelled int `json:"cancelled"`
+	}
+	r := &request{method: http.MethodPost, path: idPath("/pipelines/%d/runs/cancel-all", pipelineID), body: models.CancelRunRequest{Reason: reason}}
+	if err := c.confirmed(ctx, r, confirm, &result); err != nil {
+		return 0, err
+	}
+	return result.Cancelled, nil
+}
+
+// Usage 数量与字节数
+type Usage struct {
+	Count int64 `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+// RetentionTotals 按保留策略清理的数据量
+type RetentionTotals struct {
+	Runs           Usage `json:"runs"`
+	Logs           Usage `json:"logs"`
+	Artifacts      Usage `json:"artifacts"`
+	Workspaces     Usage `json:"workspaces"`
+	SourceArchives Usage `json:"source_archives"`
+}
+
+// PruneRetention 按服务端配置的保留策略立即清理，返回清理的数据量；需要管理员权限，回传的名称为 retention
+func (c *Client) PruneRetention(ctx context.Context, confirm ConfirmFunc) (*RetentionTotals, error) {
+	var result struct {
+		Totals RetentionTotals `json:"totals"`
+	}
+	if err := c.confirmed(ctx, &request{method: http.MethodPost, path: "/admin/retention/prune"}, confirm, &result); err != nil {
+		return nil, err
+	}
+	return &result.Totals, nil
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowforge/pkg/clock"
+	"flowforge/pkg/interlock"
+	"flowforge/pkg/retry"
+)
+
+// projectServer 模拟删除项目的两步确认：未携带令牌时返回 428，确认后删除，已删除的项目返回 404
+func projectServer(t *testing.T, name string) (*Client, *int) {
+	t.Helper()
+	store := interlock.NewStore(clock.Real, interlock.DefaultTTL)
+	deleted := false
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/v1/projects/7" {
+			t.Errorf("请求 %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case deleted:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "项目不存在", "code": "project_not_found"})
+		case r.Header.Get(confirmationTokenHeader) == "":
+			challenge := store.Issue(interlock.OperationDeleteProject, 7, name, 1, interlock.Summary{
+				Description: "删除项目 " + name,
+				Counts:      map[string]int64{"pipelines": 2, "runs": 40},
+			})
+			w.WriteHeader(http.StatusPreconditionRequired)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": "该操作需要确认", "code": "confirmation_required", "confirmation": challenge})
+		default:
+			err := store.Confirm(interlock.OperationDeleteProject, 7, name, 1, r.Header.Get(confirmationTokenHeader), r.Header.Get(confirmationNameHeader))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			deleted = true
+			json.NewEncoder(w).Encode(map[string]string{"message": "项目删除成功"})
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL, "token", WithRetry(retry.Policy{Attempts: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, &requests
+}
+
+func TestDeleteProjectAutoConfirm(t *testing.T) {
+	c, requests := projectServer(t, "web")
+	var seen *Challenge
+	confirm := func(challenge *Challenge) (string, error) {
+		seen = challenge
+		return AutoConfirm(challenge)
+	}
+	if err := c.DeleteProject(context.Background(), 7, confirm); err != nil {
+		t.Fatalf("自动确认应完成删除，实际为 %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("应先获取令牌再删除，共 %d 次请求", *requests)
+	}
+	if seen == nil || seen.ResourceName != "web" || seen.Summary.Counts["runs"] != 40 {
+		t.Fatalf("确认信息应包含资源名称与将被删除的数据，实际为 %+v", seen)
+	}
+
+	// 再次删除返回 404，不再签发令牌
+	if err := c.DeleteProject(context.Background(), 7, AutoConfirm); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("已删除的项目再次删除应返回 ErrNotFound，实际为 %v", err)
+	}
+	if *requests != 3 {
+		t.Errorf("再次删除应只有一次请求，共 %d 次请求", *requests)
+	}
+}
+
+func TestDeleteProjectNameMismatch(t *testing.T) {
+	c, _ := projectServer(t, "web")
+	err := c.DeleteProject(context.Background(), 7, func(*Challenge) (string, error) { return "api", nil })
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("回传的名称不一致应被拒绝，实际为 %v", err)
+	}
+	if err := c.DeleteProject(context.Background(), 7, AutoConfirm); err != nil {
+		t.Fatalf("重新确认后应可以删除，实际为 %v", err)
+	}
+}
+
+func TestDeleteProjectDeclined(t *testing.T) {
+	c, requests := projectServer(t, "web")
+	err := c.DeleteProject(context.Background(), 7, func(*Challenge) (string, error) { return "", ErrConfirmationDeclined })
+	if !errors.Is(err, ErrConfirmationDeclined) {
+		t.Fatalf("放弃确认应返回 ErrConfirmationDeclined，实际为 %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("放弃后不应再次请求，共 %d 次请求", *requests)
+	}
+}
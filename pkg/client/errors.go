@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 按响应状态分类的错误，用 errors.Is(err, client.ErrNotFound) 判断
+var (
+	// ErrUnauthorized 令牌无效、过期或已吊销
+	ErrUnauthorized = errors.New("认证失败")
+	// ErrForbidden 令牌没有该操作的权限或来源不在允许范围内
+	ErrForbidden = errors.New("没有权限")
+	// ErrNotFound 资源不存在或对当前用户不可见
+	ErrNotFound = errors.New("资源不存在")
+	// ErrConflict 资源状态不允许该操作，如项目已归档
+	ErrConflict = errors.New("资源状态冲突")
+	// ErrInvalidRequest 请求参数无效
+	ErrInvalidRequest = errors.New("请求参数无效")
+	// ErrRateLimited 超过速率限制
+	ErrRateLimited = errors.New("超过速率限制")
+	// ErrServer 服务端错误
+	ErrServer = errors.New("服务端错误")
+	// ErrConfirmationRequired 删除等操作需要先获取确认令牌
+	ErrConfirmationRequired = errors.New("该操作需要确认")
+)
+
+// codeConfirmationRequired 需要确认的操作返回的错误码
+const codeConfirmationRequired = "confirmation_required"
+
+// Error 接口返回的错误响应 {"error": "...", "code": "..."}
+type Error struct {
+	StatusCode int
+	// Code 错误码，为服务端错误码目录中的键（如 pipeline_not_found），不随语言变化；部分错误没有错误码
+	Code string
+	// Message 按请求语言翻译的错误信息
+	Message string
+	// Body 无法解析为错误响应时的原始响应内容（截断）
+	Body string
+	// Confirmation 需要确认的操作返回的确认令牌与将被删除的数据，见 ConfirmFunc
+	Confirmation *Challenge
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = e.Body
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("FlowForge 返回 %d（%s）: %s", e.StatusCode, e.Code, message)
+	}
+	return fmt.Sprintf("FlowForge 返回 %d: %s", e.StatusCode, message)
+}
+
+// Is 按状态码与错误码匹配上面的分类错误
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrConfirmationRequired:
+		return e.Code == codeConfirmationRequired
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrInvalidRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// HasCode err 是否为带有指定错误码的接口错误
+func HasCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// parseError 解析错误响应，响应体不是错误响应时保留原始内容
+func parseError(resp *http.Response) *Error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &Error{StatusCode: resp.StatusCode}
+
+	var body struct {
+		Error        string     `json:"error"`
+		Code         string     `json:"code"`
+		Confirmation *Challenge `json:"confirmation"`
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+		apiErr.Code = body.Code
+		apiErr.Confirmation = body.Confirmation
+		return apiErr
+	}
+	apiErr.Body = strings.TrimSpace(string(raw))
+	if apiErr.Body == "" {
+		apiErr.Body = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
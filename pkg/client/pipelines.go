@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// defaultPollInterval WaitForRun 与 FollowLogs 查询运行状态的默认间隔
+const defaultPollInterval = 2 * time.Second
+
+// ListPipelines 获取当前用户可见的流水线，偏移分页
+func (c *Client) ListPipelines(ctx context.Context, opts ListOptions) (*Page[models.Pipeline], error) {
+	opts.Cursor, opts.Limit = "", 0
+	var page Page[models.Pipeline]
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/pipelines", query: opts.values()}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetPipeline 获取流水线详情
+func (c *Client) GetPipeline(ctx context.Context, pipelineID uint) (*models.Pipeline, error) {
+	var pipeline models.Pipeline
+	if err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/pipelines/%d", pipelineID)}, &pipeline); err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
+
+// TriggerRun 手动触发流水线运行，返回创建的运行。触发请求不重试，请求失败时先用 ListRuns 确认是否已创建
+func (c *Client) TriggerRun(ctx context.Context, pipelineID uint, opts models.RunPipelineRequest) (*models.PipelineRun, error) {
+	var run models.PipelineRun
+	if err := c.do(ctx, &request{method: http.MethodPost, path: idPath("/pipelines/%d/run", pipelineID), body: opts}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// RunListOptions 运行记录的筛选与分页参数
+type RunListOptions struct {
+	ListOptions
+	DateRange
+	// Labels 只返回带有全部这些标签的运行
+	Labels []string
+	// CancellationReason 按取消原因筛选
+	CancellationReason string
+}
+
+// ListRuns 获取流水线的运行记录，按创建时间倒序
+func (c *Client) ListRuns(ctx context.Context, pipelineID uint, opts RunListOptions) (*Page[models.PipelineRun], error) {
+	query := opts.values()
+	opts.apply(query)
+	if len(opts.Labels) > 0 {
+		query.Set("label", strings.Join(opts.Labels, ","))
+	}
+	if opts.CancellationReason != "" {
+		query.Set("cancellation_reason", opts.CancellationReason)
+	}
+	var page Page[models.PipelineRun]
+	if err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/pipelines/%d/runs", pipelineID), query: query}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetRun 获取运行详情
+func (c *Client) GetRun(ctx context.Context, pipelineID, runID uint) (*models.PipelineRun, error) {
+	var run models.PipelineRun
+	if err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/pipelines/%d/runs/%d", pipelineID, runID)}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// CancelRun 取消运行，reason 记录为取消说明，可以为空
+func (c *Client) CancelRun(ctx context.Context, pipelineID, runID uint, reason string) error {
+	body := models.CancelRunRequest{Reason: reason}
+	return c.do(ctx, &request{method: http.MethodPost, path: idPath("/pipelines/%d/runs/%d/cancel", pipelineID, runID), body: body}, nil)
+}
+
+// WaitForRun 每隔 interval（为 0 时 2 秒）查询一次运行状态，直到运行结束或 ctx 结束，返回结束时的运行。
+// 需要执行进度时使用 StreamProgress
+func (c *Client) WaitForRun(ctx context.Context, pipelineID, runID uint, interval time.Duration) (*models.PipelineRun, error) {
+	return c.poll(ctx, pipelineID, runID, interval, nil)
+}
+
+// LogPage 运行日志中的一段
+type LogPage struct {
+	Logs     []string `json:"logs"`
+	Offset   int      `json:"offset"`
+	Total    int      `json:"total"`
+	Archived bool     `json:"archived"` // 日志已归档到外部存储
+}
+
+// GetLogs 读取运行日志从第 offset 行（从 0 开始）起的 limit 行，limit 为 0 时读取到末尾
+func (c *Client) GetLogs(ctx context.Context, pipelineID, runID uint, offset, limit int) (*LogPage, error) {
+	query := url.Values{"offset": {strconv.Itoa(offset)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var page LogPage
+	if err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/pipelines/%d/runs/%d/logs", pipelineID, runID), query: query}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// FollowLogs 持续读取运行日志，每次读到新的行时调用 fn，直到运行结束且日志读取完毕，返回结束时的运行。
+// fn 返回错误时停止并返回该错误
+func (c *Client) FollowLogs(ctx context.Context, pipelineID, runID uint, interval time.Duration, fn func(lines []string) error) (*models.PipelineRun, error) {
+	offset := 0
+	return c.poll(ctx, pipelineID, runID, interval, func() error {
+		page, err := c.GetLogs(ctx, pipelineID, runID, offset, 0)
+		if err != nil {
+			return err
+		}
+		if len(page.Logs) == 0 {
+			return nil
+		}
+		offset += len(page.Logs)
+		return fn(page.Logs)
+	})
+}
+
+// poll 按间隔查询运行状态，每次查询状态之后调用 after（可以为 nil）。
+// 先查询状态再调用 after，运行结束后的最后一次 after 能读到全部日志
+func (c *Client) poll(ctx context.Context, pipelineID, runID uint, interval time.Duration, after func() error) (*models.PipelineRun, error) {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run, err := c.GetRun(ctx, pipelineID, runID)
+		if err != nil {
+			return nil, err
+		}
+		if after != nil {
+			if err := after(); err != nil {
+				return run, err
+			}
+		}
+		if run.Conclusion() != "" {
+			return run, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return run, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"flowforge/pkg/progress"
+)
+
+// 运行进度流的事件类型
+const (
+	ProgressEventProgress = "progress" // 运行在服务端执行中，Snapshot 为当前进度
+	ProgressEventWaiting  = "waiting"  // 运行排队中或在其他实例执行，Status 为运行状态
+	ProgressEventFinished = "finished" // 运行已结束，Status 为最终状态，之后流关闭
+)
+
+// ProgressEvent 运行进度流中的一个事件
+type ProgressEvent struct {
+	Type     string
+	Snapshot *progress.Snapshot // 仅 progress 事件
+	Status   string             // waiting 与 finished 事件的运行状态
+}
+
+// StreamProgress 订阅运行进度（SSE），每收到一个事件调用 fn，直到收到 finished 事件、fn 返回错误或 ctx 结束。
+// 运行结束时返回 nil；服务端关闭连接（如实例重启）而运行未结束时返回错误，可重新订阅
+func (c *Client) StreamProgress(ctx context.Context, pipelineID, runID uint, fn func(ProgressEvent) error) error {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	resp, err := c.stream(ctx, &request{method: http.MethodGet, path: idPath("/pipelines/%d/runs/%d/progress", pipelineID, runID), header: header})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	events := newSSEReader(resp.Body)
+	for {
+		name, data, err := events.next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("运行进度流中断: %w", err)
+		}
+
+		event := ProgressEvent{Type: name}
+		switch name {
+		case ProgressEventProgress:
+			event.Snapshot = &progress.Snapshot{}
+			if err := json.Unmarshal(data, event.Snapshot); err != nil {
+				return fmt.Errorf("解析运行进度失败: %w", err)
+			}
+		case ProgressEventWaiting, ProgressEventFinished:
+			var body struct {
+				Status string `json:"status"`
+			}
+			if err := json.Unmarshal(data, &body); err != nil {
+				return fmt.Errorf("解析运行进度失败: %w", err)
+			}
+			event.Status = body.Status
+		default:
+			// 忽略新版本服务端增加的事件类型
+			continue
+		}
+
+		if err := fn(event); err != nil {
+			return err
+		}
+		if name == ProgressEventFinished {
+			return nil
+		}
+	}
+}
+
+// sseReader 按 text/event-stream 格式读取事件
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &sseReader{scanner: scanner}
+}
+
+// next 读取下一个事件，返回事件名（未指定时为 message）与数据；多行 data 以换行连接。连接关闭时返回 io.ErrUnexpectedEOF
+func (r *sseReader) next() (string, []byte, error) {
+	var name string
+	var data []string
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if name == "" && data == nil {
+				continue
+			}
+			if name == "" {
+				name = "message"
+			}
+			return name, []byte(strings.Join(data, "\n")), nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return "", nil, err
+	}
+	return "", nil, io.ErrUnexpectedEOF
+}
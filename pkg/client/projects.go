@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// Page 分页列表的一页，对应 models.PaginationResponse
+type Page[T any] struct {
+	Items      []T    `json:"data"`
+	Total      int64  `json:"total"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	TotalPages int    `json:"total_pages"`
+	NextCursor string `json:"next_cursor,omitempty"` // 游标分页时下一页的游标，没有更多记录时为空
+}
+
+// ListOptions 分页参数：Cursor 或 Limit 不为空时使用游标分页，否则按 Page、PageSize 偏移分页。
+// 游标分页在记录持续创建时翻页也不会重复或遗漏
+type ListOptions struct {
+	Page     int
+	PageSize int
+	Cursor   string
+	Limit    int
+}
+
+// values 分页参数的查询字符串
+func (o ListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Cursor != "" || o.Limit > 0 {
+		if o.Cursor != "" {
+			query.Set("cursor", o.Cursor)
+		}
+		if o.Limit > 0 {
+			query.Set("limit", strconv.Itoa(o.Limit))
+		}
+		return query
+	}
+	if o.Page > 0 {
+		query.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	return query
+}
+
+// DateRange 按创建日期筛选，From、To 均包含当天（服务端时区），为零值时不限制
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// apply 将日期范围写入查询字符串
+func (d DateRange) apply(query url.Values) {
+	if !d.From.IsZero() {
+		query.Set("from", d.From.Format("2006-01-02"))
+	}
+	if !d.To.IsZero() {
+		query.Set("to", d.To.Format("2006-01-02"))
+	}
+}
+
+// ListProjects 获取项目列表，includeArchived 为 true 时包括已归档的项目
+func (c *Client) ListProjects(ctx context.Context, includeArchived bool) ([]models.Project, error) {
+	query := url.Values{}
+	if includeArchived {
+		query.Set("include_archived", "true")
+	}
+	var projects []models.Project
+	err := c.do(ctx, &request{method: http.MethodGet, path: "/projects", query: query}, &projects)
+	return projects, err
+}
+
+// GetProject 获取项目详情，包括流水线与定时任务
+func (c *Client) GetProject(ctx context.Context, projectID uint) (*models.Project, error) {
+	var project models.Project
+	if err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/projects/%d", projectID)}, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// GetProjectBySlug 按 slug 获取项目详情
+func (c *Client) GetProjectBySlug(ctx context.Context, slug string) (*models.Project, error) {
+	var project models.Project
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/projects/by-slug/" + url.PathEscape(slug)}, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// DeploymentListOptions 部署记录的筛选与分页参数
+type DeploymentListOptions struct {
+	ListOptions
+	DateRange
+}
+
+// ListDeployments 获取项目的部署记录，按创建时间倒序
+func (c *Client) ListDeployments(ctx context.Context, projectID uint, opts DeploymentListOptions) (*Page[models.Deployment], error) {
+	query := opts.values()
+	opts.apply(query)
+	var page Page[models.Deployment]
+	if err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/projects/%d/deployments", projectID), query: query}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetDeployment 获取部署记录
+func (c *Client) GetDeployment(ctx context.Context, projectID, deploymentID uint) (*models.Deployment, error) {
+	var deployment models.Deployment
+	if err := c.do(ctx, &request{method: http.MethodGet, path: idPath("/projects/%d/deployments/%d", projectID, deploymentID)}, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// Deploy 部署项目，version 与 branch 可以为空。部署请求不重试，请求失败时先用 ListDeployments 确认是否已创建
+func (c *Client) Deploy(ctx context.Context, projectID uint, version, branch string) (*models.Deployment, error) {
+	body := models.DeployRequest{ProjectID: projectID, Version: version, Branch: branch}
+	var deployment models.Deployment
+	if err := c.do(ctx, &request{method: http.MethodPost, path: idPath("/projects/%d/deploy", projectID), body: body}, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
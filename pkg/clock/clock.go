@@ -0,0 +1,58 @@
+package clock
+
+import "time"
+
+// Clock 时间来源。调度器、清理任务、令牌签发与校验、部署冻结等按时间判断的逻辑通过 Clock 取时间，
+// 运行时使用 Real；测试中替换为 internal/clocktest 的可控时钟，推进时间即可验证到期行为，无需等待
+type Clock interface {
+	// Now 当前时间
+	Now() time.Time
+	// After 经过 d 后收到当时时间的通道，d 不大于 0 时立即收到
+	After(d time.Duration) <-chan time.Time
+	// NewTicker 每隔 d 触发一次的定时器
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 周期定时器，与 time.Ticker 对应
+type Ticker interface {
+	// C 每次触发时收到当时时间的通道，未及时读取时丢弃多余的触发
+	C() <-chan time.Time
+	// Stop 停止触发，不关闭通道
+	Stop()
+}
+
+// Real 系统时间
+var Real Clock = realClock{}
+
+// realClock 系统时间，直接使用 time 包
+type realClock struct{}
+
+// Now 当前时间
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After 经过 d 后收到当时时间的通道
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// NewTicker 每隔 d 触发一次的定时器
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker 包装 time.Ticker
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+// C 触发通道
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+// Stop 停止触发
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
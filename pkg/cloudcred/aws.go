@@ -0,0 +1,334 @@
+package cloudcred
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/models"
+	"flowforge/pkg/retry"
+)
+
+const (
+	// imdsEndpoint 实例元数据服务，未配置访问密钥时从实例角色获取基础凭证
+	imdsEndpoint = "http://169.254.169.254"
+	// instanceRefreshBefore 实例角色凭证过期前多久重新获取
+	instanceRefreshBefore = 5 * time.Minute
+)
+
+var (
+	awsRoleARN    = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]{1,512}$`)
+	awsRegion     = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+	awsExternalID = regexp.MustCompile(`^[\w+=,.@:/-]+$`)
+)
+
+// 外部ID的长度范围；正则的重复次数上限为 1000，长度单独校验
+const (
+	awsExternalIDMin = 2
+	awsExternalIDMax = 1224
+)
+
+// awsKeys 签名请求使用的访问密钥
+type awsKeys struct {
+	AccessKeyID     string    `json:"AccessKeyId" xml:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey" xml:"SecretAccessKey"`
+	SessionToken    string    `json:"Token" xml:"SessionToken"` // 元数据服务与 STS 的字段名不同
+	Expiration      time.Time `json:"Expiration" xml:"Expiration"`
+}
+
+// AWS 通过 STS AssumeRole 签发临时凭证
+type AWS struct {
+	config *config.AWSCloudConfig
+	// 元数据服务是链路本地地址，不经过共享客户端的出站策略
+	imds *http.Client
+
+	mu       sync.Mutex
+	instance *awsKeys
+}
+
+// assumeRoleResponse STS AssumeRole 的响应
+type assumeRoleResponse struct {
+	Result struct {
+		Credentials awsKeys `xml:"Credentials"`
+	} `xml:"AssumeRoleResult"`
+}
+
+// stsErrorResponse STS 的错误响应
+type stsErrorResponse struct {
+	Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+}
+
+// NewAWS 创建 AWS 临时凭证实现
+func NewAWS(cfg *config.AWSCloudConfig) *AWS {
+	return &AWS{
+		config: cfg,
+		imds:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Validate 校验角色ARN、区域、外部ID与有效期
+func (a *AWS) Validate(credential *models.CloudCredential) error {
+	if !awsRoleARN.MatchString(credential.RoleARN) {
+		return fmt.Errorf("角色ARN格式无效: %s", credential.RoleARN)
+	}
+	if credential.Region != "" && !awsRegion.MatchString(credential.Region) {
+		return fmt.Errorf("区域格式无效: %s", credential.Region)
+	}
+	if id := credential.ExternalID; id != "" && (len(id) < awsExternalIDMin || len(id) > awsExternalIDMax || !awsExternalID.MatchString(id)) {
+		return fmt.Errorf("外部ID格式无效")
+	}
+	if seconds := credential.DurationSeconds; seconds != 0 && (seconds < int(MinDuration.Seconds()) || seconds > int(MaxDuration.Seconds())) {
+		return fmt.Errorf("有效期应在 %d 到 %d 秒之间", int(MinDuration.Seconds()), int(MaxDuration.Seconds()))
+	}
+	return nil
+}
+
+// Issue 扮演角色签发临时凭证。会话名称包含运行ID，会话标签记录项目、流水线与运行，便于在 CloudTrail 中追溯
+func (a *AWS) Issue(ctx context.Context, credential *models.CloudCredential, session Session) (*Credentials, error) {
+	keys, err := a.baseKeys(ctx)
+	if err != nil {
+		return nil, classified(KindConfig, "获取基础凭证失败: %v", err)
+	}
+
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {credential.RoleARN},
+		"RoleSessionName": {fmt.Sprintf("flowforge-run-%d", session.RunID)},
+		"DurationSeconds": {fmt.Sprintf("%d", int(session.Duration.Seconds()))},
+	}
+	if credential.ExternalID != "" {
+		form.Set("ExternalId", credential.ExternalID)
+	}
+	tags := [][2]string{
+		{"flowforge:project-id", fmt.Sprintf("%d", session.ProjectID)},
+		{"flowforge:pipeline-id", fmt.Sprintf("%d", session.PipelineID)},
+		{"flowforge:run-id", fmt.Sprintf("%d", session.RunID)},
+	}
+	for i, tag := range tags {
+		form.Set(fmt.Sprintf("Tags.member.%d.Key", i+1), tag[0])
+		form.Set(fmt.Sprintf("Tags.member.%d.Value", i+1), tag[1])
+	}
+	body := form.Encode()
+
+	endpoint := a.config.STSEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", a.config.Region)
+	}
+
+	var result assumeRoleResponse
+	err = retry.Do(ctx, retry.Host(endpoint), retry.Default(), func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+		if err != nil {
+			return retry.Permanent(classified(KindConfig, "创建STS请求失败: %v", err))
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		signV4(req, []byte(body), keys, a.config.Region, "sts", time.Now())
+
+		resp, err := httpclient.Default().Do(req)
+		if err != nil {
+			return classified(KindUnavailable, "请求STS失败: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+		if resp.StatusCode != http.StatusOK {
+			return stsError(resp.StatusCode, data)
+		}
+		if err := xml.Unmarshal(data, &result); err != nil || result.Result.Credentials.AccessKeyID == "" {
+			return retry.Permanent(classified(KindUnavailable, "解析STS响应失败"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	region := credential.Region
+	if region == "" {
+		region = a.config.Region
+	}
+	issued := result.Result.Credentials
+	return &Credentials{
+		Env: map[string]string{
+			"AWS_ACCESS_KEY_ID":         issued.AccessKeyID,
+			"AWS_SECRET_ACCESS_KEY":     issued.SecretAccessKey,
+			"AWS_SESSION_TOKEN":         issued.SessionToken,
+			"AWS_REGION":                region,
+			"AWS_DEFAULT_REGION":        region,
+			"AWS_CREDENTIAL_EXPIRATION": issued.Expiration.UTC().Format(time.RFC3339),
+		},
+		Secrets:    []string{issued.AccessKeyID, issued.SecretAccessKey, issued.SessionToken},
+		Expiration: issued.Expiration,
+	}, nil
+}
+
+// stsError 按 STS 的错误码分类：限流与服务端错误可以重试，其余不重试
+func stsError(status int, data []byte) error {
+	var response stsErrorResponse
+	xml.Unmarshal(data, &response)
+	code, message := response.Error.Code, response.Error.Message
+	if code == "" {
+		code = fmt.Sprintf("HTTP %d", status)
+	}
+
+	switch {
+	case status >= 500 || code == "Throttling" || code == "ThrottlingException" || code == "RequestLimitExceeded":
+		return classified(KindUnavailable, "STS暂时不可用（%s）: %s", code, message)
+	case code == "AccessDenied":
+		return retry.Permanent(classified(KindDenied, "STS拒绝扮演角色（%s）: %s", code, message))
+	case code == "InvalidClientTokenId" || code == "SignatureDoesNotMatch" || code == "ExpiredToken" ||
+		code == "ValidationError" || code == "RegionDisabledException":
+		return retry.Permanent(classified(KindConfig, "STS请求无效（%s）: %s", code, message))
+	}
+	return retry.Permanent(classified(KindDenied, "STS返回错误（%s）: %s", code, message))
+}
+
+// baseKeys 扮演角色使用的基础凭证：配置的访问密钥，或实例角色的临时凭证
+func (a *AWS) baseKeys(ctx context.Context) (*awsKeys, error) {
+	if a.config.AccessKeyID != "" {
+		return &awsKeys{AccessKeyID: a.config.AccessKeyID, SecretAccessKey: a.config.SecretAccessKey}, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.instance != nil && time.Until(a.instance.Expiration) > instanceRefreshBefore {
+		return a.instance, nil
+	}
+
+	keys, err := a.instanceKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.instance = keys
+	return keys, nil
+}
+
+// instanceKeys 通过 IMDSv2 获取实例角色的凭证
+func (a *AWS) instanceKeys(ctx context.Context) (*awsKeys, error) {
+	token, err := a.metadata(ctx, http.MethodPut, "/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "21600"})
+	if err != nil {
+		return nil, fmt.Errorf("未配置访问密钥，且无法访问实例元数据服务: %w", err)
+	}
+	header := map[string]string{"X-aws-ec2-metadata-token": token}
+
+	roles, err := a.metadata(ctx, http.MethodGet, "/latest/meta-data/iam/security-credentials/", header)
+	if err != nil {
+		return nil, fmt.Errorf("实例没有关联角色: %w", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return nil, fmt.Errorf("实例没有关联角色")
+	}
+
+	data, err := a.metadata(ctx, http.MethodGet, "/latest/meta-data/iam/security-credentials/"+url.PathEscape(role), header)
+	if err != nil {
+		return nil, fmt.Errorf("获取实例角色凭证失败: %w", err)
+	}
+	var keys awsKeys
+	if err := json.Unmarshal([]byte(data), &keys); err != nil || keys.AccessKeyID == "" {
+		return nil, fmt.Errorf("解析实例角色凭证失败")
+	}
+	return &keys, nil
+}
+
+// metadata 请求实例元数据服务
+func (a *AWS) metadata(ctx context.Context, method, path string, header map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, imdsEndpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := a.imds.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("元数据服务返回状态 %d", resp.StatusCode)
+	}
+	return string(data), nil
+}
+
+// signV4 按 AWS Signature Version 4 签名请求
+func signV4(req *http.Request, body []byte, keys *awsKeys, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if keys.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", keys.SessionToken)
+	}
+
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         req.URL.Host,
+		"x-amz-date":   amzDate,
+	}
+	if keys.SessionToken != "" {
+		headers["x-amz-security-token"] = keys.SessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+keys.SecretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		keys.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hexSHA256 内容的 SHA-256 十六进制摘要
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 计算 HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
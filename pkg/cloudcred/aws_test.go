@@ -0,0 +1,34 @@
+package cloudcred
+
+import (
+	"strings"
+	"testing"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+)
+
+func TestAWSValidate(t *testing.T) {
+	aws := NewAWS(&config.AWSCloudConfig{})
+	const role = "arn:aws:iam::123456789012:role/deploy"
+	cases := []struct {
+		name       string
+		credential models.CloudCredential
+		valid      bool
+	}{
+		{"角色与区域", models.CloudCredential{RoleARN: role, Region: "cn-north-1"}, true},
+		{"无效的角色ARN", models.CloudCredential{RoleARN: "arn:aws:iam::1234:role/deploy"}, false},
+		{"无效的区域", models.CloudCredential{RoleARN: role, Region: "north"}, false},
+		{"外部ID", models.CloudCredential{RoleARN: role, ExternalID: "ci-deploy:prod"}, true},
+		{"最长的外部ID", models.CloudCredential{RoleARN: role, ExternalID: strings.Repeat("a", 1224)}, true},
+		{"过长的外部ID", models.CloudCredential{RoleARN: role, ExternalID: strings.Repeat("a", 1225)}, false},
+		{"过短的外部ID", models.CloudCredential{RoleARN: role, ExternalID: "a"}, false},
+		{"外部ID含空格", models.CloudCredential{RoleARN: role, ExternalID: "ci deploy"}, false},
+		{"有效期过长", models.CloudCredential{RoleARN: role, DurationSeconds: int(MaxDuration.Seconds()) + 1}, false},
+	}
+	for _, tc := range cases {
+		if err := aws.Validate(&tc.credential); (err == nil) != tc.valid {
+			t.Errorf("%s: Validate = %v，应%s", tc.name, err, map[bool]string{true: "通过", false: "拒绝"}[tc.valid])
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package cloudcred
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// 临时凭证有效期：未配置时的默认值与云平台允许的范围
+const (
+	DefaultDuration = time.Hour
+	MinDuration     = 15 * time.Minute
+	MaxDuration     = 12 * time.Hour
+)
+
+// 签发失败的分类，随错误写入运行日志与失败原因
+const (
+	KindConfig      = "config"      // 凭证配置或服务器基础凭证有误
+	KindDenied      = "denied"      // 云平台拒绝扮演角色，通常是信任策略或权限问题
+	KindUnavailable = "unavailable" // 云平台暂时不可用或限流，可以重试运行
+)
+
+// ErrUnknownProvider 没有该云平台的实现
+var ErrUnknownProvider = errors.New("不支持的云平台")
+
+// Session 签发临时凭证的运行信息，用于会话名称与云平台侧审计的会话标签
+type Session struct {
+	RunID      uint
+	ProjectID  uint
+	PipelineID uint
+	Duration   time.Duration
+}
+
+// Credentials 签发的临时凭证，以环境变量注入部署步骤
+type Credentials struct {
+	Env        map[string]string
+	Secrets    []string // 需要在运行日志中屏蔽的值
+	Expiration time.Time
+}
+
+// Provider 云平台的临时凭证实现；GCP、Azure 等以同样的接口注册
+type Provider interface {
+	// Validate 校验项目保存的角色配置
+	Validate(credential *models.CloudCredential) error
+	// Issue 以服务器的基础凭证扮演角色，签发本次运行使用的临时凭证
+	Issue(ctx context.Context, credential *models.CloudCredential, session Session) (*Credentials, error)
+}
+
+// Error 签发临时凭证失败，Kind 为失败分类
+type Error struct {
+	Kind string
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// classified 带分类的签发错误
+func classified(kind string, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// KindOf 错误的失败分类，未分类的错误视为云平台不可用
+func KindOf(err error) string {
+	var credErr *Error
+	if errors.As(err, &credErr) {
+		return credErr.Kind
+	}
+	return KindUnavailable
+}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]Provider)
+)
+
+// Init 按配置注册各云平台的实现
+func Init(cfg *config.CloudConfig) {
+	Register(models.CloudProviderAWS, NewAWS(&cfg.AWS))
+}
+
+// Register 注册云平台实现
+func Register(name string, provider Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[name] = provider
+}
+
+// Providers 已注册的云平台
+func Providers() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get 获取云平台实现
+func Get(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return provider, nil
+}
+
+// Lookup 部署到该环境时扮演的角色：优先使用环境单独的配置，其次使用未指定环境的配置；没有配置时返回 nil
+func Lookup(db *gorm.DB, projectID uint, environment string) (*models.CloudCredential, error) {
+	var credentials []models.CloudCredential
+	if err := db.Where("project_id = ? AND environment IN ?", projectID, []string{environment, ""}).
+		Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("读取云平台凭证配置失败: %w", err)
+	}
+
+	var fallback *models.CloudCredential
+	for i := range credentials {
+		if credentials[i].Environment == environment {
+			return &credentials[i], nil
+		}
+		fallback = &credentials[i]
+	}
+	return fallback, nil
+}
+
+// Issue 为运行签发临时凭证，有效期限制在云平台允许的范围内
+func Issue(ctx context.Context, credential *models.CloudCredential, session Session) (*Credentials, error) {
+	provider, err := Get(credential.Provider)
+	if err != nil {
+		return nil, &Error{Kind: KindConfig, Err: err}
+	}
+
+	if credential.DurationSeconds > 0 {
+		if configured := time.Duration(credential.DurationSeconds) * time.Second; session.Duration <= 0 || configured < session.Duration {
+			session.Duration = configured
+		}
+	}
+	if session.Duration <= 0 {
+		session.Duration = DefaultDuration
+	}
+	if session.Duration < MinDuration {
+		session.Duration = MinDuration
+	}
+	if session.Duration > MaxDuration {
+		session.Duration = MaxDuration
+	}
+
+	return provider.Issue(ctx, credential, session)
+}
@@ -0,0 +1,257 @@
+package compliance
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/utils"
+)
+
+// 任务类型
+const (
+	KindExport = "export" // 导出用户的全部数据
+	KindDelete = "delete" // 删除用户的个人数据
+)
+
+// 任务状态
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+const (
+	// confirmationTTL 确认令牌的有效期
+	confirmationTTL = 10 * time.Minute
+	// exportRetention 导出文件保留时间
+	exportRetention = 24 * time.Hour
+	// avatarDir 上传头像的存储目录
+	avatarDir = "./storage/avatars"
+)
+
+// ErrConfirmationInvalid 确认令牌不存在、已过期或与操作不匹配
+var ErrConfirmationInvalid = errors.New("确认令牌无效或已过期")
+
+// ErrJobRunning 同一用户已有进行中的同类任务
+var ErrJobRunning = errors.New("该用户已有进行中的任务")
+
+// Confirmation 执行导出或删除前需要提交的确认令牌，只能使用一次
+type Confirmation struct {
+	Token     string    `json:"confirmation_token"`
+	Kind      string    `json:"kind"`
+	UserID    uint      `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	requestedBy uint
+}
+
+// Job 导出或删除任务
+type Job struct {
+	ID          string           `json:"id"`
+	Kind        string           `json:"kind"`
+	UserID      uint             `json:"user_id"`
+	Status      string           `json:"status"`
+	Progress    int              `json:"progress"` // 0-100
+	Step        string           `json:"step"`
+	Error       string           `json:"error,omitempty"`
+	Counts      map[string]int64 `json:"counts"` // 各类数据导出或处理的条数
+	Size        int64            `json:"size,omitempty"`
+	RequestedBy uint             `json:"requested_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+
+	path string
+}
+
+// Manager 合规数据导出与删除任务管理
+type Manager struct {
+	config *config.Config
+	dir    string
+
+	mu            sync.Mutex
+	jobs          map[string]*Job
+	confirmations map[string]*Confirmation
+}
+
+// NewManager 创建合规任务管理器
+func NewManager(cfg *config.Config) *Manager {
+	return &Manager{
+		config:        cfg,
+		dir:           filepath.Join(cfg.Storage.Local.Path, "compliance-exports"),
+		jobs:          make(map[string]*Job),
+		confirmations: make(map[string]*Confirmation),
+	}
+}
+
+// RequestConfirmation 为管理员对用户的操作生成确认令牌，令牌只对同一管理员、同一用户与同一操作有效
+func (m *Manager) RequestConfirmation(kind string, userID, requestedBy uint) Confirmation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked()
+
+	confirmation := &Confirmation{
+		Token:       utils.GenerateRandomString(32),
+		Kind:        kind,
+		UserID:      userID,
+		ExpiresAt:   time.Now().Add(confirmationTTL),
+		requestedBy: requestedBy,
+	}
+	m.confirmations[confirmation.Token] = confirmation
+	return *confirmation
+}
+
+// Start 校验并消耗确认令牌后异步执行任务，返回任务快照
+func (m *Manager) Start(kind string, userID, requestedBy uint, token string) (Job, error) {
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return Job{}, fmt.Errorf("创建导出目录失败: %w", err)
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	if !m.consumeLocked(kind, userID, requestedBy, token) {
+		m.mu.Unlock()
+		return Job{}, ErrConfirmationInvalid
+	}
+	for _, job := range m.jobs {
+		if job.Kind == kind && job.UserID == userID && job.Status == JobStatusRunning {
+			m.mu.Unlock()
+			return Job{}, ErrJobRunning
+		}
+	}
+
+	job := &Job{
+		ID:          time.Now().Format("20060102-150405") + "-" + strings.ToLower(utils.GenerateRandomString(6)),
+		Kind:        kind,
+		UserID:      userID,
+		Status:      JobStatusRunning,
+		Step:        "准备",
+		Counts:      make(map[string]int64),
+		RequestedBy: requestedBy,
+		CreatedAt:   time.Now(),
+	}
+	if kind == KindExport {
+		job.path = filepath.Join(m.dir, fmt.Sprintf("user-%d-%s.zip", userID, job.ID))
+	}
+	m.jobs[job.ID] = job
+	snapshot := m.snapshotLocked(job)
+	m.mu.Unlock()
+
+	go m.run(job)
+	return snapshot, nil
+}
+
+// Get 获取任务快照
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return m.snapshotLocked(job), true
+}
+
+// FilePath 获取已完成导出任务的文件路径
+func (m *Manager) FilePath(id string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.Kind != KindExport || job.Status != JobStatusCompleted {
+		return "", false
+	}
+	return job.path, true
+}
+
+// run 执行任务并更新进度
+func (m *Manager) run(job *Job) {
+	progress := func(step string, percent int) {
+		m.mu.Lock()
+		job.Step, job.Progress = step, percent
+		m.mu.Unlock()
+	}
+	count := func(name string, n int64) {
+		m.mu.Lock()
+		job.Counts[name] += n
+		m.mu.Unlock()
+	}
+
+	var err error
+	switch job.Kind {
+	case KindExport:
+		err = m.export(job.UserID, job.path, progress, count)
+	case KindDelete:
+		err = deleteUserData(job.UserID, job.RequestedBy, progress, count)
+	default:
+		err = fmt.Errorf("未知的任务类型 %s", job.Kind)
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		if job.path != "" {
+			os.Remove(job.path)
+		}
+		log.Printf("用户 %d 的%s任务 %s 失败: %v", job.UserID, job.Kind, job.ID, err)
+		return
+	}
+	if job.path != "" {
+		if info, err := os.Stat(job.path); err == nil {
+			job.Size = info.Size()
+		}
+	}
+	job.Status, job.Progress, job.Step = JobStatusCompleted, 100, "完成"
+}
+
+// consumeLocked 校验确认令牌并使其失效，调用方需持有 m.mu
+func (m *Manager) consumeLocked(kind string, userID, requestedBy uint, token string) bool {
+	for key, confirmation := range m.confirmations {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) != 1 {
+			continue
+		}
+		delete(m.confirmations, key)
+		return confirmation.Kind == kind && confirmation.UserID == userID &&
+			confirmation.requestedBy == requestedBy && time.Now().Before(confirmation.ExpiresAt)
+	}
+	return false
+}
+
+// snapshotLocked 复制任务，调用方需持有 m.mu
+func (m *Manager) snapshotLocked(job *Job) Job {
+	snapshot := *job
+	snapshot.Counts = make(map[string]int64, len(job.Counts))
+	for name, n := range job.Counts {
+		snapshot.Counts[name] = n
+	}
+	return snapshot
+}
+
+// pruneLocked 删除过期的确认令牌与导出文件，调用方需持有 m.mu
+func (m *Manager) pruneLocked() {
+	for token, confirmation := range m.confirmations {
+		if time.Now().After(confirmation.ExpiresAt) {
+			delete(m.confirmations, token)
+		}
+	}
+	for id, job := range m.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > exportRetention {
+			if job.path != "" {
+				os.Remove(job.path)
+			}
+			delete(m.jobs, id)
+		}
+	}
+}
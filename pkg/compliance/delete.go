@@ -0,0 +1,180 @@
+package compliance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/events"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// userReferences 引用用户的外键列，删除个人数据后检查其中没有指向不存在用户的记录
+var userReferences = []struct {
+	table  string
+	column string
+}{
+	{"projects", "user_id"},
+	{"ssh_keys", "user_id"},
+	{"deployments", "user_id"},
+	{"pipeline_runs", "user_id"},
+	{"run_watches", "user_id"},
+	{"notifications", "user_id"},
+	{"api_tokens", "user_id"},
+	{"audit_logs", "user_id"},
+	{"feature_flags", "updated_by_id"},
+	{"pipeline_revisions", "created_by_id"},
+	{"deploy_freezes", "created_by_id"},
+	{"deploy_freezes", "lifted_by_id"},
+	{"redaction_rules", "created_by_id"},
+	{"outbound_exceptions", "created_by_id"},
+	{"run_labels", "created_by_id"},
+}
+
+// DeletedUsername 删除个人数据后用户的占位名称
+func DeletedUsername(userID uint) string {
+	return fmt.Sprintf("deleted-user-%d", userID)
+}
+
+// deleteUserData 删除用户的个人数据。运行、部署、项目等记录需要保留关联，
+// 因此用户记录保留为匿名占位；审计日志保留操作本身，清除 IP、UA 与文本中的用户名和邮箱。
+// 重复执行结果相同
+func deleteUserData(userID, requestedBy uint, progress func(string, int), count func(string, int64)) error {
+	var user models.User
+	if err := database.DB.Unscoped().First(&user, userID).Error; err != nil {
+		return fmt.Errorf("用户不存在: %w", err)
+	}
+
+	// 邮箱通常包含用户名，先替换邮箱；已删除的用户两者都已是占位值
+	placeholder := DeletedUsername(user.ID)
+	var pairs []string
+	for _, value := range []string{user.Email, user.Username} {
+		if value != "" && !strings.HasPrefix(value, placeholder) {
+			pairs = append(pairs, value, placeholder)
+		}
+	}
+	scrub := strings.NewReplacer(pairs...)
+
+	progress("清理审计日志", 10)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var batch []models.AuditLog
+		query := tx.Where("user_id = ? OR (resource_type = ? AND resource_id = ?)", user.ID, "user", user.ID)
+		result := query.FindInBatches(&batch, exportBatchSize, func(batchTx *gorm.DB, _ int) error {
+			for _, entry := range batch {
+				updates := map[string]interface{}{
+					"ip":          "",
+					"user_agent":  "",
+					"description": scrub.Replace(entry.Description),
+					"before":      scrub.Replace(entry.Before),
+					"after":       scrub.Replace(entry.After),
+				}
+				if err := tx.Model(&models.AuditLog{}).Where("id = ?", entry.ID).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			count("audit_logs_scrubbed", int64(len(batch)))
+			return nil
+		})
+		if result.Error != nil {
+			return fmt.Errorf("清理审计日志失败: %w", result.Error)
+		}
+
+		progress("删除个人记录", 40)
+		for name, model := range map[string]interface{}{
+			"notifications_deleted": &models.Notification{},
+			"run_watches_deleted":   &models.RunWatch{},
+			"api_tokens_deleted":    &models.APIToken{},
+		} {
+			result := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model)
+			if result.Error != nil {
+				return fmt.Errorf("删除个人记录失败: %w", result.Error)
+			}
+			count(name, result.RowsAffected)
+		}
+
+		progress("匿名化用户", 60)
+		if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"username":       placeholder,
+			"email":          placeholder + "@deleted.invalid",
+			"password":       "",
+			"avatar":         "",
+			"locale":         "",
+			"notify_channel": "none",
+			"notify_digest":  false,
+			"role":           models.RoleUser,
+			"status":         models.StatusDeleted,
+		}).Error; err != nil {
+			return fmt.Errorf("匿名化用户失败: %w", err)
+		}
+
+		// 保留下来引用该用户的记录，如运行与部署，显示为匿名占位
+		for _, table := range []string{"projects", "ssh_keys", "deployments", "pipeline_runs"} {
+			var n int64
+			tx.Table(table).Where("user_id = ?", user.ID).Count(&n)
+			count(table+"_kept", n)
+		}
+
+		requester := requestedBy
+		auditLog := models.AuditLog{
+			Action:       "user_data_deleted",
+			ResourceType: "user",
+			ResourceID:   user.ID,
+			Description:  fmt.Sprintf("删除用户 #%d 的个人数据，账号保留为 %s", user.ID, placeholder),
+			UserID:       &requester,
+		}
+		if err := tx.Create(&auditLog).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuditEvent(&auditLog, events.UserActor(requestedBy), ""))
+	})
+	if err != nil {
+		return err
+	}
+
+	progress("删除上传文件", 80)
+	if user.Avatar != "" {
+		path := filepath.Join(avatarDir, filepath.Base(user.Avatar))
+		if err := os.Remove(path); err == nil {
+			count("uploads_deleted", 1)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("删除头像失败: %w", err)
+		}
+	}
+
+	progress("检查外键", 90)
+	orphans, err := OrphanedReferences(database.DB)
+	if err != nil {
+		return err
+	}
+	if len(orphans) > 0 {
+		return fmt.Errorf("删除后存在指向不存在用户的记录: %s", strings.Join(orphans, ", "))
+	}
+	return nil
+}
+
+// OrphanedReferences 列出存在指向不存在用户的记录的外键列，软删除的用户记录视为存在
+func OrphanedReferences(db *gorm.DB) ([]string, error) {
+	var orphans []string
+	for _, ref := range userReferences {
+		if !db.Migrator().HasTable(ref.table) {
+			continue
+		}
+		var n int64
+		err := db.Table(ref.table).
+			Where(ref.column+" IS NOT NULL AND "+ref.column+" NOT IN (?)", db.Table("users").Select("id")).
+			Count(&n).Error
+		if err != nil {
+			return nil, fmt.Errorf("检查 %s.%s 失败: %w", ref.table, ref.column, err)
+		}
+		if n > 0 {
+			orphans = append(orphans, fmt.Sprintf("%s.%s（%d 条）", ref.table, ref.column, n))
+		}
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
@@ -0,0 +1,158 @@
+package compliance
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/support"
+
+	"gorm.io/gorm"
+)
+
+// exportBatchSize 每批读取的记录数，导出时内存占用与用户数据总量无关
+const exportBatchSize = 100
+
+// exportWriter 向导出包写入 JSONL 文件，每行都经过脱敏层
+type exportWriter struct {
+	zw    *zip.Writer
+	mask  *support.Masker
+	count func(name string, n int64)
+}
+
+// export 将用户的数据导出为 zip 包，每类数据一个 JSONL 文件
+func (m *Manager) export(userID uint, path string, progress func(string, int), count func(string, int64)) error {
+	var user models.User
+	if err := database.DB.Unscoped().First(&user, userID).Error; err != nil {
+		return fmt.Errorf("用户不存在: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	defer file.Close()
+
+	w := &exportWriter{zw: zip.NewWriter(file), mask: support.NewMasker(m.config), count: count}
+
+	sections := []struct {
+		step  string
+		write func() error
+	}{
+		{"个人资料", func() error {
+			return exportRows(w, "profile.jsonl", []models.User{user})
+		}},
+		{"拥有的项目", func() error {
+			return exportQuery[models.Project](w, "projects.jsonl", database.DB.Where("user_id = ?", userID))
+		}},
+		{"触发的运行", func() error {
+			// 运行日志与配置快照不属于个人数据，且体积不受限制，不导出
+			return exportQuery[models.PipelineRun](w, "pipeline_runs.jsonl",
+				database.DB.Omit("log_output", "resolved_config").Preload("Labels").Where("user_id = ?", userID))
+		}},
+		{"部署记录", func() error {
+			return exportQuery[models.Deployment](w, "deployments.jsonl", database.DB.Where("user_id = ?", userID))
+		}},
+		{"审计日志", func() error {
+			return exportQuery[models.AuditLog](w, "audit_logs.jsonl", database.DB.Where("user_id = ?", userID))
+		}},
+		{"站内通知", func() error {
+			return exportQuery[models.Notification](w, "notifications.jsonl", database.DB.Where("user_id = ?", userID))
+		}},
+		{"关注的运行", func() error {
+			return exportQuery[models.RunWatch](w, "run_watches.jsonl", database.DB.Where("user_id = ?", userID))
+		}},
+		{"SSH密钥", func() error {
+			return exportQuery[models.SSHKey](w, "ssh_keys.jsonl", database.DB.Where("user_id = ?", userID))
+		}},
+		{"API令牌", func() error {
+			return exportQuery[models.APIToken](w, "api_tokens.jsonl", database.DB.Where("user_id = ?", userID))
+		}},
+		{"上传文件", func() error {
+			return w.writeUploads(&user)
+		}},
+	}
+
+	for i, section := range sections {
+		progress(section.step, i*100/len(sections))
+		if err := section.write(); err != nil {
+			return fmt.Errorf("导出%s失败: %w", section.step, err)
+		}
+	}
+
+	if err := w.zw.Close(); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	return file.Close()
+}
+
+// exportQuery 分批读取查询结果并写入 JSONL 文件
+func exportQuery[T any](w *exportWriter, name string, query *gorm.DB) error {
+	entry, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	var batch []T
+	return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		return writeLines(w, entry, name, batch)
+	}).Error
+}
+
+// exportRows 将已加载的记录写入 JSONL 文件
+func exportRows[T any](w *exportWriter, name string, rows []T) error {
+	entry, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	return writeLines(w, entry, name, rows)
+}
+
+// writeLines 每条记录序列化为一行，脱敏后写入
+func writeLines[T any](w *exportWriter, entry io.Writer, name string, rows []T) error {
+	for i := range rows {
+		line, err := json.Marshal(&rows[i])
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(append(w.mask.Mask(line), '\n')); err != nil {
+			return err
+		}
+	}
+	w.count(name, int64(len(rows)))
+	return nil
+}
+
+// uploadRecord 用户上传的文件
+type uploadRecord struct {
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Included string `json:"included,omitempty"` // 文件在导出包中的路径，文件已不存在时为空
+}
+
+// writeUploads 导出用户上传的文件清单，并把文件本身放入导出包的 uploads 目录
+func (w *exportWriter) writeUploads(user *models.User) error {
+	var records []uploadRecord
+	if user.Avatar != "" {
+		record := uploadRecord{Type: "avatar", URL: user.Avatar}
+		name := filepath.Base(user.Avatar)
+		if data, err := os.Open(filepath.Join(avatarDir, name)); err == nil {
+			entry, err := w.zw.Create("uploads/" + name)
+			if err == nil {
+				_, err = io.Copy(entry, data)
+			}
+			data.Close()
+			if err != nil {
+				return err
+			}
+			record.Included = "uploads/" + name
+		}
+		records = append(records, record)
+	}
+	return exportRows(w, "uploads.jsonl", records)
+}
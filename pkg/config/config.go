@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -11,13 +13,83 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	JWT      JWTConfig      `yaml:"jwt"`
-	SSH      SSHConfig      `yaml:"ssh"`
-	Deploy   DeployConfig   `yaml:"deploy"`
-	Log      LogConfig      `yaml:"log"`
-	Storage  StorageConfig  `yaml:"storage"`
+	App ApplicationConfig `yaml:"app"`
+
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	SSH       SSHConfig       `yaml:"ssh"`
+	Deploy    DeployConfig    `yaml:"deploy"`
+	Log       LogConfig       `yaml:"log"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Security  SecurityConfig  `yaml:"security"`
+	Git       GitConfig       `yaml:"git"`
+	Network   NetworkConfig   `yaml:"network"`
+	Notify    NotifyConfig    `yaml:"notify"`
+	Events    EventsConfig    `yaml:"events"`
+	Backup    BackupConfig    `yaml:"backup"`
+	Cloud     CloudConfig     `yaml:"cloud"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Worker    WorkerConfig    `yaml:"worker"`
+	Isolation IsolationConfig `yaml:"isolation"`
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// RetentionConfig 按运行结束时间删除运行及其日志、制品与工作区的保留策略，由每日清理任务执行。
+// 制品与 skipped 运行的保留天数沿用 deploy.cleanup_after_days 与 deploy.skipped_run_keep_days；
+// 带有保留规则标签的运行只按标签规则清理
+type RetentionConfig struct {
+	RunKeepDays int                       `yaml:"run_keep_days"` // 运行结束超过该天数后删除，0 表示不删除
+	Pipelines   []PipelineRetentionConfig `yaml:"pipelines"`     // 按流水线覆盖全局天数
+}
+
+// PipelineRetentionConfig 单条流水线的保留天数，未设置的项使用全局配置
+type PipelineRetentionConfig struct {
+	PipelineID       uint `yaml:"pipeline_id"`
+	RunKeepDays      *int `yaml:"run_keep_days"`      // 0 表示不删除该流水线的运行
+	ArtifactKeepDays *int `yaml:"artifact_keep_days"` // 0 表示不清理该流水线的制品
+}
+
+// IsolationConfig 多租户隔离：各项目的工作区与依赖缓存放在项目自己的目录下并只允许项目用户访问，
+// 在本机执行的脚本以按需创建的项目专用系统用户运行。需要 Linux 与 root 权限，启动时不满足则告警并关闭
+type IsolationConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	UserPrefix string `yaml:"user_prefix"` // 项目系统用户名为前缀加项目ID，如 ff-p12
+}
+
+// WorkerConfig 独立执行器：以 -mode=worker 启动的实例只从数据库领取排队的运行并执行，不启动API服务器
+type WorkerConfig struct {
+	Name         string `yaml:"name"`          // 执行器名称，同名实例重启后沿用同一条注册记录，默认为主机名
+	Capacity     int    `yaml:"capacity"`      // 同时执行的运行数，默认为 deploy.max_concurrent
+	PollInterval int    `yaml:"poll_interval"` // 领取排队运行的间隔（秒）
+
+	// API实例是否在本地执行运行：auto 在有存活的执行器时交给执行器、没有时本地执行，
+	// always 总是本地执行，never 只交给执行器（没有存活的执行器时运行一直排队）
+	LocalExecution string `yaml:"local_execution"`
+}
+
+// CacheConfig 进程内缓存：系统配置、功能开关、用户认证信息与项目成员关系按 TTL 缓存，本实例修改时立即失效
+type CacheConfig struct {
+	Disabled bool `yaml:"disabled"` // 关闭后每次都查询数据库，用于排查缓存导致的问题
+	TTL      int  `yaml:"ttl"`      // 缓存时间（秒）：其他实例的修改或遗漏失效的修改最迟在该时间后生效
+}
+
+// CloudConfig 部署步骤扮演项目配置的云平台角色时使用的基础凭证，只由服务器使用，不注入步骤
+type CloudConfig struct {
+	AWS AWSCloudConfig `yaml:"aws"`
+}
+
+// AWSCloudConfig 调用 STS AssumeRole 的基础凭证；未配置访问密钥时使用实例角色（IMDSv2）
+type AWSCloudConfig struct {
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	Region          string `yaml:"region"`       // STS 区域，也是项目未配置区域时注入的 AWS_REGION
+	STSEndpoint     string `yaml:"sts_endpoint"` // 为空时使用区域终端节点 https://sts.<region>.amazonaws.com
+}
+
+// ApplicationConfig 应用配置
+type ApplicationConfig struct {
+	DataPath string `yaml:"data_path"` // 数据目录：项目工作区、脚本、源码包与依赖缓存放在其下
 }
 
 // ServerConfig 服务器配置
@@ -26,9 +98,85 @@ type ServerConfig struct {
 	Port         int       `yaml:"port"`
 	Mode         string    `yaml:"mode"`         // debug, release, test
 	ReadTimeout  int       `yaml:"read_timeout"`
-	WriteTimeout int       `yaml:"write_timeout"`
+	WriteTimeout int       `yaml:"write_timeout"` // 整个响应的写超时（秒），默认 0 不限制：普通接口由 handler_timeout 限制，流式接口按空闲时间限制
 	MaxHeaderMB  int       `yaml:"max_header_mb"`
 	TLS          TLSConfig `yaml:"tls"`
+
+	// 超时与关闭：普通接口处理超过 handler_timeout 返回 504；WebSocket、下载等流式接口不受限制，
+	// 连续 stream_idle_timeout 没有读写时断开；关闭时最多等待流式连接 drain_timeout
+	ReadHeaderTimeout int `yaml:"read_header_timeout"` // 秒
+	HandlerTimeout    int `yaml:"handler_timeout"`     // 秒
+	StreamIdleTimeout int `yaml:"stream_idle_timeout"` // 秒
+	DrainTimeout      int `yaml:"drain_timeout"`       // 秒
+
+	// 监听方式：network 为 unix 时监听 socket_path，否则监听 host:port
+	Network    string `yaml:"network"`     // tcp, tcp4, tcp6, unix
+	SocketPath string `yaml:"socket_path"` // unix 套接字路径
+	SocketMode string `yaml:"socket_mode"` // unix 套接字文件权限（八进制），默认 0660
+
+	// 多个监听，例如 TCP 提供API、unix 套接字供本机管理；设置后忽略上面的单个监听配置
+	Listeners []ListenerConfig `yaml:"listeners"`
+
+	WebSocket WebSocketConfig `yaml:"websocket"`
+
+	// 变更订阅接口 /changes 的变更日志保留时间与长轮询等待上限
+	Changes ChangesConfig `yaml:"changes"`
+
+	// 运行、部署、发布与审计日志列表导出 CSV、NDJSON 的限制
+	Export ExportConfig `yaml:"export"`
+
+	// 信任的反向代理（IP 或 CIDR），只采信这些地址转发的 X-Forwarded-For 与 X-Real-IP；
+	// 为空时不信任任何代理，客户端地址取连接的对端地址。Webhook与API令牌的来源限制按此得到的地址判断
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// WebSocketConfig WebSocket 连接的来源校验与限制。浏览器无法在 WebSocket 握手中设置认证头，
+// 前端先通过 POST /api/v1/ws/ticket 换取一次性票据，再以 ?ticket= 连接
+type WebSocketConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"` // 允许的页面来源，如 https://ci.example.com；为空时只允许同源，* 表示不限制
+	MaxConnections int      `yaml:"max_connections"` // 全部用户的并发连接上限，-1 表示不限制
+	MaxPerUser     int      `yaml:"max_per_user"`    // 单个用户的并发连接上限，-1 表示不限制
+	MaxMessageKB   int      `yaml:"max_message_kb"`  // 客户端单条消息的大小上限（KB）
+	TicketTTL      int      `yaml:"ticket_ttl"`      // 连接票据的有效期（秒）
+}
+
+// ExportConfig 列表导出配置：导出不分页，记录数超过 max_rows 时要求缩小筛选范围
+type ExportConfig struct {
+	MaxRows int `yaml:"max_rows"` // 默认 100000，-1 表示不限制
+}
+
+// ChangesConfig 变更订阅配置
+type ChangesConfig struct {
+	RetentionHours int `yaml:"retention_hours"`  // 变更日志保留时间（小时），由清理任务删除；游标早于已删除的日志时界面需重新获取全部列表
+	MaxWaitSeconds int `yaml:"max_wait_seconds"` // 长轮询最多等待的秒数，请求的 wait 超过时按此限制
+}
+
+// ListenerConfig 单个监听配置，TLS 只用于 TCP 监听
+type ListenerConfig struct {
+	Network    string `yaml:"network"`     // tcp, tcp4, tcp6, unix
+	Address    string `yaml:"address"`     // TCP 监听地址，如 127.0.0.1:8080、[::]:8080
+	SocketPath string `yaml:"socket_path"` // unix 套接字路径
+	SocketMode string `yaml:"socket_mode"` // unix 套接字文件权限（八进制），默认 0660
+}
+
+// ListenTargets 实际使用的监听配置：未配置 listeners 时由单个监听配置生成
+func (s *ServerConfig) ListenTargets() []ListenerConfig {
+	if len(s.Listeners) > 0 {
+		return s.Listeners
+	}
+	if s.Network == "unix" {
+		return []ListenerConfig{{Network: "unix", SocketPath: s.SocketPath, SocketMode: s.SocketMode}}
+	}
+
+	network := s.Network
+	if network == "" {
+		network = "tcp"
+	}
+	host := s.Host
+	if network == "tcp6" && host == "0.0.0.0" {
+		host = "::"
+	}
+	return []ListenerConfig{{Network: network, Address: net.JoinHostPort(host, strconv.Itoa(s.Port))}}
 }
 
 // TLSConfig TLS配置
@@ -50,6 +198,10 @@ type DatabaseConfig struct {
 	MaxOpenConns    int    `yaml:"max_open_conns"`
 	ConnMaxLifetime int    `yaml:"conn_max_lifetime"`
 	LogLevel        string `yaml:"log_level"`        // silent, error, warn, info
+	SlowQueryMs     int    `yaml:"slow_query_ms"`    // 慢查询阈值（毫秒），log_level 为 warn 或 info 时记录，-1 关闭
+
+	// 多个实例同时启动时只有一个执行表结构迁移与种子数据，其余实例等待迁移锁的最长时间（秒），默认 300
+	MigrationLockTimeout int `yaml:"migration_lock_timeout"`
 }
 
 // JWTConfig JWT配置
@@ -66,17 +218,102 @@ type SSHConfig struct {
 	MaxRetries  int    `yaml:"max_retries"`
 	DefaultUser string `yaml:"default_user"`
 	DefaultPort int    `yaml:"default_port"`
+
+	// 校验主机密钥使用的 known_hosts 文件，经跳板机连接时跳板机与目标主机分别校验；为空时不校验
+	KnownHostsFile string `yaml:"known_hosts_file"`
 }
 
 // DeployConfig 部署配置
 type DeployConfig struct {
-	WorkspaceDir      string `yaml:"workspace_dir"`
-	MaxConcurrent     int    `yaml:"max_concurrent"`
-	Timeout           int    `yaml:"timeout"`           // 秒
-	RetryCount        int    `yaml:"retry_count"`
-	CleanupAfterDays  int    `yaml:"cleanup_after_days"`
-	EnableWebhook     bool   `yaml:"enable_webhook"`
-	WebhookSecret     string `yaml:"webhook_secret"`
+	WorkspaceDir         string `yaml:"workspace_dir"`
+	MaxConcurrent        int    `yaml:"max_concurrent"`
+	Timeout              int    `yaml:"timeout"` // 秒
+	RetryCount           int    `yaml:"retry_count"`
+	CleanupAfterDays     int    `yaml:"cleanup_after_days"`
+	EnableWebhook        bool   `yaml:"enable_webhook"`
+	WebhookSecret        string `yaml:"webhook_secret"`
+	WebhookSecretOverlap int    `yaml:"webhook_secret_overlap"` // 轮换后旧密钥保留时间（秒）
+	WebhookDedupWindow   int    `yaml:"webhook_dedup_window"`   // 相同投递的去重窗口（秒）
+	RetainWorkspaceHours int    `yaml:"retain_workspace_hours"` // 失败运行与 keep_workspace 运行的工作区保留时间（小时），用于仅重跑失败步骤与浏览工作区
+	SkippedRunKeepDays   int    `yaml:"skipped_run_keep_days"`  // 被过滤的 skipped 运行保留天数，-1 表示不清理
+	DiskSafetyMarginMB   int    `yaml:"disk_safety_margin_mb"`  // 检出前要求额外保留的磁盘空间（MB）
+	HeartbeatTimeout     int    `yaml:"heartbeat_timeout"`      // 运行心跳超时（秒），超时视为执行器丢失
+	DriftPolicy          string `yaml:"drift_policy"`           // 部署到已漂移目标时的处理：warn 仅告警，block 阻止部署
+	DriftCheckCron       string `yaml:"drift_check_cron"`       // 定时漂移检查，为空时使用默认值
+	DriftCheckTimeout    int    `yaml:"drift_check_timeout"`    // 单个目标漂移检查的超时时间（秒）
+	PreflightTimeout     int    `yaml:"preflight_timeout"`      // 单个目标部署前检查的超时时间（秒）
+	PreflightCacheTTL    int    `yaml:"preflight_cache_ttl"`    // 部署前检查结果的缓存时间（秒），0 以下不缓存
+	MaxClockSkew         int    `yaml:"max_clock_skew"`         // 目标主机与服务器允许的时钟偏差（秒），超过时告警
+	MaxSourceArchiveMB   int    `yaml:"max_source_archive_mb"`  // 上传的源码包大小上限（MB）
+	MaxArchiveFiles      int    `yaml:"max_archive_files"`      // 源码包解压后的文件数上限
+	MaxArchiveExpandMB   int    `yaml:"max_archive_expand_mb"`  // 源码包解压后的总大小上限（MB）
+	MaxArchiveRatio      int    `yaml:"max_archive_ratio"`      // 源码包解压后大小与压缩包大小之比的上限
+	MaxScriptExecutions  int    `yaml:"max_script_executions"`  // 同时执行的脚本数上限，独立于运行队列的并发数
+	WorkspaceFileMaxMB   int    `yaml:"workspace_file_max_mb"`  // 浏览保留的工作区时可查看的单个文件大小上限（MB）
+	DebugSessionMinutes  int    `yaml:"debug_session_minutes"`  // 开启 debug_on_failure 的失败运行可打开调试终端的时限（分钟）
+
+	// 本机执行步骤进程的调度优先级，流水线可单独设置；无法应用时在运行日志中告警，步骤照常执行
+	ProcessNice    int    `yaml:"process_nice"`     // nice 值 -20~19，默认 0 不调整
+	ProcessIOClass string `yaml:"process_io_class"` // IO 调度类 best-effort、idle，为空时不调整
+	ProcessIOLevel int    `yaml:"process_io_level"` // best-effort 下的 IO 优先级 0~7，默认 4
+	ReservedCPUs   int    `yaml:"reserved_cpus"`    // 为服务保留的 CPU 核数，步骤进程不在这些核上运行，0 表示不保留
+
+	// 步骤耗时性能回退检查：成功运行的步骤耗时超过基线中位数的倍数时标注运行并通知，流水线可单独设置
+	PerfRegressionMultiplier float64 `yaml:"perf_regression_multiplier"` // 默认 3
+	PerfBaselineRuns         int     `yaml:"perf_baseline_runs"`         // 基线使用默认分支上最近的成功运行数，默认 20
+	PerfMinSamples           int     `yaml:"perf_min_samples"`           // 基线至少需要的运行数，不足时不检查，默认 5
+	PerfMinStepSeconds       int     `yaml:"perf_min_step_seconds"`      // 耗时低于该值的步骤不检查，默认 30
+
+	// 运行日志存储配额（按项目计算数据库中的日志量）：超过软配额时通知项目所有者，超过硬上限时清理任务将最早的日志
+	// 压缩后移到存储目录，项目可单独设置
+	LogSoftQuotaMB   int `yaml:"log_soft_quota_mb"`  // 默认 512，-1 表示不限制
+	LogHardCapMB     int `yaml:"log_hard_cap_mb"`    // 默认 1024，-1 表示不限制
+	LogCompressBatch int `yaml:"log_compress_batch"` // 每次清理任务最多压缩的运行日志数，默认 50
+
+	// 每个脚本步骤写入运行日志的输出上限（MB），超过时保留开头与结尾，默认 32，-1 表示不限制
+	MaxStepLogMB int `yaml:"max_step_log_mb"`
+
+	// 启动时加载的步骤类型插件目录（*.so），为空时不加载；也可以通过构建标签将步骤类型编译进服务端
+	StepPluginDir string `yaml:"step_plugin_dir"`
+
+	// 发布记录：部署到这些环境（部署步骤的 environment）成功后生成发布记录，列出与该环境上次发布之间的提交
+	ReleaseEnvironments []string `yaml:"release_environments"` // 默认 production
+	ReleaseNotify       bool     `yaml:"release_notify"`       // 将发布记录通知运行的关注者
+	ReleaseProvider     bool     `yaml:"release_provider"`     // 配置了托管平台令牌时在平台上创建带标签的 release
+
+	// 远程执行器批量上报运行日志：每个请求的大小上限（压缩后与解压后分别计算）与每个运行每秒可上报的行数，-1 表示不限制
+	LogIngestMaxBatchKB  int `yaml:"log_ingest_max_batch_kb"`  // 默认 1024
+	LogIngestLinesPerSec int `yaml:"log_ingest_lines_per_sec"` // 默认 2000
+
+	// 流水线配置的建议性检查在保存与试运行时返回 warnings，不阻止保存；列在这里的规则ID升级为阻止保存，
+	// 配置中以 # flowforge-lint-disable <规则ID> 忽略的除外。规则见 GET /api/v1/lint/rules
+	LintBlockingRules []string `yaml:"lint_blocking_rules"`
+
+	// 运行日志全文检索，默认关闭
+	LogSearch LogSearchConfig `yaml:"log_search"`
+
+	// 项目失败率突增告警
+	FailureSpike FailureSpikeConfig `yaml:"failure_spike"`
+}
+
+// FailureSpikeConfig 项目失败率突增告警：短窗口内的失败率超过长窗口基线的倍数且运行数足够时通知项目所有者与关注者，
+// 失败率回到基线后发送恢复通知。检测由一个实例执行（数据库租约），项目可单独设置倍数、最少运行数或关闭
+type FailureSpikeConfig struct {
+	Disabled        bool    `yaml:"disabled"`
+	Factor          float64 `yaml:"factor"`           // 短窗口失败率超过基线的倍数，默认 3
+	MinRate         float64 `yaml:"min_rate"`         // 短窗口失败率至少达到该值（0~1）才告警，避免基线接近 0 时个别失败触发，默认 0.5
+	MinRuns         int     `yaml:"min_runs"`         // 短窗口内至少结束的运行数，默认 5
+	ShortMinutes    int     `yaml:"short_minutes"`    // 短窗口（分钟），默认 30
+	LongHours       int     `yaml:"long_hours"`       // 长窗口（小时），基线为长窗口中除短窗口外的失败率，默认 24
+	CooldownMinutes int     `yaml:"cooldown_minutes"` // 告警后该时间内同一项目或流水线不再告警，避免恢复后立即再次失败时重复通知，默认 60
+}
+
+// LogSearchConfig 运行日志全文检索：日志写入后异步建立索引（内容已脱敏），按 GET /api/v1/search/logs 查找提到某段错误的运行。
+// 索引使用数据库自身的全文检索（SQLite FTS5/FTS4、Postgres tsvector、MySQL FULLTEXT），随运行由清理任务删除
+type LogSearchConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	MaxKBPerRun  int  `yaml:"max_kb_per_run"` // 每个运行建立索引的日志上限（KB），超出部分不可检索，默认 1024
+	QueueBatches int  `yaml:"queue_batches"`  // 等待建立索引的日志批次上限，积压超过时丢弃并计数，默认 1000
 }
 
 // LogConfig 日志配置
@@ -122,6 +359,119 @@ type OSSConfig struct {
 	AccessKeySecret string `yaml:"access_key_secret"`
 }
 
+// SecurityConfig 安全配置
+type SecurityConfig struct {
+	EncryptionKey string `yaml:"encryption_key"` // 静态数据加密密钥，为空时使用JWT密钥
+
+	Registration RegistrationConfig `yaml:"registration"`
+
+	AccessRequestExpireDays int `yaml:"access_request_expire_days"` // 加入项目申请的有效期（天）
+
+	ProviderRanges ProviderRangesConfig `yaml:"provider_ranges"`
+}
+
+// ProviderRangesConfig 托管平台公布的Webhook来源地址段，在Webhook与API令牌的来源限制中以 @github、@gitlab 引用；
+// 按 cron 定时刷新，刷新失败时继续使用上次成功获取的地址段
+type ProviderRangesConfig struct {
+	Cron      string `yaml:"cron"`       // 定时刷新，默认每 6 小时
+	GitHubURL string `yaml:"github_url"` // GitHub meta 接口，默认为 git.github_api_url 下的 /meta，取其中的 hooks
+	GitLabURL string `yaml:"gitlab_url"` // 返回地址段 JSON 数组的地址；GitLab 没有公布地址段的接口，为空时使用 GitLab.com 文档中的地址段
+}
+
+// RegistrationConfig 用户注册策略。配置了 policy 时以配置文件为准，管理接口不能修改；
+// 未配置时使用管理员在系统配置中设置的策略，新安装默认关闭注册，升级前已有的安装保持开放
+type RegistrationConfig struct {
+	Policy            string   `yaml:"policy"`              // open, closed, invite, domain
+	AllowedDomains    []string `yaml:"allowed_domains"`     // domain 策略允许的邮箱域名
+	VerifyEmail       bool     `yaml:"verify_email"`        // domain 策略下注册后需验证邮箱才能登录
+	InviteExpireHours int      `yaml:"invite_expire_hours"` // 邀请默认有效期（小时）
+}
+
+// GitConfig Git托管平台配置
+type GitConfig struct {
+	GitHubToken  string `yaml:"github_token"`
+	GitHubAPIURL string `yaml:"github_api_url"`
+	GitLabToken  string `yaml:"gitlab_token"`
+	GitLabURL    string `yaml:"gitlab_url"`
+
+	RepoNotFoundRuns int `yaml:"repo_not_found_runs"` // 连续多少次运行报告仓库不存在时将项目标记为异常，-1 表示不检查
+}
+
+// NetworkConfig 出站网络配置
+type NetworkConfig struct {
+	ProxyURL            string `yaml:"proxy_url"`             // 为空时使用 HTTP_PROXY/HTTPS_PROXY 环境变量
+	NoProxy             string `yaml:"no_proxy"`              // 不走代理的主机，逗号分隔
+	CACertFile          string `yaml:"ca_cert_file"`          // 额外信任的CA证书（PEM）
+	Timeout             int    `yaml:"timeout"`               // 请求超时（秒）
+	DialTimeout         int    `yaml:"dial_timeout"`          // 连接超时（秒）
+	TLSHandshakeTimeout int    `yaml:"tls_handshake_timeout"` // TLS握手超时（秒）
+	InsecureSkipVerify  bool   `yaml:"insecure_skip_verify"`  // 跳过证书校验，仅用于排障
+
+	Outbound OutboundPolicyConfig `yaml:"outbound"`
+	Retry    RetryConfig          `yaml:"retry"`
+}
+
+// RetryConfig 托管平台API、外部作业请求与邮件投递等基础设施请求的重试与熔断。
+// 同一目标主机连续失败达到阈值后熔断，冷却期间的请求直接失败，避免重试放大上游故障
+type RetryConfig struct {
+	Attempts         int `yaml:"attempts"`          // 最大尝试次数，1 表示不重试
+	BaseDelay        int `yaml:"base_delay"`        // 首次重试前的基础等待（毫秒），之后按次数翻倍并加随机抖动
+	MaxDelay         int `yaml:"max_delay"`         // 重试等待上限（毫秒）
+	BreakerThreshold int `yaml:"breaker_threshold"` // 连续失败多少次后熔断，-1 表示不熔断
+	BreakerCooldown  int `yaml:"breaker_cooldown"`  // 熔断后多久（秒）放行一次试探请求
+}
+
+// OutboundPolicyConfig 出站目标策略，作用于共享HTTP客户端发出的全部请求。
+// 默认禁止回环、链路本地（含云主机元数据地址）与内网地址；内网部署的 Git 平台等需要在 allow_cidrs 或 allow_hosts 中放行。
+// 优先级：deny_hosts、deny_cidrs 总是禁止，其次 allow_hosts、allow_cidrs 与项目出站例外放行，最后是默认禁止的地址
+type OutboundPolicyConfig struct {
+	AllowCIDRs   []string `yaml:"allow_cidrs"`   // 放行的网段或IP
+	DenyCIDRs    []string `yaml:"deny_cidrs"`    // 额外禁止的网段或IP
+	AllowHosts   []string `yaml:"allow_hosts"`   // 放行的主机名，支持 *.example.com；放行后不再检查默认禁止的地址
+	DenyHosts    []string `yaml:"deny_hosts"`    // 禁止的主机名，支持 *.example.com
+	AllowPrivate bool     `yaml:"allow_private"` // 不默认禁止回环、链路本地与内网地址
+}
+
+// NotifyConfig 通知配置
+type NotifyConfig struct {
+	BaseURL      string `yaml:"base_url"` // 通知中链接的站点地址
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	From         string `yaml:"from"`
+	DigestCron   string `yaml:"digest_cron"` // 每日汇总邮件发送时间
+}
+
+// BackupConfig 数据库备份配置。sqlite 使用在线备份接口复制，mysql 与 postgres 调用对应的导出工具，
+// 连接信息取自数据库配置；备份压缩并加密后保存在存储目录的 backups 下
+type BackupConfig struct {
+	Disabled      bool   `yaml:"disabled"`       // 关闭定时备份，管理接口仍可手动备份
+	Cron          string `yaml:"cron"`           // 定时备份时间
+	Keep          int    `yaml:"keep"`           // 保留最近成功的备份个数
+	Timeout       int    `yaml:"timeout"`        // 单次备份的超时时间（秒）
+	MysqldumpPath string `yaml:"mysqldump_path"` // mysqldump 路径，默认在 PATH 中查找
+	PgDumpPath    string `yaml:"pg_dump_path"`   // pg_dump 路径，默认在 PATH 中查找
+}
+
+// EventsConfig 系统事件投递配置：审计、运行与部署事件写入发件箱后按顺序投递到各接收端（如 SIEM），
+// 未配置接收端时不记录事件。投递使用 network 配置的代理与CA证书，并受 network.outbound 出站目标策略限制
+type EventsConfig struct {
+	Sinks         []EventSinkConfig `yaml:"sinks"`
+	RetentionDays int               `yaml:"retention_days"` // 所有接收端都已投递的事件在发件箱中保留的天数，可在此期间重放
+}
+
+// EventSinkConfig 事件接收端，只支持 HTTPS；使用 secret 对请求体做 HMAC 签名，或配置客户端证书做双向 TLS，两者可同时使用
+type EventSinkConfig struct {
+	Name       string   `yaml:"name"`
+	URL        string   `yaml:"url"`
+	Secret     string   `yaml:"secret"`       // 签名密钥，签名在 X-FlowForge-Signature 请求头
+	CertFile   string   `yaml:"cert_file"`    // 客户端证书（PEM）
+	KeyFile    string   `yaml:"key_file"`     // 客户端私钥（PEM）
+	CACertFile string   `yaml:"ca_cert_file"` // 校验接收端证书的CA，追加在系统CA与 network.ca_cert_file 之上
+	Types      []string `yaml:"types"`        // 只投递的事件类型前缀，如 audit.、run.，为空时投递全部事件
+}
+
 var (
 	AppConfig *Config
 )
@@ -157,6 +507,11 @@ func LoadConfig(configPath string) (*Config, error) {
 
 // overrideFromEnv 从环境变量覆盖配置
 func overrideFromEnv(config *Config) {
+	// 应用配置
+	if dataPath := os.Getenv("DATA_PATH"); dataPath != "" {
+		config.App.DataPath = dataPath
+	}
+
 	// 服务器配置
 	if host := os.Getenv("SERVER_HOST"); host != "" {
 		config.Server.Host = host
@@ -169,6 +524,12 @@ func overrideFromEnv(config *Config) {
 	if mode := os.Getenv("SERVER_MODE"); mode != "" {
 		config.Server.Mode = mode
 	}
+	if network := os.Getenv("SERVER_NETWORK"); network != "" {
+		config.Server.Network = network
+	}
+	if socketPath := os.Getenv("SERVER_SOCKET_PATH"); socketPath != "" {
+		config.Server.SocketPath = socketPath
+	}
 
 	// 数据库配置
 	if dbType := os.Getenv("DB_TYPE"); dbType != "" {
@@ -209,6 +570,32 @@ func overrideFromEnv(config *Config) {
 	if webhookSecret := os.Getenv("WEBHOOK_SECRET"); webhookSecret != "" {
 		config.Deploy.WebhookSecret = webhookSecret
 	}
+
+	// 安全配置
+	if encryptionKey := os.Getenv("ENCRYPTION_KEY"); encryptionKey != "" {
+		config.Security.EncryptionKey = encryptionKey
+	}
+
+	// Git托管平台配置
+	if githubToken := os.Getenv("GITHUB_TOKEN"); githubToken != "" {
+		config.Git.GitHubToken = githubToken
+	}
+	if gitlabToken := os.Getenv("GITLAB_TOKEN"); gitlabToken != "" {
+		config.Git.GitLabToken = gitlabToken
+	}
+
+	// 通知配置
+	if smtpPassword := os.Getenv("SMTP_PASSWORD"); smtpPassword != "" {
+		config.Notify.SMTPPassword = smtpPassword
+	}
+
+	// 出站网络配置
+	if proxyURL := os.Getenv("FLOWFORGE_PROXY_URL"); proxyURL != "" {
+		config.Network.ProxyURL = proxyURL
+	}
+	if caCertFile := os.Getenv("FLOWFORGE_CA_CERT_FILE"); caCertFile != "" {
+		config.Network.CACertFile = caCertFile
+	}
 }
 
 // validateConfig 验证配置
@@ -223,6 +610,25 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("无效的服务器模式: %s", config.Server.Mode)
 	}
 
+	validNetworks := []string{"tcp", "tcp4", "tcp6", "unix"}
+	for _, listener := range config.Server.ListenTargets() {
+		if !contains(validNetworks, listener.Network) {
+			return fmt.Errorf("无效的监听网络类型: %s", listener.Network)
+		}
+		if listener.Network == "unix" {
+			if listener.SocketPath == "" {
+				return fmt.Errorf("unix 监听未配置套接字路径")
+			}
+			if listener.SocketMode != "" {
+				if _, err := strconv.ParseUint(listener.SocketMode, 8, 32); err != nil {
+					return fmt.Errorf("无效的套接字文件权限: %s", listener.SocketMode)
+				}
+			}
+		} else if _, _, err := net.SplitHostPort(listener.Address); err != nil {
+			return fmt.Errorf("无效的监听地址 %s: %w", listener.Address, err)
+		}
+	}
+
 	// 验证数据库配置
 	validDBTypes := []string{"mysql", "postgres", "sqlite"}
 	if !contains(validDBTypes, config.Database.Type) {
@@ -249,6 +655,69 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("JWT密钥长度不能少于32位")
 	}
 
+	// 验证事件接收端配置
+	sinkNames := make(map[string]bool)
+	for _, sink := range config.Events.Sinks {
+		if sink.Name == "" || sinkNames[sink.Name] {
+			return fmt.Errorf("事件接收端名称为空或重复: %q", sink.Name)
+		}
+		sinkNames[sink.Name] = true
+		if !strings.HasPrefix(sink.URL, "https://") {
+			return fmt.Errorf("事件接收端 %s 只支持 HTTPS 地址", sink.Name)
+		}
+		if (sink.CertFile == "") != (sink.KeyFile == "") {
+			return fmt.Errorf("事件接收端 %s 的客户端证书与私钥需同时配置", sink.Name)
+		}
+		if sink.Secret == "" && sink.CertFile == "" {
+			return fmt.Errorf("事件接收端 %s 需配置签名密钥或客户端证书", sink.Name)
+		}
+	}
+
+	// 验证信任的代理
+	for _, proxy := range config.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("无效的信任代理地址: %s", proxy)
+			}
+		}
+	}
+
+	// 验证注册策略
+	validPolicies := []string{"open", "closed", "invite", "domain"}
+	if policy := config.Security.Registration.Policy; policy != "" && !contains(validPolicies, policy) {
+		return fmt.Errorf("不支持的注册策略: %s", policy)
+	}
+	if config.Security.Registration.Policy == "domain" && len(config.Security.Registration.AllowedDomains) == 0 {
+		return fmt.Errorf("domain 注册策略需配置允许的邮箱域名")
+	}
+
+	// 验证执行器配置
+	validLocalExecution := []string{"auto", "always", "never"}
+	if mode := config.Worker.LocalExecution; mode != "" && !contains(validLocalExecution, mode) {
+		return fmt.Errorf("不支持的本地执行方式: %s", mode)
+	}
+
+	// 验证隔离配置：系统用户名只能包含小写字母、数字、- 与 _，前缀加项目ID不能超过系统用户名的长度限制
+	if prefix := config.Isolation.UserPrefix; prefix != "" {
+		if len(prefix) > 20 || prefix[0] < 'a' || prefix[0] > 'z' ||
+			strings.Trim(prefix, "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+			return fmt.Errorf("隔离用户名前缀无效: %s", prefix)
+		}
+	}
+
+	// 验证保留策略
+	if config.Retention.RunKeepDays < 0 {
+		return fmt.Errorf("无效的运行保留天数: %d", config.Retention.RunKeepDays)
+	}
+	for _, pipeline := range config.Retention.Pipelines {
+		if pipeline.PipelineID == 0 {
+			return fmt.Errorf("流水线保留策略未指定流水线")
+		}
+		if (pipeline.RunKeepDays != nil && *pipeline.RunKeepDays < 0) || (pipeline.ArtifactKeepDays != nil && *pipeline.ArtifactKeepDays < 0) {
+			return fmt.Errorf("流水线 %d 的保留天数无效", pipeline.PipelineID)
+		}
+	}
+
 	// 验证存储配置
 	validStorageTypes := []string{"local", "s3", "oss"}
 	if !contains(validStorageTypes, config.Storage.Type) {
@@ -260,6 +729,15 @@ func validateConfig(config *Config) error {
 
 // setDefaults 设置默认值
 func setDefaults(config *Config) {
+	// 应用默认值
+	if config.App.DataPath == "" {
+		config.App.DataPath = "./data"
+	}
+	// 项目系统用户的主目录在数据目录下，useradd 要求绝对路径；工作区路径也不随工作目录变化
+	if abs, err := filepath.Abs(config.App.DataPath); err == nil {
+		config.App.DataPath = abs
+	}
+
 	// 服务器默认值
 	if config.Server.Host == "" {
 		config.Server.Host = "0.0.0.0"
@@ -273,12 +751,48 @@ func setDefaults(config *Config) {
 	if config.Server.ReadTimeout == 0 {
 		config.Server.ReadTimeout = 60
 	}
-	if config.Server.WriteTimeout == 0 {
-		config.Server.WriteTimeout = 60
+	if config.Server.ReadHeaderTimeout == 0 {
+		config.Server.ReadHeaderTimeout = 10
+	}
+	if config.Server.HandlerTimeout == 0 {
+		config.Server.HandlerTimeout = 30
+	}
+	if config.Server.StreamIdleTimeout == 0 {
+		config.Server.StreamIdleTimeout = 60
+	}
+	if config.Server.DrainTimeout == 0 {
+		config.Server.DrainTimeout = 30
+	}
+	if config.Server.WebSocket.MaxConnections == 0 {
+		config.Server.WebSocket.MaxConnections = 1000
+	}
+	if config.Server.WebSocket.MaxPerUser == 0 {
+		config.Server.WebSocket.MaxPerUser = 10
+	}
+	if config.Server.WebSocket.MaxMessageKB == 0 {
+		config.Server.WebSocket.MaxMessageKB = 64
+	}
+	if config.Server.WebSocket.TicketTTL == 0 {
+		config.Server.WebSocket.TicketTTL = 30
+	}
+	if config.Server.Export.MaxRows == 0 {
+		config.Server.Export.MaxRows = 100000
+	}
+	if config.Server.Changes.RetentionHours == 0 {
+		config.Server.Changes.RetentionHours = 24
+	}
+	if config.Server.Changes.MaxWaitSeconds == 0 {
+		config.Server.Changes.MaxWaitSeconds = 30
 	}
 	if config.Server.MaxHeaderMB == 0 {
 		config.Server.MaxHeaderMB = 1
 	}
+	if config.Server.Network == "" {
+		config.Server.Network = "tcp"
+	}
+	if config.Server.SocketMode == "" {
+		config.Server.SocketMode = "0660"
+	}
 
 	// 数据库默认值
 	if config.Database.Type == "" {
@@ -311,6 +825,12 @@ func setDefaults(config *Config) {
 	if config.Database.LogLevel == "" {
 		config.Database.LogLevel = "info"
 	}
+	if config.Database.SlowQueryMs == 0 {
+		config.Database.SlowQueryMs = 200
+	}
+	if config.Database.MigrationLockTimeout == 0 {
+		config.Database.MigrationLockTimeout = 300
+	}
 
 	// JWT默认值
 	if config.JWT.ExpireTime == 0 {
@@ -353,6 +873,123 @@ func setDefaults(config *Config) {
 	if config.Deploy.CleanupAfterDays == 0 {
 		config.Deploy.CleanupAfterDays = 7
 	}
+	if config.Deploy.RetainWorkspaceHours == 0 {
+		config.Deploy.RetainWorkspaceHours = 72
+	}
+	if config.Deploy.WorkspaceFileMaxMB == 0 {
+		config.Deploy.WorkspaceFileMaxMB = 10
+	}
+	if config.Deploy.DebugSessionMinutes == 0 {
+		config.Deploy.DebugSessionMinutes = 30
+	}
+	if config.Deploy.ProcessIOLevel == 0 {
+		config.Deploy.ProcessIOLevel = 4
+	}
+	if config.Deploy.PerfRegressionMultiplier == 0 {
+		config.Deploy.PerfRegressionMultiplier = 3
+	}
+	if config.Deploy.PerfBaselineRuns == 0 {
+		config.Deploy.PerfBaselineRuns = 20
+	}
+	if config.Deploy.PerfMinSamples == 0 {
+		config.Deploy.PerfMinSamples = 5
+	}
+	if config.Deploy.PerfMinStepSeconds == 0 {
+		config.Deploy.PerfMinStepSeconds = 30
+	}
+	if config.Deploy.LogSoftQuotaMB == 0 {
+		config.Deploy.LogSoftQuotaMB = 512
+	}
+	if config.Deploy.LogHardCapMB == 0 {
+		config.Deploy.LogHardCapMB = 1024
+	}
+	if config.Deploy.MaxStepLogMB == 0 {
+		config.Deploy.MaxStepLogMB = 32
+	}
+	if config.Deploy.LogCompressBatch == 0 {
+		config.Deploy.LogCompressBatch = 50
+	}
+	if config.Deploy.HeartbeatTimeout == 0 {
+		config.Deploy.HeartbeatTimeout = 120
+	}
+	if config.Deploy.DiskSafetyMarginMB == 0 {
+		config.Deploy.DiskSafetyMarginMB = 1024
+	}
+	if config.Deploy.WebhookSecretOverlap == 0 {
+		config.Deploy.WebhookSecretOverlap = 86400
+	}
+	if config.Deploy.WebhookDedupWindow == 0 {
+		config.Deploy.WebhookDedupWindow = 600
+	}
+	if config.Deploy.SkippedRunKeepDays == 0 {
+		config.Deploy.SkippedRunKeepDays = 7
+	}
+	if config.Deploy.DriftPolicy == "" {
+		config.Deploy.DriftPolicy = "warn"
+	}
+	if config.Deploy.DriftCheckCron == "" {
+		config.Deploy.DriftCheckCron = "0 0 */6 * * *"
+	}
+	if config.Deploy.DriftCheckTimeout == 0 {
+		config.Deploy.DriftCheckTimeout = 60
+	}
+	if config.Deploy.PreflightTimeout == 0 {
+		config.Deploy.PreflightTimeout = 30
+	}
+	if len(config.Deploy.ReleaseEnvironments) == 0 {
+		config.Deploy.ReleaseEnvironments = []string{"production"}
+	}
+	if config.Deploy.LogIngestMaxBatchKB == 0 {
+		config.Deploy.LogIngestMaxBatchKB = 1024
+	}
+	if config.Deploy.LogIngestLinesPerSec == 0 {
+		config.Deploy.LogIngestLinesPerSec = 2000
+	}
+	if config.Deploy.LogSearch.MaxKBPerRun == 0 {
+		config.Deploy.LogSearch.MaxKBPerRun = 1024
+	}
+	if config.Deploy.LogSearch.QueueBatches == 0 {
+		config.Deploy.LogSearch.QueueBatches = 1000
+	}
+	if config.Deploy.FailureSpike.Factor == 0 {
+		config.Deploy.FailureSpike.Factor = 3
+	}
+	if config.Deploy.FailureSpike.MinRate == 0 {
+		config.Deploy.FailureSpike.MinRate = 0.5
+	}
+	if config.Deploy.FailureSpike.MinRuns == 0 {
+		config.Deploy.FailureSpike.MinRuns = 5
+	}
+	if config.Deploy.FailureSpike.ShortMinutes == 0 {
+		config.Deploy.FailureSpike.ShortMinutes = 30
+	}
+	if config.Deploy.FailureSpike.LongHours == 0 {
+		config.Deploy.FailureSpike.LongHours = 24
+	}
+	if config.Deploy.FailureSpike.CooldownMinutes == 0 {
+		config.Deploy.FailureSpike.CooldownMinutes = 60
+	}
+	if config.Deploy.PreflightCacheTTL == 0 {
+		config.Deploy.PreflightCacheTTL = 60
+	}
+	if config.Deploy.MaxClockSkew == 0 {
+		config.Deploy.MaxClockSkew = 30
+	}
+	if config.Deploy.MaxSourceArchiveMB == 0 {
+		config.Deploy.MaxSourceArchiveMB = 512
+	}
+	if config.Deploy.MaxArchiveFiles == 0 {
+		config.Deploy.MaxArchiveFiles = 100000
+	}
+	if config.Deploy.MaxArchiveExpandMB == 0 {
+		config.Deploy.MaxArchiveExpandMB = 4096
+	}
+	if config.Deploy.MaxArchiveRatio == 0 {
+		config.Deploy.MaxArchiveRatio = 100
+	}
+	if config.Deploy.MaxScriptExecutions <= 0 {
+		config.Deploy.MaxScriptExecutions = 64
+	}
 
 	// 日志默认值
 	if config.Log.Level == "" {
@@ -381,6 +1018,121 @@ func setDefaults(config *Config) {
 	if config.Storage.Local.Path == "" {
 		config.Storage.Local.Path = "./storage"
 	}
+
+	// 安全默认值
+	if config.Security.EncryptionKey == "" {
+		config.Security.EncryptionKey = config.JWT.Secret
+	}
+	if config.Security.Registration.InviteExpireHours == 0 {
+		config.Security.Registration.InviteExpireHours = 168
+	}
+	if config.Security.AccessRequestExpireDays == 0 {
+		config.Security.AccessRequestExpireDays = 14
+	}
+
+	// Git默认值
+	if config.Git.GitHubAPIURL == "" {
+		config.Git.GitHubAPIURL = "https://api.github.com"
+	}
+	if config.Git.GitLabURL == "" {
+		config.Git.GitLabURL = "https://gitlab.com"
+	}
+	if config.Git.RepoNotFoundRuns == 0 {
+		config.Git.RepoNotFoundRuns = 3
+	}
+
+	// 托管平台地址段默认值
+	if config.Security.ProviderRanges.Cron == "" {
+		config.Security.ProviderRanges.Cron = "0 20 */6 * * *"
+	}
+	if config.Security.ProviderRanges.GitHubURL == "" {
+		config.Security.ProviderRanges.GitHubURL = strings.TrimSuffix(config.Git.GitHubAPIURL, "/") + "/meta"
+	}
+
+	// 通知默认值
+	if config.Notify.SMTPPort == 0 {
+		config.Notify.SMTPPort = 587
+	}
+	if config.Notify.DigestCron == "" {
+		config.Notify.DigestCron = "0 0 8 * * *"
+	}
+
+	// 数据库备份默认值
+	if config.Backup.Cron == "" {
+		config.Backup.Cron = "0 0 3 * * *"
+	}
+	if config.Backup.Keep == 0 {
+		config.Backup.Keep = 7
+	}
+	if config.Backup.Timeout == 0 {
+		config.Backup.Timeout = 3600
+	}
+	if config.Backup.MysqldumpPath == "" {
+		config.Backup.MysqldumpPath = "mysqldump"
+	}
+	if config.Backup.PgDumpPath == "" {
+		config.Backup.PgDumpPath = "pg_dump"
+	}
+
+	// 独立执行器默认值
+	if config.Worker.Name == "" {
+		config.Worker.Name, _ = os.Hostname()
+	}
+	if config.Worker.Capacity <= 0 {
+		config.Worker.Capacity = config.Deploy.MaxConcurrent
+	}
+	if config.Worker.PollInterval <= 0 {
+		config.Worker.PollInterval = 2
+	}
+	if config.Worker.LocalExecution == "" {
+		config.Worker.LocalExecution = "auto"
+	}
+
+	// 多租户隔离默认值
+	if config.Isolation.UserPrefix == "" {
+		config.Isolation.UserPrefix = "ff-p"
+	}
+
+	// 进程内缓存默认值
+	if config.Cache.TTL <= 0 {
+		config.Cache.TTL = 30
+	}
+
+	// 云平台临时凭证默认值
+	if config.Cloud.AWS.Region == "" {
+		config.Cloud.AWS.Region = "us-east-1"
+	}
+
+	// 系统事件默认值
+	if config.Events.RetentionDays == 0 {
+		config.Events.RetentionDays = 30
+	}
+
+	// 出站网络默认值
+	if config.Network.Timeout == 0 {
+		config.Network.Timeout = 30
+	}
+	if config.Network.DialTimeout == 0 {
+		config.Network.DialTimeout = 10
+	}
+	if config.Network.TLSHandshakeTimeout == 0 {
+		config.Network.TLSHandshakeTimeout = 10
+	}
+	if config.Network.Retry.Attempts == 0 {
+		config.Network.Retry.Attempts = 3
+	}
+	if config.Network.Retry.BaseDelay == 0 {
+		config.Network.Retry.BaseDelay = 500
+	}
+	if config.Network.Retry.MaxDelay == 0 {
+		config.Network.Retry.MaxDelay = 10000
+	}
+	if config.Network.Retry.BreakerThreshold == 0 {
+		config.Network.Retry.BreakerThreshold = 5
+	}
+	if config.Network.Retry.BreakerCooldown == 0 {
+		config.Network.Retry.BreakerCooldown = 30
+	}
 }
 
 // contains 检查切片是否包含指定元素
@@ -425,10 +1177,10 @@ func GetDatabaseDSN() string {
 	cfg := AppConfig.Database
 	switch cfg.Type {
 	case "mysql":
-		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
 			cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
 	case "postgres":
-		return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=Asia/Shanghai",
+		return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=UTC",
 			cfg.Host, cfg.Username, cfg.Password, cfg.Name, cfg.Port)
 	case "sqlite":
 		return cfg.Name
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"flowforge/pkg/models"
+)
+
+// prepareCancellationReason 新增运行取消原因字段时，升级前已取消的运行记为 unknown
+func prepareCancellationReason() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.PipelineRun{}) || migrator.HasColumn(&models.PipelineRun{}, "CancellationReason") {
+		return nil
+	}
+	if err := migrator.AddColumn(&models.PipelineRun{}, "CancellationReason"); err != nil {
+		return fmt.Errorf("添加字段 PipelineRun.CancellationReason 失败: %w", err)
+	}
+
+	result := DB.Model(&models.PipelineRun{}).Unscoped().Where("status = ?", models.RunStatusCancelled).
+		UpdateColumn("cancellation_reason", models.CancelReasonUnknown)
+	if result.Error != nil {
+		return fmt.Errorf("回填运行取消原因失败: %w", result.Error)
+	}
+	log.Printf("已将 %d 条已取消运行的取消原因记为 %s", result.RowsAffected, models.CancelReasonUnknown)
+	return nil
+}
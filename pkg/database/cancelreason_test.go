@@ -0,0 +1,57 @@
+package database
+
+import (
+	"testing"
+
+	"flowforge/pkg/models"
+)
+
+// TestCancellationReasonBackfill 升级时新增取消原因字段，已取消的历史运行记为 unknown，其他状态的运行不记录原因；
+// 字段已存在时再次迁移不修改已记录的原因
+func TestCancellationReasonBackfill(t *testing.T) {
+	setupTestDB(t)
+	if err := DB.Migrator().DropIndex(&models.PipelineRun{}, "CancellationReason"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Migrator().DropColumn(&models.PipelineRun{}, "CancellationReason"); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []string{models.RunStatusCancelled, models.RunStatusSuccess, models.RunStatusFailed}
+	ids := make(map[string]uint, len(statuses))
+	for i, status := range statuses {
+		if err := DB.Exec("INSERT INTO pipeline_runs (pipeline_id, user_id, run_number, status, trigger_type) VALUES (1, 1, ?, ?, ?)", i+1, status, models.TriggerManual).Error; err != nil {
+			t.Fatal(err)
+		}
+		var id uint
+		DB.Raw("SELECT id FROM pipeline_runs WHERE run_number = ?", i+1).Scan(&id)
+		ids[status] = id
+	}
+
+	if err := AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	for status, id := range ids {
+		var run models.PipelineRun
+		if err := DB.First(&run, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		want := ""
+		if status == models.RunStatusCancelled {
+			want = models.CancelReasonUnknown
+		}
+		if run.CancellationReason != want {
+			t.Errorf("状态为 %s 的历史运行取消原因为 %q，应为 %q", status, run.CancellationReason, want)
+		}
+	}
+
+	DB.Model(&models.PipelineRun{}).Where("id = ?", ids[models.RunStatusCancelled]).Update("cancellation_reason", models.CancelReasonUser)
+	if err := AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	var run models.PipelineRun
+	DB.First(&run, ids[models.RunStatusCancelled])
+	if run.CancellationReason != models.CancelReasonUser {
+		t.Errorf("再次迁移后取消原因为 %q，不应修改已记录的原因", run.CancellationReason)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor 分页游标格式无效
+var ErrInvalidCursor = errors.New("无效的分页游标")
+
+// Cursor 游标分页位置，指向上一页的最后一条记录
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// EncodeCursor 编码游标（创建时间+ID），对客户端不透明
+func EncodeCursor(createdAt time.Time, id uint) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.FormatUint(uint64(id), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor 解析游标，空字符串表示从第一页开始并返回 nil
+func DecodeCursor(value string) (*Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	i, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, n), ID: uint(i)}, nil
+}
+
+// CursorLimit 规范化游标分页的每页条数，默认20，最多100
+func CursorLimit(limit int) int {
+	if limit <= 0 {
+		return 20
+	}
+	if limit > 100 {
+		return 100
+	}
+	return limit
+}
+
+// CursorPaginate 游标分页：固定按 created_at DESC, id DESC 排序（ID 保证创建时间相同的记录顺序稳定），
+// 只返回游标之后的记录，因此翻页期间新增的记录只会出现在第一页之前，不会导致后续页重复或遗漏。
+// 多取一条用于判断是否还有下一页，调用方需截断到 limit 条
+func CursorPaginate(cursor *Cursor, limit int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cursor != nil {
+			db = db.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+		return db.Order("created_at DESC").Order("id DESC").Limit(limit + 1)
+	}
+}
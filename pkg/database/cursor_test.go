@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// cursorBase 测试运行的起始创建时间
+var cursorBase = time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+
+// createRunAt 以指定创建时间写入运行记录
+func createRunAt(t *testing.T, createdAt time.Time) *models.PipelineRun {
+	t.Helper()
+	run := &models.PipelineRun{PipelineID: 1, Status: models.RunStatusSuccess, TriggerType: models.TriggerManual}
+	run.CreatedAt = createdAt
+	if err := DB.Create(run).Error; err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+// fetchCursorPage 按游标读取一页运行，返回本页 ID 与下一页游标
+func fetchCursorPage(t *testing.T, cursor string, limit int) ([]uint, string) {
+	t.Helper()
+	position, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []models.PipelineRun
+	if err := DB.Model(&models.PipelineRun{}).Scopes(CursorPaginate(position, limit)).Find(&runs).Error; err != nil {
+		t.Fatal(err)
+	}
+	next := ""
+	if len(runs) > limit {
+		runs = runs[:limit]
+		last := runs[limit-1]
+		next = EncodeCursor(last.CreatedAt, last.ID)
+	}
+	ids := make([]uint, len(runs))
+	for i, run := range runs {
+		ids[i] = run.ID
+	}
+	return ids, next
+}
+
+// fetchOffsetPage 按偏移读取一页运行
+func fetchOffsetPage(t *testing.T, page, pageSize int) []uint {
+	t.Helper()
+	var ids []uint
+	if err := DB.Model(&models.PipelineRun{}).Order("created_at DESC").Order("id DESC").
+		Scopes(Paginate(page, pageSize)).Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// TestCursorPaginationDrift 翻页期间新增与删除运行：游标分页既不重复也不遗漏翻页前已存在且未被删除的运行，
+// 偏移分页则会重复上一页的记录
+func TestCursorPaginationDrift(t *testing.T) {
+	setupTestDB(t)
+	const limit = 5
+
+	// 每两个运行共用一个创建时间，检验相同时间按 ID 稳定排序
+	var existing []uint
+	for i := 0; i < 12; i++ {
+		existing = append(existing, createRunAt(t, cursorBase.Add(time.Duration(i/2)*time.Second)).ID)
+	}
+
+	first, cursor := fetchCursorPage(t, "", limit)
+	offsetFirst := fetchOffsetPage(t, 1, limit)
+
+	// 翻页之间：新建运行，删除一个已读到的运行和一个尚未读到的运行
+	for i := 0; i < 3; i++ {
+		createRunAt(t, cursorBase.Add(time.Hour+time.Duration(i)*time.Second))
+	}
+	deleted := existing[3]
+	if err := DB.Delete(&models.PipelineRun{}, first[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Delete(&models.PipelineRun{}, deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[uint]int{}
+	for _, id := range first {
+		seen[id]++
+	}
+	for pages := 1; cursor != ""; pages++ {
+		if pages > len(existing) {
+			t.Fatal("游标分页没有结束")
+		}
+		var ids []uint
+		ids, cursor = fetchCursorPage(t, cursor, limit)
+		for _, id := range ids {
+			seen[id]++
+		}
+	}
+
+	for _, id := range existing {
+		switch {
+		case id == deleted:
+			if seen[id] != 0 {
+				t.Errorf("翻页期间删除的运行 %d 不应出现在后续页", id)
+			}
+		case seen[id] != 1:
+			t.Errorf("运行 %d 出现 %d 次，应恰好出现 1 次", id, seen[id])
+		}
+	}
+	if len(seen) != len(existing)-1 {
+		t.Errorf("游标分页读到 %d 个运行，应只读到翻页前已存在的 %d 个", len(seen), len(existing)-1)
+	}
+
+	// 偏移分页：新运行把第一页的记录挤到第二页，再次出现
+	repeated := 0
+	for _, id := range fetchOffsetPage(t, 2, limit) {
+		for _, prev := range offsetFirst {
+			if id == prev {
+				repeated++
+			}
+		}
+	}
+	if repeated == 0 {
+		t.Error("偏移分页在翻页期间新增运行时应重复上一页的记录")
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	createdAt := cursorBase.Add(123 * time.Nanosecond)
+	cursor, err := DecodeCursor(EncodeCursor(createdAt, 42))
+	if err != nil || !cursor.CreatedAt.Equal(createdAt) || cursor.ID != 42 {
+		t.Fatalf("游标解析为 %+v, %v", cursor, err)
+	}
+	if cursor, err := DecodeCursor(""); cursor != nil || err != nil {
+		t.Errorf("空游标应返回 nil，实际为 %+v, %v", cursor, err)
+	}
+	for _, value := range []string{"!!!", "MTIz", "YWJjOjE", "MTIzOmFiYw"} {
+		if _, err := DecodeCursor(value); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("无效游标 %q 应返回 ErrInvalidCursor，实际为 %v", value, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"flowforge/pkg/config"
@@ -12,6 +13,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -25,7 +27,7 @@ func InitDatabase(cfg *config.Config) error {
 	// 根据配置选择数据库驱动
 	switch cfg.Database.Type {
 	case "mysql":
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
 			cfg.Database.Username,
 			cfg.Database.Password,
 			cfg.Database.Host,
@@ -34,7 +36,7 @@ func InitDatabase(cfg *config.Config) error {
 		)
 		dialector = mysql.Open(dsn)
 	case "postgres":
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=Asia/Shanghai",
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=UTC",
 			cfg.Database.Host,
 			cfg.Database.Username,
 			cfg.Database.Password,
@@ -48,9 +50,10 @@ func InitDatabase(cfg *config.Config) error {
 		return fmt.Errorf("不支持的数据库类型: %s", cfg.Database.Type)
 	}
 
-	// 配置GORM
+	// 配置GORM，时间统一以UTC写入
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(getLogLevel(cfg.Database.LogLevel)),
+		Logger:  newLogger(cfg.Database),
+		NowFunc: func() time.Time { return time.Now().UTC() },
 	}
 
 	// 连接数据库
@@ -75,42 +78,125 @@ func InitDatabase(cfg *config.Config) error {
 		return fmt.Errorf("数据库连接测试失败: %v", err)
 	}
 
+	setMigrationLock(cfg.Database)
+
 	log.Println("数据库连接成功")
 	return nil
 }
 
-// AutoMigrate 自动迁移数据库表结构
-func AutoMigrate() error {
-	if DB == nil {
-		return fmt.Errorf("数据库未初始化")
-	}
-
-	// 定义需要迁移的模型
-	models := []interface{}{
+// migratedModels 需要迁移的模型
+func migratedModels() []interface{} {
+	return []interface{}{
 		&models.User{},
 		&models.Project{},
 		&models.SSHKey{},
 		&models.Deployment{},
+		&models.DeploymentManifest{},
+		&models.Release{},
+		&models.ProjectPolicy{},
+		&models.CloudCredential{},
 		&models.Pipeline{},
+		&models.PipelineRevision{},
+		&models.SharedResource{},
+		&models.SharedResourceVersion{},
+		&models.RunResourceVersion{},
 		&models.PipelineRun{},
+		&models.RunLabel{},
+		&models.RunLabelRetention{},
 		&models.PipelineStep{},
+		&models.ExternalWait{},
+		&models.TestResult{},
+		&models.TestCaseFailure{},
 		&models.Environment{},
 		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.WebhookFingerprint{},
+		&models.RunWatch{},
+		&models.Notification{},
+		&models.AuditLog{},
+		&models.EventOutbox{},
+		&models.EventSinkCursor{},
+		&models.ChangeJournal{},
+		&models.APIToken{},
+		&models.FeedToken{},
+		&models.Invite{},
+		&models.EmailVerification{},
+		&models.DeployFreeze{},
+		&models.RedactionRule{},
+		&models.ArtifactBlob{},
+		&models.Artifact{},
+		&models.ArtifactConsumption{},
+		&models.ArtifactShare{},
+		&models.LogArchive{},
+		&models.RunLogSegment{},
+		&models.ProjectMember{},
+		&models.ProjectAccessRequest{},
+		&models.Backup{},
 		&models.SystemConfig{},
+		&models.Worker{},
+		&models.LeaderLease{},
+		&models.FailureSpikeAlert{},
+		&models.FeatureFlag{},
+		&models.OutboundException{},
+	}
+}
+
+// AutoMigrate 自动迁移数据库表结构
+func AutoMigrate() error {
+	if DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	// 升级前没有用户的数据库视为新安装，不存在按本地时间写入的旧数据
+	fresh := isFreshDatabase()
+
+	// 项目名称与slug的唯一索引要求先修正已有数据
+	if err := prepareProjectIndexes(); err != nil {
+		return fmt.Errorf("迁移项目数据失败: %v", err)
+	}
+
+	// 耗时字段改为毫秒，新增字段时按原有的秒数回填
+	if err := prepareDurationMs(); err != nil {
+		return fmt.Errorf("迁移耗时字段失败: %v", err)
+	}
+
+	// 日志存储配额按运行日志字节数统计，新增字段时按已有日志回填
+	if err := prepareLogSize(); err != nil {
+		return fmt.Errorf("迁移日志字节数字段失败: %v", err)
+	}
+
+	// 升级前取消的运行没有记录取消原因，新增字段时记为 unknown
+	if err := prepareCancellationReason(); err != nil {
+		return fmt.Errorf("迁移运行取消原因字段失败: %v", err)
+	}
+
+	// 功能开关新增用户覆盖，唯一索引需加入用户字段
+	if err := prepareFeatureFlagUsers(); err != nil {
+		return fmt.Errorf("迁移功能开关失败: %v", err)
 	}
 
 	// 执行自动迁移
-	for _, model := range models {
+	for _, model := range migratedModels() {
 		if err := DB.AutoMigrate(model); err != nil {
 			return fmt.Errorf("迁移模型 %T 失败: %v", model, err)
 		}
 	}
 
+	// 补建热点查询的索引，已有安装升级后也会创建
+	if err := ensureIndexes(); err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	// 旧版本按服务器本地时间写入的 MySQL 数据需先转换为UTC
+	if err := checkStoredTimeZone(fresh); err != nil {
+		return err
+	}
+
 	log.Println("数据库表结构迁移完成")
 	return nil
 }
 
-// SeedData 初始化种子数据
+// SeedData 初始化种子数据，按用户名、配置键等自然键写入，已存在的数据保持不变，可重复执行
 func SeedData() error {
 	if DB == nil {
 		return fmt.Errorf("数据库未初始化")
@@ -140,7 +226,7 @@ func createDefaultAdmin() error {
 		return nil
 	}
 
-	// 创建默认管理员
+	// 创建默认管理员，其他实例已创建同名用户时跳过
 	admin := models.User{
 		Username: "admin",
 		Email:    "admin@flowforge.com",
@@ -149,7 +235,23 @@ func createDefaultAdmin() error {
 		Status:   models.StatusActive,
 	}
 
-	if err := DB.Create(&admin).Error; err != nil {
+	result := DB.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "username"}}, DoNothing: true}).Create(&admin)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		log.Println("管理员用户已存在，跳过创建")
+		return nil
+	}
+
+	// 首次安装创建管理员后关闭自助注册，由管理员按需开放；升级的安装没有该设置，保持开放
+	policy := models.SystemConfig{
+		Key:         models.ConfigRegistrationPolicy,
+		Value:       models.RegistrationClosed,
+		Description: "用户注册策略（open, closed, invite, domain）",
+		Category:    "security",
+	}
+	if err := createSystemConfigIfMissing(&policy); err != nil {
 		return err
 	}
 
@@ -202,23 +304,36 @@ func createDefaultSystemConfig() error {
 			Category:    "integration",
 			IsPublic:    false,
 		},
+		{
+			Key:         "repo_config_allowed_steps",
+			Value:       "git_clone,script,build",
+			Description: "仓库配置文件允许使用的步骤类型（逗号分隔，* 表示不限制）",
+			Category:    "pipeline",
+			IsPublic:    false,
+		},
 	}
 
-	for _, config := range configs {
-		var existing models.SystemConfig
-		result := DB.Where("key = ?", config.Key).First(&existing)
-		
-		if result.Error == gorm.ErrRecordNotFound {
-			if err := DB.Create(&config).Error; err != nil {
-				return err
-			}
-			log.Printf("创建系统配置: %s", config.Key)
+	for i := range configs {
+		if err := createSystemConfigIfMissing(&configs[i]); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// createSystemConfigIfMissing 按配置键创建系统配置，键已存在（包括已删除的配置）时保持原值
+func createSystemConfigIfMissing(config *models.SystemConfig) error {
+	result := DB.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoNothing: true}).Create(config)
+	if result.Error != nil {
+		return fmt.Errorf("创建系统配置 %s 失败: %w", config.Key, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("创建系统配置: %s", config.Key)
+	}
+	return nil
+}
+
 // GetDB 获取数据库实例
 func GetDB() *gorm.DB {
 	return DB
@@ -238,6 +353,19 @@ func CloseDatabase() error {
 	return sqlDB.Close()
 }
 
+// newLogger 创建GORM日志，超过慢查询阈值的SQL以警告级别输出
+func newLogger(cfg config.DatabaseConfig) logger.Interface {
+	var slowThreshold time.Duration
+	if cfg.SlowQueryMs > 0 {
+		slowThreshold = time.Duration(cfg.SlowQueryMs) * time.Millisecond
+	}
+	return logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold: slowThreshold,
+		LogLevel:      getLogLevel(cfg.LogLevel),
+		Colorful:      true,
+	})
+}
+
 // getLogLevel 获取日志级别
 func getLogLevel(level string) logger.LogLevel {
 	switch level {
@@ -359,6 +487,7 @@ func GetStats() (map[string]interface{}, error) {
 		"idle":                    stats.Idle,
 		"wait_count":              stats.WaitCount,
 		"wait_duration":           stats.WaitDuration.String(),
+		"wait_duration_ms":        stats.WaitDuration.Milliseconds(),
 		"max_idle_closed":         stats.MaxIdleClosed,
 		"max_idle_time_closed":    stats.MaxIdleTimeClosed,
 		"max_lifetime_closed":     stats.MaxLifetimeClosed,
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"fmt"
+
+	"flowforge/pkg/models"
+)
+
+// prepareFeatureFlagUsers 功能开关新增用户覆盖时，删除原有按（名称, 项目）建立的唯一索引，
+// 由自动迁移按（名称, 项目, 用户）重建，否则同名的用户覆盖会与全局覆盖冲突
+func prepareFeatureFlagUsers() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.FeatureFlag{}) || migrator.HasColumn(&models.FeatureFlag{}, "UserID") {
+		return nil
+	}
+	if migrator.HasIndex(&models.FeatureFlag{}, "idx_feature_flag_scope") {
+		if err := migrator.DropIndex(&models.FeatureFlag{}, "idx_feature_flag_scope"); err != nil {
+			return fmt.Errorf("删除索引 idx_feature_flag_scope 失败: %w", err)
+		}
+	}
+	return nil
+}
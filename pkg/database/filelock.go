@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// fileLocker sqlite 的迁移锁：独占锁定数据库文件旁的锁文件，持有者将自己的标识写入文件。
+// 进程退出时操作系统释放锁，残留的锁文件不影响下次获取
+type fileLocker struct {
+	file   *os.File
+	locked bool
+}
+
+// newFileLocker 打开锁文件，不存在时创建
+func newFileLocker(path string) (*fileLocker, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开迁移锁文件失败: %w", err)
+	}
+	return &fileLocker{file: file}, nil
+}
+
+func (l *fileLocker) tryLock(ctx context.Context) (bool, error) {
+	ok, err := tryLockFile(l.file)
+	if err != nil || !ok {
+		return false, err
+	}
+	l.locked = true
+
+	if err := l.file.Truncate(0); err != nil {
+		return true, err
+	}
+	_, err = l.file.WriteAt([]byte(fmt.Sprintf("%s，获取于 %s\n", instanceName(), time.Now().UTC().Format(time.RFC3339))), 0)
+	return true, err
+}
+
+func (l *fileLocker) holder(ctx context.Context) (string, error) {
+	if !l.locked {
+		ok, err := tryLockFile(l.file)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			unlockFile(l.file)
+			return "", nil
+		}
+	}
+	content, err := os.ReadFile(l.file.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func (l *fileLocker) unlock(ctx context.Context) error {
+	if l.locked {
+		l.file.Truncate(0)
+		unlockFile(l.file)
+		l.locked = false
+	}
+	return l.file.Close()
+}
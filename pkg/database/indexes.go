@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// tableIndex 迁移时补建的索引。这些索引服务于列表、统计等热点查询，
+// 单独维护而不只依赖模型标签，已有安装升级后也能创建，且可指定列的排序方向
+type tableIndex struct {
+	table   string
+	name    string
+	columns []string
+}
+
+// hotIndexes 热点查询索引
+var hotIndexes = []tableIndex{
+	// 流水线运行列表、最近一次运行、运行编号与清理旧运行：
+	// WHERE pipeline_id = ? ORDER BY created_at DESC
+	{table: "pipeline_runs", name: "idx_pipeline_runs_pipeline_created", columns: []string{"pipeline_id", "created_at DESC"}},
+	// 项目部署历史按状态筛选、部署统计与最近成功部署：
+	// WHERE project_id = ? AND status = ?
+	{table: "deployments", name: "idx_deployments_project_status", columns: []string{"project_id", "status"}},
+	// 用户操作审计按时间范围查询：
+	// WHERE user_id = ? AND created_at BETWEEN ? AND ? ORDER BY created_at
+	{table: "audit_logs", name: "idx_audit_logs_user_created", columns: []string{"user_id", "created_at"}},
+	// 项目环境变量列表，以及运行开始时加载变量与脱敏用的密钥值：
+	// WHERE project_id = ?
+	{table: "environments", name: "idx_environments_project", columns: []string{"project_id"}},
+	// 用户的项目列表与项目权限校验（项目名称唯一索引以 name 开头，无法用于按所有者查询）：
+	// WHERE user_id = ?
+	{table: "projects", name: "idx_projects_user", columns: []string{"user_id"}},
+}
+
+// ensureIndexes 创建缺少的热点查询索引，已存在的索引跳过
+func ensureIndexes() error {
+	migrator := DB.Migrator()
+	for _, index := range hotIndexes {
+		if !migrator.HasTable(index.table) || migrator.HasIndex(index.table, index.name) {
+			continue
+		}
+		sql := fmt.Sprintf("CREATE INDEX %s ON %s (%s)", index.name, index.table, strings.Join(index.columns, ", "))
+		if err := DB.Exec(sql).Error; err != nil {
+			return fmt.Errorf("创建索引 %s 失败: %w", index.name, err)
+		}
+		log.Printf("已创建索引 %s", index.name)
+	}
+	return nil
+}
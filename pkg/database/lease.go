@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// AcquireLease 获取或续期名为 name 的租约，成功时本实例在 ttl 内是唯一的持有者。
+// 租约由本实例持有或已过期时以条件更新接管，多个实例同时接管时只有一个成功；
+// 持有者应在 ttl 内再次调用续期，停止续期后其他实例在过期后接管
+func AcquireLease(name string, ttl time.Duration) (bool, error) {
+	holder := instanceName()
+	now := time.Now()
+	expires := now.Add(ttl)
+
+	result := DB.Model(&models.LeaderLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": expires})
+	if result.Error != nil {
+		return false, fmt.Errorf("更新租约 %s 失败: %w", name, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 租约不存在时创建；其他实例已创建或正持有时失败
+	var count int64
+	if err := DB.Model(&models.LeaderLease{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询租约 %s 失败: %w", name, err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := DB.Create(&models.LeaderLease{Name: name, Holder: holder, ExpiresAt: expires}).Error; err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// ReleaseLease 释放本实例持有的租约，关闭时调用，其他实例不必等待过期即可接管
+func ReleaseLease(name string) error {
+	return DB.Model(&models.LeaderLease{}).Where("name = ? AND holder = ?", name, instanceName()).
+		Update("expires_at", time.Time{}).Error
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"flowforge/pkg/models"
+	"gorm.io/gorm"
+)
+
+// prepareLogSize 新增运行日志字节数字段时按已有日志回填，日志存储配额按该字段统计
+func prepareLogSize() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.PipelineRun{}) || migrator.HasColumn(&models.PipelineRun{}, "LogSize") {
+		return nil
+	}
+	if err := migrator.AddColumn(&models.PipelineRun{}, "LogSize"); err != nil {
+		return fmt.Errorf("添加字段 PipelineRun.LogSize 失败: %w", err)
+	}
+
+	result := DB.Model(&models.PipelineRun{}).Unscoped().Where("log_output <> ''").
+		UpdateColumn("log_size", gorm.Expr(byteLength("log_output")))
+	if result.Error != nil {
+		return fmt.Errorf("回填运行日志字节数失败: %w", result.Error)
+	}
+	log.Printf("已为 %d 条流水线运行回填日志字节数", result.RowsAffected)
+	return nil
+}
+
+// byteLength 按字节计算文本列长度的表达式，兼容各数据库方言
+func byteLength(column string) string {
+	switch DB.Dialector.Name() {
+	case "sqlite":
+		return "LENGTH(CAST(" + column + " AS BLOB))"
+	case "postgres":
+		return "OCTET_LENGTH(" + column + ")"
+	default:
+		return "LENGTH(" + column + ")"
+	}
+}
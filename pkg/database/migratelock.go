@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// migrationLockName MySQL 命名锁的名称
+const migrationLockName = "flowforge_migrate"
+
+// migrationLockKey Postgres advisory lock 的键
+const migrationLockKey int64 = 0x666c6f77666f7267 // "flowforg"
+
+// migrationLockPoll 等待迁移锁时重试的间隔
+const migrationLockPoll = time.Second
+
+// ErrMigrationLockTimeout 等待其他实例释放迁移锁超时
+var ErrMigrationLockTimeout = errors.New("等待数据库迁移锁超时")
+
+// migrationLock 迁移锁的配置，由 InitDatabase 设置
+var migrationLock struct {
+	timeout time.Duration
+	file    string // sqlite 使用的锁文件，内存数据库为空
+}
+
+// migrationLocker 数据库或文件上的迁移锁
+type migrationLocker interface {
+	// tryLock 尝试获取锁，不等待
+	tryLock(ctx context.Context) (bool, error)
+	// holder 持有锁的实例，没有实例持有时为空
+	holder(ctx context.Context) (string, error)
+	unlock(ctx context.Context) error
+}
+
+// setMigrationLock 按数据库配置设置迁移锁
+func setMigrationLock(cfg config.DatabaseConfig) {
+	migrationLock.timeout = time.Duration(cfg.MigrationLockTimeout) * time.Second
+	migrationLock.file = ""
+	if cfg.Type == "sqlite" && !strings.Contains(cfg.Name, ":memory:") && !strings.Contains(cfg.Name, "mode=memory") {
+		name := strings.TrimPrefix(cfg.Name, "file:")
+		if i := strings.Index(name, "?"); i >= 0 {
+			name = name[:i]
+		}
+		migrationLock.file = name + ".migrate.lock"
+	}
+}
+
+// instanceName 本实例的标识，写入锁信息与日志
+func instanceName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s (pid %d)", host, os.Getpid())
+}
+
+// newMigrationLocker 按数据库类型创建迁移锁：MySQL 使用 GET_LOCK，Postgres 使用 advisory lock，
+// sqlite 使用数据库文件旁的锁文件。数据库锁绑定在独占的连接上，连接断开时由数据库释放
+func newMigrationLocker(ctx context.Context) (migrationLocker, error) {
+	switch DB.Dialector.Name() {
+	case "mysql", "postgres":
+		sqlDB, err := DB.DB()
+		if err != nil {
+			return nil, fmt.Errorf("获取数据库实例失败: %w", err)
+		}
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("获取数据库连接失败: %w", err)
+		}
+		if DB.Dialector.Name() == "mysql" {
+			return &mysqlLocker{conn: conn}, nil
+		}
+		// 连接的 application_name 标识本实例，其他实例据此报告锁的持有者
+		name := "flowforge " + instanceName()
+		if _, err := conn.ExecContext(ctx, "SELECT set_config('application_name', $1, false)", name); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("设置连接名称失败: %w", err)
+		}
+		return &postgresLocker{conn: conn}, nil
+	default:
+		if migrationLock.file == "" {
+			return nil, nil
+		}
+		return newFileLocker(migrationLock.file)
+	}
+}
+
+// WithMigrationLock 持有迁移锁执行 fn，多个实例同时启动时只有一个执行迁移与种子数据，其余实例等待其完成后再执行。
+// 迁移与种子数据均可重复执行，等待的实例随后执行时不会重复创建数据。等待超过 migration_lock_timeout 时返回 ErrMigrationLockTimeout
+func WithMigrationLock(fn func() error) error {
+	if DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	ctx := context.Background()
+	locker, err := newMigrationLocker(ctx)
+	if err != nil {
+		return err
+	}
+	// 内存数据库只能被本进程访问，无需加锁
+	if locker == nil {
+		return fn()
+	}
+
+	if err := acquireMigrationLock(ctx, locker); err != nil {
+		locker.unlock(ctx)
+		return err
+	}
+	defer func() {
+		if err := locker.unlock(ctx); err != nil {
+			log.Printf("释放数据库迁移锁失败: %v", err)
+		}
+	}()
+
+	return fn()
+}
+
+// acquireMigrationLock 获取迁移锁，锁被其他实例持有时记录持有者并等待
+func acquireMigrationLock(ctx context.Context, locker migrationLocker) error {
+	deadline := time.Now().Add(migrationLock.timeout)
+	holder := ""
+	for {
+		ok, err := locker.tryLock(ctx)
+		if err != nil {
+			return fmt.Errorf("获取数据库迁移锁失败: %w", err)
+		}
+		if ok {
+			if holder != "" {
+				log.Printf("%s 已释放数据库迁移锁", holder)
+			}
+			log.Printf("已获取数据库迁移锁: %s", instanceName())
+			return nil
+		}
+
+		current, err := locker.holder(ctx)
+		if err != nil {
+			log.Printf("查询数据库迁移锁的持有者失败: %v", err)
+		}
+		if current == "" {
+			current = "其他实例"
+		}
+		if current != holder {
+			log.Printf("数据库迁移锁由 %s 持有，等待其完成迁移（最长 %s）", current, migrationLock.timeout)
+			holder = current
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w（持有者: %s）", ErrMigrationLockTimeout, holder)
+		}
+		time.Sleep(migrationLockPoll)
+	}
+}
+
+// MigrationLockHolder 当前持有迁移锁的实例，没有实例持有时返回空字符串
+func MigrationLockHolder() (string, error) {
+	if DB == nil {
+		return "", fmt.Errorf("数据库未初始化")
+	}
+
+	ctx := context.Background()
+	locker, err := newMigrationLocker(ctx)
+	if err != nil || locker == nil {
+		return "", err
+	}
+	defer locker.unlock(ctx)
+	return locker.holder(ctx)
+}
+
+// mysqlLocker MySQL 的命名锁
+type mysqlLocker struct {
+	conn   *sql.Conn
+	locked bool
+}
+
+func (l *mysqlLocker) tryLock(ctx context.Context) (bool, error) {
+	var result sql.NullInt64
+	if err := l.conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", migrationLockName).Scan(&result); err != nil {
+		return false, err
+	}
+	l.locked = result.Valid && result.Int64 == 1
+	return l.locked, nil
+}
+
+func (l *mysqlLocker) holder(ctx context.Context) (string, error) {
+	var id sql.NullInt64
+	if err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", migrationLockName).Scan(&id); err != nil {
+		return "", err
+	}
+	if !id.Valid {
+		return "", nil
+	}
+	var host string
+	err := l.conn.QueryRowContext(ctx, "SELECT HOST FROM information_schema.PROCESSLIST WHERE ID = ?", id.Int64).Scan(&host)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	if host == "" {
+		return fmt.Sprintf("连接 %d", id.Int64), nil
+	}
+	return fmt.Sprintf("连接 %d（%s）", id.Int64, host), nil
+}
+
+func (l *mysqlLocker) unlock(ctx context.Context) error {
+	return releaseLockConn(ctx, l.conn, l.locked, "SELECT RELEASE_LOCK(?)", migrationLockName)
+}
+
+// postgresLocker Postgres 的会话级 advisory lock
+type postgresLocker struct {
+	conn   *sql.Conn
+	locked bool
+}
+
+func (l *postgresLocker) tryLock(ctx context.Context) (bool, error) {
+	if err := l.conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&l.locked); err != nil {
+		return false, err
+	}
+	return l.locked, nil
+}
+
+func (l *postgresLocker) holder(ctx context.Context) (string, error) {
+	var pid int64
+	var name, addr sql.NullString
+	err := l.conn.QueryRowContext(ctx, `SELECT a.pid, a.application_name, host(a.client_addr)
+		FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+		AND ((l.classid::bigint << 32) | l.objid::bigint) = $1`, migrationLockKey).Scan(&pid, &name, &addr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	holder := strings.TrimPrefix(name.String, "flowforge ")
+	if holder == "" {
+		holder = fmt.Sprintf("后端进程 %d", pid)
+	}
+	if addr.String != "" {
+		holder += "，来自 " + addr.String
+	}
+	return holder, nil
+}
+
+func (l *postgresLocker) unlock(ctx context.Context) error {
+	return releaseLockConn(ctx, l.conn, l.locked, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+}
+
+// releaseLockConn 释放数据库锁并归还连接。释放失败时丢弃该连接，由数据库在连接断开时释放锁，
+// 避免持有锁的连接回到连接池
+func releaseLockConn(ctx context.Context, conn *sql.Conn, locked bool, query string, arg interface{}) error {
+	if !locked {
+		return conn.Close()
+	}
+	if _, err := conn.ExecContext(ctx, query, arg); err != nil {
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		conn.Close()
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+)
+
+// migrateHelperEnv 设置时 TestMigrateHelperProcess 作为一个实例迁移该 sqlite 文件
+const migrateHelperEnv = "FLOWFORGE_TEST_MIGRATE_DB"
+
+// fileDBConfig sqlite 文件数据库的配置
+func fileDBConfig(path string) *config.Config {
+	return &config.Config{Database: config.DatabaseConfig{
+		Type:                 "sqlite",
+		Name:                 path,
+		MaxIdleConns:         1,
+		MaxOpenConns:         1,
+		LogLevel:             "silent",
+		MigrationLockTimeout: 60,
+	}}
+}
+
+// TestMigrateHelperProcess 不是测试：由 TestConcurrentMigrations 以子进程启动，按服务启动的方式持有迁移锁执行迁移与种子数据。
+// 持锁期间创建 .running 标记文件，标记已存在说明另一实例同时在迁移；数据库还没有用户表时输出 applied，否则输出 skipped
+func TestMigrateHelperProcess(t *testing.T) {
+	path := os.Getenv(migrateHelperEnv)
+	if path == "" {
+		t.Skip("只在子进程中执行")
+	}
+	if err := InitDatabase(fileDBConfig(path)); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseDatabase()
+
+	err := WithMigrationLock(func() error {
+		marker, err := os.OpenFile(path+".running", os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return fmt.Errorf("另一实例正在迁移: %w", err)
+		}
+		marker.Close()
+		defer os.Remove(path + ".running")
+
+		applied := !DB.Migrator().HasTable(&models.User{})
+		// 延长持锁的时间，让其他实例在迁移期间尝试获取锁
+		time.Sleep(200 * time.Millisecond)
+		if err := AutoMigrate(); err != nil {
+			return err
+		}
+		if err := SeedData(); err != nil {
+			return err
+		}
+		if applied {
+			fmt.Println("migrate: applied")
+		} else {
+			fmt.Println("migrate: skipped")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestConcurrentMigrations 多个实例同时迁移同一个数据库时依次持有迁移锁：只有第一个实例执行迁移，其余实例等待后迁移为空操作，
+// 均不返回错误；管理员与每个系统配置只创建一次
+func TestConcurrentMigrations(t *testing.T) {
+	if os.Getenv(migrateHelperEnv) != "" {
+		t.Skip("子进程中不执行")
+	}
+	path := filepath.Join(t.TempDir(), "flowforge.db")
+
+	const instances = 3
+	outputs := make([]string, instances)
+	errs := make([]error, instances)
+	var wg sync.WaitGroup
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestMigrateHelperProcess$", "-test.v")
+			cmd.Env = append(os.Environ(), migrateHelperEnv+"="+path)
+			out, err := cmd.CombinedOutput()
+			outputs[i], errs[i] = string(out), err
+		}(i)
+	}
+	wg.Wait()
+
+	applied, waited := 0, 0
+	for i, out := range outputs {
+		if errs[i] != nil {
+			t.Fatalf("实例 %d 失败: %v\n%s", i, errs[i], out)
+		}
+		if strings.Contains(out, "migrate: applied") {
+			applied++
+		} else if !strings.Contains(out, "migrate: skipped") {
+			t.Fatalf("实例 %d 没有执行迁移:\n%s", i, out)
+		}
+		if strings.Contains(out, "等待其完成迁移") {
+			waited++
+		}
+	}
+	if applied != 1 {
+		t.Errorf("%d 个实例执行了迁移，应只有 1 个", applied)
+	}
+	if waited == 0 {
+		t.Errorf("没有实例等待迁移锁:\n%s", strings.Join(outputs, "\n"))
+	}
+
+	if err := InitDatabase(fileDBConfig(path)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDatabase() })
+	var admins int64
+	DB.Model(&models.User{}).Where("username = ?", "admin").Count(&admins)
+	if admins != 1 {
+		t.Errorf("创建了 %d 个管理员，应为 1 个", admins)
+	}
+	var keys []struct {
+		Key   string
+		Count int
+	}
+	DB.Model(&models.SystemConfig{}).Select("`key`, COUNT(*) AS count").Group("`key`").Scan(&keys)
+	seeded := 0
+	for _, key := range keys {
+		if key.Key == "site_name" || key.Key == models.ConfigRegistrationPolicy {
+			seeded++
+		}
+		if key.Count != 1 {
+			t.Errorf("系统配置 %s 创建了 %d 次", key.Key, key.Count)
+		}
+	}
+	if seeded != 2 {
+		t.Errorf("系统配置为 %+v，缺少种子数据", keys)
+	}
+}
+
+// TestMigrationLockTimeout 迁移锁被持有超过 migration_lock_timeout 时返回 ErrMigrationLockTimeout 并报告持有者，不执行迁移；
+// 持有者释放后可以获取
+func TestMigrationLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flowforge.db")
+	cfg := fileDBConfig(path)
+	cfg.Database.MigrationLockTimeout = 0
+	if err := InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDatabase() })
+
+	other, err := newFileLocker(path + ".migrate.lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := other.tryLock(context.Background()); !ok || err != nil {
+		t.Fatalf("获取锁返回 %v（%v）", ok, err)
+	}
+	if holder, err := MigrationLockHolder(); err != nil || !strings.Contains(holder, instanceName()) {
+		t.Errorf("持有者为 %q（%v），应包含 %s", holder, err, instanceName())
+	}
+
+	ran := false
+	err = WithMigrationLock(func() error { ran = true; return nil })
+	if !errors.Is(err, ErrMigrationLockTimeout) || !strings.Contains(err.Error(), instanceName()) || ran {
+		t.Errorf("锁被持有时返回 %v、执行 %v，应返回 ErrMigrationLockTimeout 并报告持有者", err, ran)
+	}
+
+	other.unlock(context.Background())
+	if holder, err := MigrationLockHolder(); err != nil || holder != "" {
+		t.Errorf("释放后持有者为 %q（%v）", holder, err)
+	}
+	if err := WithMigrationLock(func() error { ran = true; return nil }); err != nil || !ran {
+		t.Errorf("释放后返回 %v、执行 %v", err, ran)
+	}
+}
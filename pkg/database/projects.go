@@ -0,0 +1,93 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+)
+
+// maxSlugAttempts 生成唯一slug时尝试的后缀数量上限
+const maxSlugAttempts = 1000
+
+// UniqueProjectSlug 根据项目名称生成全局唯一的slug，已被占用时追加 -2、-3 等后缀；
+// 已删除项目的slug仍视为占用，避免旧链接指向其他项目。excludeID 为重新生成slug的项目自身
+func UniqueProjectSlug(name string, excludeID uint) (string, error) {
+	base := utils.Slugify(name)
+	for n := 1; n <= maxSlugAttempts; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+
+		var count int64
+		if err := DB.Unscoped().Model(&models.Project{}).Where("slug = ? AND id <> ?", slug, excludeID).Count(&count).Error; err != nil {
+			return "", fmt.Errorf("查询项目slug失败: %w", err)
+		}
+		if count == 0 {
+			return slug, nil
+		}
+	}
+	return "", fmt.Errorf("无法为项目 %s 生成唯一的slug", name)
+}
+
+// prepareProjectIndexes 在创建项目名称与slug唯一索引前修正已有数据：
+// 同一所有者下重名的项目依次追加 -2、-3 等后缀，缺少slug的项目按名称生成slug
+func prepareProjectIndexes() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.Project{}) {
+		return nil
+	}
+	if !migrator.HasColumn(&models.Project{}, "Slug") {
+		if err := migrator.AddColumn(&models.Project{}, "Slug"); err != nil {
+			return fmt.Errorf("添加项目slug字段失败: %w", err)
+		}
+	}
+
+	var projects []models.Project
+	if err := DB.Unscoped().Select("id", "name", "slug", "user_id").Order("id").Find(&projects).Error; err != nil {
+		return fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	names := make(map[string]bool, len(projects))
+	slugs := make(map[string]bool, len(projects))
+	for _, project := range projects {
+		if project.Slug != "" {
+			slugs[project.Slug] = true
+		}
+	}
+
+	for _, project := range projects {
+		updates := make(map[string]interface{})
+
+		name := project.Name
+		for n := 2; names[fmt.Sprintf("%d/%s", project.UserID, name)]; n++ {
+			name = fmt.Sprintf("%s-%d", project.Name, n)
+		}
+		names[fmt.Sprintf("%d/%s", project.UserID, name)] = true
+		if name != project.Name {
+			updates["name"] = name
+			log.Printf("项目 %d 与同一所有者的其他项目重名，已重命名为 %s", project.ID, name)
+		}
+
+		if project.Slug == "" {
+			base := utils.Slugify(name)
+			slug := base
+			for n := 2; slugs[slug]; n++ {
+				slug = fmt.Sprintf("%s-%d", base, n)
+			}
+			slugs[slug] = true
+			updates["slug"] = slug
+		}
+
+		if len(updates) == 0 {
+			continue
+		}
+		if err := DB.Unscoped().Model(&models.Project{}).Where("id = ?", project.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新项目 %d 失败: %w", project.ID, err)
+		}
+	}
+
+	return nil
+}
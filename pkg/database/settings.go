@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"flowforge/pkg/cache"
+	"flowforge/pkg/models"
+)
+
+// settingsCache 全部系统配置项，按缓存时间缓存；本实例修改系统配置后需调用 InvalidateSettings
+var settingsCache = cache.New("system_settings", func(ctx context.Context, _ struct{}) (map[string]string, error) {
+	var rows []models.SystemConfig
+	if err := DB.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询系统配置失败: %w", err)
+	}
+
+	settings := make(map[string]string, len(rows))
+	for _, row := range rows {
+		settings[row.Key] = row.Value
+	}
+	return settings, nil
+})
+
+// Setting 读取系统配置项，未设置时 ok 为 false
+func Setting(ctx context.Context, key string) (value string, ok bool, err error) {
+	settings, err := settingsCache.Get(ctx, struct{}{})
+	if err != nil {
+		return "", false, err
+	}
+	value, ok = settings[key]
+	return value, ok, nil
+}
+
+// InvalidateSettings 修改系统配置后使缓存失效
+func InvalidateSettings() {
+	settingsCache.InvalidateAll()
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// TouchSSHKey 记录SSH密钥被使用（Git或SSH操作），失败只记录日志
+func TouchSSHKey(keyID uint) {
+	if DB == nil || keyID == 0 {
+		return
+	}
+
+	if err := DB.Model(&models.SSHKey{}).Where("id = ?", keyID).Updates(map[string]interface{}{
+		"use_count":    gorm.Expr("use_count + 1"),
+		"last_used_at": time.Now(),
+	}).Error; err != nil {
+		log.Printf("记录SSH密钥 %d 使用情况失败: %v", keyID, err)
+	}
+}
+
+// ErrSSHKeyInUse SSH密钥仍被项目或其他密钥引用，不能删除
+var ErrSSHKeyInUse = errors.New("SSH密钥仍被使用，请先替换或解除引用")
+
+// SSHKeyReference 引用SSH密钥的资源
+type SSHKeyReference struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   uint   `json:"resource_id"`
+	Name         string `json:"name"`
+	Field        string `json:"field"`
+}
+
+// SSHKeyReferences 查询引用密钥的项目（项目SSH密钥、部署密钥及轮换中的部署密钥），以及将其作为跳板机密钥的其他密钥
+func SSHKeyReferences(db *gorm.DB, keyID uint) ([]SSHKeyReference, error) {
+	var projects []models.Project
+	if err := db.Select("id", "name", "ssh_key_id", "deploy_key_id", "pending_deploy_key_id").
+		Where("ssh_key_id = ? OR deploy_key_id = ? OR pending_deploy_key_id = ?", keyID, keyID, keyID).
+		Find(&projects).Error; err != nil {
+		return nil, err
+	}
+
+	refs := make([]SSHKeyReference, 0, len(projects))
+	for _, p := range projects {
+		ref := SSHKeyReference{ResourceType: "project", ResourceID: p.ID, Name: p.Name}
+		switch {
+		case p.SSHKeyID != nil && *p.SSHKeyID == keyID:
+			ref.Field = "ssh_key_id"
+		case p.DeployKeyID != nil && *p.DeployKeyID == keyID:
+			ref.Field = "deploy_key_id"
+		default:
+			ref.Field = "pending_deploy_key_id"
+		}
+		refs = append(refs, ref)
+	}
+
+	var keys []models.SSHKey
+	if err := db.Select("id", "name").Where("bastion_key_id = ? AND id <> ?", keyID, keyID).Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		refs = append(refs, SSHKeyReference{ResourceType: "ssh_key", ResourceID: k.ID, Name: k.Name, Field: "bastion_key_id"})
+	}
+
+	return refs, nil
+}
+
+// DeleteSSHKey 删除未被引用的SSH密钥；仍被引用时返回 ErrSSHKeyInUse 与引用它的资源。
+// 检查引用与删除在同一事务中，检查之后新增的引用不会留下悬空的密钥ID
+func DeleteSSHKey(key *models.SSHKey) ([]SSHKeyReference, error) {
+	var refs []SSHKeyReference
+	err := Transaction(func(tx *gorm.DB) error {
+		var err error
+		if refs, err = SSHKeyReferences(tx, key.ID); err != nil {
+			return fmt.Errorf("查询密钥引用失败: %w", err)
+		}
+		if len(refs) > 0 {
+			return ErrSSHKeyInUse
+		}
+		return tx.Delete(key).Error
+	})
+	return refs, err
+}
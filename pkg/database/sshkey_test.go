@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+)
+
+// setupTestDB 以测试名命名的内存数据库
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDatabase() })
+	if err := AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDeleteSSHKeyInUse 被项目或作为跳板机密钥引用的密钥不能删除，错误中列出引用它的资源；解除引用后可以删除
+func TestDeleteSSHKeyInUse(t *testing.T) {
+	setupTestDB(t)
+	key := &models.SSHKey{Name: "deploy", PrivateKey: "private", UserID: 1}
+	if err := DB.Create(key).Error; err != nil {
+		t.Fatal(err)
+	}
+	project := &models.Project{Name: "web", UserID: 1, SSHKeyID: &key.ID}
+	if err := DB.Create(project).Error; err != nil {
+		t.Fatal(err)
+	}
+	jump := &models.SSHKey{Name: "jump", PrivateKey: "private", UserID: 1}
+	jump.BastionKeyID = &key.ID
+	if err := DB.Create(jump).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := DeleteSSHKey(key)
+	if !errors.Is(err, ErrSSHKeyInUse) {
+		t.Fatalf("删除仍被引用的密钥应返回 ErrSSHKeyInUse，实际为 %v", err)
+	}
+	want := []SSHKeyReference{
+		{ResourceType: "project", ResourceID: project.ID, Name: "web", Field: "ssh_key_id"},
+		{ResourceType: "ssh_key", ResourceID: jump.ID, Name: "jump", Field: "bastion_key_id"},
+	}
+	if fmt.Sprint(refs) != fmt.Sprint(want) {
+		t.Errorf("引用为 %v，应为 %v", refs, want)
+	}
+	var count int64
+	DB.Model(&models.SSHKey{}).Where("id = ?", key.ID).Count(&count)
+	if count != 1 {
+		t.Fatal("被拒绝删除的密钥不应被删除")
+	}
+
+	DB.Model(project).Update("ssh_key_id", nil)
+	DB.Model(jump).Update("bastion_key_id", nil)
+	if refs, err := DeleteSSHKey(key); err != nil || len(refs) != 0 {
+		t.Fatalf("解除引用后应能删除，实际为 %v, %v", refs, err)
+	}
+	DB.Model(&models.SSHKey{}).Where("id = ?", key.ID).Count(&count)
+	if count != 0 {
+		t.Error("密钥应已删除")
+	}
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// storedTimeZoneKey 系统配置中记录数据库时间存储方式的键，值为 UTC 表示所有时间均已按UTC存储
+const storedTimeZoneKey = "stored_time_zone"
+
+// convertBatchSize 转换本地时间时每批处理的行数
+const convertBatchSize = 500
+
+var (
+	// ErrLocalTimesNotConverted 已有的 MySQL 数据按服务器本地时间写入，尚未转换为UTC
+	ErrLocalTimesNotConverted = errors.New("数据库中的时间按服务器本地时间写入，尚未转换为UTC")
+	// ErrTimesAlreadyConverted 数据库中的时间已按UTC存储，重复转换会使时间再次偏移
+	ErrTimesAlreadyConverted = errors.New("数据库中的时间已按UTC存储，无需转换")
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	timePtrType     = reflect.TypeOf(&time.Time{})
+	deletedAtType   = reflect.TypeOf(gorm.DeletedAt{})
+	durationMsTable = []interface{}{&models.PipelineRun{}, &models.PipelineStep{}, &models.Deployment{}, &models.TestResult{}}
+)
+
+// isFreshDatabase 数据库中是否还没有用户，即新安装
+func isFreshDatabase() bool {
+	if !DB.Migrator().HasTable(&models.User{}) {
+		return true
+	}
+	var count int64
+	DB.Unscoped().Model(&models.User{}).Count(&count)
+	return count == 0
+}
+
+// prepareDurationMs 为运行、步骤、部署与测试结果添加毫秒耗时字段，并按已有的秒数回填。
+// 只在字段新增时回填，之后的耗时由引擎同时写入两个字段
+func prepareDurationMs() error {
+	migrator := DB.Migrator()
+	for _, model := range durationMsTable {
+		if !migrator.HasTable(model) || migrator.HasColumn(model, "DurationMs") {
+			continue
+		}
+		if err := migrator.AddColumn(model, "DurationMs"); err != nil {
+			return fmt.Errorf("添加字段 %T.DurationMs 失败: %w", model, err)
+		}
+
+		integer := "BIGINT"
+		if DB.Dialector.Name() == "mysql" {
+			integer = "SIGNED"
+		}
+		result := DB.Model(model).Unscoped().Where("duration > 0").
+			UpdateColumn("duration_ms", gorm.Expr(fmt.Sprintf("CAST(duration * 1000 AS %s)", integer)))
+		if result.Error != nil {
+			return fmt.Errorf("回填 %T 的毫秒耗时失败: %w", model, result.Error)
+		}
+		log.Printf("已为 %T 回填 %d 条毫秒耗时", model, result.RowsAffected)
+	}
+	return nil
+}
+
+// checkStoredTimeZone 检查数据库时间是否已按UTC存储，迁移的最后一步执行。
+// PostgreSQL（timestamptz）与 SQLite（带时区偏移的文本）存储的是绝对时间，只有 MySQL 的 DATETIME
+// 保存的是写入时的本地时间：新安装直接记录为UTC，已有数据的安装须先离线执行 ConvertLocalTimes
+func checkStoredTimeZone(fresh bool) error {
+	stored, err := storedTimeZone()
+	if err != nil {
+		return err
+	}
+	if stored != "" {
+		return nil
+	}
+	if DB.Dialector.Name() == "mysql" && !fresh {
+		return fmt.Errorf("%w，请先停止服务并执行 -convert-local-times=<原服务器时区>（原服务器已使用UTC时传 UTC）", ErrLocalTimesNotConverted)
+	}
+	return markStoredTimeZone(DB)
+}
+
+// ConvertLocalTimes 将旧版本按服务器本地时间写入 MySQL 的时间转换为UTC，zone 为原服务器时区（如 Asia/Shanghai）。
+// 须在新版本服务启动前离线执行，且只能执行一次，转换在单个事务中完成；其他数据库只记录已转换。返回更新的行数
+func ConvertLocalTimes(zone string) (int64, error) {
+	if DB == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时区 %s: %w", zone, err)
+	}
+
+	if err := DB.AutoMigrate(&models.SystemConfig{}); err != nil {
+		return 0, fmt.Errorf("迁移模型 %T 失败: %w", &models.SystemConfig{}, err)
+	}
+	stored, err := storedTimeZone()
+	if err != nil {
+		return 0, err
+	}
+	if stored != "" {
+		return 0, ErrTimesAlreadyConverted
+	}
+
+	var total int64
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if DB.Dialector.Name() == "mysql" && loc != time.UTC {
+			for _, model := range migratedModels() {
+				n, err := convertTable(tx, model, loc)
+				if err != nil {
+					return err
+				}
+				total += n
+			}
+		}
+		return markStoredTimeZone(tx)
+	})
+	return total, err
+}
+
+// convertTable 转换单个表的时间字段，按主键分批读取，只更新时间字段本身
+func convertTable(tx *gorm.DB, model interface{}, loc *time.Location) (int64, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return 0, fmt.Errorf("解析模型 %T 失败: %w", model, err)
+	}
+	table := stmt.Schema.Table
+	primary := stmt.Schema.PrioritizedPrimaryField
+	migrator := tx.Migrator()
+	if primary == nil || !migrator.HasTable(table) {
+		return 0, nil
+	}
+
+	var columns []string
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || !migrator.HasColumn(table, field.DBName) {
+			continue
+		}
+		if t := field.FieldType; t == timeType || t == timePtrType || t == deletedAtType {
+			columns = append(columns, field.DBName)
+		}
+	}
+	if len(columns) == 0 {
+		return 0, nil
+	}
+
+	var converted int64
+	var last interface{} = 0
+	for {
+		var rows []map[string]interface{}
+		if err := tx.Table(table).Select(append([]string{primary.DBName}, columns...)).
+			Where(primary.DBName+" > ?", last).Order(primary.DBName).Limit(convertBatchSize).
+			Find(&rows).Error; err != nil {
+			return converted, fmt.Errorf("读取表 %s 失败: %w", table, err)
+		}
+
+		for _, row := range rows {
+			updates := map[string]interface{}{}
+			for _, column := range columns {
+				// DSN 使用 loc=UTC，读出的墙上时间即旧版本写入的本地时间
+				if t, ok := row[column].(time.Time); ok {
+					updates[column] = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc).UTC()
+				}
+			}
+			if len(updates) > 0 {
+				if err := tx.Table(table).Where(primary.DBName+" = ?", row[primary.DBName]).UpdateColumns(updates).Error; err != nil {
+					return converted, fmt.Errorf("更新表 %s 失败: %w", table, err)
+				}
+				converted++
+			}
+		}
+
+		if len(rows) < convertBatchSize {
+			break
+		}
+		last = rows[len(rows)-1][primary.DBName]
+	}
+
+	if converted > 0 {
+		log.Printf("表 %s 已转换 %d 行", table, converted)
+	}
+	return converted, nil
+}
+
+// storedTimeZone 读取数据库时间的存储方式，未记录时返回空字符串
+func storedTimeZone() (string, error) {
+	var record models.SystemConfig
+	result := DB.Where(&models.SystemConfig{Key: storedTimeZoneKey}).Limit(1).Find(&record)
+	if result.Error != nil {
+		return "", fmt.Errorf("查询系统配置失败: %w", result.Error)
+	}
+	return record.Value, nil
+}
+
+// markStoredTimeZone 记录数据库时间已按UTC存储
+func markStoredTimeZone(db *gorm.DB) error {
+	record := models.SystemConfig{
+		Key:         storedTimeZoneKey,
+		Value:       "UTC",
+		Description: "数据库时间的存储时区",
+		Category:    "system",
+		IsPublic:    false,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return fmt.Errorf("记录时间存储时区失败: %w", err)
+	}
+	return nil
+}
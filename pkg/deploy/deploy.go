@@ -103,8 +103,32 @@ func (dm *DeployManager) GetDeployTask(taskID string) (*DeployTask, error) {
 	return task, nil
 }
 
+// Status 获取部署管理器状态概要：是否运行中及各状态的任务数
+func (dm *DeployManager) Status() map[string]interface{} {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+
+	byStatus := make(map[string]int)
+	for _, task := range dm.tasks {
+		byStatus[task.GetStatus()]++
+	}
+
+	return map[string]interface{}{
+		"running":         dm.running,
+		"tasks":           len(dm.tasks),
+		"tasks_by_status": byStatus,
+	}
+}
+
 // ExecuteDeploy 执行部署
 func (dm *DeployManager) ExecuteDeploy(project *models.Project) error {
+	if project.IsArchived() {
+		return models.ErrProjectArchived
+	}
+	if err := CheckFreeze(project.ID, ""); err != nil {
+		return err
+	}
+
 	task, err := dm.CreateDeployTask(project.ID)
 	if err != nil {
 		return err
@@ -0,0 +1,329 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+	"flowforge/pkg/ssh"
+)
+
+// 部署到已漂移目标时的处理策略
+const (
+	DriftPolicyWarn  = "warn"
+	DriftPolicyBlock = "block"
+)
+
+// maxDriftPaths 漂移详情中最多列出的文件数
+const maxDriftPaths = 50
+
+// DriftTarget 远程部署目标
+type DriftTarget struct {
+	Host        string
+	Port        int
+	Username    string
+	RemoteDir   string
+	ServiceUnit string // 服务单元文件路径，如 /etc/systemd/system/app.service，可为空
+	SSHKey      *models.SSHKey
+	Bastion     models.BastionConfig // 经跳板机连接时的跳板机，为空时使用密钥的默认跳板机
+}
+
+// Key 目标标识：user@host:port/remote_dir
+func (t *DriftTarget) Key() string {
+	return fmt.Sprintf("%s@%s:%d%s", t.Username, t.Host, t.Port, path.Clean("/"+t.RemoteDir))
+}
+
+// DriftReport 一次漂移检查的结果
+type DriftReport struct {
+	Status             string   `json:"status"`
+	ModifiedCount      int      `json:"modified_count"`
+	MissingCount       int      `json:"missing_count"`
+	Modified           []string `json:"modified,omitempty"` // 最多列出 maxDriftPaths 个
+	Missing            []string `json:"missing,omitempty"`
+	ServiceUnitChanged bool     `json:"service_unit_changed,omitempty"`
+	Error              string   `json:"error,omitempty"`
+}
+
+// Summary 漂移结果的简短描述
+func (r *DriftReport) Summary() string {
+	switch r.Status {
+	case models.DriftStatusClean:
+		return "与上次部署一致"
+	case models.DriftStatusUnreachable:
+		return "无法检查: " + r.Error
+	}
+	summary := fmt.Sprintf("%d 个文件被修改，%d 个文件缺失", r.ModifiedCount, r.MissingCount)
+	if r.ServiceUnitChanged {
+		summary += "，服务单元文件被修改"
+	}
+	return summary
+}
+
+// DriftChecker 部署目标漂移检查：比对远程文件的当前哈希与上次部署记录的清单，只读取不修改
+type DriftChecker struct {
+	config    *config.Config
+	sshClient *ssh.Client
+	notifier  *notify.Manager
+}
+
+// NewDriftChecker 创建漂移检查器
+func NewDriftChecker(cfg *config.Config, notifier *notify.Manager) *DriftChecker {
+	return &DriftChecker{
+		config:    cfg,
+		sshClient: ssh.NewClient(cfg),
+		notifier:  notifier,
+	}
+}
+
+// Policy 部署到已漂移目标时的处理策略
+func (d *DriftChecker) Policy() string {
+	if d.config.Deploy.DriftPolicy == DriftPolicyBlock {
+		return DriftPolicyBlock
+	}
+	return DriftPolicyWarn
+}
+
+// Record 部署成功后记录目标的文件清单与服务单元校验和
+func (d *DriftChecker) Record(ctx context.Context, deployment *models.Deployment, target *DriftTarget, manifest *ssh.Manifest) (*models.DeploymentManifest, error) {
+	if manifest == nil {
+		return nil, fmt.Errorf("部署清单为空")
+	}
+
+	files, err := json.Marshal(manifest.Files)
+	if err != nil {
+		return nil, fmt.Errorf("序列化部署清单失败: %w", err)
+	}
+
+	now := time.Now()
+	record := &models.DeploymentManifest{
+		Target:        target.Key(),
+		Host:          target.Host,
+		Port:          target.Port,
+		Username:      target.Username,
+		RemoteDir:     target.RemoteDir,
+		SSHKeyID:      target.SSHKey.ID,
+		BastionConfig: target.Bastion,
+		Files:         string(files),
+		FileCount:     len(manifest.Files),
+		ServiceUnit:   target.ServiceUnit,
+		DriftStatus:   models.DriftStatusClean,
+		CheckedAt:     &now,
+		DeploymentID:  deployment.ID,
+		ProjectID:     deployment.ProjectID,
+	}
+	for _, f := range manifest.Files {
+		record.TotalSize += f.Size
+	}
+
+	if target.ServiceUnit != "" {
+		checkCtx, cancel := d.checkContext(ctx)
+		defer cancel()
+		hashes, err := d.sshClient.WithBastion(target.Bastion).RemoteHashes(checkCtx, target.SSHKey, target.Host, target.Port, target.Username, []string{target.ServiceUnit})
+		if err != nil {
+			return nil, fmt.Errorf("读取服务单元校验和失败: %w", err)
+		}
+		record.ServiceUnitSHA256 = hashes[target.ServiceUnit]
+	}
+
+	if err := database.DB.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("保存部署清单失败: %w", err)
+	}
+	return record, nil
+}
+
+// Latest 目标最近一次部署的清单，没有记录时返回 nil
+func (d *DriftChecker) Latest(projectID uint, target *DriftTarget) *models.DeploymentManifest {
+	var manifest models.DeploymentManifest
+	if err := database.DB.Where("project_id = ? AND target = ?", projectID, target.Key()).
+		Order("id DESC").First(&manifest).Error; err != nil {
+		return nil
+	}
+	return &manifest
+}
+
+// Check 重新读取远程文件哈希并与清单比对，结果写回清单；状态变为漂移时发送通知
+func (d *DriftChecker) Check(ctx context.Context, manifest *models.DeploymentManifest) *DriftReport {
+	report := d.compare(ctx, manifest)
+
+	detail, _ := json.Marshal(report)
+	now := time.Now()
+	previous := manifest.DriftStatus
+	manifest.DriftStatus = report.Status
+	manifest.DriftDetail = string(detail)
+	manifest.CheckedAt = &now
+	database.DB.Model(manifest).Updates(map[string]interface{}{
+		"drift_status": manifest.DriftStatus,
+		"drift_detail": manifest.DriftDetail,
+		"checked_at":   manifest.CheckedAt,
+	})
+
+	if report.Status == models.DriftStatusDrifted && previous != models.DriftStatusDrifted {
+		d.notifyDrift(manifest, report)
+	}
+	return report
+}
+
+// compare 比对远程文件与清单，检查时长受 DriftCheckTimeout 限制
+func (d *DriftChecker) compare(ctx context.Context, manifest *models.DeploymentManifest) *DriftReport {
+	var files []ssh.ManifestEntry
+	if err := json.Unmarshal([]byte(manifest.Files), &files); err != nil {
+		return &DriftReport{Status: models.DriftStatusUnreachable, Error: "部署清单已损坏"}
+	}
+
+	var sshKey models.SSHKey
+	if err := models.WithPrivateKey(database.DB).First(&sshKey, manifest.SSHKeyID).Error; err != nil {
+		return &DriftReport{Status: models.DriftStatusUnreachable, Error: "部署使用的SSH密钥不存在"}
+	}
+
+	paths := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		paths = append(paths, path.Join(manifest.RemoteDir, f.Path))
+	}
+	if manifest.ServiceUnit != "" {
+		paths = append(paths, manifest.ServiceUnit)
+	}
+
+	checkCtx, cancel := d.checkContext(ctx)
+	defer cancel()
+	hashes, err := d.sshClient.WithBastion(manifest.BastionConfig).RemoteHashes(checkCtx, &sshKey, manifest.Host, manifest.Port, manifest.Username, paths)
+	if err != nil {
+		return &DriftReport{Status: models.DriftStatusUnreachable, Error: err.Error()}
+	}
+
+	report := &DriftReport{Status: models.DriftStatusClean}
+	for _, f := range files {
+		current, ok := hashes[path.Join(manifest.RemoteDir, f.Path)]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, f.Path)
+		case current != f.SHA256:
+			report.Modified = append(report.Modified, f.Path)
+		}
+	}
+	if manifest.ServiceUnit != "" && hashes[manifest.ServiceUnit] != manifest.ServiceUnitSHA256 {
+		report.ServiceUnitChanged = true
+	}
+
+	report.ModifiedCount = len(report.Modified)
+	report.MissingCount = len(report.Missing)
+	if report.ModifiedCount > 0 || report.MissingCount > 0 || report.ServiceUnitChanged {
+		report.Status = models.DriftStatusDrifted
+		report.Modified = truncatePaths(report.Modified)
+		report.Missing = truncatePaths(report.Missing)
+	}
+	return report
+}
+
+// CheckProject 检查项目所有部署目标最近一次部署的清单
+func (d *DriftChecker) CheckProject(ctx context.Context, projectID uint) ([]models.DeploymentManifest, error) {
+	manifests, err := LatestManifests(projectID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range manifests {
+		d.Check(ctx, &manifests[i])
+	}
+	return manifests, nil
+}
+
+// CheckAll 定时任务：检查所有未归档项目的部署目标
+func (d *DriftChecker) CheckAll() {
+	var projectIDs []uint
+	database.DB.Model(&models.Project{}).Where("status <> ?", models.ProjectStatusArchived).Pluck("id", &projectIDs)
+
+	for _, projectID := range projectIDs {
+		manifests, err := d.CheckProject(context.Background(), projectID)
+		if err != nil {
+			log.Printf("检查项目 %d 的部署漂移失败: %v", projectID, err)
+			continue
+		}
+		for _, m := range manifests {
+			if m.DriftStatus == models.DriftStatusDrifted {
+				log.Printf("项目 %d 的部署目标 %s 已发生漂移", projectID, m.Target)
+			}
+		}
+	}
+}
+
+// checkContext 单次目标检查的超时上下文
+func (d *DriftChecker) checkContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(d.config.Deploy.DriftCheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// notifyDrift 向项目所有者发送漂移通知
+func (d *DriftChecker) notifyDrift(manifest *models.DeploymentManifest, report *DriftReport) {
+	if d.notifier == nil {
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, manifest.ProjectID).Error; err != nil {
+		return
+	}
+	var owner models.User
+	if err := database.DB.First(&owner, project.UserID).Error; err != nil {
+		return
+	}
+
+	msg := notify.Message{
+		Title:   fmt.Sprintf("项目 %s 的部署目标 %s 发生漂移", project.Name, manifest.Target),
+		Content: fmt.Sprintf("部署目标 %s 的当前状态与部署 #%d 不一致：%s", manifest.Target, manifest.DeploymentID, report.Summary()),
+		Link:    fmt.Sprintf("%s/projects/%d", d.config.Notify.BaseURL, project.ID),
+		Level:   models.NotifyLevelUrgent,
+	}
+	if err := d.notifier.Deliver(&owner, msg); err != nil {
+		log.Printf("向用户 %d 投递漂移通知失败: %v", owner.ID, err)
+	}
+}
+
+// LatestManifests 项目每个部署目标最近一次部署的清单
+func LatestManifests(projectID uint) ([]models.DeploymentManifest, error) {
+	var manifests []models.DeploymentManifest
+	err := database.DB.Where("id IN (?)", database.DB.Model(&models.DeploymentManifest{}).
+		Select("MAX(id)").Where("project_id = ?", projectID).Group("target")).
+		Order("target ASC").Find(&manifests).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询部署清单失败: %w", err)
+	}
+	return manifests, nil
+}
+
+// ProjectDriftStatus 汇总项目部署目标的漂移状态：任一目标漂移即为漂移，没有部署记录时为 unknown
+func ProjectDriftStatus(projectID uint) string {
+	manifests, err := LatestManifests(projectID)
+	if err != nil || len(manifests) == 0 {
+		return models.DriftStatusUnknown
+	}
+
+	status := models.DriftStatusClean
+	for _, m := range manifests {
+		switch m.DriftStatus {
+		case models.DriftStatusDrifted:
+			return models.DriftStatusDrifted
+		case models.DriftStatusUnreachable, models.DriftStatusUnknown:
+			status = m.DriftStatus
+		}
+	}
+	return status
+}
+
+// truncatePaths 限制列出的文件数量
+func truncatePaths(paths []string) []string {
+	sort.Strings(paths)
+	if len(paths) > maxDriftPaths {
+		return paths[:maxDriftPaths]
+	}
+	return paths
+}
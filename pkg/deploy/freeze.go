@@ -0,0 +1,243 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/clock"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+)
+
+var (
+	// ErrFreezeScopeInvalid 冻结范围无效或缺少对应的环境标签/项目列表
+	ErrFreezeScopeInvalid = errors.New("冻结范围无效")
+	// ErrFreezeExpiryInvalid 到期时间早于当前时间
+	ErrFreezeExpiryInvalid = errors.New("冻结到期时间无效")
+	// ErrFreezeNotFound 冻结不存在
+	ErrFreezeNotFound = errors.New("部署冻结不存在")
+	// ErrFreezeLifted 冻结已解除或已到期
+	ErrFreezeLifted = errors.New("部署冻结已解除")
+)
+
+var (
+	freezeClockMu sync.RWMutex
+	freezeClock   clock.Clock = clock.Real
+)
+
+// SetFreezeClock 设置判断冻结生效与到期的时间来源，默认为系统时间；测试中传入可控时钟验证冻结到期
+func SetFreezeClock(c clock.Clock) {
+	freezeClockMu.Lock()
+	defer freezeClockMu.Unlock()
+	freezeClock = c
+}
+
+// freezeNow 判断冻结生效与到期使用的当前时间
+func freezeNow() time.Time {
+	freezeClockMu.RLock()
+	defer freezeClockMu.RUnlock()
+	return freezeClock.Now()
+}
+
+// FrozenError 部署因冻结被拒绝，携带生效的冻结
+type FrozenError struct {
+	Freeze *models.DeployFreeze
+}
+
+func (e *FrozenError) Error() string {
+	return fmt.Sprintf("部署已冻结（冻结 #%d）: %s", e.Freeze.ID, e.Freeze.Reason)
+}
+
+// FreezeManager 部署冻结管理：创建、解除冻结并向相关用户发送通知
+type FreezeManager struct {
+	config   *config.Config
+	notifier *notify.Manager
+}
+
+// NewFreezeManager 创建部署冻结管理器
+func NewFreezeManager(cfg *config.Config, notifier *notify.Manager) *FreezeManager {
+	return &FreezeManager{
+		config:   cfg,
+		notifier: notifier,
+	}
+}
+
+// Create 创建部署冻结，与已有冻结叠加生效
+func (f *FreezeManager) Create(req *models.CreateFreezeRequest, userID uint, tokenID *uint) (*models.DeployFreeze, error) {
+	freeze := &models.DeployFreeze{
+		Scope:       req.Scope,
+		Reason:      strings.TrimSpace(req.Reason),
+		ExpiresAt:   req.ExpiresAt,
+		CreatedByID: userID,
+		APITokenID:  tokenID,
+	}
+
+	switch req.Scope {
+	case models.FreezeScopeGlobal:
+	case models.FreezeScopeEnvironment:
+		freeze.Environment = strings.TrimSpace(req.Environment)
+		if freeze.Environment == "" {
+			return nil, ErrFreezeScopeInvalid
+		}
+	case models.FreezeScopeProjects:
+		if len(req.ProjectIDs) == 0 {
+			return nil, ErrFreezeScopeInvalid
+		}
+		ids := make([]string, 0, len(req.ProjectIDs))
+		for _, id := range req.ProjectIDs {
+			ids = append(ids, strconv.FormatUint(uint64(id), 10))
+		}
+		freeze.ProjectIDs = strings.Join(ids, ",")
+	default:
+		return nil, ErrFreezeScopeInvalid
+	}
+
+	if freeze.ExpiresAt != nil && !freeze.ExpiresAt.After(freezeNow()) {
+		return nil, ErrFreezeExpiryInvalid
+	}
+
+	if err := database.DB.Create(freeze).Error; err != nil {
+		return nil, fmt.Errorf("保存部署冻结失败: %w", err)
+	}
+
+	log.Printf("部署冻结 #%d 已生效（范围: %s）: %s", freeze.ID, describeScope(freeze), freeze.Reason)
+	f.notifyFreeze(freeze, fmt.Sprintf("部署已冻结（%s）", describeScope(freeze)), freeze.Reason, models.NotifyLevelUrgent)
+	return freeze, nil
+}
+
+// Lift 解除指定的部署冻结
+func (f *FreezeManager) Lift(id uint, userID uint, reason string) (*models.DeployFreeze, error) {
+	var freeze models.DeployFreeze
+	if err := database.DB.First(&freeze, id).Error; err != nil {
+		return nil, ErrFreezeNotFound
+	}
+	if !freeze.IsActive(freezeNow()) {
+		return nil, ErrFreezeLifted
+	}
+
+	if err := f.lift(&freeze, &userID, strings.TrimSpace(reason)); err != nil {
+		return nil, err
+	}
+	return &freeze, nil
+}
+
+// LiftExpired 定时任务：解除已到期但仍未标记解除的冻结
+func (f *FreezeManager) LiftExpired() {
+	var freezes []models.DeployFreeze
+	if err := database.DB.Where("lifted_at IS NULL AND expires_at IS NOT NULL AND expires_at <= ?", freezeNow()).
+		Find(&freezes).Error; err != nil {
+		log.Printf("查询到期的部署冻结失败: %v", err)
+		return
+	}
+
+	for i := range freezes {
+		if err := f.lift(&freezes[i], nil, "已到期自动解除"); err != nil {
+			log.Printf("自动解除部署冻结 #%d 失败: %v", freezes[i].ID, err)
+		}
+	}
+}
+
+// lift 标记冻结已解除并发送通知，userID 为空表示由系统解除
+func (f *FreezeManager) lift(freeze *models.DeployFreeze, userID *uint, reason string) error {
+	now := freezeNow()
+	freeze.LiftedAt = &now
+	freeze.LiftedByID = userID
+	freeze.LiftReason = reason
+	if err := database.DB.Model(freeze).Updates(map[string]interface{}{
+		"lifted_at":    freeze.LiftedAt,
+		"lifted_by_id": freeze.LiftedByID,
+		"lift_reason":  freeze.LiftReason,
+	}).Error; err != nil {
+		return fmt.Errorf("解除部署冻结失败: %w", err)
+	}
+
+	log.Printf("部署冻结 #%d 已解除: %s", freeze.ID, reason)
+	f.notifyFreeze(freeze, fmt.Sprintf("部署冻结已解除（%s）", describeScope(freeze)), reason, models.NotifyLevelNormal)
+	return nil
+}
+
+// notifyFreeze 向管理员与冻结范围内项目的所有者发送冻结事件通知
+func (f *FreezeManager) notifyFreeze(freeze *models.DeployFreeze, title, detail string, level string) {
+	if f.notifier == nil {
+		return
+	}
+
+	var users []models.User
+	query := database.DB.Where("role = ?", models.RoleAdmin)
+	if freeze.Scope == models.FreezeScopeProjects {
+		query = query.Or("id IN (?)", database.DB.Model(&models.Project{}).Select("user_id").
+			Where("id IN ?", strings.Split(freeze.ProjectIDs, ",")))
+	}
+	if err := query.Find(&users).Error; err != nil {
+		log.Printf("查询部署冻结通知对象失败: %v", err)
+		return
+	}
+
+	msg := notify.Message{
+		Title:   fmt.Sprintf("%s #%d", title, freeze.ID),
+		Content: fmt.Sprintf("部署冻结 #%d（%s）: %s", freeze.ID, describeScope(freeze), detail),
+		Link:    fmt.Sprintf("%s/admin/freeze", f.config.Notify.BaseURL),
+		Level:   level,
+	}
+	for i := range users {
+		if err := f.notifier.Deliver(&users[i], msg); err != nil {
+			log.Printf("向用户 %d 投递部署冻结通知失败: %v", users[i].ID, err)
+		}
+	}
+}
+
+// ActiveFreezes 当前生效的部署冻结，按创建时间排序
+func ActiveFreezes() ([]models.DeployFreeze, error) {
+	var freezes []models.DeployFreeze
+	if err := database.DB.Where("lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", freezeNow()).
+		Order("id ASC").Find(&freezes).Error; err != nil {
+		return nil, fmt.Errorf("查询部署冻结失败: %w", err)
+	}
+	return freezes, nil
+}
+
+// ProjectFreezes 当前对项目生效的部署冻结，environment 为空时不匹配环境范围的冻结
+func ProjectFreezes(projectID uint, environment string) ([]models.DeployFreeze, error) {
+	freezes, err := ActiveFreezes()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]models.DeployFreeze, 0, len(freezes))
+	for _, freeze := range freezes {
+		if freeze.Covers(projectID, environment) {
+			matched = append(matched, freeze)
+		}
+	}
+	return matched, nil
+}
+
+// CheckFreeze 部署前检查冻结，被冻结时返回 *FrozenError；查询失败时同样拒绝部署
+func CheckFreeze(projectID uint, environment string) error {
+	freezes, err := ProjectFreezes(projectID, environment)
+	if err != nil {
+		return err
+	}
+	if len(freezes) > 0 {
+		return &FrozenError{Freeze: &freezes[0]}
+	}
+	return nil
+}
+
+// describeScope 冻结范围的简短描述
+func describeScope(freeze *models.DeployFreeze) string {
+	switch freeze.Scope {
+	case models.FreezeScopeEnvironment:
+		return "环境 " + freeze.Environment
+	case models.FreezeScopeProjects:
+		return "项目 " + freeze.ProjectIDs
+	}
+	return "全局"
+}
@@ -0,0 +1,128 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"flowforge/internal/clocktest"
+	"flowforge/pkg/clock"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// setupFreezeTest 内存数据库与可控时钟，测试结束后恢复系统时间
+func setupFreezeTest(t *testing.T) (*FreezeManager, *clocktest.Fake) {
+	t.Helper()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := clocktest.New(time.Date(2026, 11, 27, 18, 0, 0, 0, time.UTC))
+	SetFreezeClock(fake)
+	t.Cleanup(func() { SetFreezeClock(clock.Real) })
+	return NewFreezeManager(cfg, nil), fake
+}
+
+func TestFreezeExpires(t *testing.T) {
+	manager, fake := setupFreezeTest(t)
+	expires := fake.Now().Add(72 * time.Hour)
+	freeze, err := manager.Create(&models.CreateFreezeRequest{Scope: models.FreezeScopeGlobal, Reason: "假期封版", ExpiresAt: &expires}, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frozen *FrozenError
+	if err := CheckFreeze(42, "production"); !errors.As(err, &frozen) || frozen.Freeze.ID != freeze.ID {
+		t.Fatalf("冻结期间部署应被拒绝，实际为 %v", err)
+	}
+
+	fake.Advance(72*time.Hour - time.Second)
+	if err := CheckFreeze(42, "production"); !errors.As(err, &frozen) {
+		t.Fatalf("到期前仍应冻结，实际为 %v", err)
+	}
+
+	// 到期后不再生效，LiftExpired 以到期后的时间标记解除
+	fake.Advance(time.Second)
+	if err := CheckFreeze(42, "production"); err != nil {
+		t.Fatalf("到期后应允许部署，实际为 %v", err)
+	}
+	fake.Advance(time.Minute)
+	manager.LiftExpired()
+
+	var stored models.DeployFreeze
+	database.DB.First(&stored, freeze.ID)
+	if stored.LiftedAt == nil || !stored.LiftedAt.Equal(fake.Now()) || stored.LiftedByID != nil {
+		t.Fatalf("到期的冻结应由系统在 %v 解除，实际为 %v %v", fake.Now(), stored.LiftedAt, stored.LiftedByID)
+	}
+	if _, err := manager.Lift(freeze.ID, 1, "手动解除"); !errors.Is(err, ErrFreezeLifted) {
+		t.Fatalf("已解除的冻结不能再次解除，实际为 %v", err)
+	}
+}
+
+func TestFreezeRejectsPastExpiry(t *testing.T) {
+	manager, fake := setupFreezeTest(t)
+	expires := fake.Now()
+	_, err := manager.Create(&models.CreateFreezeRequest{Scope: models.FreezeScopeGlobal, Reason: "x", ExpiresAt: &expires}, 1, nil)
+	if !errors.Is(err, ErrFreezeExpiryInvalid) {
+		t.Fatalf("到期时间不晚于当前时间应被拒绝，实际为 %v", err)
+	}
+}
+
+func TestFreezeScopes(t *testing.T) {
+	manager, fake := setupFreezeTest(t)
+	expires := fake.Now().Add(time.Hour)
+	if _, err := manager.Create(&models.CreateFreezeRequest{Scope: models.FreezeScopeEnvironment, Environment: "production", Reason: "x", ExpiresAt: &expires}, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	manual, err := manager.Create(&models.CreateFreezeRequest{Scope: models.FreezeScopeProjects, ProjectIDs: []uint{7}, Reason: "x"}, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		project     uint
+		environment string
+		frozen      bool
+	}{
+		{1, "production", true},
+		{1, "Production", true},
+		{1, "staging", false},
+		{1, "", false},
+		{7, "", true},
+		{70, "", false},
+	}
+	for _, tc := range cases {
+		if got := CheckFreeze(tc.project, tc.environment) != nil; got != tc.frozen {
+			t.Errorf("项目 %d 环境 %q 冻结 = %v，应为 %v", tc.project, tc.environment, got, tc.frozen)
+		}
+	}
+
+	// 环境冻结到期后，未设置到期时间的项目冻结仍然生效
+	fake.Advance(24 * time.Hour)
+	if CheckFreeze(1, "production") != nil {
+		t.Error("到期的环境冻结不应生效")
+	}
+	if CheckFreeze(7, "") == nil {
+		t.Error("未设置到期时间的冻结应持续生效")
+	}
+	if _, err := manager.Lift(manual.ID, 1, "发布完成"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckFreeze(7, ""); err != nil {
+		t.Errorf("解除后应允许部署，实际为 %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrLockWaitNotFound 部署锁等待项不存在（已获得锁、已取消或不存在）
+var ErrLockWaitNotFound = errors.New("部署锁等待项不存在")
+
+// LockOwner 持有或等待部署锁的运行
+type LockOwner struct {
+	RunID        uint
+	PipelineID   uint
+	PipelineName string
+	ProjectID    uint
+	ProjectName  string
+}
+
+// lockWaiter 等待某个部署目标的运行
+type lockWaiter struct {
+	id         uint64
+	target     string
+	owner      LockOwner
+	enqueuedAt time.Time
+	ready      chan struct{} // 获得锁时关闭
+}
+
+// DeployLocks 部署目标锁：同一目标同一时间只允许一个运行同步，其余运行按显式队列顺序等待
+type DeployLocks struct {
+	mu      sync.Mutex
+	seq     uint64
+	holders map[string]LockOwner
+	waiters map[string][]*lockWaiter
+}
+
+// LockWait 部署锁等待队列中的一项
+type LockWait struct {
+	ID             uint64    `json:"id"`
+	Target         string    `json:"target"`
+	Position       int       `json:"position"` // 在该目标等待队列中的位置，从 1 开始
+	RunID          uint      `json:"run_id"`
+	PipelineID     uint      `json:"pipeline_id"`
+	PipelineName   string    `json:"pipeline_name"`
+	ProjectID      uint      `json:"project_id"`
+	ProjectName    string    `json:"project_name"`
+	HeldByRunID    uint      `json:"held_by_run_id"`
+	EnqueuedAt     time.Time `json:"enqueued_at"`
+	WaitingSeconds int64     `json:"waiting_seconds"`
+}
+
+// LockStats 部署锁指标
+type LockStats struct {
+	Locked           int   `json:"locked"` // 被持有的目标数
+	Depth            int   `json:"depth"`  // 所有目标的等待数
+	OldestAgeSeconds int64 `json:"oldest_age_seconds"`
+}
+
+// NewDeployLocks 创建部署目标锁
+func NewDeployLocks() *DeployLocks {
+	return &DeployLocks{
+		holders: make(map[string]LockOwner),
+		waiters: make(map[string][]*lockWaiter),
+	}
+}
+
+// Acquire 获取部署目标锁，目标被占用时排队等待；waiting 在开始等待时以排队位置调用，可为 nil。
+// ctx 结束时放弃等待并返回错误，成功时返回的 release 必须调用且只调用一次
+func (l *DeployLocks) Acquire(ctx context.Context, target string, owner LockOwner, waiting func(position int)) (func(), error) {
+	l.mu.Lock()
+	if _, held := l.holders[target]; !held && len(l.waiters[target]) == 0 {
+		l.holders[target] = owner
+		l.mu.Unlock()
+		return l.releaser(target), nil
+	}
+
+	l.seq++
+	w := &lockWaiter{id: l.seq, target: target, owner: owner, enqueuedAt: time.Now(), ready: make(chan struct{})}
+	l.waiters[target] = append(l.waiters[target], w)
+	position := len(l.waiters[target])
+	l.mu.Unlock()
+
+	if waiting != nil {
+		waiting(position)
+	}
+
+	select {
+	case <-w.ready:
+		return l.releaser(target), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		removed := l.removeLocked(w)
+		l.mu.Unlock()
+		if !removed {
+			// 取消的同时已获得锁，交给下一个等待者
+			l.releaser(target)()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// releaser 释放目标锁并交给队首的等待者
+func (l *DeployLocks) releaser(target string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			queue := l.waiters[target]
+			if len(queue) == 0 {
+				delete(l.holders, target)
+				delete(l.waiters, target)
+				return
+			}
+			next := queue[0]
+			l.waiters[target] = queue[1:]
+			l.holders[target] = next.owner
+			close(next.ready)
+		})
+	}
+}
+
+// removeLocked 从等待队列移除，已获得锁时返回 false
+func (l *DeployLocks) removeLocked(w *lockWaiter) bool {
+	queue := l.waiters[w.target]
+	for i, item := range queue {
+		if item == w {
+			l.waiters[w.target] = append(queue[:i:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Waits 列出所有目标的等待项，同一目标内按获得锁的顺序排列
+func (l *DeployLocks) Waits() []LockWait {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var result []LockWait
+	for target, queue := range l.waiters {
+		for i, w := range queue {
+			result = append(result, LockWait{
+				ID:             w.id,
+				Target:         target,
+				Position:       i + 1,
+				RunID:          w.owner.RunID,
+				PipelineID:     w.owner.PipelineID,
+				PipelineName:   w.owner.PipelineName,
+				ProjectID:      w.owner.ProjectID,
+				ProjectName:    w.owner.ProjectName,
+				HeldByRunID:    l.holders[target].RunID,
+				EnqueuedAt:     w.enqueuedAt,
+				WaitingSeconds: int64(now.Sub(w.enqueuedAt).Seconds()),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Target != result[j].Target {
+			return result[i].Target < result[j].Target
+		}
+		return result[i].Position < result[j].Position
+	})
+	return result
+}
+
+// Wait 获取指定ID的等待项
+func (l *DeployLocks) Wait(id uint64) (LockWait, bool) {
+	for _, w := range l.Waits() {
+		if w.ID == id {
+			return w, true
+		}
+	}
+	return LockWait{}, false
+}
+
+// Promote 将等待项移到其目标等待队列的队首，当前持有者释放后即获得锁
+func (l *DeployLocks) Promote(id uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for target, queue := range l.waiters {
+		for i, w := range queue {
+			if w.id != id {
+				continue
+			}
+			promoted := append([]*lockWaiter{w}, queue[:i]...)
+			l.waiters[target] = append(promoted, queue[i+1:]...)
+			return nil
+		}
+	}
+	return ErrLockWaitNotFound
+}
+
+// Stats 部署锁指标
+func (l *DeployLocks) Stats() LockStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := LockStats{Locked: len(l.holders)}
+	for _, queue := range l.waiters {
+		stats.Depth += len(queue)
+		for _, w := range queue {
+			if age := int64(time.Since(w.enqueuedAt).Seconds()); age > stats.OldestAgeSeconds {
+				stats.OldestAgeSeconds = age
+			}
+		}
+	}
+	return stats
+}
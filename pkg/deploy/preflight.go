@@ -0,0 +1,247 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/flags"
+	"flowforge/pkg/ssh"
+	"flowforge/pkg/utils"
+)
+
+// 部署前检查项
+const (
+	PreflightConnectivity = "connectivity"
+	PreflightWritable     = "write_permission"
+	PreflightDiskSpace    = "disk_space"
+	PreflightBinaries     = "binaries"
+	PreflightSudo         = "sudo"
+	PreflightClockSkew    = "clock_skew"
+)
+
+// 检查结果
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
+// syncBinaries 增量同步依赖的远程命令
+var syncBinaries = []string{"sha256sum", "truncate", "head"}
+
+// PreflightOptions 部署前检查的要求
+type PreflightOptions struct {
+	RequiredBytes int64    // 待部署文件的总大小，目标可用空间不足时失败
+	Binaries      []string // 除同步依赖外还需要的命令
+	Sudo          bool     // 要求登录用户可以免密 sudo
+	ProjectID     uint     // 发起检查的项目，用于判断功能开关
+}
+
+// PreflightCheck 单项检查结果
+type PreflightCheck struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Required bool   `json:"required"` // 必需检查失败时中止部署
+	Message  string `json:"message"`
+}
+
+// PreflightReport 一个部署目标的部署前检查结果
+type PreflightReport struct {
+	Target    string           `json:"target"`
+	Passed    bool             `json:"passed"` // 没有失败的必需检查
+	Checks    []PreflightCheck `json:"checks"`
+	CheckedAt time.Time        `json:"checked_at"`
+	Cached    bool             `json:"cached,omitempty"`
+}
+
+// Summary 未通过与告警的检查项摘要
+func (r *PreflightReport) Summary() string {
+	var failed, warned []string
+	for _, check := range r.Checks {
+		switch {
+		case check.Status == PreflightFail && check.Required:
+			failed = append(failed, check.Name+": "+check.Message)
+		case check.Status != PreflightPass:
+			warned = append(warned, check.Name+": "+check.Message)
+		}
+	}
+
+	var parts []string
+	if len(failed) > 0 {
+		parts = append(parts, "未通过 "+strings.Join(failed, "；"))
+	}
+	if len(warned) > 0 {
+		parts = append(parts, "告警 "+strings.Join(warned, "；"))
+	}
+	if len(parts) == 0 {
+		return "全部检查通过"
+	}
+	return strings.Join(parts, "，")
+}
+
+// add 追加检查结果
+func (r *PreflightReport) add(name, status string, required bool, message string) {
+	r.Checks = append(r.Checks, PreflightCheck{Name: name, Status: status, Required: required, Message: message})
+	if status == PreflightFail && required {
+		r.Passed = false
+	}
+}
+
+// cachedPreflight 缓存的检查结果
+type cachedPreflight struct {
+	report    *PreflightReport
+	expiresAt time.Time
+}
+
+// Preflighter 部署前检查：在修改目标之前确认连接、写权限、磁盘空间、所需命令与时钟，只读取不修改
+type Preflighter struct {
+	config    *config.Config
+	sshClient *ssh.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedPreflight
+}
+
+// NewPreflighter 创建部署前检查器
+func NewPreflighter(cfg *config.Config) *Preflighter {
+	return &Preflighter{
+		config:    cfg,
+		sshClient: ssh.NewClient(cfg),
+		cache:     make(map[string]cachedPreflight),
+	}
+}
+
+// Run 检查部署目标；相同目标与要求的结果在 PreflightCacheTTL 内复用
+func (p *Preflighter) Run(ctx context.Context, target *DriftTarget, opts PreflightOptions) *PreflightReport {
+	key := p.cacheKey(target, opts)
+	now := time.Now()
+	useCache := flags.Enabled(ctx, flags.PreflightCache, opts.ProjectID)
+
+	p.mu.Lock()
+	cached, ok := p.cache[key]
+	p.mu.Unlock()
+	if useCache && ok && now.Before(cached.expiresAt) {
+		report := *cached.report
+		report.Cached = true
+		return &report
+	}
+
+	report := p.check(ctx, target, opts)
+
+	if ttl := time.Duration(p.config.Deploy.PreflightCacheTTL) * time.Second; useCache && ttl > 0 {
+		p.mu.Lock()
+		for k, entry := range p.cache {
+			if now.After(entry.expiresAt) {
+				delete(p.cache, k)
+			}
+		}
+		p.cache[key] = cachedPreflight{report: report, expiresAt: now.Add(ttl)}
+		p.mu.Unlock()
+	}
+	return report
+}
+
+// check 执行各项检查
+func (p *Preflighter) check(ctx context.Context, target *DriftTarget, opts PreflightOptions) *PreflightReport {
+	report := &PreflightReport{Target: target.Key(), Passed: true, CheckedAt: time.Now()}
+
+	binaries := append([]string(nil), syncBinaries...)
+	for _, name := range opts.Binaries {
+		if name = strings.TrimSpace(name); name != "" && !containsString(binaries, name) {
+			binaries = append(binaries, name)
+		}
+	}
+
+	timeout := time.Duration(p.config.Deploy.PreflightTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := p.sshClient.WithBastion(target.Bastion).Probe(checkCtx, target.SSHKey, target.Host, target.Port, target.Username, ssh.ProbeRequest{
+		RemoteDir: target.RemoteDir,
+		Binaries:  binaries,
+		Sudo:      opts.Sudo,
+	})
+	if err != nil {
+		report.add(PreflightConnectivity, PreflightFail, true, err.Error())
+		return report
+	}
+	report.add(PreflightConnectivity, PreflightPass, true, "SSH连接正常")
+
+	if result.Writable {
+		report.add(PreflightWritable, PreflightPass, true, fmt.Sprintf("%s 可写", result.ExistingDir))
+	} else {
+		report.add(PreflightWritable, PreflightFail, true, fmt.Sprintf("用户 %s 对 %s 没有写权限", target.Username, result.ExistingDir))
+	}
+
+	switch {
+	case result.AvailableBytes < 0:
+		report.add(PreflightDiskSpace, PreflightWarn, true, "无法获取可用磁盘空间")
+	case result.AvailableBytes < opts.RequiredBytes:
+		report.add(PreflightDiskSpace, PreflightFail, true, fmt.Sprintf("可用空间 %s，待部署文件 %s",
+			utils.FormatFileSize(result.AvailableBytes), utils.FormatFileSize(opts.RequiredBytes)))
+	case result.AvailableBytes < opts.RequiredBytes*2:
+		// 覆盖部署期间新旧文件可能同时存在
+		report.add(PreflightDiskSpace, PreflightWarn, true, fmt.Sprintf("可用空间 %s，不足待部署文件 %s 的两倍",
+			utils.FormatFileSize(result.AvailableBytes), utils.FormatFileSize(opts.RequiredBytes)))
+	default:
+		report.add(PreflightDiskSpace, PreflightPass, true, fmt.Sprintf("可用空间 %s", utils.FormatFileSize(result.AvailableBytes)))
+	}
+
+	var missing []string
+	for _, name := range binaries {
+		if !result.Binaries[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		report.add(PreflightBinaries, PreflightFail, true, "缺少命令: "+strings.Join(missing, ", "))
+	} else {
+		report.add(PreflightBinaries, PreflightPass, true, strings.Join(binaries, ", ")+" 均可用")
+	}
+
+	if opts.Sudo {
+		if result.Sudo {
+			report.add(PreflightSudo, PreflightPass, true, "可以免密 sudo")
+		} else {
+			report.add(PreflightSudo, PreflightFail, true, fmt.Sprintf("用户 %s 无法免密 sudo", target.Username))
+		}
+	}
+
+	skew := result.ClockSkew
+	if skew < 0 {
+		skew = -skew
+	}
+	if maxSkew := time.Duration(p.config.Deploy.MaxClockSkew) * time.Second; maxSkew > 0 && skew > maxSkew {
+		report.add(PreflightClockSkew, PreflightWarn, false, fmt.Sprintf("时钟偏差 %s，超过 %s", result.ClockSkew, maxSkew))
+	} else {
+		report.add(PreflightClockSkew, PreflightPass, false, fmt.Sprintf("时钟偏差 %s", result.ClockSkew))
+	}
+
+	return report
+}
+
+// cacheKey 缓存键：目标、密钥与检查要求
+func (p *Preflighter) cacheKey(target *DriftTarget, opts PreflightOptions) string {
+	var keyID uint
+	if target.SSHKey != nil {
+		keyID = target.SSHKey.ID
+	}
+	return fmt.Sprintf("%s|%d|%d|%s|%t", target.Key(), keyID, opts.RequiredBytes, strings.Join(opts.Binaries, ","), opts.Sudo)
+}
+
+// containsString 列表中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,428 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/ssh"
+)
+
+// 部署后验证项类型
+const (
+	VerifyHTTP    = "http"    // 请求地址，检查状态码与响应内容
+	VerifyTCP     = "tcp"     // 端口可以连接
+	VerifyCommand = "command" // 目标主机上的命令退出码为 0
+	VerifySystemd = "systemd" // systemd 服务单元处于 active 状态
+)
+
+// 验证项的执行位置
+const (
+	VerifyFromServer = "server" // 在本服务所在主机上探测
+	VerifyFromTarget = "target" // 经 SSH 在部署目标上探测
+)
+
+// 验证参数的默认值与取值范围（秒）
+const (
+	defaultVerifyTimeout  = 10
+	defaultVerifyRetries  = 5
+	defaultVerifyInterval = 5
+	maxVerifyTimeout      = 300
+	maxVerifyRetries      = 100
+	maxVerifyInterval     = 300
+	maxVerifyGracePeriod  = 600
+	// maxVerifyDuration 全部检查都重试到最后一次时的最长耗时，避免配置错误使部署长时间挂起
+	maxVerifyDuration = 3600
+	// maxVerifyBodyBytes HTTP 检查读取的响应内容上限
+	maxVerifyBodyBytes = 64 * 1024
+)
+
+var (
+	verifyUnitPattern = regexp.MustCompile(`^[A-Za-z0-9@._:-]+$`)
+	verifyHostPattern = regexp.MustCompile(`^[A-Za-z0-9.:\[\]-]+$`)
+)
+
+// VerifyCheck 部署后的一项验证
+type VerifyCheck struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	From         string `json:"from"`                    // server 或 target，command 与 systemd 只能在目标上执行
+	URL          string `json:"url,omitempty"`           // http：请求地址
+	ExpectStatus int    `json:"expect_status,omitempty"` // http：期望的状态码，为 0 时接受 2xx
+	ExpectBody   string `json:"expect_body,omitempty"`   // http：响应中应包含的文本
+	Host         string `json:"host,omitempty"`          // tcp：主机，默认为部署目标（在目标上执行时为 127.0.0.1）
+	Port         int    `json:"port,omitempty"`          // tcp：端口
+	Command      string `json:"command,omitempty"`       // command：在目标上执行的命令
+	Unit         string `json:"unit,omitempty"`          // systemd：服务单元名称
+	Timeout      int    `json:"timeout,omitempty"`       // 单次探测超时（秒）
+}
+
+// VerifyConfig 部署步骤的 verify 配置：部署动作完成后等待 grace_period 秒，依次执行各项检查，
+// 每项检查失败后间隔 interval 秒重试，最多 retries 次；未通过且配置了 rollback_commands 时在目标上执行回滚
+type VerifyConfig struct {
+	Checks           []VerifyCheck `json:"checks"`
+	GracePeriod      int           `json:"grace_period"`
+	Retries          *int          `json:"retries"`
+	Interval         int           `json:"interval"`
+	RollbackCommands []string      `json:"rollback_commands"`
+}
+
+// VerifyResult 一项验证的结果
+type VerifyResult struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	From       string `json:"from"`
+	Passed     bool   `json:"passed"`
+	Attempts   int    `json:"attempts"`
+	Message    string `json:"message"` // 最后一次探测的结果
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// VerifyReport 部署后验证的结果，保存在部署记录上
+type VerifyReport struct {
+	Target        string         `json:"target"`
+	Passed        bool           `json:"passed"`
+	Checks        []VerifyResult `json:"checks"`
+	StartedAt     time.Time      `json:"started_at"`
+	FinishedAt    time.Time      `json:"finished_at"`
+	RolledBack    bool           `json:"rolled_back,omitempty"`
+	RollbackError string         `json:"rollback_error,omitempty"`
+}
+
+// Summary 未通过的检查项摘要
+func (r *VerifyReport) Summary() string {
+	var failed []string
+	for _, check := range r.Checks {
+		if !check.Passed {
+			failed = append(failed, fmt.Sprintf("%s（%d 次）: %s", check.Name, check.Attempts, check.Message))
+		}
+	}
+	if len(failed) == 0 {
+		return "全部检查通过"
+	}
+	return "未通过 " + strings.Join(failed, "；")
+}
+
+// ParseVerifyConfig 解析并校验部署步骤的 verify 配置，未配置时返回 nil；返回的配置已补全默认值
+func ParseVerifyConfig(value interface{}) (*VerifyConfig, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("verify 配置无法解析: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	cfg := &VerifyConfig{}
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("verify 配置无法解析: %w", err)
+	}
+
+	if problems := cfg.normalize(); len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "；"))
+	}
+	return cfg, nil
+}
+
+// normalize 补全默认值并检查取值范围，返回全部问题
+func (c *VerifyConfig) normalize() []string {
+	var problems []string
+	if len(c.Checks) == 0 {
+		problems = append(problems, "verify 至少需要一项检查")
+	}
+	if c.Retries == nil {
+		retries := defaultVerifyRetries
+		c.Retries = &retries
+	}
+	if c.Interval == 0 {
+		c.Interval = defaultVerifyInterval
+	}
+	if *c.Retries < 0 || *c.Retries > maxVerifyRetries {
+		problems = append(problems, fmt.Sprintf("verify.retries 应在 0 到 %d 之间", maxVerifyRetries))
+	}
+	if c.Interval < 1 || c.Interval > maxVerifyInterval {
+		problems = append(problems, fmt.Sprintf("verify.interval 应在 1 到 %d 秒之间", maxVerifyInterval))
+	}
+	if c.GracePeriod < 0 || c.GracePeriod > maxVerifyGracePeriod {
+		problems = append(problems, fmt.Sprintf("verify.grace_period 应在 0 到 %d 秒之间", maxVerifyGracePeriod))
+	}
+	for i, command := range c.RollbackCommands {
+		if strings.TrimSpace(command) == "" {
+			problems = append(problems, fmt.Sprintf("verify.rollback_commands 第 %d 条为空", i+1))
+		}
+	}
+
+	worst := c.GracePeriod
+	for i := range c.Checks {
+		check := &c.Checks[i]
+		problems = append(problems, check.normalize(i)...)
+		worst += (*c.Retries + 1) * (check.Timeout + c.Interval)
+	}
+	if len(problems) == 0 && worst > maxVerifyDuration {
+		problems = append(problems, fmt.Sprintf("verify 全部重试时最长需要 %d 秒，超过 %d 秒上限，请减少重试次数或超时", worst, maxVerifyDuration))
+	}
+	return problems
+}
+
+// normalize 补全检查项的默认值并校验，i 为检查项的序号
+func (c *VerifyCheck) normalize(i int) []string {
+	if c.Name == "" {
+		c.Name = fmt.Sprintf("%s-%d", c.Type, i+1)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultVerifyTimeout
+	}
+
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("verify 检查 %s: ", c.Name)+fmt.Sprintf(format, args...))
+	}
+	if c.Timeout < 1 || c.Timeout > maxVerifyTimeout {
+		fail("timeout 应在 1 到 %d 秒之间", maxVerifyTimeout)
+	}
+
+	switch c.From {
+	case "":
+		c.From = VerifyFromServer
+		if c.Type == VerifyCommand || c.Type == VerifySystemd {
+			c.From = VerifyFromTarget
+		}
+	case VerifyFromServer, VerifyFromTarget:
+	default:
+		fail("from 只能是 server 或 target")
+	}
+
+	switch c.Type {
+	case VerifyHTTP:
+		parsed, err := url.Parse(c.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			fail("url 应为 http 或 https 地址")
+		}
+		if c.ExpectStatus != 0 && (c.ExpectStatus < 100 || c.ExpectStatus > 599) {
+			fail("expect_status 无效")
+		}
+	case VerifyTCP:
+		if c.Port < 1 || c.Port > 65535 {
+			fail("port 应在 1 到 65535 之间")
+		}
+		if c.Host != "" && !verifyHostPattern.MatchString(c.Host) {
+			fail("host 无效")
+		}
+	case VerifyCommand:
+		if strings.TrimSpace(c.Command) == "" {
+			fail("缺少 command")
+		}
+	case VerifySystemd:
+		if !verifyUnitPattern.MatchString(c.Unit) {
+			fail("unit 无效")
+		}
+	default:
+		fail("type 只能是 http、tcp、command 或 systemd")
+	}
+	if (c.Type == VerifyCommand || c.Type == VerifySystemd) && c.From == VerifyFromServer {
+		fail("%s 检查只能在部署目标上执行", c.Type)
+	}
+	return problems
+}
+
+// Verifier 部署后验证：部署动作完成后探测服务是否正常，全部通过才视为部署成功
+type Verifier struct {
+	sshClient *ssh.Client
+}
+
+// NewVerifier 创建部署后验证器
+func NewVerifier(cfg *config.Config) *Verifier {
+	return &Verifier{sshClient: ssh.NewClient(cfg)}
+}
+
+// Run 等待宽限期后依次执行检查，每项检查在重试次数内通过即可；ctx 取消时未完成的检查记为未通过。
+// 每次探测失败时调用 onFailure，用于写入运行日志
+func (v *Verifier) Run(ctx context.Context, target *DriftTarget, cfg *VerifyConfig, onFailure func(check VerifyCheck, attempt int, err error)) *VerifyReport {
+	report := &VerifyReport{Target: target.Key(), Passed: true, StartedAt: time.Now()}
+	defer func() { report.FinishedAt = time.Now() }()
+
+	waitErr := sleepContext(ctx, time.Duration(cfg.GracePeriod)*time.Second)
+	for _, check := range cfg.Checks {
+		result := VerifyResult{Name: check.Name, Type: check.Type, From: check.From}
+		startedAt := time.Now()
+		for attempt := 0; attempt <= *cfg.Retries && waitErr == nil; attempt++ {
+			if attempt > 0 {
+				if waitErr = sleepContext(ctx, time.Duration(cfg.Interval)*time.Second); waitErr != nil {
+					break
+				}
+			}
+			result.Attempts++
+			message, err := v.probe(ctx, target, check)
+			if err == nil {
+				result.Passed, result.Message = true, message
+				break
+			}
+			result.Message = err.Error()
+			if onFailure != nil {
+				onFailure(check, result.Attempts, err)
+			}
+		}
+		if waitErr != nil && !result.Passed {
+			result.Message = "验证已取消: " + waitErr.Error()
+		}
+		result.DurationMs = time.Since(startedAt).Milliseconds()
+		if !result.Passed {
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// probe 执行一次探测，通过时返回结果描述
+func (v *Verifier) probe(ctx context.Context, target *DriftTarget, check VerifyCheck) (string, error) {
+	timeout := time.Duration(check.Timeout) * time.Second
+	if check.From == VerifyFromServer {
+		switch check.Type {
+		case VerifyHTTP:
+			return probeHTTP(ctx, check, timeout)
+		case VerifyTCP:
+			host := check.Host
+			if host == "" {
+				host = target.Host
+			}
+			return probeTCP(ctx, host, check.Port, timeout)
+		}
+		return "", fmt.Errorf("%s 检查只能在部署目标上执行", check.Type)
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := v.sshClient.WithBastion(target.Bastion).ExecuteCommandStream(probeCtx, target.SSHKey,
+		target.Host, target.Port, target.Username, remoteProbeCommand(check), ssh.StreamOptions{MaxOutputBytes: maxVerifyBodyBytes})
+	if err != nil {
+		return "", err
+	}
+	output := strings.TrimSpace(result.Output)
+
+	switch check.Type {
+	case VerifyHTTP:
+		// 输出最后一行是 curl 写出的状态码
+		body, status := "", output
+		if idx := strings.LastIndex(output, "\n"); idx >= 0 {
+			body, status = output[:idx], output[idx+1:]
+		}
+		if result.ExitCode != 0 {
+			return "", fmt.Errorf("curl 退出码 %d: %s", result.ExitCode, lastLine(body))
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(status))
+		if err != nil {
+			return "", fmt.Errorf("无法解析状态码: %q", status)
+		}
+		return checkHTTPResponse(check, code, body)
+	case VerifySystemd:
+		if result.ExitCode != 0 {
+			return "", fmt.Errorf("服务 %s 状态为 %s", check.Unit, lastLine(output))
+		}
+		return fmt.Sprintf("服务 %s 状态为 active", check.Unit), nil
+	default:
+		if result.ExitCode != 0 {
+			return "", fmt.Errorf("退出码 %d: %s", result.ExitCode, lastLine(output))
+		}
+		return "退出码 0", nil
+	}
+}
+
+// remoteProbeCommand 在部署目标上执行的探测命令
+func remoteProbeCommand(check VerifyCheck) string {
+	switch check.Type {
+	case VerifyHTTP:
+		return fmt.Sprintf("curl -sS --max-time %d -o - -w '\\n%%{http_code}' %s", check.Timeout, ssh.ShellQuote(check.URL))
+	case VerifyTCP:
+		host := check.Host
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		return "bash -c " + ssh.ShellQuote(fmt.Sprintf("exec 3<>/dev/tcp/%s/%d", host, check.Port))
+	case VerifySystemd:
+		return "systemctl is-active " + ssh.ShellQuote(check.Unit)
+	default:
+		return check.Command
+	}
+}
+
+// probeHTTP 从本服务请求地址
+func probeHTTP(ctx context.Context, check VerifyCheck, timeout time.Duration) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpclient.New(timeout).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxVerifyBodyBytes))
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+	return checkHTTPResponse(check, resp.StatusCode, string(body))
+}
+
+// checkHTTPResponse 检查状态码与响应内容是否符合期望
+func checkHTTPResponse(check VerifyCheck, status int, body string) (string, error) {
+	if check.ExpectStatus != 0 && status != check.ExpectStatus {
+		return "", fmt.Errorf("状态码 %d，期望 %d", status, check.ExpectStatus)
+	}
+	if check.ExpectStatus == 0 && (status < 200 || status > 299) {
+		return "", fmt.Errorf("状态码 %d，期望 2xx", status)
+	}
+	if check.ExpectBody != "" && !strings.Contains(body, check.ExpectBody) {
+		return "", fmt.Errorf("状态码 %d，响应中没有 %q", status, check.ExpectBody)
+	}
+	return fmt.Sprintf("状态码 %d", status), nil
+}
+
+// probeTCP 从本服务连接端口
+func probeTCP(ctx context.Context, host string, port int, timeout time.Duration) (string, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	address := net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port))
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return fmt.Sprintf("%s 可以连接", address), nil
+}
+
+// sleepContext 等待指定时间，ctx 先结束时返回其错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// lastLine 输出的最后一行，用于错误信息
+func lastLine(output string) string {
+	output = strings.TrimSpace(output)
+	if idx := strings.LastIndex(output, "\n"); idx >= 0 {
+		return output[idx+1:]
+	}
+	return output
+}
@@ -0,0 +1,168 @@
+package deploy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// flakyService 前 failures 次请求返回 503，之后返回 200 与 "ok"，记录收到的请求数
+func flakyService(t *testing.T, failures int32) (string, *int32) {
+	t.Helper()
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("status: ok"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL + "/healthz", &requests
+}
+
+// parseVerify 按流水线配置中的写法解析 verify 配置
+func parseVerify(t *testing.T, value map[string]interface{}) *VerifyConfig {
+	t.Helper()
+	cfg, err := ParseVerifyConfig(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+var verifyTarget = &DriftTarget{Host: "127.0.0.1", Port: 22, Username: "deploy", RemoteDir: "/srv/web"}
+
+// TestVerifyHealthyOnThirdProbe 服务在第三次探测时恢复正常：检查在重试次数内通过，部署视为成功，
+// 前两次失败的探测都通知调用方
+func TestVerifyHealthyOnThirdProbe(t *testing.T) {
+	url, requests := flakyService(t, 2)
+	cfg := parseVerify(t, map[string]interface{}{
+		"retries":  float64(3),
+		"interval": float64(1),
+		"checks":   []interface{}{map[string]interface{}{"name": "healthz", "type": "http", "url": url, "expect_status": float64(200), "expect_body": "ok"}},
+	})
+
+	var failures []int
+	report := NewVerifier(&config.Config{}).Run(context.Background(), verifyTarget, cfg, func(check VerifyCheck, attempt int, err error) {
+		failures = append(failures, attempt)
+	})
+	if !report.Passed || len(report.Checks) != 1 {
+		t.Fatalf("验证结果为 %+v，应通过", report)
+	}
+	check := report.Checks[0]
+	if !check.Passed || check.Attempts != 3 || *requests != 3 || check.Message != "状态码 200" {
+		t.Errorf("检查结果为 %+v（请求 %d 次），应在第 3 次探测时通过", check, *requests)
+	}
+	if len(failures) != 2 || failures[0] != 1 || failures[1] != 2 {
+		t.Errorf("失败的探测为 %v，应为第 1、2 次", failures)
+	}
+	if report.Target != verifyTarget.Key() || report.FinishedAt.Before(report.StartedAt) {
+		t.Errorf("验证目标为 %s，开始于 %v、结束于 %v", report.Target, report.StartedAt, report.FinishedAt)
+	}
+}
+
+// TestVerifyFailsAfterRetries 重试用完仍未通过时部署视为失败，记录每项检查的结果；一项检查失败不影响其他检查执行
+func TestVerifyFailsAfterRetries(t *testing.T) {
+	url, requests := flakyService(t, 10)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	cfg := parseVerify(t, map[string]interface{}{
+		"retries":  float64(1),
+		"interval": float64(1),
+		"checks": []interface{}{
+			map[string]interface{}{"name": "healthz", "type": "http", "url": url},
+			map[string]interface{}{"name": "port", "type": "tcp", "port": float64(port)},
+		},
+	})
+	report := NewVerifier(&config.Config{}).Run(context.Background(), verifyTarget, cfg, nil)
+	if report.Passed || len(report.Checks) != 2 {
+		t.Fatalf("验证结果为 %+v，应未通过", report)
+	}
+	if check := report.Checks[0]; check.Passed || check.Attempts != 2 || *requests != 2 || check.Message != "状态码 503，期望 2xx" {
+		t.Errorf("HTTP 检查结果为 %+v，应重试 1 次后仍未通过", check)
+	}
+	if check := report.Checks[1]; !check.Passed || check.Attempts != 1 || check.From != VerifyFromServer {
+		t.Errorf("TCP 检查结果为 %+v，应在本服务上探测并通过", check)
+	}
+	if summary := report.Summary(); !strings.Contains(summary, "healthz（2 次）") || strings.Contains(summary, "port") {
+		t.Errorf("摘要为 %q，应只列出未通过的检查", summary)
+	}
+}
+
+// TestVerifyCancelled 部署取消时宽限期内停止等待，未完成的检查记为未通过
+func TestVerifyCancelled(t *testing.T) {
+	url, requests := flakyService(t, 0)
+	cfg := parseVerify(t, map[string]interface{}{
+		"grace_period": float64(30),
+		"checks":       []interface{}{map[string]interface{}{"type": "http", "url": url}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	startedAt := time.Now()
+	report := NewVerifier(&config.Config{}).Run(ctx, verifyTarget, cfg, nil)
+	if time.Since(startedAt) > 5*time.Second {
+		t.Errorf("取消后 %v 验证才结束", time.Since(startedAt))
+	}
+	if report.Passed || report.Checks[0].Attempts != 0 || *requests != 0 || !strings.HasPrefix(report.Checks[0].Message, "验证已取消") {
+		t.Errorf("检查结果为 %+v，取消时不应再探测", report.Checks[0])
+	}
+}
+
+// TestParseVerifyConfig 补全默认值，超时、重试与执行位置超出范围时报告全部问题
+func TestParseVerifyConfig(t *testing.T) {
+	cfg := parseVerify(t, map[string]interface{}{
+		"checks": []interface{}{
+			map[string]interface{}{"type": "systemd", "unit": "app.service"},
+			map[string]interface{}{"type": "tcp", "port": float64(8080)},
+		},
+	})
+	if *cfg.Retries != defaultVerifyRetries || cfg.Interval != defaultVerifyInterval {
+		t.Errorf("重试 %d 次、间隔 %d 秒，应为默认值", *cfg.Retries, cfg.Interval)
+	}
+	if c := cfg.Checks[0]; c.Name != "systemd-1" || c.From != VerifyFromTarget || c.Timeout != defaultVerifyTimeout {
+		t.Errorf("systemd 检查补全为 %+v，应默认在目标上执行", c)
+	}
+	if c := cfg.Checks[1]; c.From != VerifyFromServer {
+		t.Errorf("tcp 检查默认在 %s 上执行，应为 server", c.From)
+	}
+	if cfg, err := ParseVerifyConfig(nil); cfg != nil || err != nil {
+		t.Errorf("未配置时返回 %+v（%v）", cfg, err)
+	}
+
+	tests := []struct {
+		name  string
+		value map[string]interface{}
+		want  string
+	}{
+		{"没有检查", map[string]interface{}{"checks": []interface{}{}}, "至少需要一项检查"},
+		{"未知字段", map[string]interface{}{"check": []interface{}{}}, "无法解析"},
+		{"超时过长", map[string]interface{}{"checks": []interface{}{map[string]interface{}{"type": "tcp", "port": float64(80), "timeout": float64(301)}}}, "timeout 应在 1 到 300 秒之间"},
+		{"重试过多", map[string]interface{}{"retries": float64(101), "checks": []interface{}{map[string]interface{}{"type": "tcp", "port": float64(80)}}}, "verify.retries"},
+		{"间隔为负", map[string]interface{}{"interval": float64(-1), "checks": []interface{}{map[string]interface{}{"type": "tcp", "port": float64(80)}}}, "verify.interval"},
+		{"总耗时过长", map[string]interface{}{"retries": float64(100), "interval": float64(30), "checks": []interface{}{map[string]interface{}{"type": "tcp", "port": float64(80)}}}, "超过 3600 秒上限"},
+		{"命令在本服务上执行", map[string]interface{}{"checks": []interface{}{map[string]interface{}{"type": "command", "command": "true", "from": "server"}}}, "只能在部署目标上执行"},
+		{"地址无效", map[string]interface{}{"checks": []interface{}{map[string]interface{}{"type": "http", "url": "ftp://app"}}}, "url 应为 http 或 https 地址"},
+		{"服务单元无效", map[string]interface{}{"checks": []interface{}{map[string]interface{}{"type": "systemd", "unit": "app; reboot"}}}, "unit 无效"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseVerifyConfig(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("返回 %v，应包含 %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	// DefaultContext 每处变化前后保留的上下文行数
+	DefaultContext = 3
+	// DefaultMaxLines 输出的差异最多包含的行数，超出时截断
+	DefaultMaxLines = 2000
+	// diffTimeout 计算差异的时间上限，超时后结果仍然正确但不一定最短
+	diffTimeout = 2 * time.Second
+)
+
+// Options 生成统一格式差异的选项
+type Options struct {
+	Context          int  // 上下文行数，默认 3
+	MaxLines         int  // 输出行数上限，默认 2000
+	IgnoreWhitespace bool // 忽略行内空白的变化（缩进、行尾空格、连续空格）
+}
+
+// Result 统一格式的差异
+type Result struct {
+	Diff      string `json:"diff"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Identical bool   `json:"identical"`
+	Truncated bool   `json:"truncated"`
+}
+
+// lineOp 差异中的一行：' ' 未变化、'-' 删除、'+' 新增
+type lineOp struct {
+	kind byte
+	text string
+	old  int // 在旧文本中的行号（从 0 开始），新增行为下一行旧文本的行号
+	new  int
+}
+
+// Unified 按行比较两段文本，生成带文件头与 @@ 区块的统一格式差异
+func Unified(fromName, toName, before, after string, opts Options) Result {
+	if opts.Context <= 0 {
+		opts.Context = DefaultContext
+	}
+	if opts.MaxLines <= 0 {
+		opts.MaxLines = DefaultMaxLines
+	}
+
+	ops := lineDiff(splitLines(before), splitLines(after), opts.IgnoreWhitespace)
+
+	result := Result{Identical: true}
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			result.Added++
+			result.Identical = false
+		case '-':
+			result.Removed++
+			result.Identical = false
+		}
+	}
+	if result.Identical {
+		return result
+	}
+
+	var out []string
+	out = append(out, "--- "+fromName, "+++ "+toName)
+	for _, hunk := range hunks(ops, opts.Context) {
+		out = append(out, hunk...)
+	}
+	if len(out) > opts.MaxLines {
+		out = out[:opts.MaxLines]
+		result.Truncated = true
+	}
+	result.Diff = strings.Join(out, "\n") + "\n"
+	return result
+}
+
+// splitLines 按行拆分，末尾的换行不产生空行
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// lineDiff 将每行映射为一个字符后用 diffmatchpatch 计算最短编辑序列，再还原为行。
+// 忽略空白时按规范化后的内容映射，输出仍使用原始行
+func lineDiff(a, b []string, ignoreWhitespace bool) []lineOp {
+	index := make(map[string]rune)
+	encode := func(lines []string) []rune {
+		runes := make([]rune, len(lines))
+		for i, line := range lines {
+			key := line
+			if ignoreWhitespace {
+				key = strings.Join(strings.Fields(line), " ")
+			}
+			r, ok := index[key]
+			if !ok {
+				r = lineRune(len(index))
+				index[key] = r
+			}
+			runes[i] = r
+		}
+		return runes
+	}
+	runesA, runesB := encode(a), encode(b)
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = diffTimeout
+	diffs := dmp.DiffMainRunes(runesA, runesB, false)
+
+	var ops []lineOp
+	i, j := 0, 0
+	for _, d := range diffs {
+		n := len([]rune(d.Text))
+		for k := 0; k < n; k++ {
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				// 忽略空白时相同的行取新文本中的内容
+				ops = append(ops, lineOp{kind: ' ', text: b[j], old: i, new: j})
+				i++
+				j++
+			case diffmatchpatch.DiffDelete:
+				ops = append(ops, lineOp{kind: '-', text: a[i], old: i, new: j})
+				i++
+			case diffmatchpatch.DiffInsert:
+				ops = append(ops, lineOp{kind: '+', text: b[j], old: i, new: j})
+				j++
+			}
+		}
+	}
+	return ops
+}
+
+// lineRune 第 n 个不同行对应的字符，跳过代理区以保证与字符串互转不丢失
+func lineRune(n int) rune {
+	r := rune(n + 1)
+	if r >= 0xD800 {
+		r += 0x800
+	}
+	return r
+}
+
+// hunks 将逐行差异分组为区块，相邻变化之间不超过 2*context 行未变化内容时合并
+func hunks(ops []lineOp, context int) [][]string {
+	var result [][]string
+	for start := 0; start < len(ops); {
+		// 找到下一处变化
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		// 向后扩展到未变化行超过 2*context 为止
+		last := first
+		for k := first; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			} else if k-last > 2*context {
+				break
+			}
+		}
+
+		from := first - context
+		if from < start {
+			from = start
+		}
+		to := last + context + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		oldStart, newStart := ops[from].old, ops[from].new
+		oldCount, newCount := 0, 0
+		lines := []string{""}
+		for _, op := range ops[from:to] {
+			lines = append(lines, string(op.kind)+op.text)
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		lines[0] = fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		result = append(result, lines)
+		start = to
+	}
+	return result
+}
+
+// hunkRange 区块头中的行范围，空范围按惯例使用前一行的行号
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,533 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/models"
+)
+
+// 投递请求头，签名为 "sha256=" 加请求体 HMAC-SHA256 的十六进制摘要，与外部回调签名格式相同
+const (
+	SignatureHeader = "X-FlowForge-Signature"
+	EventIDHeader   = "X-FlowForge-Event-ID"
+	EventTypeHeader = "X-FlowForge-Event"
+	SequenceHeader  = "X-FlowForge-Sequence"
+)
+
+const (
+	// batchSize 每次从发件箱读取的事件数
+	batchSize = 100
+	// pollInterval 没有新事件通知时检查发件箱的间隔
+	pollInterval = 2 * time.Second
+	// settleDelay 只投递写入超过该时长的事件：并发事务可能晚于序号更大的事件提交，
+	// 等待其提交后再投递，避免游标越过尚未提交的事件
+	settleDelay = 2 * time.Second
+	// maxBackoff 投递失败后重试间隔的上限
+	maxBackoff = 5 * time.Minute
+	// replayAttempts 重放时单个事件的最大尝试次数，超过后重放中止
+	replayAttempts = 5
+	// deliveryTimeout 单次投递请求的超时
+	deliveryTimeout = 30 * time.Second
+)
+
+var (
+	// ErrSinkNotFound 事件接收端不存在
+	ErrSinkNotFound = errors.New("事件接收端不存在")
+	// ErrReplayRunning 接收端已有正在进行的重放
+	ErrReplayRunning = errors.New("事件接收端已有正在进行的重放")
+)
+
+var (
+	mu      sync.RWMutex
+	enabled *Dispatcher
+)
+
+// Dispatcher 事件投递器：每个接收端一个协程，按发件箱序号逐条投递，
+// 当前事件投递成功后才投递下一条，因此同一资源的事件按发生顺序到达
+type Dispatcher struct {
+	sinks     []*sink
+	retention time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	replayMu sync.Mutex
+	replays  map[string]*ReplayStatus
+}
+
+// sink 事件接收端
+type sink struct {
+	name   string
+	url    string
+	secret string
+	types  []string
+	client *http.Client
+	wake   chan struct{}
+}
+
+// SinkStatus 接收端的投递状态
+type SinkStatus struct {
+	Name            string        `json:"name"`
+	URL             string        `json:"url"`
+	Types           []string      `json:"types"`
+	LastSequence    uint          `json:"last_sequence"`
+	LastDeliveredAt *time.Time    `json:"last_delivered_at"`
+	Pending         int64         `json:"pending"`
+	Failures        int           `json:"failures"`
+	LastError       string        `json:"last_error"`
+	Replay          *ReplayStatus `json:"replay,omitempty"`
+}
+
+// ReplayStatus 重放进度
+type ReplayStatus struct {
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Total      int64      `json:"total"`
+	Sent       int64      `json:"sent"`
+	Status     string     `json:"status"` // running, completed, failed
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Init 根据配置创建事件投递器，配置了接收端时启用事件记录，应在启动时调用一次
+func Init(cfg *config.EventsConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		retention: time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		stop:      make(chan struct{}),
+		replays:   make(map[string]*ReplayStatus),
+	}
+	for i := range cfg.Sinks {
+		s, err := newSink(&cfg.Sinks[i])
+		if err != nil {
+			return nil, err
+		}
+		d.sinks = append(d.sinks, s)
+	}
+
+	mu.Lock()
+	if len(d.sinks) > 0 {
+		enabled = d
+	} else {
+		enabled = nil
+	}
+	mu.Unlock()
+
+	return d, nil
+}
+
+// current 已启用的投递器，未配置接收端时为 nil
+func current() *Dispatcher {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled
+}
+
+// newSink 创建接收端：使用共享的出站传输层（代理、CA证书与出站目标策略），
+// 配置了客户端证书时使用双向 TLS，接收端的CA证书追加在共享的CA证书之上
+func newSink(cfg *config.EventSinkConfig) (*sink, error) {
+	var certs []tls.Certificate
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取事件接收端 %s 的客户端证书失败: %w", cfg.Name, err)
+		}
+		certs = []tls.Certificate{cert}
+	}
+	var caPEM []byte
+	if cfg.CACertFile != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取事件接收端 %s 的CA证书失败: %w", cfg.Name, err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书文件中没有有效的证书: %s", cfg.CACertFile)
+		}
+		caPEM = pem
+	}
+
+	client := httpclient.NewWithTLS(deliveryTimeout, func(tlsConfig *tls.Config) {
+		if tlsConfig.MinVersion < tls.VersionTLS12 {
+			tlsConfig.MinVersion = tls.VersionTLS12
+		}
+		if len(certs) > 0 {
+			tlsConfig.Certificates = certs
+		}
+		if caPEM != nil {
+			pool := tlsConfig.RootCAs
+			if pool == nil {
+				pool, _ = x509.SystemCertPool()
+			}
+			if pool == nil {
+				pool = x509.NewCertPool()
+			} else {
+				pool = pool.Clone()
+			}
+			pool.AppendCertsFromPEM(caPEM)
+			tlsConfig.RootCAs = pool
+		}
+	})
+
+	return &sink{
+		name:   cfg.Name,
+		url:    cfg.URL,
+		secret: cfg.Secret,
+		types:  cfg.Types,
+		client: client,
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Start 为每个接收端启动投递协程，启动前创建投递进度，避免新接收端漏掉启动期间的事件
+func (d *Dispatcher) Start() {
+	for _, s := range d.sinks {
+		if _, err := d.cursor(s.name); err != nil {
+			log.Printf("事件接收端 %s: %v", s.name, err)
+		}
+		go d.run(s)
+	}
+}
+
+// Stop 停止投递，未投递的事件保留在发件箱中，重启后继续投递
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() { close(d.stop) })
+}
+
+// notify 通知各接收端有新事件
+func (d *Dispatcher) notify() {
+	for _, s := range d.sinks {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run 接收端的投递循环
+func (d *Dispatcher) run(s *sink) {
+	for {
+		delivered, err := d.deliverPending(s)
+		if err != nil {
+			log.Printf("投递事件到接收端 %s 失败: %v", s.name, err)
+		}
+		if err == nil && delivered == batchSize {
+			continue
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-s.wake:
+			time.Sleep(settleDelay)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// deliverPending 按顺序投递游标之后的一批事件，返回读取的事件数；
+// 投递失败时按退避间隔重试同一事件，直到成功或投递器停止
+func (d *Dispatcher) deliverPending(s *sink) (int, error) {
+	cursor, err := d.cursor(s.name)
+	if err != nil {
+		return 0, err
+	}
+
+	var records []models.EventOutbox
+	if err := database.DB.Where("id > ? AND created_at < ?", cursor.LastSequence, time.Now().Add(-settleDelay)).
+		Order("id").Limit(batchSize).Find(&records).Error; err != nil {
+		return 0, fmt.Errorf("读取事件发件箱失败: %w", err)
+	}
+
+	for _, record := range records {
+		if s.accepts(record.Type) {
+			for {
+				err := s.send(&record, false)
+				if err == nil {
+					break
+				}
+				cursor.Failures++
+				cursor.LastError = err.Error()
+				database.DB.Save(cursor)
+
+				select {
+				case <-d.stop:
+					return 0, nil
+				case <-time.After(backoff(cursor.Failures)):
+				}
+			}
+			now := time.Now()
+			cursor.LastDeliveredAt = &now
+		}
+
+		cursor.LastSequence = record.ID
+		cursor.Failures = 0
+		cursor.LastError = ""
+		if err := database.DB.Save(cursor).Error; err != nil {
+			return 0, fmt.Errorf("保存投递进度失败: %w", err)
+		}
+	}
+	return len(records), nil
+}
+
+// cursor 接收端的投递进度，新增的接收端从当前最新的事件开始投递，更早的事件可通过重放投递
+func (d *Dispatcher) cursor(name string) (*models.EventSinkCursor, error) {
+	var cursor models.EventSinkCursor
+	result := database.DB.Where("sink = ?", name).Limit(1).Find(&cursor)
+	if result.Error != nil {
+		return nil, fmt.Errorf("读取投递进度失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &cursor, nil
+	}
+
+	var latest uint
+	database.DB.Model(&models.EventOutbox{}).Select("COALESCE(MAX(id), 0)").Scan(&latest)
+	cursor = models.EventSinkCursor{Sink: name, LastSequence: latest}
+	if err := database.DB.Create(&cursor).Error; err != nil {
+		return nil, fmt.Errorf("创建投递进度失败: %w", err)
+	}
+	return &cursor, nil
+}
+
+// accepts 接收端是否订阅该类型的事件
+func (s *sink) accepts(eventType string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, prefix := range s.types {
+		if strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// send 投递单个事件，接收端返回 2xx 视为成功
+func (s *sink) send(record *models.EventOutbox, replayed bool) error {
+	var event Event
+	if err := json.Unmarshal([]byte(record.Payload), &event); err != nil {
+		return fmt.Errorf("解析事件 %s 失败: %w", record.EventID, err)
+	}
+	event.Sequence = record.ID
+	event.Replayed = replayed
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "FlowForge-Events")
+	req.Header.Set(EventIDHeader, event.ID)
+	req.Header.Set(EventTypeHeader, event.Type)
+	req.Header.Set(SequenceHeader, strconv.FormatUint(uint64(record.ID), 10))
+	if s.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("接收端返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign 请求体签名，接收端用同一密钥计算后比较
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff 第 failures 次失败后的重试间隔，从 1 秒开始翻倍
+func backoff(failures int) time.Duration {
+	if failures > 9 {
+		return maxBackoff
+	}
+	wait := time.Second << (failures - 1)
+	if wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
+
+// Replay 在后台重新投递 [from, to) 时间范围内的事件到接收端，事件带 replayed 标记，返回事件数。
+// 重放独立于正常投递的进度，接收端按事件ID去重
+func (d *Dispatcher) Replay(name string, from, to time.Time) (int64, error) {
+	s := d.findSink(name)
+	if s == nil {
+		return 0, ErrSinkNotFound
+	}
+
+	d.replayMu.Lock()
+	defer d.replayMu.Unlock()
+	if status := d.replays[name]; status != nil && status.Status == "running" {
+		return 0, ErrReplayRunning
+	}
+
+	var total int64
+	if err := database.DB.Model(&models.EventOutbox{}).
+		Where("created_at >= ? AND created_at < ?", from, to).Count(&total).Error; err != nil {
+		return 0, fmt.Errorf("查询事件发件箱失败: %w", err)
+	}
+
+	status := &ReplayStatus{From: from, To: to, Total: total, Status: "running", StartedAt: time.Now()}
+	d.replays[name] = status
+	go d.replay(s, status)
+	return total, nil
+}
+
+// replay 按序号逐条重放，单个事件多次失败后中止
+func (d *Dispatcher) replay(s *sink, status *ReplayStatus) {
+	err := d.replayRange(s, status)
+
+	d.replayMu.Lock()
+	defer d.replayMu.Unlock()
+	now := time.Now()
+	status.FinishedAt = &now
+	status.Status = "completed"
+	if err != nil {
+		status.Status = "failed"
+		status.Error = err.Error()
+		log.Printf("重放事件到接收端 %s 失败: %v", s.name, err)
+	}
+}
+
+func (d *Dispatcher) replayRange(s *sink, status *ReplayStatus) error {
+	var after uint
+	for {
+		var records []models.EventOutbox
+		if err := database.DB.Where("id > ? AND created_at >= ? AND created_at < ?", after, status.From, status.To).
+			Order("id").Limit(batchSize).Find(&records).Error; err != nil {
+			return fmt.Errorf("读取事件发件箱失败: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		for i := range records {
+			record := &records[i]
+			after = record.ID
+			if s.accepts(record.Type) {
+				if err := d.sendWithRetry(s, record); err != nil {
+					return fmt.Errorf("事件 %s: %w", record.EventID, err)
+				}
+			}
+			d.replayMu.Lock()
+			status.Sent++
+			d.replayMu.Unlock()
+		}
+	}
+}
+
+// sendWithRetry 重放单个事件，最多尝试 replayAttempts 次
+func (d *Dispatcher) sendWithRetry(s *sink, record *models.EventOutbox) error {
+	var err error
+	for attempt := 1; attempt <= replayAttempts; attempt++ {
+		if err = s.send(record, true); err == nil {
+			return nil
+		}
+		select {
+		case <-d.stop:
+			return err
+		case <-time.After(backoff(attempt)):
+		}
+	}
+	return err
+}
+
+// Status 各接收端的投递状态
+func (d *Dispatcher) Status() ([]SinkStatus, error) {
+	statuses := make([]SinkStatus, 0, len(d.sinks))
+	for _, s := range d.sinks {
+		cursor, err := d.cursor(s.name)
+		if err != nil {
+			return nil, err
+		}
+		status := SinkStatus{
+			Name:            s.name,
+			URL:             s.url,
+			Types:           s.types,
+			LastSequence:    cursor.LastSequence,
+			LastDeliveredAt: cursor.LastDeliveredAt,
+			Failures:        cursor.Failures,
+			LastError:       cursor.LastError,
+		}
+		database.DB.Model(&models.EventOutbox{}).Where("id > ?", cursor.LastSequence).Count(&status.Pending)
+
+		d.replayMu.Lock()
+		if replay := d.replays[s.name]; replay != nil {
+			copied := *replay
+			status.Replay = &copied
+		}
+		d.replayMu.Unlock()
+
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Prune 清理超过保留天数且所有接收端都已投递的事件
+func (d *Dispatcher) Prune() {
+	query := database.DB.Where("created_at < ?", time.Now().Add(-d.retention))
+	if len(d.sinks) > 0 {
+		names := make([]string, 0, len(d.sinks))
+		for _, s := range d.sinks {
+			names = append(names, s.name)
+		}
+		var delivered uint
+		database.DB.Model(&models.EventSinkCursor{}).Where("sink IN ?", names).
+			Select("COALESCE(MIN(last_sequence), 0)").Scan(&delivered)
+		query = query.Where("id <= ?", delivered)
+	}
+
+	result := query.Delete(&models.EventOutbox{})
+	if result.Error != nil {
+		log.Printf("清理事件发件箱失败: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("已清理 %d 条事件发件箱记录", result.RowsAffected)
+	}
+}
+
+// findSink 按名称查找接收端
+func (d *Dispatcher) findSink(name string) *sink {
+	for _, s := range d.sinks {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package events
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/models"
+)
+
+const (
+	testSink   = "audit-bus"
+	testSecret = "sink-secret"
+)
+
+// delivery 接收端收到的一次投递
+type delivery struct {
+	header http.Header
+	body   []byte
+	event  Event
+}
+
+// sinkServer 模拟事件接收端：记录收到的投递，前 fail 次投递返回 500
+type sinkServer struct {
+	mu         sync.Mutex
+	fail       int
+	deliveries []delivery
+}
+
+func (s *sinkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var event Event
+	json.Unmarshal(body, &event)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, delivery{header: r.Header.Clone(), body: body, event: event})
+	if s.fail > 0 {
+		s.fail--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *sinkServer) received() []delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]delivery(nil), s.deliveries...)
+}
+
+// setupDispatcher 内存数据库与 HTTPS 接收端，接收端只订阅 run. 开头的事件；
+// 与 Start 一样先创建投递进度，投递由测试调用 deliverPending 驱动
+func setupDispatcher(t *testing.T) (*Dispatcher, *sinkServer) {
+	t.Helper()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &sinkServer{}
+	ts := httptest.NewTLSServer(server)
+	t.Cleanup(ts.Close)
+	caFile := filepath.Join(t.TempDir(), "sink-ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := httpclient.Init(&config.NetworkConfig{Outbound: config.OutboundPolicyConfig{AllowCIDRs: []string{"127.0.0.1/32"}}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { httpclient.Init(&config.NetworkConfig{}) })
+
+	d, err := Init(&config.EventsConfig{Sinks: []config.EventSinkConfig{{
+		Name:       testSink,
+		URL:        ts.URL + "/events",
+		Secret:     testSecret,
+		CACertFile: caFile,
+		Types:      []string{"run."},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		d.Stop()
+		Init(&config.EventsConfig{})
+	})
+	if _, err := d.cursor(testSink); err != nil {
+		t.Fatal(err)
+	}
+	return d, server
+}
+
+func publish(t *testing.T, eventType, resourceID string) string {
+	t.Helper()
+	event := Event{
+		ID:       fmt.Sprintf("%s-%s", eventType, resourceID),
+		Type:     eventType,
+		Resource: Resource{Type: "pipeline_run", ID: resourceID},
+		Outcome:  OutcomeInProgress,
+	}
+	if err := Publish(database.DB, event); err != nil {
+		t.Fatal(err)
+	}
+	return event.ID
+}
+
+// settle 把发件箱中的事件改为 age 之前写入，使其超过 settleDelay 可以投递
+func settle(t *testing.T, age time.Duration, eventIDs ...string) {
+	t.Helper()
+	if err := database.DB.Model(&models.EventOutbox{}).Where("event_id IN ?", eventIDs).
+		Update("created_at", time.Now().Add(-age)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sinkStatus(t *testing.T, d *Dispatcher) SinkStatus {
+	t.Helper()
+	statuses, err := d.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statuses[0]
+}
+
+// TestDeliverPending 按发件箱序号投递已提交且超过 settleDelay 的事件，跳过未订阅的类型，
+// 请求带事件ID、类型、序号与签名，投递后游标前进到最后一条
+func TestDeliverPending(t *testing.T) {
+	d, server := setupDispatcher(t)
+
+	started := publish(t, TypeRunStarted, "1")
+	audit := publish(t, AuditType("update_project"), "7")
+	finished := publish(t, TypeRunFinished, "1")
+	database.DB.Transaction(func(tx *gorm.DB) error {
+		Publish(tx, Event{ID: "rolled-back", Type: TypeRunStarted, Resource: Resource{Type: "pipeline_run", ID: "2"}})
+		return errors.New("回滚")
+	})
+	unsettled := publish(t, TypeRunStarted, "3")
+	settle(t, time.Minute, started, audit, finished)
+
+	read, err := d.deliverPending(d.findSink(testSink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 3 {
+		t.Errorf("读取了 %d 条事件，应为 3 条：回滚事务中的事件不写入，刚写入的事件等待 settleDelay", read)
+	}
+
+	deliveries := server.received()
+	if len(deliveries) != 2 || deliveries[0].event.ID != started || deliveries[1].event.ID != finished {
+		t.Fatalf("接收端应按顺序收到 %s 与 %s，实际收到 %d 次投递", started, finished, len(deliveries))
+	}
+	var records []models.EventOutbox
+	database.DB.Where("event_id IN ?", []string{started, finished}).Order("id").Find(&records)
+	for i, got := range deliveries {
+		if got.event.Sequence != records[i].ID || got.header.Get(SequenceHeader) != fmt.Sprint(records[i].ID) {
+			t.Errorf("事件 %s 的序号为 %d（请求头 %s），应为发件箱记录ID %d", got.event.ID, got.event.Sequence, got.header.Get(SequenceHeader), records[i].ID)
+		}
+		if got.header.Get(EventIDHeader) != got.event.ID || got.header.Get(EventTypeHeader) != got.event.Type {
+			t.Errorf("请求头 %s=%s %s=%s 与事件不一致", EventIDHeader, got.header.Get(EventIDHeader), EventTypeHeader, got.header.Get(EventTypeHeader))
+		}
+		if got.header.Get(SignatureHeader) != Sign(testSecret, got.body) {
+			t.Errorf("事件 %s 的签名不正确", got.event.ID)
+		}
+		if got.event.SchemaVersion != SchemaVersion || got.event.Replayed {
+			t.Errorf("事件 %s 的 schema_version 为 %d、replayed 为 %v", got.event.ID, got.event.SchemaVersion, got.event.Replayed)
+		}
+	}
+
+	status := sinkStatus(t, d)
+	if status.LastSequence != records[1].ID || status.LastDeliveredAt == nil || status.Failures != 0 {
+		t.Errorf("投递进度为 %+v，应前进到最后一条已投递的事件 %d", status, records[1].ID)
+	}
+	if status.Pending != 1 {
+		t.Errorf("待投递 %d 条，应只剩未超过 settleDelay 的 %s", status.Pending, unsettled)
+	}
+}
+
+// TestDeliverRetry 接收端返回失败时记录失败次数与错误，按退避间隔重试同一事件，成功后清零，
+// 之后的事件不会越过失败的事件先投递
+func TestDeliverRetry(t *testing.T) {
+	d, server := setupDispatcher(t)
+	server.fail = 1
+
+	first := publish(t, TypeRunStarted, "1")
+	second := publish(t, TypeRunFinished, "1")
+	settle(t, time.Minute, first, second)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.deliverPending(d.findSink(testSink))
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status := sinkStatus(t, d); status.Failures != 1; status = sinkStatus(t, d) {
+		if time.Now().After(deadline) {
+			t.Fatalf("失败后投递进度为 %+v，应记录 1 次失败", status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status := sinkStatus(t, d); !strings.Contains(status.LastError, "500") {
+		t.Errorf("最近的错误为 %q，应包含接收端返回的状态码", status.LastError)
+	}
+	if got := server.received(); len(got) != 1 {
+		t.Errorf("退避期间收到 %d 次投递，后面的事件不应越过失败的事件", len(got))
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(backoff(1) + 5*time.Second):
+		t.Fatal("退避后没有重试")
+	}
+
+	deliveries := server.received()
+	ids := make([]string, len(deliveries))
+	for i, got := range deliveries {
+		ids[i] = got.event.ID
+	}
+	if want := []string{first, first, second}; strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("收到的事件依次为 %v，应为 %v", ids, want)
+	}
+	if status := sinkStatus(t, d); status.Failures != 0 || status.LastError != "" || status.Pending != 0 {
+		t.Errorf("重试成功后投递进度为 %+v，失败次数与错误应清零", status)
+	}
+}
+
+// TestReplay 重放时间范围内订阅的事件，事件带 replayed 标记且序号不变，不影响正常投递的进度
+func TestReplay(t *testing.T) {
+	d, server := setupDispatcher(t)
+
+	old := publish(t, TypeRunStarted, "1")
+	oldAudit := publish(t, AuditType("update_project"), "7")
+	recent := publish(t, TypeRunFinished, "1")
+	settle(t, 2*time.Hour, old, oldAudit)
+	settle(t, time.Minute, recent)
+	if _, err := d.deliverPending(d.findSink(testSink)); err != nil {
+		t.Fatal(err)
+	}
+	before := sinkStatus(t, d)
+	delivered := len(server.received())
+
+	if _, err := d.Replay("unknown", time.Time{}, time.Now()); !errors.Is(err, ErrSinkNotFound) {
+		t.Errorf("重放到不存在的接收端应返回 ErrSinkNotFound，实际为 %v", err)
+	}
+	total, err := d.Replay(testSink, time.Now().Add(-3*time.Hour), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 {
+		t.Errorf("时间范围内有 %d 条事件，应为 2 条", total)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	status := sinkStatus(t, d)
+	for status.Replay == nil || status.Replay.Status == "running" {
+		if time.Now().After(deadline) {
+			t.Fatal("重放没有结束")
+		}
+		time.Sleep(20 * time.Millisecond)
+		status = sinkStatus(t, d)
+	}
+	if status.Replay.Status != "completed" || status.Replay.Sent != 2 || status.Replay.FinishedAt == nil {
+		t.Errorf("重放状态为 %+v，应完成 2 条", status.Replay)
+	}
+
+	replayed := server.received()[delivered:]
+	if len(replayed) != 1 || replayed[0].event.ID != old || !replayed[0].event.Replayed {
+		t.Fatalf("重放应只投递订阅的 %s 且带 replayed 标记，实际为 %+v", old, replayed)
+	}
+	var record models.EventOutbox
+	database.DB.Where("event_id = ?", old).First(&record)
+	if replayed[0].event.Sequence != record.ID || replayed[0].header.Get(SignatureHeader) != Sign(testSecret, replayed[0].body) {
+		t.Errorf("重放的事件序号为 %d，应为原序号 %d，且签名正确", replayed[0].event.Sequence, record.ID)
+	}
+	if status.LastSequence != before.LastSequence || status.Pending != 0 {
+		t.Errorf("重放后投递进度为 %d（待投递 %d），应保持 %d", status.LastSequence, status.Pending, before.LastSequence)
+	}
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SchemaVersion 事件信封的版本。只允许增加字段，删除或改变已有字段的含义时才增加版本
+const SchemaVersion = 1
+
+// 事件类型，审计事件为 audit. 加审计操作名，如 audit.update_project
+const (
+	TypeRunStarted               = "run.started"
+	TypeRunFinished              = "run.finished"
+	TypeRunPerformanceRegression = "run.performance_regression"
+	TypeDeploymentSucceeded      = "deployment.succeeded"
+	TypeDeploymentFailed         = "deployment.failed"
+	TypeFailureSpike             = "project.failure_spike"
+	TypeFailureSpikeResolved     = "project.failure_spike_resolved"
+	auditTypePrefix              = "audit."
+)
+
+// 事件结果
+const (
+	OutcomeSuccess    = "success"
+	OutcomeFailure    = "failure"
+	OutcomeCancelled  = "cancelled"
+	OutcomeSkipped    = "skipped"
+	OutcomeInProgress = "in_progress"
+)
+
+// 操作者类型
+const (
+	ActorUser   = "user"
+	ActorSystem = "system"
+)
+
+// Event 投递到事件接收端的事件信封，字段含义在 schema 版本内保持稳定
+type Event struct {
+	SchemaVersion int                    `json:"schema_version" desc:"事件信封版本，只增加字段时不变"`
+	ID            string                 `json:"id" desc:"事件ID（UUID），至少投递一次，接收端按此去重"`
+	Sequence      uint                   `json:"sequence" desc:"发件箱序号，同一接收端按序号递增投递"`
+	Type          string                 `json:"type" desc:"事件类型，如 run.finished、deployment.succeeded、audit.update_project"`
+	Timestamp     time.Time              `json:"timestamp" desc:"事件发生时间（UTC）"`
+	Actor         Actor                  `json:"actor" desc:"触发事件的操作者"`
+	Resource      Resource               `json:"resource" desc:"事件涉及的资源"`
+	Outcome       string                 `json:"outcome" enum:"success,failure,cancelled,skipped,in_progress" desc:"操作结果"`
+	CorrelationID string                 `json:"correlation_id,omitempty" desc:"关联ID：API请求为 X-Request-ID，运行相关事件为 run-<运行ID>"`
+	Replayed      bool                   `json:"replayed,omitempty" desc:"通过重放接口再次投递时为 true"`
+	Data          map[string]interface{} `json:"data,omitempty" desc:"事件类型相关的附加数据"`
+}
+
+// Actor 触发事件的操作者
+type Actor struct {
+	Kind       string `json:"kind" enum:"user,system" desc:"user 为用户（含API令牌），system 为定时任务、Webhook 等系统触发"`
+	ID         uint   `json:"id,omitempty" desc:"用户ID"`
+	Username   string `json:"username,omitempty" desc:"事件发生时的用户名"`
+	APITokenID uint   `json:"api_token_id,omitempty" desc:"通过API令牌操作时的令牌ID"`
+}
+
+// Resource 事件涉及的资源
+type Resource struct {
+	Type       string `json:"type" desc:"资源类型，如 pipeline_run、deployment、project"`
+	ID         string `json:"id" desc:"资源ID"`
+	ProjectID  uint   `json:"project_id,omitempty" desc:"所属项目ID"`
+	PipelineID uint   `json:"pipeline_id,omitempty" desc:"所属流水线ID"`
+	RunID      uint   `json:"run_id,omitempty" desc:"关联的流水线运行ID"`
+}
+
+// AuditType 审计操作对应的事件类型
+func AuditType(action string) string {
+	return auditTypePrefix + action
+}
+
+// Enabled 是否配置了事件接收端，未配置时不需要构造事件
+func Enabled() bool {
+	return current() != nil
+}
+
+// Publish 将事件写入发件箱，db 为事务时事件随事务一起提交；未配置事件接收端时不记录
+func Publish(db *gorm.DB, event Event) error {
+	d := current()
+	if d == nil {
+		return nil
+	}
+
+	event.SchemaVersion = SchemaVersion
+	if event.Actor.Kind == "" {
+		event.Actor.Kind = ActorSystem
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.Timestamp = event.Timestamp.UTC()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %w", err)
+	}
+	record := models.EventOutbox{
+		EventID:     event.ID,
+		Type:        event.Type,
+		ResourceKey: event.Resource.Type + ":" + event.Resource.ID,
+		Payload:     string(payload),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return fmt.Errorf("写入事件发件箱失败: %w", err)
+	}
+
+	d.notify()
+	return nil
+}
+
+// UserActor 用户操作者，用户名从数据库读取，userID 为 0 时为系统
+func UserActor(userID uint) Actor {
+	if userID == 0 {
+		return Actor{Kind: ActorSystem}
+	}
+	actor := Actor{Kind: ActorUser, ID: userID}
+	if database.DB != nil {
+		database.DB.Model(&models.User{}).Select("username").Where("id = ?", userID).Scan(&actor.Username)
+	}
+	return actor
+}
+
+// RunOutcome 运行或部署状态对应的事件结果
+func RunOutcome(status string) string {
+	switch status {
+	case models.RunStatusSuccess:
+		return OutcomeSuccess
+	case models.RunStatusFailed:
+		return OutcomeFailure
+	case models.RunStatusCancelled:
+		return OutcomeCancelled
+	case models.RunStatusSkipped:
+		return OutcomeSkipped
+	default:
+		return OutcomeInProgress
+	}
+}
+
+// RunCorrelationID 运行相关事件的关联ID，同一运行的开始、结束与部署事件相同
+func RunCorrelationID(runID uint) string {
+	return "run-" + strconv.FormatUint(uint64(runID), 10)
+}
+
+// AuditEvent 审计日志对应的事件
+func AuditEvent(auditLog *models.AuditLog, actor Actor, correlationID string) Event {
+	return Event{
+		Type:      AuditType(auditLog.Action),
+		Timestamp: auditLog.CreatedAt,
+		Actor:     actor,
+		Resource: Resource{
+			Type: auditLog.ResourceType,
+			ID:   strconv.FormatUint(uint64(auditLog.ResourceID), 10),
+		},
+		Outcome:       OutcomeSuccess,
+		CorrelationID: correlationID,
+		Data: map[string]interface{}{
+			"audit_log_id": auditLog.ID,
+			"description":  auditLog.Description,
+			"ip":           auditLog.IP,
+			"user_agent":   auditLog.UserAgent,
+			"has_diff":     auditLog.Before != auditLog.After,
+		},
+	}
+}
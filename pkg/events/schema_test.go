@@ -0,0 +1,216 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// eventTypes 每种事件类型在 testdata 中有一份接收端收到的示例
+var eventTypes = []string{
+	TypeRunStarted,
+	TypeRunFinished,
+	TypeRunPerformanceRegression,
+	TypeDeploymentSucceeded,
+	TypeDeploymentFailed,
+	TypeFailureSpike,
+	TypeFailureSpikeResolved,
+	AuditType("update_project"),
+}
+
+// TestSchemaGolden 生成的 JSON Schema 与 docs/events/schema.json 一致，修改事件类型后需要重新生成文档
+func TestSchemaGolden(t *testing.T) {
+	generated, err := SchemaJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile(filepath.Join("..", "..", "docs", "events", "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, golden) {
+		t.Error("docs/events/schema.json 与事件类型不一致，请执行 go generate ./pkg/events")
+	}
+}
+
+// TestEventExamples 每种事件类型的示例符合 JSON Schema，且经过 Event 解析再序列化后不丢失字段，
+// 即投递时重新编码的事件与写入发件箱的内容一致
+func TestEventExamples(t *testing.T) {
+	schema := loadSchema(t)
+	for _, eventType := range eventTypes {
+		t.Run(eventType, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", eventType+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var example map[string]interface{}
+			if err := json.Unmarshal(data, &example); err != nil {
+				t.Fatal(err)
+			}
+			if example["type"] != eventType {
+				t.Errorf("示例的 type 为 %v，应为 %s", example["type"], eventType)
+			}
+			for _, problem := range validate(schema, example, "$") {
+				t.Error(problem)
+			}
+
+			var event Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				t.Fatal(err)
+			}
+			encoded, _ := json.Marshal(event)
+			var roundTrip map[string]interface{}
+			json.Unmarshal(encoded, &roundTrip)
+			if !reflect.DeepEqual(roundTrip, example) {
+				t.Errorf("重新编码后与示例不一致:\n%s", encoded)
+			}
+		})
+	}
+}
+
+// TestAuditEventMatchesExample 审计事件的附加数据字段与示例一致
+func TestAuditEventMatchesExample(t *testing.T) {
+	auditLog := &models.AuditLog{
+		ID:           311,
+		Action:       "update_project",
+		ResourceType: "project",
+		ResourceID:   7,
+		Description:  "更新项目 web",
+		IP:           "10.0.0.8",
+		UserAgent:    "flowforge-cli/1.4",
+		Before:       `{"name":"web"}`,
+		After:        `{"name":"web-app"}`,
+	}
+	auditLog.CreatedAt = time.Date(2026, 10, 1, 8, 1, 0, 0, time.UTC)
+	event := AuditEvent(auditLog, Actor{Kind: ActorUser, ID: 3, Username: "alice", APITokenID: 9}, "req-5f2a")
+
+	data, err := os.ReadFile(filepath.Join("testdata", AuditType("update_project")+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var example Event
+	if err := json.Unmarshal(data, &example); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != example.Type || event.Resource != example.Resource || event.Actor != example.Actor ||
+		event.Outcome != example.Outcome || event.CorrelationID != example.CorrelationID || !event.Timestamp.Equal(example.Timestamp) {
+		t.Errorf("审计事件为 %+v，与示例 %+v 不一致", event, example)
+	}
+	if got, want := sortedKeys(event.Data), sortedKeys(example.Data); !reflect.DeepEqual(got, want) {
+		t.Errorf("审计事件的附加数据字段为 %v，示例为 %v", got, want)
+	}
+}
+
+func loadSchema(t *testing.T) map[string]interface{} {
+	t.Helper()
+	data, err := SchemaJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validate 按事件 JSON Schema 用到的关键字（type、format、enum、const、minimum、properties、required、items）校验取值
+func validate(schema map[string]interface{}, value interface{}, path string) []string {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch schema["type"] {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			fail("应为字符串，实际为 %T", value)
+			return problems
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				fail("不是 RFC 3339 时间: %q", s)
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			fail("应为数字，实际为 %T", value)
+			return problems
+		}
+		if schema["type"] == "integer" && n != math.Trunc(n) {
+			fail("应为整数，实际为 %v", n)
+		}
+		if minimum, ok := schema["minimum"].(float64); ok && n < minimum {
+			fail("不能小于 %v，实际为 %v", minimum, n)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("应为布尔值，实际为 %T", value)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("应为数组，实际为 %T", value)
+			return problems
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				problems = append(problems, validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("应为对象，实际为 %T", value)
+			return problems
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				fail("缺少必填字段 %s", name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, field := range object {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				problems = append(problems, validate(property, field, path+"."+name)...)
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if allowed == value {
+				found = true
+			}
+		}
+		if !found {
+			fail("取值 %v 不在 %v 中", value, enum)
+		}
+	}
+	if constant, ok := schema["const"]; ok && constant != value {
+		fail("应为 %v，实际为 %v", constant, value)
+	}
+	return problems
+}
@@ -0,0 +1,199 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// 列表接口的导出格式
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ErrTooManyRows 筛选后的记录数超过导出上限，导出不分页，需要缩小时间范围或增加筛选条件
+var ErrTooManyRows = errors.New("导出的记录超过上限，请缩小筛选范围")
+
+// flushRows 每写出该行数后刷新一次，客户端可以边下载边处理
+const flushRows = 500
+
+var (
+	mu  sync.Mutex
+	cfg = &config.ExportConfig{MaxRows: 100000}
+)
+
+// Init 设置导出的最大行数，启动时调用
+func Init(c *config.ExportConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
+
+// CheckRows 记录数是否在导出上限内
+func CheckRows(total int64) error {
+	mu.Lock()
+	limit := cfg.MaxRows
+	mu.Unlock()
+	if limit > 0 && total > int64(limit) {
+		return fmt.Errorf("%w: 共 %d 条，最多导出 %d 条", ErrTooManyRows, total, limit)
+	}
+	return nil
+}
+
+// Negotiate 按 ?format= 或 Accept 头确定导出格式，返回空表示普通的 JSON 列表。
+// format 优先；Accept 按出现的顺序取第一个支持的类型，浏览器默认的 Accept 不会触发导出
+func Negotiate(format, accept string) string {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return FormatCSV
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON
+	case "":
+	default:
+		return ""
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return FormatCSV
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return FormatNDJSON
+		}
+	}
+	return ""
+}
+
+// ContentType 导出格式的响应类型
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Filename 导出的文件名：资源名与筛选的日期范围，如 runs-api-deploy-2026-01-01_2026-01-31.csv，未限定日期为 all
+func Filename(resource string, from, to *time.Time, format string) string {
+	dates := "all"
+	if from != nil || to != nil {
+		start, end := "start", time.Now().Format("2006-01-02")
+		if from != nil {
+			start = from.Format("2006-01-02")
+		}
+		if to != nil {
+			end = to.Format("2006-01-02")
+		}
+		dates = start + "_" + end
+	}
+	ext := "ndjson"
+	if format == FormatCSV {
+		ext = "csv"
+	}
+	return fmt.Sprintf("%s-%s.%s", safeName(resource), dates, ext)
+}
+
+// safeName 文件名中只保留字母、数字、点、下划线与连字符
+func safeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// Row 导出的一行：CSV 按 Record 的顺序写出各列，NDJSON 写出行本身的 JSON
+type Row interface {
+	Record() []string
+}
+
+// Writer 逐行写出导出内容，不在内存中保留已写出的行
+type Writer struct {
+	format  string
+	out     *bufio.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher interface{ Flush() }
+	rows    int
+}
+
+// NewWriter 创建导出写入器，CSV 先写出表头；flusher 非空时定期将已写出的内容发送给客户端
+func NewWriter(w io.Writer, format string, header []string, flusher interface{ Flush() }) (*Writer, error) {
+	out := bufio.NewWriter(w)
+	writer := &Writer{format: format, out: out, flusher: flusher}
+	if format == FormatCSV {
+		writer.csv = csv.NewWriter(out)
+		if err := writer.csv.Write(header); err != nil {
+			return nil, err
+		}
+	} else {
+		writer.json = json.NewEncoder(out)
+		writer.json.SetEscapeHTML(false)
+	}
+	return writer, nil
+}
+
+// Write 写出一行
+func (w *Writer) Write(row Row) error {
+	var err error
+	if w.csv != nil {
+		err = w.csv.Write(row.Record())
+	} else {
+		err = w.json.Encode(row)
+	}
+	if err != nil {
+		return err
+	}
+	if w.rows++; w.rows%flushRows == 0 {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush 将缓冲的内容写出
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return nil
+}
+
+// Text 自由文本单元格：以 = + - @ 开头的内容在表格软件中会被当作公式执行，前面加单引号按文本显示。
+// 逗号、引号与换行由 CSV 编码转义
+func Text(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// Time 时间单元格，UTC 的 RFC3339，为空时为空字符串
+func Time(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,189 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// logErrors 取自步骤日志的错误信息：逗号、引号、换行与以公式字符开头的内容
+var logErrors = []struct {
+	name     string
+	errorMsg string
+	want     string // 解析 CSV 后得到的单元格
+}{
+	{"逗号", "exit status 1, retrying", "exit status 1, retrying"},
+	{"引号", `unexpected token "}" in config`, `unexpected token "}" in config`},
+	{"换行", "step failed:\nnpm ERR! code 1\r\nnpm ERR! path /srv", "step failed:\nnpm ERR! code 1\nnpm ERR! path /srv"},
+	{"公式", `=HYPERLINK("http://evil.example","点击")`, `'=HYPERLINK("http://evil.example","点击")`},
+	{"加号", "+cmd|' /C calc'!A0", "'+cmd|' /C calc'!A0"},
+	{"减号", "-2+3", "'-2+3"},
+	{"at", "@SUM(1,2)", "'@SUM(1,2)"},
+	{"制表符", "\t=1+2", "'\t=1+2"},
+	{"中间的公式字符", "a=1, b=-2", "a=1, b=-2"},
+}
+
+// TestCSVEscaping 日志中的逗号、引号与换行写出后解析得到原内容，每条记录仍是一行 CSV；以公式字符开头的内容加单引号前缀
+func TestCSVEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, RunColumns, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	for i, tt := range logErrors {
+		row := &RunRow{ID: uint(i + 1), PipelineID: 7, RunNumber: i + 1, Status: "failed", TriggerType: "manual",
+			Branch: "feature/a,b", CreatedAt: createdAt, ErrorMsg: tt.errorMsg}
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"unexpected token ""}"" in config"`) {
+		t.Errorf("引号应加倍并以引号包围单元格:\n%s", buf.String())
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(logErrors)+1 || !reflect.DeepEqual(records[0], RunColumns) {
+		t.Fatalf("解析得到 %d 行，表头为 %v，应为 %d 行且表头为 %v", len(records), records[0], len(logErrors)+1, RunColumns)
+	}
+	for i, tt := range logErrors {
+		t.Run(tt.name, func(t *testing.T) {
+			record := records[i+1]
+			if len(record) != len(RunColumns) {
+				t.Fatalf("记录有 %d 列，应为 %d 列: %q", len(record), len(RunColumns), record)
+			}
+			if got := record[len(record)-1]; got != tt.want {
+				t.Errorf("error_msg 为 %q，应为 %q", got, tt.want)
+			}
+			if record[5] != "feature/a,b" || record[7] != "2026-01-01T19:04:05Z" || record[8] != "" {
+				t.Errorf("branch、created_at、start_time 为 %q、%q、%q", record[5], record[7], record[8])
+			}
+		})
+	}
+}
+
+// TestNDJSON 每条记录一行 JSON，字段名与 CSV 表头相同，内容中的换行与 HTML 字符原样保留在字符串中
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatNDJSON, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range logErrors {
+		if err := w.Write(&DeploymentRow{ID: uint(i + 1), Version: "<v1>", ErrorMsg: tt.errorMsg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	lines := 0
+	for ; scanner.Scan(); lines++ {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("第 %d 行不是 JSON: %v", lines+1, err)
+		}
+		if len(row) != len(DeploymentColumns) || row["error_msg"] != logErrors[lines].errorMsg || row["version"] != "<v1>" {
+			t.Errorf("第 %d 行为 %v", lines+1, row)
+		}
+		if strings.Contains(scanner.Text(), `\u003c`) {
+			t.Errorf("第 %d 行转义了 HTML 字符: %s", lines+1, scanner.Text())
+		}
+	}
+	if lines != len(logErrors) {
+		t.Errorf("写出 %d 行，应为 %d 行", lines, len(logErrors))
+	}
+}
+
+// countingFlusher 记录刷新次数
+type countingFlusher struct{ flushes int }
+
+func (f *countingFlusher) Flush() { f.flushes++ }
+
+// TestWriterFlushes 每写出 flushRows 行发送一次已写出的内容，缓冲的行数不随导出的总行数增长
+func TestWriterFlushes(t *testing.T) {
+	var buf bytes.Buffer
+	flusher := &countingFlusher{}
+	w, err := NewWriter(&buf, FormatCSV, AuditColumns, flusher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*flushRows+1; i++ {
+		if err := w.Write(&AuditRow{ID: uint(i + 1), Description: "更新流水线"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if flusher.flushes != 2 || strings.Count(buf.String(), "\n") != 2*flushRows+1 {
+		t.Errorf("写出 %d 行后刷新 %d 次、已发送 %d 行，应刷新 2 次并发送表头与 %d 行",
+			2*flushRows+1, flusher.flushes, strings.Count(buf.String(), "\n"), 2*flushRows)
+	}
+}
+
+// TestNegotiate format 参数优先于 Accept，Accept 取第一个支持的类型；浏览器默认的 Accept 与未知的 format 返回普通列表
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name, format, accept, want string
+	}{
+		{"format csv", "CSV", "", FormatCSV},
+		{"format jsonl", "jsonl", "text/csv", FormatNDJSON},
+		{"未知的 format", "xlsx", "text/csv", ""},
+		{"Accept csv", "", "text/csv; charset=utf-8", FormatCSV},
+		{"Accept 按顺序", "", "application/x-ndjson, text/csv", FormatNDJSON},
+		{"浏览器默认", "", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", ""},
+		{"JSON", "", "application/json", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.format, tt.accept); got != tt.want {
+				t.Errorf("Negotiate(%q, %q) = %q，应为 %q", tt.format, tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilename 文件名包含资源名与日期范围，资源名中的特殊字符替换为连字符
+func TestFilename(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local)
+	if got := Filename("runs-api/deploy", &from, &to, FormatCSV); got != "runs-api-deploy-2026-01-01_2026-01-31.csv" {
+		t.Errorf("文件名为 %q", got)
+	}
+	if got := Filename("audit logs", nil, nil, FormatNDJSON); got != "audit-logs-all.ndjson" {
+		t.Errorf("未限定日期的文件名为 %q", got)
+	}
+	if got := Filename("deployments", &from, nil, FormatCSV); got != "deployments-2026-01-01_"+time.Now().Format("2006-01-02")+".csv" {
+		t.Errorf("只限定开始日期的文件名为 %q", got)
+	}
+}
+
+// TestCheckRows 超过配置的最大行数时返回 ErrTooManyRows，最大行数为 0 时不限制
+func TestCheckRows(t *testing.T) {
+	t.Cleanup(func() { Init(&config.ExportConfig{MaxRows: 100000}) })
+	Init(&config.ExportConfig{MaxRows: 10})
+	if err := CheckRows(10); err != nil {
+		t.Errorf("10 行返回 %v", err)
+	}
+	if err := CheckRows(11); !errors.Is(err, ErrTooManyRows) || !strings.Contains(err.Error(), "共 11 条，最多导出 10 条") {
+		t.Errorf("11 行返回 %v，应为 ErrTooManyRows", err)
+	}
+	Init(&config.ExportConfig{})
+	if err := CheckRows(1 << 40); err != nil {
+		t.Errorf("不限制时返回 %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"strconv"
+	"time"
+)
+
+// 各列表导出的列，CSV 的表头与 NDJSON 的字段名相同。列只增不改，已有列的含义与顺序保持不变；
+// 日志正文、配置快照等大字段与密钥不导出
+
+// RunColumns 流水线运行的导出列
+var RunColumns = []string{"id", "pipeline_id", "run_number", "status", "trigger_type", "branch", "commit_sha",
+	"created_at", "start_time", "end_time", "duration_ms", "failure_kind", "cancellation_reason", "error_msg"}
+
+// RunRow 流水线运行的一行，ErrorMsg 导出前按项目脱敏
+type RunRow struct {
+	ID                 uint       `json:"id"`
+	PipelineID         uint       `json:"pipeline_id"`
+	RunNumber          int        `json:"run_number"`
+	Status             string     `json:"status"`
+	TriggerType        string     `json:"trigger_type"`
+	Branch             string     `json:"branch"`
+	CommitSHA          string     `json:"commit_sha" gorm:"column:commit_sha"`
+	CreatedAt          time.Time  `json:"created_at"`
+	StartTime          *time.Time `json:"start_time"`
+	EndTime            *time.Time `json:"end_time"`
+	DurationMs         int64      `json:"duration_ms"`
+	FailureKind        string     `json:"failure_kind"`
+	CancellationReason string     `json:"cancellation_reason"`
+	ErrorMsg           string     `json:"error_msg"`
+}
+
+// Record CSV 中的一行
+func (r *RunRow) Record() []string {
+	return []string{formatID(r.ID), formatID(r.PipelineID), strconv.Itoa(r.RunNumber), r.Status, r.TriggerType,
+		Text(r.Branch), r.CommitSHA, Time(&r.CreatedAt), Time(r.StartTime), Time(r.EndTime),
+		strconv.FormatInt(r.DurationMs, 10), r.FailureKind, r.CancellationReason, Text(r.ErrorMsg)}
+}
+
+// DeploymentColumns 部署记录的导出列
+var DeploymentColumns = []string{"id", "project_id", "environment", "version", "commit_hash", "status", "user_id",
+	"created_at", "start_time", "end_time", "duration_ms", "error_msg"}
+
+// DeploymentRow 部署记录的一行，ErrorMsg 导出前按项目脱敏
+type DeploymentRow struct {
+	ID          uint       `json:"id"`
+	ProjectID   uint       `json:"project_id"`
+	Environment string     `json:"environment"`
+	Version     string     `json:"version"`
+	CommitHash  string     `json:"commit_hash"`
+	Status      string     `json:"status"`
+	UserID      uint       `json:"user_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartTime   *time.Time `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+	DurationMs  int64      `json:"duration_ms"`
+	ErrorMsg    string     `json:"error_msg"`
+}
+
+// Record CSV 中的一行
+func (r *DeploymentRow) Record() []string {
+	return []string{formatID(r.ID), formatID(r.ProjectID), Text(r.Environment), Text(r.Version), r.CommitHash, r.Status,
+		formatID(r.UserID), Time(&r.CreatedAt), Time(r.StartTime), Time(r.EndTime),
+		strconv.FormatInt(r.DurationMs, 10), Text(r.ErrorMsg)}
+}
+
+// ReleaseColumns 发布记录的导出列，deployed_by 为部署人的用户名
+var ReleaseColumns = []string{"id", "project_id", "environment", "version", "commit_hash", "previous_commit_hash",
+	"commit_count", "range_unknown", "deployment_id", "pipeline_run_id", "deployed_by", "created_at", "duration_ms", "provider_url"}
+
+// ReleaseRow 发布记录的一行
+type ReleaseRow struct {
+	ID                 uint      `json:"id"`
+	ProjectID          uint      `json:"project_id"`
+	Environment        string    `json:"environment"`
+	Version            string    `json:"version"`
+	CommitHash         string    `json:"commit_hash"`
+	PreviousCommitHash string    `json:"previous_commit_hash"`
+	CommitCount        int       `json:"commit_count"`
+	RangeUnknown       bool      `json:"range_unknown"`
+	DeploymentID       uint      `json:"deployment_id"`
+	PipelineRunID      uint      `json:"pipeline_run_id"`
+	DeployedBy         string    `json:"deployed_by"`
+	CreatedAt          time.Time `json:"created_at"`
+	DurationMs         int64     `json:"duration_ms"`
+	ProviderURL        string    `json:"provider_url" gorm:"column:provider_url"`
+}
+
+// Record CSV 中的一行
+func (r *ReleaseRow) Record() []string {
+	return []string{formatID(r.ID), formatID(r.ProjectID), Text(r.Environment), Text(r.Version), r.CommitHash,
+		r.PreviousCommitHash, strconv.Itoa(r.CommitCount), strconv.FormatBool(r.RangeUnknown),
+		formatID(r.DeploymentID), formatID(r.PipelineRunID), Text(r.DeployedBy), Time(&r.CreatedAt),
+		strconv.FormatInt(r.DurationMs, 10), Text(r.ProviderURL)}
+}
+
+// AuditColumns 审计日志的导出列，不含变更前后的内容
+var AuditColumns = []string{"id", "created_at", "user_id", "action", "resource_type", "resource_id", "description", "ip", "user_agent"}
+
+// AuditRow 审计日志的一行
+type AuditRow struct {
+	ID           uint      `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       *uint     `json:"user_id"`
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   uint      `json:"resource_id"`
+	Description  string    `json:"description"`
+	IP           string    `json:"ip" gorm:"column:ip"`
+	UserAgent    string    `json:"user_agent"`
+}
+
+// Record CSV 中的一行
+func (r *AuditRow) Record() []string {
+	user := ""
+	if r.UserID != nil {
+		user = formatID(*r.UserID)
+	}
+	return []string{formatID(r.ID), Time(&r.CreatedAt), user, r.Action, r.ResourceType, formatID(r.ResourceID),
+		Text(r.Description), r.IP, Text(r.UserAgent)}
+}
+
+// formatID ID 的十进制形式
+func formatID(value uint) string {
+	return strconv.FormatUint(uint64(value), 10)
+}
@@ -0,0 +1,79 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+
+	"flowforge/pkg/utils"
+)
+
+// Namespace Atom 1.0 命名空间（RFC 4287）
+const Namespace = "http://www.w3.org/2005/Atom"
+
+// ContentType Atom 订阅的响应类型
+const ContentType = "application/atom+xml; charset=utf-8"
+
+// Feed Atom 订阅
+type Feed struct {
+	XMLName   xml.Name `xml:"feed"`
+	Namespace string   `xml:"xmlns,attr"`
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Author    Person   `xml:"author"`
+	Links     []Link   `xml:"link"`
+	Entries   []Entry  `xml:"entry"`
+}
+
+// Person 作者
+type Person struct {
+	Name string `xml:"name"`
+}
+
+// Link 链接
+type Link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// Category 条目分类
+type Category struct {
+	Term string `xml:"term,attr"`
+}
+
+// Entry 订阅条目
+type Entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    string     `xml:"updated"`
+	Published  string     `xml:"published,omitempty"`
+	Links      []Link     `xml:"link"`
+	Summary    string     `xml:"summary,omitempty"`
+	Categories []Category `xml:"category,omitempty"`
+}
+
+// New 创建订阅，updated 为订阅的更新时间（通常为最新条目的更新时间），self 为订阅自身的地址，alternate 为对应的页面
+func New(id, title, author, self, alternate string, updated time.Time, entries []Entry) *Feed {
+	return &Feed{
+		Namespace: Namespace,
+		ID:        id,
+		Title:     title,
+		Updated:   utils.FormatTime(updated),
+		Author:    Person{Name: author},
+		Links: []Link{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: alternate, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: entries,
+	}
+}
+
+// Marshal 序列化为带 XML 声明的 Atom 文档
+func (f *Feed) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
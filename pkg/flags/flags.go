@@ -0,0 +1,273 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"flowforge/pkg/cache"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// 代码中使用的功能开关
+const (
+	BatchedLogWrites   = "batched_log_writes"   // 运行日志缓冲后批量写入，关闭时每行立即写入
+	PreflightCache     = "preflight_cache"      // 部署前检查结果在缓存时间内复用
+	WebhookPathFilters = "webhook_path_filters" // Webhook 推送事件按路径过滤
+)
+
+// ErrUnknownFlag 开关未在代码中定义
+var ErrUnknownFlag = errors.New("未定义的功能开关")
+
+// Flag 代码中定义的功能开关及其默认值
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+// definitions 所有功能开关，新增开关时在此登记
+var definitions = []Flag{
+	{Name: BatchedLogWrites, Description: "运行日志缓冲后批量写入数据库", Default: true},
+	{Name: PreflightCache, Description: "复用缓存时间内的部署前检查结果", Default: true},
+	{Name: WebhookPathFilters, Description: "按 Webhook 配置的路径过滤推送事件", Default: true},
+}
+
+// overrides 从数据库加载的覆盖值，加载后只读
+type overrides struct {
+	global   map[string]bool
+	projects map[string]map[uint]bool
+	users    map[string]map[uint]bool
+}
+
+// 覆盖值按缓存时间缓存：本实例修改立即失效，其他实例的修改最迟在缓存时间后生效
+var cached = cache.New("feature_flags", loadOverrides)
+
+// lastLoaded 上次成功加载的覆盖值，加载失败时继续使用
+var (
+	lastMu     sync.Mutex
+	lastLoaded = &overrides{}
+)
+
+// Definitions 所有功能开关的定义
+func Definitions() []Flag {
+	return append([]Flag(nil), definitions...)
+}
+
+// Lookup 按名称查找功能开关的定义
+func Lookup(name string) (Flag, bool) {
+	for _, flag := range definitions {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Enabled 功能开关对项目是否开启：项目覆盖优先于全局覆盖，都没有时使用默认值；
+// projectID 为 0 时只看全局覆盖与默认值。未定义的开关总是关闭
+func Enabled(ctx context.Context, name string, projectID uint) bool {
+	return EnabledFor(ctx, name, projectID, 0)
+}
+
+// EnabledFor 功能开关对用户在项目中的操作是否开启：用户覆盖优先于项目覆盖，其次是全局覆盖与默认值；
+// userID 为 0 时与 Enabled 相同
+func EnabledFor(ctx context.Context, name string, projectID, userID uint) bool {
+	flag, ok := Lookup(name)
+	if !ok {
+		return false
+	}
+
+	current := currentOverrides(ctx)
+	if userID != 0 {
+		if enabled, ok := current.users[name][userID]; ok {
+			return enabled
+		}
+	}
+	if projectID != 0 {
+		if enabled, ok := current.projects[name][projectID]; ok {
+			return enabled
+		}
+	}
+	if enabled, ok := current.global[name]; ok {
+		return enabled
+	}
+	return flag.Default
+}
+
+// Set 设置全局（projectID 为 0）或项目的覆盖值，enabled 为 nil 时删除覆盖值；修改后立即使缓存失效
+func Set(name string, projectID uint, enabled *bool, updatedBy *uint) error {
+	return set(name, projectID, 0, enabled, updatedBy)
+}
+
+// SetUser 设置用户的覆盖值，enabled 为 nil 时删除覆盖值；修改后立即使缓存失效
+func SetUser(name string, userID uint, enabled *bool, updatedBy *uint) error {
+	return set(name, 0, userID, enabled, updatedBy)
+}
+
+func set(name string, projectID, userID uint, enabled *bool, updatedBy *uint) error {
+	if _, ok := Lookup(name); !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+
+	var err error
+	if enabled == nil {
+		err = database.DB.Where("name = ? AND project_id = ? AND user_id = ?", name, projectID, userID).Delete(&models.FeatureFlag{}).Error
+	} else {
+		err = database.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}, {Name: "project_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_by_id", "updated_at"}),
+		}).Create(&models.FeatureFlag{
+			Name:        name,
+			ProjectID:   projectID,
+			UserID:      userID,
+			Enabled:     *enabled,
+			UpdatedByID: updatedBy,
+		}).Error
+	}
+	Invalidate()
+	if err != nil {
+		return fmt.Errorf("保存功能开关失败: %w", err)
+	}
+	return nil
+}
+
+// Invalidate 使缓存失效，下一次查询时从数据库重新加载
+func Invalidate() {
+	cached.InvalidateAll()
+}
+
+// currentOverrides 当前生效的覆盖值；调用方的请求已取消等加载失败时使用上次加载的值
+func currentOverrides(ctx context.Context) *overrides {
+	if database.DB == nil {
+		return &overrides{}
+	}
+	current, err := cached.Get(ctx, struct{}{})
+	if err != nil {
+		lastMu.Lock()
+		defer lastMu.Unlock()
+		return lastLoaded
+	}
+	return current
+}
+
+// loadOverrides 从数据库加载覆盖值。数据库错误时缓存上次加载的值，在下一个缓存周期重试；
+// 调用方的请求已取消时返回错误，不缓存，由下一次查询重试
+func loadOverrides(ctx context.Context, _ struct{}) (*overrides, error) {
+	var rows []models.FeatureFlag
+	if err := database.DB.WithContext(ctx).Find(&rows).Error; err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("加载功能开关失败，继续使用缓存的值: %v", err)
+		lastMu.Lock()
+		defer lastMu.Unlock()
+		return lastLoaded, nil
+	}
+
+	loaded := &overrides{
+		global:   make(map[string]bool),
+		projects: make(map[string]map[uint]bool),
+		users:    make(map[string]map[uint]bool),
+	}
+	for _, row := range rows {
+		switch {
+		case row.UserID != 0:
+			setScoped(loaded.users, row.Name, row.UserID, row.Enabled)
+		case row.ProjectID != 0:
+			setScoped(loaded.projects, row.Name, row.ProjectID, row.Enabled)
+		default:
+			loaded.global[row.Name] = row.Enabled
+		}
+	}
+
+	lastMu.Lock()
+	lastLoaded = loaded
+	lastMu.Unlock()
+	return loaded, nil
+}
+
+// setScoped 记录项目或用户的覆盖值
+func setScoped(scoped map[string]map[uint]bool, name string, id uint, enabled bool) {
+	if scoped[name] == nil {
+		scoped[name] = make(map[uint]bool)
+	}
+	scoped[name][id] = enabled
+}
+
+// Status 功能开关的当前取值与推广情况
+type Status struct {
+	Flag
+	Global           *bool         `json:"global"`            // 全局覆盖值，未覆盖为 null
+	Projects         map[uint]bool `json:"projects"`          // 各项目的覆盖值
+	Users            map[uint]bool `json:"users"`             // 各用户的覆盖值，不计入项目推广情况
+	EnabledProjects  int64         `json:"enabled_projects"`  // 开启该开关的项目数
+	TotalProjects    int64         `json:"total_projects"`    // 未删除的项目数
+	RolloutCoverage  float64       `json:"rollout_coverage"`  // 开启的项目占比
+	EffectiveDefault bool          `json:"effective_default"` // 没有项目覆盖的项目使用的取值
+}
+
+// Statuses 所有功能开关的取值与推广情况，直接读取数据库，不使用缓存
+func Statuses(ctx context.Context) ([]Status, error) {
+	var rows []models.FeatureFlag
+	if err := database.DB.WithContext(ctx).Order("name, project_id, user_id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询功能开关失败: %w", err)
+	}
+
+	var projectIDs []uint
+	if err := database.DB.WithContext(ctx).Model(&models.Project{}).Pluck("id", &projectIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+	existing := make(map[uint]bool, len(projectIDs))
+	for _, id := range projectIDs {
+		existing[id] = true
+	}
+
+	statuses := make([]Status, 0, len(definitions))
+	for _, flag := range definitions {
+		status := Status{Flag: flag, Projects: make(map[uint]bool), Users: make(map[uint]bool), TotalProjects: int64(len(projectIDs))}
+		for _, row := range rows {
+			if row.Name != flag.Name {
+				continue
+			}
+			switch {
+			case row.UserID != 0:
+				status.Users[row.UserID] = row.Enabled
+			case row.ProjectID == 0:
+				enabled := row.Enabled
+				status.Global = &enabled
+			case existing[row.ProjectID]:
+				status.Projects[row.ProjectID] = row.Enabled
+			}
+		}
+
+		status.EffectiveDefault = flag.Default
+		if status.Global != nil {
+			status.EffectiveDefault = *status.Global
+		}
+		overridden, enabledOverrides := int64(0), int64(0)
+		for _, enabled := range status.Projects {
+			overridden++
+			if enabled {
+				enabledOverrides++
+			}
+		}
+		status.EnabledProjects = enabledOverrides
+		if status.EffectiveDefault {
+			status.EnabledProjects += status.TotalProjects - overridden
+		}
+		if status.TotalProjects > 0 {
+			status.RolloutCoverage = float64(status.EnabledProjects) / float64(status.TotalProjects)
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
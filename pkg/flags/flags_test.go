@@ -0,0 +1,168 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"flowforge/pkg/cache"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// setupTestDB 以测试名命名的内存数据库，缓存时间为 ttl 秒
+func setupTestDB(t *testing.T, ttl int) {
+	t.Helper()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	cache.Init(&config.CacheConfig{TTL: ttl})
+	t.Cleanup(func() { cache.Init(&config.CacheConfig{TTL: 30}) })
+}
+
+func boolPtr(v bool) *bool { return &v }
+
+// TestEnabledPrecedence 用户覆盖优先于项目覆盖，项目覆盖优先于全局覆盖，都没有时使用默认值
+func TestEnabledPrecedence(t *testing.T) {
+	setupTestDB(t, 30)
+	const projectID, userID = 7, 3
+	ctx := context.Background()
+
+	set := func(project, user uint, enabled *bool) {
+		t.Helper()
+		var err error
+		if user != 0 {
+			err = SetUser(BatchedLogWrites, user, enabled, nil)
+		} else {
+			err = Set(BatchedLogWrites, project, enabled, nil)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		apply   func()
+		user    bool // 用户在项目中的取值
+		project bool // 项目中其他用户的取值
+		global  bool // 其他项目的取值
+	}{
+		{"默认值", func() {}, true, true, true},
+		{"全局覆盖默认值", func() { set(0, 0, boolPtr(false)) }, false, false, false},
+		{"项目覆盖全局", func() { set(projectID, 0, boolPtr(true)) }, true, true, false},
+		{"用户覆盖项目", func() { set(0, userID, boolPtr(false)) }, false, true, false},
+		{"全局开启后用户覆盖仍生效", func() { set(0, 0, boolPtr(true)) }, false, true, true},
+		{"删除项目覆盖后用户覆盖仍生效", func() { set(projectID, 0, nil) }, false, true, true},
+		{"删除用户覆盖后恢复全局", func() { set(0, userID, nil) }, true, true, true},
+		{"全局关闭", func() { set(0, 0, boolPtr(false)) }, false, false, false},
+		{"删除全局覆盖后恢复默认值", func() { set(0, 0, nil) }, true, true, true},
+	} {
+		tc.apply()
+		if got := EnabledFor(ctx, BatchedLogWrites, projectID, userID); got != tc.user {
+			t.Errorf("%s: 用户在项目中为 %t，应为 %t", tc.name, got, tc.user)
+		}
+		if got := Enabled(ctx, BatchedLogWrites, projectID); got != tc.project {
+			t.Errorf("%s: 项目中为 %t，应为 %t", tc.name, got, tc.project)
+		}
+		if got := Enabled(ctx, BatchedLogWrites, projectID+1); got != tc.global {
+			t.Errorf("%s: 其他项目中为 %t，应为 %t", tc.name, got, tc.global)
+		}
+	}
+
+	if Enabled(ctx, "no_such_flag", projectID) {
+		t.Error("未定义的开关应总是关闭")
+	}
+	if err := Set("no_such_flag", 0, boolPtr(true), nil); err == nil {
+		t.Error("设置未定义的开关应返回错误")
+	}
+}
+
+// TestCacheInvalidation 本实例的修改立即生效；其他实例直接写入数据库的修改在缓存时间内不可见，最迟在缓存时间后生效
+func TestCacheInvalidation(t *testing.T) {
+	const ttl = time.Second
+	setupTestDB(t, int(ttl/time.Second))
+	ctx := context.Background()
+
+	if !Enabled(ctx, PreflightCache, 1) {
+		t.Fatal("默认应开启")
+	}
+	if err := Set(PreflightCache, 1, boolPtr(false), nil); err != nil {
+		t.Fatal(err)
+	}
+	if Enabled(ctx, PreflightCache, 1) {
+		t.Fatal("本实例的修改应立即生效")
+	}
+
+	// 模拟其他实例的修改：直接写入数据库，不经过 Set 使缓存失效
+	written := time.Now()
+	if err := database.DB.Model(&models.FeatureFlag{}).Where("name = ? AND project_id = ?", PreflightCache, 1).
+		Update("enabled", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	if Enabled(ctx, PreflightCache, 1) {
+		t.Fatal("缓存时间内应继续使用缓存的值")
+	}
+	for !Enabled(ctx, PreflightCache, 1) {
+		if time.Since(written) > ttl+500*time.Millisecond {
+			t.Fatalf("其他实例的修改超过缓存时间 %v 仍未生效", ttl)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// 显式失效后立即重新加载
+	if err := database.DB.Model(&models.FeatureFlag{}).Where("name = ?", PreflightCache).Update("enabled", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	Invalidate()
+	if Enabled(ctx, PreflightCache, 1) {
+		t.Error("失效缓存后应立即读取数据库中的值")
+	}
+}
+
+// TestStatusesUserOverrides 用户覆盖单独列出，不计入项目推广情况
+func TestStatusesUserOverrides(t *testing.T) {
+	setupTestDB(t, 30)
+	for _, name := range []string{"web", "api"} {
+		if err := database.DB.Create(&models.Project{Name: name, Slug: name, UserID: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetUser(WebhookPathFilters, 3, boolPtr(false), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := Statuses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Name != WebhookPathFilters {
+			continue
+		}
+		if status.Global != nil || len(status.Projects) != 0 || !status.EffectiveDefault {
+			t.Errorf("用户覆盖不应被当作全局或项目覆盖: %+v", status)
+		}
+		if enabled, ok := status.Users[3]; !ok || enabled {
+			t.Errorf("应列出用户 3 的覆盖值 false，实际为 %v", status.Users)
+		}
+		if status.EnabledProjects != 2 || status.RolloutCoverage != 1 {
+			t.Errorf("推广情况应为 2 个项目全部开启，实际为 %d（%.2f）", status.EnabledProjects, status.RolloutCoverage)
+		}
+		return
+	}
+	t.Fatalf("缺少开关 %s", WebhookPathFilters)
+}
@@ -0,0 +1,102 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"flowforge/pkg/models"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// RemoteBranches 远程仓库的分支信息
+type RemoteBranches struct {
+	Default  string   `json:"default"`
+	Branches []string `json:"branches"`
+
+	heads map[string]string // 各分支指向的提交
+}
+
+// Has 远程仓库是否存在指定分支
+func (b *RemoteBranches) Has(branch string) bool {
+	for _, name := range b.Branches {
+		if name == branch {
+			return true
+		}
+	}
+	return false
+}
+
+// Head 远程分支指向的提交，分支不存在时返回空
+func (b *RemoteBranches) Head(branch string) string {
+	return b.heads[branch]
+}
+
+// ListRemoteBranches 查询远程仓库的分支列表与默认分支（等价于 git ls-remote --symref），使用项目配置的认证
+func (c *Client) ListRemoteBranches(ctx context.Context, project *models.Project, sshKey *models.SSHKey) (*RemoteBranches, error) {
+	auth, err := c.getAuth(project, sshKey)
+	if err != nil {
+		return nil, fmt.Errorf("设置认证失败: %w", err)
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{project.RepoURL},
+	})
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	refs, err := remote.ListContext(timeoutCtx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, fmt.Errorf("查询远程分支失败: %w", err)
+	}
+
+	result := &RemoteBranches{heads: make(map[string]string)}
+	var head *plumbing.Reference
+	hashes := make(map[string]plumbing.Hash)
+	for _, ref := range refs {
+		switch {
+		case ref.Name() == plumbing.HEAD:
+			head = ref
+		case ref.Name().IsBranch():
+			result.Branches = append(result.Branches, ref.Name().Short())
+			hashes[ref.Name().Short()] = ref.Hash()
+			result.heads[ref.Name().Short()] = ref.Hash().String()
+		}
+	}
+	sort.Strings(result.Branches)
+
+	if head != nil {
+		if head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+			result.Default = head.Target().Short()
+		} else {
+			// 服务端未通告 symref 时，按 HEAD 指向的提交匹配分支
+			for _, name := range result.Branches {
+				if hashes[name] == head.Hash() {
+					result.Default = name
+					break
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// DetectDefaultBranch 检测远程仓库的默认分支
+func (c *Client) DetectDefaultBranch(ctx context.Context, project *models.Project, sshKey *models.SSHKey) (string, error) {
+	branches, err := c.ListRemoteBranches(ctx, project, sshKey)
+	if err != nil {
+		return "", err
+	}
+	if branches.Default == "" {
+		return "", fmt.Errorf("远程仓库未返回默认分支")
+	}
+	return branches.Default, nil
+}
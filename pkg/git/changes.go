@@ -0,0 +1,46 @@
+package git
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ChangedFiles 工作区仓库中 from 与 to 两个提交之间变更的文件（重命名时包含前后两个路径），按两个提交的文件树比较，
+// 不要求 from 在 to 的历史中。任一提交不在工作区中时返回 ErrUnknownRange
+func (c *Client) ChangedFiles(repoDir, from, to string) ([]string, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, fmt.Errorf("打开代码库失败: %w", err)
+	}
+
+	var trees [2]*object.Tree
+	for i, hash := range []string{from, to} {
+		commit, err := repo.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			return nil, ErrUnknownRange
+		}
+		if trees[i], err = commit.Tree(); err != nil {
+			return nil, fmt.Errorf("读取提交 %s 的文件树失败: %w", hash, err)
+		}
+	}
+
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return nil, fmt.Errorf("比较提交失败: %w", err)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				files = append(files, name)
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,95 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"flowforge/pkg/models"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxRepoFileSize 从仓库读取的单个文件大小上限
+const maxRepoFileSize = 1 << 20
+
+// ReadFileAt 读取工作区仓库中指定提交下的文件内容，commit 为空时读取 HEAD；
+// 浅克隆中不存在该提交时按提交哈希单独拉取。返回实际读取的提交哈希
+func (c *Client) ReadFileAt(ctx context.Context, project *models.Project, sshKey *models.SSHKey, repoDir, commit, filePath string) ([]byte, string, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("打开代码库失败: %w", err)
+	}
+
+	commitObj, err := c.resolveCommit(ctx, repo, project, sshKey, commit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tree, err := commitObj.Tree()
+	if err != nil {
+		return nil, "", fmt.Errorf("读取提交目录树失败: %w", err)
+	}
+
+	file, err := tree.File(path.Clean(filePath))
+	if err != nil {
+		return nil, "", fmt.Errorf("提交 %s 中不存在文件 %s", commitObj.Hash.String()[:8], filePath)
+	}
+	if file.Size > maxRepoFileSize {
+		return nil, "", fmt.Errorf("文件 %s 超过大小限制（%d 字节）", filePath, maxRepoFileSize)
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	return content, commitObj.Hash.String(), nil
+}
+
+// resolveCommit 解析提交对象，本地不存在时尝试从远程拉取该提交
+func (c *Client) resolveCommit(ctx context.Context, repo *git.Repository, project *models.Project, sshKey *models.SSHKey, commit string) (*object.Commit, error) {
+	if commit == "" {
+		ref, err := repo.Head()
+		if err != nil {
+			return nil, fmt.Errorf("获取HEAD引用失败: %w", err)
+		}
+		return repo.CommitObject(ref.Hash())
+	}
+
+	hash := plumbing.NewHash(commit)
+	if commitObj, err := repo.CommitObject(hash); err == nil {
+		return commitObj, nil
+	}
+
+	auth, err := c.getAuth(project, sshKey)
+	if err != nil {
+		return nil, fmt.Errorf("设置认证失败: %w", err)
+	}
+
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(commit + ":refs/flowforge/config")},
+		Depth:      1,
+		Auth:       auth,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("拉取提交 %s 失败: %w", commit, err)
+	}
+
+	commitObj, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("提交 %s 不存在: %w", commit, err)
+	}
+	return commitObj, nil
+}
@@ -8,13 +8,14 @@ import (
 	"time"
 
 	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/isolation"
 	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // Client Git客户端
@@ -29,6 +30,30 @@ func NewClient(cfg *config.Config) *Client {
 	}
 }
 
+// Manager Git管理器
+type Manager struct {
+	client *Client
+	config *config.Config
+}
+
+// NewManager 创建Git管理器
+func NewManager(cfg *config.Config) *Manager {
+	return &Manager{
+		client: NewClient(cfg),
+		config: cfg,
+	}
+}
+
+// GetClient 获取Git客户端
+func (m *Manager) GetClient() *Client {
+	return m.client
+}
+
+// WorkspaceDir 项目的共享代码工作区，与流水线引擎克隆代码的目录一致
+func (m *Manager) WorkspaceDir(projectID uint) string {
+	return isolation.WorkspaceDir(m.config.App.DataPath, projectID)
+}
+
 // CloneOptions 克隆选项
 type CloneOptions struct {
 	Project   *models.Project
@@ -127,6 +152,14 @@ func (c *Client) Pull(ctx context.Context, opts PullOptions) error {
 	return nil
 }
 
+// CloneOrPull 工作区已有代码库时拉取最新代码，否则克隆；拉取与克隆都随 ctx 取消
+func (m *Manager) CloneOrPull(ctx context.Context, project *models.Project, workDir string) error {
+	if utils.IsDirExists(filepath.Join(workDir, ".git")) {
+		return m.client.Pull(ctx, PullOptions{Project: project, SSHKey: project.SSHKey, RepoDir: workDir})
+	}
+	return m.client.Clone(ctx, CloneOptions{Project: project, SSHKey: project.SSHKey, TargetDir: workDir})
+}
+
 // GetCommitInfo 获取提交信息
 func (c *Client) GetCommitInfo(repoDir string) (string, string, error) {
 	// 打开仓库
@@ -165,24 +198,70 @@ func (c *Client) GetCommitInfo(repoDir string) (string, string, error) {
 
 // getAuth 获取认证信息
 func (c *Client) getAuth(project *models.Project, sshKey *models.SSHKey) (transport.AuthMethod, error) {
-	// 如果使用SSH密钥
-	if project.SSHKeyID != nil && sshKey != nil {
-		// 创建临时SSH密钥文件
-		keyFile := filepath.Join(c.config.SSH.KeysPath, fmt.Sprintf("key_%d", *project.SSHKeyID))
-		if err := os.WriteFile(keyFile, []byte(sshKey.PrivateKey), 0600); err != nil {
-			return nil, fmt.Errorf("写入SSH密钥文件失败: %w", err)
+	key := SelectKey(project, sshKey)
+	if key == nil {
+		// 无认证
+		return nil, nil
+	}
+
+	// 项目部署密钥
+	if key.IsDeployKey() {
+		privateKey := key.PrivateKey
+		if key.Encrypted {
+			decrypted, err := utils.DecryptString(privateKey, c.config.Security.EncryptionKey)
+			if err != nil {
+				return nil, fmt.Errorf("解密部署密钥失败: %w", err)
+			}
+			privateKey = decrypted
 		}
-		defer os.Remove(keyFile) // 使用后删除
 
-		// 创建SSH认证
-		publicKeys, err := ssh.NewPublicKeysFromFile("git", keyFile, "")
+		publicKeys, err := ssh.NewPublicKeys("git", []byte(privateKey), "")
 		if err != nil {
-			return nil, fmt.Errorf("创建SSH公钥失败: %w", err)
+			return nil, fmt.Errorf("创建部署密钥认证失败: %w", err)
 		}
+		database.TouchSSHKey(key.ID)
 
 		return publicKeys, nil
 	}
 
-	// 无认证
-	return nil, nil
+	// 项目的SSH密钥：创建临时SSH密钥文件
+	keyFile := filepath.Join(c.config.SSH.KeysPath, fmt.Sprintf("key_%d", key.ID))
+	if err := os.WriteFile(keyFile, []byte(key.PrivateKey), 0600); err != nil {
+		return nil, fmt.Errorf("写入SSH密钥文件失败: %w", err)
+	}
+	defer os.Remove(keyFile) // 使用后删除
+
+	// 创建SSH认证
+	publicKeys, err := ssh.NewPublicKeysFromFile("git", keyFile, "")
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH公钥失败: %w", err)
+	}
+	database.TouchSSHKey(key.ID)
+
+	return publicKeys, nil
+}
+
+// SelectKey 项目 Git 操作使用的密钥：生效中的部署密钥优先于项目的 SSH 密钥。
+// 轮换生成的新密钥在确认前处于待确认状态，不会被选中，确认后取代旧密钥；
+// 部署密钥撤销后回退到项目的 SSH 密钥，都没有时返回 nil，按匿名访问
+func SelectKey(project *models.Project, sshKey *models.SSHKey) *models.SSHKey {
+	if deployKey := activeDeployKey(project); deployKey != nil {
+		return deployKey
+	}
+	if project.SSHKeyID != nil && sshKey != nil {
+		return sshKey
+	}
+	return nil
+}
+
+// activeDeployKey 返回项目当前生效的部署密钥
+func activeDeployKey(project *models.Project) *models.SSHKey {
+	key := project.DeployKey
+	if project.DeployKeyID == nil || key == nil {
+		return nil
+	}
+	if !key.IsDeployKey() || key.Status != models.SSHKeyStatusActive {
+		return nil
+	}
+	return key
 }
\ No newline at end of file
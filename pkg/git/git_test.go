@@ -0,0 +1,101 @@
+package git
+
+import (
+	"testing"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+	"flowforge/pkg/ssh"
+	"flowforge/pkg/utils"
+
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+const testEncryptionKey = "test-encryption-key"
+
+// testKey 生成 ed25519 密钥；部署密钥按处理器的方式加密保存私钥
+func testKey(t *testing.T, id uint, purpose, status string) *models.SSHKey {
+	t.Helper()
+	private, public, fingerprint, err := ssh.NewClient(&config.Config{}).GenerateEd25519KeyPair("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &models.SSHKey{ID: id, PublicKey: public, PrivateKey: private, Fingerprint: fingerprint, Purpose: purpose, Status: status}
+	if purpose == models.SSHKeyPurposeDeployKey {
+		if key.PrivateKey, err = utils.EncryptString(private, testEncryptionKey); err != nil {
+			t.Fatal(err)
+		}
+		key.Encrypted = true
+	}
+	return key
+}
+
+func TestSelectKey(t *testing.T) {
+	userKey := testKey(t, 1, models.SSHKeyPurposeGeneral, models.SSHKeyStatusActive)
+	active := testKey(t, 2, models.SSHKeyPurposeDeployKey, models.SSHKeyStatusActive)
+	pending := testKey(t, 3, models.SSHKeyPurposeDeployKey, models.SSHKeyStatusPending)
+	revoked := testKey(t, 4, models.SSHKeyPurposeDeployKey, models.SSHKeyStatusRevoked)
+	id := func(k *models.SSHKey) *uint { return &k.ID }
+
+	tests := []struct {
+		name    string
+		project models.Project
+		sshKey  *models.SSHKey
+		want    *models.SSHKey
+	}{
+		{"部署密钥优先于SSH密钥", models.Project{SSHKeyID: id(userKey), DeployKeyID: id(active), DeployKey: active}, userKey, active},
+		{"轮换中的新密钥确认前不使用", models.Project{SSHKeyID: id(userKey), DeployKeyID: id(active), DeployKey: active, PendingDeployKeyID: id(pending)}, userKey, active},
+		{"部署密钥撤销后回退到SSH密钥", models.Project{SSHKeyID: id(userKey), DeployKeyID: id(revoked), DeployKey: revoked}, userKey, userKey},
+		{"解除关联后回退到SSH密钥", models.Project{SSHKeyID: id(userKey)}, userKey, userKey},
+		{"关联的密钥不是部署密钥", models.Project{DeployKeyID: id(userKey), DeployKey: userKey}, nil, nil},
+		{"没有密钥时匿名访问", models.Project{}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectKey(&tt.project, tt.sshKey); got != tt.want {
+				t.Errorf("选中密钥 %v，应为 %v", keyID(got), keyID(tt.want))
+			}
+		})
+	}
+}
+
+func keyID(k *models.SSHKey) interface{} {
+	if k == nil {
+		return nil
+	}
+	return k.ID
+}
+
+// TestGetAuthUsesSelectedKey 认证使用选中密钥的私钥：部署密钥解密后使用，撤销后改用SSH密钥
+func TestGetAuthUsesSelectedKey(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.EncryptionKey = testEncryptionKey
+	cfg.SSH.KeysPath = t.TempDir()
+	client := NewClient(cfg)
+
+	userKey := testKey(t, 1, models.SSHKeyPurposeGeneral, models.SSHKeyStatusActive)
+	deployKey := testKey(t, 2, models.SSHKeyPurposeDeployKey, models.SSHKeyStatusActive)
+	project := models.Project{SSHKeyID: &userKey.ID, DeployKeyID: &deployKey.ID, DeployKey: deployKey}
+
+	fingerprint := func() string {
+		t.Helper()
+		auth, err := client.getAuth(&project, userKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, ok := auth.(*gitssh.PublicKeys)
+		if !ok {
+			t.Fatalf("认证方式应为SSH公钥，实际为 %T", auth)
+		}
+		return cryptossh.FingerprintSHA256(keys.Signer.PublicKey())
+	}
+
+	if got := fingerprint(); got != deployKey.Fingerprint {
+		t.Errorf("应使用部署密钥 %s，实际为 %s", deployKey.Fingerprint, got)
+	}
+	deployKey.Status = models.SSHKeyStatusRevoked
+	if got := fingerprint(); got != userKey.Fingerprint {
+		t.Errorf("部署密钥撤销后应使用SSH密钥 %s，实际为 %s", userKey.Fingerprint, got)
+	}
+}
@@ -0,0 +1,232 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/retry"
+)
+
+// 支持的Git托管平台
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// RepoRef 仓库在托管平台上的定位信息
+type RepoRef struct {
+	Provider string
+	Host     string
+	Path     string // owner/repo
+}
+
+// ParseRepoURL 解析仓库地址，支持 git@host:owner/repo.git 和 https://host/owner/repo.git
+func ParseRepoURL(repoURL string) (*RepoRef, error) {
+	var host, path string
+
+	if strings.HasPrefix(repoURL, "git@") {
+		rest := strings.TrimPrefix(repoURL, "git@")
+		parts := strings.SplitN(rest, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("无效的仓库地址: %s", repoURL)
+		}
+		host, path = parts[0], parts[1]
+	} else {
+		u, err := url.Parse(repoURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("无效的仓库地址: %s", repoURL)
+		}
+		host, path = u.Hostname(), u.Path
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if path == "" {
+		return nil, fmt.Errorf("无效的仓库地址: %s", repoURL)
+	}
+
+	ref := &RepoRef{Host: host, Path: path}
+	switch {
+	case strings.Contains(host, "github"):
+		ref.Provider = ProviderGitHub
+	case strings.Contains(host, "gitlab"):
+		ref.Provider = ProviderGitLab
+	}
+
+	return ref, nil
+}
+
+// CanRegisterDeployKey 是否配置了可自动注册部署密钥的平台令牌
+func (c *Client) CanRegisterDeployKey(repoURL string) bool {
+	ref, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return false
+	}
+
+	switch ref.Provider {
+	case ProviderGitHub:
+		return c.config.Git.GitHubToken != ""
+	case ProviderGitLab:
+		return c.config.Git.GitLabToken != ""
+	default:
+		return false
+	}
+}
+
+// RegisterDeployKey 在托管平台注册只读部署密钥，返回平台侧密钥ID
+func (c *Client) RegisterDeployKey(ctx context.Context, repoURL, title, publicKey string) (string, error) {
+	ref, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return "", err
+	}
+
+	var endpoint string
+	var body map[string]interface{}
+	switch ref.Provider {
+	case ProviderGitHub:
+		endpoint = fmt.Sprintf("%s/repos/%s/keys", c.config.Git.GitHubAPIURL, ref.Path)
+		body = map[string]interface{}{"title": title, "key": strings.TrimSpace(publicKey), "read_only": true}
+	case ProviderGitLab:
+		endpoint = fmt.Sprintf("%s/api/v4/projects/%s/deploy_keys", c.config.Git.GitLabURL, url.PathEscape(ref.Path))
+		body = map[string]interface{}{"title": title, "key": strings.TrimSpace(publicKey), "can_push": false}
+	default:
+		return "", fmt.Errorf("不支持自动注册部署密钥的平台: %s", ref.Host)
+	}
+
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodPost, endpoint, body, &result); err != nil {
+		return "", fmt.Errorf("注册部署密钥失败: %w", err)
+	}
+
+	return strconv.FormatInt(result.ID, 10), nil
+}
+
+// RemoveDeployKey 从托管平台删除部署密钥
+func (c *Client) RemoveDeployKey(ctx context.Context, repoURL, providerKeyID string) error {
+	ref, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return err
+	}
+
+	var endpoint string
+	switch ref.Provider {
+	case ProviderGitHub:
+		endpoint = fmt.Sprintf("%s/repos/%s/keys/%s", c.config.Git.GitHubAPIURL, ref.Path, providerKeyID)
+	case ProviderGitLab:
+		endpoint = fmt.Sprintf("%s/api/v4/projects/%s/deploy_keys/%s", c.config.Git.GitLabURL, url.PathEscape(ref.Path), providerKeyID)
+	default:
+		return fmt.Errorf("不支持自动注册部署密钥的平台: %s", ref.Host)
+	}
+
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodDelete, endpoint, nil, nil); err != nil {
+		return fmt.Errorf("删除部署密钥失败: %w", err)
+	}
+
+	return nil
+}
+
+// providerRequest 调用托管平台API，连接失败与 5xx 按默认策略重试，平台持续故障时熔断
+func (c *Client) providerRequest(ctx context.Context, provider, method, endpoint string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+	}
+
+	// 创建类请求重试可能重复创建，只经过熔断不重试
+	policy := retry.Default()
+	if method == http.MethodPost {
+		policy.Attempts = 1
+	}
+
+	return retry.Do(ctx, retry.Host(endpoint), policy, func() error {
+		var reader io.Reader
+		if data != nil {
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("创建请求失败: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		switch provider {
+		case ProviderGitHub:
+			req.Header.Set("Authorization", "Bearer "+c.config.Git.GitHubToken)
+			req.Header.Set("Accept", "application/vnd.github+json")
+		case ProviderGitLab:
+			req.Header.Set("PRIVATE-TOKEN", c.config.Git.GitLabToken)
+		}
+
+		resp, err := httpclient.Default().Do(req)
+		if err != nil {
+			return fmt.Errorf("请求失败: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			err := fmt.Errorf("平台返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+			if !retry.Retryable(resp.StatusCode) {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return retry.Permanent(fmt.Errorf("解析响应失败: %w", err))
+			}
+		}
+		return nil
+	})
+}
+
+// EstimateRepoSize 通过托管平台API查询仓库大小（字节，含LFS对象），未配置令牌或平台不支持时返回错误
+func (c *Client) EstimateRepoSize(ctx context.Context, repoURL string) (int64, error) {
+	ref, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return 0, err
+	}
+	if !c.CanRegisterDeployKey(repoURL) {
+		return 0, fmt.Errorf("未配置平台令牌，无法查询仓库大小: %s", ref.Host)
+	}
+
+	switch ref.Provider {
+	case ProviderGitHub:
+		// GitHub 返回的 size 单位为KB
+		var result struct {
+			Size int64 `json:"size"`
+		}
+		endpoint := fmt.Sprintf("%s/repos/%s", c.config.Git.GitHubAPIURL, ref.Path)
+		if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, endpoint, nil, &result); err != nil {
+			return 0, fmt.Errorf("查询仓库大小失败: %w", err)
+		}
+		return result.Size * 1024, nil
+	case ProviderGitLab:
+		var result struct {
+			Statistics struct {
+				RepositorySize int64 `json:"repository_size"`
+				LFSObjectsSize int64 `json:"lfs_objects_size"`
+			} `json:"statistics"`
+		}
+		endpoint := fmt.Sprintf("%s/api/v4/projects/%s?statistics=true", c.config.Git.GitLabURL, url.PathEscape(ref.Path))
+		if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, endpoint, nil, &result); err != nil {
+			return 0, fmt.Errorf("查询仓库大小失败: %w", err)
+		}
+		return result.Statistics.RepositorySize + result.Statistics.LFSObjectsSize, nil
+	default:
+		return 0, fmt.Errorf("不支持查询仓库大小的平台: %s", ref.Host)
+	}
+}
@@ -0,0 +1,293 @@
+package git
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// ErrReadmeNotFound 仓库根目录下没有README
+var ErrReadmeNotFound = errors.New("README不存在")
+
+// readmeNames 按优先级识别的README文件名（不区分大小写）
+var readmeNames = []string{"readme.md", "readme.markdown", "readme"}
+
+// RepoSummary 仓库某次提交的README与概要信息
+type RepoSummary struct {
+	Commit      string
+	Author      string
+	Message     string
+	CommittedAt time.Time
+	ReadmePath  string
+	Readme      []byte
+	RootFiles   []string // 根目录下的文件与目录名
+}
+
+// ReadRepoSummary 从工作区仓库读取 HEAD 提交的README、提交信息与根目录文件列表，
+// README不存在时返回 ErrReadmeNotFound
+func (c *Client) ReadRepoSummary(repoDir string) (*RepoSummary, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, fmt.Errorf("打开代码库失败: %w", err)
+	}
+
+	ref, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("获取HEAD引用失败: %w", err)
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("获取提交对象失败: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("读取提交目录树失败: %w", err)
+	}
+
+	summary := &RepoSummary{
+		Commit:      commit.Hash.String(),
+		Author:      commit.Author.Name,
+		Message:     strings.TrimSpace(commit.Message),
+		CommittedAt: commit.Author.When,
+	}
+	for _, entry := range tree.Entries {
+		summary.RootFiles = append(summary.RootFiles, entry.Name)
+	}
+
+	summary.ReadmePath = findReadme(summary.RootFiles)
+	if summary.ReadmePath == "" {
+		return summary, ErrReadmeNotFound
+	}
+
+	file, err := tree.File(summary.ReadmePath)
+	if err != nil {
+		return summary, ErrReadmeNotFound
+	}
+	if file.Size > maxRepoFileSize {
+		return summary, fmt.Errorf("文件 %s 超过大小限制（%d 字节）", summary.ReadmePath, maxRepoFileSize)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return summary, fmt.Errorf("读取文件失败: %w", err)
+	}
+	summary.Readme = []byte(content)
+
+	return summary, nil
+}
+
+// FetchRepoSummary 通过托管平台API读取分支最新提交的README与概要信息，
+// 用于工作区尚未克隆的项目；README不存在时返回 ErrReadmeNotFound
+func (c *Client) FetchRepoSummary(ctx context.Context, repoURL, branch string) (*RepoSummary, error) {
+	ref, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	if !c.CanRegisterDeployKey(repoURL) {
+		return nil, fmt.Errorf("未配置平台令牌，无法读取仓库: %s", ref.Host)
+	}
+
+	switch ref.Provider {
+	case ProviderGitHub:
+		return c.fetchGitHubSummary(ctx, ref, branch)
+	case ProviderGitLab:
+		return c.fetchGitLabSummary(ctx, ref, branch)
+	default:
+		return nil, fmt.Errorf("不支持读取仓库的平台: %s", ref.Host)
+	}
+}
+
+// fetchGitHubSummary 通过 GitHub API 读取仓库概要
+func (c *Client) fetchGitHubSummary(ctx context.Context, ref *RepoRef, branch string) (*RepoSummary, error) {
+	base := fmt.Sprintf("%s/repos/%s", c.config.Git.GitHubAPIURL, ref.Path)
+
+	var commit struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Message string `json:"message"`
+			Author  struct {
+				Name string    `json:"name"`
+				Date time.Time `json:"date"`
+			} `json:"author"`
+		} `json:"commit"`
+	}
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, base+"/commits/"+url.PathEscape(branch), nil, &commit); err != nil {
+		return nil, fmt.Errorf("查询最新提交失败: %w", err)
+	}
+	summary := &RepoSummary{
+		Commit:      commit.SHA,
+		Author:      commit.Commit.Author.Name,
+		Message:     strings.TrimSpace(commit.Commit.Message),
+		CommittedAt: commit.Commit.Author.Date,
+	}
+
+	var entries []struct {
+		Name string `json:"name"`
+	}
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, base+"/contents?ref="+url.QueryEscape(summary.Commit), nil, &entries); err != nil {
+		return nil, fmt.Errorf("查询仓库目录失败: %w", err)
+	}
+	for _, entry := range entries {
+		summary.RootFiles = append(summary.RootFiles, entry.Name)
+	}
+
+	summary.ReadmePath = findReadme(summary.RootFiles)
+	if summary.ReadmePath == "" {
+		return summary, ErrReadmeNotFound
+	}
+
+	var file struct {
+		Content string `json:"content"`
+	}
+	endpoint := fmt.Sprintf("%s/contents/%s?ref=%s", base, url.PathEscape(summary.ReadmePath), url.QueryEscape(summary.Commit))
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, endpoint, nil, &file); err != nil {
+		return summary, fmt.Errorf("读取README失败: %w", err)
+	}
+	return summary, decodeReadme(summary, file.Content)
+}
+
+// fetchGitLabSummary 通过 GitLab API 读取仓库概要
+func (c *Client) fetchGitLabSummary(ctx context.Context, ref *RepoRef, branch string) (*RepoSummary, error) {
+	base := fmt.Sprintf("%s/api/v4/projects/%s/repository", c.config.Git.GitLabURL, url.PathEscape(ref.Path))
+
+	var commit struct {
+		ID            string    `json:"id"`
+		Message       string    `json:"message"`
+		AuthorName    string    `json:"author_name"`
+		CommittedDate time.Time `json:"committed_date"`
+	}
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, base+"/commits/"+url.PathEscape(branch), nil, &commit); err != nil {
+		return nil, fmt.Errorf("查询最新提交失败: %w", err)
+	}
+	summary := &RepoSummary{
+		Commit:      commit.ID,
+		Author:      commit.AuthorName,
+		Message:     strings.TrimSpace(commit.Message),
+		CommittedAt: commit.CommittedDate,
+	}
+
+	var entries []struct {
+		Name string `json:"name"`
+	}
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, base+"/tree?per_page=100&ref="+url.QueryEscape(summary.Commit), nil, &entries); err != nil {
+		return nil, fmt.Errorf("查询仓库目录失败: %w", err)
+	}
+	for _, entry := range entries {
+		summary.RootFiles = append(summary.RootFiles, entry.Name)
+	}
+
+	summary.ReadmePath = findReadme(summary.RootFiles)
+	if summary.ReadmePath == "" {
+		return summary, ErrReadmeNotFound
+	}
+
+	var file struct {
+		Content string `json:"content"`
+	}
+	endpoint := fmt.Sprintf("%s/files/%s?ref=%s", base, url.PathEscape(summary.ReadmePath), url.QueryEscape(summary.Commit))
+	if err := c.providerRequest(ctx, ref.Provider, http.MethodGet, endpoint, nil, &file); err != nil {
+		return summary, fmt.Errorf("读取README失败: %w", err)
+	}
+	return summary, decodeReadme(summary, file.Content)
+}
+
+// decodeReadme 解码平台返回的 base64 文件内容
+func decodeReadme(summary *RepoSummary, content string) error {
+	// GitHub 返回的内容按行折断
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(content, "\n", ""))
+	if err != nil {
+		return fmt.Errorf("解码README失败: %w", err)
+	}
+	if len(data) > maxRepoFileSize {
+		return fmt.Errorf("文件 %s 超过大小限制（%d 字节）", summary.ReadmePath, maxRepoFileSize)
+	}
+	summary.Readme = data
+	return nil
+}
+
+// languageMarkers 根据根目录标志文件识别项目语言，按顺序匹配
+var languageMarkers = []struct {
+	file     string
+	language string
+}{
+	{"go.mod", "Go"},
+	{"tsconfig.json", "TypeScript"},
+	{"package.json", "JavaScript"},
+	{"Cargo.toml", "Rust"},
+	{"pom.xml", "Java"},
+	{"build.gradle", "Java"},
+	{"build.gradle.kts", "Kotlin"},
+	{"pyproject.toml", "Python"},
+	{"requirements.txt", "Python"},
+	{"setup.py", "Python"},
+	{"Gemfile", "Ruby"},
+	{"composer.json", "PHP"},
+}
+
+// DetectProjectType 根据根目录文件识别项目语言与构建类型；
+// 构建类型与构建步骤 type: auto 的检测顺序一致（node、go、docker），无法识别时为空
+func DetectProjectType(files []string) (language, buildType string) {
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file] = true
+	}
+
+	for _, marker := range languageMarkers {
+		if present[marker.file] {
+			language = marker.language
+			break
+		}
+	}
+
+	switch {
+	case present["package.json"]:
+		buildType = "node"
+	case present["go.mod"]:
+		buildType = "go"
+	case present["Dockerfile"]:
+		buildType = "docker"
+	}
+	return language, buildType
+}
+
+// findReadme 在根目录文件中按优先级查找README
+func findReadme(files []string) string {
+	for _, name := range readmeNames {
+		for _, file := range files {
+			if strings.EqualFold(file, name) {
+				return file
+			}
+		}
+	}
+	return ""
+}
+
+// FileURL 仓库文件在托管平台上的浏览地址，raw 为 true 时返回原始内容地址（用于图片）；
+// 不支持的平台返回空字符串
+func (r *RepoRef) FileURL(commit, filePath string, raw bool) string {
+	segments := strings.Split(strings.TrimPrefix(path.Clean("/"+filePath), "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	filePath = strings.Join(segments, "/")
+	kind := "blob"
+	if raw {
+		kind = "raw"
+	}
+
+	switch r.Provider {
+	case ProviderGitHub:
+		return fmt.Sprintf("https://%s/%s/%s/%s/%s", r.Host, r.Path, kind, commit, filePath)
+	case ProviderGitLab:
+		return fmt.Sprintf("https://%s/%s/-/%s/%s/%s", r.Host, r.Path, kind, commit, filePath)
+	default:
+		return ""
+	}
+}
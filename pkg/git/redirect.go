@@ -0,0 +1,107 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"flowforge/pkg/httpclient"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// redirectProbeTimeout 检查仓库重定向的超时时间
+const redirectProbeTimeout = 15 * time.Second
+
+// notFoundMessages 远程报告仓库不存在时的错误信息，SSH 远程不会通过重定向报告仓库迁移，只能据此判断
+var notFoundMessages = []string{
+	"repository not found",
+	"could not be found",
+	"does not appear to be a git repository",
+}
+
+// DetectRedirect 检查 HTTP(S) 远程仓库是否已迁移：托管平台对改名或转移的仓库返回重定向，拉取代码时会被静默跟随。
+// 按 git 协议请求 info/refs 且不跟随重定向，返回重定向到的仓库地址；未迁移或不是 HTTP(S) 远程时返回空
+func (c *Client) DetectRedirect(ctx context.Context, repoURL string) (string, error) {
+	original, err := url.Parse(repoURL)
+	if err != nil || (original.Scheme != "http" && original.Scheme != "https") {
+		return "", nil
+	}
+
+	probe := strings.TrimSuffix(repoURL, "/") + "/info/refs?service=git-upload-pack"
+	timeoutCtx, cancel := context.WithTimeout(ctx, redirectProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(timeoutCtx, http.MethodGet, probe, nil)
+	if err != nil {
+		return "", fmt.Errorf("检查仓库地址失败: %w", err)
+	}
+
+	client := httpclient.New(redirectProbeTimeout)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("检查仓库地址失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "", nil
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return "", nil
+	}
+
+	moved := *location
+	moved.RawQuery = ""
+	moved.Path = strings.TrimSuffix(moved.Path, "/info/refs")
+	moved.RawPath = ""
+	if strings.HasSuffix(original.Path, ".git") && !strings.HasSuffix(moved.Path, ".git") {
+		moved.Path += ".git"
+	}
+	// 重定向不带原地址中的认证信息
+	if moved.User == nil && moved.Host == original.Host {
+		moved.User = original.User
+	}
+
+	// 只是协议升级或大小写、.git 后缀不同时不算迁移
+	if sameRepo(repoURL, moved.String()) {
+		return "", nil
+	}
+	return moved.String(), nil
+}
+
+// sameRepo 两个仓库地址是否指向同一主机上的同一仓库
+func sameRepo(a, b string) bool {
+	refA, errA := ParseRepoURL(a)
+	refB, errB := ParseRepoURL(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return strings.EqualFold(refA.Host, refB.Host) && strings.EqualFold(refA.Path, refB.Path)
+}
+
+// IsRepoNotFound 拉取代码的错误是否为远程报告仓库不存在
+func IsRepoNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, transport.ErrRepositoryNotFound) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, text := range notFoundMessages {
+		if strings.Contains(message, text) {
+			return true
+		}
+	}
+	return false
+}
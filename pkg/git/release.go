@@ -0,0 +1,117 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"flowforge/pkg/models"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// ErrUnknownRange 无法确定两个提交之间的范围：起始提交不在结束提交的历史中（强制推送覆盖了历史），或工作区缺少中间的历史
+var ErrUnknownRange = errors.New("无法确定提交范围")
+
+// CommitRange 工作区仓库中 from（不含）到 to 之间的提交，按历史从新到旧排列，最多 limit 个，超出时 truncated 为 true。
+// 无法确定范围时返回 ErrUnknownRange
+func (c *Client) CommitRange(repoDir, from, to string, limit int) (commits []models.ReleaseCommit, truncated bool, err error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, false, fmt.Errorf("打开代码库失败: %w", err)
+	}
+	fromHash, toHash := plumbing.NewHash(from), plumbing.NewHash(to)
+	if _, err := repo.CommitObject(fromHash); err != nil {
+		return nil, false, ErrUnknownRange
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: toHash})
+	if err != nil {
+		return nil, false, ErrUnknownRange
+	}
+	defer iter.Close()
+
+	found := false
+	err = iter.ForEach(func(commit *object.Commit) error {
+		if commit.Hash == fromHash {
+			found = true
+			return storer.ErrStop
+		}
+		if len(commits) >= limit {
+			truncated = true
+			return nil
+		}
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		commits = append(commits, models.ReleaseCommit{
+			Hash:    commit.Hash.String(),
+			Subject: strings.TrimSpace(subject),
+			Author:  commit.Author.Name,
+		})
+		return nil
+	})
+	// 浅克隆的历史在边界处中断，同样视为无法确定范围
+	if err != nil || !found {
+		return nil, false, ErrUnknownRange
+	}
+	return commits, truncated, nil
+}
+
+// ReleaseOptions 在托管平台创建 release 的内容
+type ReleaseOptions struct {
+	Tag    string // 标签名，不存在时由平台在 Commit 上创建
+	Commit string
+	Name   string
+	Body   string // Markdown
+}
+
+// CreateRelease 在托管平台创建带标签的 release，返回 release 页面地址；未配置平台令牌时返回错误
+func (c *Client) CreateRelease(ctx context.Context, repoURL string, opts ReleaseOptions) (string, error) {
+	ref, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return "", err
+	}
+	if !c.CanRegisterDeployKey(repoURL) {
+		return "", fmt.Errorf("未配置平台令牌，无法创建 release: %s", ref.Host)
+	}
+
+	switch ref.Provider {
+	case ProviderGitHub:
+		var result struct {
+			HTMLURL string `json:"html_url"`
+		}
+		endpoint := fmt.Sprintf("%s/repos/%s/releases", c.config.Git.GitHubAPIURL, ref.Path)
+		body := map[string]interface{}{
+			"tag_name":         opts.Tag,
+			"target_commitish": opts.Commit,
+			"name":             opts.Name,
+			"body":             opts.Body,
+		}
+		if err := c.providerRequest(ctx, ref.Provider, http.MethodPost, endpoint, body, &result); err != nil {
+			return "", fmt.Errorf("创建 release 失败: %w", err)
+		}
+		return result.HTMLURL, nil
+	default:
+		var result struct {
+			Links struct {
+				Self string `json:"self"`
+			} `json:"_links"`
+		}
+		endpoint := fmt.Sprintf("%s/api/v4/projects/%s/releases", c.config.Git.GitLabURL, url.PathEscape(ref.Path))
+		body := map[string]interface{}{
+			"tag_name":    opts.Tag,
+			"ref":         opts.Commit,
+			"name":        opts.Name,
+			"description": opts.Body,
+		}
+		if err := c.providerRequest(ctx, ref.Provider, http.MethodPost, endpoint, body, &result); err != nil {
+			return "", fmt.Errorf("创建 release 失败: %w", err)
+		}
+		return result.Links.Self, nil
+	}
+}
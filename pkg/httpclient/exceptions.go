@@ -0,0 +1,101 @@
+package httpclient
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// exceptionTTL 项目出站例外的缓存时间；例外变更时立即失效
+const exceptionTTL = 30 * time.Second
+
+// exceptionSet 项目出站例外：放行的网段与主机名
+type exceptionSet struct {
+	nets     []netRule
+	hosts    []string
+	loadedAt time.Time
+}
+
+var exceptions = struct {
+	sync.Mutex
+	entries map[uint]*exceptionSet
+}{entries: make(map[uint]*exceptionSet)}
+
+// projectKey 出站请求所属项目
+type projectKey struct{}
+
+// WithProject 标记出站请求所属的项目，请求按该项目的出站例外检查
+func WithProject(ctx context.Context, projectID uint) context.Context {
+	return context.WithValue(ctx, projectKey{}, projectID)
+}
+
+// ProjectFromContext 出站请求所属的项目，未标记时返回 0
+func ProjectFromContext(ctx context.Context) uint {
+	projectID, _ := ctx.Value(projectKey{}).(uint)
+	return projectID
+}
+
+// InvalidateExceptions 丢弃项目的出站例外缓存；projectID 为 0 时丢弃全部
+func InvalidateExceptions(projectID uint) {
+	exceptions.Lock()
+	defer exceptions.Unlock()
+	if projectID == 0 {
+		exceptions.entries = make(map[uint]*exceptionSet)
+		return
+	}
+	delete(exceptions.entries, projectID)
+}
+
+// projectExceptions 获取项目的出站例外，projectID 为 0 或加载失败时没有例外
+func projectExceptions(projectID uint) *exceptionSet {
+	if projectID == 0 {
+		return &exceptionSet{}
+	}
+
+	now := time.Now()
+	exceptions.Lock()
+	entry, ok := exceptions.entries[projectID]
+	exceptions.Unlock()
+	if ok && now.Sub(entry.loadedAt) < exceptionTTL {
+		return entry
+	}
+
+	// 加载失败时不缓存，按没有例外处理
+	entry, err := loadExceptions(projectID)
+	if err != nil {
+		log.Printf("加载项目 %d 的出站例外失败: %v", projectID, err)
+		return &exceptionSet{}
+	}
+	entry.loadedAt = now
+
+	exceptions.Lock()
+	exceptions.entries[projectID] = entry
+	exceptions.Unlock()
+	return entry
+}
+
+// loadExceptions 从数据库加载项目的出站例外
+func loadExceptions(projectID uint) (*exceptionSet, error) {
+	if database.DB == nil {
+		return &exceptionSet{}, nil
+	}
+
+	var records []models.OutboundException
+	if err := database.DB.Where("project_id = ?", projectID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	set := &exceptionSet{}
+	for _, record := range records {
+		if network, name, err := parseNet(record.Target); err == nil {
+			set.nets = append(set.nets, netRule{network: network, name: "项目出站例外 " + name})
+			continue
+		}
+		set.hosts = append(set.hosts, normalizeHost(record.Target))
+	}
+	return set, nil
+}
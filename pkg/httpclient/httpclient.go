@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	mu             sync.RWMutex
+	transport      http.RoundTripper = http.DefaultTransport
+	defaultTimeout                   = 30 * time.Second
+	policy         *Policy
+)
+
+// Init 根据网络配置初始化共享的出站传输层，应在启动时调用一次
+func Init(cfg *config.NetworkConfig) error {
+	p, err := NewPolicy(&cfg.Outbound)
+	if err != nil {
+		return err
+	}
+
+	t, err := newTransport(cfg, p)
+	if err != nil {
+		return err
+	}
+
+	if cfg.InsecureSkipVerify {
+		log.Println("警告: 已启用 network.insecure_skip_verify，出站HTTPS请求将不校验服务端证书")
+	}
+
+	if cfg.Outbound.AllowPrivate {
+		log.Println("警告: 已启用 network.outbound.allow_private，出站请求可以访问内网与回环地址")
+	}
+
+	mu.Lock()
+	transport = t
+	policy = p
+	if cfg.Timeout > 0 {
+		defaultTimeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	mu.Unlock()
+
+	return nil
+}
+
+// NewTransport 根据网络配置创建传输层：代理、额外CA证书、超时与出站目标策略
+func NewTransport(cfg *config.NetworkConfig) (http.RoundTripper, error) {
+	p, err := NewPolicy(&cfg.Outbound)
+	if err != nil {
+		return nil, err
+	}
+	return newTransport(cfg, p)
+}
+
+func newTransport(cfg *config.NetworkConfig, p *Policy) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CACertFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书文件中没有有效的证书: %s", cfg.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	proxy, err := proxyFunc(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   seconds(cfg.DialTimeout, 10),
+		KeepAlive: 30 * time.Second,
+	}
+
+	base := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           p.dialContext(dialer.DialContext),
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   seconds(cfg.TLSHandshakeTimeout, 10),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &guardedTransport{base: base, policy: p}, nil
+}
+
+// New 返回使用共享传输层的HTTP客户端，timeout为0时使用配置的默认超时
+func New(timeout time.Duration) *http.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// NewWithTLS 返回在共享传输层基础上调整TLS配置的HTTP客户端：代理、超时与出站目标策略保持不变，
+// configure 收到共享TLS配置的副本，可在其上追加客户端证书或额外的CA证书
+func NewWithTLS(timeout time.Duration, configure func(*tls.Config)) *http.Client {
+	client := New(timeout)
+	switch t := client.Transport.(type) {
+	case *guardedTransport:
+		client.Transport = &guardedTransport{base: cloneWithTLS(t.base, configure), policy: t.policy}
+	case *http.Transport:
+		client.Transport = cloneWithTLS(t, configure)
+	}
+	return client
+}
+
+// cloneWithTLS 复制传输层并调整其TLS配置
+func cloneWithTLS(base *http.Transport, configure func(*tls.Config)) *http.Transport {
+	t := base.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	configure(t.TLSClientConfig)
+	return t
+}
+
+// Default 返回使用默认超时的HTTP客户端
+func Default() *http.Client {
+	return New(0)
+}
+
+// proxyFunc 配置了代理地址时使用该代理，否则沿用 HTTP(S)_PROXY 环境变量
+func proxyFunc(cfg *config.NetworkConfig) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	if _, err := url.Parse(cfg.ProxyURL); err != nil {
+		return nil, fmt.Errorf("无效的代理地址: %w", err)
+	}
+
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  cfg.ProxyURL,
+		HTTPSProxy: cfg.ProxyURL,
+		NoProxy:    cfg.NoProxy,
+	}
+	fn := proxyConfig.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}, nil
+}
+
+// seconds 将秒数转换为时长，未配置时使用默认值
+func seconds(value, fallback int) time.Duration {
+	if value <= 0 {
+		value = fallback
+	}
+	return time.Duration(value) * time.Second
+}
@@ -0,0 +1,56 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flowforge/pkg/config"
+)
+
+// initShared 以 cfg 初始化共享传输层，测试结束后恢复
+func initShared(t *testing.T, cfg *config.NetworkConfig) {
+	t.Helper()
+	mu.RLock()
+	saved, savedPolicy, savedTimeout := transport, policy, defaultTimeout
+	mu.RUnlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		transport, policy, defaultTimeout = saved, savedPolicy, savedTimeout
+		mu.Unlock()
+	})
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestNewWithTLS 调整TLS配置的客户端仍受出站目标策略限制；追加的CA证书只作用于该客户端，不改动共享传输层
+func TestNewWithTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	trustServer := func(tlsConfig *tls.Config) {
+		pool := x509.NewCertPool()
+		pool.AddCert(ts.Certificate())
+		tlsConfig.RootCAs = pool
+	}
+
+	initShared(t, &config.NetworkConfig{})
+	if _, err := NewWithTLS(0, trustServer).Get(ts.URL); !errors.Is(err, ErrTargetBlocked) {
+		t.Fatalf("默认策略应禁止访问回环地址，实际为 %v", err)
+	}
+
+	initShared(t, &config.NetworkConfig{Outbound: config.OutboundPolicyConfig{AllowCIDRs: []string{"127.0.0.1/32"}}})
+	resp, err := NewWithTLS(0, trustServer).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("追加CA证书后应能访问放行的地址: %v", err)
+	}
+	resp.Body.Close()
+
+	var unknown x509.UnknownAuthorityError
+	if _, err := Default().Get(ts.URL); !errors.As(err, &unknown) {
+		t.Errorf("共享传输层不应信任只追加给单个客户端的CA证书，实际为 %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"flowforge/pkg/config"
+)
+
+// ErrTargetBlocked 出站目标被策略禁止
+var ErrTargetBlocked = errors.New("出站目标被策略禁止")
+
+// PolicyError 出站目标被策略禁止，Rule 为命中的规则
+type PolicyError struct {
+	Host string
+	IP   string // 按解析出的地址禁止时为该地址
+	Rule string
+}
+
+// Error 实现 error
+func (e *PolicyError) Error() string {
+	target := e.Host
+	if e.IP != "" && e.IP != e.Host {
+		target = fmt.Sprintf("%s (%s)", e.Host, e.IP)
+	}
+	return fmt.Sprintf("出站目标 %s 被策略禁止：%s", target, e.Rule)
+}
+
+// Unwrap 使 errors.Is(err, ErrTargetBlocked) 成立
+func (e *PolicyError) Unwrap() error {
+	return ErrTargetBlocked
+}
+
+// defaultBlocked 默认禁止的地址，allow_private 时不检查
+var defaultBlocked = []struct {
+	cidr string
+	name string
+}{
+	{"127.0.0.0/8", "默认禁止的回环地址"},
+	{"::1/128", "默认禁止的回环地址"},
+	{"169.254.0.0/16", "默认禁止的链路本地地址"},
+	{"fe80::/10", "默认禁止的链路本地地址"},
+	{"10.0.0.0/8", "默认禁止的内网地址"},
+	{"172.16.0.0/12", "默认禁止的内网地址"},
+	{"192.168.0.0/16", "默认禁止的内网地址"},
+	{"fc00::/7", "默认禁止的内网地址"},
+	{"0.0.0.0/8", "默认禁止的未指定地址"},
+	{"::/128", "默认禁止的未指定地址"},
+}
+
+// netRule 网段规则
+type netRule struct {
+	network *net.IPNet
+	name    string
+}
+
+// resolver 域名解析
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Policy 出站目标策略：先检查主机名，再检查解析出的每个地址。直连时只连接检查过的地址，
+// 解析与连接之间不会再次解析，避免 DNS 重绑定
+type Policy struct {
+	allowHosts []string
+	denyHosts  []string
+	allowNets  []netRule
+	denyNets   []netRule
+	blocked    []netRule
+	resolver   resolver
+}
+
+// NewPolicy 根据配置创建出站目标策略
+func NewPolicy(cfg *config.OutboundPolicyConfig) (*Policy, error) {
+	p := &Policy{
+		allowHosts: normalizeHosts(cfg.AllowHosts),
+		denyHosts:  normalizeHosts(cfg.DenyHosts),
+		resolver:   net.DefaultResolver,
+	}
+
+	var err error
+	if p.allowNets, err = parseNets(cfg.AllowCIDRs, "network.outbound.allow_cidrs"); err != nil {
+		return nil, err
+	}
+	if p.denyNets, err = parseNets(cfg.DenyCIDRs, "network.outbound.deny_cidrs"); err != nil {
+		return nil, err
+	}
+	if !cfg.AllowPrivate {
+		for _, entry := range defaultBlocked {
+			_, network, _ := net.ParseCIDR(entry.cidr)
+			p.blocked = append(p.blocked, netRule{network: network, name: entry.name + " " + entry.cidr})
+		}
+	}
+	return p, nil
+}
+
+// Resolve 按策略检查主机并返回允许连接的地址；解析出的地址中有任何一个被禁止时整体禁止
+func (p *Policy) Resolve(ctx context.Context, host string) ([]net.IP, error) {
+	host = normalizeHost(host)
+	exceptions := projectExceptions(ProjectFromContext(ctx))
+
+	hostAllowed, err := p.checkHost(host, exceptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if err := p.checkIP(host, ip, hostAllowed, exceptions); err != nil {
+			return nil, err
+		}
+		return []net.IP{ip}, nil
+	}
+
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("解析 %s 没有得到地址", host)
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if err := p.checkIP(host, addr.IP, hostAllowed, exceptions); err != nil {
+			return nil, err
+		}
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// CheckStatic 不解析域名，只按主机名规则与 IP 字面量检查，用于保存配置时校验
+func (p *Policy) CheckStatic(host string, projectID uint) error {
+	host = normalizeHost(host)
+	exceptions := projectExceptions(projectID)
+
+	hostAllowed, err := p.checkHost(host, exceptions)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(host, ip, hostAllowed, exceptions)
+	}
+	return nil
+}
+
+// checkHost 按主机名规则检查，返回主机名是否被明确放行
+func (p *Policy) checkHost(host string, exceptions *exceptionSet) (bool, error) {
+	if pattern, ok := matchHost(host, p.denyHosts); ok {
+		return false, &PolicyError{Host: host, Rule: "network.outbound.deny_hosts " + pattern}
+	}
+	if _, ok := matchHost(host, p.allowHosts); ok {
+		return true, nil
+	}
+	_, ok := matchHost(host, exceptions.hosts)
+	return ok, nil
+}
+
+// checkIP 检查地址：明确禁止的网段总是禁止，放行的主机名、网段与项目例外跳过默认禁止的地址
+func (p *Policy) checkIP(host string, ip net.IP, hostAllowed bool, exceptions *exceptionSet) error {
+	if rule, ok := matchNet(ip, p.denyNets); ok {
+		return &PolicyError{Host: host, IP: ip.String(), Rule: rule}
+	}
+	if hostAllowed {
+		return nil
+	}
+	if _, ok := matchNet(ip, p.allowNets); ok {
+		return nil
+	}
+	if _, ok := matchNet(ip, exceptions.nets); ok {
+		return nil
+	}
+	if rule, ok := matchNet(ip, p.blocked); ok {
+		return &PolicyError{Host: host, IP: ip.String(),
+			Rule: rule + "，可由管理员在 network.outbound.allow_cidrs 或项目出站例外中放行"}
+	}
+	return nil
+}
+
+// dialContext 包装连接函数：解析并检查目标后直接连接检查过的地址；经代理的请求连接的是代理，不检查
+func (p *Policy) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if viaProxy, _ := ctx.Value(viaProxyKey{}).(bool); viaProxy {
+			return dial(ctx, network, addr)
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := p.Resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+// viaProxyKey 标记请求经代理发出
+type viaProxyKey struct{}
+
+// guardedTransport 发出请求前检查经代理访问的目标；直连的目标由 Policy.dialContext 检查
+type guardedTransport struct {
+	base   *http.Transport
+	policy *Policy
+}
+
+// RoundTrip 实现 http.RoundTripper，重定向后的每个请求同样经过检查
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.base.Proxy != nil {
+		if proxyURL, err := t.base.Proxy(req); err == nil && proxyURL != nil {
+			// 经代理访问时由代理解析并连接目标，只能按当前的解析结果检查
+			if _, err := t.policy.Resolve(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+			req = req.WithContext(context.WithValue(req.Context(), viaProxyKey{}, true))
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// ValidateURL 校验用户填写的出站地址：只允许 http 与 https，必须包含主机名，
+// 并按出站策略检查主机名与 IP 字面量；域名解析在发起请求时检查
+func ValidateURL(raw string, projectID uint) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("地址格式错误: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("地址只支持 http 与 https")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("地址缺少主机名")
+	}
+
+	mu.RLock()
+	p := policy
+	mu.RUnlock()
+	if p == nil {
+		return nil
+	}
+	return p.CheckStatic(u.Hostname(), projectID)
+}
+
+// ParseExceptionTarget 校验项目出站例外的目标：IP、网段或主机名（支持 *.example.com）
+func ParseExceptionTarget(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", fmt.Errorf("例外目标不能为空")
+	}
+	if _, _, err := parseNet(target); err == nil {
+		return target, nil
+	}
+	host := normalizeHost(target)
+	if strings.ContainsAny(host, "/:@ ") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return "", fmt.Errorf("无效的例外目标: %s", target)
+	}
+	return host, nil
+}
+
+// parseNets 解析网段或IP列表
+func parseNets(values []string, source string) ([]netRule, error) {
+	rules := make([]netRule, 0, len(values))
+	for _, value := range values {
+		network, name, err := parseNet(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s 中的 %q 不是有效的网段或IP", source, value)
+		}
+		rules = append(rules, netRule{network: network, name: source + " " + name})
+	}
+	return rules, nil
+}
+
+// parseNet 解析网段，单个 IP 视为只包含该地址的网段
+func parseNet(value string) (*net.IPNet, string, error) {
+	value = strings.TrimSpace(value)
+	if ip := net.ParseIP(value); ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, value, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, "", err
+	}
+	return network, network.String(), nil
+}
+
+// matchNet 返回地址命中的第一条网段规则
+func matchNet(ip net.IP, rules []netRule) (string, bool) {
+	for _, rule := range rules {
+		if rule.network.Contains(ip) {
+			return rule.name, true
+		}
+	}
+	return "", false
+}
+
+// matchHost 返回主机名命中的第一个模式，*.example.com 匹配 example.com 的任意子域名
+func matchHost(host string, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return pattern, true
+			}
+		} else if host == pattern {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+func normalizeHosts(hosts []string) []string {
+	normalized := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host = normalizeHost(host); host != "" {
+			normalized = append(normalized, host)
+		}
+	}
+	return normalized
+}
+
+// normalizeHost 主机名不区分大小写，去掉结尾的点与 IPv6 的方括号
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(host, ".")
+}
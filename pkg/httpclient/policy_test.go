@@ -0,0 +1,147 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"flowforge/pkg/config"
+)
+
+// rebindingResolver 依次返回预设的解析结果，模拟 DNS 重绑定：先解析为公网地址，之后解析为内网或回环地址
+type rebindingResolver struct {
+	mu      sync.Mutex
+	answers [][]string
+	calls   int
+}
+
+func (r *rebindingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	answer := r.answers[len(r.answers)-1]
+	if r.calls < len(r.answers) {
+		answer = r.answers[r.calls]
+	}
+	r.calls++
+
+	addrs := make([]net.IPAddr, 0, len(answer))
+	for _, ip := range answer {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+// recordingDial 记录实际连接的地址，不建立连接
+type recordingDial struct {
+	addrs []string
+}
+
+var errDialed = errors.New("已连接")
+
+func (d *recordingDial) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	d.addrs = append(d.addrs, addr)
+	return nil, errDialed
+}
+
+func newTestPolicy(t *testing.T, cfg config.OutboundPolicyConfig, answers ...[]string) (*Policy, *rebindingResolver) {
+	t.Helper()
+	p, err := NewPolicy(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &rebindingResolver{answers: answers}
+	p.resolver = r
+	return p, r
+}
+
+func TestDialRejectsRebinding(t *testing.T) {
+	for _, private := range []string{"127.0.0.1", "10.0.0.5", "169.254.169.254", "::1"} {
+		t.Run(private, func(t *testing.T) {
+			p, r := newTestPolicy(t, config.OutboundPolicyConfig{}, []string{"93.184.216.34"}, []string{private})
+			d := &recordingDial{}
+			dial := p.dialContext(d.dial)
+
+			// 保存配置时只检查主机名，不解析
+			if err := p.CheckStatic("rebind.example.com", 0); err != nil {
+				t.Fatalf("公网域名应允许保存: %v", err)
+			}
+
+			// 第一次解析为公网地址，连接的是检查过的地址
+			if _, err := dial(context.Background(), "tcp", "rebind.example.com:443"); !errors.Is(err, errDialed) {
+				t.Fatalf("公网地址应允许连接: %v", err)
+			}
+			if len(d.addrs) != 1 || d.addrs[0] != "93.184.216.34:443" {
+				t.Fatalf("应直接连接检查过的地址，实际连接 %v", d.addrs)
+			}
+
+			// 第二次解析为内网地址，连接前被拒绝
+			_, err := dial(context.Background(), "tcp", "rebind.example.com:443")
+			if !errors.Is(err, ErrTargetBlocked) {
+				t.Fatalf("重绑定到 %s 应被禁止，实际为 %v", private, err)
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) || policyErr.IP != private {
+				t.Fatalf("错误应指出被禁止的地址 %s: %v", private, err)
+			}
+			if len(d.addrs) != 1 {
+				t.Fatalf("被禁止的地址不应连接，实际连接 %v", d.addrs)
+			}
+			if r.calls != 2 {
+				t.Fatalf("每次连接应只解析一次，实际解析 %d 次", r.calls)
+			}
+		})
+	}
+}
+
+func TestDialRejectsMixedAnswer(t *testing.T) {
+	// 解析结果中有任何一个内网地址时整体禁止，不会先连接公网地址再回退到内网地址
+	p, _ := newTestPolicy(t, config.OutboundPolicyConfig{}, []string{"93.184.216.34", "127.0.0.1"})
+	d := &recordingDial{}
+	if _, err := p.dialContext(d.dial)(context.Background(), "tcp", "mixed.example.com:80"); !errors.Is(err, ErrTargetBlocked) {
+		t.Fatalf("包含回环地址的解析结果应被禁止，实际为 %v", err)
+	}
+	if len(d.addrs) != 0 {
+		t.Fatalf("被禁止时不应连接，实际连接 %v", d.addrs)
+	}
+}
+
+func TestDialAllowsExplicitRules(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  config.OutboundPolicyConfig
+		host string
+		ip   string
+		want error
+	}{
+		{"allow_private", config.OutboundPolicyConfig{AllowPrivate: true}, "internal.example.com", "10.0.0.5", errDialed},
+		{"allow_hosts", config.OutboundPolicyConfig{AllowHosts: []string{"*.corp.example.com"}}, "git.corp.example.com", "10.0.0.5", errDialed},
+		{"allow_cidrs", config.OutboundPolicyConfig{AllowCIDRs: []string{"10.0.0.0/24"}}, "internal.example.com", "10.0.0.5", errDialed},
+		{"deny_cidrs overrides allow_hosts", config.OutboundPolicyConfig{AllowHosts: []string{"internal.example.com"}, DenyCIDRs: []string{"10.0.0.0/8"}}, "internal.example.com", "10.0.0.5", ErrTargetBlocked},
+		{"deny_hosts", config.OutboundPolicyConfig{DenyHosts: []string{"*.example.com"}}, "public.example.com", "93.184.216.34", ErrTargetBlocked},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, _ := newTestPolicy(t, tc.cfg, []string{tc.ip})
+			d := &recordingDial{}
+			if _, err := p.dialContext(d.dial)(context.Background(), "tcp", tc.host+":443"); !errors.Is(err, tc.want) {
+				t.Fatalf("应返回 %v，实际为 %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestDialRejectsLiteralIP(t *testing.T) {
+	p, r := newTestPolicy(t, config.OutboundPolicyConfig{}, []string{"93.184.216.34"})
+	d := &recordingDial{}
+	if _, err := p.dialContext(d.dial)(context.Background(), "tcp", "[::1]:8080"); !errors.Is(err, ErrTargetBlocked) {
+		t.Fatalf("回环地址字面量应被禁止，实际为 %v", err)
+	}
+	if err := p.CheckStatic("127.0.0.1", 0); !errors.Is(err, ErrTargetBlocked) {
+		t.Fatalf("保存配置时应禁止回环地址字面量，实际为 %v", err)
+	}
+	if r.calls != 0 || len(d.addrs) != 0 {
+		t.Fatalf("IP 字面量不应解析或连接: 解析 %d 次，连接 %v", r.calls, d.addrs)
+	}
+}
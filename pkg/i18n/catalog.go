@@ -0,0 +1,942 @@
+package i18n
+
+// catalogs 各语言的消息目录，键与语言无关，同时作为接口返回的错误码
+var catalogs = map[string]map[string]string{
+	"zh-CN": {
+		// 通用
+		"invalid_request":      "请求参数错误",
+		"invalid_params":       "无效的请求参数",
+		"permission_denied":    "权限不足",
+		"owner_or_admin_only":  "只有项目所有者或管理员可以执行此操作",
+		"database_query_error": "数据库查询失败",
+		"read_body_failed":     "读取请求体失败",
+
+		// 认证
+		"auth_missing":          "未提供认证信息",
+		"auth_invalid_format":   "认证格式无效",
+		"auth_invalid_token":    "无效的认证令牌",
+		"auth_token_missing":    "缺少认证令牌",
+		"auth_token_invalid":    "无效的令牌",
+		"auth_token_failed":     "生成令牌失败",
+		"auth_bad_credentials":  "用户名或密码错误",
+		"auth_account_disabled": "用户账户已被禁用",
+
+		// 用户
+		"user_not_found":         "用户不存在",
+		"user_invalid_id":        "无效的用户ID",
+		"user_list_failed":       "获取用户列表失败",
+		"user_create_failed":     "创建用户失败",
+		"user_update_failed":     "更新用户失败",
+		"user_delete_failed":     "删除用户失败",
+		"user_username_taken":    "用户名已存在",
+		"user_email_taken":       "邮箱已存在",
+		"user_email_in_use":      "邮箱已被其他用户使用",
+		"user_password_failed":   "密码加密失败",
+		"user_role_not_found":    "角色不存在",
+		"user_invalid_locale":    "不支持的语言",
+		"notify_invalid_channel": "无效的通知渠道",
+		"notify_pref_failed":     "更新通知偏好失败",
+		"notify_not_found":       "通知不存在",
+		"notify_mark_failed":     "标记已读失败",
+		"watch_failed":           "关注失败",
+		"unwatch_failed":         "取消关注失败",
+
+		// 项目
+		"project_not_found":          "项目不存在",
+		"project_invalid_id":         "无效的项目ID",
+		"project_list_failed":        "获取项目列表失败",
+		"project_stats_failed":       "获取项目统计失败",
+		"project_load_failed":        "加载项目失败",
+		"project_create_failed":      "创建项目失败",
+		"project_update_failed":      "更新项目失败",
+		"project_delete_failed":      "删除项目失败",
+		"project_archived":           "项目已归档",
+		"project_not_archived":       "项目未归档",
+		"project_archive_failed":     "归档项目失败",
+		"project_unarchive_failed":   "取消归档失败",
+		"project_branch_failed":      "更新默认分支失败",
+		"project_no_default_branch":  "远程仓库未返回默认分支",
+		"deploy_key_missing":         "项目没有部署密钥",
+		"deploy_key_not_created":     "项目尚未创建部署密钥",
+		"deploy_key_exists":          "项目已存在部署密钥，请使用轮换接口",
+		"deploy_key_no_pending":      "没有待确认的部署密钥",
+		"deploy_key_attach_failed":   "关联部署密钥失败",
+		"deploy_key_confirm_failed":  "确认部署密钥失败",
+		"deploy_key_revoke_failed":   "撤销部署密钥失败",
+		"deploy_key_replace_via_api": "部署密钥请通过项目部署密钥轮换替换",
+		"deploy_id_missing":          "缺少部署ID",
+
+		// SSH密钥
+		"ssh_key_not_found":          "SSH密钥不存在",
+		"ssh_key_create_failed":      "创建SSH密钥失败",
+		"ssh_key_update_failed":      "更新SSH密钥失败",
+		"ssh_key_delete_failed":      "删除SSH密钥失败",
+		"ssh_key_generate_failed":    "生成SSH密钥失败",
+		"ssh_key_in_use":             "SSH密钥仍被使用，请先替换或解除引用",
+		"ssh_key_references_failed":  "查询密钥引用失败",
+		"ssh_key_replace_failed":     "替换SSH密钥失败",
+		"ssh_key_replacement_absent": "替换用的SSH密钥不存在",
+		"ssh_key_replacement_kind":   "替换用的密钥必须是可用的通用密钥",
+		"ssh_key_replace_same":       "不能替换为同一个密钥",
+		"ssh_test_failed":            "SSH连接测试失败",
+
+		// 流水线
+		"pipeline_not_found":       "流水线不存在",
+		"pipeline_create_failed":   "创建流水线失败",
+		"pipeline_update_failed":   "更新流水线失败",
+		"pipeline_delete_failed":   "删除流水线失败",
+		"pipeline_start_failed":    "启动流水线失败",
+		"config_source_invalid":    "不支持的配置来源",
+		"pipeline_config_required": "流水线配置不能为空",
+		"prewarm_minutes_invalid":  "预热提前时间无效",
+		"schedules_query_failed":   "获取定时流水线失败",
+		"run_not_found":            "流水线运行记录不存在",
+		"run_id_missing":           "缺少运行ID",
+		"run_cancel_failed":        "取消流水线运行失败",
+		"run_rerun_failed":         "重跑失败步骤失败",
+		"run_rerun_unavailable":    "原运行未失败或工作区已过期，无法仅重跑失败步骤",
+		"run_inputs_unavailable":   "原运行没有记录使用的流水线版本，无法按原始输入重跑",
+		"run_retry_failed":         "重新运行失败",
+		"run_retry_skipped":        "被跳过的运行不能重新运行",
+		"run_logs_failed":          "获取日志失败",
+		"cursor_invalid":           "无效的分页游标",
+		"deployment_list_failed":   "获取部署记录失败",
+		"readme_not_found":         "README不存在",
+		"project_name_exists":      "项目名称已存在",
+		"target_not_found":         "部署目标不存在",
+		"target_ssh_key_missing":   "部署使用的SSH密钥不存在",
+		"redact_rule_invalid":      "脱敏规则无效",
+		"redact_rule_not_found":    "脱敏规则不存在",
+		"redact_rule_query_failed": "查询脱敏规则失败",
+		"redact_rule_save_failed":  "保存脱敏规则失败",
+		"redact_delete_failed":     "删除脱敏规则失败",
+		"run_redact_running":       "运行中的流水线不能重新脱敏",
+		"run_redact_failed":        "重新脱敏运行日志失败",
+		"queue_run_not_found":      "流水线运行不在等待队列中",
+		"queue_wait_not_found":     "部署锁等待项不存在",
+		"queue_update_failed":      "调整等待队列失败",
+		"mutex_group_undefined":    "项目未定义该互斥组",
+		"mutex_group_in_use":       "互斥组仍被流水线使用",
+		"concurrency_invalid":      "无效的并发策略",
+		"mutex_group_invalid":      "互斥组名称无效",
+		"concurrency_save_failed":  "保存并发策略失败",
+		"ext_wait_not_found":       "外部等待不存在",
+		"ext_wait_finished":        "外部等待已结束",
+		"ext_wait_update_failed":   "更新外部等待失败",
+		"ext_wait_query_failed":    "查询外部等待失败",
+		"callback_sig_invalid":     "回调签名校验失败",
+		"revision_not_found":       "流水线版本不存在",
+		"revision_no_previous":     "第 1 个版本没有前一个版本，请指定 against",
+		"audit_not_found":          "审计日志不存在",
+		"audit_no_diff":            "审计日志没有变更内容",
+		"step_not_found":           "流水线步骤不存在",
+		"debug_env_owner_only":     "只有项目所有者可以开启环境变量调试",
+		"step_env_decode_failed":   "解析步骤环境变量失败",
+		"flag_unknown":             "未定义的功能开关",
+		"flag_save_failed":         "保存功能开关失败",
+		"flag_query_failed":        "查询功能开关失败",
+		"source_not_archive":       "项目不是源码包项目",
+		"source_file_missing":      "缺少源码包文件",
+		"source_read_failed":       "读取上传内容失败",
+		"source_save_failed":       "保存源码包失败",
+		"source_too_large":         "源码包超过大小上限",
+		"source_bad_format":        "不支持的源码包格式，仅支持 zip、tar 与 tar.gz",
+		"source_multi_pipeline":    "项目有多条流水线，请指定 pipeline_id",
+		"source_archive_required":  "源码包项目需要上传源码包触发运行",
+		"pipeline_query_failed":    "查询流水线失败",
+		"pipeline_config_invalid":  "流水线配置无效",
+		"project_source_invalid":   "不支持的源码来源",
+		"project_git_url_required": "git 项目必须提供仓库地址",
+		"project_git_url_unused":   "源码包项目不使用仓库地址",
+		"compliance_job_not_found": "任务不存在",
+		"compliance_file_missing":  "导出文件不存在或尚未生成完成",
+		"compliance_delete_self":   "不能删除自己的数据",
+		"compliance_token_invalid": "确认令牌无效或已过期",
+		"compliance_job_running":   "该用户已有进行中的任务",
+		"compliance_start_failed":  "启动任务失败",
+		"request_timeout":          "请求处理超时",
+		"cron_invalid":             "定时表达式无效",
+		"outbound_list_failed":     "查询出站例外失败",
+		"outbound_invalid":         "出站例外无效",
+		"outbound_save_failed":     "保存出站例外失败",
+		"outbound_not_found":       "出站例外不存在",
+		"outbound_delete_failed":   "删除出站例外失败",
+		"run_label_invalid":        "运行标签无效",
+		"run_label_not_allowed":    "运行标签不在项目允许的标签中",
+		"run_label_rules_invalid":  "标签规则无效",
+		"run_label_policy_invalid": "无效的标签策略",
+		"label_rule_duplicate":     "标签的保留规则重复",
+		"run_label_policy_failed":  "保存标签策略失败",
+		"run_label_save_failed":    "保存运行标签失败",
+		"run_label_delete_failed":  "删除运行标签失败",
+		"run_label_not_found":      "运行标签不存在",
+		"event_sink_not_found":     "事件接收端不存在",
+		"event_replay_running":     "事件接收端已有正在进行的重放",
+		"event_replay_range":       "重放的开始时间应早于结束时间",
+		"event_replay_failed":      "重放事件失败",
+		"run_not_finished":         "运行尚未结束",
+		"workspace_gone":           "运行的工作区未保留或已清理",
+		"workspace_path_invalid":   "工作区路径无效",
+		"workspace_path_not_found": "工作区中不存在该路径",
+		"workspace_not_dir":        "路径不是目录",
+		"workspace_is_dir":         "路径是目录",
+		"workspace_file_too_large": "文件超过可查看的大小上限",
+		"workspace_read_failed":    "读取工作区失败",
+		"file_read_failed":         "读取文件失败",
+		"feed_token_list_failed":   "获取订阅令牌失败",
+		"feed_token_create_failed": "创建订阅令牌失败",
+		"feed_token_revoke_failed": "撤销订阅令牌失败",
+		"feed_token_revoked":       "订阅令牌已撤销",
+		"feed_token_invalid":       "无效的订阅令牌",
+		"feed_status_invalid":      "订阅只支持已结束的运行状态",
+		"feed_render_failed":       "生成订阅失败",
+		"run_list_failed":          "获取运行记录失败",
+		"artifact_share_invalid":   "被授权的项目无效",
+		"artifact_share_save_fail": "保存制品授权失败",
+		"artifact_share_not_found": "制品授权不存在",
+		"artifact_share_del_fail":  "删除制品授权失败",
+		"registration_load_failed": "读取注册策略失败",
+		"registration_from_config": "注册策略由配置文件指定",
+		"registration_invalid":     "不支持的注册策略",
+		"registration_no_domains":  "需配置允许注册的邮箱域名",
+		"registration_save_failed": "保存注册策略失败",
+		"invite_list_failed":       "获取注册邀请失败",
+		"invite_create_failed":     "创建注册邀请失败",
+		"invite_not_found":         "注册邀请不存在",
+		"invite_used":              "注册邀请已被使用",
+		"invite_revoke_failed":     "撤销注册邀请失败",
+		"invite_invalid":           "邀请无效或已过期",
+		"invite_email_mismatch":    "邀请不适用于该邮箱",
+		"registration_closed":      "注册已关闭",
+		"registration_invite_only": "注册需要邀请",
+		"registration_domain":      "邮箱域名不允许注册",
+		"email_unverified":         "邮箱尚未验证",
+		"email_verify_invalid":     "验证链接无效或已过期",
+		"email_verify_failed":      "验证邮箱失败",
+		"perf_threshold_invalid":   "性能回退阈值无效",
+		"bastion_key_invalid":      "跳板机使用的SSH密钥无效",
+		"log_usage_failed":         "获取日志存储占用失败",
+		"log_quota_invalid":        "日志存储配额无效",
+		"log_quota_save_failed":    "保存日志存储配额失败",
+		"log_archive_unavailable":  "日志已压缩归档，未配置日志归档存储",
+		"project_role_invalid":     "项目角色无效",
+		"already_project_member":   "已是项目成员",
+		"access_req_save_failed":   "保存加入申请失败",
+		"access_req_list_failed":   "获取加入申请失败",
+		"access_req_not_found":     "加入申请不存在",
+		"access_req_closed":        "加入申请已处理或已过期",
+		"access_req_decide_failed": "处理加入申请失败",
+		"members_list_failed":      "获取项目成员失败",
+		"member_not_found":         "项目成员不存在",
+		"member_remove_failed":     "移除项目成员失败",
+		"auto_approve_save_failed": "保存自动批准规则失败",
+		"resource_not_found":       "资源不存在",
+		"access_resource_invalid":  "resource 只能是 pipeline、pipeline_run 或 project",
+		"backup_running":           "已有进行中的备份",
+		"backup_start_failed":      "启动数据库备份失败",
+		"backup_list_failed":       "获取数据库备份失败",
+		"backup_id_invalid":        "无效的备份ID",
+		"backup_not_found":         "备份不存在",
+		"backup_file_missing":      "备份文件不存在",
+		"backup_read_failed":       "读取备份失败",
+		"repo_not_moved":           "远程未报告仓库迁移",
+		"repo_url_update_failed":   "更新仓库地址失败",
+		"env_list_failed":          "获取环境变量失败",
+		"env_create_failed":        "创建环境变量失败",
+		"env_update_failed":        "更新环境变量失败",
+		"env_delete_failed":        "删除环境变量失败",
+		"env_not_found":            "环境变量不存在",
+		"env_exists":               "环境变量已存在",
+		"env_name_invalid":         "环境变量名只能包含字母、数字和下划线，且不能以数字开头",
+		"release_list_failed":      "获取发布记录失败",
+		"run_id_invalid":           "无效的运行ID",
+		"log_ingest_token_missing": "缺少日志上报令牌",
+		"log_ingest_unauthorized":  "日志上报令牌无效或运行已结束",
+		"log_ingest_rate_limited":  "日志上报超过速率限制",
+		"log_ingest_too_large":     "日志批次超过大小限制",
+		"log_ingest_gzip_failed":   "解压日志失败",
+		"log_ingest_read_failed":   "读取日志失败",
+		"log_ingest_invalid":       "日志格式错误",
+		"log_ingest_failed":        "写入日志失败",
+		"policy_load_failed":       "获取项目策略失败",
+		"policy_save_failed":       "保存项目策略失败",
+		"policy_enforced_admin":    "只有管理员可以修改强制的项目策略",
+		"policy_invalid":           "策略无效",
+		"breaker_not_found":        "熔断目标不存在",
+		"ws_ticket_invalid":        "连接票据无效或已过期",
+		"deployment_not_found":     "部署记录不存在",
+		"ws_server_limit":          "WebSocket 连接数已达服务器上限",
+		"ws_user_limit":            "WebSocket 连接数已达上限",
+		"cloud_cred_duplicate":     "该环境已配置云平台角色",
+		"cloud_cred_save_failed":   "保存云平台角色失败",
+		"cloud_cred_not_found":     "云平台角色不存在",
+		"cloud_cred_delete_failed": "删除云平台角色失败",
+		"cloud_cred_invalid":       "云平台角色无效",
+		"skip_paths_invalid":       "跳过检查的路径规则无效",
+		"workers_load_failed":      "获取执行器状态失败",
+		"pipeline_env_undefined":   "流水线配置引用了未定义的环境变量",
+		"env_ignore_invalid":       "忽略的环境变量名无效",
+		"timeline_type_invalid":    "无效的动态类型",
+		"timeline_range_invalid":   "无效的时间范围",
+		"timeline_load_failed":     "获取项目动态失败",
+		"invalid_source_entry":     "无效的来源地址",
+		"webhook_ip_denied":        "Webhook来源地址不在允许范围内",
+		"webhook_sig_required":     "Webhook要求HMAC-SHA256签名",
+		"token_ip_denied":          "API令牌不允许从该地址使用",
+		"token_access_forbidden":   "API令牌不能修改令牌的来源限制",
+		"webhook_access_failed":    "保存Webhook来源限制失败",
+		"token_access_failed":      "保存API令牌来源限制失败",
+		"invalid_retention":        "无效的保留策略",
+		"retention_sim_failed":     "模拟保留策略失败",
+		"retention_prune_failed":   "按保留策略清理失败",
+		"list_runs_failed":         "获取流水线运行记录失败",
+		"unknown_shell":            "不支持的解释器",
+		"shell_unavailable":        "解释器在执行主机上不可用",
+		"debug_unavailable":        "调试终端不可用：流水线未开启失败调试、已超过调试时限或工作区已清理",
+		"debug_session_active":     "该运行已有打开的调试终端",
+		"debug_sandbox_off":        "未开启多租户隔离，不允许打开调试终端",
+		"debug_owner_only":         "只有项目所有者可以打开调试终端",
+		"debug_start_failed":       "打开调试终端失败",
+		"invalid_change_cursor":    "无效的变更游标",
+		"changes_failed":           "获取变更失败",
+		"invalid_io_class":         "不支持的 IO 调度类",
+		"invalid_process_nice":     "nice 值需在 -20 到 19 之间",
+		"invalid_io_level":         "IO 优先级需在 0 到 7 之间",
+		"export_too_many_rows":     "导出的记录超过上限，请缩小筛选范围",
+		"export_failed":            "导出失败",
+		"invalid_date_filter":      "日期格式无效，应为 YYYY-MM-DD",
+		"artifact_required":        "registry 项目需要指定部署的镜像或 tarball 制品",
+		"invalid_artifact":         "制品引用无效",
+		"registry_no_signature":    "镜像仓库 Webhook 不支持签名，使用请求头中的令牌校验",
+		"registry_webhook_only":    "只有 registry 项目可以接收镜像仓库的 Webhook",
+		"registry_type_required":   "registry 项目的 Webhook 需要指定镜像仓库类型（harbor 或 docker_registry）",
+		"registry_payload_invalid": "无法解析镜像仓库推送事件",
+		"registry_git_url_unused":  "registry 项目不使用仓库地址",
+		"image_repo_registry_only": "只有 registry 项目可以配置镜像仓库",
+		"image_repo_invalid":       "镜像仓库名格式错误",
+		"spike_factor_invalid":     "失败率告警倍数需大于 1，0 表示使用全局配置",
+		"spike_min_runs_negative":  "失败率告警最少运行数不能为负数",
+		"confirmation_required":    "该操作需要确认",
+		"confirmation_summary_err": "统计将被删除的数据失败",
+		"confirmation_name_diff":   "确认的资源名称与要删除的资源不一致",
+		"log_search_disabled":      "日志全文检索未开启",
+		"log_search_empty":         "检索内容不能为空",
+		"log_search_failed":        "检索运行日志失败",
+		"lint_blocked":             "流水线配置未通过检查",
+		"disk_usage_failed":        "获取磁盘使用情况失败",
+		"drift_query_failed":       "查询部署清单失败",
+		"drift_check_failed":       "检查部署漂移失败",
+		"support_bundle_failed":    "生成诊断包失败",
+		"support_bundle_not_found": "诊断包不存在",
+		"support_bundle_not_ready": "诊断包不存在或尚未生成完成",
+		"freeze_query_failed":      "查询部署冻结失败",
+		"freeze_scope_invalid":     "冻结范围无效",
+		"freeze_expiry_invalid":    "冻结到期时间无效",
+		"freeze_create_failed":     "创建部署冻结失败",
+		"freeze_id_invalid":        "无效的冻结ID",
+		"freeze_not_found":         "部署冻结不存在",
+		"freeze_already_lifted":    "部署冻结已解除",
+		"freeze_lift_failed":       "解除部署冻结失败",
+		"api_token_list_failed":    "获取API令牌失败",
+		"api_token_no_mint":        "API令牌不能创建新的令牌",
+		"api_token_create_failed":  "创建API令牌失败",
+		"api_token_id_invalid":     "无效的令牌ID",
+		"api_token_not_found":      "API令牌不存在",
+		"api_token_revoke_failed":  "撤销API令牌失败",
+		"artifact_not_found":       "制品不存在",
+		"artifact_data_missing":    "制品数据不存在",
+		"artifact_save_failed":     "保存制品失败",
+		"webhook_not_found":        "Webhook不存在",
+		"webhook_create_failed":    "创建Webhook失败",
+		"webhook_delete_failed":    "删除Webhook失败",
+		"webhook_rotate_failed":    "轮换密钥失败",
+		"webhook_overlap_negative": "重叠时间不能为负数",
+		"trigger_provider_invalid": "provider 只能是 github、gitea 或 gitlab",
+
+		// 共享资源
+		"shared_resource_not_found":   "共享资源不存在",
+		"shared_resource_exists":      "同名的共享资源已存在",
+		"shared_resource_invalid":     "共享资源无效",
+		"shared_version_not_found":    "共享资源版本不存在",
+		"shared_version_invalid":      "无效的版本号",
+		"shared_version_in_use":       "共享资源版本仍被保留的运行引用，不能删除",
+		"shared_version_latest":       "不能删除共享资源的最新版本",
+		"shared_version_deleted":      "共享资源版本已删除",
+		"shared_resource_save_failed": "保存共享资源失败",
+
+		// 上传
+		"upload_get_failed":   "获取上传文件失败",
+		"upload_read_failed":  "读取上传文件失败",
+		"upload_save_failed":  "保存文件失败",
+		"upload_bad_type":     "不支持的文件类型",
+		"upload_too_large_10": "文件大小不能超过10MB",
+		"upload_too_large_2":  "文件大小不能超过2MB",
+
+		// 运行日志系统行
+		"log.run_started":            "开始执行流水线: %s",
+		"log.workspace_restored":     "已从原运行恢复工作区",
+		"log.stage_started":          "执行阶段 %d: %s",
+		"log.stage_finished":         "阶段 %s 执行完成",
+		"log.stage_failed":           "阶段 %s 执行失败: %v",
+		"log.step_started":           "执行步骤: %s",
+		"log.step_reused":            "复用步骤: %s",
+		"log.warning":                "警告: %v",
+		"log.branch_missing":         "配置的分支 %s 在远程仓库中不存在，可用分支: %s",
+		"log.checkout_finished":      "代码拉取完成",
+		"log.run_finished":           "流水线执行完成，状态: %s，耗时: %v",
+		"log.run_cancelled":          "流水线运行已被取消",
+		"log.config_invalid":         "解析流水线配置失败: %v",
+		"log.shared_resource_failed": "解析共享资源失败: %v",
+		"log.restore_failed":         "恢复工作区失败: %v",
+		"log.disk_check_skipped":     "获取磁盘空间失败，跳过检查: %v",
+		"log.disk_size_unknown":      "无法预估仓库大小，工作区可用空间: %s",
+		"log.size_source_host":       "托管平台",
+		"log.size_source_hint":       "项目大小提示",
+		"log.disk_estimate":          "仓库预估大小: %s（来源: %s），需要约 %s（含安全余量），工作区可用空间: %s",
+
+		"log.repo_config_bootstrap": "引导步骤: 拉取代码并读取仓库配置文件 %s",
+		"log.repo_config_loaded":    "已读取配置文件 %s（提交 %s）",
+		"log.repo_config_problem":   "配置文件问题: %s",
+		"log.repo_config_failed":    "加载仓库配置失败: %v",
+		"log.drift_detected":        "警告: 部署目标 %s 自部署 #%d 后已被修改（%s），本次部署将覆盖这些修改",
+		"log.drift_check_failed":    "部署前漂移检查失败（%s）: %s",
+
+		"log.prewarm_caches_applied": "已使用预热暂存的依赖缓存 %d 个",
+		"log.deploy_frozen":          "部署已冻结（冻结 #%d）: %s，拒绝部署",
+		"log.source_extracted":       "已解压源码包 %s: %d 个文件，共 %d 字节",
+		"log.source_extract_failed":  "解压源码包失败: %v",
+		"log.script_lines_dropped":   "日志输出过快，共丢弃 %d 行",
+
+		"log.test_report_warning":  "测试报告警告: %s",
+		"log.test_report_summary":  "测试结果: 共 %d 个，通过 %d 个，失败 %d 个，跳过 %d 个",
+		"log.test_report_failures": "失败的测试: %s",
+
+		"log.preflight_check":  "部署前检查 %s: %s（%s）",
+		"log.preflight_passed": "部署目标 %s 通过部署前检查",
+
+		"log.run_queued":           "并发运行数已满，进入等待队列第 %d 位",
+		"log.run_queued_project":   "项目并发运行数已达上限 %d，进入等待队列第 %d 位",
+		"log.run_queued_group":     "互斥组 %s 中的运行 #%d 正在执行，进入等待队列第 %d 位",
+		"log.run_queued_workspace": "项目工作区正被运行 #%d 使用，进入等待队列第 %d 位",
+		"log.run_dequeued":         "排队 %v 后开始执行",
+		"log.run_promoted":         "管理员已将本次运行提升到等待队列队首",
+		"log.deploy_lock_waiting":  "部署目标 %s 正在被其他运行部署，排在等待队列第 %d 位",
+
+		"log.remote_command":      "在 %s 执行: %s",
+		"log.remote_command_done": "%s 上的命令执行完成，耗时 %v",
+
+		"log.external_wait_started": "等待外部回调: %s，回调地址 %s，超时时间 %s",
+		"log.external_wait_request": "已发起外部作业请求 %s %s，响应状态 %d",
+		"log.external_wait_done":    "外部等待结束: %s（%s）%s",
+		"log.external_wait_resumed": "服务重启后恢复等待外部回调: %s",
+
+		"log.raw_output_saved":       "完整输出已保存为制品 %s（%d 字节）",
+		"log.raw_output_failed":      "保存完整输出失败: %v",
+		"log.raw_output_unavailable": "未配置制品存储，不保存完整输出",
+
+		"log.repo_moved":              "远程报告仓库已迁移到 %s，请确认后在项目中接受新地址",
+		"log.repo_not_found_repeated": "连续 %d 次运行拉取代码时远程报告仓库不存在，项目已标记为异常",
+
+		"log.env_conflict": "警告: 环境变量 %s 在多个来源层中取值不同，生效来源: %s，被覆盖的来源: %s",
+
+		"log.release_recorded":         "已生成发布记录 #%d（环境 %s，%d 个提交）",
+		"log.release_range_unknown":    "无法确定上次发布 %s 与本次提交 %s 之间的提交范围（可能被强制推送覆盖），发布记录不列出提交",
+		"log.release_failed":           "生成发布记录失败: %v",
+		"log.run_timed_out":            "运行超过策略的超时时间 %d 分钟，已中止",
+		"log.retry_attempt":            "请求 %s 第 %d 次失败: %v，%s 后重试",
+		"log.circuit_tripped":          "%s 连续失败，已熔断，冷却期间不再发起请求: %v",
+		"log.circuit_open":             "%s 已熔断，未发起请求，%d 秒后放行试探请求",
+		"log.circuit_probe":            "%s 熔断冷却结束，发起试探请求",
+		"log.circuit_recovered":        "%s 试探请求成功，熔断已恢复",
+		"log.cloud_credential_issued":  "已扮演角色 %s，临时凭证有效至 %s",
+		"log.cloud_credential_failed":  "获取云平台临时凭证失败: %v",
+		"log.run_unchanged":            "与上次成功运行相比没有变化，跳过执行: %s",
+		"log.unchanged_inputs_changed": "配置或环境变量与上次成功运行 #%d 不同，继续执行",
+		"log.unchanged_check_failed":   "无法确定是否有变化，继续执行: %v",
+		"log.run_queued_worker":        "已交给独立执行器，等待领取",
+		"log.run_claimed":              "执行器 %s 已领取运行",
+		"log.run_claimed_local":        "没有可用的执行器，由API实例执行",
+		"log.run_finished_reason":      "流水线执行完成，状态: %s（原因: %s），耗时: %v",
+		"log.run_cancel_reason":        "取消原因: %s",
+		"log.env_undefined_ref":        "未定义的环境变量: %s",
+		"log.verify_started":           "部署目标 %s 开始部署后验证：%d 项检查，等待 %d 秒后开始",
+		"log.verify_attempt_failed":    "验证 %s 第 %d/%d 次未通过: %v",
+		"log.verify_passed":            "部署目标 %s 通过部署后验证",
+		"log.verify_rollback":          "部署目标 %s 未通过部署后验证，执行回滚命令",
+		"log.verify_rollback_failed":   "部署目标 %s 回滚失败: %v",
+		"log.verify_rolled_back":       "部署目标 %s 已回滚",
+		"log.debug_available":          "调试终端可用至 %s",
+		"log.debug_no_sandbox":         "未开启多租户隔离，不提供调试终端",
+		"log.debug_remote":             "运行在独立执行器上执行，不提供调试终端",
+		"log.debug_started":            "%s 打开了调试终端，最迟 %s 结束",
+		"log.debug_ended":              "调试终端已结束",
+		"log.debug_expired":            "已到调试时限，调试终端已强制结束",
+		"log.priority_not_applied":     "未能应用步骤进程的调度优先级，步骤照常执行: %s",
+
+		"log.artifact_fetched": "已取出制品 %s（来自流水线 %s 的运行 #%d，sha256 %s）到 %s",
+	},
+	"en-US": {
+		"invalid_request":      "Invalid request",
+		"invalid_params":       "Invalid request parameters",
+		"permission_denied":    "Permission denied",
+		"owner_or_admin_only":  "Only the project owner or an administrator can perform this action",
+		"database_query_error": "Database query failed",
+		"read_body_failed":     "Failed to read request body",
+
+		"auth_missing":          "Authentication required",
+		"auth_invalid_format":   "Invalid authorization format",
+		"auth_invalid_token":    "Invalid authentication token",
+		"auth_token_missing":    "Missing authentication token",
+		"auth_token_invalid":    "Invalid token",
+		"auth_token_failed":     "Failed to generate token",
+		"auth_bad_credentials":  "Incorrect username or password",
+		"auth_account_disabled": "User account is disabled",
+
+		"user_not_found":         "User not found",
+		"user_invalid_id":        "Invalid user ID",
+		"user_list_failed":       "Failed to list users",
+		"user_create_failed":     "Failed to create user",
+		"user_update_failed":     "Failed to update user",
+		"user_delete_failed":     "Failed to delete user",
+		"user_username_taken":    "Username already exists",
+		"user_email_taken":       "Email already exists",
+		"user_email_in_use":      "Email is already used by another user",
+		"user_password_failed":   "Failed to hash password",
+		"user_role_not_found":    "Role not found",
+		"user_invalid_locale":    "Unsupported language",
+		"notify_invalid_channel": "Invalid notification channel",
+		"notify_pref_failed":     "Failed to update notification preferences",
+		"notify_not_found":       "Notification not found",
+		"notify_mark_failed":     "Failed to mark as read",
+		"watch_failed":           "Failed to watch",
+		"unwatch_failed":         "Failed to unwatch",
+
+		"project_not_found":          "Project not found",
+		"project_invalid_id":         "Invalid project ID",
+		"project_list_failed":        "Failed to list projects",
+		"project_stats_failed":       "Failed to get project statistics",
+		"project_load_failed":        "Failed to load project",
+		"project_create_failed":      "Failed to create project",
+		"project_update_failed":      "Failed to update project",
+		"project_delete_failed":      "Failed to delete project",
+		"project_archived":           "Project is archived",
+		"project_not_archived":       "Project is not archived",
+		"project_archive_failed":     "Failed to archive project",
+		"project_unarchive_failed":   "Failed to unarchive project",
+		"project_branch_failed":      "Failed to update default branch",
+		"project_no_default_branch":  "Remote repository did not report a default branch",
+		"deploy_key_missing":         "Project has no deploy key",
+		"deploy_key_not_created":     "Project has not created a deploy key yet",
+		"deploy_key_exists":          "Project already has a deploy key, use the rotate endpoint",
+		"deploy_key_no_pending":      "No pending deploy key to confirm",
+		"deploy_key_attach_failed":   "Failed to attach deploy key",
+		"deploy_key_confirm_failed":  "Failed to confirm deploy key",
+		"deploy_key_revoke_failed":   "Failed to revoke deploy key",
+		"deploy_key_replace_via_api": "Deploy keys must be replaced through project deploy key rotation",
+		"deploy_id_missing":          "Missing deployment ID",
+
+		"ssh_key_not_found":          "SSH key not found",
+		"ssh_key_create_failed":      "Failed to create SSH key",
+		"ssh_key_update_failed":      "Failed to update SSH key",
+		"ssh_key_delete_failed":      "Failed to delete SSH key",
+		"ssh_key_generate_failed":    "Failed to generate SSH key",
+		"ssh_key_in_use":             "SSH key is still in use, replace or remove its references first",
+		"ssh_key_references_failed":  "Failed to query key references",
+		"ssh_key_replace_failed":     "Failed to replace SSH key",
+		"ssh_key_replacement_absent": "Replacement SSH key not found",
+		"ssh_key_replacement_kind":   "Replacement must be an active general-purpose key",
+		"ssh_key_replace_same":       "Cannot replace a key with itself",
+		"ssh_test_failed":            "SSH connection test failed",
+
+		"pipeline_not_found":       "Pipeline not found",
+		"pipeline_create_failed":   "Failed to create pipeline",
+		"pipeline_update_failed":   "Failed to update pipeline",
+		"pipeline_delete_failed":   "Failed to delete pipeline",
+		"pipeline_start_failed":    "Failed to start pipeline",
+		"config_source_invalid":    "Unsupported config source",
+		"pipeline_config_required": "Pipeline configuration is required",
+		"prewarm_minutes_invalid":  "Invalid pre-warm lead time",
+		"schedules_query_failed":   "Failed to get scheduled pipelines",
+		"run_not_found":            "Pipeline run not found",
+		"run_id_missing":           "Missing run ID",
+		"run_cancel_failed":        "Failed to cancel pipeline run",
+		"run_rerun_failed":         "Failed to rerun failed steps",
+		"run_rerun_unavailable":    "The original run did not fail or its workspace has expired, cannot rerun failed steps only",
+		"run_inputs_unavailable":   "The original run has no recorded pipeline revision and cannot be re-run with its original inputs",
+		"run_retry_failed":         "Failed to retry the run",
+		"run_retry_skipped":        "A skipped run cannot be retried",
+		"run_logs_failed":          "Failed to get logs",
+		"cursor_invalid":           "Invalid pagination cursor",
+		"deployment_list_failed":   "Failed to get deployments",
+		"readme_not_found":         "README not found",
+		"project_name_exists":      "A project with this name already exists",
+		"target_not_found":         "Deploy target not found",
+		"target_ssh_key_missing":   "The SSH key used by this deploy target no longer exists",
+		"redact_rule_invalid":      "Invalid redaction rule",
+		"redact_rule_not_found":    "Redaction rule not found",
+		"redact_rule_query_failed": "Failed to query redaction rules",
+		"redact_rule_save_failed":  "Failed to save redaction rule",
+		"redact_delete_failed":     "Failed to delete redaction rule",
+		"run_redact_running":       "Cannot re-redact a run that is still running",
+		"run_redact_failed":        "Failed to re-redact run logs",
+		"queue_run_not_found":      "Pipeline run is not in the queue",
+		"queue_wait_not_found":     "Deploy lock wait not found",
+		"queue_update_failed":      "Failed to update the queue",
+		"mutex_group_undefined":    "The mutex group is not defined on the project",
+		"mutex_group_in_use":       "The mutex group is still used by pipelines",
+		"concurrency_invalid":      "Invalid concurrency policy",
+		"mutex_group_invalid":      "Invalid mutex group name",
+		"concurrency_save_failed":  "Failed to save the concurrency policy",
+		"ext_wait_not_found":       "External wait not found",
+		"ext_wait_finished":        "External wait has already finished",
+		"ext_wait_update_failed":   "Failed to update the external wait",
+		"ext_wait_query_failed":    "Failed to query external waits",
+		"callback_sig_invalid":     "Callback signature verification failed",
+		"revision_not_found":       "Pipeline revision not found",
+		"revision_no_previous":     "Revision 1 has no previous revision; specify against",
+		"audit_not_found":          "Audit log not found",
+		"audit_no_diff":            "Audit log has no recorded changes",
+		"step_not_found":           "Pipeline step not found",
+		"debug_env_owner_only":     "Only the project owner can enable env debugging",
+		"step_env_decode_failed":   "Failed to decode the step environment",
+		"flag_unknown":             "Unknown feature flag",
+		"flag_save_failed":         "Failed to save the feature flag",
+		"flag_query_failed":        "Failed to query feature flags",
+		"source_not_archive":       "Project does not use archive sources",
+		"source_file_missing":      "Source archive file is missing",
+		"source_read_failed":       "Failed to read the upload",
+		"source_save_failed":       "Failed to save source archive",
+		"source_too_large":         "Source archive exceeds the size limit",
+		"source_bad_format":        "Unsupported source archive format, only zip, tar and tar.gz are supported",
+		"source_multi_pipeline":    "Project has several pipelines, please specify pipeline_id",
+		"source_archive_required":  "Archive source projects run from an uploaded source archive",
+		"pipeline_query_failed":    "Failed to query pipelines",
+		"pipeline_config_invalid":  "Invalid pipeline configuration",
+		"project_source_invalid":   "Unsupported project source type",
+		"project_git_url_required": "Git projects require a repository URL",
+		"project_git_url_unused":   "Archive source projects do not use a repository URL",
+		"compliance_job_not_found": "Job not found",
+		"compliance_file_missing":  "Export file not found or not ready yet",
+		"compliance_delete_self":   "You cannot delete your own data",
+		"compliance_token_invalid": "Confirmation token is invalid or expired",
+		"compliance_job_running":   "A job is already running for this user",
+		"compliance_start_failed":  "Failed to start job",
+		"request_timeout":          "Request handling timed out",
+		"cron_invalid":             "Invalid cron expression",
+		"outbound_list_failed":     "Failed to query outbound exceptions",
+		"outbound_invalid":         "Invalid outbound exception",
+		"outbound_save_failed":     "Failed to save outbound exception",
+		"outbound_not_found":       "Outbound exception not found",
+		"outbound_delete_failed":   "Failed to delete outbound exception",
+		"run_label_invalid":        "Invalid run label",
+		"run_label_not_allowed":    "Run label is not allowed in this project",
+		"run_label_rules_invalid":  "Invalid label rules",
+		"run_label_policy_invalid": "Invalid label policy",
+		"label_rule_duplicate":     "Duplicate retention rule for label",
+		"run_label_policy_failed":  "Failed to save label policy",
+		"run_label_save_failed":    "Failed to save run labels",
+		"run_label_delete_failed":  "Failed to delete run label",
+		"run_label_not_found":      "Run label not found",
+		"event_sink_not_found":     "Event sink not found",
+		"event_replay_running":     "A replay is already running for this event sink",
+		"event_replay_range":       "Replay start time must be before the end time",
+		"event_replay_failed":      "Failed to replay events",
+		"run_not_finished":         "Run has not finished",
+		"workspace_gone":           "Run workspace was not retained or has been cleaned up",
+		"workspace_path_invalid":   "Invalid workspace path",
+		"workspace_path_not_found": "Path not found in workspace",
+		"workspace_not_dir":        "Path is not a directory",
+		"workspace_is_dir":         "Path is a directory",
+		"workspace_file_too_large": "File exceeds the viewable size limit",
+		"workspace_read_failed":    "Failed to read workspace",
+		"file_read_failed":         "Failed to read file",
+		"feed_token_list_failed":   "Failed to load feed token",
+		"feed_token_create_failed": "Failed to create feed token",
+		"feed_token_revoke_failed": "Failed to revoke feed token",
+		"feed_token_revoked":       "Feed token revoked",
+		"feed_token_invalid":       "Invalid feed token",
+		"feed_status_invalid":      "Feeds only support finished run statuses",
+		"feed_render_failed":       "Failed to render feed",
+		"run_list_failed":          "Failed to load runs",
+		"artifact_share_invalid":   "Invalid consumer project",
+		"artifact_share_save_fail": "Failed to save artifact share",
+		"artifact_share_not_found": "Artifact share not found",
+		"artifact_share_del_fail":  "Failed to delete artifact share",
+		"registration_load_failed": "Failed to load registration policy",
+		"registration_from_config": "Registration policy is set in the configuration file",
+		"registration_invalid":     "Unsupported registration policy",
+		"registration_no_domains":  "Allowed email domains are required",
+		"registration_save_failed": "Failed to save registration policy",
+		"invite_list_failed":       "Failed to load invites",
+		"invite_create_failed":     "Failed to create invite",
+		"invite_not_found":         "Invite not found",
+		"invite_used":              "Invite has already been used",
+		"invite_revoke_failed":     "Failed to revoke invite",
+		"invite_invalid":           "Invite is invalid or expired",
+		"invite_email_mismatch":    "Invite is not valid for this email address",
+		"registration_closed":      "Registration is closed",
+		"registration_invite_only": "Registration requires an invite",
+		"registration_domain":      "Email domain is not allowed to register",
+		"email_unverified":         "Email address has not been verified",
+		"email_verify_invalid":     "Verification link is invalid or expired",
+		"email_verify_failed":      "Failed to verify email address",
+		"perf_threshold_invalid":   "Invalid performance regression threshold",
+		"bastion_key_invalid":      "Invalid SSH key for bastion host",
+		"log_usage_failed":         "Failed to get log storage usage",
+		"log_quota_invalid":        "Invalid log storage quota",
+		"log_quota_save_failed":    "Failed to save log storage quota",
+		"log_archive_unavailable":  "Log is archived but no log archive store is configured",
+		"project_role_invalid":     "Invalid project role",
+		"already_project_member":   "Already a member of the project",
+		"access_req_save_failed":   "Failed to save access request",
+		"access_req_list_failed":   "Failed to load access requests",
+		"access_req_not_found":     "Access request not found",
+		"access_req_closed":        "Access request has already been decided or has expired",
+		"access_req_decide_failed": "Failed to process access request",
+		"members_list_failed":      "Failed to load project members",
+		"member_not_found":         "Project member not found",
+		"member_remove_failed":     "Failed to remove project member",
+		"auto_approve_save_failed": "Failed to save auto-approval rules",
+		"resource_not_found":       "Resource not found",
+		"access_resource_invalid":  "resource must be pipeline, pipeline_run or project",
+		"backup_running":           "A backup is already running",
+		"backup_start_failed":      "Failed to start database backup",
+		"backup_list_failed":       "Failed to get database backups",
+		"backup_id_invalid":        "Invalid backup ID",
+		"backup_not_found":         "Backup not found",
+		"backup_file_missing":      "Backup file not found",
+		"backup_read_failed":       "Failed to read backup",
+		"repo_not_moved":           "Remote has not reported a repository move",
+		"repo_url_update_failed":   "Failed to update repository URL",
+		"env_list_failed":          "Failed to load environment variables",
+		"env_create_failed":        "Failed to create environment variable",
+		"env_update_failed":        "Failed to update environment variable",
+		"env_delete_failed":        "Failed to delete environment variable",
+		"env_not_found":            "Environment variable not found",
+		"env_exists":               "Environment variable already exists",
+		"env_name_invalid":         "Environment variable names may only contain letters, digits and underscores, and must not start with a digit",
+		"release_list_failed":      "Failed to load releases",
+		"run_id_invalid":           "Invalid run ID",
+		"log_ingest_token_missing": "Missing log ingestion token",
+		"log_ingest_unauthorized":  "Log ingestion token is invalid or the run has finished",
+		"log_ingest_rate_limited":  "Log ingestion rate limit exceeded",
+		"log_ingest_too_large":     "Log batch exceeds the size limit",
+		"log_ingest_gzip_failed":   "Failed to decompress logs",
+		"log_ingest_read_failed":   "Failed to read logs",
+		"log_ingest_invalid":       "Invalid log format",
+		"log_ingest_failed":        "Failed to write logs",
+		"policy_load_failed":       "Failed to load project policy",
+		"policy_save_failed":       "Failed to save project policy",
+		"policy_enforced_admin":    "Only administrators can change enforced project policies",
+		"policy_invalid":           "Invalid policy",
+		"breaker_not_found":        "Circuit breaker destination not found",
+		"ws_ticket_invalid":        "Connection ticket is invalid or expired",
+		"deployment_not_found":     "Deployment not found",
+		"ws_server_limit":          "The server has reached its WebSocket connection limit",
+		"ws_user_limit":            "Too many concurrent WebSocket connections",
+		"cloud_cred_duplicate":     "A cloud role is already configured for this environment",
+		"cloud_cred_save_failed":   "Failed to save cloud role",
+		"cloud_cred_not_found":     "Cloud role not found",
+		"cloud_cred_delete_failed": "Failed to delete cloud role",
+		"cloud_cred_invalid":       "Invalid cloud role",
+		"skip_paths_invalid":       "Invalid skip_paths pattern",
+		"workers_load_failed":      "Failed to load worker status",
+		"pipeline_env_undefined":   "Pipeline configuration references undefined environment variables",
+		"env_ignore_invalid":       "Invalid ignored environment variable name",
+		"timeline_type_invalid":    "Invalid timeline entry type",
+		"timeline_range_invalid":   "Invalid time range",
+		"timeline_load_failed":     "Failed to load project timeline",
+		"invalid_source_entry":     "Invalid source address",
+		"webhook_ip_denied":        "Webhook source address is not allowed",
+		"webhook_sig_required":     "Webhook requires an HMAC-SHA256 signature",
+		"token_ip_denied":          "API token cannot be used from this address",
+		"token_access_forbidden":   "API tokens cannot change token source restrictions",
+		"webhook_access_failed":    "Failed to save webhook source restrictions",
+		"token_access_failed":      "Failed to save API token source restrictions",
+		"invalid_retention":        "Invalid retention policy",
+		"retention_sim_failed":     "Failed to simulate retention policy",
+		"retention_prune_failed":   "Failed to apply the retention policy",
+		"list_runs_failed":         "Failed to list pipeline runs",
+		"unknown_shell":            "Unsupported shell",
+		"shell_unavailable":        "Shell is not available on the executing host",
+		"debug_unavailable":        "Debug session unavailable: debug_on_failure is off, the time limit has passed or the workspace was cleaned up",
+		"debug_session_active":     "A debug session is already open for this run",
+		"debug_sandbox_off":        "Debug sessions are not allowed while multi-tenant isolation is disabled",
+		"debug_owner_only":         "Only the project owner can open a debug session",
+		"debug_start_failed":       "Failed to open debug session",
+		"invalid_change_cursor":    "Invalid change cursor",
+		"changes_failed":           "Failed to fetch changes",
+		"invalid_io_class":         "Unsupported IO scheduling class",
+		"invalid_process_nice":     "Nice value must be between -20 and 19",
+		"invalid_io_level":         "IO priority must be between 0 and 7",
+		"export_too_many_rows":     "Too many records to export, narrow the filters",
+		"export_failed":            "Export failed",
+		"invalid_date_filter":      "Invalid date, expected YYYY-MM-DD",
+		"artifact_required":        "Registry projects require an image or tarball artifact to deploy",
+		"invalid_artifact":         "Invalid artifact reference",
+		"registry_no_signature":    "Registry webhooks are not signed; they are verified with the token in the request header",
+		"registry_webhook_only":    "Only registry projects can receive registry webhooks",
+		"registry_type_required":   "Webhooks for registry projects require a registry type (harbor or docker_registry)",
+		"registry_payload_invalid": "Unable to parse the registry push event",
+		"registry_git_url_unused":  "Registry projects do not use a repository URL",
+		"image_repo_registry_only": "Only registry projects can configure an image repository",
+		"image_repo_invalid":       "Invalid image repository name",
+		"spike_factor_invalid":     "Failure spike factor must be greater than 1; 0 uses the global setting",
+		"spike_min_runs_negative":  "Failure spike minimum runs cannot be negative",
+		"confirmation_required":    "This operation requires confirmation",
+		"confirmation_summary_err": "Failed to summarize the data to be deleted",
+		"confirmation_name_diff":   "The confirmed name does not match the resource being deleted",
+		"log_search_disabled":      "Run log full-text search is not enabled",
+		"log_search_empty":         "The search query must not be empty",
+		"log_search_failed":        "Failed to search run logs",
+		"lint_blocked":             "The pipeline configuration failed lint checks",
+		"disk_usage_failed":        "Failed to get disk usage",
+		"drift_query_failed":       "Failed to query deployment manifests",
+		"drift_check_failed":       "Failed to check deployment drift",
+		"support_bundle_failed":    "Failed to generate support bundle",
+		"support_bundle_not_found": "Support bundle not found",
+		"support_bundle_not_ready": "Support bundle not found or not ready yet",
+		"freeze_query_failed":      "Failed to query deploy freezes",
+		"freeze_scope_invalid":     "Invalid freeze scope",
+		"freeze_expiry_invalid":    "Invalid freeze expiry time",
+		"freeze_create_failed":     "Failed to create deploy freeze",
+		"freeze_id_invalid":        "Invalid freeze ID",
+		"freeze_not_found":         "Deploy freeze not found",
+		"freeze_already_lifted":    "Deploy freeze has already been lifted",
+		"freeze_lift_failed":       "Failed to lift deploy freeze",
+		"api_token_list_failed":    "Failed to get API tokens",
+		"api_token_no_mint":        "An API token cannot create new tokens",
+		"api_token_create_failed":  "Failed to create API token",
+		"api_token_id_invalid":     "Invalid token ID",
+		"api_token_not_found":      "API token not found",
+		"api_token_revoke_failed":  "Failed to revoke API token",
+		"artifact_not_found":       "Artifact not found",
+		"artifact_data_missing":    "Artifact data not found",
+		"artifact_save_failed":     "Failed to save artifact",
+		"webhook_not_found":        "Webhook not found",
+		"webhook_create_failed":    "Failed to create webhook",
+		"webhook_delete_failed":    "Failed to delete webhook",
+		"webhook_rotate_failed":    "Failed to rotate secret",
+		"webhook_overlap_negative": "Overlap time cannot be negative",
+		"trigger_provider_invalid": "provider must be github, gitea or gitlab",
+
+		"shared_resource_not_found":   "Shared resource not found",
+		"shared_resource_exists":      "A shared resource with this name already exists",
+		"shared_resource_invalid":     "Invalid shared resource",
+		"shared_version_not_found":    "Shared resource version not found",
+		"shared_version_invalid":      "Invalid version number",
+		"shared_version_in_use":       "The shared resource version is still referenced by retained runs and cannot be deleted",
+		"shared_version_latest":       "The latest version of a shared resource cannot be deleted",
+		"shared_version_deleted":      "Shared resource version deleted",
+		"shared_resource_save_failed": "Failed to save shared resource",
+
+		"upload_get_failed":   "Failed to get uploaded file",
+		"upload_read_failed":  "Failed to read uploaded file",
+		"upload_save_failed":  "Failed to save file",
+		"upload_bad_type":     "Unsupported file type",
+		"upload_too_large_10": "File size cannot exceed 10MB",
+		"upload_too_large_2":  "File size cannot exceed 2MB",
+
+		"log.run_started":            "Starting pipeline: %s",
+		"log.workspace_restored":     "Workspace restored from the original run",
+		"log.stage_started":          "Running stage %d: %s",
+		"log.stage_finished":         "Stage %s finished",
+		"log.stage_failed":           "Stage %s failed: %v",
+		"log.step_started":           "Running step: %s",
+		"log.step_reused":            "Reusing step: %s",
+		"log.warning":                "Warning: %v",
+		"log.branch_missing":         "Configured branch %s does not exist in the remote repository, available branches: %s",
+		"log.checkout_finished":      "Checkout finished",
+		"log.run_finished":           "Pipeline finished, status: %s, duration: %v",
+		"log.run_cancelled":          "Pipeline run was cancelled",
+		"log.config_invalid":         "Failed to parse pipeline configuration: %v",
+		"log.shared_resource_failed": "Failed to resolve shared resources: %v",
+		"log.restore_failed":         "Failed to restore workspace: %v",
+		"log.disk_check_skipped":     "Failed to get disk space, skipping check: %v",
+		"log.disk_size_unknown":      "Unable to estimate repository size, free workspace space: %s",
+		"log.size_source_host":       "hosting provider",
+		"log.size_source_hint":       "project size hint",
+		"log.disk_estimate":          "Estimated repository size: %s (source: %s), about %s required including safety margin, free workspace space: %s",
+
+		"log.repo_config_bootstrap": "Bootstrap: fetching code and reading repository config file %s",
+		"log.repo_config_loaded":    "Loaded config file %s (commit %s)",
+		"log.repo_config_problem":   "Config file problem: %s",
+		"log.repo_config_failed":    "Failed to load repository config: %v",
+		"log.drift_detected":        "Warning: deploy target %s was modified after deployment #%d (%s), this deploy will overwrite those changes",
+		"log.drift_check_failed":    "Pre-deploy drift check failed (%s): %s",
+
+		"log.prewarm_caches_applied": "Applied %d dependency caches staged by pre-warm",
+		"log.deploy_frozen":          "Deploys are frozen (freeze #%d): %s, deploy rejected",
+		"log.source_extracted":       "Extracted source archive %s: %d files, %d bytes",
+		"log.source_extract_failed":  "Failed to extract source archive: %v",
+		"log.script_lines_dropped":   "Script output was too fast to log, %d lines dropped",
+
+		"log.test_report_warning":  "Test report warning: %s",
+		"log.test_report_summary":  "Test results: %d total, %d passed, %d failed, %d skipped",
+		"log.test_report_failures": "Failed tests: %s",
+
+		"log.preflight_check":  "Preflight check %s: %s (%s)",
+		"log.preflight_passed": "Deploy target %s passed preflight checks",
+
+		"log.run_queued":           "Concurrency limit reached, queued at position %d",
+		"log.run_queued_project":   "Project concurrency limit of %d reached, queued at position %d",
+		"log.run_queued_group":     "Mutex group %s is held by running run #%d, queued at position %d",
+		"log.run_queued_workspace": "Project workspace is in use by run #%d, queued at position %d",
+		"log.run_dequeued":         "Starting after waiting %v in the queue",
+		"log.run_promoted":         "An administrator moved this run to the front of the queue",
+		"log.deploy_lock_waiting":  "Deploy target %s is being deployed by another run, waiting at position %d",
+
+		"log.remote_command":      "Running on %s: %s",
+		"log.remote_command_done": "Command on %s finished in %v",
+
+		"log.external_wait_started": "Waiting for external callback: %s, callback URL %s, times out at %s",
+		"log.external_wait_request": "Sent external job request %s %s, response status %d",
+		"log.external_wait_done":    "External wait finished: %s (%s) %s",
+		"log.external_wait_resumed": "Resumed waiting for external callback after restart: %s",
+
+		"log.raw_output_saved":       "Full output saved as artifact %s (%d bytes)",
+		"log.raw_output_failed":      "Failed to save full output: %v",
+		"log.raw_output_unavailable": "Artifact storage is not configured, full output not saved",
+
+		"log.repo_moved":              "Remote reports the repository moved to %s; accept the new URL on the project after confirming",
+		"log.repo_not_found_repeated": "Remote reported the repository as not found for %d consecutive runs; the project is marked unhealthy",
+
+		"log.env_conflict": "Warning: environment variable %s has different values in several layers; winner: %s, overridden: %s",
+
+		"log.release_recorded":         "Release #%d recorded (environment %s, %d commits)",
+		"log.release_range_unknown":    "Cannot determine the commit range between the previous release %s and commit %s (history may have been force-pushed); the release lists no commits",
+		"log.release_failed":           "Failed to record release: %v",
+		"log.run_timed_out":            "Run exceeded the policy timeout of %d minutes and was stopped",
+		"log.retry_attempt":            "Request to %s failed on attempt %d: %v, retrying in %s",
+		"log.circuit_tripped":          "Circuit opened for %s after consecutive failures; no requests until cool-down ends: %v",
+		"log.circuit_open":             "Circuit open for %s, request not sent; probe allowed in %d seconds",
+		"log.circuit_probe":            "Circuit cool-down for %s ended, sending probe request",
+		"log.circuit_recovered":        "Probe request to %s succeeded, circuit closed",
+		"log.cloud_credential_issued":  "Assumed role %s, temporary credentials valid until %s",
+		"log.cloud_credential_failed":  "Failed to obtain temporary cloud credentials: %v",
+		"log.run_unchanged":            "Nothing changed since the last successful run, skipping: %s",
+		"log.unchanged_inputs_changed": "Config or environment differs from last successful run #%d, running",
+		"log.unchanged_check_failed":   "Could not determine whether anything changed, running: %v",
+		"log.run_queued_worker":        "Queued for an external worker",
+		"log.run_claimed":              "Claimed by worker %s",
+		"log.run_claimed_local":        "No worker available, running on the API instance",
+		"log.run_finished_reason":      "Pipeline finished, status: %s (reason: %s), duration: %v",
+		"log.run_cancel_reason":        "Cancellation reason: %s",
+		"log.env_undefined_ref":        "Undefined environment variable: %s",
+		"log.verify_started":           "Verifying deploy target %s: %d checks, starting after %d seconds",
+		"log.verify_attempt_failed":    "Check %s failed (attempt %d/%d): %v",
+		"log.verify_passed":            "Deploy target %s passed post-deploy verification",
+		"log.verify_rollback":          "Deploy target %s failed post-deploy verification, running rollback commands",
+		"log.verify_rollback_failed":   "Rollback on deploy target %s failed: %v",
+		"log.verify_rolled_back":       "Deploy target %s rolled back",
+		"log.debug_available":          "Debug session available until %s",
+		"log.debug_no_sandbox":         "Multi-tenant isolation is disabled, no debug session is offered",
+		"log.debug_remote":             "Run executed on a remote worker, no debug session is offered",
+		"log.debug_started":            "%s opened a debug session, ending at %s at the latest",
+		"log.debug_ended":              "Debug session ended",
+		"log.debug_expired":            "Debug time limit reached, debug session terminated",
+		"log.priority_not_applied":     "Could not apply process priority, step continues: %s",
+
+		"log.artifact_fetched": "Fetched artifact %s (pipeline %s, run #%d, sha256 %s) to %s",
+	},
+}
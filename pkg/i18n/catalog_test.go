@@ -0,0 +1,52 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestCatalogComplete 每个消息键在所有语言中都有文本
+func TestCatalogComplete(t *testing.T) {
+	for locale, keys := range MissingKeys() {
+		if len(keys) > 0 {
+			t.Errorf("%s 缺少 %d 个消息键: %s", locale, len(keys), strings.Join(keys, ", "))
+		}
+	}
+}
+
+// verbPattern fmt 格式化动词，不含 %%
+var verbPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(?:\.[0-9]+)?[a-zA-Z]`)
+
+// TestCatalogVerbs 同一消息键在各语言中的格式化动词一致，否则翻译后的参数错位或缺失
+func TestCatalogVerbs(t *testing.T) {
+	for key, text := range catalogs[DefaultLocale] {
+		want := verbs(text)
+		for _, locale := range Locales() {
+			if translated, ok := catalogs[locale][key]; ok && verbs(translated) != want {
+				t.Errorf("%s 的 %s 格式化动词为 %q，默认语言为 %q", locale, key, verbs(translated), want)
+			}
+		}
+	}
+}
+
+func verbs(text string) string {
+	found := verbPattern.FindAllString(strings.ReplaceAll(text, "%%", ""), -1)
+	sort.Strings(found)
+	return strings.Join(found, " ")
+}
+
+// TestFallback 缺失的语言与消息沿回退链查找
+func TestFallback(t *testing.T) {
+	key := "confirmation_required"
+	if got, want := T("fr-FR", key), catalogs[DefaultLocale][key]; got != want {
+		t.Errorf("不支持的语言应回退到默认语言: got %q want %q", got, want)
+	}
+	if got := T("en-US", "no_such_key"); got != "no_such_key" {
+		t.Errorf("缺失的消息应返回键本身: got %q", got)
+	}
+	if got := Negotiate("fr;q=0.9, en-GB;q=0.8"); got != "en-US" {
+		t.Errorf("Negotiate 应按语言部分匹配 en-US: got %q", got)
+	}
+}
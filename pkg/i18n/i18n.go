@@ -0,0 +1,166 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultLocale 默认语言，协商不出支持的语言或消息缺失时回退到此
+const DefaultLocale = "zh-CN"
+
+// contextKey 当前请求语言在gin上下文中的存储键
+const contextKey = "flowforge.i18n.locale"
+
+// defaultIndex 默认语言消息文本到键的索引，用于翻译以中文字面量传入的消息
+var defaultIndex = make(map[string]string)
+
+func init() {
+	for key, text := range catalogs[DefaultLocale] {
+		defaultIndex[text] = key
+	}
+}
+
+// Locales 返回支持的语言列表
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Normalize 将语言标签（如 en、en_GB、zh-cn）规范为支持的语言，不支持时返回空字符串
+func Normalize(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if tag == "" {
+		return ""
+	}
+
+	for locale := range catalogs {
+		if strings.EqualFold(locale, tag) {
+			return locale
+		}
+	}
+
+	// 仅语言部分匹配时选择该语言的任一地区版本
+	lang := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+	for _, locale := range Locales() {
+		if strings.ToLower(strings.SplitN(locale, "-", 2)[0]) == lang {
+			return locale
+		}
+	}
+	return ""
+}
+
+// Negotiate 按 Accept-Language 的权重选择支持的语言，均不支持时返回默认语言
+func Negotiate(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if locale := Normalize(fields[0]); locale != "" && q > bestQ {
+			best, bestQ = locale, q
+		}
+	}
+
+	if best == "" {
+		return DefaultLocale
+	}
+	return best
+}
+
+// fallbackChain 消息查找顺序：请求的语言，然后是默认语言
+func fallbackChain(locale string) []string {
+	chain := make([]string, 0, 2)
+	if normalized := Normalize(locale); normalized != "" && normalized != DefaultLocale {
+		chain = append(chain, normalized)
+	}
+	return append(chain, DefaultLocale)
+}
+
+// T 按语言获取消息键对应的文本，args 非空时按 fmt 格式化；沿回退链均缺失时返回键本身
+func T(locale, key string, args ...interface{}) string {
+	for _, l := range fallbackChain(locale) {
+		if text, ok := catalogs[l][key]; ok {
+			if len(args) > 0 {
+				return fmt.Sprintf(text, args...)
+			}
+			return text
+		}
+	}
+	return key
+}
+
+// Code 返回默认语言消息文本对应的键，作为与语言无关的错误码；未收录的消息返回空字符串
+func Code(text string) string {
+	if key, ok := defaultIndex[text]; ok {
+		return key
+	}
+	if prefix, _, ok := strings.Cut(text, ": "); ok {
+		return defaultIndex[prefix]
+	}
+	return ""
+}
+
+// Translate 翻译以默认语言书写的消息文本，"前缀: 详情" 形式只翻译前缀；未收录的消息原样返回
+func Translate(locale, text string) string {
+	if key, ok := defaultIndex[text]; ok {
+		return T(locale, key)
+	}
+	if prefix, detail, ok := strings.Cut(text, ": "); ok {
+		if key, ok := defaultIndex[prefix]; ok {
+			return T(locale, key) + ": " + detail
+		}
+	}
+	return text
+}
+
+// MissingKeys 返回各语言相对其他语言缺失的消息键
+func MissingKeys() map[string][]string {
+	all := make(map[string]struct{})
+	for _, messages := range catalogs {
+		for key := range messages {
+			all[key] = struct{}{}
+		}
+	}
+
+	missing := make(map[string][]string)
+	for locale, messages := range catalogs {
+		for key := range all {
+			if _, ok := messages[key]; !ok {
+				missing[locale] = append(missing[locale], key)
+			}
+		}
+		sort.Strings(missing[locale])
+	}
+	return missing
+}
+
+// SetLocale 设置当前请求的语言
+func SetLocale(c *gin.Context, locale string) {
+	c.Set(contextKey, locale)
+}
+
+// FromContext 获取当前请求的语言，未设置时返回默认语言
+func FromContext(c *gin.Context) string {
+	if value, ok := c.Get(contextKey); ok {
+		if locale, ok := value.(string); ok && locale != "" {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
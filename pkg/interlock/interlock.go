@@ -0,0 +1,117 @@
+package interlock
+
+import (
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+
+	"flowforge/pkg/clock"
+	"flowforge/pkg/utils"
+)
+
+// 需要两步确认的破坏性操作
+const (
+	OperationDeleteProject      = "delete_project"
+	OperationDeleteUser         = "delete_user"
+	OperationDeleteEnvironments = "delete_environments"
+	OperationCancelAllRuns      = "cancel_all_runs"
+	OperationPruneRetention     = "prune_retention"
+)
+
+// DefaultTTL 确认令牌的默认有效期
+const DefaultTTL = 5 * time.Minute
+
+// ErrTokenInvalid 确认令牌不存在、已使用、已过期，或不是为该操作与资源签发的
+var ErrTokenInvalid = errors.New("确认令牌无效或已过期")
+
+// ErrNameMismatch 回传的资源名称与要删除的资源不一致
+var ErrNameMismatch = errors.New("确认的资源名称与要删除的资源不一致")
+
+// Summary 操作将删除或影响的数据，Counts 的键如 pipelines、runs、artifacts
+type Summary struct {
+	Description string           `json:"description"`
+	Counts      map[string]int64 `json:"counts"`
+}
+
+// Challenge 首次请求签发的确认令牌：再次请求时原样回传令牌与资源名称，令牌只能使用一次
+type Challenge struct {
+	Token        string    `json:"confirmation_token"`
+	Operation    string    `json:"operation"`
+	ResourceID   uint      `json:"resource_id"`
+	ResourceName string    `json:"resource_name"`
+	Summary      Summary   `json:"summary"`
+	ExpiresAt    time.Time `json:"expires_at"`
+
+	requestedBy uint
+}
+
+// Store 已签发的确认令牌，保存在进程内；令牌只对签发时的用户、操作与资源有效
+type Store struct {
+	clock clock.Clock
+	ttl   time.Duration
+
+	mu         sync.Mutex
+	challenges map[string]*Challenge
+}
+
+// NewStore 创建确认令牌存储，令牌有效期为 ttl，时间取自 c
+func NewStore(c clock.Clock, ttl time.Duration) *Store {
+	return &Store{
+		clock:      c,
+		ttl:        ttl,
+		challenges: make(map[string]*Challenge),
+	}
+}
+
+// Issue 为用户对资源的操作签发确认令牌
+func (s *Store) Issue(operation string, resourceID uint, name string, requestedBy uint, summary Summary) Challenge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+
+	challenge := &Challenge{
+		Token:        utils.GenerateRandomString(32),
+		Operation:    operation,
+		ResourceID:   resourceID,
+		ResourceName: name,
+		Summary:      summary,
+		ExpiresAt:    s.clock.Now().Add(s.ttl),
+		requestedBy:  requestedBy,
+	}
+	s.challenges[challenge.Token] = challenge
+	return *challenge
+}
+
+// Confirm 校验并消耗确认令牌：令牌需为同一用户对同一资源的同一操作签发且未过期，
+// 回传的名称需与资源当前的名称完全一致。无论校验是否通过，令牌都会失效
+func (s *Store) Confirm(operation string, resourceID uint, name string, requestedBy uint, token, echoedName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+
+	for key, challenge := range s.challenges {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) != 1 {
+			continue
+		}
+		delete(s.challenges, key)
+		if challenge.Operation != operation || challenge.ResourceID != resourceID || challenge.requestedBy != requestedBy {
+			return ErrTokenInvalid
+		}
+		if echoedName != name || challenge.ResourceName != name {
+			return ErrNameMismatch
+		}
+		return nil
+	}
+	return ErrTokenInvalid
+}
+
+// pruneLocked 删除已过期的令牌，调用方需持有 s.mu
+func (s *Store) pruneLocked() {
+	now := s.clock.Now()
+	for key, challenge := range s.challenges {
+		if !now.Before(challenge.ExpiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+}
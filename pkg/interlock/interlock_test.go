@@ -0,0 +1,91 @@
+package interlock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"flowforge/internal/clocktest"
+)
+
+// newTestStore 使用可控时钟的确认令牌存储
+func newTestStore() (*Store, *clocktest.Fake) {
+	fake := clocktest.New(time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC))
+	return NewStore(fake, DefaultTTL), fake
+}
+
+func TestConfirm(t *testing.T) {
+	store, fake := newTestStore()
+	challenge := store.Issue(OperationDeleteProject, 7, "web", 1, Summary{Description: "删除项目 web"})
+	if !challenge.ExpiresAt.Equal(fake.Now().Add(DefaultTTL)) {
+		t.Errorf("到期时间 %v，应为签发后 %v", challenge.ExpiresAt, DefaultTTL)
+	}
+	if err := store.Confirm(OperationDeleteProject, 7, "web", 1, challenge.Token, "web"); err != nil {
+		t.Fatalf("令牌与名称正确时应确认，实际为 %v", err)
+	}
+	// 令牌只能使用一次，再次删除需要重新确认
+	if err := store.Confirm(OperationDeleteProject, 7, "web", 1, challenge.Token, "web"); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("已使用的令牌应无效，实际为 %v", err)
+	}
+}
+
+func TestConfirmExpired(t *testing.T) {
+	store, fake := newTestStore()
+	challenge := store.Issue(OperationDeleteProject, 7, "web", 1, Summary{})
+
+	fake.Advance(DefaultTTL - time.Second)
+	early := store.Issue(OperationDeleteProject, 7, "web", 1, Summary{})
+	if err := store.Confirm(OperationDeleteProject, 7, "web", 1, early.Token, "web"); err != nil {
+		t.Fatalf("有效期内的令牌应确认，实际为 %v", err)
+	}
+
+	fake.Advance(time.Second)
+	if err := store.Confirm(OperationDeleteProject, 7, "web", 1, challenge.Token, "web"); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("过期的令牌应无效，实际为 %v", err)
+	}
+}
+
+func TestConfirmNameMismatch(t *testing.T) {
+	store, _ := newTestStore()
+	challenge := store.Issue(OperationDeleteProject, 7, "web", 1, Summary{})
+	if err := store.Confirm(OperationDeleteProject, 7, "web", 1, challenge.Token, "Web"); !errors.Is(err, ErrNameMismatch) {
+		t.Fatalf("回传的名称不一致应返回 ErrNameMismatch，实际为 %v", err)
+	}
+	// 确认失败后令牌同样失效
+	if err := store.Confirm(OperationDeleteProject, 7, "web", 1, challenge.Token, "web"); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("确认失败后令牌应失效，实际为 %v", err)
+	}
+
+	// 签发后资源改名，按原名称回传也不能确认
+	renamed := store.Issue(OperationDeleteProject, 7, "web", 1, Summary{})
+	if err := store.Confirm(OperationDeleteProject, 7, "web-old", 1, renamed.Token, "web"); !errors.Is(err, ErrNameMismatch) {
+		t.Fatalf("资源改名后应返回 ErrNameMismatch，实际为 %v", err)
+	}
+}
+
+func TestConfirmScope(t *testing.T) {
+	cases := []struct {
+		name      string
+		operation string
+		resource  uint
+		user      uint
+	}{
+		{"其他操作", OperationDeleteEnvironments, 7, 1},
+		{"其他资源", OperationDeleteProject, 8, 1},
+		{"其他用户", OperationDeleteProject, 7, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, _ := newTestStore()
+			challenge := store.Issue(OperationDeleteProject, 7, "web", 1, Summary{})
+			if err := store.Confirm(tc.operation, tc.resource, "web", tc.user, challenge.Token, "web"); !errors.Is(err, ErrTokenInvalid) {
+				t.Fatalf("令牌只对签发时的用户、操作与资源有效，实际为 %v", err)
+			}
+		})
+	}
+
+	store, _ := newTestStore()
+	if err := store.Confirm(OperationDeleteProject, 7, "web", 1, "not-issued", "web"); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("未签发的令牌应无效，实际为 %v", err)
+	}
+}
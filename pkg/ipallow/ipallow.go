@@ -0,0 +1,117 @@
+package ipallow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// providerPrefix 来源限制中引用托管平台地址段的前缀，如 @github
+const providerPrefix = "@"
+
+// ErrInvalidEntry 来源限制中的条目不是 IP、CIDR 或已知的托管平台
+var ErrInvalidEntry = errors.New("无效的来源地址")
+
+// Normalize 校验逗号分隔的来源限制（IP、CIDR、@github、@gitlab），返回去掉空白与重复项后的形式
+func Normalize(list string) (string, error) {
+	entries, err := parse(list)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(entries, ","), nil
+}
+
+// NormalizeCIDRs 与 Normalize 相同，但不允许引用托管平台地址段，用于API令牌等不来自托管平台的来源
+func NormalizeCIDRs(list string) (string, error) {
+	entries, err := parse(list)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, providerPrefix) {
+			return "", fmt.Errorf("%w: %s", ErrInvalidEntry, entry)
+		}
+	}
+	return strings.Join(entries, ","), nil
+}
+
+// parse 拆分并校验来源限制，单个 IP 转为 /32 或 /128
+func parse(list string) ([]string, error) {
+	var entries []string
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(entry, providerPrefix):
+			if _, ok := providers[strings.TrimPrefix(entry, providerPrefix)]; !ok {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidEntry, entry)
+			}
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidEntry, entry)
+			}
+			entry = network.String()
+		default:
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidEntry, entry)
+			}
+			if ip.To4() != nil {
+				entry = ip.String() + "/32"
+			} else {
+				entry = ip.String() + "/128"
+			}
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Allowed 客户端地址是否在来源限制内；限制为空时不限制。
+// ip 取自 gin 按信任代理配置解析的客户端地址，不信任的代理转发的 X-Forwarded-For 不会影响结果
+func Allowed(ctx context.Context, list, ip string) bool {
+	entries, err := parse(list)
+	if err != nil {
+		// 保存时已校验，无法解析时按拒绝处理
+		return false
+	}
+	if len(entries) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, providerPrefix) {
+			if containsIP(providerRanges(ctx, strings.TrimPrefix(entry, providerPrefix)), addr) {
+				return true
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsIP 地址是否在任一地址段内
+func containsIP(cidrs []string, addr net.IP) bool {
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package ipallow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+)
+
+// TestNormalize 单个 IP 转为 CIDR，去掉空白与重复项；无效条目返回 ErrInvalidEntry，API令牌不能引用托管平台
+func TestNormalize(t *testing.T) {
+	got, err := Normalize(" 203.0.113.5, 10.0.0.0/8 ,2001:db8::1,@GitHub, 203.0.113.5/32,")
+	if err != nil || got != "203.0.113.5/32,10.0.0.0/8,2001:db8::1/128,@github" {
+		t.Errorf("规范化结果为 %q（%v）", got, err)
+	}
+	if got, err := Normalize(""); got != "" || err != nil {
+		t.Errorf("空列表规范化为 %q（%v）", got, err)
+	}
+	for _, list := range []string{"203.0.113", "10.0.0.0/33", "@bitbucket", "example.com"} {
+		if _, err := Normalize(list); !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("%q 返回 %v，应为 ErrInvalidEntry", list, err)
+		}
+	}
+	if _, err := NormalizeCIDRs("10.0.0.0/8,@github"); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("API令牌引用托管平台返回 %v，应为 ErrInvalidEntry", err)
+	}
+}
+
+// TestAllowed 地址在任一条目内时允许，列表为空时不限制；地址无法解析时拒绝
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		ip   string
+		want bool
+	}{
+		{"不限制", "", "198.51.100.7", true},
+		{"在地址段内", "203.0.113.0/24", "203.0.113.5", true},
+		{"不在地址段内", "203.0.113.0/24", "198.51.100.7", false},
+		{"单个地址", "203.0.113.5", "203.0.113.5", true},
+		{"IPv6", "2001:db8::/32", "2001:db8::42", true},
+		{"IPv4 映射的 IPv6", "203.0.113.0/24", "::ffff:203.0.113.5", true},
+		{"地址无法解析", "203.0.113.0/24", "unknown", false},
+		{"列表无法解析", "not-an-ip", "203.0.113.5", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Allowed(context.Background(), tt.list, tt.ip); got != tt.want {
+				t.Errorf("Allowed(%q, %q) = %v，应为 %v", tt.list, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+// setupRangesTest 内存数据库与返回 GitHub 地址段的 meta 接口，fail 为 true 时接口返回 503
+func setupRangesTest(t *testing.T) (*atomic.Value, *atomic.Bool) {
+	t.Helper()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	database.InvalidateSettings()
+	t.Cleanup(database.InvalidateSettings)
+
+	var body atomic.Value
+	var fail atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(body.Load().(string)))
+	}))
+	t.Cleanup(ts.Close)
+
+	Init(&config.ProviderRangesConfig{GitHubURL: ts.URL + "/meta"})
+	t.Cleanup(func() { Init(&config.ProviderRangesConfig{}) })
+	return &body, &fail
+}
+
+// TestRefreshKeepsLastKnownRanges 刷新成功后使用获取的地址段；接口出错或返回空列表时保留上次成功获取的地址段并记录原因，
+// 从未成功刷新的平台继续使用内置地址段
+func TestRefreshKeepsLastKnownRanges(t *testing.T) {
+	body, fail := setupRangesTest(t)
+	ctx := context.Background()
+
+	if current := status(ctx, ProviderGitHub); !current.BuiltIn || len(current.CIDRs) != 0 || current.RefreshedAt != nil {
+		t.Fatalf("刷新前 GitHub 的状态为 %+v", current)
+	}
+	if Allowed(ctx, "@github", "192.30.252.10") {
+		t.Error("刷新前 GitHub 没有内置地址段，不应允许")
+	}
+
+	body.Store(`{"hooks": ["192.30.252.0/22", "2606:50c0::/32", "invalid"], "web": ["140.82.112.0/20"]}`)
+	RefreshAll()
+	refreshed := status(ctx, ProviderGitHub)
+	if want := []string{"192.30.252.0/22", "2606:50c0::/32"}; !reflect.DeepEqual(refreshed.CIDRs, want) || refreshed.BuiltIn || refreshed.RefreshedAt == nil || refreshed.LastError != "" {
+		t.Fatalf("刷新后 GitHub 的状态为 %+v，地址段应为 %v", refreshed, want)
+	}
+	if !Allowed(ctx, "@github", "192.30.252.10") || Allowed(ctx, "@github", "140.82.112.1") {
+		t.Error("刷新后应只允许 hooks 中的地址段")
+	}
+
+	for name, broken := range map[string]func(){
+		"接口出错":  func() { fail.Store(true) },
+		"返回空列表": func() { fail.Store(false); body.Store(`{"hooks": []}`) },
+	} {
+		broken()
+		RefreshAll()
+		current := status(ctx, ProviderGitHub)
+		if !reflect.DeepEqual(current.CIDRs, refreshed.CIDRs) || !current.RefreshedAt.Equal(*refreshed.RefreshedAt) || current.LastError == "" {
+			t.Errorf("%s后 GitHub 的状态为 %+v，应保留上次的地址段并记录失败原因", name, current)
+		}
+		if current.LastAttemptAt == nil || current.LastAttemptAt.Before(*refreshed.RefreshedAt) {
+			t.Errorf("%s后最近尝试时间为 %v", name, current.LastAttemptAt)
+		}
+		if !Allowed(ctx, "@github", "192.30.252.10") {
+			t.Errorf("%s后应继续允许上次的地址段", name)
+		}
+	}
+
+	gitlab := status(ctx, ProviderGitLab)
+	if !gitlab.BuiltIn || !Allowed(ctx, "@gitlab", "34.74.226.10") {
+		t.Errorf("GitLab 的状态为 %+v，没有配置刷新地址时应使用内置地址段", gitlab)
+	}
+}
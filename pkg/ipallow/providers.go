@@ -0,0 +1,196 @@
+package ipallow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/models"
+)
+
+// 托管平台
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// provider 托管平台公布Webhook来源地址段的方式
+type provider struct {
+	url     func(cfg *config.ProviderRangesConfig) string
+	builtIn []string // 从未成功刷新时使用的地址段
+}
+
+// providers 可在来源限制中以 @名称 引用的托管平台。GitHub 从 meta 接口的 hooks 获取；
+// GitLab 没有公布地址段的接口，默认使用 GitLab.com 文档中的地址段，可配置返回地址段的地址
+var providers = map[string]provider{
+	ProviderGitHub: {
+		url: func(cfg *config.ProviderRangesConfig) string { return cfg.GitHubURL },
+	},
+	ProviderGitLab: {
+		url:     func(cfg *config.ProviderRangesConfig) string { return cfg.GitLabURL },
+		builtIn: []string{"34.74.90.64/28", "34.74.226.0/24"},
+	},
+}
+
+// ProviderStatus 托管平台地址段的刷新状态，保存在系统配置中，各实例共用
+type ProviderStatus struct {
+	Provider      string     `json:"provider"`
+	Source        string     `json:"source,omitempty"` // 刷新地址，为空时只使用内置地址段
+	CIDRs         []string   `json:"cidrs"`
+	BuiltIn       bool       `json:"built_in"`     // 尚未成功刷新，使用内置地址段
+	RefreshedAt   *time.Time `json:"refreshed_at"` // 最近一次成功刷新的时间
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"` // 最近一次刷新失败的原因，成功后清空
+}
+
+var (
+	mu        sync.Mutex
+	rangesCfg = &config.ProviderRangesConfig{}
+	// refreshing 同一时间只执行一次刷新
+	refreshing sync.Mutex
+)
+
+// Init 设置托管平台地址段的刷新地址
+func Init(cfg *config.ProviderRangesConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	rangesCfg = cfg
+}
+
+// currentConfig 当前的刷新配置
+func currentConfig() *config.ProviderRangesConfig {
+	mu.Lock()
+	defer mu.Unlock()
+	return rangesCfg
+}
+
+// Statuses 各托管平台地址段的刷新状态，按名称排序
+func Statuses(ctx context.Context) []ProviderStatus {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make([]ProviderStatus, 0, len(names))
+	for _, name := range names {
+		statuses = append(statuses, status(ctx, name))
+	}
+	return statuses
+}
+
+// status 读取保存的刷新状态，从未成功刷新时返回内置地址段
+func status(ctx context.Context, name string) ProviderStatus {
+	current := ProviderStatus{Provider: name}
+	if value, ok, err := database.Setting(ctx, models.ConfigProviderRangesPrefix+name); err == nil && ok {
+		json.Unmarshal([]byte(value), &current)
+	}
+	current.Source = providers[name].url(currentConfig())
+	if len(current.CIDRs) == 0 {
+		current.CIDRs = providers[name].builtIn
+		current.BuiltIn = true
+	}
+	return current
+}
+
+// providerRanges 托管平台当前的地址段
+func providerRanges(ctx context.Context, name string) []string {
+	return status(ctx, name).CIDRs
+}
+
+// RefreshAll 从托管平台刷新地址段，供定时任务调用；刷新失败时保留上次成功获取的地址段并记录失败原因
+func RefreshAll() {
+	refreshing.Lock()
+	defer refreshing.Unlock()
+
+	ctx := context.Background()
+	for _, current := range Statuses(ctx) {
+		if current.Source == "" {
+			continue
+		}
+		now := time.Now()
+		next := current
+		next.LastAttemptAt = &now
+		cidrs, err := fetch(ctx, current.Source)
+		if err != nil {
+			log.Printf("刷新 %s 的Webhook地址段失败，继续使用上次的地址段: %v", current.Provider, err)
+			next.LastError = err.Error()
+			if next.BuiltIn {
+				next.CIDRs = nil
+			}
+		} else {
+			next.CIDRs, next.RefreshedAt, next.LastError = cidrs, &now, ""
+		}
+		if err := save(next); err != nil {
+			log.Printf("保存 %s 的Webhook地址段失败: %v", current.Provider, err)
+		}
+	}
+}
+
+// fetch 获取地址段：GitHub meta 接口返回对象，取其中的 hooks；也接受直接返回的地址段数组
+func fetch(ctx context.Context, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpclient.New(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	var raw []string
+	if err := json.Unmarshal(body, &raw); err != nil {
+		var meta struct {
+			Hooks []string `json:"hooks"`
+		}
+		if err := json.Unmarshal(body, &meta); err != nil {
+			return nil, fmt.Errorf("无法解析响应: %w", err)
+		}
+		raw = meta.Hooks
+	}
+
+	cidrs := make([]string, 0, len(raw))
+	for _, cidr := range raw {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			cidrs = append(cidrs, network.String())
+		}
+	}
+	// 空列表会拒绝该平台的全部投递，视为失败
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("响应中没有地址段")
+	}
+	return cidrs, nil
+}
+
+// save 保存刷新状态，内置地址段不保存
+func save(current ProviderStatus) error {
+	current.BuiltIn, current.Source = false, ""
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	key := models.ConfigProviderRangesPrefix + current.Provider
+	setting := models.SystemConfig{Key: key, Category: "security", Description: "托管平台的Webhook来源地址段"}
+	err = database.DB.Where(models.SystemConfig{Key: key}).Assign(models.SystemConfig{Value: string(data)}).FirstOrCreate(&setting).Error
+	database.InvalidateSettings()
+	return err
+}
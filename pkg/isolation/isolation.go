@@ -0,0 +1,115 @@
+package isolation
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// uidBase 项目系统用户的 UID 从该值加项目ID分配，各执行器主机上同一项目的 UID 一致，共享存储上的文件归属因此不变
+const uidBase = 200000
+
+// Account 项目专用的系统用户，用户组与用户同名同ID
+type Account struct {
+	Name string
+	UID  int
+	GID  int
+	Home string // 项目目录，脚本的 HOME
+}
+
+var (
+	mu      sync.Mutex
+	enabled bool
+	prefix  = "ff-p"
+)
+
+// Init 按配置开启多租户隔离并检查运行环境（Linux、root 权限与 useradd 命令），不满足时告警并关闭，不阻止启动
+func Init(cfg *config.IsolationConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	enabled = false
+	prefix = cfg.UserPrefix
+	if !cfg.Enabled {
+		return
+	}
+	if err := checkSupport(); err != nil {
+		log.Printf("警告: 多租户隔离不可用，已关闭: %v", err)
+		return
+	}
+	enabled = true
+	log.Println("多租户隔离已开启，本机执行的脚本以项目专用系统用户运行")
+}
+
+// Enabled 多租户隔离是否开启
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return enabled
+}
+
+// ProjectDir 隔离开启时项目自己的目录，工作区与依赖缓存都在其中，只允许项目用户访问
+func ProjectDir(dataPath string, projectID uint) string {
+	return filepath.Join(dataPath, "projects", fmt.Sprintf("%d", projectID))
+}
+
+// WorkspaceDir 项目的代码工作区：隔离开启时位于项目目录下，否则位于共享的 workspaces 目录下
+func WorkspaceDir(dataPath string, projectID uint) string {
+	if Enabled() {
+		return filepath.Join(ProjectDir(dataPath, projectID), "workspace")
+	}
+	return LegacyWorkspaceDir(dataPath, projectID)
+}
+
+// LegacyWorkspaceDir 未开启隔离时的工作区位置，迁移时从这里移动到项目目录
+func LegacyWorkspaceDir(dataPath string, projectID uint) string {
+	return fmt.Sprintf("%s/workspaces/%d", dataPath, projectID)
+}
+
+// CacheDir 流水线依赖缓存的根目录：隔离开启时位于项目目录下，否则位于共享的 cache 目录下
+func CacheDir(dataPath string, projectID, pipelineID uint) string {
+	if Enabled() {
+		return filepath.Join(ProjectDir(dataPath, projectID), "cache", fmt.Sprintf("%d", pipelineID))
+	}
+	return LegacyCacheDir(dataPath, pipelineID)
+}
+
+// LegacyCacheDir 未开启隔离时流水线依赖缓存的位置
+func LegacyCacheDir(dataPath string, pipelineID uint) string {
+	return fmt.Sprintf("%s/cache/%d", dataPath, pipelineID)
+}
+
+// ProjectAccount 项目专用的系统用户，首次使用时在本机创建并记录到项目上；
+// 项目已记录用户但本机还没有（如新的执行器主机）时以记录的名称与ID创建
+func ProjectAccount(db *gorm.DB, dataPath string, project *models.Project) (*Account, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	account := &Account{
+		Name: project.OSUser,
+		UID:  project.OSUID,
+		Home: ProjectDir(dataPath, project.ID),
+	}
+	if account.Name == "" {
+		account.Name = fmt.Sprintf("%s%d", prefix, project.ID)
+		account.UID = uidBase + int(project.ID)
+	}
+	account.GID = account.UID
+
+	if err := provision(account); err != nil {
+		return nil, fmt.Errorf("创建项目系统用户 %s 失败: %w", account.Name, err)
+	}
+	if project.OSUser == "" {
+		if err := db.Model(project).Updates(map[string]interface{}{"os_user": account.Name, "os_uid": account.UID}).Error; err != nil {
+			return nil, fmt.Errorf("记录项目系统用户失败: %w", err)
+		}
+		project.OSUser, project.OSUID = account.Name, account.UID
+	}
+	return account, nil
+}
@@ -0,0 +1,108 @@
+//go:build linux
+
+package isolation
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// checkSupport 创建系统用户、修改文件归属与切换脚本的运行用户都需要 root 权限
+func checkSupport() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("需要以 root 运行服务")
+	}
+	for _, name := range []string{"useradd", "groupadd"} {
+		if _, err := exec.LookPath(name); err != nil {
+			return fmt.Errorf("找不到 %s 命令: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// provision 在本机创建项目系统用户与同名用户组，已存在时校验 UID 一致
+func provision(account *Account) error {
+	uid, gid := strconv.Itoa(account.UID), strconv.Itoa(account.GID)
+	if existing, err := user.Lookup(account.Name); err == nil {
+		if existing.Uid != uid {
+			return fmt.Errorf("本机已有同名用户，UID 为 %s", existing.Uid)
+		}
+		return nil
+	}
+
+	if _, err := user.LookupGroupId(gid); err != nil {
+		if output, err := exec.Command("groupadd", "--system", "--gid", gid, account.Name).CombinedOutput(); err != nil {
+			return fmt.Errorf("groupadd: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	output, err := exec.Command("useradd", "--system", "--uid", uid, "--gid", gid,
+		"--no-create-home", "--home-dir", account.Home, "--shell", "/usr/sbin/nologin", account.Name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("useradd: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Prepare 在执行脚本前准备项目目录下的 dir：上层 projects 目录可进入但不可列出，项目目录只允许项目用户访问，
+// dir 及其内容归项目用户所有。拉取代码、恢复缓存等由服务进程写入的文件因此每次执行前重新归属
+func Prepare(dir string, account *Account) error {
+	root := filepath.Dir(account.Home)
+	if err := os.MkdirAll(root, 0711); err != nil {
+		return fmt.Errorf("创建项目目录失败: %w", err)
+	}
+	if err := os.Chmod(root, 0711); err != nil {
+		return fmt.Errorf("设置目录权限失败: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.Chown(account.Home, account.UID, account.GID); err != nil {
+		return fmt.Errorf("修改目录归属失败: %w", err)
+	}
+	if err := os.Chmod(account.Home, 0700); err != nil {
+		return fmt.Errorf("设置目录权限失败: %w", err)
+	}
+	return chownTree(dir, account)
+}
+
+// chownTree 将目录及其内容归属项目用户，已归属的条目跳过；符号链接只修改链接本身
+func chownTree(dir string, account *Account) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) == account.UID && int(stat.Gid) == account.GID {
+			return nil
+		}
+		if err := os.Lchown(path, account.UID, account.GID); err != nil {
+			return fmt.Errorf("修改 %s 的归属失败: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// PrepareFile 将服务进程创建的文件（如临时脚本）交给项目用户，其他用户不可读
+func PrepareFile(path string, account *Account) error {
+	if err := os.Chown(path, account.UID, account.GID); err != nil {
+		return fmt.Errorf("修改文件归属失败: %w", err)
+	}
+	return os.Chmod(path, 0700)
+}
+
+// Apply 命令以项目用户运行，并清除从服务进程继承的附加用户组
+func Apply(cmd *exec.Cmd, account *Account) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    uint32(account.UID),
+		Gid:    uint32(account.GID),
+		Groups: []uint32{},
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+
+package isolation
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// errUnsupported 多租户隔离依赖 Linux 的用户与文件权限
+var errUnsupported = errors.New("多租户隔离只支持 Linux")
+
+func checkSupport() error {
+	return errUnsupported
+}
+
+func provision(account *Account) error {
+	return errUnsupported
+}
+
+// Prepare 当前平台不支持隔离，不会开启，调用时返回错误
+func Prepare(dir string, account *Account) error {
+	return errUnsupported
+}
+
+// PrepareFile 当前平台不支持隔离，不会开启，调用时返回错误
+func PrepareFile(path string, account *Account) error {
+	return errUnsupported
+}
+
+// Apply 当前平台不支持隔离，不会开启，命令保持以服务用户运行
+func Apply(cmd *exec.Cmd, account *Account) {}
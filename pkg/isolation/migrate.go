@@ -0,0 +1,84 @@
+package isolation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotEnabled 迁移需要先在配置中开启隔离，且运行环境满足要求
+var ErrNotEnabled = errors.New("多租户隔离未开启")
+
+// MigrateResult 迁移结果
+type MigrateResult struct {
+	Projects int      // 已创建系统用户并准备目录的项目数
+	Moved    int      // 已移动的工作区与缓存目录数
+	Skipped  []string // 目标已存在而未移动的旧目录，需人工核对后删除
+}
+
+// Migrate 将开启隔离前的共享工作区与依赖缓存移动到各项目目录下，并为每个项目创建系统用户、修改目录归属。
+// 同一文件系统内移动，不复制数据；目标已存在时保留旧目录并记入结果
+func Migrate(db *gorm.DB, dataPath string) (*MigrateResult, error) {
+	if !Enabled() {
+		return nil, ErrNotEnabled
+	}
+
+	var projects []models.Project
+	if err := db.Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	result := &MigrateResult{}
+	for i := range projects {
+		project := &projects[i]
+		account, err := ProjectAccount(db, dataPath, project)
+		if err != nil {
+			return result, err
+		}
+
+		if err := result.move(LegacyWorkspaceDir(dataPath, project.ID), WorkspaceDir(dataPath, project.ID)); err != nil {
+			return result, err
+		}
+
+		// 已删除的流水线也可能留有缓存，一并移动
+		var pipelineIDs []uint
+		if err := db.Unscoped().Model(&models.Pipeline{}).Where("project_id = ?", project.ID).Pluck("id", &pipelineIDs).Error; err != nil {
+			return result, fmt.Errorf("查询项目 %d 的流水线失败: %w", project.ID, err)
+		}
+		for _, pipelineID := range pipelineIDs {
+			if err := result.move(LegacyCacheDir(dataPath, pipelineID), CacheDir(dataPath, project.ID, pipelineID)); err != nil {
+				return result, err
+			}
+		}
+
+		if err := Prepare(account.Home, account); err != nil {
+			return result, fmt.Errorf("准备项目 %d 的目录失败: %w", project.ID, err)
+		}
+		result.Projects++
+	}
+	return result, nil
+}
+
+// move 将旧目录移动到新位置，旧目录不存在时跳过
+func (r *MigrateResult) move(from, to string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(to); err == nil {
+		r.Skipped = append(r.Skipped, from)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("移动 %s 失败: %w", from, err)
+	}
+	r.Moved++
+	return nil
+}
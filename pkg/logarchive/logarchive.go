@@ -0,0 +1,411 @@
+package logarchive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+
+	"gorm.io/gorm"
+)
+
+// chunkLines 每个 gzip 分段包含的日志行数，按偏移读取时只需解压所在的分段
+const chunkLines = 1000
+
+var (
+	// ErrLogChanged 压缩期间日志被修改（仍在写入或被重新脱敏），本次不归档
+	ErrLogChanged = errors.New("日志在压缩期间被修改")
+	// ErrArchiveCorrupted 归档文件与索引不一致
+	ErrArchiveCorrupted = errors.New("日志归档已损坏")
+)
+
+// finishedStatuses 可以归档日志的运行状态
+var finishedStatuses = []string{models.RunStatusSuccess, models.RunStatusFailed, models.RunStatusCancelled}
+
+// index 归档文件的行偏移索引：第 i 个分段从 Offsets[i] 字节处开始，包含第 i*ChunkLines 行起的 ChunkLines 行
+type index struct {
+	Lines      int     `json:"lines"`
+	ChunkLines int     `json:"chunk_lines"`
+	Offsets    []int64 `json:"offsets"`
+}
+
+// Store 运行日志归档存储：将数据库中的日志压缩为 gzip 文件保存在存储目录，并按项目统计日志占用
+type Store struct {
+	root     string
+	config   *config.Config
+	notifier *notify.Manager
+}
+
+// NewStore 创建日志归档存储，目前仅支持本地存储；notifier 用于通知超过软配额的项目所有者
+func NewStore(cfg *config.Config, notifier *notify.Manager) (*Store, error) {
+	if cfg.Storage.Type != "" && cfg.Storage.Type != "local" {
+		return nil, fmt.Errorf("日志归档暂不支持存储类型: %s", cfg.Storage.Type)
+	}
+
+	root := filepath.Join(cfg.Storage.Local.Path, "logs")
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志归档目录失败: %w", err)
+	}
+
+	return &Store{root: root, config: cfg, notifier: notifier}, nil
+}
+
+// Compress 将已结束运行的日志压缩归档，数据库中只保留指向归档的记录
+func (s *Store) Compress(runID uint) (*models.LogArchive, error) {
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline").First(&run, runID).Error; err != nil {
+		return nil, fmt.Errorf("流水线运行不存在: %w", err)
+	}
+	if run.LogArchived || run.LogOutput == "" {
+		return nil, nil
+	}
+
+	lines := splitLines(run.LogOutput)
+	path := filepath.Join(s.root, fmt.Sprintf("%d", run.Pipeline.ProjectID), fmt.Sprintf("%d.log.gz", run.ID))
+	compressed, err := writeArchive(path, filepath.Join(s.root, "tmp"), lines)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &models.LogArchive{
+		PipelineRunID:  run.ID,
+		ProjectID:      run.Pipeline.ProjectID,
+		Path:           path,
+		Size:           int64(len(run.LogOutput)),
+		CompressedSize: compressed,
+		Lines:          len(lines),
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 日志在读取后又有追加或改写时，log_size 已变化，放弃本次归档
+		result := tx.Model(&models.PipelineRun{}).
+			Where("id = ? AND log_archived = ? AND log_size = ?", run.ID, false, run.LogSize).
+			UpdateColumns(map[string]interface{}{
+				"log_output":   "",
+				"log_size":     0,
+				"log_archived": true,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLogChanged
+		}
+		return tx.Create(archive).Error
+	})
+	if err != nil {
+		removeArchive(path)
+		if errors.Is(err, ErrLogChanged) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("保存日志归档记录失败: %w", err)
+	}
+	return archive, nil
+}
+
+// Read 读取归档日志中从第 offset 行起的 limit 行（limit 不大于 0 时读取到末尾），同时返回总行数
+func (s *Store) Read(runID uint, offset, limit int) ([]string, int, error) {
+	var archive models.LogArchive
+	if err := database.DB.Where("pipeline_run_id = ?", runID).First(&archive).Error; err != nil {
+		return nil, 0, fmt.Errorf("日志归档不存在: %w", err)
+	}
+
+	idx, err := readIndex(archive.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= idx.Lines {
+		return []string{}, idx.Lines, nil
+	}
+	if limit <= 0 || offset+limit > idx.Lines {
+		limit = idx.Lines - offset
+	}
+
+	chunk := offset / idx.ChunkLines
+	if chunk >= len(idx.Offsets) {
+		return nil, 0, ErrArchiveCorrupted
+	}
+	file, err := os.Open(archive.Path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("打开日志归档失败: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(idx.Offsets[chunk], io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("读取日志归档失败: %w", err)
+	}
+
+	// 从所在分段开始解压，后续分段作为同一个 gzip 流的后续成员连续读取
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrArchiveCorrupted, err)
+	}
+	defer gz.Close()
+	reader := bufio.NewReader(gz)
+
+	skip := offset - chunk*idx.ChunkLines
+	lines := make([]string, 0, limit)
+	for len(lines) < limit {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, 0, fmt.Errorf("%w: %v", ErrArchiveCorrupted, err)
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	return lines, idx.Lines, nil
+}
+
+// Rewrite 按 rewrite 改写归档的日志（如按新的脱敏规则重新处理），内容有变化时重新压缩归档，返回是否有变化
+func (s *Store) Rewrite(runID uint, rewrite func(string) string) (bool, error) {
+	var archive models.LogArchive
+	if err := database.DB.Where("pipeline_run_id = ?", runID).First(&archive).Error; err != nil {
+		return false, fmt.Errorf("日志归档不存在: %w", err)
+	}
+	lines, _, err := s.Read(runID, 0, 0)
+	if err != nil {
+		return false, err
+	}
+	logs := strings.Join(lines, "\n") + "\n"
+	rewritten := rewrite(logs)
+	if rewritten == logs {
+		return false, nil
+	}
+
+	lines = splitLines(rewritten)
+	compressed, err := writeArchive(archive.Path, filepath.Join(s.root, "tmp"), lines)
+	if err != nil {
+		return false, err
+	}
+	if err := database.DB.Model(&archive).UpdateColumns(map[string]interface{}{
+		"size":            len(rewritten),
+		"compressed_size": compressed,
+		"lines":           len(lines),
+	}).Error; err != nil {
+		return false, fmt.Errorf("更新日志归档记录失败: %w", err)
+	}
+	return true, nil
+}
+
+// Delete 删除运行的日志归档，运行记录被清理时调用
+func (s *Store) Delete(runID uint) error {
+	var archive models.LogArchive
+	if err := database.DB.Where("pipeline_run_id = ?", runID).First(&archive).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("查询日志归档失败: %w", err)
+	}
+	if err := removeArchive(archive.Path); err != nil {
+		return err
+	}
+	return database.DB.Delete(&archive).Error
+}
+
+// Enforce 按项目检查数据库中的日志量：超过软配额时通知项目所有者一次，超过硬上限时从最早的运行开始压缩归档，
+// 直到回到上限以内。每次最多压缩 LogCompressBatch 个运行的日志，其余留到下次清理任务，返回本次归档的运行数
+func (s *Store) Enforce() (int, error) {
+	usages, err := s.UsageByProject()
+	if err != nil {
+		return 0, err
+	}
+
+	budget := s.config.Deploy.LogCompressBatch
+	compressed := 0
+	for i := range usages {
+		usage := &usages[i]
+		if usage.HardCapBytes > 0 && usage.DBBytes > usage.HardCapBytes && budget > 0 {
+			n, freed, err := s.compressOldest(usage.ProjectID, usage.DBBytes-usage.HardCapBytes, budget)
+			if err != nil {
+				log.Printf("压缩项目 %d 的运行日志失败: %v", usage.ProjectID, err)
+			}
+			budget -= n
+			compressed += n
+			usage.DBBytes -= freed
+		}
+		s.checkSoftQuota(usage)
+	}
+	return compressed, nil
+}
+
+// compressOldest 压缩项目最早的已结束运行的日志，直到释放 excess 字节或压缩了 max 个运行
+func (s *Store) compressOldest(projectID uint, excess int64, max int) (int, int64, error) {
+	var runs []models.PipelineRun
+	if err := database.DB.Select("pipeline_runs.id", "pipeline_runs.log_size").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Where("pipelines.project_id = ? AND pipeline_runs.log_archived = ? AND pipeline_runs.log_size > 0", projectID, false).
+		Where("pipeline_runs.status IN ?", finishedStatuses).
+		Order("pipeline_runs.id").Limit(max).Find(&runs).Error; err != nil {
+		return 0, 0, fmt.Errorf("查询待压缩的运行失败: %w", err)
+	}
+
+	count := 0
+	var freed int64
+	for _, run := range runs {
+		if freed >= excess {
+			break
+		}
+		archive, err := s.Compress(run.ID)
+		if err != nil {
+			log.Printf("压缩流水线运行 %d 的日志失败: %v", run.ID, err)
+			continue
+		}
+		if archive != nil {
+			count++
+			freed += archive.Size
+		}
+	}
+	return count, freed, nil
+}
+
+// checkSoftQuota 项目日志超过软配额且尚未通知时通知项目所有者；回到配额以内时清除通知记录，再次超过时重新通知
+func (s *Store) checkSoftQuota(usage *Usage) {
+	over := usage.SoftQuotaBytes > 0 && usage.DBBytes > usage.SoftQuotaBytes
+	var project models.Project
+	if err := database.DB.Preload("User").First(&project, usage.ProjectID).Error; err != nil {
+		return
+	}
+
+	if !over {
+		if project.LogQuotaWarnedAt != nil {
+			database.DB.Model(&project).UpdateColumn("log_quota_warned_at", nil)
+		}
+		return
+	}
+	if project.LogQuotaWarnedAt != nil {
+		return
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&project).UpdateColumn("log_quota_warned_at", &now).Error; err != nil {
+		log.Printf("记录项目 %d 的日志配额通知失败: %v", project.ID, err)
+		return
+	}
+	if s.notifier == nil {
+		return
+	}
+
+	content := fmt.Sprintf("项目 %s 数据库中的运行日志共 %s，超过软配额 %s。", project.Name, formatMB(usage.DBBytes), formatMB(usage.SoftQuotaBytes))
+	if usage.HardCapBytes > 0 {
+		content += fmt.Sprintf("\n超过硬上限 %s 后，最早的运行日志将被压缩归档，查看时读取较慢。", formatMB(usage.HardCapBytes))
+	}
+	if err := s.notifier.Deliver(&project.User, notify.Message{
+		Title:    fmt.Sprintf("项目 %s 的日志存储超过软配额", project.Name),
+		Content:  content,
+		Link:     fmt.Sprintf("%s/projects/%d", s.config.Notify.BaseURL, project.ID),
+		Level:    models.NotifyLevelNormal,
+		Category: models.NotifyCategoryLogQuota,
+	}); err != nil {
+		log.Printf("向用户 %d 投递日志配额通知失败: %v", project.UserID, err)
+	}
+}
+
+// splitLines 按行拆分日志，与读取数据库中日志时的拆分方式一致
+func splitLines(logs string) []string {
+	return strings.Split(strings.TrimRight(logs, "\n"), "\n")
+}
+
+// writeArchive 将日志按 chunkLines 行一段写为多成员 gzip 文件，并写入行偏移索引；先写入临时文件再重命名，返回压缩后的字节数
+func writeArchive(path, tmpDir string, lines []string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("创建日志归档目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(tmpDir, "log-*")
+	if err != nil {
+		return 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	counter := &countingWriter{w: tmp}
+	idx := index{Lines: len(lines), ChunkLines: chunkLines}
+	for start := 0; start < len(lines); start += chunkLines {
+		end := start + chunkLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+		idx.Offsets = append(idx.Offsets, counter.n)
+
+		gz := gzip.NewWriter(counter)
+		if _, err := io.WriteString(gz, strings.Join(lines[start:end], "\n")+"\n"); err != nil {
+			tmp.Close()
+			return 0, fmt.Errorf("压缩日志失败: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			tmp.Close()
+			return 0, fmt.Errorf("压缩日志失败: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("写入日志归档失败: %w", err)
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return 0, fmt.Errorf("生成日志索引失败: %w", err)
+	}
+	if err := os.WriteFile(path+".idx", data, 0644); err != nil {
+		return 0, fmt.Errorf("写入日志索引失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(path + ".idx")
+		return 0, fmt.Errorf("保存日志归档失败: %w", err)
+	}
+	return counter.n, nil
+}
+
+// readIndex 读取归档文件的行偏移索引
+func readIndex(path string) (*index, error) {
+	data, err := os.ReadFile(path + ".idx")
+	if err != nil {
+		return nil, fmt.Errorf("读取日志索引失败: %w", err)
+	}
+	var idx index
+	if err := json.Unmarshal(data, &idx); err != nil || idx.ChunkLines <= 0 {
+		return nil, ErrArchiveCorrupted
+	}
+	return &idx, nil
+}
+
+// removeArchive 删除归档文件与索引
+func removeArchive(path string) error {
+	for _, p := range []string{path, path + ".idx"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除日志归档失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// formatMB 字节数的可读形式
+func formatMB(bytes int64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
+
+// countingWriter 记录已写入的字节数，用于计算各分段的起始偏移
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,147 @@
+package logarchive
+
+import (
+	"fmt"
+	"sort"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// Usage 项目的运行日志存储占用（字节）
+type Usage struct {
+	ProjectID       uint   `json:"project_id"`
+	ProjectName     string `json:"project_name"`
+	DBBytes         int64  `json:"db_bytes"`         // 数据库中未归档的日志
+	ArchivedBytes   int64  `json:"archived_bytes"`   // 已归档日志的原始大小
+	CompressedBytes int64  `json:"compressed_bytes"` // 已归档日志压缩后的大小
+	ArchivedRuns    int64  `json:"archived_runs"`
+	SoftQuotaBytes  int64  `json:"soft_quota_bytes"` // 0 表示不限制
+	HardCapBytes    int64  `json:"hard_cap_bytes"`   // 0 表示不限制
+	OverSoftQuota   bool   `json:"over_soft_quota"`
+}
+
+// Totals 所有项目的运行日志存储占用合计
+type Totals struct {
+	DBBytes         int64 `json:"db_bytes"`
+	ArchivedBytes   int64 `json:"archived_bytes"`
+	CompressedBytes int64 `json:"compressed_bytes"`
+	ArchivedRuns    int64 `json:"archived_runs"`
+}
+
+// Limits 项目生效的软配额与硬上限（字节），项目未设置（为 0）时使用全局配置，-1 表示不限制
+func (s *Store) Limits(project *models.Project) (soft, hard int64) {
+	return limitBytes(project.LogSoftQuotaMB, s.config.Deploy.LogSoftQuotaMB), limitBytes(project.LogHardCapMB, s.config.Deploy.LogHardCapMB)
+}
+
+// Usage 单个项目的日志存储占用
+func (s *Store) Usage(project *models.Project) (*Usage, error) {
+	usages, err := s.usage(project.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(usages) == 0 {
+		soft, hard := s.Limits(project)
+		return &Usage{ProjectID: project.ID, ProjectName: project.Name, SoftQuotaBytes: soft, HardCapBytes: hard}, nil
+	}
+	return &usages[0], nil
+}
+
+// UsageByProject 所有项目的日志存储占用，按数据库中的日志量从大到小排列
+func (s *Store) UsageByProject() ([]Usage, error) {
+	return s.usage(0)
+}
+
+// Total 合计所有项目的日志存储占用
+func Total(usages []Usage) Totals {
+	var totals Totals
+	for _, u := range usages {
+		totals.DBBytes += u.DBBytes
+		totals.ArchivedBytes += u.ArchivedBytes
+		totals.CompressedBytes += u.CompressedBytes
+		totals.ArchivedRuns += u.ArchivedRuns
+	}
+	return totals
+}
+
+// usage 统计项目的日志存储占用，projectID 为 0 时统计所有项目
+func (s *Store) usage(projectID uint) ([]Usage, error) {
+	var projects []models.Project
+	query := database.DB.Select("id", "name", "log_soft_quota_mb", "log_hard_cap_mb")
+	if projectID != 0 {
+		query = query.Where("id = ?", projectID)
+	}
+	if err := query.Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	type dbRow struct {
+		ProjectID uint
+		Bytes     int64
+	}
+	var dbRows []dbRow
+	dbQuery := database.DB.Table("pipeline_runs").
+		Select("pipelines.project_id, COALESCE(SUM(pipeline_runs.log_size), 0) AS bytes").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Where("pipeline_runs.deleted_at IS NULL")
+	if projectID != 0 {
+		dbQuery = dbQuery.Where("pipelines.project_id = ?", projectID)
+	}
+	if err := dbQuery.Group("pipelines.project_id").Scan(&dbRows).Error; err != nil {
+		return nil, fmt.Errorf("统计运行日志失败: %w", err)
+	}
+
+	type archiveRow struct {
+		ProjectID  uint
+		Bytes      int64
+		Compressed int64
+		Runs       int64
+	}
+	var archiveRows []archiveRow
+	archiveQuery := database.DB.Model(&models.LogArchive{}).
+		Select("project_id, COALESCE(SUM(size), 0) AS bytes, COALESCE(SUM(compressed_size), 0) AS compressed, COUNT(*) AS runs")
+	if projectID != 0 {
+		archiveQuery = archiveQuery.Where("project_id = ?", projectID)
+	}
+	if err := archiveQuery.Group("project_id").Scan(&archiveRows).Error; err != nil {
+		return nil, fmt.Errorf("统计日志归档失败: %w", err)
+	}
+
+	byID := make(map[uint]*Usage, len(projects))
+	usages := make([]Usage, len(projects))
+	for i := range projects {
+		soft, hard := s.Limits(&projects[i])
+		usages[i] = Usage{ProjectID: projects[i].ID, ProjectName: projects[i].Name, SoftQuotaBytes: soft, HardCapBytes: hard}
+		byID[projects[i].ID] = &usages[i]
+	}
+	for _, row := range dbRows {
+		if u, ok := byID[row.ProjectID]; ok {
+			u.DBBytes = row.Bytes
+		}
+	}
+	for _, row := range archiveRows {
+		if u, ok := byID[row.ProjectID]; ok {
+			u.ArchivedBytes = row.Bytes
+			u.CompressedBytes = row.Compressed
+			u.ArchivedRuns = row.Runs
+		}
+	}
+	for i := range usages {
+		usages[i].OverSoftQuota = usages[i].SoftQuotaBytes > 0 && usages[i].DBBytes > usages[i].SoftQuotaBytes
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool { return usages[i].DBBytes > usages[j].DBBytes })
+	return usages, nil
+}
+
+// limitBytes 按项目设置与全局配置计算生效的限制（字节），0 表示不限制
+func limitBytes(projectMB, defaultMB int) int64 {
+	mb := projectMB
+	if mb == 0 {
+		mb = defaultMB
+	}
+	if mb <= 0 {
+		return 0
+	}
+	return int64(mb) << 20
+}
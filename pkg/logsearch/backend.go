@@ -0,0 +1,172 @@
+package logsearch
+
+import (
+	"fmt"
+	"strings"
+
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ftsTable SQLite 保存日志内容的全文检索虚拟表，rowid 为 run_log_segments 的 id
+const ftsTable = "run_log_fts"
+
+// LogSearch 按数据库类型实现的日志全文索引。索引段的元数据保存在 run_log_segments，
+// 匹配由数据库的全文检索完成，摘要统一由 ExtractSnippet 生成
+type LogSearch interface {
+	// Name 索引实现的名称，用于日志与统计
+	Name() string
+	// Prepare 创建全文索引，已存在时不做修改
+	Prepare(db *gorm.DB) error
+	// Insert 写入一段索引，segment.Content 为该段日志
+	Insert(tx *gorm.DB, segment *models.RunLogSegment) error
+	// Match 在 run_log_segments（别名 s）上筛选包含检索内容的段
+	Match(db *gorm.DB, query string) *gorm.DB
+	// Content 查询中日志内容的列
+	Content() string
+	// Delete 删除运行的全部索引
+	Delete(tx *gorm.DB, runIDs []uint) error
+}
+
+// backendFor 按数据库类型选择索引实现
+func backendFor(db *gorm.DB) (LogSearch, error) {
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return &sqliteSearch{module: "fts5"}, nil
+	case "postgres":
+		return postgresSearch{}, nil
+	case "mysql":
+		return mysqlSearch{}, nil
+	}
+	return nil, fmt.Errorf("数据库 %s 不支持日志全文检索", db.Dialector.Name())
+}
+
+// phrase 将检索内容作为短语传给全文检索，由数据库按相同的分词规则切分；去掉其中的双引号以免改变查询语法
+func phrase(query string) string {
+	return `"` + strings.ReplaceAll(query, `"`, " ") + `"`
+}
+
+// deleteSegments 删除运行的索引段
+func deleteSegments(tx *gorm.DB, runIDs []uint) error {
+	return tx.Where("pipeline_run_id IN ?", runIDs).Delete(&models.RunLogSegment{}).Error
+}
+
+// sqliteSearch SQLite 全文检索：优先使用 FTS5，驱动未编译 FTS5（sqlite_fts5 构建标签）时使用 FTS4。
+// 日志内容只保存在虚拟表中
+type sqliteSearch struct {
+	module string
+}
+
+func (s *sqliteSearch) Name() string {
+	return "sqlite " + s.module
+}
+
+func (s *sqliteSearch) Prepare(db *gorm.DB) error {
+	var sql string
+	if err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", ftsTable).Scan(&sql).Error; err != nil {
+		return err
+	}
+	if sql != "" {
+		if strings.Contains(strings.ToLower(sql), "fts4") {
+			s.module = "fts4"
+		}
+		return nil
+	}
+
+	err := db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS " + ftsTable + " USING fts5(content, tokenize = 'unicode61')").Error
+	if err != nil && strings.Contains(err.Error(), "no such module") {
+		s.module = "fts4"
+		err = db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS " + ftsTable + " USING fts4(content, tokenize=unicode61)").Error
+	}
+	return err
+}
+
+func (s *sqliteSearch) Insert(tx *gorm.DB, segment *models.RunLogSegment) error {
+	content := segment.Content
+	segment.Content = ""
+	if err := tx.Create(segment).Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO "+ftsTable+" (rowid, content) VALUES (?, ?)", segment.ID, content).Error
+}
+
+func (s *sqliteSearch) Match(db *gorm.DB, query string) *gorm.DB {
+	return db.Table("run_log_segments AS s").
+		Joins("JOIN "+ftsTable+" ON "+ftsTable+".rowid = s.id").
+		Where(ftsTable+" MATCH ?", phrase(query))
+}
+
+func (s *sqliteSearch) Content() string {
+	return ftsTable + ".content"
+}
+
+func (s *sqliteSearch) Delete(tx *gorm.DB, runIDs []uint) error {
+	// 未开启过日志检索时没有虚拟表
+	if tx.Migrator().HasTable(ftsTable) {
+		if err := tx.Exec("DELETE FROM "+ftsTable+" WHERE rowid IN (SELECT id FROM run_log_segments WHERE pipeline_run_id IN ?)", runIDs).Error; err != nil {
+			return err
+		}
+	}
+	return deleteSegments(tx, runIDs)
+}
+
+// postgresSearch Postgres 全文检索：在 content 的 tsvector 表达式上建立 GIN 索引，使用 simple 配置不做词干处理
+type postgresSearch struct{}
+
+func (postgresSearch) Name() string {
+	return "postgres tsvector"
+}
+
+func (postgresSearch) Prepare(db *gorm.DB) error {
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_run_log_segments_search ON run_log_segments USING GIN (to_tsvector('simple', content))").Error
+}
+
+func (postgresSearch) Insert(tx *gorm.DB, segment *models.RunLogSegment) error {
+	return tx.Create(segment).Error
+}
+
+func (postgresSearch) Match(db *gorm.DB, query string) *gorm.DB {
+	return db.Table("run_log_segments AS s").
+		Where("to_tsvector('simple', s.content) @@ phraseto_tsquery('simple', ?)", query)
+}
+
+func (postgresSearch) Content() string {
+	return "s.content"
+}
+
+func (postgresSearch) Delete(tx *gorm.DB, runIDs []uint) error {
+	return deleteSegments(tx, runIDs)
+}
+
+// mysqlSearch MySQL 全文检索：content 上的 FULLTEXT 索引，按布尔模式匹配短语。
+// 短于 innodb_ft_min_token_size 的词与停用词不会被索引
+type mysqlSearch struct{}
+
+func (mysqlSearch) Name() string {
+	return "mysql fulltext"
+}
+
+func (mysqlSearch) Prepare(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&models.RunLogSegment{}, "idx_run_log_segments_content") {
+		return nil
+	}
+	return db.Exec("CREATE FULLTEXT INDEX idx_run_log_segments_content ON run_log_segments (content)").Error
+}
+
+func (mysqlSearch) Insert(tx *gorm.DB, segment *models.RunLogSegment) error {
+	return tx.Create(segment).Error
+}
+
+func (mysqlSearch) Match(db *gorm.DB, query string) *gorm.DB {
+	return db.Table("run_log_segments AS s").
+		Where("MATCH (s.content) AGAINST (? IN BOOLEAN MODE)", phrase(query))
+}
+
+func (mysqlSearch) Content() string {
+	return "s.content"
+}
+
+func (mysqlSearch) Delete(tx *gorm.DB, runIDs []uint) error {
+	return deleteSegments(tx, runIDs)
+}
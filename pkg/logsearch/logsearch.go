@@ -0,0 +1,376 @@
+package logsearch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// segmentBytes 每段索引的最大字节数，一次写入的日志更多时按行拆分为多段
+const segmentBytes = 32 * 1024
+
+// 检索结果的数量限制
+const (
+	defaultLimit    = 20
+	maxLimit        = 100
+	matchesPerRun   = 3   // 每个运行最多返回的匹配行
+	scannedSegments = 500 // 每次检索最多读取的匹配段
+)
+
+var (
+	// ErrDisabled 未开启日志全文检索
+	ErrDisabled = errors.New("日志全文检索未开启")
+	// ErrEmptyQuery 检索内容中没有可检索的词
+	ErrEmptyQuery = errors.New("检索内容不能为空")
+)
+
+// Stats 索引的统计指标
+type Stats struct {
+	Enabled        bool   `json:"enabled"`
+	Backend        string `json:"backend,omitempty"`
+	Queued         int    `json:"queued"`
+	IndexedBytes   int64  `json:"indexed_bytes"`
+	DroppedBatches int64  `json:"dropped_batches"` // 积压超过上限被丢弃的批次
+	Failures       int64  `json:"failures"`
+}
+
+// pendingLog 等待建立索引的一批日志
+type pendingLog struct {
+	runID uint
+	text  string
+	at    time.Time
+}
+
+// indexer 在独立的 goroutine 中建立索引，写入日志的一方只把日志放入队列，不等待索引完成
+type indexer struct {
+	backend  LogSearch
+	maxBytes int
+	queue    chan pendingLog
+	done     chan struct{}
+
+	indexedBytes   int64
+	droppedBatches int64
+	failures       int64
+}
+
+var (
+	mu      sync.RWMutex
+	current *indexer
+)
+
+// Init 开启日志全文检索时创建全文索引并开始在后台建立索引，未开启时不做任何事。
+// 多个实例同时启动时创建索引需与表结构迁移一起在迁移锁内执行
+func Init(cfg *config.LogSearchConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	backend, err := backendFor(database.DB)
+	if err != nil {
+		return err
+	}
+	if err := backend.Prepare(database.DB); err != nil {
+		return fmt.Errorf("创建日志全文索引失败: %w", err)
+	}
+
+	idx := &indexer{
+		backend:  backend,
+		maxBytes: cfg.MaxKBPerRun * 1024,
+		queue:    make(chan pendingLog, cfg.QueueBatches),
+		done:     make(chan struct{}),
+	}
+	go idx.loop()
+
+	mu.Lock()
+	current = idx
+	mu.Unlock()
+
+	log.Printf("运行日志全文检索已开启（%s）", backend.Name())
+	return nil
+}
+
+// Stop 停止接收日志，并为队列中剩余的日志建立索引
+func Stop() {
+	mu.Lock()
+	idx := current
+	current = nil
+	mu.Unlock()
+
+	if idx != nil {
+		close(idx.queue)
+		<-idx.done
+	}
+}
+
+// Enqueue 将已写入运行记录的一批日志（已脱敏）放入索引队列；未开启或队列已满时直接返回，不阻塞日志写入
+func Enqueue(runID uint, text string) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if current == nil || text == "" {
+		return
+	}
+	select {
+	case current.queue <- pendingLog{runID: runID, text: text, at: time.Now()}:
+	default:
+		atomic.AddInt64(&current.droppedBatches, 1)
+	}
+}
+
+// GetStats 索引的统计指标
+func GetStats() Stats {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if current == nil {
+		return Stats{}
+	}
+	return Stats{
+		Enabled:        true,
+		Backend:        current.backend.Name(),
+		Queued:         len(current.queue),
+		IndexedBytes:   atomic.LoadInt64(&current.indexedBytes),
+		DroppedBatches: atomic.LoadInt64(&current.droppedBatches),
+		Failures:       atomic.LoadInt64(&current.failures),
+	}
+}
+
+// Delete 删除运行的索引，由清理任务在删除运行日志时调用；未开启检索时也会删除之前建立的索引
+func Delete(runIDs ...uint) error {
+	if len(runIDs) == 0 || database.DB == nil {
+		return nil
+	}
+	backend, err := backendFor(database.DB)
+	if err != nil {
+		return nil
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return backend.Delete(tx, runIDs)
+	})
+}
+
+// loop 依次为队列中的日志建立索引
+func (idx *indexer) loop() {
+	defer close(idx.done)
+
+	for pending := range idx.queue {
+		if err := idx.index(pending); err != nil {
+			atomic.AddInt64(&idx.failures, 1)
+			log.Printf("为运行 %d 的日志建立索引失败: %v", pending.runID, err)
+		}
+	}
+}
+
+// index 为一批日志建立索引：接在运行已索引内容之后编号，超过每个运行的上限后不再索引
+func (idx *indexer) index(pending pendingLog) error {
+	var indexed struct {
+		Size      int
+		LineCount int
+	}
+	if err := database.DB.Model(&models.RunLogSegment{}).
+		Select("COALESCE(SUM(size), 0) AS size, COALESCE(SUM(line_count), 0) AS line_count").
+		Where("pipeline_run_id = ?", pending.runID).
+		Scan(&indexed).Error; err != nil {
+		return err
+	}
+
+	remaining := idx.maxBytes - indexed.Size
+	if remaining <= 0 {
+		return nil
+	}
+
+	segments := split(strings.TrimSuffix(pending.text, "\n"), remaining)
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		line := indexed.LineCount + 1
+		for _, content := range segments {
+			segment := &models.RunLogSegment{
+				CreatedAt:     pending.at,
+				PipelineRunID: pending.runID,
+				StartLine:     line,
+				LineCount:     strings.Count(content, "\n") + 1,
+				Size:          len(content),
+				Content:       content,
+			}
+			if err := idx.backend.Insert(tx, segment); err != nil {
+				return err
+			}
+			line += segment.LineCount
+			atomic.AddInt64(&idx.indexedBytes, int64(segment.Size))
+		}
+		return nil
+	})
+}
+
+// split 按行将日志拆分为不超过 segmentBytes 的段，总计不超过 budget 字节，超出部分截断；过长的行按字节截断
+func split(text string, budget int) []string {
+	var segments []string
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if len(line) > segmentBytes {
+			line = truncate(line, segmentBytes)
+		}
+		if b.Len() > 0 && b.Len()+1+len(line) > segmentBytes {
+			segments = append(segments, b.String())
+			b.Reset()
+		}
+		sep := 0
+		if b.Len() > 0 {
+			sep = 1
+		}
+		if budget-sep <= 0 {
+			break
+		}
+		if len(line) > budget-sep {
+			line = truncate(line, budget-sep)
+		}
+		if sep > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+		budget -= sep + len(line)
+	}
+	if b.Len() > 0 {
+		segments = append(segments, b.String())
+	}
+	return segments
+}
+
+// truncate 截取不超过 n 字节的前缀，不截断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Query 日志检索条件
+type Query struct {
+	Text      string
+	ProjectID uint                    // 为 0 时检索所有可见的项目
+	Scope     func(*gorm.DB) *gorm.DB // 按当前用户的权限筛选，作用于 projects 表
+	Limit     int                     // 返回的运行数，默认 20，最多 100
+}
+
+// Result 提到检索内容的一次运行
+type Result struct {
+	RunID        uint      `json:"run_id"`
+	RunNumber    int       `json:"run_number"`
+	Status       string    `json:"status"`
+	RunCreatedAt time.Time `json:"run_created_at"`
+	PipelineID   uint      `json:"pipeline_id"`
+	PipelineName string    `json:"pipeline_name"`
+	ProjectID    uint      `json:"project_id"`
+	ProjectName  string    `json:"project_name"`
+	Matches      []Match   `json:"matches"`
+}
+
+// Match 运行日志中的一处匹配
+type Match struct {
+	Line     int       `json:"line"`      // 在运行日志中的行号，从 1 开始
+	LoggedAt time.Time `json:"logged_at"` // 该行所在批次写入的时间
+	Snippet  Snippet   `json:"snippet"`
+}
+
+// matchedSegment 检索查询读取的一段
+type matchedSegment struct {
+	ID            uint
+	PipelineRunID uint
+	StartLine     int
+	CreatedAt     time.Time
+	Content       string
+	RunNumber     int
+	Status        string
+	RunCreatedAt  time.Time
+	PipelineID    uint
+	PipelineName  string
+	ProjectID     uint
+	ProjectName   string
+}
+
+// Search 检索提到 q.Text 的运行，最近写入匹配日志的运行在前，每个运行返回最相关的几行摘要
+func Search(q Query) ([]Result, error) {
+	mu.RLock()
+	idx := current
+	mu.RUnlock()
+	if idx == nil {
+		return nil, ErrDisabled
+	}
+	if len(Terms(q.Text)) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	query := idx.backend.Match(database.DB, q.Text).
+		Select("s.id, s.pipeline_run_id, s.start_line, s.created_at, " + idx.backend.Content() + " AS content, " +
+			"pipeline_runs.run_number, pipeline_runs.status, pipeline_runs.created_at AS run_created_at, " +
+			"pipelines.id AS pipeline_id, pipelines.name AS pipeline_name, projects.id AS project_id, projects.name AS project_name").
+		Joins("JOIN pipeline_runs ON pipeline_runs.id = s.pipeline_run_id AND pipeline_runs.deleted_at IS NULL").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id AND pipelines.deleted_at IS NULL").
+		Joins("JOIN projects ON projects.id = pipelines.project_id AND projects.deleted_at IS NULL")
+	if q.ProjectID != 0 {
+		query = query.Where("projects.id = ?", q.ProjectID)
+	}
+	if q.Scope != nil {
+		query = q.Scope(query)
+	}
+
+	var segments []matchedSegment
+	if err := query.Order("s.id DESC").Limit(scannedSegments).Scan(&segments).Error; err != nil {
+		return nil, fmt.Errorf("检索日志失败: %w", err)
+	}
+
+	results := make([]Result, 0, limit)
+	byRun := make(map[uint]int)
+	for _, segment := range segments {
+		snippet, line, ok := ExtractSnippet(segment.Content, q.Text)
+		if !ok {
+			continue
+		}
+		i, seen := byRun[segment.PipelineRunID]
+		if !seen {
+			if len(results) == limit {
+				continue
+			}
+			i = len(results)
+			byRun[segment.PipelineRunID] = i
+			results = append(results, Result{
+				RunID:        segment.PipelineRunID,
+				RunNumber:    segment.RunNumber,
+				Status:       segment.Status,
+				RunCreatedAt: segment.RunCreatedAt,
+				PipelineID:   segment.PipelineID,
+				PipelineName: segment.PipelineName,
+				ProjectID:    segment.ProjectID,
+				ProjectName:  segment.ProjectName,
+			})
+		}
+		if len(results[i].Matches) < matchesPerRun {
+			results[i].Matches = append(results[i].Matches, Match{
+				Line:     segment.StartLine + line,
+				LoggedAt: segment.CreatedAt,
+				Snippet:  snippet,
+			})
+		}
+	}
+	return results, nil
+}
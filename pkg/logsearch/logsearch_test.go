@@ -0,0 +1,249 @@
+package logsearch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// searchRuns 检索用的运行：web 项目属于用户 1，secret 项目属于用户 2
+type searchRuns struct {
+	web, retry, reordered, large *models.PipelineRun
+	secret                       *models.PipelineRun
+	webProject, secretProject    *models.Project
+}
+
+// setupSearch 连接数据库并完成迁移，清空检索用到的表后创建两个项目、各一条流水线与其中的运行
+func setupSearch(t *testing.T, cfg *config.Config) *searchRuns {
+	t.Helper()
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	// 其他数据库上的测试库可能留有上次执行的数据
+	Delete(allRunIDs(t)...)
+	for _, model := range []interface{}{&models.PipelineRun{}, &models.Pipeline{}, &models.Project{}, &models.User{}} {
+		if err := database.DB.Unscoped().Where("1 = 1").Delete(model).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs := &searchRuns{}
+	for i, name := range []string{"web", "secret"} {
+		user := &models.User{Username: name + "-owner", Email: name + "@example.com", Password: "x", Role: models.RoleUser, Status: models.StatusActive}
+		if err := database.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+		project := &models.Project{Name: name, Slug: name, RepoURL: "https://git.example/" + name + ".git", UserID: user.ID}
+		if err := database.DB.Create(project).Error; err != nil {
+			t.Fatal(err)
+		}
+		pipeline := &models.Pipeline{Name: "build", ProjectID: project.ID}
+		if err := database.DB.Create(pipeline).Error; err != nil {
+			t.Fatal(err)
+		}
+		run := func(number int) *models.PipelineRun {
+			run := &models.PipelineRun{PipelineID: pipeline.ID, UserID: user.ID, RunNumber: number, Status: models.RunStatusFailed, TriggerType: models.TriggerManual}
+			if err := database.DB.Create(run).Error; err != nil {
+				t.Fatal(err)
+			}
+			return run
+		}
+		if i == 0 {
+			runs.webProject = project
+			runs.web, runs.retry, runs.reordered, runs.large = run(1), run(2), run(3), run(4)
+		} else {
+			runs.secretProject = project
+			runs.secret = run(1)
+		}
+	}
+	return runs
+}
+
+// allRunIDs 已有的全部运行
+func allRunIDs(t *testing.T) []uint {
+	t.Helper()
+	var ids []uint
+	if err := database.DB.Unscoped().Model(&models.PipelineRun{}).Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// flushIndex 停止索引并等待队列中的日志建立索引后重新开启，返回停止前的统计
+func flushIndex(t *testing.T, cfg *config.LogSearchConfig) Stats {
+	t.Helper()
+	stats := GetStats()
+	mu.RLock()
+	idx := current
+	mu.RUnlock()
+	Stop()
+	stats.IndexedBytes = atomic.LoadInt64(&idx.indexedBytes)
+	stats.Failures = atomic.LoadInt64(&idx.failures)
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+// found 检索结果中的运行与每处匹配的行号
+func found(t *testing.T, q Query) []string {
+	t.Helper()
+	results, err := Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, result := range results {
+		lines := make([]string, len(result.Matches))
+		for i, match := range result.Matches {
+			lines[i] = fmt.Sprint(match.Line)
+		}
+		got = append(got, fmt.Sprintf("%s#%d:%s", result.ProjectName, result.RunNumber, strings.Join(lines, ",")))
+	}
+	return got
+}
+
+// testSearch 各数据库共用的检索测试：按短语匹配并按权限筛选，行号接在运行已索引的内容之后，
+// 每个运行的索引不超过上限，删除运行的索引后不再返回该运行
+func testSearch(t *testing.T, dbConfig *config.Config) {
+	runs := setupSearch(t, dbConfig)
+	cfg := &config.LogSearchConfig{Enabled: true, MaxKBPerRun: 1, QueueBatches: 100}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Stop)
+
+	Enqueue(runs.web.ID, "npm install\nnpm ERR! code ERESOLVE\n")
+	Enqueue(runs.retry.ID, "npm ERR! code ERESOLVE\n")
+	Enqueue(runs.reordered.ID, "code npm ERESOLVE\n")
+	Enqueue(runs.secret.ID, "npm ERR! code ERESOLVE\n")
+	Enqueue(runs.web.ID, "retrying\nnpm ERR! code ERESOLVE unable to resolve dependency tree\n")
+	Enqueue(runs.large.ID, strings.Repeat("compiling module\n", 100)+"linker failed: undefined symbol\n")
+	if stats := flushIndex(t, cfg); !stats.Enabled || stats.Failures != 0 || stats.DroppedBatches != 0 || stats.IndexedBytes == 0 {
+		t.Errorf("统计为 %+v", stats)
+	}
+
+	ownWeb := func(db *gorm.DB) *gorm.DB { return db.Where("projects.user_id = ?", runs.webProject.UserID) }
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"短语", Query{Text: "npm ERR! code ERESOLVE"}, []string{"web#1:4,2", "secret#1:1", "web#2:1"}},
+		{"大小写与标点", Query{Text: "err: CODE"}, []string{"web#1:4,2", "secret#1:1", "web#2:1"}},
+		{"行号接在已索引的内容之后", Query{Text: "dependency tree"}, []string{"web#1:4"}},
+		{"按权限筛选", Query{Text: "ERESOLVE", Scope: ownWeb}, []string{"web#1:4,2", "web#3:1", "web#2:1"}},
+		{"按项目筛选", Query{Text: "ERESOLVE", ProjectID: runs.secretProject.ID}, []string{"secret#1:1"}},
+		{"没有权限的项目", Query{Text: "ERESOLVE", ProjectID: runs.secretProject.ID, Scope: ownWeb}, nil},
+		{"返回的运行数", Query{Text: "ERESOLVE", Limit: 1}, []string{"web#1:4,2"}},
+		{"检索内容中的引号", Query{Text: `"ERESOLVE unable`}, []string{"web#1:4"}},
+		{"超过上限的内容", Query{Text: "undefined symbol"}, nil},
+		{"上限内的内容", Query{Text: "compiling module"}, []string{"web#4:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := found(t, tt.q); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("检索 %q 返回 %q，应为 %q", tt.q.Text, got, tt.want)
+			}
+		})
+	}
+
+	var size int
+	database.DB.Model(&models.RunLogSegment{}).Select("COALESCE(SUM(size), 0)").Where("pipeline_run_id = ?", runs.large.ID).Scan(&size)
+	if size > cfg.MaxKBPerRun*1024 {
+		t.Errorf("运行索引了 %d 字节，应不超过 %d 字节", size, cfg.MaxKBPerRun*1024)
+	}
+
+	if _, err := Search(Query{Text: " !! "}); !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("没有检索词时返回 %v，应为 ErrEmptyQuery", err)
+	}
+
+	if err := Delete(runs.web.ID, runs.secret.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := found(t, Query{Text: "ERESOLVE"}); !reflect.DeepEqual(got, []string{"web#3:1", "web#2:1"}) {
+		t.Errorf("删除索引后检索返回 %q", got)
+	}
+	var left int64
+	database.DB.Model(&models.RunLogSegment{}).Where("pipeline_run_id IN ?", []uint{runs.web.ID, runs.secret.ID}).Count(&left)
+	if left != 0 {
+		t.Errorf("删除后仍有 %d 段索引", left)
+	}
+}
+
+// TestSQLiteSearch SQLite 全文检索
+func TestSQLiteSearch(t *testing.T) {
+	testSearch(t, &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}})
+}
+
+// TestEnqueueDoesNotBlock 索引跟不上时丢弃新的日志并计数，不阻塞日志写入；未开启时不接收日志
+func TestEnqueueDoesNotBlock(t *testing.T) {
+	Enqueue(1, "ignored\n")
+	if stats := GetStats(); stats.Enabled {
+		t.Errorf("未开启时统计为 %+v", stats)
+	}
+
+	mu.Lock()
+	current = &indexer{backend: &sqliteSearch{module: "fts5"}, queue: make(chan pendingLog, 1)}
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+	})
+
+	Enqueue(1, "first\n")
+	Enqueue(1, "second\n")
+	Enqueue(1, "")
+	if stats := GetStats(); stats.Queued != 1 || stats.DroppedBatches != 1 {
+		t.Errorf("统计为 %+v，应排队 1 批并丢弃 1 批", stats)
+	}
+}
+
+// TestSplit 按行拆分为不超过 segmentBytes 的段，超过预算的内容截断，不截断多字节字符
+func TestSplit(t *testing.T) {
+	long := strings.Repeat("a", segmentBytes-10)
+	tests := []struct {
+		name   string
+		text   string
+		budget int
+		want   []int // 每段的字节数
+	}{
+		{"一段", "a\nbb\nccc", 100, []int{8}},
+		{"按行拆分", long + "\n" + long, 2 * segmentBytes, []int{segmentBytes - 10, segmentBytes - 10}},
+		{"过长的行", strings.Repeat("b", segmentBytes+10), 2 * segmentBytes, []int{segmentBytes}},
+		{"超过预算", "aaaa\nbbbb\ncccc", 7, []int{7}},
+		{"多字节字符", "构建失败", 7, []int{6}},
+		{"预算用完", "aaaa\nbbbb", 4, []int{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, segment := range split(tt.text, tt.budget) {
+				got = append(got, len(segment))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("拆分为 %v 字节，应为 %v", got, tt.want)
+			}
+		})
+	}
+}
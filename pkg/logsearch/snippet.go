@@ -0,0 +1,190 @@
+package logsearch
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snippetWidth 摘要的最大字节数，匹配行更长时截取命中位置附近的内容
+const snippetWidth = 240
+
+// Snippet 匹配行的摘要：Parts 依次拼接即为 Text，Match 为 true 的部分是命中的检索词
+type Snippet struct {
+	Text  string        `json:"text"`
+	Parts []SnippetPart `json:"parts"`
+}
+
+// SnippetPart 摘要的一段
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// token 文本中的一个词，start 与 end 为字节位置
+type token struct {
+	start, end int
+	lower      string
+}
+
+// tokenize 按字母与数字以外的字符切分文本，与数据库全文检索的分词方式一致
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{start: start, end: i, lower: strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start: start, end: len(text), lower: strings.ToLower(text[start:])})
+	}
+	return tokens
+}
+
+// Terms 检索内容切分出的检索词，转为小写并保持顺序
+func Terms(query string) []string {
+	tokens := tokenize(query)
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.lower
+	}
+	return terms
+}
+
+// lineScore 一行与检索内容的相关度，依次比较：是否按顺序包含全部检索词、包含的不同检索词数、命中次数
+type lineScore struct {
+	phrase   bool
+	distinct int
+	hits     int
+	first    int // 第一处命中（或完整检索内容）的字节位置
+}
+
+func (s lineScore) better(other lineScore) bool {
+	if s.phrase != other.phrase {
+		return s.phrase
+	}
+	if s.distinct != other.distinct {
+		return s.distinct > other.distinct
+	}
+	return s.hits > other.hits
+}
+
+// scoreLine 计算一行的相关度，wanted 为检索词集合
+func scoreLine(tokens []token, terms []string, wanted map[string]bool) lineScore {
+	score := lineScore{first: -1}
+	seen := make(map[string]bool)
+	for i, t := range tokens {
+		if !wanted[t.lower] {
+			continue
+		}
+		score.hits++
+		if !seen[t.lower] {
+			seen[t.lower] = true
+			score.distinct++
+		}
+		if score.first < 0 {
+			score.first = t.start
+		}
+		if !score.phrase && i+len(terms) <= len(tokens) {
+			matched := true
+			for j, term := range terms {
+				if tokens[i+j].lower != term {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				score.phrase = true
+				score.first = t.start
+			}
+		}
+	}
+	return score
+}
+
+// ExtractSnippet 从一段日志中选出与检索内容最相关的一行生成摘要，line 为该行在 content 中的序号（从 0 开始）。
+// 相关度相同时取靠前的行；没有任何一行包含检索词时 ok 为 false
+func ExtractSnippet(content, query string) (snippet Snippet, line int, ok bool) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return Snippet{}, 0, false
+	}
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	var best lineScore
+	var bestText string
+	var bestTokens []token
+	for i, text := range strings.Split(content, "\n") {
+		tokens := tokenize(text)
+		score := scoreLine(tokens, terms, wanted)
+		if score.hits == 0 || (ok && !score.better(best)) {
+			continue
+		}
+		best, bestText, bestTokens, line, ok = score, text, tokens, i, true
+	}
+	if !ok {
+		return Snippet{}, 0, false
+	}
+	return buildSnippet(bestText, bestTokens, wanted, best.first), line, true
+}
+
+// buildSnippet 截取命中位置附近不超过 snippetWidth 的内容，标出其中的检索词
+func buildSnippet(text string, tokens []token, wanted map[string]bool, first int) Snippet {
+	start, end := 0, len(text)
+	if len(text) > snippetWidth {
+		start = first - snippetWidth/3
+		if start < 0 {
+			start = 0
+		}
+		end = start + snippetWidth
+		if end > len(text) {
+			end = len(text)
+			start = end - snippetWidth
+		}
+		for start < end && !utf8.RuneStart(text[start]) {
+			start++
+		}
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end--
+		}
+	}
+
+	var parts []SnippetPart
+	if start > 0 {
+		parts = append(parts, SnippetPart{Text: "…"})
+	}
+	pos := start
+	for _, t := range tokens {
+		if !wanted[t.lower] || t.start < start || t.end > end {
+			continue
+		}
+		if t.start > pos {
+			parts = append(parts, SnippetPart{Text: text[pos:t.start]})
+		}
+		parts = append(parts, SnippetPart{Text: text[t.start:t.end], Match: true})
+		pos = t.end
+	}
+	if pos < end {
+		parts = append(parts, SnippetPart{Text: text[pos:end]})
+	}
+	if end < len(text) {
+		parts = append(parts, SnippetPart{Text: "…"})
+	}
+
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.Text)
+	}
+	return Snippet{Text: b.String(), Parts: parts}
+}
@@ -0,0 +1,86 @@
+package logsearch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestTerms 检索词按字母与数字切分并转为小写，中文连续的字符为一个词
+func TestTerms(t *testing.T) {
+	if got := Terms(`npm ERR! code "ERESOLVE"`); !reflect.DeepEqual(got, []string{"npm", "err", "code", "eresolve"}) {
+		t.Errorf("检索词为 %q", got)
+	}
+	if got := Terms("构建失败: exit-1"); !reflect.DeepEqual(got, []string{"构建失败", "exit", "1"}) {
+		t.Errorf("检索词为 %q", got)
+	}
+	if got := Terms(" !! -- "); len(got) != 0 {
+		t.Errorf("只有标点时检索词为 %q", got)
+	}
+}
+
+// TestExtractSnippetRelevance 摘要取最相关的行：按顺序包含完整检索内容的行优先，其次包含的不同检索词多的行，
+// 再次命中次数多的行，相关度相同时取靠前的行
+func TestExtractSnippetRelevance(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		line    int
+	}{
+		{"完整短语优先于命中更多", "refused refused, connection reset, connection lost\ndial tcp: connection refused", "connection refused", 1},
+		{"不同检索词多的行优先", "timeout timeout timeout\ncontext deadline timeout", "deadline timeout exceeded", 1},
+		{"命中次数多的行优先", "warn: disk\ndisk full on /dev/sda, disk quota", "disk space", 1},
+		{"相关度相同时取靠前的行", "error: a\nerror: b", "error", 0},
+		{"忽略大小写", "\nFATAL: Out Of Memory", "out of memory", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, line, ok := ExtractSnippet(tt.content, tt.query)
+			if !ok || line != tt.line {
+				t.Errorf("选择第 %d 行（%v），应为第 %d 行", line, ok, tt.line)
+			}
+		})
+	}
+
+	if _, _, ok := ExtractSnippet("build ok\ntests ok", "ERESOLVE"); ok {
+		t.Error("没有包含检索词的行时应返回 false")
+	}
+	// 检索词须是完整的词
+	if _, _, ok := ExtractSnippet("ERESOLVED", "ERESOLVE"); ok {
+		t.Error("检索词只是其他词的一部分时应返回 false")
+	}
+}
+
+// TestExtractSnippetParts 摘要标出其中的检索词，拼接各部分即为摘要；过长的行截取命中位置附近的内容并加省略号
+func TestExtractSnippetParts(t *testing.T) {
+	snippet, _, _ := ExtractSnippet("npm ERR! code ERESOLVE", "code eresolve")
+	want := []SnippetPart{{Text: "npm ERR! "}, {Text: "code", Match: true}, {Text: " "}, {Text: "ERESOLVE", Match: true}}
+	if !reflect.DeepEqual(snippet.Parts, want) || snippet.Text != "npm ERR! code ERESOLVE" {
+		t.Errorf("摘要为 %+v", snippet)
+	}
+
+	line := strings.Repeat("编译 ", 200) + "error: undefined symbol " + strings.Repeat("x", 500)
+	snippet, _, _ = ExtractSnippet(line, "undefined symbol")
+	if !strings.HasPrefix(snippet.Text, "…") || !strings.HasSuffix(snippet.Text, "…") || !strings.Contains(snippet.Text, "undefined symbol") {
+		t.Errorf("摘要为 %q，应截取命中位置附近的内容", snippet.Text)
+	}
+	if n := len(strings.Trim(snippet.Text, "…")); n > snippetWidth {
+		t.Errorf("摘要为 %d 字节，应不超过 %d 字节", n, snippetWidth)
+	}
+	if !strings.Contains(snippet.Text, "编译") || !utf8.ValidString(snippet.Text) {
+		t.Errorf("摘要为 %q，应保留命中位置之前的内容并在字符边界截取", snippet.Text)
+	}
+	var b strings.Builder
+	matched := 0
+	for _, part := range snippet.Parts {
+		b.WriteString(part.Text)
+		if part.Match {
+			matched++
+		}
+	}
+	if b.String() != snippet.Text || matched != 2 {
+		t.Errorf("摘要的各部分为 %+v，应拼接为摘要并标出 2 个检索词", snippet.Parts)
+	}
+}
@@ -0,0 +1,181 @@
+package logship
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"flowforge/pkg/httpclient"
+)
+
+// 默认的批次大小与发送间隔
+const (
+	defaultBatchLines = 500
+	defaultInterval   = time.Second
+	maxBufferedLines  = 50000
+)
+
+// line 待上报的一行日志
+type line struct {
+	Seq  uint64 `json:"seq"`
+	Line string `json:"line"`
+}
+
+// Shipper 执行器一侧的日志上报：日志先缓冲，按批次 gzip 压缩为 NDJSON 发送到服务端，
+// 服务端确认的序号之前的行从缓冲移除，未确认的行在下次发送时重传
+type Shipper struct {
+	url    string
+	token  string
+	client *http.Client
+
+	BatchLines int           // 每个请求最多发送的行数
+	Interval   time.Duration // Run 定期发送的间隔
+
+	mu      sync.Mutex
+	nextSeq uint64
+	buffer  []line // 按序号排列、尚未被确认的日志
+	dropped int64
+}
+
+// New 创建日志上报器，url 与 token 取自步骤环境变量 LOG_INGEST_URL、LOG_INGEST_TOKEN；
+// 请求经共享的出站传输层发送，使用配置的代理与CA证书
+func New(url, token string) *Shipper {
+	return &Shipper{
+		url:        url,
+		token:      token,
+		client:     httpclient.New(30 * time.Second),
+		BatchLines: defaultBatchLines,
+		Interval:   defaultInterval,
+		nextSeq:    1,
+	}
+}
+
+// Add 缓冲一行日志；缓冲已满时丢弃并计数，不阻塞执行
+func (s *Shipper) Add(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buffer) >= maxBufferedLines {
+		s.dropped++
+		return
+	}
+	s.buffer = append(s.buffer, line{Seq: s.nextSeq, Line: text})
+	s.nextSeq++
+}
+
+// Dropped 因缓冲已满丢弃的行数
+func (s *Shipper) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Run 定期发送缓冲的日志，ctx 结束时停止；停止后调用 Flush 发送剩余日志
+func (s *Shipper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Flush(ctx)
+		}
+	}
+}
+
+// Flush 发送缓冲中的全部日志，直到全部被确认或发送失败
+func (s *Shipper) Flush(ctx context.Context) error {
+	for {
+		batch := s.pending()
+		if len(batch) == 0 {
+			return nil
+		}
+		acked, err := s.send(ctx, batch)
+		s.ack(acked)
+		if err != nil {
+			return err
+		}
+		// 服务端未确认任何新行（缺失的行仍在途中）时等待下次发送，避免重复请求
+		if acked < batch[0].Seq {
+			return nil
+		}
+	}
+}
+
+// pending 取出下一批未确认的日志
+func (s *Shipper) pending() []line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.buffer)
+	if n > s.BatchLines {
+		n = s.BatchLines
+	}
+	return append([]line(nil), s.buffer[:n]...)
+}
+
+// ack 移除服务端已确认的日志
+func (s *Shipper) ack(acked uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := 0
+	for i < len(s.buffer) && s.buffer[i].Seq <= acked {
+		i++
+	}
+	s.buffer = s.buffer[i:]
+}
+
+// send 发送一批日志，返回服务端已连续写入的最大序号
+func (s *Shipper) send(ctx context.Context, batch []line) (uint64, error) {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	encoder := json.NewEncoder(gz)
+	for _, item := range batch {
+		if err := encoder.Encode(item); err != nil {
+			return 0, fmt.Errorf("编码日志失败: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return 0, fmt.Errorf("压缩日志失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return 0, fmt.Errorf("创建上报请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("上报日志失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Acked uint64 `json:"acked"`
+		} `json:"data"`
+		Acked uint64 `json:"acked"` // 超过速率限制时的响应
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return result.Data.Acked, nil
+	case http.StatusTooManyRequests:
+		return result.Acked, fmt.Errorf("上报日志超过速率限制")
+	default:
+		return 0, fmt.Errorf("上报日志失败: HTTP %d %s", resp.StatusCode, result.Error)
+	}
+}
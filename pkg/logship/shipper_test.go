@@ -0,0 +1,144 @@
+package logship
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stressLines 压力测试上报的日志行数
+const stressLines = 1000000
+
+// ingestServer 模拟服务端的日志接收：只接受紧接已确认序号的行，按请求次数注入部分确认与失败的响应
+type ingestServer struct {
+	mu       sync.Mutex
+	acked    uint64
+	lines    []string
+	requests int
+	err      error
+}
+
+func (s *ingestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if s.requests%13 == 0 {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		s.err = err
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var batch []line
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var item line
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			s.err = err
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batch = append(batch, item)
+	}
+	// 部分确认：只写入前一半，其余的行应在之后重传
+	if s.requests%7 == 0 {
+		batch = batch[:len(batch)/2]
+	}
+	for _, item := range batch {
+		if item.Seq == s.acked+1 {
+			s.acked = item.Seq
+			s.lines = append(s.lines, item.Line)
+		}
+	}
+	fmt.Fprintf(w, `{"data":{"acked":%d}}`, s.acked)
+}
+
+// buffered 上报器当前缓冲的行数
+func (s *Shipper) buffered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buffer)
+}
+
+// TestShipperStress 上报 100 万行日志：缓冲不超过 maxBufferedLines，突发写入超出的行丢弃并计数；
+// 失败与部分确认后重传，服务端按原顺序收到每一行且不重复，收到的与丢弃的行数之和等于写入的行数
+func TestShipperStress(t *testing.T) {
+	server := &ingestServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	shipper := New(ts.URL, "token")
+	shipper.Interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		shipper.Run(ctx)
+	}()
+
+	// 前 90% 的行以上报跟得上的速度写入，不应丢弃；最后一段突发写入超过缓冲上限，多出的行丢弃
+	steady := stressLines - stressLines/10
+	maxBuffered := 0
+	for i := 1; i <= stressLines; i++ {
+		shipper.Add("line " + strconv.Itoa(i))
+		if i%1000 != 0 {
+			continue
+		}
+		n := shipper.buffered()
+		if n > maxBuffered {
+			maxBuffered = n
+		}
+		for i <= steady && n > maxBufferedLines/2 {
+			time.Sleep(time.Millisecond)
+			n = shipper.buffered()
+		}
+	}
+	if n := shipper.buffered(); n > maxBuffered {
+		maxBuffered = n
+	}
+	cancel()
+	<-done
+	for attempt := 0; shipper.buffered() > 0; attempt++ {
+		if attempt > 100 {
+			t.Fatalf("仍有 %d 行未被确认", shipper.buffered())
+		}
+		shipper.Flush(context.Background())
+	}
+
+	if maxBuffered > maxBufferedLines {
+		t.Errorf("缓冲达到 %d 行，超过上限 %d", maxBuffered, maxBufferedLines)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.err != nil {
+		t.Fatal(server.err)
+	}
+	if got := int64(len(server.lines)) + shipper.Dropped(); got != stressLines || len(server.lines) < steady {
+		t.Fatalf("收到 %d 行、丢弃 %d 行，合计应为 %d，且至少收到稳定写入的 %d 行", len(server.lines), shipper.Dropped(), stressLines, steady)
+	}
+	if shipper.Dropped() == 0 {
+		t.Error("突发写入超过缓冲上限时应丢弃并计数")
+	}
+	last := 0
+	for i, text := range server.lines {
+		n, err := strconv.Atoi(strings.TrimPrefix(text, "line "))
+		if err != nil || n <= last {
+			t.Fatalf("第 %d 行为 %q，前一行为 line %d，顺序错乱或重复", i+1, text, last)
+		}
+		last = n
+	}
+}
@@ -0,0 +1,700 @@
+// Package markdown 将 Markdown 渲染为安全的 HTML。
+//
+// 渲染器不透传任何原始 HTML：源文本中的标签一律转义输出，生成的 HTML 只包含
+// 白名单中的标签与属性（见 allowedTags），链接与图片地址只允许 http、https、
+// mailto（仅链接）和页内锚点，相对地址交给 Options.ResolveLink 转换。
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// allowedTags 渲染器可能输出的全部标签；属性仅有 a 的 href/title/rel、img 的 src/alt/title、
+// code 的 class（language-xxx）。新增输出标签时需同步更新此列表
+var allowedTags = []string{
+	"h1", "h2", "h3", "h4", "h5", "h6", "p", "br", "hr", "blockquote",
+	"ul", "ol", "li", "pre", "code", "em", "strong", "del", "a", "img",
+	"table", "thead", "tbody", "tr", "th", "td",
+}
+
+// AllowedTags 返回渲染器可能输出的标签白名单
+func AllowedTags() []string {
+	return append([]string(nil), allowedTags...)
+}
+
+// Options 渲染选项
+type Options struct {
+	// ResolveLink 将相对地址转换为绝对地址，image 表示图片地址；返回空字符串时丢弃该链接，
+	// 为 nil 时丢弃所有相对链接
+	ResolveLink func(target string, image bool) string
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?[ \t#]*$`)
+	fenceRe    = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^ \t`]*)")
+	hrRe       = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	listItemRe = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	tableSepRe = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	languageRe = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+	bareURLRe  = regexp.MustCompile(`^https?://[^\s<>"]+`)
+	autolinkRe = regexp.MustCompile(`^<((?:https?|mailto):[^\s<>]+)>`)
+)
+
+// trailingPunct 裸链接末尾不计入地址的标点
+const trailingPunct = ".,:;!?'\")"
+
+// Render 将 Markdown 渲染为 HTML
+func Render(src []byte, opts Options) string {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ReplaceAll(text, "\x00", "\uFFFD")
+
+	r := &renderer{opts: opts}
+	r.blocks(strings.Split(text, "\n"), false)
+	return r.out.String()
+}
+
+// renderer 渲染状态
+type renderer struct {
+	opts Options
+	out  strings.Builder
+}
+
+// blocks 渲染块级元素，tight 为 true 时段落不加 <p>（紧凑列表项）
+func (r *renderer) blocks(lines []string, tight bool) {
+	var para []string
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		content := r.inline(strings.TrimRight(strings.Join(para, "\n"), " "))
+		if tight {
+			r.out.WriteString(content)
+		} else {
+			r.out.WriteString("<p>" + content + "</p>\n")
+		}
+		para = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := expandTabs(lines[i])
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			flush()
+			i = r.fencedCode(lines, i, m[1], m[2])
+			continue
+		}
+
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			flush()
+			level := strconv.Itoa(len(m[1]))
+			r.out.WriteString("<h" + level + ">" + r.inline(m[2]) + "</h" + level + ">\n")
+			continue
+		}
+
+		if hrRe.MatchString(line) {
+			flush()
+			r.out.WriteString("<hr>\n")
+			continue
+		}
+
+		if isQuote(line) {
+			flush()
+			i = r.blockquote(lines, i)
+			continue
+		}
+
+		if listItemRe.MatchString(line) {
+			flush()
+			i = r.list(lines, i)
+			continue
+		}
+
+		if len(para) == 0 && i+1 < len(lines) && strings.Contains(line, "|") && tableSepRe.MatchString(lines[i+1]) {
+			i = r.table(lines, i)
+			continue
+		}
+
+		// 缩进代码块（不在段落中时）
+		if len(para) == 0 && strings.HasPrefix(line, "    ") {
+			i = r.indentedCode(lines, i)
+			continue
+		}
+
+		para = append(para, strings.TrimLeft(line, " "))
+	}
+	flush()
+}
+
+// fencedCode 渲染围栏代码块，返回最后消费的行号
+func (r *renderer) fencedCode(lines []string, start int, fence, lang string) int {
+	var code []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence[:1]) && strings.Trim(trimmed, fence[:1]) == "" && len(trimmed) >= len(fence) {
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	r.out.WriteString("<pre><code")
+	if languageRe.MatchString(lang) {
+		r.out.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	r.out.WriteString(">")
+	for _, line := range code {
+		r.out.WriteString(html.EscapeString(line) + "\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+	return i
+}
+
+// indentedCode 渲染缩进四格的代码块，返回最后消费的行号
+func (r *renderer) indentedCode(lines []string, start int) int {
+	var code []string
+	i := start
+	for ; i < len(lines); i++ {
+		line := expandTabs(lines[i])
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "    ") {
+			break
+		}
+		code = append(code, strings.TrimPrefix(line, "    "))
+	}
+	for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+		code = code[:len(code)-1]
+	}
+
+	r.out.WriteString("<pre><code>")
+	for _, line := range code {
+		r.out.WriteString(html.EscapeString(line) + "\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+	return i - 1
+}
+
+// isQuote 是否为引用行
+func isQuote(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " "), ">") && len(line)-len(strings.TrimLeft(line, " ")) <= 3
+}
+
+// blockquote 渲染引用块，返回最后消费的行号
+func (r *renderer) blockquote(lines []string, start int) int {
+	var inner []string
+	i := start
+	for ; i < len(lines); i++ {
+		line := expandTabs(lines[i])
+		if !isQuote(line) {
+			// 惰性续行：非空且不是新块的行属于引用中的段落
+			if strings.TrimSpace(line) == "" || len(inner) == 0 || startsBlock(line) {
+				break
+			}
+			inner = append(inner, line)
+			continue
+		}
+		content := strings.TrimPrefix(strings.TrimLeft(line, " "), ">")
+		inner = append(inner, strings.TrimPrefix(content, " "))
+	}
+
+	r.out.WriteString("<blockquote>\n")
+	r.blocks(inner, false)
+	r.out.WriteString("</blockquote>\n")
+	return i - 1
+}
+
+// list 渲染列表，返回最后消费的行号
+func (r *renderer) list(lines []string, start int) int {
+	first := listItemRe.FindStringSubmatch(expandTabs(lines[start]))
+	ordered := !strings.ContainsAny(first[2][:1], "-*+")
+	marker := first[2][len(first[2])-1:]
+
+	var items [][]string
+	contentIndent := 0
+	loose := false
+	blank := false
+	i := start
+scan:
+	for ; i < len(lines); i++ {
+		line := expandTabs(lines[i])
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if m := listItemRe.FindStringSubmatch(line); m != nil && (len(items) == 0 || indent < contentIndent) && sameList(m[2], ordered, marker) {
+			if blank && len(items) > 0 {
+				loose = true
+			}
+			blank = false
+			contentIndent = len(m[0])
+			if len(m[3]) > 4 {
+				contentIndent = len(m[1]) + len(m[2]) + 1
+			}
+			items = append(items, []string{strings.TrimLeft(line[len(m[0]):], " ")})
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			items[len(items)-1] = append(items[len(items)-1], "")
+			continue
+		}
+
+		switch {
+		case indent >= 2:
+			// 缩进的行属于当前列表项（嵌套块或续行）
+			if blank {
+				loose = true
+			}
+			blank = false
+			items[len(items)-1] = append(items[len(items)-1], dedent(line, contentIndent))
+		case !blank && !startsBlock(line):
+			// 惰性续行
+			items[len(items)-1] = append(items[len(items)-1], strings.TrimSpace(line))
+		default:
+			break scan
+		}
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	r.out.WriteString("<" + tag + ">\n")
+	for _, item := range items {
+		for len(item) > 0 && strings.TrimSpace(item[len(item)-1]) == "" {
+			item = item[:len(item)-1]
+		}
+		r.out.WriteString("<li>")
+		r.blocks(item, !loose)
+		r.out.WriteString("</li>\n")
+	}
+	r.out.WriteString("</" + tag + ">\n")
+	return i - 1
+}
+
+// sameList 列表项标记是否属于同一列表
+func sameList(marker string, ordered bool, last string) bool {
+	isOrdered := !strings.ContainsAny(marker[:1], "-*+")
+	return isOrdered == ordered && marker[len(marker)-1:] == last
+}
+
+// startsBlock 该行是否开始一个新的块（用于终止惰性续行）
+func startsBlock(line string) bool {
+	return headingRe.MatchString(line) || fenceRe.MatchString(line) || hrRe.MatchString(line) ||
+		isQuote(line) || listItemRe.MatchString(line)
+}
+
+// table 渲染 GFM 表格，返回最后消费的行号
+func (r *renderer) table(lines []string, start int) int {
+	header := splitRow(lines[start])
+	r.out.WriteString("<table>\n<thead>\n<tr>")
+	for _, cell := range header {
+		r.out.WriteString("<th>" + r.inline(cell) + "</th>")
+	}
+	r.out.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	i := start + 2
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" || !strings.Contains(lines[i], "|") {
+			break
+		}
+		cells := splitRow(lines[i])
+		r.out.WriteString("<tr>")
+		for j := range header {
+			cell := ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			r.out.WriteString("<td>" + r.inline(cell) + "</td>")
+		}
+		r.out.WriteString("</tr>\n")
+	}
+	r.out.WriteString("</tbody>\n</table>\n")
+	return i - 1
+}
+
+// splitRow 拆分表格行，支持 \| 转义
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(line[i])
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// inline 渲染行内元素，所有文本均经过转义
+func (r *renderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>\"'", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+			continue
+
+		case c == '\n':
+			// 行尾两个以上空格为硬换行
+			if strings.HasSuffix(b.String(), "  ") {
+				trimmed := strings.TrimRight(b.String(), " ")
+				b.Reset()
+				b.WriteString(trimmed + "<br>")
+			}
+			b.WriteByte('\n')
+			i++
+			continue
+
+		case c == '`':
+			if n, out := codeSpan(s[i:]); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if n, out := r.link(s[i+1:], true); n > 0 {
+				b.WriteString(out)
+				i += n + 1
+				continue
+			}
+
+		case c == '[':
+			if n, out := r.link(s[i:], false); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if m := autolinkRe.FindStringSubmatch(s[i:]); m != nil {
+				if href := r.safeURL(m[1], false); href != "" {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener">` + html.EscapeString(m[1]) + "</a>")
+					i += len(m[0])
+					continue
+				}
+			}
+
+		case c == 'h' && (i == 0 || !isWordByte(s[i-1])):
+			if m := bareURLRe.FindString(s[i:]); m != "" {
+				m = strings.TrimRight(m, trailingPunct)
+				if href := r.safeURL(m, false); href != "" {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener">` + html.EscapeString(m) + "</a>")
+					i += len(m)
+					continue
+				}
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if n, out := r.emphasis(s, i); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// codeSpan 解析行内代码，返回消费的字节数与输出
+func codeSpan(s string) (int, string) {
+	ticks := len(s) - len(strings.TrimLeft(s, "`"))
+	delim := s[:ticks]
+	rest := s[ticks:]
+	for offset := 0; ; {
+		idx := strings.Index(rest[offset:], delim)
+		if idx < 0 {
+			return 0, ""
+		}
+		end := offset + idx
+		// 结束标记须恰好为相同数量的反引号
+		if end+ticks < len(rest) && rest[end+ticks] == '`' {
+			offset = end + ticks + len(rest[end+ticks:]) - len(strings.TrimLeft(rest[end+ticks:], "`"))
+			continue
+		}
+		code := strings.ReplaceAll(rest[:end], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+			code = code[1 : len(code)-1]
+		}
+		return ticks + end + ticks, "<code>" + html.EscapeString(code) + "</code>"
+	}
+}
+
+// link 解析 [text](url "title") 形式的链接或图片（s 以 [ 开头），返回消费的字节数与输出；
+// 地址不安全时链接只输出文本，图片只输出替代文本
+func (r *renderer) link(s string, image bool) (int, string) {
+	depth := 0
+	closeText := -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeText = i
+			}
+		}
+		if closeText >= 0 {
+			break
+		}
+	}
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return 0, ""
+	}
+
+	depth = 0
+	closeDest := -1
+	for i := closeText + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				closeDest = i
+			}
+		}
+		if closeDest >= 0 {
+			break
+		}
+	}
+	if closeDest < 0 {
+		return 0, ""
+	}
+
+	text := s[1:closeText]
+	target, title := splitDestination(s[closeText+2 : closeDest])
+	n := closeDest + 1
+
+	href := r.safeURL(target, image)
+	titleAttr := ""
+	if title != "" {
+		titleAttr = ` title="` + html.EscapeString(title) + `"`
+	}
+
+	if image {
+		alt := html.EscapeString(plainText(text))
+		if href == "" {
+			return n, alt
+		}
+		return n, `<img src="` + html.EscapeString(href) + `" alt="` + alt + `"` + titleAttr + ">"
+	}
+
+	content := r.inline(text)
+	if href == "" {
+		return n, content
+	}
+	return n, `<a href="` + html.EscapeString(href) + `"` + titleAttr + ` rel="nofollow noopener">` + content + "</a>"
+}
+
+// splitDestination 拆分链接地址与可选标题
+func splitDestination(dest string) (string, string) {
+	dest = strings.TrimSpace(dest)
+	if strings.HasPrefix(dest, "<") {
+		if end := strings.Index(dest, ">"); end > 0 {
+			return dest[1:end], unquoteTitle(dest[end+1:])
+		}
+	}
+	if idx := strings.IndexAny(dest, " \t\n"); idx >= 0 {
+		return dest[:idx], unquoteTitle(dest[idx+1:])
+	}
+	return dest, ""
+}
+
+// unquoteTitle 去掉标题两侧的引号
+func unquoteTitle(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'' || s[0] == '(' && s[len(s)-1] == ')') {
+		return s[1 : len(s)-1]
+	}
+	return ""
+}
+
+// plainText 去掉图片替代文本中的 Markdown 标记
+func plainText(s string) string {
+	return strings.NewReplacer("*", "", "_", "", "`", "", "[", "", "]", "").Replace(s)
+}
+
+// emphasis 解析 * _ ~~ 强调，返回消费的字节数与输出
+func (r *renderer) emphasis(s string, i int) (int, string) {
+	c := s[i]
+	run := 1
+	for i+run < len(s) && s[i+run] == c {
+		run++
+	}
+
+	var delim, open, closeTag string
+	switch {
+	case c == '~' && run == 2:
+		delim, open, closeTag = "~~", "<del>", "</del>"
+	case c != '~' && run >= 2:
+		delim, open, closeTag = string([]byte{c, c}), "<strong>", "</strong>"
+	case c != '~':
+		delim, open, closeTag = string(c), "<em>", "</em>"
+	default:
+		return 0, ""
+	}
+
+	// 开始标记后不能是空白；_ 不能出现在单词内部
+	start := i + len(delim)
+	if start >= len(s) || isSpace(s[start]) {
+		return 0, ""
+	}
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0, ""
+	}
+
+	for offset := start; offset < len(s); {
+		idx := strings.Index(s[offset:], delim)
+		if idx < 0 {
+			return 0, ""
+		}
+		end := offset + idx
+		valid := end > start && !isSpace(s[end-1])
+		if c == '_' && end+len(delim) < len(s) && isWordByte(s[end+len(delim)]) {
+			valid = false
+		}
+		// 单个 * 不与 ** 的一半配对
+		if len(delim) == 1 && end+1 < len(s) && s[end+1] == c {
+			valid = false
+		}
+		if valid {
+			return end + len(delim) - i, open + r.inline(s[start:end]) + closeTag
+		}
+		offset = end + len(delim)
+		if len(delim) == 1 {
+			for offset < len(s) && s[offset] == c {
+				offset++
+			}
+		}
+	}
+	return 0, ""
+}
+
+// safeURL 校验并规范化链接地址，不允许的协议返回空字符串
+func (r *renderer) safeURL(target string, image bool) string {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return ""
+	}
+	if strings.HasPrefix(target, "#") {
+		return "#" + url.PathEscape(strings.TrimPrefix(target, "#"))
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	if u.Scheme != "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http", "https":
+			if u.Host == "" {
+				return ""
+			}
+			return u.String()
+		case "mailto":
+			if image {
+				return ""
+			}
+			return u.String()
+		default:
+			return ""
+		}
+	}
+	// 协议相对地址的协议由页面决定，不予放行
+	if u.Host != "" || strings.HasPrefix(target, "//") {
+		return ""
+	}
+
+	if r.opts.ResolveLink == nil {
+		return ""
+	}
+	resolved := r.opts.ResolveLink(target, image)
+	if resolved == "" {
+		return ""
+	}
+	// 转换结果同样需要是 http/https 地址
+	ru, err := url.Parse(resolved)
+	if err != nil || (ru.Scheme != "http" && ru.Scheme != "https") || ru.Host == "" {
+		return ""
+	}
+	return ru.String()
+}
+
+// expandTabs 将行首制表符展开为四个空格
+func expandTabs(line string) string {
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	if !strings.Contains(line[:i], "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, ch := range line[:i] {
+		if ch == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		} else {
+			b.WriteByte(' ')
+			col++
+		}
+	}
+	return b.String() + line[i:]
+}
+
+// dedent 去掉最多 n 个前导空格
+func dedent(line string, n int) string {
+	i := 0
+	for i < n && i < len(line) && line[i] == ' ' {
+		i++
+	}
+	return line[i:]
+}
+
+// isSpace 是否为空白字符
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// isWordByte 是否为单词字符（非 ASCII 字节视为单词字符）
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
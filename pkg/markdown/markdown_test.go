@@ -0,0 +1,151 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// tagRe 输出中的标签；属性值由渲染器转义，不含 >
+var tagRe = regexp.MustCompile(`<(/?)([a-zA-Z0-9]+)((?:\s+[a-zA-Z-]+="[^"]*")*)\s*/?>`)
+
+// attrRe 标签中的属性
+var attrRe = regexp.MustCompile(`([a-zA-Z-]+)="([^"]*)"`)
+
+// allowedAttrs 各标签允许的属性，与包注释一致
+var allowedAttrs = map[string]map[string]bool{
+	"a":    {"href": true, "title": true, "rel": true},
+	"img":  {"src": true, "alt": true, "title": true},
+	"code": {"class": true},
+}
+
+// assertSafe 输出只包含白名单中的标签与属性，链接地址只有 http、https、mailto 与页内锚点
+func assertSafe(t *testing.T, src, out string) {
+	t.Helper()
+	allowed := make(map[string]bool)
+	for _, tag := range AllowedTags() {
+		allowed[tag] = true
+	}
+
+	for _, m := range tagRe.FindAllStringSubmatchIndex(out, -1) {
+		tag := strings.ToLower(out[m[4]:m[5]])
+		if !allowed[tag] {
+			t.Errorf("%q 输出了不允许的标签 <%s>: %s", src, tag, out)
+		}
+		for _, attr := range attrRe.FindAllStringSubmatch(out[m[6]:m[7]], -1) {
+			name, value := strings.ToLower(attr[1]), attr[2]
+			if !allowedAttrs[tag][name] {
+				t.Errorf("%q 输出了不允许的属性 %s=%q: %s", src, name, value, out)
+			}
+			if name == "href" || name == "src" {
+				lower := strings.ToLower(value)
+				if !(strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") ||
+					strings.HasPrefix(lower, "mailto:") || strings.HasPrefix(lower, "#")) {
+					t.Errorf("%q 输出了不允许的地址 %s=%q: %s", src, name, value, out)
+				}
+			}
+		}
+	}
+	// 去掉合法的标签后不应残留未转义的 <
+	if strings.Contains(tagRe.ReplaceAllString(out, ""), "<") {
+		t.Errorf("%q 输出了未转义的 <: %s", src, out)
+	}
+}
+
+func TestRenderNeverEmitsRawHTML(t *testing.T) {
+	fixtures := []string{
+		"<script>alert(1)</script>",
+		"<SCRIPT SRC=//evil.example/x.js></SCRIPT>",
+		"text <script>alert(1)",
+		"<img src=x onerror=alert(1)>",
+		"<a href=\"javascript:alert(1)\">x</a>",
+		"<div><p><script>alert(1)</script></p></div>",
+		"<<script>script>alert(1)<</script>/script>",
+		"<iframe src=\"https://evil.example\"></iframe>",
+		"<svg onload=alert(1)>",
+		"<style>body{display:none}</style>",
+		"<!-- <script>alert(1)</script> -->",
+		"**<b onmouseover=alert(1)>bold</b>**",
+		"# heading <script>alert(1)</script>",
+		"> quote <img src=x onerror=alert(1)>",
+		"- item <script>\n- item two",
+		"| a | b |\n|---|---|\n| <script>alert(1)</script> | <img src=x onerror=alert(1)> |",
+		"`<script>alert(1)</script>`",
+		"```html\n<script>alert(1)</script>\n```",
+		"```\" onmouseover=\"alert(1)\n```",
+		"<script",
+		"<a href='x' <script>",
+	}
+	for _, src := range fixtures {
+		out := Render([]byte(src), Options{})
+		assertSafe(t, src, out)
+		if strings.Contains(strings.ToLower(out), "<script") {
+			t.Errorf("%q 输出了 script 标签: %s", src, out)
+		}
+	}
+}
+
+func TestRenderDropsUnsafeURLs(t *testing.T) {
+	fixtures := []string{
+		"[x](javascript:alert(1))",
+		"[x](JaVaScRiPt:alert(1))",
+		"[x]( javascript:alert(1))",
+		"[x](java&#x73;cript:alert(1))",
+		"[x](vbscript:msgbox(1))",
+		"[x](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+		"![x](data:image/svg+xml;base64,PHN2ZyBvbmxvYWQ9YWxlcnQoMSk+)",
+		"![x](javascript:alert(1))",
+		"![x](mailto:a@example.com)",
+		"[x](//evil.example/path)",
+		"[x](http:alert(1))",
+		"[x](file:///etc/passwd)",
+		"[x](\"javascript:alert(1)\")",
+		"[x](https://example.com \"\" onmouseover=\"alert(1))",
+		"[x](https://example.com/\"onmouseover=\"alert(1))",
+		"<javascript:alert(1)>",
+		"[a [b](javascript:alert(1))](https://example.com)",
+		"[unclosed](https://example.com",
+		"[x](relative/path)",
+	}
+	for _, src := range fixtures {
+		out := Render([]byte(src), Options{})
+		assertSafe(t, src, out)
+		lower := strings.ToLower(out)
+		for _, bad := range []string{"href=\"javascript", "href=\"data:", "src=\"data:", "src=\"javascript", "onmouseover=\"", "vbscript:"} {
+			if strings.Contains(lower, bad) {
+				t.Errorf("%q 输出了 %s: %s", src, bad, out)
+			}
+		}
+	}
+}
+
+func TestRenderResolveLink(t *testing.T) {
+	resolve := func(target string, image bool) string {
+		switch target {
+		case "docs/a.md":
+			return "https://git.example/repo/blob/main/docs/a.md"
+		case "evil":
+			return "javascript:alert(1)"
+		}
+		return ""
+	}
+
+	out := Render([]byte("[a](docs/a.md) [b](evil) [c](missing)"), Options{ResolveLink: resolve})
+	assertSafe(t, "resolve", out)
+	if !strings.Contains(out, `href="https://git.example/repo/blob/main/docs/a.md"`) {
+		t.Errorf("相对链接应按 ResolveLink 转换: %s", out)
+	}
+	if strings.Contains(out, "javascript") {
+		t.Errorf("ResolveLink 返回的非 http 地址应被丢弃: %s", out)
+	}
+}
+
+func TestRenderKeepsSafeLinks(t *testing.T) {
+	out := Render([]byte("[site](https://example.com/a?b=1&c=2) [mail](mailto:a@example.com) [top](#intro)"), Options{})
+	assertSafe(t, "safe", out)
+	for _, want := range []string{`href="https://example.com/a?b=1&amp;c=2"`, `href="mailto:a@example.com"`, `href="#intro"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出缺少 %s: %s", want, out)
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"flowforge/pkg/progress"
+
 	"gorm.io/gorm"
 )
 
@@ -19,6 +26,13 @@ type User struct {
 	Role     string `json:"role" gorm:"default:user"`
 	Avatar   string `json:"avatar"`
 	Status   string `json:"status" gorm:"default:active"`
+
+	// 通知偏好
+	NotifyChannel string `json:"notify_channel" gorm:"default:in_app"` // email, in_app, none
+	NotifyDigest  bool   `json:"notify_digest" gorm:"default:false"`   // 非紧急通知汇总为每日邮件
+
+	// 消息语言偏好（如 zh-CN、en-US），为空时按请求头协商
+	Locale string `json:"locale"`
 	
 	// 关联关系
 	Projects []Project `json:"projects,omitempty" gorm:"foreignKey:UserID"`
@@ -31,20 +45,83 @@ type Project struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 	
-	Name        string `json:"name" gorm:"not null" binding:"required"`
+	Name        string `json:"name" gorm:"size:255;not null;uniqueIndex:idx_project_owner_name" binding:"required"` // 同一所有者下唯一
+	Slug        string `json:"slug" gorm:"size:128;uniqueIndex"`                                                    // URL中使用的标识，创建时生成，改名不变
 	Description string `json:"description"`
-	RepoURL     string `json:"repo_url" gorm:"not null" binding:"required"`
+	RepoURL     string `json:"repo_url" gorm:"not null"`
 	Branch      string `json:"branch" gorm:"default:main"`
 	BuildPath   string `json:"build_path" gorm:"default:./"`
 	DeployPath  string `json:"deploy_path"`
 	Status      string `json:"status" gorm:"default:inactive"`
+	SizeHintMB  int    `json:"size_hint_mb"` // 仓库检出大小预估（MB），托管平台无法查询时用于磁盘空间检查
+
+	// 项目并发策略：同时执行的运行数上限（0 表示不限）与互斥组（逗号分隔），同一互斥组的运行依次执行
+	MaxConcurrentRuns int    `json:"max_concurrent_runs" gorm:"default:0"`
+	MutexGroups       string `json:"mutex_groups"`
+
+	// 允许的运行标签（逗号分隔），为空时不限制
+	AllowedRunLabels string `json:"allowed_run_labels"`
+
+	// 源码来源：git 从仓库拉取；archive 不关联仓库，每次运行上传源码包（RepoURL 为空）；
+	// registry 只部署镜像仓库中预先构建的制品，每次运行指定镜像或 tarball 制品，不检出代码也不构建
+	SourceType string `json:"source_type" gorm:"size:16;default:git"`
+
+	// registry 项目部署的镜像仓库（含仓库地址，如 harbor.example.com/library/app），为空时不限制；
+	// 手动触发的镜像与镜像仓库 Webhook 推送的镜像需属于该仓库
+	ImageRepository string `json:"image_repository,omitempty"`
+
+	// 归档信息
+	StatusBeforeArchive string     `json:"-"`
+	ArchivedAt          *time.Time `json:"archived_at"`
+
+	// 部署目标漂移状态汇总，查询项目详情时计算
+	DriftStatus string `json:"drift_status,omitempty" gorm:"-"`
+
+	// 当前可能影响该项目部署的冻结（全局、环境范围与包含该项目的冻结），查询项目详情时计算
+	ActiveFreezes []DeployFreeze `json:"active_freezes,omitempty" gorm:"-"`
+
+	// 告警中的失败率突增告警，查询项目详情时计算
+	FailureSpikes []FailureSpikeAlert `json:"failure_spikes,omitempty" gorm:"-"`
+
+	// 运行日志存储配额（MB），0 表示使用全局配置，-1 表示不限制；LogQuotaWarnedAt 为超过软配额后通知的时间，回到配额内后清空
+	LogSoftQuotaMB   int        `json:"log_soft_quota_mb" gorm:"default:0"`
+	LogHardCapMB     int        `json:"log_hard_cap_mb" gorm:"default:0"`
+	LogQuotaWarnedAt *time.Time `json:"log_quota_warned_at"`
+
+	// 失败率突增告警：倍数与最少运行数为 0 时使用全局配置
+	FailureSpikeFactor   float64 `json:"failure_spike_factor" gorm:"default:0"`
+	FailureSpikeMinRuns  int     `json:"failure_spike_min_runs" gorm:"default:0"`
+	FailureSpikeDisabled bool    `json:"failure_spike_disabled" gorm:"default:false"`
+
+	// 加入项目申请的自动批准规则：邮箱域名（逗号分隔）匹配的申请自动批准，授予的角色不超过 AccessAutoApproveRole
+	AccessAutoApproveDomains string `json:"access_auto_approve_domains"`
+	AccessAutoApproveRole    string `json:"access_auto_approve_role" gorm:"size:16;default:viewer"`
+
+	// 仓库状态，拉取代码时更新：RepoMovedTo 为远程通过重定向报告的迁移后地址，RepoNotFoundRuns 为连续报告仓库不存在的运行数
+	RepoMovedTo      string `json:"repo_moved_to"`
+	RepoNotFoundRuns int    `json:"repo_not_found_runs" gorm:"default:0"`
+
+	// 多租户隔离开启后首次在本机执行脚本时创建的项目专用系统用户，工作区与依赖缓存归该用户所有
+	OSUser string `json:"os_user,omitempty" gorm:"size:32"`
+	OSUID  int    `json:"os_uid,omitempty" gorm:"default:0"` // 同时作为用户组ID，各执行器主机上一致
+
+	// 脚本步骤未指定 shell 时使用的解释器，为空时由执行主机选择（Windows 为 powershell，其他系统为 bash）
+	DefaultShell string `json:"default_shell" gorm:"size:20"`
+
+	// 项目健康状态，查询项目列表与详情时计算
+	Health *ProjectHealth `json:"health,omitempty" gorm:"-"`
 	
 	// SSH配置
 	SSHKeyID     *uint   `json:"ssh_key_id"`
 	SSHKey       *SSHKey `json:"ssh_key,omitempty" gorm:"foreignKey:SSHKeyID"`
+
+	// 部署密钥（项目专用的Git只读密钥，优先于用户SSH密钥）
+	DeployKeyID        *uint   `json:"deploy_key_id"`
+	DeployKey          *SSHKey `json:"deploy_key,omitempty" gorm:"foreignKey:DeployKeyID"`
+	PendingDeployKeyID *uint   `json:"pending_deploy_key_id"` // 轮换中、尚未确认的新密钥
 	
 	// 用户关联
-	UserID uint `json:"user_id" gorm:"not null"`
+	UserID uint `json:"user_id" gorm:"not null;uniqueIndex:idx_project_owner_name"`
 	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	
 	// 关联关系
@@ -66,12 +143,36 @@ type SSHKey struct {
 	Port       int    `json:"port" gorm:"default:22"`
 	Username   string `json:"username" gorm:"default:root"`
 	Status     string `json:"status" gorm:"default:active"`
+
+	// 密钥用途与类型
+	Purpose       string     `json:"purpose" gorm:"default:general"` // general, deploy_key
+	KeyType       string     `json:"key_type" gorm:"default:rsa"`    // rsa, ed25519
+	Fingerprint   string     `json:"fingerprint"`
+	Encrypted     bool       `json:"-" gorm:"default:false"` // 私钥是否已加密存储
+	ProjectID     *uint      `json:"project_id"`             // 部署密钥所属项目
+	ProviderKeyID string     `json:"provider_key_id"`        // 在Git托管平台注册后的密钥ID
+	RevokedAt     *time.Time `json:"revoked_at"`
+
+	// 使用情况：每次用于Git或SSH操作时更新，便于识别长期未用的密钥
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UseCount   int64      `json:"use_count" gorm:"default:0"`
+
+	// 默认跳板机：使用该密钥的远程操作经跳板机连接目标主机，远程部署步骤可单独指定
+	BastionConfig
 	
 	// 用户关联
 	UserID uint `json:"user_id" gorm:"not null"`
 	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// BastionConfig 跳板机配置：目标主机只能经跳板机访问时，先连接跳板机，再经跳板机连接目标主机
+type BastionConfig struct {
+	BastionHost     string `json:"bastion_host"`
+	BastionPort     int    `json:"bastion_port"`     // 为 0 时使用 22
+	BastionUsername string `json:"bastion_username"` // 为空时与目标主机的用户名相同
+	BastionKeyID    *uint  `json:"bastion_key_id"`   // 连接跳板机使用的密钥，为空时使用连接目标主机的密钥
+}
+
 // Deployment 部署记录模型
 type Deployment struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
@@ -84,9 +185,13 @@ type Deployment struct {
 	Status      string `json:"status" gorm:"default:pending"`
 	StartTime   *time.Time `json:"start_time"`
 	EndTime     *time.Time `json:"end_time"`
-	Duration    int64  `json:"duration"` // 部署耗时（秒）
+	Duration    int64  `json:"duration"`    // 部署耗时（秒），已弃用，使用 duration_ms
+	DurationMs  int64  `json:"duration_ms"` // 部署耗时（毫秒）
 	LogOutput   string `json:"log_output" gorm:"type:text"`
 	ErrorMsg    string `json:"error_msg" gorm:"type:text"`
+	Preflight   string `json:"preflight,omitempty" gorm:"type:text"` // 远程部署的部署前检查结果（JSON）
+	Verify      string `json:"verify,omitempty" gorm:"type:text"`    // 部署后验证结果（JSON），配置了 verify 时全部通过才记为成功
+	Environment string `json:"environment" gorm:"index"`              // 部署步骤配置的环境，如 production
 	
 	// 项目关联
 	ProjectID uint    `json:"project_id" gorm:"not null"`
@@ -97,6 +202,208 @@ type Deployment struct {
 	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// DeploymentManifest 部署到远程目标后记录的文件清单，用于检测目标上的手工变更（配置漂移）
+type DeploymentManifest struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 部署目标：user@host:port/remote_dir
+	Target    string `json:"target" gorm:"size:512;not null;uniqueIndex:idx_manifest_deployment_target"`
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	Username  string `json:"username"`
+	RemoteDir string `json:"remote_dir"`
+	SSHKeyID  uint   `json:"ssh_key_id"`
+
+	// 部署时使用的跳板机，漂移检查经同一跳板机连接
+	BastionConfig
+
+	// 部署的文件（JSON：路径、大小、sha256，路径相对 RemoteDir）与服务单元文件校验和
+	Files             string `json:"-" gorm:"type:text"`
+	FileCount         int    `json:"file_count"`
+	TotalSize         int64  `json:"total_size"`
+	ServiceUnit       string `json:"service_unit"`
+	ServiceUnitSHA256 string `json:"service_unit_sha256"`
+
+	// 最近一次漂移检查结果
+	DriftStatus string     `json:"drift_status" gorm:"default:unknown"` // unknown, clean, drifted, unreachable
+	DriftDetail string     `json:"drift_detail" gorm:"type:text"`
+	CheckedAt   *time.Time `json:"checked_at"`
+
+	// 部署关联
+	DeploymentID uint `json:"deployment_id" gorm:"not null;uniqueIndex:idx_manifest_deployment_target"`
+	ProjectID    uint `json:"project_id" gorm:"not null;index"`
+}
+
+// Release 部署到发布环境（默认 production）成功后生成的发布记录：版本、与该环境上次发布之间按类型分组的提交、
+// 制品摘要、部署人与耗时。上次发布的提交不在本次提交的历史中（如强制推送）时不列出提交，RangeUnknown 为 true
+type Release struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Environment        string `json:"environment" gorm:"index"`
+	Version            string `json:"version"`
+	CommitHash         string `json:"commit_hash"`
+	PreviousCommitHash string `json:"previous_commit_hash"` // 上次发布的提交，首次发布为空
+	RangeUnknown       bool   `json:"range_unknown"`
+	CommitCount        int    `json:"commit_count"`
+	Changelog          string `json:"changelog" gorm:"type:text"` // 按类型分组的提交（JSON，见 ReleaseChangelog）
+	Artifacts          string `json:"artifacts" gorm:"type:text"` // 运行产出或使用的制品（JSON，见 ReleaseArtifact）
+	DurationMs         int64  `json:"duration_ms"`
+
+	// registry 项目部署的制品引用与该环境上次发布的制品引用，代替提交范围展示镜像标签的变化
+	ArtifactRef         string `json:"artifact_ref,omitempty"`
+	PreviousArtifactRef string `json:"previous_artifact_ref,omitempty"`
+
+	// 在托管平台创建的带标签的 release，未开启或未配置平台令牌时为空
+	ProviderURL   string `json:"provider_url"`
+	ProviderError string `json:"provider_error"`
+
+	// 关联
+	ProjectID     uint  `json:"project_id" gorm:"not null;index"`
+	DeploymentID  uint  `json:"deployment_id" gorm:"not null;index"`
+	PipelineRunID uint  `json:"pipeline_run_id" gorm:"index"`
+	PreviousID    *uint `json:"previous_id"` // 同一环境的上一个发布
+	DeployedByID  uint  `json:"deployed_by_id"`
+	DeployedBy    *User `json:"deployed_by,omitempty" gorm:"foreignKey:DeployedByID"`
+}
+
+// ReleaseChangelog 发布包含的提交，按 conventional commit 类型分组
+type ReleaseChangelog struct {
+	Features  []ReleaseCommit `json:"features"`
+	Fixes     []ReleaseCommit `json:"fixes"`
+	Other     []ReleaseCommit `json:"other"`
+	Truncated bool            `json:"truncated"` // 提交过多，只列出最近的部分
+
+	// registry 项目部署的镜像与上次发布相比的变化，代替提交列表
+	Image *ReleaseImageChange `json:"image,omitempty"`
+}
+
+// ReleaseImageChange registry 项目发布的镜像变化：镜像仓库不变时比较标签与摘要，上次发布的镜像为空表示首次发布
+type ReleaseImageChange struct {
+	Repository         string `json:"repository"`
+	Tag                string `json:"tag"`
+	Digest             string `json:"digest"`
+	PreviousRepository string `json:"previous_repository"`
+	PreviousTag        string `json:"previous_tag"`
+	PreviousDigest     string `json:"previous_digest"`
+}
+
+// ReleaseCommit 发布包含的一个提交
+type ReleaseCommit struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+	Author  string `json:"author"`
+}
+
+// ReleaseArtifact 发布使用的制品及其 sha256
+type ReleaseArtifact struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
+
+// ProjectPolicy 项目级默认策略：通知规则、审批要求与运行超时，未覆盖的流水线在运行开始时继承，
+// 修改后对之后的运行立即生效。管理员标记为强制的部分流水线不能削弱，只能增加
+type ProjectPolicy struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NotifyRules       string `json:"-" gorm:"type:text"` // JSON，[]PolicyNotifyRule
+	Approvals         string `json:"-" gorm:"type:text"` // JSON，[]PolicyApproval
+	RunTimeoutMinutes int    `json:"run_timeout_minutes" gorm:"default:0"` // 0 表示不限制
+
+	NotifyEnforced    bool `json:"notify_enforced" gorm:"default:false"`
+	ApprovalsEnforced bool `json:"approvals_enforced" gorm:"default:false"`
+	TimeoutEnforced   bool `json:"timeout_enforced" gorm:"default:false"`
+
+	ProjectID uint `json:"project_id" gorm:"not null;uniqueIndex"`
+}
+
+// PolicyNotifyRule 运行结束通知规则：运行结束状态匹配时通知指定用户（仍需有权查看流水线）
+type PolicyNotifyRule struct {
+	Statuses []string `json:"statuses"` // success, failed, cancelled，为空表示所有结束状态
+	UserIDs  []uint   `json:"user_ids"`
+}
+
+// PolicyApproval 审批要求：部署环境（步骤的 environment）匹配模式的步骤执行前插入审批等待，通过外部等待人工处理
+type PolicyApproval struct {
+	Environment    string `json:"environment"`     // 环境名模式，支持 * 通配，如 prod*
+	TimeoutSeconds int    `json:"timeout_seconds"` // 等待审批的超时时间，0 使用外部等待的默认超时
+}
+
+// EffectivePolicy 流水线生效的策略，每项标明是否继承自项目策略
+type EffectivePolicy struct {
+	NotifyRules       []EffectiveNotifyRule `json:"notify_rules"`
+	Approvals         []EffectiveApproval   `json:"approvals"`
+	RunTimeoutMinutes int                   `json:"run_timeout_minutes"`
+	TimeoutInherited  bool                  `json:"timeout_inherited"`
+
+	NotifyEnforced    bool `json:"notify_enforced"`
+	ApprovalsEnforced bool `json:"approvals_enforced"`
+	TimeoutEnforced   bool `json:"timeout_enforced"`
+}
+
+// EffectiveNotifyRule 生效的通知规则
+type EffectiveNotifyRule struct {
+	PolicyNotifyRule
+	Inherited bool `json:"inherited"`
+}
+
+// EffectiveApproval 生效的审批要求
+type EffectiveApproval struct {
+	PolicyApproval
+	Inherited bool `json:"inherited"`
+}
+
+// EnvReferenceFinding 步骤配置中对未定义环境变量的一处引用
+type EnvReferenceFinding struct {
+	Name  string `json:"name"`
+	Field string `json:"field"` // 引用所在的配置字段，如 script、env.DEPLOY_URL
+	Line  int    `json:"line"`  // 在字段内容中的行号，从 1 开始
+}
+
+// StepEnvFindings 一个步骤中对未定义环境变量的引用
+type StepEnvFindings struct {
+	Stage    string                `json:"stage"`
+	Step     string                `json:"step"`
+	Findings []EnvReferenceFinding `json:"findings"`
+}
+
+// LintWarning 流水线配置建议性检查的一处结果，不影响保存，除非规则被配置为阻止保存
+type LintWarning struct {
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity"`
+	Stage      string `json:"stage,omitempty"`
+	Step       string `json:"step,omitempty"`
+	Index      int    `json:"index,omitempty"` // 步骤在阶段中的位置，从 1 开始，便于定位没有名称的步骤
+	Field      string `json:"field,omitempty"` // 所在的配置字段，如 script、image
+	Line       int    `json:"line,omitempty"`  // 在字段内容中的行号，从 1 开始
+	Message    string `json:"message"`
+	Fix        string `json:"fix,omitempty"`        // 简单的修改建议
+	Blocking   bool   `json:"blocking,omitempty"`   // 规则被配置为阻止保存
+	Suppressed bool   `json:"suppressed,omitempty"` // 被配置中的 # flowforge-lint-disable 注释忽略，不阻止保存
+}
+
+// CloudCredential 部署到云平台时扮演的角色：部署步骤执行前以服务器配置的基础凭证签发临时凭证，
+// 只注入该步骤的环境变量，临时凭证不保存
+type CloudCredential struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Environment     string `json:"environment" gorm:"size:64;uniqueIndex:idx_cloud_credential"` // 部署步骤的环境，为空时用于没有单独配置的环境
+	Provider        string `json:"provider" gorm:"size:16;not null"`                            // aws
+	RoleARN         string `json:"role_arn" gorm:"not null"`
+	Region          string `json:"region"`                // 为空时使用服务器配置的区域
+	ExternalID      string `json:"external_id,omitempty"` // 角色信任策略要求的外部ID
+	DurationSeconds int    `json:"duration_seconds"`      // 临时凭证有效期，0 使用默认值，不超过运行的剩余超时时间
+
+	ProjectID uint `json:"project_id" gorm:"not null;uniqueIndex:idx_cloud_credential"`
+}
+
 // Pipeline 流水线模型
 type Pipeline struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
@@ -106,10 +413,65 @@ type Pipeline struct {
 	
 	Name        string `json:"name" gorm:"not null" binding:"required"`
 	Description string `json:"description"`
-	Config      string `json:"config" gorm:"type:text"` // YAML配置
+	Config      string `json:"config" gorm:"type:text"` // YAML配置，兼容早期保存的 JSON，结构见 PipelineConfig
 	Status      string `json:"status" gorm:"default:active"`
 	Trigger     string `json:"trigger" gorm:"default:manual"` // manual, webhook, schedule
 	CronExpr    string `json:"cron_expr"` // 定时触发表达式
+
+	// 配置来源：stored 使用保存的配置，repo 在运行时读取仓库中触发提交的配置文件
+	ConfigSource string `json:"config_source" gorm:"default:stored"`
+	ConfigPath   string `json:"config_path" gorm:"default:.flowforge.yml"`
+	AllowForkPRs bool   `json:"allow_fork_prs" gorm:"default:false"` // repo 模式下是否允许来自fork的PR触发运行
+
+	// 定时触发前预热：提前 PrewarmMinutes 分钟更新工作区代码并恢复依赖缓存，0 表示关闭
+	PrewarmMinutes int        `json:"prewarm_minutes" gorm:"default:0"`
+	PrewarmCaches  string     `json:"prewarm_caches"` // 依赖缓存目录（相对工作区，逗号分隔），成功运行后保存
+	PrewarmStatus  string     `json:"prewarm_status"` // running, success, failed, skipped
+	PrewarmMessage string     `json:"prewarm_message"`
+	PrewarmAt      *time.Time `json:"prewarm_at"`
+
+	// 因项目归档而暂停，取消归档时需逐个确认才恢复
+	PausedByArchive bool `json:"paused_by_archive" gorm:"default:false"`
+
+	// 加入的项目互斥组，同组的运行不会同时执行，为空表示不加入
+	MutexGroup string `json:"mutex_group"`
+
+	// 步骤耗时性能回退检查的阈值，0 表示使用全局配置
+	PerfMultiplier     float64 `json:"perf_multiplier" gorm:"default:0"`       // 超过基线中位数的倍数
+	PerfBaselineRuns   int     `json:"perf_baseline_runs" gorm:"default:0"`    // 基线使用的最近成功运行数
+	PerfMinStepSeconds int     `json:"perf_min_step_seconds" gorm:"default:0"` // 耗时低于该值的步骤不检查
+	PerfCheckDisabled  bool    `json:"perf_check_disabled" gorm:"default:false"`
+
+	// 覆盖项目默认策略：通知规则与审批要求为 JSON，为空时继承项目策略，[] 表示不使用；运行超时为 0 时继承
+	PolicyNotifyRules string `json:"policy_notify_rules" gorm:"type:text"`
+	PolicyApprovals   string `json:"policy_approvals" gorm:"type:text"`
+	RunTimeoutMinutes int    `json:"run_timeout_minutes" gorm:"default:0"`
+
+	// 没有变化时跳过：与同分支最近一次成功运行的提交相同（设置了 SkipPaths 时为这些路径下的文件没有变化）时
+	// 创建 skipped 运行而不执行，手动运行可以强制执行
+	SkipIfUnchanged   bool   `json:"skip_if_unchanged" gorm:"default:false"`
+	SkipCompareInputs bool   `json:"skip_compare_inputs" gorm:"default:false"` // 同时要求解析后的配置与环境变量没有变化
+	SkipPaths         string `json:"skip_paths"`                                 // 逗号分隔的 glob，如 src/**,go.mod
+
+	// 保存时检查配置引用的环境变量：StrictEnv 为 true 时引用未定义的变量拒绝保存，否则只提示；
+	// EnvIgnore 为不检查的变量名，逗号分隔，以 * 结尾表示前缀，如 CI_*
+	StrictEnv bool   `json:"strict_env" gorm:"default:false"`
+	EnvIgnore string `json:"env_ignore"`
+
+	// 失败时保留调试终端：步骤失败的运行在调试时限内可由项目所有者打开进入保留工作区的交互式终端
+	DebugOnFailure bool `json:"debug_on_failure" gorm:"default:false"`
+
+	// 本机执行步骤进程的调度优先级，避免构建挤占同机的服务：为空时使用全局配置 deploy.process_*
+	ProcessNice    *int   `json:"process_nice"`     // nice 值 -20~19
+	ProcessIOClass string `json:"process_io_class"` // none、best-effort、idle
+	ProcessIOLevel *int   `json:"process_io_level"` // best-effort 下的 IO 优先级 0~7，越大越低
+
+	// 合并项目策略后生效的策略，只在流水线详情中返回
+	Policy *EffectivePolicy `json:"policy,omitempty" gorm:"-"`
+	// 配置中引用了未定义环境变量的步骤，只在创建与更新的响应中返回
+	EnvWarnings []StepEnvFindings `json:"env_warnings,omitempty" gorm:"-"`
+	// 配置建议性检查的结果，只在创建与更新的响应中返回
+	LintWarnings []LintWarning `json:"warnings,omitempty" gorm:"-"`
 	
 	// 项目关联
 	ProjectID uint    `json:"project_id" gorm:"not null"`
@@ -119,6 +481,58 @@ type Pipeline struct {
 	PipelineRuns []PipelineRun `json:"pipeline_runs,omitempty" gorm:"foreignKey:PipelineID"`
 }
 
+// PipelineConfig 流水线配置：阶段按顺序执行，保存的配置与仓库中的配置文件结构相同
+type PipelineConfig struct {
+	// Template 引用的共享流水线模板，模板的阶段在流水线自己的阶段之前执行
+	Template string `json:"template,omitempty"`
+	// EnvGroups 引用的共享环境变量组，按顺序合并，后面的组覆盖前面的同名变量
+	EnvGroups []string        `json:"env_groups,omitempty"`
+	Stages    []PipelineStage `json:"stages"`
+}
+
+// PipelineStage 流水线阶段，阶段中的步骤按顺序执行，任一步骤失败时运行失败
+type PipelineStage struct {
+	Name    string         `json:"name"`
+	Timeout Timeout        `json:"timeout,omitempty"` // 阶段中步骤的默认超时时间，如 10m 或 600（秒）
+	Steps   []PipelineStep `json:"steps"`
+}
+
+// Timeout 阶段或步骤的超时时间，如 10m、1h30m；配置中的数字按秒解释，如 600 即 600s
+type Timeout string
+
+// UnmarshalJSON 接受字符串与数字；数字与其他类型保留原文，由配置校验解析或报告为无效的超时时间
+func (t *Timeout) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*t = Timeout(value)
+		return nil
+	}
+	*t = Timeout(data)
+	return nil
+}
+
+// 流水线配置问题的类型
+const (
+	ConfigProblemSyntax          = "syntax"            // 不是有效的 YAML 或 JSON
+	ConfigProblemStructure       = "structure"         // 字段类型与配置结构不符
+	ConfigProblemNoStages        = "no_stages"         // 没有阶段
+	ConfigProblemEmptyStage      = "empty_stage"       // 阶段没有步骤
+	ConfigProblemMissingName     = "missing_name"      // 阶段或步骤缺少名称
+	ConfigProblemMissingType     = "missing_type"      // 步骤缺少类型
+	ConfigProblemUnknownStepType = "unknown_step_type" // 步骤类型未注册
+	ConfigProblemInvalidStep     = "invalid_step"      // 步骤配置不符合执行器的要求
+	ConfigProblemInvalidTimeout  = "invalid_timeout"   // 阶段或步骤的超时时间不是有效的时长
+)
+
+// ConfigProblem 流水线配置解析或校验发现的一个问题
+type ConfigProblem struct {
+	Kind    string `json:"kind"`
+	Stage   int    `json:"stage,omitempty"` // 所在阶段，从 1 开始
+	Step    int    `json:"step,omitempty"`  // 步骤在阶段中的位置，从 1 开始
+	Line    int    `json:"line,omitempty"`  // 语法错误所在的行，从 1 开始
+	Message string `json:"message"`
+}
+
 // PipelineRun 流水线执行记录
 type PipelineRun struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
@@ -130,10 +544,96 @@ type PipelineRun struct {
 	Status      string     `json:"status" gorm:"default:pending"`
 	StartTime   *time.Time `json:"start_time"`
 	EndTime     *time.Time `json:"end_time"`
-	Duration    int64      `json:"duration"` // 执行耗时（秒）
+	Duration    int64      `json:"duration"`    // 执行耗时（秒），已弃用，使用 duration_ms
+	DurationMs  int64      `json:"duration_ms"` // 执行耗时（毫秒）
 	LogOutput   string     `json:"log_output" gorm:"type:text"`
 	ErrorMsg    string     `json:"error_msg" gorm:"type:text"`
-	TriggerType string     `json:"trigger_type"` // manual, webhook, schedule
+	TriggerType string     `json:"trigger_type"`                 // manual, webhook, schedule
+	CommitSHA   string     `json:"commit_sha"`                   // 触发提交，repo 配置来源读取该提交中的配置文件
+	Branch      string     `json:"branch" gorm:"size:255;index"` // 运行时项目的分支，artifact_from 步骤按分支查找最近成功的运行
+	FailureKind string     `json:"failure_kind"`                 // 失败分类：infra 表示基础设施问题（如磁盘空间不足）
+
+	// 执行心跳：运行期间定期更新，超时未更新且执行器不在内存中视为执行器丢失
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at"`
+
+	// 运行被取消或中止的原因：CancellationReason 为固定取值（user、timeout、shutdown，升级前取消的运行为 unknown），
+	// 可作为统计维度；CancellationDetail 为说明，用户取消时 CancelledByID 记录操作人
+	CancellationReason string `json:"cancellation_reason,omitempty" gorm:"size:20;index"`
+	CancellationDetail string `json:"cancellation_detail,omitempty"`
+	CancelledByID      *uint  `json:"cancelled_by_id,omitempty"`
+
+	// 交给独立执行器的运行：排队期间 AwaitingWorker 为 true，执行器领取后记录 WorkerID；本地执行的运行 WorkerID 为空
+	AwaitingWorker bool  `json:"awaiting_worker" gorm:"default:false;index"`
+	WorkerID       *uint `json:"worker_id,omitempty" gorm:"index"`
+
+	// 仅重跑失败步骤：关联原运行，失败运行保留工作区供重跑使用
+	RerunOfID          *uint      `json:"rerun_of_id"`
+	WorkspacePath      string     `json:"-"`
+	WorkspaceExpiresAt *time.Time `json:"workspace_expires_at"`
+	RerunAvailable     bool       `json:"rerun_available" gorm:"-"`
+
+	// 重新运行：关联原运行，按原运行的提交重新执行全部步骤
+	RetryOfID *uint `json:"retry_of_id,omitempty"`
+
+	// 上传源码包触发的运行：CommitSHA 记录为 sha256:<源码包哈希>，SourceArchive 为保存的源码包路径
+	SourceArchive string `json:"-"`
+
+	// registry 项目的运行部署的制品：ArtifactKind 为 image 或 tarball，ArtifactRef 为规范化后的镜像引用或 tarball 地址，
+	// ArtifactDigest 为镜像摘要或 tarball 的 sha256:<哈希>，同时记录为 CommitSHA
+	ArtifactKind   string `json:"artifact_kind,omitempty" gorm:"size:16"`
+	ArtifactRef    string `json:"artifact_ref,omitempty"`
+	ArtifactDigest string `json:"artifact_digest,omitempty"`
+
+	// skipped 运行被跳过的原因，如 path filter excluded: docs/**
+	SkipReason string `json:"skip_reason,omitempty"`
+
+	// 没有变化而跳过的运行：对应的上次成功运行，以及按其耗时计算的节省的执行时间
+	UnchangedFromID *uint `json:"unchanged_from_id,omitempty"`
+	SavedMs         int64 `json:"saved_ms,omitempty" gorm:"default:0"`
+
+	// 执行前解析的配置与环境变量的校验和（密钥只以指纹参与计算），用于判断与上次成功运行相比是否有变化
+	InputChecksum string `json:"-"`
+
+	// 手动运行时要求强制执行，不检查是否有变化
+	Forced bool `json:"forced" gorm:"default:false"`
+
+	// 运行开始时解析后的配置快照（gzip+base64，已脱敏）
+	ResolvedConfig string `json:"-" gorm:"type:text"`
+
+	// 运行使用的流水线版本号，流水线尚无版本时为 0；仓库配置来源以 CommitSHA 为准。
+	// 使用的全部输入同时记录在配置快照中，共享资源的版本另记录在 RunResourceVersion，按原始输入重新运行时沿用原运行的版本
+	PipelineRevision int `json:"pipeline_revision,omitempty" gorm:"default:0;index"`
+
+	// 调试环境变量：记录各步骤实际收到的变量值（密钥只记录指纹），只有项目所有者可以开启
+	DebugEnv bool `json:"debug_env" gorm:"default:false"`
+
+	// 运行结束后保留工作区（失败运行总是保留），保留期内可只读浏览
+	KeepWorkspace bool `json:"keep_workspace" gorm:"default:false"`
+
+	// 开启 debug_on_failure 的失败运行可打开调试终端的截止时间，截止前清理任务不处理该运行；
+	// DebugAvailable 在查询运行详情时计算
+	DebugUntil     *time.Time `json:"debug_until,omitempty"`
+	DebugAvailable bool       `json:"debug_available" gorm:"-"`
+
+	// 运行期间触及资源限制（等待脚本执行名额、日志缓冲溢出），步骤耗时不具代表性，不计入性能基线
+	ResourceLimited bool `json:"resource_limited" gorm:"default:false"`
+
+	// 数据库中日志的字节数；日志超过项目的存储上限被压缩归档后 LogOutput 清空，由 LogArchive 记录归档位置
+	LogSize     int64 `json:"log_size" gorm:"default:0"`
+	LogArchived bool  `json:"log_archived" gorm:"default:false"`
+
+	// 运行标注，如系统检测到的步骤性能回退，查询运行详情时附带
+	Annotations []RunAnnotation `json:"annotations,omitempty" gorm:"-"`
+
+	// 各步骤测试报告的汇总，查询运行详情时计算
+	TestSummary *TestSummary `json:"test_summary,omitempty" gorm:"-"`
+
+	// 运行中的进度与预计完成时间，运行在本实例执行时查询运行详情附带
+	Progress *progress.Snapshot `json:"progress,omitempty" gorm:"-"`
+
+	// 制品来源：本次运行通过 artifact_from 步骤使用的制品，以及本次运行的制品被哪些运行使用，查询运行详情时计算
+	ConsumedArtifacts []ArtifactConsumption `json:"consumed_artifacts,omitempty" gorm:"-"`
+	ArtifactConsumers []ArtifactConsumption `json:"artifact_consumers,omitempty" gorm:"-"`
 	
 	// 流水线关联
 	PipelineID uint     `json:"pipeline_id" gorm:"not null"`
@@ -144,7 +644,32 @@ type PipelineRun struct {
 	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	
 	// 关联关系
-	Steps []PipelineStep `json:"steps,omitempty" gorm:"foreignKey:PipelineRunID"`
+	Steps  []PipelineStep `json:"steps,omitempty" gorm:"foreignKey:PipelineRunID"`
+	Labels []RunLabel     `json:"labels,omitempty" gorm:"foreignKey:PipelineRunID"`
+}
+
+// RunLabel 运行标签，如 release、hotfix、experiment；触发时指定或由项目所有者之后添加
+type RunLabel struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PipelineRunID uint   `json:"-" gorm:"not null;uniqueIndex:idx_run_label"`
+	Label         string `json:"label" gorm:"size:64;not null;uniqueIndex:idx_run_label;index"`
+
+	// 添加标签的用户，触发时指定的标签为运行的触发用户
+	CreatedByID *uint `json:"created_by_id"`
+}
+
+// RunLabelRetention 按标签保留运行：KeepDays 为 0 时永久保留，否则运行结束超过该天数后清理。
+// 运行有多个标签时按保留最久的标签处理
+type RunLabelRetention struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+
+	ProjectID uint   `json:"-" gorm:"not null;uniqueIndex:idx_label_retention"`
+	Label     string `json:"label" gorm:"size:64;not null;uniqueIndex:idx_label_retention"`
+	KeepDays  int    `json:"keep_days"`
 }
 
 // PipelineStep 流水线步骤
@@ -155,20 +680,120 @@ type PipelineStep struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 	
 	Name        string     `json:"name" gorm:"not null"`
+	Type        string     `json:"type,omitempty" gorm:"size:64"`
 	StepOrder   int        `json:"step_order"`
 	Status      string     `json:"status" gorm:"default:pending"`
 	StartTime   *time.Time `json:"start_time"`
 	EndTime     *time.Time `json:"end_time"`
-	Duration    int64      `json:"duration"` // 步骤耗时（秒）
+	Duration    int64      `json:"duration"`    // 步骤耗时（秒），已弃用，使用 duration_ms
+	DurationMs  int64      `json:"duration_ms"` // 步骤耗时（毫秒）
 	Command     string     `json:"command" gorm:"type:text"`
 	LogOutput   string     `json:"log_output" gorm:"type:text"`
 	ErrorMsg    string     `json:"error_msg" gorm:"type:text"`
+
+	// 步骤在流水线配置中的配置项，由步骤类型的执行器解释；不保存到步骤记录
+	Config map[string]interface{} `json:"config,omitempty" gorm:"-"`
+
+	// 步骤的超时时间，如 10m 或 600（秒），未设置时使用阶段的超时时间或 deploy.timeout；不保存到步骤记录
+	Timeout Timeout `json:"timeout,omitempty" gorm:"-"`
+
+	// 复用的原运行步骤
+	ReusedFromID *uint `json:"reused_from_id"`
+
+	// 步骤输出（JSON 对象），如外部回调携带的 outputs，后续步骤以环境变量读取
+	Outputs string `json:"outputs,omitempty" gorm:"type:text"`
+
+	// 脚本实际收到的环境变量（JSON）：总是记录名称与来源层，运行开启调试时记录值，通过步骤详情查看
+	EnvCapture string `json:"-" gorm:"type:text"`
+
+	// 脚本进程实际应用的调度优先级（JSON），未调整时为空
+	ProcessPriority string `json:"process_priority,omitempty" gorm:"type:text"`
+
+	// external_wait 步骤的等待状态，查询运行详情时附带
+	ExternalWait *ExternalWait `json:"external_wait,omitempty" gorm:"foreignKey:PipelineStepID"`
+
+	// 步骤声明了测试报告时的解析结果
+	TestResult *TestResult `json:"test_result,omitempty" gorm:"foreignKey:PipelineStepID"`
 	
 	// 流水线执行关联
 	PipelineRunID uint        `json:"pipeline_run_id" gorm:"not null"`
 	PipelineRun   PipelineRun `json:"pipeline_run,omitempty" gorm:"foreignKey:PipelineRunID"`
 }
 
+// ExternalWait external_wait 步骤等待的外部回调；持久化保存，进程重启后据此恢复等待
+type ExternalWait struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"` // 开始等待的时间
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Token       string     `json:"token" gorm:"size:64;not null;uniqueIndex"` // 回调地址中的令牌
+	Secret      string     `json:"-" gorm:"size:128;not null"`                // 回调请求体的HMAC签名密钥
+	Description string     `json:"description"`                               // 等待的内容，如外部作业名称
+	Status      string     `json:"status" gorm:"size:20;not null;index"`
+	TimeoutAt   time.Time  `json:"timeout_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CompletedBy string     `json:"completed_by"` // callback、timeout、cancel 或完成操作的管理员用户名
+	Message     string     `json:"message" gorm:"type:text"`
+	Outputs     string     `json:"outputs,omitempty" gorm:"type:text"`
+
+	// 关联
+	PipelineRunID  uint `json:"pipeline_run_id" gorm:"not null;index"`
+	PipelineStepID uint `json:"pipeline_step_id" gorm:"not null;uniqueIndex"`
+	StepOrder      int  `json:"step_order"`
+}
+
+// IsWaiting 是否仍在等待回调
+func (w *ExternalWait) IsWaiting() bool {
+	return w.Status == ExternalWaitWaiting
+}
+
+// TestResult 步骤测试报告（JUnit XML、go test -json）的解析结果
+type TestResult struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Total      int     `json:"total"`
+	Passed     int     `json:"passed"`
+	Failed     int     `json:"failed"`
+	Skipped    int     `json:"skipped"`
+	Duration   float64 `json:"duration"`    // 报告中记录的测试耗时（秒），已弃用，使用 duration_ms
+	DurationMs int64   `json:"duration_ms"` // 报告中记录的测试耗时（毫秒）
+
+	ReportFiles int    `json:"report_files"`                        // 成功解析的报告文件数
+	Warnings    string `json:"warnings,omitempty" gorm:"type:text"` // 解析失败的文件及原因
+
+	// 失败用例，最多保留 100 个
+	Failures []TestCaseFailure `json:"failures,omitempty" gorm:"foreignKey:TestResultID"`
+
+	// 关联，PipelineID 用于跨运行统计用例稳定性
+	PipelineStepID uint `json:"pipeline_step_id" gorm:"not null;index"`
+	PipelineRunID  uint `json:"pipeline_run_id" gorm:"not null;index"`
+	PipelineID     uint `json:"pipeline_id" gorm:"not null;index"`
+}
+
+// TestCaseFailure 失败的测试用例
+type TestCaseFailure struct {
+	ID uint `json:"id" gorm:"primarykey"`
+
+	Name    string `json:"name" gorm:"size:512;not null"`
+	Message string `json:"message" gorm:"type:text"`
+
+	TestResultID  uint `json:"test_result_id" gorm:"not null;index"`
+	PipelineRunID uint `json:"pipeline_run_id" gorm:"not null;index"`
+	PipelineID    uint `json:"pipeline_id" gorm:"not null;index"`
+}
+
+// TestSummary 运行内所有步骤测试报告的汇总
+type TestSummary struct {
+	Total      int      `json:"total"`
+	Passed     int      `json:"passed"`
+	Failed     int      `json:"failed"`
+	Skipped    int      `json:"skipped"`
+	Duration   float64  `json:"duration"` // 秒，已弃用，使用 duration_ms
+	DurationMs int64    `json:"duration_ms"`
+	Failures   []string `json:"failures,omitempty"` // 失败用例名称
+}
+
 // Environment 环境变量模型
 type Environment struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
@@ -195,16 +820,626 @@ type Webhook struct {
 	
 	Name        string `json:"name" gorm:"not null" binding:"required"`
 	URL         string `json:"url" gorm:"not null"`
-	Secret      string `json:"secret"`
+	Secret      string `json:"-"`
 	Events      string `json:"events" gorm:"default:push"` // push, pull_request, etc.
 	Status      string `json:"status" gorm:"default:active"`
 	LastTrigger *time.Time `json:"last_trigger"`
+
+	// 因项目归档而停用，取消归档时自动恢复
+	PausedByArchive bool `json:"paused_by_archive" gorm:"default:false"`
+
+	// 推送事件的路径过滤（逗号分隔的 glob，如 src/**,go.mod），没有变更文件匹配时不运行；为空不过滤
+	PathFilters string `json:"path_filters"`
+	// 被过滤的事件仍创建 skipped 状态的运行并记录原因，便于查看为什么没有构建
+	RecordSkipped bool `json:"record_skipped" gorm:"default:false"`
+
+	// 运行标签规则（逗号分隔的 ref glob=标签，如 refs/tags/**=release），触发的运行带上匹配规则的标签
+	LabelRules string `json:"label_rules"`
+
+	// 来源限制（逗号分隔的 IP、CIDR 或 @github、@gitlab 托管平台地址段），为空不限制；
+	// 要求签名时只接受 HMAC-SHA256 签名，拒绝明文令牌、SHA1 签名与未配置密钥的校验
+	AllowedIPs       string `json:"allowed_ips"`
+	RequireSignature bool   `json:"require_signature" gorm:"default:false"`
+
+	// registry 项目的 Webhook 对接的镜像仓库类型（harbor、docker_registry），按仓库类型解析推送事件并校验请求头中的令牌；
+	// 为空时为 git 托管平台的 Webhook
+	RegistryType string `json:"registry_type,omitempty" gorm:"size:32"`
+
+	// 密钥轮换：重叠期内旧密钥仍可通过签名校验
+	PreviousSecret          string     `json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at"`
+	SecretRotatedAt         *time.Time `json:"secret_rotated_at"`
 	
 	// 项目关联
 	ProjectID uint    `json:"project_id" gorm:"not null"`
 	Project   Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
 }
 
+// WebhookDelivery Webhook投递记录
+type WebhookDelivery struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Event         string `json:"event"`
+	DeliveryID    string `json:"delivery_id"`
+	Status        string `json:"status"`         // accepted, rejected, duplicate
+	MatchedSecret string `json:"matched_secret"` // current, previous
+	Message       string `json:"message" gorm:"type:text"`
+	RemoteIP      string `json:"remote_ip"`
+
+	// Webhook关联
+	WebhookID uint `json:"webhook_id" gorm:"not null;index"`
+}
+
+// WebhookFingerprint Webhook投递指纹，去重窗口内相同指纹的投递不再重复触发运行
+type WebhookFingerprint struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Fingerprint string    `json:"fingerprint" gorm:"size:128;not null;uniqueIndex:idx_webhook_fingerprint"`
+	RunIDs      string    `json:"run_ids"` // 首次投递触发的运行ID，逗号分隔
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`
+
+	// Webhook关联
+	WebhookID uint `json:"webhook_id" gorm:"not null;uniqueIndex:idx_webhook_fingerprint"`
+}
+
+// RunWatch 流水线运行关注订阅，PipelineRunID 为空表示关注该流水线的所有后续运行
+type RunWatch struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID        uint  `json:"user_id" gorm:"not null;uniqueIndex:idx_run_watch"`
+	PipelineID    uint  `json:"pipeline_id" gorm:"not null;uniqueIndex:idx_run_watch"`
+	PipelineRunID *uint `json:"pipeline_run_id" gorm:"uniqueIndex:idx_run_watch"`
+}
+
+// Notification 站内通知
+type Notification struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Title    string     `json:"title" gorm:"not null"`
+	Content  string     `json:"content" gorm:"type:text"`
+	Link     string     `json:"link"`
+	Level    string     `json:"level" gorm:"default:normal"`    // normal, urgent
+	Channel  string     `json:"channel"`                        // email, in_app
+	Category string     `json:"category" gorm:"size:64;index"` // run_finished, performance_regression
+	IsRead   bool       `json:"is_read" gorm:"default:false"`
+	ReadAt   *time.Time `json:"read_at"`
+
+	// 待汇总到每日邮件的通知
+	DigestPending bool `json:"-" gorm:"default:false;index"`
+
+	// 用户关联
+	UserID uint `json:"user_id" gorm:"not null;index"`
+}
+
+// RunAnnotation 运行标注，source 为 system 时由系统生成，如成功运行中耗时超过基线阈值的步骤
+type RunAnnotation struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PipelineRunID  uint   `json:"pipeline_run_id" gorm:"not null;index"`
+	PipelineStepID *uint  `json:"pipeline_step_id"`
+	Kind           string `json:"kind" gorm:"size:64;index"` // performance_regression
+	Source         string `json:"source" gorm:"default:system"`
+	Message        string `json:"message" gorm:"type:text"`
+
+	// 性能回退时的步骤耗时与基线中位数（毫秒）
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	BaselineMs int64 `json:"baseline_ms,omitempty"`
+}
+
+// FailureSpikeAlert 失败率突增告警：短窗口内项目或流水线的失败率超过基线，ResolvedAt 为空表示告警中。
+// 项目告警时不再单独告警其中的流水线
+type FailureSpikeAlert struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID  uint  `json:"project_id" gorm:"not null;index"`
+	PipelineID *uint `json:"pipeline_id,omitempty" gorm:"index"` // 流水线告警，项目告警为空
+
+	// 告警时短窗口内结束的运行数、失败数与失败率，以及长窗口的基线失败率
+	Runs         int     `json:"runs"`
+	Failures     int     `json:"failures"`
+	Rate         float64 `json:"rate"`
+	BaselineRate float64 `json:"baseline_rate"`
+
+	FailureKind string `json:"failure_kind"`                // 短窗口内最多的失败分类，步骤执行失败为 step
+	Pipelines   string `json:"pipelines" gorm:"type:text"` // 受影响的流水线（JSON，见 SpikePipeline）
+
+	TriggeredAt time.Time  `json:"triggered_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty" gorm:"index"`
+}
+
+// AffectedPipelines 解析告警中受影响的流水线
+func (a *FailureSpikeAlert) AffectedPipelines() []SpikePipeline {
+	var pipelines []SpikePipeline
+	if a.Pipelines != "" {
+		json.Unmarshal([]byte(a.Pipelines), &pipelines)
+	}
+	return pipelines
+}
+
+// Summary 告警的说明，windowMinutes 为短窗口的分钟数
+func (a *FailureSpikeAlert) Summary(windowMinutes int) string {
+	return fmt.Sprintf("最近 %d 分钟内 %d/%d 次运行失败（%.0f%%，基线 %.0f%%），主要失败分类 %s",
+		windowMinutes, a.Failures, a.Runs, a.Rate*100, a.BaselineRate*100, a.FailureKind)
+}
+
+// SpikePipeline 失败率突增告警中受影响的流水线及其在短窗口内的运行数与失败数
+type SpikePipeline struct {
+	PipelineID uint   `json:"pipeline_id"`
+	Name       string `json:"name"`
+	Runs       int    `json:"runs"`
+	Failures   int    `json:"failures"`
+}
+
+// LogArchive 压缩归档的运行日志：gzip 文件按固定行数分段压缩，行偏移索引保存在同名 .idx 文件中，读取时只解压需要的分段
+type LogArchive struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PipelineRunID  uint   `json:"pipeline_run_id" gorm:"not null;uniqueIndex"`
+	ProjectID      uint   `json:"project_id" gorm:"not null;index"`
+	Path           string `json:"-"`
+	Size           int64  `json:"size"`            // 原始日志字节数
+	CompressedSize int64  `json:"compressed_size"` // 压缩后字节数
+	Lines          int    `json:"lines"`
+}
+
+// RunLogSegment 运行日志全文检索的一段索引，对应日志写入器的一次批量写入；SQLite 的内容只保存在全文检索虚拟表中
+type RunLogSegment struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"` // 该段日志写入的时间
+
+	PipelineRunID uint   `json:"pipeline_run_id" gorm:"not null;index"`
+	StartLine     int    `json:"start_line"` // 该段第一行在运行日志中的行号，从 1 开始
+	LineCount     int    `json:"line_count"`
+	Size          int    `json:"size"`
+	Content       string `json:"-" gorm:"type:text"`
+}
+
+// ProjectMember 项目成员：viewer 可以查看流水线与运行，maintainer 还可以触发、取消与重跑运行并处理加入申请；
+// 修改与删除项目及流水线仍只有所有者与管理员可以操作
+type ProjectMember struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID       uint   `json:"project_id" gorm:"not null;uniqueIndex:idx_project_member"`
+	UserID          uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_project_member"`
+	User            *User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role            string `json:"role" gorm:"size:16;not null"`
+	GrantedByID     *uint  `json:"granted_by_id"` // 自动批准时为空
+	AccessRequestID *uint  `json:"access_request_id"`
+}
+
+// ProjectAccessRequest 加入项目的申请。同一用户对同一项目只保留一条待处理的申请，重复申请合并到其中；
+// 超过有效期未处理的申请过期
+type ProjectAccessRequest struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID uint     `json:"project_id" gorm:"not null;index"`
+	Project   *Project `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+	UserID    uint     `json:"user_id" gorm:"not null;index"`
+	User      *User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role      string   `json:"role" gorm:"size:16;not null"`
+	Message   string   `json:"message" gorm:"type:text"`
+	Status    string   `json:"status" gorm:"size:16;not null;index"`
+	Requests  int      `json:"requests" gorm:"default:1"` // 合并的申请次数
+
+	ExpiresAt    time.Time  `json:"expires_at"`
+	AutoApproved bool       `json:"auto_approved" gorm:"default:false"`
+	DecidedByID  *uint      `json:"decided_by_id"`
+	DecidedAt    *time.Time `json:"decided_at"`
+	DecisionNote string     `json:"decision_note"`
+}
+
+// Backup 数据库备份记录。备份文件经 gzip 压缩并使用静态数据加密密钥加密后保存在存储目录，
+// Checksum 为加密后文件的 sha256；Verified 表示备份已通过恢复校验，VerifyDetail 记录校验方式与结果
+type Backup struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Status        string `json:"status" gorm:"size:16;not null;index"`
+	Trigger       string `json:"trigger" gorm:"size:16;not null"`
+	TriggeredByID *uint  `json:"triggered_by_id"`
+	DatabaseType  string `json:"database_type" gorm:"size:16"`
+	FileName      string `json:"file_name"`
+	Size          int64  `json:"size"`
+	Checksum      string `json:"checksum" gorm:"size:64"`
+	DurationMs    int64  `json:"duration_ms"`
+
+	Verified     bool       `json:"verified" gorm:"default:false"`
+	VerifyDetail string     `json:"verify_detail" gorm:"type:text"`
+	Error        string     `json:"error,omitempty" gorm:"type:text"`
+	FinishedAt   *time.Time `json:"finished_at"`
+}
+
+// ArtifactBlob 按内容寻址存储的制品数据（sha256），多个制品可共享同一份数据
+type ArtifactBlob struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Digest   string `json:"digest" gorm:"size:64;not null;uniqueIndex"` // sha256 十六进制
+	Size     int64  `json:"size"`
+	RefCount int    `json:"ref_count" gorm:"default:0"` // 引用该数据的制品数，为0时可清理
+}
+
+// Artifact 流水线运行产出的制品
+type Artifact struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name   string `json:"name" gorm:"not null"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest" gorm:"size:64;index"`
+
+	// 内容数据
+	BlobID uint          `json:"-" gorm:"not null;index"`
+	Blob   *ArtifactBlob `json:"-" gorm:"foreignKey:BlobID"`
+
+	// 运行关联
+	PipelineRunID uint `json:"pipeline_run_id" gorm:"not null;index"`
+
+	// 取用该制品的运行（仅接口返回）
+	Consumers []ArtifactConsumption `json:"consumers,omitempty" gorm:"-"`
+}
+
+// ArtifactConsumption 制品来源记录：运行通过 artifact_from 步骤使用了其他运行产出的制品，不重新构建。
+// 带有永久保留标签的运行使用的制品不会被清理
+type ArtifactConsumption struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// 使用制品的运行与步骤
+	PipelineRunID  uint `json:"pipeline_run_id" gorm:"not null;index"`
+	PipelineStepID uint `json:"pipeline_step_id" gorm:"not null"`
+
+	// 使用的制品及产出它的运行
+	ConsumedArtifactID uint      `json:"consumed_artifact_id" gorm:"not null;index"`
+	ConsumedArtifact   *Artifact `json:"consumed_artifact,omitempty" gorm:"foreignKey:ConsumedArtifactID"`
+	SourceRunID        uint      `json:"source_run_id" gorm:"not null;index"`
+
+	Path string `json:"path"` // 制品在工作区中的相对路径
+}
+
+// ArtifactShare 跨项目使用制品的授权：ConsumerProjectID 的流水线可以通过 artifact_from 使用 ProjectID 的制品
+type ArtifactShare struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ProjectID         uint `json:"project_id" gorm:"not null;uniqueIndex:idx_artifact_share"`
+	ConsumerProjectID uint `json:"consumer_project_id" gorm:"not null;uniqueIndex:idx_artifact_share"`
+
+	CreatedByID *uint `json:"created_by_id"`
+}
+
+// AuditLog 审计日志
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Action       string `json:"action" gorm:"not null;index"`
+	ResourceType string `json:"resource_type" gorm:"index"`
+	ResourceID   uint   `json:"resource_id" gorm:"index"`
+	Description  string `json:"description" gorm:"type:text"`
+	IP           string `json:"ip"`
+	UserAgent    string `json:"user_agent"`
+
+	// 变更前后的内容（已脱敏），用于查看修改了什么
+	Before  string `json:"-" gorm:"type:text"`
+	After   string `json:"-" gorm:"type:text"`
+	HasDiff bool   `json:"has_diff" gorm:"-"`
+
+	// 操作用户
+	UserID *uint `json:"user_id" gorm:"index"`
+}
+
+// EventOutbox 系统事件发件箱，事件与触发它的变更一起写入，按 ID 顺序投递到各事件接收端
+type EventOutbox struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	EventID     string `json:"event_id" gorm:"size:36;uniqueIndex"`
+	Type        string `json:"type" gorm:"size:64;index"`
+	ResourceKey string `json:"resource_key" gorm:"size:128;index"` // 资源类型:ID，同一资源的事件按顺序投递
+	Payload     string `json:"-" gorm:"type:text"`                // 事件信封 JSON，不含序号
+}
+
+// ChangeJournal 资源变更日志，由数据库回调与变更在同一事务中写入，界面通过 /changes 增量同步列表。
+// 项目中的资源记录 ProjectID，只属于某个用户的资源（如通知）记录 UserID
+type ChangeJournal struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	ResourceType string `json:"resource_type" gorm:"size:32"`
+	ResourceID   uint   `json:"resource_id"`
+	Kind         string `json:"kind" gorm:"size:10"` // created, updated, deleted
+	ProjectID    uint   `json:"project_id" gorm:"index"`
+	UserID       uint   `json:"user_id" gorm:"index"`
+}
+
+// EventSinkCursor 事件接收端的投递进度，接收端在配置中按名称标识
+type EventSinkCursor struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Sink            string     `json:"sink" gorm:"size:64;uniqueIndex"`
+	LastSequence    uint       `json:"last_sequence"` // 已投递的最后一条发件箱记录ID
+	LastDeliveredAt *time.Time `json:"last_delivered_at"`
+	Failures        int        `json:"failures"` // 当前事件连续投递失败次数
+	LastError       string     `json:"last_error" gorm:"type:text"`
+}
+
+// EventReplayRequest 重放事件请求，重新投递 [from, to) 时间范围内的事件
+type EventReplayRequest struct {
+	Sink string    `json:"sink" binding:"required"`
+	From time.Time `json:"from" binding:"required"`
+	To   time.Time `json:"to" binding:"required"`
+}
+
+// CircuitBreakerResetRequest 重置熔断器请求，destination 为空时重置全部目标
+type CircuitBreakerResetRequest struct {
+	Destination string `json:"destination"`
+}
+
+// FeatureFlag 功能开关的覆盖值：设置 UserID 为用户覆盖，设置 ProjectID 为项目覆盖，都为 0 表示全局覆盖，
+// 没有覆盖时使用代码中定义的默认值
+type FeatureFlag struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name      string `json:"name" gorm:"not null;uniqueIndex:idx_feature_flag_scope"`
+	ProjectID uint   `json:"project_id" gorm:"not null;default:0;uniqueIndex:idx_feature_flag_scope"`
+	UserID    uint   `json:"user_id" gorm:"not null;default:0;uniqueIndex:idx_feature_flag_scope"`
+	Enabled   bool   `json:"enabled"`
+
+	// 最后修改的用户
+	UpdatedByID *uint `json:"updated_by_id"`
+}
+
+// OutboundException 项目的出站例外：放行出站策略默认禁止的地址（回环、链路本地与内网），
+// 对配置中明确禁止的地址无效。只有管理员可以添加
+type OutboundException struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ProjectID uint   `json:"project_id" gorm:"index;not null"`
+	Target    string `json:"target" gorm:"size:255;not null"` // IP、网段或主机名，主机名支持 *.example.com
+	Reason    string `json:"reason" gorm:"type:text"`
+
+	CreatedByID *uint `json:"created_by_id"`
+}
+
+// 运行输入的类型
+const (
+	RunInputPipelineConfig = "pipeline_config" // 流水线保存的配置，版本为流水线版本号
+	RunInputRepoConfig     = "repo_config"     // 仓库中的配置文件，版本为提交哈希
+)
+
+// RunInput 运行引用的输入及实际使用的版本；共享资源的 Kind 为资源类型，ResourceID 为资源ID
+type RunInput struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	ResourceID uint   `json:"resource_id,omitempty"`
+	URL        string `json:"url,omitempty"` // 查看该版本内容的接口，没有对应接口时为空
+}
+
+// 共享资源的类型，同时作为运行输入的类型
+const (
+	SharedResourceScript   = "script"    // 共享脚本，脚本步骤以 script_ref 引用，内容为脚本
+	SharedResourceEnvGroup = "env_group" // 环境变量组，流水线以 env_groups 引用，内容为变量名到取值的 JSON 对象，不应包含密钥
+	SharedResourceTemplate = "template"  // 流水线模板，流水线以 template 引用，内容为流水线配置
+)
+
+// SharedResource 多个流水线共用的脚本、环境变量组或模板。每次修改内容生成新版本，
+// LatestVersion 只增不减，删除的版本号不会再次使用
+type SharedResource struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Kind          string `json:"kind" gorm:"size:16;not null;uniqueIndex:idx_shared_resource_name"`
+	Name          string `json:"name" gorm:"size:128;not null;uniqueIndex:idx_shared_resource_name"`
+	Description   string `json:"description"`
+	LatestVersion int    `json:"latest_version"`
+
+	CreatedByID *uint `json:"created_by_id"`
+}
+
+// SharedResourceVersion 共享资源的一个版本，版本号在资源内从 1 开始递增
+type SharedResourceVersion struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ResourceID uint   `json:"resource_id" gorm:"not null;uniqueIndex:idx_shared_resource_version"`
+	Version    int    `json:"version" gorm:"not null;uniqueIndex:idx_shared_resource_version"`
+	Content    string `json:"content,omitempty" gorm:"type:text"`
+
+	// 保存该版本的用户
+	CreatedByID *uint `json:"created_by_id"`
+}
+
+// RunResourceVersion 运行使用的共享资源版本，运行开始解析配置时写入；按原始输入重新运行时在创建运行时从原运行复制。
+// 用于按资源版本查找运行，以及拒绝删除仍被运行引用的版本
+type RunResourceVersion struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PipelineRunID uint   `json:"pipeline_run_id" gorm:"not null;uniqueIndex:idx_run_resource"`
+	ResourceID    uint   `json:"resource_id" gorm:"not null;uniqueIndex:idx_run_resource;index:idx_resource_version"`
+	Version       int    `json:"version" gorm:"not null;index:idx_resource_version"`
+	Kind          string `json:"kind" gorm:"size:16"`
+	Name          string `json:"name" gorm:"size:128"`
+}
+
+// PipelineRevision 流水线每次保存后的版本，用于查看配置变更与对比任意两个版本
+type PipelineRevision struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PipelineID uint `json:"pipeline_id" gorm:"not null;uniqueIndex:idx_pipeline_revision"`
+	Revision   int  `json:"revision" gorm:"not null;uniqueIndex:idx_pipeline_revision"` // 从 1 开始递增
+
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Config       string `json:"-" gorm:"type:text"`
+	Trigger      string `json:"trigger"`
+	CronExpr     string `json:"cron_expr"`
+	ConfigSource string `json:"config_source"`
+	ConfigPath   string `json:"config_path"`
+	MutexGroup   string `json:"mutex_group"`
+
+	// 保存该版本的用户
+	CreatedByID *uint `json:"created_by_id"`
+}
+
+// APIToken 供自动化系统（如故障处理机器人）调用接口的长期令牌，只保存哈希
+type APIToken struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name      string     `json:"name" gorm:"not null"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"` // sha256 十六进制
+	Prefix    string     `json:"prefix"`                                // 令牌前几位，便于识别
+	Scope     string     `json:"scope" gorm:"default:admin"`            // admin
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+
+	// 使用情况
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+
+	// 允许使用令牌的来源（逗号分隔的 IP 或 CIDR），为空不限制
+	AllowedIPs string `json:"allowed_ips"`
+
+	// 创建令牌的用户，令牌以该用户身份操作
+	UserID uint `json:"user_id" gorm:"not null;index"`
+}
+
+// FeedToken 订阅令牌，放在订阅地址的查询参数中供阅读器访问运行结果的 Atom 订阅；
+// 与API令牌分开，只能读取订阅，每个用户同时只有一个有效令牌，只保存哈希
+type FeedToken struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"` // sha256 十六进制
+	Prefix    string     `json:"prefix"`                                // 令牌前几位，便于识别
+	RevokedAt *time.Time `json:"revoked_at"`
+
+	// 使用情况
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+
+	// 令牌所属用户，订阅按该用户的权限过滤
+	UserID uint `json:"user_id" gorm:"not null;index"`
+}
+
+// Invite 注册邀请：管理员生成的一次性邀请令牌，注册时兑换，可指定角色、邮箱与有效期；只保存哈希
+type Invite struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"` // sha256 十六进制
+	Prefix    string     `json:"prefix"`                                // 令牌前几位，便于识别
+	Email     string     `json:"email"`                                 // 指定时只能以该邮箱注册
+	Role      string     `json:"role" gorm:"default:user"`              // 兑换后用户的角色
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+
+	// 兑换情况
+	UsedAt   *time.Time `json:"used_at"`
+	UsedByID *uint      `json:"used_by_id"`
+
+	CreatedByID uint `json:"created_by_id" gorm:"not null"`
+}
+
+// EmailVerification 注册邮箱验证令牌，验证后账户才能登录；只保存哈希
+type EmailVerification struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// DeployFreeze 部署冻结，生效期间范围内的部署被拒绝，多个冻结可同时生效
+type DeployFreeze struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Scope       string     `json:"scope" gorm:"not null"` // global, environment, projects
+	Environment string     `json:"environment"`           // scope 为 environment 时的环境标签，如 production
+	ProjectIDs  string     `json:"project_ids"`           // scope 为 projects 时的项目ID，逗号分隔
+	Reason      string     `json:"reason" gorm:"type:text;not null"`
+	ExpiresAt   *time.Time `json:"expires_at" gorm:"index"` // 为空表示需要手动解除
+
+	// 解除信息
+	LiftedAt   *time.Time `json:"lifted_at" gorm:"index"`
+	LiftedByID *uint      `json:"lifted_by_id"`
+	LiftReason string     `json:"lift_reason"`
+
+	// 创建者，通过API令牌创建时记录令牌
+	CreatedByID uint  `json:"created_by_id" gorm:"not null"`
+	APITokenID  *uint `json:"api_token_id"`
+}
+
+// RedactionRule 日志脱敏规则，匹配的内容在写入日志、推送实时日志与发送通知前被替换
+type RedactionRule struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name        string `json:"name" gorm:"not null"`
+	Pattern     string `json:"pattern" gorm:"type:text;not null"` // RE2 正则表达式
+	Replacement string `json:"replacement"`                       // 为空时替换为 ******
+
+	// 所属项目，为空表示对所有项目生效的全局规则（仅管理员可管理）
+	ProjectID   *uint `json:"project_id" gorm:"index"`
+	CreatedByID uint  `json:"created_by_id" gorm:"not null"`
+}
+
+// Worker 独立执行器，启动时按名称注册，运行期间定期更新心跳与正在执行的运行数
+type Worker struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name     string `json:"name" gorm:"size:100;uniqueIndex;not null"`
+	Hostname string `json:"hostname"`
+	Version  string `json:"version"`
+	Status   string `json:"status" gorm:"size:20;not null"` // active、draining、stopped
+	Capacity int    `json:"capacity"`                       // 同时执行的运行数上限
+	Running  int    `json:"running"`                        // 最近一次心跳时正在执行的运行数
+	Shells   string `json:"shells"`                         // 注册时探测到的可用解释器（逗号分隔）
+
+	StartedAt       time.Time `json:"started_at"`
+	LastHeartbeatAt time.Time `json:"last_heartbeat_at" gorm:"index"`
+}
+
+// LeaderLease 多个实例中只由一个执行的后台任务的租约，持有者在 ExpiresAt 前续期，过期后其他实例可以接管
+type LeaderLease struct {
+	Name      string    `json:"name" gorm:"primarykey;size:64"`
+	Holder    string    `json:"holder" gorm:"size:255"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // SystemConfig 系统配置模型
 type SystemConfig struct {
 	ID        uint           `json:"id" gorm:"primarykey"`
@@ -226,15 +1461,22 @@ const (
 	RoleUser  = "user"
 	
 	// 用户状态
-	StatusActive   = "active"
-	StatusInactive = "inactive"
-	StatusBlocked  = "blocked"
+	StatusActive     = "active"
+	StatusInactive   = "inactive"
+	StatusBlocked    = "blocked"
+	StatusUnverified = "unverified" // 注册后等待验证邮箱，验证前不能登录
+	StatusDeleted    = "deleted"    // 个人数据已删除，账号保留为匿名占位以维持运行、部署等记录的关联
 	
 	// 项目状态
 	ProjectStatusActive   = "active"
 	ProjectStatusInactive = "inactive"
 	ProjectStatusArchived = "archived"
 	
+	// 项目源码来源
+	ProjectSourceGit      = "git"
+	ProjectSourceArchive  = "archive"
+	ProjectSourceRegistry = "registry"
+	
 	// 部署状态
 	DeployStatusPending    = "pending"
 	DeployStatusRunning    = "running"
@@ -246,6 +1488,21 @@ const (
 	PipelineStatusActive   = "active"
 	PipelineStatusInactive = "inactive"
 	PipelineStatusArchived = "archived"
+
+	// 定时流水线预热状态
+	PrewarmStatusRunning = "running"
+	PrewarmStatusSuccess = "success"
+	PrewarmStatusFailed  = "failed"
+	PrewarmStatusSkipped = "skipped"
+
+	// 本机执行步骤进程的 IO 调度类，none 表示不调整（不继承全局配置）
+	IOClassNone       = "none"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+
+	// 流水线配置来源
+	ConfigSourceStored = "stored"
+	ConfigSourceRepo   = "repo"
 	
 	// 流水线触发类型
 	TriggerManual     = "manual"
@@ -265,13 +1522,143 @@ const (
 	RunStatusSuccess   = "success"
 	RunStatusFailed    = "failed"
 	RunStatusCancelled = "cancelled"
+	RunStatusSkipped   = "skipped" // 事件被过滤或与上次成功运行相比没有变化，未执行步骤
 	
 	// 步骤状态
-	StepStatusPending   = "pending"
-	StepStatusRunning   = "running"
-	StepStatusSuccess   = "success"
-	StepStatusFailed    = "failed"
-	StepStatusSkipped   = "skipped"
+	StepStatusPending         = "pending"
+	StepStatusRunning         = "running"
+	StepStatusSuccess         = "success"
+	StepStatusFailed          = "failed"
+	StepStatusSkipped         = "skipped"
+	StepStatusReused          = "reused"
+	StepStatusWaitingExternal = "waiting_external" // 等待外部系统回调
+
+	// 外部回调等待状态
+	ExternalWaitWaiting   = "waiting"
+	ExternalWaitSucceeded = "succeeded"
+	ExternalWaitFailed    = "failed"
+	ExternalWaitTimedOut  = "timed_out"
+	ExternalWaitCancelled = "cancelled"
+
+	// 运行失败分类
+	FailureKindInfra           = "infra"
+	FailureKindFrozen          = "frozen"           // 部署冻结期间被拒绝的部署
+	FailureKindCloudCredential = "cloud_credential" // 部署步骤未能获得云平台临时凭证
+
+	// 云平台临时凭证
+	CloudProviderAWS = "aws"
+
+	// 运行取消原因
+	CancelReasonUser     = "user"     // 用户取消或管理员移出等待队列
+	CancelReasonTimeout  = "timeout"  // 超过策略的运行超时而中止，运行状态为 failed
+	CancelReasonShutdown = "shutdown" // 服务器关闭时仍在等待队列中、未开始执行
+	CancelReasonUnknown  = "unknown"  // 升级前取消的运行
+
+	// 独立执行器状态
+	WorkerStatusActive   = "active"
+	WorkerStatusDraining = "draining" // 收到停止信号，执行完已领取的运行后退出，不再领取
+	WorkerStatusStopped  = "stopped"
+
+	// API令牌范围
+	APITokenScopeAdmin = "admin"
+
+	// 用户注册策略
+	RegistrationOpen   = "open"   // 任何人都可以注册
+	RegistrationClosed = "closed" // 只能由管理员创建用户
+	RegistrationInvite = "invite" // 需要管理员生成的邀请
+	RegistrationDomain = "domain" // 邮箱需属于允许的域名
+
+	// 注册策略的系统配置键
+	ConfigRegistrationPolicy  = "registration_policy"
+	ConfigRegistrationDomains = "registration_allowed_domains" // 逗号分隔
+	ConfigRegistrationVerify  = "registration_verify_email"    // true, false
+
+	// 托管平台Webhook地址段的刷新状态（JSON），键为前缀加平台名称
+	ConfigProviderRangesPrefix = "provider_ranges_"
+
+	// 变更日志已清理到的日志ID，早于该位置的变更游标需要重新获取全部列表
+	ConfigChangesPrunedThrough = "changes_pruned_through"
+
+	// 部署冻结范围
+	FreezeScopeGlobal      = "global"
+	FreezeScopeEnvironment = "environment"
+	FreezeScopeProjects    = "projects"
+
+	// SSH密钥用途
+	SSHKeyPurposeGeneral   = "general"    // 用户密钥，可用于Git和远程部署
+	SSHKeyPurposeDeployKey = "deploy_key" // 项目部署密钥，仅用于Git只读访问
+
+	// Webhook投递状态
+	DeliveryStatusAccepted  = "accepted"
+	DeliveryStatusRejected  = "rejected"
+	DeliveryStatusDuplicate = "duplicate"
+
+	// Webhook签名匹配的密钥
+	SecretMatchCurrent  = "current"
+	SecretMatchPrevious = "previous"
+
+	// registry 项目的 Webhook 对接的镜像仓库类型
+	RegistryTypeHarbor = "harbor"
+	RegistryTypeDocker = "docker_registry" // Docker Registry（distribution）的通知
+
+	// registry 项目运行部署的制品类型
+	ArtifactKindImage   = "image"
+	ArtifactKindTarball = "tarball"
+
+	// 通知渠道
+	NotifyChannelEmail = "email"
+	NotifyChannelInApp = "in_app"
+	NotifyChannelNone  = "none"
+
+	// 通知级别
+	NotifyLevelNormal = "normal"
+	NotifyLevelUrgent = "urgent"
+
+	// 通知类别
+	NotifyCategoryRunFinished           = "run_finished"
+	NotifyCategoryPerformanceRegression = "performance_regression"
+	NotifyCategoryLogQuota              = "log_quota"
+	NotifyCategoryAccessRequest         = "access_request"
+	NotifyCategoryBackup                = "backup"
+	NotifyCategoryRelease               = "release"
+	NotifyCategoryFailureSpike          = "failure_spike"
+
+	// 项目成员角色
+	ProjectRoleViewer     = "viewer"
+	ProjectRoleMaintainer = "maintainer"
+
+	// 加入项目申请状态
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+	AccessRequestExpired  = "expired"
+
+	// 项目健康状态
+	HealthOK      = "ok"
+	HealthWarning = "warning"
+	HealthError   = "error"
+
+	// 数据库备份状态与触发方式
+	BackupStatusRunning   = "running"
+	BackupStatusSuccess   = "success"
+	BackupStatusFailed    = "failed"
+	BackupTriggerSchedule = "scheduled"
+	BackupTriggerManual   = "manual"
+
+	// 运行标注
+	AnnotationPerformanceRegression = "performance_regression"
+	AnnotationSourceSystem          = "system"
+
+	// 部署目标漂移状态
+	DriftStatusUnknown     = "unknown"
+	DriftStatusClean       = "clean"
+	DriftStatusDrifted     = "drifted"
+	DriftStatusUnreachable = "unreachable"
+
+	// SSH密钥状态
+	SSHKeyStatusActive  = "active"
+	SSHKeyStatusPending = "pending"
+	SSHKeyStatusRevoked = "revoked"
 )
 
 // 请求和响应结构体
@@ -282,6 +1669,33 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// RegisterRequest 注册请求，invite 策略下需携带邀请令牌
+type RegisterRequest struct {
+	Username    string `json:"username" binding:"required"`
+	Email       string `json:"email" binding:"required,email"`
+	Password    string `json:"password" binding:"required,min=6"`
+	InviteToken string `json:"invite_token"`
+}
+
+// VerifyEmailRequest 验证注册邮箱请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RegistrationPolicyRequest 设置注册策略请求
+type RegistrationPolicyRequest struct {
+	Policy         string   `json:"policy" binding:"required"` // open, closed, invite, domain
+	AllowedDomains []string `json:"allowed_domains"`
+	VerifyEmail    bool     `json:"verify_email"`
+}
+
+// CreateInviteRequest 创建注册邀请请求
+type CreateInviteRequest struct {
+	Email          string `json:"email" binding:"omitempty,email"` // 指定时发送邀请邮件，且只能以该邮箱注册
+	Role           string `json:"role"`                            // 默认 user
+	ExpiresInHours int    `json:"expires_in_hours"`                // 默认为配置的有效期
+}
+
 // LoginResponse 登录响应
 type LoginResponse struct {
 	Token string `json:"token"`
@@ -317,16 +1731,231 @@ type CreateSSHKeyRequest struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	Username string `json:"username"`
+	BastionConfig
 }
 
 // CreatePipelineRequest 创建流水线请求
 type CreatePipelineRequest struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
-	Config      string `json:"config" binding:"required"`
+	Config      string `json:"config"` // 配置来源为 stored 时必填
 	Trigger     string `json:"trigger"`
 	CronExpr    string `json:"cron_expr"`
 	ProjectID   uint   `json:"project_id" binding:"required"`
+
+	ConfigSource string `json:"config_source"`
+	ConfigPath   string `json:"config_path"`
+	AllowForkPRs bool   `json:"allow_fork_prs"`
+
+	PrewarmMinutes int    `json:"prewarm_minutes"`
+	PrewarmCaches  string `json:"prewarm_caches"`
+
+	MutexGroup string `json:"mutex_group"` // 必须是项目已定义的互斥组
+
+	SkipIfUnchanged   bool   `json:"skip_if_unchanged"`
+	SkipCompareInputs bool   `json:"skip_compare_inputs"`
+	SkipPaths         string `json:"skip_paths"`
+
+	StrictEnv bool   `json:"strict_env"`
+	EnvIgnore string `json:"env_ignore"`
+
+	DebugOnFailure bool `json:"debug_on_failure"`
+
+	ProcessNice    *int   `json:"process_nice"`
+	ProcessIOClass string `json:"process_io_class"`
+	ProcessIOLevel *int   `json:"process_io_level"`
+}
+
+// SetFeatureFlagRequest 设置功能开关请求：enabled 为 null 时删除覆盖值，恢复为上一级的取值
+type SetFeatureFlagRequest struct {
+	Enabled   *bool `json:"enabled"`
+	ProjectID uint  `json:"project_id"` // 0 表示全局
+	UserID    uint  `json:"user_id"`    // 用户覆盖，不能与 project_id 同时设置
+}
+
+// PerformanceThresholdsRequest 设置流水线性能回退检查阈值请求，0 表示使用全局配置
+type PerformanceThresholdsRequest struct {
+	Multiplier     float64 `json:"perf_multiplier"`
+	BaselineRuns   int     `json:"perf_baseline_runs"`
+	MinStepSeconds int     `json:"perf_min_step_seconds"`
+	Disabled       bool    `json:"perf_check_disabled"`
+}
+
+// ProjectPolicyRequest 设置项目默认策略请求，强制标记只有管理员可以修改
+type ProjectPolicyRequest struct {
+	NotifyRules       []PolicyNotifyRule `json:"notify_rules"`
+	Approvals         []PolicyApproval   `json:"approvals"`
+	RunTimeoutMinutes int                `json:"run_timeout_minutes"`
+	NotifyEnforced    bool               `json:"notify_enforced"`
+	ApprovalsEnforced bool               `json:"approvals_enforced"`
+	TimeoutEnforced   bool               `json:"timeout_enforced"`
+}
+
+// PipelinePolicyRequest 设置流水线对项目策略的覆盖，通知规则与审批要求为 null、运行超时为 0 时继承项目策略
+type PipelinePolicyRequest struct {
+	NotifyRules       *[]PolicyNotifyRule `json:"notify_rules"`
+	Approvals         *[]PolicyApproval   `json:"approvals"`
+	RunTimeoutMinutes int                 `json:"run_timeout_minutes"`
+}
+
+// CloudCredentialRequest 配置项目部署扮演的云平台角色
+type CloudCredentialRequest struct {
+	Environment     string `json:"environment"`
+	Provider        string `json:"provider" binding:"required"`
+	RoleARN         string `json:"role_arn" binding:"required"`
+	Region          string `json:"region"`
+	ExternalID      string `json:"external_id"`
+	DurationSeconds int    `json:"duration_seconds"`
+}
+
+// ArtifactShareRequest 授权其他项目使用本项目制品的请求
+type ArtifactShareRequest struct {
+	ConsumerProjectID uint `json:"consumer_project_id" binding:"required"`
+}
+
+// OutboundExceptionRequest 添加项目出站例外请求
+type OutboundExceptionRequest struct {
+	Target string `json:"target" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ComplianceRequest 管理员导出或删除用户数据的请求：未提供确认令牌时只生成令牌，提交令牌后才开始执行
+type ComplianceRequest struct {
+	ConfirmationToken string `json:"confirmation_token"`
+}
+
+// CancelRunRequest 取消流水线运行请求，请求体可以为空
+type CancelRunRequest struct {
+	Reason string `json:"reason" binding:"max=500"` // 取消说明，记录在运行的取消原因中
+}
+
+// RunPipelineRequest 手动运行流水线请求，请求体可以为空
+type RunPipelineRequest struct {
+	DebugEnv      bool     `json:"debug_env"`      // 记录各步骤的环境变量值，仅项目所有者可用
+	Labels        []string `json:"labels"`         // 运行标签，项目配置了允许的标签时只能使用其中的标签
+	KeepWorkspace bool     `json:"keep_workspace"` // 运行结束后保留工作区，可通过工作区浏览接口查看生成的文件
+	Force         bool     `json:"force"`          // 流水线开启了没有变化时跳过，仍然执行
+
+	// registry 项目部署的制品，registry 项目必填
+	Artifact *ArtifactReference `json:"artifact"`
+}
+
+// ArtifactReference registry 项目触发运行的制品引用：镜像（image，如 repo:tag 或 repo@sha256:...），
+// 或 tarball 地址（url，同时提供 sha256 校验和），两者只能提供一个
+type ArtifactReference struct {
+	Image  string `json:"image,omitempty"`
+	URL    string `json:"url,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// RunLabelsRequest 为运行添加标签请求
+type RunLabelsRequest struct {
+	Labels []string `json:"labels" binding:"required"`
+}
+
+// RunLabelPolicyRequest 更新项目运行标签策略请求
+type RunLabelPolicyRequest struct {
+	AllowedLabels []string            `json:"allowed_labels"`
+	Retention     []RunLabelRetention `json:"retention"`
+}
+
+// ConcurrencyPolicyRequest 更新项目并发策略请求
+type ConcurrencyPolicyRequest struct {
+	MaxConcurrentRuns int      `json:"max_concurrent_runs"`
+	MutexGroups       []string `json:"mutex_groups"`
+}
+
+// LogQuotaRequest 设置项目运行日志存储配额请求（MB），0 表示使用全局配置，-1 表示不限制
+type LogQuotaRequest struct {
+	SoftQuotaMB int `json:"log_soft_quota_mb"`
+	HardCapMB   int `json:"log_hard_cap_mb"`
+}
+
+// CreateAccessRequest 申请加入项目请求，role 默认 viewer
+type CreateAccessRequest struct {
+	Role    string `json:"role"`
+	Message string `json:"message"`
+}
+
+// DecideAccessRequest 处理加入项目申请请求；批准时可以调整授予的角色
+type DecideAccessRequest struct {
+	Role string `json:"role"`
+	Note string `json:"note"`
+}
+
+// AccessAutoApproveRequest 设置加入项目申请的自动批准规则请求，domains 为空时关闭自动批准
+type AccessAutoApproveRequest struct {
+	Domains []string `json:"domains"`
+	Role    string   `json:"role"`
+}
+
+// CreateSharedResourceRequest 创建共享资源请求，内容保存为版本 1
+type CreateSharedResourceRequest struct {
+	Kind        string `json:"kind" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Content     string `json:"content" binding:"required"`
+}
+
+// UpdateSharedResourceRequest 修改共享资源请求，内容有变化时生成新版本
+type UpdateSharedResourceRequest struct {
+	Description *string `json:"description"`
+	Content     string  `json:"content" binding:"required"`
+}
+
+// CreateFreezeRequest 创建部署冻结请求
+type CreateFreezeRequest struct {
+	Scope       string     `json:"scope" binding:"required"` // global, environment, projects
+	Environment string     `json:"environment"`
+	ProjectIDs  []uint     `json:"project_ids"`
+	Reason      string     `json:"reason" binding:"required"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// LiftFreezeRequest 解除部署冻结请求
+type LiftFreezeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RedactionRuleRequest 创建或更新脱敏规则请求
+type RedactionRuleRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Pattern     string `json:"pattern" binding:"required"`
+	Replacement string `json:"replacement"`
+}
+
+// EnvironmentRequest 创建或更新项目环境变量请求，更新密钥变量时 value 为空表示保持原值
+type EnvironmentRequest struct {
+	Key         string `json:"key" binding:"required"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	IsSecret    bool   `json:"is_secret"`
+}
+
+// ExternalCallbackRequest 外部系统的回调结果，管理员手动完成外部等待时使用相同格式
+type ExternalCallbackRequest struct {
+	Status  string            `json:"status" binding:"required,oneof=success failure"`
+	Message string            `json:"message"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+// CreateAPITokenRequest 创建API令牌请求
+type CreateAPITokenRequest struct {
+	Name          string `json:"name" binding:"required"`
+	Scope         string `json:"scope"`           // 目前仅支持 admin
+	ExpiresInDays int    `json:"expires_in_days"` // 0 表示不过期
+	AllowedIPs    string `json:"allowed_ips"`     // 允许使用令牌的来源（逗号分隔的 IP 或 CIDR）
+}
+
+// UpdateAPITokenRequest 修改API令牌的来源限制
+type UpdateAPITokenRequest struct {
+	AllowedIPs string `json:"allowed_ips"`
+}
+
+// WebhookAccessRequest 修改Webhook的来源限制与签名要求
+type WebhookAccessRequest struct {
+	AllowedIPs       string `json:"allowed_ips"`
+	RequireSignature bool   `json:"require_signature"`
 }
 
 // DeployRequest 部署请求
@@ -352,6 +1981,9 @@ type PaginationResponse struct {
 	Page       int         `json:"page"`
 	PageSize   int         `json:"page_size"`
 	TotalPages int         `json:"total_pages"`
+
+	// 游标分页时下一页的游标，没有更多记录时为空
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // APIResponse 通用API响应
@@ -407,6 +2039,10 @@ func (Webhook) TableName() string {
 	return "webhooks"
 }
 
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
 func (SystemConfig) TableName() string {
 	return "system_configs"
 }
@@ -416,6 +2052,23 @@ func IsValidRole(role string) bool {
 	return role == RoleAdmin || role == RoleUser
 }
 
+// IsValidProjectRole 验证项目成员角色
+func IsValidProjectRole(role string) bool {
+	return role == ProjectRoleViewer || role == ProjectRoleMaintainer
+}
+
+// ProjectRoleRank 项目成员角色的权限高低，用于比较授予的角色
+func ProjectRoleRank(role string) int {
+	switch role {
+	case ProjectRoleMaintainer:
+		return 2
+	case ProjectRoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
 // IsValidStatus 验证用户状态
 func IsValidStatus(status string) bool {
 	return status == StatusActive || status == StatusInactive || status == StatusBlocked
@@ -440,7 +2093,243 @@ func IsValidDeployStatus(status string) bool {
 	return false
 }
 
+// ErrProjectArchived 项目已归档，拒绝运行和部署
+var ErrProjectArchived = errors.New("项目已归档")
+
+// IsArchived 项目是否已归档
+func (p *Project) IsArchived() bool {
+	return p.Status == ProjectStatusArchived
+}
+
+// ProjectHealth 项目健康状态：ok、warning 或 error，Reasons 说明非 ok 的原因
+type ProjectHealth struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// RepoHealth 按仓库状态计算项目健康状态：远程报告仓库已迁移时为 warning，
+// 连续 notFoundRuns 次运行报告仓库不存在时为 error；notFoundRuns 不大于 0 时不检查
+func (p *Project) RepoHealth(notFoundRuns int) *ProjectHealth {
+	health := &ProjectHealth{Status: HealthOK}
+	if p.RepoMovedTo != "" {
+		health.Status = HealthWarning
+		health.Reasons = append(health.Reasons, fmt.Sprintf("远程报告仓库已迁移到 %s", p.RepoMovedTo))
+	}
+	if notFoundRuns > 0 && p.RepoNotFoundRuns >= notFoundRuns {
+		health.Status = HealthError
+		health.Reasons = append(health.Reasons, fmt.Sprintf("最近连续 %d 次运行拉取代码时远程报告仓库不存在，请检查仓库地址与访问权限", p.RepoNotFoundRuns))
+	}
+	return health
+}
+
+// AddFailureSpike 将告警中的失败率突增告警计入健康状态，windowMinutes 为短窗口的分钟数
+func (h *ProjectHealth) AddFailureSpike(alert *FailureSpikeAlert, windowMinutes int) {
+	h.Status = HealthError
+	reason := "失败率突增: " + alert.Summary(windowMinutes)
+	if pipelines := alert.AffectedPipelines(); alert.PipelineID != nil && len(pipelines) > 0 {
+		reason = fmt.Sprintf("流水线 %s 失败率突增: %s", pipelines[0].Name, alert.Summary(windowMinutes))
+	}
+	h.Reasons = append(h.Reasons, reason)
+}
+
+// UsesArchiveSource 项目是否使用上传的源码包代替 git 仓库
+func (p *Project) UsesArchiveSource() bool {
+	return p.SourceType == ProjectSourceArchive
+}
+
+// UsesRegistrySource 项目是否只部署镜像仓库中预先构建的制品
+func (p *Project) UsesRegistrySource() bool {
+	return p.SourceType == ProjectSourceRegistry
+}
+
+// MutexGroupList 项目定义的互斥组
+func (p *Project) MutexGroupList() []string {
+	var groups []string
+	for _, group := range strings.Split(p.MutexGroups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// HasMutexGroup 项目是否定义了该互斥组
+func (p *Project) HasMutexGroup(name string) bool {
+	for _, group := range p.MutexGroupList() {
+		if group == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelNames 运行已加载的标签名称
+func (r *PipelineRun) LabelNames() []string {
+	names := make([]string, 0, len(r.Labels))
+	for _, label := range r.Labels {
+		names = append(names, label.Label)
+	}
+	return names
+}
+
+// AllowedRunLabelList 项目允许的运行标签，为空时不限制
+func (p *Project) AllowedRunLabelList() []string {
+	var labels []string
+	for _, label := range strings.Split(p.AllowedRunLabels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// IsDeployKey 是否为项目部署密钥
+func (k *SSHKey) IsDeployKey() bool {
+	return k.Purpose == SSHKeyPurposeDeployKey
+}
+
+// Enabled 是否配置了跳板机
+func (b BastionConfig) Enabled() bool {
+	return b.BastionHost != ""
+}
+
 // IsValidTriggerType 验证触发类型
 func IsValidTriggerType(trigger string) bool {
 	return trigger == TriggerManual || trigger == TriggerWebhook || trigger == TriggerSchedule
+}
+
+// IsValidIOClass 验证 IO 调度类，空值表示使用全局配置
+func IsValidIOClass(class string) bool {
+	return class == "" || class == IOClassNone || class == IOClassBestEffort || class == IOClassIdle
+}
+
+// IsValidConfigSource 验证配置来源，空值视为 stored
+func IsValidConfigSource(source string) bool {
+	return source == "" || source == ConfigSourceStored || source == ConfigSourceRepo
+}
+
+// ErrInvalidEnvName 环境变量名不符合 POSIX 命名，含空格或等号的名称会破坏传给脚本的环境
+var ErrInvalidEnvName = errors.New("环境变量名只能包含字母、数字和下划线，且不能以数字开头")
+
+// IsValidEnvName 验证环境变量名：字母、数字和下划线，不以数字开头
+func IsValidEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// BeforeSave 保存项目环境变量前校验变量名，不经过接口写入的变量同样被拒绝
+func (e *Environment) BeforeSave(tx *gorm.DB) error {
+	if !IsValidEnvName(e.Key) {
+		return fmt.Errorf("%w: %q", ErrInvalidEnvName, e.Key)
+	}
+	return nil
+}
+
+// Conclusion 已结束运行的结论，用于界面与提交状态展示：success、failure、cancelled、skipped（提交状态中显示为 neutral）；
+// 未结束时返回空
+func (r *PipelineRun) Conclusion() string {
+	switch r.Status {
+	case RunStatusSuccess:
+		return "success"
+	case RunStatusFailed:
+		return "failure"
+	case RunStatusCancelled:
+		return "cancelled"
+	case RunStatusSkipped:
+		return "skipped"
+	}
+	return ""
+}
+
+// CancellationSummary 取消原因及说明，用于日志与通知；没有取消原因时返回空
+func (r *PipelineRun) CancellationSummary() string {
+	if r.CancellationDetail == "" {
+		return r.CancellationReason
+	}
+	return r.CancellationReason + ": " + r.CancellationDetail
+}
+
+// UsesRepoConfig 流水线是否在运行时从仓库读取配置
+func (p *Pipeline) UsesRepoConfig() bool {
+	return p.ConfigSource == ConfigSourceRepo
+}
+
+// SkipPathList 没有变化时跳过所检查的路径规则
+func (p *Pipeline) SkipPathList() []string {
+	var paths []string
+	for _, item := range strings.Split(p.SkipPaths, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			paths = append(paths, item)
+		}
+	}
+	return paths
+}
+
+// EnvIgnoreList 检查环境变量引用时忽略的变量名
+func (p *Pipeline) EnvIgnoreList() []string {
+	var names []string
+	for _, item := range strings.Split(p.EnvIgnore, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			names = append(names, item)
+		}
+	}
+	return names
+}
+
+// IsActive 冻结在 now 时是否生效
+func (f *DeployFreeze) IsActive(now time.Time) bool {
+	return f.LiftedAt == nil && (f.ExpiresAt == nil || f.ExpiresAt.After(now))
+}
+
+// Covers 冻结范围是否包含该项目；environment 为部署步骤声明的环境标签，可为空
+func (f *DeployFreeze) Covers(projectID uint, environment string) bool {
+	switch f.Scope {
+	case FreezeScopeGlobal:
+		return true
+	case FreezeScopeEnvironment:
+		return environment != "" && strings.EqualFold(f.Environment, environment)
+	case FreezeScopeProjects:
+		for _, id := range strings.Split(f.ProjectIDs, ",") {
+			if id == strconv.FormatUint(uint64(projectID), 10) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUsable 令牌在 now 时是否可用
+func (t *APIToken) IsUsable(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || t.ExpiresAt.After(now))
+}
+
+// SummarizeTests 汇总运行各步骤的测试结果，没有测试报告时返回 nil
+func SummarizeTests(results []TestResult) *TestSummary {
+	if len(results) == 0 {
+		return nil
+	}
+
+	summary := &TestSummary{}
+	for _, r := range results {
+		summary.Total += r.Total
+		summary.Passed += r.Passed
+		summary.Failed += r.Failed
+		summary.Skipped += r.Skipped
+		summary.Duration += r.Duration
+		summary.DurationMs += r.DurationMs
+		for _, f := range r.Failures {
+			summary.Failures = append(summary.Failures, f.Name)
+		}
+	}
+	return summary
 }
\ No newline at end of file
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// privateKeySetting 查询携带该设置时才保留SSH私钥
+const privateKeySetting = "flowforge:with_private_key"
+
+// UserRef 嵌套在其他资源中的用户，只包含ID与用户名
+type UserRef struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// SSHKeyRef 嵌套在其他资源中的SSH密钥，只包含ID与名称
+type SSHKeyRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// NewUserRef 精简用户信息，未加载关联时返回 nil
+func NewUserRef(user *User) *UserRef {
+	if user == nil || user.ID == 0 {
+		return nil
+	}
+	return &UserRef{ID: user.ID, Username: user.Username}
+}
+
+// NewSSHKeyRef 精简SSH密钥信息，未加载关联时返回 nil
+func NewSSHKeyRef(key *SSHKey) *SSHKeyRef {
+	if key == nil || key.ID == 0 {
+		return nil
+	}
+	return &SSHKeyRef{ID: key.ID, Name: key.Name}
+}
+
+// WithPrivateKey 返回保留SSH私钥的查询，仅用于实际建立Git/SSH连接的代码路径
+func WithPrivateKey(db *gorm.DB) *gorm.DB {
+	return db.Set(privateKeySetting, true)
+}
+
+// AfterFind 未通过 WithPrivateKey 声明需要私钥的查询一律清空私钥，
+// 这样即使以后新增 Preload，私钥也不会随嵌套对象进入响应
+func (k *SSHKey) AfterFind(tx *gorm.DB) error {
+	if keep, ok := tx.Get(privateKeySetting); !ok || keep != true {
+		k.PrivateKey = ""
+	}
+	return nil
+}
+
+// 以下 MarshalJSON 将嵌套的用户与SSH密钥精简为引用，同一资源的完整信息只由其自身的接口返回
+
+// MarshalJSON 序列化SSH密钥，所属用户精简为引用
+func (k SSHKey) MarshalJSON() ([]byte, error) {
+	type sshKey SSHKey
+	return json.Marshal(struct {
+		sshKey
+		User *UserRef `json:"user,omitempty"`
+	}{sshKey(k), NewUserRef(&k.User)})
+}
+
+// MarshalJSON 序列化项目，所属用户与SSH密钥精简为引用
+func (p Project) MarshalJSON() ([]byte, error) {
+	type project Project
+	return json.Marshal(struct {
+		project
+		User      *UserRef   `json:"user,omitempty"`
+		SSHKey    *SSHKeyRef `json:"ssh_key,omitempty"`
+		DeployKey *SSHKeyRef `json:"deploy_key,omitempty"`
+	}{project(p), NewUserRef(&p.User), NewSSHKeyRef(p.SSHKey), NewSSHKeyRef(p.DeployKey)})
+}
+
+// MarshalJSON 序列化部署记录，部署用户精简为引用
+func (d Deployment) MarshalJSON() ([]byte, error) {
+	type deployment Deployment
+	return json.Marshal(struct {
+		deployment
+		User *UserRef `json:"user,omitempty"`
+	}{deployment(d), NewUserRef(&d.User)})
+}
+
+// MarshalJSON 序列化流水线运行，触发用户精简为引用
+func (r PipelineRun) MarshalJSON() ([]byte, error) {
+	type pipelineRun PipelineRun
+	return json.Marshal(struct {
+		pipelineRun
+		User *UserRef `json:"user,omitempty"`
+	}{pipelineRun(r), NewUserRef(&r.User)})
+}
@@ -0,0 +1,362 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/retry"
+)
+
+// Manager 通知管理器
+type Manager struct {
+	config *config.Config
+}
+
+// Message 待投递的通知内容
+type Message struct {
+	Title    string
+	Content  string
+	Link     string
+	Level    string
+	Category string
+}
+
+// NewManager 创建通知管理器
+func NewManager(cfg *config.Config) *Manager {
+	return &Manager{
+		config: cfg,
+	}
+}
+
+// NotifyRunFinished 向关注者投递流水线运行结果通知，recipients 为策略的通知规则匹配的其他用户
+func (m *Manager) NotifyRunFinished(runID uint, recipients []uint) {
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline").Preload("Labels").First(&run, runID).Error; err != nil {
+		log.Printf("获取流水线运行记录失败: %v", err)
+		return
+	}
+	pipeline := &run.Pipeline
+
+	msg := Message{
+		Title:    fmt.Sprintf("流水线 %s 运行 #%d %s", pipeline.Name, run.ID, run.Status),
+		Content:  fmt.Sprintf("流水线 %s 的运行 #%d 已结束，状态: %s", pipeline.Name, run.ID, run.Status),
+		Link:     fmt.Sprintf("%s/pipelines/%d/runs/%d", m.config.Notify.BaseURL, pipeline.ID, run.ID),
+		Level:    models.NotifyLevelNormal,
+		Category: models.NotifyCategoryRunFinished,
+	}
+	if run.Status == models.RunStatusFailed {
+		msg.Level = models.NotifyLevelUrgent
+	}
+	if summary := run.CancellationSummary(); summary != "" {
+		msg.Content += "\n取消原因: " + summary
+	}
+	if labels := run.LabelNames(); len(labels) > 0 {
+		msg.Title += " [" + strings.Join(labels, ", ") + "]"
+		msg.Content += "\n标签: " + strings.Join(labels, ", ")
+	}
+	if tests := runTestSummary(run.ID); tests != nil && tests.Failed > 0 {
+		msg.Content += fmt.Sprintf("\n%d 个测试失败: %s", tests.Failed, strings.Join(firstN(tests.Failures, maxNotifiedFailures), ", "))
+	}
+	// 测试名称等内容来自运行输出，与日志使用相同的脱敏规则
+	redactor := redact.ForProject(pipeline.ProjectID)
+	msg.Title = redactor.Redact(msg.Title)
+	msg.Content = redactor.Redact(msg.Content)
+
+	m.notifyWatchers(&run, msg, recipients...)
+}
+
+// NotifyPerformanceRegression 向关注者投递运行步骤耗时超过基线的通知
+func (m *Manager) NotifyPerformanceRegression(runID uint, findings []string) {
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline").First(&run, runID).Error; err != nil {
+		log.Printf("获取流水线运行记录失败: %v", err)
+		return
+	}
+	pipeline := &run.Pipeline
+
+	redactor := redact.ForProject(pipeline.ProjectID)
+	m.notifyWatchers(&run, Message{
+		Title:    redactor.Redact(fmt.Sprintf("流水线 %s 运行 #%d 步骤耗时超过基线", pipeline.Name, run.ID)),
+		Content:  redactor.Redact(strings.Join(findings, "\n")),
+		Link:     fmt.Sprintf("%s/pipelines/%d/runs/%d", m.config.Notify.BaseURL, pipeline.ID, run.ID),
+		Level:    models.NotifyLevelNormal,
+		Category: models.NotifyCategoryPerformanceRegression,
+	})
+}
+
+// NotifyRelease 向关注者投递发布记录，notes 为发布说明
+func (m *Manager) NotifyRelease(releaseID uint, notes string) {
+	var release models.Release
+	if err := database.DB.First(&release, releaseID).Error; err != nil {
+		log.Printf("获取发布记录失败: %v", err)
+		return
+	}
+	var run models.PipelineRun
+	if err := database.DB.Preload("Pipeline").First(&run, release.PipelineRunID).Error; err != nil {
+		log.Printf("获取流水线运行记录失败: %v", err)
+		return
+	}
+
+	redactor := redact.ForProject(release.ProjectID)
+	m.notifyWatchers(&run, Message{
+		Title:    redactor.Redact(fmt.Sprintf("流水线 %s 已发布 %s 到 %s", run.Pipeline.Name, release.Version, release.Environment)),
+		Content:  redactor.Redact(notes),
+		Link:     fmt.Sprintf("%s/projects/%d/releases", m.config.Notify.BaseURL, release.ProjectID),
+		Level:    models.NotifyLevelNormal,
+		Category: models.NotifyCategoryRelease,
+	})
+}
+
+// NotifyFailureSpike 向项目所有者与受影响流水线的关注者投递失败率突增告警或恢复通知，windowMinutes 为短窗口长度
+func (m *Manager) NotifyFailureSpike(alert *models.FailureSpikeAlert, project *models.Project, windowMinutes int) {
+	msg := Message{
+		Title:    fmt.Sprintf("项目 %s 失败率突增", project.Name),
+		Content:  alert.Summary(windowMinutes),
+		Link:     fmt.Sprintf("%s/projects/%d", m.config.Notify.BaseURL, alert.ProjectID),
+		Level:    models.NotifyLevelUrgent,
+		Category: models.NotifyCategoryFailureSpike,
+	}
+	pipelines := alert.AffectedPipelines()
+	if alert.PipelineID != nil && len(pipelines) > 0 {
+		msg.Title = fmt.Sprintf("项目 %s 的流水线 %s 失败率突增", project.Name, pipelines[0].Name)
+	}
+	if alert.ResolvedAt != nil {
+		msg.Title = strings.Replace(msg.Title, "失败率突增", "失败率已恢复", 1)
+		msg.Content = fmt.Sprintf("%s 触发的告警已于 %s 恢复\n%s", alert.TriggeredAt.Format("2006-01-02 15:04"),
+			alert.ResolvedAt.Format("2006-01-02 15:04"), msg.Content)
+		msg.Level = models.NotifyLevelNormal
+	}
+	if len(pipelines) > 0 {
+		lines := make([]string, 0, len(pipelines))
+		for _, p := range pipelines {
+			lines = append(lines, fmt.Sprintf("%s: %d/%d 次失败", p.Name, p.Failures, p.Runs))
+		}
+		msg.Content += "\n受影响的流水线:\n" + strings.Join(firstN(lines, maxNotifiedFailures), "\n")
+	}
+	redactor := redact.ForProject(alert.ProjectID)
+	msg.Title = redactor.Redact(msg.Title)
+	msg.Content = redactor.Redact(msg.Content)
+
+	ids := make([]uint, 0, len(pipelines))
+	for _, p := range pipelines {
+		ids = append(ids, p.PipelineID)
+	}
+	userIDs := []uint{project.UserID}
+	if len(ids) > 0 {
+		var watchers []uint
+		if err := database.DB.Model(&models.RunWatch{}).Where("pipeline_id IN ? AND pipeline_run_id IS NULL", ids).
+			Distinct().Pluck("user_id", &watchers).Error; err != nil {
+			log.Printf("查询流水线关注者失败: %v", err)
+		}
+		userIDs = append(userIDs, watchers...)
+	}
+
+	// 受影响的流水线都属于该项目，按项目判断查看权限
+	scope := &models.Pipeline{ProjectID: alert.ProjectID}
+	notified := make(map[uint]bool)
+	for _, userID := range userIDs {
+		if userID == 0 || notified[userID] {
+			continue
+		}
+		notified[userID] = true
+
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			continue
+		}
+		if !CanViewPipeline(&user, scope) {
+			continue
+		}
+		if err := m.Deliver(&user, msg); err != nil {
+			log.Printf("向用户 %d 投递通知失败: %v", user.ID, err)
+		}
+	}
+}
+
+// notifyWatchers 向关注该运行或其流水线、且有权查看的用户投递通知，每个用户只投递一次；extra 为关注者之外需要通知的用户
+func (m *Manager) notifyWatchers(run *models.PipelineRun, msg Message, extra ...uint) {
+	pipeline := &run.Pipeline
+
+	var watches []models.RunWatch
+	if err := database.DB.Where("pipeline_id = ? AND (pipeline_run_id IS NULL OR pipeline_run_id = ?)",
+		pipeline.ID, run.ID).Find(&watches).Error; err != nil {
+		log.Printf("查询运行关注者失败: %v", err)
+		return
+	}
+
+	userIDs := append([]uint(nil), extra...)
+	for _, watch := range watches {
+		userIDs = append(userIDs, watch.UserID)
+	}
+
+	notified := make(map[uint]bool)
+	for _, userID := range userIDs {
+		if notified[userID] {
+			continue
+		}
+		notified[userID] = true
+
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			continue
+		}
+
+		// 只通知有权查看该运行的用户
+		if !CanViewPipeline(&user, pipeline) {
+			continue
+		}
+
+		if err := m.Deliver(&user, msg); err != nil {
+			log.Printf("向用户 %d 投递通知失败: %v", user.ID, err)
+		}
+	}
+}
+
+// Deliver 按用户偏好的渠道投递通知
+func (m *Manager) Deliver(user *models.User, msg Message) error {
+	notification := models.Notification{
+		Title:    msg.Title,
+		Content:  msg.Content,
+		Link:     msg.Link,
+		Level:    msg.Level,
+		Category: msg.Category,
+		Channel:  user.NotifyChannel,
+		UserID:   user.ID,
+	}
+
+	switch user.NotifyChannel {
+	case models.NotifyChannelNone:
+		return nil
+	case models.NotifyChannelEmail:
+		// 非紧急通知在开启汇总时留到每日邮件中发送
+		if user.NotifyDigest && msg.Level != models.NotifyLevelUrgent {
+			notification.DigestPending = true
+		} else if err := m.SendEmail(user.Email, msg.Title, msg.Content+"\n\n"+msg.Link); err != nil {
+			return err
+		}
+	default:
+		notification.Channel = models.NotifyChannelInApp
+	}
+
+	return database.DB.Create(&notification).Error
+}
+
+// SendDigests 汇总待发送的非紧急通知，每个用户发送一封邮件
+func (m *Manager) SendDigests() {
+	var pending []models.Notification
+	if err := database.DB.Where("digest_pending = ?", true).Order("created_at ASC").Find(&pending).Error; err != nil {
+		log.Printf("查询待汇总通知失败: %v", err)
+		return
+	}
+
+	byUser := make(map[uint][]models.Notification)
+	for _, n := range pending {
+		byUser[n.UserID] = append(byUser[n.UserID], n)
+	}
+
+	for userID, notifications := range byUser {
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			continue
+		}
+
+		var body strings.Builder
+		ids := make([]uint, 0, len(notifications))
+		for _, n := range notifications {
+			fmt.Fprintf(&body, "[%s] %s\n%s\n\n", n.CreatedAt.Format("2006-01-02 15:04"), n.Title, n.Link)
+			ids = append(ids, n.ID)
+		}
+
+		subject := fmt.Sprintf("FlowForge 每日通知汇总（%d 条）", len(notifications))
+		if err := m.SendEmail(user.Email, subject, body.String()); err != nil {
+			log.Printf("向用户 %d 发送汇总邮件失败: %v", userID, err)
+			continue
+		}
+
+		database.DB.Model(&models.Notification{}).Where("id IN ?", ids).Update("digest_pending", false)
+	}
+}
+
+// SendEmail 通过SMTP发送邮件
+func (m *Manager) SendEmail(to, subject, body string) error {
+	cfg := m.config.Notify
+	if cfg.SMTPHost == "" {
+		return fmt.Errorf("未配置SMTP服务器")
+	}
+
+	msg := strings.Join([]string{
+		"From: " + cfg.From,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	// 服务器拒收（5xx）时不重试，连接失败与临时错误按默认策略重试，服务器持续故障时熔断
+	addr := fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort)
+	err := retry.Do(context.Background(), "smtp://"+addr, retry.Default(), func() error {
+		err := smtp.SendMail(addr, auth, cfg.From, []string{to}, []byte(msg))
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return retry.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+
+	return nil
+}
+
+// maxNotifiedFailures 通知中列出的失败用例数
+const maxNotifiedFailures = 5
+
+// runTestSummary 运行的测试报告汇总，没有测试报告时返回 nil
+func runTestSummary(runID uint) *models.TestSummary {
+	var results []models.TestResult
+	if err := database.DB.Preload("Failures").Where("pipeline_run_id = ?", runID).Find(&results).Error; err != nil {
+		return nil
+	}
+	return models.SummarizeTests(results)
+}
+
+// firstN 最多取前 n 项，超出时追加省略标记
+func firstN(items []string, n int) []string {
+	if len(items) <= n {
+		return items
+	}
+	return append(append([]string{}, items[:n]...), "...")
+}
+
+// CanViewPipeline 用户是否有权查看流水线（项目所有者或管理员）
+func CanViewPipeline(user *models.User, pipeline *models.Pipeline) bool {
+	if user.Role == models.RoleAdmin {
+		return true
+	}
+
+	var project models.Project
+	if err := database.DB.Select("user_id").First(&project, pipeline.ProjectID).Error; err != nil {
+		return false
+	}
+	return project.UserID == user.ID
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrArtifactSourceNotFound artifact_from 引用的运行不存在或没有成功
+	ErrArtifactSourceNotFound = errors.New("制品来源运行不存在或未成功")
+	// ErrArtifactNotShared 制品所属项目未授权当前项目使用
+	ErrArtifactNotShared = errors.New("制品所属项目未授权当前项目使用其制品")
+)
+
+// executeArtifactFrom 执行 artifact_from 步骤：从其他流水线的运行中取出指定制品放入工作区，不重新构建。
+// 来源为 run_id 指定的运行，或 pipeline_id 流水线在 branch 分支（默认为来源项目的分支）上最近一次成功的运行；
+// 复制时校验记录的 sha256，并记录制品来源
+func (e *Engine) executeArtifactFrom(jobCtx *JobContext, step *models.PipelineStep) error {
+	if e.artifacts == nil {
+		return fmt.Errorf("制品存储未初始化")
+	}
+	name, _ := step.Config["artifact"].(string)
+	if name == "" {
+		return fmt.Errorf("artifact_from 步骤需要配置制品名称 artifact")
+	}
+	path, _ := step.Config["path"].(string)
+	if path == "" {
+		path = name
+	}
+	if !filepath.IsLocal(path) {
+		return fmt.Errorf("制品路径必须位于工作区内: %s", path)
+	}
+
+	source, err := e.resolveArtifactSource(jobCtx, step)
+	if err != nil {
+		return err
+	}
+
+	var consumed models.Artifact
+	if err := jobCtx.db().Where("pipeline_run_id = ? AND name = ?", source.ID, name).
+		Order("id DESC").First(&consumed).Error; err != nil {
+		return fmt.Errorf("运行 #%d 没有名为 %s 的制品", source.RunNumber, name)
+	}
+
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	if err := e.artifacts.CopyTo(&consumed, filepath.Join(workDir, path)); err != nil {
+		return fmt.Errorf("取出制品 %s 失败: %w", name, err)
+	}
+
+	record := models.ArtifactConsumption{
+		PipelineRunID:      jobCtx.PipelineRun.ID,
+		PipelineStepID:     jobCtx.currentStep.ID,
+		ConsumedArtifactID: consumed.ID,
+		SourceRunID:        source.ID,
+		Path:               filepath.ToSlash(path),
+	}
+	if err := jobCtx.db().Create(&record).Error; err != nil {
+		return fmt.Errorf("记录制品来源失败: %w", err)
+	}
+
+	e.logf(jobCtx, "log.artifact_fetched", name, source.Pipeline.Name, source.RunNumber, consumed.Digest, path)
+	return nil
+}
+
+// resolveArtifactSource 解析 artifact_from 的来源运行并校验权限：来源流水线与当前流水线属于同一项目，
+// 或来源项目已授权当前项目使用其制品
+func (e *Engine) resolveArtifactSource(jobCtx *JobContext, step *models.PipelineStep) (*models.PipelineRun, error) {
+	var source models.PipelineRun
+	query := jobCtx.db().Preload("Pipeline").Where("status = ?", models.RunStatusSuccess)
+
+	runID, _ := step.Config["run_id"].(float64)
+	pipelineID, _ := step.Config["pipeline_id"].(float64)
+	switch {
+	case runID > 0:
+		if err := query.First(&source, uint(runID)).Error; err != nil ||
+			(pipelineID > 0 && source.PipelineID != uint(pipelineID)) {
+			return nil, fmt.Errorf("%w: #%d", ErrArtifactSourceNotFound, uint(runID))
+		}
+	case pipelineID > 0:
+		var pipeline models.Pipeline
+		if err := jobCtx.db().Preload("Project").First(&pipeline, uint(pipelineID)).Error; err != nil {
+			return nil, fmt.Errorf("%w: 流水线 %d 不存在", ErrArtifactSourceNotFound, uint(pipelineID))
+		}
+		branch, _ := step.Config["branch"].(string)
+		if branch == "" {
+			branch = pipeline.Project.Branch
+		}
+		if err := query.Where("pipeline_id = ? AND branch = ?", pipeline.ID, branch).
+			Order("end_time DESC, id DESC").First(&source).Error; err != nil {
+			return nil, fmt.Errorf("%w: 流水线 %s 在分支 %s 上没有成功的运行", ErrArtifactSourceNotFound, pipeline.Name, branch)
+		}
+	default:
+		return nil, fmt.Errorf("artifact_from 步骤需要配置 run_id 或 pipeline_id")
+	}
+
+	if err := checkArtifactShare(jobCtx.db(), source.Pipeline.ProjectID, jobCtx.Project.ID); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// checkArtifactShare 项目 consumerID 是否可以使用项目 projectID 的制品
+func checkArtifactShare(db *gorm.DB, projectID, consumerID uint) error {
+	if projectID == consumerID {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.ArtifactShare{}).
+		Where("project_id = ? AND consumer_project_id = ?", projectID, consumerID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询制品授权失败: %w", err)
+	}
+	if count == 0 {
+		return ErrArtifactNotShared
+	}
+	return nil
+}
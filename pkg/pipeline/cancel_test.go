@@ -0,0 +1,217 @@
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/ssh"
+)
+
+// cancelBound 取消后运行进入已取消状态的时长上限，远小于各阶段阻塞调用自身的超时
+const cancelBound = 5 * time.Second
+
+// cancelPhase 在运行的某个阶段取消：setup 创建流水线，reached 等待运行阻塞在该阶段，
+// steps 为取消后各步骤的状态，没有步骤记录时为空；queued 的运行取消时还没有开始执行
+type cancelPhase struct {
+	name   string
+	setup  func(t *testing.T, e *Engine, project *models.Project) (*models.Pipeline, func(t *testing.T, run *models.PipelineRun))
+	steps  []string
+	queued bool
+}
+
+// hangingRepo 接受请求后一直不响应的Git HTTP远程，直到客户端断开
+func hangingRepo(t *testing.T) (string, <-chan struct{}) {
+	t.Helper()
+	reached := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case reached <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL + "/web.git", reached
+}
+
+// hangingSSH 接受连接后一直不发送SSH版本信息的目标主机
+func hangingSSH(t *testing.T) (int, <-chan struct{}) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reached := make(chan struct{}, 1)
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			select {
+			case reached <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return listener.Addr().(*net.TCPAddr).Port, reached
+}
+
+func waitReached(t *testing.T, reached <-chan struct{}, phase string) {
+	t.Helper()
+	select {
+	case <-reached:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("运行没有进入%s", phase)
+	}
+}
+
+// afterStep 前一步骤被取消后不应执行的步骤，执行时在工作区留下 after.txt
+const afterStep = "      - name: after\n        type: script\n        config:\n          script: \"touch after.txt\"\n"
+
+var cancelPhases = []cancelPhase{
+	{
+		name: "排队时",
+		setup: func(t *testing.T, e *Engine, project *models.Project) (*models.Pipeline, func(*testing.T, *models.PipelineRun)) {
+			gates := t.TempDir()
+			e.config.Deploy.MaxConcurrent = 1
+			holder := startRun(t, e, createPipeline(t, project, "holder", scriptPipeline(waitGate(gates, "release"))))
+			waitStep(t, holder.ID, 1)
+			t.Cleanup(func() {
+				os.WriteFile(filepath.Join(gates, "release"), nil, 0o644)
+				waitRun(t, e, holder.ID)
+			})
+			return createPipeline(t, project, "queued", scriptPipeline("touch after.txt")), func(t *testing.T, run *models.PipelineRun) {
+				waitQueued(t, e, run.ID)
+			}
+		},
+		queued: true,
+	},
+	{
+		name: "拉取代码时",
+		setup: func(t *testing.T, e *Engine, project *models.Project) (*models.Pipeline, func(*testing.T, *models.PipelineRun)) {
+			repoURL, reached := hangingRepo(t)
+			project.RepoURL = repoURL
+			database.DB.Model(project).Update("repo_url", repoURL)
+			yaml := "stages:\n  - name: build\n    steps:\n      - name: checkout\n        type: git_clone\n        config: {}\n" + afterStep
+			return createPipeline(t, project, "checkout", yaml), func(t *testing.T, _ *models.PipelineRun) {
+				waitReached(t, reached, "拉取代码")
+			}
+		},
+		steps: []string{models.StepStatusFailed, models.StepStatusSkipped},
+	},
+	{
+		name: "执行步骤时",
+		setup: func(t *testing.T, e *Engine, project *models.Project) (*models.Pipeline, func(*testing.T, *models.PipelineRun)) {
+			yaml := scriptPipeline("sleep 30") + afterStep
+			return createPipeline(t, project, "script", yaml), func(t *testing.T, run *models.PipelineRun) {
+				waitStep(t, run.ID, 1)
+			}
+		},
+		steps: []string{models.StepStatusFailed, models.StepStatusSkipped},
+	},
+	{
+		name: "部署时",
+		setup: func(t *testing.T, e *Engine, project *models.Project) (*models.Pipeline, func(*testing.T, *models.PipelineRun)) {
+			port, reached := hangingSSH(t)
+			privateKey, publicKey, _, err := ssh.NewClient(e.config).GenerateEd25519KeyPair("deploy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := &models.SSHKey{Name: "deploy", PrivateKey: privateKey, PublicKey: publicKey, UserID: testUserID}
+			if err := database.DB.Create(key).Error; err != nil {
+				t.Fatal(err)
+			}
+			yaml := fmt.Sprintf("stages:\n  - name: deploy\n    steps:\n      - name: sync\n        type: deploy\n        config:\n"+
+				"          type: ssh\n          host: 127.0.0.1\n          port: %d\n          username: deploy\n          remote_dir: /srv/web\n          ssh_key_id: %d\n", port, key.ID)
+			return createPipeline(t, project, "deploy", yaml+afterStep), func(t *testing.T, _ *models.PipelineRun) {
+				waitReached(t, reached, "部署")
+			}
+		},
+		steps: []string{models.StepStatusFailed, models.StepStatusSkipped},
+	},
+}
+
+// TestCancelDuringPhase 在排队、拉取代码、执行步骤与部署时取消运行：阻塞的调用随运行上下文中断，
+// 运行在 cancelBound 内进入已取消状态并记录取消原因，执行中的步骤标记为失败、之后的步骤跳过且不执行，
+// 任务与协程都被释放，最终状态写入后运行与步骤记录不再变化
+func TestCancelDuringPhase(t *testing.T) {
+	for _, phase := range cancelPhases {
+		t.Run(phase.name, func(t *testing.T) {
+			e, project := setupEngineTest(t)
+			pipeline, reached := phase.setup(t, e, project)
+			goroutines := runtime.NumGoroutine()
+
+			run := startRun(t, e, pipeline)
+			reached(t, run)
+			cancelledAt := time.Now()
+			if err := e.CancelPipelineRun(run.ID, UserCancellation(testUserID, "")); err != nil {
+				t.Fatal(err)
+			}
+			finished := waitRun(t, e, run.ID)
+			if elapsed := time.Since(cancelledAt); elapsed > cancelBound {
+				t.Errorf("取消后 %v 运行才结束，应在 %v 内", elapsed, cancelBound)
+			}
+
+			if finished.Status != models.RunStatusCancelled || finished.CancellationReason != models.CancelReasonUser || finished.EndTime == nil {
+				t.Errorf("运行状态 %s（原因 %q，结束时间 %v），应为用户取消", finished.Status, finished.CancellationReason, finished.EndTime)
+			}
+			steps := runSteps(t, run.ID)
+			statuses := make([]string, len(steps))
+			for i, step := range steps {
+				statuses[i] = step.Status
+			}
+			if len(statuses) != len(phase.steps) || len(statuses) > 0 && !reflect.DeepEqual(statuses, phase.steps) {
+				t.Errorf("步骤状态为 %v，应为 %v", statuses, phase.steps)
+			}
+			if _, err := os.Stat(filepath.Join(e.workspaceDir(project.ID), "after.txt")); err == nil {
+				t.Error("取消后仍执行了之后的步骤")
+			}
+			for _, queued := range e.QueuedRuns() {
+				if queued.RunID == run.ID {
+					t.Error("取消的运行仍在等待队列中")
+				}
+			}
+			// 排队的运行没有占用名额，占着并发的是另一个运行
+			if phase.queued {
+				if e.isRunning(run.ID) {
+					t.Error("排队时取消的运行不应开始执行")
+				}
+			} else {
+				checkReleased(t, e, run.ID, goroutines)
+			}
+
+			time.Sleep(200 * time.Millisecond)
+			if again := waitRun(t, e, run.ID); !again.UpdatedAt.Equal(finished.UpdatedAt) || again.Status != finished.Status {
+				t.Errorf("最终状态写入后运行记录仍被修改: %s → %s", finished.UpdatedAt, again.UpdatedAt)
+			}
+			if again := runSteps(t, run.ID); !reflect.DeepEqual(again, steps) {
+				t.Error("最终状态写入后步骤记录仍被修改")
+			}
+		})
+	}
+}
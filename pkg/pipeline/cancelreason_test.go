@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// startBackdated 以提前 elapsed 开始计时的任务上下文执行流水线，用于在测试时间内到达以分钟计的运行超时
+func startBackdated(t *testing.T, e *Engine, pipeline *models.Pipeline, elapsed time.Duration) *models.PipelineRun {
+	t.Helper()
+	if err := database.DB.Preload("Project").First(pipeline, pipeline.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	run := &models.PipelineRun{PipelineID: pipeline.ID, RunNumber: 1, Status: models.RunStatusRunning, TriggerType: models.TriggerManual, UserID: testUserID, StartTime: &now, Branch: "main"}
+	if err := database.DB.Create(run).Error; err != nil {
+		t.Fatal(err)
+	}
+	jobCtx := newJobContext(pipeline, run, nil, "")
+	jobCtx.StartedAt = now.Add(-elapsed)
+	e.enqueueOrStart(jobCtx)
+	return run
+}
+
+// TestCancellationReason 每条取消路径记录对应的取消原因：用户取消记录说明与操作人，运行超时记为 timeout 且状态为失败，
+// 服务器关闭时排队的运行记为 shutdown，未经 CancelPipelineRun 取消的运行记为 unknown；最终日志行包含取消原因
+func TestCancellationReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel func(t *testing.T, e *Engine, project *models.Project) *models.PipelineRun
+		status string
+		reason string
+		detail string
+		actor  bool
+	}{
+		{
+			name: "执行中用户取消",
+			cancel: func(t *testing.T, e *Engine, project *models.Project) *models.PipelineRun {
+				run := startRun(t, e, createPipeline(t, project, "hanging", scriptPipeline("sleep 30")))
+				waitStep(t, run.ID, 1)
+				if err := e.CancelPipelineRun(run.ID, UserCancellation(testUserID, "  发布窗口变更 ")); err != nil {
+					t.Fatal(err)
+				}
+				return run
+			},
+			status: models.RunStatusCancelled,
+			reason: models.CancelReasonUser,
+			detail: "发布窗口变更",
+			actor:  true,
+		},
+		{
+			name: "排队时用户取消",
+			cancel: func(t *testing.T, e *Engine, project *models.Project) *models.PipelineRun {
+				holdConcurrency(t, e, project)
+				run := startRun(t, e, createPipeline(t, project, "queued", scriptPipeline("true")))
+				waitQueued(t, e, run.ID)
+				if err := e.CancelPipelineRun(run.ID, UserCancellation(testUserID, "")); err != nil {
+					t.Fatal(err)
+				}
+				return run
+			},
+			status: models.RunStatusCancelled,
+			reason: models.CancelReasonUser,
+			actor:  true,
+		},
+		{
+			name: "超过运行超时",
+			cancel: func(t *testing.T, e *Engine, project *models.Project) *models.PipelineRun {
+				if err := database.DB.Create(&models.ProjectPolicy{ProjectID: project.ID, RunTimeoutMinutes: 1}).Error; err != nil {
+					t.Fatal(err)
+				}
+				pipeline := createPipeline(t, project, "slow", scriptPipeline("sleep 30"))
+				return startBackdated(t, e, pipeline, time.Minute-500*time.Millisecond)
+			},
+			status: models.RunStatusFailed,
+			reason: models.CancelReasonTimeout,
+		},
+		{
+			name: "服务器关闭时排队",
+			cancel: func(t *testing.T, e *Engine, project *models.Project) *models.PipelineRun {
+				holdConcurrency(t, e, project)
+				run := startRun(t, e, createPipeline(t, project, "queued", scriptPipeline("true")))
+				waitQueued(t, e, run.ID)
+				e.Shutdown()
+				return run
+			},
+			status: models.RunStatusCancelled,
+			reason: models.CancelReasonShutdown,
+		},
+		{
+			name: "直接中止任务",
+			cancel: func(t *testing.T, e *Engine, project *models.Project) *models.PipelineRun {
+				run := startRun(t, e, createPipeline(t, project, "hanging", scriptPipeline("sleep 30")))
+				waitStep(t, run.ID, 1)
+				e.mu.RLock()
+				jobCtx := e.runningJobs[run.ID]
+				e.mu.RUnlock()
+				jobCtx.Cancel()
+				return run
+			},
+			status: models.RunStatusCancelled,
+			reason: models.CancelReasonUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, project := setupEngineTest(t)
+			run := waitRun(t, e, tt.cancel(t, e, project).ID)
+
+			if run.Status != tt.status || run.CancellationReason != tt.reason || run.CancellationDetail != tt.detail {
+				t.Errorf("运行状态 %s（原因 %q，说明 %q），应为 %s（原因 %q，说明 %q）",
+					run.Status, run.CancellationReason, run.CancellationDetail, tt.status, tt.reason, tt.detail)
+			}
+			if tt.actor != (run.CancelledByID != nil) || tt.actor && *run.CancelledByID != testUserID {
+				t.Errorf("操作人为 %v，用户取消时应为用户 %d，其他原因应为空", run.CancelledByID, testUserID)
+			}
+			lines := strings.Split(strings.TrimSpace(run.LogOutput), "\n")
+			if last := lines[len(lines)-1]; !strings.Contains(last, run.CancellationSummary()) {
+				t.Errorf("最后一行日志为 %q，应包含取消原因 %q", last, run.CancellationSummary())
+			}
+		})
+	}
+}
+
+// holdConcurrency 全局并发数为 1 并由另一个运行占用，之后触发的运行进入等待队列；测试结束时放行
+func holdConcurrency(t *testing.T, e *Engine, project *models.Project) {
+	t.Helper()
+	gates := t.TempDir()
+	e.config.Deploy.MaxConcurrent = 1
+	holder := startRun(t, e, createPipeline(t, project, "holder", scriptPipeline(waitGate(gates, "release"))))
+	waitStep(t, holder.ID, 1)
+	t.Cleanup(func() {
+		os.WriteFile(filepath.Join(gates, "release"), nil, 0o644)
+		waitRun(t, e, holder.ID)
+	})
+}
+
+// TestSuccessfulRunHasNoReason 成功的运行不记录取消原因
+func TestSuccessfulRunHasNoReason(t *testing.T) {
+	e, project := setupEngineTest(t)
+	run := waitRun(t, e, startRun(t, e, createPipeline(t, project, "build", scriptPipeline("true"))).ID)
+	if run.Status != models.RunStatusSuccess || run.CancellationReason != "" || run.CancelledByID != nil {
+		t.Errorf("运行状态 %s（原因 %q，操作人 %v），成功的运行不应有取消原因", run.Status, run.CancellationReason, run.CancelledByID)
+	}
+}
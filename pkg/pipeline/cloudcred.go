@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"flowforge/pkg/cloudcred"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+)
+
+// cloudCredentialEnv 部署步骤的云平台临时凭证：项目为该环境配置了角色时签发，返回注入步骤的环境变量。
+// 临时凭证只保存在内存中，并加入本次运行的日志屏蔽；签发失败时部署步骤失败，运行按凭证问题分类
+func (e *Engine) cloudCredentialEnv(jobCtx *JobContext, environment string) (map[string]string, error) {
+	credential, err := cloudcred.Lookup(jobCtx.db(), jobCtx.Project.ID, environment)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, nil
+	}
+
+	// 有效期不超过运行超时的剩余时间，超时中止的运行不会留下仍然有效的凭证
+	var duration time.Duration
+	if jobCtx.policy != nil && jobCtx.policy.RunTimeoutMinutes > 0 {
+		duration = time.Until(jobCtx.StartedAt.Add(time.Duration(jobCtx.policy.RunTimeoutMinutes) * time.Minute))
+	}
+
+	issued, err := cloudcred.Issue(jobCtx.stepContext(), credential, cloudcred.Session{
+		RunID:      jobCtx.PipelineRun.ID,
+		ProjectID:  jobCtx.Project.ID,
+		PipelineID: jobCtx.Pipeline.ID,
+		Duration:   duration,
+	})
+	if err != nil {
+		jobCtx.PipelineRun.FailureKind = models.FailureKindCloudCredential
+		return nil, fmt.Errorf("扮演角色 %s 失败（%s）: %w", credential.RoleARN, cloudcred.KindOf(err), err)
+	}
+
+	jobCtx.maskSecrets(issued.Secrets...)
+	e.logf(jobCtx, "log.cloud_credential_issued", credential.RoleARN, issued.Expiration.Local().Format("2006-01-02 15:04:05"))
+	return issued.Env, nil
+}
+
+// maskSecrets 加入只在本次运行有效的密钥值，之后写入的日志与错误信息中屏蔽这些值
+func (j *JobContext) maskSecrets(values ...string) {
+	j.secretsMu.Lock()
+	defer j.secretsMu.Unlock()
+
+	j.runSecrets = append(j.runSecrets, values...)
+	j.runRedactor = redact.New(j.runSecrets, nil)
+}
+
+// redactText 按项目的密钥与脱敏规则以及本次运行的密钥值脱敏文本
+func (j *JobContext) redactText(text string) string {
+	text = redact.ForProject(j.Project.ID).Redact(text)
+
+	j.secretsMu.Lock()
+	runRedactor := j.runRedactor
+	j.secretsMu.Unlock()
+	return runRedactor.Redact(text)
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"flowforge/pkg/models"
+)
+
+// 排队运行被阻塞的原因
+const (
+	BlockedByGlobalLimit  = "global_limit"  // 全局并发运行数已满
+	BlockedByProjectLimit = "project_limit" // 项目并发运行数已达上限
+	BlockedByMutexGroup   = "mutex_group"   // 同一互斥组有运行正在执行
+	BlockedByWorkspace    = "workspace"     // 项目工作区被其他运行占用，从保留工作区恢复的运行需要独占
+)
+
+// concurrencyPolicy 运行开始前读取的项目并发策略
+type concurrencyPolicy struct {
+	maxRuns    int    // 项目同时执行的运行数上限，0 表示不限
+	mutexGroup string // 流水线加入的互斥组
+}
+
+// queueBlock 排队运行当前被阻塞的原因
+type queueBlock struct {
+	reason string
+	limit  int    // global_limit、project_limit 时的上限
+	group  string // mutex_group 时的互斥组
+	runID  uint   // mutex_group、workspace 时占用互斥组或工作区的运行
+}
+
+// loadConcurrencyPolicy 从数据库读取项目与流水线当前的并发策略，修改策略后对之后出队的运行立即生效；
+// 读取失败时使用运行创建时加载的配置
+func loadConcurrencyPolicy(jobCtx *JobContext) concurrencyPolicy {
+	policy := concurrencyPolicy{
+		maxRuns:    jobCtx.Project.MaxConcurrentRuns,
+		mutexGroup: jobCtx.Pipeline.MutexGroup,
+	}
+
+	var project models.Project
+	if err := jobCtx.db().Select("id", "max_concurrent_runs").First(&project, jobCtx.Project.ID).Error; err == nil {
+		policy.maxRuns = project.MaxConcurrentRuns
+	}
+	var pipeline models.Pipeline
+	if err := jobCtx.db().Select("id", "mutex_group").First(&pipeline, jobCtx.Pipeline.ID).Error; err == nil {
+		policy.mutexGroup = pipeline.MutexGroup
+	}
+	return policy
+}
+
+// blockedLocked 按全局并发上限与项目并发策略判断运行能否开始，可以开始时返回 nil；调用方持有 e.mu
+func (e *Engine) blockedLocked(jobCtx *JobContext, policy concurrencyPolicy) *queueBlock {
+	if limit := e.maxConcurrent(); len(e.runningJobs) >= limit {
+		return &queueBlock{reason: BlockedByGlobalLimit, limit: limit}
+	}
+
+	projectRuns := 0
+	for runID, running := range e.runningJobs {
+		if running.Project.ID != jobCtx.Project.ID {
+			continue
+		}
+		if policy.mutexGroup != "" && running.mutexGroup == policy.mutexGroup {
+			return &queueBlock{reason: BlockedByMutexGroup, group: policy.mutexGroup, runID: runID}
+		}
+		// 恢复保留的工作区会替换整个项目工作区，与同项目的其他运行互斥
+		if jobCtx.RestoreFrom != "" || running.RestoreFrom != "" {
+			return &queueBlock{reason: BlockedByWorkspace, runID: runID}
+		}
+		projectRuns++
+	}
+	if policy.maxRuns > 0 && projectRuns >= policy.maxRuns {
+		return &queueBlock{reason: BlockedByProjectLimit, limit: policy.maxRuns}
+	}
+	return nil
+}
+
+// ApplyConcurrencyPolicy 项目并发策略变化后重新检查等待队列，放宽限制时立即启动可以执行的运行
+func (e *Engine) ApplyConcurrencyPolicy() {
+	e.dispatchQueued()
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// waitGate 等待测试创建 name 文件的脚本
+func waitGate(dir, name string) string {
+	return "while [ ! -f " + filepath.Join(dir, name) + " ]; do sleep 0.05; done"
+}
+
+// openGate 放行等待 name 文件的脚本
+func openGate(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitQueued 等待运行进入等待队列，返回阻塞它的原因
+func waitQueued(t *testing.T, e *Engine, runID uint) QueuedRun {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, queued := range e.QueuedRuns() {
+			if queued.RunID == runID {
+				return queued
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("运行 %d 没有进入等待队列", runID)
+	return QueuedRun{}
+}
+
+// TestRestoreWorkspaceWaitsForProjectRuns 重跑失败步骤的运行在同项目其他运行结束前排队，不清空它们正在使用的工作区；
+// 恢复的运行执行期间，同项目新触发的运行同样排队
+func TestRestoreWorkspaceWaitsForProjectRuns(t *testing.T) {
+	e, project := setupEngineTest(t)
+	gates := t.TempDir()
+
+	// 第一次运行在第二步失败，保留写有 marker.txt 的工作区
+	flaky := createPipeline(t, project, "flaky", scriptPipeline(
+		"echo restored > marker.txt",
+		"test -f "+filepath.Join(gates, "fixed")+" || exit 1; "+waitGate(gates, "release-rerun")+"; cat marker.txt",
+	))
+	failed := waitRun(t, e, startRun(t, e, flaky).ID)
+	if failed.Status != models.RunStatusFailed || failed.WorkspacePath == "" {
+		t.Fatalf("第一次运行应失败并保留工作区，实际为 %s（%q）", failed.Status, failed.WorkspacePath)
+	}
+
+	long := createPipeline(t, project, "long", scriptPipeline(
+		"echo long > long.txt; "+waitGate(gates, "release-long")+"; test -f long.txt; test ! -f marker.txt",
+	))
+	os.Remove(filepath.Join(e.workspaceDir(project.ID), "marker.txt"))
+	holder := startRun(t, e, long)
+	waitStep(t, holder.ID, 1)
+
+	openGate(t, gates, "fixed")
+	rerun, err := e.RerunFailed(failed.ID, testUserID)
+	if err != nil {
+		t.Fatalf("重跑失败步骤失败: %v", err)
+	}
+	queued := waitQueued(t, e, rerun.ID)
+	if queued.BlockedBy != BlockedByWorkspace || queued.BlockingRunID != holder.ID {
+		t.Fatalf("重跑应等待运行 %d 占用的工作区，实际被 %s（运行 %d）阻塞", holder.ID, queued.BlockedBy, queued.BlockingRunID)
+	}
+
+	// 占用工作区的运行结束前，工作区没有被恢复的内容替换：long.txt 仍在，marker.txt 没有出现
+	openGate(t, gates, "release-long")
+	if run := waitRun(t, e, holder.ID); run.Status != models.RunStatusSuccess {
+		t.Fatalf("占用工作区的运行应成功，实际为 %s: %s", run.Status, run.ErrorMsg)
+	}
+
+	// 恢复的运行开始执行后占用工作区，同项目新触发的运行排队
+	waitStep(t, rerun.ID, 2)
+	next := startRun(t, e, long)
+	if queued := waitQueued(t, e, next.ID); queued.BlockedBy != BlockedByWorkspace || queued.BlockingRunID != rerun.ID {
+		t.Fatalf("新运行应等待重跑占用的工作区，实际被 %s（运行 %d）阻塞", queued.BlockedBy, queued.BlockingRunID)
+	}
+
+	openGate(t, gates, "release-rerun")
+	if run := waitRun(t, e, rerun.ID); run.Status != models.RunStatusSuccess {
+		t.Fatalf("重跑应从恢复的工作区成功执行，实际为 %s: %s", run.Status, run.ErrorMsg)
+	}
+	steps := runSteps(t, rerun.ID)
+	if steps[0].Status != models.StepStatusReused || steps[1].Status != models.StepStatusSuccess {
+		t.Errorf("重跑应复用第一步并重新执行第二步，实际为 %s、%s", steps[0].Status, steps[1].Status)
+	}
+
+	waitRun(t, e, next.ID)
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidConfig 流水线配置无法解析或未通过校验
+var ErrInvalidConfig = errors.New("流水线配置无效")
+
+// yamlErrorLine YAML 错误信息中的行号
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// ConfigError 流水线配置的全部问题，errors.Is(err, ErrInvalidConfig) 为 true
+type ConfigError struct {
+	Problems []models.ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Message
+	}
+	return ErrInvalidConfig.Error() + ": " + strings.Join(messages, "；")
+}
+
+func (e *ConfigError) Unwrap() error {
+	return ErrInvalidConfig
+}
+
+// configError 由单个问题构成的配置错误
+func configError(kind, message string, line int) *ConfigError {
+	return &ConfigError{Problems: []models.ConfigProblem{{Kind: kind, Line: line, Message: message}}}
+}
+
+// ParseConfig 解析流水线配置。配置为 YAML，以 { 开头时按 JSON 解析，兼容早期保存的 JSON 配置；
+// 两种格式解析后的字段类型一致（数字均为 float64）。格式或结构错误时返回 *ConfigError，不校验步骤
+func ParseConfig(raw string) (*models.PipelineConfig, error) {
+	data := []byte(raw)
+	if !strings.HasPrefix(strings.TrimSpace(raw), "{") {
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var config models.PipelineConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return nil, configError(models.ConfigProblemSyntax, "JSON 格式错误: "+syntaxErr.Error(), lineAt(raw, int(syntaxErr.Offset)))
+		case errors.As(err, &typeErr) && typeErr.Field == "":
+			return nil, configError(models.ConfigProblemStructure, "配置应为包含 stages 的对象", 0)
+		case errors.As(err, &typeErr):
+			return nil, configError(models.ConfigProblemStructure, fmt.Sprintf("字段 %s 的值不能是 %s", typeErr.Field, typeErr.Value), 0)
+		}
+		return nil, configError(models.ConfigProblemStructure, "配置结构错误: "+err.Error(), 0)
+	}
+	return &config, nil
+}
+
+// yamlToJSON 将 YAML 转换为 JSON，再按 JSON 解析为配置结构，使 YAML 与 JSON 配置得到相同的类型
+func yamlToJSON(content []byte) ([]byte, error) {
+	var tree interface{}
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		message := err.Error()
+		line := 0
+		if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+			line, _ = strconv.Atoi(match[1])
+			message = strings.TrimPrefix(message, match[0])
+		} else {
+			message = strings.TrimPrefix(message, "yaml: ")
+		}
+		return nil, configError(models.ConfigProblemSyntax, "YAML 格式错误: "+message, line)
+	}
+	if tree == nil {
+		return []byte("{}"), nil
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		// 非字符串的键（如 1: 或 true:）无法对应到配置字段
+		return nil, configError(models.ConfigProblemStructure, "配置中的键必须是字符串", 0)
+	}
+	return data, nil
+}
+
+// CheckConfig 校验配置结构：至少一个阶段（引用模板时可以没有），阶段与步骤都有名称，每个阶段至少一个步骤，
+// 步骤类型已注册且配置符合执行器的要求，超时时间为有效的时长，返回全部问题
+func CheckConfig(config *models.PipelineConfig) []models.ConfigProblem {
+	// 引用模板时阶段可以全部来自模板
+	if len(config.Stages) == 0 && config.Template == "" {
+		return []models.ConfigProblem{{Kind: models.ConfigProblemNoStages, Message: "至少需要一个阶段"}}
+	}
+
+	var problems []models.ConfigProblem
+	for i := range config.Stages {
+		stage := &config.Stages[i]
+		stageName := stage.Name
+		if stageName == "" {
+			problems = append(problems, models.ConfigProblem{Kind: models.ConfigProblemMissingName, Stage: i + 1,
+				Message: fmt.Sprintf("第 %d 个阶段缺少名称", i+1)})
+			stageName = fmt.Sprintf("#%d", i+1)
+		}
+		if len(stage.Steps) == 0 {
+			problems = append(problems, models.ConfigProblem{Kind: models.ConfigProblemEmptyStage, Stage: i + 1,
+				Message: fmt.Sprintf("阶段 %s 没有步骤", stageName)})
+		}
+		if _, err := parseTimeout(stage.Timeout); err != nil {
+			problems = append(problems, models.ConfigProblem{Kind: models.ConfigProblemInvalidTimeout, Stage: i + 1,
+				Message: fmt.Sprintf("阶段 %s 的%s", stageName, err)})
+		}
+
+		for j := range stage.Steps {
+			step := &stage.Steps[j]
+			add := func(kind, message string) {
+				problems = append(problems, models.ConfigProblem{Kind: kind, Stage: i + 1, Step: j + 1, Message: message})
+			}
+			if step.Name == "" {
+				add(models.ConfigProblemMissingName, fmt.Sprintf("阶段 %s 的第 %d 个步骤缺少名称", stageName, j+1))
+			}
+			if _, err := parseTimeout(step.Timeout); err != nil {
+				add(models.ConfigProblemInvalidTimeout, fmt.Sprintf("阶段 %s 的第 %d 个步骤的%s", stageName, j+1, err))
+			}
+			if step.Type == "" {
+				add(models.ConfigProblemMissingType, fmt.Sprintf("阶段 %s 的第 %d 个步骤缺少类型", stageName, j+1))
+				continue
+			}
+			if _, ok := LookupStepType(step.Type); !ok {
+				add(models.ConfigProblemUnknownStepType, fmt.Sprintf("阶段 %s 的第 %d 个步骤的类型 %q 不受支持", stageName, j+1, step.Type))
+				continue
+			}
+			for _, message := range validateStepConfig(step) {
+				add(models.ConfigProblemInvalidStep, message)
+			}
+		}
+	}
+	return problems
+}
+
+// LoadConfig 解析并校验保存的流水线配置，未通过时返回 *ConfigError
+func LoadConfig(raw string) (*models.PipelineConfig, error) {
+	config, err := ParseConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+	if problems := CheckConfig(config); len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return config, nil
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// timeoutConfig 阶段与步骤声明超时时间的配置
+func timeoutConfig(stage, step string) string {
+	return `stages:
+  - name: build
+    timeout: ` + stage + `
+    steps:
+      - name: test
+        type: script
+        timeout: ` + step + `
+        config:
+          script: go test ./...
+`
+}
+
+func TestConfigTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		stage, step string
+		want        []time.Duration // 阶段与步骤解析后的超时时间
+	}{
+		{"时长", "10m", "1h30m", []time.Duration{10 * time.Minute, 90 * time.Minute}},
+		{"秒数", "600", "90", []time.Duration{600 * time.Second, 90 * time.Second}},
+		{"带引号的秒数", `"600"`, `"10m"`, []time.Duration{600 * time.Second, 10 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadConfig(timeoutConfig(tt.stage, tt.step))
+			if err != nil {
+				t.Fatalf("配置应有效，实际为 %v", err)
+			}
+			stage := &config.Stages[0]
+			for i, value := range []models.Timeout{stage.Timeout, stage.Steps[0].Timeout} {
+				got, err := parseTimeout(value)
+				if err != nil || got != tt.want[i] {
+					t.Errorf("超时时间 %q 解析为 %v（%v），应为 %v", value, got, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConfigInvalidTimeout(t *testing.T) {
+	for _, value := range []string{"0", "-30", "1.5", "ten", "true", "[10m]"} {
+		t.Run(value, func(t *testing.T) {
+			config, err := ParseConfig(timeoutConfig(value, value))
+			if err != nil {
+				t.Fatalf("超时时间的类型不应导致结构错误，实际为 %v", err)
+			}
+			problems := CheckConfig(config)
+			if len(problems) != 2 {
+				t.Fatalf("阶段与步骤应各有一个问题，实际为 %+v", problems)
+			}
+			for _, problem := range problems {
+				if problem.Kind != models.ConfigProblemInvalidTimeout {
+					t.Errorf("问题类型 %s，应为 %s", problem.Kind, models.ConfigProblemInvalidTimeout)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/scripts"
+	"flowforge/pkg/utils"
+)
+
+var (
+	// ErrDebugUnavailable 运行没有可用的调试终端
+	ErrDebugUnavailable = errors.New("调试终端不可用：流水线未开启失败调试、已超过调试时限或工作区已清理")
+	// ErrDebugSessionActive 同一运行同时只允许一个调试终端
+	ErrDebugSessionActive = errors.New("该运行已有打开的调试终端")
+	// ErrDebugSandboxDisabled 未开启多租户隔离时终端会以服务用户运行，能读取其他项目与服务自身的文件
+	ErrDebugSandboxDisabled = errors.New("未开启多租户隔离，不允许打开调试终端")
+)
+
+// debugLinePrefix 调试终端的输出记录到运行日志时的前缀
+const debugLinePrefix = "[debug] "
+
+// maxDebugLine 没有换行的输出超过该长度时先记录一行
+const maxDebugLine = 4096
+
+// terminalSequence 终端控制序列（CSI、OSC 与单字符转义），记录到运行日志前去除
+var terminalSequence = regexp.MustCompile(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// debugState 本实例打开的调试终端，每个运行同时只允许一个
+type debugState struct {
+	mu       sync.Mutex
+	sessions map[uint]*DebugSession
+}
+
+// claim 登记运行的调试终端，已有打开的终端时返回 false
+func (d *debugState) claim(session *DebugSession) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.sessions == nil {
+		d.sessions = make(map[uint]*DebugSession)
+	}
+	if _, ok := d.sessions[session.RunID]; ok {
+		return false
+	}
+	d.sessions[session.RunID] = session
+	return true
+}
+
+// release 终端结束后释放运行的名额
+func (d *debugState) release(session *DebugSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.sessions[session.RunID] == session {
+		delete(d.sessions, session.RunID)
+	}
+}
+
+// DebugSession 失败运行保留工作区中的交互式终端：读取得到终端输出，写入作为键盘输入。
+// 输出去除控制序列并脱敏后记录到运行日志，输入不记录；到达调试时限或关闭时结束终端中的全部进程
+type DebugSession struct {
+	RunID    uint
+	Deadline time.Time
+
+	engine   *Engine
+	cmd      *exec.Cmd
+	tty      *os.File
+	locale   string
+	redactor *redact.Redactor
+
+	lineMu sync.Mutex
+	line   []byte // 尚未换行的输出
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// DebugAvailable 运行是否可以打开调试终端：流水线开启了失败调试的失败运行，在调试时限内且保留的工作区仍在本机
+func (e *Engine) DebugAvailable(run *models.PipelineRun) bool {
+	if run.Status != models.RunStatusFailed || run.DebugUntil == nil || !time.Now().Before(*run.DebugUntil) {
+		return false
+	}
+	return run.WorkspacePath != "" && utils.IsDirExists(run.WorkspacePath)
+}
+
+// DebugSessionOpen 本实例中运行是否已有打开的调试终端
+func (e *Engine) DebugSessionOpen(runID uint) bool {
+	e.debug.mu.Lock()
+	defer e.debug.mu.Unlock()
+	_, ok := e.debug.sessions[runID]
+	return ok
+}
+
+// holdForDebug 开启失败调试的运行失败时计算调试时限并记录到运行日志，调试时限内清理任务不处理该运行；
+// 未开启多租户隔离或在独立执行器上执行时不提供调试终端，返回 nil
+func (e *Engine) holdForDebug(jobCtx *JobContext, endTime time.Time) *time.Time {
+	defer e.logWriter.Flush(jobCtx.PipelineRun.ID)
+
+	switch {
+	case e.worker != nil:
+		e.logf(jobCtx, "log.debug_remote")
+	case !isolation.Enabled():
+		e.logf(jobCtx, "log.debug_no_sandbox")
+	default:
+		until := endTime.Add(time.Duration(e.config.Deploy.DebugSessionMinutes) * time.Minute)
+		e.logf(jobCtx, "log.debug_available", until.Format("2006-01-02 15:04:05"))
+		return &until
+	}
+	return nil
+}
+
+// StartDebugSession 以项目系统用户在运行保留的工作区中启动交互式 shell，operator 为打开终端的用户名，记录到运行日志。
+// 终端中对工作区的修改会保留，之后仅重跑失败步骤时使用修改后的工作区
+func (e *Engine) StartDebugSession(run *models.PipelineRun, project *models.Project, operator string, cols, rows uint16) (*DebugSession, error) {
+	if !isolation.Enabled() {
+		return nil, ErrDebugSandboxDisabled
+	}
+	if !e.DebugAvailable(run) {
+		return nil, ErrDebugUnavailable
+	}
+
+	shell, err := e.scriptManager.ShellPath(scripts.ShellBash)
+	if err != nil {
+		if shell, err = e.scriptManager.ShellPath(scripts.ShellSh); err != nil {
+			return nil, err
+		}
+	}
+	account, err := isolation.ProjectAccount(database.DB, e.config.App.DataPath, project)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &DebugSession{
+		RunID:    run.ID,
+		Deadline: *run.DebugUntil,
+		engine:   e,
+		locale:   runLocale(run.UserID),
+		redactor: redact.ForProject(project.ID),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if !e.debug.claim(session) {
+		return nil, ErrDebugSessionActive
+	}
+	if err := session.start(shell, account, run.WorkspacePath, cols, rows); err != nil {
+		e.debug.release(session)
+		return nil, err
+	}
+
+	session.logf("log.debug_started", operator, session.Deadline.Format("2006-01-02 15:04:05"))
+	go session.wait()
+	return session, nil
+}
+
+// start 将保留的工作区交给项目用户并在伪终端中启动 shell
+func (s *DebugSession) start(shell string, account *isolation.Account, dir string, cols, rows uint16) error {
+	if err := isolation.Prepare(dir, account); err != nil {
+		return fmt.Errorf("准备调试工作区失败: %w", err)
+	}
+	// 保留目录的上层目录只需可进入，项目用户不能列出其他运行的保留工作区
+	if err := os.Chmod(filepath.Dir(dir), 0711); err != nil {
+		return fmt.Errorf("设置目录权限失败: %w", err)
+	}
+
+	cmd := exec.Command(shell, "-i")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"HOME="+account.Home, "USER="+account.Name, "LOGNAME="+account.Name,
+		"TERM=xterm-256color", "FLOWFORGE_DEBUG_UNTIL="+s.Deadline.Format(time.RFC3339))
+	isolation.Apply(cmd, account)
+
+	tty, err := startPTY(cmd, cols, rows)
+	if err != nil {
+		return fmt.Errorf("启动调试终端失败: %w", err)
+	}
+	s.cmd, s.tty = cmd, tty
+	return nil
+}
+
+// wait 等待 shell 退出、到达调试时限或终端被关闭，结束后记录原因并释放名额
+func (s *DebugSession) wait() {
+	exited := make(chan struct{})
+	go func() {
+		s.cmd.Wait()
+		close(exited)
+	}()
+
+	timer := time.NewTimer(time.Until(s.Deadline))
+	defer timer.Stop()
+
+	reason := "log.debug_ended"
+	select {
+	case <-exited:
+	case <-timer.C:
+		reason = "log.debug_expired"
+	case <-s.closing:
+	}
+	// shell 退出后其启动的后台进程也一并结束，不留在调试时限之后
+	killSession(s.cmd)
+	<-exited
+	s.tty.Close()
+
+	s.lineMu.Lock()
+	if len(s.line) > 0 {
+		s.record(string(s.line))
+		s.line = nil
+	}
+	s.lineMu.Unlock()
+	s.logf(reason)
+	s.engine.logWriter.Flush(s.RunID)
+
+	s.engine.debug.release(s)
+	close(s.done)
+}
+
+// Read 读取终端输出，同时按行记录到运行日志；终端结束后返回错误
+func (s *DebugSession) Read(p []byte) (int, error) {
+	n, err := s.tty.Read(p)
+	if n > 0 {
+		s.capture(p[:n])
+	}
+	return n, err
+}
+
+// Write 向终端写入键盘输入
+func (s *DebugSession) Write(p []byte) (int, error) {
+	return s.tty.Write(p)
+}
+
+// Resize 修改终端的行列数
+func (s *DebugSession) Resize(cols, rows uint16) error {
+	return resizePTY(s.tty, cols, rows)
+}
+
+// Close 结束终端中的全部进程，重复调用无副作用
+func (s *DebugSession) Close() {
+	s.closeOnce.Do(func() { close(s.closing) })
+}
+
+// Done 终端结束并记录日志后关闭
+func (s *DebugSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// capture 缓冲输出，遇到换行或缓冲过长时记录
+func (s *DebugSession) capture(data []byte) {
+	s.lineMu.Lock()
+	defer s.lineMu.Unlock()
+
+	for _, b := range data {
+		if b == '\n' || len(s.line) >= maxDebugLine {
+			s.record(string(s.line))
+			s.line = s.line[:0]
+			if b == '\n' {
+				continue
+			}
+		}
+		s.line = append(s.line, b)
+	}
+}
+
+// record 记录一行输出：去除控制序列，回车覆盖的内容只保留最后的部分
+func (s *DebugSession) record(line string) {
+	line = terminalSequence.ReplaceAllString(strings.TrimRight(line, "\r"), "")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	s.appendLog(debugLinePrefix + line)
+}
+
+// logf 按运行语言记录一行系统日志
+func (s *DebugSession) logf(key string, args ...interface{}) {
+	s.appendLog(i18n.T(s.locale, key, args...))
+}
+
+// appendLog 脱敏后追加到运行日志，由批量写入器定期落库
+func (s *DebugSession) appendLog(message string) {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	s.engine.logWriter.AppendLog(s.RunID, fmt.Sprintf("[%s] %s", timestamp, s.redactor.Redact(message)))
+}
+
+// closeDebugSessions 服务关闭时结束本实例打开的调试终端，shell 在新会话中运行，不会随服务进程退出
+func (e *Engine) closeDebugSessions() {
+	e.debug.mu.Lock()
+	sessions := make([]*DebugSession, 0, len(e.debug.sessions))
+	for _, session := range e.debug.sessions {
+		sessions = append(sessions, session)
+	}
+	e.debug.mu.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+		select {
+		case <-session.Done():
+		case <-time.After(5 * time.Second):
+			log.Printf("流水线运行 %d 的调试终端未能及时结束", session.RunID)
+		}
+	}
+}
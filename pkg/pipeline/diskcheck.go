@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+)
+
+// InfraError 基础设施类失败（磁盘、网络等），与流水线脚本自身的失败区分
+type InfraError struct {
+	Msg string
+}
+
+func (e *InfraError) Error() string {
+	return e.Msg
+}
+
+// isInfraError 错误链中是否包含基础设施类失败
+func isInfraError(err error) bool {
+	var infraErr *InfraError
+	return errors.As(err, &infraErr)
+}
+
+// estimateCheckoutSize 预估仓库检出大小：优先查询托管平台，其次使用项目配置的大小提示；来源以消息键返回
+func (e *Engine) estimateCheckoutSize(ctx context.Context, project *models.Project) (int64, string) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	size, err := e.gitManager.GetClient().EstimateRepoSize(queryCtx, project.RepoURL)
+	if err == nil && size > 0 {
+		return size, "log.size_source_host"
+	}
+
+	if project.SizeHintMB > 0 {
+		return int64(project.SizeHintMB) << 20, "log.size_source_hint"
+	}
+
+	return 0, ""
+}
+
+// checkDiskSpace 检出前校验工作区所在卷的可用空间，空间不足时返回基础设施类失败
+func (e *Engine) checkDiskSpace(jobCtx *JobContext, workDir string) error {
+	usage, err := utils.GetDiskUsage(workDir)
+	if err != nil {
+		e.logf(jobCtx, "log.disk_check_skipped", err)
+		return nil
+	}
+
+	estimate, source := e.estimateCheckoutSize(jobCtx.stepContext(), jobCtx.Project)
+	if estimate == 0 {
+		e.logf(jobCtx, "log.disk_size_unknown", utils.FormatFileSize(int64(usage.Free)))
+		return nil
+	}
+
+	need := estimate + int64(e.config.Deploy.DiskSafetyMarginMB)<<20
+	e.logf(jobCtx, "log.disk_estimate",
+		utils.FormatFileSize(estimate), i18n.T(jobCtx.Locale, source), utils.FormatFileSize(need), utils.FormatFileSize(int64(usage.Free)))
+
+	if uint64(need) > usage.Free {
+		return &InfraError{Msg: fmt.Sprintf("磁盘空间不足: 需要约 %s，可用 %s",
+			utils.FormatFileSize(need), utils.FormatFileSize(int64(usage.Free)))}
+	}
+
+	return nil
+}
+
+// WorkspaceDiskUsage 获取工作区所在卷的磁盘空间，就绪检查与管理接口共用
+func (e *Engine) WorkspaceDiskUsage() (*utils.DiskUsage, error) {
+	return utils.GetDiskUsage(e.config.Deploy.WorkspaceDir)
+}
+
+// DiskSafetyMargin 工作区要求保留的最小可用空间（字节）
+func (e *Engine) DiskSafetyMargin() uint64 {
+	return uint64(e.config.Deploy.DiskSafetyMarginMB) << 20
+}
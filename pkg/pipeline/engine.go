@@ -2,18 +2,38 @@ package pipeline
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"flowforge/pkg/artifact"
 	"flowforge/pkg/config"
 	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/events"
+	"flowforge/pkg/flags"
 	"flowforge/pkg/git"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/logarchive"
 	"flowforge/pkg/models"
+	"flowforge/pkg/notify"
+	"flowforge/pkg/policy"
+	"flowforge/pkg/progress"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/retry"
+	"flowforge/pkg/runlabel"
 	"flowforge/pkg/scripts"
+	"flowforge/pkg/ssh"
+	"flowforge/pkg/utils"
+
+	"gorm.io/gorm"
 )
 
 // Engine 流水线执行引擎
@@ -21,8 +41,24 @@ type Engine struct {
 	config        *config.Config
 	scriptManager *scripts.Manager
 	gitManager    *git.Manager
+	notifier      *notify.Manager
+	driftChecker  *deploy.DriftChecker
+	preflighter   *deploy.Preflighter
+	verifier      *deploy.Verifier
+	artifacts     *artifact.Store
+	logArchive    *logarchive.Store
+	deployLocks   *deploy.DeployLocks
+	logWriter     *runLogWriter
+	heartbeat     *heartbeat
 	runningJobs   map[uint]*JobContext
+	queue         runQueue
+	externalWaits map[uint]chan struct{} // 本实例中等待外部回调的步骤，按外部等待ID通知
 	mu            sync.RWMutex
+	shuttingDown  int32
+	prewarm       prewarmState
+	ingest        logIngestState
+	debug         debugState
+	worker        *models.Worker // 以执行器模式运行时的注册记录，API实例为 nil
 }
 
 // JobContext 任务上下文
@@ -33,94 +69,562 @@ type JobContext struct {
 	Context     context.Context
 	Cancel      context.CancelFunc
 	LogChan     chan string
+	Locale      string // 系统日志行使用的语言，取自触发用户的语言偏好
+
+	// 仅重跑失败步骤时使用：按步骤序号复用原运行中成功的步骤，并从保留的工作区恢复
+	ReuseSteps  map[int]*models.PipelineStep
+	RestoreFrom string
+	stepOrder   int
+	currentStep *models.PipelineStep
+	stepRecords map[int]*models.PipelineStep // 运行开始时创建的步骤记录，按步骤序号
+
+	// 当前步骤的日志，步骤结束时写入步骤记录
+	stepLogMu   sync.Mutex
+	stepLog     *strings.Builder
+	stepLogFull bool
+	step        *models.PipelineStep // 正在执行的步骤配置
+	engine      *Engine
+
+	// 正在执行的步骤的上下文与超时时间，只由执行步骤的协程读写
+	stepCtx      context.Context
+	stepDeadline time.Time
+
+	// 运行进度，步骤开始与结束时在内存中更新
+	progress *progress.Tracker
+
+	// 进程重启后恢复等待外部回调的运行：跳过已完成的步骤，从等待中的步骤继续
+	resumeWait *models.ExternalWait
+
+	// 前面步骤产生的输出，以 STEP_OUTPUT_<KEY> 环境变量传给后续脚本
+	Outputs map[string]string
+
+	// 开始执行时流水线所在的项目互斥组
+	mutexGroup string
+
+	// 配置来源为 repo 时本次运行读取的配置文件
+	RepoConfig *RepoConfigFile
+
+	// 解析配置时使用的共享资源版本，以及引用的环境变量组合并后的变量
+	sharedInputs []models.RunInput
+	envGroups    map[string]string
+
+	// 执行器批量上报日志使用的令牌与序号状态，任务开始执行时签发
+	ingest *runLogIngest
+
+	// 运行开始时解析的项目策略与流水线覆盖；运行超时到达时取消运行并标记 timedOut
+	policy   *models.EffectivePolicy
+	deadline *time.Timer
+	timedOut int32
+
+	// 取消运行时记录的原因，运行结束时写入运行记录与最后一行日志
+	cancelMu     sync.Mutex
+	cancellation *Cancellation
+
+	// 运行中签发的云平台临时凭证等只在本次运行有效的密钥值，写入日志前屏蔽
+	secretsMu   sync.Mutex
+	runSecrets  []string
+	runRedactor *redact.Redactor
+
+	// 生命周期：由 runJob 独占管理
+	StartedAt time.Time
+	exited    chan struct{}
+	// 看门狗首次发现运行已取消而执行协程仍未退出的时间，由 Engine.mu 保护
+	stuckSince time.Time
+	logMu     sync.RWMutex
+	closed    bool
 }
 
 // NewEngine 创建流水线执行引擎
 func NewEngine(cfg *config.Config, scriptMgr *scripts.Manager, gitMgr *git.Manager) *Engine {
-	return &Engine{
+	e := &Engine{
 		config:        cfg,
 		scriptManager: scriptMgr,
 		gitManager:    gitMgr,
+		logWriter:     newRunLogWriter(2 * time.Second),
 		runningJobs:   make(map[uint]*JobContext),
+		externalWaits: make(map[uint]chan struct{}),
+		deployLocks:   deploy.NewDeployLocks(),
+		verifier:      deploy.NewVerifier(cfg),
+	}
+	e.startHeartbeat()
+	return e
+}
+
+// Shutdown 停止引擎后台任务并写入缓冲中的日志；等待队列中未开始执行的运行随进程结束，记为因服务器关闭而取消
+func (e *Engine) Shutdown() {
+	atomic.StoreInt32(&e.shuttingDown, 1)
+
+	e.mu.RLock()
+	queued := make([]uint, 0, len(e.queue.items))
+	for _, item := range e.queue.items {
+		queued = append(queued, item.jobCtx.PipelineRun.ID)
+	}
+	e.mu.RUnlock()
+	for _, runID := range queued {
+		if _, err := e.cancelQueued(runID, Cancellation{Reason: models.CancelReasonShutdown}); err != nil {
+			log.Printf("取消排队的流水线运行 %d 失败: %v", runID, err)
+		}
 	}
+
+	e.stopHeartbeat()
+	e.closeDebugSessions()
+	e.logWriter.Stop()
+}
+
+// HealthCheck 检查引擎后台任务是否正常运行
+func (e *Engine) HealthCheck() error {
+	return e.logWriter.Healthy()
+}
+
+// LogWriterStats 获取日志批量写入的统计指标
+func (e *Engine) LogWriterStats() LogWriterStats {
+	return e.logWriter.Stats()
+}
+
+// ScriptStats 获取脚本执行的运行指标
+func (e *Engine) ScriptStats() scripts.Stats {
+	return e.scriptManager.Stats()
+}
+
+// SetNotifier 设置运行结束后的通知管理器
+func (e *Engine) SetNotifier(notifier *notify.Manager) {
+	e.notifier = notifier
+}
+
+// SetDriftChecker 设置远程部署的漂移检查器，部署后记录清单、部署前检查目标是否漂移
+func (e *Engine) SetDriftChecker(checker *deploy.DriftChecker) {
+	e.driftChecker = checker
+}
+
+// SetPreflighter 设置远程部署的部署前检查器，同步文件前确认目标可用
+func (e *Engine) SetPreflighter(preflighter *deploy.Preflighter) {
+	e.preflighter = preflighter
+}
+
+// SetArtifactStore 设置制品存储，artifact_from 步骤从中取出其他运行产出的制品
+func (e *Engine) SetArtifactStore(store *artifact.Store) {
+	e.artifacts = store
+}
+
+// SetLogArchive 设置日志归档存储，读取已压缩归档的历史日志
+func (e *Engine) SetLogArchive(store *logarchive.Store) {
+	e.logArchive = store
+}
+
+// LogArchive 获取日志归档存储，未设置时为 nil
+func (e *Engine) LogArchive() *logarchive.Store {
+	return e.logArchive
+}
+
+// DeployLocks 获取远程部署的目标锁，用于查看与调整等待队列
+func (e *Engine) DeployLocks() *deploy.DeployLocks {
+	return e.deployLocks
 }
 
 // RunPipeline 运行流水线
-func (e *Engine) RunPipeline(pipelineID uint, triggerType models.TriggerType, triggerBy uint) (*models.PipelineRun, error) {
+func (e *Engine) RunPipeline(pipelineID uint, triggerType string, triggerBy uint) (*models.PipelineRun, error) {
+	return e.RunPipelineAt(pipelineID, triggerType, triggerBy, "")
+}
+
+// RunPipelineAt 运行流水线并记录触发提交，配置来源为 repo 时读取该提交中的配置文件
+func (e *Engine) RunPipelineAt(pipelineID uint, triggerType string, triggerBy uint, commitSHA string) (*models.PipelineRun, error) {
+	return e.RunPipelineWithOptions(pipelineID, triggerType, triggerBy, RunOptions{CommitSHA: commitSHA})
+}
+
+// RunOptions 运行流水线的可选项
+type RunOptions struct {
+	CommitSHA     string // 触发提交
+	DebugEnv      bool   // 记录各步骤收到的环境变量值
+	KeepWorkspace bool   // 运行结束后保留工作区
+
+	// 上传的源码包，运行开始时解压到工作区，哈希代替提交哈希记录
+	SourceArchive *SourceArchive
+
+	// registry 项目部署的制品，校验后记录到运行并以 ARTIFACT_* 环境变量提供给步骤
+	Artifact *models.ArtifactReference
+
+	// 运行标签，调用方已按项目允许的标签校验
+	Labels []string
+
+	// 流水线开启了没有变化时跳过，仍然执行
+	Force bool
+
+	// 按该运行使用的输入执行：流水线版本与共享资源的版本，而不是当前的配置与最新版本
+	OriginalInputs *models.PipelineRun
+
+	// 重新运行的原运行
+	RetryOf *uint
+}
+
+// RunPipelineWithOptions 按可选项运行流水线
+func (e *Engine) RunPipelineWithOptions(pipelineID uint, triggerType string, triggerBy uint, opts RunOptions) (*models.PipelineRun, error) {
 	// 获取流水线信息
 	var pipeline models.Pipeline
-	if err := database.DB.Preload("Project").First(&pipeline, pipelineID).Error; err != nil {
+	if err := models.WithPrivateKey(database.DB).Preload("Project").Preload("Project.DeployKey").Preload("Project.SSHKey").First(&pipeline, pipelineID).Error; err != nil {
 		return nil, fmt.Errorf("获取流水线失败: %w", err)
 	}
+	revision := latestRevision(pipeline.ID)
+	if original := opts.OriginalInputs; original != nil {
+		if !pipeline.UsesRepoConfig() {
+			if original.PipelineRevision == 0 {
+				return nil, ErrInputsUnavailable
+			}
+			if err := pinRevision(&pipeline, original.PipelineRevision); err != nil {
+				return nil, err
+			}
+		}
+		revision = original.PipelineRevision
+	}
+	artifact, err := runnable(&pipeline, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	// 创建流水线运行记录
+	now := time.Now()
+	pipelineRun := &models.PipelineRun{
+		PipelineID:    pipelineID,
+		Status:        models.RunStatusRunning,
+		TriggerType:   triggerType,
+		UserID:        triggerBy,
+		StartTime:     &now,
+		CommitSHA:     opts.CommitSHA,
+		Branch:        pipeline.Project.Branch,
+		DebugEnv:      opts.DebugEnv,
+		KeepWorkspace: opts.KeepWorkspace,
+		Forced:        opts.Force,
+		RetryOfID:     opts.RetryOf,
+
+		PipelineRevision: revision,
+	}
+	if opts.SourceArchive != nil {
+		pipelineRun.CommitSHA = opts.SourceArchive.CommitSHA()
+		pipelineRun.SourceArchive = opts.SourceArchive.Path
+	}
+	if artifact != nil {
+		artifact.apply(pipelineRun)
+	}
+
+	if err := database.DB.Create(pipelineRun).Error; err != nil {
+		return nil, fmt.Errorf("创建流水线运行记录失败: %w", err)
+	}
+	if err := e.labelRun(pipelineRun, opts.Labels, triggerBy); err != nil {
+		database.DB.Delete(pipelineRun)
+		return nil, err
+	}
+	if opts.OriginalInputs != nil {
+		if err := copyResourceVersions(opts.OriginalInputs.ID, pipelineRun.ID); err != nil {
+			database.DB.Delete(pipelineRun)
+			return nil, fmt.Errorf("记录原运行使用的共享资源版本失败: %w", err)
+		}
+	}
+
+	e.startJob(&pipeline, pipelineRun, nil, "")
+
+	return pipelineRun, nil
+}
+
+// CheckRunnable 按可选项创建运行前的校验，与 RunPipelineWithOptions 的条件相同，不创建运行；pipeline 需已加载 Project
+func CheckRunnable(pipeline *models.Pipeline, opts RunOptions) error {
+	_, err := runnable(pipeline, opts)
+	return err
+}
+
+// runnable 校验项目未归档、源码来源与可选项匹配且保存的配置有效，返回校验后的制品
+func runnable(pipeline *models.Pipeline, opts RunOptions) (*registryArtifact, error) {
+	if pipeline.Project.IsArchived() {
+		return nil, models.ErrProjectArchived
+	}
+	if pipeline.Project.UsesArchiveSource() && opts.SourceArchive == nil {
+		return nil, ErrSourceArchiveRequired
+	}
+	if err := checkStoredConfig(pipeline); err != nil {
+		return nil, err
+	}
+	return resolveArtifact(&pipeline.Project, opts.Artifact)
+}
+
+// checkStoredConfig 创建运行记录前校验保存的配置，配置无效时返回 *ConfigError，不创建运行；
+// 配置来源为仓库时在运行中读取配置文件后校验
+func checkStoredConfig(pipeline *models.Pipeline) error {
+	if pipeline.UsesRepoConfig() {
+		return nil
+	}
+	_, err := LoadConfig(pipeline.Config)
+	return err
+}
+
+// RecordSkippedRun 为被过滤的触发事件创建 skipped 状态的运行，只记录原因，不进入队列也不执行
+func (e *Engine) RecordSkippedRun(pipelineID uint, triggerType string, triggerBy uint, commitSHA, reason string) (*models.PipelineRun, error) {
+	now := time.Now()
 	pipelineRun := &models.PipelineRun{
 		PipelineID:  pipelineID,
-		Status:      models.RunStatusRunning,
+		Status:      models.RunStatusSkipped,
 		TriggerType: triggerType,
-		TriggerBy:   triggerBy,
-		StartTime:   time.Now(),
+		UserID:      triggerBy,
+		StartTime:   &now,
+		EndTime:     &now,
+		CommitSHA:   commitSHA,
+		SkipReason:  reason,
+	}
+
+	if err := database.DB.Create(pipelineRun).Error; err != nil {
+		return nil, fmt.Errorf("创建流水线运行记录失败: %w", err)
+	}
+	return pipelineRun, nil
+}
+
+// RetryRun 重新运行：按原运行的提交、源码包或制品重新执行全部步骤，沿用原运行的标签。
+// originalInputs 为 true 时使用原运行的流水线版本与共享资源版本，否则使用当前的配置与共享资源的最新版本
+func (e *Engine) RetryRun(runID uint, triggerBy uint, originalInputs bool) (*models.PipelineRun, error) {
+	var original models.PipelineRun
+	if err := database.DB.First(&original, runID).Error; err != nil {
+		return nil, fmt.Errorf("获取流水线运行记录失败: %w", err)
+	}
+	if original.Status == models.RunStatusSkipped {
+		return nil, ErrRetrySkipped
+	}
+
+	opts := RunOptions{CommitSHA: original.CommitSHA, Force: true, RetryOf: &original.ID}
+	if originalInputs {
+		opts.OriginalInputs = &original
+	}
+	// 源码包超过制品保留期后被清理，源码包项目的运行无法再重新运行
+	if original.SourceArchive != "" && utils.IsFileExists(original.SourceArchive) {
+		opts.SourceArchive = &SourceArchive{Path: original.SourceArchive, SHA256: strings.TrimPrefix(original.CommitSHA, "sha256:")}
+	}
+	opts.Artifact = artifactReference(&original)
+	if labels, err := runlabel.ForRun(original.ID); err == nil {
+		opts.Labels = labels
+	}
+	return e.RunPipelineWithOptions(original.PipelineID, original.TriggerType, triggerBy, opts)
+}
+
+// RerunFailed 仅重跑失败步骤：复用原运行中已成功的步骤，从第一个失败步骤开始执行
+func (e *Engine) RerunFailed(runID uint, triggerBy uint) (*models.PipelineRun, error) {
+	var original models.PipelineRun
+	if err := database.DB.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_order ASC")
+	}).First(&original, runID).Error; err != nil {
+		return nil, fmt.Errorf("获取流水线运行记录失败: %w", err)
+	}
+
+	if !e.CanRerunFailed(&original) {
+		return nil, fmt.Errorf("原运行未失败或工作区已过期，无法仅重跑失败步骤")
+	}
+
+	var pipeline models.Pipeline
+	if err := models.WithPrivateKey(database.DB).Preload("Project").Preload("Project.DeployKey").Preload("Project.SSHKey").First(&pipeline, original.PipelineID).Error; err != nil {
+		return nil, fmt.Errorf("获取流水线失败: %w", err)
+	}
+	if pipeline.Project.IsArchived() {
+		return nil, models.ErrProjectArchived
+	}
+	if err := checkStoredConfig(&pipeline); err != nil {
+		return nil, err
+	}
+
+	// 只复用第一个失败步骤之前的成功步骤
+	reuse := make(map[int]*models.PipelineStep)
+	for i := range original.Steps {
+		step := &original.Steps[i]
+		if step.Status != models.StepStatusSuccess && step.Status != models.StepStatusReused {
+			break
+		}
+		// 原步骤本身是复用的，指向最初执行的步骤
+		if step.ReusedFromID != nil {
+			reuse[step.StepOrder] = &models.PipelineStep{ID: *step.ReusedFromID, Name: step.Name}
+		} else {
+			reuse[step.StepOrder] = step
+		}
+	}
+
+	now := time.Now()
+	rerunOf := original.ID
+	pipelineRun := &models.PipelineRun{
+		PipelineID:  pipeline.ID,
+		Status:      models.RunStatusRunning,
+		TriggerType: original.TriggerType,
+		UserID:      triggerBy,
+		StartTime:   &now,
+		RerunOfID:   &rerunOf,
+		CommitSHA:   original.CommitSHA,
+		Branch:      original.Branch,
+
+		SourceArchive: original.SourceArchive,
+
+		ArtifactKind:   original.ArtifactKind,
+		ArtifactRef:    original.ArtifactRef,
+		ArtifactDigest: original.ArtifactDigest,
+
+		PipelineRevision: latestRevision(pipeline.ID),
 	}
 
 	if err := database.DB.Create(pipelineRun).Error; err != nil {
 		return nil, fmt.Errorf("创建流水线运行记录失败: %w", err)
 	}
+	// 重跑沿用原运行的标签
+	labels, err := runlabel.ForRun(original.ID)
+	if err == nil {
+		err = e.labelRun(pipelineRun, labels, triggerBy)
+	}
+	if err != nil {
+		log.Printf("复制运行 %d 的标签失败: %v", original.ID, err)
+	}
 
-	// 创建任务上下文
+	e.startJob(&pipeline, pipelineRun, reuse, original.WorkspacePath)
+
+	return pipelineRun, nil
+}
+
+// labelRun 保存运行标签，并附在返回的运行记录上
+func (e *Engine) labelRun(pipelineRun *models.PipelineRun, labels []string, triggerBy uint) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	if err := runlabel.Add(database.DB, pipelineRun.ID, labels, &triggerBy); err != nil {
+		return err
+	}
+	return database.DB.Where("pipeline_run_id = ?", pipelineRun.ID).Order("id").Find(&pipelineRun.Labels).Error
+}
+
+// CanRerunFailed 原运行是否失败且其保留的工作区仍然可用
+func (e *Engine) CanRerunFailed(run *models.PipelineRun) bool {
+	if run.Status != models.RunStatusFailed || run.WorkspacePath == "" {
+		return false
+	}
+	if run.WorkspaceExpiresAt != nil && time.Now().After(*run.WorkspaceExpiresAt) {
+		return false
+	}
+	return utils.IsDirExists(run.WorkspacePath)
+}
+
+// startJob 创建任务上下文并异步执行流水线
+func (e *Engine) startJob(pipeline *models.Pipeline, pipelineRun *models.PipelineRun, reuse map[int]*models.PipelineStep, restoreFrom string) {
+	jobCtx := newJobContext(pipeline, pipelineRun, reuse, restoreFrom)
+
+	// 有存活的执行器时交给执行器领取
+	if e.dispatchToWorker(jobCtx) {
+		e.queueForWorker(jobCtx)
+		return
+	}
+
+	// 并发数已满时排队，否则异步执行流水线
+	e.enqueueOrStart(jobCtx)
+}
+
+// newJobContext 创建任务上下文
+func newJobContext(pipeline *models.Pipeline, pipelineRun *models.PipelineRun, reuse map[int]*models.PipelineStep, restoreFrom string) *JobContext {
 	ctx, cancel := context.WithCancel(context.Background())
 	jobCtx := &JobContext{
 		PipelineRun: pipelineRun,
-		Pipeline:    &pipeline,
+		Pipeline:    pipeline,
 		Project:     &pipeline.Project,
 		Context:     ctx,
 		Cancel:      cancel,
 		LogChan:     make(chan string, 100),
+		Locale:      runLocale(pipelineRun.UserID),
+		ReuseSteps:  reuse,
+		RestoreFrom: restoreFrom,
+		StartedAt:   time.Now(),
+		exited:      make(chan struct{}),
 	}
-
-	// 添加到运行中的任务
-	e.mu.Lock()
-	e.runningJobs[pipelineRun.ID] = jobCtx
-	e.mu.Unlock()
-
-	// 异步执行流水线
-	go e.executePipeline(jobCtx)
-
-	return pipelineRun, nil
+	// 运行中基础设施请求的重试与熔断决定写入运行日志
+	jobCtx.Context = retry.WithObserver(ctx, jobCtx.logRetryEvent)
+	return jobCtx
 }
 
 // executePipeline 执行流水线
 func (e *Engine) executePipeline(jobCtx *JobContext) {
-	defer func() {
-		// 清理任务上下文
-		e.mu.Lock()
-		delete(e.runningJobs, jobCtx.PipelineRun.ID)
-		e.mu.Unlock()
-		close(jobCtx.LogChan)
-	}()
-
-	// 解析流水线配置
+	// 解析流水线配置，保存的配置已在创建运行前校验
 	var config models.PipelineConfig
-	if err := json.Unmarshal([]byte(jobCtx.Pipeline.Config), &config); err != nil {
-		e.finishPipelineRun(jobCtx, models.RunStatusFailed, fmt.Sprintf("解析流水线配置失败: %v", err))
+	parsed, err := ParseConfig(jobCtx.Pipeline.Config)
+	if err == nil {
+		config = *parsed
+	}
+
+	// 解析后不再持有完整配置文本，避免长时间运行的任务保留大字符串
+	jobCtx.Pipeline.Config = ""
+
+	// 配置来源为仓库时，先拉取代码再使用触发提交中的配置文件代替保存的配置
+	if jobCtx.Pipeline.UsesRepoConfig() {
+		repoConfig, repoErr := e.loadRepoConfig(jobCtx)
+		if repoErr != nil {
+			e.finishPipelineRun(jobCtx, models.RunStatusFailed, i18n.T(jobCtx.Locale, "log.repo_config_failed", repoErr))
+			return
+		}
+		config, err = *repoConfig, nil
+	}
+
+	if err != nil {
+		e.finishPipelineRun(jobCtx, models.RunStatusFailed, i18n.T(jobCtx.Locale, "log.config_invalid", err))
+		return
+	}
+	// 展开引用的共享模板、环境变量组与脚本，记录使用的版本
+	if err := e.resolveSharedResources(jobCtx, &config); err != nil {
+		e.finishPipelineRun(jobCtx, models.RunStatusFailed, i18n.T(jobCtx.Locale, "log.shared_resource_failed", err))
 		return
 	}
+	e.applyPolicy(jobCtx, &config)
+
+	if jobCtx.resumeWait != nil {
+		// 恢复外部等待的运行沿用原工作区，配置快照已在首次执行时保存
+		e.logf(jobCtx, "log.external_wait_resumed", jobCtx.resumeWait.Description)
+	} else {
+		// 开启没有变化时跳过的流水线，与上次成功运行相比没有变化时不执行
+		if skip := e.checkUnchanged(jobCtx, &config); skip != nil {
+			e.skipUnchangedRun(jobCtx, skip)
+			return
+		}
+
+		// 保存脱敏后的配置快照，之后编辑流水线不影响本次运行的审计记录
+		if err := e.saveResolvedSnapshot(jobCtx, &config); err != nil {
+			log.Printf("保存流水线运行 %d 的配置快照失败: %v", jobCtx.PipelineRun.ID, err)
+		}
 
-	// 记录开始日志
-	e.logMessage(jobCtx, fmt.Sprintf("开始执行流水线: %s", jobCtx.Pipeline.Name))
+		// 记录开始日志
+		e.logf(jobCtx, "log.run_started", jobCtx.Pipeline.Name)
+		e.publishRunEvent(jobCtx, events.TypeRunStarted, models.RunStatusRunning)
+
+		// 从原运行保留的工作区恢复
+		if jobCtx.RestoreFrom != "" {
+			if err := e.restoreWorkspace(jobCtx); err != nil {
+				e.finishPipelineRun(jobCtx, models.RunStatusFailed, i18n.T(jobCtx.Locale, "log.restore_failed", err))
+				return
+			}
+			e.logf(jobCtx, "log.workspace_restored")
+		} else {
+			// 上传源码包触发的运行先解压源码，再使用缓存
+			if jobCtx.PipelineRun.SourceArchive != "" {
+				if err := e.extractSourceArchive(jobCtx); err != nil {
+					e.finishPipelineRun(jobCtx, models.RunStatusFailed, i18n.T(jobCtx.Locale, "log.source_extract_failed", err))
+					return
+				}
+			}
+			// 使用预热阶段暂存的依赖缓存
+			e.applyStagedCaches(jobCtx)
+		}
+	}
 
 	// 执行各个阶段
+	e.startProgress(jobCtx, &config)
+	e.createStepRecords(jobCtx, &config)
 	for i, stage := range config.Stages {
-		e.logMessage(jobCtx, fmt.Sprintf("执行阶段 %d: %s", i+1, stage.Name))
+		e.logf(jobCtx, "log.stage_started", i+1, stage.Name)
 
 		if err := e.executeStage(jobCtx, &stage); err != nil {
-			e.finishPipelineRun(jobCtx, models.RunStatusFailed, fmt.Sprintf("阶段 %s 执行失败: %v", stage.Name, err))
+			if isInfraError(err) {
+				jobCtx.PipelineRun.FailureKind = models.FailureKindInfra
+			}
+			e.finishPipelineRun(jobCtx, models.RunStatusFailed, i18n.T(jobCtx.Locale, "log.stage_failed", stage.Name, err))
 			return
 		}
 
-		e.logMessage(jobCtx, fmt.Sprintf("阶段 %s 执行完成", stage.Name))
+		e.logf(jobCtx, "log.stage_finished", stage.Name)
 	}
 
-	// 流水线执行成功
+	// 流水线执行成功，保存依赖缓存供下次预热使用
+	e.saveDependencyCaches(jobCtx)
 	e.finishPipelineRun(jobCtx, models.RunStatusSuccess, "流水线执行成功")
 }
 
@@ -128,7 +632,81 @@ func (e *Engine) executePipeline(jobCtx *JobContext) {
 func (e *Engine) executeStage(jobCtx *JobContext, stage *models.PipelineStage) error {
 	// 执行阶段中的所有步骤
 	for _, step := range stage.Steps {
-		if err := e.executeStep(jobCtx, &step); err != nil {
+		jobCtx.stepOrder++
+		record := jobCtx.stepRecord(&step)
+
+		// 恢复外部等待时，等待步骤之前的步骤已在重启前完成
+		if jobCtx.resumeWait != nil && jobCtx.stepOrder < jobCtx.resumeWait.StepOrder {
+			jobCtx.progress.Skip(jobCtx.stepOrder - 1)
+			continue
+		}
+
+		// 复用原运行中已成功的步骤，声明了 never_reuse 的步骤始终重新执行
+		if reused, ok := jobCtx.ReuseSteps[jobCtx.stepOrder]; ok && reused.Name == step.Name {
+			if neverReuse, _ := step.Config["never_reuse"].(bool); !neverReuse {
+				record.Status = models.StepStatusReused
+				record.ReusedFromID = &reused.ID
+				jobCtx.saveStepRecord(record, "status", "reused_from_id")
+				e.logf(jobCtx, "log.step_reused", step.Name)
+				jobCtx.progress.Skip(jobCtx.stepOrder - 1)
+				continue
+			}
+		}
+
+		startTime := time.Now()
+		resumed, err := e.resumedStep(jobCtx, &step)
+		if err != nil {
+			return fmt.Errorf("步骤 %s 执行失败: %w", step.Name, err)
+		}
+		if resumed != nil {
+			record = resumed
+			if record.StartTime != nil {
+				startTime = *record.StartTime
+			}
+		} else {
+			record.Status = models.StepStatusRunning
+			record.StartTime = &startTime
+			if err := jobCtx.saveStepRecord(record, "status", "start_time"); err != nil {
+				return fmt.Errorf("步骤 %s 执行失败: %w", step.Name, err)
+			}
+		}
+		jobCtx.currentStep = record
+		jobCtx.progress.Start(jobCtx.stepOrder-1, startTime)
+		jobCtx.startStepLog()
+
+		err = e.runStepWithTimeout(jobCtx, stage, &step)
+
+		// 解析测试报告；命令成功但报告中有失败用例时，按 fail_on_test_failures 决定是否判定步骤失败
+		if result := e.collectTestReports(jobCtx, &step, record); result != nil && err == nil && result.Failed > 0 {
+			if failOn, _ := step.Config["fail_on_test_failures"].(bool); failOn {
+				err = fmt.Errorf("测试报告显示 %d 个测试失败", result.Failed)
+			}
+		}
+
+		endTime := time.Now()
+		updates := map[string]interface{}{
+			"status":      models.StepStatusSuccess,
+			"end_time":    &endTime,
+			"duration":    int64(endTime.Sub(startTime).Seconds()),
+			"duration_ms": utils.DurationMs(endTime.Sub(startTime)),
+			"log_output":  jobCtx.takeStepLog(),
+		}
+		if err != nil {
+			updates["status"] = models.StepStatusFailed
+			updates["error_msg"] = jobCtx.redactText(err.Error())
+		}
+		// 步骤的结束状态在运行取消后仍然写入，否则被取消的步骤一直显示为运行中
+		database.DB.WithContext(jobCtx.detached()).Model(record).Updates(updates)
+		jobCtx.progress.Finish(jobCtx.stepOrder-1, endTime)
+
+		// 运行耗时属于非关键字段，随日志批量写入
+		elapsed := time.Since(jobCtx.StartedAt)
+		e.logWriter.SetFields(jobCtx.PipelineRun.ID, map[string]interface{}{
+			"duration":    int64(elapsed.Seconds()),
+			"duration_ms": utils.DurationMs(elapsed),
+		})
+
+		if err != nil {
 			return fmt.Errorf("步骤 %s 执行失败: %w", step.Name, err)
 		}
 	}
@@ -137,77 +715,131 @@ func (e *Engine) executeStage(jobCtx *JobContext, stage *models.PipelineStage) e
 
 // executeStep 执行步骤
 func (e *Engine) executeStep(jobCtx *JobContext, step *models.PipelineStep) error {
-	e.logMessage(jobCtx, fmt.Sprintf("执行步骤: %s", step.Name))
+	e.logf(jobCtx, "log.step_started", step.Name)
 
-	switch step.Type {
-	case "git_clone":
-		return e.executeGitClone(jobCtx, step)
-	case "script":
-		return e.executeScript(jobCtx, step)
-	case "build":
-		return e.executeBuild(jobCtx, step)
-	case "deploy":
-		return e.executeDeploy(jobCtx, step)
-	default:
-		return fmt.Errorf("不支持的步骤类型: %s", step.Type)
-	}
+	return e.runStepExecutor(jobCtx, step)
 }
 
 // executeGitClone 执行Git克隆
 func (e *Engine) executeGitClone(jobCtx *JobContext, step *models.PipelineStep) error {
 	project := jobCtx.Project
-	workDir := fmt.Sprintf("%s/workspaces/%d", e.config.App.DataPath, project.ID)
+	if project.UsesArchiveSource() {
+		return ErrArchiveSourceProject
+	}
+	if project.UsesRegistrySource() {
+		return ErrRegistrySourceProject
+	}
+	workDir := e.workspaceDir(project.ID)
+
+	// 首次克隆前预估仓库大小并检查磁盘空间，避免克隆到一半因空间不足失败
+	if !utils.IsFileExists(filepath.Join(workDir, ".git")) {
+		if err := e.checkDiskSpace(jobCtx, workDir); err != nil {
+			return err
+		}
+	}
+
+	// 仓库迁移后拉取会静默跟随重定向，先检查远程是否报告仓库已迁移
+	e.checkRepoRedirect(jobCtx)
+
+	// 配置的分支在远程已不存在时仅告警，拉取失败时在错误中列出可用分支
+	missingBranch := ""
+	branches, listErr := e.gitManager.GetClient().ListRemoteBranches(jobCtx.stepContext(), project, project.SSHKey)
+	if listErr != nil {
+		e.logf(jobCtx, "log.warning", listErr)
+	} else if !branches.Has(project.Branch) {
+		missingBranch = i18n.T(jobCtx.Locale, "log.branch_missing", project.Branch, strings.Join(branches.Branches, ", "))
+		e.logf(jobCtx, "log.warning", missingBranch)
+	}
+
+	// 隔离开启时先建好只允许项目用户访问的项目目录，代码不会短暂暴露给其他项目
+	if account, err := e.runAs(jobCtx); err != nil {
+		return err
+	} else if account != nil {
+		if err := isolation.Prepare(workDir, account); err != nil {
+			return fmt.Errorf("准备项目工作区失败: %w", err)
+		}
+	}
 
 	// 克隆或更新代码
-	if err := e.gitManager.CloneOrPull(project.RepoURL, project.Branch, workDir); err != nil {
+	err := e.gitManager.CloneOrPull(jobCtx.stepContext(), project, workDir)
+	// 查询远程分支时报告仓库不存在、随后拉取失败的同样计入
+	e.recordCheckout(jobCtx, err != nil && (git.IsRepoNotFound(err) || git.IsRepoNotFound(listErr)))
+	if err != nil {
+		if missingBranch != "" {
+			return fmt.Errorf("代码拉取失败（%s）: %w", missingBranch, err)
+		}
 		return fmt.Errorf("代码拉取失败: %w", err)
 	}
 
-	e.logMessage(jobCtx, "代码拉取完成")
+	e.logf(jobCtx, "log.checkout_finished")
 	return nil
 }
 
 // executeScript 执行脚本
 func (e *Engine) executeScript(jobCtx *JobContext, step *models.PipelineStep) error {
+	return e.executeScriptWithEnv(jobCtx, step, nil)
+}
+
+// executeScriptWithEnv 执行脚本，cloudEnv 为部署步骤的云平台临时凭证，覆盖其他来源的同名变量
+func (e *Engine) executeScriptWithEnv(jobCtx *JobContext, step *models.PipelineStep, cloudEnv map[string]string) error {
 	script, ok := step.Config["script"].(string)
 	if !ok {
 		return fmt.Errorf("脚本内容不能为空")
 	}
 
-	workDir := fmt.Sprintf("%s/workspaces/%d", e.config.App.DataPath, jobCtx.Project.ID)
+	workDir := e.workspaceDir(jobCtx.Project.ID)
 
-	// 准备环境变量
-	env := map[string]string{
-		"PROJECT_NAME":    jobCtx.Project.Name,
-		"PROJECT_ID":      fmt.Sprintf("%d", jobCtx.Project.ID),
-		"PIPELINE_ID":     fmt.Sprintf("%d", jobCtx.Pipeline.ID),
-		"PIPELINE_RUN_ID": fmt.Sprintf("%d", jobCtx.PipelineRun.ID),
-		"BUILD_VERSION":   fmt.Sprintf("v%d", jobCtx.PipelineRun.ID),
-	}
+	// 准备环境变量：内置变量、项目环境变量、前面步骤的输出、步骤自定义变量
+	env := resolveStepEnv(jobCtx, step)
+	env.setAll(EnvSourceCloud, cloudEnv)
+	e.warnEnvConflicts(jobCtx, env)
 
-	// 添加自定义环境变量
-	if envVars, ok := step.Config["env"].(map[string]interface{}); ok {
-		for k, v := range envVars {
-			if str, ok := v.(string); ok {
-				env[k] = str
-			}
-		}
+	// 隔离开启时以项目系统用户执行
+	account, err := e.runAs(jobCtx)
+	if err != nil {
+		return err
 	}
 
 	// 执行脚本
+	ctx, timeout := jobCtx.scriptTimeout()
 	opts := scripts.ExecuteOptions{
 		WorkDir: workDir,
-		Env:     env,
-		Timeout: 30 * time.Minute,
+		Env:     env.values,
+		Timeout: timeout,
 		LogCallback: func(line string) {
 			e.logMessage(jobCtx, line)
 		},
+		RunAs:    account,
+		Shell:    stepShell(jobCtx.Project, step),
+		Priority: e.stepPriority(jobCtx.Pipeline),
 	}
 
-	result, err := e.scriptManager.Execute(jobCtx.Context, script, opts)
+	// 从实际传给执行的变量表记录步骤环境
+	e.captureStepEnv(jobCtx, opts.Env, env)
+
+	// 需要时完整的原始输出另存为运行制品
+	if rawFile := e.rawOutputFile(jobCtx, step); rawFile != nil {
+		opts.RawOutput = rawFile
+		defer e.saveRawOutput(jobCtx, rawFile)
+	}
+
+	result, err := e.scriptManager.Execute(ctx, script, opts)
 	if err != nil {
+		// 执行主机缺少解释器属于基础设施问题
+		if errors.Is(err, scripts.ErrShellUnavailable) {
+			jobCtx.PipelineRun.FailureKind = models.FailureKindInfra
+		}
 		return fmt.Errorf("脚本执行失败: %w", err)
 	}
+	e.recordPriority(jobCtx, result.Priority)
+
+	if result.DroppedLines > 0 {
+		e.logf(jobCtx, "log.script_lines_dropped", result.DroppedLines)
+	}
+	// 排队等待执行名额或丢弃日志时步骤耗时不具代表性，运行不计入性能基线
+	if result.SlotWait > time.Second || result.DroppedLines > 0 {
+		jobCtx.PipelineRun.ResourceLimited = true
+	}
 
 	if result.ExitCode != 0 {
 		return fmt.Errorf("脚本执行失败，退出码: %d", result.ExitCode)
@@ -218,6 +850,9 @@ func (e *Engine) executeScript(jobCtx *JobContext, step *models.PipelineStep) er
 
 // executeBuild 执行构建
 func (e *Engine) executeBuild(jobCtx *JobContext, step *models.PipelineStep) error {
+	if jobCtx.Project.UsesRegistrySource() {
+		return ErrRegistrySourceProject
+	}
 	buildType, ok := step.Config["type"].(string)
 	if !ok {
 		buildType = "auto"
@@ -235,7 +870,7 @@ func (e *Engine) executeBuild(jobCtx *JobContext, step *models.PipelineStep) err
 		script = builtinScripts["docker_build"]
 	default:
 		// 自动检测构建类型
-		workDir := fmt.Sprintf("%s/workspaces/%d", e.config.App.DataPath, jobCtx.Project.ID)
+		workDir := e.workspaceDir(jobCtx.Project.ID)
 		if e.fileExists(workDir + "/package.json") {
 			script = builtinScripts["node_build"]
 		} else if e.fileExists(workDir + "/go.mod") {
@@ -247,13 +882,14 @@ func (e *Engine) executeBuild(jobCtx *JobContext, step *models.PipelineStep) err
 		}
 	}
 
-	// 创建脚本步骤
+	// 创建脚本步骤，内置构建脚本为 bash 脚本
 	scriptStep := &models.PipelineStep{
 		Name: "构建",
 		Type: "script",
 		Config: map[string]interface{}{
 			"script": script,
 			"env":    step.Config["env"],
+			"shell":  scripts.ShellBash,
 		},
 	}
 
@@ -262,6 +898,17 @@ func (e *Engine) executeBuild(jobCtx *JobContext, step *models.PipelineStep) err
 
 // executeDeploy 执行部署
 func (e *Engine) executeDeploy(jobCtx *JobContext, step *models.PipelineStep) error {
+	// 部署冻结期间拒绝部署，不含部署步骤的流水线不受影响
+	environment, _ := step.Config["environment"].(string)
+	if err := deploy.CheckFreeze(jobCtx.Project.ID, environment); err != nil {
+		var frozen *deploy.FrozenError
+		if errors.As(err, &frozen) {
+			jobCtx.PipelineRun.FailureKind = models.FailureKindFrozen
+			e.logf(jobCtx, "log.deploy_frozen", frozen.Freeze.ID, frozen.Freeze.Reason)
+		}
+		return err
+	}
+
 	deployType, ok := step.Config["type"].(string)
 	if !ok {
 		deployType = "script"
@@ -269,6 +916,12 @@ func (e *Engine) executeDeploy(jobCtx *JobContext, step *models.PipelineStep) er
 
 	switch deployType {
 	case "script":
+		// 只有部署步骤获得云平台临时凭证，构建与其他脚本步骤拿不到
+		cloudEnv, err := e.cloudCredentialEnv(jobCtx, environment)
+		if err != nil {
+			e.logf(jobCtx, "log.cloud_credential_failed", err)
+			return err
+		}
 		script := e.scriptManager.GetBuiltinScripts()["deploy_script"]
 		scriptStep := &models.PipelineStep{
 			Name: "部署",
@@ -276,71 +929,482 @@ func (e *Engine) executeDeploy(jobCtx *JobContext, step *models.PipelineStep) er
 			Config: map[string]interface{}{
 				"script": script,
 				"env":    step.Config["env"],
+				"shell":  scripts.ShellBash,
 			},
 		}
-		return e.executeScript(jobCtx, scriptStep)
+		return e.executeScriptWithEnv(jobCtx, scriptStep, cloudEnv)
+	case "ssh":
+		return e.executeRemoteSync(jobCtx, step)
 	default:
 		return fmt.Errorf("不支持的部署类型: %s", deployType)
 	}
 }
 
-// logMessage 记录日志消息
+// executeRemoteSync 将工作区增量同步到远程主机，仅传输变化的文件并支持断点续传
+func (e *Engine) executeRemoteSync(jobCtx *JobContext, step *models.PipelineStep) error {
+	host, _ := step.Config["host"].(string)
+	username, _ := step.Config["username"].(string)
+	remoteDir, _ := step.Config["remote_dir"].(string)
+	if host == "" || username == "" || remoteDir == "" {
+		return fmt.Errorf("远程部署需要配置 host、username 和 remote_dir")
+	}
+	verify, err := deploy.ParseVerifyConfig(step.Config["verify"])
+	if err != nil {
+		return fmt.Errorf("部署验证配置无效: %w", err)
+	}
+
+	port := 22
+	if p, ok := step.Config["port"].(float64); ok && p > 0 {
+		port = int(p)
+	}
+
+	keyID, _ := step.Config["ssh_key_id"].(float64)
+	var sshKey models.SSHKey
+	if err := models.WithPrivateKey(jobCtx.db()).First(&sshKey, uint(keyID)).Error; err != nil {
+		return fmt.Errorf("远程部署使用的SSH密钥不存在")
+	}
+
+	localDir := e.workspaceDir(jobCtx.Project.ID)
+	if source, ok := step.Config["source"].(string); ok && source != "" {
+		localDir = filepath.Join(localDir, filepath.Clean("/"+source))
+	}
+
+	serviceUnit, _ := step.Config["service_unit"].(string)
+	target := &deploy.DriftTarget{
+		Host:        host,
+		Port:        port,
+		Username:    username,
+		RemoteDir:   remoteDir,
+		ServiceUnit: serviceUnit,
+		SSHKey:      &sshKey,
+		Bastion:     stepBastion(step.Config["bastion"]),
+	}
+
+	// 同一目标同一时间只允许一个运行部署，检查与同步都在持有锁期间进行
+	release, err := e.deployLocks.Acquire(jobCtx.stepContext(), target.Key(), deploy.LockOwner{
+		RunID:        jobCtx.PipelineRun.ID,
+		PipelineID:   jobCtx.Pipeline.ID,
+		PipelineName: jobCtx.Pipeline.Name,
+		ProjectID:    jobCtx.Project.ID,
+		ProjectName:  jobCtx.Project.Name,
+	}, func(position int) {
+		e.logf(jobCtx, "log.deploy_lock_waiting", target.Key(), position)
+	})
+	if err != nil {
+		return fmt.Errorf("等待部署目标 %s 的部署锁时取消: %w", target.Key(), err)
+	}
+	defer release()
+
+	if err := e.checkTargetDrift(jobCtx, target); err != nil {
+		return err
+	}
+	preflight, err := e.preflightTarget(jobCtx, step, target, localDir)
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+	sshClient := ssh.NewClient(e.config).WithBastion(target.Bastion)
+	stats, err := sshClient.SyncDir(jobCtx.stepContext(), &sshKey, host, port, username, ssh.SyncOptions{
+		LocalDir:  localDir,
+		RemoteDir: remoteDir,
+		Retries:   e.config.Deploy.RetryCount,
+		Progress: func(message string) {
+			e.logMessage(jobCtx, message)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("同步工作区到 %s 失败: %w", host, err)
+	}
+
+	if stats.FullTransfer {
+		log.Printf("流水线运行 %d 远程同步执行了全量传输", jobCtx.PipelineRun.ID)
+	}
+
+	// 配置了部署后验证时，部署后命令执行完并全部检查通过后才记录为成功
+	environment, _ := step.Config["environment"].(string)
+	if verify == nil {
+		e.recordDeployment(jobCtx, target, stats, startedAt, preflight, environment, nil, nil)
+	}
+
+	// 同步后在目标上依次执行命令（如数据库迁移、重启服务），输出实时写入运行日志。
+	// 命令由目标主机上的监督脚本执行，连接中断时继续运行，重新连接后读取剩余输出与真实退出码；
+	// 同一运行（含其仅重跑失败步骤的运行）中的同一命令使用相同的远程运行目录，不会重复执行
+	commandTimeout, _ := step.Config["command_timeout"].(float64)
+	originRunID := e.originRunID(jobCtx.PipelineRun)
+	for i, command := range configStrings(step.Config["post_commands"]) {
+		e.logf(jobCtx, "log.remote_command", host, command)
+		result, err := sshClient.ExecuteSupervised(jobCtx.stepContext(), &sshKey, host, port, username, command, ssh.SuperviseOptions{
+			StreamOptions: ssh.StreamOptions{
+				LogCallback: func(line string) {
+					e.logMessage(jobCtx, fmt.Sprintf("[remote:%s] %s", host, line))
+				},
+			},
+			RunKey:  fmt.Sprintf("run-%d-step-%d-cmd-%d", originRunID, jobCtx.stepOrder, i),
+			Timeout: time.Duration(commandTimeout) * time.Second,
+			Progress: func(message string) {
+				e.logMessage(jobCtx, fmt.Sprintf("[remote:%s] %s", host, message))
+			},
+		})
+		if err != nil {
+			err = fmt.Errorf("在 %s 执行部署后命令失败: %w", host, err)
+			if verify != nil {
+				e.recordDeployment(jobCtx, target, stats, startedAt, preflight, environment, nil, err)
+			}
+			return err
+		}
+		e.logf(jobCtx, "log.remote_command_done", host, result.Duration.Round(time.Millisecond))
+	}
+
+	if verify != nil {
+		report, err := e.verifyDeployment(jobCtx, sshClient, target, verify)
+		e.recordDeployment(jobCtx, target, stats, startedAt, preflight, environment, report, err)
+		return err
+	}
+	return nil
+}
+
+// stepBastion 解析远程部署步骤的跳板机配置 bastion: {host, port, username, ssh_key_id}，未配置时使用密钥的默认跳板机
+func stepBastion(value interface{}) models.BastionConfig {
+	var bastion models.BastionConfig
+	config, ok := value.(map[string]interface{})
+	if !ok {
+		return bastion
+	}
+	bastion.BastionHost, _ = config["host"].(string)
+	bastion.BastionUsername, _ = config["username"].(string)
+	if port, ok := config["port"].(float64); ok && port > 0 {
+		bastion.BastionPort = int(port)
+	}
+	if keyID, ok := config["ssh_key_id"].(float64); ok && keyID > 0 {
+		id := uint(keyID)
+		bastion.BastionKeyID = &id
+	}
+	return bastion
+}
+
+// originRunID 仅重跑失败步骤的运行沿原运行链找到最初的运行，远程命令按最初运行标识，
+// 原运行中因连接中断而未取得结果的命令在重跑时重新连接，不会再次执行
+func (e *Engine) originRunID(run *models.PipelineRun) uint {
+	id, rerunOf := run.ID, run.RerunOfID
+	for depth := 0; rerunOf != nil && depth < 100; depth++ {
+		var original models.PipelineRun
+		if err := database.DB.Select("id", "rerun_of_id").First(&original, *rerunOf).Error; err != nil {
+			break
+		}
+		id, rerunOf = original.ID, original.RerunOfID
+	}
+	return id
+}
+
+// checkTargetDrift 部署前检查目标自上次部署后是否被手工修改，按配置告警或阻止部署
+func (e *Engine) checkTargetDrift(jobCtx *JobContext, target *deploy.DriftTarget) error {
+	if e.driftChecker == nil {
+		return nil
+	}
+	manifest := e.driftChecker.Latest(jobCtx.Project.ID, target)
+	if manifest == nil {
+		return nil
+	}
+
+	report := e.driftChecker.Check(jobCtx.stepContext(), manifest)
+	switch report.Status {
+	case models.DriftStatusDrifted:
+		if e.driftChecker.Policy() == deploy.DriftPolicyBlock {
+			return fmt.Errorf("部署目标 %s 自部署 #%d 后已被修改（%s），已按配置阻止部署", target.Key(), manifest.DeploymentID, report.Summary())
+		}
+		e.logf(jobCtx, "log.drift_detected", target.Key(), manifest.DeploymentID, report.Summary())
+	case models.DriftStatusUnreachable:
+		e.logf(jobCtx, "log.drift_check_failed", target.Key(), report.Error)
+	}
+	return nil
+}
+
+// recordDeployment 记录部署（附带部署前检查与部署后验证结果）并保存目标的文件清单，供后续漂移检查使用；部署到发布环境时生成发布记录。
+// deployErr 非空时（部署后命令或验证未通过）部署记为失败，文件已同步到目标，清单照常保存
+func (e *Engine) recordDeployment(jobCtx *JobContext, target *deploy.DriftTarget, stats *ssh.SyncStats, startedAt time.Time, preflight *deploy.PreflightReport, environment string, verification *deploy.VerifyReport, deployErr error) {
+	now := time.Now()
+	status, errorMsg := models.DeployStatusSuccess, ""
+	if deployErr != nil {
+		status, errorMsg = models.DeployStatusFailed, deployErr.Error()
+	}
+	deployment := &models.Deployment{
+		Version:     fmt.Sprintf("v%d", jobCtx.PipelineRun.ID),
+		CommitHash:  jobCtx.PipelineRun.CommitSHA,
+		Status:      status,
+		ErrorMsg:    errorMsg,
+		StartTime:   &startedAt,
+		EndTime:     &now,
+		Duration:    int64(now.Sub(startedAt).Seconds()),
+		DurationMs:  utils.DurationMs(now.Sub(startedAt)),
+		ProjectID:   jobCtx.Project.ID,
+		UserID:      jobCtx.PipelineRun.UserID,
+		Preflight:   preflightJSON(preflight),
+		Verify:      verifyJSON(verification),
+		Environment: environment,
+	}
+	if err := jobCtx.db().Create(deployment).Error; err != nil {
+		log.Printf("流水线运行 %d 保存部署记录失败: %v", jobCtx.PipelineRun.ID, err)
+		return
+	}
+	e.publishDeploymentEvent(jobCtx, deployment, target.Key())
+	if deployment.Status == models.DeployStatusSuccess {
+		e.recordRelease(jobCtx, deployment)
+	}
+
+	if e.driftChecker == nil {
+		return
+	}
+	if _, err := e.driftChecker.Record(jobCtx.stepContext(), deployment, target, stats.Manifest); err != nil {
+		e.logf(jobCtx, "log.warning", err)
+	}
+}
+
+// logMessage 记录日志消息，密钥值与脱敏规则匹配的内容在推送与写入前替换
 func (e *Engine) logMessage(jobCtx *JobContext, message string) {
+	message = jobCtx.redactText(message)
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	logLine := fmt.Sprintf("[%s] %s", timestamp, message)
 	
-	// 发送到日志通道
-	select {
-	case jobCtx.LogChan <- logLine:
-	default:
-		// 通道满了，丢弃日志
+	jobCtx.appendStepLog(logLine)
+
+	// 发送到日志通道，任务已释放时不再写入
+	jobCtx.logMu.RLock()
+	if !jobCtx.closed {
+		select {
+		case jobCtx.LogChan <- logLine:
+		default:
+			// 通道满了，丢弃日志
+		}
+	}
+	jobCtx.logMu.RUnlock()
+
+	// 缓冲后批量写入运行记录，对触发运行的用户或项目关闭批量写入时每行立即落库
+	e.logWriter.AppendLog(jobCtx.PipelineRun.ID, logLine)
+	if !flags.EnabledFor(jobCtx.Context, flags.BatchedLogWrites, jobCtx.Project.ID, jobCtx.PipelineRun.UserID) {
+		e.logWriter.Flush(jobCtx.PipelineRun.ID)
 	}
 
 	// 同时输出到控制台
 	log.Printf("Pipeline %d: %s", jobCtx.Pipeline.ID, message)
 }
 
+// logf 按运行语言记录系统日志行
+func (e *Engine) logf(jobCtx *JobContext, key string, args ...interface{}) {
+	e.logMessage(jobCtx, i18n.T(jobCtx.Locale, key, args...))
+}
+
+// runLocale 获取触发用户的语言偏好，未设置时使用默认语言
+func runLocale(userID uint) string {
+	var locale string
+	if database.DB != nil {
+		database.DB.Model(&models.User{}).Select("locale").Where("id = ?", userID).Scan(&locale)
+	}
+	if normalized := i18n.Normalize(locale); normalized != "" {
+		return normalized
+	}
+	return i18n.DefaultLocale
+}
+
 // finishPipelineRun 完成流水线运行
-func (e *Engine) finishPipelineRun(jobCtx *JobContext, status models.RunStatus, message string) {
+func (e *Engine) finishPipelineRun(jobCtx *JobContext, status string, message string) {
 	endTime := time.Now()
-	duration := endTime.Sub(jobCtx.PipelineRun.StartTime)
+	var duration time.Duration
+	if jobCtx.PipelineRun.StartTime != nil {
+		duration = endTime.Sub(*jobCtx.PipelineRun.StartTime)
+	}
+
+	// 运行被取消时执行中的步骤以失败返回，结束状态保持为已取消，不覆盖 CancelPipelineRun 写入的状态；
+	// 忽略取消的步骤事后成功返回时同样保持取消；超过策略的运行超时而中止的运行为失败
+	if jobCtx.Context.Err() != nil && (status != models.RunStatusSuccess || jobCtx.cancelledWith() != nil) {
+		if jobCtx.timedOutByPolicy() {
+			status = models.RunStatusFailed
+			message = i18n.T(jobCtx.Locale, "log.run_timed_out", jobCtx.policy.RunTimeoutMinutes)
+		} else {
+			status = models.RunStatusCancelled
+		}
+	}
+
+	// 取消的运行总是记录原因，未经 CancelPipelineRun 取消时为 unknown
+	cancellation := jobCtx.cancelledWith()
+	if cancellation == nil && status == models.RunStatusCancelled {
+		cancellation = &Cancellation{Reason: models.CancelReasonUnknown}
+	}
+	if status == models.RunStatusSuccess {
+		cancellation = nil
+	}
+
+	e.logMessage(jobCtx, message)
+	if cancellation != nil {
+		cancellation.apply(jobCtx.PipelineRun)
+		e.logf(jobCtx, "log.run_finished_reason", status, jobCtx.PipelineRun.CancellationSummary(), duration)
+	} else {
+		e.logf(jobCtx, "log.run_finished", status, duration)
+	}
+
+	// 状态变更前同步写入缓冲的日志
+	e.logWriter.Flush(jobCtx.PipelineRun.ID)
+	e.skipPendingSteps(jobCtx)
 
 	// 更新流水线运行记录
 	updates := map[string]interface{}{
-		"status":   status,
-		"end_time": endTime,
-		"duration": int(duration.Seconds()),
-		"logs":     message,
+		"status":      status,
+		"end_time":    endTime,
+		"duration":    int64(duration.Seconds()),
+		"duration_ms": utils.DurationMs(duration),
+	}
+	if status != models.RunStatusSuccess {
+		updates["error_msg"] = jobCtx.redactText(message)
+	}
+	if jobCtx.PipelineRun.FailureKind != "" {
+		updates["failure_kind"] = jobCtx.PipelineRun.FailureKind
+	}
+	if jobCtx.PipelineRun.ResourceLimited {
+		updates["resource_limited"] = true
+	}
+	if cancellation != nil {
+		for column, value := range cancellation.columns() {
+			updates[column] = value
+		}
+	}
+
+	// 失败运行保留工作区，供仅重跑失败步骤使用；要求保留工作区的运行结束后同样保留，供浏览生成的文件
+	if status == models.RunStatusFailed || jobCtx.PipelineRun.KeepWorkspace {
+		if path, err := e.retainWorkspace(jobCtx); err != nil {
+			log.Printf("保留流水线运行 %d 的工作区失败: %v", jobCtx.PipelineRun.ID, err)
+		} else {
+			expiresAt := endTime.Add(time.Duration(e.config.Deploy.RetainWorkspaceHours) * time.Hour)
+			if status == models.RunStatusFailed && jobCtx.Pipeline.DebugOnFailure {
+				if debugUntil := e.holdForDebug(jobCtx, endTime); debugUntil != nil {
+					updates["debug_until"] = debugUntil
+					if expiresAt.Before(*debugUntil) {
+						expiresAt = *debugUntil
+					}
+				}
+			}
+			updates["workspace_path"] = path
+			updates["workspace_expires_at"] = &expiresAt
+		}
 	}
 
-	if err := database.DB.Model(jobCtx.PipelineRun).Updates(updates).Error; err != nil {
+	// 最终状态在运行取消后仍需写入，不使用运行的上下文
+	if err := database.DB.WithContext(jobCtx.detached()).Model(jobCtx.PipelineRun).Updates(updates).Error; err != nil {
 		log.Printf("更新流水线运行记录失败: %v", err)
 	}
+	e.publishRunEvent(jobCtx, events.TypeRunFinished, string(status))
+
+	if status == models.RunStatusSuccess {
+		e.checkPerformance(jobCtx)
+	}
+
+	// 通知关注者，通知在运行结束后异步发送，不随运行取消
+	if e.notifier != nil {
+		go e.notifier.NotifyRunFinished(jobCtx.PipelineRun.ID, policy.Recipients(jobCtx.policy, string(status)))
+	}
+}
+
+// retainWorkspace 将当前工作区复制到运行专属的保留目录
+func (e *Engine) retainWorkspace(jobCtx *JobContext) (string, error) {
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	if !utils.IsDirExists(workDir) {
+		return "", fmt.Errorf("工作区不存在: %s", workDir)
+	}
+
+	retainedDir := filepath.Join(e.config.Deploy.WorkspaceDir, "retained", fmt.Sprintf("%d", jobCtx.PipelineRun.ID))
+	if err := os.RemoveAll(retainedDir); err != nil {
+		return "", err
+	}
+	if err := utils.CopyDir(workDir, retainedDir); err != nil {
+		return "", err
+	}
+
+	return retainedDir, nil
+}
+
+// restoreWorkspace 用原运行保留的工作区替换当前工作区
+func (e *Engine) restoreWorkspace(jobCtx *JobContext) error {
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	if err := os.RemoveAll(workDir); err != nil {
+		return err
+	}
+	return utils.CopyDir(jobCtx.RestoreFrom, workDir)
+}
+
+// Cancellation 取消运行的原因、说明与操作人
+type Cancellation struct {
+	Reason  string // models.CancelReason* 中的固定取值
+	Detail  string
+	ActorID *uint
+}
+
+// UserCancellation 用户取消运行，detail 为用户填写的说明
+func UserCancellation(userID uint, detail string) Cancellation {
+	return Cancellation{Reason: models.CancelReasonUser, Detail: strings.TrimSpace(detail), ActorID: &userID}
+}
+
+// columns 写入运行记录的取消原因字段
+func (c Cancellation) columns() map[string]interface{} {
+	return map[string]interface{}{
+		"cancellation_reason": c.Reason,
+		"cancellation_detail": c.Detail,
+		"cancelled_by_id":     c.ActorID,
+	}
+}
+
+// apply 将取消原因写入内存中的运行记录
+func (c Cancellation) apply(run *models.PipelineRun) {
+	run.CancellationReason = c.Reason
+	run.CancellationDetail = c.Detail
+	run.CancelledByID = c.ActorID
+}
+
+// setCancellation 记录取消原因，先记录的原因优先（如超时后用户再取消）
+func (j *JobContext) setCancellation(cancel Cancellation) {
+	j.cancelMu.Lock()
+	defer j.cancelMu.Unlock()
+	if j.cancellation == nil {
+		j.cancellation = &cancel
+	}
+}
 
-	e.logMessage(jobCtx, fmt.Sprintf("流水线执行完成，状态: %s，耗时: %v", status, duration))
+// cancelledWith 取消原因，运行未被取消时返回 nil
+func (j *JobContext) cancelledWith() *Cancellation {
+	j.cancelMu.Lock()
+	defer j.cancelMu.Unlock()
+	return j.cancellation
 }
 
-// CancelPipelineRun 取消流水线运行
-func (e *Engine) CancelPipelineRun(runID uint) error {
+// CancelPipelineRun 取消流水线运行并记录取消原因
+func (e *Engine) CancelPipelineRun(runID uint, cancel Cancellation) error {
+	if queued, err := e.cancelQueued(runID, cancel); queued {
+		return err
+	}
+
 	e.mu.RLock()
 	jobCtx, exists := e.runningJobs[runID]
 	e.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("流水线运行不存在或已完成")
+		return e.cancelRemote(runID, cancel)
 	}
 
 	// 取消上下文
+	jobCtx.setCancellation(cancel)
 	jobCtx.Cancel()
+	e.logf(jobCtx, "log.run_cancelled")
+	e.logWriter.Flush(runID)
 
 	// 更新状态
 	updates := map[string]interface{}{
-		"status":   models.RunStatusCancelled,
-		"end_time": time.Now(),
-		"logs":     "流水线运行已被取消",
+		"status":    models.RunStatusCancelled,
+		"end_time":  time.Now(),
+		"error_msg": "流水线运行已被取消",
+	}
+	for column, value := range cancel.columns() {
+		updates[column] = value
 	}
 
-	return database.DB.Model(jobCtx.PipelineRun).Updates(updates).Error
+	return database.DB.Model(&models.PipelineRun{}).Where("id = ?", runID).Updates(updates).Error
 }
 
 // GetRunningJobs 获取正在运行的任务
@@ -357,6 +1421,13 @@ func (e *Engine) GetRunningJobs() map[uint]*JobContext {
 
 // GetJobLogs 获取任务日志
 func (e *Engine) GetJobLogs(runID uint) ([]string, error) {
+	logs, _, err := e.GetJobLogPage(runID, 0, 0)
+	return logs, err
+}
+
+// GetJobLogPage 获取任务日志中从第 offset 行起的 limit 行（limit 不大于 0 时到末尾），同时返回总行数。
+// 已压缩归档的历史日志从归档中解压读取，只解压所需的分段
+func (e *Engine) GetJobLogPage(runID uint, offset, limit int) ([]string, int, error) {
 	e.mu.RLock()
 	jobCtx, exists := e.runningJobs[runID]
 	e.mu.RUnlock()
@@ -365,23 +1436,54 @@ func (e *Engine) GetJobLogs(runID uint) ([]string, error) {
 		// 从数据库获取历史日志
 		var pipelineRun models.PipelineRun
 		if err := database.DB.First(&pipelineRun, runID).Error; err != nil {
-			return nil, fmt.Errorf("流水线运行不存在")
+			return nil, 0, fmt.Errorf("流水线运行不存在")
 		}
-		return []string{pipelineRun.Logs}, nil
+		if pipelineRun.LogArchived {
+			if e.logArchive == nil {
+				return nil, 0, fmt.Errorf("日志已压缩归档，未配置日志归档存储")
+			}
+			return e.logArchive.Read(runID, offset, limit)
+		}
+		return pageLines(strings.Split(strings.TrimRight(pipelineRun.LogOutput, "\n"), "\n"), offset, limit)
 	}
 
-	// 获取实时日志
+	logs := e.drainJobLogs(jobCtx)
+	return pageLines(logs, offset, limit)
+}
+
+// drainJobLogs 取出运行中任务缓冲的实时日志
+func (e *Engine) drainJobLogs(jobCtx *JobContext) []string {
+
 	var logs []string
 	for {
 		select {
-		case log := <-jobCtx.LogChan:
+		case log, ok := <-jobCtx.LogChan:
+			if !ok {
+				return logs
+			}
 			logs = append(logs, log)
 		default:
-			return logs, nil
+			return logs
 		}
 	}
 }
 
+// pageLines 截取从第 offset 行起的 limit 行，limit 不大于 0 时到末尾
+func pageLines(lines []string, offset, limit int) ([]string, int, error) {
+	total := len(lines)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return lines[offset:end], total, nil
+}
+
 // fileExists 检查文件是否存在
 func (e *Engine) fileExists(filename string) bool {
 	_, err := os.Stat(filename)
@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/git"
+	"flowforge/pkg/models"
+	"flowforge/pkg/scripts"
+)
+
+// testUserID 触发测试运行的用户
+const testUserID = 1
+
+// setupTestDB 以测试名命名的内存数据库与临时数据目录，测试结束时恢复全局配置
+func setupTestDB(tb testing.TB) *config.Config {
+	tb.Helper()
+	dir := tb.TempDir()
+	cfg := &config.Config{
+		App: config.ApplicationConfig{DataPath: filepath.Join(dir, "data")},
+		Database: config.DatabaseConfig{
+			Type:         "sqlite",
+			Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", tb.Name()),
+			MaxIdleConns: 1,
+			MaxOpenConns: 1,
+			LogLevel:     "silent",
+		},
+		Deploy: config.DeployConfig{
+			WorkspaceDir:         filepath.Join(dir, "workspace"),
+			Timeout:              60,
+			MaxConcurrent:        4,
+			MaxScriptExecutions:  4,
+			RetainWorkspaceHours: 1,
+		},
+	}
+	if err := database.InitDatabase(cfg); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		tb.Fatal(err)
+	}
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	tb.Cleanup(func() { config.AppConfig = previous })
+	return cfg
+}
+
+// setupEngineTest 测试数据库与一个本机执行脚本的引擎，返回引擎与项目 "web"
+func setupEngineTest(t *testing.T) (*Engine, *models.Project) {
+	t.Helper()
+	cfg := setupTestDB(t)
+
+	project := &models.Project{Name: "web", RepoURL: "https://git.example/web.git", Branch: "main", UserID: testUserID}
+	if err := database.DB.Create(project).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEngine(cfg, scripts.NewManager(cfg), git.NewManager(cfg))
+	t.Cleanup(e.Shutdown)
+	if err := os.MkdirAll(e.workspaceDir(project.ID), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return e, project
+}
+
+// createPipeline 在项目下保存配置为 yaml 的流水线
+func createPipeline(t *testing.T, project *models.Project, name, yaml string) *models.Pipeline {
+	t.Helper()
+	pipeline := &models.Pipeline{Name: name, ProjectID: project.ID, Trigger: models.TriggerManual, Config: yaml}
+	if err := database.DB.Create(pipeline).Error; err != nil {
+		t.Fatal(err)
+	}
+	return pipeline
+}
+
+// scriptPipeline 单个阶段、按顺序执行给定脚本的流水线配置
+func scriptPipeline(scripts ...string) string {
+	var b strings.Builder
+	b.WriteString("stages:\n  - name: build\n    steps:\n")
+	for i, script := range scripts {
+		fmt.Fprintf(&b, "      - name: step%d\n        type: script\n        config:\n          script: %q\n", i+1, script)
+	}
+	return b.String()
+}
+
+// startRun 手动触发流水线
+func startRun(t *testing.T, e *Engine, pipeline *models.Pipeline) *models.PipelineRun {
+	t.Helper()
+	run, err := e.RunPipeline(pipeline.ID, models.TriggerManual, testUserID)
+	if err != nil {
+		t.Fatalf("触发流水线失败: %v", err)
+	}
+	return run
+}
+
+// waitRun 等待运行结束并离开运行中任务，返回数据库中的运行记录
+func waitRun(t *testing.T, e *Engine, runID uint) *models.PipelineRun {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		var run models.PipelineRun
+		if err := database.DB.First(&run, runID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if run.Status != models.RunStatusRunning && run.Status != models.RunStatusPending && !e.isRunning(runID) {
+			return &run
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("运行 %d 没有在 30 秒内结束", runID)
+	return nil
+}
+
+// waitStep 等待运行的第 order 个步骤进入执行状态
+func waitStep(t *testing.T, runID uint, order int) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		var step models.PipelineStep
+		err := database.DB.Where("pipeline_run_id = ? AND step_order = ?", runID, order).First(&step).Error
+		if err == nil && step.Status == models.StepStatusRunning {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("运行 %d 的第 %d 个步骤没有开始执行", runID, order)
+}
+
+// runSteps 运行的步骤记录，按步骤序号
+func runSteps(t *testing.T, runID uint) []models.PipelineStep {
+	t.Helper()
+	var steps []models.PipelineStep
+	if err := database.DB.Where("pipeline_run_id = ?", runID).Order("step_order").Find(&steps).Error; err != nil {
+		t.Fatal(err)
+	}
+	return steps
+}
+
+// isRunning 运行是否仍在运行中任务里
+func (e *Engine) isRunning(runID uint) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, ok := e.runningJobs[runID]
+	return ok
+}
+
+// TestEngineRunsScriptPipeline 本机执行脚本的流水线运行成功，步骤依次写入工作区
+func TestEngineRunsScriptPipeline(t *testing.T) {
+	e, project := setupEngineTest(t)
+	pipeline := createPipeline(t, project, "build", scriptPipeline("echo one > out.txt", "cat out.txt"))
+
+	run := waitRun(t, e, startRun(t, e, pipeline).ID)
+	if run.Status != models.RunStatusSuccess {
+		t.Fatalf("运行状态 %s，应为 success: %s", run.Status, run.ErrorMsg)
+	}
+	for _, step := range runSteps(t, run.ID) {
+		if step.Status != models.StepStatusSuccess {
+			t.Errorf("步骤 %s 状态 %s，应为 success", step.Name, step.Status)
+		}
+	}
+}
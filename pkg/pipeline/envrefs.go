@@ -0,0 +1,265 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// builtinEnvNames 引擎提供给步骤的变量：resolveStepEnv 设置的内置变量（ARTIFACT_* 只在 registry 项目的运行中设置）
+// 与部署步骤扮演云平台角色时注入的临时凭证
+var builtinEnvNames = map[string]bool{
+	"PROJECT_NAME":              true,
+	"PROJECT_ID":                true,
+	"PIPELINE_ID":               true,
+	"PIPELINE_RUN_ID":           true,
+	"BUILD_VERSION":             true,
+	"LOG_INGEST_TOKEN":          true,
+	"LOG_INGEST_URL":            true,
+	"ARTIFACT_KIND":             true,
+	"ARTIFACT_REF":              true,
+	"ARTIFACT_IMAGE":            true,
+	"ARTIFACT_REPOSITORY":       true,
+	"ARTIFACT_TAG":              true,
+	"ARTIFACT_DIGEST":           true,
+	"ARTIFACT_URL":              true,
+	"ARTIFACT_SHA256":           true,
+	"AWS_ACCESS_KEY_ID":         true,
+	"AWS_SECRET_ACCESS_KEY":     true,
+	"AWS_SESSION_TOKEN":         true,
+	"AWS_REGION":                true,
+	"AWS_DEFAULT_REGION":        true,
+	"AWS_CREDENTIAL_EXPIRATION": true,
+}
+
+// shellEnvNames shell 与执行环境自带的变量，脚本中引用时不报告
+var shellEnvNames = map[string]bool{
+	"HOME": true, "PATH": true, "PWD": true, "OLDPWD": true, "USER": true, "LOGNAME": true,
+	"SHELL": true, "HOSTNAME": true, "TMPDIR": true, "TERM": true, "LANG": true, "LC_ALL": true,
+	"IFS": true, "PS1": true, "PS4": true, "RANDOM": true, "LINENO": true, "SECONDS": true,
+	"REPLY": true, "OPTARG": true, "OPTIND": true, "PPID": true, "UID": true, "EUID": true,
+	"BASH": true, "BASH_VERSION": true, "BASH_SOURCE": true, "BASH_REMATCH": true, "BASHPID": true,
+	"FUNCNAME": true, "PIPESTATUS": true, "HOSTTYPE": true, "OSTYPE": true, "MACHTYPE": true,
+}
+
+// 脚本中自己定义的变量：赋值（含 export、local 等前缀）、for/select 循环变量、read 与 getopts 读入的变量
+var (
+	shellAssignment = regexp.MustCompile(`(?m)(?:^|[\s;&|(])([A-Za-z_][A-Za-z0-9_]*)\+?=`)
+	shellLoopVar    = regexp.MustCompile(`\b(?:for|select)\s+([A-Za-z_][A-Za-z0-9_]*)\b`)
+	shellReadVars   = regexp.MustCompile(`\bread\s+([^;&|<>\n]*)`)
+	shellGetoptsVar = regexp.MustCompile(`\bgetopts\s+\S+\s+([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// envRef 文本中的一处变量引用
+type envRef struct {
+	name string
+	line int
+}
+
+// CheckEnvReferences 静态检查配置中各步骤的字符串（脚本、env 的取值等）引用的环境变量，按步骤返回引用了未定义变量之处。
+// 已定义的变量为内置变量、引用的环境变量组与项目环境变量、前面步骤的输出 STEP_OUTPUT_<KEY> 与步骤自身的 env；
+// shell 自带的变量与脚本中赋值、作为循环变量的名称不报告，ignore 中的名称（以 * 结尾表示前缀）不检查
+func CheckEnvReferences(project *models.Project, config *models.PipelineConfig, ignore []string) []models.StepEnvFindings {
+	var envs []models.Environment
+	database.DB.Where("project_id = ?", project.ID).Find(&envs)
+	projectKeys := make(map[string]bool, len(envs))
+	for _, env := range envs {
+		projectKeys[env.Key] = true
+	}
+	for _, key := range sharedEnvKeys(config) {
+		projectKeys[key] = true
+	}
+
+	var results []models.StepEnvFindings
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			findings := checkStepEnvReferences(&step, projectKeys, ignore)
+			if len(findings) > 0 {
+				results = append(results, models.StepEnvFindings{Stage: stage.Name, Step: step.Name, Findings: findings})
+			}
+		}
+	}
+	return results
+}
+
+// EnvFindingProblems 将检查结果转换为逐条的问题描述，用于运行日志与错误提示
+func EnvFindingProblems(results []models.StepEnvFindings) []string {
+	var problems []string
+	for _, result := range results {
+		for _, finding := range result.Findings {
+			problems = append(problems, fmt.Sprintf("步骤 %s 的 %s 第 %d 行引用了未定义的环境变量 %s",
+				result.Step, finding.Field, finding.Line, finding.Name))
+		}
+	}
+	return problems
+}
+
+// checkStepEnvReferences 检查一个步骤，按字段名与行号排序返回，同一行对同一变量的多次引用只报告一次
+func checkStepEnvReferences(step *models.PipelineStep, projectKeys map[string]bool, ignore []string) []models.EnvReferenceFinding {
+	fields := make(map[string]string)
+	collectConfigStrings("", step.Config, fields)
+
+	local := make(map[string]bool)
+	if envVars, ok := step.Config["env"].(map[string]interface{}); ok {
+		for name := range envVars {
+			local[name] = true
+		}
+	}
+	for _, text := range fields {
+		for name := range shellLocalNames(text) {
+			local[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	var findings []models.EnvReferenceFinding
+	seen := make(map[models.EnvReferenceFinding]bool)
+	for _, field := range names {
+		for _, ref := range scanEnvRefs(fields[field]) {
+			if builtinEnvNames[ref.name] || shellEnvNames[ref.name] || projectKeys[ref.name] || local[ref.name] ||
+				strings.HasPrefix(ref.name, "STEP_OUTPUT_") || envIgnored(ref.name, ignore) {
+				continue
+			}
+			finding := models.EnvReferenceFinding{Name: ref.name, Field: field, Line: ref.line}
+			if !seen[finding] {
+				seen[finding] = true
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return findings
+}
+
+// collectConfigStrings 按字段路径收集配置中的全部字符串，路径形如 script、env.DEPLOY_URL、args[0]
+func collectConfigStrings(path string, value interface{}, out map[string]string) {
+	switch v := value.(type) {
+	case string:
+		out[path] = v
+	case map[string]interface{}:
+		for key, item := range v {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			collectConfigStrings(child, item, out)
+		}
+	case []interface{}:
+		for i, item := range v {
+			collectConfigStrings(fmt.Sprintf("%s[%d]", path, i), item, out)
+		}
+	}
+}
+
+// shellLocalNames 脚本中自己定义的变量名。只是启发式的匹配，宁可漏报也不误报
+func shellLocalNames(text string) map[string]bool {
+	names := make(map[string]bool)
+	for _, pattern := range []*regexp.Regexp{shellAssignment, shellLoopVar, shellGetoptsVar} {
+		for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+			names[match[1]] = true
+		}
+	}
+	for _, match := range shellReadVars.FindAllStringSubmatch(text, -1) {
+		for _, word := range strings.Fields(match[1]) {
+			if !strings.HasPrefix(word, "-") && models.IsValidEnvName(word) {
+				names[word] = true
+			}
+		}
+	}
+	return names
+}
+
+// scanEnvRefs 找出文本中的 $NAME 与 ${NAME} 引用，按 shell 的规则跳过单引号内、转义的 $ 与注释；
+// ${NAME:-默认值} 等带默认值的引用在变量未定义时也能工作，不报告。$?、$1 等特殊参数不是变量名，不会匹配
+func scanEnvRefs(text string) []envRef {
+	var refs []envRef
+	line := 1
+	inSingle, inDouble := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '\n':
+			line++
+		case inSingle:
+			if ch == '\'' {
+				inSingle = false
+			}
+		case ch == '\\':
+			if i+1 < len(text) {
+				i++
+				if text[i] == '\n' {
+					line++
+				}
+			}
+		case ch == '\'' && !inDouble:
+			inSingle = true
+		case ch == '"':
+			inDouble = !inDouble
+		case ch == '#' && !inDouble && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t' || text[i-1] == '\n'):
+			for i+1 < len(text) && text[i+1] != '\n' {
+				i++
+			}
+		case ch == '$':
+			name, withDefault, end := parseEnvRef(text, i+1)
+			if name != "" && !withDefault {
+				refs = append(refs, envRef{name: name, line: line})
+			}
+			i = end - 1
+		}
+	}
+	return refs
+}
+
+// parseEnvRef 解析 $ 之后的变量名，返回变量名、是否带默认值（:-、:=、:+ 及不带冒号的形式）与变量名结束的位置
+func parseEnvRef(text string, start int) (name string, withDefault bool, end int) {
+	i := start
+	braced := i < len(text) && text[i] == '{'
+	if braced {
+		i++
+		if i < len(text) && text[i] == '#' {
+			i++
+		}
+	}
+
+	nameStart := i
+	for i < len(text) {
+		ch := text[i]
+		if ch == '_' || ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' && i > nameStart {
+			i++
+			continue
+		}
+		break
+	}
+	if i == nameStart {
+		return "", false, start
+	}
+
+	name = text[nameStart:i]
+	if braced {
+		rest := strings.TrimPrefix(text[i:], ":")
+		withDefault = rest != "" && strings.ContainsRune("-=+", rune(rest[0]))
+	}
+	return name, withDefault, i
+}
+
+// envIgnored 变量是否在忽略列表中，以 * 结尾的项按前缀匹配
+func envIgnored(name string, ignore []string) bool {
+	for _, item := range ignore {
+		if prefix, ok := strings.CutSuffix(item, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if item == name {
+			return true
+		}
+	}
+	return false
+}
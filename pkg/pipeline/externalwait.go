@@ -0,0 +1,473 @@
+package pipeline
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/models"
+	"flowforge/pkg/retry"
+	"flowforge/pkg/utils"
+)
+
+// CallbackSignatureHeader 回调请求体签名所在的请求头，值为 "sha256=" 加请求体 HMAC-SHA256 的十六进制摘要
+const CallbackSignatureHeader = "X-FlowForge-Signature"
+
+const (
+	// defaultExternalWaitTimeout 未配置 timeout 时等待外部回调的时长
+	defaultExternalWaitTimeout = time.Hour
+	// maxExternalWaitTimeout 等待外部回调的时长上限
+	maxExternalWaitTimeout = 7 * 24 * time.Hour
+	// externalRequestTimeout 发起外部作业请求的超时
+	externalRequestTimeout = 30 * time.Second
+	// maxExternalRequestRetries 外部作业请求的重试次数上限
+	maxExternalRequestRetries = 10
+)
+
+var (
+	// ErrExternalWaitNotFound 外部等待不存在
+	ErrExternalWaitNotFound = errors.New("外部等待不存在")
+	// ErrExternalWaitFinished 外部等待已经结束（已回调、超时或已取消）
+	ErrExternalWaitFinished = errors.New("外部等待已结束")
+	// ErrCallbackSignature 回调请求签名缺失或不匹配
+	ErrCallbackSignature = errors.New("回调签名校验失败")
+)
+
+// executeExternalWait 执行 external_wait 步骤：生成回调令牌，按需发起外部作业请求，然后等待回调或超时。
+// 进程重启后恢复的运行直接继续等待已持久化的记录，不会重复发起请求
+func (e *Engine) executeExternalWait(jobCtx *JobContext, step *models.PipelineStep) error {
+	record := jobCtx.currentStep
+
+	wait, resumed := jobCtx.resumeWait, true
+	if wait == nil || wait.PipelineStepID != record.ID {
+		created, err := e.createExternalWait(jobCtx, step, record)
+		if err != nil {
+			return err
+		}
+		wait, resumed = created, false
+	}
+	jobCtx.resumeWait = nil
+
+	// 先登记再发起请求，外部系统立即回调时也不会丢失通知
+	signal := e.registerExternalWait(wait.ID)
+	defer e.unregisterExternalWait(wait.ID)
+
+	if request, ok := step.Config["request"].(map[string]interface{}); ok && !resumed {
+		if err := e.sendExternalRequest(jobCtx, wait, request); err != nil {
+			e.settleExternalWait(wait.ID, models.ExternalWaitFailed, "request", err.Error(), nil)
+			return err
+		}
+	}
+
+	database.DB.Model(record).Update("status", models.StepStatusWaitingExternal)
+	e.logf(jobCtx, "log.external_wait_started", wait.Description, e.CallbackURL(wait.Token), wait.TimeoutAt.Format("2006-01-02 15:04:05"))
+
+	for {
+		var current models.ExternalWait
+		if err := database.DB.First(&current, wait.ID).Error; err != nil {
+			return fmt.Errorf("获取外部等待状态失败: %w", err)
+		}
+		if !current.IsWaiting() {
+			return e.finishExternalWait(jobCtx, record, &current)
+		}
+
+		timer := time.NewTimer(time.Until(current.TimeoutAt))
+		select {
+		case <-signal:
+		case <-timer.C:
+			e.settleExternalWait(current.ID, models.ExternalWaitTimedOut, "timeout", "等待外部回调超时", nil)
+		case <-jobCtx.stepContext().Done():
+			timer.Stop()
+			// 运行未取消时为步骤超时
+			if jobCtx.Context.Err() == nil {
+				e.settleExternalWait(current.ID, models.ExternalWaitTimedOut, "timeout", "步骤执行超时", nil)
+			} else {
+				e.settleExternalWait(current.ID, models.ExternalWaitCancelled, "cancel", "流水线运行已取消", nil)
+			}
+			return jobCtx.stepContext().Err()
+		}
+		timer.Stop()
+	}
+}
+
+// createExternalWait 创建外部等待记录
+func (e *Engine) createExternalWait(jobCtx *JobContext, step *models.PipelineStep, record *models.PipelineStep) (*models.ExternalWait, error) {
+	timeout := defaultExternalWaitTimeout
+	if seconds, ok := step.Config["timeout"].(float64); ok && seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout > maxExternalWaitTimeout {
+		timeout = maxExternalWaitTimeout
+	}
+
+	description, _ := step.Config["description"].(string)
+	if description == "" {
+		description = step.Name
+	}
+	// 外部系统预先配置了签名密钥时使用步骤配置的密钥，否则为本次等待生成
+	secret, _ := step.Config["secret"].(string)
+	if secret == "" {
+		secret = utils.GenerateRandomString(32)
+	}
+
+	wait := &models.ExternalWait{
+		Token:          utils.GenerateRandomString(40),
+		Secret:         secret,
+		Description:    description,
+		Status:         models.ExternalWaitWaiting,
+		TimeoutAt:      time.Now().Add(timeout),
+		PipelineRunID:  jobCtx.PipelineRun.ID,
+		PipelineStepID: record.ID,
+		StepOrder:      record.StepOrder,
+	}
+	if err := database.DB.Create(wait).Error; err != nil {
+		return nil, fmt.Errorf("创建外部等待失败: %w", err)
+	}
+	return wait, nil
+}
+
+// sendExternalRequest 发起外部作业请求；url、请求头与请求体中的 {{callback_url}}、{{callback_token}}、
+// {{callback_secret}}、{{run_id}} 会被替换，响应不是 2xx 时步骤失败。retries 指定连接失败、超时、限流
+// 与 5xx 时的重试次数，默认不重试；目标主机持续失败时熔断，不再发起请求
+func (e *Engine) sendExternalRequest(jobCtx *JobContext, wait *models.ExternalWait, request map[string]interface{}) error {
+	replacer := strings.NewReplacer(
+		"{{callback_url}}", e.CallbackURL(wait.Token),
+		"{{callback_token}}", wait.Token,
+		"{{callback_secret}}", wait.Secret,
+		"{{run_id}}", fmt.Sprintf("%d", jobCtx.PipelineRun.ID),
+	)
+
+	url, _ := request["url"].(string)
+	if url == "" {
+		return fmt.Errorf("外部作业请求地址不能为空")
+	}
+	method, _ := request["method"].(string)
+	if method == "" {
+		method = http.MethodPost
+	}
+	body, _ := request["body"].(string)
+
+	target := replacer.Replace(url)
+	if err := httpclient.ValidateURL(target, jobCtx.Project.ID); err != nil {
+		return fmt.Errorf("外部作业请求地址无效: %w", err)
+	}
+
+	policy := retry.Default()
+	policy.Attempts = 1
+	if retries, ok := request["retries"].(float64); ok && retries > 0 {
+		policy.Attempts = int(math.Min(retries, maxExternalRequestRetries)) + 1
+	}
+
+	// 请求按项目的出站例外检查，连接时再校验解析出的地址
+	ctx := httpclient.WithProject(jobCtx.stepContext(), jobCtx.Project.ID)
+	payload := replacer.Replace(body)
+	return retry.Do(ctx, retry.Host(target), policy, func() error {
+		req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), target, strings.NewReader(payload))
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("创建外部作业请求失败: %w", err))
+		}
+		if headers, ok := request["headers"].(map[string]interface{}); ok {
+			for key, value := range headers {
+				if str, ok := value.(string); ok {
+					req.Header.Set(key, replacer.Replace(str))
+				}
+			}
+		}
+		if body != "" && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := httpclient.New(externalRequestTimeout).Do(req)
+		if err != nil {
+			return fmt.Errorf("发起外部作业请求失败: %w", err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+		e.logf(jobCtx, "log.external_wait_request", req.Method, req.URL.Redacted(), resp.StatusCode)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err := fmt.Errorf("外部作业请求返回状态 %d", resp.StatusCode)
+			if !retry.Retryable(resp.StatusCode) {
+				return retry.Permanent(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// ValidateExternalRequests 保存流水线时校验 external_wait 步骤的请求地址与重试次数；
+// 主机名包含 {{...}} 占位符的地址在运行时替换后再校验
+func ValidateExternalRequests(project *models.Project, config *models.PipelineConfig) []string {
+	if config == nil {
+		return nil
+	}
+
+	var problems []string
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			if step.Type != "external_wait" {
+				continue
+			}
+			request, ok := step.Config["request"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if value, ok := request["retries"]; ok {
+				if retries, ok := value.(float64); !ok || retries < 0 || retries > maxExternalRequestRetries || retries != math.Trunc(retries) {
+					problems = append(problems, fmt.Sprintf("步骤 %s 的请求重试次数应为 0 到 %d 的整数", step.Name, maxExternalRequestRetries))
+				}
+			}
+			target, _ := request["url"].(string)
+			if target == "" {
+				continue
+			}
+			if templatedHost(target) {
+				continue
+			}
+			if err := httpclient.ValidateURL(target, project.ID); err != nil {
+				problems = append(problems, fmt.Sprintf("步骤 %s 的请求地址无效: %v", step.Name, err))
+			}
+		}
+	}
+	return problems
+}
+
+// templatedHost 地址的协议或主机名部分是否包含占位符
+func templatedHost(target string) bool {
+	authority := target
+	if i := strings.Index(authority, "://"); i >= 0 {
+		if strings.Contains(authority[:i], "{{") {
+			return true
+		}
+		authority = authority[i+3:]
+	}
+	if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+		authority = authority[:i]
+	}
+	return strings.Contains(authority, "{{")
+}
+
+// finishExternalWait 按等待结果结束步骤：成功时保存回调携带的输出供后续步骤使用
+func (e *Engine) finishExternalWait(jobCtx *JobContext, record *models.PipelineStep, wait *models.ExternalWait) error {
+	e.logf(jobCtx, "log.external_wait_done", wait.Status, wait.CompletedBy, wait.Message)
+
+	switch wait.Status {
+	case models.ExternalWaitSucceeded:
+		if wait.Outputs != "" {
+			var outputs map[string]string
+			if err := json.Unmarshal([]byte(wait.Outputs), &outputs); err == nil {
+				if jobCtx.Outputs == nil {
+					jobCtx.Outputs = make(map[string]string)
+				}
+				for key, value := range outputs {
+					jobCtx.Outputs[key] = value
+				}
+			}
+			database.DB.Model(record).Update("outputs", wait.Outputs)
+		}
+		return nil
+	case models.ExternalWaitTimedOut:
+		return fmt.Errorf("等待外部回调超时: %s", wait.Description)
+	case models.ExternalWaitCancelled:
+		return fmt.Errorf("外部等待已取消: %s", wait.Message)
+	default:
+		if wait.Message != "" {
+			return fmt.Errorf("外部作业失败: %s", wait.Message)
+		}
+		return fmt.Errorf("外部作业失败")
+	}
+}
+
+// settleExternalWait 结束仍在等待的外部等待，已结束时不做修改并返回 false；成功时通知等待中的步骤
+func (e *Engine) settleExternalWait(id uint, status, completedBy, message string, outputs map[string]string) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       status,
+		"completed_at": &now,
+		"completed_by": completedBy,
+		"message":      message,
+	}
+	if len(outputs) > 0 {
+		data, err := json.Marshal(outputs)
+		if err != nil {
+			return false, fmt.Errorf("序列化步骤输出失败: %w", err)
+		}
+		updates["outputs"] = string(data)
+	}
+
+	result := database.DB.Model(&models.ExternalWait{}).
+		Where("id = ? AND status = ?", id, models.ExternalWaitWaiting).Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("更新外部等待失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	e.notifyExternalWait(id)
+	return true, nil
+}
+
+// ResolveExternalWait 按回调结果结束外部等待。重复回调是幂等的：等待已结束时返回当前记录且 applied 为 false
+func (e *Engine) ResolveExternalWait(id uint, result models.ExternalCallbackRequest, completedBy string) (*models.ExternalWait, bool, error) {
+	status := models.ExternalWaitFailed
+	if result.Status == "success" {
+		status = models.ExternalWaitSucceeded
+	}
+
+	applied, err := e.settleExternalWait(id, status, completedBy, result.Message, result.Outputs)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var wait models.ExternalWait
+	if err := database.DB.First(&wait, id).Error; err != nil {
+		return nil, false, ErrExternalWaitNotFound
+	}
+	return &wait, applied, nil
+}
+
+// ExternalWaitByToken 按回调令牌获取外部等待
+func (e *Engine) ExternalWaitByToken(token string) (*models.ExternalWait, error) {
+	var wait models.ExternalWait
+	if token == "" || database.DB.Where("token = ?", token).First(&wait).Error != nil {
+		return nil, ErrExternalWaitNotFound
+	}
+	return &wait, nil
+}
+
+// VerifyCallback 校验回调请求体的 HMAC-SHA256 签名
+func VerifyCallback(wait *models.ExternalWait, signature string, body []byte) error {
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if signature == "" || err != nil {
+		return ErrCallbackSignature
+	}
+	mac := hmac.New(sha256.New, []byte(wait.Secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrCallbackSignature
+	}
+	return nil
+}
+
+// CallbackURL 外部系统回调的完整地址
+func (e *Engine) CallbackURL(token string) string {
+	return fmt.Sprintf("%s/api/v1/callbacks/%s", strings.TrimRight(e.config.Notify.BaseURL, "/"), token)
+}
+
+// registerExternalWait 登记本实例中等待回调的步骤
+func (e *Engine) registerExternalWait(id uint) chan struct{} {
+	signal := make(chan struct{}, 1)
+	e.mu.Lock()
+	e.externalWaits[id] = signal
+	e.mu.Unlock()
+	return signal
+}
+
+// unregisterExternalWait 取消登记
+func (e *Engine) unregisterExternalWait(id uint) {
+	e.mu.Lock()
+	delete(e.externalWaits, id)
+	e.mu.Unlock()
+}
+
+// notifyExternalWait 通知等待中的步骤重新读取等待状态
+func (e *Engine) notifyExternalWait(id uint) {
+	e.mu.RLock()
+	signal, ok := e.externalWaits[id]
+	e.mu.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case signal <- struct{}{}:
+	default:
+	}
+}
+
+// resumedStep 恢复外部等待的运行执行到等待中的步骤时，返回已有的步骤记录；
+// 流水线配置已变更、该位置不再是原来的 external_wait 步骤时结束等待并返回错误
+func (e *Engine) resumedStep(jobCtx *JobContext, step *models.PipelineStep) (*models.PipelineStep, error) {
+	wait := jobCtx.resumeWait
+	if wait == nil || wait.StepOrder != jobCtx.stepOrder {
+		return nil, nil
+	}
+
+	var record models.PipelineStep
+	if err := database.DB.First(&record, wait.PipelineStepID).Error; err != nil || step.Type != "external_wait" || record.Name != step.Name {
+		jobCtx.resumeWait = nil
+		e.settleExternalWait(wait.ID, models.ExternalWaitCancelled, "resume", "流水线配置已变更", nil)
+		return nil, fmt.Errorf("流水线配置已变更，无法恢复外部等待")
+	}
+	return &record, nil
+}
+
+// ResumeExternalWaits 进程启动时恢复等待外部回调的运行：跳过已完成的步骤，从等待中的步骤继续等待。
+// 运行已结束或无法加载流水线的等待直接结束
+func (e *Engine) ResumeExternalWaits() {
+	var waits []models.ExternalWait
+	if err := database.DB.Where("status = ?", models.ExternalWaitWaiting).Order("id ASC").Find(&waits).Error; err != nil {
+		log.Printf("查询外部等待失败: %v", err)
+		return
+	}
+
+	for i := range waits {
+		wait := &waits[i]
+
+		var run models.PipelineRun
+		if err := database.DB.First(&run, wait.PipelineRunID).Error; err != nil || run.Status != models.RunStatusRunning {
+			e.settleExternalWait(wait.ID, models.ExternalWaitCancelled, "resume", "流水线运行已结束", nil)
+			continue
+		}
+
+		var pipeline models.Pipeline
+		if err := models.WithPrivateKey(database.DB).Preload("Project").Preload("Project.DeployKey").Preload("Project.SSHKey").First(&pipeline, run.PipelineID).Error; err != nil {
+			e.settleExternalWait(wait.ID, models.ExternalWaitCancelled, "resume", "流水线不存在", nil)
+			e.markRunFailed(run.ID, fmt.Sprintf("恢复外部等待失败: %v", err))
+			continue
+		}
+
+		jobCtx := newJobContext(&pipeline, &run, nil, "")
+		jobCtx.resumeWait = wait
+		jobCtx.mutexGroup = loadConcurrencyPolicy(jobCtx).mutexGroup
+
+		e.mu.Lock()
+		e.runningJobs[run.ID] = jobCtx
+		e.mu.Unlock()
+		go e.runJob(jobCtx)
+
+		log.Printf("流水线运行 %d 恢复等待外部回调: %s", run.ID, wait.Description)
+	}
+}
+
+// ExternalWaits 列出仍在等待外部回调的步骤
+func (e *Engine) ExternalWaits() ([]models.ExternalWait, error) {
+	var waits []models.ExternalWait
+	if err := database.DB.Where("status = ?", models.ExternalWaitWaiting).Order("created_at ASC").Find(&waits).Error; err != nil {
+		return nil, fmt.Errorf("查询外部等待失败: %w", err)
+	}
+	return waits, nil
+}
+
+// outputEnvName 步骤输出对应的环境变量名，如 job_id 对应 STEP_OUTPUT_JOB_ID
+func outputEnvName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+	return "STEP_OUTPUT_" + strings.ToUpper(name)
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// heartbeatInterval 运行心跳的写入间隔
+const heartbeatInterval = 15 * time.Second
+
+// heartbeat 运行心跳：定期为本实例内存中存活的运行更新 last_heartbeat_at
+type heartbeat struct {
+	lastSuccess int64 // 最近一次心跳写入成功的时间（UnixNano）
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// startHeartbeat 启动心跳协程
+func (e *Engine) startHeartbeat() {
+	e.heartbeat = &heartbeat{
+		lastSuccess: time.Now().UnixNano(),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go func() {
+		defer close(e.heartbeat.done)
+
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				e.beat()
+			case <-e.heartbeat.stop:
+				return
+			}
+		}
+	}()
+}
+
+// beat 批量更新存活运行与排队中运行的心跳时间
+func (e *Engine) beat() {
+	e.mu.RLock()
+	ids := make([]uint, 0, len(e.runningJobs)+len(e.queue.items))
+	for runID, jobCtx := range e.runningJobs {
+		if jobCtx.isAlive() {
+			ids = append(ids, runID)
+		}
+	}
+	for _, item := range e.queue.items {
+		ids = append(ids, item.jobCtx.PipelineRun.ID)
+	}
+	e.mu.RUnlock()
+
+	if len(ids) > 0 && database.DB != nil {
+		if err := database.DB.Model(&models.PipelineRun{}).Where("id IN ?", ids).
+			Update("last_heartbeat_at", time.Now()).Error; err != nil {
+			log.Printf("更新流水线运行心跳失败: %v", err)
+			return
+		}
+	}
+
+	atomic.StoreInt64(&e.heartbeat.lastSuccess, time.Now().UnixNano())
+}
+
+// stopHeartbeat 停止心跳协程
+func (e *Engine) stopHeartbeat() {
+	select {
+	case <-e.heartbeat.stop:
+	default:
+		close(e.heartbeat.stop)
+	}
+	<-e.heartbeat.done
+}
+
+// FailStaleRuns 看门狗：将心跳超时且不在本实例内存中的运行与排队运行标记为失败（执行器丢失）；
+// 等待执行器领取的运行没有心跳，不做判断
+func (e *Engine) FailStaleRuns() {
+	threshold := time.Duration(e.config.Deploy.HeartbeatTimeout) * time.Second
+
+	// 关闭排空期间心跳已停止，不做判断
+	if atomic.LoadInt32(&e.shuttingDown) == 1 {
+		return
+	}
+
+	// 本实例心跳写入失败（数据库降级）时，数据库中的心跳时间不可信
+	if time.Since(time.Unix(0, atomic.LoadInt64(&e.heartbeat.lastSuccess))) > heartbeatInterval*2 {
+		log.Println("运行心跳写入异常，跳过失联运行检查")
+		return
+	}
+	if err := database.HealthCheck(); err != nil {
+		log.Printf("数据库不可用，跳过失联运行检查: %v", err)
+		return
+	}
+
+	deadline := time.Now().Add(-threshold)
+	var runs []models.PipelineRun
+	if err := database.DB.Select("id").
+		Where("status IN ?", []string{models.RunStatusRunning, models.RunStatusPending}).
+		Where("awaiting_worker = ?", false).
+		Where("(last_heartbeat_at IS NULL AND start_time < ?) OR last_heartbeat_at < ?", deadline, deadline).
+		Find(&runs).Error; err != nil {
+		log.Printf("查询失联运行失败: %v", err)
+		return
+	}
+
+	for _, run := range runs {
+		e.mu.RLock()
+		_, inMemory := e.runningJobs[run.ID]
+		if e.queue.index(run.ID) >= 0 {
+			inMemory = true
+		}
+		e.mu.RUnlock()
+		if inMemory {
+			continue
+		}
+
+		log.Printf("流水线运行 %d 心跳超时，标记为失败", run.ID)
+		e.markRunFailed(run.ID, fmt.Sprintf("executor lost: 心跳超过 %v 未更新", threshold))
+		database.DB.Model(&models.PipelineRun{}).Where("id = ?", run.ID).Update("failure_kind", models.FailureKindInfra)
+	}
+}
@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+var (
+	// ErrInputsUnavailable 原运行没有记录使用的流水线版本（功能上线前的运行），无法按原始输入重新运行
+	ErrInputsUnavailable = errors.New("原运行没有记录使用的流水线版本，无法按原始输入重跑")
+	// ErrRetrySkipped skipped 运行没有执行过，没有可以重新运行的内容
+	ErrRetrySkipped = errors.New("被跳过的运行不能重新运行")
+)
+
+// latestRevision 流水线当前的版本号，没有版本时为 0
+func latestRevision(pipelineID uint) int {
+	var latest int
+	if err := database.DB.Model(&models.PipelineRevision{}).Where("pipeline_id = ?", pipelineID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&latest).Error; err != nil {
+		return 0
+	}
+	return latest
+}
+
+// pinRevision 将流水线的配置替换为指定版本的内容，使运行不受之后保存的修改影响
+func pinRevision(pipeline *models.Pipeline, revision int) error {
+	var saved models.PipelineRevision
+	if err := database.DB.Where("pipeline_id = ? AND revision = ?", pipeline.ID, revision).First(&saved).Error; err != nil {
+		return fmt.Errorf("%w: 流水线版本 %d 不存在", ErrInputsUnavailable, revision)
+	}
+	pipeline.Config = saved.Config
+	pipeline.ConfigSource = saved.ConfigSource
+	pipeline.ConfigPath = saved.ConfigPath
+	return nil
+}
+
+// snapshotInputs 运行使用的输入：仓库配置记录文件路径与提交，保存的配置记录流水线版本号，
+// 之后是解析配置时使用的共享资源及其版本
+func snapshotInputs(jobCtx *JobContext) []models.RunInput {
+	var inputs []models.RunInput
+	if repo := jobCtx.RepoConfig; repo != nil {
+		inputs = append(inputs, models.RunInput{Kind: models.RunInputRepoConfig, Name: repo.Path, Version: repo.Commit})
+	} else if jobCtx.PipelineRun.PipelineRevision > 0 {
+		inputs = append(inputs, models.RunInput{
+			Kind:    models.RunInputPipelineConfig,
+			Name:    jobCtx.Pipeline.Name,
+			Version: strconv.Itoa(jobCtx.PipelineRun.PipelineRevision),
+		})
+	}
+	return append(inputs, jobCtx.sharedInputs...)
+}
+
+// RunInputs 运行引用的输入及实际使用的版本，优先读取配置快照中的记录；
+// 没有快照的运行（如开始前被取消、快照已被保留策略清空）按记录的流水线版本号与共享资源版本返回，run.Pipeline 需已加载
+func RunInputs(run *models.PipelineRun) []models.RunInput {
+	var inputs []models.RunInput
+	if raw, err := DecodeResolvedConfig(run); err == nil {
+		var snapshot struct {
+			Inputs []models.RunInput `json:"inputs"`
+		}
+		if json.Unmarshal(raw, &snapshot) == nil {
+			inputs = snapshot.Inputs
+		}
+	}
+	if inputs == nil {
+		if run.PipelineRevision > 0 {
+			inputs = []models.RunInput{{Kind: models.RunInputPipelineConfig, Name: run.Pipeline.Name, Version: strconv.Itoa(run.PipelineRevision)}}
+		}
+		var recorded []models.RunResourceVersion
+		database.DB.Where("pipeline_run_id = ?", run.ID).Order("id").Find(&recorded)
+		for _, item := range recorded {
+			inputs = append(inputs, models.RunInput{Kind: item.Kind, Name: item.Name, Version: strconv.Itoa(item.Version), ResourceID: item.ResourceID})
+		}
+	}
+
+	for i := range inputs {
+		switch {
+		case inputs[i].Kind == models.RunInputPipelineConfig:
+			inputs[i].URL = fmt.Sprintf("/api/v1/pipelines/%d/revisions/%s", run.PipelineID, inputs[i].Version)
+		case inputs[i].ResourceID != 0:
+			inputs[i].URL = SharedResourceURL(inputs[i].ResourceID, inputs[i].Version)
+		}
+	}
+	return inputs
+}
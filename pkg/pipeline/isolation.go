@@ -0,0 +1,16 @@
+package pipeline
+
+import "flowforge/pkg/isolation"
+
+// workspaceDir 项目的代码工作区，隔离开启时位于项目自己的目录下
+func (e *Engine) workspaceDir(projectID uint) string {
+	return isolation.WorkspaceDir(e.config.App.DataPath, projectID)
+}
+
+// runAs 隔离开启时本机执行的脚本使用的项目系统用户，首次使用时创建；未开启时返回 nil
+func (e *Engine) runAs(jobCtx *JobContext) (*isolation.Account, error) {
+	if !isolation.Enabled() {
+		return nil, nil
+	}
+	return isolation.ProjectAccount(jobCtx.db(), e.config.App.DataPath, jobCtx.Project)
+}
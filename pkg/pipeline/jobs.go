@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// JobInfo 内存中任务的概要信息
+type JobInfo struct {
+	RunID        uint      `json:"run_id"`
+	PipelineID   uint      `json:"pipeline_id"`
+	PipelineName string    `json:"pipeline_name"`
+	ProjectID    uint      `json:"project_id"`
+	StartedAt    time.Time `json:"started_at"`
+	AgeSeconds   int64     `json:"age_seconds"`
+	Alive        bool      `json:"alive"`
+}
+
+// runJob 任务的唯一所有者：无论正常结束、出错还是panic，都保证移除任务并关闭日志通道
+func (e *Engine) runJob(jobCtx *JobContext) {
+	defer func() {
+		// 释放对大对象的引用；看门狗释放的任务协程可能仍在读取，只在这里清空
+		jobCtx.ReuseSteps = nil
+		e.releaseJob(jobCtx)
+	}()
+	defer close(jobCtx.exited)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("流水线运行 %d 发生panic: %v\n%s", jobCtx.PipelineRun.ID, r, debug.Stack())
+			e.markRunFailed(jobCtx.PipelineRun.ID, fmt.Sprintf("流水线执行异常: %v", r))
+		}
+	}()
+
+	jobCtx.engine = e
+	jobCtx.ingest = newRunLogIngest()
+	e.executePipeline(jobCtx)
+}
+
+// releaseJob 从运行中任务移除并关闭日志通道，可重复调用
+func (e *Engine) releaseJob(jobCtx *JobContext) {
+	e.mu.Lock()
+	if current, ok := e.runningJobs[jobCtx.PipelineRun.ID]; ok && current == jobCtx {
+		delete(e.runningJobs, jobCtx.PipelineRun.ID)
+	}
+	e.mu.Unlock()
+
+	jobCtx.Cancel()
+	if jobCtx.deadline != nil {
+		jobCtx.deadline.Stop()
+	}
+
+	jobCtx.logMu.Lock()
+	if !jobCtx.closed {
+		jobCtx.closed = true
+		close(jobCtx.LogChan)
+	}
+	jobCtx.logMu.Unlock()
+
+	// 空出的并发交给排队的运行
+	e.dispatchQueued()
+}
+
+// markRunFailed 在无法正常结束时直接将运行记录标记为失败
+func (e *Engine) markRunFailed(runID uint, message string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("标记流水线运行 %d 失败时发生panic: %v", runID, r)
+		}
+	}()
+
+	now := time.Now()
+	if err := database.DB.Model(&models.PipelineRun{}).Where("id = ? AND status IN ?", runID, []string{models.RunStatusRunning, models.RunStatusPending}).
+		Updates(map[string]interface{}{
+			"status":    models.RunStatusFailed,
+			"end_time":  &now,
+			"error_msg": message,
+		}).Error; err != nil {
+		log.Printf("标记流水线运行 %d 失败出错: %v", runID, err)
+	}
+}
+
+// ListJobs 列出内存中的任务及其存活时间，用于排查泄漏
+func (e *Engine) ListJobs() []JobInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	now := time.Now()
+	jobs := make([]JobInfo, 0, len(e.runningJobs))
+	for runID, jobCtx := range e.runningJobs {
+		jobs = append(jobs, JobInfo{
+			RunID:        runID,
+			PipelineID:   jobCtx.Pipeline.ID,
+			PipelineName: jobCtx.Pipeline.Name,
+			ProjectID:    jobCtx.Project.ID,
+			StartedAt:    jobCtx.StartedAt,
+			AgeSeconds:   int64(now.Sub(jobCtx.StartedAt).Seconds()),
+			Alive:        jobCtx.isAlive(),
+		})
+	}
+	return jobs
+}
+
+// leakGrace 运行取消后等待执行协程退出的时间，超过后看门狗不再等待，直接释放任务
+const leakGrace = time.Minute
+
+// CollectLeakedJobs 看门狗：强制清理执行协程已退出但仍在 runningJobs 中的任务，以及取消后超过 leakGrace 仍未退出的任务，
+// 释放它们占用的并发并将仍处于运行中的记录标记为失败；未取消而运行过久的任务只提示，其步骤受步骤超时限制
+func (e *Engine) CollectLeakedJobs() {
+	maxAge := time.Duration(e.config.Deploy.Timeout) * time.Second * 2
+	now := time.Now()
+
+	var leaked []*JobContext
+	e.mu.Lock()
+	for runID, jobCtx := range e.runningJobs {
+		switch {
+		case !jobCtx.isAlive():
+			leaked = append(leaked, jobCtx)
+		case jobCtx.Context.Err() != nil:
+			if jobCtx.stuckSince.IsZero() {
+				jobCtx.stuckSince = now
+			} else if now.Sub(jobCtx.stuckSince) >= leakGrace {
+				log.Printf("流水线运行 %d 取消后 %v 仍未退出", runID, now.Sub(jobCtx.stuckSince).Round(time.Second))
+				leaked = append(leaked, jobCtx)
+			}
+		case maxAge > 0 && now.Sub(jobCtx.StartedAt) > maxAge:
+			log.Printf("流水线运行 %d 已运行 %v，超过预期时长", runID, now.Sub(jobCtx.StartedAt).Round(time.Second))
+		}
+	}
+	e.mu.Unlock()
+
+	for _, jobCtx := range leaked {
+		log.Printf("清理泄漏的流水线任务: 运行 %d", jobCtx.PipelineRun.ID)
+		e.releaseJob(jobCtx)
+		e.markRunFailed(jobCtx.PipelineRun.ID, "流水线任务异常退出")
+	}
+}
+
+// isAlive 执行协程是否仍在运行
+func (j *JobContext) isAlive() bool {
+	select {
+	case <-j.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// db 运行范围内的数据库操作：运行被取消后立即失败，取消的运行不再继续写入步骤记录、输出等附带数据
+func (j *JobContext) db() *gorm.DB {
+	return database.DB.WithContext(j.Context)
+}
+
+// detached 不随运行取消的上下文，用于取消后仍需完成的最终状态写入与事件发布
+func (j *JobContext) detached() context.Context {
+	return context.WithoutCancel(j.Context)
+}
@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// blockRelease 关闭后放行 test_block 步骤
+var blockRelease = make(chan struct{})
+
+// panicStep 执行时 panic 的步骤类型
+type panicStep struct{}
+
+func (panicStep) Name() string       { return "test_panic" }
+func (panicStep) Schema() StepSchema { return StepSchema{AllowUnknown: true} }
+func (panicStep) Execute(context.Context, *JobContext, map[string]interface{}) (map[string]string, error) {
+	panic("步骤崩溃")
+}
+
+// blockStep 忽略运行取消、一直阻塞到 blockRelease 关闭的步骤类型，模拟卡住的执行器
+type blockStep struct{}
+
+func (blockStep) Name() string       { return "test_block" }
+func (blockStep) Schema() StepSchema { return StepSchema{AllowUnknown: true} }
+func (blockStep) Execute(context.Context, *JobContext, map[string]interface{}) (map[string]string, error) {
+	<-blockRelease
+	return nil, nil
+}
+
+func init() {
+	RegisterStepType(panicStep{})
+	RegisterStepType(blockStep{})
+}
+
+// stepPipeline 单个步骤的流水线配置
+func stepPipeline(stepType string) string {
+	return "stages:\n  - name: build\n    steps:\n      - name: step1\n        type: " + stepType + "\n        config: {}\n"
+}
+
+// checkReleased 运行不再占用并发与脚本名额，协程数回到运行前
+func checkReleased(t *testing.T, e *Engine, runID uint, goroutines int) {
+	t.Helper()
+	if e.isRunning(runID) {
+		t.Errorf("运行 %d 仍在运行中任务里", runID)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		stats := e.ScriptStats()
+		if stats.ActiveExecutions == 0 && stats.ReaderGoroutines == 0 && stats.DeliveryGoroutines == 0 && runtime.NumGoroutine() <= goroutines {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("运行结束后仍有协程或脚本名额未释放: %+v，协程 %d > %d\n%s", stats, runtime.NumGoroutine(), goroutines, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestPanickingStepReleasesJob 步骤 panic 时运行标记为失败，任务与协程都被释放
+func TestPanickingStepReleasesJob(t *testing.T) {
+	e, project := setupEngineTest(t)
+	pipeline := createPipeline(t, project, "panic", stepPipeline("test_panic"))
+	goroutines := runtime.NumGoroutine()
+
+	run := waitRun(t, e, startRun(t, e, pipeline).ID)
+	if run.Status != models.RunStatusFailed || !strings.Contains(run.ErrorMsg, "步骤崩溃") {
+		t.Errorf("panic 的运行应失败并记录原因，实际为 %s: %s", run.Status, run.ErrorMsg)
+	}
+	checkReleased(t, e, run.ID, goroutines)
+}
+
+// TestCancelledRunReleasesJob 取消执行中脚本的运行后，脚本进程、任务与协程都被释放
+func TestCancelledRunReleasesJob(t *testing.T) {
+	e, project := setupEngineTest(t)
+	pipeline := createPipeline(t, project, "sleep", scriptPipeline("sleep 30"))
+	goroutines := runtime.NumGoroutine()
+
+	run := startRun(t, e, pipeline)
+	waitStep(t, run.ID, 1)
+	if err := e.CancelPipelineRun(run.ID, UserCancellation(testUserID, "")); err != nil {
+		t.Fatal(err)
+	}
+	if run := waitRun(t, e, run.ID); run.Status != models.RunStatusCancelled {
+		t.Errorf("运行状态 %s，应为 cancelled", run.Status)
+	}
+	checkReleased(t, e, run.ID, goroutines)
+}
+
+// TestCollectLeakedJobs 看门狗释放执行协程已退出的任务，以及取消后超过 leakGrace 仍未退出的任务，空出的并发交给排队的运行
+func TestCollectLeakedJobs(t *testing.T) {
+	e, project := setupEngineTest(t)
+	e.config.Deploy.MaxConcurrent = 1
+	stuck := createPipeline(t, project, "stuck", stepPipeline("test_block"))
+	next := createPipeline(t, project, "next", scriptPipeline("true"))
+
+	run := startRun(t, e, stuck)
+	waitStep(t, run.ID, 1)
+	e.mu.RLock()
+	jobCtx := e.runningJobs[run.ID]
+	e.mu.RUnlock()
+	queued := startRun(t, e, next)
+	waitQueued(t, e, queued.ID)
+
+	// 步骤忽略取消，运行记录已是取消状态但任务仍占着唯一的并发
+	if err := e.CancelPipelineRun(run.ID, UserCancellation(testUserID, "")); err != nil {
+		t.Fatal(err)
+	}
+	e.CollectLeakedJobs()
+	if !e.isRunning(run.ID) {
+		t.Fatal("刚取消的任务应等待 leakGrace 后再释放")
+	}
+	e.mu.Lock()
+	jobCtx.stuckSince = jobCtx.stuckSince.Add(-leakGrace)
+	e.mu.Unlock()
+	e.CollectLeakedJobs()
+	if e.isRunning(run.ID) {
+		t.Fatal("取消后超过 leakGrace 仍未退出的任务应被释放")
+	}
+	if run := waitRun(t, e, queued.ID); run.Status != models.RunStatusSuccess {
+		t.Errorf("释放的并发应交给排队的运行，实际状态 %s: %s", run.Status, run.ErrorMsg)
+	}
+
+	// 卡住的协程最终退出时不再影响已释放的任务与运行记录
+	close(blockRelease)
+	<-jobCtx.exited
+	if run := waitRun(t, e, run.ID); run.Status != models.RunStatusCancelled {
+		t.Errorf("运行状态 %s，应保持 cancelled", run.Status)
+	}
+
+	// 执行协程已退出却仍登记在运行中任务里的运行标记为失败
+	leaked := &models.PipelineRun{PipelineID: next.ID, Status: models.RunStatusRunning, TriggerType: models.TriggerManual, UserID: testUserID}
+	if err := database.DB.Create(leaked).Error; err != nil {
+		t.Fatal(err)
+	}
+	leakedCtx := newJobContext(next, leaked, nil, "")
+	close(leakedCtx.exited)
+	e.mu.Lock()
+	e.runningJobs[leaked.ID] = leakedCtx
+	e.mu.Unlock()
+	e.CollectLeakedJobs()
+	if run := waitRun(t, e, leaked.ID); run.Status != models.RunStatusFailed {
+		t.Errorf("执行协程已退出的运行状态 %s，应为 failed", run.Status)
+	}
+}
@@ -0,0 +1,484 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"flowforge/pkg/models"
+)
+
+// 建议性检查结果的严重程度
+const (
+	LintSeverityInfo     = "info"
+	LintSeverityWarning  = "warning"
+	LintSeverityCritical = "critical"
+)
+
+// 建议性检查规则ID，配置中以 # flowforge-lint-disable <规则ID> 忽略
+const (
+	LintInlineSecret  = "inline-secret"
+	LintCurlPipeShell = "curl-pipe-shell"
+	LintDeployTimeout = "deploy-no-timeout"
+	LintLatestTag     = "latest-image-tag"
+	LintUnnamedStep   = "unnamed-step"
+	LintAbsolutePath  = "absolute-path"
+	LintSudoShared    = "sudo-shared-runner"
+)
+
+// LintRule 一条建议性检查规则。检查不阻止保存，除非本实例将规则配置为阻止保存
+type LintRule struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Blocking    bool   `json:"blocking"`
+
+	check func(step *lintStep) []lintHit
+}
+
+// lintStep 检查中的一个步骤及其配置中的全部字符串字段
+type lintStep struct {
+	step         *models.PipelineStep
+	stageTimeout models.Timeout // 所在阶段的超时时间
+	fields       map[string]string
+	names        []string // 字段名，按字母顺序
+}
+
+// lintHit 规则在步骤中的一处命中
+type lintHit struct {
+	field   string
+	line    int
+	message string
+	fix     string
+}
+
+var lintRules = []LintRule{
+	{
+		ID:          LintInlineSecret,
+		Severity:    LintSeverityCritical,
+		Title:       "配置中的明文密钥",
+		Description: "脚本或变量中出现疑似密钥的高熵字符串或已知格式的令牌。应保存为项目环境变量（加密存储，日志中脱敏），在脚本中以 $NAME 引用",
+		check:       lintInlineSecrets,
+	},
+	{
+		ID:          LintCurlPipeShell,
+		Severity:    LintSeverityWarning,
+		Title:       "下载后直接交给 shell 执行",
+		Description: "curl ... | bash 执行的内容未经校验，来源被篡改或下载中断时会执行不完整或恶意的脚本。应先下载到文件并校验 sha256 后再执行",
+		check:       lintCurlPipeShell,
+	},
+	{
+		ID:          LintDeployTimeout,
+		Severity:    LintSeverityWarning,
+		Title:       "部署步骤没有设置超时",
+		Description: "部署目标无响应时步骤会一直占用部署锁与执行名额，直到运行整体超时。应为部署步骤设置 timeout，ssh 部署的部署后命令设置 command_timeout",
+		check:       lintDeployTimeouts,
+	},
+	{
+		ID:          LintLatestTag,
+		Severity:    LintSeverityWarning,
+		Title:       "使用 latest 镜像标签",
+		Description: "latest 或未指定标签的镜像随时可能变化，同一配置的两次运行可能使用不同的镜像。应固定到具体版本或摘要",
+		check:       lintLatestTags,
+	},
+	{
+		ID:          LintUnnamedStep,
+		Severity:    LintSeverityInfo,
+		Title:       "步骤没有名称",
+		Description: "没有名称的步骤在运行日志、时间线与通知中难以分辨",
+		check:       lintUnnamedSteps,
+	},
+	{
+		ID:          LintAbsolutePath,
+		Severity:    LintSeverityWarning,
+		Title:       "脚本使用工作区外的绝对路径",
+		Description: "脚本读写工作区外的绝对路径时依赖执行主机的目录结构，在其他执行器上失败，也可能影响同一主机上的其他项目。应使用工作区内的相对路径",
+		check:       lintAbsolutePaths,
+	},
+	{
+		ID:          LintSudoShared,
+		Severity:    LintSeverityWarning,
+		Title:       "共用执行器上使用 sudo",
+		Description: "执行器由所有项目共用，sudo 以 root 身份修改主机会影响其他项目的运行。应改用项目自己的依赖缓存或部署目标上的权限",
+		check:       lintSudo,
+	},
+}
+
+var (
+	lintMu       sync.RWMutex
+	lintBlocking = map[string]bool{}
+)
+
+// InitLint 设置升级为阻止保存的规则（deploy.lint_blocking_rules），启动时调用；规则ID不存在时返回错误
+func InitLint(blocking []string) error {
+	rules := make(map[string]bool, len(blocking))
+	for _, id := range blocking {
+		if lintRule(id) == nil {
+			return fmt.Errorf("未知的配置检查规则: %s", id)
+		}
+		rules[id] = true
+	}
+
+	lintMu.Lock()
+	defer lintMu.Unlock()
+	lintBlocking = rules
+	return nil
+}
+
+// LintRules 全部建议性检查规则，按规则的定义顺序返回
+func LintRules() []LintRule {
+	lintMu.RLock()
+	defer lintMu.RUnlock()
+
+	rules := make([]LintRule, len(lintRules))
+	for i, rule := range lintRules {
+		rule.Blocking = lintBlocking[rule.ID]
+		rule.check = nil
+		rules[i] = rule
+	}
+	return rules
+}
+
+// lintRule 按ID查找规则
+func lintRule(id string) *LintRule {
+	for i := range lintRules {
+		if lintRules[i].ID == id {
+			return &lintRules[i]
+		}
+	}
+	return nil
+}
+
+// lintDisablePattern 忽略规则的注释，规则ID以逗号或空格分隔
+var lintDisablePattern = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*flowforge-lint-disable[ \t]+([A-Za-z0-9_, \t-]+)$`)
+
+// lintSuppressions 文本中忽略的规则；configWide 为 true 时只识别顶格的注释，即配置文件本身的注释，
+// 不会把 YAML 块中缩进的脚本内容当作对整个配置的忽略
+func lintSuppressions(text string, configWide bool) map[string]bool {
+	rules := make(map[string]bool)
+	for _, match := range lintDisablePattern.FindAllStringSubmatch(text, -1) {
+		if configWide && !strings.HasPrefix(match[0], "#") {
+			continue
+		}
+		for _, id := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			rules[id] = true
+		}
+	}
+	return rules
+}
+
+// LintConfig 对保存的配置运行全部建议性检查，按阶段、步骤与规则的顺序返回结果。raw 为配置原文，
+// 其中顶格的 # flowforge-lint-disable <规则ID> 注释对整个配置生效；步骤的脚本等字段中的同样注释只对该步骤生效。
+// 被忽略的结果仍然返回并标记 suppressed，保存时记录被忽略的规则
+func LintConfig(raw string, config *models.PipelineConfig) []models.LintWarning {
+	lintMu.RLock()
+	blocking := lintBlocking
+	lintMu.RUnlock()
+
+	configSuppressed := lintSuppressions(raw, true)
+	var warnings []models.LintWarning
+	for _, stage := range config.Stages {
+		for i := range stage.Steps {
+			step := &lintStep{step: &stage.Steps[i], stageTimeout: stage.Timeout, fields: make(map[string]string)}
+			collectConfigStrings("", step.step.Config, step.fields)
+			for name := range step.fields {
+				step.names = append(step.names, name)
+			}
+			sort.Strings(step.names)
+
+			stepSuppressed := make(map[string]bool)
+			for _, name := range step.names {
+				for id := range lintSuppressions(step.fields[name], false) {
+					stepSuppressed[id] = true
+				}
+			}
+
+			for _, rule := range lintRules {
+				for _, hit := range rule.check(step) {
+					suppressed := configSuppressed[rule.ID] || stepSuppressed[rule.ID]
+					warnings = append(warnings, models.LintWarning{
+						Rule:       rule.ID,
+						Severity:   rule.Severity,
+						Stage:      stage.Name,
+						Step:       step.step.Name,
+						Index:      i + 1,
+						Field:      hit.field,
+						Line:       hit.line,
+						Message:    hit.message,
+						Fix:        hit.fix,
+						Blocking:   blocking[rule.ID] && !suppressed,
+						Suppressed: suppressed,
+					})
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+// LintBlocking 结果中阻止保存的项：规则被配置为阻止保存且没有被注释忽略
+func LintBlocking(warnings []models.LintWarning) []models.LintWarning {
+	var blocking []models.LintWarning
+	for _, warning := range warnings {
+		if warning.Blocking {
+			blocking = append(blocking, warning)
+		}
+	}
+	return blocking
+}
+
+// lineAt 文本中 offset 处所在的行号，从 1 开始
+func lineAt(text string, offset int) int {
+	return strings.Count(text[:offset], "\n") + 1
+}
+
+// eachLine 按行遍历字段内容，跳过空行、# 开头的注释行（含 #! 解释器行）
+func eachLine(text string, visit func(line int, content string)) {
+	for i, content := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(content)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		visit(i+1, content)
+	}
+}
+
+// isScriptField 字段是否为在执行器上运行的脚本；ssh 部署的 post_commands 在部署目标上执行，不检查路径与 sudo
+func isScriptField(name string) bool {
+	return name == "script" || strings.HasSuffix(name, ".script")
+}
+
+var (
+	// secretTokenPattern 已知格式的令牌与私钥：AWS 访问密钥、GitHub、GitLab、Slack 令牌
+	secretTokenPattern = regexp.MustCompile(`\b(?:AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{30,}|glpat-[A-Za-z0-9_-]{20,}|xox[abprs]-[A-Za-z0-9-]{10,})\b|-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	// secretCandidatePattern 可能为密钥的长字符串，再按字符种类与熵判断
+	secretCandidatePattern = regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`)
+)
+
+// minSecretEntropy 判定为高熵字符串的每字符香农熵（比特）。随机生成的 24 位以上字母数字串通常超过 4，
+// 标识符与单词组成的字符串通常低于 4
+const minSecretEntropy = 4.0
+
+// lintInlineSecrets 明文密钥：已知格式的令牌，或同时含大小写字母与数字的高熵长字符串；每个字段每行只报告一次
+func lintInlineSecrets(step *lintStep) []lintHit {
+	var hits []lintHit
+	for _, name := range step.names {
+		text := step.fields[name]
+		reported := make(map[int]bool)
+		report := func(offset int, value string) {
+			line := lineAt(text, offset)
+			if reported[line] {
+				return
+			}
+			reported[line] = true
+			hits = append(hits, lintHit{
+				field:   name,
+				line:    line,
+				message: fmt.Sprintf("%s 第 %d 行疑似明文密钥 %s", name, line, maskSecret(value)),
+				fix:     "保存为项目环境变量，在配置中以 $NAME 引用",
+			})
+		}
+		for _, loc := range secretTokenPattern.FindAllStringIndex(text, -1) {
+			report(loc[0], text[loc[0]:loc[1]])
+		}
+		for _, loc := range secretCandidatePattern.FindAllStringIndex(text, -1) {
+			if value := text[loc[0]:loc[1]]; looksLikeSecret(value) {
+				report(loc[0], value)
+			}
+		}
+	}
+	return hits
+}
+
+// looksLikeSecret 同时含大小写字母与数字且熵足够高
+func looksLikeSecret(value string) bool {
+	var lower, upper, digit bool
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return lower && upper && digit && shannonEntropy(value) >= minSecretEntropy
+}
+
+// shannonEntropy 字符串每个字符的香农熵（比特）
+func shannonEntropy(value string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// maskSecret 结果中只显示疑似密钥的前 4 个字符
+func maskSecret(value string) string {
+	if len(value) <= 4 {
+		return "****"
+	}
+	return value[:4] + "****"
+}
+
+// curlPipeShellPattern curl 或 wget 的输出通过管道交给 shell 执行
+var curlPipeShellPattern = regexp.MustCompile(`\b(?:curl|wget)\b[^\n|;&]*\|\s*(?:sudo\s+(?:-\S+\s+)*)?(?:ba|z|da|k)?sh\b`)
+
+// lintCurlPipeShell 下载后直接执行
+func lintCurlPipeShell(step *lintStep) []lintHit {
+	var hits []lintHit
+	for _, name := range step.names {
+		eachLine(step.fields[name], func(line int, content string) {
+			if curlPipeShellPattern.MatchString(content) {
+				hits = append(hits, lintHit{
+					field:   name,
+					line:    line,
+					message: fmt.Sprintf("%s 第 %d 行将下载的内容直接交给 shell 执行", name, line),
+					fix:     "先下载到文件并校验：curl -fsSLo install.sh <地址> && echo \"<sha256>  install.sh\" | sha256sum -c - && sh install.sh",
+				})
+			}
+		})
+	}
+	return hits
+}
+
+// lintDeployTimeouts 部署步骤需要步骤或阶段的 timeout，或部署配置中的 timeout、command_timeout
+func lintDeployTimeouts(step *lintStep) []lintHit {
+	if step.step.Type != "deploy" || step.step.Timeout != "" || step.stageTimeout != "" {
+		return nil
+	}
+	if _, ok := step.step.Config["timeout"]; ok {
+		return nil
+	}
+	if _, ok := step.step.Config["command_timeout"]; ok {
+		return nil
+	}
+	fix := `设置 "timeout": "10m"`
+	if deployType, _ := step.step.Config["type"].(string); deployType == "ssh" {
+		fix = `设置 "timeout": "10m"，部署后命令另设 "command_timeout"（秒）`
+	}
+	return []lintHit{{message: "部署步骤没有设置超时", fix: fix}}
+}
+
+var (
+	// latestTagPattern 显式使用 latest 标签的镜像引用
+	latestTagPattern = regexp.MustCompile(`(?:^|[\s"'=])((?:[A-Za-z0-9.-]+(?::[0-9]+)?/)?[a-z0-9][a-z0-9._/-]*):latest\b`)
+	// untaggedImagePattern 没有标签与摘要的镜像名，只检查 image 字段
+	untaggedImagePattern = regexp.MustCompile(`^(?:[A-Za-z0-9.-]+(?::[0-9]+)?/)?[a-z0-9][a-z0-9._/-]*$`)
+)
+
+// lintLatestTags 使用 latest 标签，或 image 字段未指定标签（默认 latest）
+func lintLatestTags(step *lintStep) []lintHit {
+	var hits []lintHit
+	for _, name := range step.names {
+		text := step.fields[name]
+		if name == "image" || strings.HasSuffix(name, ".image") {
+			if image := strings.TrimSpace(text); untaggedImagePattern.MatchString(image) {
+				hits = append(hits, lintHit{
+					field:   name,
+					line:    1,
+					message: fmt.Sprintf("镜像 %s 没有指定标签，默认使用 latest", image),
+					fix:     fmt.Sprintf("固定版本，如 %s:<版本> 或 %s@sha256:<摘要>", image, image),
+				})
+				continue
+			}
+		}
+		eachLine(text, func(line int, content string) {
+			if match := latestTagPattern.FindStringSubmatch(content); match != nil {
+				hits = append(hits, lintHit{
+					field:   name,
+					line:    line,
+					message: fmt.Sprintf("%s 第 %d 行使用镜像 %s:latest", name, line, match[1]),
+					fix:     fmt.Sprintf("固定版本，如 %s:<版本> 或 %s@sha256:<摘要>", match[1], match[1]),
+				})
+			}
+		})
+	}
+	return hits
+}
+
+// lintUnnamedSteps 步骤没有名称
+func lintUnnamedSteps(step *lintStep) []lintHit {
+	if strings.TrimSpace(step.step.Name) != "" {
+		return nil
+	}
+	stepType := step.step.Type
+	if stepType == "" {
+		stepType = "step"
+	}
+	return []lintHit{{message: "步骤没有名称", fix: fmt.Sprintf(`设置 "name"，如 "%s"`, stepType)}}
+}
+
+var (
+	// absolutePathPattern 脚本中的绝对路径：前面是行首、空白、引号或 shell 运算符，$HOME/... 等变量开头的路径不匹配
+	absolutePathPattern = regexp.MustCompile(`(?:^|[\s=:"'(<>|;&])(/[A-Za-z0-9._-]+)(?:/[^\s"'<>|;&)]*)?`)
+	// systemPathRoots 只读的系统目录与临时目录，使用这些绝对路径不报告
+	systemPathRoots = map[string]bool{
+		"/dev": true, "/proc": true, "/sys": true, "/usr": true, "/bin": true, "/sbin": true,
+		"/lib": true, "/lib64": true, "/tmp": true,
+	}
+)
+
+// lintAbsolutePaths 脚本读写工作区外的绝对路径，每行只报告一次
+func lintAbsolutePaths(step *lintStep) []lintHit {
+	var hits []lintHit
+	for _, name := range step.names {
+		if !isScriptField(name) {
+			continue
+		}
+		eachLine(step.fields[name], func(line int, content string) {
+			for _, match := range absolutePathPattern.FindAllStringSubmatchIndex(content, -1) {
+				root := content[match[2]:match[3]]
+				if systemPathRoots[root] {
+					continue
+				}
+				path := content[match[2]:match[1]]
+				hits = append(hits, lintHit{
+					field:   name,
+					line:    line,
+					message: fmt.Sprintf("%s 第 %d 行使用工作区外的路径 %s", name, line, path),
+					fix:     "改用工作区内的相对路径，或在部署步骤中写入部署目标",
+				})
+				return
+			}
+		})
+	}
+	return hits
+}
+
+// sudoPattern 以 sudo 执行的命令
+var sudoPattern = regexp.MustCompile(`(?:^|[\s;&|(])sudo\b`)
+
+// lintSudo 脚本中使用 sudo，每行只报告一次
+func lintSudo(step *lintStep) []lintHit {
+	var hits []lintHit
+	for _, name := range step.names {
+		if !isScriptField(name) {
+			continue
+		}
+		eachLine(step.fields[name], func(line int, content string) {
+			if sudoPattern.MatchString(content) {
+				hits = append(hits, lintHit{
+					field:   name,
+					line:    line,
+					message: fmt.Sprintf("%s 第 %d 行在共用的执行器上使用 sudo", name, line),
+					fix:     "去掉 sudo，依赖安装到工作区或项目的依赖缓存",
+				})
+			}
+		})
+	}
+	return hits
+}
@@ -0,0 +1,147 @@
+package pipeline
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/utils"
+)
+
+// LogIngestTokenPrefix 运行日志上报令牌前缀
+const LogIngestTokenPrefix = "ffli_"
+
+// maxIngestReorder 乱序到达、等待前面缺失行的最多缓存行数，超出的行不接收，由执行器按确认的序号重传
+const maxIngestReorder = 1000
+
+var (
+	// ErrIngestUnauthorized 上报令牌无效，或运行已结束、令牌随之失效
+	ErrIngestUnauthorized = errors.New("日志上报令牌无效或运行已结束")
+	// ErrIngestRateLimited 运行的日志上报超过每秒行数限制
+	ErrIngestRateLimited = errors.New("日志上报超过速率限制")
+)
+
+// LogIngestLine 执行器上报的一行日志，同一运行内的序号从 1 开始连续编号
+type LogIngestLine struct {
+	Seq  uint64 `json:"seq"`
+	Line string `json:"line"`
+}
+
+// LogIngestStats 日志上报的统计指标
+type LogIngestStats struct {
+	Batches     int64 `json:"batches"`
+	Lines       int64 `json:"lines"`
+	Bytes       int64 `json:"bytes"`
+	Duplicates  int64 `json:"duplicates"`
+	Deferred    int64 `json:"deferred"` // 超出乱序缓存、需要重传的行数
+	Rejected    int64 `json:"rejected"` // 令牌无效的请求数
+	RateLimited int64 `json:"rate_limited"`
+}
+
+// logIngestState 日志上报的累计统计
+type logIngestState struct {
+	batches     int64
+	lines       int64
+	bytes       int64
+	duplicates  int64
+	deferred    int64
+	rejected    int64
+	rateLimited int64
+}
+
+// runLogIngest 运行的日志上报状态：令牌在任务开始执行时签发，任务释放后随之失效
+type runLogIngest struct {
+	token string
+
+	mu          sync.Mutex
+	acked       uint64            // 已连续写入的最大序号
+	pending     map[uint64]string // 序号不连续、等待前面缺失行的日志
+	windowStart time.Time
+	windowLines int
+}
+
+// newRunLogIngest 签发运行的日志上报令牌
+func newRunLogIngest() *runLogIngest {
+	return &runLogIngest{
+		token:   LogIngestTokenPrefix + utils.GenerateRandomString(40),
+		pending: make(map[uint64]string),
+	}
+}
+
+// LogIngestPath 运行日志上报的接口路径
+func LogIngestPath(runID uint) string {
+	return fmt.Sprintf("/api/v1/internal/runs/%d/logs", runID)
+}
+
+// IngestLogs 接收执行器批量上报的日志：按序号排序写入运行日志（脱敏与普通日志相同），重复的行忽略，
+// 缺失的行之后的日志先缓存；返回已连续写入的最大序号，执行器从其后重传。size 为请求解压后的字节数
+func (e *Engine) IngestLogs(runID uint, token string, lines []LogIngestLine, size int64) (uint64, error) {
+	e.mu.RLock()
+	jobCtx := e.runningJobs[runID]
+	e.mu.RUnlock()
+
+	if jobCtx == nil || jobCtx.ingest == nil || jobCtx.Context.Err() != nil ||
+		subtle.ConstantTimeCompare([]byte(jobCtx.ingest.token), []byte(token)) != 1 {
+		atomic.AddInt64(&e.ingest.rejected, 1)
+		return 0, ErrIngestUnauthorized
+	}
+
+	ingest := jobCtx.ingest
+	ingest.mu.Lock()
+	defer ingest.mu.Unlock()
+
+	if limit := e.config.Deploy.LogIngestLinesPerSec; limit > 0 {
+		now := time.Now()
+		if now.Sub(ingest.windowStart) >= time.Second {
+			ingest.windowStart, ingest.windowLines = now, 0
+		}
+		if ingest.windowLines+len(lines) > limit {
+			atomic.AddInt64(&e.ingest.rateLimited, 1)
+			return ingest.acked, ErrIngestRateLimited
+		}
+		ingest.windowLines += len(lines)
+	}
+
+	atomic.AddInt64(&e.ingest.batches, 1)
+	atomic.AddInt64(&e.ingest.bytes, size)
+
+	for _, line := range lines {
+		if _, ok := ingest.pending[line.Seq]; ok || line.Seq <= ingest.acked {
+			atomic.AddInt64(&e.ingest.duplicates, 1)
+			continue
+		}
+		if line.Seq > ingest.acked+maxIngestReorder {
+			atomic.AddInt64(&e.ingest.deferred, 1)
+			continue
+		}
+		ingest.pending[line.Seq] = line.Line
+	}
+
+	for {
+		line, ok := ingest.pending[ingest.acked+1]
+		if !ok {
+			break
+		}
+		delete(ingest.pending, ingest.acked+1)
+		ingest.acked++
+		e.logMessage(jobCtx, line)
+		atomic.AddInt64(&e.ingest.lines, 1)
+	}
+	return ingest.acked, nil
+}
+
+// LogIngestStats 获取日志上报的统计指标
+func (e *Engine) LogIngestStats() LogIngestStats {
+	return LogIngestStats{
+		Batches:     atomic.LoadInt64(&e.ingest.batches),
+		Lines:       atomic.LoadInt64(&e.ingest.lines),
+		Bytes:       atomic.LoadInt64(&e.ingest.bytes),
+		Duplicates:  atomic.LoadInt64(&e.ingest.duplicates),
+		Deferred:    atomic.LoadInt64(&e.ingest.deferred),
+		Rejected:    atomic.LoadInt64(&e.ingest.rejected),
+		RateLimited: atomic.LoadInt64(&e.ingest.rateLimited),
+	}
+}
@@ -0,0 +1,237 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/logsearch"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// maxPendingLines 单个运行缓冲的最大日志行数，超出部分丢弃并计数
+const maxPendingLines = 10000
+
+// LogWriterStats 批量写入的统计指标
+type LogWriterStats struct {
+	Flushes           int64 `json:"flushes"`
+	FlushedLines      int64 `json:"flushed_lines"`
+	DroppedLines      int64 `json:"dropped_lines"`
+	LastFlushLatency  int64 `json:"last_flush_latency_ms"`
+	MaxFlushLatency   int64 `json:"max_flush_latency_ms"`
+	PendingRuns       int   `json:"pending_runs"`
+	FlushIntervalMsec int64 `json:"flush_interval_ms"`
+}
+
+// pendingUpdate 某个运行待写入的日志与字段
+type pendingUpdate struct {
+	lines  []string
+	fields map[string]interface{}
+}
+
+// runLogWriter 运行记录的写后缓冲：日志与耗时等字段按运行合并，定期批量写入
+type runLogWriter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	pending  map[uint]*pendingUpdate
+	flushMu  sync.Mutex // 取出缓冲与写入在同一把锁内，保证同一运行的日志按顺序落库
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	flushes          int64
+	flushedLines     int64
+	droppedLines     int64
+	lastFlushLatency int64
+	maxFlushLatency  int64
+	lastTick         int64 // 最近一次定时刷新的时间（UnixNano），用于健康检查
+}
+
+// newRunLogWriter 创建并启动批量写入器
+func newRunLogWriter(interval time.Duration) *runLogWriter {
+	w := &runLogWriter{
+		interval: interval,
+		pending:  make(map[uint]*pendingUpdate),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		lastTick: time.Now().UnixNano(),
+	}
+	go w.loop()
+	return w
+}
+
+// loop 定期刷新所有运行的缓冲
+func (w *runLogWriter) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flushAll()
+			atomic.StoreInt64(&w.lastTick, time.Now().UnixNano())
+		case <-w.stop:
+			w.flushAll()
+			return
+		}
+	}
+}
+
+// AppendLog 缓冲一行日志
+func (w *runLogWriter) AppendLog(runID uint, line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p := w.entry(runID)
+	if len(p.lines) >= maxPendingLines {
+		atomic.AddInt64(&w.droppedLines, 1)
+		return
+	}
+	p.lines = append(p.lines, line)
+}
+
+// SetFields 缓冲非关键字段（耗时、进度等），后写覆盖先写
+func (w *runLogWriter) SetFields(runID uint, fields map[string]interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p := w.entry(runID)
+	for k, v := range fields {
+		p.fields[k] = v
+	}
+}
+
+// Flush 同步写入某个运行的缓冲
+func (w *runLogWriter) Flush(runID uint) {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	p := w.pending[runID]
+	delete(w.pending, runID)
+	w.mu.Unlock()
+
+	if p != nil {
+		w.write(runID, p)
+	}
+}
+
+// Stop 停止定时刷新并写入剩余缓冲
+func (w *runLogWriter) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// Stats 获取统计指标
+func (w *runLogWriter) Stats() LogWriterStats {
+	w.mu.Lock()
+	pendingRuns := len(w.pending)
+	w.mu.Unlock()
+
+	return LogWriterStats{
+		Flushes:           atomic.LoadInt64(&w.flushes),
+		FlushedLines:      atomic.LoadInt64(&w.flushedLines),
+		DroppedLines:      atomic.LoadInt64(&w.droppedLines),
+		LastFlushLatency:  atomic.LoadInt64(&w.lastFlushLatency),
+		MaxFlushLatency:   atomic.LoadInt64(&w.maxFlushLatency),
+		PendingRuns:       pendingRuns,
+		FlushIntervalMsec: w.interval.Milliseconds(),
+	}
+}
+
+// Healthy 定时刷新是否仍在正常进行，超过若干个刷新周期未完成视为卡住
+func (w *runLogWriter) Healthy() error {
+	select {
+	case <-w.done:
+		return fmt.Errorf("日志写入器已停止")
+	default:
+	}
+
+	since := time.Since(time.Unix(0, atomic.LoadInt64(&w.lastTick)))
+	if since > 5*w.interval {
+		return fmt.Errorf("日志写入器已 %v 未完成刷新", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+// entry 获取运行的缓冲，调用方需持有 w.mu
+func (w *runLogWriter) entry(runID uint) *pendingUpdate {
+	p, ok := w.pending[runID]
+	if !ok {
+		p = &pendingUpdate{fields: make(map[string]interface{})}
+		w.pending[runID] = p
+	}
+	return p
+}
+
+// flushAll 写入所有运行的缓冲
+func (w *runLogWriter) flushAll() {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = make(map[uint]*pendingUpdate)
+	w.mu.Unlock()
+
+	for runID, p := range batch {
+		w.write(runID, p)
+	}
+}
+
+// write 将一个运行的缓冲合并为一次UPDATE，调用方需持有 w.flushMu
+func (w *runLogWriter) write(runID uint, p *pendingUpdate) {
+	if database.DB == nil || (len(p.lines) == 0 && len(p.fields) == 0) {
+		return
+	}
+
+	start := time.Now()
+
+	updates := make(map[string]interface{}, len(p.fields)+1)
+	for k, v := range p.fields {
+		updates[k] = v
+	}
+	var text string
+	if len(p.lines) > 0 {
+		text = strings.Join(p.lines, "\n") + "\n"
+		updates["log_output"] = appendExpr(database.DB, "log_output", text)
+		updates["log_size"] = gorm.Expr("log_size + ?", len(text))
+	}
+
+	if err := database.DB.Model(&models.PipelineRun{}).Where("id = ?", runID).Updates(updates).Error; err != nil {
+		atomic.AddInt64(&w.droppedLines, int64(len(p.lines)))
+		log.Printf("批量写入流水线运行 %d 日志失败: %v", runID, err)
+		return
+	}
+	// 开启日志全文检索时异步建立索引，日志写入前已脱敏
+	logsearch.Enqueue(runID, text)
+
+	latency := time.Since(start).Milliseconds()
+	atomic.AddInt64(&w.flushes, 1)
+	atomic.AddInt64(&w.flushedLines, int64(len(p.lines)))
+	atomic.StoreInt64(&w.lastFlushLatency, latency)
+	for {
+		prev := atomic.LoadInt64(&w.maxFlushLatency)
+		if latency <= prev || atomic.CompareAndSwapInt64(&w.maxFlushLatency, prev, latency) {
+			break
+		}
+	}
+}
+
+// appendExpr 生成追加文本到列末尾的表达式，兼容各数据库方言
+func appendExpr(db *gorm.DB, column, text string) interface{} {
+	if db.Dialector.Name() == "mysql" {
+		return gorm.Expr("CONCAT(COALESCE("+column+", ''), ?)", text)
+	}
+	return gorm.Expr("COALESCE("+column+", '') || ?", text)
+}
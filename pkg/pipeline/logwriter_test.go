@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// runLogLines 一个运行写入的日志行数
+const runLogLines = 10000
+
+// countUpdates 统计之后对运行记录执行的 UPDATE 次数
+func countUpdates(tb testing.TB) *int64 {
+	tb.Helper()
+	var count int64
+	err := database.DB.Callback().Update().After("gorm:update").Register("test:count_updates", func(db *gorm.DB) {
+		if db.Statement.Table == "pipeline_runs" {
+			atomic.AddInt64(&count, 1)
+		}
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return &count
+}
+
+// createTestRun 写入日志的运行记录
+func createTestRun(tb testing.TB) *models.PipelineRun {
+	tb.Helper()
+	run := &models.PipelineRun{PipelineID: 1, Status: models.RunStatusRunning, TriggerType: models.TriggerManual}
+	if err := database.DB.Create(run).Error; err != nil {
+		tb.Fatal(err)
+	}
+	return run
+}
+
+// writeRunLog 写入一个运行的全部日志行；perLine 时每行立即落库，即关闭批量写入时的写法
+func writeRunLog(w *runLogWriter, runID uint, perLine bool) {
+	for i := 0; i < runLogLines; i++ {
+		w.AppendLog(runID, fmt.Sprintf("line %d", i))
+		if perLine {
+			w.Flush(runID)
+		}
+	}
+	w.Flush(runID)
+}
+
+// TestRunLogWriterBatchesUpdates 批量写入时整个运行的日志合并为一次 UPDATE，行按写入顺序落库
+func TestRunLogWriterBatchesUpdates(t *testing.T) {
+	setupTestDB(t)
+	updates := countUpdates(t)
+	// 定时刷新间隔足够长，只由最后的 Flush 写入
+	w := newRunLogWriter(time.Hour)
+	defer w.Stop()
+
+	run := createTestRun(t)
+	writeRunLog(w, run.ID, false)
+	if got := atomic.LoadInt64(updates); got != 1 {
+		t.Errorf("批量写入 %d 行执行了 %d 次 UPDATE，应为 1 次", runLogLines, got)
+	}
+
+	var saved models.PipelineRun
+	if err := database.DB.First(&saved, run.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(saved.LogOutput, "\n"), "\n")
+	if len(lines) != runLogLines {
+		t.Fatalf("落库 %d 行，应为 %d 行", len(lines), runLogLines)
+	}
+	for i, line := range lines {
+		if line != fmt.Sprintf("line %d", i) {
+			t.Fatalf("第 %d 行为 %q，日志顺序被打乱", i+1, line)
+		}
+	}
+	if stats := w.Stats(); stats.DroppedLines != 0 || stats.FlushedLines != runLogLines {
+		t.Errorf("统计应为写入 %d 行、丢弃 0 行，实际为 %+v", runLogLines, stats)
+	}
+}
+
+// BenchmarkRunLogWriter 比较 10000 行日志的运行逐行写入与批量写入的 UPDATE 次数与耗时
+func BenchmarkRunLogWriter(b *testing.B) {
+	for _, bm := range []struct {
+		name    string
+		perLine bool
+	}{
+		{"per_line", true},
+		{"batched", false},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			setupTestDB(b)
+			updates := countUpdates(b)
+			w := newRunLogWriter(2 * time.Second)
+			defer w.Stop()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				run := createTestRun(b)
+				writeRunLog(w, run.ID, bm.perLine)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(updates))/float64(b.N), "updates/run")
+		})
+	}
+}
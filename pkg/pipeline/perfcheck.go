@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"flowforge/pkg/baseline"
+	"flowforge/pkg/database"
+	"flowforge/pkg/events"
+	"flowforge/pkg/models"
+)
+
+// checkPerformance 将成功运行的步骤耗时与默认分支上最近成功运行的基线比较，超过阈值的步骤记录运行标注，
+// 并记录系统事件、通知关注者。基线每次按已保存的步骤耗时重新计算；仅重跑失败步骤的运行与触及资源限制的运行不检查
+func (e *Engine) checkPerformance(jobCtx *JobContext) {
+	run := jobCtx.PipelineRun
+	if run.RerunOfID != nil || run.ResourceLimited || run.Branch != jobCtx.Project.Branch {
+		return
+	}
+	settings := baseline.For(jobCtx.Pipeline, &e.config.Deploy)
+	if settings.Disabled || settings.Multiplier <= 0 || settings.Runs <= 0 {
+		return
+	}
+
+	samples, err := baseline.Samples(jobCtx.Pipeline.ID, jobCtx.Project.Branch, settings.Runs, run.ID)
+	if err != nil {
+		log.Printf("流水线运行 %d 计算性能基线失败: %v", run.ID, err)
+		return
+	}
+	baselines := baseline.Build(samples, settings.Multiplier)
+
+	var steps []models.PipelineStep
+	if err := database.DB.Where("pipeline_run_id = ? AND status = ?", run.ID, models.StepStatusSuccess).
+		Order("step_order").Find(&steps).Error; err != nil {
+		log.Printf("流水线运行 %d 查询步骤耗时失败: %v", run.ID, err)
+		return
+	}
+
+	var findings []string
+	regressions := make([]map[string]interface{}, 0)
+	for i := range steps {
+		step := &steps[i]
+		base, ok := baselines[step.Name]
+		if !ok || !base.Exceeds(step.DurationMs, settings) {
+			continue
+		}
+
+		message := fmt.Sprintf("步骤 %s 耗时 %s，基线 %s（超过基线的 %g 倍）",
+			step.Name, formatDurationMs(step.DurationMs), formatDurationMs(base.MedianMs), settings.Multiplier)
+		annotation := models.RunAnnotation{
+			PipelineRunID:  run.ID,
+			PipelineStepID: &step.ID,
+			Kind:           models.AnnotationPerformanceRegression,
+			Source:         models.AnnotationSourceSystem,
+			Message:        message,
+			DurationMs:     step.DurationMs,
+			BaselineMs:     base.MedianMs,
+		}
+		if err := database.DB.Create(&annotation).Error; err != nil {
+			log.Printf("流水线运行 %d 记录性能回退标注失败: %v", run.ID, err)
+			continue
+		}
+
+		findings = append(findings, message)
+		regressions = append(regressions, map[string]interface{}{
+			"step":         step.Name,
+			"duration_ms":  step.DurationMs,
+			"baseline_ms":  base.MedianMs,
+			"threshold_ms": base.ThresholdMs,
+		})
+	}
+	if len(findings) == 0 {
+		return
+	}
+
+	if events.Enabled() {
+		e.publish(jobCtx, events.Event{
+			Type:     events.TypeRunPerformanceRegression,
+			Resource: events.Resource{Type: "pipeline_run", ID: strconv.FormatUint(uint64(run.ID), 10)},
+			Outcome:  events.OutcomeSuccess,
+			Data: map[string]interface{}{
+				"run_number": run.RunNumber,
+				"commit_sha": run.CommitSHA,
+				"multiplier": settings.Multiplier,
+				"steps":      regressions,
+			},
+		})
+	}
+
+	if e.notifier != nil {
+		go e.notifier.NotifyPerformanceRegression(run.ID, findings)
+	}
+}
+
+// formatDurationMs 毫秒耗时的可读形式，精确到秒
+func formatDurationMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
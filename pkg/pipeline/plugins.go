@@ -0,0 +1,32 @@
+//go:build (linux || darwin || freebsd) && cgo
+
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"plugin"
+	"sort"
+)
+
+// LoadStepPlugins 加载目录中的 Go 插件（*.so），插件在 init 中调用 RegisterStepType 注册步骤类型。
+// 插件需使用与服务端相同的 Go 版本和依赖版本构建；dir 为空时不加载
+func LoadStepPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return fmt.Errorf("查找步骤类型插件失败: %w", err)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("加载步骤类型插件 %s 失败: %w", path, err)
+		}
+		log.Printf("已加载步骤类型插件 %s", path)
+	}
+	return nil
+}
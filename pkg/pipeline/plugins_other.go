@@ -0,0 +1,13 @@
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package pipeline
+
+import "fmt"
+
+// LoadStepPlugins 当前平台或未启用 cgo 的构建不支持 Go 插件，步骤类型只能通过构建标签编译进来
+func LoadStepPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return fmt.Errorf("当前构建不支持加载步骤类型插件，请通过构建标签编译进服务端")
+}
@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/models"
+	"flowforge/pkg/policy"
+)
+
+// applyPolicy 运行开始时与配置一起解析生效的策略：合并项目默认策略与流水线的覆盖，在部署到需要审批的环境的步骤前
+// 插入审批等待，并按运行超时设置截止时间。解析结果随配置快照保存
+func (e *Engine) applyPolicy(jobCtx *JobContext, config *models.PipelineConfig) {
+	project, err := policy.Load(jobCtx.db(), jobCtx.Project.ID)
+	if err != nil {
+		log.Printf("流水线运行 %d 读取项目策略失败: %v", jobCtx.PipelineRun.ID, err)
+		project = &models.ProjectPolicy{ProjectID: jobCtx.Project.ID}
+	}
+	jobCtx.policy = policy.For(project, jobCtx.Pipeline)
+
+	for i := range config.Stages {
+		stage := &config.Stages[i]
+		steps := make([]models.PipelineStep, 0, len(stage.Steps))
+		for _, step := range stage.Steps {
+			environment, _ := step.Config["environment"].(string)
+			if approval := policy.ApprovalFor(jobCtx.policy, environment); approval != nil {
+				steps = append(steps, approvalStep(&step, environment, approval))
+			}
+			steps = append(steps, step)
+		}
+		stage.Steps = steps
+	}
+
+	if minutes := jobCtx.policy.RunTimeoutMinutes; minutes > 0 {
+		// 恢复外部等待的运行同样从任务开始执行时计时
+		jobCtx.deadline = time.AfterFunc(time.Until(jobCtx.StartedAt.Add(time.Duration(minutes)*time.Minute)), func() {
+			jobCtx.setCancellation(Cancellation{Reason: models.CancelReasonTimeout})
+			atomic.StoreInt32(&jobCtx.timedOut, 1)
+			jobCtx.Cancel()
+		})
+	}
+}
+
+// approvalStep 部署步骤前的审批等待，由管理员在外部等待中处理
+func approvalStep(step *models.PipelineStep, environment string, approval *models.EffectiveApproval) models.PipelineStep {
+	config := map[string]interface{}{
+		"description": fmt.Sprintf("部署到 %s 前需要审批（步骤 %s）", environment, step.Name),
+	}
+	if approval.TimeoutSeconds > 0 {
+		config["timeout"] = float64(approval.TimeoutSeconds)
+	}
+	return models.PipelineStep{
+		Name:   "审批: " + step.Name,
+		Type:   "external_wait",
+		Config: config,
+	}
+}
+
+// timedOutByPolicy 运行是否因超过策略的运行超时而被中止
+func (j *JobContext) timedOutByPolicy() bool {
+	return atomic.LoadInt32(&j.timedOut) == 1
+}
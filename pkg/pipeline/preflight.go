@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/models"
+	"flowforge/pkg/ssh"
+)
+
+// preflightTarget 同步文件前检查部署目标；必需检查未通过时记录失败的部署并中止，此时目标尚未被修改。
+// 步骤配置 preflight: false 时跳过，require_sudo 与 required_binaries 追加检查要求
+func (e *Engine) preflightTarget(jobCtx *JobContext, step *models.PipelineStep, target *deploy.DriftTarget, localDir string) (*deploy.PreflightReport, error) {
+	if e.preflighter == nil {
+		return nil, nil
+	}
+	if enabled, ok := step.Config["preflight"].(bool); ok && !enabled {
+		return nil, nil
+	}
+
+	// 无法统计时按 0 处理，磁盘检查只比较可用空间是否可获取
+	size, err := ssh.LocalSize(localDir)
+	if err != nil {
+		e.logf(jobCtx, "log.warning", err)
+	}
+	opts := deploy.PreflightOptions{
+		RequiredBytes: size,
+		Binaries:      configStrings(step.Config["required_binaries"]),
+		ProjectID:     jobCtx.Project.ID,
+	}
+	opts.Sudo, _ = step.Config["require_sudo"].(bool)
+	if target.ServiceUnit != "" {
+		opts.Binaries = append(opts.Binaries, "systemctl")
+	}
+
+	report := e.preflighter.Run(jobCtx.stepContext(), target, opts)
+	for _, check := range report.Checks {
+		if check.Status != deploy.PreflightPass {
+			e.logf(jobCtx, "log.preflight_check", check.Name, check.Status, check.Message)
+		}
+	}
+	if report.Passed {
+		e.logf(jobCtx, "log.preflight_passed", target.Key())
+		return report, nil
+	}
+
+	e.recordPreflightFailure(jobCtx, target.Key(), report)
+	return report, fmt.Errorf("部署目标 %s 未通过部署前检查: %s", target.Key(), report.Summary())
+}
+
+// recordPreflightFailure 记录未通过部署前检查的部署，便于事后查看检查了什么
+func (e *Engine) recordPreflightFailure(jobCtx *JobContext, target string, report *deploy.PreflightReport) {
+	now := time.Now()
+	deployment := &models.Deployment{
+		Version:    fmt.Sprintf("v%d", jobCtx.PipelineRun.ID),
+		CommitHash: jobCtx.PipelineRun.CommitSHA,
+		Status:     models.DeployStatusFailed,
+		StartTime:  &now,
+		EndTime:    &now,
+		ErrorMsg:   report.Summary(),
+		Preflight:  preflightJSON(report),
+		ProjectID:  jobCtx.Project.ID,
+		UserID:     jobCtx.PipelineRun.UserID,
+	}
+	if err := jobCtx.db().Create(deployment).Error; err != nil {
+		log.Printf("流水线运行 %d 保存部署记录失败: %v", jobCtx.PipelineRun.ID, err)
+		return
+	}
+	e.publishDeploymentEvent(jobCtx, deployment, target)
+}
+
+// preflightJSON 序列化部署前检查结果，未检查时为空
+func preflightJSON(report *deploy.PreflightReport) string {
+	if report == nil {
+		return ""
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,241 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/git"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+)
+
+// prewarmState 预热的执行状态与统计，与流水线运行分开计数
+type prewarmState struct {
+	running   int32
+	started   int64
+	succeeded int64
+	failed    int64
+	skipped   int64
+	deferred  int64
+}
+
+// PrewarmStats 预热统计指标
+type PrewarmStats struct {
+	Running   bool  `json:"running"`
+	Started   int64 `json:"started"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	Skipped   int64 `json:"skipped"`
+	Deferred  int64 `json:"deferred"`
+}
+
+// PrewarmStats 获取预热统计，预热不计入流水线运行
+func (e *Engine) PrewarmStats() PrewarmStats {
+	return PrewarmStats{
+		Running:   atomic.LoadInt32(&e.prewarm.running) == 1,
+		Started:   atomic.LoadInt64(&e.prewarm.started),
+		Succeeded: atomic.LoadInt64(&e.prewarm.succeeded),
+		Failed:    atomic.LoadInt64(&e.prewarm.failed),
+		Skipped:   atomic.LoadInt64(&e.prewarm.skipped),
+		Deferred:  atomic.LoadInt64(&e.prewarm.deferred),
+	}
+}
+
+// Prewarm 在定时触发前预热流水线：更新项目工作区代码，并把依赖缓存恢复到暂存区，
+// 使正式运行的检出只需本地增量更新。优先级低于流水线运行：并发预算已满或已有预热在执行时
+// 返回 true 表示延后，由调度器下一轮重试；项目已有运行中的流水线时跳过
+func (e *Engine) Prewarm(pipelineID uint) (deferred bool) {
+	if atomic.LoadInt32(&e.shuttingDown) == 1 {
+		return true
+	}
+	if len(e.GetRunningJobs()) >= e.config.Deploy.MaxConcurrent {
+		atomic.AddInt64(&e.prewarm.deferred, 1)
+		return true
+	}
+	// 同一时间只进行一个预热，避免与正式运行争抢资源
+	if !atomic.CompareAndSwapInt32(&e.prewarm.running, 0, 1) {
+		atomic.AddInt64(&e.prewarm.deferred, 1)
+		return true
+	}
+	defer atomic.StoreInt32(&e.prewarm.running, 0)
+
+	var pipeline models.Pipeline
+	if err := models.WithPrivateKey(database.DB).Preload("Project").Preload("Project.DeployKey").Preload("Project.SSHKey").
+		First(&pipeline, pipelineID).Error; err != nil {
+		log.Printf("[预热] 获取流水线 %d 失败: %v", pipelineID, err)
+		return false
+	}
+
+	if e.projectBusy(pipeline.ProjectID) {
+		atomic.AddInt64(&e.prewarm.skipped, 1)
+		e.setPrewarmStatus(&pipeline, models.PrewarmStatusSkipped, "项目有运行中的流水线，跳过预热")
+		return false
+	}
+
+	atomic.AddInt64(&e.prewarm.started, 1)
+	e.setPrewarmStatus(&pipeline, models.PrewarmStatusRunning, "")
+	log.Printf("[预热] 开始预热流水线 %s (ID: %d)", pipeline.Name, pipeline.ID)
+
+	restored, err := e.runPrewarm(&pipeline)
+	if err != nil {
+		atomic.AddInt64(&e.prewarm.failed, 1)
+		e.setPrewarmStatus(&pipeline, models.PrewarmStatusFailed, err.Error())
+		log.Printf("[预热] 流水线 %d 预热失败: %v", pipeline.ID, err)
+		return false
+	}
+
+	atomic.AddInt64(&e.prewarm.succeeded, 1)
+	e.setPrewarmStatus(&pipeline, models.PrewarmStatusSuccess, fmt.Sprintf("代码已更新，恢复依赖缓存 %d 个", restored))
+	log.Printf("[预热] 流水线 %d 预热完成，恢复依赖缓存 %d 个", pipeline.ID, restored)
+	return false
+}
+
+// runPrewarm 更新工作区代码并恢复依赖缓存到暂存区，返回恢复的缓存数量
+func (e *Engine) runPrewarm(pipeline *models.Pipeline) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.config.Deploy.Timeout)*time.Second)
+	defer cancel()
+
+	project := &pipeline.Project
+	workDir := e.workspaceDir(project.ID)
+	if isolation.Enabled() {
+		account, err := isolation.ProjectAccount(database.DB, e.config.App.DataPath, project)
+		if err != nil {
+			return 0, err
+		}
+		if err := isolation.Prepare(workDir, account); err != nil {
+			return 0, fmt.Errorf("准备项目工作区失败: %w", err)
+		}
+	}
+	client := e.gitManager.GetClient()
+	if utils.IsFileExists(filepath.Join(workDir, ".git")) {
+		if err := client.Pull(ctx, git.PullOptions{Project: project, SSHKey: project.SSHKey, RepoDir: workDir}); err != nil {
+			return 0, fmt.Errorf("更新代码失败: %w", err)
+		}
+	} else {
+		if err := client.Clone(ctx, git.CloneOptions{Project: project, SSHKey: project.SSHKey, TargetDir: workDir}); err != nil {
+			return 0, fmt.Errorf("克隆代码失败: %w", err)
+		}
+	}
+
+	staging := e.stagingDir(pipeline)
+	if err := os.RemoveAll(staging); err != nil {
+		return 0, fmt.Errorf("清理暂存区失败: %w", err)
+	}
+
+	restored := 0
+	for _, rel := range cachePaths(pipeline) {
+		src := filepath.Join(e.cacheDir(pipeline), rel)
+		if !utils.IsFileExists(src) {
+			continue
+		}
+		if err := utils.CopyDir(src, filepath.Join(staging, rel)); err != nil {
+			return restored, fmt.Errorf("恢复依赖缓存 %s 失败: %w", rel, err)
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// applyStagedCaches 把预热暂存的依赖缓存移入工作区，已存在的目录保持不变
+func (e *Engine) applyStagedCaches(jobCtx *JobContext) {
+	staging := e.stagingDir(jobCtx.Pipeline)
+	if !utils.IsFileExists(staging) {
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	applied := 0
+	for _, rel := range cachePaths(jobCtx.Pipeline) {
+		src := filepath.Join(staging, rel)
+		dst := filepath.Join(workDir, rel)
+		if !utils.IsFileExists(src) || utils.IsFileExists(dst) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			continue
+		}
+		if err := os.Rename(src, dst); err == nil {
+			applied++
+		}
+	}
+	if applied > 0 {
+		e.logf(jobCtx, "log.prewarm_caches_applied", applied)
+	}
+}
+
+// saveDependencyCaches 成功运行后保存声明的依赖缓存目录，失败只记录日志
+func (e *Engine) saveDependencyCaches(jobCtx *JobContext) {
+	paths := cachePaths(jobCtx.Pipeline)
+	if len(paths) == 0 {
+		return
+	}
+
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	for _, rel := range paths {
+		src := filepath.Join(workDir, rel)
+		if !utils.IsFileExists(src) {
+			continue
+		}
+		dst := filepath.Join(e.cacheDir(jobCtx.Pipeline), rel)
+		os.RemoveAll(dst)
+		if err := utils.CopyDir(src, dst); err != nil {
+			log.Printf("流水线 %d 保存依赖缓存 %s 失败: %v", jobCtx.Pipeline.ID, rel, err)
+		}
+	}
+}
+
+// projectBusy 项目是否有运行中的流水线（同一项目共享工作区）
+func (e *Engine) projectBusy(projectID uint) bool {
+	for _, jobCtx := range e.GetRunningJobs() {
+		if jobCtx.Project.ID == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// setPrewarmStatus 记录最近一次预热的状态
+func (e *Engine) setPrewarmStatus(pipeline *models.Pipeline, status, message string) {
+	now := time.Now()
+	database.DB.Model(pipeline).Updates(map[string]interface{}{
+		"prewarm_status":  status,
+		"prewarm_message": message,
+		"prewarm_at":      &now,
+	})
+}
+
+// cacheDir 流水线依赖缓存的保存目录，隔离开启时位于项目自己的目录下
+func (e *Engine) cacheDir(pipeline *models.Pipeline) string {
+	return filepath.Join(isolation.CacheDir(e.config.App.DataPath, pipeline.ProjectID, pipeline.ID), "deps")
+}
+
+// stagingDir 预热恢复依赖缓存的暂存目录
+func (e *Engine) stagingDir(pipeline *models.Pipeline) string {
+	return filepath.Join(isolation.CacheDir(e.config.App.DataPath, pipeline.ProjectID, pipeline.ID), "staging")
+}
+
+// cachePaths 解析声明的依赖缓存目录，忽略绝对路径与跳出工作区的路径
+func cachePaths(pipeline *models.Pipeline) []string {
+	var paths []string
+	for _, item := range strings.Split(pipeline.PrewarmCaches, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || filepath.IsAbs(item) {
+			continue
+		}
+		clean := filepath.Clean(item)
+		if clean == "." || strings.HasPrefix(clean, "..") {
+			continue
+		}
+		paths = append(paths, clean)
+	}
+	return paths
+}
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"log"
+
+	"flowforge/pkg/models"
+	"flowforge/pkg/scripts"
+)
+
+// stepPriority 本机执行脚本的调度优先级：流水线设置覆盖全局配置，为服务保留的 CPU 核只有全局配置
+func (e *Engine) stepPriority(pipeline *models.Pipeline) scripts.Priority {
+	priority := scripts.Priority{
+		Nice:         e.config.Deploy.ProcessNice,
+		IOClass:      e.config.Deploy.ProcessIOClass,
+		IOLevel:      e.config.Deploy.ProcessIOLevel,
+		ReservedCPUs: e.config.Deploy.ReservedCPUs,
+	}
+	if pipeline == nil {
+		return priority
+	}
+	if pipeline.ProcessNice != nil {
+		priority.Nice = *pipeline.ProcessNice
+	}
+	if pipeline.ProcessIOClass != "" {
+		priority.IOClass = pipeline.ProcessIOClass
+	}
+	if pipeline.ProcessIOLevel != nil {
+		priority.IOLevel = *pipeline.ProcessIOLevel
+	}
+	return priority
+}
+
+// recordPriority 将实际应用的调度优先级记录到步骤，未能应用的项在运行日志中告警
+func (e *Engine) recordPriority(jobCtx *JobContext, applied *scripts.AppliedPriority) {
+	if applied == nil {
+		return
+	}
+	for _, warning := range applied.Warnings {
+		e.logf(jobCtx, "log.priority_not_applied", warning)
+	}
+
+	record := jobCtx.currentStep
+	if record == nil || record.ID == 0 {
+		return
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return
+	}
+	record.ProcessPriority = string(data)
+	if err := jobCtx.db().Model(record).Update("process_priority", record.ProcessPriority).Error; err != nil {
+		log.Printf("保存步骤 %d 的调度优先级失败: %v", record.ID, err)
+	}
+}
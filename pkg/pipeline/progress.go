@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"log"
+	"time"
+
+	"flowforge/pkg/baseline"
+	"flowforge/pkg/models"
+	"flowforge/pkg/progress"
+)
+
+// startProgress 按解析后的配置生成本次运行的步骤计划，并在开始时一次性读取各步骤的历史耗时中位数。
+// 优先使用运行分支上的历史，没有时使用项目默认分支的历史；都没有时按步骤数估算进度
+func (e *Engine) startProgress(jobCtx *JobContext, config *models.PipelineConfig) {
+	medians := e.stepMedians(jobCtx)
+
+	var steps []progress.Step
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			steps = append(steps, progress.Step{Name: step.Name, Stage: stage.Name, MedianMs: medians[step.Name]})
+		}
+	}
+	tracker := progress.NewTracker(steps, jobCtx.StartedAt)
+
+	// 读取进度时持有 e.mu，赋值同样在锁内进行
+	e.mu.Lock()
+	jobCtx.progress = tracker
+	e.mu.Unlock()
+}
+
+// stepMedians 各步骤最近成功运行的耗时中位数
+func (e *Engine) stepMedians(jobCtx *JobContext) map[string]int64 {
+	run := jobCtx.PipelineRun
+	settings := baseline.For(jobCtx.Pipeline, &e.config.Deploy)
+	if settings.Runs <= 0 {
+		return nil
+	}
+
+	for _, branch := range []string{run.Branch, jobCtx.Project.Branch} {
+		samples, err := baseline.Samples(jobCtx.Pipeline.ID, branch, settings.Runs, run.ID)
+		if err != nil {
+			log.Printf("流水线运行 %d 读取步骤历史耗时失败: %v", run.ID, err)
+			return nil
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		medians := make(map[string]int64)
+		for name, base := range baseline.Build(samples, settings.Multiplier) {
+			medians[name] = base.MedianMs
+		}
+		return medians
+	}
+	return nil
+}
+
+// RunProgress 运行中的进度与预计完成时间，运行不在本实例执行或尚未开始执行步骤时返回 false
+func (e *Engine) RunProgress(runID uint) (*progress.Snapshot, bool) {
+	e.mu.RLock()
+	var tracker *progress.Tracker
+	if jobCtx, exists := e.runningJobs[runID]; exists {
+		tracker = jobCtx.progress
+	}
+	e.mu.RUnlock()
+	if tracker == nil {
+		return nil, false
+	}
+
+	snapshot := tracker.Snapshot(time.Now())
+	return &snapshot, true
+}
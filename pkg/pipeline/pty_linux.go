@@ -0,0 +1,119 @@
+//go:build linux
+
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// startPTY 分配伪终端，以从端作为命令的标准输入输出并在新会话中启动命令，返回主端。
+// 命令设置了运行用户时从端归属该用户
+func startPTY(cmd *exec.Cmd, cols, rows uint16) (*os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("打开伪终端失败: %w", err)
+	}
+
+	// 不使用 Fd()，它会把主端切换为阻塞模式，关闭时无法中断读取
+	var number int
+	err = ptyControl(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return err
+		}
+		var err error
+		number, err = unix.IoctlGetInt(fd, unix.TIOCGPTN)
+		return err
+	})
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("分配伪终端失败: %w", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("打开伪终端从端失败: %w", err)
+	}
+	defer slave.Close()
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if cred := cmd.SysProcAttr.Credential; cred != nil {
+		if err := slave.Chown(int(cred.Uid), int(cred.Gid)); err != nil {
+			master.Close()
+			return nil, fmt.Errorf("修改伪终端归属失败: %w", err)
+		}
+	}
+	if err := resizePTY(master, cols, rows); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+// resizePTY 设置终端的行列数，为 0 时不修改
+func resizePTY(master *os.File, cols, rows uint16) error {
+	if cols == 0 || rows == 0 {
+		return nil
+	}
+	err := ptyControl(master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Col: cols, Row: rows})
+	})
+	if err != nil {
+		return fmt.Errorf("设置终端大小失败: %w", err)
+	}
+	return nil
+}
+
+// ptyControl 在伪终端的文件描述符上执行 ioctl
+func ptyControl(file *os.File, fn func(fd int) error) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// killSession 结束终端会话中的全部进程。shell 是会话首进程，会话ID与其进程ID相同；
+// 作业控制会把后台任务放入单独的进程组，因此按会话ID逐个查找
+func killSession(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	sid := cmd.Process.Pid
+	syscall.Kill(-sid, syscall.SIGKILL)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == sid {
+			continue
+		}
+		if session, err := unix.Getsid(pid); err == nil && session == sid {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+}
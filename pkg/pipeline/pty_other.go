@@ -0,0 +1,26 @@
+//go:build !linux
+
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// errPTYUnsupported 调试终端使用 Linux 的伪终端，且依赖只支持 Linux 的多租户隔离
+var errPTYUnsupported = errors.New("调试终端只支持 Linux")
+
+func startPTY(cmd *exec.Cmd, cols, rows uint16) (*os.File, error) {
+	return nil, errPTYUnsupported
+}
+
+func resizePTY(master *os.File, cols, rows uint16) error {
+	return errPTYUnsupported
+}
+
+func killSession(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,327 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// ErrRunNotQueued 运行不在等待队列中（已开始执行、已结束或不存在）
+var ErrRunNotQueued = errors.New("流水线运行不在等待队列中")
+
+// 排队运行的优先级，同优先级按入队顺序出队
+const (
+	QueuePriorityNormal = 0
+	QueuePriorityManual = 1 // 手动触发的运行有人在等待结果
+)
+
+// queuedRun 等待执行的运行
+type queuedRun struct {
+	jobCtx     *JobContext
+	priority   int
+	promoted   bool // 管理员提升到队首的运行排在所有未提升的运行之前
+	enqueuedAt time.Time
+	block      *queueBlock // 最近一次检查时阻塞它的原因
+}
+
+// runQueue 显式的运行等待队列，由 Engine.mu 保护；出队顺序即切片顺序
+type runQueue struct {
+	items []*queuedRun
+}
+
+// push 按优先级插入：排在已提升的运行与优先级不低于它的运行之后
+func (q *runQueue) push(item *queuedRun) int {
+	pos := len(q.items)
+	for i, existing := range q.items {
+		if !existing.promoted && existing.priority < item.priority {
+			pos = i
+			break
+		}
+	}
+	q.items = append(q.items, nil)
+	copy(q.items[pos+1:], q.items[pos:])
+	q.items[pos] = item
+	return pos
+}
+
+// pop 取出队首的运行
+func (q *runQueue) pop() *queuedRun {
+	if len(q.items) == 0 {
+		return nil
+	}
+	item := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return item
+}
+
+// index 运行在队列中的位置，不在队列中时返回 -1
+func (q *runQueue) index(runID uint) int {
+	for i, item := range q.items {
+		if item.jobCtx.PipelineRun.ID == runID {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove 从队列中移除运行
+func (q *runQueue) remove(runID uint) *queuedRun {
+	i := q.index(runID)
+	if i < 0 {
+		return nil
+	}
+	item := q.items[i]
+	q.items = append(q.items[:i], q.items[i+1:]...)
+	return item
+}
+
+// QueuedRun 等待队列中的运行
+type QueuedRun struct {
+	Position       int       `json:"position"` // 从 1 开始
+	RunID          uint      `json:"run_id"`
+	PipelineID     uint      `json:"pipeline_id"`
+	PipelineName   string    `json:"pipeline_name"`
+	ProjectID      uint      `json:"project_id"`
+	ProjectName    string    `json:"project_name"`
+	Priority       int       `json:"priority"`
+	Promoted       bool      `json:"promoted"`
+	TriggerType    string    `json:"trigger_type"`
+	EnqueuedAt     time.Time `json:"enqueued_at"`
+	WaitingSeconds int64     `json:"waiting_seconds"`
+	BlockedBy      string    `json:"blocked_by,omitempty"`      // global_limit、project_limit、mutex_group 或 workspace
+	BlockingLimit  int       `json:"blocking_limit,omitempty"`  // 阻塞它的并发上限
+	BlockingGroup  string    `json:"blocking_group,omitempty"`  // 阻塞它的互斥组
+	BlockingRunID  uint      `json:"blocking_run_id,omitempty"` // 占用互斥组或工作区的运行
+}
+
+// QueueStats 等待队列的指标
+type QueueStats struct {
+	Depth            int   `json:"depth"`
+	OldestAgeSeconds int64 `json:"oldest_age_seconds"`
+	MaxConcurrent    int   `json:"max_concurrent"`
+	Running          int   `json:"running"`
+}
+
+// enqueueOrStart 全局并发数与项目并发策略均允许时立即执行，否则加入等待队列
+func (e *Engine) enqueueOrStart(jobCtx *JobContext) {
+	policy := loadConcurrencyPolicy(jobCtx)
+
+	e.mu.Lock()
+	block := e.blockedLocked(jobCtx, policy)
+	if block == nil {
+		jobCtx.mutexGroup = policy.mutexGroup
+		e.runningJobs[jobCtx.PipelineRun.ID] = jobCtx
+		e.mu.Unlock()
+		go e.runJob(jobCtx)
+		return
+	}
+
+	priority := QueuePriorityNormal
+	if jobCtx.PipelineRun.TriggerType == models.TriggerManual {
+		priority = QueuePriorityManual
+	}
+	pos := e.queue.push(&queuedRun{jobCtx: jobCtx, priority: priority, enqueuedAt: time.Now(), block: block})
+	e.mu.Unlock()
+
+	jobCtx.PipelineRun.Status = models.RunStatusPending
+	if err := database.DB.Model(&models.PipelineRun{}).Where("id = ?", jobCtx.PipelineRun.ID).
+		Update("status", models.RunStatusPending).Error; err != nil {
+		log.Printf("流水线运行 %d 更新排队状态失败: %v", jobCtx.PipelineRun.ID, err)
+	}
+	switch block.reason {
+	case BlockedByMutexGroup:
+		e.logf(jobCtx, "log.run_queued_group", block.group, block.runID, pos+1)
+	case BlockedByWorkspace:
+		e.logf(jobCtx, "log.run_queued_workspace", block.runID, pos+1)
+	case BlockedByProjectLimit:
+		e.logf(jobCtx, "log.run_queued_project", block.limit, pos+1)
+	default:
+		e.logf(jobCtx, "log.run_queued", pos+1)
+	}
+}
+
+// dispatchQueued 按队列顺序启动全局并发与项目并发策略允许执行的运行，被策略阻塞的运行不影响其后的运行；
+// 运行结束与配置变化后调用
+func (e *Engine) dispatchQueued() {
+	if atomic.LoadInt32(&e.shuttingDown) == 1 {
+		return
+	}
+	for {
+		// 在锁外读取最新的并发策略
+		e.mu.RLock()
+		pending := make([]*JobContext, 0, len(e.queue.items))
+		for _, item := range e.queue.items {
+			pending = append(pending, item.jobCtx)
+		}
+		e.mu.RUnlock()
+		if len(pending) == 0 {
+			return
+		}
+		policies := make(map[uint]concurrencyPolicy, len(pending))
+		for _, jobCtx := range pending {
+			policies[jobCtx.PipelineRun.ID] = loadConcurrencyPolicy(jobCtx)
+		}
+
+		e.mu.Lock()
+		var next *queuedRun
+		for _, item := range e.queue.items {
+			policy, ok := policies[item.jobCtx.PipelineRun.ID]
+			if !ok {
+				// 读取策略之后才入队的运行由下一轮处理
+				continue
+			}
+			if item.block = e.blockedLocked(item.jobCtx, policy); item.block == nil {
+				next = item
+				item.jobCtx.mutexGroup = policy.mutexGroup
+				break
+			}
+			if item.block.reason == BlockedByGlobalLimit {
+				break
+			}
+		}
+		if next == nil {
+			e.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		e.queue.remove(next.jobCtx.PipelineRun.ID)
+		jobCtx := next.jobCtx
+		jobCtx.StartedAt = now
+		e.runningJobs[jobCtx.PipelineRun.ID] = jobCtx
+		e.mu.Unlock()
+
+		jobCtx.PipelineRun.Status = models.RunStatusRunning
+		if err := database.DB.Model(&models.PipelineRun{}).Where("id = ?", jobCtx.PipelineRun.ID).
+			Updates(map[string]interface{}{"status": models.RunStatusRunning, "start_time": &now}).Error; err != nil {
+			log.Printf("流水线运行 %d 更新开始状态失败: %v", jobCtx.PipelineRun.ID, err)
+		}
+		e.logf(jobCtx, "log.run_dequeued", now.Sub(next.enqueuedAt).Round(time.Second))
+		go e.runJob(jobCtx)
+	}
+}
+
+// maxConcurrent 同时执行的运行数上限
+func (e *Engine) maxConcurrent() int {
+	if e.worker != nil {
+		return e.config.Worker.Capacity
+	}
+	if n := e.config.Deploy.MaxConcurrent; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// QueuedRuns 按出队顺序列出等待中的运行
+func (e *Engine) QueuedRuns() []QueuedRun {
+	now := time.Now()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]QueuedRun, 0, len(e.queue.items))
+	for i, item := range e.queue.items {
+		jobCtx := item.jobCtx
+		view := QueuedRun{
+			Position:       i + 1,
+			RunID:          jobCtx.PipelineRun.ID,
+			PipelineID:     jobCtx.Pipeline.ID,
+			PipelineName:   jobCtx.Pipeline.Name,
+			ProjectID:      jobCtx.Project.ID,
+			ProjectName:    jobCtx.Project.Name,
+			Priority:       item.priority,
+			Promoted:       item.promoted,
+			TriggerType:    jobCtx.PipelineRun.TriggerType,
+			EnqueuedAt:     item.enqueuedAt,
+			WaitingSeconds: int64(now.Sub(item.enqueuedAt).Seconds()),
+		}
+		if block := item.block; block != nil {
+			view.BlockedBy = block.reason
+			view.BlockingLimit = block.limit
+			view.BlockingGroup = block.group
+			view.BlockingRunID = block.runID
+		}
+		result = append(result, view)
+	}
+	return result
+}
+
+// QueueStats 等待队列深度与最早入队运行的等待时长
+func (e *Engine) QueueStats() QueueStats {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	stats := QueueStats{
+		Depth:         len(e.queue.items),
+		MaxConcurrent: e.maxConcurrent(),
+		Running:       len(e.runningJobs),
+	}
+	for _, item := range e.queue.items {
+		if age := int64(time.Since(item.enqueuedAt).Seconds()); age > stats.OldestAgeSeconds {
+			stats.OldestAgeSeconds = age
+		}
+	}
+	return stats
+}
+
+// PromoteQueued 将等待中的运行移到队首，下一个空闲并发即执行它
+func (e *Engine) PromoteQueued(runID uint) error {
+	e.mu.Lock()
+	item := e.queue.remove(runID)
+	if item == nil {
+		e.mu.Unlock()
+		return ErrRunNotQueued
+	}
+	item.promoted = true
+	e.queue.items = append([]*queuedRun{item}, e.queue.items...)
+	e.mu.Unlock()
+
+	e.logf(item.jobCtx, "log.run_promoted")
+	return nil
+}
+
+// cancelQueued 从等待队列移除运行并标记为已取消，运行不在队列中时返回 false
+func (e *Engine) cancelQueued(runID uint, cancel Cancellation) (bool, error) {
+	e.mu.Lock()
+	item := e.queue.remove(runID)
+	e.mu.Unlock()
+	if item == nil {
+		return false, nil
+	}
+
+	jobCtx := item.jobCtx
+	cancel.apply(jobCtx.PipelineRun)
+	e.logf(jobCtx, "log.run_cancelled")
+	e.logf(jobCtx, "log.run_cancel_reason", jobCtx.PipelineRun.CancellationSummary())
+	e.logWriter.Flush(runID)
+	e.releaseJob(jobCtx)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":    models.RunStatusCancelled,
+		"end_time":  &now,
+		"error_msg": "流水线运行在排队时被取消",
+	}
+	for column, value := range cancel.columns() {
+		updates[column] = value
+	}
+	if err := database.DB.Model(&models.PipelineRun{}).Where("id = ?", runID).Updates(updates).Error; err != nil {
+		return true, fmt.Errorf("更新流水线运行状态失败: %w", err)
+	}
+	return true, nil
+}
+
+// QueuedRun 获取等待中的运行，用于权限校验
+func (e *Engine) QueuedRun(runID uint) (QueuedRun, bool) {
+	for _, item := range e.QueuedRuns() {
+		if item.RunID == runID {
+			return item, true
+		}
+	}
+	return QueuedRun{}, false
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"flowforge/pkg/models"
+)
+
+// rawOutputFile 脚本步骤声明 raw_output_artifact 时创建暂存完整原始输出的临时文件。
+// 运行日志中的输出会拆分超长行、隐藏二进制内容并限制总量，需要完整输出时另存为运行制品；未配置制品存储时返回 nil
+func (e *Engine) rawOutputFile(jobCtx *JobContext, step *models.PipelineStep) *os.File {
+	if keep, _ := step.Config["raw_output_artifact"].(bool); !keep {
+		return nil
+	}
+	if e.artifacts == nil {
+		e.logf(jobCtx, "log.raw_output_unavailable")
+		return nil
+	}
+
+	file, err := os.CreateTemp("", "flowforge-output-*")
+	if err != nil {
+		e.logf(jobCtx, "log.raw_output_failed", err)
+		return nil
+	}
+	return file
+}
+
+// saveRawOutput 将暂存的完整原始输出保存为运行制品，并删除临时文件
+func (e *Engine) saveRawOutput(jobCtx *JobContext, file *os.File) {
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		e.logf(jobCtx, "log.raw_output_failed", err)
+		return
+	}
+	name := fmt.Sprintf("step-%d-output.log", jobCtx.stepOrder)
+	saved, err := e.artifacts.Put(jobCtx.Context, jobCtx.PipelineRun.ID, name, file, "")
+	if err != nil {
+		log.Printf("流水线运行 %d 保存步骤完整输出失败: %v", jobCtx.PipelineRun.ID, err)
+		e.logf(jobCtx, "log.raw_output_failed", err)
+		return
+	}
+	e.logf(jobCtx, "log.raw_output_saved", saved.Name, saved.Size)
+}
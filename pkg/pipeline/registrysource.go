@@ -0,0 +1,240 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"flowforge/pkg/models"
+)
+
+// ErrArtifactRequired registry 项目只能指定制品触发运行
+var ErrArtifactRequired = errors.New("registry 项目需要指定部署的镜像或 tarball 制品")
+
+// ErrInvalidArtifact 制品引用格式错误或不属于项目的镜像仓库
+var ErrInvalidArtifact = errors.New("制品引用无效")
+
+// ErrRegistrySourceProject registry 项目只部署预先构建的制品
+var ErrRegistrySourceProject = errors.New("registry 项目不拉取 git 仓库，也不执行构建步骤")
+
+var (
+	// imageRepositoryPattern 镜像仓库名：可选的仓库地址（含端口）与小写的路径段
+	imageRepositoryPattern = regexp.MustCompile(`^(?:[A-Za-z0-9](?:[A-Za-z0-9.-]*[A-Za-z0-9])?(?::[0-9]+)?/)?[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	imageTagPattern        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	imageDigestPattern     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	sha256Pattern          = regexp.MustCompile(`^[a-f0-9]{64}$`)
+)
+
+// imageReference 解析后的镜像引用
+type imageReference struct {
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// String 规范形式 repository[:tag][@digest]
+func (r imageReference) String() string {
+	ref := r.Repository
+	if r.Tag != "" {
+		ref += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		ref += "@" + r.Digest
+	}
+	return ref
+}
+
+// parseImageReference 解析 repo:tag、repo@sha256:... 或 repo:tag@sha256:...；部署的镜像需要明确的标签或摘要，不默认 latest
+func parseImageReference(value string) (imageReference, error) {
+	var ref imageReference
+	rest := strings.TrimSpace(value)
+	if i := strings.Index(rest, "@"); i >= 0 {
+		rest, ref.Digest = rest[:i], rest[i+1:]
+		if !imageDigestPattern.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("%w: 镜像摘要应为 sha256:<64 位十六进制>", ErrInvalidArtifact)
+		}
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, ref.Tag = rest[:i], rest[i+1:]
+		if !imageTagPattern.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("%w: 镜像标签 %q 格式错误", ErrInvalidArtifact, ref.Tag)
+		}
+	}
+	if !imageRepositoryPattern.MatchString(rest) {
+		return ref, fmt.Errorf("%w: 镜像仓库名 %q 格式错误", ErrInvalidArtifact, rest)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		return ref, fmt.Errorf("%w: 镜像需要指定标签或摘要", ErrInvalidArtifact)
+	}
+	ref.Repository = rest
+	return ref, nil
+}
+
+// ValidateImageRepository 校验 registry 项目配置的镜像仓库名（不含标签与摘要），为空表示不限制
+func ValidateImageRepository(repository string) error {
+	if repository != "" && !imageRepositoryPattern.MatchString(repository) {
+		return fmt.Errorf("镜像仓库名格式错误: %s", repository)
+	}
+	return nil
+}
+
+// registryArtifact 校验后记录到运行的制品
+type registryArtifact struct {
+	kind   string
+	ref    string
+	digest string
+}
+
+// resolveArtifact 校验触发运行指定的制品：镜像需要标签或摘要，项目配置了镜像仓库时需属于该仓库；
+// tarball 需要 http(s) 地址与 sha256 校验和，地址中不能带凭据（会出现在运行记录与环境变量中）。
+// 非 registry 项目不能指定制品，返回 nil
+func resolveArtifact(project *models.Project, ref *models.ArtifactReference) (*registryArtifact, error) {
+	if !project.UsesRegistrySource() {
+		if ref != nil {
+			return nil, fmt.Errorf("%w: 只有 registry 项目可以指定制品", ErrInvalidArtifact)
+		}
+		return nil, nil
+	}
+	if ref == nil || (ref.Image == "" && ref.URL == "") {
+		return nil, ErrArtifactRequired
+	}
+	if ref.Image != "" && ref.URL != "" {
+		return nil, fmt.Errorf("%w: 镜像与 tarball 地址只能指定一个", ErrInvalidArtifact)
+	}
+
+	if ref.Image != "" {
+		image, err := parseImageReference(ref.Image)
+		if err != nil {
+			return nil, err
+		}
+		if want := strings.TrimSuffix(project.ImageRepository, "/"); want != "" && image.Repository != want {
+			return nil, fmt.Errorf("%w: 镜像 %s 不属于项目的镜像仓库 %s", ErrInvalidArtifact, image.Repository, want)
+		}
+		return &registryArtifact{kind: models.ArtifactKindImage, ref: image.String(), digest: image.Digest}, nil
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(ref.URL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: tarball 地址应为 http 或 https 地址", ErrInvalidArtifact)
+	}
+	if parsed.User != nil {
+		return nil, fmt.Errorf("%w: tarball 地址中不能包含凭据", ErrInvalidArtifact)
+	}
+	checksum := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ref.SHA256)), "sha256:")
+	if !sha256Pattern.MatchString(checksum) {
+		return nil, fmt.Errorf("%w: tarball 需要提供 sha256 校验和（64 位十六进制）", ErrInvalidArtifact)
+	}
+	return &registryArtifact{kind: models.ArtifactKindTarball, ref: parsed.String(), digest: "sha256:" + checksum}, nil
+}
+
+// apply 记录到运行，摘要同时代替提交哈希
+func (a *registryArtifact) apply(run *models.PipelineRun) {
+	run.ArtifactKind = a.kind
+	run.ArtifactRef = a.ref
+	run.ArtifactDigest = a.digest
+	run.CommitSHA = a.digest
+}
+
+// artifactReference 运行记录的制品，重新运行时按同一制品校验；不是 registry 项目的运行返回 nil
+func artifactReference(run *models.PipelineRun) *models.ArtifactReference {
+	switch run.ArtifactKind {
+	case models.ArtifactKindImage:
+		return &models.ArtifactReference{Image: run.ArtifactRef}
+	case models.ArtifactKindTarball:
+		return &models.ArtifactReference{URL: run.ArtifactRef, SHA256: run.ArtifactDigest}
+	}
+	return nil
+}
+
+// artifactEnv registry 运行的制品环境变量，部署步骤据此拉取镜像或下载并校验 tarball；其他运行返回 nil
+func artifactEnv(run *models.PipelineRun) [][2]string {
+	switch run.ArtifactKind {
+	case models.ArtifactKindImage:
+		image, err := parseImageReference(run.ArtifactRef)
+		if err != nil {
+			return nil
+		}
+		return [][2]string{
+			{"ARTIFACT_KIND", run.ArtifactKind},
+			{"ARTIFACT_REF", run.ArtifactRef},
+			{"ARTIFACT_IMAGE", run.ArtifactRef},
+			{"ARTIFACT_REPOSITORY", image.Repository},
+			{"ARTIFACT_TAG", image.Tag},
+			{"ARTIFACT_DIGEST", image.Digest},
+		}
+	case models.ArtifactKindTarball:
+		return [][2]string{
+			{"ARTIFACT_KIND", run.ArtifactKind},
+			{"ARTIFACT_REF", run.ArtifactRef},
+			{"ARTIFACT_URL", run.ArtifactRef},
+			{"ARTIFACT_SHA256", strings.TrimPrefix(run.ArtifactDigest, "sha256:")},
+		}
+	}
+	return nil
+}
+
+// validateRegistrySteps registry 项目没有仓库，也不构建：不能从仓库读取配置文件，不能使用 git_clone 与 build 步骤
+func validateRegistrySteps(configSource string, config *models.PipelineConfig) []string {
+	var problems []string
+	if configSource == models.ConfigSourceRepo {
+		problems = append(problems, "registry 项目不能从仓库读取流水线配置")
+	}
+	if config == nil {
+		return problems
+	}
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			if step.Type == "git_clone" || step.Type == "build" {
+				problems = append(problems, fmt.Sprintf("步骤 %s 使用 %s，registry 项目只部署预先构建的制品", step.Name, step.Type))
+			}
+		}
+	}
+	return problems
+}
+
+// releaseImageChange registry 项目发布的镜像与上次发布相比的变化，tarball 制品返回 nil
+func releaseImageChange(previousRef, ref string) *models.ReleaseImageChange {
+	current, err := parseImageReference(ref)
+	if err != nil {
+		return nil
+	}
+	change := &models.ReleaseImageChange{Repository: current.Repository, Tag: current.Tag, Digest: current.Digest}
+	if previous, err := parseImageReference(previousRef); err == nil {
+		change.PreviousRepository, change.PreviousTag, change.PreviousDigest = previous.Repository, previous.Tag, previous.Digest
+	}
+	return change
+}
+
+// describeImageChange 发布说明中的镜像变化，如 镜像 app：v1.2.0 → v1.3.0
+func describeImageChange(change *models.ReleaseImageChange) string {
+	version := func(tag, digest string) string {
+		switch {
+		case tag == "":
+			return shortDigest(digest)
+		case digest == "":
+			return tag
+		default:
+			return fmt.Sprintf("%s（%s）", tag, shortDigest(digest))
+		}
+	}
+	current := version(change.Tag, change.Digest)
+	previous := version(change.PreviousTag, change.PreviousDigest)
+	switch {
+	case change.PreviousRepository == "":
+		return fmt.Sprintf("镜像 %s：%s，首次发布", change.Repository, current)
+	case change.PreviousRepository != change.Repository:
+		return fmt.Sprintf("镜像 %s：%s → %s：%s", change.PreviousRepository, previous, change.Repository, current)
+	default:
+		return fmt.Sprintf("镜像 %s：%s → %s", change.Repository, previous, current)
+	}
+}
+
+// shortDigest 摘要的算法与前 12 位哈希
+func shortDigest(digest string) string {
+	if len(digest) > len("sha256:")+12 {
+		return digest[:len("sha256:")+12]
+	}
+	return digest
+}
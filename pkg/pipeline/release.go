@@ -0,0 +1,235 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"flowforge/pkg/git"
+	"flowforge/pkg/models"
+)
+
+// maxReleaseCommits 发布记录最多列出的提交数
+const maxReleaseCommits = 500
+
+// conventionalCommit conventional commit 格式的提交标题，如 feat(api): ...、fix!: ...
+var conventionalCommit = regexp.MustCompile(`^([A-Za-z]+)(\([^)]*\))?!?:`)
+
+// isReleaseEnvironment 部署环境是否生成发布记录
+func (e *Engine) isReleaseEnvironment(environment string) bool {
+	for _, name := range e.config.Deploy.ReleaseEnvironments {
+		if environment != "" && strings.EqualFold(name, environment) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRelease 部署到发布环境成功后生成发布记录：与该环境上次发布之间的提交按类型分组（registry 项目为镜像标签的变化），
+// 附带运行使用的制品摘要；按配置通知运行的关注者并在托管平台创建 release。失败只记录到运行日志，不影响部署结果
+func (e *Engine) recordRelease(jobCtx *JobContext, deployment *models.Deployment) {
+	if !e.isReleaseEnvironment(deployment.Environment) {
+		return
+	}
+
+	release := &models.Release{
+		Environment:   deployment.Environment,
+		Version:       deployment.Version,
+		CommitHash:    deployment.CommitHash,
+		DurationMs:    deployment.DurationMs,
+		ProjectID:     deployment.ProjectID,
+		DeploymentID:  deployment.ID,
+		PipelineRunID: jobCtx.PipelineRun.ID,
+		DeployedByID:  deployment.UserID,
+		Artifacts:     releaseArtifacts(jobCtx),
+		ArtifactRef:   jobCtx.PipelineRun.ArtifactRef,
+	}
+
+	var previous models.Release
+	if err := jobCtx.db().Where("project_id = ? AND environment = ?", release.ProjectID, release.Environment).
+		Order("id DESC").First(&previous).Error; err == nil {
+		release.PreviousID = &previous.ID
+		release.PreviousCommitHash = previous.CommitHash
+		release.PreviousArtifactRef = previous.ArtifactRef
+	}
+
+	changelog := e.releaseChangelog(jobCtx, release)
+	if data, err := json.Marshal(changelog); err == nil {
+		release.Changelog = string(data)
+	}
+
+	if err := jobCtx.db().Create(release).Error; err != nil {
+		e.logf(jobCtx, "log.release_failed", err)
+		return
+	}
+	e.logf(jobCtx, "log.release_recorded", release.ID, release.Environment, release.CommitCount)
+
+	if e.config.Deploy.ReleaseProvider {
+		e.publishProviderRelease(jobCtx, release, changelog)
+	}
+	if e.config.Deploy.ReleaseNotify && e.notifier != nil {
+		go e.notifier.NotifyRelease(release.ID, releaseNotes(release, changelog))
+	}
+}
+
+// releaseChangelog 计算与上次发布之间的提交。首次发布不列出提交；上次发布的提交不在本次历史中（强制推送）
+// 或工作区缺少历史时标记为范围未知。registry 项目没有提交历史，比较镜像的标签与摘要
+func (e *Engine) releaseChangelog(jobCtx *JobContext, release *models.Release) *models.ReleaseChangelog {
+	changelog := &models.ReleaseChangelog{}
+	if jobCtx.Project.UsesRegistrySource() {
+		changelog.Image = releaseImageChange(release.PreviousArtifactRef, release.ArtifactRef)
+		return changelog
+	}
+	if release.PreviousCommitHash == "" || release.CommitHash == "" || release.PreviousCommitHash == release.CommitHash {
+		return changelog
+	}
+
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	commits, truncated, err := e.gitManager.GetClient().CommitRange(workDir, release.PreviousCommitHash, release.CommitHash, maxReleaseCommits)
+	if err != nil {
+		if !errors.Is(err, git.ErrUnknownRange) {
+			log.Printf("流水线运行 %d 计算发布的提交范围失败: %v", jobCtx.PipelineRun.ID, err)
+		}
+		release.RangeUnknown = true
+		e.logf(jobCtx, "log.release_range_unknown", shortHash(release.PreviousCommitHash), shortHash(release.CommitHash))
+		return changelog
+	}
+
+	for _, commit := range commits {
+		switch commitType(commit.Subject) {
+		case "feat":
+			changelog.Features = append(changelog.Features, commit)
+		case "fix":
+			changelog.Fixes = append(changelog.Fixes, commit)
+		default:
+			changelog.Other = append(changelog.Other, commit)
+		}
+	}
+	changelog.Truncated = truncated
+	release.CommitCount = len(commits)
+	return changelog
+}
+
+// commitType 提交标题的 conventional commit 类型（小写），不符合格式时为空
+func commitType(subject string) string {
+	match := conventionalCommit.FindStringSubmatch(subject)
+	if match == nil {
+		return ""
+	}
+	return strings.ToLower(match[1])
+}
+
+// releaseArtifacts 运行产出与使用的制品摘要，序列化为 JSON
+func releaseArtifacts(jobCtx *JobContext) string {
+	var artifacts []models.ReleaseArtifact
+	var produced []models.Artifact
+	jobCtx.db().Where("pipeline_run_id = ?", jobCtx.PipelineRun.ID).Order("id").Find(&produced)
+	for _, artifact := range produced {
+		artifacts = append(artifacts, models.ReleaseArtifact{Name: artifact.Name, Digest: artifact.Digest})
+	}
+
+	var consumed []models.ArtifactConsumption
+	jobCtx.db().Preload("ConsumedArtifact").Where("pipeline_run_id = ?", jobCtx.PipelineRun.ID).Order("id").Find(&consumed)
+	for _, consumption := range consumed {
+		if consumption.ConsumedArtifact != nil {
+			artifacts = append(artifacts, models.ReleaseArtifact{
+				Name:   consumption.ConsumedArtifact.Name,
+				Digest: consumption.ConsumedArtifact.Digest,
+			})
+		}
+	}
+
+	if len(artifacts) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(artifacts)
+	return string(data)
+}
+
+// publishProviderRelease 在托管平台创建以版本号为标签的 release，结果记录到发布记录
+func (e *Engine) publishProviderRelease(jobCtx *JobContext, release *models.Release, changelog *models.ReleaseChangelog) {
+	client := e.gitManager.GetClient()
+	if !client.CanRegisterDeployKey(jobCtx.Project.RepoURL) {
+		return
+	}
+
+	providerURL, err := client.CreateRelease(jobCtx.Context, jobCtx.Project.RepoURL, git.ReleaseOptions{
+		Tag:    release.Version,
+		Commit: release.CommitHash,
+		Name:   fmt.Sprintf("%s %s", release.Version, release.Environment),
+		Body:   releaseNotes(release, changelog),
+	})
+	updates := map[string]interface{}{"provider_url": providerURL}
+	if err != nil {
+		updates["provider_error"] = err.Error()
+		e.logf(jobCtx, "log.warning", err)
+	}
+	if err := jobCtx.db().Model(release).Updates(updates).Error; err != nil {
+		log.Printf("保存发布 %d 的平台 release 失败: %v", release.ID, err)
+	}
+}
+
+// releaseNotes 发布记录的 Markdown 说明，用于通知与托管平台的 release
+func releaseNotes(release *models.Release, changelog *models.ReleaseChangelog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s（%s）\n\n", release.Version, release.Environment)
+	if release.ArtifactRef != "" {
+		fmt.Fprintf(&b, "制品: %s，耗时 %d ms\n", release.ArtifactRef, release.DurationMs)
+	} else {
+		fmt.Fprintf(&b, "提交: %s，耗时 %d ms\n", shortHash(release.CommitHash), release.DurationMs)
+	}
+
+	switch {
+	case changelog.Image != nil:
+		fmt.Fprintf(&b, "\n%s\n", describeImageChange(changelog.Image))
+	case release.ArtifactRef != "":
+		// tarball 制品只列出上次发布的地址
+		if release.PreviousArtifactRef != "" && release.PreviousArtifactRef != release.ArtifactRef {
+			fmt.Fprintf(&b, "\n上次发布: %s\n", release.PreviousArtifactRef)
+		}
+	case release.PreviousCommitHash == "":
+		b.WriteString("\n首次发布，未列出提交\n")
+	case release.RangeUnknown:
+		fmt.Fprintf(&b, "\n无法确定与上次发布 %s 之间的提交范围（可能被强制推送覆盖）\n", shortHash(release.PreviousCommitHash))
+	default:
+		writeCommits(&b, "新功能", changelog.Features)
+		writeCommits(&b, "问题修复", changelog.Fixes)
+		writeCommits(&b, "其他", changelog.Other)
+		if changelog.Truncated {
+			fmt.Fprintf(&b, "\n提交过多，只列出最近的 %d 个\n", release.CommitCount)
+		}
+	}
+
+	if release.Artifacts != "" {
+		var artifacts []models.ReleaseArtifact
+		if json.Unmarshal([]byte(release.Artifacts), &artifacts) == nil && len(artifacts) > 0 {
+			b.WriteString("\n### 制品\n\n")
+			for _, artifact := range artifacts {
+				fmt.Fprintf(&b, "- %s sha256:%s\n", artifact.Name, artifact.Digest)
+			}
+		}
+	}
+	return b.String()
+}
+
+// writeCommits 写入一组提交，没有提交时不写
+func writeCommits(b *strings.Builder, title string, commits []models.ReleaseCommit) {
+	if len(commits) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n### %s\n\n", title)
+	for _, commit := range commits {
+		fmt.Fprintf(b, "- %s %s（%s）\n", shortHash(commit.Hash), commit.Subject, commit.Author)
+	}
+}
+
+// shortHash 提交哈希的前 8 位
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
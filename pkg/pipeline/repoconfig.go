@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// allowedStepsConfigKey 系统配置中仓库配置文件允许的步骤类型
+const allowedStepsConfigKey = "repo_config_allowed_steps"
+
+// defaultAllowedSteps 管理员未配置时仓库配置文件允许的步骤类型
+const defaultAllowedSteps = "git_clone,script,build"
+
+// envReference 环境变量值中对项目变量的引用，形如 ${NAME}
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// RepoConfigFile 本次运行从仓库读取的配置文件
+type RepoConfigFile struct {
+	Path    string
+	Commit  string
+	Content string
+}
+
+// loadRepoConfig 引导步骤：先拉取代码，再读取触发提交中的配置文件并校验，校验失败时返回全部问题
+func (e *Engine) loadRepoConfig(jobCtx *JobContext) (*models.PipelineConfig, error) {
+	configPath := jobCtx.Pipeline.ConfigPath
+	if configPath == "" {
+		configPath = ".flowforge.yml"
+	}
+	e.logf(jobCtx, "log.repo_config_bootstrap", configPath)
+
+	bootstrap := &models.PipelineStep{Name: "bootstrap", Type: "git_clone"}
+	if err := e.executeGitClone(jobCtx, bootstrap); err != nil {
+		return nil, err
+	}
+
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	content, commit, err := e.gitManager.GetClient().ReadFileAt(jobCtx.Context, jobCtx.Project, jobCtx.Project.SSHKey,
+		workDir, jobCtx.PipelineRun.CommitSHA, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取仓库配置文件失败: %w", err)
+	}
+
+	jobCtx.RepoConfig = &RepoConfigFile{Path: configPath, Commit: commit, Content: string(content)}
+	// 记录实际读取的提交，重跑时读取同一份配置
+	if jobCtx.PipelineRun.CommitSHA == "" {
+		jobCtx.PipelineRun.CommitSHA = commit
+		jobCtx.db().Model(jobCtx.PipelineRun).Update("commit_sha", commit)
+	}
+	e.logf(jobCtx, "log.repo_config_loaded", configPath, commit[:8])
+
+	config, err := ParseConfig(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	problems := ValidatePipelineConfig(config)
+	problems = append(problems, validateRepoConfigAccess(jobCtx.Project, config)...)
+	problems = append(problems, e.localShellProblems(jobCtx.Project, config)...)
+
+	// 引用未定义的环境变量：开启 strict_env 时作为问题拒绝运行，否则只在日志中提示
+	envProblems := EnvFindingProblems(CheckEnvReferences(jobCtx.Project, config, jobCtx.Pipeline.EnvIgnoreList()))
+	if jobCtx.Pipeline.StrictEnv {
+		problems = append(problems, envProblems...)
+	} else {
+		for _, problem := range envProblems {
+			e.logf(jobCtx, "log.env_undefined_ref", problem)
+		}
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			e.logf(jobCtx, "log.repo_config_problem", problem)
+		}
+		return nil, fmt.Errorf("配置文件 %s 校验失败，共 %d 个问题", configPath, len(problems))
+	}
+
+	return config, nil
+}
+
+// ValidatePipelineConfig 校验配置结构，返回 CheckConfig 发现的全部问题的说明
+func ValidatePipelineConfig(config *models.PipelineConfig) []string {
+	var problems []string
+	for _, problem := range CheckConfig(config) {
+		problems = append(problems, problem.Message)
+	}
+	return problems
+}
+
+// validateRepoConfigAccess 仓库配置文件不受流水线编辑权限保护，
+// 因此限制其步骤类型，且只能引用项目自身可用的SSH密钥和环境变量
+func validateRepoConfigAccess(project *models.Project, config *models.PipelineConfig) []string {
+	var problems []string
+
+	allowed := allowedRepoSteps()
+	keys := projectSSHKeys(project)
+
+	var envs []models.Environment
+	database.DB.Where("project_id = ?", project.ID).Find(&envs)
+	envKeys := make(map[string]bool, len(envs))
+	for _, env := range envs {
+		envKeys[env.Key] = true
+	}
+
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			if allowed != nil && !allowed[step.Type] {
+				problems = append(problems, fmt.Sprintf("步骤 %s 的类型 %s 未被管理员允许在仓库配置中使用", step.Name, step.Type))
+			}
+
+			if raw, ok := step.Config["ssh_key_id"]; ok {
+				keyID, _ := raw.(float64)
+				if !keys[uint(keyID)] {
+					problems = append(problems, fmt.Sprintf("步骤 %s 引用的SSH密钥 %v 不属于当前项目", step.Name, raw))
+				}
+			}
+
+			envVars, _ := step.Config["env"].(map[string]interface{})
+			for name, value := range envVars {
+				text, _ := value.(string)
+				for _, match := range envReference.FindAllStringSubmatch(text, -1) {
+					if !envKeys[match[1]] {
+						problems = append(problems, fmt.Sprintf("步骤 %s 的环境变量 %s 引用了项目中不存在的变量 %s", step.Name, name, match[1]))
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// allowedRepoSteps 读取管理员配置的步骤类型白名单，返回 nil 表示不限制
+func allowedRepoSteps() map[string]bool {
+	value := defaultAllowedSteps
+	if setting, ok, err := database.Setting(context.Background(), allowedStepsConfigKey); err == nil && ok {
+		value = setting
+	}
+
+	if strings.TrimSpace(value) == "*" {
+		return nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			allowed[item] = true
+		}
+	}
+	return allowed
+}
+
+// projectSSHKeys 项目可以使用的SSH密钥：项目绑定的密钥与项目的部署密钥
+func projectSSHKeys(project *models.Project) map[uint]bool {
+	keys := make(map[uint]bool)
+	if project.SSHKeyID != nil {
+		keys[*project.SSHKeyID] = true
+	}
+	if project.DeployKeyID != nil {
+		keys[*project.DeployKeyID] = true
+	}
+
+	var ids []uint
+	database.DB.Model(&models.SSHKey{}).Where("project_id = ? AND revoked_at IS NULL", project.ID).Pluck("id", &ids)
+	for _, id := range ids {
+		keys[id] = true
+	}
+	return keys
+}
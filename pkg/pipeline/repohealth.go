@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"log"
+
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// checkRepoRedirect 拉取代码前检查 HTTP(S) 远程是否通过重定向报告仓库已迁移，迁移时在运行日志中提示并记录到项目，
+// 由项目所有者确认后接受新地址；检查失败时不处理，网络问题由拉取代码报告
+func (e *Engine) checkRepoRedirect(jobCtx *JobContext) {
+	project := jobCtx.Project
+	movedTo, err := e.gitManager.GetClient().DetectRedirect(jobCtx.stepContext(), project.RepoURL)
+	if err != nil {
+		return
+	}
+	if movedTo != "" {
+		e.logf(jobCtx, "log.repo_moved", movedTo)
+	}
+	if movedTo == project.RepoMovedTo {
+		return
+	}
+
+	project.RepoMovedTo = movedTo
+	if err := jobCtx.db().Model(&models.Project{}).Where("id = ?", project.ID).
+		UpdateColumn("repo_moved_to", movedTo).Error; err != nil {
+		log.Printf("记录项目 %d 的仓库迁移状态失败: %v", project.ID, err)
+	}
+}
+
+// recordCheckout 按拉取代码的结果更新连续报告仓库不存在的运行数：SSH 远程不会通过重定向报告仓库迁移，
+// 连续达到配置的次数时在运行日志中提示，项目健康状态标记为 error；拉取成功或因其他原因失败时清零
+func (e *Engine) recordCheckout(jobCtx *JobContext, repoNotFound bool) {
+	project := jobCtx.Project
+	query := jobCtx.db().Model(&models.Project{}).Where("id = ?", project.ID)
+
+	if !repoNotFound {
+		if project.RepoNotFoundRuns != 0 {
+			project.RepoNotFoundRuns = 0
+			query.UpdateColumn("repo_not_found_runs", 0)
+		}
+		return
+	}
+
+	// 同一项目的运行可能并发执行，计数在数据库中累加
+	if err := query.UpdateColumn("repo_not_found_runs", gorm.Expr("repo_not_found_runs + 1")).Error; err != nil {
+		log.Printf("记录项目 %d 的仓库不存在次数失败: %v", project.ID, err)
+		return
+	}
+	jobCtx.db().Model(&models.Project{}).Select("repo_not_found_runs").Where("id = ?", project.ID).Scan(&project.RepoNotFoundRuns)
+	if threshold := e.config.Git.RepoNotFoundRuns; threshold > 0 && project.RepoNotFoundRuns >= threshold {
+		e.logf(jobCtx, "log.repo_not_found_repeated", project.RepoNotFoundRuns)
+	}
+}
@@ -0,0 +1,27 @@
+package pipeline
+
+import (
+	"time"
+
+	"flowforge/pkg/retry"
+)
+
+// logRetryEvent 将运行中请求的重试与熔断决定写入运行日志，便于了解请求为什么没有发出
+func (j *JobContext) logRetryEvent(event retry.Event) {
+	if j.engine == nil {
+		return
+	}
+
+	switch event.Kind {
+	case retry.EventRetry:
+		j.engine.logf(j, "log.retry_attempt", event.Destination, event.Attempt, event.Err, event.Delay.Round(time.Millisecond))
+	case retry.EventTripped:
+		j.engine.logf(j, "log.circuit_tripped", event.Destination, event.Err)
+	case retry.EventCircuitOpen:
+		j.engine.logf(j, "log.circuit_open", event.Destination, int(event.Delay.Seconds())+1)
+	case retry.EventProbe:
+		j.engine.logf(j, "log.circuit_probe", event.Destination)
+	case retry.EventRecovered:
+		j.engine.logf(j, "log.circuit_recovered", event.Destination)
+	}
+}
@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"log"
+	"strconv"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/events"
+	"flowforge/pkg/models"
+	"flowforge/pkg/runlabel"
+)
+
+// publishRunEvent 记录运行开始或结束的系统事件，失败只记录日志不影响运行
+func (e *Engine) publishRunEvent(jobCtx *JobContext, eventType, status string) {
+	if !events.Enabled() {
+		return
+	}
+
+	run := jobCtx.PipelineRun
+	data := map[string]interface{}{
+		"status":       status,
+		"run_number":   run.RunNumber,
+		"trigger_type": run.TriggerType,
+		"commit_sha":   run.CommitSHA,
+	}
+	if eventType == events.TypeRunFinished {
+		data["failure_kind"] = run.FailureKind
+		if run.CancellationReason != "" {
+			data["cancellation_reason"] = run.CancellationReason
+		}
+	}
+	if labels, err := runlabel.ForRun(run.ID); err == nil && len(labels) > 0 {
+		data["labels"] = labels
+	}
+
+	e.publish(jobCtx, events.Event{
+		Type:     eventType,
+		Resource: events.Resource{Type: "pipeline_run", ID: strconv.FormatUint(uint64(run.ID), 10)},
+		Outcome:  events.RunOutcome(status),
+		Data:     data,
+	})
+}
+
+// publishDeploymentEvent 记录部署结果的系统事件
+func (e *Engine) publishDeploymentEvent(jobCtx *JobContext, deployment *models.Deployment, target string) {
+	if !events.Enabled() {
+		return
+	}
+
+	eventType, outcome := events.TypeDeploymentSucceeded, events.OutcomeSuccess
+	if deployment.Status != models.DeployStatusSuccess {
+		eventType, outcome = events.TypeDeploymentFailed, events.OutcomeFailure
+	}
+
+	e.publish(jobCtx, events.Event{
+		Type:     eventType,
+		Resource: events.Resource{Type: "deployment", ID: strconv.FormatUint(uint64(deployment.ID), 10)},
+		Outcome:  outcome,
+		Data: map[string]interface{}{
+			"version":     deployment.Version,
+			"commit_sha":  deployment.CommitHash,
+			"target":      target,
+			"duration":    deployment.Duration,
+			"duration_ms": deployment.DurationMs,
+			"error":       deployment.ErrorMsg,
+		},
+	})
+}
+
+// publish 补全运行相关事件的操作者、所属资源与关联ID后写入发件箱
+func (e *Engine) publish(jobCtx *JobContext, event events.Event) {
+	event.Actor = events.UserActor(jobCtx.PipelineRun.UserID)
+	event.Resource.ProjectID = jobCtx.Project.ID
+	event.Resource.PipelineID = jobCtx.Pipeline.ID
+	event.Resource.RunID = jobCtx.PipelineRun.ID
+	event.CorrelationID = events.RunCorrelationID(jobCtx.PipelineRun.ID)
+
+	// 运行结束与取消的事件在运行取消后仍需写入发件箱
+	if err := events.Publish(database.DB.WithContext(jobCtx.detached()), event); err != nil {
+		log.Printf("流水线运行 %d 记录系统事件失败: %v", jobCtx.PipelineRun.ID, err)
+	}
+}
@@ -0,0 +1,379 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSharedResourceNotFound 共享资源或其版本不存在
+	ErrSharedResourceNotFound = errors.New("共享资源不存在")
+	// ErrSharedResourceExists 同类型下已有同名的共享资源
+	ErrSharedResourceExists = errors.New("同名的共享资源已存在")
+	// ErrInvalidSharedResource 共享资源的类型、名称或内容无效
+	ErrInvalidSharedResource = errors.New("共享资源无效")
+	// ErrVersionInUse 版本仍被保留的运行引用，运行被保留策略清理后才能删除
+	ErrVersionInUse = errors.New("共享资源版本仍被保留的运行引用，不能删除")
+	// ErrLatestVersion 最新版本是新运行使用的内容，不能删除
+	ErrLatestVersion = errors.New("不能删除共享资源的最新版本")
+)
+
+// sharedResourceName 共享资源名称：字母或数字开头，可包含 _ . -，最长 128 个字符
+var sharedResourceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// envVarName 环境变量组中的变量名
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsSharedResourceKind 是否为支持的共享资源类型
+func IsSharedResourceKind(kind string) bool {
+	switch kind {
+	case models.SharedResourceScript, models.SharedResourceEnvGroup, models.SharedResourceTemplate:
+		return true
+	}
+	return false
+}
+
+// ValidateSharedContent 校验共享资源的内容：脚本不能为空；环境变量组为变量名到字符串取值的 JSON 对象；
+// 模板为有效的流水线配置，且不能再引用其他模板
+func ValidateSharedContent(kind, content string) error {
+	switch kind {
+	case models.SharedResourceScript:
+		if strings.TrimSpace(content) == "" {
+			return fmt.Errorf("%w: 脚本内容不能为空", ErrInvalidSharedResource)
+		}
+	case models.SharedResourceEnvGroup:
+		if _, err := parseEnvGroup(content); err != nil {
+			return err
+		}
+	case models.SharedResourceTemplate:
+		config, err := LoadConfig(content)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSharedResource, err)
+		}
+		if config.Template != "" {
+			return fmt.Errorf("%w: 模板不能引用其他模板", ErrInvalidSharedResource)
+		}
+	default:
+		return fmt.Errorf("%w: 不支持的类型 %q", ErrInvalidSharedResource, kind)
+	}
+	return nil
+}
+
+// parseEnvGroup 解析环境变量组的内容
+func parseEnvGroup(content string) (map[string]string, error) {
+	var vars map[string]string
+	if err := json.Unmarshal([]byte(content), &vars); err != nil {
+		return nil, fmt.Errorf("%w: 环境变量组应为变量名到字符串取值的 JSON 对象", ErrInvalidSharedResource)
+	}
+	for name := range vars {
+		if !envVarName.MatchString(name) {
+			return nil, fmt.Errorf("%w: 无效的变量名 %q", ErrInvalidSharedResource, name)
+		}
+	}
+	return vars, nil
+}
+
+// CreateSharedResource 创建共享资源，内容保存为版本 1
+func CreateSharedResource(kind, name, description, content string, createdByID *uint) (*models.SharedResource, error) {
+	if !IsSharedResourceKind(kind) {
+		return nil, fmt.Errorf("%w: 不支持的类型 %q", ErrInvalidSharedResource, kind)
+	}
+	if !sharedResourceName.MatchString(name) {
+		return nil, fmt.Errorf("%w: 无效的名称 %q", ErrInvalidSharedResource, name)
+	}
+	if err := ValidateSharedContent(kind, content); err != nil {
+		return nil, err
+	}
+
+	resource := &models.SharedResource{Kind: kind, Name: name, Description: description, LatestVersion: 1, CreatedByID: createdByID}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.SharedResource{}).Where("kind = ? AND name = ?", kind, name).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("%w: %s", ErrSharedResourceExists, name)
+		}
+		if err := tx.Create(resource).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SharedResourceVersion{ResourceID: resource.ID, Version: 1, Content: content, CreatedByID: createdByID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// UpdateSharedResource 修改共享资源：内容与最新版本不同时生成新版本，版本号在最新版本号上加一；
+// 内容没有变化时只更新说明，返回最新版本。description 为 nil 时不修改说明
+func UpdateSharedResource(id uint, description *string, content string, createdByID *uint) (*models.SharedResourceVersion, error) {
+	var version *models.SharedResourceVersion
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var resource models.SharedResource
+		if err := tx.First(&resource, id).Error; err != nil {
+			return ErrSharedResourceNotFound
+		}
+		if err := ValidateSharedContent(resource.Kind, content); err != nil {
+			return err
+		}
+		if description != nil {
+			if err := tx.Model(&resource).Update("description", *description).Error; err != nil {
+				return err
+			}
+		}
+
+		var latest models.SharedResourceVersion
+		if err := tx.Where("resource_id = ? AND version = ?", id, resource.LatestVersion).First(&latest).Error; err == nil && latest.Content == content {
+			version = &latest
+			return nil
+		}
+
+		// 在数据库中递增版本号，同时修改同一资源的请求依次得到不同的版本号
+		if err := tx.Model(&models.SharedResource{}).Where("id = ?", id).
+			Update("latest_version", gorm.Expr("latest_version + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("latest_version").First(&resource, id).Error; err != nil {
+			return err
+		}
+		version = &models.SharedResourceVersion{ResourceID: id, Version: resource.LatestVersion, Content: content, CreatedByID: createdByID}
+		return tx.Create(version).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// DeleteSharedVersion 删除共享资源的旧版本。最新版本不能删除；仍被运行记录引用的版本不能删除，
+// 运行超过保留期被清理后不再计入引用
+func DeleteSharedVersion(id uint, version int) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var resource models.SharedResource
+		if err := tx.First(&resource, id).Error; err != nil {
+			return ErrSharedResourceNotFound
+		}
+		if version == resource.LatestVersion {
+			return ErrLatestVersion
+		}
+
+		var runs int64
+		if err := tx.Model(&models.RunResourceVersion{}).
+			Joins("JOIN pipeline_runs ON pipeline_runs.id = run_resource_versions.pipeline_run_id").
+			Where("run_resource_versions.resource_id = ? AND run_resource_versions.version = ?", id, version).
+			Where("pipeline_runs.deleted_at IS NULL").
+			Count(&runs).Error; err != nil {
+			return err
+		}
+		if runs > 0 {
+			return fmt.Errorf("%w: %d 个运行", ErrVersionInUse, runs)
+		}
+
+		result := tx.Where("resource_id = ? AND version = ?", id, version).Delete(&models.SharedResourceVersion{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSharedResourceNotFound
+		}
+		return nil
+	})
+}
+
+// SharedResourceURL 查看共享资源某个版本内容的接口
+func SharedResourceURL(resourceID uint, version string) string {
+	return fmt.Sprintf("/api/v1/shared-resources/%d/versions/%s", resourceID, version)
+}
+
+// sharedResolver 解析一次运行引用的共享资源，pinned 中的资源使用记录的版本，其余使用最新版本
+type sharedResolver struct {
+	db     *gorm.DB
+	pinned map[uint]int
+	used   []models.RunResourceVersion
+	seen   map[uint]bool
+}
+
+// resolve 按类型与名称取得资源使用的版本，同一资源只记录一次
+func (r *sharedResolver) resolve(kind, name string) (*models.SharedResourceVersion, error) {
+	var resource models.SharedResource
+	if err := r.db.Where("kind = ? AND name = ?", kind, name).First(&resource).Error; err != nil {
+		return nil, fmt.Errorf("引用的共享%s %s 不存在", sharedKindName(kind), name)
+	}
+	number, ok := r.pinned[resource.ID]
+	if !ok {
+		number = resource.LatestVersion
+	}
+
+	var version models.SharedResourceVersion
+	if err := r.db.Where("resource_id = ? AND version = ?", resource.ID, number).First(&version).Error; err != nil {
+		return nil, fmt.Errorf("共享%s %s 的版本 %d 不存在", sharedKindName(kind), name, number)
+	}
+	if !r.seen[resource.ID] {
+		r.seen[resource.ID] = true
+		r.used = append(r.used, models.RunResourceVersion{ResourceID: resource.ID, Version: number, Kind: kind, Name: name})
+	}
+	return &version, nil
+}
+
+// sharedKindName 错误信息中的资源类型名称
+func sharedKindName(kind string) string {
+	switch kind {
+	case models.SharedResourceScript:
+		return "脚本"
+	case models.SharedResourceEnvGroup:
+		return "环境变量组"
+	case models.SharedResourceTemplate:
+		return "模板"
+	}
+	return kind
+}
+
+// resolveSharedResources 解析配置引用的共享资源并写入配置：模板的阶段加在流水线自己的阶段之前，
+// 环境变量组合并为 env_group 来源层的变量，脚本步骤的 script_ref 替换为共享脚本的内容。
+// 运行已记录版本的资源（按原始输入重新运行、恢复外部等待）沿用记录的版本，其余使用最新版本并记录到 RunResourceVersion，
+// 使用的版本同时保存在 jobCtx.sharedInputs 中随配置快照保存
+func (e *Engine) resolveSharedResources(jobCtx *JobContext, config *models.PipelineConfig) error {
+	db := jobCtx.db()
+	var recorded []models.RunResourceVersion
+	if err := db.Where("pipeline_run_id = ?", jobCtx.PipelineRun.ID).Find(&recorded).Error; err != nil {
+		return fmt.Errorf("读取运行使用的共享资源版本失败: %w", err)
+	}
+	resolver := &sharedResolver{db: db, pinned: make(map[uint]int, len(recorded)), seen: make(map[uint]bool)}
+	for _, item := range recorded {
+		resolver.pinned[item.ResourceID] = item.Version
+	}
+
+	if config.Template != "" {
+		version, err := resolver.resolve(models.SharedResourceTemplate, config.Template)
+		if err != nil {
+			return err
+		}
+		template, err := ParseConfig(version.Content)
+		if err != nil {
+			return fmt.Errorf("共享模板 %s 的版本 %d 无效: %w", config.Template, version.Version, err)
+		}
+		config.Stages = append(template.Stages, config.Stages...)
+		config.EnvGroups = append(template.EnvGroups, config.EnvGroups...)
+	}
+
+	for _, name := range config.EnvGroups {
+		version, err := resolver.resolve(models.SharedResourceEnvGroup, name)
+		if err != nil {
+			return err
+		}
+		vars, err := parseEnvGroup(version.Content)
+		if err != nil {
+			return fmt.Errorf("共享环境变量组 %s 的版本 %d 无效: %w", name, version.Version, err)
+		}
+		if jobCtx.envGroups == nil {
+			jobCtx.envGroups = make(map[string]string, len(vars))
+		}
+		for key, value := range vars {
+			jobCtx.envGroups[key] = value
+		}
+	}
+
+	for i := range config.Stages {
+		for j := range config.Stages[i].Steps {
+			step := &config.Stages[i].Steps[j]
+			ref, ok := step.Config["script_ref"].(string)
+			if !ok || step.Type != "script" {
+				continue
+			}
+			version, err := resolver.resolve(models.SharedResourceScript, ref)
+			if err != nil {
+				return fmt.Errorf("步骤 %s %w", step.Name, err)
+			}
+			step.Config["script"] = version.Content
+		}
+	}
+
+	for i := range resolver.used {
+		item := &resolver.used[i]
+		if _, ok := resolver.pinned[item.ResourceID]; !ok {
+			item.PipelineRunID = jobCtx.PipelineRun.ID
+			if err := db.Create(item).Error; err != nil {
+				return fmt.Errorf("记录运行使用的共享资源版本失败: %w", err)
+			}
+		}
+		jobCtx.sharedInputs = append(jobCtx.sharedInputs, models.RunInput{
+			Kind:       item.Kind,
+			Name:       item.Name,
+			Version:    strconv.Itoa(item.Version),
+			ResourceID: item.ResourceID,
+		})
+	}
+	return nil
+}
+
+// sharedEnvKeys 配置引用的环境变量组中最新版本定义的变量名，引用不存在的组时忽略
+func sharedEnvKeys(config *models.PipelineConfig) []string {
+	var keys []string
+	for _, name := range config.EnvGroups {
+		var version models.SharedResourceVersion
+		if err := database.DB.Joins("JOIN shared_resources ON shared_resources.id = shared_resource_versions.resource_id").
+			Where("shared_resources.kind = ? AND shared_resources.name = ? AND shared_resource_versions.version = shared_resources.latest_version",
+				models.SharedResourceEnvGroup, name).
+			First(&version).Error; err != nil {
+			continue
+		}
+		vars, _ := parseEnvGroup(version.Content)
+		for key := range vars {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateSharedReferences 保存流水线时校验配置引用的共享模板、环境变量组与脚本存在，返回问题说明
+func ValidateSharedReferences(config *models.PipelineConfig) []string {
+	var problems []string
+	exists := func(kind, name string) {
+		var count int64
+		database.DB.Model(&models.SharedResource{}).Where("kind = ? AND name = ?", kind, name).Count(&count)
+		if count == 0 {
+			problems = append(problems, fmt.Sprintf("引用的共享%s %s 不存在", sharedKindName(kind), name))
+		}
+	}
+	if config.Template != "" {
+		exists(models.SharedResourceTemplate, config.Template)
+	}
+	for _, name := range config.EnvGroups {
+		exists(models.SharedResourceEnvGroup, name)
+	}
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			if ref, ok := step.Config["script_ref"].(string); ok && step.Type == "script" {
+				exists(models.SharedResourceScript, ref)
+			}
+		}
+	}
+	return problems
+}
+
+// copyResourceVersions 按原运行使用的共享资源版本记录新运行的版本，新运行解析配置时沿用
+func copyResourceVersions(originalID, runID uint) error {
+	var recorded []models.RunResourceVersion
+	if err := database.DB.Where("pipeline_run_id = ?", originalID).Order("id").Find(&recorded).Error; err != nil {
+		return err
+	}
+	for _, item := range recorded {
+		pinned := models.RunResourceVersion{PipelineRunID: runID, ResourceID: item.ResourceID, Version: item.Version, Kind: item.Kind, Name: item.Name}
+		if err := database.DB.Create(&pinned).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// sharedScriptPipeline 单个脚本步骤引用共享脚本 ref 的流水线配置
+const sharedScriptPipeline = `stages:
+  - name: build
+    steps:
+      - name: build
+        type: script
+        config:
+          script_ref: build-sh
+`
+
+// createShared 创建共享资源
+func createShared(t *testing.T, kind, name, content string) *models.SharedResource {
+	t.Helper()
+	resource, err := CreateSharedResource(kind, name, "", content, nil)
+	if err != nil {
+		t.Fatalf("创建共享资源失败: %v", err)
+	}
+	return resource
+}
+
+// savedRevision 为流水线当前的配置保存版本 1
+func savedRevision(t *testing.T, pipeline *models.Pipeline) {
+	t.Helper()
+	revision := models.PipelineRevision{PipelineID: pipeline.ID, Revision: 1, Name: pipeline.Name, Config: pipeline.Config}
+	if err := database.DB.Create(&revision).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// inputVersion 运行输入中 kind/name 的版本与查看地址，没有时为空
+func inputVersion(run *models.PipelineRun, kind, name string) (string, string) {
+	for _, input := range RunInputs(run) {
+		if input.Kind == kind && input.Name == name {
+			return input.Version, input.URL
+		}
+	}
+	return "", ""
+}
+
+// readWorkspace 读取项目工作区中的文件
+func readWorkspace(t *testing.T, e *Engine, project *models.Project, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(e.workspaceDir(project.ID), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestSharedScriptVersionPerRun 两次运行之间修改共享脚本，每次运行执行并报告各自使用的版本；
+// 按原始输入重新运行沿用原运行的版本，否则使用最新版本
+func TestSharedScriptVersionPerRun(t *testing.T) {
+	e, project := setupEngineTest(t)
+	script := createShared(t, models.SharedResourceScript, "build-sh", "echo v1 >> used.txt")
+	pipeline := createPipeline(t, project, "build", sharedScriptPipeline)
+	savedRevision(t, pipeline)
+
+	first := waitRun(t, e, startRun(t, e, pipeline).ID)
+	version, err := UpdateSharedResource(script.ID, nil, "echo v2 >> used.txt", nil)
+	if err != nil || version.Version != 2 {
+		t.Fatalf("修改后的版本为 %+v（%v），应为 2", version, err)
+	}
+	second := waitRun(t, e, startRun(t, e, pipeline).ID)
+	for _, run := range []*models.PipelineRun{first, second} {
+		if run.Status != models.RunStatusSuccess {
+			t.Fatalf("运行 %d 状态 %s，应为 success: %s", run.ID, run.Status, run.ErrorMsg)
+		}
+	}
+	if got := readWorkspace(t, e, project, "used.txt"); got != "v1\nv2\n" {
+		t.Errorf("两次运行执行的脚本输出 %q，应为 v1 与 v2", got)
+	}
+
+	for _, tt := range []struct {
+		run  *models.PipelineRun
+		want string
+	}{{first, "1"}, {second, "2"}} {
+		got, url := inputVersion(tt.run, models.SharedResourceScript, "build-sh")
+		if got != tt.want || url != SharedResourceURL(script.ID, tt.want) {
+			t.Errorf("运行 %d 报告共享脚本版本 %q（%s），应为 %s", tt.run.ID, got, url, tt.want)
+		}
+	}
+	var used []uint
+	database.DB.Model(&models.RunResourceVersion{}).Where("resource_id = ? AND version = ?", script.ID, 1).Pluck("pipeline_run_id", &used)
+	if len(used) != 1 || used[0] != first.ID {
+		t.Errorf("使用版本 1 的运行为 %v，应只有运行 %d", used, first.ID)
+	}
+
+	retried, err := e.RetryRun(first.ID, testUserID, true)
+	if err != nil {
+		t.Fatalf("按原始输入重新运行失败: %v", err)
+	}
+	retried = waitRun(t, e, retried.ID)
+	if got, _ := inputVersion(retried, models.SharedResourceScript, "build-sh"); got != "1" || retried.RetryOfID == nil || *retried.RetryOfID != first.ID {
+		t.Errorf("按原始输入重新运行使用版本 %q（重新运行自 %v），应为运行 %d 的版本 1", got, retried.RetryOfID, first.ID)
+	}
+	latest, err := e.RetryRun(first.ID, testUserID, false)
+	if err != nil {
+		t.Fatalf("重新运行失败: %v", err)
+	}
+	latest = waitRun(t, e, latest.ID)
+	if got, _ := inputVersion(latest, models.SharedResourceScript, "build-sh"); got != "2" {
+		t.Errorf("重新运行使用版本 %q，应为最新版本 2", got)
+	}
+	if got := readWorkspace(t, e, project, "used.txt"); got != "v1\nv2\nv1\nv2\n" {
+		t.Errorf("重新运行后脚本输出 %q，应依次执行版本 1 与版本 2", got)
+	}
+}
+
+// TestDeleteSharedVersion 最新版本与仍被保留的运行引用的版本不能删除，运行被清理后可以删除；版本号不会复用
+func TestDeleteSharedVersion(t *testing.T) {
+	e, project := setupEngineTest(t)
+	script := createShared(t, models.SharedResourceScript, "build-sh", "echo v1")
+	pipeline := createPipeline(t, project, "build", sharedScriptPipeline)
+	run := waitRun(t, e, startRun(t, e, pipeline).ID)
+	if _, err := UpdateSharedResource(script.ID, nil, "echo v2", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteSharedVersion(script.ID, 2); !errors.Is(err, ErrLatestVersion) {
+		t.Errorf("删除最新版本返回 %v，应为 ErrLatestVersion", err)
+	}
+	if err := DeleteSharedVersion(script.ID, 1); !errors.Is(err, ErrVersionInUse) {
+		t.Errorf("删除被运行引用的版本返回 %v，应为 ErrVersionInUse", err)
+	}
+	// 保留策略软删除超过保留期的运行
+	if err := database.DB.Delete(&models.PipelineRun{}, run.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteSharedVersion(script.ID, 1); err != nil {
+		t.Fatalf("运行被清理后删除版本失败: %v", err)
+	}
+	if err := DeleteSharedVersion(script.ID, 1); !errors.Is(err, ErrSharedResourceNotFound) {
+		t.Errorf("删除已删除的版本返回 %v，应为 ErrSharedResourceNotFound", err)
+	}
+
+	version, err := UpdateSharedResource(script.ID, nil, "echo v1", nil)
+	if err != nil || version.Version != 3 {
+		t.Errorf("删除版本 1 后修改得到版本 %+v（%v），应为 3", version, err)
+	}
+	if same, err := UpdateSharedResource(script.ID, nil, "echo v1", nil); err != nil || same.Version != 3 {
+		t.Errorf("内容没有变化时返回版本 %+v（%v），应为最新版本 3", same, err)
+	}
+}
+
+// TestSharedTemplateAndEnvGroup 模板的阶段在流水线自己的阶段之前执行，环境变量组中的变量注入步骤环境，
+// 项目环境变量优先于环境变量组
+func TestSharedTemplateAndEnvGroup(t *testing.T) {
+	e, project := setupEngineTest(t)
+	createShared(t, models.SharedResourceEnvGroup, "common", `{"GREETING": "hello", "TARGET": "group"}`)
+	createShared(t, models.SharedResourceTemplate, "base", `stages:
+  - name: prepare
+    steps:
+      - name: greet
+        type: script
+        config:
+          script: echo "$GREETING $TARGET" > order.txt
+env_groups: [common]
+`)
+	if err := database.DB.Create(&models.Environment{ProjectID: project.ID, Key: "TARGET", Value: "project"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	pipeline := createPipeline(t, project, "build", `template: base
+stages:
+  - name: build
+    steps:
+      - name: build
+        type: script
+        config:
+          script: echo built >> order.txt
+`)
+
+	run := waitRun(t, e, startRun(t, e, pipeline).ID)
+	if run.Status != models.RunStatusSuccess {
+		t.Fatalf("运行状态 %s，应为 success: %s", run.Status, run.ErrorMsg)
+	}
+	if got := readWorkspace(t, e, project, "order.txt"); got != "hello project\nbuilt\n" {
+		t.Errorf("脚本输出 %q，应先执行模板的阶段并使用环境变量组与项目环境变量", got)
+	}
+	for _, tt := range []struct{ kind, name string }{{models.SharedResourceTemplate, "base"}, {models.SharedResourceEnvGroup, "common"}} {
+		if got, _ := inputVersion(run, tt.kind, tt.name); got != "1" {
+			t.Errorf("运行报告 %s %s 的版本 %q，应为 1", tt.kind, tt.name, got)
+		}
+	}
+
+	if problems := ValidateSharedReferences(&models.PipelineConfig{Template: "missing", EnvGroups: []string{"common"}}); len(problems) != 1 {
+		t.Errorf("引用不存在的模板时问题为 %q，应有 1 个", problems)
+	}
+}
+
+// TestValidateSharedContent 环境变量组须为变量名到字符串的 JSON 对象，模板须为有效的配置且不能引用其他模板
+func TestValidateSharedContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		content string
+		valid   bool
+	}{
+		{"脚本", models.SharedResourceScript, "make build", true},
+		{"空脚本", models.SharedResourceScript, " \n", false},
+		{"环境变量组", models.SharedResourceEnvGroup, `{"GOFLAGS": "-mod=mod"}`, true},
+		{"环境变量组的取值不是字符串", models.SharedResourceEnvGroup, `{"PORT": 8080}`, false},
+		{"无效的变量名", models.SharedResourceEnvGroup, `{"1PORT": "8080"}`, false},
+		{"模板", models.SharedResourceTemplate, scriptPipeline("make"), true},
+		{"模板引用其他模板", models.SharedResourceTemplate, "template: base\n" + scriptPipeline("make"), false},
+		{"不支持的类型", "secret", "x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSharedContent(tt.kind, tt.content)
+			if (err == nil) != tt.valid || (err != nil && !errors.Is(err, ErrInvalidSharedResource)) {
+				t.Errorf("校验返回 %v，应为有效 %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/scripts"
+)
+
+// stepShell 脚本步骤使用的解释器：步骤配置的 shell，未配置时为项目默认的解释器；都为空时由执行主机选择默认的解释器
+func stepShell(project *models.Project, step *models.PipelineStep) string {
+	if shell, _ := step.Config["shell"].(string); shell != "" {
+		return shell
+	}
+	return project.DefaultShell
+}
+
+// validateScriptConfig 保存流水线时校验脚本步骤的 shell 是支持的解释器，script_ref 为共享脚本名且不与 script 同时设置
+func validateScriptConfig(config map[string]interface{}) []string {
+	var problems []string
+	if ref, ok := config["script_ref"]; ok {
+		if name, isString := ref.(string); !isString || name == "" {
+			problems = append(problems, "script_ref 应为共享脚本的名称")
+		} else if config["script"] != nil {
+			problems = append(problems, "script 与 script_ref 只能设置一个")
+		}
+	}
+	if config["shell"] == nil {
+		return problems
+	}
+	if shell, ok := config["shell"].(string); !ok || !scripts.IsShell(shell) {
+		problems = append(problems, fmt.Sprintf("不支持的解释器 %v，可选: %s", config["shell"], strings.Join(scripts.ShellNames(), ", ")))
+	}
+	return problems
+}
+
+// ValidateShells 校验脚本步骤要求的解释器在会执行运行的主机上可用：运行交给执行器时每个存活的执行器都可能领取，
+// 因此要求这些执行器注册时都探测到该解释器；在本地执行时检查本机。问题中列出缺少的可执行文件；
+// 没有可检查的主机（只交给执行器且没有存活的执行器）时不检查，运行在执行器上执行时再报告
+func (e *Engine) ValidateShells(project *models.Project, config *models.PipelineConfig) []string {
+	available, ok := e.shellHosts()
+	if !ok {
+		return nil
+	}
+	return shellProblems(project, config, available)
+}
+
+// localShellProblems 校验解释器在本机可用，用于执行前读取的仓库配置文件
+func (e *Engine) localShellProblems(project *models.Project, config *models.PipelineConfig) []string {
+	available := make(map[string]bool)
+	for _, shell := range e.scriptManager.AvailableShells() {
+		available[shell] = true
+	}
+	return shellProblems(project, config, available)
+}
+
+// shellProblems 列出要求的解释器不在 available 中的脚本步骤；未指定解释器的步骤使用主机默认的解释器，不检查
+func shellProblems(project *models.Project, config *models.PipelineConfig, available map[string]bool) []string {
+	var problems []string
+	for _, stage := range config.Stages {
+		for i := range stage.Steps {
+			step := &stage.Steps[i]
+			if step.Type != "script" {
+				continue
+			}
+			shell := stepShell(project, step)
+			if shell == "" || !scripts.IsShell(shell) || available[shell] {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("步骤 %s 要求的解释器 %s 在执行主机上不可用（未找到 %s）",
+				step.Name, shell, scripts.ShellBinaries(shell)))
+		}
+	}
+	return problems
+}
+
+// shellHosts 会执行运行的主机上都可用的解释器，与 dispatchToWorker 选择执行位置的方式一致；ok 为 false 表示没有可检查的主机
+func (e *Engine) shellHosts() (map[string]bool, bool) {
+	local := func() (map[string]bool, bool) {
+		available := make(map[string]bool)
+		for _, shell := range e.scriptManager.AvailableShells() {
+			available[shell] = true
+		}
+		return available, true
+	}
+	if e.config.Worker.LocalExecution == LocalExecutionAlways {
+		return local()
+	}
+
+	var workers []models.Worker
+	if err := database.DB.Select("shells").Where("status <> ? AND last_heartbeat_at > ?",
+		models.WorkerStatusStopped, time.Now().Add(-workerStaleAfter)).Find(&workers).Error; err != nil {
+		log.Printf("查询执行器失败: %v", err)
+		return nil, false
+	}
+	if len(workers) == 0 {
+		if e.config.Worker.LocalExecution == LocalExecutionNever {
+			return nil, false
+		}
+		return local()
+	}
+
+	// 每个执行器都有的解释器
+	counts := make(map[string]int)
+	for _, worker := range workers {
+		for _, shell := range strings.Split(worker.Shells, ",") {
+			if shell != "" {
+				counts[shell]++
+			}
+		}
+	}
+	available := make(map[string]bool)
+	for shell, count := range counts {
+		if count == len(workers) {
+			available[shell] = true
+		}
+	}
+	return available, true
+}
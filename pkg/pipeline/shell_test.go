@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// shellConfig 三个脚本步骤：python、bash 与未指定解释器（使用项目默认的解释器）
+const shellConfig = `stages:
+  - name: build
+    steps:
+      - name: report
+        type: script
+        config:
+          shell: python
+          script: print("ok")
+      - name: test
+        type: script
+        config:
+          shell: bash
+          script: "true"
+      - name: package
+        type: script
+        config:
+          script: "true"
+`
+
+// TestValidateShells 交给执行器时要求每个存活的执行器都有步骤的解释器，问题中列出缺少的可执行文件；
+// 已停止或心跳过期的执行器不参与检查，没有可检查的主机时不报告问题
+func TestValidateShells(t *testing.T) {
+	e, project := setupEngineTest(t)
+	e.config.Worker.LocalExecution = LocalExecutionNever
+	config, err := LoadConfig(shellConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := e.ValidateShells(project, config); problems != nil {
+		t.Errorf("没有存活的执行器时报告了 %v", problems)
+	}
+
+	now := time.Now()
+	for _, worker := range []models.Worker{
+		{Name: "worker-1", Status: models.WorkerStatusActive, Shells: "bash,python,sh", LastHeartbeatAt: now},
+		{Name: "worker-2", Status: models.WorkerStatusDraining, Shells: "bash,sh", LastHeartbeatAt: now},
+		{Name: "stopped", Status: models.WorkerStatusStopped, Shells: "", LastHeartbeatAt: now},
+		{Name: "stale", Status: models.WorkerStatusActive, Shells: "", LastHeartbeatAt: now.Add(-time.Hour)},
+	} {
+		if err := database.DB.Create(&worker).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		defaultShell string
+		want         []string
+	}{
+		{"默认解释器由执行主机选择", "", []string{"report", "python3 或 python"}},
+		{"项目默认的解释器不可用", "pwsh", []string{"report", "python3 或 python", "package", "pwsh"}},
+		{"项目默认的解释器可用", "sh", []string{"report", "python3 或 python"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project.DefaultShell = tt.defaultShell
+			var got []string
+			for _, problem := range e.ValidateShells(project, config) {
+				step, _, _ := strings.Cut(strings.TrimPrefix(problem, "步骤 "), " ")
+				_, binary, _ := strings.Cut(problem, "未找到 ")
+				got = append(got, step, strings.TrimSuffix(binary, "）"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("问题为 %v，应为 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateScriptShell 保存时 shell 应为支持的解释器名称
+func TestValidateScriptShell(t *testing.T) {
+	if problems := validateScriptConfig(map[string]interface{}{"shell": "pwsh"}); problems != nil {
+		t.Errorf("pwsh 报告了 %v", problems)
+	}
+	for _, shell := range []interface{}{"zsh", 1} {
+		if problems := validateScriptConfig(map[string]interface{}{"shell": shell}); len(problems) != 1 || !strings.Contains(problems[0], "可选: bash, cmd") {
+			t.Errorf("shell %v 的问题为 %v，应列出可选的解释器", shell, problems)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package pipeline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+)
+
+// ResolvedSnapshot 运行开始时解析后的完整配置快照，不包含任何明文密钥
+type ResolvedSnapshot struct {
+	RunID        uint          `json:"run_id"`
+	PipelineID   uint          `json:"pipeline_id"`
+	PipelineName string        `json:"pipeline_name"`
+	ProjectID    uint          `json:"project_id"`
+	RepoURL      string        `json:"repo_url"`
+	Branch       string        `json:"branch"`
+	TriggerType  string        `json:"trigger_type"`
+	Config       interface{}   `json:"config"`
+	ConfigSource string        `json:"config_source"`
+	ConfigPath   string        `json:"config_path,omitempty"`
+	ConfigCommit string        `json:"config_commit,omitempty"`
+	ConfigFile   string        `json:"config_file,omitempty"` // 仓库配置文件原文
+	Env          []SnapshotEnv `json:"env"`
+	// EnvConflicts 运行开始时各脚本步骤的环境变量冲突；前面步骤的输出在运行中才产生，其冲突见运行日志与步骤环境变量
+	EnvConflicts []EnvConflict `json:"env_conflicts,omitempty"`
+	// Policy 运行开始时合并项目策略后生效的策略，审批等待已插入 Config 中对应的步骤前
+	Policy *models.EffectivePolicy `json:"policy,omitempty"`
+	// CloudRoles 部署步骤扮演的云平台角色，只记录角色，临时凭证不保存
+	CloudRoles []SnapshotCloudRole `json:"cloud_roles,omitempty"`
+	// Inputs 运行使用的配置及其版本，按原始输入重跑时沿用
+	Inputs     []models.RunInput `json:"inputs,omitempty"`
+	CapturedAt time.Time         `json:"captured_at"`
+}
+
+// SnapshotCloudRole 快照中部署到某环境时扮演的角色
+type SnapshotCloudRole struct {
+	Environment string `json:"environment"`
+	Provider    string `json:"provider"`
+	RoleARN     string `json:"role_arn"`
+}
+
+// SnapshotEnv 快照中的环境变量，密钥值以指纹代替
+type SnapshotEnv struct {
+	Key         string `json:"key"`
+	Value       string `json:"value,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Secret      bool   `json:"secret"`
+}
+
+// minMaskLength 参与内联替换的密钥最小长度，过短的值替换会误伤正常内容
+const minMaskLength = 4
+
+// saveResolvedSnapshot 生成并压缩保存运行的配置快照
+func (e *Engine) saveResolvedSnapshot(jobCtx *JobContext, config interface{}) error {
+	var envs []models.Environment
+	jobCtx.db().Where("project_id = ?", jobCtx.Project.ID).Find(&envs)
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Key < envs[j].Key })
+
+	snapshot := ResolvedSnapshot{
+		RunID:        jobCtx.PipelineRun.ID,
+		PipelineID:   jobCtx.Pipeline.ID,
+		PipelineName: jobCtx.Pipeline.Name,
+		ProjectID:    jobCtx.Project.ID,
+		RepoURL:      jobCtx.Project.RepoURL,
+		Branch:       jobCtx.Project.Branch,
+		TriggerType:  jobCtx.PipelineRun.TriggerType,
+		Config:       config,
+		ConfigSource: models.ConfigSourceStored,
+		Policy:       jobCtx.policy,
+		Inputs:       snapshotInputs(jobCtx),
+		CapturedAt:   time.Now(),
+	}
+	if repo := jobCtx.RepoConfig; repo != nil {
+		snapshot.ConfigSource = models.ConfigSourceRepo
+		snapshot.ConfigPath = repo.Path
+		snapshot.ConfigCommit = repo.Commit
+		snapshot.ConfigFile = repo.Content
+	}
+
+	var secrets []string
+	for _, env := range envs {
+		item := SnapshotEnv{Key: env.Key, Secret: env.IsSecret}
+		if env.IsSecret {
+			item.Fingerprint = redact.Fingerprint(env.Value)
+			if len(env.Value) >= minMaskLength {
+				secrets = append(secrets, env.Value)
+			}
+		} else {
+			item.Value = env.Value
+		}
+		snapshot.Env = append(snapshot.Env, item)
+	}
+	var credentials []models.CloudCredential
+	jobCtx.db().Where("project_id = ?", jobCtx.Project.ID).Order("environment").Find(&credentials)
+	for _, credential := range credentials {
+		snapshot.CloudRoles = append(snapshot.CloudRoles, SnapshotCloudRole{
+			Environment: credential.Environment,
+			Provider:    credential.Provider,
+			RoleARN:     credential.RoleARN,
+		})
+	}
+	if pipelineConfig, ok := config.(*models.PipelineConfig); ok {
+		snapshot.EnvConflicts = configEnvConflicts(jobCtx, pipelineConfig)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("序列化配置快照失败: %w", err)
+	}
+
+	// 步骤配置中内联的密钥值同样替换为指纹，长的先替换以免部分匹配
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	text := string(data)
+	for _, secret := range secrets {
+		encoded, _ := json.Marshal(secret)
+		text = strings.ReplaceAll(text, strings.Trim(string(encoded), `"`), redact.Fingerprint(secret))
+	}
+
+	encoded, err := compressSnapshot([]byte(text))
+	if err != nil {
+		return err
+	}
+
+	jobCtx.PipelineRun.ResolvedConfig = encoded
+	return jobCtx.db().Model(jobCtx.PipelineRun).Update("resolved_config", encoded).Error
+}
+
+// configEnvConflicts 按配置顺序解析各脚本步骤的环境变量，返回带步骤名称的冲突
+func configEnvConflicts(jobCtx *JobContext, config *models.PipelineConfig) []EnvConflict {
+	var conflicts []EnvConflict
+	for _, stage := range config.Stages {
+		for i := range stage.Steps {
+			step := &stage.Steps[i]
+			if step.Type != "script" {
+				continue
+			}
+			for _, conflict := range resolveStepEnv(jobCtx, step).conflicts() {
+				conflict.Step = step.Name
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+	return conflicts
+}
+
+// DecodeResolvedConfig 解压运行保存的配置快照
+func DecodeResolvedConfig(run *models.PipelineRun) (json.RawMessage, error) {
+	if run.ResolvedConfig == "" {
+		return nil, fmt.Errorf("该运行没有配置快照")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(run.ResolvedConfig)
+	if err != nil {
+		return nil, fmt.Errorf("解码配置快照失败: %w", err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解压配置快照失败: %w", err)
+	}
+	defer reader.Close()
+
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("解压配置快照失败: %w", err)
+	}
+
+	return json.RawMessage(raw), nil
+}
+
+// compressSnapshot gzip压缩后base64编码
+func compressSnapshot(data []byte) (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return "", fmt.Errorf("压缩配置快照失败: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("压缩配置快照失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
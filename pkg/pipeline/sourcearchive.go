@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flowforge/pkg/archive"
+	"flowforge/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrSourceArchiveTooLarge 上传的源码包超过大小上限
+var ErrSourceArchiveTooLarge = errors.New("源码包超过大小上限")
+
+// ErrSourceArchiveRequired 源码包项目只能通过上传源码包触发运行
+var ErrSourceArchiveRequired = errors.New("源码包项目需要上传源码包触发运行")
+
+// ErrArchiveSourceProject 源码包项目不关联 git 仓库
+var ErrArchiveSourceProject = errors.New("源码包项目不支持拉取 git 仓库")
+
+// SourceArchive 已保存的源码包
+type SourceArchive struct {
+	Path   string `json:"-"`
+	Format string `json:"format"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// CommitSHA 运行记录中代替提交哈希的源码包哈希
+func (a *SourceArchive) CommitSHA() string {
+	return "sha256:" + a.SHA256
+}
+
+// StoreSourceArchive 将上传的源码包流式写入存储，同时计算 sha256 并限制大小；超过上限时删除已写入的部分
+func (e *Engine) StoreSourceArchive(projectID uint, filename string, r io.Reader) (*SourceArchive, error) {
+	reader := bufio.NewReader(r)
+	header, _ := reader.Peek(512)
+	format, err := archive.DetectFormat(filename, header)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(e.config.App.DataPath, "source-archives", fmt.Sprintf("%d", projectID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建源码包目录失败: %w", err)
+	}
+	path := filepath.Join(dir, uuid.New().String()+"."+format)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("保存源码包失败: %w", err)
+	}
+
+	limit := int64(e.config.Deploy.MaxSourceArchiveMB) * 1024 * 1024
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(reader, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > limit {
+		err = fmt.Errorf("%w（%d MB）", ErrSourceArchiveTooLarge, e.config.Deploy.MaxSourceArchiveMB)
+	}
+	if err != nil {
+		os.Remove(path)
+		if errors.Is(err, ErrSourceArchiveTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("保存源码包失败: %w", err)
+	}
+
+	return &SourceArchive{Path: path, Format: format, SHA256: hex.EncodeToString(hash.Sum(nil)), Size: size}, nil
+}
+
+// extractSourceArchive 清空工作区并解压运行上传的源码包，代替 git_clone 步骤准备源码
+func (e *Engine) extractSourceArchive(jobCtx *JobContext) error {
+	src := jobCtx.PipelineRun.SourceArchive
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	if err := os.RemoveAll(workDir); err != nil {
+		return fmt.Errorf("清空工作区失败: %w", err)
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(src), ".")
+	if strings.HasSuffix(src, ".tar.gz") {
+		format = archive.FormatTarGz
+	}
+	stats, err := archive.Extract(src, format, workDir, archive.Limits{
+		MaxFiles:      e.config.Deploy.MaxArchiveFiles,
+		MaxTotalBytes: int64(e.config.Deploy.MaxArchiveExpandMB) * 1024 * 1024,
+		MaxRatio:      int64(e.config.Deploy.MaxArchiveRatio),
+	})
+	if err != nil {
+		// 不在工作区中留下解压到一半的文件
+		os.RemoveAll(workDir)
+		return err
+	}
+
+	e.logf(jobCtx, "log.source_extracted", strings.TrimPrefix(jobCtx.PipelineRun.CommitSHA, "sha256:"), stats.Files, stats.TotalBytes)
+	return nil
+}
+
+// ValidateSourceSteps 校验流水线与项目源码来源是否匹配：源码包项目没有仓库，
+// 不能使用 git_clone 步骤，也不能从仓库读取配置文件；registry 项目还不能使用 build 步骤
+func ValidateSourceSteps(project *models.Project, configSource string, config *models.PipelineConfig) []string {
+	if project.UsesRegistrySource() {
+		return validateRegistrySteps(configSource, config)
+	}
+	if !project.UsesArchiveSource() {
+		return nil
+	}
+
+	var problems []string
+	if configSource == models.ConfigSourceRepo {
+		problems = append(problems, "源码包项目不能从仓库读取流水线配置")
+	}
+	if config == nil {
+		return problems
+	}
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			if step.Type == "git_clone" {
+				problems = append(problems, fmt.Sprintf("步骤 %s 使用 git_clone，源码包项目的源码来自上传的源码包", step.Name))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,249 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+)
+
+// 环境变量的来源层，按覆盖顺序排列，后面的层覆盖前面的同名变量
+const (
+	EnvSourceBuiltin  = "builtin"   // 平台内置变量，如 PIPELINE_RUN_ID
+	EnvSourceEnvGroup = "env_group" // 流水线引用的共享环境变量组
+	EnvSourceProject  = "project"   // 项目环境变量
+	EnvSourceOutput   = "output"    // 前面步骤产生的输出 STEP_OUTPUT_<KEY>
+	EnvSourceStep     = "step"      // 步骤配置中的 env
+	EnvSourceCloud    = "cloud"     // 部署步骤扮演云平台角色得到的临时凭证，覆盖项目中遗留的静态密钥
+)
+
+// StepEnvVar 步骤收到的一个环境变量
+type StepEnvVar struct {
+	Name        string   `json:"name"`
+	Source      string   `json:"source"`
+	Overrides   []string `json:"overrides,omitempty"` // 被该变量覆盖的来源层
+	Value       string   `json:"value,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	Secret      bool     `json:"secret,omitempty"`
+}
+
+// StepEnvCapture 步骤实际收到的环境变量。未开启调试时只有名称与来源层
+type StepEnvCapture struct {
+	Debug      bool          `json:"debug"`
+	Vars       []StepEnvVar  `json:"vars"`
+	Conflicts  []EnvConflict `json:"conflicts,omitempty"`
+	CapturedAt time.Time     `json:"captured_at"`
+}
+
+// EnvConflict 同一变量在多个来源层中取值不同，记录生效的来源层与取值不同而被覆盖的来源层，不记录值
+type EnvConflict struct {
+	Step       string   `json:"step,omitempty"`
+	Key        string   `json:"key"`
+	Winner     string   `json:"winner"`
+	Overridden []string `json:"overridden"`
+}
+
+// stepEnv 按来源层解析出的步骤环境变量，values 即传给脚本执行的变量
+type stepEnv struct {
+	values    map[string]string
+	sources   map[string]string
+	overrides map[string][]string
+	// layered 各来源层中同名变量的取值，按覆盖顺序排列，用于检查冲突
+	layered map[string][]envLayerValue
+}
+
+// envLayerValue 变量在一个来源层中的取值
+type envLayerValue struct {
+	source string
+	value  string
+}
+
+// set 设置来源层中的变量，覆盖前面层的同名变量
+func (s *stepEnv) set(source, name, value string) {
+	if previous, ok := s.sources[name]; ok && previous != source {
+		s.overrides[name] = append(s.overrides[name], previous)
+	}
+	s.values[name] = value
+	s.sources[name] = source
+	s.layered[name] = append(s.layered[name], envLayerValue{source: source, value: value})
+}
+
+// setAll 按变量名顺序设置来源层中的一组变量
+func (s *stepEnv) setAll(source string, values map[string]string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.set(source, name, values[name])
+	}
+}
+
+// conflicts 在多个来源层中取值不同的变量，按变量名排序；前面层的取值与生效值相同时不算冲突
+func (s *stepEnv) conflicts() []EnvConflict {
+	names := make([]string, 0, len(s.layered))
+	for name, layers := range s.layered {
+		if len(layers) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var conflicts []EnvConflict
+	for _, name := range names {
+		layers := s.layered[name]
+		winner := layers[len(layers)-1]
+		var overridden []string
+		for _, layer := range layers[:len(layers)-1] {
+			if layer.value != winner.value {
+				overridden = append(overridden, layer.source)
+			}
+		}
+		if len(overridden) > 0 {
+			conflicts = append(conflicts, EnvConflict{Key: name, Winner: winner.source, Overridden: overridden})
+		}
+	}
+	return conflicts
+}
+
+// resolveStepEnv 按 builtin、env_group、project、output、step 的顺序解析脚本步骤的环境变量，
+// 同一层内按变量名顺序设置，解析结果与冲突列表因此稳定
+func resolveStepEnv(jobCtx *JobContext, step *models.PipelineStep) *stepEnv {
+	env := &stepEnv{
+		values:    make(map[string]string),
+		sources:   make(map[string]string),
+		overrides: make(map[string][]string),
+		layered:   make(map[string][]envLayerValue),
+	}
+
+	env.set(EnvSourceBuiltin, "PROJECT_NAME", jobCtx.Project.Name)
+	env.set(EnvSourceBuiltin, "PROJECT_ID", fmt.Sprintf("%d", jobCtx.Project.ID))
+	env.set(EnvSourceBuiltin, "PIPELINE_ID", fmt.Sprintf("%d", jobCtx.Pipeline.ID))
+	env.set(EnvSourceBuiltin, "PIPELINE_RUN_ID", fmt.Sprintf("%d", jobCtx.PipelineRun.ID))
+	env.set(EnvSourceBuiltin, "BUILD_VERSION", fmt.Sprintf("v%d", jobCtx.PipelineRun.ID))
+	for _, item := range artifactEnv(jobCtx.PipelineRun) {
+		env.set(EnvSourceBuiltin, item[0], item[1])
+	}
+	if jobCtx.ingest != nil {
+		// 远程执行器以 HTTP 方式上报日志时使用，令牌随运行结束失效
+		env.set(EnvSourceBuiltin, "LOG_INGEST_TOKEN", jobCtx.ingest.token)
+		if jobCtx.engine != nil && jobCtx.engine.config.Notify.BaseURL != "" {
+			env.set(EnvSourceBuiltin, "LOG_INGEST_URL", strings.TrimRight(jobCtx.engine.config.Notify.BaseURL, "/")+LogIngestPath(jobCtx.PipelineRun.ID))
+		}
+	}
+
+	env.setAll(EnvSourceEnvGroup, jobCtx.envGroups)
+
+	var projectEnvs []models.Environment
+	if err := jobCtx.db().Where("project_id = ?", jobCtx.Project.ID).Find(&projectEnvs).Error; err != nil {
+		log.Printf("运行 %d 读取项目环境变量失败: %v", jobCtx.PipelineRun.ID, err)
+	}
+	sort.Slice(projectEnvs, func(i, j int) bool { return projectEnvs[i].Key < projectEnvs[j].Key })
+	for _, projectEnv := range projectEnvs {
+		env.set(EnvSourceProject, projectEnv.Key, projectEnv.Value)
+	}
+
+	// 不同的输出名可能转换为同一变量名，按输出名顺序设置
+	outputKeys := make([]string, 0, len(jobCtx.Outputs))
+	for key := range jobCtx.Outputs {
+		outputKeys = append(outputKeys, key)
+	}
+	sort.Strings(outputKeys)
+	for _, key := range outputKeys {
+		env.set(EnvSourceOutput, outputEnvName(key), jobCtx.Outputs[key])
+	}
+
+	if envVars, ok := step.Config["env"].(map[string]interface{}); ok {
+		names := make([]string, 0, len(envVars))
+		for name := range envVars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if str, ok := envVars[name].(string); ok {
+				env.set(EnvSourceStep, name, str)
+			}
+		}
+	}
+	return env
+}
+
+// warnEnvConflicts 在运行日志中提示步骤环境变量的冲突
+func (e *Engine) warnEnvConflicts(jobCtx *JobContext, env *stepEnv) {
+	for _, conflict := range env.conflicts() {
+		e.logf(jobCtx, "log.env_conflict", conflict.Key, conflict.Winner, strings.Join(conflict.Overridden, ", "))
+	}
+}
+
+// captureStepEnv 记录当前步骤收到的环境变量。passed 必须是传给脚本执行的同一个变量表，
+// 记录的内容因此不会与实际执行不一致；值只在运行开启调试时记录，密钥只记录指纹
+func (e *Engine) captureStepEnv(jobCtx *JobContext, passed map[string]string, env *stepEnv) {
+	record := jobCtx.currentStep
+	if record == nil || record.ID == 0 {
+		return
+	}
+
+	capture := StepEnvCapture{Debug: jobCtx.PipelineRun.DebugEnv, Conflicts: env.conflicts(), CapturedAt: time.Now()}
+
+	secrets := make(map[string]bool)
+	if capture.Debug {
+		values, err := redact.ProjectSecrets(jobCtx.Project.ID)
+		if err != nil {
+			// 无法判断哪些值是密钥时不记录任何值
+			log.Printf("运行 %d 读取项目密钥失败，不记录环境变量值: %v", jobCtx.PipelineRun.ID, err)
+			capture.Debug = false
+		}
+		for _, value := range values {
+			secrets[value] = true
+		}
+	}
+
+	names := make([]string, 0, len(passed))
+	for name := range passed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		item := StepEnvVar{Name: name, Source: env.sources[name], Overrides: env.overrides[name]}
+		if capture.Debug {
+			value := passed[name]
+			if secrets[value] || redact.LooksSecret(name) || item.Source == EnvSourceCloud {
+				item.Secret = true
+				item.Fingerprint = redact.Fingerprint(value)
+			} else {
+				item.Value = value
+			}
+		}
+		capture.Vars = append(capture.Vars, item)
+	}
+
+	data, err := json.Marshal(capture)
+	if err != nil {
+		log.Printf("序列化步骤环境变量失败: %v", err)
+		return
+	}
+	record.EnvCapture = string(data)
+	if err := jobCtx.db().Model(record).Update("env_capture", record.EnvCapture).Error; err != nil {
+		log.Printf("保存步骤 %d 的环境变量失败: %v", record.ID, err)
+	}
+}
+
+// DecodeStepEnv 解析步骤记录的环境变量，步骤未执行脚本时返回 nil
+func DecodeStepEnv(step *models.PipelineStep) (*StepEnvCapture, error) {
+	if step.EnvCapture == "" {
+		return nil, nil
+	}
+
+	var capture StepEnvCapture
+	if err := json.Unmarshal([]byte(step.EnvCapture), &capture); err != nil {
+		return nil, fmt.Errorf("解析步骤环境变量失败: %w", err)
+	}
+	return &capture, nil
+}
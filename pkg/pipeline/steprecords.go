@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"log"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// maxStepLogBytes 步骤记录保存的日志上限，超出的部分只保留在运行日志中
+const maxStepLogBytes = 256 << 10
+
+// stepLogTruncated 步骤日志超过上限时追加的说明
+const stepLogTruncated = "...（步骤日志超过上限，完整内容见运行日志）\n"
+
+// createStepRecords 运行开始时按配置为每个步骤创建待执行的步骤记录，运行详情可以在步骤执行前展示完整的步骤列表；
+// 恢复外部等待的运行沿用重启前创建的记录。创建失败时由 executeStage 在步骤开始时逐个创建
+func (e *Engine) createStepRecords(jobCtx *JobContext, config *models.PipelineConfig) {
+	jobCtx.stepRecords = make(map[int]*models.PipelineStep)
+	if jobCtx.resumeWait != nil {
+		var existing []models.PipelineStep
+		if err := jobCtx.db().Where("pipeline_run_id = ?", jobCtx.PipelineRun.ID).Find(&existing).Error; err != nil {
+			log.Printf("查询流水线运行 %d 的步骤记录失败: %v", jobCtx.PipelineRun.ID, err)
+		}
+		for i := range existing {
+			jobCtx.stepRecords[existing[i].StepOrder] = &existing[i]
+		}
+		return
+	}
+
+	var records []models.PipelineStep
+	for _, stage := range config.Stages {
+		for _, step := range stage.Steps {
+			records = append(records, models.PipelineStep{
+				Name:          step.Name,
+				Type:          step.Type,
+				StepOrder:     len(records) + 1,
+				Status:        models.StepStatusPending,
+				PipelineRunID: jobCtx.PipelineRun.ID,
+			})
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err := jobCtx.db().CreateInBatches(records, 100).Error; err != nil {
+		log.Printf("创建流水线运行 %d 的步骤记录失败: %v", jobCtx.PipelineRun.ID, err)
+		return
+	}
+	for i := range records {
+		jobCtx.stepRecords[records[i].StepOrder] = &records[i]
+	}
+}
+
+// stepRecord 当前步骤的记录：运行开始时已创建的记录，或尚未保存的新记录
+func (j *JobContext) stepRecord(step *models.PipelineStep) *models.PipelineStep {
+	if record, ok := j.stepRecords[j.stepOrder]; ok && record.Name == step.Name {
+		return record
+	}
+	return &models.PipelineStep{
+		Name:          step.Name,
+		Type:          step.Type,
+		StepOrder:     j.stepOrder,
+		PipelineRunID: j.PipelineRun.ID,
+	}
+}
+
+// saveStepRecord 保存步骤记录的新状态，已创建的记录只更新 columns
+func (j *JobContext) saveStepRecord(record *models.PipelineStep, columns ...string) error {
+	if record.ID == 0 {
+		return j.db().Create(record).Error
+	}
+	return j.db().Model(record).Select(columns).Updates(record).Error
+}
+
+// skipPendingSteps 运行结束时将未执行的步骤标记为已跳过
+func (e *Engine) skipPendingSteps(jobCtx *JobContext) {
+	if err := database.DB.WithContext(jobCtx.detached()).Model(&models.PipelineStep{}).
+		Where("pipeline_run_id = ? AND status = ?", jobCtx.PipelineRun.ID, models.StepStatusPending).
+		Update("status", models.StepStatusSkipped).Error; err != nil {
+		log.Printf("更新流水线运行 %d 未执行的步骤失败: %v", jobCtx.PipelineRun.ID, err)
+	}
+}
+
+// startStepLog 开始记录当前步骤的日志
+func (j *JobContext) startStepLog() {
+	j.stepLogMu.Lock()
+	j.stepLog = &strings.Builder{}
+	j.stepLogFull = false
+	j.stepLogMu.Unlock()
+}
+
+// appendStepLog 将已脱敏的日志行追加到当前步骤的日志，没有执行中的步骤时忽略
+func (j *JobContext) appendStepLog(line string) {
+	j.stepLogMu.Lock()
+	defer j.stepLogMu.Unlock()
+	if j.stepLog == nil || j.stepLogFull {
+		return
+	}
+	if j.stepLog.Len()+len(line)+1 > maxStepLogBytes {
+		j.stepLog.WriteString(stepLogTruncated)
+		j.stepLogFull = true
+		return
+	}
+	j.stepLog.WriteString(line)
+	j.stepLog.WriteByte('\n')
+}
+
+// takeStepLog 结束记录并返回当前步骤的日志
+func (j *JobContext) takeStepLog() string {
+	j.stepLogMu.Lock()
+	defer j.stepLogMu.Unlock()
+	if j.stepLog == nil {
+		return ""
+	}
+	text := j.stepLog.String()
+	j.stepLog = nil
+	return text
+}
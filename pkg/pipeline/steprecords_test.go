@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flowforge/pkg/models"
+)
+
+// stepStatuses 运行各步骤记录的状态，按步骤序号
+func stepStatuses(t *testing.T, runID uint) []string {
+	t.Helper()
+	var statuses []string
+	for _, step := range runSteps(t, runID) {
+		statuses = append(statuses, step.Status)
+	}
+	return statuses
+}
+
+// TestStepRecordsPersisted 运行开始时为每个步骤创建待执行的记录；执行过的步骤记录开始结束时间、耗时与日志，
+// 失败的步骤记录错误，失败之后未执行的步骤标记为已跳过
+func TestStepRecordsPersisted(t *testing.T) {
+	e, project := setupEngineTest(t)
+	gates := t.TempDir()
+	pipeline := createPipeline(t, project, "build", scriptPipeline(
+		"echo compiled; "+waitGate(gates, "release"),
+		"echo broken; exit 3",
+		"echo never",
+	))
+
+	run := startRun(t, e, pipeline)
+	waitStep(t, run.ID, 1)
+	if got := strings.Join(stepStatuses(t, run.ID), ","); got != "running,pending,pending" {
+		t.Errorf("第一步执行时步骤状态为 %s，应为 running,pending,pending", got)
+	}
+	openGate(t, gates, "release")
+	run = waitRun(t, e, run.ID)
+	if run.Status != models.RunStatusFailed {
+		t.Fatalf("运行状态 %s，应为 failed", run.Status)
+	}
+
+	steps := runSteps(t, run.ID)
+	if len(steps) != 3 {
+		t.Fatalf("步骤记录 %d 个，应为 3 个", len(steps))
+	}
+	for i, want := range []string{models.StepStatusSuccess, models.StepStatusFailed, models.StepStatusSkipped} {
+		step := steps[i]
+		if step.Status != want || step.Name != fmt.Sprintf("step%d", i+1) || step.Type != "script" {
+			t.Errorf("第 %d 个步骤为 %s/%s 状态 %s，应为 step%d/script 状态 %s", i+1, step.Name, step.Type, step.Status, i+1, want)
+		}
+	}
+	for _, step := range steps[:2] {
+		if step.StartTime == nil || step.EndTime == nil || step.EndTime.Before(*step.StartTime) {
+			t.Errorf("步骤 %s 的开始结束时间为 %v - %v，应都已记录", step.Name, step.StartTime, step.EndTime)
+		}
+	}
+	if steps[0].DurationMs < 50 {
+		t.Errorf("第一步耗时 %dms，应包含等待放行的时间", steps[0].DurationMs)
+	}
+	if !strings.Contains(steps[0].LogOutput, "compiled") || strings.Contains(steps[0].LogOutput, "broken") {
+		t.Errorf("第一步的日志为 %q，应只包含该步骤的输出", steps[0].LogOutput)
+	}
+	if !strings.Contains(steps[1].LogOutput, "broken") || steps[1].ErrorMsg == "" {
+		t.Errorf("失败步骤的日志为 %q、错误为 %q，应记录输出与错误", steps[1].LogOutput, steps[1].ErrorMsg)
+	}
+	if steps[2].StartTime != nil || steps[2].LogOutput != "" {
+		t.Errorf("跳过的步骤开始时间为 %v、日志为 %q，应都为空", steps[2].StartTime, steps[2].LogOutput)
+	}
+}
+
+// TestStepRecordsReused 仅重跑失败步骤时，原运行成功的步骤记录为复用并指向原步骤，之后的步骤重新执行
+func TestStepRecordsReused(t *testing.T) {
+	e, project := setupEngineTest(t)
+	fixed := filepath.Join(t.TempDir(), "fixed")
+	pipeline := createPipeline(t, project, "flaky", scriptPipeline(
+		"echo built",
+		"test -f "+fixed+" || exit 1",
+	))
+	failed := waitRun(t, e, startRun(t, e, pipeline).ID)
+	if failed.Status != models.RunStatusFailed {
+		t.Fatalf("第一次运行状态 %s，应为 failed", failed.Status)
+	}
+	openGate(t, filepath.Dir(fixed), "fixed")
+
+	rerun, err := e.RerunFailed(failed.ID, testUserID)
+	if err != nil {
+		t.Fatalf("重跑失败步骤失败: %v", err)
+	}
+	rerun = waitRun(t, e, rerun.ID)
+	if rerun.Status != models.RunStatusSuccess {
+		t.Fatalf("重跑状态 %s，应为 success: %s", rerun.Status, rerun.ErrorMsg)
+	}
+
+	original := runSteps(t, failed.ID)
+	steps := runSteps(t, rerun.ID)
+	if got := strings.Join(stepStatuses(t, rerun.ID), ","); got != "reused,success" {
+		t.Fatalf("重跑的步骤状态为 %s，应为 reused,success", got)
+	}
+	if steps[0].ReusedFromID == nil || *steps[0].ReusedFromID != original[0].ID {
+		t.Errorf("复用的步骤指向 %v，应为原运行的步骤 %d", steps[0].ReusedFromID, original[0].ID)
+	}
+	if steps[1].ReusedFromID != nil || steps[1].StartTime == nil || steps[1].EndTime == nil {
+		t.Errorf("重新执行的步骤复用自 %v、开始结束时间为 %v - %v，应不复用并记录时间", steps[1].ReusedFromID, steps[1].StartTime, steps[1].EndTime)
+	}
+}
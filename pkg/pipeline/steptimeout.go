@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// ErrStepTimeout 步骤超过超时时间未完成
+var ErrStepTimeout = errors.New("步骤执行超时")
+
+// parseTimeout 解析阶段或步骤配置的超时时间，不带单位的整数为秒数，未设置时返回 0
+func parseTimeout(value models.Timeout) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(string(value))
+	if seconds, atoiErr := strconv.Atoi(string(value)); atoiErr == nil {
+		timeout, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("超时时间 %q 无效，应为正的时长或秒数，如 10m、1h30m、600", string(value))
+	}
+	return timeout, nil
+}
+
+// stepTimeout 步骤生效的超时时间：步骤的 timeout，未设置时为阶段的 timeout，都未设置时为 deploy.timeout；
+// 外部等待步骤由自身的等待超时限制，未设置 timeout 时返回 0 不限制
+func (e *Engine) stepTimeout(stage *models.PipelineStage, step *models.PipelineStep) time.Duration {
+	for _, value := range []models.Timeout{step.Timeout, stage.Timeout} {
+		// 保存的配置与仓库配置文件都已校验
+		if timeout, err := parseTimeout(value); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	if step.Type == "external_wait" {
+		return 0
+	}
+	return time.Duration(e.config.Deploy.Timeout) * time.Second
+}
+
+// runStepWithTimeout 在步骤的超时时间内执行步骤。超时只结束当前步骤，运行按步骤失败处理而不是取消；
+// 步骤的上下文只由执行步骤的协程使用，其他协程判断运行是否取消仍读取 Context
+func (e *Engine) runStepWithTimeout(jobCtx *JobContext, stage *models.PipelineStage, step *models.PipelineStep) error {
+	timeout := e.stepTimeout(stage, step)
+	if timeout <= 0 {
+		return e.executeStep(jobCtx, step)
+	}
+
+	deadline := time.Now().Add(timeout)
+	stepCtx, cancel := context.WithDeadline(jobCtx.Context, deadline)
+	jobCtx.stepCtx, jobCtx.stepDeadline = stepCtx, deadline
+	defer func() {
+		cancel()
+		jobCtx.stepCtx, jobCtx.stepDeadline = nil, time.Time{}
+	}()
+
+	err := e.executeStep(jobCtx, step)
+	if err != nil && jobCtx.Context.Err() == nil && !time.Now().Before(deadline) {
+		return fmt.Errorf("%w（超过 %s）: %v", ErrStepTimeout, timeout, err)
+	}
+	return err
+}
+
+// stepContext 当前步骤的上下文，在运行取消或步骤超时时取消；步骤之外（如读取仓库配置时的引导克隆）为运行的上下文
+func (j *JobContext) stepContext() context.Context {
+	if j.stepCtx != nil {
+		return j.stepCtx
+	}
+	return j.Context
+}
+
+// scriptTimeout 脚本执行使用的上下文与超时时间：以步骤剩余的时间作为脚本的超时，
+// 超时后已读取的输出仍然写入日志，而步骤上下文到期时剩余的输出会被丢弃
+func (j *JobContext) scriptTimeout() (context.Context, time.Duration) {
+	if j.stepCtx == nil {
+		return j.Context, 0
+	}
+	remaining := time.Until(j.stepDeadline)
+	if remaining <= 0 {
+		return j.stepCtx, 0
+	}
+	return j.Context, remaining
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// timeoutPipeline 阶段超时为 stageTimeout、唯一的脚本步骤超时为 stepTimeout 的流水线配置，为空时不设置
+func timeoutPipeline(stageTimeout, stepTimeout, script string) string {
+	var b strings.Builder
+	b.WriteString("stages:\n  - name: build\n")
+	if stageTimeout != "" {
+		fmt.Fprintf(&b, "    timeout: %s\n", stageTimeout)
+	}
+	b.WriteString("    steps:\n      - name: build\n        type: script\n")
+	if stepTimeout != "" {
+		fmt.Fprintf(&b, "        timeout: %s\n", stepTimeout)
+	}
+	fmt.Fprintf(&b, "        config:\n          script: %q\n", script)
+	return b.String()
+}
+
+// TestStepTimeoutPrecedence 超过超时时间的脚本步骤被结束并以 ErrStepTimeout 失败，运行失败而不是取消；
+// 步骤的 timeout 优先于阶段的 timeout，阶段的优先于 deploy.timeout
+func TestStepTimeoutPrecedence(t *testing.T) {
+	tests := []struct {
+		name          string
+		stage, step   string
+		deployTimeout int
+		script        string
+		timedOut      bool
+	}{
+		{"步骤超时", "", "1s", 60, "sleep 20", true},
+		{"步骤的超时优先于阶段", "30s", "1", 60, "sleep 20", true},
+		{"步骤的超时更长时不受阶段限制", "1s", "10s", 60, "sleep 2", false},
+		{"阶段的超时优先于 deploy.timeout", "1s", "", 60, "sleep 20", true},
+		{"阶段的超时更长时不受 deploy.timeout 限制", "10s", "", 1, "sleep 2", false},
+		{"都未设置时使用 deploy.timeout", "", "", 1, "sleep 20", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, project := setupEngineTest(t)
+			e.config.Deploy.Timeout = tt.deployTimeout
+			pipeline := createPipeline(t, project, "build", timeoutPipeline(tt.stage, tt.step, tt.script))
+
+			run := waitRun(t, e, startRun(t, e, pipeline).ID)
+			step := runSteps(t, run.ID)[0]
+			if !tt.timedOut {
+				if run.Status != models.RunStatusSuccess {
+					t.Errorf("运行状态 %s，应为 success: %s", run.Status, run.ErrorMsg)
+				}
+				return
+			}
+			if run.Status != models.RunStatusFailed || step.Status != models.StepStatusFailed {
+				t.Errorf("运行状态 %s、步骤状态 %s，应都为 failed", run.Status, step.Status)
+			}
+			if !strings.Contains(step.ErrorMsg, ErrStepTimeout.Error()+"（超过 1s）") {
+				t.Errorf("步骤错误为 %q，应为超过 1s 的 ErrStepTimeout", step.ErrorMsg)
+			}
+			// 超时结束脚本，不等到 sleep 结束
+			if step.DurationMs >= 10000 {
+				t.Errorf("步骤耗时 %dms，超时后应立即结束脚本", step.DurationMs)
+			}
+		})
+	}
+}
+
+// TestStepTimeoutExternalWait 未设置 timeout 的外部等待步骤不受 deploy.timeout 限制，由自身的等待超时限制
+func TestStepTimeoutExternalWait(t *testing.T) {
+	e, project := setupEngineTest(t)
+	e.config.Deploy.Timeout = 1
+	pipeline := createPipeline(t, project, "approve", `stages:
+  - name: release
+    steps:
+      - name: approve
+        type: external_wait
+        config:
+          timeout: 60
+`)
+
+	run := startRun(t, e, pipeline)
+	var wait models.ExternalWait
+	deadline := time.Now().Add(10 * time.Second)
+	for database.DB.Where("pipeline_run_id = ?", run.ID).First(&wait).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("外部等待没有创建")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// 超过 deploy.timeout 后仍在等待
+	time.Sleep(1500 * time.Millisecond)
+	if step := runSteps(t, run.ID)[0]; step.Status != models.StepStatusWaitingExternal {
+		t.Fatalf("超过 deploy.timeout 后步骤状态 %s，应仍为 waiting_external: %s", step.Status, step.ErrorMsg)
+	}
+
+	if _, _, err := e.ResolveExternalWait(wait.ID, models.ExternalCallbackRequest{Status: "success"}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if run = waitRun(t, e, run.ID); run.Status != models.RunStatusSuccess {
+		t.Errorf("回调成功后运行状态 %s，应为 success: %s", run.Status, run.ErrorMsg)
+	}
+}
@@ -0,0 +1,273 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"flowforge/pkg/models"
+)
+
+// StepExecutor 步骤类型的执行器。引擎按步骤的 type 从注册表中查找执行器执行，内置步骤类型也以执行器注册；
+// 外部代码在 init 中调用 RegisterStepType 注册新的步骤类型，通过构建标签编译进来或以 Go 插件加载，无需修改引擎
+type StepExecutor interface {
+	// Name 步骤类型名称，即流水线配置中步骤的 type
+	Name() string
+	// Schema 步骤 config 的结构，保存流水线与读取仓库配置文件时按此校验
+	Schema() StepSchema
+	// Execute 执行步骤。ctx 在运行取消时取消；返回的输出合并到运行的步骤输出中，
+	// 后续脚本以 STEP_OUTPUT_<KEY> 环境变量读取；返回错误时步骤失败
+	Execute(ctx context.Context, jobCtx *JobContext, config map[string]interface{}) (map[string]string, error)
+}
+
+// ConfigValidator 执行器可选实现的额外配置校验，在结构校验之后调用，返回全部问题
+type ConfigValidator interface {
+	ValidateConfig(config map[string]interface{}) []string
+}
+
+// 配置字段类型，与 JSON 解析后的值对应
+const (
+	FieldString = "string"
+	FieldNumber = "number"
+	FieldBool   = "bool"
+	FieldObject = "object"
+	FieldArray  = "array"
+)
+
+// SchemaField 步骤配置中一个字段的类型与是否必填
+type SchemaField struct {
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// StepSchema 步骤配置的结构；AllowUnknown 为 false 时不允许未声明的字段
+type StepSchema struct {
+	Fields       map[string]SchemaField `json:"fields,omitempty"`
+	AllowUnknown bool                   `json:"allow_unknown"`
+}
+
+// commonStepFields 引擎对所有步骤类型处理的配置字段，不需要在各执行器的结构中声明
+var commonStepFields = map[string]bool{
+	"never_reuse":           true,
+	"fail_on_test_failures": true,
+	"reports":               true,
+	"env":                   true,
+}
+
+// Validate 按结构校验步骤配置，返回全部问题
+func (s StepSchema) Validate(stepName string, config map[string]interface{}) []string {
+	var problems []string
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := s.Fields[name]
+		value, ok := config[name]
+		if !ok || value == nil {
+			if field.Required {
+				problems = append(problems, fmt.Sprintf("步骤 %s 缺少配置 %s", stepName, name))
+			}
+			continue
+		}
+		if !fieldTypeMatches(field.Type, value) {
+			problems = append(problems, fmt.Sprintf("步骤 %s 的配置 %s 应为 %s", stepName, name, field.Type))
+		}
+	}
+
+	if !s.AllowUnknown {
+		unknown := make([]string, 0)
+		for name := range config {
+			if _, ok := s.Fields[name]; !ok && !commonStepFields[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		for _, name := range unknown {
+			problems = append(problems, fmt.Sprintf("步骤 %s 的配置 %s 不受支持", stepName, name))
+		}
+	}
+	return problems
+}
+
+// fieldTypeMatches JSON 解析后的值是否为声明的类型
+func fieldTypeMatches(fieldType string, value interface{}) bool {
+	switch fieldType {
+	case FieldString:
+		_, ok := value.(string)
+		return ok
+	case FieldNumber:
+		_, ok := value.(float64)
+		return ok
+	case FieldBool:
+		_, ok := value.(bool)
+		return ok
+	case FieldObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case FieldArray:
+		_, ok := value.([]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// stepTypes 已注册的步骤类型
+var stepTypes = struct {
+	mu        sync.RWMutex
+	executors map[string]StepExecutor
+}{executors: make(map[string]StepExecutor)}
+
+// RegisterStepType 注册步骤类型，应在 init 中调用；名称为空或已被注册时 panic
+func RegisterStepType(executor StepExecutor) {
+	name := executor.Name()
+	if name == "" {
+		panic("pipeline: 步骤类型名称不能为空")
+	}
+
+	stepTypes.mu.Lock()
+	defer stepTypes.mu.Unlock()
+	if _, exists := stepTypes.executors[name]; exists {
+		panic(fmt.Sprintf("pipeline: 重复注册步骤类型 %s", name))
+	}
+	stepTypes.executors[name] = executor
+}
+
+// LookupStepType 查找已注册的步骤类型
+func LookupStepType(name string) (StepExecutor, bool) {
+	stepTypes.mu.RLock()
+	defer stepTypes.mu.RUnlock()
+	executor, ok := stepTypes.executors[name]
+	return executor, ok
+}
+
+// StepTypeInfo 已注册步骤类型的名称与配置结构
+type StepTypeInfo struct {
+	Name    string     `json:"name"`
+	Schema  StepSchema `json:"schema"`
+	BuiltIn bool       `json:"built_in"`
+}
+
+// StepTypes 已注册的步骤类型，按名称排序
+func StepTypes() []StepTypeInfo {
+	stepTypes.mu.RLock()
+	defer stepTypes.mu.RUnlock()
+
+	infos := make([]StepTypeInfo, 0, len(stepTypes.executors))
+	for name, executor := range stepTypes.executors {
+		_, builtIn := executor.(builtinStep)
+		infos = append(infos, StepTypeInfo{Name: name, Schema: executor.Schema(), BuiltIn: builtIn})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// validateStepConfig 校验步骤类型已注册，并交由执行器校验配置
+func validateStepConfig(step *models.PipelineStep) []string {
+	executor, ok := LookupStepType(step.Type)
+	if !ok {
+		return []string{fmt.Sprintf("步骤 %s 的类型 %q 不受支持", step.Name, step.Type)}
+	}
+	problems := executor.Schema().Validate(step.Name, step.Config)
+	if validator, ok := executor.(ConfigValidator); ok {
+		problems = append(problems, validator.ValidateConfig(step.Config)...)
+	}
+	return append(problems, validateStepEnvNames(step)...)
+}
+
+// validateStepEnvNames 校验步骤 env 中的变量名，按名称排序报告以保证提示稳定
+func validateStepEnvNames(step *models.PipelineStep) []string {
+	envVars, _ := step.Config["env"].(map[string]interface{})
+	names := make([]string, 0, len(envVars))
+	for name := range envVars {
+		if !models.IsValidEnvName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, fmt.Sprintf("步骤 %s 的环境变量名 %q 无效: %v", step.Name, name, models.ErrInvalidEnvName))
+	}
+	return problems
+}
+
+// runStepExecutor 按步骤类型执行步骤，执行器返回的输出合并到运行的步骤输出并记录到步骤上
+func (e *Engine) runStepExecutor(jobCtx *JobContext, step *models.PipelineStep) error {
+	executor, ok := LookupStepType(step.Type)
+	if !ok {
+		return fmt.Errorf("不支持的步骤类型: %s", step.Type)
+	}
+
+	jobCtx.step = step
+	defer func() { jobCtx.step = nil }()
+	outputs, err := executor.Execute(jobCtx.stepContext(), jobCtx, step.Config)
+	if len(outputs) > 0 {
+		if jobCtx.Outputs == nil {
+			jobCtx.Outputs = make(map[string]string)
+		}
+		for key, value := range outputs {
+			jobCtx.Outputs[key] = value
+		}
+		if jobCtx.currentStep != nil {
+			if data, jsonErr := json.Marshal(outputs); jsonErr == nil {
+				jobCtx.db().Model(jobCtx.currentStep).Update("outputs", string(data))
+			}
+		}
+	}
+	return err
+}
+
+// Step 正在执行的步骤配置
+func (j *JobContext) Step() *models.PipelineStep {
+	return j.step
+}
+
+// Log 写入一行运行日志，与系统日志一样按项目规则脱敏
+func (j *JobContext) Log(format string, args ...interface{}) {
+	j.engine.logMessage(j, fmt.Sprintf(format, args...))
+}
+
+// WorkDir 项目工作区目录，git_clone 步骤将代码检出到这里
+func (j *JobContext) WorkDir() string {
+	return j.engine.workspaceDir(j.Project.ID)
+}
+
+// builtinStep 内置步骤类型，执行引擎中对应的方法；配置结构由各方法自行解析，不做结构校验，
+// validate 非空时在保存流水线时校验其中需要提前发现问题的配置
+type builtinStep struct {
+	name     string
+	run      func(e *Engine, jobCtx *JobContext, step *models.PipelineStep) error
+	validate func(config map[string]interface{}) []string
+}
+
+func (b builtinStep) Name() string { return b.name }
+
+func (b builtinStep) Schema() StepSchema { return StepSchema{AllowUnknown: true} }
+
+func (b builtinStep) Execute(ctx context.Context, jobCtx *JobContext, config map[string]interface{}) (map[string]string, error) {
+	return nil, b.run(jobCtx.engine, jobCtx, jobCtx.step)
+}
+
+func (b builtinStep) ValidateConfig(config map[string]interface{}) []string {
+	if b.validate == nil {
+		return nil
+	}
+	return b.validate(config)
+}
+
+func init() {
+	RegisterStepType(builtinStep{name: "git_clone", run: (*Engine).executeGitClone})
+	RegisterStepType(builtinStep{name: "script", run: (*Engine).executeScript, validate: validateScriptConfig})
+	RegisterStepType(builtinStep{name: "build", run: (*Engine).executeBuild})
+	RegisterStepType(builtinStep{name: "deploy", run: (*Engine).executeDeploy, validate: validateDeployConfig})
+	RegisterStepType(builtinStep{name: "external_wait", run: (*Engine).executeExternalWait})
+	RegisterStepType(builtinStep{name: "artifact_from", run: (*Engine).executeArtifactFrom})
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"flowforge/pkg/models"
+	"flowforge/pkg/testreport"
+)
+
+// maxLoggedFailures 运行日志与通知中列出的失败用例数
+const maxLoggedFailures = 5
+
+// collectTestReports 解析步骤 reports 声明的测试报告并保存结果，未声明时返回 nil；
+// 解析失败只记录警告，不影响步骤状态
+func (e *Engine) collectTestReports(jobCtx *JobContext, step *models.PipelineStep, record *models.PipelineStep) *models.TestResult {
+	patterns := configStrings(step.Config["reports"])
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	summary := &testreport.Summary{}
+	var warnings []string
+	files := 0
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := testreport.Glob(workDir, pattern)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		if len(matches) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: 没有匹配的报告文件", pattern))
+		}
+		for _, file := range matches {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+
+			rel, _ := filepath.Rel(workDir, file)
+			parsed, err := testreport.ParseFile(file)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %v", rel, err))
+				continue
+			}
+			summary.Merge(parsed)
+			files++
+		}
+	}
+
+	for _, warning := range warnings {
+		e.logf(jobCtx, "log.test_report_warning", warning)
+	}
+
+	result := &models.TestResult{
+		Total:          summary.Total,
+		Passed:         summary.Passed,
+		Failed:         summary.Failed,
+		Skipped:        summary.Skipped,
+		Duration:       summary.Duration,
+		DurationMs:     int64(summary.Duration * 1000),
+		ReportFiles:    files,
+		Warnings:       strings.Join(warnings, "\n"),
+		PipelineStepID: record.ID,
+		PipelineRunID:  jobCtx.PipelineRun.ID,
+		PipelineID:     jobCtx.Pipeline.ID,
+	}
+	for _, f := range summary.Failures {
+		result.Failures = append(result.Failures, models.TestCaseFailure{
+			Name:          f.Name,
+			Message:       f.Message,
+			PipelineRunID: jobCtx.PipelineRun.ID,
+			PipelineID:    jobCtx.Pipeline.ID,
+		})
+	}
+	if err := jobCtx.db().Create(result).Error; err != nil {
+		log.Printf("保存步骤 %d 的测试结果失败: %v", record.ID, err)
+	}
+
+	e.logf(jobCtx, "log.test_report_summary", result.Total, result.Passed, result.Failed, result.Skipped)
+	if result.Failed > 0 {
+		e.logf(jobCtx, "log.test_report_failures", strings.Join(summary.FailureNames(maxLoggedFailures), ", "))
+	}
+	return result
+}
+
+// configStrings 解析步骤中的字符串列表配置（如 reports），支持单个字符串或字符串列表
+func configStrings(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = append(values, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case []string:
+		values = append(values, v...)
+	}
+
+	result := values[:0]
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"flowforge/pkg/git"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+	"flowforge/pkg/webhook"
+)
+
+// unchangedSkip 没有变化而跳过运行的依据
+type unchangedSkip struct {
+	last   *models.PipelineRun // 同分支最近一次成功运行
+	reason string
+}
+
+// checkUnchanged 开启 skip_if_unchanged 的流水线在执行前与同分支最近一次成功运行比较：提交相同，或设置了路径规则时
+// 两次提交之间没有匹配规则的文件变化；开启 skip_compare_inputs 时还要求解析后的配置与环境变量相同。
+// 强制运行、重跑、源码包运行与 registry 项目的运行不检查，无法确定是否有变化时总是执行
+func (e *Engine) checkUnchanged(jobCtx *JobContext, config *models.PipelineConfig) *unchangedSkip {
+	pipeline, run := jobCtx.Pipeline, jobCtx.PipelineRun
+	if !pipeline.SkipIfUnchanged || run.Forced || run.SourceArchive != "" || run.ArtifactKind != "" || jobCtx.RestoreFrom != "" || len(jobCtx.ReuseSteps) > 0 {
+		return nil
+	}
+
+	// 校验和总是记录，之后开启配置比较时上次成功运行已有可比较的值
+	checksum, err := inputChecksum(jobCtx, config)
+	if err != nil {
+		log.Printf("计算流水线运行 %d 的输入校验和失败: %v", run.ID, err)
+		return nil
+	}
+	run.InputChecksum = checksum
+	jobCtx.db().Model(run).Update("input_checksum", checksum)
+
+	paths := pipeline.SkipPathList()
+	commit, err := e.resolveRunCommit(jobCtx, len(paths) > 0)
+	if err != nil {
+		e.logf(jobCtx, "log.unchanged_check_failed", err)
+		return nil
+	}
+
+	var last models.PipelineRun
+	if err := jobCtx.db().Where("pipeline_id = ? AND branch = ? AND status = ? AND id <> ?",
+		pipeline.ID, run.Branch, models.RunStatusSuccess, run.ID).Order("id DESC").First(&last).Error; err != nil {
+		return nil
+	}
+	if last.CommitSHA == "" {
+		return nil
+	}
+	if pipeline.SkipCompareInputs && last.InputChecksum != checksum {
+		e.logf(jobCtx, "log.unchanged_inputs_changed", last.ID)
+		return nil
+	}
+
+	if commit == last.CommitSHA {
+		return &unchangedSkip{last: &last, reason: fmt.Sprintf("unchanged since run #%d (commit %s)", last.ID, shortCommit(commit))}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	workDir := e.workspaceDir(jobCtx.Project.ID)
+	files, err := e.gitManager.GetClient().ChangedFiles(workDir, last.CommitSHA, commit)
+	if err != nil {
+		if errors.Is(err, git.ErrUnknownRange) {
+			err = fmt.Errorf("工作区中没有上次成功运行的提交 %s", shortCommit(last.CommitSHA))
+		}
+		e.logf(jobCtx, "log.unchanged_check_failed", err)
+		return nil
+	}
+	for _, file := range files {
+		for _, pattern := range paths {
+			if webhook.MatchPath(pattern, file) {
+				return nil
+			}
+		}
+	}
+	return &unchangedSkip{
+		last:   &last,
+		reason: fmt.Sprintf("no changes under %s since run #%d (commit %s)", strings.Join(paths, ", "), last.ID, shortCommit(last.CommitSHA)),
+	}
+}
+
+// resolveRunCommit 本次运行要构建的提交。触发时未指定提交的运行查询远程分支指向的提交；
+// 需要比较文件变化时先拉取代码，以工作区中取得的提交为准
+func (e *Engine) resolveRunCommit(jobCtx *JobContext, needWorkspace bool) (string, error) {
+	run := jobCtx.PipelineRun
+	client := e.gitManager.GetClient()
+
+	// 仓库配置来源的流水线已在读取配置时拉取代码
+	if needWorkspace && !jobCtx.Pipeline.UsesRepoConfig() {
+		bootstrap := &models.PipelineStep{Name: "bootstrap", Type: "git_clone"}
+		if err := e.executeGitClone(jobCtx, bootstrap); err != nil {
+			return "", err
+		}
+	}
+	if run.CommitSHA != "" {
+		return run.CommitSHA, nil
+	}
+
+	var commit string
+	if needWorkspace {
+		workDir := e.workspaceDir(jobCtx.Project.ID)
+		hash, _, err := client.GetCommitInfo(workDir)
+		if err != nil {
+			return "", err
+		}
+		commit = hash
+	} else {
+		branches, err := client.ListRemoteBranches(jobCtx.Context, jobCtx.Project, jobCtx.Project.SSHKey)
+		if err != nil {
+			return "", err
+		}
+		if commit = branches.Head(run.Branch); commit == "" {
+			return "", fmt.Errorf("远程仓库不存在分支 %s", run.Branch)
+		}
+	}
+
+	// 记录比较时使用的提交，下一次运行与之比较
+	run.CommitSHA = commit
+	jobCtx.db().Model(run).Update("commit_sha", commit)
+	return commit, nil
+}
+
+// skipUnchangedRun 结束没有变化的运行：状态为 skipped，记录原因与对应的上次成功运行，按其耗时计入节省的执行时间
+func (e *Engine) skipUnchangedRun(jobCtx *JobContext, skip *unchangedSkip) {
+	run := jobCtx.PipelineRun
+	run.SkipReason = skip.reason
+	run.UnchangedFromID = &skip.last.ID
+	run.SavedMs = skip.last.DurationMs
+	if err := jobCtx.db().Model(run).Updates(map[string]interface{}{
+		"skip_reason":       run.SkipReason,
+		"unchanged_from_id": run.UnchangedFromID,
+		"saved_ms":          run.SavedMs,
+	}).Error; err != nil {
+		log.Printf("记录流水线运行 %d 的跳过原因失败: %v", run.ID, err)
+	}
+
+	e.finishPipelineRun(jobCtx, models.RunStatusSkipped, i18n.T(jobCtx.Locale, "log.run_unchanged", skip.reason))
+}
+
+// inputChecksum 解析后的配置、环境变量组与项目环境变量的校验和，项目环境变量的值只以指纹参与计算
+func inputChecksum(jobCtx *JobContext, config *models.PipelineConfig) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("序列化配置失败: %w", err)
+	}
+
+	var envs []models.Environment
+	if err := jobCtx.db().Where("project_id = ?", jobCtx.Project.ID).Find(&envs).Error; err != nil {
+		return "", fmt.Errorf("读取项目环境变量失败: %w", err)
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Key < envs[j].Key })
+
+	hash := sha256.New()
+	hash.Write(data)
+	for _, env := range envs {
+		fmt.Fprintf(hash, "\n%s=%s", env.Key, redact.Fingerprint(env.Value))
+	}
+	// 环境变量组的取值不在配置中，没有引用时不参与计算，校验和与引入环境变量组之前相同
+	groupKeys := make([]string, 0, len(jobCtx.envGroups))
+	for key := range jobCtx.envGroups {
+		groupKeys = append(groupKeys, key)
+	}
+	sort.Strings(groupKeys)
+	for _, key := range groupKeys {
+		fmt.Fprintf(hash, "\n%s:%s=%s", EnvSourceEnvGroup, key, jobCtx.envGroups[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// shortCommit 日志与跳过原因中显示的短提交哈希
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
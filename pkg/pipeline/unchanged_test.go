@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// localRepo 临时目录中的 Git 仓库，作为项目的远程
+type localRepo struct {
+	dir string
+}
+
+func newLocalRepo(t *testing.T) *localRepo {
+	t.Helper()
+	repo := &localRepo{dir: t.TempDir()}
+	repo.git(t, "init", "-q", "-b", "main")
+	repo.commit(t, "README.md", "web")
+	return repo
+}
+
+func (r *localRepo) git(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = r.dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v 失败: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit 写入文件并提交，返回提交哈希
+func (r *localRepo) commit(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(r.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r.git(t, "add", name)
+	r.git(t, "commit", "-q", "-m", "update "+name)
+	return r.git(t, "rev-parse", "HEAD")
+}
+
+// unchangedYAML 拉取代码后执行脚本的流水线配置，脚本在工作区留下 built.txt
+const unchangedYAML = "stages:\n  - name: build\n    steps:\n      - name: checkout\n        type: git_clone\n        config: {}\n" +
+	"      - name: build\n        type: script\n        config:\n          script: \"touch built.txt\"\n"
+
+// setupUnchangedTest 远程为本地仓库、开启 skip_if_unchanged 的流水线，paths 为路径规则
+func setupUnchangedTest(t *testing.T, paths string, compareInputs bool) (*Engine, *models.Project, *models.Pipeline, *localRepo) {
+	t.Helper()
+	e, project := setupEngineTest(t)
+	repo := newLocalRepo(t)
+	project.RepoURL = repo.dir
+	database.DB.Model(project).Update("repo_url", repo.dir)
+
+	pipeline := createPipeline(t, project, "nightly", unchangedYAML)
+	database.DB.Model(pipeline).Updates(map[string]interface{}{
+		"skip_if_unchanged":   true,
+		"skip_compare_inputs": compareInputs,
+		"skip_paths":          paths,
+	})
+	return e, project, pipeline, repo
+}
+
+// runOnce 触发一次运行并等待结束，返回运行记录与是否执行了构建步骤
+func runOnce(t *testing.T, e *Engine, project *models.Project, pipeline *models.Pipeline, opts RunOptions) (*models.PipelineRun, bool) {
+	t.Helper()
+	built := filepath.Join(e.workspaceDir(project.ID), "built.txt")
+	os.Remove(built)
+	run, err := e.RunPipelineWithOptions(pipeline.ID, models.TriggerManual, testUserID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := waitRun(t, e, run.ID)
+	_, err = os.Stat(built)
+	return finished, err == nil
+}
+
+func expectExecuted(t *testing.T, run *models.PipelineRun, built bool, why string) {
+	t.Helper()
+	if run.Status != models.RunStatusSuccess || !built || run.SkipReason != "" || run.UnchangedFromID != nil {
+		t.Fatalf("%s应执行运行，实际状态 %s（跳过原因 %q）: %s", why, run.Status, run.SkipReason, run.ErrorMsg)
+	}
+}
+
+func expectSkipped(t *testing.T, run *models.PipelineRun, built bool, last *models.PipelineRun, reason string) {
+	t.Helper()
+	if run.Status != models.RunStatusSkipped || built {
+		t.Fatalf("运行状态 %s，应跳过且不执行步骤: %s", run.Status, run.ErrorMsg)
+	}
+	if run.UnchangedFromID == nil || *run.UnchangedFromID != last.ID || run.SavedMs != last.DurationMs {
+		t.Errorf("跳过的运行对应 %v、节省 %dms，应为运行 %d 的 %dms", run.UnchangedFromID, run.SavedMs, last.ID, last.DurationMs)
+	}
+	if !strings.Contains(run.SkipReason, reason) || run.EndTime == nil {
+		t.Errorf("跳过原因为 %q，应包含 %q", run.SkipReason, reason)
+	}
+}
+
+// TestSkipIfUnchangedCommit 提交与同分支上次成功运行相同时跳过并记录原因与节省的时长；
+// 有新提交或强制运行时照常执行，跳过的运行不作为之后比较的基准
+func TestSkipIfUnchangedCommit(t *testing.T) {
+	e, project, pipeline, repo := setupUnchangedTest(t, "", false)
+
+	first, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, first, built, "第一次运行没有可比较的成功运行，")
+	if head := repo.git(t, "rev-parse", "HEAD"); first.CommitSHA != head {
+		t.Errorf("运行记录的提交为 %s，应为远程分支的 %s", first.CommitSHA, head)
+	}
+
+	second, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectSkipped(t, second, built, first, "commit "+shortCommit(first.CommitSHA))
+	if steps := runSteps(t, second.ID); len(steps) != 0 {
+		t.Errorf("跳过的运行有 %d 个步骤记录", len(steps))
+	}
+
+	forced, built := runOnce(t, e, project, pipeline, RunOptions{Force: true})
+	expectExecuted(t, forced, built, "强制运行")
+
+	commit := repo.commit(t, "main.go", "package main")
+	changed, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, changed, built, "有新提交时")
+	if changed.CommitSHA != commit {
+		t.Errorf("运行记录的提交为 %s，应为新提交 %s", changed.CommitSHA, commit)
+	}
+
+	again, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectSkipped(t, again, built, changed, "#")
+}
+
+// TestSkipIfUnchangedInputs 开启 skip_compare_inputs 时配置或环境变量变化后照常执行，
+// 没有开启时只比较提交
+func TestSkipIfUnchangedInputs(t *testing.T) {
+	e, project, pipeline, _ := setupUnchangedTest(t, "", true)
+	first, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, first, built, "第一次运行")
+
+	database.DB.Create(&models.Environment{ProjectID: project.ID, Key: "API_URL", Value: "https://api.example.com"})
+	envChanged, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, envChanged, built, "环境变量变化后")
+
+	database.DB.Model(pipeline).Update("config", strings.Replace(unchangedYAML, "touch built.txt", "touch built.txt ok.txt", 1))
+	configChanged, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, configChanged, built, "配置变化后")
+
+	same, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectSkipped(t, same, built, configChanged, "#")
+
+	// 只比较提交时，环境变量变化不影响跳过
+	database.DB.Model(pipeline).Update("skip_compare_inputs", false)
+	database.DB.Model(&models.Environment{}).Where("project_id = ?", project.ID).Update("value", "https://api2.example.com")
+	commitOnly, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectSkipped(t, commitOnly, built, configChanged, "#")
+}
+
+// TestSkipIfUnchangedPaths 设置了路径规则时，两次提交之间只有规则以外的文件变化则跳过，
+// 规则内的文件变化后照常执行
+func TestSkipIfUnchangedPaths(t *testing.T) {
+	e, project, pipeline, repo := setupUnchangedTest(t, "src/**, go.mod", false)
+	first, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, first, built, "第一次运行")
+
+	repo.commit(t, "docs/guide.md", "guide")
+	docs, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectSkipped(t, docs, built, first, "no changes under src/**, go.mod")
+	if head := repo.git(t, "rev-parse", "HEAD"); docs.CommitSHA != head {
+		t.Errorf("跳过的运行记录的提交为 %s，应为 %s", docs.CommitSHA, head)
+	}
+
+	repo.commit(t, "src/app/main.go", "package main")
+	src, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, src, built, "规则内的文件变化后")
+
+	repo.commit(t, "README.md", "web app")
+	readme, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectSkipped(t, readme, built, src, "since run #")
+
+	repo.commit(t, "go.mod", "module web")
+	mod, built := runOnce(t, e, project, pipeline, RunOptions{})
+	expectExecuted(t, mod, built, "go.mod 变化后")
+}
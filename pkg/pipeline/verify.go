@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/httpclient"
+	"flowforge/pkg/ssh"
+)
+
+// validateDeployConfig 保存流水线时校验部署步骤的 verify 配置，超时与重试超出范围时提前报告
+func validateDeployConfig(config map[string]interface{}) []string {
+	if config["verify"] == nil {
+		return nil
+	}
+	if deployType, _ := config["type"].(string); deployType != "ssh" {
+		return []string{"verify 只支持 ssh 类型的部署"}
+	}
+	if _, err := deploy.ParseVerifyConfig(config["verify"]); err != nil {
+		return []string{fmt.Sprintf("部署验证配置无效: %v", err)}
+	}
+	return nil
+}
+
+// verifyDeployment 部署后命令执行完后验证服务，未通过时按配置在目标上执行回滚命令；返回的错误使步骤失败
+func (e *Engine) verifyDeployment(jobCtx *JobContext, sshClient *ssh.Client, target *deploy.DriftTarget, verify *deploy.VerifyConfig) (*deploy.VerifyReport, error) {
+	e.logf(jobCtx, "log.verify_started", target.Key(), len(verify.Checks), verify.GracePeriod)
+
+	ctx := httpclient.WithProject(jobCtx.stepContext(), jobCtx.Project.ID)
+	report := e.verifier.Run(ctx, target, verify, func(check deploy.VerifyCheck, attempt int, err error) {
+		e.logf(jobCtx, "log.verify_attempt_failed", check.Name, attempt, *verify.Retries+1, err)
+	})
+	if report.Passed {
+		e.logf(jobCtx, "log.verify_passed", target.Key())
+		return report, nil
+	}
+
+	verifyErr := fmt.Errorf("部署目标 %s 未通过部署后验证: %s", target.Key(), report.Summary())
+	if len(verify.RollbackCommands) == 0 || jobCtx.stepContext().Err() != nil {
+		return report, verifyErr
+	}
+
+	e.logf(jobCtx, "log.verify_rollback", target.Key())
+	originRunID := e.originRunID(jobCtx.PipelineRun)
+	for i, command := range verify.RollbackCommands {
+		e.logf(jobCtx, "log.remote_command", target.Host, command)
+		_, err := sshClient.ExecuteSupervised(jobCtx.stepContext(), target.SSHKey, target.Host, target.Port, target.Username, command, ssh.SuperviseOptions{
+			StreamOptions: ssh.StreamOptions{
+				LogCallback: func(line string) {
+					e.logMessage(jobCtx, fmt.Sprintf("[remote:%s] %s", target.Host, line))
+				},
+			},
+			RunKey: fmt.Sprintf("run-%d-step-%d-rollback-%d", originRunID, jobCtx.stepOrder, i),
+			Progress: func(message string) {
+				e.logMessage(jobCtx, fmt.Sprintf("[remote:%s] %s", target.Host, message))
+			},
+		})
+		if err != nil {
+			report.RollbackError = err.Error()
+			e.logf(jobCtx, "log.verify_rollback_failed", target.Key(), err)
+			return report, verifyErr
+		}
+	}
+	report.RolledBack = true
+	report.FinishedAt = time.Now()
+	e.logf(jobCtx, "log.verify_rolled_back", target.Key())
+	return report, verifyErr
+}
+
+// verifyJSON 序列化部署后验证结果，未配置验证时为空
+func verifyJSON(report *deploy.VerifyReport) string {
+	if report == nil {
+		return ""
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,400 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/i18n"
+	"flowforge/pkg/models"
+)
+
+// workerStaleAfter 执行器超过该时间未更新心跳视为不可用，API实例不再把运行交给它
+const workerStaleAfter = 3 * heartbeatInterval
+
+// 运行交给执行器的方式，对应 worker.local_execution
+const (
+	LocalExecutionAuto   = "auto"
+	LocalExecutionAlways = "always"
+	LocalExecutionNever  = "never"
+)
+
+// WorkerStatus 执行器及其是否存活
+type WorkerStatus struct {
+	models.Worker
+	Alive bool `json:"alive"`
+}
+
+// WorkerFleet 执行器列表与汇总
+type WorkerFleet struct {
+	Workers        []WorkerStatus `json:"workers"`
+	Alive          int            `json:"alive"`
+	Capacity       int            `json:"capacity"` // 存活执行器的名额之和
+	Running        int            `json:"running"`
+	AwaitingRuns   int64          `json:"awaiting_runs"` // 等待执行器领取的运行数
+	LocalExecution string         `json:"local_execution"`
+}
+
+// RunWorker 以执行器模式运行：注册执行器，按间隔领取交给执行器的运行并执行，直到 stop 关闭。
+// 关闭后进入排空：不再领取新的运行，已领取的运行执行完毕后注销并返回
+func (e *Engine) RunWorker(version string, stop <-chan struct{}) error {
+	worker, err := e.registerWorker(version)
+	if err != nil {
+		return err
+	}
+	e.worker = worker
+	log.Printf("执行器 %s 已注册，名额 %d", worker.Name, worker.Capacity)
+
+	poll := time.NewTicker(time.Duration(e.config.Worker.PollInterval) * time.Second)
+	defer poll.Stop()
+	beat := time.NewTicker(heartbeatInterval)
+	defer beat.Stop()
+
+	draining := false
+	for {
+		select {
+		case <-poll.C:
+			e.syncRemoteRuns()
+			if !draining {
+				e.claimRuns(&worker.ID, worker.Name)
+			} else if e.activeJobs() == 0 {
+				e.setWorkerStatus(models.WorkerStatusStopped)
+				log.Printf("执行器 %s 已排空并注销", worker.Name)
+				return nil
+			}
+		case <-beat.C:
+			e.setWorkerStatus(worker.Status)
+		case <-stop:
+			stop = nil
+			draining = true
+			e.setWorkerStatus(models.WorkerStatusDraining)
+			log.Printf("执行器 %s 开始排空，等待 %d 个运行结束", worker.Name, e.activeJobs())
+		}
+	}
+}
+
+// registerWorker 按名称注册执行器；同名执行器重启前领取且仍未结束的运行已随进程中断，直接标记为失败
+func (e *Engine) registerWorker(version string) (*models.Worker, error) {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+
+	worker := &models.Worker{Name: e.config.Worker.Name}
+	if err := database.DB.Where(models.Worker{Name: worker.Name}).Assign(map[string]interface{}{
+		"hostname":          hostname,
+		"version":           version,
+		"status":            models.WorkerStatusActive,
+		"capacity":          e.config.Worker.Capacity,
+		"shells":            strings.Join(e.scriptManager.AvailableShells(), ","),
+		"running":           0,
+		"started_at":        now,
+		"last_heartbeat_at": now,
+	}).FirstOrCreate(worker).Error; err != nil {
+		return nil, fmt.Errorf("注册执行器失败: %w", err)
+	}
+
+	var orphaned []models.PipelineRun
+	database.DB.Select("id").Where("worker_id = ? AND status IN ?", worker.ID,
+		[]string{models.RunStatusRunning, models.RunStatusPending}).Find(&orphaned)
+	for _, run := range orphaned {
+		log.Printf("流水线运行 %d 在执行器 %s 重启前未结束，标记为失败", run.ID, worker.Name)
+		e.markRunFailed(run.ID, fmt.Sprintf("executor lost: 执行器 %s 重启", worker.Name))
+		database.DB.Model(&models.PipelineRun{}).Where("id = ?", run.ID).Update("failure_kind", models.FailureKindInfra)
+	}
+	return worker, nil
+}
+
+// setWorkerStatus 更新执行器的状态、心跳与正在执行的运行数
+func (e *Engine) setWorkerStatus(status string) {
+	e.worker.Status = status
+	if err := database.DB.Model(e.worker).Updates(map[string]interface{}{
+		"status":            status,
+		"running":           e.activeJobs(),
+		"last_heartbeat_at": time.Now(),
+	}).Error; err != nil {
+		log.Printf("更新执行器 %s 心跳失败: %v", e.worker.Name, err)
+	}
+}
+
+// activeJobs 本实例执行中与排队的运行数
+func (e *Engine) activeJobs() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.runningJobs) + len(e.queue.items)
+}
+
+// dispatchToWorker 运行是否交给执行器：执行器自身、仅重跑失败步骤（依赖本实例保留的工作区）与上传源码包的运行总是本地执行
+func (e *Engine) dispatchToWorker(jobCtx *JobContext) bool {
+	if e.worker != nil || jobCtx.RestoreFrom != "" || len(jobCtx.ReuseSteps) > 0 || jobCtx.PipelineRun.SourceArchive != "" {
+		return false
+	}
+	switch e.config.Worker.LocalExecution {
+	case LocalExecutionAlways:
+		return false
+	case LocalExecutionNever:
+		return true
+	default:
+		return e.aliveWorkers() > 0
+	}
+}
+
+// queueForWorker 将运行标记为等待执行器领取，本实例不再持有任务上下文
+func (e *Engine) queueForWorker(jobCtx *JobContext) {
+	jobCtx.PipelineRun.Status = models.RunStatusPending
+	jobCtx.PipelineRun.AwaitingWorker = true
+	if err := database.DB.Model(&models.PipelineRun{}).Where("id = ?", jobCtx.PipelineRun.ID).Updates(map[string]interface{}{
+		"status":          models.RunStatusPending,
+		"awaiting_worker": true,
+	}).Error; err != nil {
+		// 无法交给执行器时在本地执行
+		log.Printf("流水线运行 %d 交给执行器失败: %v", jobCtx.PipelineRun.ID, err)
+		e.enqueueOrStart(jobCtx)
+		return
+	}
+
+	e.logf(jobCtx, "log.run_queued_worker")
+	e.logWriter.Flush(jobCtx.PipelineRun.ID)
+	e.releaseJob(jobCtx)
+}
+
+// aliveWorkers 存活的执行器数量
+func (e *Engine) aliveWorkers() int64 {
+	var count int64
+	if err := database.DB.Model(&models.Worker{}).Where("status <> ? AND last_heartbeat_at > ?",
+		models.WorkerStatusStopped, time.Now().Add(-workerStaleAfter)).Count(&count).Error; err != nil {
+		log.Printf("查询执行器失败: %v", err)
+		return 0
+	}
+	return count
+}
+
+// claimRuns 按触发顺序领取等待执行器的运行，手动触发的运行优先，直到本实例没有空闲名额。
+// 领取以条件更新完成，多个实例同时领取同一个运行时只有一个成功；项目并发上限与互斥组按数据库中所有实例执行中的运行检查
+func (e *Engine) claimRuns(workerID *uint, workerName string) {
+	free := e.maxConcurrent() - e.activeJobs()
+	if free <= 0 {
+		return
+	}
+
+	var candidates []models.PipelineRun
+	if err := database.DB.Select("id", "pipeline_id").
+		Where("awaiting_worker = ? AND status = ?", true, models.RunStatusPending).
+		Order("CASE WHEN trigger_type = 'manual' THEN 0 ELSE 1 END, id").
+		Limit(free * 4).Find(&candidates).Error; err != nil {
+		log.Printf("查询等待执行器的运行失败: %v", err)
+		return
+	}
+
+	for _, candidate := range candidates {
+		if free == 0 {
+			return
+		}
+
+		var pipeline models.Pipeline
+		if err := models.WithPrivateKey(database.DB).Preload("Project").Preload("Project.DeployKey").Preload("Project.SSHKey").
+			First(&pipeline, candidate.PipelineID).Error; err != nil {
+			e.markRunFailed(candidate.ID, fmt.Sprintf("获取流水线失败: %v", err))
+			continue
+		}
+		if !claimAllowed(&pipeline) {
+			continue
+		}
+
+		run, ok := claimRun(candidate.ID, workerID)
+		if !ok {
+			continue
+		}
+		free--
+		// 按触发时记录的流水线版本执行，排队期间保存的修改不影响该运行
+		if run.PipelineRevision > 0 {
+			if err := pinRevision(&pipeline, run.PipelineRevision); err != nil {
+				log.Printf("流水线运行 %d 沿用版本 %d 失败，使用当前配置: %v", run.ID, run.PipelineRevision, err)
+			}
+		}
+
+		jobCtx := newJobContext(&pipeline, run, nil, "")
+		if workerID != nil {
+			e.logf(jobCtx, "log.run_claimed", workerName)
+		} else {
+			e.logf(jobCtx, "log.run_claimed_local")
+		}
+		e.enqueueOrStart(jobCtx)
+	}
+}
+
+// claimAllowed 按所有实例执行中的运行检查项目并发上限与互斥组
+func claimAllowed(pipeline *models.Pipeline) bool {
+	running := func(scope string, args ...interface{}) int64 {
+		var count int64
+		database.DB.Model(&models.PipelineRun{}).
+			Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+			Where("pipeline_runs.status = ?", models.RunStatusRunning).
+			Where(scope, args...).Count(&count)
+		return count
+	}
+
+	if group := pipeline.MutexGroup; group != "" &&
+		running("pipelines.project_id = ? AND pipelines.mutex_group = ?", pipeline.Project.ID, group) > 0 {
+		return false
+	}
+	if limit := pipeline.Project.MaxConcurrentRuns; limit > 0 &&
+		running("pipelines.project_id = ?", pipeline.Project.ID) >= int64(limit) {
+		return false
+	}
+	return true
+}
+
+// claimRun 以条件更新领取运行，已被其他实例领取或已取消时返回 false
+func claimRun(runID uint, workerID *uint) (*models.PipelineRun, bool) {
+	now := time.Now()
+	result := database.DB.Model(&models.PipelineRun{}).
+		Where("id = ? AND awaiting_worker = ? AND status = ?", runID, true, models.RunStatusPending).
+		Updates(map[string]interface{}{
+			"awaiting_worker":   false,
+			"worker_id":         workerID,
+			"status":            models.RunStatusRunning,
+			"start_time":        &now,
+			"last_heartbeat_at": &now,
+		})
+	if result.Error != nil {
+		log.Printf("领取流水线运行 %d 失败: %v", runID, result.Error)
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	var run models.PipelineRun
+	if err := database.DB.First(&run, runID).Error; err != nil {
+		log.Printf("读取已领取的流水线运行 %d 失败: %v", runID, err)
+		return nil, false
+	}
+	return &run, true
+}
+
+// syncRemoteRuns 执行器同步其他实例写入的状态：取消在API实例上被取消的运行，通知已收到回调的外部等待
+func (e *Engine) syncRemoteRuns() {
+	e.mu.RLock()
+	runIDs := make([]uint, 0, len(e.runningJobs))
+	for runID := range e.runningJobs {
+		runIDs = append(runIDs, runID)
+	}
+	waitIDs := make([]uint, 0, len(e.externalWaits))
+	for waitID := range e.externalWaits {
+		waitIDs = append(waitIDs, waitID)
+	}
+	e.mu.RUnlock()
+
+	if len(runIDs) > 0 {
+		var cancelled []models.PipelineRun
+		database.DB.Select("id", "cancellation_reason", "cancellation_detail", "cancelled_by_id").
+			Where("id IN ? AND status = ?", runIDs, models.RunStatusCancelled).Find(&cancelled)
+		for _, run := range cancelled {
+			e.mu.RLock()
+			jobCtx, ok := e.runningJobs[run.ID]
+			e.mu.RUnlock()
+			if ok && jobCtx.Context.Err() == nil {
+				jobCtx.setCancellation(Cancellation{Reason: run.CancellationReason, Detail: run.CancellationDetail, ActorID: run.CancelledByID})
+				jobCtx.Cancel()
+				e.logf(jobCtx, "log.run_cancelled")
+			}
+		}
+	}
+
+	if len(waitIDs) > 0 {
+		var settled []uint
+		database.DB.Model(&models.ExternalWait{}).Where("id IN ? AND status <> ?", waitIDs, models.ExternalWaitWaiting).
+			Pluck("id", &settled)
+		for _, waitID := range settled {
+			e.notifyExternalWait(waitID)
+		}
+	}
+}
+
+// cancelRemote 取消不在本实例执行的运行：等待执行器领取的运行直接结束，执行器上的运行由执行器同步状态后中止
+func (e *Engine) cancelRemote(runID uint, cancel Cancellation) error {
+	updates := map[string]interface{}{
+		"status":          models.RunStatusCancelled,
+		"awaiting_worker": false,
+		"end_time":        time.Now(),
+		"error_msg":       "流水线运行已被取消",
+	}
+	for column, value := range cancel.columns() {
+		updates[column] = value
+	}
+	active := []string{models.RunStatusRunning, models.RunStatusPending}
+
+	// 还没有执行器领取的运行不会再有日志写入，在这里记录最后一行
+	result := database.DB.Model(&models.PipelineRun{}).
+		Where("id = ? AND status IN ? AND awaiting_worker = ?", runID, active, true).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		run := &models.PipelineRun{}
+		cancel.apply(run)
+		e.logWriter.AppendLog(runID, fmt.Sprintf("[%s] %s", time.Now().Format("2006-01-02 15:04:05"),
+			i18n.T(i18n.DefaultLocale, "log.run_cancel_reason", run.CancellationSummary())))
+		e.logWriter.Flush(runID)
+		return nil
+	}
+
+	result = database.DB.Model(&models.PipelineRun{}).
+		Where("id = ? AND status IN ? AND worker_id IS NOT NULL", runID, active).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("流水线运行不存在或已完成")
+	}
+	return nil
+}
+
+// ClaimOrphanedRuns API实例在没有存活的执行器时领取等待执行器的运行在本地执行；local_execution 为 never 时不领取
+func (e *Engine) ClaimOrphanedRuns() {
+	if e.worker != nil || atomic.LoadInt32(&e.shuttingDown) == 1 {
+		return
+	}
+	switch e.config.Worker.LocalExecution {
+	case LocalExecutionNever:
+		return
+	case LocalExecutionAuto:
+		if e.aliveWorkers() > 0 {
+			return
+		}
+	}
+	e.claimRuns(nil, "")
+}
+
+// Workers 执行器列表，存活的排在前面
+func (e *Engine) Workers() (*WorkerFleet, error) {
+	var workers []models.Worker
+	if err := database.DB.Order("name").Find(&workers).Error; err != nil {
+		return nil, fmt.Errorf("查询执行器失败: %w", err)
+	}
+
+	fleet := &WorkerFleet{Workers: make([]WorkerStatus, 0, len(workers)), LocalExecution: e.config.Worker.LocalExecution}
+	deadline := time.Now().Add(-workerStaleAfter)
+	var stale []WorkerStatus
+	for _, worker := range workers {
+		status := WorkerStatus{Worker: worker, Alive: worker.Status != models.WorkerStatusStopped && worker.LastHeartbeatAt.After(deadline)}
+		if !status.Alive {
+			stale = append(stale, status)
+			continue
+		}
+		fleet.Alive++
+		fleet.Capacity += worker.Capacity
+		fleet.Running += worker.Running
+		fleet.Workers = append(fleet.Workers, status)
+	}
+	fleet.Workers = append(fleet.Workers, stale...)
+
+	if err := database.DB.Model(&models.PipelineRun{}).Where("awaiting_worker = ? AND status = ?", true, models.RunStatusPending).
+		Count(&fleet.AwaitingRuns).Error; err != nil {
+		return nil, fmt.Errorf("查询等待执行器的运行失败: %w", err)
+	}
+	return fleet, nil
+}
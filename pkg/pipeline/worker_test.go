@@ -0,0 +1,298 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/git"
+	"flowforge/pkg/models"
+	"flowforge/pkg/scripts"
+)
+
+// testWorker 以执行器模式运行的引擎，与API实例共用测试数据库，工作区相互独立
+type testWorker struct {
+	*Engine
+	name string
+	stop chan struct{}
+	done chan struct{} // RunWorker 返回后关闭，返回值为 err
+	err  error
+}
+
+// startWorker 启动名为 name、名额为 capacity 的执行器，测试结束时排空
+func startWorker(t *testing.T, api *Engine, project *models.Project, name string, capacity int) *testWorker {
+	t.Helper()
+	cfg := *api.config
+	cfg.Deploy.WorkspaceDir = t.TempDir()
+	cfg.Worker = config.WorkerConfig{Name: name, Capacity: capacity, PollInterval: 1}
+
+	w := &testWorker{
+		Engine: NewEngine(&cfg, scripts.NewManager(&cfg), git.NewManager(&cfg)),
+		name:   name,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	t.Cleanup(w.Shutdown)
+	if err := os.MkdirAll(w.workspaceDir(project.ID), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.err = w.RunWorker("test", w.stop)
+		close(w.done)
+	}()
+	t.Cleanup(w.drain)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for database.DB.Where("name = ? AND status = ?", name, models.WorkerStatusActive).First(&models.Worker{}).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("执行器 %s 没有注册", name)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return w
+}
+
+// drain 通知执行器排空并等待其退出
+func (w *testWorker) drain() {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	select {
+	case <-w.done:
+	case <-time.After(30 * time.Second):
+	}
+}
+
+// workerID 执行器的注册记录ID
+func (w *testWorker) workerID(t *testing.T) uint {
+	t.Helper()
+	var worker models.Worker
+	if err := database.DB.Where("name = ?", w.name).First(&worker).Error; err != nil {
+		t.Fatal(err)
+	}
+	return worker.ID
+}
+
+// waitFinished 等待运行结束且不在任何实例中执行
+func waitFinished(t *testing.T, runID uint, engines ...*Engine) *models.PipelineRun {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		var run models.PipelineRun
+		if err := database.DB.First(&run, runID).Error; err != nil {
+			t.Fatal(err)
+		}
+		running := false
+		for _, e := range engines {
+			running = running || e.isRunning(runID)
+		}
+		if run.Status != models.RunStatusRunning && run.Status != models.RunStatusPending && !running {
+			return &run
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("运行 %d 没有在 30 秒内结束", runID)
+	return nil
+}
+
+// TestWorkersExecuteQueuedRuns 一个API实例与两个执行器：有存活的执行器时API实例只把运行标记为等待领取，
+// 每个运行只由一个执行器领取并执行一次，各执行器同时执行的运行不超过名额，执行器列表汇总名额与排队数
+func TestWorkersExecuteQueuedRuns(t *testing.T) {
+	api, project := setupEngineTest(t)
+	gates := t.TempDir()
+	w1 := startWorker(t, api, project, "worker-1", 2)
+	w2 := startWorker(t, api, project, "worker-2", 2)
+	pipeline := createPipeline(t, project, "build", scriptPipeline(waitGate(gates, "release")))
+
+	const total = 6
+	runIDs := make([]uint, total)
+	for i := range runIDs {
+		run := startRun(t, api, pipeline)
+		runIDs[i] = run.ID
+		if api.isRunning(run.ID) {
+			t.Errorf("有存活的执行器时API实例执行了运行 %d", run.ID)
+		}
+	}
+
+	fleet, err := api.Workers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fleet.Alive != 2 || fleet.Capacity != 4 || len(fleet.Workers) != 2 {
+		t.Errorf("执行器列表为 %+v，应有 2 个存活的执行器、共 4 个名额", fleet)
+	}
+
+	// 两个执行器各自领满名额，其余运行继续等待
+	deadline := time.Now().Add(10 * time.Second)
+	for w1.activeJobs() < 2 || w2.activeJobs() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("执行器没有领满名额: %s 执行 %d 个，%s 执行 %d 个", w1.name, w1.activeJobs(), w2.name, w2.activeJobs())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if a, b := w1.activeJobs(), w2.activeJobs(); a > 2 || b > 2 {
+		t.Errorf("执行器同时执行 %d 与 %d 个运行，超过名额 2", a, b)
+	}
+	var awaiting int64
+	database.DB.Model(&models.PipelineRun{}).Where("awaiting_worker = ?", true).Count(&awaiting)
+	if awaiting != total-4 {
+		t.Errorf("等待领取的运行有 %d 个，应为 %d 个", awaiting, total-4)
+	}
+
+	openGate(t, gates, "release")
+	claimed := map[uint]int{}
+	for _, runID := range runIDs {
+		run := waitFinished(t, runID, api, w1.Engine, w2.Engine)
+		if run.Status != models.RunStatusSuccess || run.AwaitingWorker || run.WorkerID == nil {
+			t.Errorf("运行 %d 状态 %s（等待领取 %v，执行器 %v），应由执行器执行成功: %s", runID, run.Status, run.AwaitingWorker, run.WorkerID, run.ErrorMsg)
+			continue
+		}
+		claimed[*run.WorkerID]++
+		if steps := runSteps(t, runID); len(steps) != 1 {
+			t.Errorf("运行 %d 有 %d 个步骤记录，应只执行一次", runID, len(steps))
+		}
+	}
+	if claimed[w1.workerID(t)] == 0 || claimed[w2.workerID(t)] == 0 || claimed[w1.workerID(t)]+claimed[w2.workerID(t)] != total {
+		t.Errorf("各执行器领取的运行数为 %v，两个执行器都应领取且合计 %d 个", claimed, total)
+	}
+}
+
+// TestConcurrentClaim 两个执行器同时领取同一批运行，条件更新保证每个运行只被一个执行器领取
+func TestConcurrentClaim(t *testing.T) {
+	api, project := setupEngineTest(t)
+	api.config.Worker.LocalExecution = LocalExecutionNever
+	pipeline := createPipeline(t, project, "build", scriptPipeline("true"))
+
+	const total = 20
+	runIDs := make([]uint, total)
+	for i := range runIDs {
+		runIDs[i] = startRun(t, api, pipeline).ID
+	}
+
+	// 执行器不启动领取循环，由测试同时调用 claimRuns
+	workers := make([]*Engine, 2)
+	ids := make([]uint, 2)
+	for i := range workers {
+		cfg := *api.config
+		cfg.Deploy.WorkspaceDir = t.TempDir()
+		cfg.Worker = config.WorkerConfig{Name: fmt.Sprintf("worker-%d", i+1), Capacity: total, PollInterval: 1}
+		workers[i] = NewEngine(&cfg, scripts.NewManager(&cfg), git.NewManager(&cfg))
+		t.Cleanup(workers[i].Shutdown)
+		os.MkdirAll(workers[i].workspaceDir(project.ID), 0o755)
+		worker, err := workers[i].registerWorker("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		workers[i].worker, ids[i] = worker, worker.ID
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range workers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			workers[i].claimRuns(&ids[i], workers[i].worker.Name)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for _, runID := range runIDs {
+		run := waitFinished(t, runID, append(workers, api)...)
+		if run.Status != models.RunStatusSuccess || run.WorkerID == nil {
+			t.Errorf("运行 %d 状态 %s（执行器 %v），应被领取并执行成功", runID, run.Status, run.WorkerID)
+		}
+		if steps := runSteps(t, runID); len(steps) != 1 {
+			t.Errorf("运行 %d 有 %d 个步骤记录，同时领取时只应执行一次", runID, len(steps))
+		}
+	}
+}
+
+// TestWorkerDrainAndRemoteCancel 排空的执行器不再领取新的运行，已领取的运行执行完毕后退出；
+// API实例上取消执行器中的运行，执行器同步后中止；没有存活的执行器时API实例领取排队的运行在本地执行
+func TestWorkerDrainAndRemoteCancel(t *testing.T) {
+	api, project := setupEngineTest(t)
+	gates := t.TempDir()
+	w1 := startWorker(t, api, project, "worker-1", 2)
+	w2 := startWorker(t, api, project, "worker-2", 2)
+	slow := createPipeline(t, project, "slow", scriptPipeline(waitGate(gates, "release")))
+	hanging := createPipeline(t, project, "hanging", scriptPipeline("sleep 30"))
+
+	first := startRun(t, api, slow)
+	waitStep(t, first.ID, 1)
+	drained, other := w1, w2
+	if !w1.isRunning(first.ID) {
+		drained, other = w2, w1
+	}
+
+	close(drained.stop)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var worker models.Worker
+		database.DB.Where("name = ?", drained.name).First(&worker)
+		if worker.Status == models.WorkerStatusDraining {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("执行器 %s 状态为 %s，应为排空中", drained.name, worker.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// 排空中的执行器有空闲名额也不再领取
+	second := startRun(t, api, hanging)
+	waitStep(t, second.ID, 1)
+	if drained.isRunning(second.ID) || !other.isRunning(second.ID) {
+		t.Error("排空中的执行器领取了新的运行")
+	}
+
+	cancelledAt := time.Now()
+	if err := api.CancelPipelineRun(second.ID, UserCancellation(testUserID, "")); err != nil {
+		t.Fatal(err)
+	}
+	run := waitFinished(t, second.ID, api, other.Engine)
+	if run.Status != models.RunStatusCancelled || run.CancellationReason != models.CancelReasonUser || time.Since(cancelledAt) > cancelBound {
+		t.Errorf("API实例取消后运行状态 %s（原因 %q），应在 %v 内由执行器中止", run.Status, run.CancellationReason, cancelBound)
+	}
+
+	select {
+	case <-drained.done:
+		t.Fatal("执行器在已领取的运行结束前退出")
+	case <-time.After(200 * time.Millisecond):
+	}
+	openGate(t, gates, "release")
+	if run := waitFinished(t, first.ID, drained.Engine); run.Status != models.RunStatusSuccess {
+		t.Errorf("运行状态 %s，排空时已领取的运行应执行完毕", run.Status)
+	}
+	select {
+	case <-drained.done:
+		if drained.err != nil {
+			t.Fatal(drained.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("排空的执行器没有在运行结束后退出")
+	}
+	var worker models.Worker
+	database.DB.Where("name = ?", drained.name).First(&worker)
+	if worker.Status != models.WorkerStatusStopped {
+		t.Errorf("排空后执行器状态为 %s，应为 stopped", worker.Status)
+	}
+
+	// 另一个执行器也退出后，排队的运行由API实例领取
+	other.drain()
+	orphan := &models.PipelineRun{PipelineID: slow.ID, Status: models.RunStatusPending, AwaitingWorker: true, TriggerType: models.TriggerManual, Branch: "main"}
+	database.DB.Create(orphan)
+	api.ClaimOrphanedRuns()
+	if run := waitFinished(t, orphan.ID, api); run.Status != models.RunStatusSuccess || run.WorkerID != nil {
+		t.Errorf("没有存活的执行器时运行状态 %s（执行器 %v），应由API实例执行", run.Status, run.WorkerID)
+	}
+}
@@ -0,0 +1,256 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// notifyStatuses 通知规则可以匹配的运行结束状态
+var notifyStatuses = map[string]bool{
+	models.RunStatusSuccess:   true,
+	models.RunStatusFailed:    true,
+	models.RunStatusCancelled: true,
+}
+
+// Load 读取项目的默认策略，未设置时返回空策略
+func Load(db *gorm.DB, projectID uint) (*models.ProjectPolicy, error) {
+	var policy models.ProjectPolicy
+	err := db.Where("project_id = ?", projectID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.ProjectPolicy{ProjectID: projectID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取项目策略失败: %w", err)
+	}
+	return &policy, nil
+}
+
+// NotifyRules 解析 JSON 保存的通知规则，内容无效时视为没有规则
+func NotifyRules(data string) []models.PolicyNotifyRule {
+	var rules []models.PolicyNotifyRule
+	if data != "" {
+		json.Unmarshal([]byte(data), &rules)
+	}
+	return rules
+}
+
+// Approvals 解析 JSON 保存的审批要求，内容无效时视为没有要求
+func Approvals(data string) []models.PolicyApproval {
+	var approvals []models.PolicyApproval
+	if data != "" {
+		json.Unmarshal([]byte(data), &approvals)
+	}
+	return approvals
+}
+
+// Encode 序列化通知规则或审批要求用于保存
+func Encode(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// View 项目策略的接口表示
+func View(policy *models.ProjectPolicy) models.ProjectPolicyRequest {
+	view := models.ProjectPolicyRequest{
+		NotifyRules:       NotifyRules(policy.NotifyRules),
+		Approvals:         Approvals(policy.Approvals),
+		RunTimeoutMinutes: policy.RunTimeoutMinutes,
+		NotifyEnforced:    policy.NotifyEnforced,
+		ApprovalsEnforced: policy.ApprovalsEnforced,
+		TimeoutEnforced:   policy.TimeoutEnforced,
+	}
+	if view.NotifyRules == nil {
+		view.NotifyRules = []models.PolicyNotifyRule{}
+	}
+	if view.Approvals == nil {
+		view.Approvals = []models.PolicyApproval{}
+	}
+	return view
+}
+
+// Validate 校验通知规则、审批要求与运行超时的取值
+func Validate(rules []models.PolicyNotifyRule, approvals []models.PolicyApproval, timeoutMinutes int) []string {
+	var problems []string
+	for i, rule := range rules {
+		if len(rule.UserIDs) == 0 {
+			problems = append(problems, fmt.Sprintf("通知规则 %d 没有指定通知的用户", i+1))
+		}
+		for _, status := range rule.Statuses {
+			if !notifyStatuses[status] {
+				problems = append(problems, fmt.Sprintf("通知规则 %d 的运行状态无效: %s", i+1, status))
+			}
+		}
+	}
+	for i, approval := range approvals {
+		if strings.TrimSpace(approval.Environment) == "" {
+			problems = append(problems, fmt.Sprintf("审批要求 %d 没有指定环境", i+1))
+		} else if _, err := path.Match(approval.Environment, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("审批要求 %d 的环境模式无效: %s", i+1, approval.Environment))
+		}
+		if approval.TimeoutSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("审批要求 %d 的超时时间无效", i+1))
+		}
+	}
+	if timeoutMinutes < 0 {
+		problems = append(problems, "运行超时无效")
+	}
+	return problems
+}
+
+// Weakens 流水线的覆盖削弱了哪些强制的项目策略：强制的通知规则与审批要求必须保留，强制的运行超时不能放宽
+func Weakens(project *models.ProjectPolicy, req *models.PipelinePolicyRequest) []string {
+	var problems []string
+	if project.NotifyEnforced && req.NotifyRules != nil {
+		keys := make(map[string]bool)
+		for _, rule := range *req.NotifyRules {
+			keys[ruleKey(rule)] = true
+		}
+		for _, rule := range NotifyRules(project.NotifyRules) {
+			if !keys[ruleKey(rule)] {
+				problems = append(problems, fmt.Sprintf("项目强制的通知规则不能移除: %s", ruleKey(rule)))
+			}
+		}
+	}
+	if project.ApprovalsEnforced && req.Approvals != nil {
+		patterns := make(map[string]bool)
+		for _, approval := range *req.Approvals {
+			patterns[approval.Environment] = true
+		}
+		for _, approval := range Approvals(project.Approvals) {
+			if !patterns[approval.Environment] {
+				problems = append(problems, fmt.Sprintf("项目强制的审批要求不能移除: %s", approval.Environment))
+			}
+		}
+	}
+	if project.TimeoutEnforced && project.RunTimeoutMinutes > 0 && req.RunTimeoutMinutes > project.RunTimeoutMinutes {
+		problems = append(problems, fmt.Sprintf("运行超时不能超过项目强制的 %d 分钟", project.RunTimeoutMinutes))
+	}
+	return problems
+}
+
+// For 流水线生效的策略：流水线未覆盖的部分继承项目策略；项目策略强制的部分始终生效，
+// 强制之前保存的覆盖因此也不会削弱项目策略
+func For(project *models.ProjectPolicy, pipeline *models.Pipeline) *models.EffectivePolicy {
+	effective := &models.EffectivePolicy{
+		NotifyRules:       []models.EffectiveNotifyRule{},
+		Approvals:         []models.EffectiveApproval{},
+		NotifyEnforced:    project.NotifyEnforced,
+		ApprovalsEnforced: project.ApprovalsEnforced,
+		TimeoutEnforced:   project.TimeoutEnforced,
+	}
+
+	projectRules := NotifyRules(project.NotifyRules)
+	if pipeline.PolicyNotifyRules == "" {
+		for _, rule := range projectRules {
+			effective.NotifyRules = append(effective.NotifyRules, models.EffectiveNotifyRule{PolicyNotifyRule: rule, Inherited: true})
+		}
+	} else {
+		keys := make(map[string]bool)
+		for _, rule := range NotifyRules(pipeline.PolicyNotifyRules) {
+			keys[ruleKey(rule)] = true
+			effective.NotifyRules = append(effective.NotifyRules, models.EffectiveNotifyRule{PolicyNotifyRule: rule})
+		}
+		if project.NotifyEnforced {
+			for _, rule := range projectRules {
+				if !keys[ruleKey(rule)] {
+					effective.NotifyRules = append(effective.NotifyRules, models.EffectiveNotifyRule{PolicyNotifyRule: rule, Inherited: true})
+				}
+			}
+		}
+	}
+
+	projectApprovals := Approvals(project.Approvals)
+	if pipeline.PolicyApprovals == "" {
+		for _, approval := range projectApprovals {
+			effective.Approvals = append(effective.Approvals, models.EffectiveApproval{PolicyApproval: approval, Inherited: true})
+		}
+	} else {
+		patterns := make(map[string]bool)
+		for _, approval := range Approvals(pipeline.PolicyApprovals) {
+			patterns[approval.Environment] = true
+			effective.Approvals = append(effective.Approvals, models.EffectiveApproval{PolicyApproval: approval})
+		}
+		if project.ApprovalsEnforced {
+			for _, approval := range projectApprovals {
+				if !patterns[approval.Environment] {
+					effective.Approvals = append(effective.Approvals, models.EffectiveApproval{PolicyApproval: approval, Inherited: true})
+				}
+			}
+		}
+	}
+
+	effective.RunTimeoutMinutes, effective.TimeoutInherited = project.RunTimeoutMinutes, true
+	if pipeline.RunTimeoutMinutes > 0 {
+		enforcedLimit := project.TimeoutEnforced && project.RunTimeoutMinutes > 0 && pipeline.RunTimeoutMinutes > project.RunTimeoutMinutes
+		if !enforcedLimit {
+			effective.RunTimeoutMinutes, effective.TimeoutInherited = pipeline.RunTimeoutMinutes, false
+		}
+	}
+	return effective
+}
+
+// ApprovalFor 部署到该环境前需要的审批，多个要求匹配时使用第一个；不需要审批时返回 nil
+func ApprovalFor(effective *models.EffectivePolicy, environment string) *models.EffectiveApproval {
+	if effective == nil || environment == "" {
+		return nil
+	}
+	for i := range effective.Approvals {
+		if matched, _ := path.Match(effective.Approvals[i].Environment, environment); matched {
+			return &effective.Approvals[i]
+		}
+	}
+	return nil
+}
+
+// Recipients 运行以该状态结束时按通知规则需要通知的用户
+func Recipients(effective *models.EffectivePolicy, status string) []uint {
+	if effective == nil {
+		return nil
+	}
+	seen := make(map[uint]bool)
+	var users []uint
+	for _, rule := range effective.NotifyRules {
+		if len(rule.Statuses) > 0 && !contains(rule.Statuses, status) {
+			continue
+		}
+		for _, userID := range rule.UserIDs {
+			if !seen[userID] {
+				seen[userID] = true
+				users = append(users, userID)
+			}
+		}
+	}
+	return users
+}
+
+// ruleKey 通知规则的规范表示，状态与用户的顺序不影响比较
+func ruleKey(rule models.PolicyNotifyRule) string {
+	statuses := append([]string(nil), rule.Statuses...)
+	sort.Strings(statuses)
+	users := append([]uint(nil), rule.UserIDs...)
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+
+	status := "all"
+	if len(statuses) > 0 {
+		status = strings.Join(statuses, ",")
+	}
+	return fmt.Sprintf("%s -> %v", status, users)
+}
+
+// contains 切片中是否包含该值
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package progress
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// 预估的可信度
+const (
+	ConfidenceHigh   = "high"   // 大部分步骤有历史耗时
+	ConfidenceMedium = "medium" // 约一半步骤有历史耗时，或当前步骤已超过历史耗时
+	ConfidenceLow    = "low"    // 少数步骤有历史耗时，或只能按已执行步骤的平均耗时推算
+	ConfidenceNone   = "none"   // 没有历史耗时且还没有执行完的步骤，无法预估剩余时间
+)
+
+// 预估的依据
+const (
+	BasisHistory   = "history"    // 按步骤的历史耗时中位数加权
+	BasisStepCount = "step_count" // 按已完成的步骤数
+)
+
+// 本次运行与历史耗时的快慢比例的范围，避免个别步骤的偶然快慢放大到整个预估
+const (
+	minPace = 0.5
+	maxPace = 3
+)
+
+// Step 运行计划中的一个步骤
+type Step struct {
+	Name     string
+	Stage    string
+	MedianMs int64 // 历史耗时中位数，0 表示没有历史
+}
+
+// Snapshot 运行的进度与预计完成时间
+type Snapshot struct {
+	StepsCompleted  int          `json:"steps_completed"`
+	StepsTotal      int          `json:"steps_total"`
+	Percent         float64      `json:"percent"`
+	CurrentStep     *CurrentStep `json:"current_step,omitempty"`
+	ElapsedMs       int64        `json:"elapsed_ms"`
+	RemainingMs     *int64       `json:"remaining_ms"` // 无法预估时为空
+	ETA             *time.Time   `json:"eta"`
+	Confidence      string       `json:"confidence"`
+	Basis           string       `json:"basis"`
+	HistoryCoverage float64      `json:"history_coverage"` // 有历史耗时的步骤占比
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// CurrentStep 正在执行的步骤
+type CurrentStep struct {
+	Name      string    `json:"name"`
+	Stage     string    `json:"stage"`
+	Index     int       `json:"index"` // 从 1 开始
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+	MedianMs  int64     `json:"median_ms,omitempty"` // 历史耗时中位数，没有历史时为 0
+	Overrun   bool      `json:"overrun"`             // 已超过历史耗时
+}
+
+// Tracker 运行中的进度。步骤开始与结束时在内存中更新，读取时按当前时间计算，不写数据库
+type Tracker struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	steps     []Step
+	done      []bool
+	completed int
+
+	current      int // 正在执行的步骤下标，-1 表示没有
+	currentStart time.Time
+
+	// 本次实际执行完的步骤耗时，用于推算快慢与没有历史时的平均耗时
+	executed     int
+	executedMs   int64
+	pacedActual  int64 // 有历史耗时的已执行步骤的实际耗时
+	pacedHistory int64 // 上述步骤的历史耗时
+}
+
+// NewTracker 按运行计划创建进度
+func NewTracker(steps []Step, startedAt time.Time) *Tracker {
+	return &Tracker{
+		startedAt: startedAt,
+		steps:     steps,
+		done:      make([]bool, len(steps)),
+		current:   -1,
+	}
+}
+
+// Start 第 index 个步骤（从 0 开始）开始执行
+func (t *Tracker) Start(index int, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if index < 0 || index >= len(t.steps) {
+		return
+	}
+	t.current = index
+	t.currentStart = at
+}
+
+// Finish 第 index 个步骤执行结束，耗时计入本次运行的快慢
+func (t *Tracker) Finish(index int, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if index < 0 || index >= len(t.steps) || t.done[index] {
+		return
+	}
+	if t.current == index {
+		elapsed := at.Sub(t.currentStart).Milliseconds()
+		t.executed++
+		t.executedMs += elapsed
+		if median := t.steps[index].MedianMs; median > 0 {
+			t.pacedActual += elapsed
+			t.pacedHistory += median
+		}
+		t.current = -1
+	}
+	t.markDone(index)
+}
+
+// Skip 第 index 个步骤没有实际执行（复用原运行的结果或重启前已完成），只计入完成数
+func (t *Tracker) Skip(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if index < 0 || index >= len(t.steps) || t.done[index] {
+		return
+	}
+	if t.current == index {
+		t.current = -1
+	}
+	t.markDone(index)
+}
+
+func (t *Tracker) markDone(index int) {
+	t.done[index] = true
+	t.completed++
+}
+
+// Snapshot 按当前时间计算进度。有历史耗时时按各步骤的历史耗时中位数加权，没有历史的步骤取有历史步骤的平均值，
+// 剩余时间再按本次运行已执行步骤与历史的快慢比例调整；完全没有历史时退化为按步骤数计算进度，
+// 剩余时间按已执行步骤的平均耗时推算。阶段内的步骤顺序执行，阶段的权重即其步骤权重之和
+func (t *Tracker) Snapshot(now time.Time) Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	total := len(t.steps)
+	snapshot := Snapshot{
+		StepsCompleted: t.completed,
+		StepsTotal:     total,
+		ElapsedMs:      now.Sub(t.startedAt).Milliseconds(),
+		UpdatedAt:      now,
+	}
+
+	var currentElapsed int64
+	if t.current >= 0 {
+		step := t.steps[t.current]
+		currentElapsed = now.Sub(t.currentStart).Milliseconds()
+		snapshot.CurrentStep = &CurrentStep{
+			Name:      step.Name,
+			Stage:     step.Stage,
+			Index:     t.current + 1,
+			StartedAt: t.currentStart,
+			ElapsedMs: currentElapsed,
+			MedianMs:  step.MedianMs,
+			Overrun:   step.MedianMs > 0 && currentElapsed > step.MedianMs,
+		}
+	}
+	if total == 0 {
+		snapshot.Basis = BasisStepCount
+		snapshot.Confidence = ConfidenceNone
+		return snapshot
+	}
+
+	known, knownMs := 0, int64(0)
+	for _, step := range t.steps {
+		if step.MedianMs > 0 {
+			known++
+			knownMs += step.MedianMs
+		}
+	}
+	snapshot.HistoryCoverage = round(float64(known) / float64(total))
+
+	var remaining float64
+	if known == 0 {
+		snapshot.Basis = BasisStepCount
+		snapshot.Percent = percent(float64(t.completed), float64(total), t.completed == total)
+		if t.executed == 0 {
+			snapshot.Confidence = ConfidenceNone
+			return snapshot
+		}
+		average := float64(t.executedMs) / float64(t.executed)
+		pending := total - t.completed
+		remaining = average * float64(pending)
+		if t.current >= 0 {
+			// 当前步骤已执行的时间从剩余中扣除，但不少于其余步骤的平均耗时之和
+			remaining = math.Max(remaining-float64(currentElapsed), average*float64(pending-1))
+		}
+		snapshot.Confidence = ConfidenceLow
+	} else {
+		snapshot.Basis = BasisHistory
+		fallback := float64(knownMs) / float64(known)
+		weight := func(step Step) float64 {
+			if step.MedianMs > 0 {
+				return float64(step.MedianMs)
+			}
+			return fallback
+		}
+
+		var totalWeight, doneWeight, pendingWeight float64
+		for i, step := range t.steps {
+			w := weight(step)
+			totalWeight += w
+			switch {
+			case t.done[i]:
+				doneWeight += w
+			case i == t.current:
+				// 当前步骤最多计入其权重，超过历史耗时后停在该步骤结束处
+				doneWeight += math.Min(float64(currentElapsed), w)
+				pendingWeight += math.Max(w-float64(currentElapsed), 0)
+			default:
+				pendingWeight += w
+			}
+		}
+		snapshot.Percent = percent(doneWeight, totalWeight, t.completed == total)
+		remaining = pendingWeight * t.pace()
+
+		switch cover := float64(known) / float64(total); {
+		case cover >= 0.8:
+			snapshot.Confidence = ConfidenceHigh
+		case cover >= 0.5:
+			snapshot.Confidence = ConfidenceMedium
+		default:
+			snapshot.Confidence = ConfidenceLow
+		}
+		if snapshot.CurrentStep != nil && snapshot.CurrentStep.Overrun && snapshot.Confidence == ConfidenceHigh {
+			snapshot.Confidence = ConfidenceMedium
+		}
+	}
+
+	remainingMs := int64(math.Round(remaining))
+	eta := now.Add(time.Duration(remainingMs) * time.Millisecond)
+	snapshot.RemainingMs = &remainingMs
+	snapshot.ETA = &eta
+	return snapshot
+}
+
+// pace 本次运行与历史耗时的快慢比例，还没有执行完有历史耗时的步骤时为 1
+func (t *Tracker) pace() float64 {
+	if t.pacedHistory <= 0 {
+		return 1
+	}
+	return math.Min(math.Max(float64(t.pacedActual)/float64(t.pacedHistory), minPace), maxPace)
+}
+
+// percent 完成百分比，保留一位小数；步骤全部完成前不超过 99
+func percent(done, total float64, finished bool) float64 {
+	if finished {
+		return 100
+	}
+	if total <= 0 {
+		return 0
+	}
+	return math.Min(round(done/total*100), 99)
+}
+
+func round(value float64) float64 {
+	return math.Round(value*10) / 10
+}
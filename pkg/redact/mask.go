@@ -0,0 +1,54 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretNamePattern 看起来是密钥的名称片段
+const secretNamePattern = `(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credential)`
+
+// secretName 名称中包含 password、secret、token 等片段
+var secretName = regexp.MustCompile(`(?i)` + secretNamePattern)
+
+// secretAssignment 看起来是密钥的赋值：键名包含 password、secret、token 等，值为其后的非空白内容
+var secretAssignment = regexp.MustCompile(`(?i)(` + secretNamePattern + `[A-Za-z0-9_-]*["']?\s*[:=]\s*["']?)([^\s"',}]+)`)
+
+// LooksSecret 变量名看起来是密钥，如 DB_PASSWORD、GITHUB_TOKEN
+func LooksSecret(name string) bool {
+	return secretName.MatchString(name)
+}
+
+// Fingerprint 计算密钥值的指纹（sha256前12位），用于在不泄露明文的情况下比对
+func Fingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// MaskConsistent 将已知密钥值与看起来是密钥的赋值替换为指纹。同一个值总是得到同一个指纹，
+// 用于对比两段文本：未变化的密钥不产生差异，变化的密钥显示为指纹变化而不泄露明文
+func MaskConsistent(text string, secrets []string) string {
+	values := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if len(secret) >= MinSecretLength {
+			values = append(values, secret)
+		}
+	}
+	// 长的先替换，避免短值命中长值的一部分
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, Fingerprint(value))
+	}
+
+	return secretAssignment.ReplaceAllStringFunc(text, func(match string) string {
+		parts := secretAssignment.FindStringSubmatch(match)
+		if strings.HasPrefix(parts[2], "sha256:") || strings.HasPrefix(parts[2], "${") {
+			// 已替换的指纹与变量引用保持原样
+			return match
+		}
+		return parts[1] + Fingerprint(parts[2])
+	})
+}
@@ -0,0 +1,187 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Placeholder 密钥值与未设置替换文本的规则使用的占位符
+const Placeholder = "******"
+
+// MinSecretLength 参与替换的密钥值最小长度，过短的值替换会误伤正常内容
+const MinSecretLength = 4
+
+const (
+	// MaxPatternLength 规则正则表达式的最大长度
+	MaxPatternLength = 512
+	// maxProgramSize 单条规则编译后的最大指令数，限制每个字符的匹配开销
+	maxProgramSize = 2000
+	// probeTimeout 校验规则时在样本文本上匹配的时间上限
+	probeTimeout = 200 * time.Millisecond
+)
+
+var (
+	ErrPatternEmpty        = errors.New("正则表达式不能为空")
+	ErrPatternTooLong      = errors.New("正则表达式过长")
+	ErrPatternTooComplex   = errors.New("正则表达式过于复杂")
+	ErrPatternMatchesEmpty = errors.New("正则表达式不能匹配空字符串")
+	ErrPatternTooSlow      = errors.New("正则表达式匹配耗时过长")
+)
+
+// probeText 校验规则时使用的样本文本：长的重复字符与常见日志内容
+var probeText = strings.Repeat("a", 8192) + strings.Repeat("0123456789", 512) +
+	strings.Repeat("[2006-01-02 15:04:05] key=value token: abc-DEF_123 user@example.com https://example.com/path?q=1\n", 256)
+
+// Rule 一条脱敏规则：匹配 Pattern 的内容替换为 Replacement（原样替换，不展开分组引用）
+type Rule struct {
+	Name        string
+	Pattern     string
+	Replacement string
+}
+
+// Validate 校验规则的正则表达式：语法正确、长度与编译规模受限、不匹配空字符串，
+// 并在样本文本上限时试运行，避免单条规则拖慢每一行日志的处理
+func Validate(pattern string) error {
+	if pattern == "" {
+		return ErrPatternEmpty
+	}
+	if len(pattern) > MaxPatternLength {
+		return ErrPatternTooLong
+	}
+
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("正则表达式无效: %w", err)
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return fmt.Errorf("正则表达式无效: %w", err)
+	}
+	if len(prog.Inst) > maxProgramSize {
+		return ErrPatternTooComplex
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("正则表达式无效: %w", err)
+	}
+	if re.MatchString("") {
+		return ErrPatternMatchesEmpty
+	}
+
+	done := make(chan struct{})
+	go func() {
+		re.FindAllStringIndex(probeText, -1)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(probeTimeout):
+		return ErrPatternTooSlow
+	}
+}
+
+// alternative 合并后正则中的一个分支：分支对应的捕获组与替换文本
+type alternative struct {
+	group       int
+	replacement string
+}
+
+// Redactor 将密钥值与脱敏规则合并为一个正则，每段文本只扫描一遍；为 nil 时原样返回
+type Redactor struct {
+	re           *regexp.Regexp
+	alternatives []alternative
+}
+
+// New 创建脱敏器。密钥值按长度从长到短优先于规则匹配，多条规则在同一位置都能匹配时先声明的规则生效；
+// 无法编译的规则记录日志后跳过，规则在保存时已经过 Validate 校验
+func New(secrets []string, rules []Rule) *Redactor {
+	var literals []string
+	seen := make(map[string]bool)
+	for _, secret := range secrets {
+		if len(secret) >= MinSecretLength && !seen[secret] {
+			seen[secret] = true
+			literals = append(literals, secret)
+		}
+	}
+	// 长的先匹配，避免只替换掉一部分
+	sort.Slice(literals, func(i, j int) bool { return len(literals[i]) > len(literals[j]) })
+
+	r := &Redactor{}
+	var parts []string
+	group := 1
+	if len(literals) > 0 {
+		quoted := make([]string, len(literals))
+		for i, literal := range literals {
+			quoted[i] = regexp.QuoteMeta(literal)
+		}
+		parts = append(parts, "("+strings.Join(quoted, "|")+")")
+		r.alternatives = append(r.alternatives, alternative{group: group, replacement: Placeholder})
+		group++
+	}
+
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil || rule.Pattern == "" {
+			log.Printf("跳过无法编译的脱敏规则 %s: %v", rule.Name, err)
+			continue
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = Placeholder
+		}
+		parts = append(parts, "("+rule.Pattern+")")
+		r.alternatives = append(r.alternatives, alternative{group: group, replacement: replacement})
+		group += 1 + re.NumSubexp()
+	}
+
+	if len(parts) == 0 {
+		return r
+	}
+	re, err := regexp.Compile(strings.Join(parts, "|"))
+	if err != nil {
+		log.Printf("合并脱敏规则失败: %v", err)
+		return r
+	}
+	r.re = re
+	return r
+}
+
+// Redact 替换文本中的密钥值与规则匹配的内容
+func (r *Redactor) Redact(text string) string {
+	if r == nil || r.re == nil || text == "" {
+		return text
+	}
+	matches := r.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	b.Grow(len(text))
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m[0]])
+		b.WriteString(r.replacement(m))
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// replacement 匹配所在分支的替换文本
+func (r *Redactor) replacement(match []int) string {
+	for _, alt := range r.alternatives {
+		if match[2*alt.group] >= 0 {
+			return alt.replacement
+		}
+	}
+	return Placeholder
+}
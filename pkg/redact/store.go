@@ -0,0 +1,103 @@
+package redact
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// cacheTTL 项目脱敏器的缓存时间；规则变更时立即失效，密钥值变更最迟在此时间后生效
+const cacheTTL = 30 * time.Second
+
+// cachedRedactor 缓存的项目脱敏器
+type cachedRedactor struct {
+	redactor *Redactor
+	loadedAt time.Time
+}
+
+var cache = struct {
+	sync.Mutex
+	entries map[uint]cachedRedactor
+}{entries: make(map[uint]cachedRedactor)}
+
+// ForProject 获取项目的脱敏器：项目的密钥环境变量、全局规则与项目规则；
+// projectID 为 0 时只包含全局规则。结果按项目缓存，日志每行调用的开销只有一次查表
+func ForProject(projectID uint) *Redactor {
+	now := time.Now()
+
+	cache.Lock()
+	entry, ok := cache.entries[projectID]
+	cache.Unlock()
+	if ok && now.Sub(entry.loadedAt) < cacheTTL {
+		return entry.redactor
+	}
+
+	// 加载失败时不缓存，下一行日志重新加载
+	redactor, err := load(projectID)
+	if err != nil {
+		log.Printf("加载项目 %d 的脱敏规则失败: %v", projectID, err)
+		return redactor
+	}
+
+	cache.Lock()
+	cache.entries[projectID] = cachedRedactor{redactor: redactor, loadedAt: now}
+	cache.Unlock()
+	return redactor
+}
+
+// Invalidate 丢弃项目的缓存脱敏器，下一行日志按最新的规则处理；projectID 为 0 时丢弃全部
+func Invalidate(projectID uint) {
+	cache.Lock()
+	defer cache.Unlock()
+	if projectID == 0 {
+		cache.entries = make(map[uint]cachedRedactor)
+		return
+	}
+	delete(cache.entries, projectID)
+}
+
+// load 从数据库加载项目的密钥值与规则，全局规则在前；出错时返回已加载部分的脱敏器
+func load(projectID uint) (*Redactor, error) {
+	if database.DB == nil {
+		return nil, fmt.Errorf("数据库未连接")
+	}
+
+	secrets, err := ProjectSecrets(projectID)
+	if err != nil {
+		return New(nil, nil), err
+	}
+
+	var records []models.RedactionRule
+	query := database.DB.Where("project_id IS NULL")
+	if projectID != 0 {
+		query = database.DB.Where("project_id IS NULL OR project_id = ?", projectID)
+	}
+	if err := query.Order("project_id IS NOT NULL, id").Find(&records).Error; err != nil {
+		return New(secrets, nil), fmt.Errorf("查询脱敏规则失败: %w", err)
+	}
+
+	rules := make([]Rule, 0, len(records))
+	for _, record := range records {
+		rules = append(rules, Rule{Name: record.Name, Pattern: record.Pattern, Replacement: record.Replacement})
+	}
+	return New(secrets, rules), nil
+}
+
+// ProjectSecrets 项目密钥环境变量的值，projectID 为 0 时返回空
+func ProjectSecrets(projectID uint) ([]string, error) {
+	if projectID == 0 {
+		return nil, nil
+	}
+
+	var secrets []string
+	if err := database.DB.Model(&models.Environment{}).
+		Where("project_id = ? AND is_secret = ?", projectID, true).
+		Pluck("value", &secrets).Error; err != nil {
+		return nil, fmt.Errorf("查询密钥环境变量失败: %w", err)
+	}
+	return secrets, nil
+}
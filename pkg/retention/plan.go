@@ -0,0 +1,242 @@
+package retention
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/runlabel"
+
+	"gorm.io/gorm"
+)
+
+// batchSize 每批读取的运行数，清理与模拟都逐批处理，内存占用与运行总数无关
+const batchSize = 500
+
+// 删除运行的原因
+const (
+	ReasonAge       = "age"       // 超过流水线的运行保留天数
+	ReasonLabel     = "label"     // 超过标签保留规则的天数
+	ReasonSkipped   = "skipped"   // 超过 skipped 运行的保留天数
+	ReasonFootprint = "footprint" // 统计删除项目等操作涉及的全部数据，不用于清理任务
+)
+
+// Run 判断保留期使用的运行字段，不读取日志与配置快照等大字段
+type Run struct {
+	ID                 uint
+	PipelineID         uint
+	ProjectID          uint
+	Status             string
+	CreatedAt          time.Time
+	EndTime            *time.Time
+	LogSize            int64
+	WorkspacePath      string
+	WorkspaceExpiresAt *time.Time
+	SourceArchive      string
+	DebugUntil         *time.Time
+}
+
+// Time 运行的时间：已结束的运行为结束时间，其余为创建时间
+func (r *Run) Time() time.Time {
+	if r.EndTime != nil {
+		return *r.EndTime
+	}
+	return r.CreatedAt
+}
+
+// Decision 清理任务对一次运行的处理
+type Decision struct {
+	Run           Run
+	Reason        string             // 删除运行记录的原因，为空时保留运行记录
+	Artifacts     []models.Artifact  // 释放的制品
+	LogArchive    *models.LogArchive // 随运行删除的日志归档
+	Workspace     bool               // 删除保留的工作区
+	SourceArchive bool               // 删除上传的源码包
+
+	workspaceBytes     int64
+	sourceArchiveBytes int64
+}
+
+// Deletes 是否删除运行记录
+func (d *Decision) Deletes() bool {
+	return d.Reason != ""
+}
+
+// measure 统计将删除的工作区与源码包的大小，需在删除前调用
+func (d *Decision) measure() {
+	if d.Workspace {
+		d.workspaceBytes = dirSize(d.Run.WorkspacePath)
+	}
+	if d.SourceArchive {
+		if info, err := os.Stat(d.Run.SourceArchive); err == nil {
+			d.sourceArchiveBytes = info.Size()
+		}
+	}
+}
+
+// batch 一批运行的标签保留规则、可释放的制品与日志归档
+type batch struct {
+	labelDays map[uint]int
+	artifacts map[uint][]models.Artifact
+	archives  map[uint]*models.LogArchive
+}
+
+// Plan 按保留策略逐批判断每个运行需要清理的数据，按运行ID顺序对每个运行调用 visit，不需要清理的运行也会调用。
+// 每日清理任务与模拟共用该函数，模拟的结果即清理任务会执行的操作；visit 中删除已判断过的运行不影响后续批次
+func Plan(policy Policy, now time.Time, visit func(*Decision) error) error {
+	overrides := policy.overrides()
+	return scanRuns(nil, func(runs []Run, b *batch) error {
+		for i := range runs {
+			if err := visit(policy.decide(&runs[i], b, overrides, now)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scanRuns 按运行ID顺序逐批读取 scope 范围内的运行（scope 为 nil 时为全部运行）及其制品与日志归档
+func scanRuns(scope func(*gorm.DB) *gorm.DB, visit func([]Run, *batch) error) error {
+	var lastID uint
+	for {
+		var runs []Run
+		query := database.DB.Table("pipeline_runs")
+		if scope != nil {
+			query = query.Scopes(scope)
+		}
+		if err := query.
+			Select("pipeline_runs.id, pipeline_runs.pipeline_id, pipelines.project_id, pipeline_runs.status, pipeline_runs.created_at, "+
+				"pipeline_runs.end_time, pipeline_runs.log_size, pipeline_runs.workspace_path, pipeline_runs.workspace_expires_at, pipeline_runs.source_archive, "+
+				"pipeline_runs.debug_until").
+			Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+			Where("pipeline_runs.deleted_at IS NULL AND pipeline_runs.id > ?", lastID).
+			Order("pipeline_runs.id").Limit(batchSize).
+			Scan(&runs).Error; err != nil {
+			return fmt.Errorf("查询运行失败: %w", err)
+		}
+		if len(runs) == 0 {
+			return nil
+		}
+		lastID = runs[len(runs)-1].ID
+
+		b, err := loadBatch(runs)
+		if err != nil {
+			return err
+		}
+		if err := visit(runs, b); err != nil {
+			return err
+		}
+		if len(runs) < batchSize {
+			return nil
+		}
+	}
+}
+
+// loadBatch 读取一批运行的标签保留规则、制品与日志归档；仍被永久保留的运行使用的制品不释放，不计入
+func loadBatch(runs []Run) (*batch, error) {
+	ids := make([]uint, len(runs))
+	for i := range runs {
+		ids[i] = runs[i].ID
+	}
+
+	labelDays, err := runlabel.KeepDays(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifacts []models.Artifact
+	if err := database.DB.Where("pipeline_run_id IN ?", ids).
+		Where("artifacts.id NOT IN (?)", artifact.Protected()).
+		Order("id").Find(&artifacts).Error; err != nil {
+		return nil, fmt.Errorf("查询制品失败: %w", err)
+	}
+	var archives []models.LogArchive
+	if err := database.DB.Where("pipeline_run_id IN ?", ids).Find(&archives).Error; err != nil {
+		return nil, fmt.Errorf("查询日志归档失败: %w", err)
+	}
+
+	b := &batch{
+		labelDays: labelDays,
+		artifacts: make(map[uint][]models.Artifact),
+		archives:  make(map[uint]*models.LogArchive, len(archives)),
+	}
+	for _, a := range artifacts {
+		b.artifacts[a.PipelineRunID] = append(b.artifacts[a.PipelineRunID], a)
+	}
+	for i := range archives {
+		b.archives[archives[i].PipelineRunID] = &archives[i]
+	}
+	return b, nil
+}
+
+// decide 判断一次运行的处理：skipped 运行按 skipped 保留天数删除；带有保留规则标签的运行只按标签规则删除；
+// 其余运行按流水线的运行保留天数删除。保留的运行超过制品保留天数时释放制品与源码包（永久保留的运行除外），
+// 保留期已过的工作区总是删除
+func (p *Policy) decide(run *Run, b *batch, overrides map[uint]PipelineOverride, now time.Time) *Decision {
+	d := &Decision{Run: *run}
+	// 调试时限内保留运行的全部内容，时限过后按保留期正常清理
+	if run.DebugUntil != nil && run.DebugUntil.After(now) {
+		return d
+	}
+	labelDays, labeled := b.labelDays[run.ID]
+	runDays, artifactDays := p.keepDays(overrides, run.PipelineID)
+
+	switch {
+	case run.Status == models.RunStatusSkipped:
+		if p.SkippedKeepDays > 0 && run.CreatedAt.Before(now.AddDate(0, 0, -p.SkippedKeepDays)) {
+			d.Reason = ReasonSkipped
+		}
+	case labeled:
+		if labelDays > 0 && endedBefore(run, now.AddDate(0, 0, -labelDays)) {
+			d.Reason = ReasonLabel
+		}
+	default:
+		if runDays > 0 && endedBefore(run, now.AddDate(0, 0, -runDays)) {
+			d.Reason = ReasonAge
+		}
+	}
+
+	if d.Deletes() {
+		d.Artifacts = b.artifacts[run.ID]
+		d.LogArchive = b.archives[run.ID]
+		d.Workspace = run.WorkspacePath != ""
+		d.SourceArchive = run.SourceArchive != ""
+		return d
+	}
+
+	if pinned := labeled && labelDays == 0; !pinned && artifactDays > 0 {
+		cutoff := now.AddDate(0, 0, -artifactDays)
+		if endedBefore(run, cutoff) {
+			d.Artifacts = b.artifacts[run.ID]
+		}
+		d.SourceArchive = run.SourceArchive != "" && run.CreatedAt.Before(cutoff)
+	}
+	d.Workspace = run.WorkspacePath != "" && run.WorkspaceExpiresAt != nil && run.WorkspaceExpiresAt.Before(now)
+	return d
+}
+
+// endedBefore 运行是否在截止时间前结束，未结束的运行不清理
+func endedBefore(run *Run, cutoff time.Time) bool {
+	return run.EndTime != nil && run.EndTime.Before(cutoff)
+}
+
+// dirSize 目录下文件的总字节数，无法读取的文件不计入
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,88 @@
+package retention
+
+import (
+	"errors"
+	"fmt"
+
+	"flowforge/pkg/config"
+)
+
+// ErrInvalidPolicy 保留策略中的天数为负数或流水线未指定
+var ErrInvalidPolicy = errors.New("无效的保留策略")
+
+// Policy 清理任务使用的保留策略。天数不大于 0 时不按时间清理对应的数据；
+// 带有保留规则标签的运行只按标签规则删除，永久保留的运行不释放制品与源码包
+type Policy struct {
+	RunKeepDays      int                `json:"run_keep_days"`      // 运行结束超过该天数后删除运行及其日志、制品与工作区
+	ArtifactKeepDays int                `json:"artifact_keep_days"` // 运行结束超过该天数后释放制品，源码包按创建时间
+	SkippedKeepDays  int                `json:"skipped_keep_days"`  // skipped 运行创建超过该天数后删除
+	Pipelines        []PipelineOverride `json:"pipelines"`
+}
+
+// PipelineOverride 单条流水线的保留天数，为 nil 的项使用全局天数
+type PipelineOverride struct {
+	PipelineID       uint `json:"pipeline_id"`
+	RunKeepDays      *int `json:"run_keep_days"`
+	ArtifactKeepDays *int `json:"artifact_keep_days"`
+}
+
+// FromConfig 配置中的保留策略，即每日清理任务使用的策略
+func FromConfig(cfg *config.Config) Policy {
+	policy := Policy{
+		RunKeepDays:      cfg.Retention.RunKeepDays,
+		ArtifactKeepDays: cfg.Deploy.CleanupAfterDays,
+		SkippedKeepDays:  cfg.Deploy.SkippedRunKeepDays,
+	}
+	for _, pipeline := range cfg.Retention.Pipelines {
+		policy.Pipelines = append(policy.Pipelines, PipelineOverride{
+			PipelineID:       pipeline.PipelineID,
+			RunKeepDays:      pipeline.RunKeepDays,
+			ArtifactKeepDays: pipeline.ArtifactKeepDays,
+		})
+	}
+	return policy
+}
+
+// Validate 校验保留策略，与配置文件的校验规则一致
+func (p *Policy) Validate() error {
+	if p.RunKeepDays < 0 {
+		return fmt.Errorf("%w: 运行保留天数不能为负数", ErrInvalidPolicy)
+	}
+	seen := make(map[uint]bool, len(p.Pipelines))
+	for _, pipeline := range p.Pipelines {
+		if pipeline.PipelineID == 0 {
+			return fmt.Errorf("%w: 未指定流水线", ErrInvalidPolicy)
+		}
+		if seen[pipeline.PipelineID] {
+			return fmt.Errorf("%w: 流水线 %d 重复", ErrInvalidPolicy, pipeline.PipelineID)
+		}
+		seen[pipeline.PipelineID] = true
+		if (pipeline.RunKeepDays != nil && *pipeline.RunKeepDays < 0) || (pipeline.ArtifactKeepDays != nil && *pipeline.ArtifactKeepDays < 0) {
+			return fmt.Errorf("%w: 流水线 %d 的保留天数不能为负数", ErrInvalidPolicy, pipeline.PipelineID)
+		}
+	}
+	return nil
+}
+
+// keepDays 流水线的运行与制品保留天数
+func (p *Policy) keepDays(overrides map[uint]PipelineOverride, pipelineID uint) (runDays, artifactDays int) {
+	runDays, artifactDays = p.RunKeepDays, p.ArtifactKeepDays
+	if override, ok := overrides[pipelineID]; ok {
+		if override.RunKeepDays != nil {
+			runDays = *override.RunKeepDays
+		}
+		if override.ArtifactKeepDays != nil {
+			artifactDays = *override.ArtifactKeepDays
+		}
+	}
+	return runDays, artifactDays
+}
+
+// overrides 按流水线ID索引的覆盖配置
+func (p *Policy) overrides() map[uint]PipelineOverride {
+	overrides := make(map[uint]PipelineOverride, len(p.Pipelines))
+	for _, pipeline := range p.Pipelines {
+		overrides[pipeline.PipelineID] = pipeline
+	}
+	return overrides
+}
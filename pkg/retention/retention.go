@@ -0,0 +1,160 @@
+package retention
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/database"
+	"flowforge/pkg/logarchive"
+	"flowforge/pkg/logsearch"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Simulate 按候选策略计算清理任务会删除的数据，不做任何修改
+func Simulate(policy Policy, now time.Time) (*Summary, error) {
+	summary := newSummary(policy, now)
+	if err := Plan(policy, now, func(d *Decision) error {
+		d.measure()
+		summary.add(d)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	summary.finish()
+	return summary, nil
+}
+
+// Cleanup 按保留策略清理运行数据，返回实际清理的数据；单个运行清理失败时记录日志并继续。
+// store 为 nil 时不释放制品，logs 为 nil 时不删除日志归档
+func Cleanup(policy Policy, store *artifact.Store, logs *logarchive.Store, now time.Time) (*Summary, error) {
+	summary := newSummary(policy, now)
+	if err := Plan(policy, now, func(d *Decision) error {
+		d.measure()
+		summary.add(apply(d, store, logs))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	summary.finish()
+	return summary, nil
+}
+
+// Footprint 统计 scope 范围内全部运行的数据量：运行与日志、制品、保留的工作区与源码包，与清理任务使用相同的查询与统计口径。
+// scope 可按 pipelines.project_id 等条件筛选；删除项目前据此展示将被删除的数据
+func Footprint(scope func(*gorm.DB) *gorm.DB) (*Totals, error) {
+	summary := newSummary(Policy{}, time.Now())
+	if err := scanRuns(scope, func(runs []Run, b *batch) error {
+		for i := range runs {
+			run := &runs[i]
+			d := &Decision{
+				Run:           *run,
+				Reason:        ReasonFootprint,
+				Artifacts:     b.artifacts[run.ID],
+				LogArchive:    b.archives[run.ID],
+				Workspace:     run.WorkspacePath != "",
+				SourceArchive: run.SourceArchive != "",
+			}
+			d.measure()
+			summary.add(d)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &summary.Totals, nil
+}
+
+// apply 执行一次运行的处理，返回实际完成的部分
+func apply(d *Decision, store *artifact.Store, logs *logarchive.Store) *Decision {
+	done := &Decision{Run: d.Run, workspaceBytes: d.workspaceBytes, sourceArchiveBytes: d.sourceArchiveBytes}
+	if store != nil {
+		for i := range d.Artifacts {
+			if err := store.Release(&d.Artifacts[i]); err != nil {
+				log.Printf("清理运行 %d 的制品 %d 失败: %v", d.Run.ID, d.Artifacts[i].ID, err)
+				continue
+			}
+			done.Artifacts = append(done.Artifacts, d.Artifacts[i])
+		}
+	}
+
+	if !d.Deletes() {
+		if d.SourceArchive {
+			if err := os.Remove(d.Run.SourceArchive); err != nil && !os.IsNotExist(err) {
+				log.Printf("删除运行 %d 的源码包失败: %v", d.Run.ID, err)
+			} else if err := database.DB.Model(&models.PipelineRun{ID: d.Run.ID}).Update("source_archive", "").Error; err == nil {
+				done.SourceArchive = true
+			}
+		}
+		if d.Workspace {
+			if err := os.RemoveAll(d.Run.WorkspacePath); err != nil {
+				log.Printf("删除运行 %d 保留的工作区失败: %v", d.Run.ID, err)
+			} else if err := database.DB.Model(&models.PipelineRun{ID: d.Run.ID}).Updates(map[string]interface{}{
+				"workspace_path":       "",
+				"workspace_expires_at": nil,
+			}).Error; err == nil {
+				done.Workspace = true
+			}
+		}
+		return done
+	}
+
+	// 删除运行：删除日志归档、工作区与源码包，清空日志后删除运行记录；skipped 运行没有其他数据，直接删除记录
+	if logs != nil {
+		if err := logs.Delete(d.Run.ID); err != nil {
+			log.Printf("删除运行 %d 的日志归档失败: %v", d.Run.ID, err)
+			return done
+		}
+		done.LogArchive = d.LogArchive
+	}
+	for _, target := range []struct {
+		path string
+		done *bool
+	}{
+		{d.Run.WorkspacePath, &done.Workspace},
+		{d.Run.SourceArchive, &done.SourceArchive},
+	} {
+		if target.path == "" {
+			continue
+		}
+		if err := os.RemoveAll(target.path); err != nil {
+			log.Printf("删除运行 %d 的 %s 失败: %v", d.Run.ID, target.path, err)
+			continue
+		}
+		*target.done = true
+	}
+
+	if d.Reason == ReasonSkipped {
+		if err := database.DB.Unscoped().Delete(&models.PipelineRun{}, d.Run.ID).Error; err != nil {
+			log.Printf("删除运行 %d 失败: %v", d.Run.ID, err)
+			return done
+		}
+		done.Reason = d.Reason
+		return done
+	}
+
+	if err := database.DB.Model(&models.PipelineRun{ID: d.Run.ID}).Updates(map[string]interface{}{
+		"log_output":           "",
+		"log_size":             0,
+		"resolved_config":      "",
+		"workspace_path":       "",
+		"workspace_expires_at": nil,
+		"source_archive":       "",
+	}).Error; err != nil {
+		log.Printf("清空运行 %d 失败: %v", d.Run.ID, err)
+		return done
+	}
+	database.DB.Model(&models.PipelineStep{}).Where("pipeline_run_id = ?", d.Run.ID).Update("log_output", "")
+	if err := logsearch.Delete(d.Run.ID); err != nil {
+		log.Printf("删除运行 %d 的日志索引失败: %v", d.Run.ID, err)
+	}
+	if err := database.DB.Delete(&models.PipelineRun{}, d.Run.ID).Error; err != nil {
+		log.Printf("删除运行 %d 失败: %v", d.Run.ID, err)
+		return done
+	}
+	done.Reason = d.Reason
+	return done
+}
@@ -0,0 +1,381 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/internal/clocktest"
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/logarchive"
+	"flowforge/pkg/logsearch"
+	"flowforge/pkg/models"
+)
+
+// openTestDB 为每个测试创建独立的内存数据库并完成迁移
+func openTestDB(t *testing.T) {
+	t.Helper()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seedRun 创建运行，结束于 ended；ended 为零值时运行未结束
+func seedRun(t *testing.T, pipelineID uint, status string, created, ended time.Time) *models.PipelineRun {
+	t.Helper()
+	run := &models.PipelineRun{PipelineID: pipelineID, Status: status}
+	run.CreatedAt = created
+	if !ended.IsZero() {
+		run.EndTime = &ended
+	}
+	if err := database.DB.Create(run).Error; err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+// deletedRuns 模拟结果中删除的运行数
+func deletedRuns(t *testing.T, policy Policy, now time.Time) int64 {
+	t.Helper()
+	summary, err := Simulate(policy, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary.Totals.Runs.Count
+}
+
+// TestSimulateAsClockAdvances 推进可控时钟模拟数周后的清理结果，无需构造不同时间的数据
+func TestSimulateAsClockAdvances(t *testing.T) {
+	openTestDB(t)
+	day := 24 * time.Hour
+	fake := clocktest.New(time.Date(2026, 6, 1, 2, 0, 0, 0, time.UTC))
+	now := fake.Now()
+
+	project := &models.Project{Name: "web", RepoURL: "https://git.example/web.git"}
+	database.DB.Create(project)
+	pipeline := &models.Pipeline{Name: "build", ProjectID: project.ID}
+	database.DB.Create(pipeline)
+
+	seedRun(t, pipeline.ID, models.RunStatusSuccess, now.Add(-41*day), now.Add(-40*day))
+	seedRun(t, pipeline.ID, models.RunStatusFailed, now.Add(-11*day), now.Add(-10*day))
+	seedRun(t, pipeline.ID, models.RunStatusSkipped, now.Add(-5*day), now.Add(-5*day))
+	seedRun(t, pipeline.ID, models.RunStatusRunning, now.Add(-50*day), time.Time{})
+
+	policy := Policy{RunKeepDays: 30, SkippedKeepDays: 7}
+	if got := deletedRuns(t, policy, fake.Now()); got != 1 {
+		t.Fatalf("当前应只删除超过 30 天的运行，实际删除 %d 个", got)
+	}
+
+	fake.Advance(3 * day)
+	if got := deletedRuns(t, policy, fake.Now()); got != 2 {
+		t.Fatalf("3 天后 skipped 运行超过 7 天，应删除 2 个，实际删除 %d 个", got)
+	}
+
+	fake.Advance(20 * day)
+	if got := deletedRuns(t, policy, fake.Now()); got != 3 {
+		t.Fatalf("23 天后失败的运行超过 30 天，应删除 3 个，实际删除 %d 个", got)
+	}
+
+	// 未结束的运行不按时间清理
+	fake.Advance(365 * day)
+	if got := deletedRuns(t, policy, fake.Now()); got != 3 {
+		t.Fatalf("未结束的运行不应删除，实际删除 %d 个", got)
+	}
+}
+
+// TestSimulateLabelRetention 带保留规则标签的运行按标签天数清理，永久保留的标签不清理
+func TestSimulateLabelRetention(t *testing.T) {
+	openTestDB(t)
+	day := 24 * time.Hour
+	fake := clocktest.New(time.Date(2026, 6, 1, 2, 0, 0, 0, time.UTC))
+	now := fake.Now()
+
+	project := &models.Project{Name: "api", RepoURL: "https://git.example/api.git"}
+	database.DB.Create(project)
+	pipeline := &models.Pipeline{Name: "deploy", ProjectID: project.ID}
+	database.DB.Create(pipeline)
+	database.DB.Create(&models.RunLabelRetention{ProjectID: project.ID, Label: "release", KeepDays: 0})
+	database.DB.Create(&models.RunLabelRetention{ProjectID: project.ID, Label: "hotfix", KeepDays: 90})
+
+	release := seedRun(t, pipeline.ID, models.RunStatusSuccess, now.Add(-41*day), now.Add(-40*day))
+	hotfix := seedRun(t, pipeline.ID, models.RunStatusSuccess, now.Add(-41*day), now.Add(-40*day))
+	database.DB.Create(&models.RunLabel{PipelineRunID: release.ID, Label: "release"})
+	database.DB.Create(&models.RunLabel{PipelineRunID: hotfix.ID, Label: "hotfix"})
+
+	policy := Policy{RunKeepDays: 30}
+	if got := deletedRuns(t, policy, fake.Now()); got != 0 {
+		t.Fatalf("带保留规则标签的运行不按运行保留天数删除，实际删除 %d 个", got)
+	}
+	fake.Advance(51 * day)
+	if got := deletedRuns(t, policy, fake.Now()); got != 1 {
+		t.Fatalf("hotfix 运行超过 90 天应删除，release 运行永久保留，实际删除 %d 个", got)
+	}
+}
+
+// seededRun 混合数据中的一次运行及其需要检查的文件
+type seededRun struct {
+	run       *models.PipelineRun
+	workspace string
+	archive   string
+}
+
+// seedMixed 两个项目中不同时间、带日志、制品、工作区、源码包与标签的运行，另有 filler 个超过保留期的小运行跨越多个批次
+func seedMixed(t *testing.T, store *artifact.Store, logs *logarchive.Store, now time.Time, filler int) map[string]*seededRun {
+	t.Helper()
+	day := 24 * time.Hour
+	dir := t.TempDir()
+	web := &models.Project{Name: "web", Slug: "web"}
+	api := &models.Project{Name: "api", Slug: "api"}
+	database.DB.Create(web)
+	database.DB.Create(api)
+	build := &models.Pipeline{Name: "build", ProjectID: web.ID}
+	deploy := &models.Pipeline{Name: "deploy", ProjectID: web.ID}
+	apiBuild := &models.Pipeline{Name: "build", ProjectID: api.ID}
+	for _, p := range []*models.Pipeline{build, deploy, apiBuild} {
+		database.DB.Create(p)
+	}
+	database.DB.Create(&models.RunLabelRetention{ProjectID: web.ID, Label: "release", KeepDays: 0})
+
+	runs := map[string]*seededRun{}
+	add := func(name string, pipeline *models.Pipeline, status string, age time.Duration, ended bool, setup func(*models.PipelineRun, *seededRun)) {
+		created := now.Add(-age - time.Hour)
+		var end time.Time
+		if ended {
+			end = now.Add(-age)
+		}
+		run := seedRun(t, pipeline.ID, status, created, end)
+		seeded := &seededRun{run: run}
+		if setup != nil {
+			setup(run, seeded)
+			database.DB.Save(run)
+		}
+		runs[name] = seeded
+	}
+	withLog := func(run *models.PipelineRun, _ *seededRun) {
+		run.LogOutput = strings.Repeat("build output\n", 10)
+		run.LogSize = int64(len(run.LogOutput))
+	}
+	withArtifact := func(run *models.PipelineRun, _ *seededRun) {
+		if _, err := store.Put(context.Background(), run.ID, "app.tar", strings.NewReader(fmt.Sprintf("artifact of run %d", run.ID)), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	withWorkspace := func(expires time.Time) func(*models.PipelineRun, *seededRun) {
+		return func(run *models.PipelineRun, seeded *seededRun) {
+			seeded.workspace = filepath.Join(dir, "retained", fmt.Sprint(run.ID))
+			os.MkdirAll(seeded.workspace, 0o755)
+			os.WriteFile(filepath.Join(seeded.workspace, "main.go"), make([]byte, 1000), 0o644)
+			run.WorkspacePath, run.WorkspaceExpiresAt = seeded.workspace, &expires
+		}
+	}
+	withSource := func(run *models.PipelineRun, seeded *seededRun) {
+		seeded.archive = filepath.Join(dir, "sources", fmt.Sprintf("%d.tar.gz", run.ID))
+		os.MkdirAll(filepath.Dir(seeded.archive), 0o755)
+		os.WriteFile(seeded.archive, make([]byte, 300), 0o644)
+		run.SourceArchive = seeded.archive
+	}
+	all := func(steps ...func(*models.PipelineRun, *seededRun)) func(*models.PipelineRun, *seededRun) {
+		return func(run *models.PipelineRun, seeded *seededRun) {
+			for _, step := range steps {
+				step(run, seeded)
+			}
+		}
+	}
+
+	future := now.Add(day)
+	add("过期的构建", build, models.RunStatusSuccess, 40*day, true, all(withLog, withArtifact, withWorkspace(future), withSource))
+	add("十天前的构建", build, models.RunStatusSuccess, 10*day, true, all(withLog, withArtifact, withSource))
+	add("最近的构建", build, models.RunStatusSuccess, 3*day, true, all(withLog, withArtifact))
+	add("工作区过期的失败构建", build, models.RunStatusFailed, 2*day, true, withWorkspace(now.Add(-time.Hour)))
+	add("保留期内的部署", deploy, models.RunStatusSuccess, 40*day, true, all(withLog, withArtifact))
+	add("过期的部署", deploy, models.RunStatusSuccess, 100*day, true, all(withLog, withArtifact))
+	add("归档日志的旧运行", apiBuild, models.RunStatusSuccess, 200*day, true, withLog)
+	add("过期的 skipped 运行", apiBuild, models.RunStatusSkipped, 20*day, true, nil)
+	add("未结束的旧运行", apiBuild, models.RunStatusRunning, 50*day, false, nil)
+	add("永久保留的发布", build, models.RunStatusSuccess, 400*day, true, all(withLog, withArtifact))
+	add("调试中的旧运行", build, models.RunStatusFailed, 45*day, true, func(run *models.PipelineRun, _ *seededRun) { run.DebugUntil = &future })
+	database.DB.Create(&models.RunLabel{PipelineRunID: runs["永久保留的发布"].run.ID, Label: "release"})
+	if _, err := logs.Compress(runs["归档日志的旧运行"].run.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	fillers := make([]models.PipelineRun, filler)
+	for i := range fillers {
+		ended := now.Add(-35 * day)
+		fillers[i] = models.PipelineRun{PipelineID: apiBuild.ID, Status: models.RunStatusSuccess, EndTime: &ended, LogOutput: "ok\n", LogSize: 3}
+		fillers[i].CreatedAt = ended.Add(-time.Minute)
+	}
+	if err := database.DB.CreateInBatches(fillers, 200).Error; err != nil {
+		t.Fatal(err)
+	}
+	return runs
+}
+
+// counts 实际存在的运行、制品、日志归档、保留的工作区与源码包数量
+func counts(t *testing.T, runs map[string]*seededRun) [5]int64 {
+	t.Helper()
+	var c [5]int64
+	database.DB.Model(&models.PipelineRun{}).Count(&c[0])
+	database.DB.Model(&models.Artifact{}).Count(&c[1])
+	database.DB.Model(&models.LogArchive{}).Count(&c[2])
+	for _, seeded := range runs {
+		if _, err := os.Stat(seeded.workspace); seeded.workspace != "" && err == nil {
+			c[3]++
+		}
+		if _, err := os.Stat(seeded.archive); seeded.archive != "" && err == nil {
+			c[4]++
+		}
+	}
+	return c
+}
+
+// TestSimulateMatchesCleanup 混合时间的数据上，模拟的结果与随后实际清理的结果完全相同（合计、按项目与按时间分组），
+// 模拟不修改任何数据，实际删除的数量与模拟的数量一致；清理后再次模拟没有需要清理的数据
+func TestSimulateMatchesCleanup(t *testing.T) {
+	openTestDB(t)
+	cfg := &config.Config{Storage: config.StorageConfig{Local: config.LocalConfig{Path: t.TempDir()}}}
+	store, err := artifact.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := logarchive.NewStore(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 6, 1, 2, 0, 0, 0, time.UTC)
+	const filler = batchSize + 100
+	runs := seedMixed(t, store, logs, now, filler)
+
+	ninety := 90
+	policy := Policy{RunKeepDays: 30, ArtifactKeepDays: 7, SkippedKeepDays: 14,
+		Pipelines: []PipelineOverride{{PipelineID: runs["过期的部署"].run.PipelineID, RunKeepDays: &ninety}}}
+
+	before := counts(t, runs)
+	simulated, err := Simulate(policy, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := counts(t, runs); after != before {
+		t.Fatalf("模拟后数据从 %v 变为 %v，模拟不应修改数据", before, after)
+	}
+
+	// 删除：过期的构建、过期的部署、归档日志的旧运行、skipped 运行与全部填充运行
+	if got, want := simulated.Totals.Runs.Count, int64(4+filler); got != want {
+		t.Errorf("模拟删除 %d 个运行，应为 %d 个", got, want)
+	}
+	// 释放：被删除的两个运行，以及十天前的构建与保留期内部署超过制品保留天数的制品
+	if got := simulated.Totals.Artifacts.Count; got != 4 {
+		t.Errorf("模拟释放 %d 个制品，应为 4 个（永久保留与最近的运行除外）", got)
+	}
+	if got := simulated.Totals.Workspaces; got != (Usage{Count: 2, Bytes: 2000}) {
+		t.Errorf("模拟删除的工作区为 %+v，应为 2 个共 2000 字节", got)
+	}
+	if got := simulated.Totals.SourceArchives; got != (Usage{Count: 2, Bytes: 600}) {
+		t.Errorf("模拟删除的源码包为 %+v，应为 2 个共 600 字节", got)
+	}
+	if oldest := simulated.OldestSurviving; oldest == nil || oldest.RunID != runs["永久保留的发布"].run.ID {
+		t.Errorf("保留的最早运行为 %+v，应为永久保留的发布", oldest)
+	}
+
+	cleaned, err := Cleanup(policy, store, logs, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cleaned.Totals, simulated.Totals) {
+		t.Errorf("实际清理的合计为 %+v，模拟为 %+v", cleaned.Totals, simulated.Totals)
+	}
+	if !reflect.DeepEqual(cleaned.Projects, simulated.Projects) {
+		t.Errorf("实际清理的项目明细为 %+v，模拟为 %+v", cleaned.Projects, simulated.Projects)
+	}
+	if !reflect.DeepEqual(cleaned.AgeBuckets, simulated.AgeBuckets) {
+		t.Errorf("实际清理的时间分组为 %+v，模拟为 %+v", cleaned.AgeBuckets, simulated.AgeBuckets)
+	}
+
+	after := counts(t, runs)
+	deleted := [5]int64{before[0] - after[0], before[1] - after[1], before[2] - after[2], before[3] - after[3], before[4] - after[4]}
+	want := [5]int64{simulated.Totals.Runs.Count, simulated.Totals.Artifacts.Count, 1, simulated.Totals.Workspaces.Count, simulated.Totals.SourceArchives.Count}
+	if deleted != want {
+		t.Errorf("实际删除的运行、制品、日志归档、工作区与源码包为 %v，模拟为 %v", deleted, want)
+	}
+	for _, name := range []string{"永久保留的发布", "调试中的旧运行", "未结束的旧运行", "保留期内的部署", "十天前的构建"} {
+		if err := database.DB.First(&models.PipelineRun{}, runs[name].run.ID).Error; err != nil {
+			t.Errorf("%s应保留: %v", name, err)
+		}
+	}
+
+	again, err := Simulate(policy, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Totals != (Totals{}) {
+		t.Errorf("清理后再次模拟仍有 %+v 需要清理", again.Totals)
+	}
+}
+
+// TestCleanupDeletesLogIndex 清理删除运行时一并删除其日志全文索引，保留的运行仍可检索
+func TestCleanupDeletesLogIndex(t *testing.T) {
+	openTestDB(t)
+	cfg := &config.Config{Storage: config.StorageConfig{Local: config.LocalConfig{Path: t.TempDir()}}}
+	store, err := artifact.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err := logarchive.NewStore(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := &config.LogSearchConfig{Enabled: true, MaxKBPerRun: 1024, QueueBatches: 10}
+	if err := logsearch.Init(search); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(logsearch.Stop)
+
+	now := time.Date(2026, 6, 1, 2, 0, 0, 0, time.UTC)
+	project := &models.Project{Name: "web", RepoURL: "https://git.example/web.git"}
+	database.DB.Create(project)
+	pipeline := &models.Pipeline{Name: "build", ProjectID: project.ID}
+	database.DB.Create(pipeline)
+	old := seedRun(t, pipeline.ID, models.RunStatusFailed, now.AddDate(0, 0, -41), now.AddDate(0, 0, -40))
+	recent := seedRun(t, pipeline.ID, models.RunStatusFailed, now.AddDate(0, 0, -2), now.AddDate(0, 0, -1))
+	logsearch.Enqueue(old.ID, "npm ERR! code ERESOLVE\n")
+	logsearch.Enqueue(recent.ID, "npm ERR! code ERESOLVE\n")
+	// 停止时为队列中的日志建立索引
+	logsearch.Stop()
+	if err := logsearch.Init(search); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Cleanup(Policy{RunKeepDays: 30}, store, logs, now); err != nil {
+		t.Fatal(err)
+	}
+	var segments []models.RunLogSegment
+	database.DB.Find(&segments)
+	if len(segments) != 1 || segments[0].PipelineRunID != recent.ID {
+		t.Errorf("清理后的索引为 %+v，应只有保留的运行 %d", segments, recent.ID)
+	}
+	results, err := logsearch.Search(logsearch.Query{Text: "ERESOLVE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].RunID != recent.ID {
+		t.Errorf("检索返回 %+v，应只有保留的运行 %d", results, recent.ID)
+	}
+}
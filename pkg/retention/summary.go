@@ -0,0 +1,207 @@
+package retention
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// Usage 数量与字节数
+type Usage struct {
+	Count int64 `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Totals 各类数据的清理量。日志字节数包括数据库中的日志与压缩归档；制品字节数为制品大小之和，
+// 内容相同的制品共用数据，实际释放的空间可能更少
+type Totals struct {
+	Runs           Usage `json:"runs"`
+	Logs           Usage `json:"logs"`
+	Artifacts      Usage `json:"artifacts"`
+	Workspaces     Usage `json:"workspaces"`
+	SourceArchives Usage `json:"source_archives"`
+}
+
+// ProjectTotals 单个项目的清理量
+type ProjectTotals struct {
+	ProjectID uint   `json:"project_id"`
+	Name      string `json:"name"`
+	Totals
+}
+
+// BucketTotals 按运行时间距今的天数分组的清理量
+type BucketTotals struct {
+	Bucket string `json:"bucket"`
+	Totals
+}
+
+// Survivor 清理后保留的最早的运行
+type Survivor struct {
+	RunID      uint      `json:"run_id"`
+	PipelineID uint      `json:"pipeline_id"`
+	ProjectID  uint      `json:"project_id"`
+	Time       time.Time `json:"time"`
+}
+
+// Summary 一次清理（或模拟）的结果
+type Summary struct {
+	Policy          Policy          `json:"policy"`
+	Scanned         int64           `json:"scanned"` // 检查的运行数
+	Totals          Totals          `json:"totals"`
+	Projects        []ProjectTotals `json:"projects"`
+	AgeBuckets      []BucketTotals  `json:"age_buckets"`
+	OldestSurviving *Survivor       `json:"oldest_surviving"`
+
+	now      time.Time
+	projects map[uint]*Totals
+	buckets  []Totals
+}
+
+// ageBuckets 按运行时间距今的天数分组，最后一组没有上限
+var ageBuckets = []struct {
+	name string
+	days int
+}{
+	{"0-30d", 30},
+	{"30-90d", 90},
+	{"90-180d", 180},
+	{"180-365d", 365},
+	{"365d+", 0},
+}
+
+func newSummary(policy Policy, now time.Time) *Summary {
+	return &Summary{
+		Policy:   policy,
+		now:      now,
+		projects: make(map[uint]*Totals),
+		buckets:  make([]Totals, len(ageBuckets)),
+	}
+}
+
+// add 累计一次运行的处理
+func (s *Summary) add(d *Decision) {
+	s.Scanned++
+	if !d.Deletes() {
+		if t := d.Run.Time(); s.OldestSurviving == nil || t.Before(s.OldestSurviving.Time) {
+			s.OldestSurviving = &Survivor{RunID: d.Run.ID, PipelineID: d.Run.PipelineID, ProjectID: d.Run.ProjectID, Time: t}
+		}
+	}
+
+	var t Totals
+	if d.Deletes() {
+		t.Runs.Count = 1
+		if d.Run.LogSize > 0 || d.LogArchive != nil {
+			t.Logs.Count = 1
+			t.Logs.Bytes = d.Run.LogSize
+			if d.LogArchive != nil {
+				t.Logs.Bytes += d.LogArchive.CompressedSize
+			}
+		}
+	}
+	for _, a := range d.Artifacts {
+		t.Artifacts.Count++
+		t.Artifacts.Bytes += a.Size
+	}
+	if d.Workspace {
+		t.Workspaces = Usage{Count: 1, Bytes: d.workspaceBytes}
+	}
+	if d.SourceArchive {
+		t.SourceArchives = Usage{Count: 1, Bytes: d.sourceArchiveBytes}
+	}
+	if t == (Totals{}) {
+		return
+	}
+
+	s.Totals.merge(&t)
+	project, ok := s.projects[d.Run.ProjectID]
+	if !ok {
+		project = &Totals{}
+		s.projects[d.Run.ProjectID] = project
+	}
+	project.merge(&t)
+	s.buckets[bucketOf(s.now.Sub(d.Run.Time()))].merge(&t)
+}
+
+// finish 按项目ID排序并补充项目名称
+func (s *Summary) finish() {
+	ids := make([]uint, 0, len(s.projects))
+	for id := range s.projects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	names := make(map[uint]string, len(ids))
+	if len(ids) > 0 {
+		var projects []models.Project
+		database.DB.Unscoped().Select("id", "name").Where("id IN ?", ids).Find(&projects)
+		for _, project := range projects {
+			names[project.ID] = project.Name
+		}
+	}
+
+	s.Projects = make([]ProjectTotals, 0, len(ids))
+	for _, id := range ids {
+		s.Projects = append(s.Projects, ProjectTotals{ProjectID: id, Name: names[id], Totals: *s.projects[id]})
+	}
+	s.AgeBuckets = make([]BucketTotals, len(ageBuckets))
+	for i, bucket := range ageBuckets {
+		s.AgeBuckets[i] = BucketTotals{Bucket: bucket.name, Totals: s.buckets[i]}
+	}
+}
+
+// WriteCSV 将结果按合计、项目与时间分组导出为 CSV
+func (s *Summary) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"scope", "name", "runs", "logs", "log_bytes", "artifacts", "artifact_bytes",
+		"workspaces", "workspace_bytes", "source_archives", "source_archive_bytes"})
+
+	row := func(scope, name string, t *Totals) {
+		writer.Write([]string{scope, name,
+			strconv.FormatInt(t.Runs.Count, 10),
+			strconv.FormatInt(t.Logs.Count, 10), strconv.FormatInt(t.Logs.Bytes, 10),
+			strconv.FormatInt(t.Artifacts.Count, 10), strconv.FormatInt(t.Artifacts.Bytes, 10),
+			strconv.FormatInt(t.Workspaces.Count, 10), strconv.FormatInt(t.Workspaces.Bytes, 10),
+			strconv.FormatInt(t.SourceArchives.Count, 10), strconv.FormatInt(t.SourceArchives.Bytes, 10),
+		})
+	}
+	row("total", "", &s.Totals)
+	for i := range s.Projects {
+		row("project", s.Projects[i].Name, &s.Projects[i].Totals)
+	}
+	for i := range s.AgeBuckets {
+		row("age", s.AgeBuckets[i].Bucket, &s.AgeBuckets[i].Totals)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// merge 累加清理量
+func (t *Totals) merge(other *Totals) {
+	for _, pair := range [][2]*Usage{
+		{&t.Runs, &other.Runs},
+		{&t.Logs, &other.Logs},
+		{&t.Artifacts, &other.Artifacts},
+		{&t.Workspaces, &other.Workspaces},
+		{&t.SourceArchives, &other.SourceArchives},
+	} {
+		pair[0].Count += pair[1].Count
+		pair[0].Bytes += pair[1].Bytes
+	}
+}
+
+// bucketOf 运行时间距今的天数所在的分组
+func bucketOf(age time.Duration) int {
+	days := int(age.Hours() / 24)
+	for i, bucket := range ageBuckets {
+		if bucket.days == 0 || days < bucket.days {
+			return i
+		}
+	}
+	return len(ageBuckets) - 1
+}
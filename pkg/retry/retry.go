@@ -0,0 +1,326 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// ErrCircuitOpen 目标熔断期间不再发起请求
+var ErrCircuitOpen = errors.New("熔断已打开")
+
+// 熔断器状态
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// 重试过程中通知观察者的事件
+const (
+	EventRetry       = "retry"        // 请求失败，等待后重试
+	EventTripped     = "tripped"      // 连续失败达到阈值，目标熔断
+	EventCircuitOpen = "circuit_open" // 目标熔断中，未发起请求
+	EventProbe       = "probe"        // 冷却结束，放行一次试探请求
+	EventRecovered   = "recovered"    // 试探请求成功，熔断恢复
+)
+
+// Policy 重试策略
+type Policy struct {
+	Attempts  int           // 最大尝试次数，1 表示不重试
+	BaseDelay time.Duration // 首次重试前的基础等待
+	MaxDelay  time.Duration // 等待上限
+}
+
+// Event 重试与熔断的决定，运行中的请求据此写入运行日志
+type Event struct {
+	Kind        string
+	Destination string
+	Attempt     int
+	Delay       time.Duration // 重试前的等待，或熔断剩余的冷却时间
+	Err         error
+}
+
+// Observer 接收重试与熔断事件
+type Observer func(Event)
+
+// BreakerStats 目标的熔断器状态与计数，用于管理员排查
+type BreakerStats struct {
+	Destination         string     `json:"destination"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Trips               int64      `json:"trips"`
+	Rejected            int64      `json:"rejected"`
+	Successes           int64      `json:"successes"`
+	Failures            int64      `json:"failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+// breaker 单个目标的熔断器
+type breaker struct {
+	state    string
+	failures int // 连续失败次数
+	openedAt time.Time
+	probing  bool // 半开状态下已有试探请求在途
+
+	trips, rejected, successes, total int64
+}
+
+// permanentError 不需要重试、也不计入熔断的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// observerKey 上下文中的重试观察者
+type observerKey struct{}
+
+var (
+	mu        sync.Mutex
+	breakers  = make(map[string]*breaker)
+	threshold = 5
+	cooldown  = 30 * time.Second
+	defaults  = Policy{Attempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second}
+)
+
+// Init 按配置设置默认重试策略与熔断阈值
+func Init(cfg *config.RetryConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	threshold = cfg.BreakerThreshold
+	if cfg.BreakerCooldown > 0 {
+		cooldown = time.Duration(cfg.BreakerCooldown) * time.Second
+	}
+	if cfg.Attempts > 0 {
+		defaults.Attempts = cfg.Attempts
+	}
+	if cfg.BaseDelay > 0 {
+		defaults.BaseDelay = time.Duration(cfg.BaseDelay) * time.Millisecond
+	}
+	if cfg.MaxDelay > 0 {
+		defaults.MaxDelay = time.Duration(cfg.MaxDelay) * time.Millisecond
+	}
+}
+
+// Default 配置的默认重试策略
+func Default() Policy {
+	mu.Lock()
+	defer mu.Unlock()
+	return defaults
+}
+
+// Permanent 标记不需要重试的错误，例如平台返回的 4xx；目标能够正常响应，不计入熔断
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retryable HTTP 响应状态是否值得重试：超时、限流与服务端错误
+func Retryable(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// WithObserver 为上下文中发起的请求设置重试观察者
+func WithObserver(ctx context.Context, observer Observer) context.Context {
+	return context.WithValue(ctx, observerKey{}, observer)
+}
+
+// Host 请求地址对应的熔断目标，按主机区分
+func Host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(u.Host)
+}
+
+// Do 按策略执行 fn：失败时指数退避加随机抖动后重试，目标连续失败达到阈值后熔断，
+// 冷却期间直接返回包含 ErrCircuitOpen 的错误，冷却结束后只放行一次试探请求
+func Do(ctx context.Context, destination string, policy Policy, fn func() error) error {
+	observe, _ := ctx.Value(observerKey{}).(Observer)
+	notify := func(event Event) {
+		if observe != nil {
+			event.Destination = destination
+			observe(event)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		probe, remaining, ok := allow(destination)
+		if !ok {
+			notify(Event{Kind: EventCircuitOpen, Attempt: attempt, Delay: remaining})
+			return fmt.Errorf("%s %w，%d 秒后重试", destination, ErrCircuitOpen, int(remaining.Seconds())+1)
+		}
+		if probe {
+			notify(Event{Kind: EventProbe, Attempt: attempt})
+		}
+
+		err := fn()
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) {
+			if record(destination, true) {
+				notify(Event{Kind: EventRecovered, Attempt: attempt})
+			}
+			if permanent != nil {
+				return permanent.err
+			}
+			return nil
+		}
+
+		if record(destination, false) {
+			notify(Event{Kind: EventTripped, Attempt: attempt, Err: err})
+		}
+		if attempt >= policy.Attempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		notify(Event{Kind: EventRetry, Attempt: attempt, Delay: delay, Err: err})
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff 第 attempt 次失败后的等待：基础等待按次数翻倍，不超过上限，取其一半加随机抖动，
+// 避免同时失败的请求同时重试
+func (p Policy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// allow 是否放行对目标的请求；冷却结束后转为半开，只放行一次试探请求
+func allow(destination string) (probe bool, remaining time.Duration, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if threshold < 0 {
+		return false, 0, true
+	}
+	b := breakers[destination]
+	if b == nil {
+		b = &breaker{state: StateClosed}
+		breakers[destination] = b
+	}
+
+	switch b.state {
+	case StateOpen:
+		if wait := time.Until(b.openedAt.Add(cooldown)); wait > 0 {
+			b.rejected++
+			return false, wait, false
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return true, 0, true
+	case StateHalfOpen:
+		if b.probing {
+			b.rejected++
+			return false, 0, false
+		}
+		b.probing = true
+		return true, 0, true
+	}
+	return false, 0, true
+}
+
+// record 记录请求结果，返回熔断器是否因此熔断（失败）或恢复（成功）
+func record(destination string, success bool) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	b := breakers[destination]
+	if b == nil || threshold < 0 {
+		return false
+	}
+	wasOpen := b.state != StateClosed
+	b.probing = false
+	b.total++
+
+	if success {
+		b.successes++
+		b.failures = 0
+		b.state = StateClosed
+		return wasOpen
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= threshold) {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+		b.trips++
+		return true
+	}
+	return false
+}
+
+// Breakers 各目标的熔断器状态，按目标排序
+func Breakers() []BreakerStats {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stats := make([]BreakerStats, 0, len(breakers))
+	for destination, b := range breakers {
+		s := BreakerStats{
+			Destination:         destination,
+			State:               b.state,
+			ConsecutiveFailures: b.failures,
+			Trips:               b.trips,
+			Rejected:            b.rejected,
+			Successes:           b.successes,
+			Failures:            b.total - b.successes,
+		}
+		if b.state != StateClosed {
+			openedAt, retryAt := b.openedAt, b.openedAt.Add(cooldown)
+			s.OpenedAt, s.RetryAt = &openedAt, &retryAt
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Destination < stats[j].Destination })
+	return stats
+}
+
+// Reset 关闭目标的熔断器并清空连续失败次数；destination 为空时重置全部，返回重置的数量
+func Reset(destination string) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	count := 0
+	for key, b := range breakers {
+		if destination != "" && key != destination {
+			continue
+		}
+		b.state = StateClosed
+		b.failures = 0
+		b.probing = false
+		count++
+	}
+	return count
+}
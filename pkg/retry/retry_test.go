@@ -0,0 +1,336 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// errUnavailable 模拟上游服务降级时返回的错误
+var errUnavailable = errors.New("503 service unavailable")
+
+// setupBreakers 清空熔断器并设置阈值与冷却时间，测试结束后恢复默认值
+func setupBreakers(t *testing.T, limit int, wait time.Duration) {
+	t.Helper()
+	mu.Lock()
+	savedThreshold, savedCooldown, savedDefaults := threshold, cooldown, defaults
+	breakers = make(map[string]*breaker)
+	threshold, cooldown = limit, wait
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		breakers = make(map[string]*breaker)
+		threshold, cooldown, defaults = savedThreshold, savedCooldown, savedDefaults
+	})
+}
+
+// recorder 记录观察者收到的事件类型
+type recorder struct {
+	mu    sync.Mutex
+	kinds []string
+}
+
+func (r *recorder) observe(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds = append(r.kinds, event.Kind)
+}
+
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kinds := r.kinds
+	r.kinds = nil
+	return kinds
+}
+
+func stats(t *testing.T, destination string) BreakerStats {
+	t.Helper()
+	for _, s := range Breakers() {
+		if s.Destination == destination {
+			return s
+		}
+	}
+	t.Fatalf("没有目标 %s 的熔断器", destination)
+	return BreakerStats{}
+}
+
+// once 不重试的策略，每次 Do 只调用一次 fn
+var once = Policy{Attempts: 1}
+
+// TestBurstTripsBreaker 连续失败达到阈值后熔断，冷却期间不再调用 fn 并返回 ErrCircuitOpen；
+// 冷却结束后只放行一次试探请求，试探失败重新熔断，试探成功恢复
+func TestBurstTripsBreaker(t *testing.T) {
+	setupBreakers(t, 3, 50*time.Millisecond)
+	events := &recorder{}
+	ctx := WithObserver(context.Background(), events.observe)
+	const host = "s3.example.com"
+
+	calls := 0
+	failing := func() error {
+		calls++
+		return errUnavailable
+	}
+
+	// 一次成功清零连续失败次数，零散的失败不会熔断
+	for _, fn := range []func() error{failing, failing, func() error { return nil }, failing, failing} {
+		Do(ctx, host, once, fn)
+	}
+	if s := stats(t, host); s.State != StateClosed || s.ConsecutiveFailures != 2 || s.Trips != 0 {
+		t.Fatalf("零散失败后熔断器为 %+v，应保持关闭且连续失败 2 次", s)
+	}
+
+	// 第三次连续失败达到阈值
+	if err := Do(ctx, host, once, failing); !errors.Is(err, errUnavailable) {
+		t.Fatalf("达到阈值的请求应返回原错误，实际为 %v", err)
+	}
+	if kinds := events.take(); strings.Join(kinds, ",") != EventTripped {
+		t.Errorf("达到阈值时的事件为 %v，应为 %s", kinds, EventTripped)
+	}
+	s := stats(t, host)
+	if s.State != StateOpen || s.Trips != 1 || s.OpenedAt == nil || s.RetryAt == nil || !s.RetryAt.After(*s.OpenedAt) {
+		t.Fatalf("熔断后熔断器为 %+v，应为打开且记录打开与恢复时间", s)
+	}
+
+	calls = 0
+	for i := 0; i < 5; i++ {
+		err := Do(ctx, host, once, failing)
+		if !errors.Is(err, ErrCircuitOpen) || !strings.Contains(err.Error(), host) {
+			t.Fatalf("冷却期间应返回包含目标的 ErrCircuitOpen，实际为 %v", err)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("冷却期间调用了 %d 次 fn", calls)
+	}
+	if s := stats(t, host); s.Rejected != 5 {
+		t.Errorf("冷却期间拒绝了 %d 次，应为 5 次", s.Rejected)
+	}
+	if kinds := events.take(); len(kinds) != 5 || kinds[0] != EventCircuitOpen {
+		t.Errorf("冷却期间的事件为 %v，应为 5 次 %s", kinds, EventCircuitOpen)
+	}
+
+	// 试探失败立即重新熔断，不需要再次累计到阈值
+	time.Sleep(60 * time.Millisecond)
+	if err := Do(ctx, host, once, failing); !errors.Is(err, errUnavailable) || calls != 1 {
+		t.Fatalf("冷却结束后应放行一次试探请求，返回 %v，调用 %d 次", err, calls)
+	}
+	if kinds := events.take(); strings.Join(kinds, ",") != EventProbe+","+EventTripped {
+		t.Errorf("试探失败的事件为 %v，应为 probe、tripped", kinds)
+	}
+	if s := stats(t, host); s.State != StateOpen || s.Trips != 2 {
+		t.Fatalf("试探失败后熔断器为 %+v，应重新打开", s)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := Do(ctx, host, once, func() error { return nil }); err != nil {
+		t.Fatalf("试探请求成功时返回 %v", err)
+	}
+	if kinds := events.take(); strings.Join(kinds, ",") != EventProbe+","+EventRecovered {
+		t.Errorf("试探成功的事件为 %v，应为 probe、recovered", kinds)
+	}
+	s = stats(t, host)
+	if s.State != StateClosed || s.ConsecutiveFailures != 0 || s.OpenedAt != nil || s.Successes != 2 || s.Failures != 6 {
+		t.Errorf("恢复后熔断器为 %+v，应关闭并保留成功 2 次、失败 6 次的计数", s)
+	}
+}
+
+// TestHalfOpenSingleProbe 半开状态下只放行一个试探请求，并发的请求在试探结束前被拒绝
+func TestHalfOpenSingleProbe(t *testing.T) {
+	setupBreakers(t, 1, 20*time.Millisecond)
+	const host = "api.github.com"
+	Do(context.Background(), host, once, func() error { return errUnavailable })
+	time.Sleep(30 * time.Millisecond)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- Do(context.Background(), host, once, func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	calls := 0
+	for i := 0; i < 3; i++ {
+		if err := Do(context.Background(), host, once, func() error { calls++; return nil }); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("试探请求在途时应返回 ErrCircuitOpen，实际为 %v", err)
+		}
+	}
+	if calls != 0 {
+		t.Errorf("试探请求在途时放行了 %d 个请求", calls)
+	}
+	if s := stats(t, host); s.State != StateHalfOpen || s.RetryAt == nil {
+		t.Errorf("试探请求在途时熔断器为 %+v，应为半开", s)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := Do(context.Background(), host, once, func() error { calls++; return nil }); err != nil || calls != 1 {
+		t.Errorf("恢复后请求返回 %v，调用 %d 次", err, calls)
+	}
+}
+
+// TestRetriesWithinBurst 单次 Do 内的重试计入连续失败次数，爆发的失败在用完重试次数前熔断，剩余的重试不再发起；
+// 不需要重试的错误直接返回且不计入熔断
+func TestRetriesWithinBurst(t *testing.T) {
+	setupBreakers(t, 3, time.Minute)
+	events := &recorder{}
+	ctx := WithObserver(context.Background(), events.observe)
+	policy := Policy{Attempts: 5, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	calls := 0
+	err := Do(ctx, "hooks.slack.com", policy, func() error { calls++; return errUnavailable })
+	if !errors.Is(err, ErrCircuitOpen) || calls != 3 {
+		t.Errorf("熔断后应停止重试并返回 ErrCircuitOpen，实际调用 %d 次，返回 %v", calls, err)
+	}
+	want := []string{EventRetry, EventRetry, EventTripped, EventRetry, EventCircuitOpen}
+	if kinds := events.take(); strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("事件依次为 %v，应为 %v", kinds, want)
+	}
+
+	calls = 0
+	rejected := fmt.Errorf("422 %w", errors.New("validation failed"))
+	err = Do(ctx, "gitlab.example.com", policy, func() error { calls++; return Permanent(rejected) })
+	if err != rejected || calls != 1 {
+		t.Errorf("不需要重试的错误应原样返回且只调用一次，实际调用 %d 次，返回 %v", calls, err)
+	}
+	if s := stats(t, "gitlab.example.com"); s.ConsecutiveFailures != 0 || s.State != StateClosed {
+		t.Errorf("不需要重试的错误计入了熔断: %+v", s)
+	}
+
+	calls = 0
+	err = Do(ctx, "gitlab.example.com", policy, func() error {
+		if calls++; calls < 3 {
+			return errUnavailable
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("重试后成功应返回 nil，实际调用 %d 次，返回 %v", calls, err)
+	}
+	if s := stats(t, "gitlab.example.com"); s.ConsecutiveFailures != 0 {
+		t.Errorf("成功后连续失败次数为 %d", s.ConsecutiveFailures)
+	}
+}
+
+// TestBreakerPerDestination 熔断按目标区分，一个目标熔断不影响其他目标；阈值为 -1 时不熔断
+func TestBreakerPerDestination(t *testing.T) {
+	setupBreakers(t, 2, time.Minute)
+	for i := 0; i < 2; i++ {
+		Do(context.Background(), Host("https://S3.example.com/bucket/a"), once, func() error { return errUnavailable })
+	}
+	if err := Do(context.Background(), Host("https://s3.example.com/bucket/b"), once, func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("同一主机的其他地址应被熔断，实际为 %v", err)
+	}
+	if err := Do(context.Background(), Host("https://storage.example.com/b"), once, func() error { return nil }); err != nil {
+		t.Errorf("其他主机不应受影响，实际为 %v", err)
+	}
+
+	setupBreakers(t, -1, time.Minute)
+	for i := 0; i < 10; i++ {
+		if err := Do(context.Background(), "s3.example.com", once, func() error { return errUnavailable }); !errors.Is(err, errUnavailable) {
+			t.Fatalf("不熔断时第 %d 次请求返回 %v", i+1, err)
+		}
+	}
+	if len(Breakers()) != 0 {
+		t.Errorf("不熔断时不应记录熔断器: %+v", Breakers())
+	}
+}
+
+// TestReset 重置关闭指定目标或全部目标的熔断器，保留累计计数
+func TestReset(t *testing.T) {
+	setupBreakers(t, 1, time.Hour)
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		Do(context.Background(), host, once, func() error { return errUnavailable })
+	}
+
+	if n := Reset("a.example.com"); n != 1 {
+		t.Errorf("重置单个目标返回 %d", n)
+	}
+	if s := stats(t, "a.example.com"); s.State != StateClosed || s.ConsecutiveFailures != 0 || s.Trips != 1 {
+		t.Errorf("重置后熔断器为 %+v，应关闭并保留熔断次数", s)
+	}
+	if err := Do(context.Background(), "a.example.com", once, func() error { return nil }); err != nil {
+		t.Errorf("重置后请求返回 %v", err)
+	}
+	if s := stats(t, "b.example.com"); s.State != StateOpen {
+		t.Errorf("重置其他目标后 b.example.com 为 %s", s.State)
+	}
+
+	if n := Reset("unknown.example.com"); n != 0 {
+		t.Errorf("重置不存在的目标返回 %d", n)
+	}
+	if n := Reset(""); n != 3 {
+		t.Errorf("重置全部返回 %d，应为 3", n)
+	}
+	for _, s := range Breakers() {
+		if s.State != StateClosed {
+			t.Errorf("重置全部后 %s 为 %s", s.Destination, s.State)
+		}
+	}
+}
+
+// TestBackoff 等待按次数翻倍且不超过上限，抖动后落在 [delay/2, delay] 内
+func TestBackoff(t *testing.T) {
+	policy := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{40, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			if got := policy.backoff(tt.attempt); got < tt.delay/2 || got > tt.delay {
+				t.Fatalf("第 %d 次失败后等待 %v，应在 [%v, %v] 内", tt.attempt, got, tt.delay/2, tt.delay)
+			}
+		}
+	}
+	if got := (Policy{}).backoff(3); got != 0 {
+		t.Errorf("没有基础等待时等待 %v", got)
+	}
+}
+
+// TestDoStopsOnCancel 等待重试时上下文取消，立即返回最近一次的错误
+func TestDoStopsOnCancel(t *testing.T) {
+	setupBreakers(t, -1, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, "s3.example.com", Policy{Attempts: 3, BaseDelay: time.Minute}, func() error {
+		calls++
+		cancel()
+		return errUnavailable
+	})
+	if !errors.Is(err, errUnavailable) || calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("取消后应立即返回最近的错误，实际调用 %d 次，返回 %v", calls, err)
+	}
+}
+
+// TestInit 配置覆盖默认重试策略与熔断参数，未设置的字段保留默认值
+func TestInit(t *testing.T) {
+	setupBreakers(t, 5, 30*time.Second)
+	Init(&config.RetryConfig{Attempts: 4, BaseDelay: 200, BreakerThreshold: -1, BreakerCooldown: 10})
+	if got := Default(); got.Attempts != 4 || got.BaseDelay != 200*time.Millisecond || got.MaxDelay != 10*time.Second {
+		t.Errorf("默认策略为 %+v", got)
+	}
+	if threshold != -1 || cooldown != 10*time.Second {
+		t.Errorf("熔断阈值为 %d、冷却 %v", threshold, cooldown)
+	}
+}
@@ -0,0 +1,190 @@
+package runlabel
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxKeepDays 按标签保留运行的天数上限
+const MaxKeepDays = 3650
+
+var (
+	// ErrInvalidLabel 标签格式无效
+	ErrInvalidLabel = errors.New("运行标签无效")
+	// ErrLabelNotAllowed 标签不在项目允许的标签中
+	ErrLabelNotAllowed = errors.New("运行标签不在项目允许的标签中")
+)
+
+// labelPattern 标签：字母或数字开头，可包含 _ . : / -，最长 64 个字符
+var labelPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_.:/-]{0,63}$`)
+
+// Normalize 校验标签列表并去重，保持原有顺序
+func Normalize(labels []string) ([]string, error) {
+	normalized := make([]string, 0, len(labels))
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if !labelPattern.MatchString(label) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLabel, label)
+		}
+		if !seen[label] {
+			seen[label] = true
+			normalized = append(normalized, label)
+		}
+	}
+	return normalized, nil
+}
+
+// Check 校验标签并检查项目允许的标签，项目未配置允许的标签时只校验格式
+func Check(project *models.Project, labels []string) ([]string, error) {
+	normalized, err := Normalize(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := project.AllowedRunLabelList()
+	if len(allowed) == 0 {
+		return normalized, nil
+	}
+	for _, label := range normalized {
+		if !contains(allowed, label) {
+			return nil, fmt.Errorf("%w: %s（允许: %s）", ErrLabelNotAllowed, label, strings.Join(allowed, ", "))
+		}
+	}
+	return normalized, nil
+}
+
+// ParseQuery 解析查询参数中逗号分隔的标签并去重
+func ParseQuery(value string) []string {
+	var labels []string
+	for _, label := range strings.Split(value, ",") {
+		if label = strings.TrimSpace(label); label != "" && !contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// Add 为运行添加标签，已有的标签忽略
+func Add(db *gorm.DB, runID uint, labels []string, createdByID *uint) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	records := make([]models.RunLabel, 0, len(labels))
+	for _, label := range labels {
+		records = append(records, models.RunLabel{PipelineRunID: runID, Label: label, CreatedByID: createdByID})
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error; err != nil {
+		return fmt.Errorf("保存运行标签失败: %w", err)
+	}
+	return nil
+}
+
+// ForRun 运行的标签，按添加顺序
+func ForRun(runID uint) ([]string, error) {
+	var labels []string
+	err := database.DB.Model(&models.RunLabel{}).Where("pipeline_run_id = ?", runID).Order("id").Pluck("label", &labels).Error
+	return labels, err
+}
+
+// Filter 只保留带有全部指定标签的运行，column 为查询中运行ID所在的列；labels 为空时不过滤。
+// labels 应当已去重
+func Filter(query *gorm.DB, column string, labels []string) *gorm.DB {
+	if len(labels) == 0 {
+		return query
+	}
+	matching := database.DB.Model(&models.RunLabel{}).Select("pipeline_run_id").
+		Where("label IN ?", labels).Group("pipeline_run_id").
+		Having("COUNT(DISTINCT label) = ?", len(labels))
+	return query.Where(column+" IN (?)", matching)
+}
+
+// Pinned 带有永久保留标签的运行ID子查询，制品与源码包等按时间清理时跳过这些运行
+func Pinned() *gorm.DB {
+	return retentionJoin().Select("run_labels.pipeline_run_id").Where("run_label_retentions.keep_days = 0")
+}
+
+// KeepDays 运行按标签保留规则的保留天数，0 表示永久保留；没有带保留规则标签的运行不在结果中。
+// 多个标签时按保留最久的标签处理，永久保留优先
+func KeepDays(runIDs []uint) (map[uint]int, error) {
+	keep := make(map[uint]int)
+	if len(runIDs) == 0 {
+		return keep, nil
+	}
+
+	var rows []struct {
+		RunID    uint
+		KeepDays int
+	}
+	if err := retentionJoin().
+		Select("run_labels.pipeline_run_id AS run_id, run_label_retentions.keep_days").
+		Where("run_labels.pipeline_run_id IN ?", runIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询标签保留规则失败: %w", err)
+	}
+
+	for _, row := range rows {
+		current, seen := keep[row.RunID]
+		switch {
+		case !seen:
+			keep[row.RunID] = row.KeepDays
+		case current == 0 || row.KeepDays == 0:
+			keep[row.RunID] = 0
+		case row.KeepDays > current:
+			keep[row.RunID] = row.KeepDays
+		}
+	}
+	return keep, nil
+}
+
+// SetPolicy 更新项目允许的标签与按标签保留规则，在事务中替换全部保留规则
+func SetPolicy(db *gorm.DB, project *models.Project, allowed []string, retention []models.RunLabelRetention) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(project).Update("allowed_run_labels", strings.Join(allowed, ",")).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.RunLabelRetention{}).Error; err != nil {
+			return err
+		}
+		if len(retention) == 0 {
+			return nil
+		}
+		for i := range retention {
+			retention[i].ID = 0
+			retention[i].ProjectID = project.ID
+		}
+		return tx.Create(&retention).Error
+	})
+}
+
+// Retention 项目的按标签保留规则
+func Retention(projectID uint) ([]models.RunLabelRetention, error) {
+	var rules []models.RunLabelRetention
+	err := database.DB.Where("project_id = ?", projectID).Order("label").Find(&rules).Error
+	return rules, err
+}
+
+// retentionJoin 运行标签关联到所属项目的保留规则
+func retentionJoin() *gorm.DB {
+	return database.DB.Table("run_labels").
+		Joins("JOIN pipeline_runs ON pipeline_runs.id = run_labels.pipeline_run_id").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Joins("JOIN run_label_retentions ON run_label_retentions.project_id = pipelines.project_id AND run_label_retentions.label = run_labels.label")
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
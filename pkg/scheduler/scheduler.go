@@ -7,19 +7,44 @@ import (
 	"sync"
 	"time"
 
+	"flowforge/pkg/artifact"
+	"flowforge/pkg/changefeed"
+	"flowforge/pkg/clock"
+	"flowforge/pkg/database"
+	"flowforge/pkg/logarchive"
 	"flowforge/pkg/models"
-	
-	"github.com/robfig/cron/v3"
+	"flowforge/pkg/retention"
 )
 
 // Scheduler 调度器
 type Scheduler struct {
-	cron    *cron.Cron
+	triggers *triggerRunner
 	ctx     context.Context
 	cancel  context.CancelFunc
 	mu      sync.RWMutex
 	running bool
-	jobs    map[string]cron.EntryID
+	jobs    map[string]int
+
+	// 时间来源：任务触发、预热检查与清理任务的截止时间均取自该时钟
+	clock clock.Clock
+
+	// 清理过期运行制品，未设置时跳过
+	artifactStore *artifact.Store
+
+	// 运行数据保留策略：运行、制品、源码包与 skipped 运行的保留天数
+	retention retention.Policy
+
+	// 运行日志归档：按项目存储配额压缩最早的日志，未设置时跳过
+	logArchive *logarchive.Store
+
+	// 定时流水线预热，未设置时跳过；prewarmed 记录已预热的触发时间，避免重复预热
+	prewarmer Prewarmer
+	prewarmed map[uint]time.Time
+}
+
+// Prewarmer 流水线预热执行者，返回 true 表示资源不足已延后，下一轮重试
+type Prewarmer interface {
+	Prewarm(pipelineID uint) bool
 }
 
 // Job 调度任务
@@ -37,17 +62,59 @@ type Job struct {
 func NewScheduler() *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 	
-	// 创建带有秒级精度的cron调度器
-	c := cron.New(cron.WithSeconds())
-	
+	// 按带秒字段的cron表达式触发任务
 	return &Scheduler{
-		cron:   c,
-		ctx:    ctx,
-		cancel: cancel,
-		jobs:   make(map[string]cron.EntryID),
+		triggers: newTriggerRunner(clock.Real),
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]int),
+		clock:    clock.Real,
+
+		prewarmed: make(map[uint]time.Time),
 	}
 }
 
+// SetClock 设置时间来源，默认为系统时间；测试中传入可控时钟，推进时钟即可触发到期的任务
+func (s *Scheduler) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clock = c
+	s.triggers.setClock(c)
+}
+
+// SetArtifactStore 设置制品存储，清理任务据此释放超过保留天数的运行制品
+func (s *Scheduler) SetArtifactStore(store *artifact.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.artifactStore = store
+}
+
+// SetLogArchive 设置日志归档存储，清理任务检查各项目的日志存储配额并压缩超过上限的日志
+func (s *Scheduler) SetLogArchive(store *logarchive.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logArchive = store
+}
+
+// SetRetentionPolicy 设置运行数据保留策略，清理任务按策略删除过期的运行、制品、源码包与工作区
+func (s *Scheduler) SetRetentionPolicy(policy retention.Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retention = policy
+}
+
+// SetPrewarmer 设置流水线预热执行者
+func (s *Scheduler) SetPrewarmer(p Prewarmer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prewarmer = p
+}
+
 // Start 启动调度器
 func (s *Scheduler) Start() error {
 	s.mu.Lock()
@@ -57,7 +124,7 @@ func (s *Scheduler) Start() error {
 		return fmt.Errorf("scheduler is already running")
 	}
 
-	s.cron.Start()
+	s.triggers.start()
 	s.running = true
 	
 	log.Println("Scheduler started")
@@ -74,7 +141,7 @@ func (s *Scheduler) Stop() error {
 	}
 
 	s.cancel()
-	s.cron.Stop()
+	s.triggers.halt()
 	s.running = false
 	
 	log.Println("Scheduler stopped")
@@ -88,11 +155,11 @@ func (s *Scheduler) AddJob(jobID, spec string, cmd func()) error {
 
 	// 如果任务已存在，先删除
 	if entryID, exists := s.jobs[jobID]; exists {
-		s.cron.Remove(entryID)
+		s.triggers.remove(entryID)
 	}
 
 	// 添加新任务
-	entryID, err := s.cron.AddFunc(spec, cmd)
+	entryID, err := s.triggers.add(spec, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to add job %s: %v", jobID, err)
 	}
@@ -112,7 +179,7 @@ func (s *Scheduler) RemoveJob(jobID string) error {
 		return fmt.Errorf("job %s not found", jobID)
 	}
 
-	s.cron.Remove(entryID)
+	s.triggers.remove(entryID)
 	delete(s.jobs, jobID)
 	
 	log.Printf("Job %s removed", jobID)
@@ -127,18 +194,18 @@ func (s *Scheduler) GetJobs() []Job {
 	jobs := make([]Job, 0, len(s.jobs))
 	
 	for jobID, entryID := range s.jobs {
-		entry := s.cron.Entry(entryID)
+		next, prev := s.triggers.entry(entryID)
 		job := Job{
 			ID:      jobID,
 			Name:    jobID,
 			Enabled: true,
 		}
 		
-		if !entry.Next.IsZero() {
-			job.NextRun = &entry.Next
+		if !next.IsZero() {
+			job.NextRun = &next
 		}
-		if !entry.Prev.IsZero() {
-			job.LastRun = &entry.Prev
+		if !prev.IsZero() {
+			job.LastRun = &prev
 		}
 		
 		jobs = append(jobs, job)
@@ -193,6 +260,61 @@ func (s *Scheduler) executePipeline(pipeline *models.Pipeline) {
 	log.Printf("Pipeline execution completed: %s", pipeline.Name)
 }
 
+// AddPrewarmJob 添加预热检查任务，每分钟检查即将触发的定时流水线
+func (s *Scheduler) AddPrewarmJob() error {
+	return s.AddJob("pipeline_prewarm", "0 * * * * *", s.checkPrewarm)
+}
+
+// checkPrewarm 对距离下次触发不超过预热提前时间的流水线执行预热，每个触发时间只预热一次
+func (s *Scheduler) checkPrewarm() {
+	s.mu.RLock()
+	prewarmer, now := s.prewarmer, s.clock.Now()
+	s.mu.RUnlock()
+	if prewarmer == nil || database.DB == nil {
+		return
+	}
+
+	var pipelines []models.Pipeline
+	database.DB.Where(&models.Pipeline{Trigger: models.TriggerSchedule, Status: models.PipelineStatusActive}).
+		Where("prewarm_minutes > 0 AND cron_expr <> ''").Find(&pipelines)
+
+	for _, pipeline := range pipelines {
+		next, err := NextRun(pipeline.CronExpr, now)
+		if err != nil {
+			continue
+		}
+		if next.Sub(now) > time.Duration(pipeline.PrewarmMinutes)*time.Minute {
+			continue
+		}
+
+		s.mu.Lock()
+		done := s.prewarmed[pipeline.ID].Equal(next)
+		if !done {
+			s.prewarmed[pipeline.ID] = next
+		}
+		s.mu.Unlock()
+		if done {
+			continue
+		}
+
+		if prewarmer.Prewarm(pipeline.ID) {
+			log.Printf("Prewarm of pipeline %d deferred, will retry", pipeline.ID)
+			s.mu.Lock()
+			delete(s.prewarmed, pipeline.ID)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// NextRun 计算cron表达式在 from 之后的下次触发时间，表达式包含秒字段
+func NextRun(expr string, from time.Time) (time.Time, error) {
+	schedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression %s: %v", expr, err)
+	}
+	return schedule.Next(from), nil
+}
+
 // AddCleanupJob 添加清理任务
 func (s *Scheduler) AddCleanupJob() error {
 	// 每天凌晨2点执行清理任务
@@ -212,6 +334,60 @@ func (s *Scheduler) performCleanup() {
 	
 	// 清理过期的日志文件
 	log.Println("Cleaning up expired log files...")
+
+	// 按保留策略清理过期的运行、制品、源码包与保留的工作区，与保留策略模拟使用相同的判断
+	s.mu.RLock()
+	store, logs, policy, now := s.artifactStore, s.logArchive, s.retention, s.clock.Now()
+	s.mu.RUnlock()
+	if database.DB != nil {
+		summary, err := retention.Cleanup(policy, store, logs, now)
+		if err != nil {
+			log.Printf("Failed to apply retention policy: %v", err)
+		} else if summary.Totals != (retention.Totals{}) {
+			log.Printf("Retention: deleted %d runs, released %d artifacts, removed %d workspaces and %d source archives",
+				summary.Totals.Runs.Count, summary.Totals.Artifacts.Count, summary.Totals.Workspaces.Count, summary.Totals.SourceArchives.Count)
+		}
+	}
+
+	// 按项目日志存储配额通知并压缩归档最早的运行日志，每次清理最多处理一批
+	if database.DB != nil && logs != nil {
+		compressed, err := logs.Enforce()
+		if err != nil {
+			log.Printf("Failed to enforce log storage quotas: %v", err)
+		} else if compressed > 0 {
+			log.Printf("Compressed logs of %d runs", compressed)
+		}
+	}
+
+	// 清理已过重叠期的Webhook旧密钥
+	if database.DB != nil {
+		result := database.DB.Model(&models.Webhook{}).
+			Where("previous_secret_expires_at IS NOT NULL AND previous_secret_expires_at < ?", now).
+			Updates(map[string]interface{}{
+				"previous_secret":            "",
+				"previous_secret_expires_at": nil,
+			})
+		if result.Error != nil {
+			log.Printf("Failed to purge expired webhook secrets: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			log.Printf("Purged %d expired webhook secrets", result.RowsAffected)
+		}
+
+		// 清理已过去重窗口的Webhook投递指纹
+		result = database.DB.Where("expires_at < ?", now).Delete(&models.WebhookFingerprint{})
+		if result.Error != nil {
+			log.Printf("Failed to purge expired webhook fingerprints: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			log.Printf("Purged %d expired webhook fingerprints", result.RowsAffected)
+		}
+
+		// 清理超过保留时间的变更日志
+		if pruned, err := changefeed.Prune(now); err != nil {
+			log.Printf("Failed to prune change journal: %v", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d change journal entries", pruned)
+		}
+	}
 	
 	log.Println("Cleanup job completed")
 }
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"flowforge/pkg/clock"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser 定时任务与流水线 cron 表达式的解析器，表达式包含秒字段
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// trigger 按 cron 表达式触发的任务，next 为下次触发时间，prev 为上次触发时间
+type trigger struct {
+	schedule cron.Schedule
+	job      func()
+	next     time.Time
+	prev     time.Time
+}
+
+// triggerRunner 按 cron 表达式触发任务。只使用 robfig/cron 解析表达式与计算下次触发时间，
+// 等待与触发由 Clock 驱动：测试中推进可控时钟到下次触发时间即可触发任务，无需等待真实时间
+type triggerRunner struct {
+	mu       sync.Mutex
+	clock    clock.Clock
+	triggers map[int]*trigger
+	nextID   int
+	wake     chan struct{} // 任务变化时唤醒等待中的循环，重新计算最早的触发时间
+	stop     chan struct{}
+}
+
+// newTriggerRunner 创建使用 c 计时的触发器
+func newTriggerRunner(c clock.Clock) *triggerRunner {
+	return &triggerRunner{
+		clock:    c,
+		triggers: make(map[int]*trigger),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// setClock 更换计时的时钟，已添加任务的下次触发时间按新时钟重新计算
+func (r *triggerRunner) setClock(c clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clock = c
+	now := c.Now()
+	for _, t := range r.triggers {
+		t.next = t.schedule.Next(now)
+	}
+	r.signal()
+}
+
+// add 添加任务，返回用于删除的ID
+func (r *triggerRunner) add(spec string, job func()) (int, error) {
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cron expression %s: %v", spec, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	r.triggers[r.nextID] = &trigger{schedule: schedule, job: job, next: schedule.Next(r.clock.Now())}
+	r.signal()
+	return r.nextID, nil
+}
+
+// remove 删除任务，已开始执行的不受影响
+func (r *triggerRunner) remove(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.triggers, id)
+	r.signal()
+}
+
+// entry 任务的下次与上次触发时间，任务不存在时均为零值
+func (r *triggerRunner) entry(id int) (next, prev time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.triggers[id]; ok {
+		return t.next, t.prev
+	}
+	return time.Time{}, time.Time{}
+}
+
+// start 开始触发任务
+func (r *triggerRunner) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	go r.run(r.stop)
+}
+
+// halt 停止触发任务，已开始执行的任务继续执行至完成
+func (r *triggerRunner) halt() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+// signal 唤醒等待中的循环，调用方持有锁
+func (r *triggerRunner) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 等待至最早的触发时间，触发所有已到期的任务后继续等待；任务变化时重新计算等待时间
+func (r *triggerRunner) run(stop <-chan struct{}) {
+	for {
+		r.mu.Lock()
+		c := r.clock
+		var earliest time.Time
+		for _, t := range r.triggers {
+			// 表达式没有可触发的时间时 next 为零值，不再触发
+			if t.next.IsZero() {
+				continue
+			}
+			if earliest.IsZero() || t.next.Before(earliest) {
+				earliest = t.next
+			}
+		}
+		r.mu.Unlock()
+
+		// 没有任务时只等待任务变化或停止
+		var fire <-chan time.Time
+		if !earliest.IsZero() {
+			fire = c.After(earliest.Sub(c.Now()))
+		}
+		select {
+		case <-stop:
+			return
+		case <-r.wake:
+		case <-fire:
+			r.fireDue()
+		}
+	}
+}
+
+// fireDue 触发所有下次触发时间不晚于当前时间的任务，各任务在独立的 goroutine 中执行
+func (r *triggerRunner) fireDue() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	for _, t := range r.triggers {
+		if t.next.IsZero() || t.next.After(now) {
+			continue
+		}
+		t.prev = t.next
+		t.next = t.schedule.Next(now)
+		go t.job()
+	}
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"flowforge/internal/clocktest"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestScheduler 使用可控时钟的调度器，测试结束时停止
+func newTestScheduler(t *testing.T) (*Scheduler, *clocktest.Fake) {
+	t.Helper()
+	fake := clocktest.New(epoch)
+	s := NewScheduler()
+	s.SetClock(fake)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s, fake
+}
+
+// waitFired 等待任务触发，返回触发时的时钟时间；任务在独立的 goroutine 中执行，只有这里需要等待
+func waitFired(t *testing.T, fired <-chan time.Time) time.Time {
+	t.Helper()
+	select {
+	case at := <-fired:
+		return at
+	case <-time.After(5 * time.Second):
+		t.Fatal("推进到触发时间后任务未执行")
+		return time.Time{}
+	}
+}
+
+func TestJobFiresWhenClockReachesNextRun(t *testing.T) {
+	s, fake := newTestScheduler(t)
+	fired := make(chan time.Time, 10)
+	if err := s.AddJob("every5m", "0 */5 * * * *", func() { fired <- fake.Now() }); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := s.GetJobs()
+	if len(jobs) != 1 || jobs[0].NextRun == nil || !jobs[0].NextRun.Equal(epoch.Add(5*time.Minute)) {
+		t.Fatalf("下次触发时间应为 00:05，实际为 %+v", jobs)
+	}
+
+	// 等待循环开始等待最早的触发时间，推进到触发时间之前不触发
+	fake.BlockUntil(1)
+	fake.Advance(5*time.Minute - time.Second)
+	select {
+	case <-fired:
+		t.Fatal("未到触发时间不应执行")
+	default:
+	}
+
+	fake.Advance(time.Second)
+	if at := waitFired(t, fired); !at.Equal(epoch.Add(5 * time.Minute)) {
+		t.Errorf("触发时间 %v，应为 00:05", at)
+	}
+
+	// 触发后下次触发时间前进一个周期
+	fake.Advance(5 * time.Minute)
+	if at := waitFired(t, fired); !at.Equal(epoch.Add(10 * time.Minute)) {
+		t.Errorf("第二次触发时间 %v，应为 00:10", at)
+	}
+	jobs = s.GetJobs()
+	if jobs[0].LastRun == nil || !jobs[0].LastRun.Equal(epoch.Add(10*time.Minute)) {
+		t.Errorf("上次触发时间应为 00:10，实际为 %v", jobs[0].LastRun)
+	}
+}
+
+func TestDailyJobWithoutWaiting(t *testing.T) {
+	s, fake := newTestScheduler(t)
+	fired := make(chan time.Time, 10)
+	// 与清理任务相同的每天凌晨 2 点
+	if err := s.AddJob("nightly", "0 0 2 * * *", func() { fired <- fake.Now() }); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.BlockUntil(1)
+	for day := 0; day < 3; day++ {
+		fake.Set(epoch.AddDate(0, 0, day).Add(2 * time.Hour))
+		if at, want := waitFired(t, fired), epoch.AddDate(0, 0, day).Add(2*time.Hour); !at.Equal(want) {
+			t.Fatalf("第 %d 天的触发时间 %v，应为 %v", day+1, at, want)
+		}
+	}
+}
+
+func TestRemovedJobDoesNotFire(t *testing.T) {
+	s, fake := newTestScheduler(t)
+	fired := make(chan time.Time, 10)
+	if err := s.AddJob("minutely", "0 * * * * *", func() { fired <- fake.Now() }); err != nil {
+		t.Fatal(err)
+	}
+	fake.BlockUntil(1)
+	if err := s.RemoveJob("minutely"); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Advance(time.Hour)
+	select {
+	case at := <-fired:
+		t.Fatalf("删除的任务不应执行，在 %v 执行了", at)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if s.GetJobCount() != 0 {
+		t.Errorf("删除后任务数应为 0，实际为 %d", s.GetJobCount())
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	next, err := NextRun("0 30 9 * * 1-5", epoch) // 2026-01-01 为周四
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextRun = %v，应为 %v", next, want)
+	}
+	if _, err := NextRun("not a cron", epoch); err == nil {
+		t.Error("无效的表达式应返回错误")
+	}
+}
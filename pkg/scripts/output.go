@@ -0,0 +1,414 @@
+package scripts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 日志回调跟不上输出时的处理策略
+const (
+	BackpressureDrop     = "drop"     // 丢弃新的行并计数，恢复后输出一行提示
+	BackpressureCoalesce = "coalesce" // 把新的行合并到缓冲区最后一条，一次回调传递多行；字节数超限时仍然丢弃
+)
+
+const (
+	// defaultBufferLines 等待日志回调的默认最大条数
+	defaultBufferLines = 10000
+	// maxBufferedBytes 等待日志回调的输出字节数上限
+	maxBufferedBytes = 8 << 20
+	// maxLineBytes 单行输出的长度上限，超出时拆成多行
+	maxLineBytes = 64 << 10
+	// maxRetainedOutput 执行结果中保留的输出字节数，超出时保留开头与结尾
+	maxRetainedOutput = 1 << 20
+	// defaultMaxLogBytes 未配置时一次执行交给日志回调的输出字节数上限
+	defaultMaxLogBytes = 32 << 20
+
+	// lineSplitMarker 超长的行拆开后，除最后一段外每段末尾的标记
+	lineSplitMarker = " ↩"
+	// binarySampleMin 按无效 UTF-8 占比判断二进制内容时至少需要的字节数
+	binarySampleMin = 64
+	// binaryInvalidRatio 无效 UTF-8 字节占比超过该值时视为二进制内容
+	binaryInvalidRatio = 0.3
+)
+
+// pendingLine 等待回调的一条输出
+type pendingLine struct {
+	text      string
+	coalesced []string // 缓冲区满时合并进来的后续行
+	size      int
+	dropped   int64 // 紧随这一条之后被丢弃的行数
+}
+
+// lineBuffer 输出读取与日志回调之间的有界环形缓冲。写入从不等待回调，
+// 缓冲区满时按策略丢弃或合并，子进程的管道始终被及时读空
+type lineBuffer struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	entries   []pendingLine
+	head      int
+	count     int
+	bytes     int
+	policy    string
+	dropped   int64
+	closed    bool
+	abandoned bool
+}
+
+// newLineBuffer 创建输出缓冲
+func newLineBuffer(lines int, policy string) *lineBuffer {
+	if lines <= 0 {
+		lines = defaultBufferLines
+	}
+	if policy != BackpressureCoalesce {
+		policy = BackpressureDrop
+	}
+	b := &lineBuffer{
+		entries: make([]pendingLine, lines),
+		policy:  policy,
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// push 写入一行输出，不会阻塞
+func (b *lineBuffer) push(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.abandoned {
+		b.dropped++
+		return
+	}
+
+	fits := b.bytes+len(line) <= maxBufferedBytes
+	switch {
+	case fits && b.count < len(b.entries):
+		b.entries[(b.head+b.count)%len(b.entries)] = pendingLine{text: line, size: len(line)}
+		b.count++
+		b.bytes += len(line)
+		b.cond.Signal()
+		return
+	case fits && b.policy == BackpressureCoalesce:
+		last := &b.entries[(b.head+b.count-1)%len(b.entries)]
+		if last.dropped == 0 {
+			last.coalesced = append(last.coalesced, line)
+			last.size += len(line)
+			b.bytes += len(line)
+			return
+		}
+	}
+
+	// 缓冲区满时条数一定大于 0，丢弃的行数记在最后一条上，提示出现在正确的位置
+	b.entries[(b.head+b.count-1)%len(b.entries)].dropped++
+	b.dropped++
+}
+
+// pop 取出下一条输出，缓冲区关闭且为空时返回 false
+func (b *lineBuffer) pop() (pendingLine, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.count == 0 && !b.closed {
+		b.cond.Wait()
+	}
+	if b.count == 0 || b.abandoned {
+		return pendingLine{}, false
+	}
+
+	entry := b.entries[b.head]
+	b.entries[b.head] = pendingLine{}
+	b.head = (b.head + 1) % len(b.entries)
+	b.count--
+	b.bytes -= entry.size
+	return entry, true
+}
+
+// deliver 依次把输出交给日志回调，直到缓冲区关闭并取完
+func (b *lineBuffer) deliver(callback func(string)) {
+	for {
+		entry, ok := b.pop()
+		if !ok {
+			return
+		}
+		if len(entry.coalesced) == 0 {
+			callback(entry.text)
+		} else {
+			callback(entry.text + "\n" + strings.Join(entry.coalesced, "\n"))
+		}
+		if entry.dropped > 0 {
+			callback(fmt.Sprintf("... 输出过快，日志回调跟不上，丢弃了 %d 行 ...", entry.dropped))
+		}
+	}
+}
+
+// close 不再写入，剩余的输出继续交给回调
+func (b *lineBuffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+}
+
+// abandon 放弃尚未回调的输出，计入丢弃的行数
+func (b *lineBuffer) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.count > 0 {
+		entry := b.entries[b.head]
+		b.dropped += 1 + int64(len(entry.coalesced))
+		b.entries[b.head] = pendingLine{}
+		b.head = (b.head + 1) % len(b.entries)
+		b.count--
+	}
+	b.bytes = 0
+	b.closed, b.abandoned = true, true
+	b.cond.Broadcast()
+}
+
+// droppedLines 丢弃的行数
+func (b *lineBuffer) droppedLines() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// lineWriter 把子进程的输出按行切分后交给 emit：超过 maxLineBytes 的行拆成多行，除最后一段外都带有 lineSplitMarker；
+// 检测到二进制内容后不再逐行输出，只统计字节数，结束时输出一行汇总。raw 非空时原始输出同时写入 raw。
+// 作为 exec.Cmd 的 Stdout/Stderr 使用，由 exec 的复制协程调用
+type lineWriter struct {
+	emit    func(string)
+	partial []byte
+	raw     io.Writer
+
+	binary      bool
+	binaryBytes int64
+}
+
+// Write 实现 io.Writer
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.raw != nil {
+		w.raw.Write(p)
+	}
+	for len(p) > 0 && !w.binary {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.appendPartial(p)
+			p = nil
+			break
+		}
+		w.appendPartial(p[:i])
+		p = p[i+1:]
+		if !w.binary {
+			w.flush(w.partial, false)
+			w.partial = w.partial[:0]
+		}
+	}
+	if w.binary {
+		w.binaryBytes += int64(len(p))
+	}
+	return n, nil
+}
+
+// Close 输出最后一行没有换行结尾的内容；二进制输出只输出汇总
+func (w *lineWriter) Close() error {
+	if !w.binary && len(w.partial) > 0 {
+		w.flush(w.partial, false)
+		w.partial = w.partial[:0]
+	}
+	if w.binary {
+		w.emit(fmt.Sprintf("... 已隐藏二进制输出 %s ...", formatSize(w.binaryBytes)))
+	}
+	return nil
+}
+
+// appendPartial 累积未结束的行，超过 maxLineBytes 时按 UTF-8 字符边界拆出一段
+func (w *lineWriter) appendPartial(p []byte) {
+	if w.binary {
+		w.binaryBytes += int64(len(p))
+		return
+	}
+	w.partial = append(w.partial, p...)
+	for len(w.partial) > maxLineBytes && !w.binary {
+		cut := maxLineBytes
+		for i := 0; i < utf8.UTFMax && cut > 0 && !utf8.RuneStart(w.partial[cut]); i++ {
+			cut--
+		}
+		if cut == 0 {
+			cut = maxLineBytes
+		}
+		w.flush(w.partial[:cut], true)
+		w.partial = append(w.partial[:0], w.partial[cut:]...)
+	}
+	if w.binary {
+		w.binaryBytes += int64(len(w.partial))
+		w.partial = nil
+	}
+}
+
+// flush 输出一行（或超长行拆出的一段）；内容像二进制时切换为只统计字节数
+func (w *lineWriter) flush(line []byte, split bool) {
+	if looksBinary(line) {
+		w.binary = true
+		w.binaryBytes += int64(len(line))
+		w.emit("... 检测到二进制输出，不再记录该输出流 ...")
+		return
+	}
+	text := strings.TrimSuffix(string(line), "\r")
+	if split {
+		text += lineSplitMarker
+	}
+	w.emit(text)
+}
+
+// looksBinary 内容是否像二进制：含有 NUL 字节，或者无效的 UTF-8 字节占比超过 binaryInvalidRatio 且含有控制字符。
+// 只有无效 UTF-8 而没有控制字符的内容可能是 GBK 等其他编码的文本，不视为二进制
+func looksBinary(line []byte) bool {
+	if bytes.IndexByte(line, 0) >= 0 {
+		return true
+	}
+	if len(line) < binarySampleMin {
+		return false
+	}
+
+	invalid, control := 0, 0
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+		case r < 0x20 && r != '\t' && r != '\r' && r != '\b' && r != '\f' && r != '\v' && r != 0x1b:
+			control++
+		}
+		i += size
+	}
+	return float64(invalid) > float64(len(line))*binaryInvalidRatio && control > 0
+}
+
+// formatSize 字节数的可读形式
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// lockedWriter 供 stdout 与 stderr 的复制协程同时写入同一个 io.Writer，写入失败后不再写入
+type lockedWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return len(p), nil
+	}
+	if _, err := l.w.Write(p); err != nil {
+		l.err = err
+	}
+	return len(p), nil
+}
+
+// logLimiter 限制一次执行交给日志回调的输出量：开头 head 字节原样交给回调，之后只在内存中保留最后 tail 字节，
+// 结束时输出省略的行数与字节数，再输出保留的结尾部分。stdout 与 stderr 的复制协程同时调用
+type logLimiter struct {
+	mu   sync.Mutex
+	emit func(string)
+	head int
+	tail int
+	sent int
+
+	lines        []string
+	lineBytes    int
+	omittedLines int64
+	omittedBytes int64
+}
+
+// newLogLimiter 按总字节数上限创建，开头与结尾各保留一半
+func newLogLimiter(limit int, emit func(string)) *logLimiter {
+	return &logLimiter{emit: emit, head: limit / 2, tail: limit - limit/2}
+}
+
+// push 写入一行输出
+func (l *logLimiter) push(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.lines) == 0 && l.sent+len(line) <= l.head {
+		l.sent += len(line)
+		l.emit(line)
+		return
+	}
+	l.lines = append(l.lines, line)
+	l.lineBytes += len(line)
+	for l.lineBytes > l.tail && len(l.lines) > 1 {
+		l.omittedLines++
+		l.omittedBytes += int64(len(l.lines[0]))
+		l.lineBytes -= len(l.lines[0])
+		l.lines[0] = ""
+		l.lines = l.lines[1:]
+	}
+}
+
+// finish 输出省略的说明与保留的结尾部分
+func (l *logLimiter) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.omittedLines > 0 {
+		l.emit(fmt.Sprintf("... 输出超过 %s，省略了中间的 %d 行（%s）...",
+			formatSize(int64(l.head+l.tail)), l.omittedLines, formatSize(l.omittedBytes)))
+	}
+	for _, line := range l.lines {
+		l.emit(line)
+	}
+	l.lines = nil
+}
+
+// outputCapture 执行结果中保留的输出：超过 limit 字节时保留开头与结尾各一半，中间省略
+type outputCapture struct {
+	limit   int
+	head    []byte
+	tail    []byte
+	omitted int64
+}
+
+func (o *outputCapture) add(line string) {
+	if o.tail == nil && len(o.head)+len(line)+1 <= o.limit/2 {
+		o.head = append(o.head, line...)
+		o.head = append(o.head, '\n')
+		return
+	}
+	o.tail = append(o.tail, line...)
+	o.tail = append(o.tail, '\n')
+	if keep := o.limit - o.limit/2; len(o.tail) > 2*keep {
+		cut := len(o.tail) - keep
+		o.omitted += int64(cut)
+		o.tail = append(o.tail[:0], o.tail[cut:]...)
+	}
+}
+
+func (o *outputCapture) String() string {
+	keep := o.limit - o.limit/2
+	tail, omitted := o.tail, o.omitted
+	if len(tail) > keep {
+		omitted += int64(len(tail) - keep)
+		tail = tail[len(tail)-keep:]
+	}
+	if omitted == 0 {
+		return string(o.head) + string(tail)
+	}
+	return string(o.head) + fmt.Sprintf("... 省略了 %s 输出 ...\n", formatSize(omitted)) + string(tail)
+}
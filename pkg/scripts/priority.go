@@ -0,0 +1,25 @@
+package scripts
+
+import "flowforge/pkg/models"
+
+// Priority 本机执行的脚本进程的调度优先级，零值不调整
+type Priority struct {
+	Nice         int    // nice 值 -20~19
+	IOClass      string // models.IOClassBestEffort、models.IOClassIdle，为空或 none 时不调整
+	IOLevel      int    // best-effort 下的 IO 优先级 0~7
+	ReservedCPUs int    // 为服务保留的 CPU 核数，脚本进程只在其余的核上运行
+}
+
+// AppliedPriority 实际应用到脚本进程的调度优先级，记录到步骤；未能应用的项记录在 Warnings 中
+type AppliedPriority struct {
+	Nice     int      `json:"nice"`
+	IOClass  string   `json:"io_class,omitempty"`
+	IOLevel  int      `json:"io_level,omitempty"`
+	CPUs     string   `json:"cpus,omitempty"` // 允许使用的 CPU，如 2-7
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// requested 是否需要调整
+func (p Priority) requested() bool {
+	return p.Nice != 0 || p.ReservedCPUs > 0 || (p.IOClass != "" && p.IOClass != models.IOClassNone)
+}
@@ -0,0 +1,116 @@
+//go:build linux
+
+package scripts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"flowforge/pkg/models"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set 的参数，见 linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// applyPriority 在脚本进程启动后立即应用调度优先级，脚本此后创建的子进程继承该设置。
+// 每项单独应用，失败的项记录告警，不影响脚本执行
+func applyPriority(pid int, p Priority) *AppliedPriority {
+	applied := &AppliedPriority{}
+
+	if p.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, p.Nice); err != nil {
+			applied.Warnings = append(applied.Warnings, fmt.Sprintf("设置 nice %d 失败: %v", p.Nice, err))
+		} else {
+			applied.Nice = p.Nice
+		}
+	}
+
+	if class := ioClass(p.IOClass); class != 0 {
+		level := 0
+		if class == ioprioClassBE {
+			level = p.IOLevel
+		}
+		value := class<<ioprioClassShift | level
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(value)); errno != 0 {
+			applied.Warnings = append(applied.Warnings, fmt.Sprintf("设置 IO 调度类 %s 失败: %v", p.IOClass, errno))
+		} else {
+			applied.IOClass = p.IOClass
+			if class == ioprioClassBE {
+				applied.IOLevel = level
+			}
+		}
+	}
+
+	if p.ReservedCPUs > 0 {
+		cpus, err := reserveCPUs(pid, p.ReservedCPUs)
+		if err != nil {
+			applied.Warnings = append(applied.Warnings, fmt.Sprintf("为服务保留 %d 个 CPU 核失败: %v", p.ReservedCPUs, err))
+		} else {
+			applied.CPUs = cpus
+		}
+	}
+	return applied
+}
+
+// ioClass IO 调度类对应的内核常量，不调整时返回 0
+func ioClass(class string) int {
+	switch class {
+	case models.IOClassBestEffort:
+		return ioprioClassBE
+	case models.IOClassIdle:
+		return ioprioClassIdle
+	}
+	return 0
+}
+
+// reserveCPUs 从服务进程可用的 CPU 中去掉编号最小的 reserved 个，其余设置为脚本进程的 CPU 亲和性，返回允许使用的 CPU
+func reserveCPUs(pid, reserved int) (string, error) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		return "", err
+	}
+	var available []int
+	for cpu := 0; len(available) < set.Count(); cpu++ {
+		if set.IsSet(cpu) {
+			available = append(available, cpu)
+		}
+	}
+	if len(available) <= reserved {
+		return "", fmt.Errorf("服务只有 %d 个可用的 CPU 核", len(available))
+	}
+
+	var allowed unix.CPUSet
+	for _, cpu := range available[reserved:] {
+		allowed.Set(cpu)
+	}
+	if err := unix.SchedSetaffinity(pid, &allowed); err != nil {
+		return "", err
+	}
+	return formatCPUs(available[reserved:]), nil
+}
+
+// formatCPUs 以 cpuset 的列表格式表示 CPU，如 2-5,7
+func formatCPUs(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(cpus[i])+"-"+strconv.Itoa(cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
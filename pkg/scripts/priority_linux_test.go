@@ -0,0 +1,122 @@
+//go:build linux
+
+package scripts
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"flowforge/pkg/models"
+
+	"golang.org/x/sys/unix"
+)
+
+// priorityScript 等待调度优先级应用后输出脚本进程的 nice 值、IO 调度类与允许使用的 CPU，各占一行
+const priorityScript = `sleep 0.3
+cut -d' ' -f19 /proc/$$/stat
+ionice -p $$
+grep Cpus_allowed_list /proc/$$/status | cut -f2
+`
+
+// runPriority 以 p 执行 priorityScript，返回输出的各行与实际应用的调度优先级
+func runPriority(t *testing.T, p Priority) ([]string, *AppliedPriority) {
+	t.Helper()
+	if _, err := exec.LookPath("ionice"); err != nil {
+		t.Skip("本机没有 ionice")
+	}
+	result, err := newTestManager(t).Execute(context.Background(), priorityScript, ExecuteOptions{Shell: ShellBash, Priority: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("脚本退出码 %d: %s", result.ExitCode, result.Error)
+	}
+	return strings.Split(strings.TrimSpace(result.Output), "\n"), result.Priority
+}
+
+// TestApplyPriority 脚本进程按配置的 nice 值与 IO 调度类运行，应用的值记录在执行结果中；未要求调整时不记录
+func TestApplyPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority Priority
+		nice     string
+		ionice   string
+		applied  *AppliedPriority
+	}{
+		{"best-effort", Priority{Nice: 5, IOClass: models.IOClassBestEffort, IOLevel: 6}, "5", "best-effort: prio 6",
+			&AppliedPriority{Nice: 5, IOClass: models.IOClassBestEffort, IOLevel: 6}},
+		{"idle", Priority{Nice: 19, IOClass: models.IOClassIdle, IOLevel: 6}, "19", "idle",
+			&AppliedPriority{Nice: 19, IOClass: models.IOClassIdle}},
+		{"none 不调整 IO", Priority{Nice: 10, IOClass: models.IOClassNone}, "10", "none: prio 0",
+			&AppliedPriority{Nice: 10}},
+		{"不调整", Priority{IOClass: models.IOClassNone}, "0", "none: prio 0", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, applied := runPriority(t, tt.priority)
+			if len(lines) != 3 || lines[0] != tt.nice || lines[1] != tt.ionice {
+				t.Errorf("脚本进程的 nice 与 IO 调度类为 %q，应为 %s 与 %q", lines, tt.nice, tt.ionice)
+			}
+			if !reflect.DeepEqual(applied, tt.applied) {
+				t.Errorf("记录的调度优先级为 %+v，应为 %+v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+// TestReserveCPUs 为服务保留编号最小的 CPU 核，脚本进程只在其余的核上运行；可用的核不多于保留数时告警并照常执行
+func TestReserveCPUs(t *testing.T) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, applied := runPriority(t, Priority{ReservedCPUs: set.Count()})
+	if applied == nil || applied.CPUs != "" || len(applied.Warnings) != 1 || !strings.Contains(applied.Warnings[0], "保留") {
+		t.Errorf("保留全部 CPU 核时记录 %+v，应告警且不设置亲和性", applied)
+	}
+	if original := formatAllowed(t, set); len(lines) != 3 || lines[2] != original {
+		t.Errorf("保留失败时脚本进程允许使用的 CPU 为 %q，应为 %s", lines, original)
+	}
+
+	if set.Count() < 2 {
+		t.Skip("本机只有 1 个可用的 CPU 核")
+	}
+	lines, applied = runPriority(t, Priority{ReservedCPUs: 1})
+	if applied == nil || applied.CPUs == "" || len(applied.Warnings) != 0 || len(lines) != 3 || lines[2] != applied.CPUs {
+		t.Errorf("保留 1 个核时记录 %+v，脚本进程允许使用的 CPU 为 %q", applied, lines)
+	}
+}
+
+// formatAllowed 亲和性集合中的 CPU 以列表格式表示
+func formatAllowed(t *testing.T, set unix.CPUSet) string {
+	t.Helper()
+	var cpus []int
+	for cpu := 0; len(cpus) < set.Count(); cpu++ {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return formatCPUs(cpus)
+}
+
+// TestFormatCPUs 连续的 CPU 合并为区间
+func TestFormatCPUs(t *testing.T) {
+	tests := []struct {
+		cpus []int
+		want string
+	}{
+		{[]int{0}, "0"},
+		{[]int{2, 3, 4, 5}, "2-5"},
+		{[]int{1, 2, 4, 6, 7}, "1-2,4,6-7"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := formatCPUs(tt.cpus); got != tt.want {
+			t.Errorf("formatCPUs(%v) = %q，应为 %q", tt.cpus, got, tt.want)
+		}
+	}
+}
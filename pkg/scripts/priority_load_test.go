@@ -0,0 +1,93 @@
+//go:build loadtest && linux
+
+package scripts
+
+// 手动执行的负载测试，比较构建占满 CPU 时保留与不保留 CPU 核的接口延迟：
+//
+//	go test -tags loadtest -run TestAPILatencyUnderLoad -v ./pkg/scripts
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+)
+
+const (
+	// loadBuilds 每个 CPU 核上同时执行的构建数
+	loadBuilds = 4
+	// loadRequests 每轮测量的接口请求数
+	loadRequests = 300
+)
+
+// measureP95 builds 个构建以 p 执行期间依次请求 url，返回请求耗时的 p95
+func measureP95(t *testing.T, url string, builds int, p Priority) time.Duration {
+	t.Helper()
+	m := NewManager(&config.Config{Deploy: config.DeployConfig{WorkspaceDir: t.TempDir(), MaxScriptExecutions: builds + 1}})
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < builds; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Execute(ctx, "while :; do :; done", ExecuteOptions{Shell: ShellBash, Priority: p})
+		}()
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	time.Sleep(time.Second)
+
+	durations := make([]time.Duration, loadRequests)
+	for i := range durations {
+		startedAt := time.Now()
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		durations[i] = time.Since(startedAt)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)*95/100]
+}
+
+// TestAPILatencyUnderLoad 每个 CPU 核上运行 4 个占满 CPU 的构建，分别在不调整、只调整 nice 与 IO 调度类、
+// 另外为服务保留 1 个 CPU 核时测量接口延迟的 p95，与没有构建时比较
+func TestAPILatencyUnderLoad(t *testing.T) {
+	if runtime.NumCPU() < 2 {
+		t.Skip("保留 CPU 核至少需要 2 个核")
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 模拟一次列表接口的计算量
+		data := make([]byte, 256<<10)
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(w, "%x", sum[:4])
+	}))
+	defer api.Close()
+
+	builds := loadBuilds * runtime.NumCPU()
+	rounds := []struct {
+		name     string
+		builds   int
+		priority Priority
+	}{
+		{"没有构建", 0, Priority{}},
+		{"不调整", builds, Priority{}},
+		{"nice 10、IO idle", builds, Priority{Nice: 10, IOClass: models.IOClassIdle}},
+		{"nice 10、IO idle、保留 1 个核", builds, Priority{Nice: 10, IOClass: models.IOClassIdle, ReservedCPUs: 1}},
+	}
+	for _, round := range rounds {
+		t.Logf("%s: p95 %v", round.name, measureP95(t, api.URL, round.builds, round.priority))
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package scripts
+
+// applyPriority 只支持 Linux，其他系统记录告警后照常执行
+func applyPriority(pid int, p Priority) *AppliedPriority {
+	return &AppliedPriority{Warnings: []string{"当前系统不支持调整脚本进程的调度优先级"}}
+}
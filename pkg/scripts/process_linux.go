@@ -0,0 +1,24 @@
+//go:build linux
+
+package scripts
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 脚本在独立的进程组中执行，取消或超时时结束整个进程组，
+// 脚本启动的子进程（如 sleep、构建工具）不会在脚本结束后继续运行并占着输出管道
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package scripts
+
+import "os/exec"
+
+// setProcessGroup 只支持 Linux，其他系统取消时只结束脚本进程本身
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -1,407 +1,565 @@
-package scripts
-
-import (
-	"bufio"
-	"context"
-	"fmt"
-	"io"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
-	"sync"
-	"time"
-
-	"flowforge/pkg/config"
-	"flowforge/pkg/models"
-)
-
-// Manager 脚本管理器
-type Manager struct {
-	config *config.Config
-	mu     sync.RWMutex
-}
-
-// NewManager 创建脚本管理器
-func NewManager(cfg *config.Config) *Manager {
-	return &Manager{
-		config: cfg,
-	}
-}
-
-// ExecuteOptions 执行选项
-type ExecuteOptions struct {
-	WorkDir     string
-	Env         map[string]string
-	Timeout     time.Duration
-	LogCallback func(string)
-}
-
-// ExecuteResult 执行结果
-type ExecuteResult struct {
-	ExitCode int
-	Output   string
-	Error    string
-	Duration time.Duration
-}
-
-// Execute 执行脚本
-func (m *Manager) Execute(ctx context.Context, script string, opts ExecuteOptions) (*ExecuteResult, error) {
-	startTime := time.Now()
-	
-	// 创建临时脚本文件
-	scriptFile, err := m.createTempScript(script)
-	if err != nil {
-		return nil, fmt.Errorf("创建临时脚本失败: %w", err)
-	}
-	defer os.Remove(scriptFile)
-
-	// 设置超时上下文
-	if opts.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
-		defer cancel()
-	}
-
-	// 创建命令
-	var cmd *exec.Cmd
-	if strings.HasSuffix(scriptFile, ".sh") {
-		cmd = exec.CommandContext(ctx, "bash", scriptFile)
-	} else if strings.HasSuffix(scriptFile, ".ps1") {
-		cmd = exec.CommandContext(ctx, "powershell", "-ExecutionPolicy", "Bypass", "-File", scriptFile)
-	} else {
-		cmd = exec.CommandContext(ctx, scriptFile)
-	}
-
-	// 设置工作目录
-	if opts.WorkDir != "" {
-		cmd.Dir = opts.WorkDir
-	}
-
-	// 设置环境变量
-	cmd.Env = os.Environ()
-	for key, value := range opts.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
-	}
-
-	// 创建管道
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("创建stdout管道失败: %w", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, fmt.Errorf("创建stderr管道失败: %w", err)
-	}
-
-	// 启动命令
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("启动命令失败: %w", err)
-	}
-
-	// 读取输出
-	var outputBuilder strings.Builder
-	var errorBuilder strings.Builder
-	var wg sync.WaitGroup
-
-	// 读取stdout
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			outputBuilder.WriteString(line + "\n")
-			if opts.LogCallback != nil {
-				opts.LogCallback(line)
-			}
-		}
-	}()
-
-	// 读取stderr
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			errorBuilder.WriteString(line + "\n")
-			if opts.LogCallback != nil {
-				opts.LogCallback("ERROR: " + line)
-			}
-		}
-	}()
-
-	// 等待命令完成
-	err = cmd.Wait()
-	wg.Wait()
-
-	duration := time.Since(startTime)
-	exitCode := 0
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		} else {
-			return nil, fmt.Errorf("命令执行失败: %w", err)
-		}
-	}
-
-	return &ExecuteResult{
-		ExitCode: exitCode,
-		Output:   outputBuilder.String(),
-		Error:    errorBuilder.String(),
-		Duration: duration,
-	}, nil
-}
-
-// createTempScript 创建临时脚本文件
-func (m *Manager) createTempScript(script string) (string, error) {
-	// 确保脚本目录存在
-	scriptDir := filepath.Join(m.config.Deploy.WorkspaceDir, "scripts", "temp")
-	if err := os.MkdirAll(scriptDir, 0755); err != nil {
-		return "", fmt.Errorf("创建脚本目录失败: %w", err)
-	}
-
-	// 根据操作系统选择脚本扩展名
-	var ext string
-	if strings.Contains(strings.ToLower(os.Getenv("OS")), "windows") {
-		ext = ".ps1"
-	} else {
-		ext = ".sh"
-	}
-
-	// 创建临时文件
-	tempFile, err := os.CreateTemp(scriptDir, "script_*"+ext)
-	if err != nil {
-		return "", fmt.Errorf("创建临时文件失败: %w", err)
-	}
-	defer tempFile.Close()
-
-	// 写入脚本内容
-	if _, err := tempFile.WriteString(script); err != nil {
-		return "", fmt.Errorf("写入脚本内容失败: %w", err)
-	}
-
-	// 设置执行权限
-	if err := os.Chmod(tempFile.Name(), 0755); err != nil {
-		return "", fmt.Errorf("设置执行权限失败: %w", err)
-	}
-
-	return tempFile.Name(), nil
-}
-
-// ValidateScript 验证脚本语法
-func (m *Manager) ValidateScript(script string, scriptType string) error {
-	switch scriptType {
-	case models.ScriptTypeBash:
-		return m.validateBashScript(script)
-	case models.ScriptTypePowerShell:
-		return m.validatePowerShellScript(script)
-	case models.ScriptTypePython:
-		return m.validatePythonScript(script)
-	default:
-		return fmt.Errorf("不支持的脚本类型: %s", scriptType)
-	}
-}
-
-// validateBashScript 验证Bash脚本
-func (m *Manager) validateBashScript(script string) error {
-	// 创建临时脚本文件
-	scriptFile, err := m.createTempScript(script)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(scriptFile)
-
-	// 使用bash -n检查语法
-	cmd := exec.Command("bash", "-n", scriptFile)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("Bash脚本语法错误: %w", err)
-	}
-
-	return nil
-}
-
-// validatePowerShellScript 验证PowerShell脚本
-func (m *Manager) validatePowerShellScript(script string) error {
-	// 创建临时脚本文件
-	scriptFile, err := m.createTempScript(script)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(scriptFile)
-
-	// 使用PowerShell检查语法
-	cmd := exec.Command("powershell", "-NoProfile", "-Command", fmt.Sprintf("Get-Command -Syntax (Get-Content '%s' -Raw)", scriptFile))
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("PowerShell脚本语法错误: %w", err)
-	}
-
-	return nil
-}
-
-// validatePythonScript 验证Python脚本
-func (m *Manager) validatePythonScript(script string) error {
-	// 创建临时脚本文件
-	scriptFile, err := m.createTempScript(script)
-	if err != nil {
-		return err
-	}
-	defer os.Remove(scriptFile)
-
-	// 使用python -m py_compile检查语法
-	cmd := exec.Command("python", "-m", "py_compile", scriptFile)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("Python脚本语法错误: %w", err)
-	}
-
-	return nil
-}
-
-// GetBuiltinScripts 获取内置脚本模板
-func (m *Manager) GetBuiltinScripts() map[string]string {
-	return map[string]string{
-		"node_build": `#!/bin/bash
-# Node.js 项目构建脚本
-set -e
-
-echo "开始构建 Node.js 项目..."
-
-# 安装依赖
-if [ -f "package.json" ]; then
-    echo "安装 npm 依赖..."
-    npm install
-fi
-
-# 运行构建
-if [ -f "package.json" ] && npm run | grep -q "build"; then
-    echo "运行构建命令..."
-    npm run build
-fi
-
-echo "Node.js 项目构建完成"
-`,
-		"go_build": `#!/bin/bash
-# Go 项目构建脚本
-set -e
-
-echo "开始构建 Go 项目..."
-
-# 下载依赖
-echo "下载 Go 模块依赖..."
-go mod download
-
-# 运行测试
-echo "运行测试..."
-go test ./...
-
-# 构建项目
-echo "构建项目..."
-go build -o app ./cmd/server
-
-echo "Go 项目构建完成"
-`,
-		"docker_build": `#!/bin/bash
-# Docker 构建脚本
-set -e
-
-echo "开始 Docker 构建..."
-
-# 构建镜像
-if [ -f "Dockerfile" ]; then
-    echo "构建 Docker 镜像..."
-    docker build -t $PROJECT_NAME:$BUILD_VERSION .
-    
-    echo "Docker 镜像构建完成: $PROJECT_NAME:$BUILD_VERSION"
-else
-    echo "未找到 Dockerfile"
-    exit 1
-fi
-`,
-		"deploy_script": `#!/bin/bash
-# 部署脚本
-set -e
-
-echo "开始部署应用..."
-
-# 停止旧服务
-echo "停止旧服务..."
-sudo systemctl stop $SERVICE_NAME || true
-
-# 备份旧版本
-if [ -f "$DEPLOY_PATH/$APP_NAME" ]; then
-    echo "备份旧版本..."
-    sudo cp "$DEPLOY_PATH/$APP_NAME" "$DEPLOY_PATH/$APP_NAME.backup.$(date +%Y%m%d_%H%M%S)"
-fi
-
-# 复制新版本
-echo "复制新版本..."
-sudo cp ./app "$DEPLOY_PATH/$APP_NAME"
-sudo chmod +x "$DEPLOY_PATH/$APP_NAME"
-
-# 启动新服务
-echo "启动新服务..."
-sudo systemctl start $SERVICE_NAME
-sudo systemctl enable $SERVICE_NAME
-
-echo "部署完成"
-`,
-	}
-}
-
-// ExecuteBuiltinScript 执行内置脚本
-func (m *Manager) ExecuteBuiltinScript(ctx context.Context, scriptName string, opts ExecuteOptions) (*ExecuteResult, error) {
-	builtinScripts := m.GetBuiltinScripts()
-	script, exists := builtinScripts[scriptName]
-	if !exists {
-		return nil, fmt.Errorf("内置脚本不存在: %s", scriptName)
-	}
-
-	return m.Execute(ctx, script, opts)
-}
-
-// StreamExecute 流式执行脚本
-func (m *Manager) StreamExecute(ctx context.Context, script string, opts ExecuteOptions, output io.Writer) error {
-	// 创建临时脚本文件
-	scriptFile, err := m.createTempScript(script)
-	if err != nil {
-		return fmt.Errorf("创建临时脚本失败: %w", err)
-	}
-	defer os.Remove(scriptFile)
-
-	// 设置超时上下文
-	if opts.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
-		defer cancel()
-	}
-
-	// 创建命令
-	var cmd *exec.Cmd
-	if strings.HasSuffix(scriptFile, ".sh") {
-		cmd = exec.CommandContext(ctx, "bash", scriptFile)
-	} else if strings.HasSuffix(scriptFile, ".ps1") {
-		cmd = exec.CommandContext(ctx, "powershell", "-ExecutionPolicy", "Bypass", "-File", scriptFile)
-	} else {
-		cmd = exec.CommandContext(ctx, scriptFile)
-	}
-
-	// 设置工作目录
-	if opts.WorkDir != "" {
-		cmd.Dir = opts.WorkDir
-	}
-
-	// 设置环境变量
-	cmd.Env = os.Environ()
-	for key, value := range opts.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
-	}
-
-	// 设置输出
-	cmd.Stdout = output
-	cmd.Stderr = output
-
-	// 执行命令
-	return cmd.Run()
+package scripts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/isolation"
+	"flowforge/pkg/models"
+)
+
+// pipeWaitDelay 脚本结束后等待输出管道关闭的时间，后台进程继承管道时不会一直等待
+const pipeWaitDelay = 10 * time.Second
+
+// Manager 脚本管理器
+type Manager struct {
+	config *config.Config
+	mu     sync.RWMutex
+
+	// shells 本机可用的解释器及其可执行文件，创建时探测一次
+	shells map[string]string
+
+	// slots 同时执行的脚本数上限，独立于引擎的运行队列
+	slots chan struct{}
+
+	active     int64
+	waiting    int64
+	readers    int64
+	delivering int64
+	executions int64
+	dropped    int64
+}
+
+// Stats 脚本执行的运行指标
+type Stats struct {
+	ActiveExecutions   int64 `json:"active_executions"`
+	WaitingExecutions  int64 `json:"waiting_executions"`
+	MaxConcurrent      int   `json:"max_concurrent"`
+	ReaderGoroutines   int64 `json:"reader_goroutines"`
+	DeliveryGoroutines int64 `json:"delivery_goroutines"`
+	TotalExecutions    int64 `json:"total_executions"`
+	DroppedLines       int64 `json:"dropped_lines"`
+}
+
+// NewManager 创建脚本管理器
+func NewManager(cfg *config.Config) *Manager {
+	return &Manager{
+		config: cfg,
+		slots:  make(chan struct{}, cfg.Deploy.MaxScriptExecutions),
+		shells: probeShells(),
+	}
+}
+
+// Stats 获取脚本执行的运行指标
+func (m *Manager) Stats() Stats {
+	return Stats{
+		ActiveExecutions:   atomic.LoadInt64(&m.active),
+		WaitingExecutions:  atomic.LoadInt64(&m.waiting),
+		MaxConcurrent:      cap(m.slots),
+		ReaderGoroutines:   atomic.LoadInt64(&m.readers),
+		DeliveryGoroutines: atomic.LoadInt64(&m.delivering),
+		TotalExecutions:    atomic.LoadInt64(&m.executions),
+		DroppedLines:       atomic.LoadInt64(&m.dropped),
+	}
+}
+
+// acquire 等待执行名额，ctx 结束时放弃
+func (m *Manager) acquire(ctx context.Context) (func(), error) {
+	atomic.AddInt64(&m.waiting, 1)
+	defer atomic.AddInt64(&m.waiting, -1)
+
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待执行名额时取消: %w", ctx.Err())
+	}
+
+	atomic.AddInt64(&m.active, 1)
+	atomic.AddInt64(&m.executions, 1)
+	return func() {
+		atomic.AddInt64(&m.active, -1)
+		<-m.slots
+	}, nil
+}
+
+// ExecuteOptions 执行选项
+type ExecuteOptions struct {
+	WorkDir     string
+	Env         map[string]string
+	Timeout     time.Duration
+	LogCallback func(string)
+
+	// Backpressure 日志回调跟不上输出时的处理策略，默认 BackpressureDrop
+	Backpressure string
+	// BufferLines 等待日志回调的最大条数，默认 10000
+	BufferLines int
+	// MaxLogBytes 交给日志回调的输出字节数上限，超过时只保留开头与结尾；0 使用配置 deploy.max_step_log_mb，小于 0 不限制
+	MaxLogBytes int
+	// RawOutput 非空时 stdout 与 stderr 的原始输出同时写入，不受行长度、二进制检测与字节数上限的影响
+	RawOutput io.Writer
+	// RunAs 非空时以该项目系统用户执行，执行前将工作目录与临时脚本归属该用户
+	RunAs *isolation.Account
+	// Shell 执行脚本的解释器（bash、sh、pwsh、powershell、cmd、python），为空时使用本机默认的解释器
+	Shell string
+	// Priority 脚本进程的调度优先级，零值不调整
+	Priority Priority
+}
+
+// ExecuteResult 执行结果，Output 与 Error 最多保留 1MB，超出时保留开头与结尾
+type ExecuteResult struct {
+	ExitCode     int
+	Output       string
+	Error        string
+	Duration     time.Duration
+	DroppedLines int64            // 日志回调跟不上而丢弃的行数
+	SlotWait     time.Duration    // 等待执行名额（max_script_executions）的时间，不计入 Duration
+	Priority     *AppliedPriority // 实际应用的调度优先级，未要求调整时为 nil
+}
+
+// Execute 执行脚本。输出先写入有界缓冲，再由单独的协程交给日志回调，
+// 回调阻塞不会让子进程因管道写满而停住
+func (m *Manager) Execute(ctx context.Context, script string, opts ExecuteOptions) (*ExecuteResult, error) {
+	queuedAt := time.Now()
+	release, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	startTime := time.Now()
+	slotWait := startTime.Sub(queuedAt)
+
+	shell, shellPath, err := m.resolveShell(opts.Shell)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 创建临时脚本文件
+	scriptFile, err := m.createTempScript(script, shell)
+	if err != nil {
+		return nil, fmt.Errorf("创建临时脚本失败: %w", err)
+	}
+	defer os.Remove(scriptFile)
+	if err := m.prepareRunAs(scriptFile, opts); err != nil {
+		return nil, err
+	}
+
+	// 设置超时上下文，超时后已读取的输出仍然交给回调
+	runCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := m.command(runCtx, shellPath, shell, scriptFile, opts)
+
+	// 日志回调在单独的协程中执行
+	logLine := func(string) {}
+	var buffer *lineBuffer
+	var limiter *logLimiter
+	delivered := make(chan struct{})
+	if opts.LogCallback != nil {
+		buffer = newLineBuffer(opts.BufferLines, opts.Backpressure)
+		logLine = buffer.push
+		if limit := m.maxLogBytes(opts); limit > 0 {
+			limiter = newLogLimiter(limit, buffer.push)
+			logLine = limiter.push
+		}
+		atomic.AddInt64(&m.delivering, 1)
+		go func() {
+			defer close(delivered)
+			defer atomic.AddInt64(&m.delivering, -1)
+			buffer.deliver(opts.LogCallback)
+		}()
+	} else {
+		close(delivered)
+	}
+
+	// 读取输出：exec 的复制协程写入 lineWriter，写入从不阻塞
+	var raw io.Writer
+	if opts.RawOutput != nil {
+		raw = &lockedWriter{w: opts.RawOutput}
+	}
+	output := &outputCapture{limit: maxRetainedOutput}
+	errorOutput := &outputCapture{limit: maxRetainedOutput}
+	stdout := &lineWriter{raw: raw, emit: func(line string) {
+		output.add(line)
+		logLine(line)
+	}}
+	stderr := &lineWriter{raw: raw, emit: func(line string) {
+		errorOutput.add(line)
+		logLine("ERROR: " + line)
+	}}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = pipeWaitDelay
+
+	// 启动命令
+	if err := cmd.Start(); err != nil {
+		if buffer != nil {
+			buffer.close()
+		}
+		return nil, fmt.Errorf("启动命令失败: %w", err)
+	}
+	var priority *AppliedPriority
+	if opts.Priority.requested() {
+		priority = applyPriority(cmd.Process.Pid, opts.Priority)
+	}
+
+	// 等待命令完成，Wait 返回时输出已经复制完
+	atomic.AddInt64(&m.readers, 2)
+	err = cmd.Wait()
+	atomic.AddInt64(&m.readers, -2)
+	stdout.Close()
+	stderr.Close()
+	if limiter != nil {
+		limiter.finish()
+	}
+
+	// 等待剩余的输出交给回调，运行被取消时放弃
+	var dropped int64
+	if buffer != nil {
+		buffer.close()
+		select {
+		case <-delivered:
+		case <-ctx.Done():
+			buffer.abandon()
+		}
+		dropped = buffer.droppedLines()
+		atomic.AddInt64(&m.dropped, dropped)
+	}
+
+	duration := time.Since(startTime)
+	exitCode := 0
+	if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		} else {
+			return nil, fmt.Errorf("命令执行失败: %w", err)
+		}
+	}
+
+	return &ExecuteResult{
+		ExitCode:     exitCode,
+		Output:       output.String(),
+		Error:        errorOutput.String(),
+		Duration:     duration,
+		DroppedLines: dropped,
+		SlotWait:     slotWait,
+		Priority:     priority,
+	}, nil
+}
+
+// maxLogBytes 一次执行交给日志回调的输出字节数上限，0 表示不限制
+func (m *Manager) maxLogBytes(opts ExecuteOptions) int {
+	switch {
+	case opts.MaxLogBytes > 0:
+		return opts.MaxLogBytes
+	case opts.MaxLogBytes < 0:
+		return 0
+	}
+	switch mb := m.config.Deploy.MaxStepLogMB; {
+	case mb < 0:
+		return 0
+	case mb == 0:
+		return defaultMaxLogBytes
+	default:
+		return mb << 20
+	}
+}
+
+// command 创建以解释器执行脚本文件的命令
+func (m *Manager) command(ctx context.Context, shellPath string, shell shellSpec, scriptFile string, opts ExecuteOptions) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, shellPath, shell.args(scriptFile)...)
+
+	// 设置工作目录
+	if opts.WorkDir != "" {
+		cmd.Dir = opts.WorkDir
+	}
+
+	// 设置环境变量，以项目用户执行时 HOME 等指向该用户
+	cmd.Env = os.Environ()
+	if opts.RunAs != nil {
+		isolation.Apply(cmd, opts.RunAs)
+		cmd.Env = append(cmd.Env, "HOME="+opts.RunAs.Home, "USER="+opts.RunAs.Name, "LOGNAME="+opts.RunAs.Name)
+	}
+	for key, value := range opts.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// prepareRunAs 以项目用户执行前，将工作目录与临时脚本交给该用户
+func (m *Manager) prepareRunAs(scriptFile string, opts ExecuteOptions) error {
+	if opts.RunAs == nil {
+		return nil
+	}
+	if opts.WorkDir != "" {
+		if err := isolation.Prepare(opts.WorkDir, opts.RunAs); err != nil {
+			return fmt.Errorf("准备工作目录失败: %w", err)
+		}
+	}
+	if err := isolation.PrepareFile(scriptFile, opts.RunAs); err != nil {
+		return fmt.Errorf("准备临时脚本失败: %w", err)
+	}
+	return nil
+}
+
+// createTempScript 按解释器的扩展名创建临时脚本文件
+func (m *Manager) createTempScript(script string, shell shellSpec) (string, error) {
+	// 确保脚本目录存在
+	scriptDir := filepath.Join(m.config.Deploy.WorkspaceDir, "scripts", "temp")
+	if err := os.MkdirAll(scriptDir, 0755); err != nil {
+		return "", fmt.Errorf("创建脚本目录失败: %w", err)
+	}
+
+	// 创建临时文件
+	tempFile, err := os.CreateTemp(scriptDir, "script_*"+shell.ext)
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer tempFile.Close()
+
+	// 写入脚本内容
+	if shell.wrap != nil {
+		script = shell.wrap(script)
+	}
+	if _, err := tempFile.WriteString(script); err != nil {
+		return "", fmt.Errorf("写入脚本内容失败: %w", err)
+	}
+
+	// 设置执行权限
+	if err := os.Chmod(tempFile.Name(), 0755); err != nil {
+		return "", fmt.Errorf("设置执行权限失败: %w", err)
+	}
+
+	return tempFile.Name(), nil
+}
+
+// ValidateScript 验证脚本语法
+func (m *Manager) ValidateScript(script string, scriptType string) error {
+	switch scriptType {
+	case models.ScriptTypeBash:
+		return m.validateBashScript(script)
+	case models.ScriptTypePowerShell:
+		return m.validatePowerShellScript(script)
+	case models.ScriptTypePython:
+		return m.validatePythonScript(script)
+	default:
+		return fmt.Errorf("不支持的脚本类型: %s", scriptType)
+	}
+}
+
+// validateBashScript 验证Bash脚本
+func (m *Manager) validateBashScript(script string) error {
+	// 创建临时脚本文件
+	scriptFile, err := m.createTempScript(script, shells[ShellBash])
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scriptFile)
+
+	// 使用bash -n检查语法
+	cmd := exec.Command("bash", "-n", scriptFile)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Bash脚本语法错误: %w", err)
+	}
+
+	return nil
+}
+
+// validatePowerShellScript 验证PowerShell脚本
+func (m *Manager) validatePowerShellScript(script string) error {
+	// 创建临时脚本文件
+	scriptFile, err := m.createTempScript(script, shells[ShellPowerShell])
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scriptFile)
+
+	// 使用PowerShell检查语法
+	powershell := ShellPowerShell
+	if _, ok := m.shells[powershell]; !ok {
+		powershell = ShellPwsh
+	}
+	cmd := exec.Command(powershell, "-NoProfile", "-Command", fmt.Sprintf("Get-Command -Syntax (Get-Content '%s' -Raw)", scriptFile))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("PowerShell脚本语法错误: %w", err)
+	}
+
+	return nil
+}
+
+// validatePythonScript 验证Python脚本
+func (m *Manager) validatePythonScript(script string) error {
+	// 创建临时脚本文件
+	scriptFile, err := m.createTempScript(script, shells[ShellPython])
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scriptFile)
+
+	// 使用python -m py_compile检查语法
+	python := "python"
+	if path, ok := m.shells[ShellPython]; ok {
+		python = path
+	}
+	cmd := exec.Command(python, "-m", "py_compile", scriptFile)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Python脚本语法错误: %w", err)
+	}
+
+	return nil
+}
+
+// GetBuiltinScripts 获取内置脚本模板
+func (m *Manager) GetBuiltinScripts() map[string]string {
+	return map[string]string{
+		"node_build": `#!/bin/bash
+# Node.js 项目构建脚本
+set -e
+
+echo "开始构建 Node.js 项目..."
+
+# 安装依赖
+if [ -f "package.json" ]; then
+    echo "安装 npm 依赖..."
+    npm install
+fi
+
+# 运行构建
+if [ -f "package.json" ] && npm run | grep -q "build"; then
+    echo "运行构建命令..."
+    npm run build
+fi
+
+echo "Node.js 项目构建完成"
+`,
+		"go_build": `#!/bin/bash
+# Go 项目构建脚本
+set -e
+
+echo "开始构建 Go 项目..."
+
+# 下载依赖
+echo "下载 Go 模块依赖..."
+go mod download
+
+# 运行测试
+echo "运行测试..."
+go test ./...
+
+# 构建项目
+echo "构建项目..."
+go build -o app ./cmd/server
+
+echo "Go 项目构建完成"
+`,
+		"docker_build": `#!/bin/bash
+# Docker 构建脚本
+set -e
+
+echo "开始 Docker 构建..."
+
+# 构建镜像
+if [ -f "Dockerfile" ]; then
+    echo "构建 Docker 镜像..."
+    docker build -t $PROJECT_NAME:$BUILD_VERSION .
+    
+    echo "Docker 镜像构建完成: $PROJECT_NAME:$BUILD_VERSION"
+else
+    echo "未找到 Dockerfile"
+    exit 1
+fi
+`,
+		"deploy_script": `#!/bin/bash
+# 部署脚本
+set -e
+
+echo "开始部署应用..."
+
+# 停止旧服务
+echo "停止旧服务..."
+sudo systemctl stop $SERVICE_NAME || true
+
+# 备份旧版本
+if [ -f "$DEPLOY_PATH/$APP_NAME" ]; then
+    echo "备份旧版本..."
+    sudo cp "$DEPLOY_PATH/$APP_NAME" "$DEPLOY_PATH/$APP_NAME.backup.$(date +%Y%m%d_%H%M%S)"
+fi
+
+# 复制新版本
+echo "复制新版本..."
+sudo cp ./app "$DEPLOY_PATH/$APP_NAME"
+sudo chmod +x "$DEPLOY_PATH/$APP_NAME"
+
+# 启动新服务
+echo "启动新服务..."
+sudo systemctl start $SERVICE_NAME
+sudo systemctl enable $SERVICE_NAME
+
+echo "部署完成"
+`,
+	}
+}
+
+// ExecuteBuiltinScript 执行内置脚本
+func (m *Manager) ExecuteBuiltinScript(ctx context.Context, scriptName string, opts ExecuteOptions) (*ExecuteResult, error) {
+	builtinScripts := m.GetBuiltinScripts()
+	script, exists := builtinScripts[scriptName]
+	if !exists {
+		return nil, fmt.Errorf("内置脚本不存在: %s", scriptName)
+	}
+
+	return m.Execute(ctx, script, opts)
+}
+
+// StreamExecute 流式执行脚本
+func (m *Manager) StreamExecute(ctx context.Context, script string, opts ExecuteOptions, output io.Writer) error {
+	release, err := m.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	shell, shellPath, err := m.resolveShell(opts.Shell)
+	if err != nil {
+		return err
+	}
+
+	// 创建临时脚本文件
+	scriptFile, err := m.createTempScript(script, shell)
+	if err != nil {
+		return fmt.Errorf("创建临时脚本失败: %w", err)
+	}
+	defer os.Remove(scriptFile)
+	if err := m.prepareRunAs(scriptFile, opts); err != nil {
+		return err
+	}
+
+	// 设置超时上下文
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// 设置输出
+	cmd := m.command(ctx, shellPath, shell, scriptFile, opts)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	// 执行命令
+	return cmd.Run()
 }
\ No newline at end of file
@@ -0,0 +1,122 @@
+package scripts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+)
+
+// stressLines 压力测试脚本输出的行数，每行约 110 字节，共约 110MB
+const stressLines = 1000000
+
+// maxHeapGrowth 回调阻塞期间允许的存活堆内存增长，远小于脚本的全部输出
+const maxHeapGrowth = 48 << 20
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	return NewManager(&config.Config{Deploy: config.DeployConfig{
+		WorkspaceDir:        t.TempDir(),
+		MaxScriptExecutions: 2,
+	}})
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// TestExecuteMillionLines 日志回调一直阻塞到脚本输出 100 万行并退出：子进程不因管道写满而停住，
+// 缓冲的内存有上限；回调恢复后按输出顺序收到每一行，收到的与丢弃的行数之和等于输出的行数
+func TestExecuteMillionLines(t *testing.T) {
+	for _, policy := range []string{BackpressureDrop, BackpressureCoalesce} {
+		t.Run(policy, func(t *testing.T) {
+			m := newTestManager(t)
+			done := filepath.Join(t.TempDir(), "done")
+			script := fmt.Sprintf("seq -f '%%.0f %s' 1 %d\ntouch %s\n", strings.Repeat("x", 100), stressLines, done)
+
+			base := heapAlloc()
+			var growth uint64
+			blocked := true
+			var delivered, noticed int64
+			last := 0
+			var orderErr string
+			callback := func(text string) {
+				if blocked {
+					deadline := time.Now().Add(time.Minute)
+					for {
+						if _, err := os.Stat(done); err == nil {
+							break
+						}
+						if time.Now().After(deadline) {
+							orderErr = "回调阻塞时脚本没有执行完，子进程被管道阻塞"
+							break
+						}
+						time.Sleep(10 * time.Millisecond)
+					}
+					if heap := heapAlloc(); heap > base {
+						growth = heap - base
+					}
+					blocked = false
+				}
+
+				for _, line := range strings.Split(text, "\n") {
+					var n int64
+					if _, err := fmt.Sscanf(line, "... 输出过快，日志回调跟不上，丢弃了 %d 行 ...", &n); err == nil {
+						noticed += n
+						continue
+					}
+					number, _, _ := strings.Cut(line, " ")
+					i, err := strconv.Atoi(number)
+					if err != nil || i <= last {
+						if orderErr == "" {
+							orderErr = fmt.Sprintf("收到 %q，前一行为第 %d 行，顺序错乱或重复", line, last)
+						}
+						continue
+					}
+					last = i
+					delivered++
+				}
+			}
+
+			result, err := m.Execute(context.Background(), script, ExecuteOptions{
+				LogCallback:  callback,
+				Backpressure: policy,
+				MaxLogBytes:  -1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if orderErr != "" {
+				t.Fatal(orderErr)
+			}
+			if result.ExitCode != 0 {
+				t.Fatalf("脚本退出码 %d: %s", result.ExitCode, result.Error)
+			}
+			if growth > maxHeapGrowth {
+				t.Errorf("回调阻塞期间存活堆内存增长 %d MB，超过 %d MB", growth>>20, maxHeapGrowth>>20)
+			}
+			if delivered+result.DroppedLines != stressLines || noticed != result.DroppedLines {
+				t.Errorf("收到 %d 行、丢弃 %d 行（提示 %d 行），合计应为 %d", delivered, result.DroppedLines, noticed, stressLines)
+			}
+			if result.DroppedLines == 0 {
+				t.Error("回调阻塞期间缓冲已满，应丢弃并计数")
+			}
+			if len(result.Output) > maxRetainedOutput+1024 {
+				t.Errorf("执行结果保留了 %d 字节输出，超过上限 %d", len(result.Output), maxRetainedOutput)
+			}
+			if stats := m.Stats(); stats.ActiveExecutions != 0 || stats.ReaderGoroutines != 0 || stats.DeliveryGoroutines != 0 {
+				t.Errorf("执行结束后仍有名额或协程未释放: %+v", stats)
+			}
+		})
+	}
+}
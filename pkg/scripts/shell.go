@@ -0,0 +1,174 @@
+package scripts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// 步骤可选的解释器
+const (
+	ShellBash       = "bash"
+	ShellSh         = "sh"
+	ShellPwsh       = "pwsh"
+	ShellPowerShell = "powershell"
+	ShellCmd        = "cmd"
+	ShellPython     = "python"
+)
+
+var (
+	// ErrUnknownShell 解释器名称不受支持
+	ErrUnknownShell = errors.New("不支持的解释器")
+	// ErrShellUnavailable 执行主机上没有解释器的可执行文件
+	ErrShellUnavailable = errors.New("解释器在执行主机上不可用")
+)
+
+// psExitCode PowerShell 以 -File 执行时，脚本正常结束的退出码总是 0，外部命令的失败只记录在 $LASTEXITCODE；
+// 结束时以 $LASTEXITCODE 退出，与 bash 以最后一个命令的退出码结束一致。脚本中的 exit 先于此处生效
+const psExitCode = "\nif ((Test-Path -LiteralPath variable:\\LASTEXITCODE)) { exit $LASTEXITCODE }\n"
+
+// utf8BOM Windows PowerShell 读取没有 BOM 的脚本时按系统代码页解码，非 ASCII 内容会乱码
+const utf8BOM = "\ufeff"
+
+// shellSpec 解释器的脚本扩展名、可执行文件与命令行参数
+type shellSpec struct {
+	ext      string
+	binaries []string // 按顺序查找，使用第一个存在的
+	args     func(scriptFile string) []string
+	wrap     func(script string) string // 写入临时脚本前的处理，为 nil 时原样写入
+}
+
+var shells = map[string]shellSpec{
+	ShellBash:       {ext: ".sh", binaries: []string{"bash"}, args: fileArg},
+	ShellSh:         {ext: ".sh", binaries: []string{"sh"}, args: fileArg},
+	ShellPwsh:       {ext: ".ps1", binaries: []string{"pwsh"}, args: powerShellArgs, wrap: wrapPowerShell},
+	ShellPowerShell: {ext: ".ps1", binaries: []string{"powershell"}, args: powerShellArgs, wrap: wrapPowerShell},
+	ShellCmd: {ext: ".cmd", binaries: []string{"cmd"}, args: func(scriptFile string) []string {
+		// CALL 使脚本的 errorlevel 成为 cmd 的退出码
+		return []string{"/D", "/E:ON", "/V:OFF", "/S", "/C", "CALL", scriptFile}
+	}},
+	ShellPython: {ext: ".py", binaries: []string{"python3", "python"}, args: func(scriptFile string) []string {
+		// 不缓冲输出，日志与其他解释器一样逐行出现
+		return []string{"-u", scriptFile}
+	}},
+}
+
+func fileArg(scriptFile string) []string {
+	return []string{scriptFile}
+}
+
+func powerShellArgs(scriptFile string) []string {
+	return []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", scriptFile}
+}
+
+func wrapPowerShell(script string) string {
+	return utf8BOM + script + psExitCode
+}
+
+// IsShell 是否为支持的解释器名称
+func IsShell(name string) bool {
+	_, ok := shells[name]
+	return ok
+}
+
+// ShellNames 支持的解释器名称，按名称排序
+func ShellNames() []string {
+	names := make([]string, 0, len(shells))
+	for name := range shells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ShellBinaries 解释器的可执行文件名，用于提示缺少的文件
+func ShellBinaries(name string) string {
+	return strings.Join(shells[name].binaries, " 或 ")
+}
+
+// ShellInfo 解释器在本机的可用情况
+type ShellInfo struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Path      string `json:"path,omitempty"` // 找到的可执行文件
+	Binaries  string `json:"binaries"`       // 查找的可执行文件名
+}
+
+// probeShells 在 PATH 中查找各解释器的可执行文件，创建管理器时执行一次
+func probeShells() map[string]string {
+	found := make(map[string]string, len(shells))
+	for name, spec := range shells {
+		for _, binary := range spec.binaries {
+			if path, err := exec.LookPath(binary); err == nil {
+				found[name] = path
+				break
+			}
+		}
+	}
+	return found
+}
+
+// Shells 各解释器在本机的可用情况，按名称排序
+func (m *Manager) Shells() []ShellInfo {
+	infos := make([]ShellInfo, 0, len(shells))
+	for _, name := range ShellNames() {
+		path, ok := m.shells[name]
+		infos = append(infos, ShellInfo{Name: name, Available: ok, Path: path, Binaries: ShellBinaries(name)})
+	}
+	return infos
+}
+
+// AvailableShells 本机可用的解释器，按名称排序
+func (m *Manager) AvailableShells() []string {
+	var names []string
+	for _, name := range ShellNames() {
+		if _, ok := m.shells[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DefaultShell 未指定解释器时使用的解释器：Windows 上为 powershell，没有时为 pwsh；其他系统为 bash
+func (m *Manager) DefaultShell() string {
+	if strings.Contains(strings.ToLower(os.Getenv("OS")), "windows") {
+		if _, ok := m.shells[ShellPowerShell]; !ok {
+			if _, ok := m.shells[ShellPwsh]; ok {
+				return ShellPwsh
+			}
+		}
+		return ShellPowerShell
+	}
+	return ShellBash
+}
+
+// CheckShell 检查本机可以使用该解释器，name 为空时检查默认解释器
+func (m *Manager) CheckShell(name string) error {
+	_, _, err := m.resolveShell(name)
+	return err
+}
+
+// ShellPath 解释器在本机的可执行文件，如调试终端使用的 bash
+func (m *Manager) ShellPath(name string) (string, error) {
+	_, path, err := m.resolveShell(name)
+	return path, err
+}
+
+// resolveShell 查找解释器的配置与本机的可执行文件
+func (m *Manager) resolveShell(name string) (shellSpec, string, error) {
+	if name == "" {
+		name = m.DefaultShell()
+	}
+	spec, ok := shells[name]
+	if !ok {
+		return shellSpec{}, "", fmt.Errorf("%w: %s", ErrUnknownShell, name)
+	}
+	path, ok := m.shells[name]
+	if !ok {
+		return shellSpec{}, "", fmt.Errorf("%w: %s（未找到 %s）", ErrShellUnavailable, name, ShellBinaries(name))
+	}
+	return spec, path, nil
+}
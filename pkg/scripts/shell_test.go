@@ -0,0 +1,92 @@
+package scripts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// shellScripts 各解释器下输出一行到 stdout、一行到 stderr 后以退出码 %d 退出的脚本
+var shellScripts = map[string]string{
+	ShellBash:       "echo 第一行\necho 第二行 >&2\nexit %d\n",
+	ShellSh:         "echo 第一行\necho 第二行 >&2\nexit %d\n",
+	ShellPwsh:       "Write-Output '第一行'\n[Console]::Error.WriteLine('第二行')\nexit %d\n",
+	ShellPowerShell: "Write-Output '第一行'\n[Console]::Error.WriteLine('第二行')\nexit %d\n",
+	ShellCmd:        "@echo off\r\necho 第一行\r\necho 第二行 1>&2\r\nexit /B %d\r\n",
+	ShellPython:     "import sys\nprint('第一行')\nprint('第二行', file=sys.stderr)\nsys.exit(%d)\n",
+}
+
+// TestExecuteAcrossShells 本机可用的每种解释器执行同一个简单脚本：退出码原样传递，stdout 与 stderr 的行
+// 按相同的方式交给日志回调；本机缺少的解释器跳过
+func TestExecuteAcrossShells(t *testing.T) {
+	m := newTestManager(t)
+	for _, shell := range ShellNames() {
+		t.Run(shell, func(t *testing.T) {
+			if err := m.CheckShell(shell); err != nil {
+				t.Skip(err)
+			}
+			for _, code := range []int{0, 3} {
+				var mu sync.Mutex
+				var lines []string
+				script := fmt.Sprintf(shellScripts[shell], code)
+				result, err := m.Execute(context.Background(), script, ExecuteOptions{
+					Shell: shell,
+					LogCallback: func(text string) {
+						mu.Lock()
+						defer mu.Unlock()
+						lines = append(lines, strings.Split(text, "\n")...)
+					},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.ExitCode != code {
+					t.Errorf("退出码为 %d，应为 %d: %s", result.ExitCode, code, result.Error)
+				}
+				// stdout 与 stderr 分别读取，两者之间的先后不确定
+				mu.Lock()
+				got := append([]string(nil), lines...)
+				mu.Unlock()
+				if len(got) == 2 && got[0] != "第一行" {
+					got[0], got[1] = got[1], got[0]
+				}
+				if want := []string{"第一行", "ERROR: 第二行"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("日志回调收到 %q，应为 %q", got, want)
+				}
+				if strings.TrimSpace(result.Output) != "第一行" || strings.TrimSpace(result.Error) != "第二行" {
+					t.Errorf("执行结果的输出为 %q、错误输出为 %q", result.Output, result.Error)
+				}
+			}
+		})
+	}
+}
+
+// TestCheckShell 未知的解释器返回 ErrUnknownShell；本机缺少的解释器返回 ErrShellUnavailable，错误中列出缺少的可执行文件，
+// 执行时不启动进程
+func TestCheckShell(t *testing.T) {
+	m := newTestManager(t)
+	m.shells = map[string]string{ShellBash: "/bin/bash"}
+
+	if err := m.CheckShell("zsh"); !errors.Is(err, ErrUnknownShell) {
+		t.Errorf("未知的解释器返回 %v，应为 ErrUnknownShell", err)
+	}
+	for shell, binary := range map[string]string{ShellPwsh: "pwsh", ShellPython: "python3 或 python"} {
+		err := m.CheckShell(shell)
+		if !errors.Is(err, ErrShellUnavailable) || !strings.Contains(err.Error(), "未找到 "+binary) {
+			t.Errorf("缺少 %s 时返回 %v，应为 ErrShellUnavailable 并列出 %s", shell, err, binary)
+		}
+	}
+	if _, err := m.Execute(context.Background(), "exit 0", ExecuteOptions{Shell: ShellCmd}); !errors.Is(err, ErrShellUnavailable) {
+		t.Errorf("以缺少的解释器执行返回 %v，应为 ErrShellUnavailable", err)
+	}
+	if infos := m.Shells(); len(infos) != len(shells) || infos[0].Name != ShellBash || !infos[0].Available || infos[1].Available {
+		t.Errorf("解释器可用情况为 %+v", infos)
+	}
+	if got := m.AvailableShells(); !reflect.DeepEqual(got, []string{ShellBash}) {
+		t.Errorf("可用的解释器为 %v，应为 [bash]", got)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package service
+
+// Run 在前台运行，停止由进程信号处理
+func Run(name string, run func(stop <-chan struct{}) error) error {
+	return run(nil)
+}
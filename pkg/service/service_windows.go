@@ -0,0 +1,70 @@
+//go:build windows
+
+package service
+
+import (
+	"log"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// Run 由Windows服务管理器启动时以服务方式运行，停止请求通过 stop 触发与 SIGTERM 相同的优雅关闭
+func Run(name string, run func(stop <-chan struct{}) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return run(nil)
+	}
+
+	h := &handler{run: run}
+	if err := svc.Run(name, h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+// handler Windows服务控制处理器
+type handler struct {
+	run func(stop <-chan struct{}) error
+	err error
+}
+
+// Execute 处理服务管理器的控制请求
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+
+	status <- svc.Status{State: svc.StartPending}
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(stop)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for {
+		select {
+		case err := <-done:
+			h.err = err
+			status <- svc.Status{State: svc.StopPending}
+			if err != nil {
+				return true, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Println("收到服务停止请求...")
+				status <- svc.Status{State: svc.StopPending}
+				close(stop)
+				h.err = <-done
+				return false, 0
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// bastionIdleTimeout 跳板机连接在最后一个经由它的连接关闭后保留的时间，供随后的操作复用
+const bastionIdleTimeout = time.Minute
+
+var (
+	// ErrBastionConnect 无法连接跳板机
+	ErrBastionConnect = errors.New("连接跳板机失败")
+	// ErrBastionTarget 已连接跳板机，但无法从跳板机连接目标主机
+	ErrBastionTarget = errors.New("从跳板机连接目标主机失败")
+)
+
+// bastions 进程内共享的跳板机连接，同一跳板机后的多个目标主机复用一个连接
+var bastions = &bastionPool{conns: make(map[string]*pooledBastion)}
+
+// WithBastion 返回经指定跳板机连接目标主机的客户端；未配置跳板机时使用密钥的默认跳板机
+func (c *Client) WithBastion(bastion models.BastionConfig) *Client {
+	return &Client{config: c.config, bastion: bastion}
+}
+
+// dial 使用密钥建立到目标主机的SSH连接：配置了跳板机时先连接跳板机，再经跳板机连接目标主机。
+// ctx 结束时中断到目标主机的连接与握手；共享的跳板机连接不随单次操作取消
+func (c *Client) dial(ctx context.Context, sshKey *models.SSHKey, host string, port int, username string) (*ssh.Client, error) {
+	bastion := c.bastion
+	if !bastion.Enabled() {
+		bastion = sshKey.BastionConfig
+	}
+	if !bastion.Enabled() {
+		return c.dialDirect(ctx, sshKey, host, port, username)
+	}
+	return c.dialViaBastion(ctx, bastion, sshKey, host, port, username)
+}
+
+// dialDirect 直接连接目标主机
+func (c *Client) dialDirect(ctx context.Context, sshKey *models.SSHKey, host string, port int, username string) (*ssh.Client, error) {
+	config, err := c.clientConfig(sshKey, username)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := fmt.Sprintf("%s:%d", host, port)
+	var dialer net.Dialer
+	client, err := handshake(ctx, func(ctx context.Context) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}, addr, config, config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("SSH连接失败: %w", err)
+	}
+	return client, nil
+}
+
+// dialViaBastion 经跳板机连接目标主机。两段连接分别校验主机密钥、分别计算超时；
+// 跳板机连接按跳板机地址与密钥共享，经由它的连接全部关闭后保留 bastionIdleTimeout
+func (c *Client) dialViaBastion(ctx context.Context, bastion models.BastionConfig, sshKey *models.SSHKey, host string, port int, username string) (*ssh.Client, error) {
+	bastionKey := sshKey
+	if bastion.BastionKeyID != nil && *bastion.BastionKeyID != sshKey.ID {
+		var key models.SSHKey
+		if err := models.WithPrivateKey(database.DB).First(&key, *bastion.BastionKeyID).Error; err != nil {
+			return nil, fmt.Errorf("%w: 跳板机使用的SSH密钥不存在", ErrBastionConnect)
+		}
+		if err := checkRemoteUsable(&key); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBastionConnect, err)
+		}
+		database.TouchSSHKey(key.ID)
+		bastionKey = &key
+	}
+	bastionPort := bastion.BastionPort
+	if bastionPort == 0 {
+		bastionPort = 22
+	}
+	bastionUser := bastion.BastionUsername
+	if bastionUser == "" {
+		bastionUser = username
+	}
+	bastionAddr := fmt.Sprintf("%s:%d", bastion.BastionHost, bastionPort)
+	targetAddr := fmt.Sprintf("%s:%d", host, port)
+	timeout := time.Duration(c.config.SSH.Timeout) * time.Second
+
+	poolKey := fmt.Sprintf("%s@%s#%d", bastionUser, bastionAddr, bastionKey.ID)
+	entry, err := bastions.acquire(poolKey, func() (*ssh.Client, error) {
+		config, err := c.clientConfig(bastionKey, bastionUser)
+		if err != nil {
+			return nil, err
+		}
+		var dialer net.Dialer
+		return handshake(context.Background(), func(ctx context.Context) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", bastionAddr)
+		}, bastionAddr, config, timeout)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w（%s）: %w", ErrBastionConnect, bastionAddr, err)
+	}
+
+	config, err := c.clientConfig(sshKey, username)
+	if err != nil {
+		bastions.release(poolKey, entry)
+		return nil, err
+	}
+	client, err := handshake(ctx, func(ctx context.Context) (net.Conn, error) {
+		return entry.client.DialContext(ctx, "tcp", targetAddr)
+	}, targetAddr, config, timeout)
+	if err != nil {
+		bastions.release(poolKey, entry)
+		return nil, fmt.Errorf("%w（跳板机 %s，目标 %s）: %w", ErrBastionTarget, bastionAddr, targetAddr, err)
+	}
+
+	// 目标连接关闭后释放跳板机连接
+	go func() {
+		client.Wait()
+		bastions.release(poolKey, entry)
+	}()
+	return client, nil
+}
+
+// clientConfig 使用密钥认证的客户端配置
+func (c *Client) clientConfig(sshKey *models.SSHKey, username string) (*ssh.ClientConfig, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(sshKey.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+	hostKeyCallback, err := c.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User: username,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         time.Duration(c.config.SSH.Timeout) * time.Second,
+	}, nil
+}
+
+// hostKeyCallback 主机密钥校验：配置了 known_hosts 文件时按文件校验，否则不校验
+func (c *Client) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if c.config.SSH.KnownHostsFile == "" {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	callback, err := knownhosts.New(c.config.SSH.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 known_hosts 文件失败: %w", err)
+	}
+	return callback, nil
+}
+
+// handshake 建立连接并完成SSH握手，连接与握手合计不超过 timeout，parent 结束时同样中断
+func handshake(parent context.Context, dial func(ctx context.Context) (net.Conn, error), addr string, config *ssh.ClientConfig, timeout time.Duration) (*ssh.Client, error) {
+	ctx := parent
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 经跳板机建立的连接不支持设置截止时间，超时后关闭连接中断握手
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		if parent.Err() != nil {
+			return nil, parent.Err()
+		}
+		return nil, fmt.Errorf("SSH握手超时（%s）", timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// pooledBastion 共享的跳板机连接
+type pooledBastion struct {
+	ready  chan struct{} // 连接建立完成（成功或失败）后关闭
+	client *ssh.Client
+	err    error
+	refs   int
+	idle   *time.Timer
+}
+
+// bastionPool 按跳板机地址、用户与密钥共享的跳板机连接
+type bastionPool struct {
+	mu    sync.Mutex
+	conns map[string]*pooledBastion
+}
+
+// acquire 获取跳板机连接，没有可用连接时调用 connect 建立；同时获取同一跳板机的调用共用一次连接
+func (p *bastionPool) acquire(key string, connect func() (*ssh.Client, error)) (*pooledBastion, error) {
+	p.mu.Lock()
+	entry, ok := p.conns[key]
+	if ok {
+		entry.refs++
+		if entry.idle != nil {
+			entry.idle.Stop()
+			entry.idle = nil
+		}
+		p.mu.Unlock()
+
+		<-entry.ready
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return entry, nil
+	}
+
+	entry = &pooledBastion{ready: make(chan struct{}), refs: 1}
+	p.conns[key] = entry
+	p.mu.Unlock()
+
+	entry.client, entry.err = connect()
+	close(entry.ready)
+	if entry.err != nil {
+		p.remove(key, entry)
+		return nil, entry.err
+	}
+
+	// 跳板机连接断开后不再复用
+	go func() {
+		entry.client.Wait()
+		p.remove(key, entry)
+	}()
+	return entry, nil
+}
+
+// release 释放跳板机连接，没有连接经由它时在 bastionIdleTimeout 后关闭
+func (p *bastionPool) release(key string, entry *pooledBastion) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry.refs--
+	if entry.refs > 0 || p.conns[key] != entry {
+		return
+	}
+	entry.idle = time.AfterFunc(bastionIdleTimeout, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if entry.refs == 0 && p.conns[key] == entry {
+			delete(p.conns, key)
+			entry.client.Close()
+		}
+	})
+}
+
+// remove 从池中移除已失效的跳板机连接
+func (p *bastionPool) remove(key string, entry *pooledBastion) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[key] == entry {
+		delete(p.conns, key)
+	}
+}
@@ -0,0 +1,82 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// hashBatchSize 单条 sha256sum 命令携带的文件数，避免命令行过长
+const hashBatchSize = 200
+
+// RemoteHashes 只读地计算远程文件的sha256，不存在或不可读的文件不出现在结果中；
+// ctx 到期时关闭连接并返回错误，保证检查时长有上限
+func (c *Client) RemoteHashes(ctx context.Context, sshKey *models.SSHKey, host string, port int, username string, paths []string) (map[string]string, error) {
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return nil, err
+	}
+
+	client, err := c.dial(ctx, sshKey, host, port, username)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	database.TouchSSHKey(sshKey.ID)
+
+	// 到期后关闭连接，使阻塞中的会话立即返回
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-done:
+		}
+	}()
+
+	hashes := make(map[string]string, len(paths))
+	for start := 0; start < len(paths); start += hashBatchSize {
+		end := start + hashBatchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+
+		quoted := make([]string, 0, end-start)
+		for _, p := range paths[start:end] {
+			quoted = append(quoted, shellQuote(p))
+		}
+
+		session, err := client.NewSession()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("远程检查超时: %w", ctx.Err())
+			}
+			return nil, fmt.Errorf("创建SSH会话失败: %w", err)
+		}
+
+		var stdout bytes.Buffer
+		session.Stdout = &stdout
+		// 部分文件不存在时 sha256sum 以非零状态退出，已输出的结果仍然有效
+		session.Run("sha256sum -- " + strings.Join(quoted, " ") + " 2>/dev/null")
+		session.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("远程检查超时: %w", ctx.Err())
+		}
+
+		scanner := bufio.NewScanner(&stdout)
+		for scanner.Scan() {
+			// 输出格式：<hash>  <path>
+			hash, name, ok := strings.Cut(scanner.Text(), "  ")
+			if ok {
+				hashes[name] = hash
+			}
+		}
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,146 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// binaryNameRe 允许探测的命令名，避免拼入远程脚本时出现注入
+var binaryNameRe = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// ProbeRequest 部署前探测的内容
+type ProbeRequest struct {
+	RemoteDir string   // 部署目录，不存在时检查最近的已存在上级目录
+	Binaries  []string // 需要存在于 PATH 中的命令
+	Sudo      bool     // 是否检查免密 sudo
+}
+
+// ProbeResult 部署前探测结果
+type ProbeResult struct {
+	ExistingDir    string          // RemoteDir 或其最近的已存在上级目录
+	Writable       bool            // ExistingDir 对登录用户可写
+	AvailableBytes int64           // ExistingDir 所在文件系统的可用空间，无法获取时为 -1
+	ClockSkew      time.Duration   // 远程时钟减本地时钟，精度为秒
+	Binaries       map[string]bool // 每个命令是否存在
+	Sudo           bool            // 免密 sudo 是否可用（仅在请求时检查）
+}
+
+// Probe 只读地探测部署目标：目录可写性、可用磁盘空间、命令是否存在与时钟偏差，不创建任何文件；
+// 连接失败时返回错误，ctx 到期时关闭连接
+func (c *Client) Probe(ctx context.Context, sshKey *models.SSHKey, host string, port int, username string, req ProbeRequest) (*ProbeResult, error) {
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return nil, err
+	}
+	for _, name := range req.Binaries {
+		if !binaryNameRe.MatchString(name) {
+			return nil, fmt.Errorf("无效的命令名: %s", name)
+		}
+	}
+
+	client, err := c.dial(ctx, sshKey, host, port, username)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	database.TouchSSHKey(sshKey.ID)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-done:
+		}
+	}()
+
+	session, err := client.NewSession()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("远程检查超时: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	sentAt := time.Now()
+	if err := session.Run(probeScript(req)); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("远程检查超时: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("执行远程检查失败: %w", err)
+	}
+	// 以请求往返的中点作为远程时间对应的本地时间
+	localAt := sentAt.Add(time.Since(sentAt) / 2)
+
+	return parseProbe(stdout.Bytes(), localAt), nil
+}
+
+// probeScript 生成只读的探测脚本，每行输出 key=value
+func probeScript(req ProbeRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "d=%s\n", shellQuote(req.RemoteDir))
+	b.WriteString(`while [ ! -d "$d" ]; do d=$(dirname -- "$d"); done
+echo "dir=$d"
+if [ -w "$d" ]; then echo "writable=1"; else echo "writable=0"; fi
+echo "avail_kb=$(df -Pk -- "$d" 2>/dev/null | awk 'NR==2 {print $4}')"
+echo "clock=$(date +%s)"
+`)
+	for _, name := range req.Binaries {
+		fmt.Fprintf(&b, "if command -v %s >/dev/null 2>&1; then echo 'bin.%s=1'; else echo 'bin.%s=0'; fi\n", name, name, name)
+	}
+	if req.Sudo {
+		b.WriteString("if sudo -n true >/dev/null 2>&1; then echo 'sudo=1'; else echo 'sudo=0'; fi\n")
+	}
+	b.WriteString("exit 0\n")
+	return b.String()
+}
+
+// parseProbe 解析探测脚本输出
+func parseProbe(output []byte, localAt time.Time) *ProbeResult {
+	result := &ProbeResult{AvailableBytes: -1, Binaries: make(map[string]bool)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch {
+		case key == "dir":
+			result.ExistingDir = value
+		case key == "writable":
+			result.Writable = value == "1"
+		case key == "avail_kb":
+			if kb, err := strconv.ParseInt(value, 10, 64); err == nil {
+				result.AvailableBytes = kb * 1024
+			}
+		case key == "clock":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				result.ClockSkew = time.Unix(sec, 0).Sub(localAt).Round(time.Second)
+			}
+		case key == "sudo":
+			result.Sudo = value == "1"
+		case strings.HasPrefix(key, "bin."):
+			result.Binaries[strings.TrimPrefix(key, "bin.")] = value == "1"
+		}
+	}
+	return result
+}
+
+// LocalSize 同步目录时需要传输的文件总大小（全量）
+func LocalSize(dir string) (int64, error) {
+	_, total, err := walkLocal(dir)
+	return total, err
+}
@@ -2,6 +2,8 @@ package ssh
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -10,22 +12,17 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"time"
 
 	"flowforge/pkg/config"
+	"flowforge/pkg/database"
 	"flowforge/pkg/models"
 	"golang.org/x/crypto/ssh"
 )
 
 // Client SSH客户端
 type Client struct {
-	config *config.Config
-}
-
-// Manager SSH管理器
-type Manager struct {
-	client *Client
-	config *config.Config
+	config  *config.Config
+	bastion models.BastionConfig // 为空时使用密钥的默认跳板机
 }
 
 // Manager SSH管理器
@@ -54,26 +51,6 @@ func (m *Manager) GetClient() *Client {
 	return m.client
 }
 
-package ssh
-
-import (
-	"bytes"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-	"time"
-
-	"flowforge/pkg/config"
-	"flowforge/pkg/models"
-	"golang.org/x/crypto/ssh"
-)
-
-
 // GenerateKeyPair 生成SSH密钥对
 func (c *Client) GenerateKeyPair(bits int, passphrase string) (string, string, error) {
 	// 生成私钥
@@ -122,35 +99,51 @@ func (c *Client) GenerateKeyPair(bits int, passphrase string) (string, string, e
 	return privateKeyPEM.String(), publicKeyString, nil
 }
 
-// TestConnection 测试SSH连接
-func (c *Client) TestConnection(host string, port int, username string, privateKey string, passphrase string) error {
-	// 解析私钥
-	var signer ssh.Signer
-	var err error
-	if passphrase == "" {
-		signer, err = ssh.ParsePrivateKey([]byte(privateKey))
-	} else {
-		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+// GenerateEd25519KeyPair 生成Ed25519密钥对，返回私钥PEM、授权公钥和指纹
+func (c *Client) GenerateEd25519KeyPair(comment string) (string, string, string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", "", fmt.Errorf("生成Ed25519密钥对失败: %w", err)
 	}
+
+	// 将私钥转换为OpenSSH PEM格式
+	privateKeyBlock, err := ssh.MarshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return "", "", "", fmt.Errorf("编码私钥失败: %w", err)
+	}
+
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
 	if err != nil {
-		return fmt.Errorf("解析私钥失败: %w", err)
+		return "", "", "", fmt.Errorf("生成公钥失败: %w", err)
 	}
 
-	// 创建SSH客户端配置
-	config := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 仅用于测试，生产环境应使用已知主机密钥
-		Timeout:         time.Duration(c.config.SSH.Timeout) * time.Second,
+	// 授权密钥格式末尾附带注释，便于在托管平台识别
+	authorizedKey := bytes.TrimSpace(ssh.MarshalAuthorizedKey(sshPublicKey))
+	if comment != "" {
+		authorizedKey = append(authorizedKey, []byte(" "+comment)...)
 	}
 
-	// 连接到SSH服务器
-	addr := fmt.Sprintf("%s:%d", host, port)
-	client, err := ssh.Dial("tcp", addr, config)
+	return string(pem.EncodeToMemory(privateKeyBlock)), string(authorizedKey) + "\n", ssh.FingerprintSHA256(sshPublicKey), nil
+}
+
+// checkRemoteUsable 检查密钥是否可用于远程SSH操作
+func checkRemoteUsable(sshKey *models.SSHKey) error {
+	if sshKey.IsDeployKey() {
+		return fmt.Errorf("部署密钥 %s 仅用于Git只读访问，不能用于远程SSH操作", sshKey.Name)
+	}
+	return nil
+}
+
+// TestConnection 测试SSH连接：经跳板机连接时依次建立两段连接，并在目标主机上执行命令，
+// 失败时错误区分无法连接跳板机（ErrBastionConnect）与无法从跳板机连接目标主机（ErrBastionTarget）
+func (c *Client) TestConnection(sshKey *models.SSHKey, host string, port int, username string) error {
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return err
+	}
+
+	client, err := c.dial(context.Background(), sshKey, host, port, username)
 	if err != nil {
-		return fmt.Errorf("SSH连接失败: %w", err)
+		return err
 	}
 	defer client.Close()
 
@@ -173,6 +166,11 @@ func (c *Client) TestConnection(host string, port int, username string, privateK
 
 // ExecuteCommand 执行SSH命令
 func (c *Client) ExecuteCommand(sshKey *models.SSHKey, host string, port int, username string, command string) (string, error) {
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return "", err
+	}
+	database.TouchSSHKey(sshKey.ID)
+
 	// 创建临时SSH密钥文件
 	keyFile := filepath.Join(c.config.SSH.KeysPath, fmt.Sprintf("key_%d", sshKey.ID))
 	if err := os.WriteFile(keyFile, []byte(sshKey.PrivateKey), 0600); err != nil {
@@ -180,30 +178,10 @@ func (c *Client) ExecuteCommand(sshKey *models.SSHKey, host string, port int, us
 	}
 	defer os.Remove(keyFile) // 使用后删除
 
-	// 解析私钥
-	var signer ssh.Signer
-	var err error
-	// SSHKey模型中没有密码字段，假设私钥没有密码保护
-	signer, err = ssh.ParsePrivateKey([]byte(sshKey.PrivateKey))
-	if err != nil {
-		return "", fmt.Errorf("解析私钥失败: %w", err)
-	}
-
-	// 创建SSH客户端配置
-	config := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 仅用于测试，生产环境应使用已知主机密钥
-		Timeout:         time.Duration(c.config.SSH.Timeout) * time.Second,
-	}
-
-	// 连接到SSH服务器
-	addr := fmt.Sprintf("%s:%d", host, port)
-	client, err := ssh.Dial("tcp", addr, config)
+	// 连接到SSH服务器（SSHKey模型中没有密码字段，假设私钥没有密码保护）
+	client, err := c.dial(context.Background(), sshKey, host, port, username)
 	if err != nil {
-		return "", fmt.Errorf("SSH连接失败: %w", err)
+		return "", err
 	}
 	defer client.Close()
 
@@ -228,30 +206,15 @@ func (c *Client) ExecuteCommand(sshKey *models.SSHKey, host string, port int, us
 
 // CopyFile 通过SCP复制文件
 func (c *Client) CopyFile(sshKey *models.SSHKey, host string, port int, username string, localPath string, remotePath string) error {
-	// 解析私钥
-	var signer ssh.Signer
-	var err error
-	// SSHKey模型中没有密码字段，假设私钥没有密码保护
-	signer, err = ssh.ParsePrivateKey([]byte(sshKey.PrivateKey))
-	if err != nil {
-		return fmt.Errorf("解析私钥失败: %w", err)
-	}
-
-	// 创建SSH客户端配置
-	config := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 仅用于测试，生产环境应使用已知主机密钥
-		Timeout:         time.Duration(c.config.SSH.Timeout) * time.Second,
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return err
 	}
+	database.TouchSSHKey(sshKey.ID)
 
-	// 连接到SSH服务器
-	addr := fmt.Sprintf("%s:%d", host, port)
-	client, err := ssh.Dial("tcp", addr, config)
+	// 连接到SSH服务器（SSHKey模型中没有密码字段，假设私钥没有密码保护）
+	client, err := c.dial(context.Background(), sshKey, host, port, username)
 	if err != nil {
-		return fmt.Errorf("SSH连接失败: %w", err)
+		return err
 	}
 	defer client.Close()
 
@@ -0,0 +1,52 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/models"
+)
+
+// TestDeployKeyRejectedForRemote 部署密钥只用于Git只读访问，远程SSH操作在连接前拒绝
+func TestDeployKeyRejectedForRemote(t *testing.T) {
+	client := NewClient(&config.Config{})
+	key := &models.SSHKey{Name: "flowforge-project-1", Purpose: models.SSHKeyPurposeDeployKey, Status: models.SSHKeyStatusActive}
+	// 目标地址不可达，连接前未被拒绝时返回的是连接错误
+	const host, port, user = "192.0.2.1", 22, "deploy"
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"TestConnection": func() error { return client.TestConnection(key, host, port, user) },
+		"ExecuteCommand": func() error { _, err := client.ExecuteCommand(key, host, port, user, "true"); return err },
+		"CopyFile":       func() error { return client.CopyFile(key, host, port, user, "/dev/null", "/tmp/x") },
+		"ExecuteCommandStream": func() error {
+			_, err := client.ExecuteCommandStream(ctx, key, host, port, user, "true", StreamOptions{})
+			return err
+		},
+		"SyncDir": func() error { _, err := client.SyncDir(ctx, key, host, port, user, SyncOptions{}); return err },
+		"Probe":   func() error { _, err := client.Probe(ctx, key, host, port, user, ProbeRequest{}); return err },
+		"RemoteHashes": func() error {
+			_, err := client.RemoteHashes(ctx, key, host, port, user, []string{"/etc/hostname"})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			err := call()
+			if err == nil || !strings.Contains(err.Error(), "仅用于Git只读访问") {
+				t.Fatalf("部署密钥应被拒绝，实际为 %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckRemoteUsable(t *testing.T) {
+	if err := checkRemoteUsable(&models.SSHKey{Purpose: models.SSHKeyPurposeGeneral}); err != nil {
+		t.Errorf("用户密钥可以用于远程操作，实际为 %v", err)
+	}
+	if err := checkRemoteUsable(&models.SSHKey{Purpose: models.SSHKeyPurposeDeployKey}); err == nil {
+		t.Error("部署密钥不能用于远程操作")
+	}
+}
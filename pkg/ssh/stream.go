@@ -0,0 +1,179 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// defaultMaxLineBytes 单行日志的最大长度，超出部分截断
+	defaultMaxLineBytes = 8 * 1024
+	// defaultMaxOutputBytes 返回的输出副本的最大长度，超出时保留最后的内容
+	defaultMaxOutputBytes = 64 * 1024
+)
+
+// StreamOptions 流式执行远程命令的选项
+type StreamOptions struct {
+	LogCallback    func(string) // 每读到一行输出调用一次，stderr 的行带 "ERROR: " 前缀
+	MaxLineBytes   int          // 单行最大长度，默认 8KB
+	MaxOutputBytes int          // 返回的输出副本最大长度，默认 64KB
+}
+
+// StreamResult 远程命令的执行结果
+type StreamResult struct {
+	ExitCode  int
+	Output    string // stdout 与 stderr 按到达顺序合并后的输出，超出上限时只保留最后部分
+	Truncated bool
+	Duration  time.Duration
+}
+
+// ExecuteCommandStream 执行远程命令并按行实时回调输出，结束后返回退出码与有限长度的输出副本；
+// ctx 结束时终止远程命令并关闭连接
+func (c *Client) ExecuteCommandStream(ctx context.Context, sshKey *models.SSHKey, host string, port int, username string, command string, opts StreamOptions) (*StreamResult, error) {
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return nil, err
+	}
+	if opts.MaxLineBytes <= 0 {
+		opts.MaxLineBytes = defaultMaxLineBytes
+	}
+	if opts.MaxOutputBytes <= 0 {
+		opts.MaxOutputBytes = defaultMaxOutputBytes
+	}
+
+	client, err := c.dial(ctx, sshKey, host, port, username)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	database.TouchSSHKey(sshKey.ID)
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("获取远程输出失败: %w", err)
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("获取远程输出失败: %w", err)
+	}
+
+	out := &streamOutput{callback: opts.LogCallback, limit: opts.MaxOutputBytes}
+	startTime := time.Now()
+	if err := session.Start(command); err != nil {
+		return nil, fmt.Errorf("执行远程命令失败: %w", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Signal(ssh.SIGKILL)
+			client.Close()
+		case <-done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanLines(stdout, opts.MaxLineBytes, func(line string) { out.write(line) })
+	}()
+	go func() {
+		defer wg.Done()
+		scanLines(stderr, opts.MaxLineBytes, func(line string) { out.write("ERROR: " + line) })
+	}()
+	// 先读完输出再等待退出，Wait 会在输出读完前关闭管道
+	wg.Wait()
+	err = session.Wait()
+
+	result := &StreamResult{Duration: time.Since(startTime)}
+	result.Output, result.Truncated = out.result()
+	if ctx.Err() != nil {
+		result.ExitCode = -1
+		return result, fmt.Errorf("远程命令已取消: %w", ctx.Err())
+	}
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitStatus()
+			return result, fmt.Errorf("远程命令退出码 %d", result.ExitCode)
+		}
+		result.ExitCode = -1
+		return result, fmt.Errorf("执行远程命令失败: %w", err)
+	}
+	return result, nil
+}
+
+// streamOutput 合并 stdout 与 stderr：两路输出各自按行读取，整行回调，行之间按到达顺序交错
+type streamOutput struct {
+	mu        sync.Mutex
+	callback  func(string)
+	limit     int
+	buf       []byte
+	truncated bool
+}
+
+// write 回调一行输出并追加到输出副本，超出上限时丢弃最早的内容
+func (o *streamOutput) write(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.callback != nil {
+		o.callback(line)
+	}
+	o.buf = append(o.buf, line...)
+	o.buf = append(o.buf, '\n')
+	if over := len(o.buf) - o.limit; over > 0 {
+		o.buf = append(o.buf[:0], o.buf[over:]...)
+		o.truncated = true
+	}
+}
+
+// result 输出副本
+func (o *streamOutput) result() (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return string(o.buf), o.truncated
+}
+
+// scanLines 按行读取，超过 maxLine 的行截断并丢弃该行剩余部分，读到EOF或出错时结束
+func scanLines(r io.Reader, maxLine int, emit func(string)) {
+	reader := bufio.NewReaderSize(r, maxLine)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		switch {
+		case err == bufio.ErrBufferFull:
+			emit(string(chunk) + " ...(已截断)")
+			// 丢弃该行剩余部分
+			for err == bufio.ErrBufferFull {
+				_, err = reader.ReadSlice('\n')
+			}
+			if err != nil {
+				return
+			}
+		case len(chunk) > 0:
+			emit(strings.TrimRight(string(chunk), "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
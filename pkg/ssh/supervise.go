@@ -0,0 +1,373 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+//go:embed supervisor.sh
+var supervisorScript string
+
+const (
+	// supervisorPath 监督脚本在目标主机上的位置，相对于登录用户的主目录
+	supervisorPath = ".flowforge/supervisor.sh"
+	// supervisorRunsDir 各次远程执行的运行目录
+	supervisorRunsDir = ".flowforge/runs"
+	// supervisorHeartbeat 远程监督进程更新心跳的间隔
+	supervisorHeartbeat = 5 * time.Second
+	// supervisorStale 心跳超过该时间未更新且没有退出码时，视为监督进程已丢失（如主机重启）
+	supervisorStale = 30 * time.Second
+	// supervisorPollBytes 每次轮询读取的最大输出长度
+	supervisorPollBytes = 256 * 1024
+)
+
+// ErrSupervisorLost 远程命令没有留下退出码且监督进程已停止心跳
+var ErrSupervisorLost = errors.New("远程监督进程已丢失")
+
+// SuperviseOptions 由远程监督脚本执行命令的选项
+type SuperviseOptions struct {
+	StreamOptions
+
+	// RunKey 远程运行目录名。同一命令重试时必须相同：命令仍在运行或已成功时只重新连接读取结果，不会重复执行
+	RunKey           string
+	Timeout          time.Duration // 远程执行超时，由监督脚本在目标主机上终止命令，0 表示不限
+	PollInterval     time.Duration // 轮询输出与状态的间隔，默认 2 秒
+	ReconnectTimeout time.Duration // 连接中断后持续重连的时间，默认 5 分钟
+	Progress         func(string)  // 连接中断、重新连接等状态消息
+}
+
+// supervisorRemote 执行监督脚本动作的远程连接
+type supervisorRemote interface {
+	run(command string, stdin io.Reader) ([]byte, error)
+	Close() error
+}
+
+// ExecuteSupervised 通过目标主机上的监督脚本执行命令：SSH 连接中断时命令继续运行，
+// 重新连接后从上次的位置继续读取输出，并以监督脚本记录的退出码作为结果。
+// ctx 结束时通过监督脚本终止命令所在的进程组。stdout 与 stderr 合并为同一输出
+func (c *Client) ExecuteSupervised(ctx context.Context, sshKey *models.SSHKey, host string, port int, username string, command string, opts SuperviseOptions) (*StreamResult, error) {
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return nil, err
+	}
+	database.TouchSSHKey(sshKey.ID)
+
+	connect := func() (supervisorRemote, error) {
+		client, err := c.dial(ctx, sshKey, host, port, username)
+		if err != nil {
+			return nil, err
+		}
+		return &sshRemote{client: client}, nil
+	}
+	return supervise(ctx, connect, command, opts)
+}
+
+// supervision 一次受监督执行的状态
+type supervision struct {
+	ctx     context.Context
+	connect func() (supervisorRemote, error)
+	remote  supervisorRemote
+	opts    SuperviseOptions
+	dir     string
+	out     *streamOutput
+	offset  int64
+	pending []byte
+}
+
+// pollState 一次轮询得到的远程状态
+type pollState struct {
+	missing      bool
+	exitCode     int
+	exited       bool
+	heartbeatAge time.Duration
+	size         int64
+	output       []byte
+}
+
+// supervise 启动或重新连接到远程命令，轮询输出直到得到退出码
+func supervise(ctx context.Context, connect func() (supervisorRemote, error), command string, opts SuperviseOptions) (*StreamResult, error) {
+	if opts.RunKey == "" {
+		return nil, fmt.Errorf("缺少远程运行标识")
+	}
+	if opts.MaxLineBytes <= 0 {
+		opts.MaxLineBytes = defaultMaxLineBytes
+	}
+	if opts.MaxOutputBytes <= 0 {
+		opts.MaxOutputBytes = defaultMaxOutputBytes
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.ReconnectTimeout <= 0 {
+		opts.ReconnectTimeout = 5 * time.Minute
+	}
+
+	s := &supervision{
+		ctx:     ctx,
+		connect: connect,
+		opts:    opts,
+		dir:     supervisorRunsDir + "/" + opts.RunKey,
+		out:     &streamOutput{callback: opts.LogCallback, limit: opts.MaxOutputBytes},
+	}
+	defer s.close()
+
+	startTime := time.Now()
+	if err := s.start(command); err != nil {
+		return nil, err
+	}
+
+	for {
+		state, err := s.poll()
+		if err != nil {
+			if ctx.Err() != nil {
+				return s.cancelled(startTime)
+			}
+			return s.finish(startTime, -1), err
+		}
+		s.consume(state.output)
+
+		switch {
+		case state.missing:
+			return s.finish(startTime, -1), fmt.Errorf("远程运行目录 %s 不存在", s.dir)
+		case state.exited && s.offset >= state.size:
+			s.flushPending()
+			result := s.finish(startTime, state.exitCode)
+			s.clean()
+			switch state.exitCode {
+			case 0:
+				return result, nil
+			case 124:
+				return result, fmt.Errorf("远程命令超时（%s）", opts.Timeout)
+			default:
+				return result, fmt.Errorf("远程命令退出码 %d", state.exitCode)
+			}
+		case !state.exited && state.heartbeatAge > supervisorStale:
+			return s.finish(startTime, -1), fmt.Errorf("%w: %s 未更新心跳", ErrSupervisorLost, state.heartbeatAge)
+		case len(state.output) > 0 && s.offset < state.size:
+			// 还有未读完的输出，立即继续读取
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return s.cancelled(startTime)
+		case <-time.After(opts.PollInterval):
+		}
+	}
+}
+
+// start 安装监督脚本并启动命令；运行目录已存在且命令仍在运行或已成功时只重新连接
+func (s *supervision) start(command string) error {
+	install := fmt.Sprintf("mkdir -p .flowforge && cat > %s.tmp && mv -f %s.tmp %s", supervisorPath, supervisorPath, supervisorPath)
+	if _, err := s.run(install, supervisorScript); err != nil {
+		return fmt.Errorf("安装远程监督脚本失败: %w", err)
+	}
+
+	start := fmt.Sprintf("sh %s start %s %d %d %d", supervisorPath, shellQuote(s.dir),
+		int(s.opts.Timeout.Seconds()), int(supervisorHeartbeat.Seconds()), int(supervisorStale.Seconds()))
+	out, err := s.run(start, command)
+	if err != nil {
+		return fmt.Errorf("启动远程命令失败: %w", err)
+	}
+	if strings.TrimSpace(string(out)) == "attached" {
+		s.progress("远程命令已在运行或已完成，重新连接读取结果，不重复执行")
+	}
+	return nil
+}
+
+// poll 读取远程状态与上次偏移量之后的输出
+func (s *supervision) poll() (*pollState, error) {
+	command := fmt.Sprintf("sh %s poll %s %d %d", supervisorPath, shellQuote(s.dir), s.offset, supervisorPollBytes)
+	out, err := s.run(command, "")
+	if err != nil {
+		return nil, err
+	}
+	return parsePoll(out)
+}
+
+// run 执行监督脚本动作，连接中断时在重连时间内重新连接并重试；
+// 各动作都可以安全重试：start 不会重复启动命令，poll 按偏移量读取
+func (s *supervision) run(command, stdin string) ([]byte, error) {
+	var failedAt time.Time
+	for attempt := 0; ; attempt++ {
+		if s.ctx.Err() != nil {
+			return nil, s.ctx.Err()
+		}
+
+		err := s.ensureRemote()
+		if err == nil {
+			var out []byte
+			out, err = s.remote.run(command, strings.NewReader(stdin))
+			if err == nil {
+				if !failedAt.IsZero() {
+					s.progress(fmt.Sprintf("已重新连接（中断 %s），继续读取远程输出", time.Since(failedAt).Round(time.Second)))
+				}
+				return out, nil
+			}
+			s.remote.Close()
+			s.remote = nil
+		}
+
+		if failedAt.IsZero() {
+			failedAt = time.Now()
+			s.progress(fmt.Sprintf("连接中断，远程命令继续运行，正在重新连接: %v", err))
+		}
+		if time.Since(failedAt) > s.opts.ReconnectTimeout {
+			return nil, fmt.Errorf("连接中断超过 %s: %w", s.opts.ReconnectTimeout, err)
+		}
+
+		backoff := time.Duration(attempt+1) * time.Second
+		if backoff > 10*time.Second {
+			backoff = 10 * time.Second
+		}
+		select {
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// ensureRemote 没有可用连接时建立连接
+func (s *supervision) ensureRemote() error {
+	if s.remote != nil {
+		return nil
+	}
+	remote, err := s.connect()
+	if err != nil {
+		return err
+	}
+	s.remote = remote
+	return nil
+}
+
+// cancelled 运行取消：通过监督脚本终止远程进程组。ctx 已结束，使用独立的短时重试
+func (s *supervision) cancelled(startTime time.Time) (*StreamResult, error) {
+	command := fmt.Sprintf("sh %s cancel %s", supervisorPath, shellQuote(s.dir))
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = s.ensureRemote(); err == nil {
+			if _, err = s.remote.run(command, nil); err == nil {
+				break
+			}
+			s.remote.Close()
+			s.remote = nil
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+	if err != nil {
+		s.progress(fmt.Sprintf("终止远程命令失败: %v", err))
+	}
+
+	s.flushPending()
+	return s.finish(startTime, -1), fmt.Errorf("远程命令已取消: %w", s.ctx.Err())
+}
+
+// clean 删除已读取结果的运行目录，失败时由监督脚本之后清理过期目录
+func (s *supervision) clean() {
+	if s.remote == nil {
+		return
+	}
+	s.remote.run(fmt.Sprintf("sh %s clean %s", supervisorPath, shellQuote(s.dir)), nil)
+}
+
+// close 关闭连接
+func (s *supervision) close() {
+	if s.remote != nil {
+		s.remote.Close()
+		s.remote = nil
+	}
+}
+
+// consume 追加读取到的输出，完整的行回调输出，未结束的行留到下次
+func (s *supervision) consume(data []byte) {
+	s.offset += int64(len(data))
+	s.pending = append(s.pending, data...)
+	for {
+		i := bytes.IndexByte(s.pending, '\n')
+		if i < 0 {
+			break
+		}
+		s.emit(s.pending[:i])
+		s.pending = s.pending[i+1:]
+	}
+	// 超长的行不等待换行，截断后输出
+	if len(s.pending) > s.opts.MaxLineBytes {
+		s.emit(s.pending)
+		s.pending = nil
+	}
+}
+
+// flushPending 输出最后一行没有换行的内容
+func (s *supervision) flushPending() {
+	if len(s.pending) > 0 {
+		s.emit(s.pending)
+		s.pending = nil
+	}
+}
+
+// emit 输出一行，超过单行上限时截断
+func (s *supervision) emit(line []byte) {
+	text := strings.TrimRight(string(line), "\r")
+	if len(text) > s.opts.MaxLineBytes {
+		text = text[:s.opts.MaxLineBytes] + " ...(已截断)"
+	}
+	s.out.write(text)
+}
+
+// finish 生成执行结果
+func (s *supervision) finish(startTime time.Time, exitCode int) *StreamResult {
+	result := &StreamResult{ExitCode: exitCode, Duration: time.Since(startTime)}
+	result.Output, result.Truncated = s.out.result()
+	return result
+}
+
+// progress 报告状态消息
+func (s *supervision) progress(message string) {
+	if s.opts.Progress != nil {
+		s.opts.Progress(message)
+	}
+}
+
+// parsePoll 解析轮询输出：首行为 "<退出码|-> <心跳秒数> <输出大小>" 或 missing，其后为输出内容
+func parsePoll(out []byte) (*pollState, error) {
+	header, body := out, []byte(nil)
+	if i := bytes.IndexByte(out, '\n'); i >= 0 {
+		header, body = out[:i], out[i+1:]
+	}
+
+	fields := strings.Fields(string(header))
+	if len(fields) == 1 && fields[0] == "missing" {
+		return &pollState{missing: true}, nil
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("无法解析远程状态: %q", header)
+	}
+
+	state := &pollState{output: body}
+	if fields[0] != "-" {
+		code, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("无法解析远程退出码: %q", fields[0])
+		}
+		state.exitCode, state.exited = code, true
+	}
+	age, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("无法解析远程心跳: %q", fields[1])
+	}
+	state.heartbeatAge = time.Duration(age) * time.Second
+	if state.size, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return nil, fmt.Errorf("无法解析远程输出大小: %q", fields[2])
+	}
+	return state, nil
+}
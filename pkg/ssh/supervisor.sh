@@ -0,0 +1,123 @@
+#!/bin/sh
+# flowforge 远程命令监督脚本，安装在目标主机的 ~/.flowforge/supervisor.sh。
+# 命令在独立的会话与进程组中运行，SSH 连接断开后继续执行：输出写入 output，
+# 运行期间定期更新 heartbeat，结束后退出码写入 status。服务端重新连接后从上次的
+# 偏移量继续读取 output，并以 status 判断真实结果。
+#
+#   start  <dir> <timeout> <interval> <stale>  从标准输入读取命令并在后台启动
+#   poll   <dir> <offset> <max>                输出 "<status|-> <心跳秒数> <输出大小>"，随后是输出内容
+#   cancel <dir>                               终止命令所在的进程组
+#   clean  <dir>                               删除运行目录
+set -u
+
+action=$1
+dir=$2
+
+# heartbeat_age 距离上次心跳的秒数
+heartbeat_age() {
+	now=$(date +%s)
+	beat=$(cat "$dir/heartbeat" 2>/dev/null || echo 0)
+	echo $((now - beat))
+}
+
+case "$action" in
+start)
+	timeout=$3
+	interval=$4
+	stale=$5
+	base=$(dirname "$dir")
+	mkdir -p "$base" || exit 1
+	# 清理一周前的运行目录
+	find "$base" -mindepth 1 -maxdepth 1 -type d -mtime +7 -exec rm -rf {} + 2>/dev/null
+
+	if [ -d "$dir" ]; then
+		status=$(cat "$dir/status" 2>/dev/null || true)
+		# 仍在运行或已成功结束的命令不重复执行；失败或监督进程已丢失时重新执行
+		if [ "$status" = 0 ] || { [ -z "$status" ] && [ "$(heartbeat_age)" -le "$stale" ]; }; then
+			cat > /dev/null
+			echo attached
+			exit 0
+		fi
+		rm -rf "$dir"
+	fi
+	# mkdir 是原子操作，并发启动时只有一个成功
+	if ! mkdir "$dir" 2>/dev/null; then
+		cat > /dev/null
+		echo attached
+		exit 0
+	fi
+
+	cat > "$dir/command"
+	: > "$dir/output"
+	date +%s > "$dir/heartbeat"
+	# setsid 使监督进程脱离 SSH 会话，连接断开不会终止命令
+	setsid sh "$0" run "$dir" "$timeout" "$interval" < /dev/null > /dev/null 2>&1 &
+	echo started
+	;;
+
+run)
+	timeout=$3
+	interval=$4
+	# 命令在自己的进程组中运行，进程组号即其进程号
+	setsid sh "$dir/command" < /dev/null >> "$dir/output" 2>&1 &
+	child=$!
+	echo "$child" > "$dir/pid"
+
+	(
+		while kill -0 "$child" 2>/dev/null; do
+			date +%s > "$dir/heartbeat"
+			sleep "$interval"
+		done
+	) &
+
+	watchdog=
+	if [ "$timeout" -gt 0 ]; then
+		(
+			sleep "$timeout"
+			if kill -0 "$child" 2>/dev/null; then
+				: > "$dir/timed_out"
+				kill -KILL -"$child" 2>/dev/null
+			fi
+		) &
+		watchdog=$!
+	fi
+
+	wait "$child"
+	code=$?
+	[ -f "$dir/timed_out" ] && code=124
+	[ -f "$dir/cancelled" ] && code=130
+	[ -n "$watchdog" ] && kill "$watchdog" 2>/dev/null
+	date +%s > "$dir/heartbeat"
+	echo "$code" > "$dir/status.tmp" && mv -f "$dir/status.tmp" "$dir/status"
+	;;
+
+poll)
+	offset=$3
+	max=$4
+	if [ ! -d "$dir" ]; then
+		echo missing
+		exit 0
+	fi
+	# 先读取状态再读取输出：状态存在时输出已经完整
+	status=$(cat "$dir/status" 2>/dev/null || echo -)
+	size=$(wc -c < "$dir/output" 2>/dev/null || echo 0)
+	echo "$status $(heartbeat_age) $size"
+	tail -c +$((offset + 1)) "$dir/output" 2>/dev/null | head -c "$max"
+	;;
+
+cancel)
+	: > "$dir/cancelled" 2>/dev/null
+	pid=$(cat "$dir/pid" 2>/dev/null || true)
+	[ -n "$pid" ] && kill -KILL -"$pid" 2>/dev/null
+	exit 0
+	;;
+
+clean)
+	rm -rf "$dir"
+	;;
+
+*)
+	echo "unknown action: $action" >&2
+	exit 2
+	;;
+esac
@@ -0,0 +1,596 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// syncManifestName 远程目录中记录上次同步结果的清单文件
+	syncManifestName = ".flowforge-sync.json"
+	// partSuffix 传输中文件的后缀，校验通过后才重命名为目标文件
+	partSuffix = ".flowforge-part"
+	// defaultProgressInterval 默认的进度汇总间隔
+	defaultProgressInterval = 5 * time.Second
+)
+
+// ErrManifestCorrupted 远程清单内容与其摘要不一致
+var ErrManifestCorrupted = errors.New("同步清单已损坏")
+
+// SyncOptions 目录同步参数
+type SyncOptions struct {
+	LocalDir         string
+	RemoteDir        string
+	Retries          int           // 单个文件传输中断后的重连重试次数
+	ProgressInterval time.Duration // 进度汇总输出间隔
+	Progress         func(message string)
+}
+
+// SyncStats 目录同步结果统计
+type SyncStats struct {
+	Files        int   `json:"files"`
+	Transferred  int   `json:"transferred"`
+	Skipped      int   `json:"skipped"`
+	Resumed      int   `json:"resumed"`
+	BytesSent    int64 `json:"bytes_sent"`
+	FullTransfer bool  `json:"full_transfer"`
+
+	// 本次同步后远程目录的文件清单，用于记录部署清单
+	Manifest *Manifest `json:"-"`
+}
+
+// ManifestEntry 清单中的单个文件
+type ManifestEntry struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // UnixNano
+	SHA256  string `json:"sha256"`
+}
+
+// Manifest 同步清单，Digest 对文件列表计算，用于发现清单损坏
+type Manifest struct {
+	Files  []ManifestEntry `json:"files"`
+	Digest string          `json:"digest"`
+}
+
+// computeDigest 计算文件列表的摘要
+func (m *Manifest) computeDigest() string {
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	hasher := sha256.New()
+	for _, f := range m.Files {
+		fmt.Fprintf(hasher, "%s\x00%d\x00%d\x00%s\n", f.Path, f.Size, f.ModTime, f.SHA256)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// remoteFS 同步所需的远程文件操作
+type remoteFS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	// Size 返回文件大小，文件不存在时返回 -1
+	Size(name string) (int64, error)
+	// WriteAt 将文件截断到 offset 后追加写入 r 的内容
+	WriteAt(name string, offset int64, r io.Reader) error
+	// Hash 计算文件前 length 字节的sha256，length 小于0时计算整个文件
+	Hash(name string, length int64) (string, error)
+	Rename(from, to string) error
+	Close() error
+}
+
+// SyncDir 将本地目录增量同步到远程主机：仅传输变化的文件，大文件传输中断后从已传输位置续传
+func (c *Client) SyncDir(ctx context.Context, sshKey *models.SSHKey, host string, port int, username string, opts SyncOptions) (*SyncStats, error) {
+	if err := checkRemoteUsable(sshKey); err != nil {
+		return nil, err
+	}
+	database.TouchSSHKey(sshKey.ID)
+
+	connect := func() (remoteFS, error) {
+		client, err := c.dial(ctx, sshKey, host, port, username)
+		if err != nil {
+			return nil, err
+		}
+		return &sshRemote{client: client}, nil
+	}
+
+	return syncTree(ctx, connect, opts)
+}
+
+// syncer 一次目录同步的状态
+type syncer struct {
+	ctx      context.Context
+	connect  func() (remoteFS, error)
+	remote   remoteFS
+	opts     SyncOptions
+	stats    SyncStats
+	progress *syncProgress
+}
+
+// syncTree 同步本地目录到远程目录，远程清单缺失或损坏时全量传输
+func syncTree(ctx context.Context, connect func() (remoteFS, error), opts SyncOptions) (*SyncStats, error) {
+	remote, err := connect()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syncer{
+		ctx:      ctx,
+		connect:  connect,
+		remote:   remote,
+		opts:     opts,
+		progress: newSyncProgress(opts),
+	}
+	defer func() {
+		if s.remote != nil {
+			s.remote.Close()
+		}
+	}()
+
+	manifestPath := path.Join(opts.RemoteDir, syncManifestName)
+	previous, err := loadManifest(s.remote, manifestPath)
+	if err != nil {
+		s.stats.FullTransfer = true
+		s.progress.report(fmt.Sprintf("远程同步清单不可用（%v），执行全量传输", err))
+		previous = &Manifest{}
+	}
+	known := make(map[string]ManifestEntry, len(previous.Files))
+	for _, f := range previous.Files {
+		known[f.Path] = f
+	}
+
+	files, totalBytes, err := walkLocal(opts.LocalDir)
+	if err != nil {
+		return nil, err
+	}
+	s.stats.Files = len(files)
+	s.progress.begin(len(files), totalBytes)
+
+	current := &Manifest{Files: make([]ManifestEntry, 0, len(files))}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return &s.stats, err
+		}
+
+		entry, err := s.syncFile(f, known)
+		if err != nil {
+			return &s.stats, err
+		}
+		current.Files = append(current.Files, entry)
+	}
+
+	if err := s.writeManifest(manifestPath, current); err != nil {
+		return &s.stats, err
+	}
+	s.stats.Manifest = current
+
+	s.progress.finish(&s.stats)
+	return &s.stats, nil
+}
+
+// localFile 待同步的本地文件
+type localFile struct {
+	rel     string
+	abs     string
+	size    int64
+	modTime int64
+}
+
+// walkLocal 遍历本地目录下的普通文件
+func walkLocal(root string) ([]localFile, int64, error) {
+	var files []localFile
+	var total int64
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == syncManifestName || strings.HasSuffix(rel, partSuffix) {
+			return nil
+		}
+
+		files = append(files, localFile{rel: rel, abs: p, size: info.Size(), modTime: info.ModTime().UnixNano()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("遍历本地目录失败: %w", err)
+	}
+
+	return files, total, nil
+}
+
+// syncFile 同步单个文件：大小与修改时间未变时直接跳过，内容哈希未变时仅更新清单
+func (s *syncer) syncFile(f localFile, known map[string]ManifestEntry) (ManifestEntry, error) {
+	old, ok := known[f.rel]
+	if ok && old.Size == f.size && old.ModTime == f.modTime {
+		s.stats.Skipped++
+		s.progress.skipped(f)
+		return old, nil
+	}
+
+	hash, err := hashLocal(f.abs, -1)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	entry := ManifestEntry{Path: f.rel, Size: f.size, ModTime: f.modTime, SHA256: hash}
+
+	if ok && old.Size == f.size && old.SHA256 == hash {
+		s.stats.Skipped++
+		s.progress.skipped(f)
+		return entry, nil
+	}
+
+	if err := s.transfer(f, entry); err != nil {
+		return ManifestEntry{}, err
+	}
+	return entry, nil
+}
+
+// transfer 传输文件，连接中断时重新连接并从已传输位置续传
+func (s *syncer) transfer(f localFile, entry ManifestEntry) error {
+	for attempt := 0; ; attempt++ {
+		err := s.upload(f, entry)
+		if err == nil {
+			s.stats.Transferred++
+			s.progress.transferred(f)
+			return nil
+		}
+		if attempt >= s.opts.Retries || s.ctx.Err() != nil {
+			return fmt.Errorf("传输文件 %s 失败: %w", f.rel, err)
+		}
+
+		s.progress.report(fmt.Sprintf("传输文件 %s 中断，第 %d 次重试: %v", f.rel, attempt+1, err))
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		}
+
+		// 重新连接，连接失败时由下一次尝试继续重连
+		if s.remote != nil {
+			s.remote.Close()
+			s.remote = nil
+		}
+		if remote, err := s.connect(); err == nil {
+			s.remote = remote
+		}
+	}
+}
+
+// upload 上传到临时文件并校验哈希后重命名；已有部分数据且前缀校验一致时从其末尾续传
+func (s *syncer) upload(f localFile, entry ManifestEntry) error {
+	if s.remote == nil {
+		return fmt.Errorf("远程连接不可用")
+	}
+
+	target := path.Join(s.opts.RemoteDir, f.rel)
+	part := target + partSuffix
+
+	offset, err := s.remote.Size(part)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset > f.size {
+		offset = 0
+	}
+	if offset > 0 {
+		localPrefix, err := hashLocal(f.abs, offset)
+		if err != nil {
+			return err
+		}
+		remotePrefix, err := s.remote.Hash(part, offset)
+		if err != nil {
+			return err
+		}
+		if localPrefix == remotePrefix {
+			s.stats.Resumed++
+			s.progress.report(fmt.Sprintf("续传文件 %s，已完成 %s / %s", f.rel,
+				utils.FormatFileSize(offset), utils.FormatFileSize(f.size)))
+		} else {
+			offset = 0
+		}
+	}
+
+	file, err := os.Open(f.abs)
+	if err != nil {
+		return fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer file.Close()
+
+	counter := &countingReader{r: io.NewSectionReader(file, offset, f.size-offset), sent: &s.stats.BytesSent}
+	if err := s.remote.WriteAt(part, offset, counter); err != nil {
+		return err
+	}
+
+	remoteHash, err := s.remote.Hash(part, -1)
+	if err != nil {
+		return err
+	}
+	if remoteHash != entry.SHA256 {
+		return fmt.Errorf("文件 %s 传输后校验失败", f.rel)
+	}
+
+	return s.remote.Rename(part, target)
+}
+
+// writeManifest 写入本次同步的清单并回读校验
+func (s *syncer) writeManifest(name string, m *Manifest) error {
+	m.Digest = m.computeDigest()
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("序列化同步清单失败: %w", err)
+	}
+
+	if s.remote == nil {
+		remote, err := s.connect()
+		if err != nil {
+			return err
+		}
+		s.remote = remote
+	}
+	if err := s.remote.WriteFile(name, data); err != nil {
+		return fmt.Errorf("写入同步清单失败: %w", err)
+	}
+
+	written, err := loadManifest(s.remote, name)
+	if err != nil {
+		return fmt.Errorf("校验同步清单失败: %w", err)
+	}
+	if written.Digest != m.Digest {
+		return ErrManifestCorrupted
+	}
+	return nil
+}
+
+// loadManifest 读取远程清单并校验摘要
+func loadManifest(remote remoteFS, name string) (*Manifest, error) {
+	data, err := remote.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, ErrManifestCorrupted
+	}
+	if m.Digest == "" || m.computeDigest() != m.Digest {
+		return nil, ErrManifestCorrupted
+	}
+	return &m, nil
+}
+
+// hashLocal 计算本地文件前 length 字节的sha256，length 小于0时计算整个文件
+func hashLocal(name string, length int64) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if length >= 0 {
+		r = io.LimitReader(file, length)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", fmt.Errorf("读取本地文件失败: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// countingReader 统计已发送的字节数
+type countingReader struct {
+	r    io.Reader
+	sent *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.sent += int64(n)
+	return n, err
+}
+
+// syncProgress 按固定间隔汇总输出跳过与传输的文件，避免逐文件刷屏
+type syncProgress struct {
+	emit     func(string)
+	interval time.Duration
+	last     time.Time
+
+	total      int
+	totalBytes int64
+	done       int
+	doneBytes  int64
+
+	pendingSkipped     int
+	pendingTransferred []string
+}
+
+// newSyncProgress 创建进度汇总器
+func newSyncProgress(opts SyncOptions) *syncProgress {
+	emit := opts.Progress
+	if emit == nil {
+		emit = func(string) {}
+	}
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &syncProgress{emit: emit, interval: interval, last: time.Now()}
+}
+
+func (p *syncProgress) begin(total int, totalBytes int64) {
+	p.total, p.totalBytes = total, totalBytes
+	p.emit(fmt.Sprintf("开始同步 %d 个文件，共 %s", total, utils.FormatFileSize(totalBytes)))
+}
+
+func (p *syncProgress) report(message string) {
+	p.flush()
+	p.emit(message)
+}
+
+func (p *syncProgress) skipped(f localFile) {
+	p.done++
+	p.doneBytes += f.size
+	p.pendingSkipped++
+	p.maybeFlush()
+}
+
+func (p *syncProgress) transferred(f localFile) {
+	p.done++
+	p.doneBytes += f.size
+	p.pendingTransferred = append(p.pendingTransferred, f.rel)
+	p.maybeFlush()
+}
+
+func (p *syncProgress) maybeFlush() {
+	if time.Since(p.last) >= p.interval {
+		p.flush()
+	}
+}
+
+// flush 输出自上次汇总以来的进度
+func (p *syncProgress) flush() {
+	p.last = time.Now()
+	if p.pendingSkipped == 0 && len(p.pendingTransferred) == 0 {
+		return
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "同步进度 %d/%d（%s / %s）：传输 %d 个", p.done, p.total,
+		utils.FormatFileSize(p.doneBytes), utils.FormatFileSize(p.totalBytes), len(p.pendingTransferred))
+	if n := len(p.pendingTransferred); n > 0 {
+		shown := p.pendingTransferred
+		if n > 3 {
+			shown = shown[n-3:]
+		}
+		fmt.Fprintf(&b, "（最近: %s）", strings.Join(shown, ", "))
+	}
+	fmt.Fprintf(&b, "，跳过 %d 个未变化文件", p.pendingSkipped)
+	p.emit(b.String())
+
+	p.pendingSkipped = 0
+	p.pendingTransferred = nil
+}
+
+func (p *syncProgress) finish(stats *SyncStats) {
+	p.flush()
+	p.emit(fmt.Sprintf("同步完成：共 %d 个文件，传输 %d 个（续传 %d 个），跳过 %d 个，发送 %s",
+		stats.Files, stats.Transferred, stats.Resumed, stats.Skipped, utils.FormatFileSize(stats.BytesSent)))
+}
+
+// sshRemote 通过SSH会话执行远程命令实现文件操作，仅依赖 POSIX 工具与 sha256sum
+type sshRemote struct {
+	client *ssh.Client
+}
+
+// run 在新会话中执行命令
+func (r *sshRemote) run(command string, stdin io.Reader) ([]byte, error) {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %w", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(command); err != nil {
+		return nil, fmt.Errorf("执行远程命令失败: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func (r *sshRemote) ReadFile(name string) ([]byte, error) {
+	return r.run("cat -- "+shellQuote(name), nil)
+}
+
+func (r *sshRemote) WriteFile(name string, data []byte) error {
+	tmp := shellQuote(name + ".tmp")
+	_, err := r.run(fmt.Sprintf("mkdir -p -- %s && cat > %s && mv -f -- %s %s",
+		shellQuote(path.Dir(name)), tmp, tmp, shellQuote(name)), bytes.NewReader(data))
+	return err
+}
+
+func (r *sshRemote) Size(name string) (int64, error) {
+	q := shellQuote(name)
+	out, err := r.run(fmt.Sprintf("if [ -f %s ]; then wc -c < %s; else echo -1; fi", q, q), nil)
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析远程文件大小失败: %w", err)
+	}
+	return size, nil
+}
+
+func (r *sshRemote) WriteAt(name string, offset int64, data io.Reader) error {
+	q := shellQuote(name)
+	_, err := r.run(fmt.Sprintf("mkdir -p -- %s && truncate -s %d %s && cat >> %s",
+		shellQuote(path.Dir(name)), offset, q, q), data)
+	return err
+}
+
+func (r *sshRemote) Hash(name string, length int64) (string, error) {
+	command := "sha256sum < " + shellQuote(name)
+	if length >= 0 {
+		command = fmt.Sprintf("head -c %d %s | sha256sum", length, shellQuote(name))
+	}
+	out, err := r.run(command, nil)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("远程哈希输出为空")
+	}
+	return fields[0], nil
+}
+
+func (r *sshRemote) Rename(from, to string) error {
+	_, err := r.run(fmt.Sprintf("mv -f -- %s %s", shellQuote(from), shellQuote(to)), nil)
+	return err
+}
+
+func (r *sshRemote) Close() error {
+	return r.client.Close()
+}
+
+// shellQuote 用单引号转义远程命令参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellQuote 用单引号转义远程命令参数，供其他包拼接在目标主机上执行的命令
+func ShellQuote(s string) string {
+	return shellQuote(s)
+}
@@ -0,0 +1,448 @@
+package support
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/deploy"
+	"flowforge/pkg/models"
+	"flowforge/pkg/pipeline"
+	"flowforge/pkg/scheduler"
+	"flowforge/pkg/utils"
+)
+
+const (
+	// maxLogBytes 诊断包中服务器日志的最大长度，只保留末尾
+	maxLogBytes = 2 << 20
+	// failedRunLimit 诊断包中收录的最近失败运行数量
+	failedRunLimit = 20
+	// bundleRetention 生成的诊断包保留时间
+	bundleRetention = 24 * time.Hour
+)
+
+// 诊断包生成状态
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+// BuildInfo 版本与构建信息
+type BuildInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+}
+
+// NewBuildInfo 生成构建信息，VCS 版本取自编译时嵌入的信息
+func NewBuildInfo(name, version string) BuildInfo {
+	info := BuildInfo{
+		Name:      name,
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// Sources 诊断包的状态来源，离线生成时均为空
+type Sources struct {
+	Engine    *pipeline.Engine
+	Scheduler *scheduler.Scheduler
+	Deploy    *deploy.DeployManager
+}
+
+// Job 诊断包异步生成任务
+type Job struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Progress    int        `json:"progress"` // 0-100
+	Step        string     `json:"step"`
+	Error       string     `json:"error,omitempty"`
+	Size        int64      `json:"size"`
+	RequestedBy uint       `json:"requested_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	path string
+}
+
+// Generator 诊断包生成器
+type Generator struct {
+	config  *config.Config
+	sources Sources
+	info    BuildInfo
+	dir     string
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewGenerator 创建诊断包生成器
+func NewGenerator(cfg *config.Config, sources Sources, info BuildInfo) *Generator {
+	return &Generator{
+		config:  cfg,
+		sources: sources,
+		info:    info,
+		dir:     filepath.Join(cfg.Storage.Local.Path, "support-bundles"),
+		jobs:    make(map[string]*Job),
+	}
+}
+
+// Start 异步生成诊断包，返回任务快照
+func (g *Generator) Start(requestedBy uint) (Job, error) {
+	if err := os.MkdirAll(g.dir, 0700); err != nil {
+		return Job{}, fmt.Errorf("创建诊断包目录失败: %w", err)
+	}
+
+	g.mu.Lock()
+	g.pruneLocked()
+	job := &Job{
+		ID:          time.Now().Format("20060102-150405") + "-" + strings.ToLower(utils.GenerateRandomString(6)),
+		Status:      JobStatusRunning,
+		Step:        "准备",
+		RequestedBy: requestedBy,
+		CreatedAt:   time.Now(),
+	}
+	job.path = filepath.Join(g.dir, "flowforge-support-"+job.ID+".zip")
+	g.jobs[job.ID] = job
+	snapshot := *job
+	g.mu.Unlock()
+
+	go g.run(job)
+	return snapshot, nil
+}
+
+// Get 获取任务快照
+func (g *Generator) Get(id string) (Job, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	job, ok := g.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// FilePath 获取已完成任务的诊断包路径
+func (g *Generator) FilePath(id string) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	job, ok := g.jobs[id]
+	if !ok || job.Status != JobStatusCompleted {
+		return "", false
+	}
+	return job.path, true
+}
+
+// run 执行生成任务并更新进度
+func (g *Generator) run(job *Job) {
+	err := g.GenerateFile(job.path, func(step string, progress int) {
+		g.mu.Lock()
+		job.Step, job.Progress = step, progress
+		g.mu.Unlock()
+	})
+
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		os.Remove(job.path)
+		log.Printf("生成诊断包 %s 失败: %v", job.ID, err)
+		return
+	}
+	if info, err := os.Stat(job.path); err == nil {
+		job.Size = info.Size()
+	}
+	job.Status, job.Progress, job.Step = JobStatusCompleted, 100, "完成"
+}
+
+// pruneLocked 删除过期的诊断包，调用方需持有 g.mu
+func (g *Generator) pruneLocked() {
+	for id, job := range g.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > bundleRetention {
+			os.Remove(job.path)
+			delete(g.jobs, id)
+		}
+	}
+}
+
+// GenerateFile 生成诊断包到指定路径，progress 可为空
+func (g *Generator) GenerateFile(path string, progress func(step string, progress int)) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("创建诊断包文件失败: %w", err)
+	}
+
+	err = g.Write(file, progress)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// section 诊断包中的一个文件
+type section struct {
+	name  string
+	step  string
+	build func() ([]byte, error)
+}
+
+// Write 将诊断包以zip格式写入 w，所有内容经过脱敏层
+func (g *Generator) Write(w io.Writer, progress func(step string, progress int)) error {
+	if progress == nil {
+		progress = func(string, int) {}
+	}
+
+	mask := NewMasker(g.config)
+	sections := []section{
+		{"build.json", "版本信息", g.buildSection},
+		{"config.yaml", "配置", func() ([]byte, error) { return sanitizeConfig(g.config) }},
+		{"database.json", "数据库统计", g.databaseSection},
+		{"status.json", "组件状态", g.statusSection},
+		{"failed_runs.json", "失败运行", g.failedRunsSection},
+		{"disk.json", "磁盘使用", g.diskSection},
+		{"logs/server.log", "服务器日志", g.logSection},
+	}
+
+	zw := zip.NewWriter(w)
+	for i, s := range sections {
+		progress(s.step, i*100/len(sections))
+
+		data, err := s.build()
+		if err != nil {
+			// 单个部分失败不影响其余内容，错误本身写入诊断包
+			data = []byte(fmt.Sprintf("收集%s失败: %v\n", s.step, err))
+		}
+
+		entry, err := zw.Create(s.name)
+		if err != nil {
+			return fmt.Errorf("写入诊断包失败: %w", err)
+		}
+		if _, err := entry.Write(mask.Mask(data)); err != nil {
+			return fmt.Errorf("写入诊断包失败: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("写入诊断包失败: %w", err)
+	}
+	progress("完成", 100)
+	return nil
+}
+
+// buildSection 版本与运行环境
+func (g *Generator) buildSection() ([]byte, error) {
+	hostname, _ := os.Hostname()
+	return json.MarshalIndent(map[string]interface{}{
+		"build":        g.info,
+		"hostname":     hostname,
+		"generated_at": time.Now(),
+		"offline":      g.sources.Engine == nil,
+	}, "", "  ")
+}
+
+// databaseSection 连接池统计与各表行数
+func (g *Generator) databaseSection() ([]byte, error) {
+	if database.DB == nil {
+		return nil, fmt.Errorf("数据库未连接")
+	}
+
+	result := map[string]interface{}{
+		"dialect": database.DB.Dialector.Name(),
+	}
+	if sqlDB, err := database.DB.DB(); err == nil {
+		result["pool"] = sqlDB.Stats()
+		if err := sqlDB.Ping(); err != nil {
+			result["ping_error"] = err.Error()
+		}
+	}
+
+	tables, err := database.DB.Migrator().GetTables()
+	if err != nil {
+		result["tables_error"] = err.Error()
+	} else {
+		counts := make(map[string]int64, len(tables))
+		for _, table := range tables {
+			var count int64
+			if err := database.DB.Table(table).Count(&count).Error; err != nil {
+				count = -1
+			}
+			counts[table] = count
+		}
+		result["row_counts"] = counts
+	}
+
+	return json.MarshalIndent(result, "", "  ")
+}
+
+// statusSection 执行引擎、调度器与部署管理器的状态
+func (g *Generator) statusSection() ([]byte, error) {
+	status := map[string]interface{}{}
+
+	if engine := g.sources.Engine; engine != nil {
+		engineStatus := map[string]interface{}{
+			"jobs":         engine.ListJobs(),
+			"log_writer":   engine.LogWriterStats(),
+			"queue":        engine.QueueStats(),
+			"deploy_locks": engine.DeployLocks().Stats(),
+			"healthy":      true,
+		}
+		if err := engine.HealthCheck(); err != nil {
+			engineStatus["healthy"] = false
+			engineStatus["health_error"] = err.Error()
+		}
+		status["engine"] = engineStatus
+	} else {
+		status["engine"] = "离线生成，不可用"
+	}
+
+	if s := g.sources.Scheduler; s != nil {
+		status["scheduler"] = map[string]interface{}{
+			"running": s.IsRunning(),
+			"jobs":    s.GetJobs(),
+		}
+	} else {
+		status["scheduler"] = "离线生成，不可用"
+	}
+
+	if dm := g.sources.Deploy; dm != nil {
+		status["deploy_manager"] = dm.Status()
+	} else {
+		status["deploy_manager"] = "离线生成，不可用"
+	}
+
+	return json.MarshalIndent(status, "", "  ")
+}
+
+// failedRunSummary 失败运行的分类信息，不含完整日志
+type failedRunSummary struct {
+	ID          uint       `json:"id"`
+	PipelineID  uint       `json:"pipeline_id"`
+	TriggerType string     `json:"trigger_type"`
+	FailureKind string     `json:"failure_kind"`
+	StartTime   *time.Time `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+	Error       string     `json:"error"`
+}
+
+// failedRunsSection 最近失败运行的分类
+func (g *Generator) failedRunsSection() ([]byte, error) {
+	if database.DB == nil {
+		return nil, fmt.Errorf("数据库未连接")
+	}
+
+	var runs []models.PipelineRun
+	if err := database.DB.Select("id", "pipeline_id", "trigger_type", "failure_kind", "start_time", "end_time", "error_msg").
+		Where("status = ?", models.RunStatusFailed).
+		Order("id DESC").Limit(failedRunLimit).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make([]failedRunSummary, 0, len(runs))
+	for _, run := range runs {
+		message, _, _ := strings.Cut(run.ErrorMsg, "\n")
+		if len(message) > 300 {
+			message = message[:300] + "..."
+		}
+		summaries = append(summaries, failedRunSummary{
+			ID:          run.ID,
+			PipelineID:  run.PipelineID,
+			TriggerType: run.TriggerType,
+			FailureKind: run.FailureKind,
+			StartTime:   run.StartTime,
+			EndTime:     run.EndTime,
+			Error:       message,
+		})
+	}
+
+	return json.MarshalIndent(summaries, "", "  ")
+}
+
+// diskSection 工作区与存储目录所在卷的磁盘使用
+func (g *Generator) diskSection() ([]byte, error) {
+	result := map[string]interface{}{}
+	for name, dir := range map[string]string{
+		"workspace": g.config.Deploy.WorkspaceDir,
+		"storage":   g.config.Storage.Local.Path,
+		"logs":      filepath.Dir(g.config.Log.Filename),
+	} {
+		if dir == "" {
+			continue
+		}
+		usage, err := utils.GetDiskUsage(dir)
+		if err != nil {
+			result[name] = err.Error()
+			continue
+		}
+		result[name] = usage
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
+
+// logSection 服务器日志文件的末尾部分
+func (g *Generator) logSection() ([]byte, error) {
+	if g.config.Log.Filename == "" {
+		return []byte("未配置日志文件，日志输出到 " + g.config.Log.Output + "\n"), nil
+	}
+
+	file, err := os.Open(g.config.Log.Filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxLogBytes {
+		if _, err := file.Seek(info.Size()-maxLogBytes, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	// 截断位置可能落在行中间，丢弃不完整的首行
+	if info.Size() > maxLogBytes {
+		if i := strings.IndexByte(string(data), '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return data, nil
+}
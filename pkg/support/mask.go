@@ -0,0 +1,147 @@
+package support
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+	"flowforge/pkg/redact"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted 替换密钥后的占位符
+const redacted = "******"
+
+// minSecretLength 参与内联替换的密钥最小长度，过短的值替换会误伤正常内容
+const minSecretLength = 4
+
+var (
+	// sensitiveKey 配置项名称包含这些词时视为密钥
+	sensitiveKey = regexp.MustCompile(`(?i)(secret|password|passwd|token|private_key|api_key|credential|dsn)`)
+
+	// sensitiveText 文本中常见的密钥形式：私钥块、Bearer令牌、URL中的用户名密码
+	sensitiveText = []*regexp.Regexp{
+		regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+		regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`),
+	}
+)
+
+// Masker 诊断包与数据导出的脱敏层，所有写入的内容都经过它处理
+type Masker struct {
+	secrets []string
+	rules   *redact.Redactor // 管理员配置的全局日志脱敏规则
+}
+
+// NewMasker 收集配置与数据库中的密钥值，用于在日志等自由文本中替换
+func NewMasker(cfg *config.Config) *Masker {
+	m := &Masker{}
+
+	if data, err := yaml.Marshal(cfg); err == nil {
+		var tree map[string]interface{}
+		if yaml.Unmarshal(data, &tree) == nil {
+			m.collect(tree)
+		}
+	}
+
+	if database.DB != nil {
+		var envs []models.Environment
+		database.DB.Where("is_secret = ?", true).Find(&envs)
+		for _, env := range envs {
+			m.add(env.Value)
+		}
+
+		var hooks []models.Webhook
+		database.DB.Select("secret", "previous_secret").Find(&hooks)
+		for _, hook := range hooks {
+			m.add(hook.Secret)
+			m.add(hook.PreviousSecret)
+		}
+	}
+
+	// 长的先替换，避免只替换掉一部分
+	sort.Slice(m.secrets, func(i, j int) bool { return len(m.secrets[i]) > len(m.secrets[j]) })
+	m.rules = redact.ForProject(0)
+	return m
+}
+
+// add 登记一个需要替换的密钥值
+func (m *Masker) add(value string) {
+	if len(value) >= minSecretLength {
+		m.secrets = append(m.secrets, value)
+	}
+}
+
+// collect 收集配置树中名称敏感的字符串值
+func (m *Masker) collect(node interface{}) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && sensitiveKey.MatchString(key) {
+				m.add(s)
+				continue
+			}
+			m.collect(value)
+		}
+	case []interface{}:
+		for _, item := range v {
+			m.collect(item)
+		}
+	}
+}
+
+// Mask 替换文本中的密钥
+func (m *Masker) Mask(data []byte) []byte {
+	text := string(data)
+	for _, secret := range m.secrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	for i, re := range sensitiveText {
+		if i == 0 {
+			text = re.ReplaceAllString(text, redacted)
+		} else {
+			text = re.ReplaceAllString(text, "${1}"+redacted)
+		}
+	}
+	return []byte(m.rules.Redact(text))
+}
+
+// sanitizeConfig 输出脱敏后的配置，名称敏感的配置项整体替换
+func sanitizeConfig(cfg *config.Config) ([]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	redactTree(tree)
+
+	return yaml.Marshal(tree)
+}
+
+// redactTree 将名称敏感且非空的配置值替换为占位符
+func redactTree(node interface{}) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && sensitiveKey.MatchString(key) {
+				if s != "" {
+					v[key] = redacted
+				}
+				continue
+			}
+			redactTree(value)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactTree(item)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package testreport
+
+import "sort"
+
+// RunFailures 一次运行中失败的测试用例名称
+type RunFailures struct {
+	RunID  uint
+	Failed map[string]bool
+}
+
+// TestFlakiness 测试用例在最近若干次运行中的稳定性
+type TestFlakiness struct {
+	Name         string  `json:"name"`
+	Runs         int     `json:"runs"`           // 参与统计的运行数
+	Failures     int     `json:"failures"`       // 失败的运行数
+	FailureRate  float64 `json:"failure_rate"`   // 失败率 0-1
+	Flips        int     `json:"flips"`          // 相邻运行间结果变化的次数
+	Flaky        bool    `json:"flaky"`          // 失败与通过交替出现
+	LastFailedIn uint    `json:"last_failed_in"` // 最近一次失败的运行ID
+}
+
+// Flakiness 按运行先后顺序（旧到新）统计每个失败过的用例；
+// 未出现在某次运行失败列表中的用例视为该次通过。结果与通过之间至少变化两次
+// （如 通过→失败→通过）才认为不稳定，持续失败或修复后不再失败都不算
+func Flakiness(runs []RunFailures) []TestFlakiness {
+	names := make(map[string]bool)
+	for _, run := range runs {
+		for name := range run.Failed {
+			names[name] = true
+		}
+	}
+
+	result := make([]TestFlakiness, 0, len(names))
+	for name := range names {
+		stat := TestFlakiness{Name: name, Runs: len(runs)}
+		for i, run := range runs {
+			failed := run.Failed[name]
+			if failed {
+				stat.Failures++
+				stat.LastFailedIn = run.RunID
+			}
+			if i > 0 && failed != runs[i-1].Failed[name] {
+				stat.Flips++
+			}
+		}
+		if stat.Runs > 0 {
+			stat.FailureRate = float64(stat.Failures) / float64(stat.Runs)
+		}
+		stat.Flaky = stat.Flips >= 2
+		result = append(result, stat)
+	}
+
+	// 不稳定的用例优先，其次按变化次数与失败次数排序
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Flaky != b.Flaky {
+			return a.Flaky
+		}
+		if a.Flips != b.Flips {
+			return a.Flips > b.Flips
+		}
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.Name < b.Name
+	})
+	return result
+}
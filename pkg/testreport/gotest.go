@@ -0,0 +1,100 @@
+package testreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// goTestEvent go test -json 的输出事件
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+	Output  string  `json:"Output"`
+}
+
+// ParseGoTest 解析 go test -json 输出，按测试函数统计；没有测试结果但失败的包（如编译失败）计为一个失败
+func ParseGoTest(r io.Reader) (*Summary, error) {
+	summary := &Summary{}
+	outputs := make(map[string]*strings.Builder)
+	packagesWithTests := make(map[string]bool)
+	parsed := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event goTestEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			// go test 可能夹杂非JSON的输出行
+			continue
+		}
+		parsed++
+
+		key := event.Package + "." + event.Test
+		switch event.Action {
+		case "output":
+			b, ok := outputs[key]
+			if !ok {
+				b = &strings.Builder{}
+				outputs[key] = b
+			}
+			// 只保留足够截断所需的输出
+			if b.Len() < maxMessageLength*4 {
+				b.WriteString(event.Output)
+			}
+		case "pass", "fail", "skip":
+			if event.Test == "" {
+				if event.Action != "skip" {
+					summary.Duration += event.Elapsed
+				}
+				if event.Action == "fail" && !packagesWithTests[event.Package] {
+					summary.Total++
+					summary.Failed++
+					summary.appendFailure(event.Package, outputs[key])
+				}
+				continue
+			}
+
+			packagesWithTests[event.Package] = true
+			summary.Total++
+			switch event.Action {
+			case "pass":
+				summary.Passed++
+			case "skip":
+				summary.Skipped++
+			case "fail":
+				summary.Failed++
+				summary.appendFailure(event.Package+"."+event.Test, outputs[key])
+			}
+			delete(outputs, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取go test输出失败: %w", err)
+	}
+	if parsed == 0 {
+		return nil, fmt.Errorf("解析go test输出失败: 没有有效的事件")
+	}
+	return summary, nil
+}
+
+// appendFailure 记录失败用例，输出作为失败信息
+func (s *Summary) appendFailure(name string, output *strings.Builder) {
+	if len(s.Failures) >= MaxFailures {
+		return
+	}
+	message := ""
+	if output != nil {
+		message = output.String()
+	}
+	s.Failures = append(s.Failures, Failure{Name: name, Message: truncate(message)})
+}
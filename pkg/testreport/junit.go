@@ -0,0 +1,90 @@
+package testreport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// junitSuite JUnit XML 的 testsuite/testsuites 节点，testsuites 可嵌套 testsuite
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+// junitCase JUnit XML 的 testcase 节点
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+}
+
+// junitProblem failure/error/skipped 节点
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit 解析 JUnit XML 报告，按 testcase 统计，error 计为失败
+func ParseJUnit(r io.Reader) (*Summary, error) {
+	var root junitSuite
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("解析JUnit报告失败: %w", err)
+	}
+
+	summary := &Summary{}
+	collectJUnit(summary, &root)
+	return summary, nil
+}
+
+// collectJUnit 递归统计测试套件中的用例
+func collectJUnit(summary *Summary, suite *junitSuite) {
+	for _, tc := range suite.Cases {
+		summary.Total++
+		if d, err := strconv.ParseFloat(strings.TrimSpace(tc.Time), 64); err == nil {
+			summary.Duration += d
+		}
+
+		problem := tc.Failure
+		if problem == nil {
+			problem = tc.Error
+		}
+		switch {
+		case problem != nil:
+			summary.Failed++
+			if len(summary.Failures) < MaxFailures {
+				message := problem.Message
+				if strings.TrimSpace(problem.Text) != "" {
+					message = problem.Text
+				}
+				summary.Failures = append(summary.Failures, Failure{Name: junitName(suite, &tc), Message: truncate(message)})
+			}
+		case tc.Skipped != nil:
+			summary.Skipped++
+		default:
+			summary.Passed++
+		}
+	}
+
+	for i := range suite.Suites {
+		collectJUnit(summary, &suite.Suites[i])
+	}
+}
+
+// junitName 用例全名：classname.name，缺少 classname 时使用套件名
+func junitName(suite *junitSuite, tc *junitCase) string {
+	prefix := tc.ClassName
+	if prefix == "" {
+		prefix = suite.Name
+	}
+	if prefix == "" {
+		return tc.Name
+	}
+	return prefix + "." + tc.Name
+}
@@ -0,0 +1,175 @@
+package testreport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxReportSize 单个报告文件的大小上限
+	maxReportSize = 32 << 20
+	// maxMessageLength 失败信息保留的最大字符数
+	maxMessageLength = 500
+	// MaxFailures 每个步骤保留的失败用例数量上限
+	MaxFailures = 100
+)
+
+// 报告格式
+const (
+	FormatJUnit  = "junit"
+	FormatGoTest = "go-test-json"
+)
+
+// Failure 失败的测试用例
+type Failure struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// Summary 测试报告汇总
+type Summary struct {
+	Total    int       `json:"total"`
+	Passed   int       `json:"passed"`
+	Failed   int       `json:"failed"`
+	Skipped  int       `json:"skipped"`
+	Duration float64   `json:"duration"` // 秒
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// Merge 合并另一份报告的结果，失败用例超过 MaxFailures 的部分只计数不保留
+func (s *Summary) Merge(other *Summary) {
+	s.Total += other.Total
+	s.Passed += other.Passed
+	s.Failed += other.Failed
+	s.Skipped += other.Skipped
+	s.Duration += other.Duration
+	for _, f := range other.Failures {
+		if len(s.Failures) >= MaxFailures {
+			break
+		}
+		s.Failures = append(s.Failures, f)
+	}
+}
+
+// FailureNames 前 limit 个失败用例的名称
+func (s *Summary) FailureNames(limit int) []string {
+	names := make([]string, 0, limit)
+	for _, f := range s.Failures {
+		if len(names) >= limit {
+			break
+		}
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// ParseFile 解析测试报告文件，按内容识别 JUnit XML 或 go test -json 格式
+func ParseFile(file string) (*Summary, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("打开测试报告失败: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxReportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取测试报告失败: %w", err)
+	}
+	if len(data) > maxReportSize {
+		return nil, fmt.Errorf("测试报告超过 %d MB", maxReportSize>>20)
+	}
+
+	switch DetectFormat(data) {
+	case FormatJUnit:
+		return ParseJUnit(bytes.NewReader(data))
+	case FormatGoTest:
+		return ParseGoTest(bytes.NewReader(data))
+	}
+	return nil, fmt.Errorf("无法识别的测试报告格式")
+}
+
+// DetectFormat 根据首个非空白字符识别报告格式，无法识别时返回空字符串
+func DetectFormat(data []byte) string {
+	trimmed := bytes.TrimLeft(data, " \t\r\n\xef\xbb\xbf")
+	if len(trimmed) == 0 {
+		return ""
+	}
+	switch trimmed[0] {
+	case '<':
+		return FormatJUnit
+	case '{':
+		return FormatGoTest
+	}
+	return ""
+}
+
+// Glob 在 root 下按模式查找报告文件，模式为相对 root 的路径，支持 * ? [] 与 **（匹配任意层目录）；
+// 绝对路径或跳出 root 的模式返回错误
+func Glob(root string, pattern string) ([]string, error) {
+	pattern = path.Clean(filepath.ToSlash(strings.TrimSpace(pattern)))
+	if pattern == "." || path.IsAbs(pattern) || pattern == ".." || strings.HasPrefix(pattern, "../") {
+		return nil, fmt.Errorf("报告路径 %s 必须位于工作区内", pattern)
+	}
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, fmt.Errorf("报告路径 %s 无效: %w", pattern, err)
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		if matchPath(strings.Split(pattern, "/"), strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchPath 逐段匹配路径，** 段匹配零个或多个目录
+func matchPath(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchPath(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// truncate 截断失败信息，最多保留 maxMessageLength 个字符
+func truncate(message string) string {
+	message = strings.TrimSpace(message)
+	if utf8.RuneCountInString(message) <= maxMessageLength {
+		return message
+	}
+	return string([]rune(message)[:maxMessageLength]) + "..."
+}
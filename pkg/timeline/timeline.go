@@ -0,0 +1,417 @@
+package timeline
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// 条目类型。同一时间的条目按此顺序排列，再按来源记录ID倒序，分页因此稳定
+const (
+	TypeRun        = "run"        // 流水线运行
+	TypeDeployment = "deployment" // 项目部署
+	TypeAudit      = "audit"      // 审计日志中的配置变更：流水线编辑、环境变量、成员等
+)
+
+// Types 全部条目类型，按同一时间的排列顺序
+var Types = []string{TypeRun, TypeDeployment, TypeAudit}
+
+const (
+	batchSize = 100  // 每个来源每次读取的记录数
+	maxScan   = 2000 // 一次请求中每个来源最多读取的记录数，搜索过滤掉大部分记录时避免扫描整表
+)
+
+// Entry 时间线中的一条记录
+type Entry struct {
+	Type    string    `json:"type"`
+	ID      uint      `json:"id"` // 来源记录的ID
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"` // 运行与部署为状态，审计记录为操作
+	ActorID *uint     `json:"actor_id,omitempty"`
+	Actor   string    `json:"actor,omitempty"`
+	Summary string    `json:"summary"`
+	Link    string    `json:"link,omitempty"` // 来源记录的接口地址
+}
+
+// Query 时间线的查询条件，From、To 为零值表示不限
+type Query struct {
+	ProjectID uint
+	Types     []string // 为空表示全部类型
+	From      time.Time
+	To        time.Time
+	Search    string // 摘要中包含的文本，不区分大小写
+	Cursor    *Cursor
+	Limit     int // 每页条数，规范化方式同 database.CursorLimit
+}
+
+// Page 一页时间线。NextCursor 为空表示没有更多记录；使用搜索时一页的条目可能少于 limit，仍应按 NextCursor 继续读取
+type Page struct {
+	Entries    []Entry `json:"entries"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Cursor 时间线的分页位置，指向上一页的最后一条记录
+type Cursor struct {
+	Time time.Time
+	Type string
+	ID   uint
+}
+
+// Encode 编码游标（时间+类型+ID），对客户端不透明
+func (c *Cursor) Encode() string {
+	raw := strconv.FormatInt(c.Time.UnixNano(), 10) + ":" + c.Type + ":" + strconv.FormatUint(uint64(c.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor 解析游标，空字符串表示从第一页开始并返回 nil
+func DecodeCursor(value string) (*Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, database.ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || !IsValidType(parts[1]) {
+		return nil, database.ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, database.ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return nil, database.ErrInvalidCursor
+	}
+	return &Cursor{Time: time.Unix(0, nanos), Type: parts[1], ID: uint(id)}, nil
+}
+
+// IsValidType 验证条目类型
+func IsValidType(value string) bool {
+	return typeRank(value) >= 0
+}
+
+// typeRank 类型在同一时间的条目中的位置
+func typeRank(value string) int {
+	for i, item := range Types {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// before 游标位置 c 是否排在 other 之前：时间较新，时间相同时类型靠前，类型也相同时ID较大
+func (c *Cursor) before(other *Cursor) bool {
+	if !c.Time.Equal(other.Time) {
+		return c.Time.After(other.Time)
+	}
+	if c.Type != other.Type {
+		return typeRank(c.Type) < typeRank(other.Type)
+	}
+	return c.ID > other.ID
+}
+
+// position 条目在时间线中的位置
+func (e *Entry) position() *Cursor {
+	return &Cursor{Time: e.Time, Type: e.Type, ID: e.ID}
+}
+
+// Load 读取一页项目时间线。各来源按时间倒序分批查询游标之后的记录，在内存中按时间线顺序合并，
+// 每个来源只读取凑满一页所需的记录；某个来源达到读取上限时，本页在该来源已读取的位置截止
+func Load(db *gorm.DB, q Query) (*Page, error) {
+	types := q.Types
+	if len(types) == 0 {
+		types = Types
+	}
+	var sources []*source
+	for _, typ := range Types {
+		for _, wanted := range types {
+			if wanted == typ {
+				sources = append(sources, &source{typ: typ, fetch: fetcher(db, typ, &q), after: q.Cursor})
+			}
+		}
+	}
+
+	search := strings.ToLower(strings.TrimSpace(q.Search))
+	match := func(entry *Entry) bool {
+		return search == "" || strings.Contains(strings.ToLower(entry.Summary), search)
+	}
+
+	limit := database.CursorLimit(q.Limit)
+	page := &Page{Entries: []Entry{}}
+	for {
+		var next *source
+		var nextEntry *Entry
+		var floor *Cursor
+		for _, s := range sources {
+			entry, err := s.peek(match)
+			if err != nil {
+				return nil, err
+			}
+			if entry != nil {
+				if nextEntry == nil || entry.position().before(nextEntry.position()) {
+					next, nextEntry = s, entry
+				}
+			} else if s.truncated && (floor == nil || s.after.before(floor)) {
+				floor = s.after
+			}
+		}
+
+		// 达到读取上限的来源在更早的位置可能还有记录，不能越过它已读取的位置
+		if nextEntry == nil || (floor != nil && floor.before(nextEntry.position())) {
+			if floor != nil {
+				page.NextCursor = floor.Encode()
+			}
+			break
+		}
+		if len(page.Entries) == limit {
+			last := page.Entries[len(page.Entries)-1]
+			page.NextCursor = last.position().Encode()
+			break
+		}
+		page.Entries = append(page.Entries, *nextEntry)
+		next.buf = next.buf[1:]
+	}
+
+	if err := fillActors(db, page.Entries); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// source 一个来源的读取器，按时间线顺序分批读取 after 之后的记录
+type source struct {
+	typ   string
+	fetch func(after *Cursor, limit int) ([]Entry, error)
+
+	buf       []Entry // 已读取且满足搜索条件、尚未合并的记录
+	after     *Cursor // 已读取的最后位置
+	scanned   int
+	exhausted bool // 已读完
+	truncated bool // 达到读取上限
+}
+
+// peek 来源中下一条满足搜索条件的记录，没有时返回 nil
+func (s *source) peek(match func(*Entry) bool) (*Entry, error) {
+	for len(s.buf) == 0 && !s.exhausted && !s.truncated {
+		batch, err := s.fetch(s.after, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		s.scanned += len(batch)
+		if len(batch) < batchSize {
+			s.exhausted = true
+		} else if s.scanned >= maxScan {
+			s.truncated = true
+		}
+		if len(batch) > 0 {
+			s.after = batch[len(batch)-1].position()
+		}
+		for i := range batch {
+			if match(&batch[i]) {
+				s.buf = append(s.buf, batch[i])
+			}
+		}
+	}
+	if len(s.buf) == 0 {
+		return nil, nil
+	}
+	return &s.buf[0], nil
+}
+
+// fetcher 各类型来源的查询
+func fetcher(db *gorm.DB, typ string, q *Query) func(after *Cursor, limit int) ([]Entry, error) {
+	switch typ {
+	case TypeRun:
+		return func(after *Cursor, limit int) ([]Entry, error) { return fetchRuns(db, q, after, limit) }
+	case TypeDeployment:
+		return func(after *Cursor, limit int) ([]Entry, error) { return fetchDeployments(db, q, after, limit) }
+	default:
+		return func(after *Cursor, limit int) ([]Entry, error) { return fetchAudits(db, q, after, limit) }
+	}
+}
+
+// window 时间范围与游标条件，按 table 的 created_at DESC, id DESC 排序并限制条数。
+// 游标所在类型排在本类型之前时，与游标时间相同的记录也在游标之后
+func window(query *gorm.DB, table, typ string, q *Query, after *Cursor, limit int) *gorm.DB {
+	createdAt, id := table+".created_at", table+".id"
+	if !q.From.IsZero() {
+		query = query.Where(createdAt+" >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where(createdAt+" < ?", q.To)
+	}
+	if after != nil {
+		switch rank, afterRank := typeRank(typ), typeRank(after.Type); {
+		case rank > afterRank:
+			query = query.Where(createdAt+" <= ?", after.Time)
+		case rank < afterRank:
+			query = query.Where(createdAt+" < ?", after.Time)
+		default:
+			query = query.Where(createdAt+" < ? OR ("+createdAt+" = ? AND "+id+" < ?)", after.Time, after.Time, after.ID)
+		}
+	}
+	return query.Order(createdAt + " DESC").Order(id + " DESC").Limit(limit)
+}
+
+// fetchRuns 项目各流水线的运行，包括已删除流水线留下的运行
+func fetchRuns(db *gorm.DB, q *Query, after *Cursor, limit int) ([]Entry, error) {
+	var rows []struct {
+		ID           uint
+		CreatedAt    time.Time
+		Status       string
+		RunNumber    int
+		Branch       string
+		TriggerType  string
+		PipelineID   uint
+		PipelineName string
+		UserID       uint
+	}
+	query := db.Table("pipeline_runs").
+		Select("pipeline_runs.id, pipeline_runs.created_at, pipeline_runs.status, pipeline_runs.run_number, pipeline_runs.branch, "+
+			"pipeline_runs.trigger_type, pipeline_runs.pipeline_id, pipeline_runs.user_id, pipelines.name AS pipeline_name").
+		Joins("JOIN pipelines ON pipelines.id = pipeline_runs.pipeline_id").
+		Where("pipelines.project_id = ? AND pipeline_runs.deleted_at IS NULL", q.ProjectID)
+	if err := window(query, "pipeline_runs", TypeRun, q, after, limit).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询运行记录失败: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		summary := fmt.Sprintf("流水线 %s 运行 #%d %s（%s 触发）", row.PipelineName, row.RunNumber, row.Status, row.TriggerType)
+		if row.Branch != "" {
+			summary += "，分支 " + row.Branch
+		}
+		userID := row.UserID
+		entries = append(entries, Entry{
+			Type:    TypeRun,
+			ID:      row.ID,
+			Time:    row.CreatedAt,
+			Action:  row.Status,
+			ActorID: &userID,
+			Summary: summary,
+			Link:    fmt.Sprintf("/api/v1/pipelines/%d/runs/%d", row.PipelineID, row.ID),
+		})
+	}
+	return entries, nil
+}
+
+// fetchDeployments 项目的部署记录
+func fetchDeployments(db *gorm.DB, q *Query, after *Cursor, limit int) ([]Entry, error) {
+	var rows []models.Deployment
+	query := db.Table("deployments").
+		Select("id, created_at, version, status, environment, user_id").
+		Where("project_id = ? AND deleted_at IS NULL", q.ProjectID)
+	if err := window(query, "deployments", TypeDeployment, q, after, limit).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询部署记录失败: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		summary := fmt.Sprintf("部署 %s %s", row.Version, row.Status)
+		if row.Environment != "" {
+			summary = fmt.Sprintf("部署 %s 到 %s %s", row.Version, row.Environment, row.Status)
+		}
+		userID := row.UserID
+		entries = append(entries, Entry{
+			Type:    TypeDeployment,
+			ID:      row.ID,
+			Time:    row.CreatedAt,
+			Action:  row.Status,
+			ActorID: &userID,
+			Summary: summary,
+			Link:    fmt.Sprintf("/api/v1/projects/%d/deployments/%d", q.ProjectID, row.ID),
+		})
+	}
+	return entries, nil
+}
+
+// fetchAudits 审计日志中针对项目、项目的流水线及其运行的操作
+func fetchAudits(db *gorm.DB, q *Query, after *Cursor, limit int) ([]Entry, error) {
+	var rows []struct {
+		ID           uint
+		CreatedAt    time.Time
+		Action       string
+		ResourceType string
+		ResourceID   uint
+		Description  string
+		UserID       *uint
+		PipelineID   *uint // 资源为运行时运行所属的流水线
+	}
+	pipelines := db.Table("pipelines").Select("id").Where("project_id = ?", q.ProjectID)
+	query := db.Table("audit_logs").
+		Select("audit_logs.id, audit_logs.created_at, audit_logs.action, audit_logs.resource_type, audit_logs.resource_id, "+
+			"audit_logs.description, audit_logs.user_id, pipeline_runs.pipeline_id").
+		Joins("LEFT JOIN pipeline_runs ON audit_logs.resource_type = ? AND pipeline_runs.id = audit_logs.resource_id", "pipeline_run").
+		Where(db.Where("audit_logs.resource_type = ? AND audit_logs.resource_id = ?", "project", q.ProjectID).
+			Or("audit_logs.resource_type = ? AND audit_logs.resource_id IN (?)", "pipeline", pipelines).
+			Or("audit_logs.resource_type = ? AND pipeline_runs.pipeline_id IN (?)", "pipeline_run", pipelines))
+	if err := window(query, "audit_logs", TypeAudit, q, after, limit).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		summary := row.Description
+		if summary == "" {
+			summary = row.Action
+		}
+		var link string
+		switch {
+		case row.ResourceType == "project":
+			link = fmt.Sprintf("/api/v1/projects/%d", row.ResourceID)
+		case row.ResourceType == "pipeline":
+			link = fmt.Sprintf("/api/v1/pipelines/%d", row.ResourceID)
+		case row.PipelineID != nil:
+			link = fmt.Sprintf("/api/v1/pipelines/%d/runs/%d", *row.PipelineID, row.ResourceID)
+		}
+		entries = append(entries, Entry{
+			Type:    TypeAudit,
+			ID:      row.ID,
+			Time:    row.CreatedAt,
+			Action:  row.Action,
+			ActorID: row.UserID,
+			Summary: summary,
+			Link:    link,
+		})
+	}
+	return entries, nil
+}
+
+// fillActors 填充条目的操作用户名，已删除的用户同样显示
+func fillActors(db *gorm.DB, entries []Entry) error {
+	var ids []uint
+	for _, entry := range entries {
+		if entry.ActorID != nil {
+			ids = append(ids, *entry.ActorID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var users []models.User
+	if err := db.Unscoped().Select("id", "username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+	for i := range entries {
+		if entries[i].ActorID != nil {
+			entries[i].Actor = names[*entries[i].ActorID]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package timeline
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/config"
+	"flowforge/pkg/database"
+	"flowforge/pkg/models"
+)
+
+// timelineBase 测试记录的起始创建时间
+var timelineBase = time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+
+// fixture 两个项目的运行、部署与审计记录；web 的多条记录来自不同来源但创建时间相同
+type fixture struct {
+	project  *models.Project
+	pipeline *models.Pipeline
+	ids      map[string]uint // 记录名到来源记录ID
+}
+
+func setupTimelineTest(t *testing.T) *fixture {
+	t.Helper()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Type:         "sqlite",
+		Name:         fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+		LogLevel:     "silent",
+	}}
+	if err := database.InitDatabase(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDatabase() })
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(value interface{}) {
+		if err := database.DB.Create(value).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	create(&models.User{Username: "alice", Email: "alice@example.com", Password: "x"})
+	f := &fixture{project: &models.Project{Name: "web", Slug: "web", UserID: 1}, ids: map[string]uint{}}
+	create(f.project)
+	f.pipeline = &models.Pipeline{Name: "build", ProjectID: f.project.ID, Config: "stages: []"}
+	create(f.pipeline)
+	other := &models.Project{Name: "api", Slug: "api", UserID: 1}
+	create(other)
+	otherPipeline := &models.Pipeline{Name: "build", ProjectID: other.ID, Config: "stages: []"}
+	create(otherPipeline)
+
+	at := func(seconds int) time.Time { return timelineBase.Add(time.Duration(seconds) * time.Second) }
+	run := func(pipeline *models.Pipeline, seconds int) uint {
+		r := &models.PipelineRun{PipelineID: pipeline.ID, UserID: 1, RunNumber: seconds + 1, Status: models.RunStatusSuccess, TriggerType: models.TriggerManual}
+		r.CreatedAt = at(seconds)
+		create(r)
+		return r.ID
+	}
+	deployment := func(project *models.Project, seconds int) uint {
+		d := &models.Deployment{ProjectID: project.ID, UserID: 1, Version: "v1", Status: "success"}
+		d.CreatedAt = at(seconds)
+		create(d)
+		return d.ID
+	}
+	userID := uint(1)
+	audit := func(resourceType string, resourceID uint, description string, seconds int) uint {
+		a := &models.AuditLog{Action: "update", ResourceType: resourceType, ResourceID: resourceID, Description: description, UserID: &userID}
+		a.CreatedAt = at(seconds)
+		create(a)
+		return a.ID
+	}
+
+	// 名称的首字母表示来源：r 运行，d 部署，a 审计
+	f.ids["r1"] = run(f.pipeline, 0)
+	f.ids["a0"] = audit("pipeline_run", f.ids["r1"], "取消运行", 0)
+	f.ids["d1"] = deployment(f.project, 1)
+	f.ids["a1"] = audit("project", f.project.ID, "添加成员 bob", 1)
+	f.ids["r3"] = run(f.pipeline, 2)
+	f.ids["d2"] = deployment(f.project, 2)
+	f.ids["a2"] = audit("pipeline", f.pipeline.ID, "更新流水线配置", 2)
+	f.ids["r4"] = run(f.pipeline, 2)
+
+	// 其他项目同一时间的记录不出现在 web 的时间线中
+	run(otherPipeline, 2)
+	deployment(other, 2)
+	audit("pipeline", otherPipeline.ID, "更新流水线配置", 2)
+	audit("project", other.ID, "添加成员 bob", 1)
+	return f
+}
+
+// names 把条目还原为 fixture 中的记录名
+func (f *fixture) names(entries []Entry) []string {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = fmt.Sprintf("%s %d", entry.Type, entry.ID)
+		for name, id := range f.ids {
+			if id == entry.ID && entry.Type == Types[strings.IndexByte("rda", name[0])] {
+				names[i] = name
+			}
+		}
+	}
+	return names
+}
+
+// readAll 按每页 limit 条读取全部时间线，between 在读完第一页后调用
+func readAll(t *testing.T, q Query, limit int, between func()) []Entry {
+	t.Helper()
+	q.Limit = limit
+	var entries []Entry
+	for pages := 0; ; pages++ {
+		if pages > 50 {
+			t.Fatal("游标分页没有结束")
+		}
+		page, err := Load(database.DB, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, page.Entries...)
+		if page.NextCursor == "" {
+			return entries
+		}
+		if q.Cursor, err = DecodeCursor(page.NextCursor); err != nil {
+			t.Fatal(err)
+		}
+		if pages == 0 && between != nil {
+			between()
+		}
+	}
+}
+
+// TestTimelineOrderAcrossSources 不同来源的记录按时间倒序合并，时间相同时按运行、部署、审计的顺序，
+// 同一来源再按ID倒序；任意每页条数翻页的结果与一次读取相同，不重复也不遗漏，翻页期间新增的记录不影响后续页
+func TestTimelineOrderAcrossSources(t *testing.T) {
+	f := setupTimelineTest(t)
+	want := []string{"r4", "r3", "d2", "a2", "d1", "a1", "r1", "a0"}
+
+	for _, limit := range []int{1, 2, 3, 5, 100} {
+		t.Run(fmt.Sprintf("每页%d条", limit), func(t *testing.T) {
+			got := f.names(readAll(t, Query{ProjectID: f.project.ID}, limit, nil))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("时间线顺序为 %v，应为 %v", got, want)
+			}
+		})
+	}
+
+	got := f.names(readAll(t, Query{ProjectID: f.project.ID}, 3, func() {
+		d := &models.Deployment{ProjectID: f.project.ID, UserID: 1, Version: "late", Status: "success"}
+		d.CreatedAt = timelineBase.Add(time.Hour)
+		database.DB.Create(d)
+		r := &models.PipelineRun{PipelineID: f.pipeline.ID, UserID: 1, RunNumber: 99, Status: models.RunStatusSuccess, TriggerType: models.TriggerManual}
+		r.CreatedAt = timelineBase.Add(2 * time.Second)
+		database.DB.Create(r)
+	}))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("翻页期间新增记录后时间线为 %v，应为 %v", got, want)
+	}
+}
+
+// TestTimelineFilters 按类型、时间范围（不含结束时间）与摘要文本过滤，文本不区分大小写
+func TestTimelineFilters(t *testing.T) {
+	f := setupTimelineTest(t)
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"部署与审计", Query{Types: []string{TypeDeployment, TypeAudit}}, []string{"d2", "a2", "d1", "a1", "a0"}},
+		{"时间范围", Query{From: timelineBase.Add(time.Second), To: timelineBase.Add(2 * time.Second)}, []string{"d1", "a1"}},
+		{"摘要文本", Query{Search: "成员"}, []string{"a1"}},
+		{"不区分大小写", Query{Search: "BUILD", Types: []string{TypeRun}}, []string{"r4", "r3", "r1"}},
+		{"没有匹配", Query{Search: "不存在"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.ProjectID = f.project.ID
+			got := f.names(readAll(t, tt.query, 2, nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("过滤结果为 %v，应为 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTimelineEntries 条目带操作用户名与来源记录的接口地址
+func TestTimelineEntries(t *testing.T) {
+	f := setupTimelineTest(t)
+	page, err := Load(database.DB, Query{ProjectID: f.project.ID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"r4": fmt.Sprintf("/api/v1/pipelines/%d/runs/%d", f.pipeline.ID, f.ids["r4"]),
+		"d2": fmt.Sprintf("/api/v1/projects/%d/deployments/%d", f.project.ID, f.ids["d2"]),
+		"a2": fmt.Sprintf("/api/v1/pipelines/%d", f.pipeline.ID),
+		"a1": fmt.Sprintf("/api/v1/projects/%d", f.project.ID),
+		"a0": fmt.Sprintf("/api/v1/pipelines/%d/runs/%d", f.pipeline.ID, f.ids["r1"]),
+	}
+	for i, name := range f.names(page.Entries) {
+		entry := page.Entries[i]
+		if entry.Actor != "alice" {
+			t.Errorf("条目 %s 的操作用户为 %q", name, entry.Actor)
+		}
+		if link, ok := links[name]; ok && entry.Link != link {
+			t.Errorf("条目 %s 的地址为 %q，应为 %q", name, entry.Link, link)
+		}
+	}
+}
+
+// TestTimelineBoundedScan 搜索过滤掉大部分记录时每个来源一次最多读取 maxScan 条：本页在达到上限的来源已读取的位置截止，
+// 按游标继续读取时得到更早的匹配记录
+func TestTimelineBoundedScan(t *testing.T) {
+	f := setupTimelineTest(t)
+	runs := make([]models.PipelineRun, maxScan+batchSize)
+	for i := range runs {
+		runs[i] = models.PipelineRun{PipelineID: f.pipeline.ID, UserID: 1, RunNumber: 100 + i, Status: models.RunStatusSuccess, TriggerType: models.TriggerManual}
+		runs[i].CreatedAt = timelineBase.Add(time.Hour + time.Duration(i)*time.Second)
+	}
+	if err := database.DB.CreateInBatches(runs, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	q := Query{ProjectID: f.project.ID, Search: "成员", Limit: 20}
+	page, err := Load(database.DB, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 0 || page.NextCursor == "" {
+		t.Fatalf("第一页有 %d 条、下一页游标 %q，运行达到读取上限时应截止并返回游标", len(page.Entries), page.NextCursor)
+	}
+	if got := f.names(readAll(t, q, 20, nil)); !reflect.DeepEqual(got, []string{"a1"}) {
+		t.Errorf("按游标继续读取得到 %v，应为 [a1]", got)
+	}
+}
+
+// TestTimelineCursor 游标编码往返不变，格式错误或类型未知的游标返回 ErrInvalidCursor
+func TestTimelineCursor(t *testing.T) {
+	cursor := &Cursor{Time: timelineBase.Add(1500 * time.Millisecond), Type: TypeDeployment, ID: 42}
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil || !decoded.Time.Equal(cursor.Time) || decoded.Type != cursor.Type || decoded.ID != cursor.ID {
+		t.Errorf("解析得到 %+v（%v），应为 %+v", decoded, err, cursor)
+	}
+	if decoded, err := DecodeCursor(""); decoded != nil || err != nil {
+		t.Errorf("空游标解析得到 %+v（%v）", decoded, err)
+	}
+	unknown := (&Cursor{Time: timelineBase, Type: "build", ID: 1}).Encode()
+	for _, value := range []string{"not-base64!", "bm8tY29sb25z", unknown} {
+		if _, err := DecodeCursor(value); !errors.Is(err, database.ErrInvalidCursor) {
+			t.Errorf("游标 %q 返回 %v，应为 ErrInvalidCursor", value, err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// deriveKey 从配置的密钥字符串派生AES-256密钥
+func deriveKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// EncryptString 使用AES-GCM加密字符串，返回base64编码的密文
+func EncryptString(plaintext, key string) (string, error) {
+	block, err := aes.NewCipher(deriveKey(key))
+	if err != nil {
+		return "", fmt.Errorf("创建加密器失败: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("创建GCM失败: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptString 解密由EncryptString生成的密文
+func DecryptString(encoded, key string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("解码密文失败: %w", err)
+	}
+
+	block, err := aes.NewCipher(deriveKey(key))
+	if err != nil {
+		return "", fmt.Errorf("创建解密器失败: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("创建GCM失败: %w", err)
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("密文长度无效")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// 流式加密格式：文件以 streamMagic 开头，之后每块为 1 字节结束标记、4 字节密文长度、随机数与 AES-GCM 密文，
+// 块序号与结束标记作为附加数据，调换、删除块或截断文件都会导致解密失败
+const (
+	streamMagic     = "FFENC1\n"
+	streamChunkSize = 64 << 10
+)
+
+// ErrStreamTruncated 加密数据在结束块之前中断
+var ErrStreamTruncated = errors.New("加密数据不完整")
+
+// newGCM 按配置的密钥创建AES-GCM
+func newGCM(key string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建GCM失败: %w", err)
+	}
+	return gcm, nil
+}
+
+// chunkAAD 块的附加数据：块序号与结束标记
+func chunkAAD(seq uint64, final bool) []byte {
+	aad := make([]byte, 9)
+	binary.BigEndian.PutUint64(aad, seq)
+	if final {
+		aad[8] = 1
+	}
+	return aad
+}
+
+// encryptWriter 分块加密写入，Close 时写出结束块
+type encryptWriter struct {
+	w   io.Writer
+	gcm cipher.AEAD
+	buf []byte
+	seq uint64
+}
+
+// NewEncryptWriter 返回将数据分块加密后写入 w 的 Writer，用于加密备份等较大的数据；
+// 必须调用 Close 写出结束块，Close 不关闭 w
+func NewEncryptWriter(w io.Writer, key string) (io.WriteCloser, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, streamMagic); err != nil {
+		return nil, fmt.Errorf("写入密文失败: %w", err)
+	}
+	return &encryptWriter{w: w, gcm: gcm, buf: make([]byte, 0, streamChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// 缓冲区满时才写出，保证最后一块总是在 Close 时作为结束块写出
+		if len(e.buf) == streamChunkSize {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):streamChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.flush(true)
+}
+
+func (e *encryptWriter) flush(final bool) error {
+	nonce := make([]byte, e.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := e.gcm.Seal(nil, nonce, e.buf, chunkAAD(e.seq, final))
+
+	header := make([]byte, 5)
+	if final {
+		header[0] = 1
+	}
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+	for _, part := range [][]byte{header, nonce, sealed} {
+		if _, err := e.w.Write(part); err != nil {
+			return fmt.Errorf("写入密文失败: %w", err)
+		}
+	}
+	e.seq++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decryptReader 逐块解密由 encryptWriter 写入的数据
+type decryptReader struct {
+	r    io.Reader
+	gcm  cipher.AEAD
+	buf  []byte
+	seq  uint64
+	done bool
+}
+
+// NewDecryptReader 返回解密由 NewEncryptWriter 写入的数据的 Reader，数据被篡改时读取返回错误，
+// 在结束块之前中断时返回 ErrStreamTruncated
+func NewDecryptReader(r io.Reader, key string) (io.Reader, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(streamMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != streamMagic {
+		return nil, fmt.Errorf("密文格式无效")
+	}
+	return &decryptReader{r: r, gcm: gcm}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrStreamTruncated
+		}
+		return fmt.Errorf("读取密文失败: %w", err)
+	}
+	final := header[0] == 1
+	size := int(binary.BigEndian.Uint32(header[1:]))
+	if size < d.gcm.Overhead() || size > streamChunkSize+d.gcm.Overhead() {
+		return fmt.Errorf("密文长度无效")
+	}
+
+	frame := make([]byte, d.gcm.NonceSize()+size)
+	if _, err := io.ReadFull(d.r, frame); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrStreamTruncated
+		}
+		return fmt.Errorf("读取密文失败: %w", err)
+	}
+	plain, err := d.gcm.Open(nil, frame[:d.gcm.NonceSize()], frame[d.gcm.NonceSize():], chunkAAD(d.seq, final))
+	if err != nil {
+		return fmt.Errorf("解密失败: %w", err)
+	}
+
+	d.seq++
+	d.buf = plain
+	d.done = final
+	return nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DiskUsage 磁盘空间信息（字节）
+type DiskUsage struct {
+	Path  string `json:"path"`
+	Total uint64 `json:"total"`
+	Free  uint64 `json:"free"`
+	Used  uint64 `json:"used"`
+}
+
+// GetDiskUsage 获取路径所在卷的磁盘空间，路径不存在时使用最近的已存在上级目录
+func GetDiskUsage(path string) (*DiskUsage, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	total, free, err := diskSpace(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskUsage{
+		Path:  dir,
+		Total: total,
+		Free:  free,
+		Used:  total - free,
+	}, nil
+}
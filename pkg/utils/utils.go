@@ -10,12 +10,23 @@ import (
 	"strings"
 	"time"
 
+	"flowforge/pkg/i18n"
+
 	"github.com/gin-gonic/gin"
 )
 
-// ErrorResponse 返回错误响应
+// ErrorResponse 返回错误响应，消息按请求语言翻译；已收录的消息附带与语言无关的错误码
 func ErrorResponse(c *gin.Context, code int, message string) {
-	c.JSON(code, gin.H{"error": message})
+	c.JSON(code, ErrorBody(i18n.FromContext(c), message))
+}
+
+// ErrorBody 按语言生成错误响应体，用于无法通过 gin.Context 写出的响应
+func ErrorBody(locale, message string) gin.H {
+	body := gin.H{"error": i18n.Translate(locale, message)}
+	if key := i18n.Code(message); key != "" {
+		body["code"] = key
+	}
+	return body
 }
 
 // SuccessResponse 返回成功响应
@@ -35,9 +46,14 @@ func EnsureDir(path string) error {
 	return os.MkdirAll(path, 0755)
 }
 
-// FormatTime 格式化时间
+// FormatTime 将时间格式化为 UTC 的 RFC3339 字符串，接口返回的时间统一使用该格式
 func FormatTime(t time.Time) string {
-	return t.Format("2006-01-02 15:04:05")
+	return t.UTC().Format(time.RFC3339)
+}
+
+// DurationMs 时长的毫秒数，接口中的耗时统一以毫秒返回（duration_ms）
+func DurationMs(d time.Duration) int64 {
+	return d.Milliseconds()
 }
 
 // GetFileExtension 获取文件扩展名
@@ -95,4 +111,70 @@ func CopyFile(src, dst string) error {
 		return err
 	}
 	return os.WriteFile(dst, input, 0644)
+}
+
+// CopyDir 递归复制目录
+func CopyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if err := CopyFile(path, target); err != nil {
+				return err
+			}
+			return os.Chmod(target, info.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
+
+// maxSlugLength slug 的最大长度（不含去重后缀）
+const maxSlugLength = 64
+
+// Slugify 将名称转换为URL安全的slug：小写字母、数字与连字符；
+// 名称中没有可用字符时返回 project，纯数字时加 project- 前缀以免与ID混淆
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+	}
+	slug = strings.Trim(slug, "-")
+	if slug == "" {
+		return "project"
+	}
+	if strings.Trim(slug, "0123456789") == "" {
+		return "project-" + slug
+	}
+	return slug
 }
\ No newline at end of file
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// pushPayload 计算指纹所需的推送事件字段，兼容 GitHub / Gitea / GitLab
+type pushPayload struct {
+	Ref         string `json:"ref"`
+	After       string `json:"after"`
+	CheckoutSHA string `json:"checkout_sha"`
+	HeadCommit  struct {
+		ID string `json:"id"`
+	} `json:"head_commit"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		URL      string `json:"url"`
+	} `json:"repository"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+// Fingerprint 计算投递指纹：优先使用平台投递ID，否则使用仓库+分支+头提交的哈希；无法识别时返回空字符串
+func Fingerprint(header http.Header, body []byte) string {
+	if id := DeliveryID(header); id != "" {
+		return "delivery:" + id
+	}
+
+	var payload pushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+
+	repo := firstNonEmpty(payload.Repository.FullName, payload.Project.PathWithNamespace, payload.Repository.URL)
+	commit := firstNonEmpty(payload.HeadCommit.ID, payload.CheckoutSHA, payload.After)
+	if repo == "" || payload.Ref == "" || commit == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(repo + "\n" + payload.Ref + "\n" + commit))
+	return "payload:" + hex.EncodeToString(sum[:])
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
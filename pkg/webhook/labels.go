@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"flowforge/pkg/models"
+)
+
+// LabelRule Webhook 的运行标签规则：推送的 ref 匹配 Ref 时运行带上 Label
+type LabelRule struct {
+	Ref   string
+	Label string
+}
+
+// ParseLabelRules 解析逗号分隔的 ref glob=标签 规则
+func ParseLabelRules(rules string) ([]LabelRule, error) {
+	var parsed []LabelRule
+	for _, rule := range strings.Split(rules, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		ref, label, ok := strings.Cut(rule, "=")
+		ref, label = strings.TrimSpace(ref), strings.TrimSpace(label)
+		if !ok || ref == "" || label == "" {
+			return nil, fmt.Errorf("标签规则格式应为 ref=标签: %s", rule)
+		}
+		parsed = append(parsed, LabelRule{Ref: ref, Label: label})
+	}
+	return parsed, nil
+}
+
+// MatchLabels 投递的 ref 匹配的标签（去重），ref 同 MatchPath 的 glob 语法，如 refs/tags/**；
+// 规则无效或投递没有 ref 时返回空
+func MatchLabels(hook *models.Webhook, body []byte) []string {
+	return MatchRefLabels(hook, EventRef(body))
+}
+
+// MatchRefLabels ref 匹配的标签（去重），规则同 MatchLabels
+func MatchRefLabels(hook *models.Webhook, ref string) []string {
+	rules, err := ParseLabelRules(hook.LabelRules)
+	if err != nil || len(rules) == 0 || ref == "" {
+		return nil
+	}
+
+	var labels []string
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if MatchPath(rule.Ref, ref) && !seen[rule.Label] {
+			seen[rule.Label] = true
+			labels = append(labels, rule.Label)
+		}
+	}
+	return labels
+}
+
+// EventRef 投递对应的 ref：推送事件取 ref，PR/MR 取源分支并补全为 refs/heads/ 形式
+func EventRef(body []byte) string {
+	var payload struct {
+		Ref         string `json:"ref"`
+		PullRequest *struct {
+			Head struct {
+				Ref string `json:"ref"`
+			} `json:"head"`
+		} `json:"pull_request"`
+		ObjectAttributes *struct {
+			SourceBranch string `json:"source_branch"`
+		} `json:"object_attributes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+
+	branch := ""
+	switch {
+	case payload.Ref != "":
+		return payload.Ref
+	case payload.PullRequest != nil:
+		branch = payload.PullRequest.Head.Ref
+	case payload.ObjectAttributes != nil:
+		branch = payload.ObjectAttributes.SourceBranch
+	}
+	if branch == "" {
+		return ""
+	}
+	return "refs/heads/" + branch
+}
@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+
+	"flowforge/pkg/models"
+)
+
+// commitsPayload 推送事件中各提交变更的文件，GitHub / Gitea / GitLab 格式相同
+type commitsPayload struct {
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+// ChangedFiles 推送事件中变更的文件（去重），投递不包含提交列表时 ok 为 false
+func ChangedFiles(body []byte) (files []string, ok bool) {
+	var payload commitsPayload
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.Commits) == 0 {
+		return nil, false
+	}
+
+	seen := make(map[string]bool)
+	for _, commit := range payload.Commits {
+		for _, list := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, file := range list {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files, true
+}
+
+// PathFilters Webhook 配置的路径过滤规则
+func PathFilters(hook *models.Webhook) []string {
+	var filters []string
+	for _, filter := range strings.Split(hook.PathFilters, ",") {
+		if filter = strings.TrimSpace(filter); filter != "" {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// FilterPaths 判断推送是否通过路径过滤：有文件匹配任一规则时通过。未配置过滤、
+// 或投递中无法得到变更文件（如 PR 事件）时总是通过；未通过时返回说明原因
+func FilterPaths(hook *models.Webhook, body []byte) (bool, string) {
+	files, ok := ChangedFiles(body)
+	return FilterFiles(hook, files, ok)
+}
+
+// FilterFiles 按变更文件判断是否通过路径过滤，规则同 FilterPaths；ok 为 false 表示无法得到变更文件
+func FilterFiles(hook *models.Webhook, files []string, ok bool) (bool, string) {
+	filters := PathFilters(hook)
+	if len(filters) == 0 || !ok {
+		return true, ""
+	}
+
+	for _, file := range files {
+		for _, filter := range filters {
+			if MatchPath(filter, file) {
+				return true, ""
+			}
+		}
+	}
+	return false, "path filter excluded: " + strings.Join(filters, ", ")
+}
+
+// MatchPath 按 glob 匹配仓库内的文件路径，** 匹配任意层目录（包括零层），其余语法同 path.Match
+func MatchPath(pattern, file string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+// matchSegments 逐段匹配路径
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(file); skip++ {
+				if matchSegments(pattern[1:], file[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], file[0]); err != nil || !matched {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
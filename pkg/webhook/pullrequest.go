@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"encoding/json"
+)
+
+// pullRequestPayload 判断PR来源所需的字段，兼容 GitHub / Gitea / GitLab
+type pullRequestPayload struct {
+	PullRequest *struct {
+		Head struct {
+			SHA  string `json:"sha"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	ObjectAttributes *struct {
+		SourceProjectID int `json:"source_project_id"`
+		TargetProjectID int `json:"target_project_id"`
+		LastCommit      struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// IsForkPullRequest 投递是否为来自fork仓库的PR/MR事件
+func IsForkPullRequest(body []byte) bool {
+	var payload pullRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return false
+	}
+
+	if pr := payload.PullRequest; pr != nil {
+		return pr.Head.Repo.FullName != pr.Base.Repo.FullName
+	}
+	if mr := payload.ObjectAttributes; mr != nil && mr.SourceProjectID != 0 {
+		return mr.SourceProjectID != mr.TargetProjectID
+	}
+	return false
+}
+
+// HeadCommit 提取投递触发的提交：PR/MR 取源分支头提交，推送事件取推送后的头提交
+func HeadCommit(body []byte) string {
+	var pr pullRequestPayload
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return ""
+	}
+	if pr.PullRequest != nil && pr.PullRequest.Head.SHA != "" {
+		return pr.PullRequest.Head.SHA
+	}
+	if pr.ObjectAttributes != nil && pr.ObjectAttributes.LastCommit.ID != "" {
+		return pr.ObjectAttributes.LastCommit.ID
+	}
+
+	var push pushPayload
+	json.Unmarshal(body, &push)
+	commit := firstNonEmpty(push.HeadCommit.ID, push.CheckoutSHA, push.After)
+	// 删除分支的推送 after 为全零
+	if commit == "0000000000000000000000000000000000000000" {
+		return ""
+	}
+	return commit
+}
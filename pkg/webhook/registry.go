@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// ErrRegistryPayload 请求体不是镜像仓库的推送事件
+var ErrRegistryPayload = errors.New("无法解析镜像仓库推送事件")
+
+// RegistryPush 镜像仓库推送的一个镜像
+type RegistryPush struct {
+	Repository string `json:"repository"` // 含仓库地址，如 harbor.example.com/library/app
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+}
+
+// Reference 推送的镜像引用，标签与摘要都有时固定到摘要
+func (p RegistryPush) Reference() string {
+	ref := p.Repository
+	if p.Tag != "" {
+		ref += ":" + p.Tag
+	}
+	if p.Digest != "" {
+		ref += "@" + p.Digest
+	}
+	return ref
+}
+
+// Fingerprint 推送的去重指纹，镜像仓库重投同一事件时不重复部署
+func (p RegistryPush) Fingerprint() string {
+	sum := sha256.Sum256([]byte(p.Repository + "\n" + p.Tag + "\n" + p.Digest))
+	return "registry:" + hex.EncodeToString(sum[:])
+}
+
+// harborPayload Harbor 的 Webhook 事件，推送镜像时 type 为 PUSH_ARTIFACT
+type harborPayload struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Digest      string `json:"digest"`
+			Tag         string `json:"tag"`
+			ResourceURL string `json:"resource_url"` // harbor.example.com/library/app:v1
+		} `json:"resources"`
+		Repository struct {
+			RepoFullName string `json:"repo_full_name"` // library/app
+		} `json:"repository"`
+	} `json:"event_data"`
+}
+
+// dockerRegistryEnvelope Docker Registry（distribution）的通知，一次投递可能包含多个事件
+type dockerRegistryEnvelope struct {
+	Events []struct {
+		ID     string `json:"id"`
+		Action string `json:"action"`
+		Target struct {
+			MediaType  string `json:"mediaType"`
+			Digest     string `json:"digest"`
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+		} `json:"target"`
+		Request struct {
+			Host string `json:"host"`
+		} `json:"request"`
+	} `json:"events"`
+}
+
+// RegistryEventType 镜像仓库事件的类型，推送镜像统一为 push，与 Webhook 订阅的事件比较
+func RegistryEventType(registryType string, body []byte) string {
+	switch registryType {
+	case models.RegistryTypeHarbor:
+		var payload harborPayload
+		if json.Unmarshal(body, &payload) != nil || payload.Type == "" {
+			return ""
+		}
+		// PUSH_ARTIFACT、DELETE_ARTIFACT 等统一为 push、delete
+		return strings.ToLower(strings.TrimSuffix(payload.Type, "_ARTIFACT"))
+	case models.RegistryTypeDocker:
+		var envelope dockerRegistryEnvelope
+		if json.Unmarshal(body, &envelope) != nil || len(envelope.Events) == 0 {
+			return ""
+		}
+		for _, event := range envelope.Events {
+			if event.Action == "push" {
+				return "push"
+			}
+		}
+		return envelope.Events[0].Action
+	}
+	return ""
+}
+
+// RegistryPushes 解析推送事件中的镜像，按事件中的顺序返回；Docker Registry 推送层文件的事件没有清单，不返回
+func RegistryPushes(registryType string, body []byte) ([]RegistryPush, error) {
+	var pushes []RegistryPush
+	switch registryType {
+	case models.RegistryTypeHarbor:
+		var payload harborPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRegistryPayload, err)
+		}
+		if payload.Type != "PUSH_ARTIFACT" {
+			return nil, nil
+		}
+		for _, resource := range payload.EventData.Resources {
+			repository := harborRepository(resource.ResourceURL)
+			if repository == "" {
+				repository = payload.EventData.Repository.RepoFullName
+			}
+			if repository == "" || (resource.Tag == "" && resource.Digest == "") {
+				continue
+			}
+			pushes = append(pushes, RegistryPush{Repository: repository, Tag: resource.Tag, Digest: resource.Digest})
+		}
+	case models.RegistryTypeDocker:
+		var envelope dockerRegistryEnvelope
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRegistryPayload, err)
+		}
+		for _, event := range envelope.Events {
+			target := event.Target
+			if event.Action != "push" || target.Repository == "" || !strings.Contains(target.MediaType, "manifest") {
+				continue
+			}
+			repository := target.Repository
+			if event.Request.Host != "" {
+				repository = event.Request.Host + "/" + repository
+			}
+			pushes = append(pushes, RegistryPush{Repository: repository, Tag: target.Tag, Digest: target.Digest})
+		}
+	default:
+		return nil, fmt.Errorf("%w: 不支持的镜像仓库类型 %s", ErrRegistryPayload, registryType)
+	}
+	return pushes, nil
+}
+
+// harborRepository 从 resource_url 中去掉标签与摘要，得到含仓库地址的镜像仓库名
+func harborRepository(resourceURL string) string {
+	ref := resourceURL
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// verifyRegistryToken 校验镜像仓库 Webhook 请求头中的令牌。镜像仓库不对请求签名：Harbor 原样发送策略中配置的
+// Auth Header，可以是密钥本身或 Bearer <密钥>；Docker Registry 发送通知端点配置的 Authorization: Bearer <密钥>
+func verifyRegistryToken(hook *models.Webhook, header http.Header, now time.Time) (string, error) {
+	authorization := strings.TrimSpace(header.Get("Authorization"))
+	token, bearer := strings.CutPrefix(authorization, "Bearer ")
+	switch hook.RegistryType {
+	case models.RegistryTypeHarbor:
+	case models.RegistryTypeDocker:
+		if !bearer {
+			token = ""
+		}
+	default:
+		return "", fmt.Errorf("不支持的镜像仓库类型 %s", hook.RegistryType)
+	}
+	if token == "" {
+		return "", ErrSignatureMissing
+	}
+
+	candidates := []secretCandidate{{models.SecretMatchCurrent, hook.Secret}}
+	if hook.PreviousSecret != "" && hook.PreviousSecretExpiresAt != nil && now.Before(*hook.PreviousSecretExpiresAt) {
+		candidates = append(candidates, secretCandidate{models.SecretMatchPrevious, hook.PreviousSecret})
+	}
+	for _, candidate := range candidates {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(candidate.secret)) == 1 {
+			return candidate.name, nil
+		}
+	}
+	return "", ErrSignatureMismatch
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+const fixtureDigest = "sha256:9b2a7c4f1e3d5a6b8c0d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c6d8e0f2a4b"
+
+// readFixture testdata 中镜像仓库投递的请求体
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// TestRegistryPushes Harbor 与 Docker Registry 的推送事件解析为含仓库地址的镜像；Docker Registry 推送层文件与拉取的事件、
+// Harbor 删除镜像的事件不返回镜像
+func TestRegistryPushes(t *testing.T) {
+	tests := []struct {
+		fixture      string
+		registryType string
+		event        string
+		want         []RegistryPush
+	}{
+		{"harbor.push_artifact.json", models.RegistryTypeHarbor, "push",
+			[]RegistryPush{{Repository: "harbor.example.com/library/app", Tag: "v1.3.0", Digest: fixtureDigest}}},
+		{"harbor.delete_artifact.json", models.RegistryTypeHarbor, "delete", nil},
+		{"docker_registry.push.json", models.RegistryTypeDocker, "push",
+			[]RegistryPush{{Repository: "registry.example.com:5000/library/app", Tag: "v1.3.0", Digest: fixtureDigest}}},
+		{"docker_registry.pull.json", models.RegistryTypeDocker, "pull", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body := readFixture(t, tt.fixture)
+			if event := RegistryEventType(tt.registryType, body); event != tt.event {
+				t.Errorf("事件类型为 %q，应为 %q", event, tt.event)
+			}
+			pushes, err := RegistryPushes(tt.registryType, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pushes, tt.want) {
+				t.Errorf("推送的镜像为 %+v，应为 %+v", pushes, tt.want)
+			}
+		})
+	}
+
+	if _, err := RegistryPushes(models.RegistryTypeHarbor, []byte("not json")); !errors.Is(err, ErrRegistryPayload) {
+		t.Errorf("请求体无法解析时返回 %v，应为 ErrRegistryPayload", err)
+	}
+	if _, err := RegistryPushes("quay", readFixture(t, "harbor.push_artifact.json")); !errors.Is(err, ErrRegistryPayload) {
+		t.Errorf("不支持的镜像仓库类型返回 %v，应为 ErrRegistryPayload", err)
+	}
+}
+
+// TestRegistryPushReference 标签与摘要都有时引用固定到摘要；重投同一推送的指纹相同，不同标签的指纹不同
+func TestRegistryPushReference(t *testing.T) {
+	push := RegistryPush{Repository: "harbor.example.com/library/app", Tag: "v1.3.0", Digest: fixtureDigest}
+	if ref := push.Reference(); ref != "harbor.example.com/library/app:v1.3.0@"+fixtureDigest {
+		t.Errorf("镜像引用为 %s", ref)
+	}
+	if ref := (RegistryPush{Repository: "app", Digest: fixtureDigest}).Reference(); ref != "app@"+fixtureDigest {
+		t.Errorf("只有摘要的镜像引用为 %s", ref)
+	}
+	retag := push
+	retag.Tag = "latest"
+	if push.Fingerprint() != push.Fingerprint() || push.Fingerprint() == retag.Fingerprint() || !strings.HasPrefix(push.Fingerprint(), "registry:") {
+		t.Errorf("指纹为 %s 与 %s，同一推送应相同、不同标签应不同", push.Fingerprint(), retag.Fingerprint())
+	}
+}
+
+// TestVerifyRegistryToken Harbor 接受密钥本身或 Bearer <密钥>，Docker Registry 只接受 Bearer <密钥>；
+// 轮换后的旧密钥在过期前仍然有效
+func TestVerifyRegistryToken(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	expired := now.Add(-time.Minute)
+	hook := func(registryType string, previousExpires *time.Time) *models.Webhook {
+		return &models.Webhook{RegistryType: registryType, Secret: "s3cret", PreviousSecret: "old", PreviousSecretExpiresAt: previousExpires}
+	}
+	tests := []struct {
+		name          string
+		hook          *models.Webhook
+		authorization string
+		match         string
+		err           error
+	}{
+		{"Harbor 密钥", hook(models.RegistryTypeHarbor, nil), "s3cret", models.SecretMatchCurrent, nil},
+		{"Harbor Bearer", hook(models.RegistryTypeHarbor, nil), "Bearer s3cret", models.SecretMatchCurrent, nil},
+		{"Harbor 旧密钥", hook(models.RegistryTypeHarbor, &expires), "old", models.SecretMatchPrevious, nil},
+		{"Harbor 旧密钥已过期", hook(models.RegistryTypeHarbor, &expired), "old", "", ErrSignatureMismatch},
+		{"Harbor 密钥错误", hook(models.RegistryTypeHarbor, nil), "Bearer wrong", "", ErrSignatureMismatch},
+		{"Harbor 没有令牌", hook(models.RegistryTypeHarbor, nil), "", "", ErrSignatureMissing},
+		{"Docker Registry Bearer", hook(models.RegistryTypeDocker, nil), "Bearer s3cret", models.SecretMatchCurrent, nil},
+		{"Docker Registry 不带 Bearer", hook(models.RegistryTypeDocker, nil), "s3cret", "", ErrSignatureMissing},
+		{"Docker Registry 密钥错误", hook(models.RegistryTypeDocker, nil), "Bearer wrong", "", ErrSignatureMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.authorization != "" {
+				header.Set("Authorization", tt.authorization)
+			}
+			match, err := VerifyRequest(tt.hook, header, readFixture(t, "harbor.push_artifact.json"), now)
+			if match != tt.match || !errors.Is(err, tt.err) {
+				t.Errorf("返回 %q（%v），应为 %q（%v）", match, err, tt.match, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"flowforge/pkg/models"
+)
+
+// ErrSignatureMismatch 签名与当前及旧密钥均不匹配
+var ErrSignatureMismatch = errors.New("签名校验失败")
+
+// ErrSignatureMissing 请求未携带签名
+var ErrSignatureMissing = errors.New("缺少签名")
+
+// ErrSignatureRequired Webhook要求 HMAC-SHA256 签名，请求使用了明文令牌或 SHA1 签名
+var ErrSignatureRequired = errors.New("Webhook要求HMAC-SHA256签名")
+
+// secretCandidate 参与校验的密钥
+type secretCandidate struct {
+	name   string
+	secret string
+}
+
+// VerifyRequest 校验入站请求签名，返回匹配的密钥（current 或 previous）；镜像仓库的 Webhook 校验请求头中的令牌
+func VerifyRequest(hook *models.Webhook, header http.Header, body []byte, now time.Time) (string, error) {
+	if hook.RegistryType != "" {
+		return verifyRegistryToken(hook, header, now)
+	}
+	// 未配置密钥的Webhook不做校验，要求签名时拒绝
+	if hook.Secret == "" {
+		if hook.RequireSignature {
+			return "", ErrSignatureRequired
+		}
+		return "", nil
+	}
+	if hook.RequireSignature && header.Get("X-Hub-Signature-256") == "" && header.Get("X-Gitea-Signature") == "" {
+		return "", ErrSignatureRequired
+	}
+
+	candidates := []secretCandidate{{models.SecretMatchCurrent, hook.Secret}}
+	if hook.PreviousSecret != "" && hook.PreviousSecretExpiresAt != nil && now.Before(*hook.PreviousSecretExpiresAt) {
+		candidates = append(candidates, secretCandidate{models.SecretMatchPrevious, hook.PreviousSecret})
+	}
+
+	// GitLab 使用明文令牌
+	if token := header.Get("X-Gitlab-Token"); token != "" {
+		for _, candidate := range candidates {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(candidate.secret)) == 1 {
+				return candidate.name, nil
+			}
+		}
+		return "", ErrSignatureMismatch
+	}
+
+	// GitHub / Gitea 使用HMAC签名
+	signature, newHash := "", sha256.New
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		signature = strings.TrimPrefix(sig, "sha256=")
+	} else if sig := header.Get("X-Gitea-Signature"); sig != "" {
+		signature = sig
+	} else if sig := header.Get("X-Hub-Signature"); sig != "" {
+		signature, newHash = strings.TrimPrefix(sig, "sha1="), sha1.New
+	}
+	if signature == "" {
+		return "", ErrSignatureMissing
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return "", ErrSignatureMismatch
+	}
+
+	for _, candidate := range candidates {
+		mac := hmac.New(newHash, []byte(candidate.secret))
+		mac.Write(body)
+		if hmac.Equal(mac.Sum(nil), expected) {
+			return candidate.name, nil
+		}
+	}
+
+	return "", ErrSignatureMismatch
+}
+
+// EventType 从请求头识别事件类型
+func EventType(header http.Header) string {
+	if event := header.Get("X-GitHub-Event"); event != "" {
+		return event
+	}
+	if event := header.Get("X-Gitea-Event"); event != "" {
+		return event
+	}
+	if event := header.Get("X-Gitlab-Event"); event != "" {
+		// GitLab 事件形如 "Push Hook"，统一为 push
+		return strings.ReplaceAll(strings.ToLower(strings.TrimSuffix(event, " Hook")), " ", "_")
+	}
+	return "push"
+}
+
+// DeliveryID 从请求头获取平台投递ID
+func DeliveryID(header http.Header) string {
+	for _, key := range []string{"X-GitHub-Delivery", "X-Gitea-Delivery", "X-Gitlab-Event-UUID"} {
+		if id := header.Get(key); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// AcceptsEvent Webhook是否订阅了该事件
+func AcceptsEvent(hook *models.Webhook, event string) bool {
+	if hook.Events == "" || hook.Events == "*" {
+		return true
+	}
+	for _, e := range strings.Split(hook.Events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxPageSize 目录列表每页的条目数上限
+const MaxPageSize = 1000
+
+var (
+	// ErrUnsafePath 路径无效或指向工作区之外（含通过符号链接）
+	ErrUnsafePath = errors.New("工作区路径无效")
+	// ErrNotFound 工作区中不存在该路径
+	ErrNotFound = errors.New("工作区中不存在该路径")
+	// ErrNotDirectory 路径不是目录
+	ErrNotDirectory = errors.New("路径不是目录")
+	// ErrIsDirectory 路径是目录，不能作为文件读取
+	ErrIsDirectory = errors.New("路径是目录")
+	// ErrTooLarge 文件超过可查看的大小上限
+	ErrTooLarge = errors.New("文件超过可查看的大小上限")
+)
+
+// Entry 目录中的条目，符号链接不跟随，大小与修改时间为链接本身的
+type Entry struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"` // file, dir, symlink, other
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Listing 目录列表的一页，目录在前，同类按名称排序
+type Listing struct {
+	Path    string  `json:"path"`
+	Entries []Entry `json:"entries"`
+	Total   int     `json:"total"`
+}
+
+// Resolve 将相对工作区根目录的路径解析为实际路径。路径中含 .. 或 NUL 时拒绝，
+// 解析符号链接后仍须位于根目录内，返回实际路径与规范化后的相对路径（以 / 开头）
+func Resolve(root, rel string) (string, string, error) {
+	rel = strings.ReplaceAll(rel, "\\", "/")
+	if strings.ContainsRune(rel, 0) {
+		return "", "", ErrUnsafePath
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if part == ".." {
+			return "", "", fmt.Errorf("%w: %s", ErrUnsafePath, rel)
+		}
+	}
+	clean := "/" + strings.Trim(filepath.ToSlash(filepath.Clean("/"+rel)), "/")
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", "", fmt.Errorf("解析工作区根目录失败: %w", err)
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(realRoot, filepath.FromSlash(clean)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", fmt.Errorf("%w: %s", ErrNotFound, clean)
+		}
+		return "", "", err
+	}
+	if !within(realRoot, real) {
+		return "", "", fmt.Errorf("%w: %s", ErrUnsafePath, clean)
+	}
+	return real, clean, nil
+}
+
+// List 列出工作区目录的一页条目，只读取当前页条目的文件信息
+func List(root, rel string, page, pageSize int) (*Listing, error) {
+	dir, clean, err := Resolve(root, rel)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotDirectory, clean)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	start := (page - 1) * pageSize
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := start + pageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	listing := &Listing{Path: clean, Entries: make([]Entry, 0, end-start), Total: len(entries)}
+	for _, entry := range entries[start:end] {
+		// 列出期间被删除的条目跳过
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		listing.Entries = append(listing.Entries, Entry{
+			Name:    entry.Name(),
+			Type:    entryType(info.Mode()),
+			Size:    info.Size(),
+			Mode:    info.Mode().String(),
+			ModTime: info.ModTime(),
+		})
+	}
+	return listing, nil
+}
+
+// Open 打开工作区中的普通文件，超过 maxBytes 时拒绝；maxBytes 为 0 时不限制
+func Open(root, rel string, maxBytes int64) (*os.File, os.FileInfo, error) {
+	path, clean, err := Resolve(root, rel)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	switch {
+	case info.IsDir():
+		file.Close()
+		return nil, nil, fmt.Errorf("%w: %s", ErrIsDirectory, clean)
+	case !info.Mode().IsRegular():
+		file.Close()
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsafePath, clean)
+	case maxBytes > 0 && info.Size() > maxBytes:
+		file.Close()
+		return nil, nil, fmt.Errorf("%w（%d 字节）", ErrTooLarge, maxBytes)
+	}
+	return file, info, nil
+}
+
+// within path 是否为 root 或位于 root 之下
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// entryType 条目类型
+func entryType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "dir"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsRegular():
+		return "file"
+	default:
+		return "other"
+	}
+}